@@ -37,17 +37,17 @@ func (a *Adapter) RequiredEnv(source config.Source) []string {
 }
 
 func (a *Adapter) Validate(source config.Source) error {
-	if source.Type != config.SourceTypeSpotify {
-		return fmt.Errorf("deemix adapter only supports spotify sources")
+	if source.Type != config.SourceTypeSpotify && source.Type != config.SourceTypeDeezer {
+		return fmt.Errorf("deemix adapter only supports spotify and deezer sources")
 	}
 	if strings.TrimSpace(source.StateFile) == "" {
-		return fmt.Errorf("state_file is required for spotify source")
+		return fmt.Errorf("state_file is required for %s source", source.Type)
 	}
 	return nil
 }
 
 func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
-	targetDir, err := config.ExpandPath(source.TargetDir)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
 	if err != nil {
 		return engine.ExecSpec{}, err
 	}
@@ -71,7 +71,7 @@ func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults,
 
 	sourceURL := strings.TrimSpace(source.URL)
 	if sourceURL == "" {
-		return engine.ExecSpec{}, fmt.Errorf("spotify source url must be set")
+		return engine.ExecSpec{}, fmt.Errorf("%s source url must be set", source.Type)
 	}
 
 	args := []string{sourceURL}
@@ -85,6 +85,10 @@ func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults,
 		args = append(args, "--portable")
 		displayArgs = append(displayArgs, "--portable")
 	}
+	if bitrate := strings.TrimSpace(source.DeemixBitrate); bitrate != "" && !containsArg(source.Adapter.ExtraArgs, "--bitrate") && !containsArg(source.Adapter.ExtraArgs, "-b") {
+		args = append(args, "--bitrate", bitrate)
+		displayArgs = append(displayArgs, "--bitrate", bitrate)
+	}
 
 	args = append(args, source.Adapter.ExtraArgs...)
 	displayArgs = append(displayArgs, source.Adapter.ExtraArgs...)
@@ -119,6 +119,11 @@ func TestValidate(t *testing.T) {
 		t.Fatalf("validate: %v", err)
 	}
 
+	source.Type = config.SourceTypeDeezer
+	if err := New().Validate(source); err != nil {
+		t.Fatalf("validate deezer: %v", err)
+	}
+
 	source.Type = config.SourceTypeSoundCloud
 	if err := New().Validate(source); err == nil {
 		t.Fatalf("expected type validation error")
@@ -159,3 +164,60 @@ func TestPrepareRuntimeConfig(t *testing.T) {
 		t.Fatalf("unexpected spotify config: %+v", cfg)
 	}
 }
+
+func TestBuildExecSpecPassesQualityTierAsBitrate(t *testing.T) {
+	source, defaults := setupDeemixSource(t)
+	source.DeemixBitrate = "320"
+
+	spec, err := New().BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	if joined := strings.Join(spec.Args, " "); !strings.Contains(joined, "--bitrate 320") {
+		t.Fatalf("expected --bitrate arg, got %v", spec.Args)
+	}
+}
+
+func TestPrepareRuntimeConfigDisablesBitrateFallbackForQualityList(t *testing.T) {
+	source, _ := setupDeemixSource(t)
+	source.Type = config.SourceTypeDeezer
+	source.DeezerARL = "arl-value"
+	source.Quality = []string{"flac", "320"}
+
+	runtimeDir, err := PrepareRuntimeConfig(source)
+	if err != nil {
+		t.Fatalf("prepare runtime config: %v", err)
+	}
+	defer CleanupRuntimeConfig(runtimeDir)
+
+	payload, err := os.ReadFile(filepath.Join(runtimeDir, "config", "config.json"))
+	if err != nil {
+		t.Fatalf("read deemix config: %v", err)
+	}
+	var cfg map[string]any
+	if err := json.Unmarshal(payload, &cfg); err != nil {
+		t.Fatalf("decode deemix config: %v", err)
+	}
+	if cfg["fallbackBitrate"] != false {
+		t.Fatalf("expected fallbackBitrate=false, got %+v", cfg)
+	}
+}
+
+func TestPrepareRuntimeConfigDeezerSkipsSpotifyPlugin(t *testing.T) {
+	source, _ := setupDeemixSource(t)
+	source.Type = config.SourceTypeDeezer
+	source.DeezerARL = "arl-value"
+
+	runtimeDir, err := PrepareRuntimeConfig(source)
+	if err != nil {
+		t.Fatalf("prepare runtime config: %v", err)
+	}
+	defer CleanupRuntimeConfig(runtimeDir)
+
+	if _, err := os.Stat(filepath.Join(runtimeDir, "config", ".arl")); err != nil {
+		t.Fatalf("expected .arl file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(runtimeDir, "config", "spotify")); !os.IsNotExist(err) {
+		t.Fatalf("expected no spotify plugin config for deezer source, got %v", err)
+	}
+}
@@ -10,6 +10,12 @@ import (
 	"github.com/jaa/update-downloads/internal/config"
 )
 
+// deemixSettings is the subset of deemix's config.json that udl sets; deemix
+// fills in defaults for every other key.
+type deemixSettings struct {
+	FallbackBitrate bool `json:"fallbackBitrate"`
+}
+
 type spotifyPluginConfig struct {
 	ClientID       string `json:"clientId"`
 	ClientSecret   string `json:"clientSecret"`
@@ -21,9 +27,12 @@ func PrepareRuntimeConfig(source config.Source) (string, error) {
 	if arl == "" {
 		return "", fmt.Errorf("missing Deezer ARL for deemix runtime")
 	}
+	// Deezer sources hand deemix native links, so the Spotify plugin is not
+	// configured and no Spotify credentials are written.
+	withSpotify := source.Type != config.SourceTypeDeezer
 	clientID := strings.TrimSpace(source.SpotifyClientID)
 	clientSecret := strings.TrimSpace(source.SpotifyClientSecret)
-	if clientID == "" || clientSecret == "" {
+	if withSpotify && (clientID == "" || clientSecret == "") {
 		return "", fmt.Errorf("missing Spotify client credentials for deemix runtime")
 	}
 
@@ -47,6 +56,22 @@ func PrepareRuntimeConfig(source config.Source) (string, error) {
 		_ = os.RemoveAll(runtimeDir)
 		return "", fmt.Errorf("write deemix ARL: %w", err)
 	}
+	// With a quality list the engine walks the tiers itself, so deemix must
+	// report a missing bitrate instead of silently downloading a lower one.
+	if len(source.Quality) > 0 {
+		payload, err := json.MarshalIndent(deemixSettings{FallbackBitrate: false}, "", "  ")
+		if err != nil {
+			_ = os.RemoveAll(runtimeDir)
+			return "", fmt.Errorf("encode deemix config: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(configDir, "config.json"), payload, 0o600); err != nil {
+			_ = os.RemoveAll(runtimeDir)
+			return "", fmt.Errorf("write deemix config: %w", err)
+		}
+	}
+	if !withSpotify {
+		return runtimeDir, nil
+	}
 
 	spotifyDir := filepath.Join(configDir, "spotify")
 	if err := os.MkdirAll(spotifyDir, 0o700); err != nil {
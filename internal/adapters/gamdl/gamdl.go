@@ -0,0 +1,123 @@
+package gamdl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+// Adapter syncs Apple Music playlists, albums, and songs by driving gamdl.
+// gamdl authenticates with a Netscape cookies export of a signed-in
+// music.apple.com session; udl only passes the file's path along.
+type Adapter struct{}
+
+var resolveAppleMusicCookiesFileFn = auth.ResolveAppleMusicCookiesFile
+
+func New() *Adapter {
+	return &Adapter{}
+}
+
+func (a *Adapter) Kind() string {
+	return "gamdl"
+}
+
+func (a *Adapter) Binary() string {
+	return resolveGamdlBinary()
+}
+
+func (a *Adapter) MinVersion() string {
+	return "2.0.0"
+}
+
+func (a *Adapter) RequiredEnv(source config.Source) []string {
+	return nil
+}
+
+func (a *Adapter) Validate(source config.Source) error {
+	if source.Type != config.SourceTypeAppleMusic {
+		return fmt.Errorf("gamdl adapter only supports apple_music sources")
+	}
+	return nil
+}
+
+func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+
+	extraArgs := append([]string{}, source.Adapter.ExtraArgs...)
+	args := []string{}
+	if !containsArg(extraArgs, "-c") && !containsArg(extraArgs, "--cookies-path") {
+		cookiesPath, _, err := resolveAppleMusicCookiesFileFn()
+		if err != nil {
+			if strings.TrimSpace(cookiesPath) == "" {
+				return engine.ExecSpec{}, err
+			}
+			return engine.ExecSpec{}, fmt.Errorf("apple music cookies file not found at %s; export music.apple.com cookies (Netscape format) there or set UDL_APPLE_MUSIC_COOKIES_FILE", cookiesPath)
+		}
+		args = append(args, "--cookies-path", cookiesPath)
+	}
+	if !containsArg(extraArgs, "-o") && !containsArg(extraArgs, "--output-path") {
+		args = append(args, "--output-path", targetDir)
+	}
+	displayArgs := append([]string{}, args...)
+	args = append(args, extraArgs...)
+	displayArgs = append(displayArgs, extraArgs...)
+	args = append(args, source.URL)
+	displayArgs = append(displayArgs, sanitizeURL(source.URL))
+
+	bin := a.Binary()
+	return engine.ExecSpec{
+		Bin:            bin,
+		Args:           args,
+		Dir:            targetDir,
+		Timeout:        timeout,
+		DisplayCommand: formatCommand(bin, displayArgs),
+	}, nil
+}
+
+func formatCommand(bin string, args []string) string {
+	parts := []string{bin}
+	parts = append(parts, args...)
+	return strings.Join(parts, " ")
+}
+
+// sanitizeURL drops tracking parameters but keeps ?i=, which selects a
+// single song on an album link.
+func sanitizeURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	songID := parsed.Query().Get("i")
+	parsed.RawQuery = ""
+	if songID != "" {
+		parsed.RawQuery = url.Values{"i": {songID}}.Encode()
+	}
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+func containsArg(args []string, needle string) bool {
+	for _, candidate := range args {
+		trimmed := strings.TrimSpace(candidate)
+		if trimmed == needle || strings.HasPrefix(trimmed, needle+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveGamdlBinary() string {
+	if override := strings.TrimSpace(os.Getenv("UDL_GAMDL_BIN")); override != "" {
+		return override
+	}
+	return "gamdl"
+}
@@ -0,0 +1,109 @@
+package gamdl
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestBuildExecSpecPassesCookiesAndOutputPath(t *testing.T) {
+	t.Setenv("UDL_GAMDL_BIN", "")
+	tmp := t.TempDir()
+	originalResolve := resolveAppleMusicCookiesFileFn
+	t.Cleanup(func() { resolveAppleMusicCookiesFileFn = originalResolve })
+	resolveAppleMusicCookiesFileFn = func() (string, auth.CredentialStorageSource, error) {
+		return "/home/listener/.config/udl/apple-music-cookies.txt", auth.CredentialStorageSourceCookiesFile, nil
+	}
+
+	source := config.Source{
+		ID:        "am-chill",
+		Type:      config.SourceTypeAppleMusic,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://music.apple.com/us/album/blue/1440833098?i=1440833541&ls=1",
+		Adapter:   config.AdapterSpec{Kind: "gamdl"},
+	}
+	spec, err := New().BuildExecSpec(source, config.Defaults{StateDir: filepath.Join(tmp, "state")}, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if !strings.Contains(joined, "--cookies-path /home/listener/.config/udl/apple-music-cookies.txt") {
+		t.Fatalf("expected cookies path arg, got %s", joined)
+	}
+	if !strings.Contains(joined, "--output-path "+source.TargetDir) {
+		t.Fatalf("expected target dir output arg, got %s", joined)
+	}
+	if spec.Args[len(spec.Args)-1] != source.URL {
+		t.Fatalf("expected url as the last arg, got %v", spec.Args)
+	}
+	if strings.Contains(spec.DisplayCommand, "ls=1") || !strings.Contains(spec.DisplayCommand, "?i=1440833541") {
+		t.Fatalf("expected sanitized display URL keeping the song id, got %s", spec.DisplayCommand)
+	}
+	if spec.Bin != "gamdl" {
+		t.Fatalf("expected default gamdl binary, got %q", spec.Bin)
+	}
+}
+
+func TestBuildExecSpecRespectsExtraArgOverrides(t *testing.T) {
+	t.Setenv("UDL_GAMDL_BIN", "/opt/bin/gamdl")
+	tmp := t.TempDir()
+	originalResolve := resolveAppleMusicCookiesFileFn
+	t.Cleanup(func() { resolveAppleMusicCookiesFileFn = originalResolve })
+	resolveAppleMusicCookiesFileFn = func() (string, auth.CredentialStorageSource, error) {
+		return "", auth.CredentialStorageSourceNone, errors.New("should not resolve cookies")
+	}
+
+	source := config.Source{
+		ID:        "am-chill",
+		Type:      config.SourceTypeAppleMusic,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://music.apple.com/us/playlist/chill/pl.u-123",
+		Adapter: config.AdapterSpec{
+			Kind:      "gamdl",
+			ExtraArgs: []string{"-c", "/secure/cookies.txt", "--output-path=/custom/out", "--codec-song", "aac-legacy"},
+		},
+	}
+	spec, err := New().BuildExecSpec(source, config.Defaults{StateDir: filepath.Join(tmp, "state")}, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if strings.Contains(joined, "--cookies-path") || strings.Contains(joined, "--output-path "+source.TargetDir) {
+		t.Fatalf("expected extra_args overrides only, got %s", joined)
+	}
+	if spec.Bin != "/opt/bin/gamdl" {
+		t.Fatalf("expected binary override, got %q", spec.Bin)
+	}
+}
+
+func TestBuildExecSpecRequiresCookiesFile(t *testing.T) {
+	t.Setenv("UDL_GAMDL_BIN", "")
+	tmp := t.TempDir()
+	originalResolve := resolveAppleMusicCookiesFileFn
+	t.Cleanup(func() { resolveAppleMusicCookiesFileFn = originalResolve })
+	resolveAppleMusicCookiesFileFn = func() (string, auth.CredentialStorageSource, error) {
+		return "/home/listener/.config/udl/apple-music-cookies.txt", auth.CredentialStorageSourceNone, auth.ErrAppleMusicCookiesNotFound
+	}
+
+	_, err := New().BuildExecSpec(config.Source{
+		ID:        "am-chill",
+		Type:      config.SourceTypeAppleMusic,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://music.apple.com/us/playlist/chill/pl.u-123",
+		Adapter:   config.AdapterSpec{Kind: "gamdl"},
+	}, config.Defaults{StateDir: filepath.Join(tmp, "state")}, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "UDL_APPLE_MUSIC_COOKIES_FILE") {
+		t.Fatalf("expected missing cookies error, got %v", err)
+	}
+}
+
+func TestValidateRejectsNonAppleMusicSource(t *testing.T) {
+	if err := New().Validate(config.Source{Type: config.SourceTypeDeezer}); err == nil {
+		t.Fatalf("expected validation error for deezer source")
+	}
+}
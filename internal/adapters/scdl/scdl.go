@@ -1,6 +1,7 @@
 package scdl
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -29,6 +30,7 @@ var (
 	detectRuntimeOnce sync.Once
 	detectedRuntime   runtimeInfo
 	resolveSoundCloudClientIDFn = auth.ResolveSoundCloudClientID
+	readSoundCloudOAuthTokenFn  = auth.ReadSoundCloudOAuthToken
 )
 
 func New() *Adapter {
@@ -60,7 +62,7 @@ func (a *Adapter) Validate(source config.Source) error {
 
 func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
 	runtimeInfo := resolveRuntimeInfo()
-	targetDir, err := config.ExpandPath(source.TargetDir)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
 	if err != nil {
 		return engine.ExecSpec{}, err
 	}
@@ -73,22 +75,61 @@ func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults,
 		return engine.ExecSpec{}, err
 	}
 
-	args := []string{"-l", source.URL}
-	displayArgs := []string{"-l", sanitizeURL(source.URL)}
-	if !containsArg(source.Adapter.ExtraArgs, "-f") {
-		args = append(args, "-f")
-		displayArgs = append(displayArgs, "-f")
+	// A reposts feed URL is the profile in -r mode; a station URL needs no
+	// mode flag at all.
+	listURL, modeFlag := source.URL, "-f"
+	if profile, ok := config.SoundCloudRepostsProfile(source.URL); ok {
+		listURL, modeFlag = profile, "-r"
+	} else if config.IsSoundCloudStationURL(source.URL) {
+		modeFlag = ""
+	}
+	args := []string{"-l", listURL}
+	displayArgs := []string{"-l", sanitizeURL(listURL)}
+	if modeFlag != "" && !containsArg(source.Adapter.ExtraArgs, modeFlag) {
+		args = append(args, modeFlag)
+		displayArgs = append(displayArgs, modeFlag)
 	}
 	if !source.DisableSyncMode {
 		args = append(args, "--sync", syncFilePath)
 		displayArgs = append(displayArgs, "--sync", syncFilePath)
 	}
 
-	if clientID, err := resolveSoundCloudClientIDFn(); err == nil && strings.TrimSpace(clientID) != "" {
+	clientID := strings.TrimSpace(source.SoundCloudClientID)
+	if clientID == "" {
+		if resolved, err := resolveSoundCloudClientIDFn(); err == nil {
+			clientID = strings.TrimSpace(resolved)
+		}
+	}
+	if clientID != "" {
 		args = append(args, "--client-id", clientID)
 		displayArgs = append(displayArgs, "--client-id", "***")
 	}
 
+	// A cookies_file from a signed-in session gives scdl its OAuth token,
+	// which unlocks private tracks and Go+ streams, and yt-dlp the cookies.
+	// Without one, the stored OAuth session the engine attached is used.
+	cookiesPath, err := config.ResolveCookiesFile(source)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+	authToken := ""
+	if cookiesPath != "" {
+		token, err := readSoundCloudOAuthTokenFn(cookiesPath)
+		switch {
+		case err == nil:
+			authToken = token
+		case errors.Is(err, auth.ErrSoundCloudCookiesOAuthNotFound):
+		default:
+			return engine.ExecSpec{}, fmt.Errorf("cookies_file %s: %w; export soundcloud.com cookies (Netscape format) from a signed-in browser", cookiesPath, err)
+		}
+	} else {
+		authToken = strings.TrimSpace(source.SoundCloudOAuthToken)
+	}
+	if authToken != "" && !containsArg(source.Adapter.ExtraArgs, "--auth-token") {
+		args = append(args, "--auth-token", authToken)
+		displayArgs = append(displayArgs, "--auth-token", "***")
+	}
+
 	extraArgs := stripManagedArgs(source.Adapter.ExtraArgs)
 	args = append(args, extraArgs...)
 	displayArgs = append(displayArgs, extraArgs...)
@@ -116,6 +157,10 @@ func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults,
 	}
 	ytdlpArgs = normalizeYTDLPBreakArgs(ytdlpArgs, breakOnExisting)
 	ytdlpArgs = normalizeYTDLPPlaylistItems(ytdlpArgs, source.SelectedPlaylistIDs)
+	ytdlpArgs = appendYTDLPTrackIDFilters(ytdlpArgs, source.SelectedTrackIDs)
+	if cookiesPath != "" && !hasYTDLPCookies(ytdlpArgs) {
+		ytdlpArgs += " --cookies " + quoteYTDLPArg(cookiesPath)
+	}
 	if !runtimeInfo.SupportsYTDLPArgs {
 		return engine.ExecSpec{}, fmt.Errorf(
 			"scdl binary %q does not support --yt-dlp-args (requires scdl >= 3.0.0); set PATH or UDL_SCDL_BIN to a compatible binary",
@@ -260,6 +305,18 @@ func normalizeYTDLPPlaylistItems(raw string, selected []int) string {
 	return strings.Join(filtered, " ")
 }
 
+// appendYTDLPTrackIDFilters limits the run to the given track ids; yt-dlp
+// downloads an entry when any one --match-filters matches.
+func appendYTDLPTrackIDFilters(raw string, ids []string) string {
+	tokens := []string{strings.TrimSpace(raw)}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			tokens = append(tokens, "--match-filters", quoteYTDLPArg("id="+id))
+		}
+	}
+	return strings.TrimSpace(strings.Join(tokens, " "))
+}
+
 func hasDownloadArchive(raw string) bool {
 	tokens := strings.Fields(strings.TrimSpace(raw))
 	for i := 0; i < len(tokens); i++ {
@@ -274,6 +331,24 @@ func hasDownloadArchive(raw string) bool {
 	return false
 }
 
+func hasYTDLPCookies(raw string) bool {
+	for _, token := range strings.Fields(raw) {
+		if token == "--cookies" || strings.HasPrefix(token, "--cookies=") || token == "--cookies-from-browser" || strings.HasPrefix(token, "--cookies-from-browser=") {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteYTDLPArg quotes a value for the --yt-dlp-args string, which scdl
+// splits shell-style.
+func quoteYTDLPArg(value string) string {
+	if !strings.ContainsAny(value, " \t'\"") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
 func resolveRuntimeInfo() runtimeInfo {
 	detectRuntimeOnce.Do(func() {
 		detectedRuntime = detectRuntimeInfo()
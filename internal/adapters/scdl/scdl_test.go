@@ -100,6 +100,36 @@ func TestBuildExecSpecDoesNotDuplicateForceFlag(t *testing.T) {
 	}
 }
 
+func TestBuildExecSpecMapsRepostsAndStationURLs(t *testing.T) {
+	t.Setenv("SCDL_CLIENT_ID", "secret-client-id")
+
+	source, defaults := setupSCDLTest(t)
+	source.URL = "https://soundcloud.com/user/reposts"
+	spec, err := New().BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if !strings.HasPrefix(joined, "-l https://soundcloud.com/user -r ") || strings.Contains(joined, " -f ") {
+		t.Fatalf("expected the reposts feed as the profile in -r mode, got %v", spec.Args)
+	}
+
+	source.URL = "https://soundcloud.com/stations/track/user/seed"
+	source.DisableSyncMode = true
+	source.SelectedTrackIDs = []string{"111", "333"}
+	spec, err = New().BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined = strings.Join(spec.Args, " ")
+	if strings.Contains(joined, " -f ") || strings.Contains(joined, "--sync ") {
+		t.Fatalf("expected no mode flag or sync file for a station, got %v", spec.Args)
+	}
+	if !strings.Contains(joined, "--match-filters id=111 --match-filters id=333") {
+		t.Fatalf("expected the station run filtered to the planned ids, got %v", spec.Args)
+	}
+}
+
 func TestBuildExecSpecAddsDefaultYTDLPArgs(t *testing.T) {
 	t.Setenv("SCDL_CLIENT_ID", "secret-client-id")
 
@@ -364,3 +394,50 @@ func writeFakeSCDL(path string, includeYTDLP bool) error {
 func boolPtr(v bool) *bool {
 	return &v
 }
+
+func TestBuildExecSpecUsesCookiesFileForAuthTokenAndYTDLP(t *testing.T) {
+	source, defaults := setupSCDLTest(t)
+	cookiesPath := filepath.Join(t.TempDir(), "sc cookies.txt")
+	payload := "# Netscape HTTP Cookie File\n" +
+		".soundcloud.com\tTRUE\t/\tTRUE\t0\toauth_token\tsecret-oauth\n"
+	if err := os.WriteFile(cookiesPath, []byte(payload), 0o600); err != nil {
+		t.Fatalf("write cookies: %v", err)
+	}
+	source.CookiesFile = cookiesPath
+
+	spec, err := New().BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	args := strings.Join(spec.Args, " ")
+	if !strings.Contains(args, "--auth-token secret-oauth") {
+		t.Fatalf("expected auth token from cookies in args, got %s", args)
+	}
+	if strings.Contains(spec.DisplayCommand, "secret-oauth") || !strings.Contains(spec.DisplayCommand, "--auth-token ***") {
+		t.Fatalf("expected redacted auth token in display command, got %s", spec.DisplayCommand)
+	}
+	if !strings.Contains(args, "--cookies '"+cookiesPath+"'") {
+		t.Fatalf("expected quoted cookies path in yt-dlp args, got %s", args)
+	}
+
+	source.CookiesFile = filepath.Join(t.TempDir(), "missing.txt")
+	if _, err := New().BuildExecSpec(source, defaults, 2*time.Minute); err == nil || !strings.Contains(err.Error(), "cookies_file") {
+		t.Fatalf("expected missing cookies_file error, got %v", err)
+	}
+}
+
+func TestBuildExecSpecUsesStoredOAuthTokenWithoutCookiesFile(t *testing.T) {
+	source, defaults := setupSCDLTest(t)
+	source.SoundCloudOAuthToken = "stored-oauth"
+
+	spec, err := New().BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	if !strings.Contains(strings.Join(spec.Args, " "), "--auth-token stored-oauth") {
+		t.Fatalf("expected stored oauth token in args, got %v", spec.Args)
+	}
+	if strings.Contains(spec.DisplayCommand, "stored-oauth") {
+		t.Fatalf("expected redacted auth token in display command, got %s", spec.DisplayCommand)
+	}
+}
@@ -13,6 +13,11 @@ import (
 	"github.com/jaa/update-downloads/internal/engine"
 )
 
+const (
+	artistOutputTemplate   = "{album}/{artists} - {title}.{output-ext}"
+	fallbackOutputTemplate = "{artists} - {title}.{output-ext}"
+)
+
 type Adapter struct{}
 
 func New() *Adapter {
@@ -46,7 +51,7 @@ func (a *Adapter) Validate(source config.Source) error {
 }
 
 func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
-	targetDir, err := config.ExpandPath(source.TargetDir)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
 	if err != nil {
 		return engine.ExecSpec{}, err
 	}
@@ -56,24 +61,40 @@ func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults,
 		return engine.ExecSpec{}, err
 	}
 
+	archivePath, err := engine.ResolveSpotDLArchivePath(source, defaults)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+
 	args := []string{"sync"}
 	displayArgs := []string{"sync"}
 	if _, err := os.Stat(stateFilePath); err == nil {
 		args = append(args, stateFilePath)
 		displayArgs = append(displayArgs, stateFilePath)
 	} else {
-		args = append(args, source.URL, "--save-file", stateFilePath)
-		displayArgs = append(displayArgs, sanitizeURL(source.URL), "--save-file", stateFilePath)
+		sourceURL := spotifyWebURL(source.URL)
+		args = append(args, sourceURL, "--save-file", stateFilePath)
+		displayArgs = append(displayArgs, sanitizeURL(sourceURL), "--save-file", stateFilePath)
 	}
 
 	args = append(args,
 		"--threads", strconv.Itoa(defaults.Threads),
-		"--archive", defaults.ArchiveFile,
+		"--archive", archivePath,
 	)
 	displayArgs = append(displayArgs,
 		"--threads", strconv.Itoa(defaults.Threads),
-		"--archive", defaults.ArchiveFile,
+		"--archive", archivePath,
 	)
+	// spotdl reads liked songs through the user's own login.
+	if config.IsSpotifyUserLibraryURL(source.URL) && !containsArg(source.Adapter.ExtraArgs, "--user-auth") {
+		args = append(args, "--user-auth")
+		displayArgs = append(displayArgs, "--user-auth")
+	}
+	// Artist discographies land in one folder per album.
+	if config.IsSpotifyArtistURL(source.URL) && !containsArg(source.Adapter.ExtraArgs, "--output") {
+		args = append(args, "--output", artistOutputTemplate)
+		displayArgs = append(displayArgs, "--output", artistOutputTemplate)
+	}
 	args = append(args, source.Adapter.ExtraArgs...)
 	displayArgs = append(displayArgs, source.Adapter.ExtraArgs...)
 
@@ -87,6 +108,39 @@ func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults,
 	}, nil
 }
 
+// BuildTrackFallbackSpec downloads one track another adapter could not get,
+// by its Spotify link when there is one and by an "artist - title" search
+// otherwise. The source's extra_args belong to its primary adapter and are
+// not passed on.
+func (a *Adapter) BuildTrackFallbackSpec(track engine.FallbackTrack, source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+	query := spotifyWebURL(track.URL)
+	display := sanitizeURL(query)
+	if query == "" {
+		artist := strings.TrimSpace(track.Artist)
+		title := strings.TrimSpace(track.Title)
+		if artist == "" || title == "" {
+			return engine.ExecSpec{}, fmt.Errorf("track %s has no spotify link or artist and title to search for", track.ID)
+		}
+		query = artist + " - " + title
+		display = strconv.Quote(query)
+	}
+	args := []string{"download", query, "--output", fallbackOutputTemplate}
+	displayArgs := []string{"download", display, "--output", fallbackOutputTemplate}
+
+	bin := a.Binary()
+	return engine.ExecSpec{
+		Bin:            bin,
+		Args:           args,
+		Dir:            targetDir,
+		Timeout:        timeout,
+		DisplayCommand: formatCommand(bin, displayArgs),
+	}, nil
+}
+
 func formatCommand(bin string, args []string) string {
 	parts := []string{bin}
 	parts = append(parts, args...)
@@ -103,6 +157,30 @@ func sanitizeURL(raw string) string {
 	return parsed.String()
 }
 
+// spotifyWebURL rewrites spotify:<kind>:<id> URIs as open.spotify.com links,
+// and spotify:liked as spotdl's "saved" query.
+func spotifyWebURL(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if strings.EqualFold(trimmed, config.SpotifyLikedURL) {
+		return "saved"
+	}
+	parts := strings.Split(trimmed, ":")
+	if len(parts) == 3 && strings.EqualFold(parts[0], "spotify") {
+		return "https://open.spotify.com/" + strings.ToLower(parts[1]) + "/" + parts[2]
+	}
+	return trimmed
+}
+
+func containsArg(args []string, needle string) bool {
+	for _, candidate := range args {
+		trimmed := strings.TrimSpace(candidate)
+		if trimmed == needle || strings.HasPrefix(trimmed, needle+"=") {
+			return true
+		}
+	}
+	return false
+}
+
 func resolveSpotDLBinary() string {
 	if override := strings.TrimSpace(os.Getenv("UDL_SPOTDL_BIN")); override != "" {
 		return override
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
 )
 
 func TestBuildExecSpecWithExistingStateFile(t *testing.T) {
@@ -111,3 +112,119 @@ func TestResolveSpotDLBinaryFallsBackToPATHBinary(t *testing.T) {
 		t.Fatalf("expected fallback binary 'spotdl', got %q", got)
 	}
 }
+
+func TestBuildExecSpecArtistSourceUsesPerAlbumOutput(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+
+	adapter := New()
+	source := config.Source{
+		ID:        "artist",
+		Type:      config.SourceTypeSpotify,
+		TargetDir: targetDir,
+		URL:       "spotify:artist:4tZwfgrHOc3mvqYlEYSvVi",
+		StateFile: "artist.sync.spotdl",
+		Adapter:   config.AdapterSpec{Kind: "spotdl"},
+	}
+	defaults := config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt", Threads: 1}
+	spec, err := adapter.BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if !strings.Contains(joined, "https://open.spotify.com/artist/4tZwfgrHOc3mvqYlEYSvVi --save-file") {
+		t.Fatalf("expected artist URI rewritten as a web link, got %v", spec.Args)
+	}
+	if !strings.Contains(joined, "--output "+artistOutputTemplate) {
+		t.Fatalf("expected per-album output template, got %v", spec.Args)
+	}
+
+	source.Adapter.ExtraArgs = []string{"--output={artist}/{title}.{output-ext}"}
+	spec, err = adapter.BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	if strings.Contains(strings.Join(spec.Args, " "), artistOutputTemplate) {
+		t.Fatalf("expected explicit --output to win, got %v", spec.Args)
+	}
+}
+
+func TestBuildExecSpecLikedSourceSyncsSavedTracksWithUserAuth(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+
+	source := config.Source{
+		ID:        "liked",
+		Type:      config.SourceTypeSpotify,
+		TargetDir: targetDir,
+		URL:       config.SpotifyLikedURL,
+		StateFile: "liked.sync.spotdl",
+		Adapter:   config.AdapterSpec{Kind: "spotdl"},
+	}
+	defaults := config.Defaults{StateDir: filepath.Join(tmp, "state"), ArchiveFile: "archive.txt", Threads: 1}
+	spec, err := New().BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if !strings.HasPrefix(joined, "sync saved --save-file") || !strings.Contains(joined, "--user-auth") {
+		t.Fatalf("expected a user-auth sync of saved tracks, got %v", spec.Args)
+	}
+
+	source.Adapter.ExtraArgs = []string{"--user-auth", "--headless"}
+	spec, err = New().BuildExecSpec(source, defaults, 2*time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	if strings.Count(strings.Join(spec.Args, " "), "--user-auth") != 1 {
+		t.Fatalf("expected --user-auth once, got %v", spec.Args)
+	}
+}
+
+func TestBuildTrackFallbackSpecPrefersSpotifyLinkOverSearch(t *testing.T) {
+	t.Setenv("UDL_SPOTDL_BIN", "spotdl")
+	tmp := t.TempDir()
+	source := config.Source{
+		ID:        "deezer-mix",
+		Type:      config.SourceTypeDeezer,
+		TargetDir: tmp,
+		Adapter:   config.AdapterSpec{Kind: "deemix", ExtraArgs: []string{"--bitrate", "flac"}},
+	}
+	adapter := New()
+
+	spec, err := adapter.BuildTrackFallbackSpec(engine.FallbackTrack{
+		ID:     "4uLU6hMCjMI75M1A2tKUQC",
+		URL:    "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC",
+		Artist: "Artist",
+		Title:  "Song",
+	}, source, config.Defaults{}, time.Minute)
+	if err != nil {
+		t.Fatalf("build fallback spec: %v", err)
+	}
+	want := []string{"download", "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC", "--output", fallbackOutputTemplate}
+	if strings.Join(spec.Args, "\x00") != strings.Join(want, "\x00") {
+		t.Fatalf("unexpected args %v", spec.Args)
+	}
+	if spec.Dir != tmp {
+		t.Fatalf("expected target dir, got %q", spec.Dir)
+	}
+
+	spec, err = adapter.BuildTrackFallbackSpec(engine.FallbackTrack{ID: "3135556", Artist: "Artist", Title: "Song"}, source, config.Defaults{}, time.Minute)
+	if err != nil {
+		t.Fatalf("build fallback spec: %v", err)
+	}
+	if spec.Args[1] != "Artist - Song" {
+		t.Fatalf("expected artist - title search, got %v", spec.Args)
+	}
+
+	if _, err := adapter.BuildTrackFallbackSpec(engine.FallbackTrack{ID: "3135556", Title: "Song"}, source, config.Defaults{}, time.Minute); err == nil {
+		t.Fatal("expected an error without a link or artist")
+	}
+}
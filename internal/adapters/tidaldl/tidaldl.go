@@ -0,0 +1,137 @@
+package tidaldl
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+// Adapter syncs Tidal playlists, albums, and artists by driving tidal-dl.
+// tidal-dl reads its login session from $HOME/.tidal-dl.token.json; udl only
+// resolves that path and never reads the token itself.
+type Adapter struct{}
+
+var (
+	resolveTidalTokenFileFn = auth.ResolveTidalTokenFile
+	userHomeDirFn           = os.UserHomeDir
+)
+
+func New() *Adapter {
+	return &Adapter{}
+}
+
+func (a *Adapter) Kind() string {
+	return "tidal-dl"
+}
+
+func (a *Adapter) Binary() string {
+	return resolveTidalDLBinary()
+}
+
+func (a *Adapter) MinVersion() string {
+	return "2022.10.31"
+}
+
+func (a *Adapter) RequiredEnv(source config.Source) []string {
+	return nil
+}
+
+func (a *Adapter) Validate(source config.Source) error {
+	if source.Type != config.SourceTypeTidal {
+		return fmt.Errorf("tidal-dl adapter only supports tidal sources")
+	}
+	return nil
+}
+
+func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+
+	tokenPath, _, err := resolveTidalTokenFileFn()
+	if err != nil {
+		if strings.TrimSpace(tokenPath) == "" {
+			return engine.ExecSpec{}, err
+		}
+		return engine.ExecSpec{}, fmt.Errorf("tidal-dl token file not found at %s; run tidal-dl once to log in or set UDL_TIDAL_TOKEN_FILE", tokenPath)
+	}
+	env, err := tokenEnv(tokenPath)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+
+	extraArgs := append([]string{}, source.Adapter.ExtraArgs...)
+	args := []string{"-l", source.URL}
+	displayArgs := []string{"-l", sanitizeURL(source.URL)}
+	if !containsArg(extraArgs, "-o") && !containsArg(extraArgs, "--output") {
+		args = append(args, "-o", targetDir)
+		displayArgs = append(displayArgs, "-o", targetDir)
+	}
+	args = append(args, extraArgs...)
+	displayArgs = append(displayArgs, extraArgs...)
+
+	bin := a.Binary()
+	return engine.ExecSpec{
+		Bin:            bin,
+		Args:           args,
+		Dir:            targetDir,
+		Env:            env,
+		Timeout:        timeout,
+		DisplayCommand: formatCommand(bin, displayArgs),
+	}, nil
+}
+
+// tokenEnv points tidal-dl at a token file outside the user's home directory.
+// tidal-dl has no flag for this, so the override must keep the canonical file
+// name and udl runs tidal-dl with HOME set to the token's directory.
+func tokenEnv(tokenPath string) ([]string, error) {
+	dir := filepath.Dir(tokenPath)
+	if home, err := userHomeDirFn(); err == nil && filepath.Clean(home) == filepath.Clean(dir) {
+		return nil, nil
+	}
+	if filepath.Base(tokenPath) != auth.TidalTokenFileName {
+		return nil, fmt.Errorf("UDL_TIDAL_TOKEN_FILE must point to a file named %s (got %s)", auth.TidalTokenFileName, tokenPath)
+	}
+	return []string{"HOME=" + dir}, nil
+}
+
+func formatCommand(bin string, args []string) string {
+	parts := []string{bin}
+	parts = append(parts, args...)
+	return strings.Join(parts, " ")
+}
+
+func sanitizeURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+func containsArg(args []string, needle string) bool {
+	for _, candidate := range args {
+		trimmed := strings.TrimSpace(candidate)
+		if trimmed == needle || strings.HasPrefix(trimmed, needle+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func resolveTidalDLBinary() string {
+	if override := strings.TrimSpace(os.Getenv("UDL_TIDAL_DL_BIN")); override != "" {
+		return override
+	}
+	return "tidal-dl"
+}
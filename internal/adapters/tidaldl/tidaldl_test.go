@@ -0,0 +1,120 @@
+package tidaldl
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestBuildExecSpecUsesLinkAndTargetDir(t *testing.T) {
+	t.Setenv("UDL_TIDAL_DL_BIN", "")
+	tmp := t.TempDir()
+	originalResolve, originalHome := resolveTidalTokenFileFn, userHomeDirFn
+	t.Cleanup(func() { resolveTidalTokenFileFn, userHomeDirFn = originalResolve, originalHome })
+	resolveTidalTokenFileFn = func() (string, auth.CredentialStorageSource, error) {
+		return "/home/listener/.tidal-dl.token.json", auth.CredentialStorageSourceTokenFile, nil
+	}
+	userHomeDirFn = func() (string, error) { return "/home/listener", nil }
+
+	source := config.Source{
+		ID:        "tidal-favs",
+		Type:      config.SourceTypeTidal,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://tidal.com/browse/playlist/1234-abcd?utm_source=share",
+		Adapter:   config.AdapterSpec{Kind: "tidal-dl"},
+	}
+	spec, err := New().BuildExecSpec(source, config.Defaults{StateDir: filepath.Join(tmp, "state")}, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if !strings.Contains(joined, "-l "+source.URL) {
+		t.Fatalf("expected link arg, got %s", joined)
+	}
+	if !strings.Contains(joined, "-o "+source.TargetDir) {
+		t.Fatalf("expected target dir output arg, got %s", joined)
+	}
+	if strings.Contains(spec.DisplayCommand, "utm_source") {
+		t.Fatalf("expected sanitized display URL, got %s", spec.DisplayCommand)
+	}
+	if len(spec.Env) != 0 {
+		t.Fatalf("expected no env override for token in home dir, got %v", spec.Env)
+	}
+	if spec.Bin != "tidal-dl" {
+		t.Fatalf("expected default tidal-dl binary, got %q", spec.Bin)
+	}
+}
+
+func TestBuildExecSpecRespectsOutputOverrideAndTokenDir(t *testing.T) {
+	t.Setenv("UDL_TIDAL_DL_BIN", "/opt/bin/tidal-dl")
+	tmp := t.TempDir()
+	originalResolve, originalHome := resolveTidalTokenFileFn, userHomeDirFn
+	t.Cleanup(func() { resolveTidalTokenFileFn, userHomeDirFn = originalResolve, originalHome })
+	resolveTidalTokenFileFn = func() (string, auth.CredentialStorageSource, error) {
+		return "/secure/tidal/.tidal-dl.token.json", auth.CredentialStorageSourceTokenFile, nil
+	}
+	userHomeDirFn = func() (string, error) { return "/home/listener", nil }
+
+	spec, err := New().BuildExecSpec(config.Source{
+		ID:        "tidal-favs",
+		Type:      config.SourceTypeTidal,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://tidal.com/browse/playlist/1234-abcd",
+		Adapter:   config.AdapterSpec{Kind: "tidal-dl", ExtraArgs: []string{"-o", "/custom/out", "-q", "HiFi"}},
+	}, config.Defaults{StateDir: filepath.Join(tmp, "state")}, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if strings.Count(joined, "-o ") != 1 || !strings.Contains(joined, "-o /custom/out") {
+		t.Fatalf("expected custom output only, got %s", joined)
+	}
+	if len(spec.Env) != 1 || spec.Env[0] != "HOME=/secure/tidal" {
+		t.Fatalf("expected HOME override for token dir, got %v", spec.Env)
+	}
+	if spec.Bin != "/opt/bin/tidal-dl" {
+		t.Fatalf("expected binary override, got %q", spec.Bin)
+	}
+}
+
+func TestBuildExecSpecRequiresTokenFile(t *testing.T) {
+	t.Setenv("UDL_TIDAL_DL_BIN", "")
+	tmp := t.TempDir()
+	originalResolve := resolveTidalTokenFileFn
+	t.Cleanup(func() { resolveTidalTokenFileFn = originalResolve })
+	source := config.Source{
+		ID:        "tidal-favs",
+		Type:      config.SourceTypeTidal,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://tidal.com/browse/playlist/1234-abcd",
+		Adapter:   config.AdapterSpec{Kind: "tidal-dl"},
+	}
+	defaults := config.Defaults{StateDir: filepath.Join(tmp, "state")}
+
+	resolveTidalTokenFileFn = func() (string, auth.CredentialStorageSource, error) {
+		return "/home/listener/.tidal-dl.token.json", auth.CredentialStorageSourceNone, errors.New("missing")
+	}
+	_, err := New().BuildExecSpec(source, defaults, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "run tidal-dl once to log in") {
+		t.Fatalf("expected missing token error, got %v", err)
+	}
+
+	resolveTidalTokenFileFn = func() (string, auth.CredentialStorageSource, error) {
+		return "/secure/tidal/session.json", auth.CredentialStorageSourceTokenFile, nil
+	}
+	_, err = New().BuildExecSpec(source, defaults, time.Minute)
+	if err == nil || !strings.Contains(err.Error(), auth.TidalTokenFileName) {
+		t.Fatalf("expected token file name error, got %v", err)
+	}
+}
+
+func TestValidateRejectsNonTidalSource(t *testing.T) {
+	if err := New().Validate(config.Source{Type: config.SourceTypeYouTube}); err == nil {
+		t.Fatalf("expected validation error for youtube source")
+	}
+}
@@ -0,0 +1,230 @@
+package ytdlp
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+// Adapter syncs YouTube / YouTube Music playlists and channels by driving
+// yt-dlp directly. The per-source download archive under state_dir is the
+// sync state: known IDs are skipped and break_on_existing stops at the first
+// archived entry.
+type Adapter struct{}
+
+var defaultArgTokens = []string{
+	"--extract-audio",
+	"--audio-format", "best",
+	"--embed-thumbnail",
+	"--embed-metadata",
+	"--yes-playlist",
+	"--newline",
+	"-o", "%(title)s [%(id)s].%(ext)s",
+}
+
+func New() *Adapter {
+	return &Adapter{}
+}
+
+func (a *Adapter) Kind() string {
+	return "ytdlp"
+}
+
+func (a *Adapter) Binary() string {
+	return resolveYTDLPBinary()
+}
+
+func (a *Adapter) MinVersion() string {
+	return "2024.1.0"
+}
+
+func (a *Adapter) RequiredEnv(source config.Source) []string {
+	return nil
+}
+
+func (a *Adapter) Validate(source config.Source) error {
+	if source.Type != config.SourceTypeYouTube {
+		return fmt.Errorf("ytdlp adapter only supports youtube sources")
+	}
+	return nil
+}
+
+func (a *Adapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+
+	archivePath := strings.TrimSpace(source.DownloadArchivePath)
+	if archivePath == "" {
+		archivePath, err = config.ResolveArchiveFile(defaults.StateDir, defaults.ArchiveFile, source.ID)
+		if err != nil {
+			return engine.ExecSpec{}, err
+		}
+	}
+
+	breakOnExisting := true
+	if source.Sync.BreakOnExisting != nil {
+		breakOnExisting = *source.Sync.BreakOnExisting
+	}
+
+	extraArgs := stripManagedArgs(source.Adapter.ExtraArgs)
+	args := []string{}
+	if !containsArg(extraArgs, "-o") && !containsArg(extraArgs, "--output") {
+		args = append(args, defaultArgTokens...)
+	} else {
+		args = append(args, defaultArgTokens[:len(defaultArgTokens)-2]...)
+	}
+	if !hasDownloadArchive(source.Adapter.ExtraArgs) {
+		args = append(args, "--download-archive", archivePath)
+	} else {
+		args = append(args, downloadArchiveArgs(source.Adapter.ExtraArgs)...)
+	}
+	if breakOnExisting {
+		args = append(args, "--break-on-existing")
+	} else {
+		args = append(args, "--no-break-on-existing")
+	}
+	cookiesPath, err := config.ResolveCookiesFile(source)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+	if cookiesPath != "" && !containsArg(extraArgs, "--cookies") {
+		if _, err := os.Stat(cookiesPath); err != nil {
+			return engine.ExecSpec{}, fmt.Errorf("cookies_file %s not found; export youtube.com cookies (Netscape format) there", cookiesPath)
+		}
+		args = append(args, "--cookies", cookiesPath)
+	}
+	args = append(args, extraArgs...)
+
+	displayArgs := append([]string{}, args...)
+	args = append(args, source.URL)
+	displayArgs = append(displayArgs, sanitizeURL(source.URL))
+
+	bin := a.Binary()
+	return engine.ExecSpec{
+		Bin:            bin,
+		Args:           args,
+		Dir:            targetDir,
+		Timeout:        timeout,
+		DisplayCommand: formatCommand(bin, displayArgs),
+	}, nil
+}
+
+// BuildTrackFallbackSpec downloads the first YouTube search result for
+// "artist - title", for a track another adapter could not get. The source's
+// extra_args belong to its primary adapter and are not passed on.
+func (a *Adapter) BuildTrackFallbackSpec(track engine.FallbackTrack, source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return engine.ExecSpec{}, err
+	}
+	artist := strings.TrimSpace(track.Artist)
+	title := strings.TrimSpace(track.Title)
+	if artist == "" || title == "" {
+		return engine.ExecSpec{}, fmt.Errorf("track %s has no artist and title to search for", track.ID)
+	}
+	args := append([]string{}, defaultArgTokens...)
+	for i, arg := range args {
+		if arg == "--yes-playlist" {
+			args[i] = "--no-playlist"
+		}
+	}
+	query := "ytsearch1:" + artist + " - " + title
+	displayArgs := append(append([]string{}, args...), strconv.Quote(query))
+	args = append(args, query)
+
+	bin := a.Binary()
+	return engine.ExecSpec{
+		Bin:            bin,
+		Args:           args,
+		Dir:            targetDir,
+		Timeout:        timeout,
+		DisplayCommand: formatCommand(bin, displayArgs),
+	}, nil
+}
+
+func formatCommand(bin string, args []string) string {
+	parts := []string{bin}
+	parts = append(parts, args...)
+	return strings.Join(parts, " ")
+}
+
+// sanitizeURL keeps the `list=` query parameter because YouTube playlist URLs
+// are otherwise indistinguishable; all other query values are dropped.
+func sanitizeURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	list := parsed.Query().Get("list")
+	parsed.RawQuery = ""
+	if list != "" {
+		parsed.RawQuery = url.Values{"list": []string{list}}.Encode()
+	}
+	parsed.Fragment = ""
+	return parsed.String()
+}
+
+func containsArg(args []string, needle string) bool {
+	for _, candidate := range args {
+		trimmed := strings.TrimSpace(candidate)
+		if trimmed == needle || strings.HasPrefix(trimmed, needle+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func hasDownloadArchive(args []string) bool {
+	return len(downloadArchiveArgs(args)) > 0
+}
+
+func downloadArchiveArgs(args []string) []string {
+	for i := 0; i < len(args); i++ {
+		trimmed := strings.TrimSpace(args[i])
+		if trimmed == "--download-archive" && i+1 < len(args) && strings.TrimSpace(args[i+1]) != "" {
+			return []string{"--download-archive", strings.TrimSpace(args[i+1])}
+		}
+		if strings.HasPrefix(trimmed, "--download-archive=") {
+			value := strings.TrimSpace(strings.TrimPrefix(trimmed, "--download-archive="))
+			if value != "" {
+				return []string{"--download-archive", value}
+			}
+		}
+	}
+	return nil
+}
+
+// stripManagedArgs removes flags udl controls from config (archive and
+// break-on-existing) so extra_args cannot contradict sync policy.
+func stripManagedArgs(args []string) []string {
+	filtered := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		trimmed := strings.TrimSpace(args[i])
+		switch {
+		case trimmed == "--download-archive":
+			i++
+			continue
+		case strings.HasPrefix(trimmed, "--download-archive="),
+			trimmed == "--break-on-existing",
+			trimmed == "--no-break-on-existing":
+			continue
+		}
+		filtered = append(filtered, args[i])
+	}
+	return filtered
+}
+
+func resolveYTDLPBinary() string {
+	if override := strings.TrimSpace(os.Getenv("UDL_YTDLP_BIN")); override != "" {
+		return override
+	}
+	return "yt-dlp"
+}
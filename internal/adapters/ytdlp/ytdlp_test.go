@@ -0,0 +1,165 @@
+package ytdlp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func TestBuildExecSpecInjectsArchiveAndBreakOnExisting(t *testing.T) {
+	t.Setenv("UDL_YTDLP_BIN", "")
+	tmp := t.TempDir()
+	source := config.Source{
+		ID:        "yt-mix",
+		Type:      config.SourceTypeYouTube,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://music.youtube.com/playlist?list=PL123&si=tracking",
+		Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+		Sync:      config.SyncPolicy{BreakOnExisting: boolPtr(true)},
+	}
+	defaults := config.Defaults{StateDir: filepath.Join(tmp, "state"), ArchiveFile: "archive.txt"}
+	spec, err := New().BuildExecSpec(source, defaults, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	expectedArchive := filepath.Join(defaults.StateDir, "yt-mix.archive.txt")
+	if !strings.Contains(joined, "--download-archive "+expectedArchive) {
+		t.Fatalf("expected per-source archive in args, got %s", joined)
+	}
+	if !strings.Contains(joined, "--break-on-existing") {
+		t.Fatalf("expected --break-on-existing, got %s", joined)
+	}
+	if spec.Args[len(spec.Args)-1] != source.URL {
+		t.Fatalf("expected source URL as final arg, got %q", spec.Args[len(spec.Args)-1])
+	}
+	if strings.Contains(spec.DisplayCommand, "tracking") {
+		t.Fatalf("expected sanitized display URL, got %s", spec.DisplayCommand)
+	}
+	if !strings.Contains(spec.DisplayCommand, "list=PL123") {
+		t.Fatalf("expected playlist id to survive sanitizing, got %s", spec.DisplayCommand)
+	}
+	if spec.Bin != "yt-dlp" {
+		t.Fatalf("expected default yt-dlp binary, got %q", spec.Bin)
+	}
+}
+
+func TestBuildExecSpecRespectsExtraArgsAndPolicy(t *testing.T) {
+	t.Setenv("UDL_YTDLP_BIN", "/opt/bin/yt-dlp")
+	tmp := t.TempDir()
+	source := config.Source{
+		ID:        "yt-mix",
+		Type:      config.SourceTypeYouTube,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://music.youtube.com/playlist?list=PL123",
+		Adapter: config.AdapterSpec{Kind: "ytdlp", ExtraArgs: []string{
+			"--download-archive", "/custom/archive.txt",
+			"--break-on-existing",
+			"-o", "%(artist)s - %(title)s.%(ext)s",
+		}},
+		Sync: config.SyncPolicy{BreakOnExisting: boolPtr(false)},
+	}
+	defaults := config.Defaults{StateDir: filepath.Join(tmp, "state"), ArchiveFile: "archive.txt"}
+
+	spec, err := New().BuildExecSpec(source, defaults, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if !strings.Contains(joined, "--download-archive /custom/archive.txt") {
+		t.Fatalf("expected custom archive, got %s", joined)
+	}
+	if strings.Count(joined, "--download-archive") != 1 {
+		t.Fatalf("expected a single --download-archive, got %s", joined)
+	}
+	if !strings.Contains(joined, "--no-break-on-existing") || strings.Contains(joined, " --break-on-existing") {
+		t.Fatalf("expected sync policy to override extra args, got %s", joined)
+	}
+	if strings.Count(joined, "-o ") != 1 || !strings.Contains(joined, "%(artist)s") {
+		t.Fatalf("expected custom output template only, got %s", joined)
+	}
+	if spec.Bin != "/opt/bin/yt-dlp" {
+		t.Fatalf("expected binary override, got %q", spec.Bin)
+	}
+}
+
+func TestValidateRejectsNonYouTubeSource(t *testing.T) {
+	if err := New().Validate(config.Source{Type: config.SourceTypeSoundCloud}); err == nil {
+		t.Fatalf("expected validation error for soundcloud source")
+	}
+}
+
+func TestBuildTrackFallbackSpecSearchesSingleVideo(t *testing.T) {
+	t.Setenv("UDL_YTDLP_BIN", "")
+	tmp := t.TempDir()
+	source := config.Source{
+		ID:        "yt-mix",
+		Type:      config.SourceTypeYouTube,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://music.youtube.com/playlist?list=PL123",
+		Adapter:   config.AdapterSpec{Kind: "ytdlp", ExtraArgs: []string{"--format", "bestaudio"}},
+	}
+	defaults := config.Defaults{StateDir: filepath.Join(tmp, "state"), ArchiveFile: "archive.txt"}
+	spec, err := New().BuildTrackFallbackSpec(engine.FallbackTrack{ID: "3135556", Artist: "Artist", Title: "Song"}, source, defaults, time.Minute)
+	if err != nil {
+		t.Fatalf("build fallback spec: %v", err)
+	}
+	joined := strings.Join(spec.Args, " ")
+	if !strings.Contains(joined, "--no-playlist") || strings.Contains(joined, "--yes-playlist") {
+		t.Fatalf("expected a single-video download, got %s", joined)
+	}
+	if strings.Contains(joined, "--download-archive") || strings.Contains(joined, "bestaudio") {
+		t.Fatalf("expected no archive or primary extra args, got %s", joined)
+	}
+	if spec.Args[len(spec.Args)-1] != "ytsearch1:Artist - Song" {
+		t.Fatalf("expected search query as final arg, got %q", spec.Args[len(spec.Args)-1])
+	}
+
+	if _, err := New().BuildTrackFallbackSpec(engine.FallbackTrack{ID: "3135556", Title: "Song"}, source, defaults, time.Minute); err == nil {
+		t.Fatal("expected an error without an artist")
+	}
+}
+
+func TestBuildExecSpecPassesCookiesFile(t *testing.T) {
+	t.Setenv("UDL_YTDLP_BIN", "")
+	tmp := t.TempDir()
+	source := config.Source{
+		ID:        "yt-mix",
+		Type:      config.SourceTypeYouTube,
+		TargetDir: filepath.Join(tmp, "target"),
+		URL:       "https://music.youtube.com/playlist?list=PL123&si=tracking",
+		Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+		Sync:      config.SyncPolicy{BreakOnExisting: boolPtr(true)},
+	}
+	defaults := config.Defaults{StateDir: filepath.Join(tmp, "state"), ArchiveFile: "archive.txt"}
+	cookiesPath := filepath.Join(t.TempDir(), "yt-cookies.txt")
+	if err := os.WriteFile(cookiesPath, []byte("# Netscape HTTP Cookie File\n"), 0o600); err != nil {
+		t.Fatalf("write cookies: %v", err)
+	}
+	source.CookiesFile = cookiesPath
+	spec, err := New().BuildExecSpec(source, defaults, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	if !strings.Contains(strings.Join(spec.Args, " "), "--cookies "+cookiesPath) {
+		t.Fatalf("expected --cookies in args, got %v", spec.Args)
+	}
+
+	source.Adapter.ExtraArgs = []string{"--cookies", "/other/cookies.txt"}
+	spec, err = New().BuildExecSpec(source, defaults, time.Minute)
+	if err != nil {
+		t.Fatalf("build exec spec: %v", err)
+	}
+	if strings.Contains(strings.Join(spec.Args, " "), cookiesPath) {
+		t.Fatalf("expected extra_args --cookies to win, got %v", spec.Args)
+	}
+}
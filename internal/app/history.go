@@ -0,0 +1,12 @@
+package app
+
+import (
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+type HistoryUseCase struct{}
+
+func (HistoryUseCase) Run(cfg config.Config, sourceID string, limit int) ([]engine.HistoryEntry, error) {
+	return engine.LoadHistory(cfg.Defaults.StateDir, sourceID, limit)
+}
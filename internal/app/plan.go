@@ -0,0 +1,35 @@
+package app
+
+import (
+	"context"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+type PlanRequest struct {
+	SourceIDs   []string
+	ScanGaps    bool
+	OnlyGaps    bool
+	Limit       int
+	NoHTTPCache bool
+}
+
+type PlanUseCase struct {
+	Registry map[string]engine.Adapter
+	Runner   engine.ExecRunner
+	Emitter  output.EventEmitter
+}
+
+func (u PlanUseCase) Run(ctx context.Context, cfg config.Config, req PlanRequest) (engine.PlanReport, error) {
+	syncer := engine.NewSyncer(u.Registry, u.Runner, u.Emitter)
+	return syncer.Plan(ctx, cfg, engine.SyncOptions{
+		SourceIDs:   req.SourceIDs,
+		ScanGaps:    req.ScanGaps,
+		OnlyGaps:    req.OnlyGaps,
+		Limit:       req.Limit,
+		NoHTTPCache: req.NoHTTPCache,
+		NoBrowser:   true,
+	})
+}
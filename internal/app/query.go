@@ -0,0 +1,17 @@
+package app
+
+import (
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/query"
+)
+
+type QueryUseCase struct{}
+
+func (QueryUseCase) Run(cfg config.Config, q query.Query) (query.Result, error) {
+	records, err := engine.LoadQueryRecords(cfg, q.Collection)
+	if err != nil {
+		return query.Result{}, err
+	}
+	return q.Run(records), nil
+}
@@ -0,0 +1,14 @@
+package app
+
+import (
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+type StatsUseCase struct{}
+
+func (StatsUseCase) Run(cfg config.Config, sourceIDs []string, since time.Time) ([]engine.SourceActivityStats, error) {
+	return engine.InspectSourceActivity(cfg, sourceIDs, since)
+}
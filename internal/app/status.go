@@ -0,0 +1,12 @@
+package app
+
+import (
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+type StatusUseCase struct{}
+
+func (StatusUseCase) Run(cfg config.Config, sourceIDs []string) ([]engine.SourceStatus, error) {
+	return engine.InspectSourceStatuses(cfg, sourceIDs)
+}
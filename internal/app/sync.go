@@ -19,9 +19,19 @@ type SyncRequest struct {
 	AskOnExisting    bool
 	AskOnExistingSet bool
 	ScanGaps         bool
+	OnlyGaps         bool
+	Limit            int
 	NoPreflight      bool
+	Ordered          bool
+	Resume           bool
+	Force            bool
+	ApplyPrune       bool
+	NoHTTPCache      bool
+	NoBrowser        bool
+	WaitForLock      bool
 	AllowPrompt      bool
 	TrackStatus      engine.TrackStatusMode
+	LogFile          string
 }
 
 type SyncUseCase struct {
@@ -34,6 +44,14 @@ func (u SyncUseCase) Run(ctx context.Context, cfg config.Config, req SyncRequest
 	if interaction == nil {
 		interaction = NoopInteraction{}
 	}
+	var resume *engine.ResumeCheckpoint
+	if req.Resume {
+		checkpoint, err := engine.LoadResumeCheckpoint(cfg.Defaults.StateDir)
+		if err != nil {
+			return engine.SyncResult{}, err
+		}
+		resume = checkpoint
+	}
 	syncer := engine.NewSyncer(u.Registry, u.Runner, u.Emitter)
 	return syncer.Sync(ctx, cfg, engine.SyncOptions{
 		SourceIDs:        req.SourceIDs,
@@ -44,8 +62,18 @@ func (u SyncUseCase) Run(ctx context.Context, cfg config.Config, req SyncRequest
 		AskOnExisting:    req.AskOnExisting,
 		AskOnExistingSet: req.AskOnExistingSet,
 		ScanGaps:         req.ScanGaps,
+		OnlyGaps:         req.OnlyGaps,
+		Limit:            req.Limit,
 		NoPreflight:      req.NoPreflight,
+		Ordered:          req.Ordered,
+		Resume:           resume,
+		Force:            req.Force,
+		ApplyPrune:       req.ApplyPrune,
+		NoHTTPCache:      req.NoHTTPCache,
+		NoBrowser:        req.NoBrowser,
+		WaitForLock:      req.WaitForLock,
 		AllowPrompt:      req.AllowPrompt,
+		LogFile:          req.LogFile,
 		SelectPlanRows: func(sourceID string, rows []engine.PlanRow) (engine.PlanSelectionResult, error) {
 			return interaction.SelectRows(sourceID, rows)
 		},
@@ -59,6 +87,9 @@ func (u SyncUseCase) Run(ctx context.Context, cfg config.Config, req SyncRequest
 		PromptOnDeemixARL: func(sourceID string) (string, error) {
 			return interaction.Input(fmt.Sprintf("[%s] Enter your Deezer ARL for deemix", sourceID))
 		},
+		PromptOnPrune: func(sourceID string, candidates []engine.PruneCandidate) (bool, error) {
+			return interaction.Confirm(fmt.Sprintf("[%s] Move %d track(s) removed from the remote playlist to trash?", sourceID, len(candidates)), false)
+		},
 		TrackStatus: req.TrackStatus,
 	})
 }
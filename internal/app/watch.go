@@ -0,0 +1,339 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/output"
+	"github.com/jaa/update-downloads/internal/schedule"
+)
+
+const (
+	WatchLockFileName = "udl-watch.lock"
+
+	watchLockHeartbeat = 30 * time.Second
+	watchLockStaleAge  = 2 * time.Minute
+)
+
+// ErrWatchRunning is returned when another `udl watch` holds the state
+// directory lock.
+var ErrWatchRunning = errors.New("another udl watch is already running for this state_dir")
+
+var ErrNoWatchedSources = errors.New("no sources to watch (set sync.schedule on a source or pass --interval)")
+
+type WatchRequest struct {
+	SourceIDs []string
+	// DefaultInterval schedules sources without sync.schedule; when zero those
+	// sources are not watched.
+	DefaultInterval time.Duration
+	// SkipInitial waits for each source's first scheduled time instead of
+	// syncing every watched source at startup.
+	SkipInitial bool
+	// MaxCycles stops the loop after that many sync cycles (0 = until ctx is
+	// done).
+	MaxCycles int
+	Sync      SyncRequest
+}
+
+type WatchResult struct {
+	Cycles    int
+	Succeeded int
+	Failed    int
+}
+
+// WatchUseCase re-runs SyncUseCase for sources as their schedules come due.
+// Due sources share one sequential sync run, so a source never overlaps
+// itself; ticks missed while a run is in progress are coalesced.
+type WatchUseCase struct {
+	Sync    SyncUseCase
+	Emitter output.EventEmitter
+	Now     func() time.Time
+	Sleep   func(ctx context.Context, d time.Duration) error
+}
+
+type watchEntry struct {
+	source   config.Source
+	schedule schedule.Schedule
+	next     time.Time
+}
+
+func (u WatchUseCase) Run(ctx context.Context, cfg config.Config, req WatchRequest) (WatchResult, error) {
+	now := u.Now
+	if now == nil {
+		now = time.Now
+	}
+	sleep := u.Sleep
+	if sleep == nil {
+		sleep = sleepContext
+	}
+	emit := func(event output.Event) {
+		if u.Emitter != nil {
+			event.Timestamp = now()
+			_ = u.Emitter.Emit(event)
+		}
+	}
+
+	entries, err := watchEntries(cfg, req)
+	if err != nil {
+		return WatchResult{}, err
+	}
+	if len(entries) == 0 {
+		return WatchResult{}, ErrNoWatchedSources
+	}
+
+	release, err := acquireWatchLock(cfg.Defaults.StateDir)
+	if err != nil {
+		return WatchResult{}, err
+	}
+	defer release()
+
+	start := now()
+	for i := range entries {
+		if req.SkipInitial {
+			entries[i].next = entries[i].schedule.Next(start)
+		} else {
+			entries[i].next = start
+		}
+	}
+	emit(output.Event{
+		Level:   output.LevelInfo,
+		Event:   output.EventWatchStarted,
+		Message: fmt.Sprintf("watch: started sources=%d", len(entries)),
+		Details: map[string]any{"sources": len(entries), "next_run_at": watchNextRuns(entries)},
+	})
+
+	result := WatchResult{}
+	stopReason := "stopped"
+	for {
+		if wait := earliestWatchRun(entries).Sub(now()); wait > 0 {
+			if err := sleep(ctx, wait); err != nil {
+				stopReason = "interrupted"
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			stopReason = "interrupted"
+			break
+		}
+
+		cycleStart := now()
+		due := []string{}
+		for _, entry := range entries {
+			if !entry.next.After(cycleStart) {
+				due = append(due, entry.source.ID)
+			}
+		}
+		if len(due) == 0 {
+			continue
+		}
+
+		result.Cycles++
+		emit(output.Event{
+			Level:   output.LevelInfo,
+			Event:   output.EventWatchCycleStarted,
+			Message: fmt.Sprintf("watch: cycle %d sources=%s", result.Cycles, strings.Join(due, ",")),
+			Details: map[string]any{"cycle": result.Cycles, "sources": due},
+		})
+
+		syncReq := req.Sync
+		syncReq.SourceIDs = due
+		syncResult, runErr := u.Sync.Run(ctx, cfg, syncReq, nil)
+		result.Succeeded += syncResult.Succeeded
+		result.Failed += syncResult.Failed
+
+		finished := now()
+		for i := range entries {
+			for _, id := range due {
+				if entries[i].source.ID == id {
+					entries[i].next = entries[i].schedule.Next(finished)
+				}
+			}
+		}
+
+		level := output.LevelInfo
+		details := map[string]any{
+			"cycle":               result.Cycles,
+			"sources":             due,
+			"attempted":           syncResult.Attempted,
+			"succeeded":           syncResult.Succeeded,
+			"failed":              syncResult.Failed,
+			"skipped":             syncResult.Skipped,
+			"dependency_failures": syncResult.DependencyFailures,
+			"duration_ms":         finished.Sub(cycleStart).Milliseconds(),
+			"next_run_at":         watchNextRuns(entries),
+		}
+		if runErr != nil {
+			details["error"] = runErr.Error()
+		}
+		if runErr != nil || syncResult.Failed > 0 {
+			level = output.LevelWarn
+		}
+		emit(output.Event{
+			Level: level,
+			Event: output.EventWatchCycleFinished,
+			Message: fmt.Sprintf(
+				"watch: cycle %d finished succeeded=%d failed=%d next=%s",
+				result.Cycles,
+				syncResult.Succeeded,
+				syncResult.Failed,
+				earliestWatchRun(entries).Format(time.RFC3339),
+			),
+			Details: details,
+		})
+
+		if errors.Is(runErr, engine.ErrInterrupted) || ctx.Err() != nil {
+			stopReason = "interrupted"
+			break
+		}
+		if req.MaxCycles > 0 && result.Cycles >= req.MaxCycles {
+			break
+		}
+	}
+
+	emit(output.Event{
+		Level:   output.LevelInfo,
+		Event:   output.EventWatchStopped,
+		Message: fmt.Sprintf("watch: %s cycles=%d succeeded=%d failed=%d", stopReason, result.Cycles, result.Succeeded, result.Failed),
+		Details: map[string]any{
+			"reason":    stopReason,
+			"cycles":    result.Cycles,
+			"succeeded": result.Succeeded,
+			"failed":    result.Failed,
+		},
+	})
+	return result, nil
+}
+
+func watchEntries(cfg config.Config, req WatchRequest) ([]watchEntry, error) {
+	requested := map[string]bool{}
+	for _, id := range req.SourceIDs {
+		requested[id] = false
+	}
+	entries := []watchEntry{}
+	for _, source := range cfg.Sources {
+		if len(requested) > 0 {
+			if _, ok := requested[source.ID]; !ok {
+				continue
+			}
+			requested[source.ID] = true
+		}
+		if !source.Enabled {
+			continue
+		}
+		var sched schedule.Schedule
+		if raw := strings.TrimSpace(source.Sync.Schedule); raw != "" {
+			parsed, err := schedule.Parse(raw)
+			if err != nil {
+				return nil, fmt.Errorf("source %q: %w", source.ID, err)
+			}
+			sched = parsed
+		} else if req.DefaultInterval > 0 {
+			sched = schedule.Every(req.DefaultInterval)
+		} else {
+			continue
+		}
+		entries = append(entries, watchEntry{source: source, schedule: sched})
+	}
+	missing := []string{}
+	for _, id := range req.SourceIDs {
+		if !requested[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &engine.SelectionError{Missing: missing}
+	}
+	return entries, nil
+}
+
+func earliestWatchRun(entries []watchEntry) time.Time {
+	earliest := entries[0].next
+	for _, entry := range entries[1:] {
+		if entry.next.Before(earliest) {
+			earliest = entry.next
+		}
+	}
+	return earliest
+}
+
+func watchNextRuns(entries []watchEntry) map[string]string {
+	next := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		next[entry.source.ID] = entry.next.Format(time.RFC3339)
+	}
+	return next
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// acquireWatchLock creates <state_dir>/udl-watch.lock holding the pid. A
+// heartbeat keeps the mtime fresh; a lock untouched for watchLockStaleAge is
+// assumed to belong to a crashed process and is taken over.
+func acquireWatchLock(stateDir string) (func(), error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(root, WatchLockFileName)
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, writeErr := file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+			closeErr := file.Close()
+			if writeErr != nil || closeErr != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("write watch lock: %w", errors.Join(writeErr, closeErr))
+			}
+			return startWatchLockHeartbeat(path), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		info, statErr := os.Stat(path)
+		if statErr != nil || time.Since(info.ModTime()) < watchLockStaleAge {
+			return nil, fmt.Errorf("%w (%s)", ErrWatchRunning, path)
+		}
+		_ = os.Remove(path)
+	}
+	return nil, fmt.Errorf("%w (%s)", ErrWatchRunning, path)
+}
+
+func startWatchLockHeartbeat(path string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(watchLockHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case tick := <-ticker.C:
+				_ = os.Chtimes(path, tick, tick)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		_ = os.Remove(path)
+	}
+}
@@ -0,0 +1,209 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+type watchTestAdapter struct{}
+
+func (watchTestAdapter) Kind() string                              { return "fake" }
+func (watchTestAdapter) Binary() string                            { return "fakebin" }
+func (watchTestAdapter) MinVersion() string                        { return "1.0.0" }
+func (watchTestAdapter) RequiredEnv(source config.Source) []string { return nil }
+func (watchTestAdapter) Validate(source config.Source) error       { return nil }
+func (watchTestAdapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (engine.ExecSpec, error) {
+	return engine.ExecSpec{Bin: "fakebin", Args: []string{source.ID}, Dir: source.TargetDir, Timeout: timeout}, nil
+}
+
+type watchTestRunner struct{}
+
+func (watchTestRunner) Run(ctx context.Context, spec engine.ExecSpec) engine.ExecResult {
+	return engine.ExecResult{ExitCode: 0}
+}
+
+type watchCaptureEmitter struct {
+	events []output.Event
+}
+
+func (e *watchCaptureEmitter) Emit(event output.Event) error {
+	e.events = append(e.events, event)
+	return nil
+}
+
+func watchTestConfig(t *testing.T, schedules map[string]string) config.Config {
+	t.Helper()
+	tmp := t.TempDir()
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              filepath.Join(tmp, "state"),
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+	}
+	for _, id := range []string{"alpha", "beta", "gamma"} {
+		raw, ok := schedules[id]
+		if !ok {
+			continue
+		}
+		targetDir := filepath.Join(tmp, id)
+		if err := os.MkdirAll(targetDir, 0o755); err != nil {
+			t.Fatalf("mkdir target: %v", err)
+		}
+		cfg.Sources = append(cfg.Sources, config.Source{
+			ID:        id,
+			Type:      config.SourceTypeSpotify,
+			Enabled:   true,
+			TargetDir: targetDir,
+			URL:       "https://example.com/" + id,
+			StateFile: id + ".sync.spotdl",
+			Sync:      config.SyncPolicy{Schedule: raw},
+			Adapter:   config.AdapterSpec{Kind: "fake"},
+		})
+	}
+	return cfg
+}
+
+func newWatchTestUseCase(clock *time.Time, emitter output.EventEmitter) WatchUseCase {
+	return WatchUseCase{
+		Sync: SyncUseCase{
+			Registry: map[string]engine.Adapter{"fake": watchTestAdapter{}},
+			Runner:   watchTestRunner{},
+			Emitter:  emitter,
+		},
+		Emitter: emitter,
+		Now:     func() time.Time { return *clock },
+		Sleep: func(ctx context.Context, d time.Duration) error {
+			*clock = clock.Add(d)
+			return ctx.Err()
+		},
+	}
+}
+
+func TestWatchRunsSourcesAsSchedulesComeDue(t *testing.T) {
+	cfg := watchTestConfig(t, map[string]string{"alpha": "10m", "beta": "@every 25m", "gamma": ""})
+	clock := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	emitter := &watchCaptureEmitter{}
+
+	result, err := newWatchTestUseCase(&clock, emitter).Run(context.Background(), cfg, WatchRequest{
+		MaxCycles: 4,
+		Sync:      SyncRequest{DryRun: true},
+	})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if result.Cycles != 4 || result.Failed != 0 || result.Succeeded != 5 {
+		t.Fatalf("unexpected watch result: %+v", result)
+	}
+
+	cycles := [][]string{}
+	names := []output.EventName{}
+	for _, event := range emitter.events {
+		switch event.Event {
+		case output.EventWatchCycleStarted:
+			cycles = append(cycles, event.Details["sources"].([]string))
+			names = append(names, event.Event)
+		case output.EventWatchStarted, output.EventWatchCycleFinished, output.EventWatchStopped:
+			names = append(names, event.Event)
+		}
+	}
+	wantCycles := [][]string{{"alpha", "beta"}, {"alpha"}, {"alpha"}, {"beta"}}
+	if !reflect.DeepEqual(cycles, wantCycles) {
+		t.Fatalf("expected cycles %v, got %v", wantCycles, cycles)
+	}
+	if names[0] != output.EventWatchStarted || names[len(names)-1] != output.EventWatchStopped {
+		t.Fatalf("expected watch_started ... watch_stopped, got %v", names)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Defaults.StateDir, WatchLockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected watch lock to be released, stat err=%v", err)
+	}
+}
+
+func TestWatchSkipInitialUsesDefaultInterval(t *testing.T) {
+	cfg := watchTestConfig(t, map[string]string{"alpha": "", "beta": "0 * * * *"})
+	clock := time.Date(2026, 3, 1, 9, 15, 0, 0, time.UTC)
+	emitter := &watchCaptureEmitter{}
+
+	_, err := newWatchTestUseCase(&clock, emitter).Run(context.Background(), cfg, WatchRequest{
+		DefaultInterval: 30 * time.Minute,
+		SkipInitial:     true,
+		MaxCycles:       2,
+		Sync:            SyncRequest{DryRun: true},
+	})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	cycles := []string{}
+	for _, event := range emitter.events {
+		if event.Event == output.EventWatchCycleStarted {
+			cycles = append(cycles, event.Timestamp.Format("15:04"))
+		}
+	}
+	if want := []string{"09:45", "10:00"}; !reflect.DeepEqual(cycles, want) {
+		t.Fatalf("expected cycles at %v, got %v", want, cycles)
+	}
+}
+
+func TestWatchRejectsConcurrentWatchAndStopsOnCancel(t *testing.T) {
+	cfg := watchTestConfig(t, map[string]string{"alpha": "10m"})
+	if err := os.MkdirAll(cfg.Defaults.StateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	lockPath := filepath.Join(cfg.Defaults.StateDir, WatchLockFileName)
+	if err := os.WriteFile(lockPath, []byte("1\n"), 0o644); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+	clock := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	useCase := newWatchTestUseCase(&clock, &watchCaptureEmitter{})
+	if _, err := useCase.Run(context.Background(), cfg, WatchRequest{}); !errors.Is(err, ErrWatchRunning) {
+		t.Fatalf("expected ErrWatchRunning, got %v", err)
+	}
+
+	stale := time.Now().Add(-10 * time.Minute)
+	if err := os.Chtimes(lockPath, stale, stale); err != nil {
+		t.Fatalf("age lock: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	emitter := &watchCaptureEmitter{}
+	useCase = newWatchTestUseCase(&clock, emitter)
+	useCase.Sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+	result, err := useCase.Run(ctx, cfg, WatchRequest{Sync: SyncRequest{DryRun: true}})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if result.Cycles != 1 {
+		t.Fatalf("expected one cycle before cancel, got %+v", result)
+	}
+	last := emitter.events[len(emitter.events)-1]
+	if last.Event != output.EventWatchStopped || last.Details["reason"] != "interrupted" {
+		t.Fatalf("expected interrupted watch_stopped, got %+v", last)
+	}
+}
+
+func TestWatchRequiresScheduledSources(t *testing.T) {
+	cfg := watchTestConfig(t, map[string]string{"alpha": ""})
+	clock := time.Now()
+	useCase := newWatchTestUseCase(&clock, nil)
+	if _, err := useCase.Run(context.Background(), cfg, WatchRequest{}); !errors.Is(err, ErrNoWatchedSources) {
+		t.Fatalf("expected ErrNoWatchedSources, got %v", err)
+	}
+	var selectionErr *engine.SelectionError
+	if _, err := useCase.Run(context.Background(), cfg, WatchRequest{SourceIDs: []string{"missing"}}); !errors.As(err, &selectionErr) {
+		t.Fatalf("expected selection error, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// AppleMusicCookiesFileName is the Netscape cookies.txt export of a signed-in
+// music.apple.com session, looked up next to the user config file.
+const AppleMusicCookiesFileName = "apple-music-cookies.txt"
+
+const appleMusicMediaUserTokenCookie = "media-user-token"
+
+var (
+	ErrAppleMusicCookiesNotFound        = errors.New("apple music cookies file not found")
+	ErrAppleMusicMediaUserTokenNotFound = errors.New("apple music cookies file has no media-user-token cookie")
+)
+
+// AppleMusicCookiesResolver locates the cookies file gamdl authenticates
+// with. Only the path is resolved here; the cookie values stay on disk.
+type AppleMusicCookiesResolver struct {
+	Getenv         func(string) string
+	UserConfigPath func() (string, error)
+	Stat           func(string) (os.FileInfo, error)
+}
+
+func ResolveAppleMusicCookiesFile() (string, CredentialStorageSource, error) {
+	return AppleMusicCookiesResolver{
+		Getenv:         os.Getenv,
+		UserConfigPath: config.UserConfigPath,
+		Stat:           os.Stat,
+	}.ResolveWithSource()
+}
+
+func (r AppleMusicCookiesResolver) ResolveWithSource() (string, CredentialStorageSource, error) {
+	getenv := r.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	stat := r.Stat
+	if stat == nil {
+		stat = os.Stat
+	}
+
+	path := ""
+	storage := CredentialStorageSourceCookiesFile
+	if override := strings.TrimSpace(getenv("UDL_APPLE_MUSIC_COOKIES_FILE")); override != "" {
+		expanded, err := config.ExpandPath(override)
+		if err != nil {
+			return "", CredentialStorageSourceNone, err
+		}
+		path = expanded
+		storage = CredentialStorageSourceEnv
+	} else {
+		userConfigPath := r.UserConfigPath
+		if userConfigPath == nil {
+			userConfigPath = config.UserConfigPath
+		}
+		configPath, err := userConfigPath()
+		if err != nil {
+			return "", CredentialStorageSourceNone, err
+		}
+		path = filepath.Join(filepath.Dir(configPath), AppleMusicCookiesFileName)
+	}
+
+	info, err := stat(path)
+	if err != nil || info.IsDir() || info.Size() == 0 {
+		return path, CredentialStorageSourceNone, ErrAppleMusicCookiesNotFound
+	}
+	return path, storage, nil
+}
+
+// ReadAppleMusicMediaUserToken returns the media-user-token cookie from a
+// Netscape cookies file. The value authorizes library API calls for the
+// signed-in account; callers must keep it in memory and never log it.
+func ReadAppleMusicMediaUserToken(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrAppleMusicCookiesNotFound
+		}
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// curl and browser exporters prefix HttpOnly cookies with #HttpOnly_;
+		// every other # line is a comment.
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		domain := strings.TrimPrefix(strings.ToLower(fields[0]), ".")
+		if domain != "apple.com" && !strings.HasSuffix(domain, ".apple.com") {
+			continue
+		}
+		if fields[5] == appleMusicMediaUserTokenCookie {
+			if value := strings.TrimSpace(fields[6]); value != "" {
+				return value, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	return "", ErrAppleMusicMediaUserTokenNotFound
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAppleMusicCookiesResolverDefaultsNextToUserConfig(t *testing.T) {
+	dir := t.TempDir()
+	resolver := AppleMusicCookiesResolver{
+		Getenv:         func(key string) string { return "" },
+		UserConfigPath: func() (string, error) { return filepath.Join(dir, "config.yaml"), nil },
+	}
+
+	got, _, err := resolver.ResolveWithSource()
+	if !errors.Is(err, ErrAppleMusicCookiesNotFound) {
+		t.Fatalf("expected ErrAppleMusicCookiesNotFound, got %v", err)
+	}
+	if got != filepath.Join(dir, AppleMusicCookiesFileName) {
+		t.Fatalf("expected default cookies path, got %q", got)
+	}
+
+	if err := os.WriteFile(got, []byte("# Netscape HTTP Cookie File\n"), 0o600); err != nil {
+		t.Fatalf("write cookies: %v", err)
+	}
+	if _, source, err := resolver.ResolveWithSource(); err != nil || source != CredentialStorageSourceCookiesFile {
+		t.Fatalf("expected default cookies file to resolve, got source=%q err=%v", source, err)
+	}
+
+	override := filepath.Join(dir, "elsewhere.txt")
+	if err := os.WriteFile(override, []byte("cookies"), 0o600); err != nil {
+		t.Fatalf("write override: %v", err)
+	}
+	resolver.Getenv = func(key string) string {
+		if key == "UDL_APPLE_MUSIC_COOKIES_FILE" {
+			return override
+		}
+		return ""
+	}
+	if got, source, err := resolver.ResolveWithSource(); err != nil || got != override || source != CredentialStorageSourceEnv {
+		t.Fatalf("expected env override, got path=%q source=%q err=%v", got, source, err)
+	}
+}
+
+func TestReadAppleMusicMediaUserToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), AppleMusicCookiesFileName)
+	payload := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tTRUE\t0\tmedia-user-token\tnot-apple\n" +
+		".music.apple.com\tTRUE\t/\tTRUE\t0\titspod\t42\n" +
+		"#HttpOnly_.music.apple.com\tTRUE\t/\tTRUE\t0\tmedia-user-token\tAmUserToken==\n"
+	if err := os.WriteFile(path, []byte(payload), 0o600); err != nil {
+		t.Fatalf("write cookies: %v", err)
+	}
+	token, err := ReadAppleMusicMediaUserToken(path)
+	if err != nil {
+		t.Fatalf("read media-user-token: %v", err)
+	}
+	if token != "AmUserToken==" {
+		t.Fatalf("unexpected token %q", token)
+	}
+
+	if err := os.WriteFile(path, []byte(".music.apple.com\tTRUE\t/\tTRUE\t0\titspod\t42\n"), 0o600); err != nil {
+		t.Fatalf("rewrite cookies: %v", err)
+	}
+	if _, err := ReadAppleMusicMediaUserToken(path); !errors.Is(err, ErrAppleMusicMediaUserTokenNotFound) {
+		t.Fatalf("expected ErrAppleMusicMediaUserTokenNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+const (
+	appleMusicTokenTimeout   = 20 * time.Second
+	appleMusicMaxScriptBytes = 16 << 20
+)
+
+var (
+	appleMusicWebAppURL       = "https://music.apple.com/"
+	appleMusicTokenClient     = &http.Client{}
+	appleMusicScriptPattern   = regexp.MustCompile(`<script[^>]+src="([^"]*/assets/index[^"]*\.js)"`)
+	appleMusicTokenJWTPattern = regexp.MustCompile(`eyJh[0-9A-Za-z_-]+\.[0-9A-Za-z_-]+\.[0-9A-Za-z_-]+`)
+)
+
+var ErrAppleMusicDeveloperTokenFailed = errors.New("apple music developer token lookup failed")
+
+// FetchAppleMusicDeveloperToken reads the public developer token the Apple
+// Music web player embeds in its index bundle. It is the same token every
+// anonymous visitor receives; nothing is persisted.
+func FetchAppleMusicDeveloperToken(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, appleMusicTokenTimeout)
+	defer cancel()
+
+	page, err := fetchAppleMusicAsset(ctx, appleMusicWebAppURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: load web player: %v", ErrAppleMusicDeveloperTokenFailed, err)
+	}
+	match := appleMusicScriptPattern.FindStringSubmatch(page)
+	if len(match) != 2 {
+		return "", fmt.Errorf("%w: no index bundle on web player page", ErrAppleMusicDeveloperTokenFailed)
+	}
+	scriptURL, err := resolveAppleMusicAssetURL(match[1])
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrAppleMusicDeveloperTokenFailed, err)
+	}
+	script, err := fetchAppleMusicAsset(ctx, scriptURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: load index bundle: %v", ErrAppleMusicDeveloperTokenFailed, err)
+	}
+	token := appleMusicTokenJWTPattern.FindString(script)
+	if token == "" {
+		return "", fmt.Errorf("%w: token not found in index bundle", ErrAppleMusicDeveloperTokenFailed)
+	}
+	return token, nil
+}
+
+func resolveAppleMusicAssetURL(ref string) (string, error) {
+	base, err := url.Parse(appleMusicWebAppURL)
+	if err != nil {
+		return "", err
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return base.ResolveReference(parsed).String(), nil
+}
+
+func fetchAppleMusicAsset(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36")
+	resp, err := appleMusicTokenClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s returned HTTP %d", rawURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, appleMusicMaxScriptBytes))
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
@@ -25,7 +25,10 @@ const (
 	CredentialStorageSourceNone        CredentialStorageSource = ""
 	CredentialStorageSourceEnv         CredentialStorageSource = "env"
 	CredentialStorageSourceKeychain    CredentialStorageSource = "keychain"
+	CredentialStorageSourceStore       CredentialStorageSource = "credential_store"
 	CredentialStorageSourceSpotDL      CredentialStorageSource = "spotdl_config"
+	CredentialStorageSourceTokenFile   CredentialStorageSource = "token_file"
+	CredentialStorageSourceCookiesFile CredentialStorageSource = "cookies_file"
 	CredentialStorageSourceUnknown     CredentialStorageSource = "unknown"
 )
 
@@ -59,6 +59,9 @@ func InspectDeemixARL(stateDir string) CredentialStatus {
 		case CredentialStorageSourceKeychain:
 			status.Health = CredentialHealthAvailable
 			status.Summary = "Available in macOS Keychain."
+		case CredentialStorageSourceStore:
+			status.Health = CredentialHealthAvailable
+			status.Summary = "Available in the encrypted credential store."
 		case CredentialStorageSourceEnv:
 			status.Health = CredentialHealthExternalOverride
 			status.Summary = "Available via environment override."
@@ -86,6 +89,9 @@ func InspectSpotifyCredentials(stateDir string) CredentialStatus {
 		case CredentialStorageSourceKeychain:
 			status.Health = CredentialHealthAvailable
 			status.Summary = "Available in macOS Keychain."
+		case CredentialStorageSourceStore:
+			status.Health = CredentialHealthAvailable
+			status.Summary = "Available in the encrypted credential store."
 		case CredentialStorageSourceEnv:
 			status.Health = CredentialHealthExternalOverride
 			status.Summary = "Available via environment override."
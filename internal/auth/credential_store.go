@@ -0,0 +1,338 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	credentialStoreFileName = "credentials.enc"
+	credentialStoreVersion  = 1
+	// credentialStoreAAD binds the ciphertext to this file format.
+	credentialStoreAAD = "udl credential store v1"
+
+	credentialStoreKeyEnv             = "UDL_CREDENTIALS_KEY"
+	credentialStoreKeychainService    = "udl.credentials"
+	credentialStoreKeychainAccountKey = "store_key"
+)
+
+// Names accepted by the credential store.
+const (
+	CredentialNameDeemixARL           = "deemix_arl"
+	CredentialNameSpotifyClientID     = "spotify_client_id"
+	CredentialNameSpotifyClientSecret = "spotify_client_secret"
+)
+
+var CredentialStoreNames = []string{
+	CredentialNameDeemixARL,
+	CredentialNameSpotifyClientID,
+	CredentialNameSpotifyClientSecret,
+}
+
+var (
+	ErrCredentialStoreKeyMissing = errors.New("credential store key not found")
+	ErrUnknownCredentialName     = errors.New("unknown credential name")
+)
+
+// CredentialStore is an AES-256-GCM encrypted file of named secrets in
+// state_dir. The 32-byte key never touches the file: it comes from
+// UDL_CREDENTIALS_KEY (base64) or, on macOS, the Keychain item
+// udl.credentials/store_key, which Set creates on first use.
+type CredentialStore struct {
+	Path    string
+	Getenv  func(string) string
+	Command commandRunner
+	Now     func() time.Time
+}
+
+// CredentialStoreEntry describes a stored credential without its value.
+type CredentialStoreEntry struct {
+	Name      string    `json:"name"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type credentialStoreFile struct {
+	Version    int    `json:"version"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+type storedCredential struct {
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OpenCredentialStore returns the store at <state_dir>/credentials.enc. The
+// file is only read or created when a credential is accessed.
+func OpenCredentialStore(stateDir string) (CredentialStore, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return CredentialStore{}, err
+	}
+	if !filepath.IsAbs(root) {
+		return CredentialStore{}, fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return CredentialStore{Path: filepath.Join(root, credentialStoreFileName)}, nil
+}
+
+func ValidCredentialName(name string) error {
+	for _, candidate := range CredentialStoreNames {
+		if name == candidate {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w %q (expected one of: %s)", ErrUnknownCredentialName, name, strings.Join(CredentialStoreNames, ", "))
+}
+
+// Get returns the named credential; ok is false when it is not stored.
+func (s CredentialStore) Get(name string) (string, bool, error) {
+	entries, err := s.load(false)
+	if err != nil {
+		return "", false, err
+	}
+	entry, ok := entries[name]
+	return entry.Value, ok, nil
+}
+
+func (s CredentialStore) Set(name string, value string) error {
+	if err := ValidCredentialName(name); err != nil {
+		return err
+	}
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return fmt.Errorf("credential must not be empty")
+	}
+	entries, err := s.load(true)
+	if err != nil {
+		return err
+	}
+	entries[name] = storedCredential{Value: trimmed, UpdatedAt: s.now().UTC()}
+	return s.save(entries)
+}
+
+// Remove deletes the named credential and reports whether it was stored.
+func (s CredentialStore) Remove(name string) (bool, error) {
+	entries, err := s.load(false)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := entries[name]; !ok {
+		return false, nil
+	}
+	delete(entries, name)
+	return true, s.save(entries)
+}
+
+func (s CredentialStore) List() ([]CredentialStoreEntry, error) {
+	entries, err := s.load(false)
+	if err != nil {
+		return nil, err
+	}
+	listed := make([]CredentialStoreEntry, 0, len(entries))
+	for name, entry := range entries {
+		listed = append(listed, CredentialStoreEntry{Name: name, UpdatedAt: entry.UpdatedAt})
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Name < listed[j].Name })
+	return listed, nil
+}
+
+// load decrypts the store. A missing file is an empty store and needs no key
+// unless createKey is set, which also provisions a Keychain key on macOS.
+func (s CredentialStore) load(createKey bool) (map[string]storedCredential, error) {
+	entries := map[string]storedCredential{}
+	raw, err := os.ReadFile(s.Path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if createKey {
+			if _, err := s.key(true); err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	}
+	var file credentialStoreFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		return nil, fmt.Errorf("parse credential store %s: %w", s.Path, err)
+	}
+	if file.Version != credentialStoreVersion {
+		return nil, fmt.Errorf("credential store %s has unsupported version %d", s.Path, file.Version)
+	}
+	key, err := s.key(false)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newCredentialStoreAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, nonceErr := base64.StdEncoding.DecodeString(file.Nonce)
+	ciphertext, cipherErr := base64.StdEncoding.DecodeString(file.Ciphertext)
+	if nonceErr != nil || cipherErr != nil || len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("credential store %s is malformed", s.Path)
+	}
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(credentialStoreAAD))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credential store %s: wrong key or tampered file", s.Path)
+	}
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return nil, fmt.Errorf("parse decrypted credential store %s: %w", s.Path, err)
+	}
+	return entries, nil
+}
+
+func (s CredentialStore) save(entries map[string]storedCredential) error {
+	key, err := s.key(false)
+	if err != nil {
+		return err
+	}
+	aead, err := newCredentialStoreAEAD(key)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(credentialStoreFile{
+		Version:    credentialStoreVersion,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(aead.Seal(nil, nonce, plaintext, []byte(credentialStoreAAD))),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.Path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".credentials-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(append(payload, '\n')); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, s.Path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func (s CredentialStore) key(create bool) ([]byte, error) {
+	getenv := s.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	if encoded := strings.TrimSpace(getenv(credentialStoreKeyEnv)); encoded != "" {
+		return decodeCredentialStoreKey(encoded, credentialStoreKeyEnv)
+	}
+	command := s.Command
+	if command == nil {
+		command = runCommandOutput
+	}
+	if encoded := keychainCredential(command, credentialStoreKeychainService, credentialStoreKeychainAccountKey); encoded != "" {
+		return decodeCredentialStoreKey(encoded, "keychain item "+credentialStoreKeychainService)
+	}
+	if !create || runtime.GOOS != "darwin" {
+		return nil, fmt.Errorf(
+			"%w: set %s to a base64-encoded 32-byte key (for example `openssl rand -base64 32`) or use macOS Keychain",
+			ErrCredentialStoreKeyMissing, credentialStoreKeyEnv,
+		)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := saveKeychainCredential(command, credentialStoreKeychainService, credentialStoreKeychainAccountKey, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("save credential store key to keychain: %w", err)
+	}
+	return key, nil
+}
+
+func (s CredentialStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func decodeCredentialStoreKey(encoded string, origin string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("%s must be a base64-encoded 32-byte key", origin)
+	}
+	return key, nil
+}
+
+func newCredentialStoreAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+var (
+	activeCredentialStoreMu sync.Mutex
+	activeCredentialStore   *CredentialStore
+)
+
+// UseCredentialStore makes the default resolvers consult the credential store
+// in stateDir before env vars and Keychain. It is called once the config is
+// loaded, like the managed tools directory.
+func UseCredentialStore(stateDir string) {
+	store, err := OpenCredentialStore(stateDir)
+	activeCredentialStoreMu.Lock()
+	defer activeCredentialStoreMu.Unlock()
+	if err != nil {
+		activeCredentialStore = nil
+		return
+	}
+	activeCredentialStore = &store
+}
+
+// lookupStoredCredential reads name from the active credential store. It
+// reports false without error when no store is active or the file does not
+// exist yet.
+func lookupStoredCredential(name string) (string, bool, error) {
+	activeCredentialStoreMu.Lock()
+	store := activeCredentialStore
+	activeCredentialStoreMu.Unlock()
+	if store == nil {
+		return "", false, nil
+	}
+	value, ok, err := store.Get(name)
+	if err != nil {
+		return "", false, err
+	}
+	return strings.TrimSpace(value), ok && strings.TrimSpace(value) != "", nil
+}
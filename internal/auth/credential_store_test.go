@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCredentialStore(t *testing.T, key string) CredentialStore {
+	t.Helper()
+	return CredentialStore{
+		Path: filepath.Join(t.TempDir(), "state", "credentials.enc"),
+		Getenv: func(name string) string {
+			if name == "UDL_CREDENTIALS_KEY" {
+				return key
+			}
+			return ""
+		},
+		Command: func(name string, args ...string) ([]byte, error) {
+			return nil, errors.New("keychain unavailable")
+		},
+		Now: func() time.Time { return time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC) },
+	}
+}
+
+func TestCredentialStoreRoundTripEncryptsValues(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32)))
+	store := testCredentialStore(t, key)
+
+	if err := store.Set(CredentialNameDeemixARL, " secret-arl \n"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	raw, err := os.ReadFile(store.Path)
+	if err != nil {
+		t.Fatalf("read store: %v", err)
+	}
+	if strings.Contains(string(raw), "secret-arl") {
+		t.Fatalf("credential value must not be stored in plaintext: %s", raw)
+	}
+	info, err := os.Stat(store.Path)
+	if err != nil {
+		t.Fatalf("stat store: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 store file, got %v", info.Mode().Perm())
+	}
+
+	got, ok, err := store.Get(CredentialNameDeemixARL)
+	if err != nil || !ok || got != "secret-arl" {
+		t.Fatalf("unexpected get: value=%q ok=%v err=%v", got, ok, err)
+	}
+	entries, err := store.List()
+	if err != nil || len(entries) != 1 || entries[0].Name != CredentialNameDeemixARL || entries[0].UpdatedAt.IsZero() {
+		t.Fatalf("unexpected list: %+v (%v)", entries, err)
+	}
+
+	removed, err := store.Remove(CredentialNameDeemixARL)
+	if err != nil || !removed {
+		t.Fatalf("remove: removed=%v err=%v", removed, err)
+	}
+	if _, ok, _ := store.Get(CredentialNameDeemixARL); ok {
+		t.Fatalf("expected credential to be removed")
+	}
+}
+
+func TestCredentialStoreRejectsWrongKeyAndUnknownNames(t *testing.T) {
+	store := testCredentialStore(t, base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", 32))))
+	if err := store.Set(CredentialNameSpotifyClientID, "id"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	other := store
+	other.Getenv = func(string) string { return base64.StdEncoding.EncodeToString([]byte(strings.Repeat("b", 32))) }
+	if _, _, err := other.Get(CredentialNameSpotifyClientID); err == nil || !strings.Contains(err.Error(), "wrong key") {
+		t.Fatalf("expected decrypt failure with another key, got %v", err)
+	}
+
+	if err := store.Set("github_token", "x"); !errors.Is(err, ErrUnknownCredentialName) {
+		t.Fatalf("expected ErrUnknownCredentialName, got %v", err)
+	}
+}
+
+func TestCredentialStoreRequiresKeyToWrite(t *testing.T) {
+	store := testCredentialStore(t, "")
+	if _, ok, err := store.Get(CredentialNameDeemixARL); err != nil || ok {
+		t.Fatalf("missing store must read as empty without a key: ok=%v err=%v", ok, err)
+	}
+	if runtime.GOOS == "darwin" {
+		t.Skip("Set provisions a Keychain key on macOS")
+	}
+	if err := store.Set(CredentialNameDeemixARL, "arl"); !errors.Is(err, ErrCredentialStoreKeyMissing) {
+		t.Fatalf("expected ErrCredentialStoreKeyMissing, got %v", err)
+	}
+}
+
+func TestResolversPreferCredentialStore(t *testing.T) {
+	stored := map[string]string{
+		CredentialNameDeemixARL:           "store-arl",
+		CredentialNameSpotifyClientID:     "store-id",
+		CredentialNameSpotifyClientSecret: "store-secret",
+	}
+	lookup := func(name string) (string, bool, error) {
+		value, ok := stored[name]
+		return value, ok, nil
+	}
+	getenv := func(key string) string { return "env-value" }
+
+	arl, source, err := DeemixARLResolver{Getenv: getenv, Store: lookup}.ResolveWithSource()
+	if err != nil || arl != "store-arl" || source != CredentialStorageSourceStore {
+		t.Fatalf("unexpected arl resolution: %q %q %v", arl, source, err)
+	}
+	creds, source, err := SpotifyCredentialsResolver{Getenv: getenv, Store: lookup}.ResolveWithSource()
+	if err != nil || creds.ClientID != "store-id" || creds.ClientSecret != "store-secret" || source != CredentialStorageSourceStore {
+		t.Fatalf("unexpected spotify resolution: %+v %q %v", creds, source, err)
+	}
+
+	delete(stored, CredentialNameSpotifyClientSecret)
+	if _, _, err := (SpotifyCredentialsResolver{Getenv: getenv, Store: lookup}).ResolveWithSource(); err == nil {
+		t.Fatalf("expected an error when only the client id is stored")
+	}
+	delete(stored, CredentialNameSpotifyClientID)
+	creds, source, err = SpotifyCredentialsResolver{Getenv: getenv, Store: lookup}.ResolveWithSource()
+	if err != nil || creds.ClientID != "env-value" || source != CredentialStorageSourceEnv {
+		t.Fatalf("expected env fallback, got %+v %q %v", creds, source, err)
+	}
+}
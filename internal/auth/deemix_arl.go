@@ -19,6 +19,7 @@ type commandRunner func(name string, args ...string) ([]byte, error)
 type DeemixARLResolver struct {
 	Getenv  func(string) string
 	Command commandRunner
+	Store   func(name string) (string, bool, error)
 }
 
 func ResolveDeemixARL() (string, error) {
@@ -30,6 +31,7 @@ func ResolveDeemixARLWithSource() (string, CredentialStorageSource, error) {
 	return DeemixARLResolver{
 		Getenv:  os.Getenv,
 		Command: runCommandOutput,
+		Store:   lookupStoredCredential,
 	}.ResolveWithSource()
 }
 
@@ -39,6 +41,16 @@ func (r DeemixARLResolver) Resolve() (string, error) {
 }
 
 func (r DeemixARLResolver) ResolveWithSource() (string, CredentialStorageSource, error) {
+	if r.Store != nil {
+		value, ok, err := r.Store(CredentialNameDeemixARL)
+		if err != nil {
+			return "", CredentialStorageSourceNone, err
+		}
+		if ok {
+			return value, CredentialStorageSourceStore, nil
+		}
+	}
+
 	getenv := r.Getenv
 	if getenv == nil {
 		getenv = os.Getenv
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	soundCloudRefreshTimeout  = 20 * time.Second
+	soundCloudMaxScriptBytes  = 8 << 20
+	soundCloudMaxScriptChecks = 8
+)
+
+var (
+	soundCloudWebAppURL       = "https://soundcloud.com/"
+	soundCloudRefreshClient   = &http.Client{}
+	soundCloudScriptPattern   = regexp.MustCompile(`<script[^>]+src="(https://a-v2\.sndcdn\.com/assets/[^"]+\.js)"`)
+	soundCloudClientIDPattern = regexp.MustCompile(`client_id\s*[:=]\s*"?([0-9A-Za-z]{32})\b`)
+)
+
+var ErrSoundCloudClientIDRefreshFailed = errors.New("soundcloud client id refresh failed")
+
+// RefreshSoundCloudClientID scrapes a current public client_id from the
+// SoundCloud web app the way a browser bootstraps it: load the home page,
+// then search its asset bundles (newest last, so scanned in reverse).
+func RefreshSoundCloudClientID(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, soundCloudRefreshTimeout)
+	defer cancel()
+
+	page, err := fetchSoundCloudAsset(ctx, soundCloudWebAppURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: load web app: %v", ErrSoundCloudClientIDRefreshFailed, err)
+	}
+	matches := soundCloudScriptPattern.FindAllStringSubmatch(page, -1)
+	if len(matches) == 0 {
+		return "", fmt.Errorf("%w: no asset scripts on web app page", ErrSoundCloudClientIDRefreshFailed)
+	}
+	checked := 0
+	for i := len(matches) - 1; i >= 0 && checked < soundCloudMaxScriptChecks; i-- {
+		checked++
+		script, err := fetchSoundCloudAsset(ctx, matches[i][1])
+		if err != nil {
+			if ctx.Err() != nil {
+				return "", fmt.Errorf("%w: %v", ErrSoundCloudClientIDRefreshFailed, ctx.Err())
+			}
+			continue
+		}
+		if found := soundCloudClientIDPattern.FindStringSubmatch(script); len(found) == 2 {
+			return found[1], nil
+		}
+	}
+	return "", fmt.Errorf("%w: client_id not found in %d asset scripts", ErrSoundCloudClientIDRefreshFailed, checked)
+}
+
+func fetchSoundCloudAsset(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36")
+	resp, err := soundCloudRefreshClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s returned HTTP %d", rawURL, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, soundCloudMaxScriptBytes))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
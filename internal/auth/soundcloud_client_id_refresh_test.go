@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRefreshSoundCloudClientIDScrapesNewestAssetScript(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	// Asset URLs must look like the real CDN, so route them through a
+	// transport that rewrites the host to the test server.
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><script crossorigin src="https://a-v2.sndcdn.com/assets/0-old.js"></script>`+
+				`<script crossorigin src="https://a-v2.sndcdn.com/assets/50-new.js"></script></html>`)
+		case "/assets/50-new.js":
+			fmt.Fprint(w, `({env:"production",client_id:"AbCdEfGhIjKlMnOpQrStUvWxYz012345",version:1})`)
+		case "/assets/0-old.js":
+			fmt.Fprint(w, `client_id:"00000000000000000000000000000000"`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	origURL := soundCloudWebAppURL
+	origClient := soundCloudRefreshClient
+	t.Cleanup(func() {
+		soundCloudWebAppURL = origURL
+		soundCloudRefreshClient = origClient
+	})
+	soundCloudWebAppURL = server.URL + "/"
+	soundCloudRefreshClient = &http.Client{Transport: rewriteHostTransport{target: server.URL}}
+
+	clientID, err := RefreshSoundCloudClientID(context.Background())
+	if err != nil {
+		t.Fatalf("refresh client id: %v", err)
+	}
+	if clientID != "AbCdEfGhIjKlMnOpQrStUvWxYz012345" {
+		t.Fatalf("expected client id from newest script, got %q", clientID)
+	}
+}
+
+func TestRefreshSoundCloudClientIDFailsWithoutScripts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html></html>")
+	}))
+	defer server.Close()
+
+	origURL := soundCloudWebAppURL
+	origClient := soundCloudRefreshClient
+	t.Cleanup(func() {
+		soundCloudWebAppURL = origURL
+		soundCloudRefreshClient = origClient
+	})
+	soundCloudWebAppURL = server.URL + "/"
+	soundCloudRefreshClient = server.Client()
+
+	if _, err := RefreshSoundCloudClientID(context.Background()); !errors.Is(err, ErrSoundCloudClientIDRefreshFailed) {
+		t.Fatalf("expected refresh failure, got %v", err)
+	}
+}
+
+type rewriteHostTransport struct {
+	target string
+}
+
+func (t rewriteHostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := req.Clone(req.Context())
+	rewritten := t.target + req.URL.Path
+	parsed, err := req.URL.Parse(rewritten)
+	if err != nil {
+		return nil, err
+	}
+	clone.URL = parsed
+	clone.Host = strings.TrimPrefix(t.target, "http://")
+	return http.DefaultTransport.RoundTrip(clone)
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// soundCloudOAuthTokenCookie is the cookie a signed-in soundcloud.com
+// session keeps its OAuth token in; api-v2 accepts it as
+// "Authorization: OAuth <token>".
+const soundCloudOAuthTokenCookie = "oauth_token"
+
+var (
+	ErrSoundCloudCookiesNotFound      = errors.New("soundcloud cookies file not found")
+	ErrSoundCloudCookiesOAuthNotFound = errors.New("soundcloud cookies file has no oauth_token cookie")
+)
+
+// ReadSoundCloudOAuthToken returns the oauth_token cookie from a Netscape
+// cookies file exported from a signed-in soundcloud.com session. The token
+// stays in memory and is sent only to SoundCloud.
+func ReadSoundCloudOAuthToken(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", ErrSoundCloudCookiesNotFound
+		}
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		domain := strings.TrimPrefix(strings.ToLower(fields[0]), ".")
+		if domain != "soundcloud.com" && !strings.HasSuffix(domain, ".soundcloud.com") {
+			continue
+		}
+		if fields[5] == soundCloudOAuthTokenCookie {
+			if value := strings.TrimSpace(fields[6]); value != "" {
+				return value, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	return "", ErrSoundCloudCookiesOAuthNotFound
+}
@@ -0,0 +1,310 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	soundCloudKeychainAccountOAuth       = "oauth_token"
+	soundCloudKeychainAccountAppClientID = "app_client_id"
+	soundCloudKeychainAccountAppSecret   = "app_client_secret"
+
+	soundCloudTokenURL = "https://secure.soundcloud.com/oauth/token"
+
+	// SoundCloudOAuthRefreshMargin is how long before expiry udl refreshes a
+	// SoundCloud access token, so one never lapses during a run's preflight.
+	SoundCloudOAuthRefreshMargin = 10 * time.Minute
+)
+
+var (
+	ErrSoundCloudOAuthTokenNotFound = errors.New("soundcloud oauth token not found")
+	// ErrSoundCloudRefreshTokenRevoked is returned when SoundCloud rejects the
+	// stored refresh token; only a new login can recover from it.
+	ErrSoundCloudRefreshTokenRevoked    = errors.New("soundcloud refresh token was revoked or expired")
+	ErrSoundCloudAppCredentialsNotFound = errors.New("soundcloud app client id/secret not found")
+)
+
+// SoundCloudOAuthToken is a signed-in SoundCloud session. ExpiresAt is zero
+// when the expiry is unknown, which is the case for UDL_SOUNDCLOUD_OAUTH_TOKEN.
+type SoundCloudOAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresAt    int64  `json:"expires_at,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// Expired reports whether the access token expires within margin of now. A
+// token without a known expiry never counts as expired.
+func (t SoundCloudOAuthToken) Expired(now time.Time, margin time.Duration) bool {
+	if strings.TrimSpace(t.AccessToken) == "" {
+		return true
+	}
+	return t.ExpiresAt > 0 && now.Add(margin).Unix() >= t.ExpiresAt
+}
+
+// CanRefresh reports whether the token carries a refresh token.
+func (t SoundCloudOAuthToken) CanRefresh() bool {
+	return strings.TrimSpace(t.RefreshToken) != ""
+}
+
+// SoundCloudAppCredentials are the client id and secret of the SoundCloud
+// app the OAuth token was issued to, needed to refresh it. They are not the
+// public web client_id scdl uses.
+type SoundCloudAppCredentials struct {
+	ClientID     string
+	ClientSecret string
+}
+
+// SoundCloudOAuthResolver finds the SoundCloud OAuth session:
+// UDL_SOUNDCLOUD_OAUTH_TOKEN (an access token only, never refreshed), then
+// the macOS Keychain item udl.soundcloud/oauth_token, which holds the token
+// as JSON.
+type SoundCloudOAuthResolver struct {
+	Getenv  func(string) string
+	Command commandRunner
+}
+
+func ResolveSoundCloudOAuthTokenWithSource() (SoundCloudOAuthToken, CredentialStorageSource, error) {
+	return SoundCloudOAuthResolver{Getenv: os.Getenv, Command: runCommandOutput}.ResolveWithSource()
+}
+
+func (r SoundCloudOAuthResolver) ResolveWithSource() (SoundCloudOAuthToken, CredentialStorageSource, error) {
+	getenv := r.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	if value := strings.TrimSpace(getenv("UDL_SOUNDCLOUD_OAUTH_TOKEN")); value != "" {
+		return SoundCloudOAuthToken{AccessToken: value}, CredentialStorageSourceEnv, nil
+	}
+	command := r.Command
+	if command == nil {
+		command = runCommandOutput
+	}
+	raw := keychainCredential(command, soundCloudKeychainService, soundCloudKeychainAccountOAuth)
+	if raw == "" {
+		return SoundCloudOAuthToken{}, CredentialStorageSourceNone, ErrSoundCloudOAuthTokenNotFound
+	}
+	var token SoundCloudOAuthToken
+	if err := json.Unmarshal([]byte(raw), &token); err != nil {
+		// A bare token pasted into the Keychain item works until it expires.
+		token = SoundCloudOAuthToken{AccessToken: raw}
+	}
+	if strings.TrimSpace(token.AccessToken) == "" {
+		return SoundCloudOAuthToken{}, CredentialStorageSourceNone, ErrSoundCloudOAuthTokenNotFound
+	}
+	return token, CredentialStorageSourceKeychain, nil
+}
+
+// ResolveAppCredentials returns the app credentials from
+// UDL_SOUNDCLOUD_APP_CLIENT_ID/UDL_SOUNDCLOUD_APP_CLIENT_SECRET, then the
+// Keychain accounts app_client_id/app_client_secret of udl.soundcloud.
+func (r SoundCloudOAuthResolver) ResolveAppCredentials() (SoundCloudAppCredentials, error) {
+	getenv := r.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	creds := SoundCloudAppCredentials{
+		ClientID:     strings.TrimSpace(getenv("UDL_SOUNDCLOUD_APP_CLIENT_ID")),
+		ClientSecret: strings.TrimSpace(getenv("UDL_SOUNDCLOUD_APP_CLIENT_SECRET")),
+	}
+	if creds.ClientID != "" && creds.ClientSecret != "" {
+		return creds, nil
+	}
+	command := r.Command
+	if command == nil {
+		command = runCommandOutput
+	}
+	creds = SoundCloudAppCredentials{
+		ClientID:     keychainCredential(command, soundCloudKeychainService, soundCloudKeychainAccountAppClientID),
+		ClientSecret: keychainCredential(command, soundCloudKeychainService, soundCloudKeychainAccountAppSecret),
+	}
+	if creds.ClientID == "" || creds.ClientSecret == "" {
+		return SoundCloudAppCredentials{}, ErrSoundCloudAppCredentialsNotFound
+	}
+	return creds, nil
+}
+
+// SaveSoundCloudOAuthToken stores token as JSON in the Keychain item
+// udl.soundcloud/oauth_token.
+func SaveSoundCloudOAuthToken(token SoundCloudOAuthToken) error {
+	return saveSoundCloudOAuthToken(runCommandOutput, token)
+}
+
+func saveSoundCloudOAuthToken(command commandRunner, token SoundCloudOAuthToken) error {
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	if err := saveKeychainCredential(command, soundCloudKeychainService, soundCloudKeychainAccountOAuth, string(payload)); err != nil {
+		return fmt.Errorf("save soundcloud oauth token to keychain: %w", err)
+	}
+	return nil
+}
+
+// SoundCloudOAuth refreshes SoundCloud access tokens with the OAuth 2.1
+// refresh_token grant.
+type SoundCloudOAuth struct {
+	Credentials SoundCloudAppCredentials
+	Client      *http.Client
+	TokenURL    string
+	Now         func() time.Time
+}
+
+// Refresh renews token. SoundCloud rotates refresh tokens, so the returned
+// token must replace the stored one; when a response omits it the old one is
+// kept.
+func (a SoundCloudOAuth) Refresh(ctx context.Context, token SoundCloudOAuthToken) (SoundCloudOAuthToken, error) {
+	refreshToken := strings.TrimSpace(token.RefreshToken)
+	if refreshToken == "" {
+		return SoundCloudOAuthToken{}, ErrSoundCloudOAuthTokenNotFound
+	}
+	clientID := strings.TrimSpace(a.Credentials.ClientID)
+	clientSecret := strings.TrimSpace(a.Credentials.ClientSecret)
+	if clientID == "" || clientSecret == "" {
+		return SoundCloudOAuthToken{}, ErrSoundCloudAppCredentialsNotFound
+	}
+	tokenURL := a.TokenURL
+	if tokenURL == "" {
+		tokenURL = soundCloudTokenURL
+	}
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	form.Set("refresh_token", refreshToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return SoundCloudOAuthToken{}, fmt.Errorf("create soundcloud token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json; charset=utf-8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return SoundCloudOAuthToken{}, fmt.Errorf("soundcloud token request failed: %w", err)
+	}
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	_ = resp.Body.Close()
+	if readErr != nil {
+		return SoundCloudOAuthToken{}, fmt.Errorf("read soundcloud token response: %w", readErr)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &failure)
+		if failure.Error == "invalid_grant" {
+			return SoundCloudOAuthToken{}, ErrSoundCloudRefreshTokenRevoked
+		}
+		return SoundCloudOAuthToken{}, fmt.Errorf("soundcloud token request failed: status=%d error=%s %s", resp.StatusCode, failure.Error, failure.Description)
+	}
+
+	var refreshed struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Scope        string `json:"scope"`
+	}
+	if err := json.Unmarshal(body, &refreshed); err != nil {
+		return SoundCloudOAuthToken{}, fmt.Errorf("decode soundcloud token response: %w", err)
+	}
+	if strings.TrimSpace(refreshed.AccessToken) == "" {
+		return SoundCloudOAuthToken{}, fmt.Errorf("soundcloud token response missing access_token")
+	}
+	next := SoundCloudOAuthToken{
+		AccessToken:  refreshed.AccessToken,
+		RefreshToken: refreshed.RefreshToken,
+		Scope:        refreshed.Scope,
+	}
+	if next.RefreshToken == "" {
+		next.RefreshToken = refreshToken
+	}
+	if next.Scope == "" {
+		next.Scope = token.Scope
+	}
+	if refreshed.ExpiresIn > 0 {
+		next.ExpiresAt = a.now().Add(time.Duration(refreshed.ExpiresIn) * time.Second).Unix()
+	}
+	return next, nil
+}
+
+func (a SoundCloudOAuth) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+// SoundCloudOAuthManager hands out a usable access token, refreshing and
+// re-saving a Keychain token that expires within SoundCloudOAuthRefreshMargin.
+type SoundCloudOAuthManager struct {
+	Resolver SoundCloudOAuthResolver
+	OAuth    SoundCloudOAuth
+	Save     func(SoundCloudOAuthToken) error
+	Now      func() time.Time
+}
+
+func NewSoundCloudOAuthManager() SoundCloudOAuthManager {
+	return SoundCloudOAuthManager{
+		Resolver: SoundCloudOAuthResolver{Getenv: os.Getenv, Command: runCommandOutput},
+		Save:     SaveSoundCloudOAuthToken,
+		Now:      time.Now,
+	}
+}
+
+// AccessToken returns the current access token and whether it was just
+// refreshed. With refresh false an expiring token is returned unchanged.
+func (m SoundCloudOAuthManager) AccessToken(ctx context.Context, refresh bool) (string, bool, error) {
+	token, source, err := m.Resolver.ResolveWithSource()
+	if err != nil {
+		return "", false, err
+	}
+	now := time.Now
+	if m.Now != nil {
+		now = m.Now
+	}
+	if !refresh || source != CredentialStorageSourceKeychain || !token.Expired(now(), SoundCloudOAuthRefreshMargin) {
+		return token.AccessToken, false, nil
+	}
+	if !token.CanRefresh() {
+		return "", false, fmt.Errorf("soundcloud oauth token expired and has no refresh token; sign in again")
+	}
+	oauth := m.OAuth
+	if oauth.Credentials.ClientID == "" || oauth.Credentials.ClientSecret == "" {
+		creds, err := m.Resolver.ResolveAppCredentials()
+		if err != nil {
+			return "", false, err
+		}
+		oauth.Credentials = creds
+	}
+	if oauth.Now == nil {
+		oauth.Now = now
+	}
+	refreshed, err := oauth.Refresh(ctx, token)
+	if err != nil {
+		return "", false, err
+	}
+	save := m.Save
+	if save == nil {
+		save = SaveSoundCloudOAuthToken
+	}
+	if err := save(refreshed); err != nil {
+		return "", false, err
+	}
+	return refreshed.AccessToken, true, nil
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSoundCloudOAuthResolverReadsKeychainJSONAndEnvOverride(t *testing.T) {
+	keychain := func(name string, args ...string) ([]byte, error) {
+		return []byte(`{"access_token":"access-1","refresh_token":"refresh-1","expires_at":1700000000}` + "\n"), nil
+	}
+	token, source, err := SoundCloudOAuthResolver{Getenv: func(string) string { return "" }, Command: keychain}.ResolveWithSource()
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if source != CredentialStorageSourceKeychain || token.AccessToken != "access-1" || !token.CanRefresh() || token.ExpiresAt != 1700000000 {
+		t.Fatalf("unexpected keychain token %+v (%s)", token, source)
+	}
+
+	env := func(key string) string {
+		if key == "UDL_SOUNDCLOUD_OAUTH_TOKEN" {
+			return " env-token "
+		}
+		return ""
+	}
+	token, source, err = SoundCloudOAuthResolver{Getenv: env, Command: keychain}.ResolveWithSource()
+	if err != nil || source != CredentialStorageSourceEnv || token.AccessToken != "env-token" || token.CanRefresh() {
+		t.Fatalf("unexpected env token %+v (%s, %v)", token, source, err)
+	}
+	if token.Expired(time.Now(), SoundCloudOAuthRefreshMargin) {
+		t.Fatalf("expected a token without expiry to never count as expired")
+	}
+
+	missing := func(name string, args ...string) ([]byte, error) { return nil, errors.New("not found") }
+	if _, _, err := (SoundCloudOAuthResolver{Getenv: func(string) string { return "" }, Command: missing}).ResolveWithSource(); !errors.Is(err, ErrSoundCloudOAuthTokenNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestSoundCloudOAuthManagerRefreshesNearExpiryAndSaves(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("client_id") != "app" || r.PostForm.Get("client_secret") != "secret" {
+			t.Fatalf("unexpected refresh form %v", r.PostForm)
+		}
+		if r.PostForm.Get("refresh_token") == "revoked" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"access-2","refresh_token":"refresh-2","expires_in":3600,"scope":""}`)
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	stored := fmt.Sprintf(`{"access_token":"access-1","refresh_token":"refresh-1","expires_at":%d}`, now.Add(5*time.Minute).Unix())
+	var saved SoundCloudOAuthToken
+	manager := SoundCloudOAuthManager{
+		Resolver: SoundCloudOAuthResolver{
+			Getenv:  func(string) string { return "" },
+			Command: func(name string, args ...string) ([]byte, error) { return []byte(stored), nil },
+		},
+		OAuth: SoundCloudOAuth{Credentials: SoundCloudAppCredentials{ClientID: "app", ClientSecret: "secret"}, TokenURL: server.URL},
+		Save:  func(token SoundCloudOAuthToken) error { saved = token; return nil },
+		Now:   func() time.Time { return now },
+	}
+
+	value, refreshed, err := manager.AccessToken(context.Background(), false)
+	if err != nil || refreshed || value != "access-1" {
+		t.Fatalf("expected no refresh when disabled, got %q %v %v", value, refreshed, err)
+	}
+	value, refreshed, err = manager.AccessToken(context.Background(), true)
+	if err != nil || !refreshed || value != "access-2" {
+		t.Fatalf("expected refreshed token, got %q %v %v", value, refreshed, err)
+	}
+	if saved.RefreshToken != "refresh-2" || saved.ExpiresAt != now.Add(time.Hour).Unix() {
+		t.Fatalf("expected rotated token to be saved, got %+v", saved)
+	}
+
+	stored = fmt.Sprintf(`{"access_token":"access-1","refresh_token":"revoked","expires_at":%d}`, now.Unix())
+	if _, _, err := manager.AccessToken(context.Background(), true); !errors.Is(err, ErrSoundCloudRefreshTokenRevoked) {
+		t.Fatalf("expected revoked refresh token, got %v", err)
+	}
+}
@@ -32,6 +32,7 @@ type SpotifyCredentialsResolver struct {
 	ReadFile func(string) ([]byte, error)
 	HomeDir  func() (string, error)
 	Command  commandRunner
+	Store    func(name string) (string, bool, error)
 }
 
 func ResolveSpotifyCredentials() (SpotifyCredentials, error) {
@@ -45,6 +46,7 @@ func ResolveSpotifyCredentialsWithSource() (SpotifyCredentials, CredentialStorag
 		ReadFile: os.ReadFile,
 		HomeDir:  os.UserHomeDir,
 		Command:  runCommandOutput,
+		Store:    lookupStoredCredential,
 	}.ResolveWithSource()
 }
 
@@ -54,6 +56,23 @@ func (r SpotifyCredentialsResolver) Resolve() (SpotifyCredentials, error) {
 }
 
 func (r SpotifyCredentialsResolver) ResolveWithSource() (SpotifyCredentials, CredentialStorageSource, error) {
+	if r.Store != nil {
+		clientID, hasID, err := r.Store(CredentialNameSpotifyClientID)
+		if err != nil {
+			return SpotifyCredentials{}, CredentialStorageSourceNone, err
+		}
+		clientSecret, hasSecret, err := r.Store(CredentialNameSpotifyClientSecret)
+		if err != nil {
+			return SpotifyCredentials{}, CredentialStorageSourceNone, err
+		}
+		if hasID && hasSecret {
+			return SpotifyCredentials{ClientID: clientID, ClientSecret: clientSecret}, CredentialStorageSourceStore, nil
+		}
+		if hasID || hasSecret {
+			return SpotifyCredentials{}, CredentialStorageSourceStore, fmt.Errorf("both %s and %s must be set in the credential store", CredentialNameSpotifyClientID, CredentialNameSpotifyClientSecret)
+		}
+	}
+
 	getenv := r.Getenv
 	if getenv == nil {
 		getenv = os.Getenv
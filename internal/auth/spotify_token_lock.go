@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	// spotifyTokenLockStaleAge is how long a lock file may go without a
+	// heartbeat before it is assumed to belong to a crashed process.
+	spotifyTokenLockStaleAge  = 2 * time.Minute
+	spotifyTokenLockHeartbeat = 20 * time.Second
+	spotifyTokenLockPoll      = 200 * time.Millisecond
+)
+
+// spotifyTokenCacheSlot serializes lock holders inside one process, so
+// goroutines queue on the channel instead of polling the lock file.
+var spotifyTokenCacheSlot = make(chan struct{}, 1)
+
+// LockSpotifyUserTokenCache takes the lock guarding the spotipy cache at
+// path, shared by concurrent syncs in this and other udl processes. It waits
+// until the lock is free or ctx is done; waited reports whether another
+// holder had it first, in which case the cache may have been refreshed or
+// logged in meanwhile and should be read again. The lock is a
+// <path>.udl-lock file holding the pid; spotdl itself does not take it.
+func LockSpotifyUserTokenCache(ctx context.Context, path string) (unlock func(), waited bool, err error) {
+	select {
+	case spotifyTokenCacheSlot <- struct{}{}:
+	default:
+		waited = true
+		select {
+		case spotifyTokenCacheSlot <- struct{}{}:
+		case <-ctx.Done():
+			return nil, waited, ctx.Err()
+		}
+	}
+	release := func() { <-spotifyTokenCacheSlot }
+
+	lockPath := path + ".udl-lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0o700); err != nil {
+		release()
+		return nil, waited, err
+	}
+	for {
+		file, openErr := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if openErr == nil {
+			_, writeErr := file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+			closeErr := file.Close()
+			if writeErr != nil || closeErr != nil {
+				_ = os.Remove(lockPath)
+				release()
+				return nil, waited, fmt.Errorf("write spotify token lock: %w", errors.Join(writeErr, closeErr))
+			}
+			stop := startSpotifyTokenLockHeartbeat(lockPath)
+			return func() {
+				stop()
+				release()
+			}, waited, nil
+		}
+		if !os.IsExist(openErr) {
+			release()
+			return nil, waited, openErr
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) >= spotifyTokenLockStaleAge {
+			_ = os.Remove(lockPath)
+			continue
+		}
+		waited = true
+		timer := time.NewTimer(spotifyTokenLockPoll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			release()
+			return nil, waited, fmt.Errorf("wait for spotify token lock %s: %w", lockPath, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func startSpotifyTokenLockHeartbeat(path string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(spotifyTokenLockHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case tick := <-ticker.C:
+				_ = os.Chtimes(path, tick, tick)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		_ = os.Remove(path)
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockSpotifyUserTokenCacheSerializesHolders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spotdl", ".spotipy")
+	unlock, waited, err := LockSpotifyUserTokenCache(context.Background(), path)
+	if err != nil || waited {
+		t.Fatalf("expected free lock, got waited=%v err=%v", waited, err)
+	}
+	if _, err := os.Stat(path + ".udl-lock"); err != nil {
+		t.Fatalf("expected lock file: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, _, err := LockSpotifyUserTokenCache(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected second holder to wait until its context ends, got %v", err)
+	}
+
+	acquired := make(chan bool, 1)
+	go func() {
+		second, waited, err := LockSpotifyUserTokenCache(context.Background(), path)
+		if err != nil {
+			t.Errorf("second lock: %v", err)
+			acquired <- false
+			return
+		}
+		second()
+		acquired <- waited
+	}()
+	time.Sleep(20 * time.Millisecond)
+	unlock()
+	if waited := <-acquired; !waited {
+		t.Fatalf("expected second holder to report that it waited")
+	}
+	if _, err := os.Stat(path + ".udl-lock"); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file to be removed, got %v", err)
+	}
+}
+
+func TestLockSpotifyUserTokenCacheTakesOverStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spotipy")
+	lockPath := path + ".udl-lock"
+	if err := os.WriteFile(lockPath, []byte("999999\n"), 0o600); err != nil {
+		t.Fatalf("write stale lock: %v", err)
+	}
+	stale := time.Now().Add(-2 * spotifyTokenLockStaleAge)
+	if err := os.Chtimes(lockPath, stale, stale); err != nil {
+		t.Fatalf("age lock: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, _, err := LockSpotifyUserTokenCache(ctx, path)
+	if err != nil {
+		t.Fatalf("expected stale lock to be taken over, got %v", err)
+	}
+	unlock()
+}
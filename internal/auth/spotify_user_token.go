@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// SpotDLUserAuthScope is the scope spotdl requests with --user-auth; spotipy
+	// only reuses a cached token whose scope covers it.
+	SpotDLUserAuthScope = "user-library-read user-follow-read playlist-read-private"
+	// SpotDLRedirectURI is spotdl's default OAuth redirect URI, registered for
+	// its bundled client id.
+	SpotDLRedirectURI = "http://127.0.0.1:9900/"
+
+	spotifyAuthorizeURL = "https://accounts.spotify.com/authorize"
+	spotifyTokenURL     = "https://accounts.spotify.com/api/token"
+)
+
+var (
+	ErrSpotifyUserTokenNotFound = errors.New("spotify user token cache not found")
+	// ErrSpotifyRefreshTokenRevoked is returned when Spotify rejects the cached
+	// refresh token; only a new login can recover from it.
+	ErrSpotifyRefreshTokenRevoked = errors.New("spotify refresh token was revoked or expired")
+)
+
+// SpotifyUserToken is a Spotify user access token in spotipy's cache layout,
+// so spotdl --user-auth picks up a token udl obtained or refreshed.
+type SpotifyUserToken struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// Expired reports whether the access token expires within margin of now.
+func (t SpotifyUserToken) Expired(now time.Time, margin time.Duration) bool {
+	return strings.TrimSpace(t.AccessToken) == "" || now.Add(margin).Unix() >= t.ExpiresAt
+}
+
+// SpotifyUserAuth runs Spotify's authorization code grant without a local
+// browser: the authorization URL can be opened on any device and the URL it
+// redirects to pasted back. Spotify does not offer the OAuth device-code grant
+// to third-party apps, so this is the headless login it supports.
+type SpotifyUserAuth struct {
+	Credentials  SpotifyCredentials
+	RedirectURI  string
+	Client       *http.Client
+	AuthorizeURL string
+	TokenURL     string
+	Now          func() time.Time
+}
+
+// AuthorizationURL is the consent page the user opens; state is echoed back in
+// the redirect and must be checked with CodeFromRedirect.
+func (a SpotifyUserAuth) AuthorizationURL(state string) string {
+	authorizeURL := a.AuthorizeURL
+	if authorizeURL == "" {
+		authorizeURL = spotifyAuthorizeURL
+	}
+	query := url.Values{}
+	query.Set("client_id", strings.TrimSpace(a.Credentials.ClientID))
+	query.Set("response_type", "code")
+	query.Set("redirect_uri", a.redirectURI())
+	query.Set("scope", SpotDLUserAuthScope)
+	query.Set("state", state)
+	return authorizeURL + "?" + query.Encode()
+}
+
+// CodeFromRedirect extracts the authorization code from the pasted redirect
+// URL after checking its state.
+func (a SpotifyUserAuth) CodeFromRedirect(redirected string, state string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(redirected))
+	if err != nil || parsed.RawQuery == "" {
+		return "", fmt.Errorf("paste the full URL the browser was redirected to (it starts with %s)", a.redirectURI())
+	}
+	query := parsed.Query()
+	if denied := strings.TrimSpace(query.Get("error")); denied != "" {
+		return "", fmt.Errorf("spotify authorization failed: %s", denied)
+	}
+	if query.Get("state") != state {
+		return "", fmt.Errorf("spotify authorization state mismatch; start the login again")
+	}
+	code := strings.TrimSpace(query.Get("code"))
+	if code == "" {
+		return "", fmt.Errorf("redirect URL has no authorization code")
+	}
+	return code, nil
+}
+
+// Exchange trades an authorization code for a user token.
+func (a SpotifyUserAuth) Exchange(ctx context.Context, code string) (SpotifyUserToken, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", a.redirectURI())
+	return a.requestToken(ctx, form, "")
+}
+
+// Refresh renews token with its refresh token. Spotify may omit the refresh
+// token from the response, in which case the existing one is kept.
+func (a SpotifyUserAuth) Refresh(ctx context.Context, token SpotifyUserToken) (SpotifyUserToken, error) {
+	refreshToken := strings.TrimSpace(token.RefreshToken)
+	if refreshToken == "" {
+		return SpotifyUserToken{}, ErrSpotifyUserTokenNotFound
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	refreshed, err := a.requestToken(ctx, form, refreshToken)
+	if err != nil {
+		return SpotifyUserToken{}, err
+	}
+	if refreshed.Scope == "" {
+		refreshed.Scope = token.Scope
+	}
+	return refreshed, nil
+}
+
+func (a SpotifyUserAuth) requestToken(ctx context.Context, form url.Values, fallbackRefresh string) (SpotifyUserToken, error) {
+	clientID := strings.TrimSpace(a.Credentials.ClientID)
+	clientSecret := strings.TrimSpace(a.Credentials.ClientSecret)
+	if clientID == "" || clientSecret == "" {
+		return SpotifyUserToken{}, ErrSpotifyCredentialsNotFound
+	}
+	tokenURL := a.TokenURL
+	if tokenURL == "" {
+		tokenURL = spotifyTokenURL
+	}
+	client := a.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return SpotifyUserToken{}, fmt.Errorf("create spotify token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(clientID+":"+clientSecret)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return SpotifyUserToken{}, fmt.Errorf("spotify token request failed: %w", err)
+	}
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	_ = resp.Body.Close()
+	if readErr != nil {
+		return SpotifyUserToken{}, fmt.Errorf("read spotify token response: %w", readErr)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Token endpoint errors carry only an error code and description, never
+		// a token, so they are safe to surface.
+		var failure struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		_ = json.Unmarshal(body, &failure)
+		if failure.Error == "invalid_grant" && fallbackRefresh != "" {
+			return SpotifyUserToken{}, ErrSpotifyRefreshTokenRevoked
+		}
+		return SpotifyUserToken{}, fmt.Errorf("spotify token request failed: status=%d error=%s %s", resp.StatusCode, failure.Error, failure.Description)
+	}
+
+	var token SpotifyUserToken
+	if err := json.Unmarshal(body, &token); err != nil {
+		return SpotifyUserToken{}, fmt.Errorf("decode spotify token response: %w", err)
+	}
+	if strings.TrimSpace(token.AccessToken) == "" {
+		return SpotifyUserToken{}, fmt.Errorf("spotify token response missing access_token")
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = fallbackRefresh
+	}
+	token.ExpiresAt = a.now().Add(time.Duration(token.ExpiresIn) * time.Second).Unix()
+	return token, nil
+}
+
+func (a SpotifyUserAuth) redirectURI() string {
+	if strings.TrimSpace(a.RedirectURI) == "" {
+		return SpotDLRedirectURI
+	}
+	return strings.TrimSpace(a.RedirectURI)
+}
+
+func (a SpotifyUserAuth) now() time.Time {
+	if a.Now != nil {
+		return a.Now()
+	}
+	return time.Now()
+}
+
+// SpotDLUserTokenCachePath is the spotipy cache spotdl --user-auth reads.
+func SpotDLUserTokenCachePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".spotdl", ".spotipy"), nil
+}
+
+func LoadSpotifyUserToken(path string) (SpotifyUserToken, error) {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return SpotifyUserToken{}, ErrSpotifyUserTokenNotFound
+		}
+		return SpotifyUserToken{}, err
+	}
+	var token SpotifyUserToken
+	if err := json.Unmarshal(payload, &token); err != nil {
+		return SpotifyUserToken{}, fmt.Errorf("parse spotify token cache %s: %w", path, err)
+	}
+	return token, nil
+}
+
+// SaveSpotifyUserToken writes token atomically with owner-only permissions;
+// the refresh token grants ongoing access to the Spotify account.
+func SaveSpotifyUserToken(path string, token SpotifyUserToken) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".spotipy-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(payload); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
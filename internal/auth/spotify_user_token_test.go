@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpotifyUserAuthExchangesPastedRedirectAndRefreshes(t *testing.T) {
+	forms := []url.Values{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "secret" {
+			t.Fatalf("expected basic auth with app credentials, got %q %q", user, pass)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("parse form: %v", err)
+		}
+		forms = append(forms, r.PostForm)
+		switch r.PostForm.Get("grant_type") {
+		case "authorization_code":
+			fmt.Fprint(w, `{"access_token":"access-1","token_type":"Bearer","expires_in":3600,"refresh_token":"refresh-1","scope":"user-library-read"}`)
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") == "revoked" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Refresh token revoked"}`)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"access-2","token_type":"Bearer","expires_in":3600}`)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	userAuth := SpotifyUserAuth{
+		Credentials: SpotifyCredentials{ClientID: "client", ClientSecret: "secret"},
+		TokenURL:    server.URL,
+		Now:         func() time.Time { return now },
+	}
+
+	authURL, err := url.Parse(userAuth.AuthorizationURL("state-1"))
+	if err != nil {
+		t.Fatalf("parse authorization url: %v", err)
+	}
+	if authURL.Query().Get("redirect_uri") != SpotDLRedirectURI || authURL.Query().Get("scope") != SpotDLUserAuthScope {
+		t.Fatalf("unexpected authorization url: %s", authURL)
+	}
+	if _, err := userAuth.CodeFromRedirect("http://127.0.0.1:9900/?code=abc&state=other", "state-1"); err == nil {
+		t.Fatalf("expected state mismatch to be rejected")
+	}
+	code, err := userAuth.CodeFromRedirect(" http://127.0.0.1:9900/?code=abc&state=state-1 \n", "state-1")
+	if err != nil || code != "abc" {
+		t.Fatalf("expected code abc, got %q (%v)", code, err)
+	}
+
+	token, err := userAuth.Exchange(context.Background(), code)
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if token.AccessToken != "access-1" || token.RefreshToken != "refresh-1" || token.ExpiresAt != now.Add(time.Hour).Unix() {
+		t.Fatalf("unexpected token: %+v", token)
+	}
+	if forms[0].Get("code") != "abc" || forms[0].Get("redirect_uri") != SpotDLRedirectURI {
+		t.Fatalf("unexpected exchange form: %v", forms[0])
+	}
+
+	refreshed, err := userAuth.Refresh(context.Background(), token)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if refreshed.AccessToken != "access-2" || refreshed.RefreshToken != "refresh-1" || refreshed.Scope != "user-library-read" {
+		t.Fatalf("expected refresh to keep the refresh token and scope, got %+v", refreshed)
+	}
+
+	_, err = userAuth.Refresh(context.Background(), SpotifyUserToken{RefreshToken: "revoked"})
+	if !errors.Is(err, ErrSpotifyRefreshTokenRevoked) {
+		t.Fatalf("expected revoked refresh token error, got %v", err)
+	}
+}
+
+func TestSaveSpotifyUserTokenWritesOwnerOnlyCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".spotdl", ".spotipy")
+	token := SpotifyUserToken{AccessToken: "a", TokenType: "Bearer", RefreshToken: "r", Scope: SpotDLUserAuthScope, ExpiresAt: 42}
+	if err := SaveSpotifyUserToken(path, token); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected 0600 token cache, got %o", info.Mode().Perm())
+	}
+	raw, _ := os.ReadFile(path)
+	if !strings.Contains(string(raw), `"expires_at":42`) || !strings.Contains(string(raw), `"refresh_token":"r"`) {
+		t.Fatalf("expected spotipy cache layout, got %s", raw)
+	}
+	loaded, err := LoadSpotifyUserToken(path)
+	if err != nil || loaded != token {
+		t.Fatalf("expected round trip, got %+v (%v)", loaded, err)
+	}
+	if _, err := LoadSpotifyUserToken(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, ErrSpotifyUserTokenNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
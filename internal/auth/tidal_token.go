@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// TidalTokenFileName is the file tidal-dl reads its login session from,
+// relative to $HOME.
+const TidalTokenFileName = ".tidal-dl.token.json"
+
+var ErrTidalTokenNotFound = errors.New("tidal-dl token file not found")
+
+// TidalTokenResolver locates the tidal-dl session token file. Only the path is
+// resolved; udl never reads or logs the token contents.
+type TidalTokenResolver struct {
+	Getenv  func(string) string
+	HomeDir func() (string, error)
+	Stat    func(string) (os.FileInfo, error)
+}
+
+func ResolveTidalTokenFile() (string, CredentialStorageSource, error) {
+	return TidalTokenResolver{
+		Getenv:  os.Getenv,
+		HomeDir: os.UserHomeDir,
+		Stat:    os.Stat,
+	}.ResolveWithSource()
+}
+
+func (r TidalTokenResolver) ResolveWithSource() (string, CredentialStorageSource, error) {
+	getenv := r.Getenv
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+	stat := r.Stat
+	if stat == nil {
+		stat = os.Stat
+	}
+
+	path := ""
+	storage := CredentialStorageSourceTokenFile
+	if override := strings.TrimSpace(getenv("UDL_TIDAL_TOKEN_FILE")); override != "" {
+		expanded, err := config.ExpandPath(override)
+		if err != nil {
+			return "", CredentialStorageSourceNone, err
+		}
+		path = expanded
+		storage = CredentialStorageSourceEnv
+	} else {
+		homeDir := r.HomeDir
+		if homeDir == nil {
+			homeDir = os.UserHomeDir
+		}
+		home, err := homeDir()
+		if err != nil {
+			return "", CredentialStorageSourceNone, err
+		}
+		path = filepath.Join(home, TidalTokenFileName)
+	}
+
+	info, err := stat(path)
+	if err != nil || info.IsDir() || info.Size() == 0 {
+		return path, CredentialStorageSourceNone, ErrTidalTokenNotFound
+	}
+	return path, storage, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTidalTokenResolverPrefersEnvOverride(t *testing.T) {
+	dir := t.TempDir()
+	tokenPath := filepath.Join(dir, TidalTokenFileName)
+	if err := os.WriteFile(tokenPath, []byte("token"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	resolver := TidalTokenResolver{
+		Getenv: func(key string) string {
+			if key == "UDL_TIDAL_TOKEN_FILE" {
+				return tokenPath
+			}
+			return ""
+		},
+		HomeDir: func() (string, error) {
+			return "", errors.New("should not resolve home")
+		},
+	}
+
+	got, source, err := resolver.ResolveWithSource()
+	if err != nil {
+		t.Fatalf("resolve token file: %v", err)
+	}
+	if got != tokenPath || source != CredentialStorageSourceEnv {
+		t.Fatalf("unexpected resolution path=%q source=%q", got, source)
+	}
+}
+
+func TestTidalTokenResolverReportsMissingDefaultFile(t *testing.T) {
+	home := t.TempDir()
+	resolver := TidalTokenResolver{
+		Getenv:  func(key string) string { return "" },
+		HomeDir: func() (string, error) { return home, nil },
+	}
+
+	got, _, err := resolver.ResolveWithSource()
+	if !errors.Is(err, ErrTidalTokenNotFound) {
+		t.Fatalf("expected ErrTidalTokenNotFound, got %v", err)
+	}
+	if got != filepath.Join(home, TidalTokenFileName) {
+		t.Fatalf("expected default token path, got %q", got)
+	}
+
+	if err := os.WriteFile(got, []byte("token"), 0o600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	if _, source, err := resolver.ResolveWithSource(); err != nil || source != CredentialStorageSourceTokenFile {
+		t.Fatalf("expected default token file to resolve, got source=%q err=%v", source, err)
+	}
+}
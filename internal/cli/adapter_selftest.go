@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+var newAdapterSelfTestRunnerFn = func() engine.ExecRunner {
+	return engine.NewSubprocessRunner(nil, nil, nil)
+}
+
+func newAdapterCommand(app *AppContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "adapter",
+		Short: "Inspect download adapters",
+	}
+	cmd.AddCommand(newAdapterTestCommand(app))
+	return cmd
+}
+
+func newAdapterTestCommand(app *AppContext) *cobra.Command {
+	opts := engine.AdapterSelfTestOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "test <kind>",
+		Short: "Probe an adapter's tool, credentials, and a one-track download",
+		Long: "Run a minimal end-to-end probe of one adapter outside of any configured source: the tool's version " +
+			"against udl's minimum, the credentials it needs, and a one-track download of a known public URL into " +
+			"a temporary directory that is removed afterwards. Use it to tell whether a failing sync is udl's " +
+			"planning or the underlying tool.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registry := syncAdapterRegistry()
+			kind := strings.TrimSpace(args[0])
+			adapter, ok := registry[kind]
+			if !ok {
+				kinds := make([]string, 0, len(registry))
+				for candidate := range registry {
+					kinds = append(kinds, candidate)
+				}
+				sort.Strings(kinds)
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("unknown adapter kind %q (expected one of: %s)", kind, strings.Join(kinds, ", ")))
+			}
+			if _, err := loadConfig(app); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			opts.SkipDownload = app.Opts.DryRun
+			report := engine.RunAdapterSelfTest(cmd.Context(), adapter, newAdapterSelfTestRunnerFn(), opts)
+			if app.Opts.JSON {
+				if err := json.NewEncoder(app.IO.Out).Encode(report); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				for _, step := range report.Steps {
+					fmt.Fprintf(app.IO.Out, "[%s] %-8s %6s  %s\n", step.Status, step.Name, formatSelfTestDuration(step.DurationMS), step.Detail)
+				}
+				if report.Dir != "" {
+					fmt.Fprintf(app.IO.Out, "kept download directory %s\n", report.Dir)
+				}
+				verdict := "passed"
+				if !report.Passed {
+					verdict = "failed"
+				}
+				fmt.Fprintf(app.IO.Out, "adapter %s %s in %s\n", report.Kind, verdict, formatSelfTestDuration(report.DurationMS))
+			}
+			if !report.Passed {
+				return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("adapter %s self-test failed", report.Kind))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.URL, "url", "", "One-track URL to download instead of the built-in public track")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 5*time.Minute, "Timeout for the test download")
+	cmd.Flags().BoolVar(&opts.KeepDir, "keep", false, "Keep the temporary download directory for inspection")
+	return cmd
+}
+
+func formatSelfTestDuration(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(10 * time.Millisecond).String()
+}
@@ -0,0 +1,165 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type artworkOptions struct {
+	SourceIDs    []string
+	Apply        bool
+	MinSize      int
+	ProbeTimeout time.Duration
+}
+
+func newArtworkCommand(app *AppContext) *cobra.Command {
+	opts := artworkOptions{MinSize: engine.DefaultArtworkMinSize, ProbeTimeout: 10 * time.Second}
+
+	cmd := &cobra.Command{
+		Use:   "artwork",
+		Short: "Find tracks with missing or low-resolution embedded covers and re-embed high-res artwork",
+		Long: "Probe the embedded cover of every tracked file and list those with no cover or one smaller than " +
+			"--min-size pixels, with the track's artwork on the SoundCloud, Spotify, or Deezer track it was " +
+			"downloaded from. Use --apply to download that artwork and embed it with ffmpeg in place of the " +
+			"current cover; the audio and tags are copied unchanged, and artwork no larger than the current cover is skipped.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.MinSize <= 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--min-size must be > 0"))
+			}
+			if opts.ProbeTimeout <= 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--probe-timeout must be > 0"))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			dependencies := []string{"ffprobe"}
+			if opts.Apply && !app.Opts.DryRun {
+				dependencies = append(dependencies, "ffmpeg")
+			}
+			for _, dependency := range dependencies {
+				if _, err := verifyLookPathFn(dependency); err != nil {
+					return withExitCode(exitcode.MissingDependency, fmt.Errorf("required dependency %q not found in PATH", dependency))
+				}
+			}
+
+			engineOpts := engine.ArtworkOptions{MinSize: opts.MinSize, ProbeTimeout: opts.ProbeTimeout}
+			reports, err := engine.ScanArtwork(cmd.Context(), cfg, opts.SourceIDs, engineOpts)
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			embedded := 0
+			previewApply := opts.Apply && app.Opts.DryRun
+			if opts.Apply && !app.Opts.DryRun {
+				embedded = engine.ApplyArtworkPlan(cmd.Context(), reports, engineOpts)
+			}
+
+			scanned, missing, lowRes, unsupported, problems := 0, 0, 0, 0, 0
+			for _, report := range reports {
+				scanned += report.Scanned
+				unsupported += report.Unsupported
+				problems += len(report.Errors)
+				for _, candidate := range report.Candidates {
+					if candidate.Reason == engine.ArtworkReasonMissing {
+						missing++
+					} else {
+						lowRes++
+					}
+				}
+			}
+
+			if app.Opts.JSON {
+				payload := map[string]any{"sources": reports, "embedded": embedded}
+				if err := json.NewEncoder(app.IO.Out).Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				for _, report := range reports {
+					printArtworkReport(app, report)
+				}
+				mode := "plan"
+				if previewApply {
+					mode = "apply-preview"
+				} else if opts.Apply {
+					mode = "apply"
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"artwork: summary sources=%d scanned=%d missing=%d low_res=%d unsupported=%d embedded=%d mode=%s\n",
+					len(reports),
+					scanned,
+					missing,
+					lowRes,
+					unsupported,
+					embedded,
+					mode,
+				)
+			}
+
+			if problems > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("artwork finished with %d error(s)", problems))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.SourceIDs, "source", nil, "Scan only selected source id (repeatable)")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "Download the source artwork and embed it (honors --dry-run)")
+	cmd.Flags().IntVar(&opts.MinSize, "min-size", opts.MinSize, "Covers with a shorter edge below this many pixels count as low resolution")
+	cmd.Flags().DurationVar(&opts.ProbeTimeout, "probe-timeout", opts.ProbeTimeout, "Per-file ffprobe timeout")
+	return cmd
+}
+
+func printArtworkReport(app *AppContext, report engine.SourceArtworkReport) {
+	if report.Skipped != "" {
+		fmt.Fprintf(app.IO.Out, "[%s] skipped: %s\n", report.SourceID, report.Skipped)
+		return
+	}
+	fmt.Fprintf(
+		app.IO.Out,
+		"[%s] scanned=%d up_to_date=%d unsupported=%d candidates=%d\n",
+		report.SourceID,
+		report.Scanned,
+		report.UpToDate,
+		report.Unsupported,
+		len(report.Candidates),
+	)
+	for i, candidate := range report.Candidates {
+		if !app.Opts.Verbose && i >= 20 {
+			fmt.Fprintf(app.IO.Out, "  ... %d more file(s) (use --verbose to list all)\n", len(report.Candidates)-i)
+			break
+		}
+		current := "no cover"
+		if candidate.Reason == engine.ArtworkReasonLowRes {
+			current = fmt.Sprintf("%dx%d", candidate.Width, candidate.Height)
+		}
+		line := fmt.Sprintf("  [%s] %s (%s)", candidate.Reason, candidate.LocalPath, current)
+		switch {
+		case candidate.Embedded:
+			line += " embedded"
+		case candidate.Note != "":
+			line += ": " + candidate.Note
+		case candidate.ArtworkURL == "":
+			line += ": no source artwork"
+		}
+		fmt.Fprintln(app.IO.Out, line)
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(app.IO.ErrOut, "[%s] error: %s\n", report.SourceID, problem)
+	}
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+var openCredentialStoreFn = auth.OpenCredentialStore
+
+func newAuthCommand(app *AppContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Manage credentials in the encrypted credential store",
+		Long: "Store the Deezer ARL and Spotify app credentials in an encrypted file in state_dir " +
+			"(credentials.enc). The key comes from UDL_CREDENTIALS_KEY or macOS Keychain. Stored credentials " +
+			"take precedence over UDL_* environment variables and Keychain entries.",
+	}
+	cmd.AddCommand(newAuthSetCommand(app))
+	cmd.AddCommand(newAuthGetCommand(app))
+	cmd.AddCommand(newAuthRemoveCommand(app))
+	cmd.AddCommand(newAuthListCommand(app))
+	return cmd
+}
+
+func newAuthSetCommand(app *AppContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <name>",
+		Short: "Store a credential, read from stdin",
+		Long: "Store a credential. The value is read from stdin (or prompted for) rather than taken as an " +
+			"argument, so it does not end up in shell history or the process list.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := auth.ValidCredentialName(name); err != nil {
+				return withExitCode(exitcode.InvalidUsage, err)
+			}
+			store, err := openAuthCredentialStore(app)
+			if err != nil {
+				return err
+			}
+			value, err := promptLine(app, "Value for "+name)
+			if err != nil || value == "" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("auth set: no value provided on stdin for %s", name))
+			}
+			if app.Opts.DryRun {
+				fmt.Fprintf(app.IO.Out, "auth: dry-run; not storing %s in %s\n", name, store.Path)
+				return nil
+			}
+			if err := store.Set(name, value); err != nil {
+				return withExitCode(authStoreExitCode(err), err)
+			}
+			fmt.Fprintf(app.IO.Out, "auth: stored %s in %s\n", name, store.Path)
+			return nil
+		},
+	}
+}
+
+func newAuthGetCommand(app *AppContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <name>",
+		Short: "Print a stored credential",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := auth.ValidCredentialName(name); err != nil {
+				return withExitCode(exitcode.InvalidUsage, err)
+			}
+			store, err := openAuthCredentialStore(app)
+			if err != nil {
+				return err
+			}
+			value, ok, err := store.Get(name)
+			if err != nil {
+				return withExitCode(authStoreExitCode(err), err)
+			}
+			if !ok {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("%s is not stored in %s", name, store.Path))
+			}
+			fmt.Fprintln(app.IO.Out, value)
+			return nil
+		},
+	}
+}
+
+func newAuthRemoveCommand(app *AppContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Delete a stored credential",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := auth.ValidCredentialName(name); err != nil {
+				return withExitCode(exitcode.InvalidUsage, err)
+			}
+			store, err := openAuthCredentialStore(app)
+			if err != nil {
+				return err
+			}
+			if app.Opts.DryRun {
+				fmt.Fprintf(app.IO.Out, "auth: dry-run; not removing %s from %s\n", name, store.Path)
+				return nil
+			}
+			removed, err := store.Remove(name)
+			if err != nil {
+				return withExitCode(authStoreExitCode(err), err)
+			}
+			if !removed {
+				fmt.Fprintf(app.IO.Out, "auth: %s was not stored\n", name)
+				return nil
+			}
+			fmt.Fprintf(app.IO.Out, "auth: removed %s\n", name)
+			return nil
+		},
+	}
+}
+
+func newAuthListCommand(app *AppContext) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List stored credential names (never their values)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := openAuthCredentialStore(app)
+			if err != nil {
+				return err
+			}
+			entries, err := store.List()
+			if err != nil {
+				return withExitCode(authStoreExitCode(err), err)
+			}
+			if app.Opts.JSON {
+				if err := json.NewEncoder(app.IO.Out).Encode(map[string]any{"path": store.Path, "credentials": entries}); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			if len(entries) == 0 {
+				fmt.Fprintf(app.IO.Out, "auth: no credentials stored in %s\n", store.Path)
+				return nil
+			}
+			for _, entry := range entries {
+				fmt.Fprintf(app.IO.Out, "%s\tupdated %s\n", entry.Name, entry.UpdatedAt.Local().Format(time.RFC3339))
+			}
+			return nil
+		},
+	}
+}
+
+func openAuthCredentialStore(app *AppContext) (auth.CredentialStore, error) {
+	cfg, err := loadConfig(app)
+	if err != nil {
+		return auth.CredentialStore{}, withExitCode(exitcode.InvalidConfig, err)
+	}
+	store, err := openCredentialStoreFn(cfg.Defaults.StateDir)
+	if err != nil {
+		return auth.CredentialStore{}, withExitCode(exitcode.InvalidConfig, err)
+	}
+	return store, nil
+}
+
+func authStoreExitCode(err error) int {
+	if errors.Is(err, auth.ErrCredentialStoreKeyMissing) {
+		return exitcode.InvalidConfig
+	}
+	return exitcode.RuntimeFailure
+}
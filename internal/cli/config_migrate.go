@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+func newConfigCommand(app *AppContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Maintain the udl config file",
+	}
+	cmd.AddCommand(newConfigMigrateCommand(app))
+	cmd.AddCommand(newConfigMigrateArchivesCommand(app))
+	return cmd
+}
+
+func newConfigMigrateCommand(app *AppContext) *cobra.Command {
+	var apply bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade a version 1 config file to the current schema",
+		Long: "Preview or apply an upgrade of the config file (--config or the user config) to schema version 2, " +
+			"which adds profiles and per-source defaults. --apply keeps the original next to it as <file>.v<N>.bak " +
+			"and rewrites the file in canonical form, so YAML comments are not preserved.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			configPath, _, err := tuiResolveConfigEditorTargetPath(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			original, err := os.ReadFile(configPath)
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return withExitCode(exitcode.InvalidConfig, fmt.Errorf("config file does not exist: %s", configPath))
+				}
+				return withExitCode(exitcode.InvalidConfig, fmt.Errorf("read config file %s: %w", configPath, err))
+			}
+			cfg, err := config.ParseSingleFile(configPath, original)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			migrated, changes, err := config.Migrate(cfg)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			applied := false
+			backupPath := ""
+			if len(changes) > 0 && apply && !app.Opts.DryRun {
+				backupPath = fmt.Sprintf("%s.v%d.bak", configPath, cfg.Version)
+				if err := os.WriteFile(backupPath, original, 0o644); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("write backup %s: %w", backupPath, err))
+				}
+				if _, err := config.SaveSingleFile(configPath, migrated); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				applied = true
+			}
+
+			if app.Opts.JSON {
+				encoder := json.NewEncoder(app.IO.Out)
+				payload := map[string]any{
+					"path":         configPath,
+					"from_version": cfg.Version,
+					"to_version":   migrated.Version,
+					"changes":      append([]string{}, changes...),
+					"applied":      applied,
+				}
+				if backupPath != "" {
+					payload["backup"] = backupPath
+				}
+				if err := encoder.Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+
+			if len(changes) == 0 {
+				fmt.Fprintf(app.IO.Out, "config migrate: %s is already at version %d\n", configPath, cfg.Version)
+				return nil
+			}
+			if !applied {
+				fmt.Fprintln(app.IO.Out, "config migrate: preview mode (set --apply to write changes)")
+			}
+			for _, change := range changes {
+				fmt.Fprintf(app.IO.Out, "[plan] %s\n", change)
+			}
+			if applied {
+				fmt.Fprintf(app.IO.Out, "config migrate: updated %s (backup: %s)\n", configPath, backupPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&apply, "apply", false, "Write the migrated config (default is preview-only)")
+	return cmd
+}
@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+func newConfigMigrateArchivesCommand(app *AppContext) *cobra.Command {
+	var apply bool
+	var tombstone bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate-archives",
+		Short: "Move legacy archive files from target_dir into state_dir",
+		Long: "Preview or apply moving download archives that older configs kept in a source's target_dir into " +
+			"state_dir as <id>.archive.txt, merging with any archive already there. The old file is replaced by a " +
+			"symlink to the new one (or a read-only tombstone with --tombstone) so stale tooling does not start a " +
+			"second archive, and per-source defaults.archive_file values pointing at the old file are removed.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			migrations, err := engine.PlanArchiveMigrations(cfg)
+			if err != nil {
+				return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("plan archive migration: %w", err))
+			}
+
+			previewMode := app.Opts.DryRun || !apply
+			link := engine.ArchiveLinkSymlink
+			if tombstone {
+				link = engine.ArchiveLinkTombstone
+			}
+
+			configPath, _, err := tuiResolveConfigEditorTargetPath(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			var fileCfg *config.Config
+			cleared := []string{}
+			if info, statErr := os.Stat(configPath); statErr == nil && !info.IsDir() {
+				loaded, loadErr := config.LoadSingleFile(configPath)
+				if loadErr != nil {
+					return withExitCode(exitcode.InvalidConfig, loadErr)
+				}
+				cleared = clearMigratedArchiveFiles(&loaded, migrations)
+				fileCfg = &loaded
+			} else if statErr != nil && !errors.Is(statErr, os.ErrNotExist) {
+				return withExitCode(exitcode.InvalidConfig, fmt.Errorf("inspect config file %s: %w", configPath, statErr))
+			}
+
+			if !previewMode && len(migrations) > 0 {
+				if err := engine.ApplyArchiveMigrations(cmd.Context(), cfg.Defaults.StateDir, migrations, link); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("migrate archives: %w", err))
+				}
+				if fileCfg != nil && len(cleared) > 0 {
+					if _, err := config.SaveSingleFile(configPath, *fileCfg); err != nil {
+						return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("write config: %w", err))
+					}
+				}
+			}
+
+			if app.Opts.JSON {
+				items := make([]map[string]any, 0, len(migrations))
+				for _, migration := range migrations {
+					item := map[string]any{
+						"source_id":  migration.SourceID,
+						"from":       migration.From,
+						"to":         migration.To,
+						"entries":    migration.Entries,
+						"duplicates": migration.Duplicates,
+						"shared":     migration.Shared,
+					}
+					if migration.Link != "" {
+						item["link"] = migration.Link
+					}
+					items = append(items, item)
+				}
+				payload := map[string]any{
+					"migrations":     items,
+					"config_cleared": cleared,
+					"applied":        !previewMode && len(migrations) > 0,
+				}
+				if err := json.NewEncoder(app.IO.Out).Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+
+			if len(migrations) == 0 {
+				fmt.Fprintln(app.IO.Out, "config migrate-archives: no legacy archives found in target_dir")
+				return nil
+			}
+			if previewMode && !app.Opts.DryRun {
+				fmt.Fprintln(app.IO.Out, "config migrate-archives: preview mode (set --apply to write changes)")
+			}
+			for _, migration := range migrations {
+				replacement := link
+				if migration.Link != "" {
+					replacement = migration.Link
+				} else if migration.Shared {
+					replacement = engine.ArchiveLinkTombstone
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"[plan] source %s archive %s -> %s: entries=%d duplicates=%d replace=%s\n",
+					migration.SourceID,
+					migration.From,
+					migration.To,
+					migration.Entries,
+					migration.Duplicates,
+					replacement,
+				)
+			}
+			for _, id := range cleared {
+				fmt.Fprintf(app.IO.Out, "[plan] config sources[%s].defaults.archive_file: remove\n", id)
+			}
+			fmt.Fprintf(
+				app.IO.Out,
+				"config migrate-archives: summary archives=%d config_fields=%d mode=%s\n",
+				len(migrations),
+				len(cleared),
+				map[bool]string{true: "preview", false: "apply"}[previewMode],
+			)
+			if !previewMode && fileCfg != nil && len(cleared) > 0 {
+				fmt.Fprintf(app.IO.Out, "config migrate-archives: updated %s\n", configPath)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&apply, "apply", false, "Move archives and update config (default is preview-only)")
+	cmd.Flags().BoolVar(&tombstone, "tombstone", false, "Leave a read-only tombstone instead of a symlink at the old path")
+	return cmd
+}
+
+// clearMigratedArchiveFiles drops per-source archive_file overrides that
+// pointed at a migrated legacy archive and returns the affected source ids.
+func clearMigratedArchiveFiles(cfg *config.Config, migrations []engine.ArchiveMigration) []string {
+	targets := map[string]struct{}{}
+	for _, migration := range migrations {
+		if migration.ClearConfig {
+			targets[migration.SourceID] = struct{}{}
+		}
+	}
+	cleared := []string{}
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		if _, ok := targets[source.ID]; !ok || source.Defaults.ArchiveFile == "" {
+			continue
+		}
+		source.Defaults.ArchiveFile = ""
+		cleared = append(cleared, source.ID)
+	}
+	return cleared
+}
@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestConfigMigratePreviewsThenAppliesWithBackup(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + filepath.Join(tmp, "state") + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + filepath.Join(tmp, "music") + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	run := func(args ...string) string {
+		t.Helper()
+		out := &bytes.Buffer{}
+		app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+		cmd := newConfigMigrateCommand(app)
+		cmd.SetArgs(args)
+		cmd.SetOut(out)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("config migrate %v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	preview := run()
+	if !strings.Contains(preview, "preview mode") || !strings.Contains(preview, "[plan] version: 1 -> 2") {
+		t.Fatalf("unexpected preview output: %s", preview)
+	}
+	if raw, _ := os.ReadFile(configPath); string(raw) != payload {
+		t.Fatalf("preview must not rewrite the config, got %s", raw)
+	}
+
+	applied := run("--apply")
+	if !strings.Contains(applied, "config migrate: updated") {
+		t.Fatalf("unexpected apply output: %s", applied)
+	}
+	backup, err := os.ReadFile(configPath + ".v1.bak")
+	if err != nil || string(backup) != payload {
+		t.Fatalf("expected original config backup, got %q err=%v", backup, err)
+	}
+	cfg, err := config.LoadSingleFile(configPath)
+	if err != nil {
+		t.Fatalf("load migrated config: %v", err)
+	}
+	if cfg.Version != config.CurrentVersion || len(cfg.Sources) != 1 || cfg.Sources[0].URL != "https://soundcloud.com/user" {
+		t.Fatalf("unexpected migrated config: %+v", cfg)
+	}
+
+	if again := run("--apply"); !strings.Contains(again, "already at version 2") {
+		t.Fatalf("expected no-op on second migrate, got %s", again)
+	}
+}
+
+func TestConfigMigrateArchivesClearsLegacyArchiveOverride(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	legacy := filepath.Join(targetDir, "downloaded.txt")
+	if err := os.WriteFile(legacy, []byte("youtube abc\n"), 0o644); err != nil {
+		t.Fatalf("write legacy archive: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 2\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: yt\n    type: youtube\n    enabled: true\n" +
+		"    target_dir: " + targetDir + "\n" +
+		"    url: https://www.youtube.com/playlist?list=abc\n" +
+		"    defaults:\n      archive_file: " + legacy + "\n" +
+		"    adapter:\n      kind: ytdlp\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newConfigMigrateArchivesCommand(app)
+	cmd.SetArgs([]string{"--apply", "--tombstone"})
+	cmd.SetOut(out)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("config migrate-archives: %v", err)
+	}
+	if !strings.Contains(out.String(), "summary archives=1 config_fields=1 mode=apply") {
+		t.Fatalf("unexpected output: %s", out.String())
+	}
+
+	managed, err := os.ReadFile(filepath.Join(stateDir, "yt.archive.txt"))
+	if err != nil || string(managed) != "youtube abc\n" {
+		t.Fatalf("expected managed archive, got %q err=%v", managed, err)
+	}
+	cfg, err := config.LoadSingleFile(configPath)
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.Sources[0].Defaults.ArchiveFile != "" {
+		t.Fatalf("expected archive_file override to be removed, got %q", cfg.Sources[0].Defaults.ArchiveFile)
+	}
+}
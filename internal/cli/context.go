@@ -16,11 +16,13 @@ type IOStreams struct {
 
 type GlobalOptions struct {
 	ConfigPath    string
+	Profile       string
 	JSON          bool
 	Quiet         bool
 	Verbose       bool
 	NoColor       bool
 	NoInput       bool
+	Headless      bool
 	DryRun        bool
 	AskOnExisting bool
 	ScanGaps      bool
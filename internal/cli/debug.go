@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/doctor"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// debugBundleTools are the helper binaries whose versions go into a debug
+// bundle, with the flag each one prints its version for.
+var debugBundleTools = []struct {
+	Binary string
+	Flag   string
+}{
+	{Binary: "scdl", Flag: "--version"},
+	{Binary: "yt-dlp", Flag: "--version"},
+	{Binary: "spotdl", Flag: "--version"},
+	{Binary: "deemix", Flag: "--version"},
+	{Binary: "ffmpeg", Flag: "-version"},
+	{Binary: "ffprobe", Flag: "-version"},
+}
+
+var (
+	debugBundleKeyFn       = randomDebugBundleKey
+	debugLookPathFn        = exec.LookPath
+	readDebugToolVersionFn = readDebugToolVersion
+	runDebugDoctorFn       = runDebugDoctor
+)
+
+type debugBundleOptions struct {
+	Output     string
+	StateLines int
+	Runs       int
+	Online     bool
+}
+
+type debugBundleManifest struct {
+	CreatedAt       time.Time `json:"created_at"`
+	UDLVersion      string    `json:"udl_version"`
+	UDLCommit       string    `json:"udl_commit"`
+	UDLBuildDate    string    `json:"udl_build_date"`
+	GoVersion       string    `json:"go_version"`
+	OS              string    `json:"os"`
+	Arch            string    `json:"arch"`
+	ValidationError string    `json:"validation_error,omitempty"`
+	Files           []string  `json:"files"`
+	Notes           []string  `json:"notes"`
+}
+
+type debugToolVersion struct {
+	Binary  string `json:"binary"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type debugBundleFile struct {
+	Name string
+	Data []byte
+}
+
+type debugBundleResult struct {
+	Path  string   `json:"path"`
+	Files []string `json:"files"`
+}
+
+func newDebugCommand(app *AppContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Collect diagnostics for bug reports",
+	}
+	cmd.AddCommand(newDebugBundleCommand(app))
+	return cmd
+}
+
+func newDebugBundleCommand(app *AppContext) *cobra.Command {
+	opts := debugBundleOptions{StateLines: 50, Runs: 5}
+
+	cmd := &cobra.Command{
+		Use:   "bundle",
+		Short: "Write a redacted zip of config, state excerpts, run reports, doctor output, and tool versions",
+		Long: "Collect the redacted config, the tail of every state file with track IDs hashed and titles " +
+			"and paths removed, the most recent run reports, doctor output, and tool versions into one zip " +
+			"to attach to an issue. IDs are hashed with a key generated for the bundle and not stored, so " +
+			"they match across the files of one bundle only.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.StateLines < 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --state-lines %d", opts.StateLines))
+			}
+			if opts.Runs < 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --runs %d", opts.Runs))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			output := strings.TrimSpace(opts.Output)
+			if output == "" {
+				output = fmt.Sprintf("udl-debug-%s.zip", time.Now().UTC().Format("20060102T150405Z"))
+			}
+
+			files, err := collectDebugBundle(cmd.Context(), app, cfg, opts)
+			if err != nil {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+			result := debugBundleResult{Path: output, Files: []string{}}
+			for _, file := range files {
+				result.Files = append(result.Files, file.Name)
+			}
+			if app.Opts.DryRun {
+				for _, name := range result.Files {
+					fmt.Fprintf(app.IO.Out, "[plan] %s\n", name)
+				}
+				fmt.Fprintf(app.IO.Out, "[plan] write debug bundle to %s\n", output)
+				return nil
+			}
+			if err := writeDebugBundle(output, files); err != nil {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			if app.Opts.JSON {
+				if err := json.NewEncoder(app.IO.Out).Encode(result); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			fmt.Fprintf(app.IO.Out, "[ok] wrote debug bundle to %s (%d files); review it before attaching\n", output, len(files))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Output, "output", "o", "", "Bundle path (default udl-debug-<timestamp>.zip in the current directory)")
+	cmd.Flags().IntVar(&opts.StateLines, "state-lines", opts.StateLines, "Most recent lines kept from each state file")
+	cmd.Flags().IntVar(&opts.Runs, "runs", opts.Runs, "Most recent run reports to include")
+	cmd.Flags().BoolVar(&opts.Online, "online", false, "Let doctor run its network probes (Spotify API throttling check, compat.url refresh)")
+	return cmd
+}
+
+// collectDebugBundle gathers the bundle's files in archive order, with every
+// path under the home directory shown as ~ and URLs redacted.
+func collectDebugBundle(ctx context.Context, app *AppContext, cfg config.Config, opts debugBundleOptions) ([]debugBundleFile, error) {
+	key, err := debugBundleKeyFn()
+	if err != nil {
+		return nil, err
+	}
+	home, _ := os.UserHomeDir()
+	anonymizer := engine.DebugAnonymizer{Key: key, Home: home}
+
+	manifest := debugBundleManifest{
+		CreatedAt:    time.Now().UTC(),
+		UDLVersion:   app.Build.Version,
+		UDLCommit:    app.Build.Commit,
+		UDLBuildDate: app.Build.Date,
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Notes: []string{
+			"config.yaml is redacted: credentials, tokens, and private share links are removed",
+			"state.json keeps each state file's headers, quality, and provider; track IDs are hashed with a key not stored anywhere, titles are removed, and paths keep only their extension",
+		},
+	}
+	if err := config.Validate(cfg); err != nil {
+		manifest.ValidationError = anonymizer.ScrubDebugText(err.Error())
+	}
+
+	files := []debugBundleFile{}
+	addJSON := func(name string, value any) error {
+		payload, err := marshalDebugJSON(value)
+		if err != nil {
+			return err
+		}
+		files = append(files, debugBundleFile{Name: name, Data: payload})
+		return nil
+	}
+
+	configYAML, err := config.MarshalCanonical(config.Redacted(cfg))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, debugBundleFile{Name: "config.yaml", Data: []byte(anonymizer.ScrubDebugText(string(configYAML)))})
+
+	excerpts, err := engine.DebugStateExcerpts(cfg, anonymizer, opts.StateLines)
+	if err != nil {
+		return nil, err
+	}
+	if err := addJSON("state.json", excerpts); err != nil {
+		return nil, err
+	}
+
+	reports, err := engine.DebugRunReports(cfg.Defaults.StateDir, anonymizer, opts.Runs)
+	if err != nil {
+		return nil, err
+	}
+	if err := addJSON("runs.json", reports); err != nil {
+		return nil, err
+	}
+
+	report := runDebugDoctorFn(ctx, cfg, opts.Online)
+	for i := range report.Checks {
+		report.Checks[i].Message = anonymizer.ScrubDebugText(report.Checks[i].Message)
+	}
+	if err := addJSON("doctor.json", report); err != nil {
+		return nil, err
+	}
+
+	versions := []debugToolVersion{}
+	for _, tool := range debugBundleTools {
+		entry := debugToolVersion{Binary: tool.Binary}
+		path, err := debugLookPathFn(tool.Binary)
+		if err != nil {
+			entry.Error = "not found on PATH"
+			versions = append(versions, entry)
+			continue
+		}
+		entry.Path = anonymizer.ScrubDebugText(path)
+		version, err := readDebugToolVersionFn(ctx, path, tool.Flag)
+		if err != nil {
+			entry.Error = anonymizer.ScrubDebugText(err.Error())
+		}
+		entry.Version = anonymizer.ScrubDebugText(version)
+		versions = append(versions, entry)
+	}
+	if err := addJSON("versions.json", versions); err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		manifest.Files = append(manifest.Files, file.Name)
+	}
+	manifestPayload, err := marshalDebugJSON(manifest)
+	if err != nil {
+		return nil, err
+	}
+	files = append([]debugBundleFile{{Name: "manifest.json", Data: manifestPayload}}, files...)
+	return files, nil
+}
+
+// marshalDebugJSON indents value and leaves <redacted> markers unescaped so
+// the bundle reads as plain text.
+func marshalDebugJSON(value any) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeDebugBundle writes files into a new zip at path; an existing file is
+// never overwritten.
+func writeDebugBundle(path string, files []debugBundleFile) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+	writeErr := func() error {
+		for _, entry := range files {
+			writer, err := archive.Create(entry.Name)
+			if err != nil {
+				return err
+			}
+			if _, err := writer.Write(entry.Data); err != nil {
+				return err
+			}
+		}
+		return archive.Close()
+	}()
+	closeErr := file.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		_ = os.Remove(path)
+		return writeErr
+	}
+	return nil
+}
+
+func runDebugDoctor(ctx context.Context, cfg config.Config, online bool) doctor.Report {
+	checker := doctor.NewChecker()
+	if !online {
+		checker.ProbeSpotifyAPI = nil
+		checker.FetchCompatMatrix = nil
+	}
+	return workflows.DoctorUseCase{Checker: checker}.Run(ctx, cfg)
+}
+
+func randomDebugBundleKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// readDebugToolVersion returns the first line a binary prints for its
+// version flag.
+func readDebugToolVersion(ctx context.Context, path string, flag string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, flag).CombinedOutput()
+	scanner := bufio.NewScanner(strings.NewReader(string(out)))
+	first := ""
+	if scanner.Scan() {
+		first = strings.TrimSpace(scanner.Text())
+	}
+	return first, err
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/doctor"
+)
+
+func TestDebugBundleWritesRedactedArchive(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + filepath.Join(tmp, "music") + "\n" +
+		"    url: https://soundcloud.com/user/sets/mix/s-PrivateToken\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "sc.sync.scdl"), []byte("soundcloud 98765 Secret Demo.m4a\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	origKey := debugBundleKeyFn
+	origLookPath := debugLookPathFn
+	origReadVersion := readDebugToolVersionFn
+	origDoctor := runDebugDoctorFn
+	t.Cleanup(func() {
+		debugBundleKeyFn = origKey
+		debugLookPathFn = origLookPath
+		readDebugToolVersionFn = origReadVersion
+		runDebugDoctorFn = origDoctor
+	})
+	debugBundleKeyFn = func() ([]byte, error) { return []byte("key"), nil }
+	debugLookPathFn = func(binary string) (string, error) {
+		if binary == "scdl" {
+			return "/usr/local/bin/scdl", nil
+		}
+		return "", errors.New("not found")
+	}
+	readDebugToolVersionFn = func(ctx context.Context, path string, flag string) (string, error) {
+		return "3.0.1", nil
+	}
+	doctorOnline := true
+	runDebugDoctorFn = func(ctx context.Context, cfg config.Config, online bool) doctor.Report {
+		doctorOnline = online
+		return doctor.Report{Checks: []doctor.Check{{Severity: doctor.SeverityInfo, Name: "state_dir", Message: "writable: " + stateDir}}}
+	}
+
+	bundlePath := filepath.Join(tmp, "bundle.zip")
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newDebugBundleCommand(app)
+	cmd.SetArgs([]string{"--output", bundlePath})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("debug bundle: %v", err)
+	}
+	if !strings.Contains(out.String(), "[ok] wrote debug bundle to "+bundlePath+" (6 files)") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	if doctorOnline {
+		t.Fatalf("expected doctor to run offline by default")
+	}
+
+	archive, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		t.Fatalf("open bundle: %v", err)
+	}
+	defer archive.Close()
+	contents := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", file.Name, err)
+		}
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		contents[file.Name] = string(data)
+	}
+	for _, name := range []string{"manifest.json", "config.yaml", "state.json", "runs.json", "doctor.json", "versions.json"} {
+		if _, ok := contents[name]; !ok {
+			t.Fatalf("expected %s in bundle, got %v", name, contents)
+		}
+	}
+	all := strings.Join([]string{contents["config.yaml"], contents["state.json"], contents["doctor.json"]}, "\n")
+	for _, leak := range []string{"PrivateToken", "98765", "Secret Demo"} {
+		if strings.Contains(all, leak) {
+			t.Fatalf("expected %q to be redacted from the bundle:\n%s", leak, all)
+		}
+	}
+	if !strings.Contains(contents["state.json"], "<redacted>.m4a") {
+		t.Fatalf("expected the state excerpt to keep the file extension, got %s", contents["state.json"])
+	}
+	if !strings.Contains(contents["versions.json"], `"version": "3.0.1"`) || !strings.Contains(contents["versions.json"], "not found on PATH") {
+		t.Fatalf("unexpected versions.json %s", contents["versions.json"])
+	}
+
+	cmd = newDebugBundleCommand(app)
+	cmd.SetArgs([]string{"--output", bundlePath})
+	if err := cmd.Execute(); err == nil {
+		t.Fatalf("expected an existing bundle not to be overwritten")
+	}
+}
@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type dedupeOptions struct {
+	SourceIDs    []string
+	Apply        bool
+	AudioHash    bool
+	ProbeTimeout time.Duration
+}
+
+func newDedupeCommand(app *AppContext) *cobra.Command {
+	opts := dedupeOptions{ProbeTimeout: 10 * time.Second}
+
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Find duplicate tracks in target directories and plan which copy to keep",
+		Long: "Scan each source's target_dir for duplicate tracks: byte-identical files, \" (1)\" or \" - Copy\" " +
+			"browser copies, and with --audio-hash files whose audio streams match under different tags. The copy " +
+			"kept is the best by codec and bitrate (lossless, then bitrate, then the one without a copy suffix). " +
+			"Copies a state file points at are never removed. Use --apply to move the other copies to trash.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.ProbeTimeout <= 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--probe-timeout must be > 0"))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if opts.AudioHash {
+				if _, err := verifyLookPathFn("ffmpeg"); err != nil {
+					return withExitCode(exitcode.MissingDependency, fmt.Errorf("required dependency %q not found in PATH (needed by --audio-hash)", "ffmpeg"))
+				}
+			}
+			if _, err := verifyLookPathFn("ffprobe"); err != nil && !app.Opts.Quiet {
+				fmt.Fprintln(app.IO.ErrOut, "dedupe: ffprobe not found; copies are ranked by file extension only (install ffmpeg to compare bitrates)")
+			}
+
+			reports, err := engine.ScanDuplicates(cmd.Context(), cfg, opts.SourceIDs, engine.DedupeOptions{
+				ProbeTimeout: opts.ProbeTimeout,
+				AudioHash:    opts.AudioHash,
+			})
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			scanned, groups, removable, problems := 0, 0, 0, 0
+			reclaimable := int64(0)
+			for _, report := range reports {
+				scanned += report.Scanned
+				groups += len(report.Groups)
+				files, bytes := report.Removable()
+				removable += files
+				reclaimable += bytes
+				problems += len(report.Errors)
+			}
+
+			moved := 0
+			previewApply := opts.Apply && app.Opts.DryRun
+			if opts.Apply && !app.Opts.DryRun && removable > 0 {
+				moved, err = engine.ApplyDedupePlan(cfg, reports, time.Now().Format("20060102-150405"))
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("apply dedupe plan (%d file(s) moved): %w", moved, err))
+				}
+			}
+
+			if app.Opts.JSON {
+				payload := map[string]any{"sources": reports, "moved": moved}
+				if err := json.NewEncoder(app.IO.Out).Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				for _, report := range reports {
+					printDedupeReport(app, report)
+				}
+				mode := "plan"
+				if previewApply {
+					mode = "apply-preview"
+				} else if opts.Apply {
+					mode = "apply"
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"dedupe: summary sources=%d scanned=%d groups=%d removable=%d reclaimable=%s moved=%d mode=%s\n",
+					len(reports),
+					scanned,
+					groups,
+					removable,
+					engine.FormatByteSize(reclaimable),
+					moved,
+					mode,
+				)
+			}
+
+			if problems > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("dedupe finished with %d error(s)", problems))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.SourceIDs, "source", nil, "Scan only selected source id (repeatable)")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "Move the copies not kept to trash (honors --dry-run)")
+	cmd.Flags().BoolVar(&opts.AudioHash, "audio-hash", false, "Also match files whose audio streams are identical under different tags (runs ffmpeg on every file)")
+	cmd.Flags().DurationVar(&opts.ProbeTimeout, "probe-timeout", opts.ProbeTimeout, "Per-file ffprobe/ffmpeg timeout")
+	return cmd
+}
+
+func printDedupeReport(app *AppContext, report engine.SourceDedupeReport) {
+	if report.Skipped != "" {
+		fmt.Fprintf(app.IO.Out, "[%s] skipped: %s\n", report.SourceID, report.Skipped)
+		return
+	}
+	files, bytes := report.Removable()
+	fmt.Fprintf(
+		app.IO.Out,
+		"[%s] target=%s scanned=%d groups=%d removable=%d (%s)\n",
+		report.SourceID,
+		report.TargetDir,
+		report.Scanned,
+		len(report.Groups),
+		files,
+		engine.FormatByteSize(bytes),
+	)
+	for i, group := range report.Groups {
+		if !app.Opts.Verbose && i >= 20 {
+			fmt.Fprintf(app.IO.Out, "  ... %d more group(s) (use --verbose to list all)\n", len(report.Groups)-i)
+			break
+		}
+		fmt.Fprintf(app.IO.Out, "  [%s] keep %s (%s)\n", group.Reason, group.Keep.Path, describeDuplicateFile(group.Keep))
+		for _, file := range group.Remove {
+			fmt.Fprintf(app.IO.Out, "    remove %s (%s)\n", file.Path, describeDuplicateFile(file))
+		}
+		for _, file := range group.Retained {
+			fmt.Fprintf(app.IO.Out, "    retain %s (%s, tracked by state)\n", file.Path, describeDuplicateFile(file))
+		}
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(app.IO.ErrOut, "[%s] error: %s\n", report.SourceID, problem)
+	}
+}
+
+func describeDuplicateFile(file engine.DuplicateFile) string {
+	parts := []string{}
+	if file.Codec != "" {
+		parts = append(parts, strings.TrimSpace(file.Codec+" "+formatUpgradeBitrate(file.BitrateKbps)))
+	}
+	parts = append(parts, engine.FormatByteSize(file.Bytes))
+	return strings.Join(parts, ", ")
+}
@@ -10,11 +10,15 @@ import (
 	"github.com/jaa/update-downloads/internal/config"
 	"github.com/jaa/update-downloads/internal/doctor"
 	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/tools"
 	"github.com/spf13/cobra"
 )
 
 func newDoctorCommand(app *AppContext) *cobra.Command {
-	return &cobra.Command{
+	var offline bool
+	var fix bool
+
+	cmd := &cobra.Command{
 		Use:   "doctor",
 		Short: "Check dependencies, auth, and filesystem readiness",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -29,7 +33,20 @@ func newDoctorCommand(app *AppContext) *cobra.Command {
 				}
 			}
 
-			report := workflows.DoctorUseCase{Checker: doctor.NewChecker()}.Run(context.Background(), cfg)
+			checker := doctor.NewChecker()
+			if offline {
+				checker.ProbeSpotifyAPI = nil
+				checker.ProbeSoundCloudOAuth = nil
+				checker.FetchCompatMatrix = nil
+			}
+			fixes := []doctor.FixResult{}
+			if fix {
+				fixes = checker.Fix(context.Background(), cfg, doctor.FixOptions{DryRun: app.Opts.DryRun})
+				// Pick up tools the fix just linked into <state_dir>/tools/bin.
+				tools.ActivateManagedBinDir(cfg.Defaults.StateDir)
+			}
+			report := workflows.DoctorUseCase{Checker: checker}.Run(context.Background(), cfg)
+			report.Fixes = fixes
 
 			if app.Opts.JSON {
 				encoder := json.NewEncoder(app.IO.Out)
@@ -37,6 +54,9 @@ func newDoctorCommand(app *AppContext) *cobra.Command {
 					return withExitCode(exitcode.RuntimeFailure, err)
 				}
 			} else {
+				for _, result := range fixes {
+					fmt.Fprintf(app.IO.Out, "[fix:%s] %s: %s\n", result.Status, result.Name, result.Message)
+				}
 				checks := append([]doctor.Check{}, report.Checks...)
 				sort.SliceStable(checks, func(i, j int) bool {
 					return checks[i].Name < checks[j].Name
@@ -52,4 +72,8 @@ func newDoctorCommand(app *AppContext) *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&offline, "offline", false, "Skip network probes (Spotify API throttling check, compat.url refresh)")
+	cmd.Flags().BoolVar(&fix, "fix", false, "Remediate what doctor safely can before checking (with --dry-run, only list the fixes)")
+	return cmd
 }
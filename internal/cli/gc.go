@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+func newGCCommand(app *AppContext) *cobra.Command {
+	apply := false
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Remove state_dir run reports, logs, caches, and trash past their retention",
+		Long: "Apply the config's retention rules to <state_dir>: run reports, the failure log and history " +
+			"journal, HTTP and metadata caches, and prune/upgrade-scan trash. Sync enforces the same rules at " +
+			"the end of every run. Use --apply to remove what the preview lists.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if len(cfg.Sources) > 0 {
+				if err := config.Validate(cfg); err != nil {
+					return withExitCode(exitcode.InvalidConfig, err)
+				}
+			}
+
+			plan, err := engine.PlanStateGC(cfg, time.Now())
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			removed := 0
+			previewApply := apply && app.Opts.DryRun
+			if apply && !app.Opts.DryRun && len(plan.Items) > 0 {
+				removed, err = engine.ApplyStateGC(plan)
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("apply retention (%d item(s) removed): %w", removed, err))
+				}
+			}
+
+			if app.Opts.JSON {
+				payload := map[string]any{"state_dir": plan.StateDir, "classes": plan.Classes, "items": plan.Items, "removed": removed}
+				if err := json.NewEncoder(app.IO.Out).Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+
+			configured := false
+			for _, class := range plan.Classes {
+				rule := "keep forever"
+				if class.Rule.MaxAgeDays > 0 || class.Rule.MaxSizeMB > 0 {
+					configured = true
+					rule = fmt.Sprintf("max_age_days=%d max_size_mb=%d", class.Rule.MaxAgeDays, class.Rule.MaxSizeMB)
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"[%s] %d artifact(s) %s; %s; expired %d (%s)\n",
+					class.Class,
+					class.Artifacts,
+					engine.FormatByteSize(class.Bytes),
+					rule,
+					class.Removed,
+					engine.FormatByteSize(class.RemovedBytes),
+				)
+			}
+			for _, item := range plan.Items {
+				what := item.Path
+				if item.Records > 0 {
+					what = fmt.Sprintf("%d record(s) of %s", item.Records, item.Path)
+				}
+				fmt.Fprintf(app.IO.Out, "[%s] remove %s (%s, %s)\n", item.Class, what, item.Reason, engine.FormatByteSize(item.Bytes))
+			}
+			mode := "plan"
+			if previewApply {
+				mode = "apply-preview"
+			} else if apply {
+				mode = "apply"
+			}
+			fmt.Fprintf(
+				app.IO.Out,
+				"gc: summary planned=%d removed=%d reclaimable=%s mode=%s\n",
+				len(plan.Items),
+				removed,
+				engine.FormatByteSize(plan.RemovedBytes()),
+				mode,
+			)
+			if !configured {
+				fmt.Fprintln(app.IO.Out, "gc: no retention rules configured; add a retention section to the config")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&apply, "apply", false, "Remove the expired artifacts (honors --dry-run)")
+	return cmd
+}
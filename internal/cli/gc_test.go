@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGCCommandPreviewsThenApplies(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	old := filepath.Join(stateDir, "runs", "20250101T000000Z")
+	for _, dir := range []string{old, filepath.Join(stateDir, "runs", "20260101T000000Z")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	stamp := time.Now().Add(-400 * 24 * time.Hour)
+	if err := os.Chtimes(old, stamp, stamp); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"retention:\n  reports:\n    max_age_days: 30\n" +
+		"sources: []\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newGCCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("gc: %v", err)
+	}
+	for _, want := range []string{
+		"[reports] 2 artifact(s) 0B; max_age_days=30 max_size_mb=0; expired 1 (0B)",
+		"[reports] remove " + old + " (max_age, 0B)",
+		"gc: summary planned=1 removed=0 reclaimable=0B mode=plan",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("expected the preview to keep %s: %v", old, err)
+	}
+
+	out.Reset()
+	cmd = newGCCommand(app)
+	cmd.SetArgs([]string{"--apply"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("gc --apply: %v", err)
+	}
+	if !strings.Contains(out.String(), "gc: summary planned=1 removed=1 reclaimable=0B mode=apply") {
+		t.Fatalf("unexpected apply output:\n%s", out.String())
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed, got %v", old, err)
+	}
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// headlessEnv enables --headless for service units and package-manager
+// wrappers (launchd, systemd, Homebrew services, Nix modules) that would
+// rather set the environment than edit the command line.
+const headlessEnv = "UDL_HEADLESS"
+
+// applyHeadless resolves --headless, or UDL_HEADLESS when the flag is not
+// given, into the global options it stands for: --json (events on stdout,
+// subprocess output on stderr), --no-input, and no color. Commands read
+// app.Opts.Headless for the rest: no live progress lines, no browser handoff,
+// and a non-zero exit when work is deferred for lack of interaction.
+func applyHeadless(app *AppContext, cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("headless") {
+		if raw := strings.TrimSpace(os.Getenv(headlessEnv)); raw != "" {
+			enabled, err := strconv.ParseBool(raw)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid %s=%q (expected true or false)", headlessEnv, raw))
+			}
+			app.Opts.Headless = enabled
+		}
+	}
+	if !app.Opts.Headless {
+		return nil
+	}
+	app.Opts.JSON = true
+	app.Opts.NoInput = true
+	app.Opts.NoColor = true
+	return nil
+}
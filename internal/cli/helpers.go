@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/jaa/update-downloads/internal/auth"
 	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/tools"
 )
 
 func loadConfig(app *AppContext) (config.Config, error) {
@@ -17,10 +21,15 @@ func loadConfig(app *AppContext) (config.Config, error) {
 	cfg, err := config.Load(config.LoadOptions{
 		ExplicitPath: strings.TrimSpace(app.Opts.ConfigPath),
 		WorkingDir:   wd,
+		Profile:      strings.TrimSpace(app.Opts.Profile),
 	})
 	if err != nil {
 		return config.Config{}, err
 	}
+	resolveSourceShortLinks(context.Background(), &cfg)
+	tools.ActivateManagedBinDir(cfg.Defaults.StateDir)
+	auth.UseCredentialStore(cfg.Defaults.StateDir)
+	engine.ConfigurePostProcessing(cfg.PostProcessing)
 	return cfg, nil
 }
 
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+const defaultHistoryLimit = 20
+
+func newHistoryCommand(app *AppContext) *cobra.Command {
+	var sourceID string
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show past sync runs from the local journal",
+		Long: "List past syncs recorded in <state_dir>/history.jsonl, newest first, with per-source outcomes and durations. " +
+			"With --source, only runs that touched that source are shown, together with the track ids it downloaded. " +
+			"Dry runs are not journaled.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit < 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --limit %d (must be >= 0)", limit))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			entries, err := (workflows.HistoryUseCase{}).Run(cfg, sourceID, limit)
+			if err != nil {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			if app.Opts.JSON {
+				encoder := json.NewEncoder(app.IO.Out)
+				if err := encoder.Encode(map[string]any{"runs": entries}); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			if len(entries) == 0 {
+				fmt.Fprintln(app.IO.Out, "No sync runs recorded.")
+				return nil
+			}
+			showTracks := strings.TrimSpace(sourceID) != ""
+			for _, entry := range entries {
+				fmt.Fprintln(app.IO.Out, formatHistoryRunLine(entry))
+				for _, source := range entry.Sources {
+					fmt.Fprintln(app.IO.Out, formatHistorySourceLine(source))
+					if showTracks {
+						for _, trackID := range source.DownloadedTrackIDs {
+							fmt.Fprintf(app.IO.Out, "    + %s\n", trackID)
+						}
+					}
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&sourceID, "source", "", "Show only runs that touched this source id")
+	cmd.Flags().IntVar(&limit, "limit", defaultHistoryLimit, "Maximum number of runs to show (0 = all)")
+	return cmd
+}
+
+func formatHistoryRunLine(entry engine.HistoryEntry) string {
+	runID := entry.RunID
+	if runID == "" {
+		runID = "-"
+	}
+	line := fmt.Sprintf(
+		"%s started=%s duration=%s attempted=%d succeeded=%d failed=%d skipped=%d",
+		runID,
+		entry.StartedAt.Local().Format(time.RFC3339),
+		formatHistoryDuration(entry.DurationMS),
+		entry.Result.Attempted,
+		entry.Result.Succeeded,
+		entry.Result.Failed,
+		entry.Result.Skipped,
+	)
+	if entry.Result.Interrupted {
+		line += " (interrupted)"
+	}
+	if entry.Error != "" {
+		line += " error=" + strings.Join(strings.Fields(entry.Error), " ")
+	}
+	return line
+}
+
+func formatHistorySourceLine(source engine.HistorySource) string {
+	line := fmt.Sprintf(
+		"  [%s] %s duration=%s downloaded=%d",
+		source.SourceID,
+		source.Status,
+		formatHistoryDuration(source.DurationMS),
+		len(source.DownloadedTrackIDs),
+	)
+	if source.Status == "failed" {
+		class := source.Class
+		if class == "" {
+			class = "runtime"
+		}
+		line += fmt.Sprintf(" class=%s: %s", class, strings.Join(strings.Fields(source.Message), " "))
+	}
+	return line
+}
+
+func formatHistoryDuration(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
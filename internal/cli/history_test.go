@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryCommandPrintsRunsAndSourceTracks(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + filepath.Join(tmp, "music") + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	journal := `{"run_id":"20260402T103000Z","started_at":"2026-04-02T10:30:00Z","finished_at":"2026-04-02T10:31:05Z","duration_ms":65000,"result":{"total":2,"attempted":2,"succeeded":1,"failed":1},"sources":[{"source_id":"sc","status":"finished","duration_ms":42000,"downloaded_track_ids":["111","222"]},{"source_id":"yt","status":"failed","class":"auth","message":"cookies expired","duration_ms":3000}]}
+not json
+{"run_id":"20260403T103000Z","started_at":"2026-04-03T10:30:00Z","finished_at":"2026-04-03T10:30:02Z","duration_ms":2000,"result":{"total":1,"attempted":1,"succeeded":1},"sources":[{"source_id":"yt","status":"finished","duration_ms":2000}]}
+`
+	if err := os.WriteFile(filepath.Join(stateDir, "history.jsonl"), []byte(journal), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newHistoryCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	got := out.String()
+	if strings.Index(got, "20260403T103000Z") > strings.Index(got, "20260402T103000Z") {
+		t.Fatalf("expected newest run first, got %q", got)
+	}
+	for _, want := range []string{
+		"duration=1m5s attempted=2 succeeded=1 failed=1 skipped=0",
+		"  [sc] finished duration=42s downloaded=2",
+		"  [yt] failed duration=3s downloaded=0 class=auth: cookies expired",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output, got %q", want, got)
+		}
+	}
+	if strings.Contains(got, "+ 111") {
+		t.Fatalf("expected track ids only with --source, got %q", got)
+	}
+
+	out.Reset()
+	cmd = newHistoryCommand(app)
+	cmd.SetArgs([]string{"--source", "sc"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("history --source: %v", err)
+	}
+	if got := out.String(); strings.Contains(got, "20260403T103000Z") || !strings.Contains(got, "    + 111\n    + 222\n") {
+		t.Fatalf("unexpected --source output: %q", got)
+	}
+
+	out.Reset()
+	app.Opts.JSON = true
+	cmd = newHistoryCommand(app)
+	cmd.SetArgs([]string{"--limit", "1"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("history --json: %v", err)
+	}
+	decoded := map[string][]map[string]any{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json: %v (%s)", err, out.String())
+	}
+	if len(decoded["runs"]) != 1 || decoded["runs"][0]["run_id"] != "20260403T103000Z" {
+		t.Fatalf("unexpected json payload: %s", out.String())
+	}
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/signal"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/output"
+	"github.com/spf13/cobra"
+)
+
+func newImportWatchCommand(app *AppContext) *cobra.Command {
+	opts := engine.ImportWatchOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import-watch",
+		Short: "Import media files from the Downloads folder into the SoundCloud source they belong to",
+		Long: strings.TrimSpace(`
+Watch the browser Downloads folder and import each new media file into the
+target_dir of the SoundCloud source whose missing track it is: free-DL gates
+completed by hand, tracks bought on Bandcamp or Beatport, and the like.
+
+A file is matched when its name contains the title of a track the source's
+metadata cache knows (from its last sync) and its state file does not; the
+artist breaks ties. Matched files are moved, tagged like free-DL downloads,
+and recorded in the state file and archive. Files matching no track, or
+several, stay where they are.
+
+Files already in the folder when the watch starts are left alone; use --once
+to import them and exit instead. Do not run a sync of the same sources while
+the watch is importing.
+`),
+		Example: strings.TrimSpace(`
+  udl import-watch
+  udl import-watch --source soundcloud-likes --dir ~/Downloads/music
+  udl import-watch --once --dry-run
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if strings.TrimSpace(opts.Dir) != "" {
+				if opts.Dir, err = config.ExpandPath(opts.Dir); err != nil {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+			}
+			opts.DryRun = app.Opts.DryRun
+
+			var emitter output.EventEmitter
+			if app.Opts.JSON {
+				emitter = output.NewJSONEmitter(app.IO.Out)
+			} else {
+				emitter = output.NewHumanEmitter(app.IO.Out, app.IO.ErrOut, app.Opts.Quiet, app.Opts.Verbose)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), interruptSignals()...)
+			defer stop()
+
+			watcher := engine.ImportWatcher{Config: cfg, Emitter: emitter}
+			summary, runErr := watcher.Run(ctx, opts)
+			if runErr != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(runErr, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, runErr)
+				}
+				return withExitCode(exitcode.RuntimeFailure, runErr)
+			}
+			if app.Opts.JSON {
+				if err := json.NewEncoder(app.IO.Out).Encode(map[string]any{"summary": summary}); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				fmt.Fprintf(
+					app.IO.Out,
+					"import-watch: summary imported=%d planned=%d unmatched=%d ambiguous=%d failed=%d locked=%d\n",
+					summary.Imported,
+					summary.Planned,
+					summary.Unmatched,
+					summary.Ambiguous,
+					summary.Failed,
+					summary.Locked,
+				)
+			}
+			if summary.Failed > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("%d file(s) failed to import", summary.Failed))
+			}
+			if summary.Locked > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("%d file(s) left in place while their source syncs", summary.Locked))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.Dir, "dir", "", "Folder to watch (default ~/Downloads, or UDL_FREEDL_BROWSER_DOWNLOAD_DIR)")
+	cmd.Flags().StringArrayVar(&opts.SourceIDs, "source", nil, "Import only into selected source id (repeatable)")
+	cmd.Flags().BoolVar(&opts.Once, "once", false, "Import the files already in the folder and exit")
+	return cmd
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/signal"
+	"strings"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/output"
+	"github.com/spf13/cobra"
+)
+
+func newPlanCommand(app *AppContext) *cobra.Command {
+	var (
+		sourceIDs   []string
+		tags        []string
+		excludeTags []string
+		scanGaps    bool
+		onlyGaps    bool
+		limit       int
+		noHTTPCache bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Show which tracks a sync would download, skip, or prune, without running adapters",
+		Long: strings.TrimSpace(`
+Run preflight for every selected source and print, per track, what a sync
+would do: "+" lines are downloads, "=" lines are skips with their reason, and
+"-" lines are tracks sync.prune would move to trash. Skips preflight gives no
+specific reason for (already synced, or past the first known track in break
+mode) are counted, and listed with --verbose.
+
+Remote listings are fetched as a sync would, but no adapter runs, nothing is
+prompted for, and no state, archive, or library file changes. Adapters with
+no per-track preflight (spotdl, ytdlp, tidal-dl) are listed as no_preflight.
+`),
+		Example: strings.TrimSpace(`
+  udl plan
+  udl plan --source soundcloud-likes --scan-gaps
+  udl plan --json | jq '.sources[] | {source_id, download: (.download | length)}'
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if limit < 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --limit %d (must be >= 0; 0 means unlimited)", limit))
+			}
+			if onlyGaps && scanGaps {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--only-gaps cannot be combined with --scan-gaps"))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			selectedIDs, err := resolveSourceSelection(cfg, sourceIDs, tags, excludeTags)
+			if err != nil {
+				return err
+			}
+
+			// Sync's own lines would bury the report; only its warnings
+			// and errors are kept, on stderr.
+			emitter := output.NewHumanEmitter(io.Discard, app.IO.ErrOut, app.Opts.Quiet, false)
+			useCase := workflows.PlanUseCase{
+				Registry: syncAdapterRegistry(),
+				Runner:   engine.NewSubprocessRunner(app.IO.In, io.Discard, app.IO.ErrOut),
+				Emitter:  emitter,
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), interruptSignals()...)
+			defer stop()
+
+			report, runErr := useCase.Run(ctx, cfg, workflows.PlanRequest{
+				SourceIDs:   selectedIDs,
+				ScanGaps:    scanGaps,
+				OnlyGaps:    onlyGaps,
+				Limit:       limit,
+				NoHTTPCache: noHTTPCache,
+			})
+			if runErr != nil {
+				var selectionErr *engine.SelectionError
+				switch {
+				case errors.As(runErr, &selectionErr):
+					return withExitCode(exitcode.InvalidUsage, runErr)
+				case errors.Is(runErr, engine.ErrInterrupted):
+					return withExitCode(exitcode.Interrupted, runErr)
+				default:
+					return withExitCode(exitcode.RuntimeFailure, runErr)
+				}
+			}
+
+			if app.Opts.JSON {
+				if err := json.NewEncoder(app.IO.Out).Encode(report); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				for _, line := range formatPlanReport(report, app.Opts.Verbose) {
+					fmt.Fprintln(app.IO.Out, line)
+				}
+			}
+			if report.Summary.Failed > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("plan failed for %d source(s)", report.Summary.Failed))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sourceIDs, "source", nil, "Plan only selected source id (repeatable)")
+	addSourceTagFlags(cmd, &tags, &excludeTags)
+	cmd.Flags().BoolVar(&scanGaps, "scan-gaps", false, "Plan as sync --scan-gaps would, past the first known track")
+	cmd.Flags().BoolVar(&onlyGaps, "only-gaps", false, "Plan as sync --only-gaps would, gaps only")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Plan as sync --limit would, at most this many downloads per source (0 = unlimited)")
+	cmd.Flags().BoolVar(&noHTTPCache, "no-http-cache", false, "Fetch remote playlist pages and API responses without the on-disk ETag cache")
+	return cmd
+}
+
+// formatPlanReport renders a plan as one header per source followed by its
+// track lines, diff style, and a closing summary.
+func formatPlanReport(report engine.PlanReport, verbose bool) []string {
+	lines := []string{}
+	for _, source := range report.Sources {
+		header := fmt.Sprintf("%s (%s/%s): %s", source.SourceID, source.Type, source.Adapter, source.Status)
+		switch source.Status {
+		case engine.PlanStatusPlanned, engine.PlanStatusUpToDate:
+			header += fmt.Sprintf(" remote=%d download=%d skip=%d prune=%d", source.RemoteTotal, len(source.Download), len(source.Skip), len(source.Prune))
+			if source.Mode != "" {
+				header += " mode=" + source.Mode
+			}
+		default:
+			if source.Message != "" {
+				header += ": " + source.Message
+			}
+		}
+		lines = append(lines, header)
+
+		for _, track := range source.Download {
+			lines = append(lines, "  + "+formatPlanTrack(track))
+		}
+		hidden := 0
+		for _, track := range source.Skip {
+			if track.Reason == engine.PlanSkipNotPlanned && !verbose {
+				hidden++
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("  = %s (%s)", formatPlanTrack(track), track.Reason))
+		}
+		for _, track := range source.Prune {
+			line := fmt.Sprintf("  - %s (%s)", formatPlanTrack(track), track.Reason)
+			if track.Path != "" {
+				line += " " + track.Path
+			}
+			lines = append(lines, line)
+		}
+		if hidden > 0 {
+			lines = append(lines, fmt.Sprintf("  = %d %s track(s); --verbose lists them", hidden, engine.PlanSkipNotPlanned))
+		}
+	}
+	lines = append(lines, fmt.Sprintf(
+		"plan: sources=%d failed=%d download=%d skip=%d prune=%d",
+		report.Summary.Sources,
+		report.Summary.Failed,
+		report.Summary.Download,
+		report.Summary.Skip,
+		report.Summary.Prune,
+	))
+	return lines
+}
+
+func formatPlanTrack(track engine.PlanTrackLine) string {
+	if track.Label == "" || track.Label == track.ID {
+		return track.ID
+	}
+	return fmt.Sprintf("%s %s", track.ID, track.Label)
+}
@@ -283,7 +283,7 @@ func defaultPlanSelectorKeys() planSelectorKeys {
 
 func buildPlanSourceDetails(source config.Source, defaults config.Defaults, planLimit int, dryRun bool) planSourceDetails {
 	targetDir := strings.TrimSpace(source.TargetDir)
-	if expanded, err := config.ExpandPath(targetDir); err == nil && strings.TrimSpace(expanded) != "" {
+	if expanded, err := config.ExpandTargetDir(targetDir); err == nil && strings.TrimSpace(expanded) != "" {
 		targetDir = expanded
 	}
 
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+func TestFormatPlanReportPrintsDiffLinesAndHidesUnspecificSkips(t *testing.T) {
+	report := engine.PlanReport{
+		Sources: []engine.PlanReportSource{
+			{
+				SourceID:    "deezer-mix",
+				Type:        "deezer",
+				Adapter:     "deemix",
+				Status:      engine.PlanStatusPlanned,
+				RemoteTotal: 3,
+				Mode:        "break",
+				Download:    []engine.PlanTrackLine{{ID: "1", Label: "Daft Punk - Digital Love"}},
+				Skip: []engine.PlanTrackLine{
+					{ID: "2", Label: "Daft Punk - Veridis Quo", Reason: "unavailable-on-deezer"},
+					{ID: "3", Label: "Daft Punk - Aerodynamic", Reason: engine.PlanSkipNotPlanned},
+				},
+				Prune: []engine.PlanTrackLine{{ID: "4", Label: "Daft Punk - One More Time", Reason: "removed-from-remote", Path: "/music/One More Time.mp3"}},
+			},
+			{SourceID: "videos", Type: "youtube", Adapter: "ytdlp", Status: engine.PlanStatusFailed, Message: "[videos] preflight failed"},
+		},
+		Summary: engine.PlanReportSummary{Sources: 2, Failed: 1, Download: 1, Skip: 2, Prune: 1},
+	}
+
+	got := strings.Join(formatPlanReport(report, false), "\n")
+	want := strings.Join([]string{
+		"deezer-mix (deezer/deemix): planned remote=3 download=1 skip=2 prune=1 mode=break",
+		"  + 1 Daft Punk - Digital Love",
+		"  = 2 Daft Punk - Veridis Quo (unavailable-on-deezer)",
+		"  - 4 Daft Punk - One More Time (removed-from-remote) /music/One More Time.mp3",
+		"  = 1 not-planned track(s); --verbose lists them",
+		"videos (youtube/ytdlp): failed: [videos] preflight failed",
+		"plan: sources=2 failed=1 download=1 skip=2 prune=1",
+	}, "\n")
+	if got != want {
+		t.Fatalf("unexpected plan output:\n%s\nwant:\n%s", got, want)
+	}
+
+	verbose := strings.Join(formatPlanReport(report, true), "\n")
+	if !strings.Contains(verbose, "  = 3 Daft Punk - Aerodynamic (not-planned)") || strings.Contains(verbose, "--verbose lists them") {
+		t.Fatalf("expected --verbose to list every skip, got:\n%s", verbose)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
 	"net/url"
 	"os"
@@ -17,6 +18,7 @@ import (
 	"unicode"
 
 	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
 	"github.com/jaa/update-downloads/internal/exitcode"
 	"github.com/jaa/update-downloads/internal/fileops"
 	"github.com/spf13/cobra"
@@ -40,6 +42,7 @@ var (
 	probeAudioFn     = probePromoteAudio
 	probeTagsFn      = probePromoteTags
 	runPromoteFFmpeg = runPromoteFFmpegCommand
+	probeSpectralFn  = engine.ProbeSpectralCeiling
 )
 
 type promoteFreeDLOptions struct {
@@ -58,6 +61,32 @@ type promoteFreeDLOptions struct {
 	MinOpusKbps   int
 	ReplaceLimit  int
 	AmbiguityGap  int
+	// AllowLossyTranscode permits re-encoding a high-quality lossy source
+	// into a different lossy codec, which compounds generation loss.
+	AllowLossyTranscode bool
+	// Fingerprint adds Chromaprint (fpcalc) audio fingerprints as a match
+	// signal, for pairs whose titles differ but whose audio is identical.
+	Fingerprint bool
+	// VerifyQuality runs the spectral check on lossless sources; sources
+	// it, or a sync-time check, finds upsampled from lossy are skipped
+	// unless AllowSuspectQuality is set.
+	VerifyQuality       bool
+	AllowSuspectQuality bool
+	// Output is text (per-file lines) or json (one promoteReport).
+	Output string
+	// Interactive reviews each ambiguous match and planned replacement
+	// before anything is written.
+	Interactive bool
+	// Rollback is the run id of an applying run whose in-place
+	// replacements are restored from its undo journal.
+	Rollback string
+	// TagPolicy, TagFields (<tag>=<policy>) and Artwork choose which
+	// input's tags and cover the promoted file keeps; tagPolicy is their
+	// parsed form.
+	TagPolicy string
+	TagFields []string
+	Artwork   string
+	tagPolicy promoteTagPolicy
 }
 
 type promoteMediaFile struct {
@@ -73,6 +102,7 @@ type promoteMediaFile struct {
 	ArtistKey    string
 	SourceURLKey string
 	Tokens       []string
+	Fingerprint  promoteFingerprint
 }
 
 type promoteAudioProbe struct {
@@ -80,6 +110,11 @@ type promoteAudioProbe struct {
 	Bitrate          int
 	FormatBitrate    int
 	EffectiveBitrate int
+	// SuspectQuality marks a lossless source whose spectrum ends below
+	// engine.SpectralSuspectCeilingHz; SpectralCeilingHz is zero when the
+	// flag only comes from a comment-tag marker.
+	SuspectQuality    bool
+	SpectralCeilingHz float64
 }
 
 type promotePairCandidate struct {
@@ -123,9 +158,22 @@ const (
 	promoteActionEncodeWAV promoteActionMode = "encode-wav"
 )
 
+// promoteQualityImpact estimates what a promote action costs in audio
+// quality relative to the free-DL source.
+type promoteQualityImpact string
+
+const (
+	promoteImpactNone           promoteQualityImpact = "none"
+	promoteImpactLosslessDecode promoteQualityImpact = "lossless-decode"
+	promoteImpactLossyEncode    promoteQualityImpact = "lossy-encode"
+	promoteImpactGenerationLoss promoteQualityImpact = "generation-loss"
+)
+
 type promoteDecision struct {
 	Mode   promoteActionMode
 	Reason string
+	Impact promoteQualityImpact
+	Detail string
 }
 
 type promoteTargetPolicy struct {
@@ -145,6 +193,9 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 		TargetFormat:  promoteTargetAuto,
 		ProbeTimeout:  2 * time.Second,
 		AmbiguityGap:  8,
+		Output:        promoteOutputText,
+		TagPolicy:     promoteTagPreferLibrary,
+		Artwork:       promoteArtworkBest,
 	}
 
 	cmd := &cobra.Command{
@@ -153,6 +204,9 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 		Long: "Preview or apply a separate post-processing flow that matches tracks from a free-download folder " +
 			"to a target library folder and replaces target audio with higher-quality sources.",
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.TrimSpace(opts.Rollback) != "" {
+				return runPromoteRollback(app, opts)
+			}
 			if strings.TrimSpace(opts.FreeDLDir) == "" {
 				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--free-dl-dir is required"))
 			}
@@ -171,11 +225,31 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 			if opts.ProbeTimeout <= 0 {
 				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--probe-timeout must be > 0"))
 			}
+			opts.Output = strings.ToLower(strings.TrimSpace(opts.Output))
+			if opts.Output != promoteOutputText && opts.Output != promoteOutputJSON {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--output must be text or json"))
+			}
+			jsonOutput := opts.Output == promoteOutputJSON || app.Opts.JSON
+			if opts.Interactive && jsonOutput {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--interactive cannot be used with --output json"))
+			}
+			if opts.Interactive && app.Opts.NoInput {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--interactive cannot be used with --no-input"))
+			}
+			out, errOut := app.IO.Out, app.IO.ErrOut
+			if jsonOutput {
+				// The report replaces the progress and per-file lines.
+				out, errOut = io.Discard, io.Discard
+			}
 			targetFormat, err := normalizePromoteTargetFormat(opts.TargetFormat)
 			if err != nil {
 				return withExitCode(exitcode.InvalidUsage, err)
 			}
 			opts.TargetFormat = targetFormat
+			opts.tagPolicy, err = parsePromoteTagPolicy(opts.TagPolicy, opts.TagFields, opts.Artwork)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, err)
+			}
 
 			freeDLDir, err := config.ExpandPath(opts.FreeDLDir)
 			if err != nil {
@@ -196,41 +270,73 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 			if err := ensurePromoteDependencies(); err != nil {
 				return withExitCode(exitcode.MissingDependency, err)
 			}
+			if opts.Fingerprint {
+				if _, err := lookPathFn("fpcalc"); err != nil {
+					return withExitCode(exitcode.MissingDependency, fmt.Errorf("required dependency %q not found in PATH (install chromaprint for --fingerprint)", "fpcalc"))
+				}
+			}
 
 			ctx := cmd.Context()
 			if ctx == nil {
 				ctx = context.Background()
 			}
 
-			fmt.Fprintf(app.IO.Out, "promote-freedl: indexing free-dl titles in %s\n", freeDLDir)
+			fmt.Fprintf(out, "promote-freedl: indexing free-dl titles in %s\n", freeDLDir)
 			freeDLFiles, err := collectPromoteMediaFiles(ctx, freeDLDir, opts.ProbeTimeout)
 			if err != nil {
 				return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("scan free-dl directory: %w", err))
 			}
-			fmt.Fprintf(app.IO.Out, "promote-freedl: indexed free-dl files=%d\n", len(freeDLFiles))
-			fmt.Fprintf(app.IO.Out, "promote-freedl: indexing library titles in %s\n", libraryDir)
+			fmt.Fprintf(out, "promote-freedl: indexed free-dl files=%d\n", len(freeDLFiles))
+			fmt.Fprintf(out, "promote-freedl: indexing library titles in %s\n", libraryDir)
 			libraryFiles, err := collectPromoteMediaFiles(ctx, libraryDir, opts.ProbeTimeout)
 			if err != nil {
 				return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("scan library directory: %w", err))
 			}
-			fmt.Fprintf(app.IO.Out, "promote-freedl: indexed library files=%d\n", len(libraryFiles))
-			if len(freeDLFiles) == 0 {
-				fmt.Fprintln(app.IO.Out, "promote-freedl: no media files found in --free-dl-dir")
+			fmt.Fprintf(out, "promote-freedl: indexed library files=%d\n", len(libraryFiles))
+			if opts.Fingerprint && len(freeDLFiles) > 0 && len(libraryFiles) > 0 {
+				fmt.Fprintln(out, "promote-freedl: fingerprinting audio with fpcalc")
+				freeDLFingerprinted := fingerprintPromoteFiles(ctx, freeDLFiles)
+				libraryFingerprinted := fingerprintPromoteFiles(ctx, libraryFiles)
+				fmt.Fprintf(
+					out,
+					"promote-freedl: fingerprinted free-dl files=%d/%d library files=%d/%d\n",
+					freeDLFingerprinted,
+					len(freeDLFiles),
+					libraryFingerprinted,
+					len(libraryFiles),
+				)
+			}
+			previewMode := app.Opts.DryRun || !opts.Apply
+			startedAt := time.Now()
+			var journal *promoteJournal
+			report := newPromoteReport(opts, freeDLDir, libraryDir, writeDir, previewMode)
+			report.FreeDLFiles = len(freeDLFiles)
+			report.LibraryFiles = len(libraryFiles)
+			writeReport := func() error {
+				if !jsonOutput {
+					return nil
+				}
+				if err := report.write(app.IO.Out); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
 				return nil
 			}
+			if len(freeDLFiles) == 0 {
+				fmt.Fprintln(out, "promote-freedl: no media files found in --free-dl-dir")
+				return writeReport()
+			}
 			if len(libraryFiles) == 0 {
-				fmt.Fprintln(app.IO.Out, "promote-freedl: no media files found in --library-dir")
-				return nil
+				fmt.Fprintln(out, "promote-freedl: no media files found in --library-dir")
+				return writeReport()
 			}
 
 			matchPlan := buildPromoteAssignments(libraryFiles, freeDLFiles, opts.MinMatchScore, opts.AmbiguityGap)
 			assignments := matchPlan.Assignments
-			previewMode := app.Opts.DryRun || !opts.Apply
 			if !opts.Apply && !app.Opts.DryRun {
-				fmt.Fprintln(app.IO.Out, "promote-freedl: preview mode (set --apply to write changes)")
+				fmt.Fprintln(out, "promote-freedl: preview mode (set --apply to write changes)")
 			}
 			fmt.Fprintf(
-				app.IO.Out,
+				out,
 				"promote-freedl: free_dl=%d library=%d matched=%d ambiguous=%d min_match_score=%d ambiguity_gap=%d mode=%s\n",
 				len(freeDLFiles),
 				len(libraryFiles),
@@ -240,15 +346,32 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 				opts.AmbiguityGap,
 				map[bool]string{true: "preview", false: "apply"}[previewMode],
 			)
+			rejected := []promoteAssignment{}
+			if opts.Interactive {
+				review, reviewErr := reviewPromotePlan(ctx, app.IO.In, out, opts, matchPlan, freeDLFiles)
+				if reviewErr != nil {
+					return withExitCode(exitcode.RuntimeFailure, reviewErr)
+				}
+				matchPlan = review.Plan
+				assignments = matchPlan.Assignments
+				rejected = review.Rejected
+			}
 
 			planned := 0
 			replaced := 0
-			skipped := len(matchPlan.Ambiguous)
+			skipped := len(matchPlan.Ambiguous) + len(rejected)
 			failed := 0
 			processed := 0
 			for _, ambiguous := range matchPlan.Ambiguous {
+				report.Ambiguous = append(report.Ambiguous, promoteReportAmbiguous{
+					Library:          ambiguous.Library.Rel,
+					Best:             ambiguous.Best.Rel,
+					BestScore:        ambiguous.BestScore,
+					Alternative:      ambiguous.Alternative.Rel,
+					AlternativeScore: ambiguous.AltScore,
+				})
 				fmt.Fprintf(
-					app.IO.Out,
+					out,
 					"[skip] %s (ambiguous-match top=%d second=%d best=%s alt=%s)\n",
 					ambiguous.Library.Rel,
 					ambiguous.BestScore,
@@ -257,8 +380,13 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 					ambiguous.Alternative.Rel,
 				)
 			}
+			for _, assignment := range rejected {
+				report.add(assignment, "skipped", promoteDecision{}).Reason = promoteReviewRejectedReason
+				fmt.Fprintf(out, "[skip] %s <= %s (%s)\n", assignment.Library.Rel, assignment.FreeDL.Rel, promoteReviewRejectedReason)
+			}
 			for _, assignment := range assignments {
 				if opts.ReplaceLimit > 0 && processed >= opts.ReplaceLimit {
+					report.Summary.Unprocessed = len(assignments) - processed
 					break
 				}
 				processed++
@@ -266,9 +394,10 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 				sourceProbe, sourceProbeErr := probePromoteAudioWithTimeout(ctx, assignment.FreeDL.Path, opts.ProbeTimeout)
 				if sourceProbeErr != nil {
 					skipped++
+					report.add(assignment, "skipped", promoteDecision{}).Reason = fmt.Sprintf("probe-source-failed: %v", sourceProbeErr)
 					if app.Opts.Verbose {
 						fmt.Fprintf(
-							app.IO.ErrOut,
+							errOut,
 							"[skip] %s <= %s (probe-source-failed: %v)\n",
 							assignment.Library.Rel,
 							assignment.FreeDL.Rel,
@@ -278,12 +407,17 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 					continue
 				}
 
+				sourceProbe, qualityErr := checkPromoteSourceQuality(ctx, opts, assignment.FreeDL, sourceProbe)
+				if qualityErr != nil && app.Opts.Verbose {
+					fmt.Fprintf(errOut, "[warn] %s: quality check skipped: %v\n", assignment.FreeDL.Rel, qualityErr)
+				}
 				decision := decidePromoteAction(opts, assignment, sourceProbe)
 				if decision.Mode == promoteActionSkip {
 					skipped++
+					report.add(assignment, "skipped", promoteDecision{}).Reason = decision.Reason
 					if app.Opts.Verbose {
 						fmt.Fprintf(
-							app.IO.Out,
+							out,
 							"[skip] %s <= %s (%s)\n",
 							assignment.Library.Rel,
 							assignment.FreeDL.Rel,
@@ -297,9 +431,10 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 				outputPath, outputErr := resolvePromoteOutputPath(opts, assignment, writeDir)
 				if outputErr != nil {
 					skipped++
+					report.add(assignment, "skipped", decision).Reason = fmt.Sprintf("output-policy: %v", outputErr)
 					if app.Opts.Verbose {
 						fmt.Fprintf(
-							app.IO.Out,
+							out,
 							"[skip] %s <= %s (output-policy: %v)\n",
 							assignment.Library.Rel,
 							assignment.FreeDL.Rel,
@@ -310,13 +445,16 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 				}
 
 				if previewMode {
+					report.add(assignment, "planned", decision).OutputPath = outputPath
 					fmt.Fprintf(
-						app.IO.Out,
-						"[plan] %s <= %s (score=%d mode=%s)\n",
+						out,
+						"[plan] %s <= %s (score=%d mode=%s impact=%s: %s)\n",
 						assignment.Library.Rel,
 						assignment.FreeDL.Rel,
 						assignment.Score,
 						decision.Mode,
+						decision.Impact,
+						decision.Detail,
 					)
 					continue
 				}
@@ -324,9 +462,12 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 				if writeDir != "" && !opts.Overwrite {
 					if _, statErr := os.Stat(outputPath); statErr == nil {
 						skipped++
+						entry := report.add(assignment, "skipped", decision)
+						entry.OutputPath = outputPath
+						entry.Reason = "output exists; use --overwrite"
 						if app.Opts.Verbose {
 							fmt.Fprintf(
-								app.IO.Out,
+								out,
 								"[skip] %s (output exists; use --overwrite)\n",
 								assignment.Library.Rel,
 							)
@@ -334,8 +475,11 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 						continue
 					} else if statErr != nil && !errors.Is(statErr, os.ErrNotExist) {
 						failed++
+						entry := report.add(assignment, "failed", decision)
+						entry.OutputPath = outputPath
+						entry.Error = fmt.Sprintf("stat output: %v", statErr)
 						fmt.Fprintf(
-							app.IO.ErrOut,
+							errOut,
 							"[fail] %s <= %s (stat output: %v)\n",
 							assignment.Library.Rel,
 							assignment.FreeDL.Rel,
@@ -345,10 +489,44 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 					}
 				}
 
+				var journalEntry promoteJournalEntry
+				if writeDir == "" {
+					if journal == nil {
+						opened, journalErr := openPromoteJournal(libraryDir, startedAt)
+						if journalErr != nil {
+							return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("open undo journal: %w", journalErr))
+						}
+						journal = opened
+						report.RunID = journal.RunID
+					}
+					entry, backupErr := journal.backup(assignment)
+					if backupErr != nil {
+						failed++
+						reportEntry := report.add(assignment, "failed", decision)
+						reportEntry.OutputPath = outputPath
+						reportEntry.Error = backupErr.Error()
+						fmt.Fprintf(
+							errOut,
+							"[fail] %s <= %s (%v)\n",
+							assignment.Library.Rel,
+							assignment.FreeDL.Rel,
+							backupErr,
+						)
+						continue
+					}
+					journalEntry = entry
+				}
+
 				if applyErr := applyPromoteReplacement(ctx, opts, assignment, outputPath, decision); applyErr != nil {
+					if journal != nil && journalEntry.Backup != "" {
+						journal.discard(journalEntry)
+					}
 					failed++
+					entry := report.add(assignment, "failed", decision)
+					entry.OutputPath = outputPath
+					entry.Error = applyErr.Error()
 					fmt.Fprintf(
-						app.IO.ErrOut,
+						errOut,
 						"[fail] %s <= %s (%v)\n",
 						assignment.Library.Rel,
 						assignment.FreeDL.Rel,
@@ -356,25 +534,43 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 					)
 					continue
 				}
+				if journal != nil && journalEntry.Backup != "" {
+					if recordErr := journal.record(journalEntry); recordErr != nil {
+						fmt.Fprintf(errOut, "[warn] %s (undo journal not updated: %v)\n", assignment.Library.Rel, recordErr)
+					}
+				}
 				replaced++
+				report.add(assignment, "done", decision).OutputPath = outputPath
 				fmt.Fprintf(
-					app.IO.Out,
-					"[done] %s <= %s (score=%d mode=%s)\n",
+					out,
+					"[done] %s <= %s (score=%d mode=%s impact=%s: %s)\n",
 					assignment.Library.Rel,
 					assignment.FreeDL.Rel,
 					assignment.Score,
 					decision.Mode,
+					decision.Impact,
+					decision.Detail,
 				)
 			}
 
 			fmt.Fprintf(
-				app.IO.Out,
+				out,
 				"promote-freedl: summary planned=%d replaced=%d skipped=%d failed=%d\n",
 				planned,
 				replaced,
 				skipped,
 				failed,
 			)
+			if journal != nil {
+				fmt.Fprintf(out, "promote-freedl: undo journal run_id=%s (restore with --library-dir %s --rollback %s --apply)\n", journal.RunID, libraryDir, journal.RunID)
+			}
+			report.Summary.Planned = planned
+			report.Summary.Replaced = replaced
+			report.Summary.Skipped = skipped
+			report.Summary.Failed = failed
+			if err := writeReport(); err != nil {
+				return err
+			}
 			if failed > 0 {
 				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("promote-freedl completed with %d failure(s)", failed))
 			}
@@ -397,10 +593,49 @@ func newPromoteFreeDLCommand(app *AppContext) *cobra.Command {
 	cmd.Flags().IntVar(&opts.MinOpusKbps, "min-opus-kbps", opts.MinOpusKbps, "Minimum Opus/Vorbis bitrate treated as high-quality lossy source")
 	cmd.Flags().IntVar(&opts.AmbiguityGap, "ambiguity-gap", opts.AmbiguityGap, "Minimum score gap between top two candidates; lower gaps are skipped as ambiguous (0 disables)")
 	cmd.Flags().IntVar(&opts.ReplaceLimit, "replace-limit", 0, "Limit number of matched replacements (0 = no limit)")
+	cmd.Flags().BoolVar(&opts.AllowLossyTranscode, "allow-lossy-transcode", false, "Re-encode high-quality lossy sources into a different lossy codec instead of skipping them")
+	cmd.Flags().StringVar(&opts.Output, "output", opts.Output, "Output format: text or json (a structured plan/apply report)")
+	cmd.Flags().BoolVar(&opts.Interactive, "interactive", false, "Review each ambiguous match and planned replacement (accept, reject, or pick another candidate) before anything is written")
+	cmd.Flags().StringVar(&opts.Rollback, "rollback", "", "Restore the originals replaced in place by run <run-id> (needs --library-dir; preview unless --apply)")
+	cmd.Flags().StringVar(&opts.TagPolicy, "tag-policy", opts.TagPolicy, "Tags the promoted file keeps: prefer-library, prefer-source, or merge (tags one file lacks always come from the other)")
+	cmd.Flags().StringArrayVar(&opts.TagFields, "tag-field", nil, "Per-tag policy override as <tag>=<policy>, e.g. genre=merge (repeatable)")
+	cmd.Flags().StringVar(&opts.Artwork, "artwork", opts.Artwork, "Embedded cover to keep: best (higher resolution), prefer-library, or prefer-source")
+	cmd.Flags().BoolVar(&opts.Fingerprint, "fingerprint", false, "Also match on Chromaprint audio fingerprints (needs fpcalc; slower on large libraries)")
+	cmd.Flags().BoolVar(&opts.VerifyQuality, "verify-quality", false, "Run a spectral check on lossless sources and skip those that look upsampled from lossy")
+	cmd.Flags().BoolVar(&opts.AllowSuspectQuality, "allow-suspect-quality", false, "Promote lossless sources flagged as suspect quality instead of skipping them")
 
 	return cmd
 }
 
+// runPromoteRollback is promote-freedl --rollback: restore one run's in-place
+// replacements from its undo journal.
+func runPromoteRollback(app *AppContext, opts promoteFreeDLOptions) error {
+	if strings.TrimSpace(opts.LibraryDir) == "" {
+		return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--rollback requires --library-dir"))
+	}
+	libraryDir, err := config.ExpandPath(opts.LibraryDir)
+	if err != nil {
+		return withExitCode(exitcode.InvalidUsage, fmt.Errorf("resolve --library-dir: %w", err))
+	}
+	apply := opts.Apply && !app.Opts.DryRun
+	if !apply {
+		fmt.Fprintln(app.IO.Out, "promote-freedl: rollback preview (set --apply to restore originals)")
+	}
+	result, err := rollbackPromoteRun(libraryDir, opts.Rollback, apply, app.IO.Out, app.IO.ErrOut)
+	if err != nil {
+		return withExitCode(exitcode.InvalidUsage, err)
+	}
+	verb := "restored"
+	if !apply {
+		verb = "restorable"
+	}
+	fmt.Fprintf(app.IO.Out, "promote-freedl: rollback %s=%d skipped=%d failed=%d\n", verb, result.Restored, result.Skipped, result.Failed)
+	if result.Failed > 0 {
+		return withExitCode(exitcode.PartialSuccess, fmt.Errorf("promote-freedl rollback completed with %d failure(s)", result.Failed))
+	}
+	return nil
+}
+
 func ensurePromoteDependencies() error {
 	for _, bin := range []string{"ffprobe", "ffmpeg"} {
 		if _, err := lookPathFn(bin); err != nil {
@@ -429,6 +664,9 @@ func collectPromoteMediaFiles(ctx context.Context, root string, probeTimeout tim
 			return walkErr
 		}
 		if d.IsDir() {
+			if path != trimmedRoot && d.Name() == promoteBackupDirName {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		ext := strings.ToLower(filepath.Ext(d.Name()))
@@ -661,7 +899,21 @@ func sortPromoteCandidates(candidates []promotePairCandidate, libraryFiles []pro
 	})
 }
 
+// scorePromoteMatch scores a library/free-DL pair from its metadata, then
+// lets audio fingerprints, when both files have one, lift an identical
+// recording to promoteFingerprintMatchScore or push different audio down.
 func scorePromoteMatch(libraryFile promoteMediaFile, freeDLFile promoteMediaFile) int {
+	score := scorePromoteMetadataMatch(libraryFile, freeDLFile)
+	switch comparePromoteFingerprints(libraryFile.Fingerprint, freeDLFile.Fingerprint) {
+	case promoteAudioSame:
+		score = max(score, promoteFingerprintMatchScore)
+	case promoteAudioDifferent:
+		score = max(score-promoteFingerprintMismatchPenalty, 0)
+	}
+	return score
+}
+
+func scorePromoteMetadataMatch(libraryFile promoteMediaFile, freeDLFile promoteMediaFile) int {
 	if libraryFile.SourceURLKey != "" && freeDLFile.SourceURLKey != "" && libraryFile.SourceURLKey == freeDLFile.SourceURLKey {
 		return 100
 	}
@@ -734,6 +986,19 @@ func probePromoteTagsWithTimeout(
 	return probeTagsFn(probeCtx, path)
 }
 
+func probePromoteMetadataWithTimeout(
+	ctx context.Context,
+	path string,
+	timeout time.Duration,
+) (promoteMetadata, error) {
+	if timeout <= 0 {
+		return probeMetadataFn(ctx, path)
+	}
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return probeMetadataFn(probeCtx, path)
+}
+
 func probePromoteAudioWithTimeout(
 	ctx context.Context,
 	path string,
@@ -859,6 +1124,10 @@ func probePromoteAudio(ctx context.Context, path string) (promoteAudioProbe, err
 	}, nil
 }
 
+// decidePromoteAction prefers a container remux that copies the source audio
+// stream whenever its codec already fits the target, encodes lossless sources
+// to the target codec, and skips lossy sources in another codec unless
+// AllowLossyTranscode accepts the generation loss of a lossy-to-lossy encode.
 func decidePromoteAction(
 	opts promoteFreeDLOptions,
 	assignment promoteAssignment,
@@ -872,8 +1141,34 @@ func decidePromoteAction(
 		}
 	}
 	sourceCodec := normalizePromoteCodec(sourceProbe.Codec)
+	source := describePromoteSource(sourceCodec, sourceProbe)
 	if isPromoteLossless(assignment.FreeDL, sourceProbe) {
-		return promoteDecision{Mode: policy.LosslessAction}
+		if sourceProbe.SuspectQuality && !opts.AllowSuspectQuality {
+			reason := "suspect-quality-tagged"
+			if sourceProbe.SpectralCeilingHz > 0 {
+				reason = fmt.Sprintf("suspect-quality-spectral-ceiling-%.1fkHz", sourceProbe.SpectralCeilingHz/1000)
+			}
+			return promoteDecision{
+				Mode:   promoteActionSkip,
+				Reason: reason + "; likely upsampled from lossy, promoting needs --allow-suspect-quality",
+			}
+		}
+		if canRemuxPromoteAudio(sourceCodec, policy.DesiredCodec) {
+			return promoteDecision{
+				Mode:   promoteActionCopyAudio,
+				Impact: promoteImpactNone,
+				Detail: fmt.Sprintf("remux, %s stream copied", source),
+			}
+		}
+		impact := promoteImpactLossyEncode
+		if policy.DesiredCodec == promoteCodecWAV {
+			impact = promoteImpactLosslessDecode
+		}
+		return promoteDecision{
+			Mode:   policy.LosslessAction,
+			Impact: impact,
+			Detail: fmt.Sprintf("%s -> %s", source, describePromoteEncodeTarget(opts, policy.LosslessAction)),
+		}
 	}
 
 	if !isHighQualityLossySource(opts, sourceProbe) {
@@ -883,13 +1178,66 @@ func decidePromoteAction(
 		}
 	}
 
-	if !isPromoteCodecCompatible(sourceCodec, policy.DesiredCodec) {
+	if isPromoteCodecCompatible(sourceCodec, policy.DesiredCodec) {
+		return promoteDecision{
+			Mode:   promoteActionCopyAudio,
+			Impact: promoteImpactNone,
+			Detail: fmt.Sprintf("remux, %s stream copied", source),
+		}
+	}
+	reason := fmt.Sprintf("hq-lossy-source-codec-%s-target-codec-%s", sourceCodec, policy.DesiredCodec)
+	if policy.DesiredCodec == promoteCodecWAV {
+		// Decoding lossy audio to PCM only inflates the file.
+		return promoteDecision{Mode: promoteActionSkip, Reason: reason}
+	}
+	if !opts.AllowLossyTranscode {
 		return promoteDecision{
 			Mode:   promoteActionSkip,
-			Reason: fmt.Sprintf("hq-lossy-source-codec-%s-target-codec-%s", sourceCodec, policy.DesiredCodec),
+			Reason: reason + "; lossy-to-lossy transcode needs --allow-lossy-transcode",
 		}
 	}
-	return promoteDecision{Mode: promoteActionCopyAudio}
+	return promoteDecision{
+		Mode:   policy.LosslessAction,
+		Impact: promoteImpactGenerationLoss,
+		Detail: fmt.Sprintf("%s re-encoded to %s", source, describePromoteEncodeTarget(opts, policy.LosslessAction)),
+	}
+}
+
+// canRemuxPromoteAudio reports whether a lossless source stream can be copied
+// into the target container unchanged. WAV only holds little-endian PCM, so
+// big-endian AIFF audio still needs an encode.
+func canRemuxPromoteAudio(sourceCodec string, desiredCodec string) bool {
+	if desiredCodec != promoteCodecWAV {
+		return false
+	}
+	return sourceCodec == "pcm_u8" || (strings.HasPrefix(sourceCodec, "pcm_") && strings.HasSuffix(sourceCodec, "le"))
+}
+
+func describePromoteSource(codec string, probe promoteAudioProbe) string {
+	bitrate := probe.EffectiveBitrate
+	if bitrate <= 0 {
+		bitrate = probe.Bitrate
+	}
+	if codec == "" {
+		codec = "unknown"
+	}
+	if bitrate <= 0 || strings.HasPrefix(codec, "pcm_") {
+		return codec
+	}
+	return fmt.Sprintf("%s %dk", codec, int(math.Round(float64(bitrate)/1000)))
+}
+
+func describePromoteEncodeTarget(opts promoteFreeDLOptions, mode promoteActionMode) string {
+	switch mode {
+	case promoteActionEncodeMP3:
+		return "mp3 " + opts.MP3Bitrate
+	case promoteActionEncodeAAC:
+		return "aac " + opts.AACBitrate
+	case promoteActionEncodeWAV:
+		return "pcm_s16le"
+	default:
+		return string(mode)
+	}
 }
 
 func normalizePromoteTargetFormat(raw string) (string, error) {
@@ -998,6 +1346,34 @@ func isPromoteLossless(file promoteMediaFile, probe promoteAudioProbe) bool {
 	}
 }
 
+// checkPromoteSourceQuality flags a lossless source as suspect quality when
+// its comment tag carries the marker a sync-time check leaves, or, with
+// VerifyQuality, when its spectral ceiling is too low. The returned error is
+// a spectral check that could not run; the probe is then left unflagged.
+func checkPromoteSourceQuality(
+	ctx context.Context,
+	opts promoteFreeDLOptions,
+	file promoteMediaFile,
+	probe promoteAudioProbe,
+) (promoteAudioProbe, error) {
+	if !isPromoteLossless(file, probe) {
+		return probe, nil
+	}
+	if strings.Contains(file.Comment, engine.SuspectQualityMarker) {
+		probe.SuspectQuality = true
+	}
+	if !opts.VerifyQuality {
+		return probe, nil
+	}
+	ceiling, err := probeSpectralFn(ctx, file.Path)
+	if err != nil {
+		return probe, err
+	}
+	probe.SpectralCeilingHz = ceiling.CeilingHz
+	probe.SuspectQuality = probe.SuspectQuality || ceiling.Suspect
+	return probe, nil
+}
+
 func isHighQualityLossySource(opts promoteFreeDLOptions, probe promoteAudioProbe) bool {
 	codec := normalizePromoteCodec(probe.Codec)
 	bitrate := probe.EffectiveBitrate
@@ -1067,8 +1443,17 @@ func runPromoteFFmpegCommand(
 		"-i", assignment.FreeDL.Path,
 		"-i", assignment.Library.Path,
 		"-map", "0:a:0",
-		"-map_metadata", "1",
 	}
+	var sourceMetadata, libraryMetadata *promoteMetadata
+	if probed, err := probePromoteMetadataWithTimeout(ctx, assignment.FreeDL.Path, opts.ProbeTimeout); err == nil {
+		sourceMetadata = &probed
+	}
+	if probed, err := probePromoteMetadataWithTimeout(ctx, assignment.Library.Path, opts.ProbeTimeout); err == nil {
+		libraryMetadata = &probed
+	}
+	// WAV containers do not support embedded cover-art video streams.
+	artwork := !strings.EqualFold(filepath.Ext(outputPath), ".wav")
+	args = append(args, promoteMetadataArgs(opts.tagPolicy, sourceMetadata, libraryMetadata, artwork)...)
 	switch decision.Mode {
 	case promoteActionCopyAudio:
 		args = append(args, "-c:a", "copy")
@@ -1081,14 +1466,9 @@ func runPromoteFFmpegCommand(
 	default:
 		return fmt.Errorf("unsupported promote action mode: %s", decision.Mode)
 	}
-	// WAV containers do not support embedded cover-art video streams.
-	if !strings.EqualFold(filepath.Ext(outputPath), ".wav") {
-		args = append(args, "-map", "1:v?", "-c:v", "copy")
-	}
 	args = append(args, outputPath)
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-	output, err := cmd.CombinedOutput()
+	output, err := engine.RunPostProcess(ctx, "ffmpeg", args...)
 	if err != nil {
 		trimmedOutput := strings.TrimSpace(string(output))
 		if trimmedOutput == "" {
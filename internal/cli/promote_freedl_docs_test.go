@@ -37,6 +37,14 @@ func TestPromoteFreeDLReadmeIncludesCurrentFlagsAndNotes(t *testing.T) {
 		"`--min-mp3-kbps <n>`",
 		"`--min-opus-kbps <n>`",
 		"`--replace-limit <n>`",
+		"`--allow-lossy-transcode`",
+		"`--fingerprint`",
+		"`--output <text|json>`",
+		"`--interactive`",
+		"`--rollback <run-id>`",
+		"`--tag-policy <prefer-library|prefer-source|merge>`",
+		"`--tag-field <tag>=<policy>`",
+		"`--artwork <best|prefer-library|prefer-source>`",
 		"Browser launch/wait/post-processing failures are persisted for manual follow-up in `defaults.state_dir/<source-id>.freedl-stuck.jsonl`.",
 		"`VBR`",
 	}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// promoteFingerprintLength is how many seconds of audio fpcalc reads;
+	// the opening two minutes identify a recording without decoding it all.
+	promoteFingerprintLength  = 120
+	promoteFingerprintTimeout = 30 * time.Second
+	// promoteFingerprintMaxOffset is how many fingerprint items (~0.124s
+	// each) one file may lead or trail the other, to absorb leading silence
+	// or trimmed intros.
+	promoteFingerprintMaxOffset  = 24
+	promoteFingerprintMinOverlap = 40
+	// Bit agreement at or above promoteFingerprintSame means the same
+	// recording; at or below promoteFingerprintDifferent means different
+	// audio. Unrelated recordings agree on about half the bits.
+	promoteFingerprintSame      = 0.85
+	promoteFingerprintDifferent = 0.65
+	// promoteFingerprintMaxDurationGap rejects pairs whose lengths differ so
+	// much (an extended mix, a radio edit) that they cannot be one recording.
+	promoteFingerprintMaxDurationGap = 10.0
+
+	promoteFingerprintMatchScore      = 99
+	promoteFingerprintMismatchPenalty = 25
+)
+
+var fingerprintPromoteFn = probePromoteFingerprint
+
+type promoteFingerprint struct {
+	Duration float64
+	Values   []uint32
+}
+
+type promoteAudioComparison int
+
+const (
+	promoteAudioUnknown promoteAudioComparison = iota
+	promoteAudioSame
+	promoteAudioDifferent
+)
+
+// fingerprintPromoteFiles computes a Chromaprint fingerprint for each file
+// and returns how many succeeded. Files fpcalc cannot read are matched on
+// metadata alone.
+func fingerprintPromoteFiles(ctx context.Context, files []promoteMediaFile) int {
+	fingerprinted := 0
+	for i := range files {
+		if ctx.Err() != nil {
+			return fingerprinted
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, promoteFingerprintTimeout)
+		fingerprint, err := fingerprintPromoteFn(probeCtx, files[i].Path)
+		cancel()
+		if err != nil || len(fingerprint.Values) == 0 {
+			continue
+		}
+		files[i].Fingerprint = fingerprint
+		fingerprinted++
+	}
+	return fingerprinted
+}
+
+func probePromoteFingerprint(ctx context.Context, path string) (promoteFingerprint, error) {
+	cmd := exec.CommandContext(ctx, "fpcalc", "-raw", "-json", "-length", fmt.Sprint(promoteFingerprintLength), path)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if trimmed := strings.TrimSpace(string(exitErr.Stderr)); trimmed != "" {
+				return promoteFingerprint{}, fmt.Errorf("%v: %s", err, trimmed)
+			}
+		}
+		return promoteFingerprint{}, err
+	}
+	return parsePromoteFingerprint(output)
+}
+
+func parsePromoteFingerprint(output []byte) (promoteFingerprint, error) {
+	var payload struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint []int64 `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return promoteFingerprint{}, fmt.Errorf("decode fpcalc output: %w", err)
+	}
+	values := make([]uint32, 0, len(payload.Fingerprint))
+	for _, value := range payload.Fingerprint {
+		// fpcalc prints raw items unsigned, older builds signed; both carry
+		// the same 32 bits.
+		values = append(values, uint32(value))
+	}
+	return promoteFingerprint{Duration: payload.Duration, Values: values}, nil
+}
+
+// comparePromoteFingerprints reports whether two files hold the same
+// recording, trying small offsets between them and keeping the best bit
+// agreement. Pairs missing a fingerprint compare as unknown.
+func comparePromoteFingerprints(a promoteFingerprint, b promoteFingerprint) promoteAudioComparison {
+	if len(a.Values) < promoteFingerprintMinOverlap || len(b.Values) < promoteFingerprintMinOverlap {
+		return promoteAudioUnknown
+	}
+	if a.Duration > 0 && b.Duration > 0 && math.Abs(a.Duration-b.Duration) > promoteFingerprintMaxDurationGap {
+		return promoteAudioDifferent
+	}
+	best := 0.0
+	for offset := -promoteFingerprintMaxOffset; offset <= promoteFingerprintMaxOffset; offset++ {
+		if agreement, ok := promoteFingerprintAgreement(a.Values, b.Values, offset); ok && agreement > best {
+			best = agreement
+		}
+	}
+	switch {
+	case best >= promoteFingerprintSame:
+		return promoteAudioSame
+	case best <= promoteFingerprintDifferent:
+		return promoteAudioDifferent
+	default:
+		return promoteAudioUnknown
+	}
+}
+
+// promoteFingerprintAgreement is the fraction of equal bits between a and b
+// with b shifted by offset items.
+func promoteFingerprintAgreement(a []uint32, b []uint32, offset int) (float64, bool) {
+	startA, startB := 0, 0
+	if offset > 0 {
+		startB = offset
+	} else {
+		startA = -offset
+	}
+	overlap := min(len(a)-startA, len(b)-startB)
+	if overlap < promoteFingerprintMinOverlap {
+		return 0, false
+	}
+	differing := 0
+	for i := 0; i < overlap; i++ {
+		differing += bits.OnesCount32(a[startA+i] ^ b[startB+i])
+	}
+	return 1 - float64(differing)/float64(overlap*32), true
+}
@@ -0,0 +1,289 @@
+package cli
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/fileops"
+)
+
+const (
+	// promoteBackupDirName holds one directory per applying run under
+	// --library-dir; promote-freedl never indexes it.
+	promoteBackupDirName   = ".udl-backup"
+	promoteJournalFileName = "journal.jsonl"
+	promoteBackupFilesDir  = "files"
+)
+
+// promoteJournalEntry records one in-place replacement: the library file
+// (relative to --library-dir), where its original was kept, and the hashes
+// that let --rollback tell whether the file changed again since.
+type promoteJournalEntry struct {
+	Library        string    `json:"library"`
+	FreeDL         string    `json:"free_dl"`
+	Backup         string    `json:"backup"`
+	OriginalSHA256 string    `json:"original_sha256"`
+	ReplacedSHA256 string    `json:"replaced_sha256"`
+	ReplacedAt     time.Time `json:"replaced_at"`
+}
+
+// promoteJournal is the undo journal of one applying run, kept in
+// <library-dir>/.udl-backup/<run-id>/. Originals are hard-linked into
+// files/ before they are replaced (copied when linking fails), so a backup
+// costs no extra space on the same filesystem.
+type promoteJournal struct {
+	RunID      string
+	dir        string
+	libraryDir string
+	now        func() time.Time
+}
+
+// openPromoteJournal creates the run directory for a run started at started.
+// Run ids use the run-report form (20060102T150405Z, suffixed -2, -3, ... on
+// collision).
+func openPromoteJournal(libraryDir string, started time.Time) (*promoteJournal, error) {
+	root := filepath.Join(libraryDir, promoteBackupDirName)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", root, err)
+	}
+	base := started.UTC().Format("20060102T150405Z")
+	runID := base
+	dir := filepath.Join(root, runID)
+	for attempt := 2; ; attempt++ {
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) || attempt > 100 {
+			return nil, fmt.Errorf("create promote journal directory: %w", err)
+		}
+		runID = fmt.Sprintf("%s-%d", base, attempt)
+		dir = filepath.Join(root, runID)
+	}
+	return &promoteJournal{RunID: runID, dir: dir, libraryDir: libraryDir, now: time.Now}, nil
+}
+
+// backup preserves the library file of assignment before it is replaced.
+func (j *promoteJournal) backup(assignment promoteAssignment) (promoteJournalEntry, error) {
+	hash, err := promoteFileSHA256(assignment.Library.Path)
+	if err != nil {
+		return promoteJournalEntry{}, fmt.Errorf("hash original: %w", err)
+	}
+	entry := promoteJournalEntry{
+		Library:        assignment.Library.Rel,
+		FreeDL:         assignment.FreeDL.Path,
+		Backup:         filepath.ToSlash(filepath.Join(promoteBackupFilesDir, filepath.FromSlash(assignment.Library.Rel))),
+		OriginalSHA256: hash,
+	}
+	backupPath := filepath.Join(j.dir, filepath.FromSlash(entry.Backup))
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0o755); err != nil {
+		return promoteJournalEntry{}, fmt.Errorf("create backup directory: %w", err)
+	}
+	if err := os.Link(assignment.Library.Path, backupPath); err != nil {
+		if copyErr := copyPromoteFile(assignment.Library.Path, backupPath); copyErr != nil {
+			return promoteJournalEntry{}, fmt.Errorf("back up original: %w", copyErr)
+		}
+	}
+	return entry, nil
+}
+
+// discard drops the backup of a replacement that did not happen.
+func (j *promoteJournal) discard(entry promoteJournalEntry) {
+	_ = os.Remove(filepath.Join(j.dir, filepath.FromSlash(entry.Backup)))
+}
+
+// record appends entry once its replacement is in place.
+func (j *promoteJournal) record(entry promoteJournalEntry) error {
+	hash, err := promoteFileSHA256(filepath.Join(j.libraryDir, filepath.FromSlash(entry.Library)))
+	if err != nil {
+		return fmt.Errorf("hash replacement: %w", err)
+	}
+	entry.ReplacedSHA256 = hash
+	entry.ReplacedAt = j.now().UTC()
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(j.dir, promoteJournalFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(encoded, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// promoteRollbackResult counts what --rollback did or, in preview, would do.
+type promoteRollbackResult struct {
+	Restored int
+	Skipped  int
+	Failed   int
+}
+
+// rollbackPromoteRun restores the originals recorded by run runID, newest
+// replacement first. Files whose content is neither the recorded replacement
+// nor the original changed after the run and are left alone; files already
+// back to their original are skipped. With apply unset nothing is written.
+// Restored backups are removed, and the run directory goes once every entry
+// is restored.
+func rollbackPromoteRun(libraryDir string, runID string, apply bool, out io.Writer, errOut io.Writer) (promoteRollbackResult, error) {
+	result := promoteRollbackResult{}
+	runID = strings.TrimSpace(runID)
+	if runID == "" || runID != filepath.Base(runID) || strings.HasPrefix(runID, ".") {
+		return result, fmt.Errorf("invalid --rollback run id %q", runID)
+	}
+	dir := filepath.Join(libraryDir, promoteBackupDirName, runID)
+	entries, err := readPromoteJournal(filepath.Join(dir, promoteJournalFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return result, fmt.Errorf("no promote-freedl journal for run %q in %s", runID, filepath.Join(libraryDir, promoteBackupDirName))
+		}
+		return result, err
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		target := filepath.Join(libraryDir, filepath.FromSlash(entry.Library))
+		backupPath := filepath.Join(dir, filepath.FromSlash(entry.Backup))
+		if !promotePathWithin(libraryDir, target) || !promotePathWithin(dir, backupPath) {
+			result.Failed++
+			fmt.Fprintf(errOut, "[fail] %s (journal path escapes --library-dir)\n", entry.Library)
+			continue
+		}
+		current, hashErr := promoteFileSHA256(target)
+		switch {
+		case hashErr != nil && !errors.Is(hashErr, os.ErrNotExist):
+			result.Failed++
+			fmt.Fprintf(errOut, "[fail] %s (hash current file: %v)\n", entry.Library, hashErr)
+			continue
+		case hashErr == nil && current == entry.OriginalSHA256:
+			result.Skipped++
+			fmt.Fprintf(out, "[skip] %s (already original)\n", entry.Library)
+			continue
+		case hashErr == nil && current != entry.ReplacedSHA256:
+			result.Skipped++
+			fmt.Fprintf(out, "[skip] %s (modified since run %s; restore %s by hand if needed)\n", entry.Library, runID, backupPath)
+			continue
+		}
+		if _, statErr := os.Stat(backupPath); statErr != nil {
+			result.Failed++
+			fmt.Fprintf(errOut, "[fail] %s (backup missing: %v)\n", entry.Library, statErr)
+			continue
+		}
+		if !apply {
+			result.Restored++
+			fmt.Fprintf(out, "[plan] restore %s from %s\n", entry.Library, backupPath)
+			continue
+		}
+		if err := restorePromoteBackup(backupPath, target); err != nil {
+			result.Failed++
+			fmt.Fprintf(errOut, "[fail] %s (%v)\n", entry.Library, err)
+			continue
+		}
+		_ = os.Remove(backupPath)
+		result.Restored++
+		fmt.Fprintf(out, "[done] restored %s\n", entry.Library)
+	}
+	if apply && result.Skipped == 0 && result.Failed == 0 {
+		_ = os.RemoveAll(dir)
+	}
+	return result, nil
+}
+
+func readPromoteJournal(path string) ([]promoteJournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	entries := []promoteJournalEntry{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		entry := promoteJournalEntry{}
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return nil, fmt.Errorf("parse %s line %d: %w", path, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// restorePromoteBackup copies backupPath next to target and swaps it in, so
+// an interrupted restore leaves either file intact.
+func restorePromoteBackup(backupPath string, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(target), ".udl-rollback-*"+filepath.Ext(target))
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	_ = tempFile.Close()
+	if err := copyPromoteFile(backupPath, tempPath); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := fileops.ReplaceFileSafely(tempPath, target); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+func copyPromoteFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func promoteFileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func promotePathWithin(root string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"encoding/json"
+	"io"
+)
+
+const (
+	promoteOutputText = "text"
+	promoteOutputJSON = "json"
+)
+
+// promoteReport is the `--output json` form of a promote-freedl run: every
+// matched pair with its score and decision, the ambiguous matches that were
+// skipped, and the totals. Paths in assignments are relative to the free-DL
+// and library directories.
+type promoteReport struct {
+	Mode         string                    `json:"mode"`
+	RunID        string                    `json:"run_id,omitempty"`
+	FreeDLDir    string                    `json:"free_dl_dir"`
+	LibraryDir   string                    `json:"library_dir"`
+	WriteDir     string                    `json:"write_dir,omitempty"`
+	Settings     promoteReportSettings     `json:"settings"`
+	FreeDLFiles  int                       `json:"free_dl_files"`
+	LibraryFiles int                       `json:"library_files"`
+	Assignments  []promoteReportAssignment `json:"assignments"`
+	Ambiguous    []promoteReportAmbiguous  `json:"ambiguous"`
+	Summary      promoteReportSummary      `json:"summary"`
+}
+
+type promoteReportSettings struct {
+	TargetFormat        string            `json:"target_format"`
+	MinMatchScore       int               `json:"min_match_score"`
+	AmbiguityGap        int               `json:"ambiguity_gap"`
+	ReplaceLimit        int               `json:"replace_limit"`
+	Fingerprint         bool              `json:"fingerprint"`
+	AllowLossyTranscode bool              `json:"allow_lossy_transcode"`
+	TagPolicy           string            `json:"tag_policy"`
+	TagFields           map[string]string `json:"tag_fields,omitempty"`
+	Artwork             string            `json:"artwork"`
+}
+
+// promoteReportAssignment is one matched pair. Status is planned, done,
+// skipped, or failed; Reason says why a pair was skipped and Error why it
+// failed.
+type promoteReportAssignment struct {
+	Library    string `json:"library"`
+	FreeDL     string `json:"free_dl"`
+	Score      int    `json:"score"`
+	Status     string `json:"status"`
+	Action     string `json:"action,omitempty"`
+	Impact     string `json:"impact,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	OutputPath string `json:"output_path,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type promoteReportAmbiguous struct {
+	Library          string `json:"library"`
+	Best             string `json:"best"`
+	BestScore        int    `json:"best_score"`
+	Alternative      string `json:"alternative"`
+	AlternativeScore int    `json:"alternative_score"`
+}
+
+// promoteReportSummary matches the text summary line; Unprocessed counts
+// matches left out by --replace-limit.
+type promoteReportSummary struct {
+	Planned     int `json:"planned"`
+	Replaced    int `json:"replaced"`
+	Skipped     int `json:"skipped"`
+	Failed      int `json:"failed"`
+	Unprocessed int `json:"unprocessed"`
+}
+
+func newPromoteReport(opts promoteFreeDLOptions, freeDLDir string, libraryDir string, writeDir string, previewMode bool) *promoteReport {
+	return &promoteReport{
+		Mode:       map[bool]string{true: "preview", false: "apply"}[previewMode],
+		FreeDLDir:  freeDLDir,
+		LibraryDir: libraryDir,
+		WriteDir:   writeDir,
+		Settings: promoteReportSettings{
+			TargetFormat:        opts.TargetFormat,
+			MinMatchScore:       opts.MinMatchScore,
+			AmbiguityGap:        opts.AmbiguityGap,
+			ReplaceLimit:        opts.ReplaceLimit,
+			Fingerprint:         opts.Fingerprint,
+			AllowLossyTranscode: opts.AllowLossyTranscode,
+			TagPolicy:           opts.tagPolicy.Default,
+			TagFields:           opts.tagPolicy.Fields,
+			Artwork:             opts.tagPolicy.Artwork,
+		},
+		Assignments: []promoteReportAssignment{},
+		Ambiguous:   []promoteReportAmbiguous{},
+	}
+}
+
+func (r *promoteReport) add(assignment promoteAssignment, status string, decision promoteDecision) *promoteReportAssignment {
+	r.Assignments = append(r.Assignments, promoteReportAssignment{
+		Library: assignment.Library.Rel,
+		FreeDL:  assignment.FreeDL.Rel,
+		Score:   assignment.Score,
+		Status:  status,
+		Action:  string(decision.Mode),
+		Impact:  string(decision.Impact),
+		Detail:  decision.Detail,
+	})
+	return &r.Assignments[len(r.Assignments)-1]
+}
+
+func (r *promoteReport) write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
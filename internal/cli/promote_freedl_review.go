@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const promoteReviewRejectedReason = "rejected-in-review"
+
+// promoteReviewResult is the match plan after --interactive review: accepted
+// assignments (with any alternative the user chose), ambiguous matches left
+// unresolved, and the assignments the user rejected.
+type promoteReviewResult struct {
+	Plan     promoteAssignmentPlan
+	Rejected []promoteAssignment
+}
+
+// promoteReviewer asks one question per match on out and reads answers from
+// a single buffered reader, so piped answers are not lost between prompts.
+type promoteReviewer struct {
+	in          *bufio.Reader
+	out         io.Writer
+	opts        promoteFreeDLOptions
+	freeDLFiles []promoteMediaFile
+	// used maps a free-DL path to the library file it is assigned to.
+	used map[string]string
+	quit bool
+}
+
+// reviewPromotePlan walks each ambiguous match and then each planned
+// replacement before anything is written. Ambiguous matches can take one of
+// their candidates or stay skipped; planned replacements can be accepted,
+// rejected, or switched to another free-DL candidate. Quitting rejects every
+// match not yet answered.
+func reviewPromotePlan(
+	ctx context.Context,
+	in io.Reader,
+	out io.Writer,
+	opts promoteFreeDLOptions,
+	plan promoteAssignmentPlan,
+	freeDLFiles []promoteMediaFile,
+) (promoteReviewResult, error) {
+	r := &promoteReviewer{
+		in:          bufio.NewReader(in),
+		out:         out,
+		opts:        opts,
+		freeDLFiles: freeDLFiles,
+		used:        map[string]string{},
+	}
+	for _, assignment := range plan.Assignments {
+		r.used[assignment.FreeDL.Path] = assignment.Library.Rel
+	}
+	total := len(plan.Ambiguous) + len(plan.Assignments)
+	step := 0
+	result := promoteReviewResult{}
+
+	fmt.Fprintln(out, "promote-freedl: interactive review (nothing is written until every match is answered)")
+	for _, ambiguous := range plan.Ambiguous {
+		step++
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if r.quit {
+			result.Plan.Ambiguous = append(result.Plan.Ambiguous, ambiguous)
+			continue
+		}
+		candidates := r.candidates(ambiguous.Library, "")
+		fmt.Fprintf(out, "[review %d/%d] %s has ambiguous matches (top=%d second=%d)\n", step, total, ambiguous.Library.Rel, ambiguous.BestScore, ambiguous.AltScore)
+		choice, err := r.ask(candidates, false)
+		if err != nil {
+			return result, err
+		}
+		if choice < 0 {
+			result.Plan.Ambiguous = append(result.Plan.Ambiguous, ambiguous)
+			continue
+		}
+		chosen := candidates[choice]
+		r.used[chosen.FreeDL.Path] = ambiguous.Library.Rel
+		result.Plan.Assignments = append(result.Plan.Assignments, chosen)
+	}
+
+	for _, assignment := range plan.Assignments {
+		step++
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		if r.quit {
+			result.Rejected = append(result.Rejected, assignment)
+			continue
+		}
+		line := fmt.Sprintf("%s <= %s (score=%d)", assignment.Library.Rel, assignment.FreeDL.Rel, assignment.Score)
+		if probe, err := probePromoteAudioWithTimeout(ctx, assignment.FreeDL.Path, opts.ProbeTimeout); err == nil {
+			probe, _ = checkPromoteSourceQuality(ctx, opts, assignment.FreeDL, probe)
+			decision := decidePromoteAction(opts, assignment, probe)
+			if decision.Mode == promoteActionSkip {
+				// The apply loop skips it with its reason; nothing to decide.
+				result.Plan.Assignments = append(result.Plan.Assignments, assignment)
+				continue
+			}
+			line = fmt.Sprintf("%s <= %s (score=%d mode=%s impact=%s: %s)", assignment.Library.Rel, assignment.FreeDL.Rel, assignment.Score, decision.Mode, decision.Impact, decision.Detail)
+		}
+		fmt.Fprintf(out, "[review %d/%d] %s\n", step, total, line)
+		candidates := r.candidates(assignment.Library, assignment.FreeDL.Path)
+		choice, err := r.ask(candidates, true)
+		if err != nil {
+			return result, err
+		}
+		switch {
+		case choice == promoteReviewAccept:
+			result.Plan.Assignments = append(result.Plan.Assignments, assignment)
+		case choice < 0:
+			delete(r.used, assignment.FreeDL.Path)
+			result.Rejected = append(result.Rejected, assignment)
+		default:
+			chosen := candidates[choice]
+			delete(r.used, assignment.FreeDL.Path)
+			r.used[chosen.FreeDL.Path] = assignment.Library.Rel
+			result.Plan.Assignments = append(result.Plan.Assignments, chosen)
+		}
+	}
+
+	sort.SliceStable(result.Plan.Assignments, func(i, j int) bool {
+		return result.Plan.Assignments[i].Library.Rel < result.Plan.Assignments[j].Library.Rel
+	})
+	fmt.Fprintf(out, "promote-freedl: review accepted=%d rejected=%d unresolved-ambiguous=%d\n", len(result.Plan.Assignments), len(result.Rejected), len(result.Plan.Ambiguous))
+	return result, nil
+}
+
+// promoteReviewAccept is ask's answer for keeping a planned assignment as is.
+const promoteReviewAccept = -2
+
+// ask lists candidates and reads an answer until it is valid. It returns a
+// candidate index, promoteReviewAccept, or -1 for reject and quit.
+func (r *promoteReviewer) ask(candidates []promoteAssignment, acceptable bool) (int, error) {
+	for i, candidate := range candidates {
+		fmt.Fprintf(r.out, "  %d) %s (score=%d)\n", i+1, candidate.FreeDL.Rel, candidate.Score)
+	}
+	options := []string{}
+	if acceptable {
+		options = append(options, "a=accept")
+	}
+	if len(candidates) == 1 {
+		options = append(options, "1=choose it")
+	} else if len(candidates) > 1 {
+		options = append(options, fmt.Sprintf("1-%d=choose one", len(candidates)))
+	}
+	options = append(options, "r=reject", "q=quit")
+	for {
+		fmt.Fprintf(r.out, "  %s: ", strings.Join(options, ", "))
+		line, err := r.in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if err != nil && (answer == "" || !errors.Is(err, io.EOF)) {
+			if errors.Is(err, io.EOF) {
+				return 0, fmt.Errorf("interactive review input ended before every match was answered; nothing was written")
+			}
+			return 0, fmt.Errorf("read review answer: %w", err)
+		}
+		switch answer {
+		case "a", "accept", "y", "yes":
+			if acceptable {
+				return promoteReviewAccept, nil
+			}
+		case "r", "reject", "n", "no", "s", "skip":
+			return -1, nil
+		case "q", "quit":
+			r.quit = true
+			return -1, nil
+		default:
+			if n, convErr := strconv.Atoi(answer); convErr == nil && n >= 1 && n <= len(candidates) {
+				return n - 1, nil
+			}
+		}
+		fmt.Fprintf(r.out, "  unrecognized answer %q\n", answer)
+	}
+}
+
+// candidates lists the free-DL files that clear --min-match-score for
+// library, best first, leaving out exclude and files already assigned to
+// another library file.
+func (r *promoteReviewer) candidates(library promoteMediaFile, exclude string) []promoteAssignment {
+	out := []promoteAssignment{}
+	for _, freeDL := range r.freeDLFiles {
+		if freeDL.Path == exclude {
+			continue
+		}
+		if owner, taken := r.used[freeDL.Path]; taken && owner != library.Rel {
+			continue
+		}
+		score := scorePromoteMatch(library, freeDL)
+		if score < r.opts.MinMatchScore {
+			continue
+		}
+		out = append(out, promoteAssignment{Library: library, FreeDL: freeDL, Score: score})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Score != out[j].Score {
+			return out[i].Score > out[j].Score
+		}
+		return out[i].FreeDL.Rel < out[j].FreeDL.Rel
+	})
+	return out
+}
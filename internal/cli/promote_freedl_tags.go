@@ -0,0 +1,294 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+const (
+	// promoteTagPreferLibrary keeps the library value and fills tags the
+	// library lacks from the free-DL file.
+	promoteTagPreferLibrary = "prefer-library"
+	// promoteTagPreferSource takes the free-DL value wherever it has one.
+	promoteTagPreferSource = "prefer-source"
+	// promoteTagMerge keeps every distinct value of both files, library
+	// values first, joined with "; ".
+	promoteTagMerge = "merge"
+
+	// promoteArtworkBest embeds the higher-resolution cover of the two.
+	promoteArtworkBest = "best"
+)
+
+// promoteTechnicalTags are container and encoder bookkeeping, never copied
+// from one file to the other.
+var promoteTechnicalTags = map[string]bool{
+	"encoder":           true,
+	"major_brand":       true,
+	"minor_version":     true,
+	"compatible_brands": true,
+	"creation_time":     true,
+	"handler_name":      true,
+	"vendor_id":         true,
+	"duration":          true,
+}
+
+var probeMetadataFn = probePromoteMetadata
+
+// promoteTagPolicy decides which input's tags and artwork a promoted file
+// keeps: Default for every tag, Fields for named ones, Artwork for the
+// embedded cover.
+type promoteTagPolicy struct {
+	Default string
+	Fields  map[string]string
+	Artwork string
+}
+
+// promoteMetadata is what ffprobe reports about one input: its tags with
+// lowercased keys and its largest embedded cover, if any.
+type promoteMetadata struct {
+	Tags    map[string]string
+	Artwork *promoteArtwork
+}
+
+type promoteArtwork struct {
+	Stream int
+	Width  int
+	Height int
+}
+
+func (a *promoteArtwork) pixels() int {
+	if a == nil {
+		return 0
+	}
+	return a.Width * a.Height
+}
+
+// parsePromoteTagPolicy validates --tag-policy, each --tag-field
+// <tag>=<policy>, and --artwork.
+func parsePromoteTagPolicy(defaultPolicy string, fields []string, artwork string) (promoteTagPolicy, error) {
+	policy := promoteTagPolicy{Fields: map[string]string{}}
+	parsed, ok := normalizePromoteTagPolicy(defaultPolicy, false)
+	if !ok {
+		return policy, fmt.Errorf("--tag-policy must be %s, %s, or %s", promoteTagPreferLibrary, promoteTagPreferSource, promoteTagMerge)
+	}
+	policy.Default = parsed
+	for _, raw := range fields {
+		field, value, found := strings.Cut(raw, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !found || field == "" {
+			return policy, fmt.Errorf("invalid --tag-field %q (expected <tag>=<policy>)", raw)
+		}
+		parsed, ok := normalizePromoteTagPolicy(value, false)
+		if !ok {
+			return policy, fmt.Errorf("invalid --tag-field %q (policy must be %s, %s, or %s)", raw, promoteTagPreferLibrary, promoteTagPreferSource, promoteTagMerge)
+		}
+		policy.Fields[field] = parsed
+	}
+	parsed, ok = normalizePromoteTagPolicy(artwork, true)
+	if !ok {
+		return policy, fmt.Errorf("--artwork must be %s, %s, or %s", promoteArtworkBest, promoteTagPreferLibrary, promoteTagPreferSource)
+	}
+	policy.Artwork = parsed
+	return policy, nil
+}
+
+func normalizePromoteTagPolicy(raw string, artwork bool) (string, bool) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	switch value {
+	case "":
+		if artwork {
+			return promoteArtworkBest, true
+		}
+		return promoteTagPreferLibrary, true
+	case promoteTagPreferLibrary, promoteTagPreferSource:
+		return value, true
+	case promoteTagMerge:
+		return value, !artwork
+	case promoteArtworkBest:
+		return value, artwork
+	default:
+		return "", false
+	}
+}
+
+func (p promoteTagPolicy) forField(field string) string {
+	if policy, ok := p.Fields[field]; ok {
+		return policy
+	}
+	if p.Default == "" {
+		return promoteTagPreferLibrary
+	}
+	return p.Default
+}
+
+// mergePromoteTags returns the tags whose value in the promoted file differs
+// from the library file's, which ffmpeg writes over the library metadata it
+// maps in.
+func mergePromoteTags(policy promoteTagPolicy, library map[string]string, source map[string]string) map[string]string {
+	overrides := map[string]string{}
+	for field, sourceValue := range source {
+		sourceValue = strings.TrimSpace(sourceValue)
+		if sourceValue == "" || promoteTechnicalTags[field] {
+			continue
+		}
+		libraryValue := strings.TrimSpace(library[field])
+		if libraryValue == "" {
+			overrides[field] = sourceValue
+			continue
+		}
+		switch policy.forField(field) {
+		case promoteTagPreferSource:
+			if sourceValue != libraryValue {
+				overrides[field] = sourceValue
+			}
+		case promoteTagMerge:
+			if merged, changed := mergePromoteTagValues(libraryValue, sourceValue); changed {
+				overrides[field] = merged
+			}
+		}
+	}
+	return overrides
+}
+
+// mergePromoteTagValues splits both values on ";", "/" and ",", and joins
+// the distinct items (compared case-insensitively) library first. The
+// library value is kept verbatim when the source adds nothing.
+func mergePromoteTagValues(libraryValue string, sourceValue string) (string, bool) {
+	seen := map[string]bool{}
+	items := []string{}
+	add := func(value string) int {
+		added := 0
+		for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == '/' || r == ',' }) {
+			item = strings.TrimSpace(item)
+			key := strings.ToLower(item)
+			if item == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			items = append(items, item)
+			added++
+		}
+		return added
+	}
+	add(libraryValue)
+	if add(sourceValue) == 0 {
+		return libraryValue, false
+	}
+	return strings.Join(items, "; "), true
+}
+
+// choosePromoteArtwork returns the input (0 free-DL, 1 library) and stream
+// whose cover the promoted file embeds, or ok=false when neither has one.
+func choosePromoteArtwork(policy string, source *promoteArtwork, library *promoteArtwork) (input int, stream int, ok bool) {
+	switch {
+	case source == nil && library == nil:
+		return 0, 0, false
+	case source == nil:
+		return 1, library.Stream, true
+	case library == nil:
+		return 0, source.Stream, true
+	}
+	switch policy {
+	case promoteTagPreferSource:
+		return 0, source.Stream, true
+	case promoteArtworkBest:
+		if source.pixels() > library.pixels() {
+			return 0, source.Stream, true
+		}
+	}
+	return 1, library.Stream, true
+}
+
+// promoteMetadataArgs maps the promoted file's tags and cover: the library
+// file's metadata, the merge overrides on top, and the chosen artwork unless
+// the output container cannot embed one. Without probe results it keeps the
+// library metadata and cover as they are.
+func promoteMetadataArgs(policy promoteTagPolicy, source *promoteMetadata, library *promoteMetadata, artwork bool) []string {
+	args := []string{"-map_metadata", "1"}
+	if source == nil || library == nil {
+		if artwork {
+			args = append(args, "-map", "1:v?", "-c:v", "copy")
+		}
+		return args
+	}
+	overrides := mergePromoteTags(policy, library.Tags, source.Tags)
+	fields := make([]string, 0, len(overrides))
+	for field := range overrides {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		args = append(args, "-metadata", field+"="+overrides[field])
+	}
+	if artwork {
+		if input, stream, ok := choosePromoteArtwork(policy.Artwork, source.Artwork, library.Artwork); ok {
+			args = append(args, "-map", fmt.Sprintf("%d:%d", input, stream), "-c:v", "copy")
+		}
+	}
+	return args
+}
+
+func probePromoteMetadata(ctx context.Context, path string) (promoteMetadata, error) {
+	args := []string{
+		"-v", "error",
+		"-show_entries", "format_tags:stream=index,codec_type,width,height:stream_tags:stream_disposition=attached_pic",
+		"-of", "json",
+		path,
+	}
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		trimmedOutput := strings.TrimSpace(string(output))
+		if trimmedOutput == "" {
+			return promoteMetadata{}, err
+		}
+		return promoteMetadata{}, fmt.Errorf("%v: %s", err, trimmedOutput)
+	}
+
+	var payload struct {
+		Streams []struct {
+			Index       int               `json:"index"`
+			CodecType   string            `json:"codec_type"`
+			Width       int               `json:"width"`
+			Height      int               `json:"height"`
+			Tags        map[string]string `json:"tags"`
+			Disposition struct {
+				AttachedPic int `json:"attached_pic"`
+			} `json:"disposition"`
+		} `json:"streams"`
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return promoteMetadata{}, err
+	}
+	metadata := promoteMetadata{Tags: map[string]string{}}
+	for key, value := range payload.Format.Tags {
+		metadata.Tags[strings.ToLower(key)] = value
+	}
+	for _, stream := range payload.Streams {
+		switch stream.CodecType {
+		case "audio":
+			// Ogg and Opus keep their tags on the audio stream.
+			for key, value := range stream.Tags {
+				if _, ok := metadata.Tags[strings.ToLower(key)]; !ok {
+					metadata.Tags[strings.ToLower(key)] = value
+				}
+			}
+		case "video":
+			if stream.Disposition.AttachedPic != 1 {
+				continue
+			}
+			candidate := &promoteArtwork{Stream: stream.Index, Width: stream.Width, Height: stream.Height}
+			if metadata.Artwork == nil || candidate.pixels() > metadata.Artwork.pixels() {
+				metadata.Artwork = candidate
+			}
+		}
+	}
+	return metadata, nil
+}
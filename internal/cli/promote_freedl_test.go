@@ -3,11 +3,15 @@ package cli
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/jaa/update-downloads/internal/engine"
 )
 
 func TestNormalizePromoteKey(t *testing.T) {
@@ -110,6 +114,98 @@ func TestIsHighQualityLossySourceUsesEffectiveBitrateFallback(t *testing.T) {
 	}
 }
 
+func TestDecidePromoteActionPrefersRemuxAndGuardsLossyTranscode(t *testing.T) {
+	opts := promoteFreeDLOptions{
+		TargetFormat: promoteTargetAuto,
+		MP3Bitrate:   "320k",
+		AACBitrate:   "256k",
+		MinAACKbps:   256,
+		MinMP3Kbps:   320,
+		MinOpusKbps:  192,
+	}
+	wavOpts := opts
+	wavOpts.TargetFormat = promoteTargetWAV
+	cases := []struct {
+		name       string
+		opts       promoteFreeDLOptions
+		libraryExt string
+		freeExt    string
+		probe      promoteAudioProbe
+		mode       promoteActionMode
+		impact     promoteQualityImpact
+		detail     string
+	}{
+		{"hq aac remux", opts, ".m4a", ".m4a", promoteAudioProbe{Codec: "aac", EffectiveBitrate: 256000}, promoteActionCopyAudio, promoteImpactNone, "remux, aac 256k stream copied"},
+		{"lossless encode", opts, ".m4a", ".flac", promoteAudioProbe{Codec: "flac"}, promoteActionEncodeAAC, promoteImpactLossyEncode, "flac -> aac 256k"},
+		{"24-bit wav remux", wavOpts, ".wav", ".wav", promoteAudioProbe{Codec: "pcm_s24le"}, promoteActionCopyAudio, promoteImpactNone, "remux, pcm_s24le stream copied"},
+		{"aiff needs encode", wavOpts, ".wav", ".aiff", promoteAudioProbe{Codec: "pcm_s16be"}, promoteActionEncodeWAV, promoteImpactLosslessDecode, "pcm_s16be -> pcm_s16le"},
+		{"lossy transcode skipped", opts, ".m4a", ".opus", promoteAudioProbe{Codec: "opus", EffectiveBitrate: 256000}, promoteActionSkip, "", ""},
+	}
+	for _, tc := range cases {
+		assignment := promoteAssignment{
+			Library: promoteMediaFile{Ext: tc.libraryExt},
+			FreeDL:  promoteMediaFile{Ext: tc.freeExt},
+		}
+		decision := decidePromoteAction(tc.opts, assignment, tc.probe)
+		if decision.Mode != tc.mode || decision.Impact != tc.impact || decision.Detail != tc.detail {
+			t.Fatalf("%s: unexpected decision %+v", tc.name, decision)
+		}
+	}
+
+	assignment := promoteAssignment{Library: promoteMediaFile{Ext: ".m4a"}, FreeDL: promoteMediaFile{Ext: ".opus"}}
+	probe := promoteAudioProbe{Codec: "opus", EffectiveBitrate: 256000}
+	if decision := decidePromoteAction(opts, assignment, probe); !strings.Contains(decision.Reason, "--allow-lossy-transcode") {
+		t.Fatalf("expected skip reason to name the flag, got %q", decision.Reason)
+	}
+	opts.AllowLossyTranscode = true
+	decision := decidePromoteAction(opts, assignment, probe)
+	if decision.Mode != promoteActionEncodeAAC || decision.Impact != promoteImpactGenerationLoss || decision.Detail != "opus 256k re-encoded to aac 256k" {
+		t.Fatalf("expected explicit lossy transcode, got %+v", decision)
+	}
+}
+
+func TestDecidePromoteActionSkipsSuspectQualitySources(t *testing.T) {
+	opts := promoteFreeDLOptions{TargetFormat: promoteTargetAuto, AACBitrate: "256k", VerifyQuality: true}
+	restore := probeSpectralFn
+	probeSpectralFn = func(ctx context.Context, path string) (engine.SpectralCeiling, error) {
+		if strings.HasSuffix(path, "fake.wav") {
+			return engine.SpectralCeiling{CeilingHz: 16100, Suspect: true}, nil
+		}
+		return engine.SpectralCeiling{CeilingHz: 20500}, nil
+	}
+	t.Cleanup(func() { probeSpectralFn = restore })
+
+	library := promoteMediaFile{Ext: ".m4a"}
+	fake := promoteMediaFile{Path: "/free/fake.wav", Ext: ".wav"}
+	probe, err := checkPromoteSourceQuality(context.Background(), opts, fake, promoteAudioProbe{Codec: "pcm_s16le"})
+	if err != nil || !probe.SuspectQuality {
+		t.Fatalf("expected suspect probe, got %+v err=%v", probe, err)
+	}
+	decision := decidePromoteAction(opts, promoteAssignment{Library: library, FreeDL: fake}, probe)
+	if decision.Mode != promoteActionSkip || !strings.HasPrefix(decision.Reason, "suspect-quality-spectral-ceiling-16.1kHz") || !strings.Contains(decision.Reason, "--allow-suspect-quality") {
+		t.Fatalf("expected suspect-quality skip, got %+v", decision)
+	}
+	opts.AllowSuspectQuality = true
+	if decision := decidePromoteAction(opts, promoteAssignment{Library: library, FreeDL: fake}, probe); decision.Mode != promoteActionEncodeAAC {
+		t.Fatalf("expected --allow-suspect-quality to promote, got %+v", decision)
+	}
+
+	// A sync-time check leaves a marker in the comment tag, which counts
+	// without --verify-quality.
+	opts = promoteFreeDLOptions{TargetFormat: promoteTargetAuto, AACBitrate: "256k"}
+	tagged := promoteMediaFile{Path: "/free/real.wav", Ext: ".wav", Comment: "https://soundcloud.com/a/b " + engine.SuspectQualityMarker + " ceiling=16.1kHz"}
+	probe, _ = checkPromoteSourceQuality(context.Background(), opts, tagged, promoteAudioProbe{Codec: "pcm_s16le"})
+	if decision := decidePromoteAction(opts, promoteAssignment{Library: library, FreeDL: tagged}, probe); !strings.HasPrefix(decision.Reason, "suspect-quality-tagged") {
+		t.Fatalf("expected tagged suspect skip, got %+v", decision)
+	}
+	genuine := promoteMediaFile{Path: "/free/real.wav", Ext: ".wav"}
+	opts.VerifyQuality = true
+	probe, _ = checkPromoteSourceQuality(context.Background(), opts, genuine, promoteAudioProbe{Codec: "pcm_s16le"})
+	if probe.SuspectQuality {
+		t.Fatalf("expected genuine source to pass, got %+v", probe)
+	}
+}
+
 func TestNormalizePromoteURLKey(t *testing.T) {
 	got := normalizePromoteURLKey("https://soundcloud.com/PICHI/BOFUNK?utm_source=test#frag")
 	if got != "https://soundcloud.com/PICHI/BOFUNK" {
@@ -117,6 +213,66 @@ func TestNormalizePromoteURLKey(t *testing.T) {
 	}
 }
 
+func TestPromoteMetadataArgsMergesTagsAndPicksLargerArtwork(t *testing.T) {
+	policy, err := parsePromoteTagPolicy("prefer-library", []string{"genre=merge", "Comment=prefer-source"}, "")
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	library := &promoteMetadata{
+		Tags: map[string]string{
+			"title":   "Bo Funk",
+			"artist":  "PICHI",
+			"genre":   "House",
+			"comment": "library note",
+			"encoder": "Lavf58",
+		},
+		Artwork: &promoteArtwork{Stream: 1, Width: 500, Height: 500},
+	}
+	source := &promoteMetadata{
+		Tags: map[string]string{
+			"title":   "Bo Funk (Original Mix)",
+			"artist":  "PICHI",
+			"genre":   "house / Tech House",
+			"comment": "Free DL via hypeddit",
+			"bpm":     "126",
+			"encoder": "Lavf60",
+		},
+		Artwork: &promoteArtwork{Stream: 2, Width: 1400, Height: 1400},
+	}
+
+	got := strings.Join(promoteMetadataArgs(policy, source, library, true), " ")
+	want := "-map_metadata 1 -metadata bpm=126 -metadata comment=Free DL via hypeddit -metadata genre=House; Tech House -map 0:2 -c:v copy"
+	if got != want {
+		t.Fatalf("unexpected metadata args:\n got: %s\nwant: %s", got, want)
+	}
+
+	policy.Artwork = promoteTagPreferLibrary
+	if got := strings.Join(promoteMetadataArgs(policy, source, library, true), " "); !strings.HasSuffix(got, "-map 1:1 -c:v copy") {
+		t.Fatalf("expected prefer-library artwork from the library, got: %s", got)
+	}
+	if got := strings.Join(promoteMetadataArgs(policy, source, library, false), " "); strings.Contains(got, "-c:v") {
+		t.Fatalf("expected no artwork map for containers without cover art, got: %s", got)
+	}
+	if got := strings.Join(promoteMetadataArgs(policy, nil, library, true), " "); got != "-map_metadata 1 -map 1:v? -c:v copy" {
+		t.Fatalf("expected library metadata when a probe failed, got: %s", got)
+	}
+
+	for _, tc := range []struct {
+		policy  string
+		fields  []string
+		artwork string
+	}{
+		{policy: "newest"},
+		{fields: []string{"genre"}},
+		{fields: []string{"genre=longest"}},
+		{artwork: "merge"},
+	} {
+		if _, err := parsePromoteTagPolicy(tc.policy, tc.fields, tc.artwork); err == nil {
+			t.Fatalf("expected %+v to be rejected", tc)
+		}
+	}
+}
+
 func TestPromoteFreeDLPreviewModePlansWithoutWriting(t *testing.T) {
 	tmp := t.TempDir()
 	freeDir := filepath.Join(tmp, "free")
@@ -601,3 +757,343 @@ func TestPromoteFreeDLApplyInPlaceAutoReplacesLibraryPath(t *testing.T) {
 		t.Fatalf("expected in-place file replacement, got %q", string(payload))
 	}
 }
+
+func TestBuildPromoteAssignmentsUsesFingerprintsToResolveAmbiguity(t *testing.T) {
+	recording := make([]uint32, 200)
+	other := make([]uint32, 200)
+	for i := range recording {
+		recording[i] = uint32(i) * 2654435761
+		other[i] = ^recording[i] ^ uint32(i)<<7
+	}
+	// The free-DL capture starts 3 items later and has a few flipped bits
+	// from the lossy encode.
+	captured := append([]uint32{0x1, 0x2, 0x3}, recording...)
+	for i := 10; i < len(captured); i += 9 {
+		captured[i] ^= 0x5
+	}
+	library := []promoteMediaFile{
+		{Rel: "Pichi - Bo Funk.m4a", Key: "pichi bo funk", Tokens: []string{"pichi", "bo", "funk"}, Fingerprint: promoteFingerprint{Duration: 201, Values: recording}},
+	}
+	free := []promoteMediaFile{
+		{Rel: "Pichi - Bo Funk (Remastered).wav", Key: "pichi bo funk remastered", Tokens: []string{"pichi", "bo", "funk", "remastered"}, Fingerprint: promoteFingerprint{Duration: 203, Values: captured}},
+		{Rel: "Pichi - Bo Funk (VIP).wav", Key: "pichi bo funk vip", Tokens: []string{"pichi", "bo", "funk", "vip"}, Fingerprint: promoteFingerprint{Duration: 198, Values: other}},
+	}
+
+	withoutFingerprints := make([]promoteMediaFile, len(free))
+	for i, file := range free {
+		file.Fingerprint = promoteFingerprint{}
+		withoutFingerprints[i] = file
+	}
+	libraryWithout := []promoteMediaFile{library[0]}
+	libraryWithout[0].Fingerprint = promoteFingerprint{}
+	if plan := buildPromoteAssignments(libraryWithout, withoutFingerprints, 72, 8); len(plan.Ambiguous) != 1 {
+		t.Fatalf("expected metadata-only match to be ambiguous, got %+v", plan)
+	}
+
+	plan := buildPromoteAssignments(library, free, 72, 8)
+	if len(plan.Ambiguous) != 0 || len(plan.Assignments) != 1 {
+		t.Fatalf("expected fingerprints to resolve the match, got %+v", plan)
+	}
+	if got := plan.Assignments[0]; got.FreeDL.Rel != "Pichi - Bo Funk (Remastered).wav" || got.Score != promoteFingerprintMatchScore {
+		t.Fatalf("expected identical audio to win with score %d, got %s score=%d", promoteFingerprintMatchScore, got.FreeDL.Rel, got.Score)
+	}
+}
+
+func TestParsePromoteFingerprintAcceptsSignedAndUnsignedItems(t *testing.T) {
+	fingerprint, err := parsePromoteFingerprint([]byte(`{"duration": 215.5, "fingerprint": [4294967295, -1, 7]}`))
+	if err != nil {
+		t.Fatalf("parse fingerprint: %v", err)
+	}
+	if fingerprint.Duration != 215.5 || len(fingerprint.Values) != 3 {
+		t.Fatalf("unexpected fingerprint: %+v", fingerprint)
+	}
+	if fingerprint.Values[0] != 0xffffffff || fingerprint.Values[1] != 0xffffffff || fingerprint.Values[2] != 7 {
+		t.Fatalf("unexpected fingerprint items: %v", fingerprint.Values)
+	}
+}
+
+func TestPromoteFreeDLOutputJSONReportsPlanAndSkips(t *testing.T) {
+	tmp := t.TempDir()
+	freeDir := filepath.Join(tmp, "free")
+	libraryDir := filepath.Join(tmp, "library")
+	if err := os.MkdirAll(freeDir, 0o755); err != nil {
+		t.Fatalf("mkdir free: %v", err)
+	}
+	if err := os.MkdirAll(libraryDir, 0o755); err != nil {
+		t.Fatalf("mkdir library: %v", err)
+	}
+	for path, payload := range map[string]string{
+		filepath.Join(freeDir, "PICHI - BO FUNK [FREE DL].wav"): "source",
+		filepath.Join(freeDir, "FUJI - NIGHT.opus"):             "source",
+		filepath.Join(libraryDir, "PICHI - BO FUNK.m4a"):        "target",
+		filepath.Join(libraryDir, "FUJI - NIGHT.m4a"):           "target",
+	} {
+		if err := os.WriteFile(path, []byte(payload), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	origLookPath := lookPathFn
+	origProbe := probeAudioFn
+	origRun := runPromoteFFmpeg
+	lookPathFn = func(bin string) (string, error) { return "/usr/bin/" + bin, nil }
+	probeAudioFn = func(ctx context.Context, path string) (promoteAudioProbe, error) {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".wav":
+			return promoteAudioProbe{Codec: "pcm_s16le"}, nil
+		case ".opus":
+			return promoteAudioProbe{Codec: "opus", EffectiveBitrate: 256000}, nil
+		default:
+			return promoteAudioProbe{Codec: "aac", Bitrate: 192000}, nil
+		}
+	}
+	runPromoteFFmpeg = func(ctx context.Context, opts promoteFreeDLOptions, assignment promoteAssignment, outputPath string, decision promoteDecision) error {
+		t.Fatalf("did not expect ffmpeg invocation in preview mode")
+		return nil
+	}
+	t.Cleanup(func() {
+		lookPathFn = origLookPath
+		probeAudioFn = origProbe
+		runPromoteFFmpeg = origRun
+	})
+
+	stdout := &bytes.Buffer{}
+	app := &AppContext{
+		Build: BuildInfo{Version: "test"},
+		IO:    IOStreams{In: strings.NewReader(""), Out: stdout, ErrOut: &bytes.Buffer{}},
+	}
+	root := newRootCommand(app)
+	root.SetArgs([]string{
+		"promote-freedl",
+		"--free-dl-dir", freeDir,
+		"--library-dir", libraryDir,
+		"--probe-timeout", "20ms",
+		"--output", "json",
+	})
+	if err := root.Execute(); err != nil {
+		t.Fatalf("promote-freedl --output json failed: %v", err)
+	}
+
+	var report promoteReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("expected a single JSON report, got %q: %v", stdout.String(), err)
+	}
+	if report.Mode != "preview" || report.FreeDLFiles != 2 || report.LibraryFiles != 2 {
+		t.Fatalf("unexpected report header: %+v", report)
+	}
+	byLibrary := map[string]promoteReportAssignment{}
+	for _, assignment := range report.Assignments {
+		byLibrary[assignment.Library] = assignment
+	}
+	planned := byLibrary["PICHI - BO FUNK.m4a"]
+	if planned.Status != "planned" || planned.FreeDL != "PICHI - BO FUNK [FREE DL].wav" || planned.Action != string(promoteActionEncodeAAC) || planned.Score < 72 || planned.OutputPath == "" {
+		t.Fatalf("unexpected planned assignment: %+v", planned)
+	}
+	skipped := byLibrary["FUJI - NIGHT.m4a"]
+	if skipped.Status != "skipped" || skipped.Reason == "" {
+		t.Fatalf("expected lossy transcode skip with a reason, got %+v", skipped)
+	}
+	if report.Summary.Planned != 1 || report.Summary.Skipped != 1 || report.Summary.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v", report.Summary)
+	}
+}
+
+func TestPromoteFreeDLInteractiveReviewAcceptsRejectsAndResolvesAmbiguity(t *testing.T) {
+	tmp := t.TempDir()
+	freeDir := filepath.Join(tmp, "free")
+	libraryDir := filepath.Join(tmp, "library")
+	if err := os.MkdirAll(freeDir, 0o755); err != nil {
+		t.Fatalf("mkdir free: %v", err)
+	}
+	if err := os.MkdirAll(libraryDir, 0o755); err != nil {
+		t.Fatalf("mkdir library: %v", err)
+	}
+	for path, payload := range map[string]string{
+		filepath.Join(freeDir, "PICHI - BO FUNK [FREE DL].wav"):            "source",
+		filepath.Join(freeDir, "PICHI - BO FUNK (Extended) [FREE DL].wav"): "source",
+		filepath.Join(freeDir, "FUJI - NIGHT [FREE DL].wav"):               "source",
+		filepath.Join(libraryDir, "PICHI - BO FUNK.m4a"):                   "target",
+		filepath.Join(libraryDir, "FUJI - NIGHT.m4a"):                      "target",
+	} {
+		if err := os.WriteFile(path, []byte(payload), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	origLookPath := lookPathFn
+	origProbe := probeAudioFn
+	origRun := runPromoteFFmpeg
+	lookPathFn = func(bin string) (string, error) { return "/usr/bin/" + bin, nil }
+	probeAudioFn = func(ctx context.Context, path string) (promoteAudioProbe, error) {
+		if strings.EqualFold(filepath.Ext(path), ".wav") {
+			return promoteAudioProbe{Codec: "pcm_s16le"}, nil
+		}
+		return promoteAudioProbe{Codec: "aac", Bitrate: 192000}, nil
+	}
+	runPromoteFFmpeg = func(ctx context.Context, opts promoteFreeDLOptions, assignment promoteAssignment, outputPath string, decision promoteDecision) error {
+		t.Fatalf("did not expect ffmpeg invocation in preview mode")
+		return nil
+	}
+	t.Cleanup(func() {
+		lookPathFn = origLookPath
+		probeAudioFn = origProbe
+		runPromoteFFmpeg = origRun
+	})
+
+	run := func(answers string) string {
+		t.Helper()
+		stdout := &bytes.Buffer{}
+		app := &AppContext{
+			Build: BuildInfo{Version: "test"},
+			IO:    IOStreams{In: strings.NewReader(answers), Out: stdout, ErrOut: &bytes.Buffer{}},
+		}
+		root := newRootCommand(app)
+		root.SetArgs([]string{
+			"promote-freedl",
+			"--free-dl-dir", freeDir,
+			"--library-dir", libraryDir,
+			"--probe-timeout", "20ms",
+			"--min-match-score", "40",
+			"--ambiguity-gap", "100",
+			"--interactive",
+		})
+		if err := root.Execute(); err != nil {
+			t.Fatalf("promote-freedl --interactive failed: %v\n%s", err, stdout.String())
+		}
+		return stdout.String()
+	}
+
+	// An unrecognized answer is asked again; 2 picks the second candidate
+	// for the ambiguous match and a accepts the planned replacement.
+	out := run("x\n2\na\n")
+	for _, want := range []string{
+		`unrecognized answer "x"`,
+		"[plan] PICHI - BO FUNK.m4a <= PICHI - BO FUNK (Extended) [FREE DL].wav",
+		"[plan] FUJI - NIGHT.m4a <= FUJI - NIGHT [FREE DL].wav",
+		"summary planned=2 replaced=0 skipped=0",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in review output, got:\n%s", want, out)
+		}
+	}
+
+	// Quitting leaves the ambiguous match skipped and rejects the rest.
+	out = run("q\n")
+	for _, want := range []string{
+		"[skip] PICHI - BO FUNK.m4a (ambiguous-match",
+		"[skip] FUJI - NIGHT.m4a <= FUJI - NIGHT [FREE DL].wav (rejected-in-review)",
+		"summary planned=0 replaced=0 skipped=2",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q after quitting review, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPromoteFreeDLRollbackRestoresInPlaceReplacements(t *testing.T) {
+	tmp := t.TempDir()
+	freeDir := filepath.Join(tmp, "free")
+	libraryDir := filepath.Join(tmp, "library")
+	if err := os.MkdirAll(freeDir, 0o755); err != nil {
+		t.Fatalf("mkdir free: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(libraryDir, "sub"), 0o755); err != nil {
+		t.Fatalf("mkdir library: %v", err)
+	}
+	for path, payload := range map[string]string{
+		filepath.Join(freeDir, "track one.wav"):           "source",
+		filepath.Join(freeDir, "track two.wav"):           "source",
+		filepath.Join(libraryDir, "track one.m4a"):        "original one",
+		filepath.Join(libraryDir, "sub", "track two.m4a"): "original two",
+	} {
+		if err := os.WriteFile(path, []byte(payload), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	origLookPath := lookPathFn
+	origProbe := probeAudioFn
+	origTags := probeTagsFn
+	origRun := runPromoteFFmpeg
+	lookPathFn = func(bin string) (string, error) { return "/usr/bin/" + bin, nil }
+	probeAudioFn = func(ctx context.Context, path string) (promoteAudioProbe, error) {
+		if strings.EqualFold(filepath.Ext(path), ".wav") {
+			return promoteAudioProbe{Codec: "pcm_s16le"}, nil
+		}
+		return promoteAudioProbe{Codec: "aac", Bitrate: 192000}, nil
+	}
+	probeTagsFn = func(ctx context.Context, path string) (promoteTagProbe, error) {
+		return promoteTagProbe{}, nil
+	}
+	runPromoteFFmpeg = func(ctx context.Context, opts promoteFreeDLOptions, assignment promoteAssignment, outputPath string, decision promoteDecision) error {
+		return os.WriteFile(outputPath, []byte("upgraded"), 0o644)
+	}
+	t.Cleanup(func() {
+		lookPathFn = origLookPath
+		probeAudioFn = origProbe
+		probeTagsFn = origTags
+		runPromoteFFmpeg = origRun
+	})
+
+	run := func(args ...string) string {
+		t.Helper()
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		app := &AppContext{
+			Build: BuildInfo{Version: "test"},
+			IO:    IOStreams{In: strings.NewReader(""), Out: stdout, ErrOut: stderr},
+		}
+		root := newRootCommand(app)
+		root.SetArgs(append([]string{"promote-freedl"}, args...))
+		if err := root.Execute(); err != nil {
+			t.Fatalf("promote-freedl %v failed: %v\n%s%s", args, err, stdout.String(), stderr.String())
+		}
+		return stdout.String()
+	}
+
+	out := run("--free-dl-dir", freeDir, "--library-dir", libraryDir, "--target-format", "auto", "--apply", "--probe-timeout", "20ms")
+	match := regexp.MustCompile(`undo journal run_id=(\S+)`).FindStringSubmatch(out)
+	if match == nil {
+		t.Fatalf("expected undo journal run id in output, got:\n%s", out)
+	}
+	runID := match[1]
+	for _, rel := range []string{"track one.m4a", filepath.Join("sub", "track two.m4a")} {
+		payload, err := os.ReadFile(filepath.Join(libraryDir, rel))
+		if err != nil || string(payload) != "upgraded" {
+			t.Fatalf("expected %s replaced, got %q (%v)", rel, payload, err)
+		}
+	}
+
+	// A second run must not index the backups as library files.
+	out = run("--free-dl-dir", freeDir, "--library-dir", libraryDir, "--probe-timeout", "20ms")
+	if !strings.Contains(out, "indexed library files=2") {
+		t.Fatalf("expected backups to be left out of the library index, got:\n%s", out)
+	}
+
+	// Edited after the run: rollback leaves it alone.
+	if err := os.WriteFile(filepath.Join(libraryDir, "track one.m4a"), []byte("retagged"), 0o644); err != nil {
+		t.Fatalf("edit replaced file: %v", err)
+	}
+
+	out = run("--library-dir", libraryDir, "--rollback", runID)
+	if !strings.Contains(out, "[plan] restore sub/track two.m4a") || !strings.Contains(out, "rollback restorable=1 skipped=1") {
+		t.Fatalf("unexpected rollback preview:\n%s", out)
+	}
+	if payload, _ := os.ReadFile(filepath.Join(libraryDir, "sub", "track two.m4a")); string(payload) != "upgraded" {
+		t.Fatalf("expected preview to leave files untouched, got %q", payload)
+	}
+
+	out = run("--library-dir", libraryDir, "--rollback", runID, "--apply")
+	if !strings.Contains(out, "[done] restored sub/track two.m4a") || !strings.Contains(out, "[skip] track one.m4a (modified since run") {
+		t.Fatalf("unexpected rollback output:\n%s", out)
+	}
+	if payload, _ := os.ReadFile(filepath.Join(libraryDir, "sub", "track two.m4a")); string(payload) != "original two" {
+		t.Fatalf("expected original restored, got %q", payload)
+	}
+	if payload, _ := os.ReadFile(filepath.Join(libraryDir, "track one.m4a")); string(payload) != "retagged" {
+		t.Fatalf("expected modified file kept, got %q", payload)
+	}
+	if _, err := os.Stat(filepath.Join(libraryDir, promoteBackupDirName, runID, promoteJournalFileName)); err != nil {
+		t.Fatalf("expected journal kept while an entry is unrestored: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/query"
+	"github.com/spf13/cobra"
+)
+
+func newQueryCommand(app *AppContext) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "query '<expression>'",
+		Short: "Filter and project sources, tracks, and run history",
+		Long: "Run a query over local state: `<sources|tracks|runs> [where <condition>] [select <fields>] [order by <field> [asc|desc]] [limit <n>]`. " +
+			"Conditions combine comparisons (==, !=, <, <=, >, >=) with and/or/not, and the functions contains(field, \"text\"), " +
+			"starts_with(field, \"text\"), and <name>_after(\"date\") / <name>_before(\"date\") for <name>_at fields. " +
+			"Output is JSON, or CSV with --format csv. Nothing remote is contacted.",
+		Example: "  udl query 'tracks where source == \"label-x\" and added_after(\"2025-01-01\") select id, title'\n" +
+			"  udl query 'runs where status == \"failed\" order by started_at desc limit 5' --format csv",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format = strings.ToLower(strings.TrimSpace(format))
+			if format != "json" && format != "csv" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --format %q (expected json or csv)", format))
+			}
+			if app.Opts.JSON && format == "csv" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--json cannot be combined with --format csv"))
+			}
+			q, err := query.Parse(args[0], engine.QuerySchema)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid query: %w", err))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			result, err := (workflows.QueryUseCase{}).Run(cfg, q)
+			if err != nil {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+			if format == "csv" {
+				if err := writeQueryCSV(app, result); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			if err := json.NewEncoder(app.IO.Out).Encode(result); err != nil {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "json", "Output format: json or csv")
+	return cmd
+}
+
+func writeQueryCSV(app *AppContext, result query.Result) error {
+	writer := csv.NewWriter(app.IO.Out)
+	if err := writer.Write(result.Columns); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		values := make([]string, 0, len(result.Columns))
+		for _, column := range result.Columns {
+			values = append(values, formatQueryCSVValue(row[column]))
+		}
+		if err := writer.Write(values); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatQueryCSVValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Local().Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
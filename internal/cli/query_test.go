@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQueryCommandFiltersTracksAndRuns(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	musicDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, musicDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: label-x\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + musicDir + "\n" +
+		"    url: https://soundcloud.com/label-x\n    state_file: label-x.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	state := "soundcloud 111 Night Drive.mp3\nsoundcloud 222 Old Tune.mp3\n"
+	if err := os.WriteFile(filepath.Join(stateDir, "label-x.sync.scdl"), []byte(state), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(musicDir, "Night Drive.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+	journal := `{"run_id":"20240102T103000Z","started_at":"2024-01-02T10:30:00Z","finished_at":"2024-01-02T10:31:00Z","duration_ms":60000,"result":{"attempted":1,"succeeded":1},"sources":[{"source_id":"label-x","status":"finished","duration_ms":60000,"downloaded_track_ids":["222"]}]}
+{"run_id":"20250302T103000Z","started_at":"2025-03-02T10:30:00Z","finished_at":"2025-03-02T10:31:00Z","duration_ms":60000,"result":{"attempted":1,"failed":1},"sources":[{"source_id":"label-x","status":"failed","class":"auth","message":"token expired","duration_ms":1000,"downloaded_track_ids":["111"]}]}
+`
+	if err := os.WriteFile(filepath.Join(stateDir, "history.jsonl"), []byte(journal), 0o644); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newQueryCommand(app)
+	cmd.SetArgs([]string{`tracks where source == "label-x" and added_after("2025-01-01") select id, title, present`})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("query tracks: %v", err)
+	}
+	decoded := struct {
+		Columns []string         `json:"columns"`
+		Rows    []map[string]any `json:"rows"`
+	}{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json: %v (%s)", err, out.String())
+	}
+	if strings.Join(decoded.Columns, ",") != "id,title,present" || len(decoded.Rows) != 1 {
+		t.Fatalf("unexpected result: %s", out.String())
+	}
+	if row := decoded.Rows[0]; row["id"] != "111" || row["title"] != "Night Drive" || row["present"] != true {
+		t.Fatalf("unexpected row: %v", row)
+	}
+
+	out.Reset()
+	cmd = newQueryCommand(app)
+	cmd.SetArgs([]string{`runs where status == "failed" select run_id, class, message`, "--format", "csv"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("query runs: %v", err)
+	}
+	if got, want := out.String(), "run_id,class,message\n20250302T103000Z,auth,token expired\n"; got != want {
+		t.Fatalf("expected csv %q, got %q", want, got)
+	}
+
+	cmd = newQueryCommand(app)
+	cmd.SetArgs([]string{`tracks where artist == "x"`})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+}
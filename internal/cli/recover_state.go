@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type recoverStateOptions struct {
+	SourceIDs    []string
+	Rebuild      bool
+	ProbeTimeout time.Duration
+}
+
+func newRecoverStateCommand(app *AppContext) *cobra.Command {
+	opts := recoverStateOptions{ProbeTimeout: 10 * time.Second}
+
+	cmd := &cobra.Command{
+		Use:   "recover-state",
+		Short: "Quarantine corrupted state files and salvage their readable entries",
+		Long: "Check each source's state file line by line, report every line a sync cannot parse, and rewrite the " +
+			"file with the salvageable lines after copying the original to <state_file>.corrupt-<timestamp>. " +
+			"Use --rebuild to restore entries for local media whose tags identify tracks the state no longer knows.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			recovery := engine.StateRecoveryOptions{
+				Rebuild:      opts.Rebuild,
+				ProbeTags:    opts.Rebuild,
+				ProbeTimeout: opts.ProbeTimeout,
+				DryRun:       app.Opts.DryRun,
+			}
+			if recovery.ProbeTags {
+				if _, err := verifyLookPathFn("ffprobe"); err != nil {
+					fmt.Fprintln(app.IO.ErrOut, "recover-state: ffprobe not found; skipping tag-based rebuild (install ffmpeg)")
+					recovery.ProbeTags = false
+				}
+			}
+
+			reports, err := engine.RecoverStateFiles(cmd.Context(), cfg, opts.SourceIDs, recovery)
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			dropped, rebuilt, unresolved, failed := 0, 0, 0, 0
+			for _, report := range reports {
+				dropped += len(report.Dropped)
+				rebuilt += len(report.Rebuilt)
+				unresolved += report.Unresolved
+				if len(report.Errors) > 0 {
+					failed++
+				}
+			}
+
+			if app.Opts.JSON {
+				encoder := json.NewEncoder(app.IO.Out)
+				if err := encoder.Encode(map[string]any{"sources": reports}); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				for _, report := range reports {
+					printStateRecoveryReport(app, report)
+				}
+				mode := "apply"
+				if app.Opts.DryRun {
+					mode = "preview"
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"recover-state: summary sources=%d dropped=%d rebuilt=%d unresolved=%d mode=%s\n",
+					len(reports),
+					dropped,
+					rebuilt,
+					unresolved,
+					mode,
+				)
+			}
+
+			if failed > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("recover-state failed for %d source(s)", failed))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.SourceIDs, "source", nil, "Recover only selected source id (repeatable)")
+	cmd.Flags().BoolVar(&opts.Rebuild, "rebuild", false, "Restore entries for untracked local media from their tags (needs ffprobe)")
+	cmd.Flags().DurationVar(&opts.ProbeTimeout, "probe-timeout", opts.ProbeTimeout, "Per-file ffprobe timeout")
+	return cmd
+}
+
+func printStateRecoveryReport(app *AppContext, report engine.StateRecoveryReport) {
+	fmt.Fprintf(
+		app.IO.Out,
+		"[%s] state=%s lines=%d kept=%d dropped=%d rebuilt=%d\n",
+		report.SourceID,
+		report.StateFile,
+		report.Lines,
+		report.Kept,
+		len(report.Dropped),
+		len(report.Rebuilt),
+	)
+	for _, line := range report.Dropped {
+		fmt.Fprintf(app.IO.Out, "  [drop] line %d (%s) %s\n", line.Line, line.Reason, line.Content)
+	}
+	for _, entry := range report.Rebuilt {
+		fmt.Fprintf(app.IO.Out, "  [rebuild] %s -> %s (%s)\n", entry.TrackID, entry.Path, entry.From)
+	}
+	if report.Unresolved > 0 {
+		fmt.Fprintf(app.IO.Out, "  %d archived track(s) have no local match; the archive still skips them\n", report.Unresolved)
+	}
+	if report.QuarantinePath != "" {
+		fmt.Fprintf(app.IO.Out, "  original quarantined to %s\n", report.QuarantinePath)
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(app.IO.ErrOut, "[%s] error: %s\n", report.SourceID, problem)
+	}
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecoverStateCommandReportsDroppedLines(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	musicDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, musicDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "sc.sync.scdl")
+	original := "soundcloud 1 one.mp3\n\x00\x00\x00\n"
+	if err := os.WriteFile(statePath, []byte(original), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + musicDir + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath, DryRun: true}}
+	cmd := newRecoverStateCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("recover-state --dry-run: %v", err)
+	}
+	if !strings.Contains(out.String(), `[drop] line 2 (nul-bytes) "\x00\x00\x00"`) ||
+		!strings.Contains(out.String(), "dropped=1 rebuilt=0 unresolved=0 mode=preview") {
+		t.Fatalf("unexpected preview output: %q", out.String())
+	}
+	if raw, _ := os.ReadFile(statePath); string(raw) != original {
+		t.Fatalf("preview must not rewrite state: %q", string(raw))
+	}
+
+	out.Reset()
+	app.Opts.DryRun = false
+	cmd = newRecoverStateCommand(app)
+	cmd.SetArgs([]string{"--source", "sc"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("recover-state: %v", err)
+	}
+	if !strings.Contains(out.String(), "original quarantined to "+statePath+".corrupt-") {
+		t.Fatalf("expected quarantine line: %q", out.String())
+	}
+	if raw, _ := os.ReadFile(statePath); string(raw) != "soundcloud 1 one.mp3\n" {
+		t.Fatalf("unexpected salvaged state: %q", string(raw))
+	}
+}
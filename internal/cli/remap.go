@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type remapOptions struct {
+	From         string
+	To           string
+	Apply        bool
+	AllowMissing bool
+}
+
+type remapConfigChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+func newRemapCommand(app *AppContext) *cobra.Command {
+	opts := remapOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "remap",
+		Short: "Rewrite config, state, and archive paths after moving a library",
+		Long: "Preview or apply a consistent path rewrite from --from to --to across config paths " +
+			"(state_dir, target_dir, state_file, archive_file), SoundCloud state entries, and per-source archives. " +
+			"Without --from/--to, reports sources whose state entries point at a missing root.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			if strings.TrimSpace(opts.From) == "" && strings.TrimSpace(opts.To) == "" {
+				roots := engine.DetectStaleStateRoots(cfg)
+				if len(roots) == 0 {
+					fmt.Fprintln(app.IO.Out, "remap: no moved state roots detected")
+					return nil
+				}
+				for _, root := range roots {
+					fmt.Fprintf(
+						app.IO.Out,
+						"remap: source %s has %d state entries under missing root %s; run `udl remap --from %s --to <new root>`\n",
+						root.SourceID,
+						root.Entries,
+						root.Root,
+						root.Root,
+					)
+				}
+				return nil
+			}
+			if strings.TrimSpace(opts.From) == "" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--from is required"))
+			}
+			if strings.TrimSpace(opts.To) == "" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--to is required"))
+			}
+			from, err := config.ExpandPath(opts.From)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("resolve --from: %w", err))
+			}
+			to, err := config.ExpandPath(opts.To)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("resolve --to: %w", err))
+			}
+			if !filepath.IsAbs(from) || !filepath.IsAbs(to) {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--from and --to must resolve to absolute paths"))
+			}
+			if from == to {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--from and --to must differ"))
+			}
+
+			previewMode := app.Opts.DryRun || !opts.Apply
+			if !opts.Apply && !app.Opts.DryRun {
+				fmt.Fprintln(app.IO.Out, "remap: preview mode (set --apply to write changes)")
+			}
+
+			configPath, _, err := tuiResolveConfigEditorTargetPath(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			var fileCfg *config.Config
+			fileChanges := []remapConfigChange{}
+			if info, statErr := os.Stat(configPath); statErr == nil && !info.IsDir() {
+				loaded, loadErr := config.LoadSingleFile(configPath)
+				if loadErr != nil {
+					return withExitCode(exitcode.InvalidConfig, loadErr)
+				}
+				fileChanges = remapConfigPaths(&loaded, from, to)
+				fileCfg = &loaded
+			} else if statErr != nil && !errors.Is(statErr, os.ErrNotExist) {
+				return withExitCode(exitcode.InvalidConfig, fmt.Errorf("inspect config file %s: %w", configPath, statErr))
+			}
+
+			// Remap the effective config too so state files are located at their
+			// new home even when paths came from env overrides or layered files.
+			remapped := cfg
+			remapped.Sources = append([]config.Source{}, cfg.Sources...)
+			effectiveChanges := remapConfigPaths(&remapped, from, to)
+			for _, change := range fileChanges {
+				fmt.Fprintf(app.IO.Out, "[plan] config %s: %s -> %s\n", change.Field, change.From, change.To)
+			}
+			if fileCfg == nil && len(effectiveChanges) > 0 {
+				fmt.Fprintf(app.IO.ErrOut, "remap: no config file at %s; %d effective config path(s) must be updated where they are set\n", configPath, len(effectiveChanges))
+			}
+
+			missing := remapMissingDirs(effectiveChanges)
+			stateFiles, err := engine.PlanStateRemap(remapped, from, to)
+			if err != nil {
+				return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("plan state remap: %w", err))
+			}
+			entries := 0
+			for _, file := range stateFiles {
+				if file.Rewritten == 0 {
+					continue
+				}
+				entries += file.Rewritten
+				fmt.Fprintf(
+					app.IO.Out,
+					"[plan] source %s %s %s: rewrite=%d missing=%d\n",
+					file.SourceID,
+					file.Kind,
+					file.Path,
+					file.Rewritten,
+					len(file.Missing),
+				)
+				missing = append(missing, file.Missing...)
+			}
+			for i, path := range missing {
+				if !app.Opts.Verbose && i >= 10 {
+					fmt.Fprintf(app.IO.ErrOut, "[missing] ... %d more (use --verbose to list all)\n", len(missing)-i)
+					break
+				}
+				fmt.Fprintf(app.IO.ErrOut, "[missing] %s\n", path)
+			}
+
+			fmt.Fprintf(
+				app.IO.Out,
+				"remap: summary config_fields=%d state_entries=%d missing=%d mode=%s\n",
+				len(fileChanges),
+				entries,
+				len(missing),
+				map[bool]string{true: "preview", false: "apply"}[previewMode],
+			)
+			if previewMode {
+				return nil
+			}
+			if len(missing) > 0 && !opts.AllowMissing {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("remap: %d remapped path(s) do not exist; check --to or pass --allow-missing", len(missing)))
+			}
+
+			if err := engine.ApplyStateRemap(cmd.Context(), remapped.Defaults.StateDir, stateFiles); err != nil {
+				return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("write remapped state: %w", err))
+			}
+			if fileCfg != nil && len(fileChanges) > 0 {
+				if _, err := config.SaveSingleFile(configPath, *fileCfg); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("write remapped config: %w", err))
+				}
+				fmt.Fprintf(app.IO.Out, "remap: updated %s\n", configPath)
+			}
+			fmt.Fprintln(app.IO.Out, "remap: done")
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.From, "from", "", "Old root path to replace")
+	cmd.Flags().StringVar(&opts.To, "to", "", "New root path")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "Apply changes (default is preview-only)")
+	cmd.Flags().BoolVar(&opts.AllowMissing, "allow-missing", false, "Apply even when remapped files or directories do not exist")
+
+	return cmd
+}
+
+// remapConfigPaths rewrites path-valued config fields in place. Relative
+// state_file/archive_file values follow state_dir and are left untouched.
+func remapConfigPaths(cfg *config.Config, from string, to string) []remapConfigChange {
+	changes := []remapConfigChange{}
+	rewrite := func(field string, value *string) {
+		expanded, err := config.ExpandPath(*value)
+		if err != nil || !filepath.IsAbs(expanded) {
+			return
+		}
+		next, ok := engine.RemapPathPrefix(expanded, from, to)
+		if !ok {
+			return
+		}
+		changes = append(changes, remapConfigChange{Field: field, From: *value, To: next})
+		*value = next
+	}
+
+	rewrite("defaults.state_dir", &cfg.Defaults.StateDir)
+	rewrite("defaults.archive_file", &cfg.Defaults.ArchiveFile)
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		rewrite(fmt.Sprintf("sources[%s].target_dir", source.ID), &source.TargetDir)
+		rewrite(fmt.Sprintf("sources[%s].state_file", source.ID), &source.StateFile)
+	}
+	return changes
+}
+
+func remapMissingDirs(changes []remapConfigChange) []string {
+	missing := []string{}
+	for _, change := range changes {
+		if !strings.HasSuffix(change.Field, "state_dir") && !strings.HasSuffix(change.Field, "target_dir") {
+			continue
+		}
+		if info, err := os.Stat(change.To); err != nil || !info.IsDir() {
+			missing = append(missing, change.To)
+		}
+	}
+	return missing
+}
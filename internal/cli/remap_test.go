@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestRemapConfigPathsRewritesAbsolutePaths(t *testing.T) {
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: "/old/state", ArchiveFile: "archive.txt"},
+		Sources: []config.Source{
+			{ID: "sc", TargetDir: "/old/music/sc", StateFile: "sc.sync.scdl"},
+			{ID: "other", TargetDir: "/elsewhere/music"},
+		},
+	}
+	changes := remapConfigPaths(&cfg, "/old", "/new")
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 config changes, got %+v", changes)
+	}
+	if cfg.Defaults.StateDir != "/new/state" || cfg.Sources[0].TargetDir != "/new/music/sc" {
+		t.Fatalf("unexpected remapped config: %+v", cfg)
+	}
+	if cfg.Defaults.ArchiveFile != "archive.txt" || cfg.Sources[0].StateFile != "sc.sync.scdl" || cfg.Sources[1].TargetDir != "/elsewhere/music" {
+		t.Fatalf("expected relative and unrelated paths untouched, got %+v", cfg)
+	}
+}
+
+func TestRemapCommandRefusesMissingTargetsWithoutAllowMissing(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "udl.yaml")
+	newState := filepath.Join(tmp, "new", "state")
+	if err := os.MkdirAll(newState, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + filepath.Join(tmp, "old", "state") + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + filepath.Join(tmp, "old", "music") + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	errOut := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: errOut}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newRemapCommand(app)
+	cmd.SetArgs([]string{"--from", filepath.Join(tmp, "old"), "--to", filepath.Join(tmp, "new"), "--apply"})
+	cmd.SetOut(out)
+	cmd.SetErr(errOut)
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "do not exist") {
+		t.Fatalf("expected missing target error, got %v", err)
+	}
+	if !strings.Contains(errOut.String(), "[missing] "+filepath.Join(tmp, "new", "music")) {
+		t.Fatalf("expected missing target dir listed, got %q", errOut.String())
+	}
+	raw, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(raw), filepath.Join(tmp, "old", "music")) {
+		t.Fatalf("expected config untouched after refused apply, got %s", raw)
+	}
+
+	cmd = newRemapCommand(app)
+	cmd.SetArgs([]string{"--from", filepath.Join(tmp, "old"), "--to", filepath.Join(tmp, "new"), "--apply", "--allow-missing"})
+	cmd.SetOut(out)
+	cmd.SetErr(errOut)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("remap with --allow-missing: %v", err)
+	}
+	updated, err := config.LoadSingleFile(configPath)
+	if err != nil {
+		t.Fatalf("reload config: %v", err)
+	}
+	if updated.Sources[0].TargetDir != filepath.Join(tmp, "new", "music") || updated.Defaults.StateDir != newState {
+		t.Fatalf("expected remapped config, got %+v", updated)
+	}
+}
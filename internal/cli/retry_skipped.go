@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type retrySkippedOptions struct {
+	SourceIDs []string
+	TrackIDs  []string
+}
+
+func newRetrySkippedCommand(app *AppContext) *cobra.Command {
+	opts := retrySkippedOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "retry-skipped",
+		Short: "Forget remembered unavailable tracks so the next sync checks them again",
+		Long: "Clear the tracks sources with sync.skip_ttl_days remember as unavailable-on-deezer or " +
+			"no-free-download-link in <state_dir>/skipped-tracks.json, so the next sync plans and checks them " +
+			"again instead of waiting for the TTL. Use --dry-run to list what would be cleared.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			cleared, err := engine.ClearRememberedSkips(cfg, opts.SourceIDs, opts.TrackIDs, app.Opts.DryRun)
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			if app.Opts.JSON {
+				payload := map[string]any{"cleared": cleared, "dry_run": app.Opts.DryRun}
+				if err := json.NewEncoder(app.IO.Out).Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			for _, skip := range cleared {
+				label := skip.Label
+				if label == "" {
+					label = skip.TrackID
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"[%s] %s (%s) (%s) skipped %s\n",
+					skip.SourceID,
+					skip.TrackID,
+					label,
+					skip.Reason,
+					skip.At.Local().Format("2006-01-02 15:04"),
+				)
+			}
+			mode := "apply"
+			if app.Opts.DryRun {
+				mode = "preview"
+			}
+			fmt.Fprintf(app.IO.Out, "retry-skipped: summary cleared=%d mode=%s\n", len(cleared), mode)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.SourceIDs, "source", nil, "Clear only selected source id (repeatable)")
+	cmd.Flags().StringArrayVar(&opts.TrackIDs, "track", nil, "Clear only selected track id (repeatable)")
+	return cmd
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRetrySkippedCommandClearsRememberedSkips(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	musicDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, musicDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	memoryPath := filepath.Join(stateDir, "skipped-tracks.json")
+	memory := `{"sources":{"sc":{"777":{"reason":"no-free-download-link","label":"Gate","at":"2026-10-10T08:00:00Z"}}}}`
+	if err := os.WriteFile(memoryPath, []byte(memory), 0o644); err != nil {
+		t.Fatalf("write skip memory: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + musicDir + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    sync:\n      skip_ttl_days: 14\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath, DryRun: true}}
+	cmd := newRetrySkippedCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("retry-skipped --dry-run: %v", err)
+	}
+	if !strings.Contains(out.String(), "[sc] 777 (Gate) (no-free-download-link) skipped ") ||
+		!strings.Contains(out.String(), "retry-skipped: summary cleared=1 mode=preview") {
+		t.Fatalf("unexpected preview output: %q", out.String())
+	}
+	if _, err := os.Stat(memoryPath); err != nil {
+		t.Fatalf("preview must keep the skip memory: %v", err)
+	}
+
+	out.Reset()
+	app.Opts.DryRun = false
+	cmd = newRetrySkippedCommand(app)
+	cmd.SetArgs([]string{"--source", "sc"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("retry-skipped: %v", err)
+	}
+	if !strings.Contains(out.String(), "retry-skipped: summary cleared=1 mode=apply") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	if _, err := os.Stat(memoryPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the emptied skip memory removed, got %v", err)
+	}
+}
@@ -51,6 +51,9 @@ Start here:
 			}
 			return cmd.Help()
 		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return applyHeadless(app, cmd)
+		},
 		SilenceErrors:     true,
 		SilenceUsage:      true,
 		CompletionOptions: cobra.CompletionOptions{DisableDefaultCmd: true},
@@ -58,10 +61,12 @@ Start here:
 
 	defaultConfigPath := os.Getenv("UDL_CONFIG")
 	root.PersistentFlags().StringVarP(&app.Opts.ConfigPath, "config", "c", defaultConfigPath, "Path to config file")
+	root.PersistentFlags().StringVar(&app.Opts.Profile, "profile", "", "Config profile to apply (overrides UDL_PROFILE and the config's profile key)")
 	root.PersistentFlags().BoolVar(&app.Opts.JSON, "json", false, "Emit newline-delimited JSON events")
 	root.PersistentFlags().BoolVarP(&app.Opts.Quiet, "quiet", "q", false, "Reduce output to errors and summary")
 	root.PersistentFlags().BoolVarP(&app.Opts.Verbose, "verbose", "v", false, "Increase diagnostic output")
 	root.PersistentFlags().BoolVar(&app.Opts.NoInput, "no-input", false, "Disable interactive prompts")
+	root.PersistentFlags().BoolVar(&app.Opts.Headless, "headless", false, "Server defaults: --json --no-input, no live progress or browser handoff, non-zero exit for deferred work (or UDL_HEADLESS=1)")
 	root.PersistentFlags().BoolVarP(&app.Opts.DryRun, "dry-run", "n", false, "Validate and plan execution without running adapters")
 	root.Flags().BoolVar(&showVersion, "version", false, "Print version info")
 
@@ -72,9 +77,32 @@ Start here:
 	root.AddCommand(newTUICommand(app))
 	root.AddCommand(newDoctorCommand(app))
 	root.AddCommand(newSyncCommand(app))
+	root.AddCommand(newPlanCommand(app))
 	root.AddCommand(newValidateCommand(app))
 	root.AddCommand(newInitCommand(app))
+	root.AddCommand(newConfigCommand(app))
 	root.AddCommand(newPromoteFreeDLCommand(app))
+	root.AddCommand(newRemapCommand(app))
+	root.AddCommand(newStatusCommand(app))
+	root.AddCommand(newHistoryCommand(app))
+	root.AddCommand(newStatsCommand(app))
+	root.AddCommand(newQueryCommand(app))
+	root.AddCommand(newVerifyCommand(app))
+	root.AddCommand(newUpgradeScanCommand(app))
+	root.AddCommand(newDedupeCommand(app))
+	root.AddCommand(newArtworkCommand(app))
+	root.AddCommand(newRecoverStateCommand(app))
+	root.AddCommand(newRetrySkippedCommand(app))
+	root.AddCommand(newGCCommand(app))
+	root.AddCommand(newSpotifyLoginCommand(app))
+	root.AddCommand(newAuthCommand(app))
+	root.AddCommand(newAdapterCommand(app))
+	root.AddCommand(newWatchCommand(app))
+	root.AddCommand(newImportWatchCommand(app))
+	root.AddCommand(newRPCCommand(app))
+	root.AddCommand(newServeCommand(app))
+	root.AddCommand(newToolsCommand(app))
+	root.AddCommand(newDebugCommand(app))
 	root.AddCommand(newVersionCommand(app))
 
 	return root
@@ -0,0 +1,327 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/output"
+	"github.com/jaa/update-downloads/internal/rpc"
+	"github.com/spf13/cobra"
+)
+
+// rpcEventMethod is the notification method that carries sync events while
+// stream-events is enabled.
+const rpcEventMethod = "event"
+
+func newRPCCommand(app *AppContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rpc",
+		Short: "Serve JSON-RPC 2.0 on stdin/stdout for scripts and language bindings",
+		Long: strings.TrimSpace(`
+Serve JSON-RPC 2.0 on stdin/stdout, one JSON object per line, so wrappers can
+embed udl without scraping CLI output.
+
+Methods:
+- list-sources: configured sources
+- plan {source_ids?, scan_gaps?, no_preflight?}: dry-run sync; planned downloads per source
+- sync-source {source_id, dry_run?, timeout?, scan_gaps?, no_preflight?, ordered?, force?}: sync one source
+- stream-events {enabled?}: send sync events as "event" notifications during plan and sync-source
+
+Requests run one at a time in order. Adapter output goes to stderr; stdout only
+carries protocol messages. udl exits when stdin closes.
+`),
+		Example: strings.TrimSpace(`
+  printf '%s\n' '{"jsonrpc":"2.0","id":1,"method":"list-sources"}' | udl rpc
+`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), interruptSignals()...)
+			defer stop()
+
+			session := &rpcSession{
+				app:    app,
+				cfg:    cfg,
+				runner: engine.NewSubprocessRunner(strings.NewReader(""), app.IO.ErrOut, app.IO.ErrOut),
+			}
+			if err := session.server().Serve(ctx, app.IO.In, app.IO.Out); err != nil && !errors.Is(err, context.Canceled) {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+type rpcSession struct {
+	app          *AppContext
+	cfg          config.Config
+	runner       engine.ExecRunner
+	streamEvents bool
+}
+
+func (s *rpcSession) server() *rpc.Server {
+	server := rpc.NewServer()
+	server.Register("list-sources", s.listSources)
+	server.Register("plan", s.plan)
+	server.Register("sync-source", s.syncSource)
+	server.Register("stream-events", s.setStreamEvents)
+	return server
+}
+
+type rpcSource struct {
+	ID        string `json:"id"`
+	Type      string `json:"type"`
+	Enabled   bool   `json:"enabled"`
+	Adapter   string `json:"adapter"`
+	TargetDir string `json:"target_dir"`
+	URL       string `json:"url"`
+	StateFile string `json:"state_file,omitempty"`
+}
+
+type rpcSyncSummary struct {
+	Total              int  `json:"total"`
+	Attempted          int  `json:"attempted"`
+	Succeeded          int  `json:"succeeded"`
+	Failed             int  `json:"failed"`
+	Skipped            int  `json:"skipped"`
+	DependencyFailures int  `json:"dependency_failures"`
+	Interrupted        bool `json:"interrupted"`
+}
+
+type rpcPlanSource struct {
+	SourceID             string `json:"source_id"`
+	Status               string `json:"status"`
+	PlannedDownloadCount *int   `json:"planned_download_count,omitempty"`
+	Command              string `json:"command,omitempty"`
+	Message              string `json:"message,omitempty"`
+}
+
+const (
+	rpcPlanStatusPlanned  = "planned"
+	rpcPlanStatusUpToDate = "up_to_date"
+	rpcPlanStatusFailed   = "failed"
+)
+
+func (s *rpcSession) listSources(ctx context.Context, params json.RawMessage, notify rpc.Notifier) (any, error) {
+	sources := make([]rpcSource, 0, len(s.cfg.Sources))
+	for _, source := range s.cfg.Sources {
+		sources = append(sources, rpcSource{
+			ID:        source.ID,
+			Type:      string(source.Type),
+			Enabled:   source.Enabled,
+			Adapter:   source.Adapter.Kind,
+			TargetDir: source.TargetDir,
+			URL:       source.URL,
+			StateFile: source.StateFile,
+		})
+	}
+	return map[string]any{"sources": sources}, nil
+}
+
+func (s *rpcSession) setStreamEvents(ctx context.Context, params json.RawMessage, notify rpc.Notifier) (any, error) {
+	req := struct {
+		Enabled *bool `json:"enabled"`
+	}{}
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	s.streamEvents = req.Enabled == nil || *req.Enabled
+	return map[string]bool{"enabled": s.streamEvents}, nil
+}
+
+func (s *rpcSession) plan(ctx context.Context, params json.RawMessage, notify rpc.Notifier) (any, error) {
+	req := struct {
+		SourceIDs   []string `json:"source_ids"`
+		ScanGaps    bool     `json:"scan_gaps"`
+		NoPreflight bool     `json:"no_preflight"`
+	}{}
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	collector := newRPCPlanCollector()
+	result, err := s.runSync(ctx, notify, collector.observe, workflows.SyncRequest{
+		SourceIDs:   req.SourceIDs,
+		DryRun:      true,
+		ScanGaps:    req.ScanGaps,
+		NoPreflight: req.NoPreflight,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"sources": collector.sources(), "summary": newRPCSyncSummary(result)}, nil
+}
+
+func (s *rpcSession) syncSource(ctx context.Context, params json.RawMessage, notify rpc.Notifier) (any, error) {
+	req := struct {
+		SourceID    string `json:"source_id"`
+		DryRun      *bool  `json:"dry_run"`
+		Timeout     string `json:"timeout"`
+		ScanGaps    bool   `json:"scan_gaps"`
+		NoPreflight bool   `json:"no_preflight"`
+		Ordered     bool   `json:"ordered"`
+		Force       bool   `json:"force"`
+	}{}
+	if err := decodeRPCParams(params, &req); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.SourceID) == "" {
+		return nil, rpc.InvalidParams("source_id is required")
+	}
+	timeout := time.Duration(0)
+	if strings.TrimSpace(req.Timeout) != "" {
+		parsed, err := time.ParseDuration(req.Timeout)
+		if err != nil || parsed <= 0 {
+			return nil, rpc.InvalidParams("invalid timeout %q (expected a positive duration such as 10m)", req.Timeout)
+		}
+		timeout = parsed
+	}
+	dryRun := s.app.Opts.DryRun
+	if req.DryRun != nil {
+		dryRun = *req.DryRun
+	}
+	result, err := s.runSync(ctx, notify, nil, workflows.SyncRequest{
+		SourceIDs:       []string{strings.TrimSpace(req.SourceID)},
+		DryRun:          dryRun,
+		TimeoutOverride: timeout,
+		ScanGaps:        req.ScanGaps,
+		NoPreflight:     req.NoPreflight,
+		Ordered:         req.Ordered,
+		Force:           req.Force,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"summary": newRPCSyncSummary(result)}, nil
+}
+
+func (s *rpcSession) runSync(ctx context.Context, notify rpc.Notifier, observe func(output.Event), req workflows.SyncRequest) (engine.SyncResult, error) {
+	req.TrackStatus = engine.TrackStatusNames
+	useCase := workflows.SyncUseCase{
+		Registry: syncAdapterRegistry(),
+		Runner:   s.runner,
+		Emitter:  rpcEventEmitter{notify: notify, stream: s.streamEvents, observe: observe},
+	}
+	result, err := useCase.Run(ctx, s.cfg, req, nil)
+	if err != nil {
+		var selectionErr *engine.SelectionError
+		if errors.As(err, &selectionErr) {
+			return result, rpc.InvalidParams("%v", err)
+		}
+		return result, err
+	}
+	return result, nil
+}
+
+func decodeRPCParams(params json.RawMessage, dst any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(params))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return rpc.InvalidParams("invalid params: %v", err)
+	}
+	return nil
+}
+
+func newRPCSyncSummary(result engine.SyncResult) rpcSyncSummary {
+	return rpcSyncSummary{
+		Total:              result.Total,
+		Attempted:          result.Attempted,
+		Succeeded:          result.Succeeded,
+		Failed:             result.Failed,
+		Skipped:            result.Skipped,
+		DependencyFailures: result.DependencyFailures,
+		Interrupted:        result.Interrupted,
+	}
+}
+
+// rpcEventEmitter forwards sync events to observe and, while streaming is
+// on, to the client as notifications.
+type rpcEventEmitter struct {
+	notify  rpc.Notifier
+	stream  bool
+	observe func(output.Event)
+}
+
+func (e rpcEventEmitter) Emit(event output.Event) error {
+	if e.observe != nil {
+		e.observe(event)
+	}
+	if e.stream {
+		return e.notify(rpcEventMethod, event)
+	}
+	return nil
+}
+
+// rpcPlanCollector condenses dry-run events into one entry per source.
+type rpcPlanCollector struct {
+	mu    sync.Mutex
+	order []string
+	bySrc map[string]*rpcPlanSource
+}
+
+func newRPCPlanCollector() *rpcPlanCollector {
+	return &rpcPlanCollector{bySrc: map[string]*rpcPlanSource{}}
+}
+
+func (c *rpcPlanCollector) observe(event output.Event) {
+	if event.SourceID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.bySrc[event.SourceID]
+	if !ok {
+		entry = &rpcPlanSource{SourceID: event.SourceID, Status: rpcPlanStatusPlanned}
+		c.bySrc[event.SourceID] = entry
+		c.order = append(c.order, event.SourceID)
+	}
+	if planned, ok := event.Details["planned_download_count"].(int); ok {
+		count := planned
+		entry.PlannedDownloadCount = &count
+		if planned == 0 && entry.Status != rpcPlanStatusFailed {
+			entry.Status = rpcPlanStatusUpToDate
+		}
+	}
+	switch event.Event {
+	case output.EventSourceFinished:
+		if command, ok := event.Details["command"].(string); ok {
+			entry.Command = command
+		}
+		if event.Level == output.LevelInfo && entry.Status != rpcPlanStatusFailed {
+			entry.Message = event.Message
+		}
+	case output.EventSourceFailed:
+		entry.Status = rpcPlanStatusFailed
+		entry.Message = event.Message
+	}
+}
+
+func (c *rpcPlanCollector) sources() []rpcPlanSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sources := make([]rpcPlanSource, 0, len(c.order))
+	for _, id := range c.order {
+		sources = append(sources, *c.bySrc[id])
+	}
+	return sources
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestRPCCommandListsSourcesAndValidatesParams(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + filepath.Join(tmp, "state") + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + filepath.Join(tmp, "music") + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"list-sources"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"stream-events","params":{"enabled":true}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"sync-source","params":{}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"sync-source","params":{"source_id":"sc","timeout":"soon"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"plan","params":{"sources":["sc"]}}`,
+	}, "\n")
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(in), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newRPCCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("rpc: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected 5 responses, got %q", out.String())
+	}
+	var sources struct {
+		Result struct {
+			Sources []rpcSource `json:"sources"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &sources); err != nil {
+		t.Fatalf("decode list-sources: %v", err)
+	}
+	if len(sources.Result.Sources) != 1 || sources.Result.Sources[0].ID != "sc" || sources.Result.Sources[0].Adapter != "scdl" {
+		t.Fatalf("unexpected list-sources result: %s", lines[0])
+	}
+	if lines[1] != `{"jsonrpc":"2.0","id":2,"result":{"enabled":true}}` {
+		t.Fatalf("unexpected stream-events result: %s", lines[1])
+	}
+	for i, want := range []string{"source_id is required", `invalid timeout \"soon\"`, `unknown field \"sources\"`} {
+		if line := lines[i+2]; !strings.Contains(line, `"code":-32602`) || !strings.Contains(line, want) {
+			t.Fatalf("expected invalid params error containing %q, got %s", want, line)
+		}
+	}
+}
+
+func TestRPCPlanCollectorSummarizesSourceEvents(t *testing.T) {
+	collector := newRPCPlanCollector()
+	collector.observe(output.Event{Event: output.EventSyncStarted})
+	collector.observe(output.Event{Event: output.EventSourcePreflight, SourceID: "sc", Details: map[string]any{"planned_download_count": 3}})
+	collector.observe(output.Event{Event: output.EventSourceFinished, SourceID: "sc", Level: output.LevelInfo, Message: "[sc] dry-run complete", Details: map[string]any{"dry_run": true, "command": "scdl -l url"}})
+	collector.observe(output.Event{Event: output.EventSourceFinished, SourceID: "sp", Level: output.LevelInfo, Message: "[sp] up-to-date (no downloads planned)", Details: map[string]any{"planned_download_count": 0}})
+	collector.observe(output.Event{Event: output.EventSourceFailed, SourceID: "yt", Level: output.LevelError, Message: "[yt] cannot build command"})
+
+	sources := collector.sources()
+	if len(sources) != 3 {
+		t.Fatalf("expected three sources, got %+v", sources)
+	}
+	if sources[0].Status != rpcPlanStatusPlanned || *sources[0].PlannedDownloadCount != 3 || sources[0].Command != "scdl -l url" {
+		t.Fatalf("unexpected planned source: %+v", sources[0])
+	}
+	if sources[1].Status != rpcPlanStatusUpToDate || *sources[1].PlannedDownloadCount != 0 {
+		t.Fatalf("unexpected up-to-date source: %+v", sources[1])
+	}
+	if sources[2].Status != rpcPlanStatusFailed || sources[2].Message != "[yt] cannot build command" {
+		t.Fatalf("unexpected failed source: %+v", sources[2])
+	}
+}
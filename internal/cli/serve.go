@@ -0,0 +1,507 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/output"
+	"github.com/spf13/cobra"
+)
+
+const (
+	serveDefaultAddr = "127.0.0.1:8765"
+	// serveTokenEnv holds the bearer token every API request must carry.
+	serveTokenEnv = "UDL_SERVE_TOKEN"
+
+	serveEventBuffer    = 256
+	serveEventKeepAlive = 15 * time.Second
+)
+
+func newServeCommand(app *AppContext) *cobra.Command {
+	var addr string
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a small HTTP API to list sources, trigger syncs, and stream events",
+		Long: strings.TrimSpace(`
+Serve a small HTTP API so a frontend can drive udl remotely.
+
+Endpoints:
+- GET  /api/sources: configured sources
+- GET  /api/status: per-source status (as udl status --json) and the latest API-triggered run
+- POST /api/sources/{id}/sync {dry_run?, timeout?, scan_gaps?, no_preflight?, ordered?, force?}: start a sync of one source
+- GET  /api/events: sync events as Server-Sent Events
+
+One sync runs at a time; starting another while it runs returns 409. POST
+bodies must be sent as "Content-Type: application/json". When UDL_SERVE_TOKEN
+is set, every request needs "Authorization: Bearer <token>". Addresses other
+than loopback are refused without it, and token-less requests must name
+localhost, 127.0.0.1 or [::1] with the listening port as their Host and carry
+no Origin other than that address, so web pages cannot drive the API.
+SIGINT/SIGTERM interrupt the running sync and stop the server.
+`),
+		Example: strings.TrimSpace(`
+  udl serve
+  UDL_SERVE_TOKEN=... udl serve --addr 0.0.0.0:8765
+  curl -X POST -H 'Content-Type: application/json' http://127.0.0.1:8765/api/sources/soundcloud-likes/sync
+`),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			token := strings.TrimSpace(os.Getenv(serveTokenEnv))
+			if token == "" && !isLoopbackServeAddr(addr) {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("refusing to serve on %s without %s; the API can start syncs, so set a token or bind to 127.0.0.1", addr, serveTokenEnv))
+			}
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --addr %q: %w", addr, err))
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), interruptSignals()...)
+			defer stop()
+
+			session := newServeSession(ctx, app, cfg, token)
+			server := &http.Server{
+				Handler:           session.handler(),
+				ReadHeaderTimeout: 10 * time.Second,
+				BaseContext:       func(net.Listener) context.Context { return ctx },
+			}
+			fmt.Fprintf(app.IO.ErrOut, "serve: listening on http://%s\n", listener.Addr())
+
+			serveErr := make(chan error, 1)
+			go func() {
+				serveErr <- server.Serve(listener)
+			}()
+			select {
+			case err := <-serveErr:
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			case <-ctx.Done():
+			}
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+			session.wait()
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&addr, "addr", serveDefaultAddr, "Listen address (host:port)")
+	return cmd
+}
+
+// isLoopbackServeAddr reports whether addr only accepts local connections.
+// An empty host listens on every interface.
+func isLoopbackServeAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveRun is a sync started through the API.
+type serveRun struct {
+	ID         int             `json:"id"`
+	SourceID   string          `json:"source_id"`
+	DryRun     bool            `json:"dry_run"`
+	Status     string          `json:"status"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	Summary    *rpcSyncSummary `json:"summary,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+const (
+	serveRunRunning   = "running"
+	serveRunSucceeded = "succeeded"
+	serveRunFailed    = "failed"
+)
+
+type serveSession struct {
+	ctx   context.Context
+	app   *AppContext
+	cfg   config.Config
+	token string
+	now   func() time.Time
+	// runSync runs one sync with events going to emitter; tests replace it.
+	runSync func(ctx context.Context, emitter output.EventEmitter, req workflows.SyncRequest) (engine.SyncResult, error)
+
+	events *serveEventBroker
+	mu     sync.Mutex
+	nextID int
+	run    *serveRun
+	done   chan struct{}
+}
+
+func newServeSession(ctx context.Context, app *AppContext, cfg config.Config, token string) *serveSession {
+	runner := engine.NewSubprocessRunner(strings.NewReader(""), app.IO.ErrOut, app.IO.ErrOut)
+	return &serveSession{
+		ctx:    ctx,
+		app:    app,
+		cfg:    cfg,
+		token:  token,
+		now:    time.Now,
+		events: newServeEventBroker(),
+		runSync: func(ctx context.Context, emitter output.EventEmitter, req workflows.SyncRequest) (engine.SyncResult, error) {
+			useCase := workflows.SyncUseCase{
+				Registry: syncAdapterRegistry(),
+				Runner:   runner,
+				Emitter:  emitter,
+			}
+			return useCase.Run(ctx, cfg, req, nil)
+		},
+	}
+}
+
+func (s *serveSession) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/sources", s.listSources)
+	mux.HandleFunc("GET /api/status", s.status)
+	mux.HandleFunc("POST /api/sources/{id}/sync", s.syncSource)
+	mux.HandleFunc("GET /api/events", s.streamEvents)
+	return s.authorize(mux)
+}
+
+func (s *serveSession) authorize(next http.Handler) http.Handler {
+	if s.token == "" {
+		return localOnlyServeHandler(next)
+	}
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeServeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// localOnlyServeHandler guards the token-less API, which listens on
+// loopback but is still reachable from any page the user's browser opens. A
+// Host other than a loopback name for the listening port means DNS
+// rebinding, and an Origin other than that address means a cross-site
+// request; both are refused.
+func localOnlyServeHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackServeHost(r) {
+			writeServeError(w, http.StatusForbidden, fmt.Sprintf("host %q is not a loopback address of this server; set %s to serve other hosts", r.Host, serveTokenEnv))
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" && !strings.EqualFold(origin, "http://"+r.Host) {
+			writeServeError(w, http.StatusForbidden, fmt.Sprintf("cross-origin request from %q refused", origin))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackServeHost reports whether the request's Host is localhost,
+// 127.0.0.1 or [::1] with the port the connection came in on.
+func isLoopbackServeHost(r *http.Request) bool {
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]"), "80"
+	}
+	switch strings.ToLower(host) {
+	case "localhost", "127.0.0.1", "::1":
+	default:
+		return false
+	}
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return false
+	}
+	_, localPort, err := net.SplitHostPort(local.String())
+	return err == nil && port == localPort
+}
+
+func (s *serveSession) listSources(w http.ResponseWriter, r *http.Request) {
+	sources := make([]rpcSource, 0, len(s.cfg.Sources))
+	for _, source := range s.cfg.Sources {
+		sources = append(sources, rpcSource{
+			ID:        source.ID,
+			Type:      string(source.Type),
+			Enabled:   source.Enabled,
+			Adapter:   source.Adapter.Kind,
+			TargetDir: source.TargetDir,
+			URL:       source.URL,
+			StateFile: source.StateFile,
+		})
+	}
+	writeServeJSON(w, http.StatusOK, map[string]any{"sources": sources})
+}
+
+func (s *serveSession) status(w http.ResponseWriter, r *http.Request) {
+	sourceIDs := r.URL.Query()["source"]
+	statuses, err := (workflows.StatusUseCase{}).Run(s.cfg, sourceIDs)
+	if err != nil {
+		var selectionErr *engine.SelectionError
+		if errors.As(err, &selectionErr) {
+			writeServeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeServeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeServeJSON(w, http.StatusOK, map[string]any{"sources": statuses, "run": s.currentRun()})
+}
+
+func (s *serveSession) syncSource(w http.ResponseWriter, r *http.Request) {
+	sourceID := strings.TrimSpace(r.PathValue("id"))
+	if !serveSourceConfigured(s.cfg, sourceID) {
+		writeServeError(w, http.StatusNotFound, fmt.Sprintf("unknown source %q", sourceID))
+		return
+	}
+	req := struct {
+		DryRun      *bool  `json:"dry_run"`
+		Timeout     string `json:"timeout"`
+		ScanGaps    bool   `json:"scan_gaps"`
+		NoPreflight bool   `json:"no_preflight"`
+		Ordered     bool   `json:"ordered"`
+		Force       bool   `json:"force"`
+	}{}
+	if !isServeJSONRequest(r) {
+		writeServeError(w, http.StatusUnsupportedMediaType, "request body must be sent as Content-Type: application/json")
+		return
+	}
+	if err := decodeServeBody(r.Body, &req); err != nil {
+		writeServeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	timeout := time.Duration(0)
+	if strings.TrimSpace(req.Timeout) != "" {
+		parsed, err := time.ParseDuration(req.Timeout)
+		if err != nil || parsed <= 0 {
+			writeServeError(w, http.StatusBadRequest, fmt.Sprintf("invalid timeout %q (expected a positive duration such as 10m)", req.Timeout))
+			return
+		}
+		timeout = parsed
+	}
+	dryRun := s.app.Opts.DryRun
+	if req.DryRun != nil {
+		dryRun = *req.DryRun
+	}
+
+	run, ok := s.startRun(sourceID, dryRun, workflows.SyncRequest{
+		SourceIDs:       []string{sourceID},
+		DryRun:          dryRun,
+		TimeoutOverride: timeout,
+		ScanGaps:        req.ScanGaps,
+		NoPreflight:     req.NoPreflight,
+		Ordered:         req.Ordered,
+		Force:           req.Force,
+		TrackStatus:     engine.TrackStatusNames,
+	})
+	if !ok {
+		writeServeJSON(w, http.StatusConflict, map[string]any{
+			"error": fmt.Sprintf("a sync of %s is already running", run.SourceID),
+			"run":   run,
+		})
+		return
+	}
+	writeServeJSON(w, http.StatusAccepted, map[string]any{"run": run})
+}
+
+// startRun starts req in the background unless a sync is already running,
+// in which case it returns that run and false.
+func (s *serveSession) startRun(sourceID string, dryRun bool, req workflows.SyncRequest) (serveRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run != nil && s.run.Status == serveRunRunning {
+		return *s.run, false
+	}
+	s.nextID++
+	run := &serveRun{ID: s.nextID, SourceID: sourceID, DryRun: dryRun, Status: serveRunRunning, StartedAt: s.now().UTC()}
+	s.run = run
+	done := make(chan struct{})
+	s.done = done
+	go func() {
+		defer close(done)
+		result, err := s.runSync(s.ctx, s.events, req)
+		s.finishRun(run, result, err)
+	}()
+	return *run, true
+}
+
+func (s *serveSession) finishRun(run *serveRun, result engine.SyncResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	finished := s.now().UTC()
+	summary := newRPCSyncSummary(result)
+	run.FinishedAt = &finished
+	run.Summary = &summary
+	run.Status = serveRunSucceeded
+	if err != nil {
+		run.Error = err.Error()
+	}
+	if err != nil || result.Failed > 0 || result.Interrupted {
+		run.Status = serveRunFailed
+	}
+}
+
+func (s *serveSession) currentRun() *serveRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.run == nil {
+		return nil
+	}
+	run := *s.run
+	return &run
+}
+
+// wait blocks until the running sync, if any, has returned.
+func (s *serveSession) wait() {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// streamEvents sends every sync event, in the --json event shape, as a
+// Server-Sent Event named after the event until the client goes away.
+func (s *serveSession) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeServeError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	_, _ = io.WriteString(w, ": udl events\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(serveEventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = io.WriteString(w, ": keepalive\n\n")
+		case event := <-events:
+			payload, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Event, payload); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// serveEventBroker fans sync events out to every connected event stream.
+// A client that falls serveEventBuffer events behind misses the rest rather
+// than stalling the sync.
+type serveEventBroker struct {
+	mu          sync.Mutex
+	subscribers map[chan output.Event]struct{}
+}
+
+func newServeEventBroker() *serveEventBroker {
+	return &serveEventBroker{subscribers: map[chan output.Event]struct{}{}}
+}
+
+func (b *serveEventBroker) subscribe() (<-chan output.Event, func()) {
+	ch := make(chan output.Event, serveEventBuffer)
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, ch)
+		b.mu.Unlock()
+	}
+}
+
+func (b *serveEventBroker) Emit(event output.Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+func serveSourceConfigured(cfg config.Config, sourceID string) bool {
+	for _, source := range cfg.Sources {
+		if source.ID == sourceID {
+			return true
+		}
+	}
+	return false
+}
+
+// isServeJSONRequest reports a JSON Content-Type. HTML forms cannot send
+// one, and a cross-site fetch that does must pass a CORS preflight the API
+// never grants.
+func isServeJSONRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+func decodeServeBody(body io.Reader, dst any) error {
+	payload, err := io.ReadAll(io.LimitReader(body, 64*1024))
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(dst); err != nil {
+		return fmt.Errorf("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func writeServeJSON(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeServeError(w http.ResponseWriter, status int, message string) {
+	writeServeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func newTestServeSession(t *testing.T, token string) *serveSession {
+	t.Helper()
+	tmp := t.TempDir()
+	cfg := config.Config{
+		Version:  1,
+		Defaults: config.Defaults{StateDir: filepath.Join(tmp, "state"), ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sc",
+			Type:      config.SourceTypeSoundCloud,
+			Enabled:   true,
+			TargetDir: filepath.Join(tmp, "music"),
+			URL:       "https://soundcloud.com/user",
+			StateFile: "sc.sync.scdl",
+			Adapter:   config.AdapterSpec{Kind: "scdl"},
+		}},
+	}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}}}
+	return newServeSession(context.Background(), app, cfg, token)
+}
+
+func TestServeAPIListsSourcesAndRequiresToken(t *testing.T) {
+	session := newTestServeSession(t, "secret")
+	server := httptest.NewServer(session.handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/sources")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/sources", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get with token: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		Sources []rpcSource `json:"sources"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || len(body.Sources) != 1 || body.Sources[0].ID != "sc" {
+		t.Fatalf("unexpected sources response %d %+v", resp.StatusCode, body)
+	}
+}
+
+func TestServeAPITriggersOneSyncAtATimeAndStreamsEvents(t *testing.T) {
+	session := newTestServeSession(t, "")
+	release := make(chan struct{})
+	var got workflows.SyncRequest
+	session.runSync = func(ctx context.Context, emitter output.EventEmitter, req workflows.SyncRequest) (engine.SyncResult, error) {
+		got = req
+		<-release
+		_ = emitter.Emit(output.Event{Timestamp: time.Now(), Level: output.LevelInfo, Event: output.EventTrackDone, SourceID: "sc", Message: "[sc] [done] 1"})
+		return engine.SyncResult{Total: 1, Attempted: 1, Succeeded: 1}, nil
+	}
+	server := httptest.NewServer(session.handler())
+	defer server.Close()
+
+	stream, err := http.Get(server.URL + "/api/events")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	defer stream.Body.Close()
+	if stream.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected stream content type %q", stream.Header.Get("Content-Type"))
+	}
+	lines := bufio.NewReader(stream.Body)
+	if line, _ := lines.ReadString('\n'); line != ": udl events\n" {
+		t.Fatalf("unexpected stream preamble %q", line)
+	}
+
+	post := func(path string, body string) *http.Response {
+		t.Helper()
+		resp, err := http.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("post %s: %v", path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := post("/api/sources/missing/sync", ""); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown source, got %d", resp.StatusCode)
+	}
+	if resp := post("/api/sources/sc/sync", `{"timeout":"soon"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad timeout, got %d", resp.StatusCode)
+	}
+	if resp := post("/api/sources/sc/sync", `{"dry_run":true,"scan_gaps":true}`); resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d", resp.StatusCode)
+	}
+	if resp := post("/api/sources/sc/sync", ""); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 while a sync runs, got %d", resp.StatusCode)
+	}
+	close(release)
+
+	var event, data string
+	for event == "" || data == "" {
+		line, err := lines.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+		}
+	}
+	if event != string(output.EventTrackDone) || !strings.Contains(data, `"source_id":"sc"`) {
+		t.Fatalf("unexpected streamed event %q %s", event, data)
+	}
+
+	session.wait()
+	if !got.DryRun || !got.ScanGaps || len(got.SourceIDs) != 1 || got.SourceIDs[0] != "sc" {
+		t.Fatalf("unexpected sync request %+v", got)
+	}
+	run := session.currentRun()
+	if run == nil || run.ID != 1 || run.Status != serveRunSucceeded || run.Summary == nil || run.Summary.Succeeded != 1 {
+		t.Fatalf("unexpected last run %+v", run)
+	}
+}
+
+func TestIsLoopbackServeAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8765": true,
+		"localhost:8765": true,
+		"[::1]:8765":     true,
+		"0.0.0.0:8765":   false,
+		":8765":          false,
+		"192.168.1.2:80": false,
+		"nonsense":       false,
+	} {
+		if got := isLoopbackServeAddr(addr); got != want {
+			t.Fatalf("isLoopbackServeAddr(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestServeAPIWithoutTokenRefusesBrowserRequests(t *testing.T) {
+	session := newTestServeSession(t, "")
+	started := 0
+	session.runSync = func(ctx context.Context, emitter output.EventEmitter, req workflows.SyncRequest) (engine.SyncResult, error) {
+		started++
+		return engine.SyncResult{}, nil
+	}
+	server := httptest.NewServer(session.handler())
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	send := func(method string, path string, mutate func(*http.Request)) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(""))
+		if method == http.MethodPost {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		mutate(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code := send(http.MethodPost, "/api/sources/sc/sync", func(req *http.Request) {
+		req.Header.Set("Origin", "https://evil.example")
+	}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a cross-origin POST, got %d", code)
+	}
+	if code := send(http.MethodGet, "/api/status", func(req *http.Request) {
+		req.Host = "evil.example:" + port
+	}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a foreign Host, got %d", code)
+	}
+	if code := send(http.MethodGet, "/api/sources", func(req *http.Request) {
+		req.Host = "localhost:1"
+	}); code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a loopback Host on another port, got %d", code)
+	}
+	if code := send(http.MethodPost, "/api/sources/sc/sync", func(req *http.Request) {
+		req.Header.Set("Content-Type", "text/plain")
+	}); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for a non-JSON POST, got %d", code)
+	}
+	if started != 0 {
+		t.Fatalf("expected refused requests to start no sync, got %d", started)
+	}
+
+	if code := send(http.MethodGet, "/api/sources", func(req *http.Request) {
+		req.Host = "localhost:" + port
+	}); code != http.StatusOK {
+		t.Fatalf("expected 200 for a localhost Host, got %d", code)
+	}
+	if code := send(http.MethodPost, "/api/sources/sc/sync", func(req *http.Request) {
+		req.Header.Set("Origin", "http://127.0.0.1:"+port)
+	}); code != http.StatusAccepted {
+		t.Fatalf("expected 202 for a same-origin POST, got %d", code)
+	}
+	session.wait()
+	if started != 1 {
+		t.Fatalf("expected the same-origin POST to start one sync, got %d", started)
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const shortLinksFileName = "short-links.json"
+
+var resolveShortLinkFn = config.ResolveShortLink
+
+type shortLinksFile struct {
+	Links map[string]string `json:"links"`
+}
+
+// resolveSourceShortLinks replaces share short links in source URLs with the
+// canonical form of the link they redirect to. Resolutions are cached in
+// <state_dir>/short-links.json, so each link is fetched once. A link that
+// cannot be resolved is left in place for config.Validate to reject.
+func resolveSourceShortLinks(ctx context.Context, cfg *config.Config) {
+	var cachePath string
+	var links map[string]string
+	changed := false
+	for i := range cfg.Sources {
+		source := &cfg.Sources[i]
+		if !config.IsShortLink(source.URL) {
+			continue
+		}
+		if links == nil {
+			cachePath, links = loadShortLinks(cfg.Defaults.StateDir)
+		}
+		resolved, ok := links[source.URL]
+		if !ok {
+			target, err := resolveShortLinkFn(ctx, source.URL)
+			if err != nil {
+				continue
+			}
+			resolved = config.NormalizeSourceURL(source.Type, target)
+			links[source.URL] = resolved
+			changed = true
+		}
+		source.URL = resolved
+	}
+	if changed && cachePath != "" {
+		_ = writeShortLinks(cachePath, links)
+	}
+}
+
+func loadShortLinks(stateDir string) (string, map[string]string) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil || !filepath.IsAbs(root) {
+		return "", map[string]string{}
+	}
+	path := filepath.Join(root, shortLinksFileName)
+	var payload shortLinksFile
+	if raw, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(raw, &payload)
+	}
+	if payload.Links == nil {
+		payload.Links = map[string]string{}
+	}
+	return path, payload.Links
+}
+
+func writeShortLinks(path string, links map[string]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(shortLinksFile{Links: links}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(payload, '\n'), 0o644)
+}
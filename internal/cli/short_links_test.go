@@ -0,0 +1,57 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestResolveSourceShortLinksCachesCanonicalTargets(t *testing.T) {
+	stateDir := t.TempDir()
+	calls := 0
+	orig := resolveShortLinkFn
+	t.Cleanup(func() { resolveShortLinkFn = orig })
+	resolveShortLinkFn = func(ctx context.Context, link string) (string, error) {
+		calls++
+		return "https://open.spotify.com/intl-de/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc", nil
+	}
+
+	newConfig := func() config.Config {
+		return config.Config{
+			Defaults: config.Defaults{StateDir: stateDir},
+			Sources: []config.Source{
+				{ID: "spotify-mix", Type: config.SourceTypeSpotify, URL: "https://spotify.link/AbCdEf"},
+				{ID: "soundcloud-likes", Type: config.SourceTypeSoundCloud, URL: "https://soundcloud.com/user/likes"},
+			},
+		}
+	}
+
+	cfg := newConfig()
+	resolveSourceShortLinks(context.Background(), &cfg)
+	if got := cfg.Sources[0].URL; got != "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M" {
+		t.Fatalf("expected canonical resolved url, got %q", got)
+	}
+	if got := cfg.Sources[1].URL; got != "https://soundcloud.com/user/likes" {
+		t.Fatalf("expected full link untouched, got %q", got)
+	}
+	raw, err := os.ReadFile(filepath.Join(stateDir, shortLinksFileName))
+	if err != nil {
+		t.Fatalf("read short link cache: %v", err)
+	}
+	if !strings.Contains(string(raw), `"https://spotify.link/AbCdEf": "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"`) {
+		t.Fatalf("unexpected short link cache:\n%s", raw)
+	}
+
+	cfg = newConfig()
+	resolveSourceShortLinks(context.Background(), &cfg)
+	if calls != 1 {
+		t.Fatalf("expected cached resolution on second load, got %d fetches", calls)
+	}
+	if got := cfg.Sources[0].URL; got != "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M" {
+		t.Fatalf("expected cached canonical url, got %q", got)
+	}
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// addSourceTagFlags registers --tag and --exclude-tag next to a command's
+// --source flag.
+func addSourceTagFlags(cmd *cobra.Command, tags *[]string, excludeTags *[]string) {
+	cmd.Flags().StringArrayVar(tags, "tag", nil, "Also select sources carrying this tag (repeatable)")
+	cmd.Flags().StringArrayVar(excludeTags, "exclude-tag", nil, "Leave out sources carrying this tag (repeatable)")
+}
+
+// resolveSourceSelection turns --source, --tag, and --exclude-tag into the
+// source IDs a command runs; nil still means every source.
+func resolveSourceSelection(cfg config.Config, sourceIDs []string, tags []string, excludeTags []string) ([]string, error) {
+	ids, err := engine.SelectSourceIDs(cfg.Sources, sourceIDs, tags, excludeTags)
+	if err != nil {
+		return nil, withExitCode(exitcode.InvalidUsage, err)
+	}
+	return ids, nil
+}
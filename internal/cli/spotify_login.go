@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type spotifyLoginOptions struct {
+	RedirectURI string
+	Refresh     bool
+}
+
+var (
+	spotifyLoginCredentialsFn = auth.ResolveSpotifyCredentials
+	spotifyLoginTokenPathFn   = auth.SpotDLUserTokenCachePath
+	newSpotifyUserAuthFn      = func(creds auth.SpotifyCredentials, redirectURI string) auth.SpotifyUserAuth {
+		return auth.SpotifyUserAuth{Credentials: creds, RedirectURI: redirectURI}
+	}
+)
+
+func newSpotifyLoginCommand(app *AppContext) *cobra.Command {
+	opts := spotifyLoginOptions{RedirectURI: auth.SpotDLRedirectURI}
+
+	cmd := &cobra.Command{
+		Use:   "spotify-login",
+		Short: "Log in to Spotify for spotdl --user-auth without a local browser",
+		Long: "Print a Spotify authorization URL to open on any device, then paste back the URL the browser was " +
+			"redirected to. The user token is written to spotdl's cache (~/.spotdl/.spotipy), so syncs that need " +
+			"--user-auth reuse and refresh it headlessly. Use --refresh to renew the cached token without logging in again.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			creds, err := spotifyLoginCredentialsFn()
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, fmt.Errorf("resolve spotify app credentials: %w", err))
+			}
+			tokenPath, err := spotifyLoginTokenPathFn()
+			if err != nil {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+			userAuth := newSpotifyUserAuthFn(creds, opts.RedirectURI)
+
+			var token auth.SpotifyUserToken
+			if opts.Refresh {
+				// Hold the cache lock across load, refresh and save so a sync
+				// refreshing the same token cannot overwrite the result.
+				unlock, _, err := auth.LockSpotifyUserTokenCache(cmd.Context(), tokenPath)
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				defer unlock()
+				cached, err := auth.LoadSpotifyUserToken(tokenPath)
+				if err != nil {
+					if errors.Is(err, auth.ErrSpotifyUserTokenNotFound) {
+						return withExitCode(exitcode.InvalidUsage, fmt.Errorf("no cached spotify login at %s; run `udl spotify-login` first", tokenPath))
+					}
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				token, err = userAuth.Refresh(cmd.Context(), cached)
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				if app.Opts.NoInput {
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("spotify-login needs input; use --refresh with --no-input"))
+				}
+				state, err := newSpotifyLoginState()
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				fmt.Fprintln(app.IO.Out, "Open this URL on any device and approve access:")
+				fmt.Fprintln(app.IO.Out, userAuth.AuthorizationURL(state))
+				fmt.Fprintln(app.IO.Out, "The browser then tries to load "+opts.RedirectURI+"; the page failing to load is expected.")
+				redirected, err := promptLine(app, "Paste the full URL from the address bar")
+				if err != nil {
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("read redirect URL: %w", err))
+				}
+				code, err := userAuth.CodeFromRedirect(redirected, state)
+				if err != nil {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				token, err = userAuth.Exchange(cmd.Context(), code)
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			}
+
+			if app.Opts.DryRun {
+				fmt.Fprintf(app.IO.Out, "spotify-login: dry-run; not writing %s\n", tokenPath)
+				return nil
+			}
+			if !opts.Refresh {
+				unlock, _, err := auth.LockSpotifyUserTokenCache(cmd.Context(), tokenPath)
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				defer unlock()
+			}
+			if err := auth.SaveSpotifyUserToken(tokenPath, token); err != nil {
+				return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("write spotify token cache: %w", err))
+			}
+			fmt.Fprintf(
+				app.IO.Out,
+				"spotify-login: saved user token to %s (access token expires %s; refreshed automatically)\n",
+				tokenPath,
+				time.Unix(token.ExpiresAt, 0).Local().Format(time.RFC3339),
+			)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.RedirectURI, "redirect-uri", opts.RedirectURI, "OAuth redirect URI registered for the Spotify app")
+	cmd.Flags().BoolVar(&opts.Refresh, "refresh", false, "Refresh the cached user token with its refresh token instead of logging in")
+	return cmd
+}
+
+func newSpotifyLoginState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate oauth state: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
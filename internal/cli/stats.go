@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+const defaultStatsDays = 90
+
+// statsHeatGlyphs shade a weekday cell by the share of its runs that found
+// new tracks: none, then up to a quarter, half, three quarters, and all.
+var statsHeatGlyphs = []string{"·", "░", "▒", "▓", "█"}
+
+func newStatsCommand(app *AppContext) *cobra.Command {
+	var sourceIDs []string
+	var days int
+	var suggestSchedule bool
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show on which weekdays sources get new tracks",
+		Long: "Read <state_dir>/history.jsonl and show, per source, a weekday heat map of the finished syncs that downloaded new tracks. " +
+			"With --suggest-schedule, each source also gets a sync.schedule fitted to the weekdays its playlist actually changes, " +
+			"so `udl watch` stops running it on days that never bring anything new.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if days < 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --days %d (must be >= 0)", days))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			since := time.Time{}
+			if days > 0 {
+				since = time.Now().AddDate(0, 0, -days)
+			}
+			stats, err := (workflows.StatsUseCase{}).Run(cfg, sourceIDs, since)
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+			if !suggestSchedule {
+				for i := range stats {
+					stats[i].Suggestion = nil
+				}
+			}
+
+			if app.Opts.JSON {
+				encoder := json.NewEncoder(app.IO.Out)
+				if err := encoder.Encode(map[string]any{"sources": stats}); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			if len(stats) == 0 {
+				fmt.Fprintln(app.IO.Out, "No sources configured.")
+				return nil
+			}
+			for _, source := range stats {
+				for _, line := range formatSourceActivityLines(source) {
+					fmt.Fprintln(app.IO.Out, line)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sourceIDs, "source", nil, "Show only selected source id (repeatable)")
+	cmd.Flags().IntVar(&days, "days", defaultStatsDays, "Only count runs from the last N days (0 = all)")
+	cmd.Flags().BoolVar(&suggestSchedule, "suggest-schedule", false, "Suggest a sync.schedule per source from the weekdays it gets new tracks")
+	return cmd
+}
+
+func formatSourceActivityLines(stats engine.SourceActivityStats) []string {
+	schedule := stats.Schedule
+	if schedule == "" {
+		schedule = "none"
+	}
+	lines := []string{fmt.Sprintf(
+		"[%s] runs=%d with_new=%d tracks=%d schedule=%s",
+		stats.SourceID,
+		stats.Runs,
+		stats.RunsWithNewTracks,
+		stats.Tracks,
+		schedule,
+	)}
+	header := "       "
+	heat := "  heat "
+	runs := "  runs "
+	withNew := "  new  "
+	for _, day := range stats.Weekdays {
+		header += fmt.Sprintf(" %4s", day.Weekday)
+		heat += fmt.Sprintf(" %4s", statsHeatGlyph(day))
+		runs += fmt.Sprintf(" %4d", day.Runs)
+		withNew += fmt.Sprintf(" %4d", day.RunsWithNewTracks)
+	}
+	lines = append(lines, header, heat, withNew, runs)
+	if suggestion := stats.Suggestion; suggestion != nil {
+		if suggestion.Schedule == "" {
+			lines = append(lines, "  suggest: "+suggestion.Reason)
+		} else {
+			lines = append(lines, fmt.Sprintf("  suggest: schedule: %q (%s)", suggestion.Schedule, suggestion.Reason))
+		}
+	}
+	return lines
+}
+
+func statsHeatGlyph(day engine.WeekdayActivity) string {
+	if day.Runs == 0 {
+		return " "
+	}
+	if day.RunsWithNewTracks == 0 {
+		return statsHeatGlyphs[0]
+	}
+	level := (day.RunsWithNewTracks*4 + day.Runs - 1) / day.Runs
+	return statsHeatGlyphs[level]
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/engine"
+)
+
+func TestFormatSourceActivityLinesRendersHeatMapAndSuggestion(t *testing.T) {
+	weekdays := make([]engine.WeekdayActivity, 7)
+	for day, name := range []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"} {
+		weekdays[day] = engine.WeekdayActivity{Weekday: name, Runs: 4}
+	}
+	weekdays[5].RunsWithNewTracks = 4
+	weekdays[6].RunsWithNewTracks = 1
+	weekdays[3].Runs = 0
+
+	lines := formatSourceActivityLines(engine.SourceActivityStats{
+		SourceID:          "mix",
+		Runs:              24,
+		RunsWithNewTracks: 5,
+		Tracks:            9,
+		Weekdays:          weekdays,
+		Suggestion: &engine.ScheduleSuggestion{
+			Schedule: "0 9 * * 5,6",
+			Reason:   "new tracks only on Fri, Sat; 16 of 24 runs fell on other days and found nothing",
+		},
+	})
+	got := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"[mix] runs=24 with_new=5 tracks=9 schedule=none",
+		"         Sun  Mon  Tue  Wed  Thu  Fri  Sat",
+		"  heat     ·    ·    ·         ·    █    ░",
+		"  new      0    0    0    0    0    4    1",
+		`  suggest: schedule: "0 9 * * 5,6" (new tracks only on Fri, Sat; 16 of 24 runs fell on other days and found nothing)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, got)
+		}
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}}
+	cmd := newStatsCommand(app)
+	cmd.SetArgs([]string{"--days", "-1"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid --days -1") {
+		t.Fatalf("expected invalid --days error, got %v", err)
+	}
+}
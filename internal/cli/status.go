@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+func newStatusCommand(app *AppContext) *cobra.Command {
+	var sourceIDs []string
+	var tags []string
+	var excludeTags []string
+
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show per-source sync state without downloading",
+		Long: "Read each source's state file, archive, and target_dir and report when it last synced, " +
+			"how many tracks are known, how many local media files exist, and whether gaps are detected. " +
+			"Sources whose last sync failed also show that error with pointers to the failure log and run report. " +
+			"Monitor sources also show how many tracks they recorded and how many are not pulled yet. " +
+			"No adapters or remote lookups are run.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			selectedIDs, err := resolveSourceSelection(cfg, sourceIDs, tags, excludeTags)
+			if err != nil {
+				return err
+			}
+
+			statuses, err := (workflows.StatusUseCase{}).Run(cfg, selectedIDs)
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			if app.Opts.JSON {
+				encoder := json.NewEncoder(app.IO.Out)
+				if err := encoder.Encode(map[string]any{"sources": statuses}); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			if len(statuses) == 0 {
+				fmt.Fprintln(app.IO.Out, "No sources configured.")
+				return nil
+			}
+			for _, status := range statuses {
+				fmt.Fprintln(app.IO.Out, formatSourceStatusLine(status))
+				for _, line := range formatSourceLastErrorLines(status) {
+					fmt.Fprintln(app.IO.Out, line)
+				}
+				for _, problem := range status.Errors {
+					fmt.Fprintf(app.IO.ErrOut, "[%s] error: %s\n", status.SourceID, problem)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sourceIDs, "source", nil, "Show only selected source id (repeatable)")
+	addSourceTagFlags(cmd, &tags, &excludeTags)
+	return cmd
+}
+
+func formatSourceStatusLine(status engine.SourceStatus) string {
+	lastSynced := "never"
+	if status.LastSyncedAt != nil {
+		lastSynced = status.LastSyncedAt.Local().Format(time.RFC3339)
+	}
+	gaps := "n/a"
+	if status.GapsChecked {
+		gaps = strconv.Itoa(status.Gaps)
+	}
+	line := fmt.Sprintf(
+		"[%s] %s/%s last_synced=%s known=%d local=%d gaps=%s",
+		status.SourceID,
+		status.Type,
+		status.Adapter,
+		lastSynced,
+		status.KnownTracks,
+		status.LocalFiles,
+		gaps,
+	)
+	if status.Monitor {
+		line += fmt.Sprintf(" monitor=on monitored=%d unpulled=%d", status.Monitored, status.Unpulled)
+	}
+	if !status.Enabled {
+		line += " (disabled)"
+	}
+	return line
+}
+
+// formatSourceLastErrorLines renders the persisted last failure as one summary
+// line plus one line per log pointer, most specific first.
+func formatSourceLastErrorLines(status engine.SourceStatus) []string {
+	lastErr := status.LastError
+	if lastErr == nil {
+		return nil
+	}
+	class := lastErr.Class
+	if class == "" {
+		class = "runtime"
+	}
+	message := strings.Join(strings.Fields(lastErr.Message), " ")
+	lines := []string{fmt.Sprintf(
+		"[%s]   last_error=%s class=%s: %s",
+		status.SourceID,
+		lastErr.At.Local().Format(time.RFC3339),
+		class,
+		message,
+	)}
+	for _, pointer := range []struct{ label, path string }{
+		{"failure_log", lastErr.FailureLog},
+		{"run_report", lastErr.RunReport},
+		{"log_file", lastErr.LogFile},
+	} {
+		if strings.TrimSpace(pointer.path) == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("[%s]   %s=%s", status.SourceID, pointer.label, pointer.path))
+	}
+	return lines
+}
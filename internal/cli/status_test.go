@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStatusCommandPrintsHumanAndJSON(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + filepath.Join(tmp, "state") + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + filepath.Join(tmp, "music") + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newStatusCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !strings.Contains(out.String(), "[sc] soundcloud/scdl last_synced=never known=0 local=0 gaps=0") {
+		t.Fatalf("unexpected human output: %q", out.String())
+	}
+
+	out.Reset()
+	app.Opts.JSON = true
+	cmd = newStatusCommand(app)
+	cmd.SetArgs([]string{"--source", "sc"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("status --json: %v", err)
+	}
+	decoded := map[string][]map[string]any{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json: %v (%s)", err, out.String())
+	}
+	if len(decoded["sources"]) != 1 || decoded["sources"][0]["source_id"] != "sc" {
+		t.Fatalf("unexpected json payload: %s", out.String())
+	}
+}
+
+func TestStatusCommandShowsSourceLastError(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + filepath.Join(tmp, "music") + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	failureLog := filepath.Join(stateDir, "sync-failures.jsonl")
+	lastErrors := `{"sources":{"sc":{"message":"[sc] command failed with exit code 1\nsee log","class":"command",` +
+		`"at":"2026-04-02T10:30:00Z","run_id":"20260402T103000Z","failure_log":"` + failureLog + `"}}}`
+	if err := os.WriteFile(filepath.Join(stateDir, "source-errors.json"), []byte(lastErrors), 0o644); err != nil {
+		t.Fatalf("write source errors: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newStatusCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if !strings.Contains(out.String(), "class=command: [sc] command failed with exit code 1 see log") {
+		t.Fatalf("expected last_error line, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "[sc]   failure_log="+failureLog) || strings.Contains(out.String(), "run_report=") {
+		t.Fatalf("unexpected log pointer lines: %q", out.String())
+	}
+
+	out.Reset()
+	app.Opts.JSON = true
+	cmd = newStatusCommand(app)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("status --json: %v", err)
+	}
+	decoded := map[string][]map[string]any{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("decode json: %v (%s)", err, out.String())
+	}
+	lastError, ok := decoded["sources"][0]["last_error"].(map[string]any)
+	if !ok || lastError["class"] != "command" || lastError["run_id"] != "20260402T103000Z" {
+		t.Fatalf("unexpected json last_error: %s", out.String())
+	}
+}
@@ -11,9 +11,12 @@ import (
 	"time"
 
 	"github.com/jaa/update-downloads/internal/adapters/deemix"
+	"github.com/jaa/update-downloads/internal/adapters/gamdl"
 	"github.com/jaa/update-downloads/internal/adapters/scdl"
 	"github.com/jaa/update-downloads/internal/adapters/scdlfreedl"
 	"github.com/jaa/update-downloads/internal/adapters/spotdl"
+	"github.com/jaa/update-downloads/internal/adapters/tidaldl"
+	"github.com/jaa/update-downloads/internal/adapters/ytdlp"
 	workflows "github.com/jaa/update-downloads/internal/app"
 	"github.com/jaa/update-downloads/internal/config"
 	"github.com/jaa/update-downloads/internal/engine"
@@ -24,15 +27,30 @@ import (
 
 func newSyncCommand(app *AppContext) *cobra.Command {
 	var sourceIDs []string
+	var tags []string
+	var excludeTags []string
 	var timeout time.Duration
 	var askOnExisting bool
 	var scanGaps bool
+	var onlyGaps bool
+	var limit int
 	var noPreflight bool
+	var ordered bool
+	var resume bool
+	var force bool
+	var applyPrune bool
+	var noHTTPCache bool
+	var waitLock bool
 	var plan bool
 	var planLimit int
 	var progressMode string
+	var outputRenderer string
+	var uiMode string
 	var preflightSummaryMode string
 	var trackStatusMode string
+	var logFile string
+	var logFileMaxMB int
+	var logFileBackups int
 
 	cmd := &cobra.Command{
 		Use:   "sync",
@@ -44,13 +62,19 @@ Operational notes:
 - SoundCloud sync for adapter.kind=scdl uses SCDL_CLIENT_ID when set, otherwise falls back to the managed macOS Keychain entry.
 - For Spotify, adapter.kind=deemix is recommended when available.
 - Legacy spotdl can be throttled when using shared/default Spotify app credentials; use user-owned credentials.
-- If spotdl auth retry happens and --headless is enabled, rerun without --headless for browser-led OAuth.
+- If spotdl auth retry happens and spotdl's --headless is in adapter.extra_args, rerun without it for browser-led OAuth.
+- udl --headless (or UDL_HEADLESS=1) implies --json and --no-input, never opens a browser for free-DL gates, and exits non-zero when gates were deferred.
 `),
 		Example: strings.TrimSpace(`
   udl sync
   udl sync --dry-run
   udl sync --source soundcloud-likes --scan-gaps
+  udl sync --source soundcloud-likes --limit 20
+  udl sync --source soundcloud-likes --only-gaps
+  udl sync --tag weekly --exclude-tag paused
+  udl sync --ui tui
   udl sync --source spotify-legacy --timeout 20m -v
+  udl sync --resume
 `),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			parsedProgressMode, err := parseProgressMode(progressMode)
@@ -65,13 +89,60 @@ Operational notes:
 			if err != nil {
 				return withExitCode(exitcode.InvalidUsage, err)
 			}
+			rendererName, err := resolveOutputRenderer(app, outputRenderer)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, err)
+			}
+			dashboard, err := parseSyncUIMode(uiMode)
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, err)
+			}
+			if dashboard && cmd.Flags().Changed("output-renderer") && rendererName != output.RendererDashboard {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--ui tui cannot be combined with --output-renderer %s", rendererName))
+			}
+			dashboard = dashboard || rendererName == output.RendererDashboard
+			if dashboard {
+				switch {
+				case app.Opts.JSON || app.Opts.Headless:
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--ui tui cannot be used with --json or --headless"))
+				case plan:
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--ui tui cannot be combined with --plan"))
+				case !isTTY(os.Stdout):
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--ui tui requires an interactive TTY on stdout"))
+				}
+				rendererName = output.RendererDashboard
+			}
+			if logFileMaxMB <= 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --log-file-max-mb %d (must be > 0)", logFileMaxMB))
+			}
+			if logFileBackups < 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --log-file-backups %d (must be >= 0)", logFileBackups))
+			}
 			if planLimit < 0 {
 				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --plan-limit %d (must be >= 0; 0 means unlimited)", planLimit))
 			}
 			if cmd.Flags().Changed("plan-limit") && !plan {
 				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--plan-limit requires --plan"))
 			}
+			if limit < 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --limit %d (must be >= 0; 0 means unlimited)", limit))
+			}
+			if onlyGaps && scanGaps {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--only-gaps cannot be combined with --scan-gaps"))
+			}
+			if (onlyGaps || limit > 0) && noPreflight {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--only-gaps and --limit cannot be combined with --no-preflight"))
+			}
+			if plan && resume {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--plan cannot be combined with --resume"))
+			}
+			if app.Opts.Headless && parsedProgressMode == "always" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--progress always cannot be used with --headless"))
+			}
 			if plan {
+				if app.Opts.Headless {
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--plan cannot be used with --headless"))
+				}
 				if app.Opts.JSON {
 					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--plan cannot be used with --json"))
 				}
@@ -87,6 +158,9 @@ Operational notes:
 				if cmd.Flags().Changed("no-preflight") {
 					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--plan cannot be combined with --no-preflight"))
 				}
+				if onlyGaps || cmd.Flags().Changed("limit") {
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--plan cannot be combined with --only-gaps or --limit; use --plan-limit"))
+				}
 				if !isTTY(os.Stdin) || !isTTY(os.Stdout) {
 					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--plan requires an interactive TTY on stdin and stdout"))
 				}
@@ -99,56 +173,56 @@ Operational notes:
 			if err := config.Validate(cfg); err != nil {
 				return withExitCode(exitcode.InvalidConfig, err)
 			}
+			selectedIDs, err := resolveSourceSelection(cfg, sourceIDs, tags, excludeTags)
+			if err != nil {
+				return err
+			}
 
-			humanStdout := app.IO.Out
-			humanStderr := app.IO.ErrOut
-			runnerStdout := app.IO.Out
-			runnerStderr := app.IO.ErrOut
-			var compactWriter *output.CompactLogWriter
-			if app.Opts.JSON {
-				runnerStdout = app.IO.ErrOut
-			} else if app.Opts.Quiet {
-				runnerStdout = io.Discard
-				runnerStderr = io.Discard
-			} else if !app.Opts.Verbose {
-				interactive := output.SupportsInPlaceUpdates(app.IO.Out)
-				switch parsedProgressMode {
-				case "always":
-					interactive = true
-				case "never":
-					interactive = false
+			interactive := output.SupportsInPlaceUpdates(app.IO.Out) && !app.Opts.Headless
+			switch parsedProgressMode {
+			case "always":
+				interactive = true
+			case "never":
+				interactive = false
+			}
+			renderer, err := output.NewRenderer(rendererName, output.RendererOptions{
+				Stdout:           app.IO.Out,
+				Stderr:           app.IO.ErrOut,
+				Interactive:      interactive,
+				Color:            interactive && !app.Opts.NoColor && os.Getenv("NO_COLOR") == "",
+				Quiet:            app.Opts.Quiet,
+				Verbose:          app.Opts.Verbose,
+				PreflightSummary: parsedPreflightSummaryMode,
+				TrackStatus:      string(parsedTrackStatusMode),
+			})
+			if err != nil {
+				return withExitCode(exitcode.InvalidUsage, err)
+			}
+			if closer, ok := renderer.(io.Closer); ok {
+				defer closer.Close()
+			}
+			runnerStdout, runnerStderr := renderer.SubprocessOutput()
+			var emitter output.EventEmitter = renderer
+			logPath := ""
+			if strings.TrimSpace(logFile) != "" {
+				logPath, err = config.ExpandPath(logFile)
+				if err != nil {
+					return withExitCode(exitcode.InvalidUsage, fmt.Errorf("resolve --log-file: %w", err))
 				}
-				compactWriter = output.NewCompactLogWriterWithOptions(app.IO.Out, output.CompactLogOptions{
-					Interactive:      interactive,
-					PreflightSummary: parsedPreflightSummaryMode,
-					TrackStatus:      string(parsedTrackStatusMode),
+				sink, err := output.OpenRotatingFile(logPath, output.RotatingFileOptions{
+					MaxBytes:   int64(logFileMaxMB) << 20,
+					MaxBackups: logFileBackups,
 				})
-				humanStdout = compactWriter
-				runnerStdout = compactWriter
-				runnerStderr = compactWriter
-			}
-
-			var emitter output.EventEmitter
-			if app.Opts.JSON {
-				emitter = output.NewJSONEmitter(app.IO.Out)
-			} else {
-				humanEmitter := output.NewHumanEmitter(humanStdout, humanStderr, app.Opts.Quiet, app.Opts.Verbose)
-				if compactWriter != nil {
-					emitter = output.NewObservingEmitter(compactWriter, humanEmitter)
-				} else {
-					emitter = humanEmitter
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("open --log-file: %w", err))
 				}
+				defer sink.Close()
+				emitter = output.NewMultiEmitter(emitter, output.NewJSONEmitter(sink))
 			}
 			runner := engine.NewSubprocessRunner(app.IO.In, runnerStdout, runnerStderr)
 
-			registry := map[string]engine.Adapter{
-				"deemix":      deemix.New(),
-				"spotdl":      spotdl.New(),
-				"scdl":        scdl.New(),
-				"scdl-freedl": scdlfreedl.New(),
-			}
 			useCase := workflows.SyncUseCase{
-				Registry: registry,
+				Registry: syncAdapterRegistry(),
 				Runner:   runner,
 				Emitter:  emitter,
 			}
@@ -158,7 +232,7 @@ Operational notes:
 
 			interaction := buildCLIInteraction(app, cfg, planLimit, app.Opts.DryRun)
 			result, runErr := useCase.Run(ctx, cfg, workflows.SyncRequest{
-				SourceIDs:        sourceIDs,
+				SourceIDs:        selectedIDs,
 				DryRun:           app.Opts.DryRun,
 				TimeoutOverride:  timeout,
 				Plan:             plan,
@@ -166,14 +240,24 @@ Operational notes:
 				AskOnExisting:    askOnExisting,
 				AskOnExistingSet: cmd.Flags().Changed("ask-on-existing"),
 				ScanGaps:         scanGaps,
+				OnlyGaps:         onlyGaps,
+				Limit:            limit,
 				NoPreflight:      noPreflight,
-				AllowPrompt:      !app.Opts.NoInput && !app.Opts.JSON && isTTY(os.Stdin),
+				Ordered:          ordered,
+				Resume:           resume,
+				Force:            force,
+				ApplyPrune:       applyPrune,
+				NoHTTPCache:      noHTTPCache,
+				NoBrowser:        app.Opts.Headless,
+				WaitForLock:      waitLock,
+				AllowPrompt:      !app.Opts.NoInput && !app.Opts.JSON && !dashboard && isTTY(os.Stdin),
 				TrackStatus:      parsedTrackStatusMode,
+				LogFile:          logPath,
 			}, interaction)
 			if runErr != nil {
 				var selectionErr *engine.SelectionError
 				switch {
-				case errors.As(runErr, &selectionErr):
+				case errors.As(runErr, &selectionErr), errors.Is(runErr, engine.ErrNoResumeCheckpoint):
 					return withExitCode(exitcode.InvalidUsage, runErr)
 				case errors.Is(runErr, engine.ErrInterrupted):
 					return withExitCode(exitcode.Interrupted, runErr)
@@ -188,23 +272,89 @@ Operational notes:
 			if result.Failed > 0 {
 				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("sync finished with failed sources (%d)", result.Failed))
 			}
+			if app.Opts.Headless && result.DeferredGates > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("sync deferred %d free-DL browser gate(s); --headless opens no browser", result.DeferredGates))
+			}
 			return nil
 		},
 	}
 
 	cmd.Flags().StringArrayVar(&sourceIDs, "source", nil, "Run only selected source id (repeatable)")
+	addSourceTagFlags(cmd, &tags, &excludeTags)
 	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Override per-source command timeout (e.g. 10m, 1h)")
 	cmd.Flags().BoolVar(&askOnExisting, "ask-on-existing", false, "Prompt once when first existing track is found and optionally continue with gap scan")
 	cmd.Flags().BoolVar(&scanGaps, "scan-gaps", false, "Continue full remote scan to fill archive and local-file gaps")
+	cmd.Flags().BoolVar(&onlyGaps, "only-gaps", false, "Download only known and archive gaps below the first local track, not new tracks at the head of the playlist")
+	cmd.Flags().IntVar(&limit, "limit", 0, "Download at most this many planned tracks per source; the rest stay planned for the next run (0 = unlimited)")
 	cmd.Flags().BoolVar(&noPreflight, "no-preflight", false, "Skip remote preflight diff stage for supported adapters")
+	cmd.Flags().BoolVar(&ordered, "ordered", false, "Write state entries and done events in remote playlist order even when tracks finish out of order")
+	cmd.Flags().BoolVar(&resume, "resume", false, "Continue the last interrupted sync: only its unfinished sources and not-yet-downloaded planned tracks")
+	cmd.Flags().BoolVar(&force, "force", false, "Proceed even when a source's remote track count dropped past sync.max_remote_shrink_percent")
+	cmd.Flags().BoolVar(&applyPrune, "apply", false, "Move tracks removed from the remote playlist to trash without asking (sources with sync.prune)")
+	cmd.Flags().BoolVar(&noHTTPCache, "no-http-cache", false, "Fetch remote playlist pages and API responses without the on-disk ETag cache")
+	cmd.Flags().BoolVar(&waitLock, "wait-lock", false, "Wait for another udl process syncing the same source to finish instead of failing that source")
 	cmd.Flags().BoolVar(&plan, "plan", false, "Interactive plan mode for selecting tracks to download (currently adapter.kind=scdl only)")
 	cmd.Flags().IntVar(&planLimit, "plan-limit", 10, "Per-source remote track check limit in --plan mode (0 = unlimited)")
 	cmd.Flags().StringVar(&progressMode, "progress", "auto", "Progress rendering mode: auto, always, or never")
+	cmd.Flags().StringVar(&uiMode, "ui", "auto", "Sync view: auto (the --output-renderer view) or tui (live dashboard of every source; implies no prompts)")
+	cmd.Flags().StringVar(&outputRenderer, "output-renderer", "", "Output renderer: compact, plain, minimal, json, or dashboard (same as --ui tui) (default: json with --json, plain with --quiet/--verbose, compact otherwise)")
 	cmd.Flags().StringVar(&preflightSummaryMode, "preflight-summary", "auto", "Preflight summary output: auto, always, or never")
 	cmd.Flags().StringVar(&trackStatusMode, "track-status", "names", "Per-track status output: names, count, or none")
+	cmd.Flags().StringVar(&logFile, "log-file", "", "Also write every event as newline-delimited JSON to this file (rotated by size)")
+	cmd.Flags().IntVar(&logFileMaxMB, "log-file-max-mb", int(output.DefaultLogFileMaxBytes>>20), "Rotate --log-file after this many MiB")
+	cmd.Flags().IntVar(&logFileBackups, "log-file-backups", output.DefaultLogFileMaxBackups, "Rotated --log-file copies to keep")
 	return cmd
 }
 
+// syncAdapterRegistry maps adapter.kind values to their implementations.
+func syncAdapterRegistry() map[string]engine.Adapter {
+	return map[string]engine.Adapter{
+		"deemix":      deemix.New(),
+		"spotdl":      spotdl.New(),
+		"scdl":        scdl.New(),
+		"scdl-freedl": scdlfreedl.New(),
+		"ytdlp":       ytdlp.New(),
+		"tidal-dl":    tidaldl.New(),
+		"gamdl":       gamdl.New(),
+	}
+}
+
+// parseSyncUIMode reports whether --ui asks for the live dashboard.
+func parseSyncUIMode(raw string) (bool, error) {
+	switch strings.TrimSpace(strings.ToLower(raw)) {
+	case "", "auto":
+		return false, nil
+	case "tui":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid --ui %q (expected auto or tui)", raw)
+}
+
+// resolveOutputRenderer picks the --output-renderer name, defaulting to the
+// view the global --json/--quiet/--verbose flags imply.
+func resolveOutputRenderer(app *AppContext, raw string) (string, error) {
+	name := strings.TrimSpace(strings.ToLower(raw))
+	if name == "" {
+		switch {
+		case app.Opts.JSON:
+			return output.RendererJSON, nil
+		case app.Opts.Quiet, app.Opts.Verbose:
+			return output.RendererPlain, nil
+		default:
+			return output.RendererCompact, nil
+		}
+	}
+	if app.Opts.JSON && name != output.RendererJSON {
+		return "", fmt.Errorf("--output-renderer %s cannot be used with --json", name)
+	}
+	for _, known := range output.RendererNames() {
+		if name == known {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid --output-renderer %q (expected: %s)", raw, strings.Join(output.RendererNames(), ", "))
+}
+
 func parseProgressMode(raw string) (string, error) {
 	mode := strings.TrimSpace(strings.ToLower(raw))
 	switch mode {
@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/jaa/update-downloads/internal/exitcode"
 )
 
 func writeDryRunConfig(t *testing.T, dir string) string {
@@ -118,6 +120,40 @@ func TestSyncRejectsInvalidProgressMode(t *testing.T) {
 	}
 }
 
+func TestSyncOutputRendererSelection(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := writeDryRunConfig(t, tmp)
+
+	run := func(args ...string) (string, error) {
+		stdout := &bytes.Buffer{}
+		app := &AppContext{
+			Build: BuildInfo{Version: "test"},
+			IO:    IOStreams{In: strings.NewReader(""), Out: stdout, ErrOut: &bytes.Buffer{}},
+		}
+		root := newRootCommand(app)
+		root.SetArgs(append([]string{"sync", "--config", configPath, "--dry-run"}, args...))
+		err := root.Execute()
+		return stdout.String(), err
+	}
+
+	out, err := run("--output-renderer", "json")
+	if err != nil {
+		t.Fatalf("json renderer: %v", err)
+	}
+	if !strings.HasPrefix(out, "{") || !strings.Contains(out, `"event":"sync_finished"`) {
+		t.Fatalf("expected JSON events, got:\n%s", out)
+	}
+	if _, err := run("--output-renderer", "minimal"); err != nil {
+		t.Fatalf("minimal renderer: %v", err)
+	}
+	if _, err := run("--output-renderer", "fancy"); err == nil || !strings.Contains(err.Error(), "invalid --output-renderer") {
+		t.Fatalf("expected unknown renderer guidance, got: %v", err)
+	}
+	if _, err := run("--json", "--output-renderer", "plain"); err == nil || !strings.Contains(err.Error(), "cannot be used with --json") {
+		t.Fatalf("expected --json conflict, got: %v", err)
+	}
+}
+
 func TestSyncDryRunAcceptsOutputModeFlags(t *testing.T) {
 	tmp := t.TempDir()
 	configPath := writeDryRunConfig(t, tmp)
@@ -255,6 +291,7 @@ func TestSyncPlanRejectsIncompatibleFlowFlags(t *testing.T) {
 		{name: "scan-gaps", flag: "--scan-gaps", errSnippet: "--plan cannot be combined with --scan-gaps"},
 		{name: "ask-on-existing", flag: "--ask-on-existing", errSnippet: "--plan cannot be combined with --ask-on-existing"},
 		{name: "no-preflight", flag: "--no-preflight", errSnippet: "--plan cannot be combined with --no-preflight"},
+		{name: "resume", flag: "--resume", errSnippet: "--plan cannot be combined with --resume"},
 	}
 
 	for _, tc := range testCases {
@@ -282,6 +319,26 @@ func TestSyncPlanRejectsIncompatibleFlowFlags(t *testing.T) {
 	}
 }
 
+func TestSyncResumeWithoutCheckpointIsUsageError(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := writeDryRunConfig(t, tmp)
+
+	app := &AppContext{
+		Build: BuildInfo{Version: "test"},
+		IO:    IOStreams{In: strings.NewReader(""), Out: &bytes.Buffer{}, ErrOut: &bytes.Buffer{}},
+	}
+	root := newRootCommand(app)
+	root.SetArgs([]string{"sync", "--config", configPath, "--resume"})
+
+	err := root.Execute()
+	if err == nil || !strings.Contains(err.Error(), "no resume checkpoint found") {
+		t.Fatalf("expected missing checkpoint error, got %v", err)
+	}
+	if code := mapExitCode(err); code != exitcode.InvalidUsage {
+		t.Fatalf("expected usage exit code, got %d", code)
+	}
+}
+
 func TestSyncPlanRequiresTTY(t *testing.T) {
 	tmp := t.TempDir()
 	configPath := writeDryRunConfig(t, tmp)
@@ -303,3 +360,57 @@ func TestSyncPlanRequiresTTY(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestSyncHeadlessImpliesJSONAndRejectsInteractiveModes(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := writeDryRunConfig(t, tmp)
+
+	run := func(args ...string) (*AppContext, string, error) {
+		stdout := &bytes.Buffer{}
+		app := &AppContext{
+			Build: BuildInfo{Version: "test"},
+			IO:    IOStreams{In: strings.NewReader(""), Out: stdout, ErrOut: &bytes.Buffer{}},
+		}
+		root := newRootCommand(app)
+		root.SetArgs(append([]string{"--config", configPath}, args...))
+		err := root.Execute()
+		return app, stdout.String(), err
+	}
+
+	app, out, err := run("sync", "--dry-run", "--headless")
+	if err != nil {
+		t.Fatalf("sync --headless: %v", err)
+	}
+	if !app.Opts.JSON || !app.Opts.NoInput || !app.Opts.NoColor {
+		t.Fatalf("expected --headless to imply --json, --no-input and no color, got %+v", app.Opts)
+	}
+	if !strings.HasPrefix(out, "{") || !strings.Contains(out, `"event":"sync_finished"`) {
+		t.Fatalf("expected JSON events on stdout, got:\n%s", out)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"sync", "--dry-run", "--headless", "--progress", "always"}, want: "--progress always cannot be used with --headless"},
+		{args: []string{"sync", "--dry-run", "--headless", "--plan"}, want: "--plan cannot be used with --headless"},
+		{args: []string{"tui", "--headless"}, want: "cannot be used with --headless"},
+	} {
+		_, _, err := run(tc.args...)
+		if err == nil || !strings.Contains(err.Error(), tc.want) || mapExitCode(err) != exitcode.InvalidUsage {
+			t.Fatalf("%v: expected usage error %q, got %v", tc.args, tc.want, err)
+		}
+	}
+
+	t.Setenv(headlessEnv, "1")
+	if app, _, err := run("sync", "--dry-run"); err != nil || !app.Opts.Headless || !app.Opts.JSON {
+		t.Fatalf("expected %s=1 to enable headless mode, got opts=%+v err=%v", headlessEnv, app.Opts, err)
+	}
+	if app, _, err := run("sync", "--dry-run", "--headless=false"); err != nil || app.Opts.Headless || app.Opts.JSON {
+		t.Fatalf("expected --headless=false to override %s, got opts=%+v err=%v", headlessEnv, app.Opts, err)
+	}
+	t.Setenv(headlessEnv, "sometimes")
+	if _, _, err := run("sync", "--dry-run"); err == nil || !strings.Contains(err.Error(), "invalid UDL_HEADLESS") {
+		t.Fatalf("expected invalid %s guidance, got %v", headlessEnv, err)
+	}
+}
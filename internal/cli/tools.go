@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/tools"
+	"github.com/spf13/cobra"
+)
+
+type installFFmpegOptions struct {
+	URL     string
+	Archive string
+	SHA256  string
+	Force   bool
+}
+
+func newToolsCommand(app *AppContext) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tools",
+		Short: "Manage helper binaries installed under state_dir",
+	}
+	cmd.AddCommand(newToolsInstallFFmpegCommand(app))
+	return cmd
+}
+
+func newToolsInstallFFmpegCommand(app *AppContext) *cobra.Command {
+	opts := installFFmpegOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "install-ffmpeg",
+		Short: "Install checksum-verified ffmpeg/ffprobe into state_dir/tools/bin",
+		Long: "Download (--url) or read (--archive) an ffmpeg release archive, verify it against --sha256, " +
+			"and extract ffmpeg/ffprobe into <state_dir>/tools/bin. udl appends that directory to PATH for " +
+			"itself and the adapters it runs, so a system ffmpeg still takes precedence.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.TrimSpace(opts.URL) == "" && strings.TrimSpace(opts.Archive) == "" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("one of --url or --archive is required"))
+			}
+			if strings.TrimSpace(opts.URL) != "" && strings.TrimSpace(opts.Archive) != "" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--url and --archive are mutually exclusive"))
+			}
+			if strings.TrimSpace(opts.SHA256) == "" {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--sha256 is required; use the checksum published with the archive"))
+			}
+
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			binDir, err := tools.ManagedBinDir(cfg.Defaults.StateDir)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			source := strings.TrimSpace(opts.URL)
+			if source == "" {
+				source = strings.TrimSpace(opts.Archive)
+			}
+			if app.Opts.DryRun {
+				fmt.Fprintf(app.IO.Out, "[plan] install %s from %s into %s\n", strings.Join(tools.FFmpegBinaries, ", "), source, binDir)
+				return nil
+			}
+
+			result, err := tools.InstallFFmpeg(cmd.Context(), tools.InstallFFmpegOptions{
+				StateDir:    cfg.Defaults.StateDir,
+				URL:         opts.URL,
+				ArchivePath: opts.Archive,
+				SHA256:      opts.SHA256,
+				Force:       opts.Force,
+			})
+			if err != nil {
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			if app.Opts.JSON {
+				if err := json.NewEncoder(app.IO.Out).Encode(result); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+				return nil
+			}
+			for _, binary := range result.Installed {
+				fmt.Fprintf(app.IO.Out, "[done] installed %s into %s\n", binary, result.BinDir)
+			}
+			for _, binary := range result.Skipped {
+				fmt.Fprintf(app.IO.Out, "[skip] %s already installed in %s (use --force to replace)\n", binary, result.BinDir)
+			}
+			fmt.Fprintf(app.IO.Out, "tools: sha256 verified %s\n", result.SHA256)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.URL, "url", "", "HTTPS URL of an ffmpeg release archive (.zip, .tar.gz, or .tar)")
+	cmd.Flags().StringVar(&opts.Archive, "archive", "", "Local ffmpeg release archive to install instead of downloading")
+	cmd.Flags().StringVar(&opts.SHA256, "sha256", "", "Expected SHA-256 of the archive (required)")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Replace binaries already present in the managed dir")
+	return cmd
+}
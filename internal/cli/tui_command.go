@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jaa/update-downloads/internal/exitcode"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +14,9 @@ func newTUICommand(app *AppContext) *cobra.Command {
 		Use:   "tui",
 		Short: "Launch the main TUI setup and sync flow",
 		RunE: func(cmd *cobra.Command, args []string) (runErr error) {
+			if app.Opts.Headless {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("udl tui cannot be used with --headless"))
+			}
 			defer func() {
 				if recovered := recover(); recovered != nil {
 					_, _ = fmt.Fprint(app.IO.Out, "\x1b[?25h")
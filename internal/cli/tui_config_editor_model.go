@@ -97,6 +97,7 @@ type tuiConfigEditorModel struct {
 	fileExists         bool
 	prepareErr         error
 	parseErr           error
+	base               config.Config
 	defaults           config.Defaults
 	sources            []tuiConfigEditorSourceState
 	dirty              bool
@@ -216,6 +217,7 @@ func (m *tuiConfigEditorModel) loadTarget() {
 }
 
 func (m *tuiConfigEditorModel) applyConfig(cfg config.Config, dirty bool) {
+	m.base = cfg
 	m.defaults = cfg.Defaults
 	m.sources = make([]tuiConfigEditorSourceState, 0, len(cfg.Sources))
 	for _, source := range cfg.Sources {
@@ -331,10 +333,20 @@ func (m *tuiConfigEditorModel) revalidate() {
 }
 
 func (m tuiConfigEditorModel) buildConfig() config.Config {
+	// Sections the editor has no screens for (profiles, metadata providers,
+	// freedl tuning) are carried over from the loaded file unchanged.
+	version := m.base.Version
+	if version == 0 {
+		version = config.CurrentVersion
+	}
 	cfg := config.Config{
-		Version:  1,
-		Defaults: m.defaults,
-		Sources:  make([]config.Source, 0, len(m.sources)),
+		Version:           version,
+		Profile:           m.base.Profile,
+		Defaults:          m.defaults,
+		Profiles:          m.base.Profiles,
+		MetadataProviders: m.base.MetadataProviders,
+		FreeDL:            m.base.FreeDL,
+		Sources:           make([]config.Source, 0, len(m.sources)),
 	}
 	for _, source := range m.sources {
 		item := source
@@ -374,6 +374,9 @@ func tuiCredentialCardFromStatus(status auth.CredentialStatus, workflows string)
 	case auth.CredentialStorageSourceSpotDL:
 		storageLabel = "~/.spotdl/config.json"
 		external = true
+	case auth.CredentialStorageSourceStore:
+		storageLabel = "encrypted credential store (udl auth)"
+		external = true
 	}
 	switch status.Health {
 	case auth.CredentialHealthAvailable:
@@ -401,7 +401,7 @@ func (m *tuiOnboardingModel) toggleSourceType() {
 
 func (m tuiOnboardingModel) buildConfig() config.Config {
 	cfg := config.Config{
-		Version: 1,
+		Version: config.CurrentVersion,
 		Defaults: config.Defaults{
 			StateDir:              strings.TrimSpace(m.stateDir),
 			ArchiveFile:           m.startup.Defaults.ArchiveFile,
@@ -453,7 +453,7 @@ func (m *tuiOnboardingModel) revalidate() {
 func (m tuiOnboardingModel) saveAndCheckCmd() tea.Cmd {
 	cfg := m.buildConfig()
 	targetPath := m.startup.ConfigPath
-	targetDir, _ := config.ExpandPath(cfg.Sources[0].TargetDir)
+	targetDir, _ := config.ExpandTargetDir(cfg.Sources[0].TargetDir)
 	return func() tea.Msg {
 		switch m.sourceType {
 		case config.SourceTypeSoundCloud:
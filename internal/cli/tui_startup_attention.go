@@ -88,8 +88,12 @@ func tuiDetectStartupAttentionForConfig(cfg config.Config) *tuiStartupAttentionS
 					issue.SourceIDs = append(issue.SourceIDs, source.ID)
 				}
 			}
-		case source.Type == config.SourceTypeSpotify && source.Adapter.Kind == "deemix":
-			for _, kind := range []auth.CredentialKind{auth.CredentialKindDeemixARL, auth.CredentialKindSpotifyApp} {
+		case source.Adapter.Kind == "deemix":
+			kinds := []auth.CredentialKind{auth.CredentialKindDeemixARL}
+			if source.Type == config.SourceTypeSpotify {
+				kinds = append(kinds, auth.CredentialKindSpotifyApp)
+			}
+			for _, kind := range kinds {
 				status := statusByKind[kind]
 				if !tuiCredentialStatusBlocksStartup(status) {
 					continue
@@ -10,10 +10,6 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/jaa/update-downloads/internal/auth"
-	"github.com/jaa/update-downloads/internal/adapters/deemix"
-	"github.com/jaa/update-downloads/internal/adapters/scdl"
-	"github.com/jaa/update-downloads/internal/adapters/scdlfreedl"
-	"github.com/jaa/update-downloads/internal/adapters/spotdl"
 	workflows "github.com/jaa/update-downloads/internal/app"
 	"github.com/jaa/update-downloads/internal/config"
 	"github.com/jaa/update-downloads/internal/engine"
@@ -596,14 +592,9 @@ func (m tuiSyncModel) startRunCmd(runCtx context.Context) tea.Cmd {
 	return func() tea.Msg {
 		emitter := &tuiChannelEmitter{ch: ch}
 		useCase := workflows.SyncUseCase{
-			Registry: map[string]engine.Adapter{
-				"deemix":      deemix.New(),
-				"spotdl":      spotdl.New(),
-				"scdl":        scdl.New(),
-				"scdl-freedl": scdlfreedl.New(),
-			},
-			Runner:  engine.NewSubprocessRunner(m.app.IO.In, io.Discard, io.Discard),
-			Emitter: emitter,
+			Registry: syncAdapterRegistry(),
+			Runner:   engine.NewSubprocessRunner(m.app.IO.In, io.Discard, io.Discard),
+			Emitter:  emitter,
 		}
 		req := m.buildSyncRequest(selectedIDs)
 		sourceByID := map[string]config.Source{}
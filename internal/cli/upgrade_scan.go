@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type upgradeScanOptions struct {
+	SourceIDs    []string
+	Apply        bool
+	NoRemote     bool
+	ProbeTimeout time.Duration
+}
+
+func newUpgradeScanCommand(app *AppContext) *cobra.Command {
+	opts := upgradeScanOptions{ProbeTimeout: 10 * time.Second}
+
+	cmd := &cobra.Command{
+		Use:   "upgrade-scan",
+		Short: "Plan re-downloads of library files below their source's best quality tier",
+		Long: "Probe the codec and bitrate of every tracked file of deemix sources with a quality list and plan " +
+			"re-downloads of the files below the list's first tier. Deezer tracks are checked on api.deezer.com to " +
+			"drop ones that are no longer downloadable. Use --apply to move the planned files to trash and drop " +
+			"their state entries so the next sync downloads them again.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.ProbeTimeout <= 0 {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("--probe-timeout must be > 0"))
+			}
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if _, err := verifyLookPathFn("ffprobe"); err != nil {
+				return withExitCode(exitcode.MissingDependency, fmt.Errorf("required dependency %q not found in PATH (install ffmpeg)", "ffprobe"))
+			}
+
+			reports, err := engine.ScanUpgrades(cmd.Context(), cfg, opts.SourceIDs, engine.UpgradeScanOptions{
+				ProbeTimeout: opts.ProbeTimeout,
+				NoRemote:     opts.NoRemote,
+			})
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			scanned, planned, unavailable, problems := 0, 0, 0, 0
+			for _, report := range reports {
+				scanned += report.Scanned
+				planned += len(report.Planned())
+				unavailable += len(report.Candidates) - len(report.Planned())
+				problems += len(report.Errors)
+			}
+
+			moved := 0
+			previewApply := opts.Apply && app.Opts.DryRun
+			if opts.Apply && !app.Opts.DryRun && planned > 0 {
+				moved, err = engine.ApplyUpgradePlan(cmd.Context(), cfg, reports, time.Now().Format("20060102-150405"))
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("apply upgrade plan (%d file(s) moved): %w", moved, err))
+				}
+			}
+
+			if app.Opts.JSON {
+				encoder := json.NewEncoder(app.IO.Out)
+				payload := map[string]any{"sources": reports, "moved": moved}
+				if err := encoder.Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				for _, report := range reports {
+					printUpgradeScanReport(app, report)
+				}
+				mode := "plan"
+				if previewApply {
+					mode = "apply-preview"
+				} else if opts.Apply {
+					mode = "apply"
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"upgrade-scan: summary sources=%d scanned=%d planned=%d unavailable=%d moved=%d mode=%s\n",
+					len(reports),
+					scanned,
+					planned,
+					unavailable,
+					moved,
+					mode,
+				)
+				if moved > 0 {
+					fmt.Fprintln(app.IO.Out, "upgrade-scan: run `udl sync` to download the moved tracks again")
+				}
+			}
+
+			if problems > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("upgrade-scan finished with %d error(s)", problems))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.SourceIDs, "source", nil, "Scan only selected source id (repeatable)")
+	cmd.Flags().BoolVar(&opts.Apply, "apply", false, "Move planned files to trash and drop their state entries so the next sync re-downloads them (honors --dry-run)")
+	cmd.Flags().BoolVar(&opts.NoRemote, "no-remote", false, "Skip the api.deezer.com availability checks")
+	cmd.Flags().DurationVar(&opts.ProbeTimeout, "probe-timeout", opts.ProbeTimeout, "Per-file ffprobe timeout")
+	return cmd
+}
+
+func printUpgradeScanReport(app *AppContext, report engine.SourceUpgradeReport) {
+	if report.Skipped != "" {
+		fmt.Fprintf(app.IO.Out, "[%s] skipped: %s\n", report.SourceID, report.Skipped)
+		return
+	}
+	fmt.Fprintf(
+		app.IO.Out,
+		"[%s] target=%s scanned=%d up-to-date=%d candidates=%d\n",
+		report.SourceID,
+		report.TargetQuality,
+		report.Scanned,
+		report.UpToDate,
+		len(report.Candidates),
+	)
+	for i, candidate := range report.Candidates {
+		if !app.Opts.Verbose && i >= 20 {
+			fmt.Fprintf(app.IO.Out, "  ... %d more (use --verbose to list all)\n", len(report.Candidates)-i)
+			break
+		}
+		label := "upgrade"
+		if candidate.Remote == engine.UpgradeRemoteUnavailable {
+			label = "unavailable"
+		}
+		local := candidate.LocalQuality
+		if candidate.Codec != "" {
+			local = strings.TrimSpace(fmt.Sprintf("%s %s", candidate.Codec, formatUpgradeBitrate(candidate.BitrateKbps)))
+		}
+		fmt.Fprintf(
+			app.IO.Out,
+			"  [%s] %s (%s -> %s, remote=%s) %s\n",
+			label,
+			candidate.Label,
+			local,
+			candidate.TargetQuality,
+			candidate.Remote,
+			candidate.LocalPath,
+		)
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(app.IO.ErrOut, "[%s] error: %s\n", report.SourceID, problem)
+	}
+}
+
+func formatUpgradeBitrate(kbps int) string {
+	if kbps <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%dk", kbps)
+}
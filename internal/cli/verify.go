@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/spf13/cobra"
+)
+
+type verifyOptions struct {
+	SourceIDs    []string
+	Fix          bool
+	NoProbe      bool
+	ProbeTimeout time.Duration
+}
+
+var verifyLookPathFn = exec.LookPath
+
+func newVerifyCommand(app *AppContext) *cobra.Command {
+	opts := verifyOptions{ProbeTimeout: 10 * time.Second}
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Cross-check state files against the library on disk",
+		Long: "Report tracks recorded in state but missing on disk, media files in target_dir that no state file " +
+			"references, and zero-byte or unreadable media (via ffprobe). Use --fix to prune stale state entries " +
+			"so the next sync downloads them again.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+
+			verify := engine.VerifyOptions{Probe: !opts.NoProbe, ProbeTimeout: opts.ProbeTimeout}
+			if verify.Probe {
+				if _, err := verifyLookPathFn("ffprobe"); err != nil {
+					fmt.Fprintln(app.IO.ErrOut, "verify: ffprobe not found; skipping corrupt-media checks (install ffmpeg or pass --no-probe)")
+					verify.Probe = false
+				}
+			}
+
+			reports, err := engine.VerifyLibrary(cmd.Context(), cfg, opts.SourceIDs, verify)
+			if err != nil {
+				var selectionErr *engine.SelectionError
+				if errors.As(err, &selectionErr) {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				return withExitCode(exitcode.RuntimeFailure, err)
+			}
+
+			counts := map[string]int{}
+			for _, report := range reports {
+				for _, issue := range report.Issues {
+					counts[issue.Kind]++
+				}
+			}
+
+			pruned := 0
+			previewFix := opts.Fix && app.Opts.DryRun
+			if opts.Fix && !app.Opts.DryRun && counts[engine.VerifyIssueMissing] > 0 {
+				pruned, err = engine.PruneMissingStateEntries(cmd.Context(), cfg.Defaults.StateDir, reports)
+				if err != nil {
+					return withExitCode(exitcode.RuntimeFailure, fmt.Errorf("prune state entries: %w", err))
+				}
+			}
+
+			if app.Opts.JSON {
+				encoder := json.NewEncoder(app.IO.Out)
+				payload := map[string]any{"sources": reports, "pruned": pruned}
+				if err := encoder.Encode(payload); err != nil {
+					return withExitCode(exitcode.RuntimeFailure, err)
+				}
+			} else {
+				for _, report := range reports {
+					printVerifyReport(app, report, opts.Fix)
+				}
+				mode := "check"
+				if previewFix {
+					mode = "fix-preview"
+				} else if opts.Fix {
+					mode = "fix"
+				}
+				fmt.Fprintf(
+					app.IO.Out,
+					"verify: summary sources=%d missing=%d orphan=%d empty=%d corrupt=%d pruned=%d mode=%s\n",
+					len(reports),
+					counts[engine.VerifyIssueMissing],
+					counts[engine.VerifyIssueOrphan],
+					counts[engine.VerifyIssueEmpty],
+					counts[engine.VerifyIssueCorrupt],
+					pruned,
+					mode,
+				)
+			}
+
+			remaining := counts[engine.VerifyIssueOrphan] + counts[engine.VerifyIssueEmpty] + counts[engine.VerifyIssueCorrupt]
+			remaining += counts[engine.VerifyIssueMissing] - pruned
+			if remaining > 0 {
+				return withExitCode(exitcode.PartialSuccess, fmt.Errorf("verify found %d unresolved issue(s)", remaining))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&opts.SourceIDs, "source", nil, "Verify only selected source id (repeatable)")
+	cmd.Flags().BoolVar(&opts.Fix, "fix", false, "Prune state entries whose files are missing on disk (honors --dry-run)")
+	cmd.Flags().BoolVar(&opts.NoProbe, "no-probe", false, "Skip ffprobe corrupt-media checks (zero-byte files are still reported)")
+	cmd.Flags().DurationVar(&opts.ProbeTimeout, "probe-timeout", opts.ProbeTimeout, "Per-file ffprobe timeout")
+	return cmd
+}
+
+func printVerifyReport(app *AppContext, report engine.SourceVerifyReport, fix bool) {
+	orphans := "n/a"
+	if report.OrphansChecked {
+		orphans = "checked"
+	}
+	fmt.Fprintf(
+		app.IO.Out,
+		"[%s] tracked=%d local=%d issues=%d orphans=%s\n",
+		report.SourceID,
+		report.Tracked,
+		report.LocalFiles,
+		len(report.Issues),
+		orphans,
+	)
+	for i, issue := range report.Issues {
+		if !app.Opts.Verbose && i >= 20 {
+			fmt.Fprintf(app.IO.Out, "  ... %d more (use --verbose to list all)\n", len(report.Issues)-i)
+			break
+		}
+		label := issue.Kind
+		if fix && issue.Kind == engine.VerifyIssueMissing {
+			label = "prune"
+		}
+		line := fmt.Sprintf("  [%s] %s", label, issue.Path)
+		if issue.TrackID != "" {
+			line += " id=" + issue.TrackID
+		}
+		if issue.Detail != "" {
+			line += " (" + issue.Detail + ")"
+		}
+		fmt.Fprintln(app.IO.Out, line)
+	}
+	for _, problem := range report.Errors {
+		fmt.Fprintf(app.IO.ErrOut, "[%s] error: %s\n", report.SourceID, problem)
+	}
+}
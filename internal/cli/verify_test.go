@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/exitcode"
+)
+
+func TestVerifyCommandFixPrunesMissingEntries(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	musicDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, musicDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(musicDir, "one.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+	statePath := filepath.Join(stateDir, "sc.sync.scdl")
+	if err := os.WriteFile(statePath, []byte("soundcloud 1 one.mp3\nsoundcloud 2 gone.mp3\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	configPath := filepath.Join(tmp, "udl.yaml")
+	payload := "version: 1\n" +
+		"defaults:\n  state_dir: " + stateDir + "\n" +
+		"sources:\n" +
+		"  - id: sc\n    type: soundcloud\n    enabled: true\n" +
+		"    target_dir: " + musicDir + "\n" +
+		"    url: https://soundcloud.com/user\n    state_file: sc.sync.scdl\n" +
+		"    adapter:\n      kind: scdl\n"
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	app := &AppContext{IO: IOStreams{In: strings.NewReader(""), Out: out, ErrOut: &bytes.Buffer{}}, Opts: GlobalOptions{ConfigPath: configPath}}
+	cmd := newVerifyCommand(app)
+	cmd.SetArgs([]string{"--no-probe"})
+	err := cmd.Execute()
+	if got := mapExitCode(err); got != exitcode.PartialSuccess {
+		t.Fatalf("expected partial success exit code, got %d (%v)", got, err)
+	}
+	if !strings.Contains(out.String(), "[missing] "+filepath.Join(musicDir, "gone.mp3")+" id=2") {
+		t.Fatalf("expected missing entry in output: %q", out.String())
+	}
+
+	out.Reset()
+	cmd = newVerifyCommand(app)
+	cmd.SetArgs([]string{"--no-probe", "--fix"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("verify --fix: %v", err)
+	}
+	if !strings.Contains(out.String(), "missing=1 orphan=0 empty=0 corrupt=0 pruned=1 mode=fix") {
+		t.Fatalf("unexpected fix summary: %q", out.String())
+	}
+	updated, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if string(updated) != "soundcloud 1 one.mp3\n" {
+		t.Fatalf("unexpected pruned state: %q", string(updated))
+	}
+}
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os/signal"
+	"strings"
+	"time"
+
+	workflows "github.com/jaa/update-downloads/internal/app"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine"
+	"github.com/jaa/update-downloads/internal/exitcode"
+	"github.com/jaa/update-downloads/internal/output"
+	"github.com/jaa/update-downloads/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+func newWatchCommand(app *AppContext) *cobra.Command {
+	var sourceIDs []string
+	var tags []string
+	var excludeTags []string
+	var interval time.Duration
+	var skipInitial bool
+	var timeout time.Duration
+	var metricsAddr string
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Keep running and re-sync sources on their configured schedules",
+		Long: strings.TrimSpace(`
+Keep running and re-sync sources on their configured schedules.
+
+Each source is scheduled by sync.schedule (a duration such as 6h, "@every 30m",
+@hourly/@daily/@weekly, or a five-field cron expression in local time). Sources
+without a schedule use --interval, or are not watched when --interval is unset.
+
+Due sources run sequentially in one sync; a source never overlaps itself and
+ticks missed while a sync is running are coalesced. Only one watch may run per
+state_dir. SIGINT/SIGTERM interrupt the in-flight sync and stop the watch.
+`),
+		Example: strings.TrimSpace(`
+  udl watch
+  udl watch --interval 6h
+  udl watch --source soundcloud-likes --skip-initial --json
+  udl watch --metrics-addr 127.0.0.1:9464
+`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval != 0 && interval < schedule.MinInterval {
+				return withExitCode(exitcode.InvalidUsage, fmt.Errorf("invalid --interval %s (must be at least %s)", interval, schedule.MinInterval))
+			}
+
+			cfg, err := loadConfig(app)
+			if err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			if err := config.Validate(cfg); err != nil {
+				return withExitCode(exitcode.InvalidConfig, err)
+			}
+			selectedIDs, err := resolveSourceSelection(cfg, sourceIDs, tags, excludeTags)
+			if err != nil {
+				return err
+			}
+
+			runnerStdout := app.IO.Out
+			runnerStderr := app.IO.ErrOut
+			var emitter output.EventEmitter
+			if app.Opts.JSON {
+				runnerStdout = app.IO.ErrOut
+				emitter = output.NewJSONEmitter(app.IO.Out)
+			} else {
+				if !app.Opts.Verbose {
+					runnerStdout = io.Discard
+					runnerStderr = io.Discard
+				}
+				emitter = output.NewHumanEmitter(app.IO.Out, app.IO.ErrOut, app.Opts.Quiet, app.Opts.Verbose)
+			}
+
+			if strings.TrimSpace(metricsAddr) != "" {
+				metrics := output.NewMetricsCollector()
+				shutdown, err := serveWatchMetrics(metricsAddr, metrics)
+				if err != nil {
+					return withExitCode(exitcode.InvalidUsage, err)
+				}
+				defer shutdown()
+				emitter = output.NewObservingEmitter(metrics, emitter)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), interruptSignals()...)
+			defer stop()
+
+			useCase := workflows.WatchUseCase{
+				Sync: workflows.SyncUseCase{
+					Registry: syncAdapterRegistry(),
+					Runner:   engine.NewSubprocessRunner(app.IO.In, runnerStdout, runnerStderr),
+					Emitter:  emitter,
+				},
+				Emitter: emitter,
+			}
+			_, runErr := useCase.Run(ctx, cfg, workflows.WatchRequest{
+				SourceIDs:       selectedIDs,
+				DefaultInterval: interval,
+				SkipInitial:     skipInitial,
+				// A scheduled sync queues behind a manual one of the
+				// same source rather than failing it.
+				Sync: workflows.SyncRequest{
+					DryRun:          app.Opts.DryRun,
+					TimeoutOverride: timeout,
+					NoBrowser:       app.Opts.Headless,
+					WaitForLock:     true,
+				},
+			})
+			if runErr != nil {
+				var selectionErr *engine.SelectionError
+				switch {
+				case errors.As(runErr, &selectionErr):
+					return withExitCode(exitcode.InvalidUsage, runErr)
+				case errors.Is(runErr, workflows.ErrNoWatchedSources):
+					return withExitCode(exitcode.InvalidUsage, runErr)
+				default:
+					return withExitCode(exitcode.RuntimeFailure, runErr)
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&sourceIDs, "source", nil, "Watch only selected source id (repeatable)")
+	addSourceTagFlags(cmd, &tags, &excludeTags)
+	cmd.Flags().DurationVar(&interval, "interval", 0, "Schedule for sources without sync.schedule (e.g. 6h; minimum 1m)")
+	cmd.Flags().BoolVar(&skipInitial, "skip-initial", false, "Wait for each source's first scheduled time instead of syncing at startup")
+	cmd.Flags().DurationVar(&timeout, "timeout", 0, "Override per-source command timeout (e.g. 10m, 1h)")
+	cmd.Flags().StringVar(&metricsAddr, "metrics-addr", "", "Serve Prometheus metrics at http://<addr>/metrics (e.g. 127.0.0.1:9464)")
+	return cmd
+}
+
+// serveWatchMetrics listens on addr before the watch starts, so a taken port
+// fails the command instead of a background goroutine. The endpoint has no
+// authentication; bind it to loopback unless the network is trusted.
+func serveWatchMetrics(addr string, metrics *output.MetricsCollector) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --metrics-addr %q: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(ctx)
+	}, nil
+}
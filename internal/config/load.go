@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -16,43 +17,169 @@ type LoadOptions struct {
 	ExplicitPath string
 	WorkingDir   string
 	Env          map[string]string
+	// Profile selects a profile by name, overriding UDL_PROFILE and the
+	// config's profile key.
+	Profile string
 }
 
 type fileConfig struct {
-	Version  *int          `yaml:"version"`
-	Defaults fileDefaults  `yaml:"defaults"`
-	Sources  *[]fileSource `yaml:"sources"`
+	Version           *int                    `yaml:"version"`
+	Profile           *string                 `yaml:"profile"`
+	Defaults          fileDefaults            `yaml:"defaults"`
+	Profiles          map[string]fileProfile  `yaml:"profiles"`
+	MetadataProviders *[]fileMetadataProvider `yaml:"metadata_providers"`
+	FreeDL            fileFreeDL              `yaml:"freedl"`
+	PostProcessing    filePostProcessing      `yaml:"post_processing"`
+	Compat            fileCompat              `yaml:"compat"`
+	Retention         fileRetention           `yaml:"retention"`
+	Sources           *[]fileSource           `yaml:"sources"`
+}
+
+type fileFreeDL struct {
+	PollIntervalMS     *int      `yaml:"poll_interval_ms"`
+	StableSamples      *int      `yaml:"stable_samples"`
+	IdleTimeoutSeconds *int      `yaml:"idle_timeout_seconds"`
+	ArtworkVariants    *[]string `yaml:"artwork_variants"`
+
+	InteractiveBudgetSeconds *int    `yaml:"interactive_budget_seconds"`
+	ConcurrentGates          *int    `yaml:"concurrent_gates"`
+	VerifyQuality            *bool   `yaml:"verify_quality"`
+	Automation               *string `yaml:"automation"`
+	Email                    *string `yaml:"email"`
+	Comment                  *string `yaml:"comment"`
+	BrowserProfileDir        *string `yaml:"browser_profile_dir"`
+}
+
+type filePostProcessing struct {
+	MaxConcurrent *int    `yaml:"max_concurrent"`
+	Priority      *string `yaml:"priority"`
+}
+
+type fileCompat struct {
+	URL   *string                   `yaml:"url"`
+	Tools map[string]fileCompatRule `yaml:"tools"`
+}
+
+type fileCompatRule struct {
+	MinVersion          *string           `yaml:"min_version"`
+	MaxVersionExclusive *string           `yaml:"max_version_exclusive"`
+	KnownBad            map[string]string `yaml:"known_bad"`
+}
+
+type fileRetention struct {
+	Reports fileRetentionRule `yaml:"reports"`
+	Logs    fileRetentionRule `yaml:"logs"`
+	Caches  fileRetentionRule `yaml:"caches"`
+	Backups fileRetentionRule `yaml:"backups"`
+}
+
+type fileRetentionRule struct {
+	MaxAgeDays *int `yaml:"max_age_days"`
+	MaxSizeMB  *int `yaml:"max_size_mb"`
+}
+
+type fileMetadataProvider struct {
+	Name           string   `yaml:"name"`
+	Enabled        *bool    `yaml:"enabled"`
+	Command        []string `yaml:"command"`
+	Priority       int      `yaml:"priority"`
+	TimeoutSeconds int      `yaml:"timeout_seconds"`
 }
 
 type fileDefaults struct {
-	StateDir              *string `yaml:"state_dir"`
-	ArchiveFile           *string `yaml:"archive_file"`
-	Threads               *int    `yaml:"threads"`
-	ContinueOnError       *bool   `yaml:"continue_on_error"`
-	CommandTimeoutSeconds *int    `yaml:"command_timeout_seconds"`
+	StateDir              *string              `yaml:"state_dir"`
+	ArchiveFile           *string              `yaml:"archive_file"`
+	Threads               *int                 `yaml:"threads"`
+	ContinueOnError       *bool                `yaml:"continue_on_error"`
+	CommandTimeoutSeconds *int                 `yaml:"command_timeout_seconds"`
+	Notifications         *[]fileNotification  `yaml:"notifications"`
+	FileOwnership         *fileFileOwnership   `yaml:"file_ownership"`
+	ArtifactCleanup       *fileArtifactCleanup `yaml:"artifact_cleanup"`
+}
+
+type fileArtifactCleanup struct {
+	Adapters      map[string][]string `yaml:"adapters"`
+	KeepArtifacts bool                `yaml:"keep_artifacts"`
+	Quarantine    bool                `yaml:"quarantine"`
+}
+
+type fileFileOwnership struct {
+	Umask string `yaml:"umask"`
+	Owner string `yaml:"owner"`
+	Group string `yaml:"group"`
+}
+
+type fileProfile struct {
+	Defaults fileDefaults `yaml:"defaults"`
+}
+
+type fileSourceDefaults struct {
+	ArchiveFile           string   `yaml:"archive_file"`
+	Threads               int      `yaml:"threads"`
+	CommandTimeoutSeconds int      `yaml:"command_timeout_seconds"`
+	ArtifactPatterns      []string `yaml:"artifact_patterns"`
+	KeepArtifacts         *bool    `yaml:"keep_artifacts"`
+	QuarantineArtifacts   *bool    `yaml:"quarantine_artifacts"`
+}
+
+type fileNotification struct {
+	Name           string   `yaml:"name"`
+	URL            string   `yaml:"url"`
+	URLEnv         string   `yaml:"url_env"`
+	Format         string   `yaml:"format"`
+	Events         []string `yaml:"events"`
+	TimeoutSeconds int      `yaml:"timeout_seconds"`
 }
 
 type fileSource struct {
-	ID        string          `yaml:"id"`
-	Type      SourceType      `yaml:"type"`
-	Enabled   *bool           `yaml:"enabled"`
-	TargetDir string          `yaml:"target_dir"`
-	URL       string          `yaml:"url"`
-	StateFile string          `yaml:"state_file"`
-	Sync      fileSyncPolicy  `yaml:"sync"`
-	Adapter   fileAdapterSpec `yaml:"adapter"`
+	ID          string             `yaml:"id"`
+	Type        SourceType         `yaml:"type"`
+	Enabled     *bool              `yaml:"enabled"`
+	TargetDir   string             `yaml:"target_dir"`
+	URL         string             `yaml:"url"`
+	StateFile   string             `yaml:"state_file"`
+	Quality     []string           `yaml:"quality"`
+	Tags        []string           `yaml:"tags"`
+	CookiesFile string             `yaml:"cookies_file"`
+	Monitor     bool               `yaml:"monitor"`
+	Defaults    fileSourceDefaults `yaml:"defaults"`
+	Sync        fileSyncPolicy     `yaml:"sync"`
+	Adapter     fileAdapterSpec    `yaml:"adapter"`
 }
 
 type fileSyncPolicy struct {
-	BreakOnExisting *bool `yaml:"break_on_existing"`
-	AskOnExisting   *bool `yaml:"ask_on_existing"`
-	LocalIndexCache *bool `yaml:"local_index_cache"`
+	BreakOnExisting        *bool        `yaml:"break_on_existing"`
+	AskOnExisting          *bool        `yaml:"ask_on_existing"`
+	LocalIndexCache        *bool        `yaml:"local_index_cache"`
+	FreeDownloads          string       `yaml:"free_downloads"`
+	IncludeGroups          []string     `yaml:"include_groups"`
+	Schedule               string       `yaml:"schedule"`
+	SuccessWhen            string       `yaml:"success_when"`
+	DedupeAcrossSources    *bool        `yaml:"dedupe_across_sources"`
+	MaxRemoteShrinkPercent int          `yaml:"max_remote_shrink_percent"`
+	NewReleasesDays        int          `yaml:"new_releases_days"`
+	MaxDepth               int          `yaml:"max_depth"`
+	Concurrency            int          `yaml:"concurrency"`
+	PlaylistFile           string       `yaml:"playlist_file"`
+	Prune                  *bool        `yaml:"prune"`
+	SourceInfo             *bool        `yaml:"source_info"`
+	SourceCover            *bool        `yaml:"source_cover"`
+	ReplayGain             string       `yaml:"replaygain"`
+	MusicBrainz            string       `yaml:"musicbrainz"`
+	Lyrics                 string       `yaml:"lyrics"`
+	StallMinKbps           int          `yaml:"stall_min_kbps"`
+	StallSeconds           int          `yaml:"stall_seconds"`
+	Compilations           *bool        `yaml:"compilations"`
+	GenreRouting           []GenreRoute `yaml:"genre_routing"`
+	Blocklist              Blocklist    `yaml:"blocklist"`
+	SkipTTLDays            int          `yaml:"skip_ttl_days"`
 }
 
 type fileAdapterSpec struct {
 	Kind       string   `yaml:"kind"`
 	ExtraArgs  []string `yaml:"extra_args"`
 	MinVersion string   `yaml:"min_version"`
+	Fallback   []string `yaml:"fallback"`
 }
 
 func Load(opts LoadOptions) (Config, error) {
@@ -90,6 +217,9 @@ func Load(opts LoadOptions) (Config, error) {
 		}
 	}
 
+	if err := applyProfile(&cfg, opts.Profile, env); err != nil {
+		return Config{}, err
+	}
 	if err := applyEnvOverrides(&cfg, env); err != nil {
 		return Config{}, err
 	}
@@ -98,6 +228,52 @@ func Load(opts LoadOptions) (Config, error) {
 	return cfg, nil
 }
 
+// applyProfile merges the selected profile's defaults over defaults. The
+// profile is chosen by LoadOptions.Profile, then UDL_PROFILE, then the
+// config's profile key; env overrides are applied after it.
+func applyProfile(cfg *Config, explicit string, env map[string]string) error {
+	name := strings.TrimSpace(explicit)
+	if name == "" {
+		name = strings.TrimSpace(env["UDL_PROFILE"])
+	}
+	if name == "" {
+		name = strings.TrimSpace(cfg.Profile)
+	}
+	if name == "" {
+		return nil
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		known := make([]string, 0, len(cfg.Profiles))
+		for key := range cfg.Profiles {
+			known = append(known, key)
+		}
+		sort.Strings(known)
+		if len(known) == 0 {
+			return fmt.Errorf("profile %q is not defined (config has no profiles)", name)
+		}
+		return fmt.Errorf("profile %q is not defined (available: %s)", name, strings.Join(known, ", "))
+	}
+	cfg.Profile = name
+	overrides := profile.Defaults
+	if overrides.StateDir != "" {
+		cfg.Defaults.StateDir = overrides.StateDir
+	}
+	if overrides.ArchiveFile != "" {
+		cfg.Defaults.ArchiveFile = overrides.ArchiveFile
+	}
+	if overrides.Threads > 0 {
+		cfg.Defaults.Threads = overrides.Threads
+	}
+	if overrides.ContinueOnError != nil {
+		cfg.Defaults.ContinueOnError = *overrides.ContinueOnError
+	}
+	if overrides.CommandTimeoutSeconds > 0 {
+		cfg.Defaults.CommandTimeoutSeconds = overrides.CommandTimeoutSeconds
+	}
+	return nil
+}
+
 func mergeFile(cfg *Config, path string, required bool) error {
 	payload, err := os.ReadFile(path)
 	if err != nil {
@@ -136,6 +312,31 @@ func applyFileConfig(cfg *Config, fc fileConfig) {
 	if fc.Version != nil {
 		cfg.Version = *fc.Version
 	}
+	if fc.Profile != nil {
+		cfg.Profile = strings.TrimSpace(*fc.Profile)
+	}
+	if fc.Profiles != nil {
+		if cfg.Profiles == nil {
+			cfg.Profiles = map[string]Profile{}
+		}
+		for name, fp := range fc.Profiles {
+			continueOnError := copyBoolPtr(fp.Defaults.ContinueOnError)
+			profile := Profile{Defaults: ProfileDefaults{ContinueOnError: continueOnError}}
+			if fp.Defaults.StateDir != nil {
+				profile.Defaults.StateDir = strings.TrimSpace(*fp.Defaults.StateDir)
+			}
+			if fp.Defaults.ArchiveFile != nil {
+				profile.Defaults.ArchiveFile = strings.TrimSpace(*fp.Defaults.ArchiveFile)
+			}
+			if fp.Defaults.Threads != nil {
+				profile.Defaults.Threads = *fp.Defaults.Threads
+			}
+			if fp.Defaults.CommandTimeoutSeconds != nil {
+				profile.Defaults.CommandTimeoutSeconds = *fp.Defaults.CommandTimeoutSeconds
+			}
+			cfg.Profiles[strings.TrimSpace(name)] = profile
+		}
+	}
 	if fc.Defaults.StateDir != nil {
 		cfg.Defaults.StateDir = strings.TrimSpace(*fc.Defaults.StateDir)
 	}
@@ -151,6 +352,133 @@ func applyFileConfig(cfg *Config, fc fileConfig) {
 	if fc.Defaults.CommandTimeoutSeconds != nil {
 		cfg.Defaults.CommandTimeoutSeconds = *fc.Defaults.CommandTimeoutSeconds
 	}
+	if fc.Defaults.Notifications != nil {
+		cfg.Defaults.Notifications = make([]Notification, 0, len(*fc.Defaults.Notifications))
+		for _, fn := range *fc.Defaults.Notifications {
+			events := make([]string, 0, len(fn.Events))
+			for _, event := range fn.Events {
+				events = append(events, strings.ToLower(strings.TrimSpace(event)))
+			}
+			cfg.Defaults.Notifications = append(cfg.Defaults.Notifications, Notification{
+				Name:           strings.TrimSpace(fn.Name),
+				URL:            strings.TrimSpace(fn.URL),
+				URLEnv:         strings.TrimSpace(fn.URLEnv),
+				Format:         strings.ToLower(strings.TrimSpace(fn.Format)),
+				Events:         events,
+				TimeoutSeconds: fn.TimeoutSeconds,
+			})
+		}
+	}
+	if fc.Defaults.FileOwnership != nil {
+		cfg.Defaults.FileOwnership = FileOwnership{
+			Umask: strings.TrimSpace(fc.Defaults.FileOwnership.Umask),
+			Owner: strings.TrimSpace(fc.Defaults.FileOwnership.Owner),
+			Group: strings.TrimSpace(fc.Defaults.FileOwnership.Group),
+		}
+	}
+	if fc.Defaults.ArtifactCleanup != nil {
+		cfg.Defaults.ArtifactCleanup.KeepArtifacts = fc.Defaults.ArtifactCleanup.KeepArtifacts
+		cfg.Defaults.ArtifactCleanup.Quarantine = fc.Defaults.ArtifactCleanup.Quarantine
+		if fc.Defaults.ArtifactCleanup.Adapters != nil {
+			cfg.Defaults.ArtifactCleanup.Adapters = make(map[string][]string, len(fc.Defaults.ArtifactCleanup.Adapters))
+			for kind, patterns := range fc.Defaults.ArtifactCleanup.Adapters {
+				cfg.Defaults.ArtifactCleanup.Adapters[strings.ToLower(strings.TrimSpace(kind))] = normalizeArtifactPatterns(patterns)
+			}
+		}
+	}
+
+	if fc.MetadataProviders != nil {
+		cfg.MetadataProviders = make([]MetadataProvider, 0, len(*fc.MetadataProviders))
+		for _, fp := range *fc.MetadataProviders {
+			enabled := true
+			if fp.Enabled != nil {
+				enabled = *fp.Enabled
+			}
+			command := make([]string, 0, len(fp.Command))
+			for _, arg := range fp.Command {
+				command = append(command, strings.TrimSpace(arg))
+			}
+			cfg.MetadataProviders = append(cfg.MetadataProviders, MetadataProvider{
+				Name:           strings.TrimSpace(fp.Name),
+				Enabled:        enabled,
+				Command:        command,
+				Priority:       fp.Priority,
+				TimeoutSeconds: fp.TimeoutSeconds,
+			})
+		}
+	}
+
+	if fc.FreeDL.PollIntervalMS != nil {
+		cfg.FreeDL.PollIntervalMS = *fc.FreeDL.PollIntervalMS
+	}
+	if fc.FreeDL.StableSamples != nil {
+		cfg.FreeDL.StableSamples = *fc.FreeDL.StableSamples
+	}
+	if fc.FreeDL.IdleTimeoutSeconds != nil {
+		cfg.FreeDL.IdleTimeoutSeconds = *fc.FreeDL.IdleTimeoutSeconds
+	}
+	if fc.FreeDL.InteractiveBudgetSeconds != nil {
+		cfg.FreeDL.InteractiveBudgetSeconds = *fc.FreeDL.InteractiveBudgetSeconds
+	}
+	if fc.FreeDL.ConcurrentGates != nil {
+		cfg.FreeDL.ConcurrentGates = *fc.FreeDL.ConcurrentGates
+	}
+	if fc.FreeDL.VerifyQuality != nil {
+		cfg.FreeDL.VerifyQuality = *fc.FreeDL.VerifyQuality
+	}
+	if fc.FreeDL.Automation != nil {
+		cfg.FreeDL.Automation = strings.ToLower(strings.TrimSpace(*fc.FreeDL.Automation))
+	}
+	if fc.FreeDL.Email != nil {
+		cfg.FreeDL.Email = strings.TrimSpace(*fc.FreeDL.Email)
+	}
+	if fc.FreeDL.Comment != nil {
+		cfg.FreeDL.Comment = strings.TrimSpace(*fc.FreeDL.Comment)
+	}
+	if fc.FreeDL.BrowserProfileDir != nil {
+		cfg.FreeDL.BrowserProfileDir = strings.TrimSpace(*fc.FreeDL.BrowserProfileDir)
+	}
+	if fc.FreeDL.ArtworkVariants != nil {
+		cfg.FreeDL.ArtworkVariants = make([]string, 0, len(*fc.FreeDL.ArtworkVariants))
+		for _, variant := range *fc.FreeDL.ArtworkVariants {
+			cfg.FreeDL.ArtworkVariants = append(cfg.FreeDL.ArtworkVariants, strings.ToLower(strings.TrimSpace(variant)))
+		}
+	}
+
+	if fc.PostProcessing.MaxConcurrent != nil {
+		cfg.PostProcessing.MaxConcurrent = *fc.PostProcessing.MaxConcurrent
+	}
+	if fc.PostProcessing.Priority != nil {
+		cfg.PostProcessing.Priority = strings.ToLower(strings.TrimSpace(*fc.PostProcessing.Priority))
+	}
+
+	if fc.Compat.URL != nil {
+		cfg.Compat.URL = strings.TrimSpace(*fc.Compat.URL)
+	}
+	if fc.Compat.Tools != nil {
+		cfg.Compat.Tools = make(map[string]CompatRule, len(fc.Compat.Tools))
+		for tool, fr := range fc.Compat.Tools {
+			rule := CompatRule{}
+			if fr.MinVersion != nil {
+				rule.MinVersion = strings.TrimSpace(*fr.MinVersion)
+			}
+			if fr.MaxVersionExclusive != nil {
+				rule.MaxVersionExclusive = strings.TrimSpace(*fr.MaxVersionExclusive)
+			}
+			if fr.KnownBad != nil {
+				rule.KnownBad = make(map[string]string, len(fr.KnownBad))
+				for version, reason := range fr.KnownBad {
+					rule.KnownBad[strings.TrimSpace(version)] = strings.TrimSpace(reason)
+				}
+			}
+			cfg.Compat.Tools[strings.ToLower(strings.TrimSpace(tool))] = rule
+		}
+	}
+
+	applyRetentionRule(&cfg.Retention.Reports, fc.Retention.Reports)
+	applyRetentionRule(&cfg.Retention.Logs, fc.Retention.Logs)
+	applyRetentionRule(&cfg.Retention.Caches, fc.Retention.Caches)
+	applyRetentionRule(&cfg.Retention.Backups, fc.Retention.Backups)
 
 	if fc.Sources != nil {
 		cfg.Sources = make([]Source, 0, len(*fc.Sources))
@@ -161,21 +489,56 @@ func applyFileConfig(cfg *Config, fc fileConfig) {
 			}
 
 			source := Source{
-				ID:        strings.TrimSpace(fs.ID),
-				Type:      fs.Type,
-				Enabled:   enabled,
-				TargetDir: strings.TrimSpace(fs.TargetDir),
-				URL:       strings.TrimSpace(fs.URL),
-				StateFile: strings.TrimSpace(fs.StateFile),
+				ID:          strings.TrimSpace(fs.ID),
+				Type:        fs.Type,
+				Enabled:     enabled,
+				TargetDir:   strings.TrimSpace(fs.TargetDir),
+				URL:         strings.TrimSpace(fs.URL),
+				StateFile:   strings.TrimSpace(fs.StateFile),
+				Quality:     normalizeLowerList(fs.Quality),
+				Tags:        normalizeLowerList(fs.Tags),
+				CookiesFile: strings.TrimSpace(fs.CookiesFile),
+				Monitor:     fs.Monitor,
+				Defaults: SourceDefaults{
+					ArchiveFile:           strings.TrimSpace(fs.Defaults.ArchiveFile),
+					Threads:               fs.Defaults.Threads,
+					CommandTimeoutSeconds: fs.Defaults.CommandTimeoutSeconds,
+					ArtifactPatterns:      normalizeArtifactPatterns(fs.Defaults.ArtifactPatterns),
+					KeepArtifacts:         copyBoolPtr(fs.Defaults.KeepArtifacts),
+					QuarantineArtifacts:   copyBoolPtr(fs.Defaults.QuarantineArtifacts),
+				},
 				Sync: SyncPolicy{
-					BreakOnExisting: copyBoolPtr(fs.Sync.BreakOnExisting),
-					AskOnExisting:   copyBoolPtr(fs.Sync.AskOnExisting),
-					LocalIndexCache: copyBoolPtr(fs.Sync.LocalIndexCache),
+					BreakOnExisting:        copyBoolPtr(fs.Sync.BreakOnExisting),
+					AskOnExisting:          copyBoolPtr(fs.Sync.AskOnExisting),
+					LocalIndexCache:        copyBoolPtr(fs.Sync.LocalIndexCache),
+					FreeDownloads:          strings.ToLower(strings.TrimSpace(fs.Sync.FreeDownloads)),
+					IncludeGroups:          append([]string{}, fs.Sync.IncludeGroups...),
+					Schedule:               strings.TrimSpace(fs.Sync.Schedule),
+					SuccessWhen:            strings.TrimSpace(fs.Sync.SuccessWhen),
+					DedupeAcrossSources:    copyBoolPtr(fs.Sync.DedupeAcrossSources),
+					MaxRemoteShrinkPercent: fs.Sync.MaxRemoteShrinkPercent,
+					NewReleasesDays:        fs.Sync.NewReleasesDays,
+					MaxDepth:               fs.Sync.MaxDepth,
+					Concurrency:            fs.Sync.Concurrency,
+					PlaylistFile:           strings.TrimSpace(fs.Sync.PlaylistFile),
+					Prune:                  copyBoolPtr(fs.Sync.Prune),
+					SourceInfo:             copyBoolPtr(fs.Sync.SourceInfo),
+					SourceCover:            copyBoolPtr(fs.Sync.SourceCover),
+					ReplayGain:             strings.ToLower(strings.TrimSpace(fs.Sync.ReplayGain)),
+					MusicBrainz:            strings.ToLower(strings.TrimSpace(fs.Sync.MusicBrainz)),
+					Lyrics:                 strings.ToLower(strings.TrimSpace(fs.Sync.Lyrics)),
+					StallMinKbps:           fs.Sync.StallMinKbps,
+					StallSeconds:           fs.Sync.StallSeconds,
+					Compilations:           copyBoolPtr(fs.Sync.Compilations),
+					GenreRouting:           normalizeGenreRoutes(fs.Sync.GenreRouting),
+					Blocklist:              normalizeBlocklist(fs.Sync.Blocklist),
+					SkipTTLDays:            fs.Sync.SkipTTLDays,
 				},
 				Adapter: AdapterSpec{
 					Kind:       strings.TrimSpace(fs.Adapter.Kind),
 					ExtraArgs:  append([]string{}, fs.Adapter.ExtraArgs...),
 					MinVersion: strings.TrimSpace(fs.Adapter.MinVersion),
+					Fallback:   normalizeLowerList(fs.Adapter.Fallback),
 				},
 			}
 			cfg.Sources = append(cfg.Sources, source)
@@ -183,6 +546,15 @@ func applyFileConfig(cfg *Config, fc fileConfig) {
 	}
 }
 
+func applyRetentionRule(rule *RetentionRule, fr fileRetentionRule) {
+	if fr.MaxAgeDays != nil {
+		rule.MaxAgeDays = *fr.MaxAgeDays
+	}
+	if fr.MaxSizeMB != nil {
+		rule.MaxSizeMB = *fr.MaxSizeMB
+	}
+}
+
 func applyEnvOverrides(cfg *Config, env map[string]string) error {
 	if value := strings.TrimSpace(env["UDL_STATE_DIR"]); value != "" {
 		cfg.Defaults.StateDir = value
@@ -216,6 +588,7 @@ func applyEnvOverrides(cfg *Config, env map[string]string) error {
 
 func normalize(cfg *Config) {
 	for i := range cfg.Sources {
+		cfg.Sources[i].URL = NormalizeSourceURL(cfg.Sources[i].Type, cfg.Sources[i].URL)
 		if strings.TrimSpace(cfg.Sources[i].Adapter.Kind) == "" {
 			cfg.Sources[i].Adapter.Kind = defaultAdapterKind(cfg.Sources[i].Type)
 		}
@@ -225,6 +598,15 @@ func normalize(cfg *Config) {
 		if cfg.Sources[i].Type == SourceTypeSoundCloud && strings.TrimSpace(cfg.Sources[i].StateFile) == "" && cfg.Sources[i].ID != "" {
 			cfg.Sources[i].StateFile = cfg.Sources[i].ID + ".sync.scdl"
 		}
+		if cfg.Sources[i].Type == SourceTypeDeezer && strings.TrimSpace(cfg.Sources[i].StateFile) == "" && cfg.Sources[i].ID != "" {
+			cfg.Sources[i].StateFile = cfg.Sources[i].ID + ".sync.deezer"
+		}
+		if cfg.Sources[i].Type == SourceTypeAppleMusic && strings.TrimSpace(cfg.Sources[i].StateFile) == "" && cfg.Sources[i].ID != "" {
+			cfg.Sources[i].StateFile = cfg.Sources[i].ID + ".sync.applemusic"
+		}
+		if cfg.Sources[i].Type == SourceTypeYouTube && cfg.Sources[i].Sync.BreakOnExisting == nil {
+			cfg.Sources[i].Sync.BreakOnExisting = boolPtr(true)
+		}
 		if cfg.Sources[i].Type == SourceTypeSoundCloud {
 			if cfg.Sources[i].Sync.BreakOnExisting == nil {
 				cfg.Sources[i].Sync.BreakOnExisting = boolPtr(true)
@@ -243,6 +625,14 @@ func defaultAdapterKind(sourceType SourceType) string {
 	switch sourceType {
 	case SourceTypeSoundCloud:
 		return "scdl"
+	case SourceTypeYouTube:
+		return "ytdlp"
+	case SourceTypeTidal:
+		return "tidal-dl"
+	case SourceTypeDeezer:
+		return "deemix"
+	case SourceTypeAppleMusic:
+		return "gamdl"
 	default:
 		return ""
 	}
@@ -267,6 +657,60 @@ func EnsureConfigDir(path string) error {
 	return nil
 }
 
+// normalizeLowerList lowercases list values such as quality tiers and
+// adapter kinds and drops blank entries.
+func normalizeLowerList(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(in))
+	for _, tier := range in {
+		if trimmed := strings.ToLower(strings.TrimSpace(tier)); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+// normalizeGenreRoutes lower-cases genre patterns and trims folders; rules
+// keep their order since the first match wins.
+func normalizeGenreRoutes(in []GenreRoute) []GenreRoute {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]GenreRoute, 0, len(in))
+	for _, route := range in {
+		out = append(out, GenreRoute{
+			Genres: normalizeLowerList(route.Genres),
+			Folder: strings.TrimSpace(route.Folder),
+		})
+	}
+	return out
+}
+
+// normalizeBlocklist trims entries and drops blank ones. Patterns keep their
+// case; title regexes opt into case-insensitivity with (?i).
+func normalizeBlocklist(in Blocklist) Blocklist {
+	return Blocklist{
+		IDs:    normalizeTrimmedList(in.IDs),
+		URLs:   normalizeTrimmedList(in.URLs),
+		Titles: normalizeTrimmedList(in.Titles),
+	}
+}
+
+func normalizeTrimmedList(in []string) []string {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(in))
+	for _, value := range in {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
 func copyBoolPtr(in *bool) *bool {
 	if in == nil {
 		return nil
@@ -278,3 +722,18 @@ func copyBoolPtr(in *bool) *bool {
 func boolPtr(v bool) *bool {
 	return &v
 }
+
+// normalizeArtifactPatterns trims patterns and drops blanks, keeping an
+// explicitly empty list non-nil so it still overrides the built-in patterns.
+func normalizeArtifactPatterns(patterns []string) []string {
+	if patterns == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		if trimmed := strings.TrimSpace(pattern); trimmed != "" {
+			normalized = append(normalized, trimmed)
+		}
+	}
+	return normalized
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -159,3 +160,407 @@ sources:
 		t.Fatalf("expected spotify adapter kind to remain explicit-only, got %q", cfg.Sources[0].Adapter.Kind)
 	}
 }
+
+func TestLoadSpotifyArtistIncludeGroups(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 1
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+sources:
+  - id: "artist"
+    type: "spotify"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://open.spotify.com/artist/4tZwfgrHOc3mvqYlEYSvVi"
+    sync:
+      include_groups: ["album", "appears_on"]
+    adapter:
+      kind: "deemix"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if got := strings.Join(SpotifyArtistIncludeGroups(cfg.Sources[0]), ","); got != "album,appears_on" {
+		t.Fatalf("expected include_groups album,appears_on, got %q", got)
+	}
+}
+
+func TestLoadMetadataProviders(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 1
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+metadata_providers:
+  - name: " beatport "
+    command: ["/usr/local/bin/udl-beatport", "--json"]
+    priority: 10
+    timeout_seconds: 5
+  - name: "discogs"
+    enabled: false
+    command: ["udl-discogs"]
+sources:
+  - id: "soundcloud-likes"
+    type: "soundcloud"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://soundcloud.com/user"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if len(cfg.MetadataProviders) != 2 {
+		t.Fatalf("expected 2 metadata providers, got %+v", cfg.MetadataProviders)
+	}
+	first := cfg.MetadataProviders[0]
+	if first.Name != "beatport" || !first.Enabled || first.Priority != 10 || first.TimeoutSeconds != 5 {
+		t.Fatalf("unexpected first provider: %+v", first)
+	}
+	if len(first.Command) != 2 || first.Command[1] != "--json" {
+		t.Fatalf("unexpected first provider command: %+v", first.Command)
+	}
+	if cfg.MetadataProviders[1].Enabled {
+		t.Fatalf("expected second provider to be disabled")
+	}
+}
+
+func TestLoadFreeDLTimingSection(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 1
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+freedl:
+  poll_interval_ms: 2500
+  stable_samples: 4
+  idle_timeout_seconds: 180
+  artwork_variants: [" Original ", "t500x500"]
+  interactive_budget_seconds: 1800
+  concurrent_gates: 3
+  verify_quality: true
+  automation: " Headless "
+  email: "me@example.com"
+  comment: "Thanks for the free download!"
+sources:
+  - id: "soundcloud-likes"
+    type: "soundcloud"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://soundcloud.com/user"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.FreeDL.PollIntervalMS != 2500 || cfg.FreeDL.StableSamples != 4 || cfg.FreeDL.IdleTimeoutSeconds != 180 {
+		t.Fatalf("unexpected freedl timing: %+v", cfg.FreeDL)
+	}
+	if len(cfg.FreeDL.ArtworkVariants) != 2 || cfg.FreeDL.ArtworkVariants[0] != "original" {
+		t.Fatalf("unexpected artwork variants: %+v", cfg.FreeDL.ArtworkVariants)
+	}
+	if cfg.FreeDL.InteractiveBudgetSeconds != 1800 {
+		t.Fatalf("unexpected interactive budget: %d", cfg.FreeDL.InteractiveBudgetSeconds)
+	}
+	if cfg.FreeDL.ConcurrentGates != 3 {
+		t.Fatalf("unexpected concurrent gates: %d", cfg.FreeDL.ConcurrentGates)
+	}
+	if !cfg.FreeDL.VerifyQuality {
+		t.Fatalf("expected verify_quality to load")
+	}
+	if cfg.FreeDL.Automation != FreeDLAutomationHeadless || cfg.FreeDL.Email != "me@example.com" || cfg.FreeDL.Comment != "Thanks for the free download!" {
+		t.Fatalf("unexpected freedl automation: %+v", cfg.FreeDL)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid freedl section, got %v", err)
+	}
+
+	cfg.FreeDL.StableSamples = -1
+	cfg.FreeDL.ArtworkVariants = []string{"huge"}
+	cfg.FreeDL.InteractiveBudgetSeconds = -5
+	cfg.FreeDL.Automation = "chromedp"
+	cfg.FreeDL.Email = "me at example"
+	cfg.FreeDL.ConcurrentGates = 20
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "freedl.stable_samples must be >= 0") || !strings.Contains(err.Error(), `unsupported variant "huge"`) || !strings.Contains(err.Error(), "freedl.interactive_budget_seconds must be >= 0") {
+		t.Fatalf("expected freedl validation problems, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `freedl.automation "chromedp" is invalid`) || !strings.Contains(err.Error(), `freedl.email "me at example" is not an email address`) {
+		t.Fatalf("expected freedl automation problems, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "freedl.concurrent_gates must be between 0 and 8") {
+		t.Fatalf("expected concurrent gates problem, got %v", err)
+	}
+}
+
+func TestLoadPostProcessingSection(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 1
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+post_processing:
+  max_concurrent: 2
+  priority: " Idle "
+sources:
+  - id: "soundcloud-likes"
+    type: "soundcloud"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://soundcloud.com/user"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.PostProcessing.MaxConcurrent != 2 || cfg.PostProcessing.Priority != PostProcessingPriorityIdle {
+		t.Fatalf("unexpected post_processing: %+v", cfg.PostProcessing)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid post_processing section, got %v", err)
+	}
+
+	cfg.PostProcessing = PostProcessing{MaxConcurrent: -1, Priority: "realtime"}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "post_processing.max_concurrent must be >= 0") || !strings.Contains(err.Error(), `post_processing.priority "realtime" is invalid`) {
+		t.Fatalf("expected post_processing validation problems, got %v", err)
+	}
+}
+
+func TestLoadRetentionSection(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 1
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+retention:
+  reports:
+    max_age_days: 30
+    max_size_mb: 50
+  caches:
+    max_age_days: 14
+sources:
+  - id: "soundcloud-likes"
+    type: "soundcloud"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://soundcloud.com/user"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	want := Retention{
+		Reports: RetentionRule{MaxAgeDays: 30, MaxSizeMB: 50},
+		Caches:  RetentionRule{MaxAgeDays: 14},
+	}
+	if cfg.Retention != want {
+		t.Fatalf("unexpected retention: %+v", cfg.Retention)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid retention section, got %v", err)
+	}
+
+	cfg.Retention.Logs = RetentionRule{MaxAgeDays: -1, MaxSizeMB: -5}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "retention.logs.max_age_days must be >= 0") || !strings.Contains(err.Error(), "retention.logs.max_size_mb must be >= 0") {
+		t.Fatalf("expected retention validation problems, got %v", err)
+	}
+}
+
+func TestLoadCompatSection(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 1
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+compat:
+  url: " https://example.com/udl-compat.json "
+  tools:
+    SCDL:
+      max_version_exclusive: "3.5.0"
+      known_bad:
+        "3.1.2": " breaks playlist pagination "
+sources:
+  - id: "soundcloud-likes"
+    type: "soundcloud"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://soundcloud.com/user"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	rule, ok := cfg.Compat.Tools["scdl"]
+	if cfg.Compat.URL != "https://example.com/udl-compat.json" || !ok || rule.MaxVersionExclusive != "3.5.0" || rule.KnownBad["3.1.2"] != "breaks playlist pagination" {
+		t.Fatalf("unexpected compat: %+v", cfg.Compat)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid compat section, got %v", err)
+	}
+
+	cfg.Compat = Compat{URL: "http://example.com/compat.json", Tools: map[string]CompatRule{"ffmpeg": {}}}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `compat.url "http://example.com/compat.json" must be an https URL`) || !strings.Contains(err.Error(), `compat.tools has unknown tool "ffmpeg"`) {
+		t.Fatalf("expected compat validation problems, got %v", err)
+	}
+}
+
+func TestLoadProfilesAndSourceDefaultsPrecedence(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 2
+profile: "laptop"
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+  archive_file: "archive.txt"
+  threads: 1
+  continue_on_error: true
+  command_timeout_seconds: 900
+profiles:
+  laptop:
+    defaults:
+      threads: 2
+  nas:
+    defaults:
+      state_dir: "` + filepath.Join(tmp, "nas-state") + `"
+      threads: 8
+      continue_on_error: false
+      command_timeout_seconds: 3600
+sources:
+  - id: "soundcloud-likes"
+    type: "soundcloud"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://soundcloud.com/user"
+    defaults:
+      threads: 4
+      archive_file: "likes-archive.txt"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath, Env: map[string]string{}})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.Profile != "laptop" || cfg.Defaults.Threads != 2 || cfg.Defaults.CommandTimeoutSeconds != 900 {
+		t.Fatalf("expected laptop profile over defaults, got profile=%q defaults=%+v", cfg.Profile, cfg.Defaults)
+	}
+	effective := cfg.Defaults.ForSource(cfg.Sources[0])
+	if effective.Threads != 4 || effective.ArchiveFile != "likes-archive.txt" || effective.CommandTimeoutSeconds != 900 {
+		t.Fatalf("expected per-source overrides to win, got %+v", effective)
+	}
+
+	cfg, err = Load(LoadOptions{
+		ExplicitPath: configPath,
+		Env:          map[string]string{"UDL_PROFILE": "laptop", "UDL_THREADS": "6"},
+		Profile:      "nas",
+	})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if cfg.Profile != "nas" || cfg.Defaults.StateDir != filepath.Join(tmp, "nas-state") || cfg.Defaults.ContinueOnError {
+		t.Fatalf("expected explicit nas profile, got profile=%q defaults=%+v", cfg.Profile, cfg.Defaults)
+	}
+	if cfg.Defaults.Threads != 6 || cfg.Defaults.CommandTimeoutSeconds != 3600 {
+		t.Fatalf("expected env over profile, got %+v", cfg.Defaults)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid v2 config, got %v", err)
+	}
+
+	_, err = Load(LoadOptions{ExplicitPath: configPath, Env: map[string]string{"UDL_PROFILE": "desktop"}})
+	if err == nil || !strings.Contains(err.Error(), `profile "desktop" is not defined (available: laptop, nas)`) {
+		t.Fatalf("expected unknown profile error, got %v", err)
+	}
+}
+
+func TestLoadArtifactCleanupDefaultsAndSourceOverrides(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 2
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+  archive_file: "archive.txt"
+  threads: 1
+  continue_on_error: true
+  command_timeout_seconds: 900
+  artifact_cleanup:
+    adapters:
+      ytdlp: ["*.part", " *.temp.* "]
+sources:
+  - id: "yt"
+    type: "youtube"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://www.youtube.com/playlist?list=abc"
+    adapter:
+      kind: "ytdlp"
+  - id: "yt-debug"
+    type: "youtube"
+    enabled: true
+    target_dir: "/tmp/music"
+    url: "https://www.youtube.com/playlist?list=def"
+    adapter:
+      kind: "ytdlp"
+    defaults:
+      artifact_patterns: ["tmp/*.json"]
+      keep_artifacts: true
+      quarantine_artifacts: true
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath, Env: map[string]string{}})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if got := cfg.Defaults.ForSource(cfg.Sources[0]).ArtifactCleanup; got.KeepArtifacts || strings.Join(got.Adapters["ytdlp"], ",") != "*.part,*.temp.*" {
+		t.Fatalf("unexpected default artifact cleanup %+v", got)
+	}
+	got := cfg.Defaults.ForSource(cfg.Sources[1]).ArtifactCleanup
+	if !got.KeepArtifacts || !got.Quarantine || strings.Join(got.Adapters["ytdlp"], ",") != "tmp/*.json" {
+		t.Fatalf("expected per-source artifact overrides, got %+v", got)
+	}
+	if strings.Join(cfg.Defaults.ArtifactCleanup.Adapters["ytdlp"], ",") != "*.part,*.temp.*" {
+		t.Fatalf("expected ForSource to leave defaults untouched, got %+v", cfg.Defaults.ArtifactCleanup)
+	}
+
+	cfg.Defaults.ArtifactCleanup.Adapters["wget"] = []string{"[bad"}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `unknown adapter kind "wget"`) || !strings.Contains(err.Error(), `invalid pattern "[bad"`) {
+		t.Fatalf("expected artifact cleanup validation errors, got %v", err)
+	}
+}
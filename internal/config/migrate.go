@@ -0,0 +1,20 @@
+package config
+
+import "fmt"
+
+// Migrate upgrades cfg to CurrentVersion and returns a description of each
+// change. A config already at CurrentVersion is returned unchanged.
+func Migrate(cfg Config) (Config, []string, error) {
+	switch cfg.Version {
+	case CurrentVersion:
+		return cfg, nil, nil
+	case 1:
+		// Version 2 only adds optional sections (profiles, per-source
+		// defaults), so every version 1 file keeps its meaning.
+		migrated := cfg
+		migrated.Version = CurrentVersion
+		return migrated, []string{"version: 1 -> 2 (enables profiles and per-source defaults)"}, nil
+	default:
+		return Config{}, nil, fmt.Errorf("cannot migrate config version %d (supported: 1, %d)", cfg.Version, CurrentVersion)
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// UmaskValue parses Umask. ok is false when no umask is configured.
+func (o FileOwnership) UmaskValue() (mask int, ok bool, err error) {
+	raw := strings.TrimSpace(o.Umask)
+	if raw == "" {
+		return 0, false, nil
+	}
+	value, err := strconv.ParseUint(raw, 8, 32)
+	if err != nil || value > 0o777 {
+		return 0, false, fmt.Errorf("invalid umask %q (expected octal 0000-0777, e.g. \"0002\")", o.Umask)
+	}
+	return int(value), true, nil
+}
+
+// IDs resolves Owner and Group to numeric ids on this machine; an unset field
+// resolves to -1, which chown treats as "leave unchanged".
+func (o FileOwnership) IDs() (uid int, gid int, err error) {
+	uid, gid = -1, -1
+	if owner := strings.TrimSpace(o.Owner); owner != "" {
+		if uid, err = strconv.Atoi(owner); err != nil {
+			account, lookupErr := user.Lookup(owner)
+			if lookupErr != nil {
+				return -1, -1, fmt.Errorf("owner %q: %w", owner, lookupErr)
+			}
+			if uid, err = strconv.Atoi(account.Uid); err != nil {
+				return -1, -1, fmt.Errorf("owner %q has non-numeric uid %q", owner, account.Uid)
+			}
+		}
+	}
+	if group := strings.TrimSpace(o.Group); group != "" {
+		if gid, err = strconv.Atoi(group); err != nil {
+			entry, lookupErr := user.LookupGroup(group)
+			if lookupErr != nil {
+				return -1, -1, fmt.Errorf("group %q: %w", group, lookupErr)
+			}
+			if gid, err = strconv.Atoi(entry.Gid); err != nil {
+				return -1, -1, fmt.Errorf("group %q has non-numeric gid %q", group, entry.Gid)
+			}
+		}
+	}
+	return uid, gid, nil
+}
+
+// Enabled reports whether any part of the policy is configured.
+func (o FileOwnership) Enabled() bool {
+	return strings.TrimSpace(o.Umask) != "" || strings.TrimSpace(o.Owner) != "" || strings.TrimSpace(o.Group) != ""
+}
@@ -1,12 +1,29 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+// ErrRcloneRemote is returned by ExpandTargetDir for an rclone "remote:path"
+// target, which has no local path to expand to.
+var ErrRcloneRemote = errors.New("rclone remote paths are only supported for target_dir during udl sync")
+
+// rcloneRemotePattern matches the "remote:" prefix of an rclone path. Remote
+// names are at least two characters long so a Windows drive letter ("C:")
+// is never mistaken for one.
+var rcloneRemotePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_. -]*[A-Za-z0-9_.-]:`)
+
+// IsRcloneRemote reports whether raw names an rclone remote, such as
+// "gdrive:Music/Playlists", rather than a local path.
+func IsRcloneRemote(raw string) bool {
+	return rcloneRemotePattern.MatchString(strings.TrimSpace(raw))
+}
+
 func UserConfigPath() (string, error) {
 	if xdg := os.Getenv("XDG_CONFIG_HOME"); strings.TrimSpace(xdg) != "" {
 		return filepath.Join(xdg, "udl", "config.yaml"), nil
@@ -56,6 +73,16 @@ func ExpandPath(raw string) (string, error) {
 	return filepath.Clean(expanded), nil
 }
 
+// ExpandTargetDir expands a source's target_dir like ExpandPath, or returns
+// ErrRcloneRemote when it names an rclone remote. udl sync swaps a remote
+// target_dir for its local staging dir before anything expands it.
+func ExpandTargetDir(raw string) (string, error) {
+	if IsRcloneRemote(raw) {
+		return "", fmt.Errorf("%w: %s", ErrRcloneRemote, strings.TrimSpace(raw))
+	}
+	return ExpandPath(raw)
+}
+
 func ResolveStateFile(defaultStateDir string, stateFile string) (string, error) {
 	expandedStateFile, err := ExpandPath(stateFile)
 	if err != nil {
@@ -104,3 +131,13 @@ func ResolveArchiveFile(defaultStateDir string, archiveFile string, sourceID str
 
 	return filepath.Clean(filepath.Join(expandedStateDir, expandedArchiveFile)), nil
 }
+
+// ResolveCookiesFile expands a source's cookies_file, or returns "" when the
+// source has none.
+func ResolveCookiesFile(source Source) (string, error) {
+	raw := strings.TrimSpace(source.CookiesFile)
+	if raw == "" {
+		return "", nil
+	}
+	return ExpandPath(raw)
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 )
@@ -26,3 +27,59 @@ func TestResolveArchiveFileKeepsNestedRelativePath(t *testing.T) {
 		t.Fatalf("unexpected archive path. got=%q want=%q", got, want)
 	}
 }
+
+func TestIsRcloneRemote(t *testing.T) {
+	for raw, want := range map[string]bool{
+		"gdrive:Music/Playlists": true,
+		"my-nas:":                true,
+		"b2.backup:music":        true,
+		"C:\\Music":              false,
+		"/Users/me/Music":        false,
+		"~/Music":                false,
+		"Music/Mixes":            false,
+		"":                       false,
+	} {
+		if got := IsRcloneRemote(raw); got != want {
+			t.Fatalf("IsRcloneRemote(%q) = %v, want %v", raw, got, want)
+		}
+	}
+	if _, err := ExpandTargetDir("gdrive:Music"); !errors.Is(err, ErrRcloneRemote) {
+		t.Fatalf("expected ExpandTargetDir to reject a remote, got %v", err)
+	}
+	if got, err := ExpandPath("notes:draft.txt"); err != nil || got != "notes:draft.txt" {
+		t.Fatalf("expected ExpandPath to leave a colon in a file name alone, got %q (%v)", got, err)
+	}
+}
+
+func TestSoundCloudRepostsAndStationURLs(t *testing.T) {
+	if profile, ok := SoundCloudRepostsProfile("https://soundcloud.com/user/reposts/"); !ok || profile != "https://soundcloud.com/user" {
+		t.Fatalf("unexpected reposts profile %q (%v)", profile, ok)
+	}
+	if _, ok := SoundCloudRepostsProfile("https://soundcloud.com/user/sets/reposts"); ok {
+		t.Fatalf("expected a set named reposts not to be a reposts feed")
+	}
+	for raw, want := range map[string]bool{
+		"https://soundcloud.com/stations/track/user/seed":         true,
+		"https://soundcloud.com/stations/artist/user":             true,
+		"https://soundcloud.com/discover/sets/track-stations:123": true,
+		"https://soundcloud.com/discover/sets/weekly::user":       false,
+		"https://soundcloud.com/stations/track/user":              false,
+		"https://soundcloud.com/user/seed":                        false,
+		"https://example.com/stations/artist/user":                false,
+	} {
+		if got := IsSoundCloudStationURL(raw); got != want {
+			t.Fatalf("IsSoundCloudStationURL(%q) = %v, want %v", raw, got, want)
+		}
+	}
+	station := Source{URL: "https://soundcloud.com/stations/artist/user"}
+	if got := SoundCloudMaxDepth(station); got != DefaultSoundCloudStationDepth {
+		t.Fatalf("expected the default station depth, got %d", got)
+	}
+	station.Sync.MaxDepth = 20
+	if got := SoundCloudMaxDepth(station); got != 20 {
+		t.Fatalf("expected sync.max_depth to win, got %d", got)
+	}
+	if got := SoundCloudMaxDepth(Source{URL: "https://soundcloud.com/user"}); got != 0 {
+		t.Fatalf("expected profiles to read the whole listing, got %d", got)
+	}
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+const RedactedValue = "<redacted>"
+
+// Redacted returns a copy of cfg that is safe to write to disk or share:
+// URL query strings, fragments, userinfo, and SoundCloud secret-link tokens are
+// dropped, and values of credential-looking flags in adapter extra_args and
+// metadata provider commands are replaced with RedactedValue. Notification
+// webhook URLs keep only their scheme and host. Fields tagged
+// yaml:"-" (resolved ARLs and client secrets) never reach the marshaled form.
+func Redacted(cfg Config) Config {
+	redacted := cfg
+	redacted.Defaults.Notifications = make([]Notification, len(cfg.Defaults.Notifications))
+	for i, notification := range cfg.Defaults.Notifications {
+		notification.URL = redactWebhookURL(notification.URL)
+		redacted.Defaults.Notifications[i] = notification
+	}
+	redacted.MetadataProviders = make([]MetadataProvider, len(cfg.MetadataProviders))
+	for i, provider := range cfg.MetadataProviders {
+		provider.Command = redactArgs(provider.Command)
+		redacted.MetadataProviders[i] = provider
+	}
+	redacted.Sources = make([]Source, len(cfg.Sources))
+	for i, source := range cfg.Sources {
+		source.URL = RedactURL(source.URL)
+		source.Adapter.ExtraArgs = redactArgs(source.Adapter.ExtraArgs)
+		source.DeezerARL = ""
+		source.SpotifyClientID = ""
+		source.SpotifyClientSecret = ""
+		redacted.Sources[i] = source
+	}
+	return redacted
+}
+
+// RedactURL strips parts of a URL that commonly carry credentials or private
+// share tokens while keeping the host and path readable.
+func RedactURL(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return raw
+	}
+	parsed, err := url.Parse(trimmed)
+	if err != nil || parsed.Host == "" {
+		return trimmed
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.Fragment = ""
+	if strings.HasSuffix(strings.ToLower(parsed.Hostname()), "soundcloud.com") {
+		segments := strings.Split(parsed.Path, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, "s-") && len(segment) > 2 {
+				segments[i] = "s-redacted"
+			}
+		}
+		parsed.Path = strings.Join(segments, "/")
+		parsed.RawPath = ""
+	}
+	return parsed.String()
+}
+
+// redactWebhookURL keeps only scheme and host; webhook paths are credentials.
+func redactWebhookURL(raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return raw
+	}
+	parsed, err := url.Parse(trimmed)
+	if err != nil || parsed.Host == "" {
+		return RedactedValue
+	}
+	return parsed.Scheme + "://" + parsed.Host + "/" + RedactedValue
+}
+
+func redactArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	out := make([]string, len(args))
+	redactNext := false
+	for i, arg := range args {
+		if redactNext {
+			out[i] = RedactedValue
+			redactNext = false
+			continue
+		}
+		trimmed := strings.TrimSpace(arg)
+		if !strings.HasPrefix(trimmed, "-") {
+			out[i] = RedactURL(arg)
+			continue
+		}
+		name, _, hasValue := strings.Cut(trimmed, "=")
+		if !isSecretFlag(name) {
+			out[i] = arg
+			continue
+		}
+		if hasValue {
+			out[i] = name + "=" + RedactedValue
+			continue
+		}
+		out[i] = arg
+		redactNext = true
+	}
+	return out
+}
+
+func isSecretFlag(flag string) bool {
+	name := strings.ToLower(strings.TrimLeft(flag, "-"))
+	name = strings.NewReplacer("-", "", "_", "").Replace(name)
+	if name == "arl" {
+		return true
+	}
+	for _, marker := range []string{"token", "secret", "password", "passwd", "apikey", "clientid", "authorization", "oauth", "cookie"} {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
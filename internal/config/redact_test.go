@@ -0,0 +1,40 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactedStripsURLSecretsAndCredentialFlags(t *testing.T) {
+	cfg := Config{
+		MetadataProviders: []MetadataProvider{{Name: "exec", Command: []string{"/bin/lookup", "--api-key", "k123"}}},
+		Sources: []Source{{
+			ID:                  "sc",
+			URL:                 "https://user:pw@soundcloud.com/artist/track/s-AbCdEf?si=abc#t=1",
+			DeezerARL:           "arl-secret",
+			SpotifyClientSecret: "shh",
+			Adapter: AdapterSpec{ExtraArgs: []string{
+				"-f", "--client-id", "abc123", "--auth-token=tok", "--onlymp3",
+			}},
+		}},
+	}
+	redacted := Redacted(cfg)
+
+	source := redacted.Sources[0]
+	if source.URL != "https://soundcloud.com/artist/track/s-redacted" {
+		t.Fatalf("unexpected redacted url: %q", source.URL)
+	}
+	if source.DeezerARL != "" || source.SpotifyClientSecret != "" {
+		t.Fatalf("expected resolved credentials to be cleared, got %+v", source)
+	}
+	got := strings.Join(source.Adapter.ExtraArgs, " ")
+	if got != "-f --client-id <redacted> --auth-token=<redacted> --onlymp3" {
+		t.Fatalf("unexpected redacted extra_args: %q", got)
+	}
+	if strings.Join(redacted.MetadataProviders[0].Command, " ") != "/bin/lookup --api-key <redacted>" {
+		t.Fatalf("unexpected redacted provider command: %v", redacted.MetadataProviders[0].Command)
+	}
+	if cfg.Sources[0].Adapter.ExtraArgs[2] != "abc123" || cfg.MetadataProviders[0].Command[2] != "k123" {
+		t.Fatalf("expected original config to stay untouched")
+	}
+}
@@ -3,7 +3,7 @@ package config
 import "fmt"
 
 func DefaultTemplate() string {
-	return fmt.Sprintf(`version: 1
+	return fmt.Sprintf(`version: 2
 defaults:
   state_dir: %q
   archive_file: %q
@@ -1,59 +1,623 @@
 package config
 
+import (
+	"net/url"
+	"strings"
+)
+
 type SourceType string
 
 const (
 	SourceTypeSpotify    SourceType = "spotify"
 	SourceTypeSoundCloud SourceType = "soundcloud"
+	SourceTypeYouTube    SourceType = "youtube"
+	SourceTypeTidal      SourceType = "tidal"
+	SourceTypeDeezer     SourceType = "deezer"
+	SourceTypeAppleMusic SourceType = "apple_music"
 )
 
+// CurrentVersion is the config schema written by `udl init` and
+// `udl config migrate`. Version 1 files still load; profiles and per-source
+// defaults require version 2.
+const CurrentVersion = 2
+
 type Config struct {
-	Version  int      `yaml:"version"`
-	Defaults Defaults `yaml:"defaults"`
-	Sources  []Source `yaml:"sources"`
+	Version int `yaml:"version"`
+	// Profile names the profile applied by Load; --profile and UDL_PROFILE
+	// take precedence over this value.
+	Profile           string             `yaml:"profile,omitempty"`
+	Defaults          Defaults           `yaml:"defaults"`
+	Profiles          map[string]Profile `yaml:"profiles,omitempty"`
+	MetadataProviders []MetadataProvider `yaml:"metadata_providers,omitempty"`
+	FreeDL            FreeDL             `yaml:"freedl,omitempty"`
+	PostProcessing    PostProcessing     `yaml:"post_processing,omitempty"`
+	Compat            Compat             `yaml:"compat,omitempty"`
+	Retention         Retention          `yaml:"retention,omitempty"`
+	Sources           []Source           `yaml:"sources"`
+}
+
+// Profile overrides defaults for one machine or environment (for example
+// "laptop" vs "nas"). Zero values inherit from defaults.
+type Profile struct {
+	Defaults ProfileDefaults `yaml:"defaults,omitempty"`
+}
+
+type ProfileDefaults struct {
+	StateDir              string `yaml:"state_dir,omitempty"`
+	ArchiveFile           string `yaml:"archive_file,omitempty"`
+	Threads               int    `yaml:"threads,omitempty"`
+	ContinueOnError       *bool  `yaml:"continue_on_error,omitempty"`
+	CommandTimeoutSeconds int    `yaml:"command_timeout_seconds,omitempty"`
+}
+
+// SourceDefaults overrides defaults for a single source. Zero values inherit
+// the (profile-adjusted) defaults.
+type SourceDefaults struct {
+	ArchiveFile           string `yaml:"archive_file,omitempty"`
+	Threads               int    `yaml:"threads,omitempty"`
+	CommandTimeoutSeconds int    `yaml:"command_timeout_seconds,omitempty"`
+	// ArtifactPatterns replaces the cleanup patterns of the source's adapter;
+	// KeepArtifacts and QuarantineArtifacts override
+	// defaults.artifact_cleanup.keep_artifacts and .quarantine.
+	ArtifactPatterns    []string `yaml:"artifact_patterns,omitempty"`
+	KeepArtifacts       *bool    `yaml:"keep_artifacts,omitempty"`
+	QuarantineArtifacts *bool    `yaml:"quarantine_artifacts,omitempty"`
+}
+
+// IsZero reports whether the source overrides no defaults.
+func (d SourceDefaults) IsZero() bool {
+	return d.ArchiveFile == "" && d.Threads == 0 && d.CommandTimeoutSeconds == 0 &&
+		d.ArtifactPatterns == nil && d.KeepArtifacts == nil && d.QuarantineArtifacts == nil
+}
+
+// FreeDL tunes the SoundCloud free-download browser flow. Zero values keep the
+// built-in defaults; raise the poll interval and stable sample count for slow
+// NAS-mounted or cloud-synced Downloads folders.
+type FreeDL struct {
+	PollIntervalMS     int      `yaml:"poll_interval_ms,omitempty"`
+	StableSamples      int      `yaml:"stable_samples,omitempty"`
+	IdleTimeoutSeconds int      `yaml:"idle_timeout_seconds,omitempty"`
+	ArtworkVariants    []string `yaml:"artwork_variants,omitempty"`
+	// InteractiveBudgetSeconds caps the wall-clock time one sync spends in
+	// browser gates. Once used up, remaining gated tracks are deferred and
+	// listed at the end of the run. Zero means no cap.
+	InteractiveBudgetSeconds int `yaml:"interactive_budget_seconds,omitempty"`
+	// ConcurrentGates opens up to this many browser-handoff gates at once
+	// and tells their downloads apart by title and artist. Zero or one opens
+	// one gate at a time.
+	ConcurrentGates int `yaml:"concurrent_gates,omitempty"`
+	// VerifyQuality runs a spectral check on lossless free-DL downloads and
+	// flags those that look upsampled from a lossy rip as suspect quality.
+	VerifyQuality bool `yaml:"verify_quality,omitempty"`
+	// Automation "headless" completes Hypeddit and ToneDen gates in a
+	// headless Chrome before falling back to the browser handoff. Empty or
+	// "handoff" keeps the handoff only.
+	Automation string `yaml:"automation,omitempty"`
+	// Email is submitted to gates that ask for one and Comment is posted on
+	// gates with a comment step; automation hands such gates off while the
+	// value is unset.
+	Email   string `yaml:"email,omitempty"`
+	Comment string `yaml:"comment,omitempty"`
+	// BrowserProfileDir is the Chrome profile automation runs in, signed in
+	// to SoundCloud for follow/like/comment steps. Empty uses
+	// <state_dir>/freedl-browser-profile.
+	BrowserProfileDir string `yaml:"browser_profile_dir,omitempty"`
+}
+
+// FreeDLMaxConcurrentGates caps freedl.concurrent_gates.
+const FreeDLMaxConcurrentGates = 8
+
+// freedl.automation values.
+const (
+	FreeDLAutomationHandoff  = "handoff"
+	FreeDLAutomationHeadless = "headless"
+)
+
+const (
+	PostProcessingPriorityNormal = "normal"
+	PostProcessingPriorityLow    = "low"
+	PostProcessingPriorityIdle   = "idle"
+)
+
+// PostProcessing throttles the ffmpeg remuxes and transcodes udl runs itself
+// after downloads (free-download tagging, promote-freedl), independently of
+// download threads. Priority low or idle runs them under nice/ionice (Linux)
+// or nice/taskpolicy (macOS) so downloads and other services keep the disk.
+// Zero values mean one job at a time at low priority.
+type PostProcessing struct {
+	MaxConcurrent int    `yaml:"max_concurrent,omitempty"`
+	Priority      string `yaml:"priority,omitempty"`
+}
+
+// Compat extends the dependency compatibility matrix udl doctor checks tool
+// versions against, so a broken release can be blocklisted without a new
+// udl build. URL points at an optional remote JSON document with the same
+// tools shape; rules in Tools are applied on top of it.
+type Compat struct {
+	URL   string                `yaml:"url,omitempty"`
+	Tools map[string]CompatRule `yaml:"tools,omitempty"`
+}
+
+// Retention bounds the run reports, logs, caches, and trash that pile up
+// under state_dir. Rules are enforced at the end of every sync and by udl gc;
+// zero values keep a class forever. The newest artifact of a class is always
+// kept.
+type Retention struct {
+	Reports RetentionRule `yaml:"reports,omitempty"`
+	Logs    RetentionRule `yaml:"logs,omitempty"`
+	Caches  RetentionRule `yaml:"caches,omitempty"`
+	Backups RetentionRule `yaml:"backups,omitempty"`
+}
+
+// RetentionRule drops a class's artifacts older than MaxAgeDays, then the
+// oldest ones until the rest fit in MaxSizeMB.
+type RetentionRule struct {
+	MaxAgeDays int `yaml:"max_age_days,omitempty"`
+	MaxSizeMB  int `yaml:"max_size_mb,omitempty"`
+}
+
+// CompatRule overrides the supported version range of one tool and adds
+// known-bad versions, mapped to the reason they are blocked.
+type CompatRule struct {
+	MinVersion          string            `yaml:"min_version,omitempty"`
+	MaxVersionExclusive string            `yaml:"max_version_exclusive,omitempty"`
+	KnownBad            map[string]string `yaml:"known_bad,omitempty"`
+}
+
+// MetadataProvider configures an external exec-protocol plugin that enriches
+// downloaded track tags (label, catalog number, genre). Providers with a higher
+// priority are consulted first.
+type MetadataProvider struct {
+	Name           string   `yaml:"name"`
+	Enabled        bool     `yaml:"enabled"`
+	Command        []string `yaml:"command"`
+	Priority       int      `yaml:"priority,omitempty"`
+	TimeoutSeconds int      `yaml:"timeout_seconds,omitempty"`
 }
 
 type Defaults struct {
-	StateDir              string `yaml:"state_dir"`
-	ArchiveFile           string `yaml:"archive_file"`
-	Threads               int    `yaml:"threads"`
-	ContinueOnError       bool   `yaml:"continue_on_error"`
-	CommandTimeoutSeconds int    `yaml:"command_timeout_seconds"`
+	StateDir              string          `yaml:"state_dir"`
+	ArchiveFile           string          `yaml:"archive_file"`
+	Threads               int             `yaml:"threads"`
+	ContinueOnError       bool            `yaml:"continue_on_error"`
+	CommandTimeoutSeconds int             `yaml:"command_timeout_seconds"`
+	Notifications         []Notification  `yaml:"notifications,omitempty"`
+	FileOwnership         FileOwnership   `yaml:"file_ownership,omitempty"`
+	ArtifactCleanup       ArtifactCleanup `yaml:"artifact_cleanup,omitempty"`
+}
+
+// ArtifactCleanup controls which leftovers a failed adapter run removes from
+// the target directory. Adapters maps an adapter kind to glob patterns
+// (path.Match syntax) matched against file names, or against the path
+// relative to the target directory when the pattern has a "/"; a kind listed
+// here replaces its built-in patterns, and an empty list cleans nothing.
+// KeepArtifacts leaves every leftover in place for debugging a failed run;
+// Quarantine moves them to <state_dir>/quarantine/<source>/<stamp> with a
+// manifest instead of deleting them.
+type ArtifactCleanup struct {
+	Adapters      map[string][]string `yaml:"adapters,omitempty"`
+	KeepArtifacts bool                `yaml:"keep_artifacts,omitempty"`
+	Quarantine    bool                `yaml:"quarantine,omitempty"`
+}
+
+// FileOwnership is enforced on the files and directories a sync creates, for
+// shared libraries (e.g. on a NAS) where udl runs as a different user than the
+// one that owns them. Umask is octal ("0002") and also applies to adapter
+// processes; Owner and Group are names or numeric ids. Handing files to
+// another user needs root.
+type FileOwnership struct {
+	Umask string `yaml:"umask,omitempty"`
+	Owner string `yaml:"owner,omitempty"`
+	Group string `yaml:"group,omitempty"`
+}
+
+const (
+	NotificationFormatGeneric = "generic"
+	NotificationFormatDiscord = "discord"
+	NotificationFormatSlack   = "slack"
+
+	NotificationEventFinished    = "finished"
+	NotificationEventFailed      = "failed"
+	NotificationEventInterrupted = "interrupted"
+	// NotificationEventNewTracks is sent in addition to the run event when
+	// monitor sources found remote tracks they had not seen before.
+	NotificationEventNewTracks = "new_tracks"
+)
+
+// Notification posts a sync summary to a webhook when a sync finishes, fails,
+// or is interrupted, and lists tracks monitor sources newly found. Webhook
+// URLs usually embed a secret token, so url_env is preferred over url to keep
+// them out of config files. Empty events means all.
+type Notification struct {
+	Name           string   `yaml:"name,omitempty"`
+	URL            string   `yaml:"url,omitempty"`
+	URLEnv         string   `yaml:"url_env,omitempty"`
+	Format         string   `yaml:"format,omitempty"`
+	Events         []string `yaml:"events,omitempty"`
+	TimeoutSeconds int      `yaml:"timeout_seconds,omitempty"`
 }
 
 type Source struct {
-	ID                  string      `yaml:"id"`
-	Type                SourceType  `yaml:"type"`
-	Enabled             bool        `yaml:"enabled"`
-	TargetDir           string      `yaml:"target_dir"`
-	URL                 string      `yaml:"url"`
-	StateFile           string      `yaml:"state_file,omitempty"`
-	SelectedPlaylistIDs []int       `yaml:"-"`
-	DisableSyncMode     bool        `yaml:"-"`
-	DownloadArchivePath string      `yaml:"-"`
-	DeezerARL           string      `yaml:"-"`
-	SpotifyClientID     string      `yaml:"-"`
-	SpotifyClientSecret string      `yaml:"-"`
-	DeemixRuntimeDir    string      `yaml:"-"`
-	Sync                SyncPolicy  `yaml:"sync,omitempty"`
-	Adapter             AdapterSpec `yaml:"adapter"`
+	ID                   string         `yaml:"id"`
+	Type                 SourceType     `yaml:"type"`
+	Enabled              bool           `yaml:"enabled"`
+	TargetDir            string         `yaml:"target_dir"`
+	URL                  string         `yaml:"url"`
+	StateFile            string         `yaml:"state_file,omitempty"`
+	Quality              []string       `yaml:"quality,omitempty"`
+	Tags                 []string       `yaml:"tags,omitempty"`
+	CookiesFile          string         `yaml:"cookies_file,omitempty"`
+	SelectedPlaylistIDs  []int          `yaml:"-"`
+	SelectedTrackIDs     []string       `yaml:"-"`
+	DisableSyncMode      bool           `yaml:"-"`
+	DownloadArchivePath  string         `yaml:"-"`
+	DeezerARL            string         `yaml:"-"`
+	SoundCloudClientID   string         `yaml:"-"`
+	SoundCloudOAuthToken string         `yaml:"-"`
+	SpotifyClientID      string         `yaml:"-"`
+	SpotifyClientSecret  string         `yaml:"-"`
+	DeemixRuntimeDir     string         `yaml:"-"`
+	DeemixBitrate        string         `yaml:"-"`
+	Defaults             SourceDefaults `yaml:"defaults,omitempty"`
+	Sync                 SyncPolicy     `yaml:"sync,omitempty"`
+	Adapter              AdapterSpec    `yaml:"adapter"`
+	// Monitor records the remote tracks sync would download in
+	// <state_dir>/<id>.monitor.json and reports them, without downloading
+	// anything or touching the state file and archive.
+	Monitor bool `yaml:"monitor,omitempty"`
 }
 
 type SyncPolicy struct {
-	BreakOnExisting *bool `yaml:"break_on_existing,omitempty"`
-	AskOnExisting   *bool `yaml:"ask_on_existing,omitempty"`
-	LocalIndexCache *bool `yaml:"local_index_cache,omitempty"`
+	BreakOnExisting *bool  `yaml:"break_on_existing,omitempty"`
+	AskOnExisting   *bool  `yaml:"ask_on_existing,omitempty"`
+	LocalIndexCache *bool  `yaml:"local_index_cache,omitempty"`
+	FreeDownloads   string `yaml:"free_downloads,omitempty"`
+	// IncludeGroups filters which releases a Spotify artist source syncs
+	// (album, single, appears_on, compilation). Empty means album+single.
+	IncludeGroups []string `yaml:"include_groups,omitempty"`
+	// Schedule is used by `udl watch`: a duration ("6h"), "@every <duration>",
+	// @hourly/@daily/@weekly, or a five-field cron expression in local time.
+	Schedule string `yaml:"schedule,omitempty"`
+	// SuccessWhen decides when a run of the source counts as successful, e.g.
+	// "failed_tracks == 0 && unavailable <= 2". Empty keeps the default: the
+	// source succeeds unless the adapter or engine reports a failure.
+	SuccessWhen string `yaml:"success_when,omitempty"`
+	// DedupeAcrossSources skips planned tracks that another source syncing
+	// into the same target_dir already downloaded, matched on normalized
+	// artist/title keys.
+	DedupeAcrossSources *bool `yaml:"dedupe_across_sources,omitempty"`
+	// MaxRemoteShrinkPercent aborts planning when the remote track count
+	// drops by more than this percentage since the last sync. 0 disables it.
+	MaxRemoteShrinkPercent int `yaml:"max_remote_shrink_percent,omitempty"`
+	// NewReleasesDays is how far back a spotify:new-releases source looks
+	// for releases by followed artists. 0 means 30 days.
+	NewReleasesDays int `yaml:"new_releases_days,omitempty"`
+	// MaxDepth caps how many tracks from the top of a SoundCloud listing are
+	// enumerated and planned. 0 reads the whole listing, except for station
+	// sources, which read DefaultSoundCloudStationDepth tracks.
+	MaxDepth int `yaml:"max_depth,omitempty"`
+	// Concurrency is how many deemix track subprocesses a spotify+deemix
+	// source runs at once. 0 and 1 download one track at a time.
+	Concurrency int `yaml:"concurrency,omitempty"`
+	// PlaylistFile is an .m3u8/.m3u path relative to target_dir that is
+	// rewritten after each sync with the source's tracks in remote order.
+	PlaylistFile string `yaml:"playlist_file,omitempty"`
+	// Prune moves local files of tracks that disappeared from the remote
+	// playlist to <state_dir>/trash and drops them from the state file, after
+	// confirmation or with sync --apply.
+	Prune *bool `yaml:"prune,omitempty"`
+	// SourceInfo writes <target_dir>/source.json with the remote playlist's
+	// name, owner, description, and URL after each sync; SourceCover also
+	// saves the playlist cover image next to it.
+	SourceInfo  *bool `yaml:"source_info,omitempty"`
+	SourceCover *bool `yaml:"source_cover,omitempty"`
+	// ReplayGain runs loudness analysis on the files each sync adds to
+	// target_dir and writes ReplayGain track tags, using rsgain or ffmpeg's
+	// ebur128 filter. Empty disables it.
+	ReplayGain string `yaml:"replaygain,omitempty"`
+	// MusicBrainz looks up the files each sync adds to target_dir on
+	// MusicBrainz, by title and artist or by AcoustID fingerprint, and writes
+	// MBIDs plus missing album, date, and genre tags. Empty disables it.
+	MusicBrainz string `yaml:"musicbrainz,omitempty"`
+	// Lyrics looks up lyrics on LRCLIB for the files each sync adds to
+	// target_dir and embeds them as a LYRICS tag or writes a sidecar .lrc
+	// file. Empty disables it.
+	Lyrics string `yaml:"lyrics,omitempty"`
+	// StallMinKbps and StallSeconds cancel a deemix track whose download
+	// grows slower than StallMinKbps for StallSeconds in a row; the track is
+	// retried once at the end of the run. Both unset disables the watchdog.
+	StallMinKbps int `yaml:"stall_min_kbps,omitempty"`
+	StallSeconds int `yaml:"stall_seconds,omitempty"`
+	// Compilations tags tracks from compilation and Various Artists releases
+	// as such (compilation flag, album artist "Various Artists", track and
+	// disc numbers) and files them under <target_dir>/Compilations/<album>.
+	Compilations *bool `yaml:"compilations,omitempty"`
+	// GenreRouting files downloads under <target_dir>/<folder> by the
+	// track's genre. The first rule with a matching genre wins; tracks that
+	// match none stay in target_dir.
+	GenreRouting []GenreRoute `yaml:"genre_routing,omitempty"`
+	// Blocklist names tracks the planner never plans, so they are neither
+	// downloaded nor retried.
+	Blocklist Blocklist `yaml:"blocklist,omitempty"`
+	// SkipTTLDays remembers tracks skipped as unavailable-on-deezer or
+	// no-free-download-link for that many days, during which the planner
+	// leaves them out instead of checking them again. 0 disables it.
+	SkipTTLDays int `yaml:"skip_ttl_days,omitempty"`
+}
+
+// Blocklist is a source's sync.blocklist. A remote track is blocked when its
+// id is in IDs, its URL matches one of the URLs patterns (case-insensitive,
+// "*" matching any run of characters, slashes included), or its title
+// ("Artist - Title" where the listing has an artist) matches one of the
+// Titles regular expressions.
+type Blocklist struct {
+	IDs    []string `yaml:"ids,omitempty"`
+	URLs   []string `yaml:"urls,omitempty"`
+	Titles []string `yaml:"titles,omitempty"`
+}
+
+// Empty reports whether the blocklist blocks nothing.
+func (b Blocklist) Empty() bool {
+	return len(b.IDs) == 0 && len(b.URLs) == 0 && len(b.Titles) == 0
+}
+
+// GenreRoute is one sync.genre_routing rule. Genres are case-insensitive
+// glob patterns ("drum & bass", "techno*") matched against the track's
+// genre; Folder is a path relative to target_dir.
+type GenreRoute struct {
+	Genres []string `yaml:"genres"`
+	Folder string   `yaml:"folder"`
+}
+
+// sync.replaygain values: the tool that measures loudness and writes the
+// ReplayGain tags.
+const (
+	ReplayGainRsgain = "rsgain"
+	ReplayGainFFmpeg = "ffmpeg"
+)
+
+// sync.musicbrainz values: how files are matched to MusicBrainz recordings.
+const (
+	MusicBrainzSearch      = "search"
+	MusicBrainzFingerprint = "fingerprint"
+)
+
+// sync.lyrics values: where fetched lyrics are written.
+const (
+	LyricsTags = "tags"
+	LyricsLRC  = "lrc"
+)
+
+// Deemix quality tiers for a source's quality list, which is ordered best
+// first. A track missing at one tier is retried at the next before it is
+// skipped.
+const (
+	DeemixQualityFLAC = "flac"
+	DeemixQuality320  = "320"
+	DeemixQuality128  = "128"
+)
+
+// MaxSyncConcurrency caps sync.concurrency.
+const MaxSyncConcurrency = 8
+
+// sync.free_downloads values for SoundCloud sources. They control whether the
+// browser-gate free-download flow runs and how gated tracks are planned.
+const (
+	FreeDownloadsInclude = "include"
+	FreeDownloadsOnly    = "only"
+	FreeDownloadsSkip    = "skip"
+)
+
+// FreeDownloadsPolicy returns the effective sync.free_downloads value. Unset
+// means "only" for scdl-freedl sources and "include" for everything else.
+func FreeDownloadsPolicy(source Source) string {
+	if policy := strings.ToLower(strings.TrimSpace(source.Sync.FreeDownloads)); policy != "" {
+		return policy
+	}
+	if source.Adapter.Kind == "scdl-freedl" {
+		return FreeDownloadsOnly
+	}
+	return FreeDownloadsInclude
+}
+
+// sync.include_groups values for Spotify artist sources, matching the Web
+// API's include_groups filter on /artists/{id}/albums.
+const (
+	SpotifyAlbumGroupAlbum       = "album"
+	SpotifyAlbumGroupSingle      = "single"
+	SpotifyAlbumGroupAppearsOn   = "appears_on"
+	SpotifyAlbumGroupCompilation = "compilation"
+)
+
+// SpotifyArtistIncludeGroups returns the effective sync.include_groups for a
+// Spotify artist source, lower-cased and de-duplicated.
+func SpotifyArtistIncludeGroups(source Source) []string {
+	groups := []string{}
+	seen := map[string]struct{}{}
+	for _, raw := range source.Sync.IncludeGroups {
+		group := strings.ToLower(strings.TrimSpace(raw))
+		if group == "" {
+			continue
+		}
+		if _, ok := seen[group]; ok {
+			continue
+		}
+		seen[group] = struct{}{}
+		groups = append(groups, group)
+	}
+	if len(groups) == 0 {
+		return []string{SpotifyAlbumGroupAlbum, SpotifyAlbumGroupSingle}
+	}
+	return groups
+}
+
+// Pseudo-source URLs for the signed-in Spotify user's library: saved tracks,
+// and recent releases by artists the user follows.
+const (
+	SpotifyLikedURL       = "spotify:liked"
+	SpotifyNewReleasesURL = "spotify:new-releases"
+)
+
+// DefaultSpotifyNewReleasesDays is the spotify:new-releases window when
+// sync.new_releases_days is unset.
+const DefaultSpotifyNewReleasesDays = 30
+
+// IsSpotifyUserLibraryURL reports whether raw is spotify:liked or
+// spotify:new-releases, which are listed with the user's Spotify login.
+func IsSpotifyUserLibraryURL(raw string) bool {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case SpotifyLikedURL, SpotifyNewReleasesURL:
+		return true
+	}
+	return false
+}
+
+// IsSpotifyNewReleasesURL reports whether raw is spotify:new-releases, in any
+// letter case.
+func IsSpotifyNewReleasesURL(raw string) bool {
+	return strings.EqualFold(strings.TrimSpace(raw), SpotifyNewReleasesURL)
+}
+
+// SpotifyNewReleasesDays returns the source's new-releases window in days.
+func SpotifyNewReleasesDays(source Source) int {
+	if source.Sync.NewReleasesDays > 0 {
+		return source.Sync.NewReleasesDays
+	}
+	return DefaultSpotifyNewReleasesDays
+}
+
+// IsSpotifyArtistURL reports whether raw names a Spotify artist, either as an
+// open.spotify.com/artist/<id> link or a spotify:artist:<id> URI.
+func IsSpotifyArtistURL(raw string) bool {
+	trimmed := strings.ToLower(strings.TrimSpace(raw))
+	if strings.HasPrefix(trimmed, "spotify:artist:") {
+		return true
+	}
+	parsed, err := url.Parse(trimmed)
+	if err != nil {
+		return false
+	}
+	host := parsed.Hostname()
+	if host != "open.spotify.com" && host != "play.spotify.com" {
+		return false
+	}
+	for _, part := range strings.Split(strings.Trim(parsed.Path, "/"), "/") {
+		if part == "artist" {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultSoundCloudStationDepth is how many tracks of a SoundCloud station are
+// planned when sync.max_depth is unset; stations have no natural end.
+const DefaultSoundCloudStationDepth = 50
+
+// SoundCloudRepostsProfile returns the profile URL of a
+// soundcloud.com/<user>/reposts feed URL, and false for any other URL.
+func SoundCloudRepostsProfile(raw string) (string, bool) {
+	segments, ok := soundCloudPathSegments(raw)
+	if !ok || len(segments) != 2 || !strings.EqualFold(segments[1], "reposts") {
+		return "", false
+	}
+	return "https://soundcloud.com/" + segments[0], true
+}
+
+// IsSoundCloudStationURL reports whether raw is an algorithmic SoundCloud
+// station: soundcloud.com/stations/track/<user>/<track>,
+// soundcloud.com/stations/artist/<user>, or the
+// soundcloud.com/discover/sets/track-stations:<id> and artist-stations:<id>
+// links of the web app.
+func IsSoundCloudStationURL(raw string) bool {
+	segments, ok := soundCloudPathSegments(raw)
+	if !ok || len(segments) < 2 {
+		return false
+	}
+	first := strings.ToLower(segments[0])
+	kind := strings.ToLower(segments[1])
+	switch {
+	case first == "stations" && kind == "track":
+		return len(segments) == 4
+	case first == "stations" && kind == "artist":
+		return len(segments) == 3
+	case first == "discover" && kind == "sets" && len(segments) == 3:
+		station := strings.ToLower(segments[2])
+		return strings.HasPrefix(station, "track-stations:") || strings.HasPrefix(station, "artist-stations:")
+	}
+	return false
+}
+
+// SoundCloudMaxDepth returns how many listing tracks a SoundCloud source
+// plans, 0 meaning all of them.
+func SoundCloudMaxDepth(source Source) int {
+	if source.Sync.MaxDepth > 0 {
+		return source.Sync.MaxDepth
+	}
+	if IsSoundCloudStationURL(source.URL) {
+		return DefaultSoundCloudStationDepth
+	}
+	return 0
+}
+
+func soundCloudPathSegments(raw string) ([]string, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, false
+	}
+	switch strings.ToLower(parsed.Hostname()) {
+	case "soundcloud.com", "www.soundcloud.com", "m.soundcloud.com":
+	default:
+		return nil, false
+	}
+	trimmed := strings.Trim(parsed.Path, "/")
+	if trimmed == "" {
+		return nil, false
+	}
+	return strings.Split(trimmed, "/"), true
 }
 
 type AdapterSpec struct {
 	Kind       string   `yaml:"kind"`
 	ExtraArgs  []string `yaml:"extra_args,omitempty"`
 	MinVersion string   `yaml:"min_version,omitempty"`
+	// Fallback lists adapter kinds (spotdl, ytdlp) tried in order for a
+	// track the deemix adapter reports unavailable.
+	Fallback []string `yaml:"fallback,omitempty"`
+}
+
+// ForSource returns the defaults a source runs with: per-source overrides
+// win over the effective defaults.
+func (d Defaults) ForSource(source Source) Defaults {
+	effective := d
+	if archiveFile := strings.TrimSpace(source.Defaults.ArchiveFile); archiveFile != "" {
+		effective.ArchiveFile = archiveFile
+	}
+	if source.Defaults.Threads > 0 {
+		effective.Threads = source.Defaults.Threads
+	}
+	if source.Defaults.CommandTimeoutSeconds > 0 {
+		effective.CommandTimeoutSeconds = source.Defaults.CommandTimeoutSeconds
+	}
+	if source.Defaults.ArtifactPatterns != nil {
+		adapters := make(map[string][]string, len(d.ArtifactCleanup.Adapters)+1)
+		for kind, patterns := range d.ArtifactCleanup.Adapters {
+			adapters[kind] = patterns
+		}
+		adapters[source.Adapter.Kind] = append([]string{}, source.Defaults.ArtifactPatterns...)
+		effective.ArtifactCleanup.Adapters = adapters
+	}
+	if source.Defaults.KeepArtifacts != nil {
+		effective.ArtifactCleanup.KeepArtifacts = *source.Defaults.KeepArtifacts
+	}
+	if source.Defaults.QuarantineArtifacts != nil {
+		effective.ArtifactCleanup.Quarantine = *source.Defaults.QuarantineArtifacts
+	}
+	return effective
 }
 
 func DefaultConfig() Config {
 	return Config{
-		Version: 1,
+		Version: CurrentVersion,
 		Defaults: Defaults{
 			StateDir:              defaultStateDir(),
 			ArchiveFile:           "archive.txt",
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// spotifyLinkKinds are the open.spotify.com resource paths udl syncs.
+var spotifyLinkKinds = map[string]struct{}{
+	"playlist": {},
+	"album":    {},
+	"track":    {},
+	"artist":   {},
+}
+
+// shortLinkHosts redirect to a full Spotify or SoundCloud link.
+var shortLinkHosts = map[string]struct{}{
+	"spotify.link":          {},
+	"spotify.app.link":      {},
+	"on.soundcloud.com":     {},
+	"soundcloud.app.goo.gl": {},
+}
+
+// NormalizeSourceURL returns the canonical form of a Spotify or SoundCloud
+// source URL, so one playlist referenced two ways maps to the same URL:
+// share query params and fragments are dropped, hosts and schemes unified,
+// and Spotify URIs, locale (intl-xx), embed, and legacy /user/<name>/
+// playlist paths rewritten to open.spotify.com/<kind>/<id>. Short links and
+// URLs it does not recognize are returned trimmed but otherwise unchanged.
+func NormalizeSourceURL(sourceType SourceType, raw string) string {
+	trimmed := strings.TrimSpace(raw)
+	switch sourceType {
+	case SourceTypeSpotify:
+		return normalizeSpotifyURL(trimmed)
+	case SourceTypeSoundCloud:
+		return normalizeSoundCloudURL(trimmed)
+	default:
+		return trimmed
+	}
+}
+
+func normalizeSpotifyURL(raw string) string {
+	if strings.HasPrefix(strings.ToLower(raw), "spotify:") {
+		if IsSpotifyUserLibraryURL(raw) {
+			return strings.ToLower(raw)
+		}
+		parts := strings.Split(raw, ":")
+		if len(parts) == 3 && parts[2] != "" {
+			kind := strings.ToLower(parts[1])
+			if _, ok := spotifyLinkKinds[kind]; ok {
+				return "https://open.spotify.com/" + kind + "/" + parts[2]
+			}
+		}
+		return raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return raw
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host != "open.spotify.com" && host != "play.spotify.com" {
+		return raw
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(segments) > 0 && strings.HasPrefix(strings.ToLower(segments[0]), "intl-") {
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && strings.ToLower(segments[0]) == "embed" {
+		segments = segments[1:]
+	}
+	if len(segments) == 4 && strings.ToLower(segments[0]) == "user" && strings.ToLower(segments[2]) == "playlist" {
+		segments = segments[2:]
+	}
+	if len(segments) != 2 || segments[1] == "" {
+		return raw
+	}
+	kind := strings.ToLower(segments[0])
+	if _, ok := spotifyLinkKinds[kind]; !ok {
+		return raw
+	}
+	return "https://open.spotify.com/" + kind + "/" + segments[1]
+}
+
+func normalizeSoundCloudURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return raw
+	}
+	switch strings.ToLower(parsed.Hostname()) {
+	case "soundcloud.com", "www.soundcloud.com", "m.soundcloud.com":
+	default:
+		return raw
+	}
+	path := strings.TrimRight(parsed.EscapedPath(), "/")
+	if path == "" {
+		return raw
+	}
+	return "https://soundcloud.com" + path
+}
+
+// IsShortLink reports whether raw is a Spotify or SoundCloud share short link
+// (spotify.link, on.soundcloud.com, ...) that has to be resolved over HTTP.
+func IsShortLink(raw string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	_, ok := shortLinkHosts[strings.ToLower(parsed.Hostname())]
+	return ok
+}
+
+// ResolveShortLink follows the redirects of a share short link and returns
+// the first URL that is no longer a short link.
+func ResolveShortLink(ctx context.Context, link string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !IsShortLink(req.URL.String()) {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= 10 {
+				return fmt.Errorf("too many redirects")
+			}
+			return nil
+		},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSpace(link), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	location := resp.Request.URL
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		next, err := resp.Location()
+		if err != nil {
+			return "", fmt.Errorf("redirect without location: %w", err)
+		}
+		location = next
+	}
+	if IsShortLink(location.String()) {
+		return "", fmt.Errorf("%s did not redirect to a full link (HTTP %d)", link, resp.StatusCode)
+	}
+	return location.String(), nil
+}
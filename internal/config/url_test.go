@@ -0,0 +1,85 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeSourceURL(t *testing.T) {
+	cases := []struct {
+		sourceType SourceType
+		raw        string
+		want       string
+	}{
+		{SourceTypeSpotify, "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc123&pt=x", "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"},
+		{SourceTypeSpotify, "http://open.spotify.com/intl-de/album/1ATL5GLyefJaxhQzSPVrLX/", "https://open.spotify.com/album/1ATL5GLyefJaxhQzSPVrLX"},
+		{SourceTypeSpotify, "https://open.spotify.com/user/spotify/playlist/37i9dQZF1DXcBWIGoYBM5M", "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"},
+		{SourceTypeSpotify, "https://play.spotify.com/embed/track/4uLU6hMCjMI75M1A2tKUQC#t=10", "https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC"},
+		{SourceTypeSpotify, "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M", "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"},
+		{SourceTypeSpotify, "spotify:user:someone", "spotify:user:someone"},
+		{SourceTypeSpotify, " https://spotify.link/AbCdEf ", "https://spotify.link/AbCdEf"},
+		{SourceTypeSoundCloud, "https://m.soundcloud.com/user/sets/mix/?si=abc&utm_source=clipboard", "https://soundcloud.com/user/sets/mix"},
+		{SourceTypeSoundCloud, "http://www.soundcloud.com/artist/track/s-AbCdEf?in=user/sets/x", "https://soundcloud.com/artist/track/s-AbCdEf"},
+		{SourceTypeYouTube, "https://www.youtube.com/playlist?list=PL123", "https://www.youtube.com/playlist?list=PL123"},
+	}
+	for _, tc := range cases {
+		if got := NormalizeSourceURL(tc.sourceType, tc.raw); got != tc.want {
+			t.Fatalf("NormalizeSourceURL(%s, %q) = %q, want %q", tc.sourceType, tc.raw, got, tc.want)
+		}
+	}
+}
+
+func TestLoadStoresCanonicalSourceURLsAndRejectsShortLinks(t *testing.T) {
+	tmp := t.TempDir()
+	configPath := filepath.Join(tmp, "config.yaml")
+	payload := `version: 1
+defaults:
+  state_dir: "` + filepath.Join(tmp, "state") + `"
+sources:
+  - id: "spotify-mix"
+    type: "spotify"
+    target_dir: "/tmp/music-sp"
+    url: "https://open.spotify.com/intl-fr/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc"
+    adapter:
+      kind: "deemix"
+  - id: "soundcloud-likes"
+    type: "soundcloud"
+    target_dir: "/tmp/music-sc"
+    url: "https://on.soundcloud.com/AbCdEf"
+`
+	if err := os.WriteFile(configPath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(LoadOptions{ExplicitPath: configPath})
+	if err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	if got := cfg.Sources[0].URL; got != "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M" {
+		t.Fatalf("expected canonical spotify url, got %q", got)
+	}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `source "soundcloud-likes" url https://on.soundcloud.com/AbCdEf is a share short link that could not be resolved`) {
+		t.Fatalf("expected unresolved short link problem, got %v", err)
+	}
+}
+
+func TestResolveShortLinkStopsAtFirstFullLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc", http.StatusTemporaryRedirect)
+	}))
+	defer server.Close()
+
+	resolved, err := ResolveShortLink(context.Background(), server.URL+"/AbCdEf")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved != "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M?si=abc" {
+		t.Fatalf("unexpected resolved link %q", resolved)
+	}
+}
@@ -3,13 +3,29 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+
+	"github.com/jaa/update-downloads/internal/criteria"
+	"github.com/jaa/update-downloads/internal/schedule"
 )
 
 var sourceIDPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
 
+var artworkVariantPattern = regexp.MustCompile(`^(original|large|crop|t[0-9]+x[0-9]+)$`)
+
+var compatToolNames = map[string]struct{}{
+	"spotdl":   {},
+	"deemix":   {},
+	"scdl":     {},
+	"yt-dlp":   {},
+	"tidal-dl": {},
+	"gamdl":    {},
+}
+
 type ValidationError struct {
 	Problems []string
 }
@@ -24,8 +40,38 @@ func (e *ValidationError) Error() string {
 func Validate(cfg Config) error {
 	problems := []string{}
 
-	if cfg.Version != 1 {
-		problems = append(problems, "version must be 1")
+	if cfg.Version != 1 && cfg.Version != CurrentVersion {
+		problems = append(problems, "version must be 1 or 2")
+	}
+	if cfg.Version == 1 && usesVersion2Features(cfg) {
+		problems = append(problems, "profiles and per-source defaults require version: 2 (run `udl config migrate`)")
+	}
+	if name := strings.TrimSpace(cfg.Profile); name != "" {
+		if _, ok := cfg.Profiles[name]; !ok {
+			problems = append(problems, fmt.Sprintf("profile %q is not defined", name))
+		}
+	}
+	profileNames := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		profile := cfg.Profiles[name]
+		if !sourceIDPattern.MatchString(name) {
+			problems = append(problems, fmt.Sprintf("profile %q must match %s", name, sourceIDPattern.String()))
+		}
+		if profile.Defaults.StateDir != "" {
+			if stateDir, err := ExpandPath(profile.Defaults.StateDir); err != nil || !filepath.IsAbs(stateDir) {
+				problems = append(problems, fmt.Sprintf("profile %q defaults.state_dir must resolve to an absolute path", name))
+			}
+		}
+		if profile.Defaults.Threads < 0 {
+			problems = append(problems, fmt.Sprintf("profile %q defaults.threads must be >= 0", name))
+		}
+		if profile.Defaults.CommandTimeoutSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("profile %q defaults.command_timeout_seconds must be >= 0", name))
+		}
 	}
 
 	stateDir, err := ExpandPath(cfg.Defaults.StateDir)
@@ -45,10 +91,162 @@ func Validate(cfg Config) error {
 		problems = append(problems, "defaults.command_timeout_seconds must be > 0")
 	}
 
+	cleanupKinds := make([]string, 0, len(cfg.Defaults.ArtifactCleanup.Adapters))
+	for kind := range cfg.Defaults.ArtifactCleanup.Adapters {
+		cleanupKinds = append(cleanupKinds, kind)
+	}
+	sort.Strings(cleanupKinds)
+	for _, kind := range cleanupKinds {
+		switch kind {
+		case "spotdl", "scdl", "scdl-freedl", "deemix", "ytdlp", "tidal-dl", "gamdl":
+		default:
+			problems = append(problems, fmt.Sprintf("defaults.artifact_cleanup.adapters has unknown adapter kind %q", kind))
+		}
+		for _, pattern := range cfg.Defaults.ArtifactCleanup.Adapters[kind] {
+			if _, err := path.Match(pattern, ""); err != nil {
+				problems = append(problems, fmt.Sprintf("defaults.artifact_cleanup.adapters.%s has invalid pattern %q", kind, pattern))
+			}
+		}
+	}
+	if _, _, err := cfg.Defaults.FileOwnership.UmaskValue(); err != nil {
+		problems = append(problems, fmt.Sprintf("defaults.file_ownership.umask: %v", err))
+	}
+
 	if len(cfg.Sources) == 0 {
 		problems = append(problems, "at least one source must be configured")
 	}
 
+	for i, notification := range cfg.Defaults.Notifications {
+		label := notification.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i+1)
+		}
+		if notification.URL == "" && notification.URLEnv == "" {
+			problems = append(problems, fmt.Sprintf("notification %s must set url or url_env", label))
+		}
+		if notification.URL != "" && notification.URLEnv != "" {
+			problems = append(problems, fmt.Sprintf("notification %s must set only one of url or url_env", label))
+		}
+		if notification.URL != "" {
+			if err := validateURL(notification.URL); err != nil {
+				problems = append(problems, fmt.Sprintf("notification %s has invalid url: %v", label, err))
+			}
+		}
+		switch notification.Format {
+		case "", NotificationFormatGeneric, NotificationFormatDiscord, NotificationFormatSlack:
+		default:
+			problems = append(problems, fmt.Sprintf("notification %s format must be one of generic, discord, slack", label))
+		}
+		for _, event := range notification.Events {
+			switch event {
+			case NotificationEventFinished, NotificationEventFailed, NotificationEventInterrupted, NotificationEventNewTracks:
+			default:
+				problems = append(problems, fmt.Sprintf("notification %s has unknown event %q (use finished, failed, interrupted, new_tracks)", label, event))
+			}
+		}
+		if notification.TimeoutSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("notification %s timeout_seconds must be >= 0", label))
+		}
+	}
+
+	seenProviders := map[string]struct{}{}
+	for _, provider := range cfg.MetadataProviders {
+		if strings.TrimSpace(provider.Name) == "" {
+			problems = append(problems, "metadata_providers[].name must not be empty")
+		} else {
+			if !sourceIDPattern.MatchString(provider.Name) {
+				problems = append(problems, fmt.Sprintf("metadata provider %q has invalid name format", provider.Name))
+			}
+			if _, exists := seenProviders[provider.Name]; exists {
+				problems = append(problems, fmt.Sprintf("duplicate metadata provider name %q", provider.Name))
+			}
+			seenProviders[provider.Name] = struct{}{}
+		}
+		if len(provider.Command) == 0 || strings.TrimSpace(provider.Command[0]) == "" {
+			problems = append(problems, fmt.Sprintf("metadata provider %q command must be set", provider.Name))
+		}
+		if provider.TimeoutSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("metadata provider %q timeout_seconds must be >= 0", provider.Name))
+		}
+	}
+
+	if cfg.FreeDL.PollIntervalMS < 0 {
+		problems = append(problems, "freedl.poll_interval_ms must be >= 0")
+	}
+	if cfg.FreeDL.StableSamples < 0 {
+		problems = append(problems, "freedl.stable_samples must be >= 0")
+	}
+	if cfg.FreeDL.IdleTimeoutSeconds < 0 {
+		problems = append(problems, "freedl.idle_timeout_seconds must be >= 0")
+	}
+	if cfg.FreeDL.InteractiveBudgetSeconds < 0 {
+		problems = append(problems, "freedl.interactive_budget_seconds must be >= 0")
+	}
+	if cfg.FreeDL.ConcurrentGates < 0 || cfg.FreeDL.ConcurrentGates > FreeDLMaxConcurrentGates {
+		problems = append(problems, fmt.Sprintf("freedl.concurrent_gates must be between 0 and %d", FreeDLMaxConcurrentGates))
+	}
+	switch cfg.FreeDL.Automation {
+	case "", FreeDLAutomationHandoff, FreeDLAutomationHeadless:
+	default:
+		problems = append(problems, fmt.Sprintf("freedl.automation %q is invalid (expected handoff or headless)", cfg.FreeDL.Automation))
+	}
+	if email := cfg.FreeDL.Email; email != "" && (!strings.Contains(email, "@") || strings.ContainsAny(email, " \t")) {
+		problems = append(problems, fmt.Sprintf("freedl.email %q is not an email address", email))
+	}
+	for _, variant := range cfg.FreeDL.ArtworkVariants {
+		if !artworkVariantPattern.MatchString(variant) {
+			problems = append(problems, fmt.Sprintf("freedl.artwork_variants has unsupported variant %q (expected original, large, crop, or tWxH such as t500x500)", variant))
+		}
+	}
+
+	if cfg.PostProcessing.MaxConcurrent < 0 {
+		problems = append(problems, "post_processing.max_concurrent must be >= 0")
+	}
+	switch cfg.PostProcessing.Priority {
+	case "", PostProcessingPriorityNormal, PostProcessingPriorityLow, PostProcessingPriorityIdle:
+	default:
+		problems = append(problems, fmt.Sprintf("post_processing.priority %q is invalid (expected normal, low, or idle)", cfg.PostProcessing.Priority))
+	}
+
+	if cfg.Compat.URL != "" {
+		if parsed, err := url.Parse(cfg.Compat.URL); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			problems = append(problems, fmt.Sprintf("compat.url %q must be an https URL", cfg.Compat.URL))
+		}
+	}
+	compatTools := make([]string, 0, len(cfg.Compat.Tools))
+	for tool := range cfg.Compat.Tools {
+		compatTools = append(compatTools, tool)
+	}
+	sort.Strings(compatTools)
+	for _, tool := range compatTools {
+		if _, ok := compatToolNames[tool]; !ok {
+			problems = append(problems, fmt.Sprintf("compat.tools has unknown tool %q (expected spotdl, deemix, scdl, yt-dlp, tidal-dl, or gamdl)", tool))
+			continue
+		}
+		for version := range cfg.Compat.Tools[tool].KnownBad {
+			if version == "" {
+				problems = append(problems, fmt.Sprintf("compat.tools.%s.known_bad must not have an empty version", tool))
+			}
+		}
+	}
+
+	for _, class := range []struct {
+		name string
+		rule RetentionRule
+	}{
+		{"reports", cfg.Retention.Reports},
+		{"logs", cfg.Retention.Logs},
+		{"caches", cfg.Retention.Caches},
+		{"backups", cfg.Retention.Backups},
+	} {
+		if class.rule.MaxAgeDays < 0 {
+			problems = append(problems, fmt.Sprintf("retention.%s.max_age_days must be >= 0", class.name))
+		}
+		if class.rule.MaxSizeMB < 0 {
+			problems = append(problems, fmt.Sprintf("retention.%s.max_size_mb must be >= 0", class.name))
+		}
+	}
+
 	seenIDs := map[string]struct{}{}
 	for _, source := range cfg.Sources {
 		if strings.TrimSpace(source.ID) == "" {
@@ -62,15 +260,22 @@ func Validate(cfg Config) error {
 			}
 			seenIDs[source.ID] = struct{}{}
 		}
+		for _, tag := range source.Tags {
+			if !sourceIDPattern.MatchString(tag) {
+				problems = append(problems, fmt.Sprintf("source %q has invalid tag %q (letters, digits, '.', '_', '-')", source.ID, tag))
+			}
+		}
 
 		switch source.Type {
-		case SourceTypeSpotify, SourceTypeSoundCloud:
+		case SourceTypeSpotify, SourceTypeSoundCloud, SourceTypeYouTube, SourceTypeTidal, SourceTypeDeezer, SourceTypeAppleMusic:
 		default:
 			problems = append(problems, fmt.Sprintf("source %q has unsupported type %q", source.ID, source.Type))
 		}
 
 		if strings.TrimSpace(source.TargetDir) == "" {
 			problems = append(problems, fmt.Sprintf("source %q target_dir must be set", source.ID))
+		} else if IsRcloneRemote(source.TargetDir) {
+			// Remote targets are staged locally and moved there with rclone.
 		} else {
 			targetDir, targetErr := ExpandPath(source.TargetDir)
 			if targetErr != nil {
@@ -82,8 +287,16 @@ func Validate(cfg Config) error {
 
 		if strings.TrimSpace(source.URL) == "" {
 			problems = append(problems, fmt.Sprintf("source %q url must be set", source.ID))
+		} else if source.Type == SourceTypeSpotify && strings.HasPrefix(strings.ToLower(strings.TrimSpace(source.URL)), "spotify:") {
+			if !IsSpotifyArtistURL(source.URL) && !IsSpotifyUserLibraryURL(source.URL) {
+				problems = append(problems, fmt.Sprintf("source %q has invalid url: only spotify:artist:, spotify:liked, and spotify:new-releases URIs are accepted; use an open.spotify.com link", source.ID))
+			} else if IsSpotifyNewReleasesURL(source.URL) && source.Adapter.Kind != "deemix" {
+				problems = append(problems, fmt.Sprintf("source %q spotify:new-releases requires the deemix adapter (spotdl cannot list followed artists' releases)", source.ID))
+			}
 		} else if err := validateURL(source.URL); err != nil {
 			problems = append(problems, fmt.Sprintf("source %q has invalid url: %v", source.ID, err))
+		} else if IsShortLink(source.URL) {
+			problems = append(problems, fmt.Sprintf("source %q url %s is a share short link that could not be resolved; use the full link it opens", source.ID, source.URL))
 		}
 
 		if strings.TrimSpace(source.Adapter.Kind) == "" {
@@ -93,7 +306,7 @@ func Validate(cfg Config) error {
 			}
 		} else {
 			switch source.Adapter.Kind {
-			case "spotdl", "scdl", "scdl-freedl", "deemix":
+			case "spotdl", "scdl", "scdl-freedl", "deemix", "ytdlp", "tidal-dl", "gamdl":
 			default:
 				problems = append(problems, fmt.Sprintf("source %q has unsupported adapter.kind %q", source.ID, source.Adapter.Kind))
 			}
@@ -103,6 +316,38 @@ func Validate(cfg Config) error {
 			if source.Type == SourceTypeSoundCloud && source.Adapter.Kind != "scdl" && source.Adapter.Kind != "scdl-freedl" {
 				problems = append(problems, fmt.Sprintf("source %q soundcloud type requires scdl or scdl-freedl adapter", source.ID))
 			}
+			if source.Type == SourceTypeYouTube && source.Adapter.Kind != "ytdlp" {
+				problems = append(problems, fmt.Sprintf("source %q youtube type requires ytdlp adapter", source.ID))
+			}
+			if source.Adapter.Kind == "ytdlp" && source.Type != SourceTypeYouTube {
+				problems = append(problems, fmt.Sprintf("source %q ytdlp adapter requires youtube type", source.ID))
+			}
+			if source.Type == SourceTypeTidal && source.Adapter.Kind != "tidal-dl" {
+				problems = append(problems, fmt.Sprintf("source %q tidal type requires tidal-dl adapter", source.ID))
+			}
+			if source.Adapter.Kind == "tidal-dl" && source.Type != SourceTypeTidal {
+				problems = append(problems, fmt.Sprintf("source %q tidal-dl adapter requires tidal type", source.ID))
+			}
+			if source.Type == SourceTypeDeezer && source.Adapter.Kind != "deemix" {
+				problems = append(problems, fmt.Sprintf("source %q deezer type requires deemix adapter", source.ID))
+			}
+			if source.Adapter.Kind == "deemix" && source.Type != SourceTypeSpotify && source.Type != SourceTypeDeezer {
+				problems = append(problems, fmt.Sprintf("source %q deemix adapter requires spotify or deezer type", source.ID))
+			}
+			if source.Type == SourceTypeAppleMusic && source.Adapter.Kind != "gamdl" {
+				problems = append(problems, fmt.Sprintf("source %q apple_music type requires gamdl adapter", source.ID))
+			}
+			if source.Adapter.Kind == "gamdl" && source.Type != SourceTypeAppleMusic {
+				problems = append(problems, fmt.Sprintf("source %q gamdl adapter requires apple_music type", source.ID))
+			}
+		}
+
+		if strings.TrimSpace(source.CookiesFile) != "" {
+			if source.Type != SourceTypeSoundCloud && source.Type != SourceTypeYouTube {
+				problems = append(problems, fmt.Sprintf("source %q cookies_file is only supported for soundcloud and youtube sources", source.ID))
+			} else if cookiesPath, cookiesErr := ExpandPath(source.CookiesFile); cookiesErr != nil || !filepath.IsAbs(cookiesPath) {
+				problems = append(problems, fmt.Sprintf("source %q cookies_file must resolve to an absolute path", source.ID))
+			}
 		}
 
 		if source.Type == SourceTypeSpotify && strings.TrimSpace(source.StateFile) == "" {
@@ -111,9 +356,109 @@ func Validate(cfg Config) error {
 		if source.Type == SourceTypeSoundCloud && strings.TrimSpace(source.StateFile) == "" {
 			problems = append(problems, fmt.Sprintf("source %q state_file is required for soundcloud", source.ID))
 		}
+		if source.Type == SourceTypeDeezer && strings.TrimSpace(source.StateFile) == "" {
+			problems = append(problems, fmt.Sprintf("source %q state_file is required for deezer", source.ID))
+		}
+		if source.Type == SourceTypeAppleMusic && strings.TrimSpace(source.StateFile) == "" {
+			problems = append(problems, fmt.Sprintf("source %q state_file is required for apple_music", source.ID))
+		}
+		if freeDownloads := strings.TrimSpace(source.Sync.FreeDownloads); freeDownloads != "" {
+			switch {
+			case source.Type != SourceTypeSoundCloud:
+				problems = append(problems, fmt.Sprintf("source %q sync.free_downloads is only supported for soundcloud", source.ID))
+			case freeDownloads != FreeDownloadsInclude && freeDownloads != FreeDownloadsOnly && freeDownloads != FreeDownloadsSkip:
+				problems = append(problems, fmt.Sprintf("source %q has unsupported sync.free_downloads %q (expected include, only, or skip)", source.ID, freeDownloads))
+			case source.Adapter.Kind == "scdl-freedl" && freeDownloads != FreeDownloadsOnly:
+				problems = append(problems, fmt.Sprintf("source %q scdl-freedl adapter only supports sync.free_downloads=only; use adapter.kind=scdl for %s", source.ID, freeDownloads))
+			}
+		}
+		if len(source.Sync.IncludeGroups) > 0 {
+			if source.Type != SourceTypeSpotify || (!IsSpotifyArtistURL(source.URL) && !IsSpotifyNewReleasesURL(source.URL)) {
+				problems = append(problems, fmt.Sprintf("source %q sync.include_groups is only supported for spotify artist sources and spotify:new-releases", source.ID))
+			} else if source.Adapter.Kind != "deemix" {
+				problems = append(problems, fmt.Sprintf("source %q sync.include_groups requires the deemix adapter (spotdl downloads every release)", source.ID))
+			}
+			for _, group := range SpotifyArtistIncludeGroups(source) {
+				switch group {
+				case SpotifyAlbumGroupAlbum, SpotifyAlbumGroupSingle, SpotifyAlbumGroupAppearsOn, SpotifyAlbumGroupCompilation:
+				default:
+					problems = append(problems, fmt.Sprintf("source %q has unsupported sync.include_groups value %q (expected album, single, appears_on, or compilation)", source.ID, group))
+				}
+			}
+		}
+		if len(source.Quality) > 0 {
+			if source.Adapter.Kind != "deemix" {
+				problems = append(problems, fmt.Sprintf("source %q quality is only supported for the deemix adapter", source.ID))
+			}
+			seen := map[string]struct{}{}
+			for _, tier := range source.Quality {
+				switch tier {
+				case DeemixQualityFLAC, DeemixQuality320, DeemixQuality128:
+				default:
+					problems = append(problems, fmt.Sprintf("source %q has unsupported quality %q (expected flac, 320, or 128)", source.ID, tier))
+				}
+				if _, dup := seen[tier]; dup {
+					problems = append(problems, fmt.Sprintf("source %q lists quality %q more than once", source.ID, tier))
+				}
+				seen[tier] = struct{}{}
+			}
+			for _, arg := range source.Adapter.ExtraArgs {
+				if flag := strings.TrimSpace(arg); flag == "-b" || flag == "--bitrate" || strings.HasPrefix(flag, "--bitrate=") {
+					problems = append(problems, fmt.Sprintf("source %q sets quality and a --bitrate adapter arg; keep only quality", source.ID))
+					break
+				}
+			}
+		}
+		if len(source.Adapter.Fallback) > 0 {
+			if source.Adapter.Kind != "deemix" {
+				problems = append(problems, fmt.Sprintf("source %q adapter.fallback is only supported for the deemix adapter", source.ID))
+			}
+			seen := map[string]struct{}{}
+			for _, kind := range source.Adapter.Fallback {
+				switch kind {
+				case "spotdl", "ytdlp":
+				default:
+					problems = append(problems, fmt.Sprintf("source %q has unsupported adapter.fallback %q (expected spotdl or ytdlp)", source.ID, kind))
+				}
+				if _, dup := seen[kind]; dup {
+					problems = append(problems, fmt.Sprintf("source %q lists adapter.fallback %q more than once", source.ID, kind))
+				}
+				seen[kind] = struct{}{}
+			}
+		}
+		if source.Defaults.Threads < 0 {
+			problems = append(problems, fmt.Sprintf("source %q defaults.threads must be >= 0", source.ID))
+		}
+		if source.Defaults.CommandTimeoutSeconds < 0 {
+			problems = append(problems, fmt.Sprintf("source %q defaults.command_timeout_seconds must be >= 0", source.ID))
+		}
+		for _, pattern := range source.Defaults.ArtifactPatterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				problems = append(problems, fmt.Sprintf("source %q defaults.artifact_patterns has invalid pattern %q", source.ID, pattern))
+			}
+		}
+		if raw := strings.TrimSpace(source.Sync.Schedule); raw != "" {
+			if _, err := schedule.Parse(raw); err != nil {
+				problems = append(problems, fmt.Sprintf("source %q has invalid sync.schedule: %v", source.ID, err))
+			}
+		}
+		if raw := strings.TrimSpace(source.Sync.SuccessWhen); raw != "" {
+			if _, err := criteria.Parse(raw); err != nil {
+				problems = append(problems, fmt.Sprintf("source %q has invalid sync.success_when: %v", source.ID, err))
+			}
+		}
 		supportsSyncPolicy := source.Type == SourceTypeSoundCloud ||
+			source.Type == SourceTypeDeezer ||
+			source.Type == SourceTypeAppleMusic ||
 			(source.Type == SourceTypeSpotify && source.Adapter.Kind == "deemix")
-		if !supportsSyncPolicy {
+		if source.Type == SourceTypeYouTube {
+			if source.Sync.AskOnExisting != nil {
+				problems = append(problems, fmt.Sprintf("source %q sync.ask_on_existing is not supported for youtube", source.ID))
+			}
+			if source.Sync.LocalIndexCache != nil {
+				problems = append(problems, fmt.Sprintf("source %q sync.local_index_cache is only supported for soundcloud", source.ID))
+			}
+		} else if !supportsSyncPolicy {
 			if source.Sync.BreakOnExisting != nil {
 				problems = append(problems, fmt.Sprintf("source %q sync.break_on_existing is only supported for soundcloud or spotify+deemix", source.ID))
 			}
@@ -124,6 +469,131 @@ func Validate(cfg Config) error {
 				problems = append(problems, fmt.Sprintf("source %q sync.local_index_cache is only supported for soundcloud", source.ID))
 			}
 		}
+		if source.Sync.MaxRemoteShrinkPercent < 0 || source.Sync.MaxRemoteShrinkPercent > 100 {
+			problems = append(problems, fmt.Sprintf("source %q sync.max_remote_shrink_percent must be between 0 and 100", source.ID))
+		} else if source.Sync.MaxRemoteShrinkPercent > 0 && !supportsSyncPolicy {
+			problems = append(problems, fmt.Sprintf("source %q sync.max_remote_shrink_percent is only supported for soundcloud, deezer, apple_music, or spotify+deemix", source.ID))
+		}
+		if source.Sync.NewReleasesDays < 0 {
+			problems = append(problems, fmt.Sprintf("source %q sync.new_releases_days must not be negative", source.ID))
+		} else if source.Sync.NewReleasesDays > 0 && (source.Type != SourceTypeSpotify || !IsSpotifyNewReleasesURL(source.URL)) {
+			problems = append(problems, fmt.Sprintf("source %q sync.new_releases_days is only supported for spotify:new-releases sources", source.ID))
+		}
+		if source.Sync.MaxDepth < 0 {
+			problems = append(problems, fmt.Sprintf("source %q sync.max_depth must not be negative", source.ID))
+		} else if source.Sync.MaxDepth > 0 && source.Type != SourceTypeSoundCloud {
+			problems = append(problems, fmt.Sprintf("source %q sync.max_depth is only supported for soundcloud sources", source.ID))
+		}
+		if source.Type == SourceTypeSoundCloud {
+			// Stations reshuffle and max_depth cuts the listing short, so a
+			// track missing from either is not gone from the remote.
+			if source.Sync.Prune != nil && *source.Sync.Prune && (IsSoundCloudStationURL(source.URL) || source.Sync.MaxDepth > 0) {
+				problems = append(problems, fmt.Sprintf("source %q sync.prune cannot be used with a station url or sync.max_depth", source.ID))
+			}
+			_, reposts := SoundCloudRepostsProfile(source.URL)
+			if reposts || IsSoundCloudStationURL(source.URL) {
+				for _, arg := range source.Adapter.ExtraArgs {
+					switch strings.TrimSpace(arg) {
+					case "-a", "-t", "-f", "-C", "-p", "-r":
+						problems = append(problems, fmt.Sprintf("source %q url is a reposts feed or station; drop the scdl mode flag %s from adapter.extra_args", source.ID, strings.TrimSpace(arg)))
+					}
+				}
+			}
+		}
+		if source.Sync.SkipTTLDays < 0 {
+			problems = append(problems, fmt.Sprintf("source %q sync.skip_ttl_days must not be negative", source.ID))
+		} else if source.Sync.SkipTTLDays > 0 && source.Type != SourceTypeSoundCloud && source.Type != SourceTypeDeezer && (source.Type != SourceTypeSpotify || source.Adapter.Kind != "deemix") {
+			problems = append(problems, fmt.Sprintf("source %q sync.skip_ttl_days is only supported for soundcloud, deezer, or spotify+deemix", source.ID))
+		}
+		if source.Sync.Concurrency < 0 || source.Sync.Concurrency > MaxSyncConcurrency {
+			problems = append(problems, fmt.Sprintf("source %q sync.concurrency must be between 0 and %d", source.ID, MaxSyncConcurrency))
+		} else if source.Sync.Concurrency > 1 && (source.Type != SourceTypeSpotify || source.Adapter.Kind != "deemix") {
+			problems = append(problems, fmt.Sprintf("source %q sync.concurrency is only supported for spotify+deemix", source.ID))
+		}
+		switch stall := source.Sync; {
+		case stall.StallMinKbps < 0 || stall.StallSeconds < 0:
+			problems = append(problems, fmt.Sprintf("source %q sync.stall_min_kbps and sync.stall_seconds must be >= 0", source.ID))
+		case (stall.StallMinKbps > 0) != (stall.StallSeconds > 0):
+			problems = append(problems, fmt.Sprintf("source %q sync.stall_min_kbps and sync.stall_seconds must be set together", source.ID))
+		case stall.StallSeconds > 0 && (source.Adapter.Kind != "deemix" || (source.Type != SourceTypeDeezer && source.Type != SourceTypeSpotify)):
+			problems = append(problems, fmt.Sprintf("source %q sync.stall_min_kbps is only supported for deezer or spotify+deemix", source.ID))
+		}
+		if source.Monitor && source.Type != SourceTypeSoundCloud {
+			problems = append(problems, fmt.Sprintf("source %q monitor is only supported for soundcloud", source.ID))
+		}
+		if source.Sync.Compilations != nil && (source.Type != SourceTypeSpotify || source.Adapter.Kind != "deemix") {
+			problems = append(problems, fmt.Sprintf("source %q sync.compilations is only supported for spotify+deemix", source.ID))
+		}
+		if source.Sync.DedupeAcrossSources != nil && !supportsSyncPolicy {
+			problems = append(problems, fmt.Sprintf("source %q sync.dedupe_across_sources is only supported for soundcloud, deezer, apple_music, or spotify+deemix", source.ID))
+		}
+		if source.Sync.Prune != nil && !supportsSyncPolicy {
+			problems = append(problems, fmt.Sprintf("source %q sync.prune is only supported for soundcloud, deezer, apple_music, or spotify+deemix", source.ID))
+		}
+		sourceInfo := source.Sync.SourceInfo != nil && *source.Sync.SourceInfo
+		sourceCover := source.Sync.SourceCover != nil && *source.Sync.SourceCover
+		switch {
+		case (sourceInfo || sourceCover) && source.Type != SourceTypeSoundCloud && source.Type != SourceTypeSpotify && source.Type != SourceTypeDeezer && source.Type != SourceTypeAppleMusic:
+			problems = append(problems, fmt.Sprintf("source %q sync.source_info is only supported for soundcloud, spotify, deezer, or apple_music", source.ID))
+		case sourceCover && !sourceInfo:
+			problems = append(problems, fmt.Sprintf("source %q sync.source_cover requires sync.source_info: true", source.ID))
+		}
+		switch source.Sync.ReplayGain {
+		case "", ReplayGainRsgain, ReplayGainFFmpeg:
+		default:
+			problems = append(problems, fmt.Sprintf("source %q has unsupported sync.replaygain %q (expected rsgain or ffmpeg)", source.ID, source.Sync.ReplayGain))
+		}
+		switch source.Sync.MusicBrainz {
+		case "", MusicBrainzSearch, MusicBrainzFingerprint:
+		default:
+			problems = append(problems, fmt.Sprintf("source %q has unsupported sync.musicbrainz %q (expected search or fingerprint)", source.ID, source.Sync.MusicBrainz))
+		}
+		switch source.Sync.Lyrics {
+		case "", LyricsTags, LyricsLRC:
+		default:
+			problems = append(problems, fmt.Sprintf("source %q has unsupported sync.lyrics %q (expected tags or lrc)", source.ID, source.Sync.Lyrics))
+		}
+		if len(source.Sync.GenreRouting) > 0 {
+			switch {
+			case source.Type != SourceTypeSoundCloud && source.Type != SourceTypeYouTube && source.Type != SourceTypeDeezer && (source.Type != SourceTypeSpotify || source.Adapter.Kind != "deemix"):
+				problems = append(problems, fmt.Sprintf("source %q sync.genre_routing is only supported for soundcloud, youtube, deezer, or spotify+deemix", source.ID))
+			default:
+				for i, route := range source.Sync.GenreRouting {
+					if len(route.Genres) == 0 {
+						problems = append(problems, fmt.Sprintf("source %q sync.genre_routing[%d] needs at least one genre", source.ID, i))
+					}
+					for _, pattern := range route.Genres {
+						if _, err := path.Match(pattern, ""); err != nil {
+							problems = append(problems, fmt.Sprintf("source %q sync.genre_routing[%d] has invalid genre pattern %q", source.ID, i, pattern))
+						}
+					}
+					if route.Folder == "" || filepath.IsAbs(route.Folder) || !filepath.IsLocal(route.Folder) {
+						problems = append(problems, fmt.Sprintf("source %q sync.genre_routing[%d].folder must be a path inside target_dir", source.ID, i))
+					}
+				}
+			}
+		}
+		if blocklist := source.Sync.Blocklist; !blocklist.Empty() {
+			if !supportsSyncPolicy {
+				problems = append(problems, fmt.Sprintf("source %q sync.blocklist is only supported for soundcloud, deezer, apple_music, or spotify+deemix", source.ID))
+			}
+			for _, expr := range blocklist.Titles {
+				if _, err := regexp.Compile(expr); err != nil {
+					problems = append(problems, fmt.Sprintf("source %q sync.blocklist.titles has invalid regex %q: %v", source.ID, expr, err))
+				}
+			}
+		}
+		if playlistFile := source.Sync.PlaylistFile; playlistFile != "" {
+			ext := strings.ToLower(filepath.Ext(playlistFile))
+			switch {
+			case !supportsSyncPolicy:
+				problems = append(problems, fmt.Sprintf("source %q sync.playlist_file is only supported for soundcloud, deezer, apple_music, or spotify+deemix", source.ID))
+			case ext != ".m3u8" && ext != ".m3u":
+				problems = append(problems, fmt.Sprintf("source %q sync.playlist_file must end in .m3u8 or .m3u", source.ID))
+			case filepath.IsAbs(playlistFile) || !filepath.IsLocal(playlistFile):
+				problems = append(problems, fmt.Sprintf("source %q sync.playlist_file must be a path inside target_dir", source.ID))
+			}
+		}
 	}
 
 	if len(problems) > 0 {
@@ -132,6 +602,18 @@ func Validate(cfg Config) error {
 	return nil
 }
 
+func usesVersion2Features(cfg Config) bool {
+	if len(cfg.Profiles) > 0 || strings.TrimSpace(cfg.Profile) != "" {
+		return true
+	}
+	for _, source := range cfg.Sources {
+		if !source.Defaults.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
 func validateURL(raw string) error {
 	parsed, err := url.ParseRequestURI(raw)
 	if err != nil {
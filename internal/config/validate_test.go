@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestValidateSuccess(t *testing.T) {
 	cfg := Config{
@@ -108,3 +111,956 @@ func TestValidateFailure(t *testing.T) {
 func testBoolPtr(v bool) *bool {
 	return &v
 }
+
+func testValidConfig() Config {
+	return Config{
+		Version: 1,
+		Defaults: Defaults{
+			StateDir:              "/tmp/udl-state",
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []Source{
+			{
+				ID:        "soundcloud-likes",
+				Type:      SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: "/tmp/music-sc",
+				URL:       "https://soundcloud.com/user",
+				StateFile: "soundcloud-likes.sync.scdl",
+				Adapter:   AdapterSpec{Kind: "scdl"},
+			},
+		},
+	}
+}
+
+func TestValidateMetadataProviders(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.MetadataProviders = []MetadataProvider{
+		{Name: "beatport", Enabled: true, Command: []string{"/usr/local/bin/udl-beatport"}, Priority: 10},
+		{Name: "discogs", Enabled: true, Command: []string{"udl-discogs", "--json"}},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid metadata providers, got %v", err)
+	}
+
+	cfg.MetadataProviders = []MetadataProvider{
+		{Name: "beatport", Enabled: true, Command: []string{"udl-beatport"}},
+		{Name: "beatport", Enabled: true},
+		{Name: "bad name", Enabled: true, Command: []string{"x"}, TimeoutSeconds: -1},
+	}
+	err := Validate(cfg)
+	validationErr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %T (%v)", err, err)
+	}
+	expected := []string{
+		`duplicate metadata provider name "beatport"`,
+		`metadata provider "beatport" command must be set`,
+		`metadata provider "bad name" has invalid name format`,
+		`metadata provider "bad name" timeout_seconds must be >= 0`,
+	}
+	for _, want := range expected {
+		found := false
+		for _, problem := range validationErr.Problems {
+			if problem == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("expected problem %q, got %v", want, validationErr.Problems)
+		}
+	}
+}
+
+func TestValidateYouTubeSource(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources = append(cfg.Sources, Source{
+		ID:        "yt-mix",
+		Type:      SourceTypeYouTube,
+		Enabled:   true,
+		TargetDir: "/tmp/music-yt",
+		URL:       "https://music.youtube.com/playlist?list=PL123",
+		Adapter:   AdapterSpec{Kind: "ytdlp"},
+		Sync:      SyncPolicy{BreakOnExisting: testBoolPtr(true)},
+	})
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid youtube source, got %v", err)
+	}
+
+	cfg.Sources[1].Adapter.Kind = "scdl"
+	cfg.Sources[1].Sync.LocalIndexCache = testBoolPtr(true)
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	if !strings.Contains(err.Error(), `source "yt-mix" youtube type requires ytdlp adapter`) {
+		t.Fatalf("expected youtube adapter problem, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `source "yt-mix" sync.local_index_cache is only supported for soundcloud`) {
+		t.Fatalf("expected local_index_cache problem, got %v", err)
+	}
+}
+
+func TestValidateTidalSource(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources = append(cfg.Sources, Source{
+		ID:        "tidal-favs",
+		Type:      SourceTypeTidal,
+		Enabled:   true,
+		TargetDir: "/tmp/music-tidal",
+		URL:       "https://tidal.com/browse/playlist/1234-abcd",
+		Adapter:   AdapterSpec{Kind: "tidal-dl"},
+	})
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid tidal source, got %v", err)
+	}
+
+	cfg.Sources[1].Adapter.Kind = "ytdlp"
+	cfg.Sources[1].Sync.BreakOnExisting = testBoolPtr(true)
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{
+		`source "tidal-favs" tidal type requires tidal-dl adapter`,
+		`source "tidal-favs" ytdlp adapter requires youtube type`,
+		`source "tidal-favs" sync.break_on_existing is only supported for soundcloud or spotify+deemix`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateDeezerSource(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources = append(cfg.Sources, Source{
+		ID:        "deezer-mix",
+		Type:      SourceTypeDeezer,
+		Enabled:   true,
+		TargetDir: "/tmp/music-deezer",
+		URL:       "https://www.deezer.com/en/playlist/908622995",
+		StateFile: "deezer-mix.sync.deezer",
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	})
+	cfg.Sources[1].Sync.BreakOnExisting = testBoolPtr(true)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid deezer source, got %v", err)
+	}
+
+	cfg.Sources[1].Adapter.Kind = "spotdl"
+	cfg.Sources[1].StateFile = ""
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{
+		`source "deezer-mix" deezer type requires deemix adapter`,
+		`source "deezer-mix" state_file is required for deezer`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateAppleMusicSource(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources = append(cfg.Sources, Source{
+		ID:        "am-chill",
+		Type:      SourceTypeAppleMusic,
+		Enabled:   true,
+		TargetDir: "/tmp/music-apple",
+		URL:       "https://music.apple.com/us/playlist/chill/pl.u-abc123",
+		StateFile: "am-chill.sync.applemusic",
+		Adapter:   AdapterSpec{Kind: "gamdl"},
+	})
+	cfg.Sources[1].Sync.BreakOnExisting = testBoolPtr(true)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid apple_music source, got %v", err)
+	}
+
+	cfg.Sources[1].Adapter.Kind = "deemix"
+	cfg.Sources[1].StateFile = ""
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{
+		`source "am-chill" apple_music type requires gamdl adapter`,
+		`source "am-chill" deemix adapter requires spotify or deezer type`,
+		`source "am-chill" state_file is required for apple_music`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateFreeDownloadsPolicy(t *testing.T) {
+	cfg := testValidConfig()
+	for _, policy := range []string{FreeDownloadsInclude, FreeDownloadsOnly, FreeDownloadsSkip} {
+		cfg.Sources[0].Sync.FreeDownloads = policy
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("expected free_downloads=%s to be valid for scdl, got %v", policy, err)
+		}
+	}
+
+	cfg.Sources[0].Sync.FreeDownloads = "sometimes"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), `unsupported sync.free_downloads "sometimes"`) {
+		t.Fatalf("expected unsupported value problem, got %v", err)
+	}
+
+	cfg.Sources[0].Adapter.Kind = "scdl-freedl"
+	cfg.Sources[0].Sync.FreeDownloads = FreeDownloadsSkip
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "scdl-freedl adapter only supports sync.free_downloads=only") {
+		t.Fatalf("expected scdl-freedl conflict problem, got %v", err)
+	}
+	if got := FreeDownloadsPolicy(Source{Adapter: AdapterSpec{Kind: "scdl-freedl"}}); got != FreeDownloadsOnly {
+		t.Fatalf("expected scdl-freedl default policy only, got %q", got)
+	}
+	if got := FreeDownloadsPolicy(Source{Adapter: AdapterSpec{Kind: "scdl"}}); got != FreeDownloadsInclude {
+		t.Fatalf("expected scdl default policy include, got %q", got)
+	}
+}
+
+func TestValidateSpotifyArtistIncludeGroups(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources = []Source{{
+		ID:        "artist",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/artist",
+		URL:       "spotify:artist:4tZwfgrHOc3mvqYlEYSvVi",
+		StateFile: "artist.sync.spotify",
+		Sync:      SyncPolicy{IncludeGroups: []string{"Album", "single", "appears_on"}},
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected spotify artist source to be valid, got %v", err)
+	}
+	if got := SpotifyArtistIncludeGroups(cfg.Sources[0]); strings.Join(got, ",") != "album,single,appears_on" {
+		t.Fatalf("unexpected include groups %v", got)
+	}
+	if got := SpotifyArtistIncludeGroups(Source{}); strings.Join(got, ",") != "album,single" {
+		t.Fatalf("expected album,single default, got %v", got)
+	}
+
+	cfg.Sources[0].Sync.IncludeGroups = []string{"remix"}
+	cfg.Sources[0].Adapter.Kind = "spotdl"
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{
+		`unsupported sync.include_groups value "remix"`,
+		"sync.include_groups requires the deemix adapter",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+
+	cfg.Sources[0].URL = "spotify:playlist:37i9dQZF1DXcBWIGoYBM5M"
+	cfg.Sources[0].Sync.IncludeGroups = nil
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "only spotify:artist:, spotify:liked, and spotify:new-releases URIs are accepted") {
+		t.Fatalf("expected spotify URI problem, got %v", err)
+	}
+	cfg.Sources[0].URL = "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"
+	cfg.Sources[0].Sync.IncludeGroups = []string{"album"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "only supported for spotify artist sources") {
+		t.Fatalf("expected artist-only problem, got %v", err)
+	}
+}
+
+func TestValidateSpotifyUserLibrarySources(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources = []Source{
+		{
+			ID:        "liked",
+			Type:      SourceTypeSpotify,
+			Enabled:   true,
+			TargetDir: "/tmp/liked",
+			URL:       SpotifyLikedURL,
+			StateFile: "liked.sync.spotify",
+			Adapter:   AdapterSpec{Kind: "spotdl"},
+		},
+		{
+			ID:        "new",
+			Type:      SourceTypeSpotify,
+			Enabled:   true,
+			TargetDir: "/tmp/new",
+			URL:       "Spotify:New-Releases",
+			StateFile: "new.sync.spotify",
+			Sync:      SyncPolicy{NewReleasesDays: 14, IncludeGroups: []string{"single"}},
+			Adapter:   AdapterSpec{Kind: "deemix"},
+		},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected spotify library sources to be valid, got %v", err)
+	}
+	if got := SpotifyNewReleasesDays(cfg.Sources[1]); got != 14 {
+		t.Fatalf("expected 14 days, got %d", got)
+	}
+	if got := SpotifyNewReleasesDays(Source{}); got != DefaultSpotifyNewReleasesDays {
+		t.Fatalf("expected the default window, got %d", got)
+	}
+
+	cfg.Sources[0].Sync.NewReleasesDays = 7
+	cfg.Sources[1].Adapter.Kind = "spotdl"
+	cfg.Sources[1].Sync.IncludeGroups = nil
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{
+		`source "liked" sync.new_releases_days is only supported for spotify:new-releases sources`,
+		`source "new" spotify:new-releases requires the deemix adapter`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateSoundCloudMaxDepthAndStationModeFlags(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources = []Source{
+		{
+			ID:        "station",
+			Type:      SourceTypeSoundCloud,
+			Enabled:   true,
+			TargetDir: "/tmp/station",
+			URL:       "https://soundcloud.com/stations/track/user/seed",
+			StateFile: "station.sync.scdl",
+			Sync:      SyncPolicy{MaxDepth: 100},
+			Adapter:   AdapterSpec{Kind: "scdl"},
+		},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected station source to be valid, got %v", err)
+	}
+
+	prune := true
+	cfg.Sources[0].Sync.Prune = &prune
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "sync.prune cannot be used with a station url or sync.max_depth") {
+		t.Fatalf("expected prune on a station to be rejected, got %v", err)
+	}
+	cfg.Sources[0].URL = "https://soundcloud.com/user/likes"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "sync.prune cannot be used") {
+		t.Fatalf("expected prune with max_depth to be rejected, got %v", err)
+	}
+	cfg.Sources[0].Sync.Prune = nil
+
+	cfg.Sources[0].URL = "https://soundcloud.com/user/reposts"
+	cfg.Sources[0].Sync.MaxDepth = -1
+	cfg.Sources[0].Adapter.ExtraArgs = []string{"-f"}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{
+		"sync.max_depth must not be negative",
+		"drop the scdl mode flag -f from adapter.extra_args",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateNotifications(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Defaults.Notifications = []Notification{
+		{Name: "discord", URLEnv: "UDL_DISCORD_WEBHOOK", Format: NotificationFormatDiscord, Events: []string{NotificationEventFailed}},
+		{URL: "https://hooks.example.com/udl"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid notifications, got %v", err)
+	}
+
+	cfg.Defaults.Notifications = []Notification{
+		{Name: "empty"},
+		{Name: "bad", URL: "ftp://example.com", Format: "teams", Events: []string{"started"}},
+	}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatalf("expected validation error")
+	}
+	for _, want := range []string{
+		"notification empty must set url or url_env",
+		"notification bad has invalid url",
+		"notification bad format must be one of generic, discord, slack",
+		`notification bad has unknown event "started"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateSyncSchedule(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.Schedule = "0 3 * * *"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid schedule, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.Schedule = "every day"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "invalid sync.schedule") {
+		t.Fatalf("expected invalid sync.schedule problem, got %v", err)
+	}
+}
+
+func TestValidateSyncSuccessWhen(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.SuccessWhen = "failed_tracks == 0 && unavailable <= 2"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid success_when, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.SuccessWhen = "errors == 0"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "invalid sync.success_when") {
+		t.Fatalf("expected invalid sync.success_when problem, got %v", err)
+	}
+}
+
+func TestValidateSyncDedupeAcrossSources(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.DedupeAcrossSources = testBoolPtr(true)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected dedupe_across_sources valid for soundcloud, got %v", err)
+	}
+
+	cfg.Sources[0].Type = SourceTypeYouTube
+	cfg.Sources[0].Adapter.Kind = "ytdlp"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.dedupe_across_sources is only supported") {
+		t.Fatalf("expected unsupported dedupe_across_sources problem, got %v", err)
+	}
+}
+
+func TestValidateSyncPrune(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.Prune = testBoolPtr(true)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected prune valid for soundcloud, got %v", err)
+	}
+
+	cfg.Sources[0].Type = SourceTypeYouTube
+	cfg.Sources[0].Adapter.Kind = "ytdlp"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.prune is only supported") {
+		t.Fatalf("expected unsupported prune problem, got %v", err)
+	}
+}
+
+func TestValidateSyncSourceInfo(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.SourceInfo = testBoolPtr(true)
+	cfg.Sources[0].Sync.SourceCover = testBoolPtr(true)
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected source_info with cover valid for soundcloud, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.SourceInfo = nil
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.source_cover requires sync.source_info") {
+		t.Fatalf("expected source_cover dependency problem, got %v", err)
+	}
+}
+
+func TestValidateSyncReplayGain(t *testing.T) {
+	cfg := testValidConfig()
+	for _, tool := range []string{ReplayGainRsgain, ReplayGainFFmpeg} {
+		cfg.Sources[0].Sync.ReplayGain = tool
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("expected sync.replaygain %q valid, got %v", tool, err)
+		}
+	}
+
+	cfg.Sources[0].Sync.ReplayGain = "loudgain"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `unsupported sync.replaygain "loudgain"`) {
+		t.Fatalf("expected replaygain tool problem, got %v", err)
+	}
+}
+
+func TestValidateSyncMusicBrainz(t *testing.T) {
+	cfg := testValidConfig()
+	for _, mode := range []string{MusicBrainzSearch, MusicBrainzFingerprint} {
+		cfg.Sources[0].Sync.MusicBrainz = mode
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("expected sync.musicbrainz %q valid, got %v", mode, err)
+		}
+	}
+
+	cfg.Sources[0].Sync.MusicBrainz = "picard"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `unsupported sync.musicbrainz "picard"`) {
+		t.Fatalf("expected musicbrainz mode problem, got %v", err)
+	}
+}
+
+func TestValidateSyncLyrics(t *testing.T) {
+	cfg := testValidConfig()
+	for _, mode := range []string{LyricsTags, LyricsLRC} {
+		cfg.Sources[0].Sync.Lyrics = mode
+		if err := Validate(cfg); err != nil {
+			t.Fatalf("expected sync.lyrics %q valid, got %v", mode, err)
+		}
+	}
+
+	cfg.Sources[0].Sync.Lyrics = "musixmatch"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `unsupported sync.lyrics "musixmatch"`) {
+		t.Fatalf("expected lyrics mode problem, got %v", err)
+	}
+}
+
+func TestValidateSyncMaxRemoteShrinkPercent(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.MaxRemoteShrinkPercent = 30
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid max_remote_shrink_percent, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.MaxRemoteShrinkPercent = 120
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.max_remote_shrink_percent must be between 0 and 100") {
+		t.Fatalf("expected range problem, got %v", err)
+	}
+}
+
+func TestValidateSyncConcurrency(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "spotify-deemix",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sp",
+		URL:       "https://open.spotify.com/playlist/a",
+		StateFile: "spotify-deemix.sync.spotify",
+		Sync:      SyncPolicy{Concurrency: 4},
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid concurrency, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.Concurrency = 9
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.concurrency must be between 0 and 8") {
+		t.Fatalf("expected range problem, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.Concurrency = 2
+	cfg.Sources[0].Adapter.Kind = "spotdl"
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.concurrency is only supported for spotify+deemix") {
+		t.Fatalf("expected unsupported concurrency problem, got %v", err)
+	}
+}
+
+func TestValidateSyncStallWatchdog(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "deezer-mix",
+		Type:      SourceTypeDeezer,
+		Enabled:   true,
+		TargetDir: "/tmp/music-dz",
+		URL:       "https://www.deezer.com/en/playlist/908622995",
+		StateFile: "deezer-mix.sync.deezer",
+		Sync:      SyncPolicy{StallMinKbps: 64, StallSeconds: 180},
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid stall watchdog, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.StallSeconds = 0
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.stall_min_kbps and sync.stall_seconds must be set together") {
+		t.Fatalf("expected pairing problem, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.StallSeconds = -1
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "must be >= 0") {
+		t.Fatalf("expected range problem, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.StallSeconds = 180
+	cfg.Sources[0].Adapter.Kind = "spotdl"
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.stall_min_kbps is only supported for deezer or spotify+deemix") {
+		t.Fatalf("expected unsupported stall watchdog problem, got %v", err)
+	}
+}
+
+func TestValidateSyncCompilations(t *testing.T) {
+	cfg := testValidConfig()
+	enabled := true
+	cfg.Sources[0] = Source{
+		ID:        "spotify-artist",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sp",
+		URL:       "https://open.spotify.com/artist/1vCWHaC5f2uS3yhpwWbIA6",
+		StateFile: "spotify-artist.sync.spotify",
+		Sync:      SyncPolicy{Compilations: &enabled},
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid compilations setting, got %v", err)
+	}
+
+	cfg.Sources[0].Adapter.Kind = "spotdl"
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.compilations is only supported for spotify+deemix") {
+		t.Fatalf("expected unsupported compilations problem, got %v", err)
+	}
+}
+
+func TestValidateSyncGenreRouting(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.GenreRouting = []GenreRoute{
+		{Genres: []string{"drum & bass", "dnb", "jungle*"}, Folder: "Drum & Bass"},
+		{Genres: []string{"techno"}, Folder: "Electronic/Techno"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid genre routing, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.GenreRouting = []GenreRoute{
+		{Genres: nil, Folder: "House"},
+		{Genres: []string{"[techno"}, Folder: "../outside"},
+	}
+	err := Validate(cfg)
+	for _, want := range []string{
+		"sync.genre_routing[0] needs at least one genre",
+		`sync.genre_routing[1] has invalid genre pattern "[techno"`,
+		"sync.genre_routing[1].folder must be a path inside target_dir",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+
+	cfg = testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "spotify-playlist",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sp",
+		URL:       "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M",
+		Sync:      SyncPolicy{GenreRouting: []GenreRoute{{Genres: []string{"house"}, Folder: "House"}}},
+		Adapter:   AdapterSpec{Kind: "spotdl"},
+	}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.genre_routing is only supported for soundcloud, youtube, deezer, or spotify+deemix") {
+		t.Fatalf("expected unsupported genre routing problem, got %v", err)
+	}
+}
+
+func TestValidateSyncBlocklist(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.Blocklist = Blocklist{
+		IDs:    []string{"123456"},
+		URLs:   []string{"https://soundcloud.com/label/*-intro"},
+		Titles: []string{`(?i)\bskit\b`},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid blocklist, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.Blocklist = Blocklist{Titles: []string{"(intro"}}
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `sync.blocklist.titles has invalid regex "(intro"`) {
+		t.Fatalf("expected invalid title regex problem, got %v", err)
+	}
+
+	cfg = testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "spotify-playlist",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sp",
+		URL:       "https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M",
+		Sync:      SyncPolicy{Blocklist: Blocklist{IDs: []string{"4uLU6hMCjMI75M1A2tKUQC"}}},
+		Adapter:   AdapterSpec{Kind: "spotdl"},
+	}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.blocklist is only supported for soundcloud, deezer, apple_music, or spotify+deemix") {
+		t.Fatalf("expected unsupported blocklist problem, got %v", err)
+	}
+}
+
+func TestValidateSyncSkipTTLDays(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Sync.SkipTTLDays = 30
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid skip_ttl_days, got %v", err)
+	}
+
+	cfg.Sources[0].Sync.SkipTTLDays = -1
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.skip_ttl_days must not be negative") {
+		t.Fatalf("expected negative skip_ttl_days problem, got %v", err)
+	}
+
+	cfg = testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "am-playlist",
+		Type:      SourceTypeAppleMusic,
+		Enabled:   true,
+		TargetDir: "/tmp/music-am",
+		URL:       "https://music.apple.com/us/playlist/example/pl.u-123",
+		StateFile: "am-playlist.sync.applemusic",
+		Sync:      SyncPolicy{SkipTTLDays: 7},
+		Adapter:   AdapterSpec{Kind: "gamdl"},
+	}
+	err = Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "sync.skip_ttl_days is only supported for soundcloud, deezer, or spotify+deemix") {
+		t.Fatalf("expected unsupported skip_ttl_days problem, got %v", err)
+	}
+}
+
+func TestValidateSourceMonitor(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "sc-artist",
+		Type:      SourceTypeSoundCloud,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sc",
+		URL:       "https://soundcloud.com/artist/tracks",
+		StateFile: "sc-artist.sync.scdl",
+		Monitor:   true,
+		Adapter:   AdapterSpec{Kind: "scdl"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid monitor source, got %v", err)
+	}
+
+	cfg.Sources[0] = Source{
+		ID:        "spotify-artist",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sp",
+		URL:       "https://open.spotify.com/artist/1vCWHaC5f2uS3yhpwWbIA6",
+		StateFile: "spotify-artist.sync.spotify",
+		Monitor:   true,
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "monitor is only supported for soundcloud") {
+		t.Fatalf("expected unsupported monitor problem, got %v", err)
+	}
+}
+
+func TestValidateSourceTags(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].Tags = []string{"weekly", "dj-sets"}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid tags, got %v", err)
+	}
+
+	cfg.Sources[0].Tags = []string{"late night"}
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), `invalid tag "late night"`) {
+		t.Fatalf("expected invalid tag problem, got %v", err)
+	}
+}
+
+func TestValidateSourceCookiesFile(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].CookiesFile = "~/.config/udl/soundcloud-cookies.txt"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid cookies_file, got %v", err)
+	}
+
+	cfg.Sources[0].CookiesFile = "cookies.txt"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "cookies_file must resolve to an absolute path") {
+		t.Fatalf("expected relative cookies_file problem, got %v", err)
+	}
+
+	cfg.Sources[0] = Source{
+		ID:        "spotify-deemix",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sp",
+		URL:       "https://open.spotify.com/playlist/a",
+		StateFile: "spotify-deemix.sync.spotify",
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}
+	cfg.Sources[0].CookiesFile = "/tmp/cookies.txt"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "cookies_file is only supported for soundcloud and youtube sources") {
+		t.Fatalf("expected unsupported cookies_file problem, got %v", err)
+	}
+}
+
+func TestValidateSyncPlaylistFile(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "spotify-deemix",
+		Type:      SourceTypeSpotify,
+		Enabled:   true,
+		TargetDir: "/tmp/music-sp",
+		URL:       "https://open.spotify.com/playlist/a",
+		StateFile: "spotify-deemix.sync.spotify",
+		Sync:      SyncPolicy{PlaylistFile: "Playlists/Mix.m3u8"},
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid playlist file, got %v", err)
+	}
+
+	for value, want := range map[string]string{
+		"mix.txt":          "sync.playlist_file must end in .m3u8 or .m3u",
+		"../outside.m3u8":  "sync.playlist_file must be a path inside target_dir",
+		"/tmp/abs.m3u":     "sync.playlist_file must be a path inside target_dir",
+		"sub/../../x.m3u8": "sync.playlist_file must be a path inside target_dir",
+	} {
+		cfg.Sources[0].Sync.PlaylistFile = value
+		if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("playlist_file %q: expected %q, got %v", value, want, err)
+		}
+	}
+
+	cfg.Sources[0].Sync.PlaylistFile = "mix.m3u8"
+	cfg.Sources[0].Adapter.Kind = "spotdl"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "sync.playlist_file is only supported for") {
+		t.Fatalf("expected unsupported playlist_file problem, got %v", err)
+	}
+}
+
+func TestValidateAcceptsRcloneRemoteTargetDir(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0].TargetDir = "gdrive:Music/Playlists"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected rclone remote target_dir to be valid, got %v", err)
+	}
+	cfg.Sources[0].TargetDir = "Music/Playlists"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "target_dir must resolve to an absolute path") {
+		t.Fatalf("expected relative target_dir to stay invalid, got %v", err)
+	}
+}
+
+func TestValidateSourceQuality(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "deezer-playlist",
+		Type:      SourceTypeDeezer,
+		Enabled:   true,
+		TargetDir: "/tmp/music-dz",
+		URL:       "https://www.deezer.com/playlist/123",
+		StateFile: "deezer-playlist.sync.deezer",
+		Quality:   []string{"flac", "320", "128"},
+		Adapter:   AdapterSpec{Kind: "deemix"},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid quality list, got %v", err)
+	}
+
+	cfg.Sources[0].Quality = []string{"flac", "256", "flac"}
+	cfg.Sources[0].Adapter.ExtraArgs = []string{"--bitrate", "320"}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected quality problems")
+	}
+	for _, want := range []string{`unsupported quality "256"`, `lists quality "flac" more than once`, "sets quality and a --bitrate adapter arg"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestValidateAdapterFallback(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Sources[0] = Source{
+		ID:        "deezer-playlist",
+		Type:      SourceTypeDeezer,
+		Enabled:   true,
+		TargetDir: "/tmp/music-dz",
+		URL:       "https://www.deezer.com/playlist/123",
+		StateFile: "deezer-playlist.sync.deezer",
+		Adapter:   AdapterSpec{Kind: "deemix", Fallback: []string{"spotdl", "ytdlp"}},
+	}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid fallback chain, got %v", err)
+	}
+
+	cfg.Sources[0].Adapter.Fallback = []string{"ytdlp", "scdl", "ytdlp"}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected fallback problems")
+	}
+	for _, want := range []string{`unsupported adapter.fallback "scdl"`, `lists adapter.fallback "ytdlp" more than once`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	cfg.Sources[0].Adapter = AdapterSpec{Kind: "spotdl", Fallback: []string{"ytdlp"}}
+	cfg.Sources[0].Type = SourceTypeSpotify
+	cfg.Sources[0].URL = "https://open.spotify.com/playlist/abc"
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "adapter.fallback is only supported for the deemix adapter") {
+		t.Fatalf("expected deemix-only fallback problem, got %v", err)
+	}
+}
+
+func TestValidateVersion2FeaturesRequireVersion2(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Version = 1
+	cfg.Sources[0].Defaults = SourceDefaults{Threads: 3}
+	err := Validate(cfg)
+	if err == nil || !strings.Contains(err.Error(), "require version: 2") {
+		t.Fatalf("expected version 2 requirement, got %v", err)
+	}
+
+	migrated, changes, err := Migrate(cfg)
+	if err != nil || len(changes) != 1 || migrated.Version != CurrentVersion {
+		t.Fatalf("unexpected migration: version=%d changes=%v err=%v", migrated.Version, changes, err)
+	}
+	if err := Validate(migrated); err != nil {
+		t.Fatalf("expected migrated config to validate, got %v", err)
+	}
+
+	migrated.Sources[0].Defaults.Threads = -1
+	migrated.Profiles = map[string]Profile{"bad name": {Defaults: ProfileDefaults{StateDir: "relative"}}}
+	err = Validate(migrated)
+	for _, want := range []string{
+		`source "` + migrated.Sources[0].ID + `" defaults.threads must be >= 0`,
+		`profile "bad name" must match`,
+		`profile "bad name" defaults.state_dir must resolve to an absolute path`,
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected problem %q, got %v", want, err)
+		}
+	}
+}
+
+func TestValidateFileOwnershipUmask(t *testing.T) {
+	cfg := testValidConfig()
+	cfg.Defaults.FileOwnership = FileOwnership{Umask: "0002", Owner: "media"}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid file_ownership, got %v", err)
+	}
+	if mask, ok, _ := cfg.Defaults.FileOwnership.UmaskValue(); !ok || mask != 0o002 {
+		t.Fatalf("expected umask 0002, got %o (ok=%v)", mask, ok)
+	}
+
+	for _, umask := range []string{"0999", "1777", "rw"} {
+		cfg.Defaults.FileOwnership.Umask = umask
+		err := Validate(cfg)
+		if err == nil || !strings.Contains(err.Error(), "defaults.file_ownership.umask") {
+			t.Fatalf("expected umask problem for %q, got %v", umask, err)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Package criteria parses the sync.success_when expressions that decide when a
+// source run counts as successful, e.g. "failed_tracks == 0 && unavailable <= 2".
+// Expressions compare per-source track counters with integers and combine the
+// comparisons with &&, ||, ! and parentheses.
+package criteria
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Per-source track counters an expression may reference.
+const (
+	Planned      = "planned"
+	Downloaded   = "downloaded"
+	Skipped      = "skipped"
+	Unavailable  = "unavailable"
+	FailedTracks = "failed_tracks"
+)
+
+var knownVariables = map[string]struct{}{
+	Planned:      {},
+	Downloaded:   {},
+	Skipped:      {},
+	Unavailable:  {},
+	FailedTracks: {},
+}
+
+// Variables lists the counter names accepted in expressions, sorted.
+func Variables() []string {
+	names := make([]string, 0, len(knownVariables))
+	for name := range knownVariables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type Expr interface {
+	// Eval reports whether the expression holds for the given counters;
+	// counters missing from vars read as 0.
+	Eval(vars map[string]int) bool
+	String() string
+}
+
+func Parse(raw string) (Expr, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return nil, fmt.Errorf("expression is empty")
+	}
+	tokens, err := tokenize(value)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.pos)
+	}
+	return expression{node: node, raw: value}, nil
+}
+
+type expression struct {
+	node node
+	raw  string
+}
+
+func (e expression) Eval(vars map[string]int) bool {
+	return e.node.eval(vars)
+}
+
+func (e expression) String() string {
+	return e.raw
+}
+
+type node interface {
+	eval(vars map[string]int) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(vars map[string]int) bool { return n.left.eval(vars) && n.right.eval(vars) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(vars map[string]int) bool { return n.left.eval(vars) || n.right.eval(vars) }
+
+type notNode struct{ inner node }
+
+func (n notNode) eval(vars map[string]int) bool { return !n.inner.eval(vars) }
+
+type operand struct {
+	name  string
+	value int
+}
+
+func (o operand) resolve(vars map[string]int) int {
+	if o.name == "" {
+		return o.value
+	}
+	return vars[o.name]
+}
+
+type compareNode struct {
+	op          string
+	left, right operand
+}
+
+func (n compareNode) eval(vars map[string]int) bool {
+	left, right := n.left.resolve(vars), n.right.resolve(vars)
+	switch n.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	default:
+		return left >= right
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenCompare
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(value string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(value); {
+		c := value[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			kind := tokenLParen
+			if c == ')' {
+				kind = tokenRParen
+			}
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: i})
+			i++
+		case strings.HasPrefix(value[i:], "&&"):
+			tokens = append(tokens, token{kind: tokenAnd, text: "&&", pos: i})
+			i += 2
+		case strings.HasPrefix(value[i:], "||"):
+			tokens = append(tokens, token{kind: tokenOr, text: "||", pos: i})
+			i += 2
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			if i+1 < len(value) && value[i+1] == '=' {
+				tokens = append(tokens, token{kind: tokenCompare, text: value[i : i+2], pos: i})
+				i += 2
+				continue
+			}
+			switch c {
+			case '!':
+				tokens = append(tokens, token{kind: tokenNot, text: "!", pos: i})
+			case '<', '>':
+				tokens = append(tokens, token{kind: tokenCompare, text: string(c), pos: i})
+			default:
+				return nil, fmt.Errorf("unexpected %q at offset %d (use == for equality)", string(c), i)
+			}
+			i++
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(value) && value[i] >= '0' && value[i] <= '9' {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: value[start:i], pos: start})
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(value) && (value[i] == '_' || (value[i] >= 'a' && value[i] <= 'z') || (value[i] >= 'A' && value[i] <= 'Z') || (value[i] >= '0' && value[i] <= '9')) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: value[start:i], pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", string(c), i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, text: "end of expression", pos: len(value)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch p.peek().kind {
+	case tokenNot:
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner: inner}, nil
+	case tokenLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ) at offset %d, got %q", tok.pos, tok.text)
+		}
+		return inner, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.next()
+	if op.kind != tokenCompare {
+		return nil, fmt.Errorf("expected comparison operator at offset %d, got %q", op.pos, op.text)
+	}
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op.text, left: left, right: right}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		value, err := strconv.Atoi(tok.text)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return operand{value: value}, nil
+	case tokenIdent:
+		name := strings.ToLower(tok.text)
+		if _, ok := knownVariables[name]; !ok {
+			return operand{}, fmt.Errorf("unknown counter %q (expected one of %s)", tok.text, strings.Join(Variables(), ", "))
+		}
+		return operand{name: name}, nil
+	default:
+		return operand{}, fmt.Errorf("expected counter or number at offset %d, got %q", tok.pos, tok.text)
+	}
+}
@@ -0,0 +1,45 @@
+package criteria
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAndEvalExpressions(t *testing.T) {
+	vars := map[string]int{FailedTracks: 0, Unavailable: 2, Downloaded: 5, Planned: 6}
+	cases := map[string]bool{
+		"failed_tracks == 0 && unavailable <= 2":      true,
+		"failed_tracks == 0 && unavailable < 2":       false,
+		"unavailable > 5 || downloaded >= planned":    false,
+		"unavailable > 5 || downloaded >= 5":          true,
+		"!(failed_tracks > 0) && skipped == 0":        true,
+		"(downloaded == planned) || unavailable != 0": true,
+		"FAILED_TRACKS != 0":                          false,
+	}
+	for raw, want := range cases {
+		expr, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("parse %q: %v", raw, err)
+		}
+		if got := expr.Eval(vars); got != want {
+			t.Fatalf("%q: expected %v, got %v", raw, want, got)
+		}
+	}
+}
+
+func TestParseRejectsInvalidExpressions(t *testing.T) {
+	for raw, want := range map[string]string{
+		"":                       "empty",
+		"failed == 0":            "unknown counter",
+		"failed_tracks = 0":      "use ==",
+		"failed_tracks":          "comparison operator",
+		"(unavailable <= 2":      "expected )",
+		"unavailable <= 2 &&":    "counter or number",
+		"unavailable <= 2 extra": "unexpected",
+	} {
+		_, err := Parse(raw)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error containing %q, got %v", raw, want, err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	compatFetchTimeout = 10 * time.Second
+	compatCacheTTL     = time.Hour
+	compatCacheFile    = "compat-matrix.json"
+	compatMaxBodyBytes = 1 << 20
+)
+
+var compatHTTPClient = &http.Client{}
+
+// compatDocument is the remote compat.url format:
+//
+//	{"tools": {"scdl": {"min_version": "3.0.0", "max_version_exclusive": "4.0.0",
+//	  "known_bad": {"3.1.2": "breaks playlist pagination"}}}}
+type compatDocument struct {
+	Tools map[string]compatDocumentRule `json:"tools"`
+}
+
+type compatDocumentRule struct {
+	MinVersion          string            `json:"min_version,omitempty"`
+	MaxVersionExclusive string            `json:"max_version_exclusive,omitempty"`
+	KnownBad            map[string]string `json:"known_bad,omitempty"`
+}
+
+type compatCache struct {
+	URL       string         `json:"url"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	Document  compatDocument `json:"document"`
+}
+
+// dependencyMatrix layers the remote compat.url document and then the
+// config's compat.tools over the built-in matrix. The remote document is
+// cached under state_dir for compatCacheTTL, and the cached copy is used
+// when a refresh fails or network probes are off.
+func (c *Checker) dependencyMatrix(ctx context.Context, cfg config.Config) (map[string]dependencyMatrixRule, []Check) {
+	matrix := c.matrix()
+	checks := []Check{}
+	if remoteURL := strings.TrimSpace(cfg.Compat.URL); remoteURL != "" {
+		document, check := c.remoteCompatDocument(ctx, cfg, remoteURL)
+		mergeCompatDocument(matrix, document)
+		checks = append(checks, check)
+	}
+
+	local := compatDocument{Tools: map[string]compatDocumentRule{}}
+	for tool, rule := range cfg.Compat.Tools {
+		local.Tools[tool] = compatDocumentRule{
+			MinVersion:          rule.MinVersion,
+			MaxVersionExclusive: rule.MaxVersionExclusive,
+			KnownBad:            rule.KnownBad,
+		}
+	}
+	mergeCompatDocument(matrix, local)
+	return matrix, checks
+}
+
+func (c *Checker) remoteCompatDocument(ctx context.Context, cfg config.Config, remoteURL string) (compatDocument, Check) {
+	now := c.Now
+	if now == nil {
+		now = time.Now
+	}
+	cachePath := ""
+	if stateDir, err := config.ExpandPath(cfg.Defaults.StateDir); err == nil && filepath.IsAbs(stateDir) {
+		cachePath = filepath.Join(stateDir, "doctor", compatCacheFile)
+	}
+	cache, cached := loadCompatCache(cachePath, remoteURL)
+	if cached && now().Sub(cache.FetchedAt) < compatCacheTTL {
+		return cache.Document, Check{
+			Severity: SeverityInfo,
+			Name:     "dependency",
+			Message:  fmt.Sprintf("compatibility matrix from %s (cached %s)", remoteURL, cache.FetchedAt.Format(time.RFC3339)),
+		}
+	}
+
+	if c.FetchCompatMatrix == nil {
+		if cached {
+			return cache.Document, Check{
+				Severity: SeverityInfo,
+				Name:     "dependency",
+				Message:  fmt.Sprintf("compatibility matrix from %s not refreshed offline; using copy from %s", remoteURL, cache.FetchedAt.Format(time.RFC3339)),
+			}
+		}
+		return compatDocument{}, Check{
+			Severity: SeverityInfo,
+			Name:     "dependency",
+			Message:  fmt.Sprintf("compatibility matrix from %s skipped offline; using built-in and config rules", remoteURL),
+		}
+	}
+
+	document := compatDocument{}
+	raw, err := c.FetchCompatMatrix(ctx, remoteURL)
+	if err == nil {
+		if unmarshalErr := json.Unmarshal(raw, &document); unmarshalErr != nil {
+			err = fmt.Errorf("invalid JSON: %w", unmarshalErr)
+		}
+	}
+	if err != nil {
+		if cached {
+			return cache.Document, Check{
+				Severity: SeverityWarn,
+				Name:     "dependency",
+				Message:  fmt.Sprintf("compatibility matrix from %s could not be refreshed: %v; using copy from %s", remoteURL, err, cache.FetchedAt.Format(time.RFC3339)),
+			}
+		}
+		return compatDocument{}, Check{
+			Severity: SeverityWarn,
+			Name:     "dependency",
+			Message:  fmt.Sprintf("compatibility matrix from %s could not be loaded: %v; using built-in and config rules", remoteURL, err),
+		}
+	}
+	if cachePath != "" {
+		_ = saveCompatCache(cachePath, compatCache{URL: remoteURL, FetchedAt: now().UTC(), Document: document})
+	}
+	return document, Check{
+		Severity: SeverityInfo,
+		Name:     "dependency",
+		Message:  fmt.Sprintf("compatibility matrix loaded from %s (%d tool rule(s))", remoteURL, len(document.Tools)),
+	}
+}
+
+// mergeCompatDocument applies each rule over matrix: a non-empty range bound
+// replaces the current one and known-bad versions are added.
+func mergeCompatDocument(matrix map[string]dependencyMatrixRule, document compatDocument) {
+	for tool, override := range document.Tools {
+		key := strings.ToLower(strings.TrimSpace(tool))
+		rule := matrix[key]
+		if rule.KnownBad == nil {
+			rule.KnownBad = map[string]string{}
+		}
+		if minimum := strings.TrimSpace(override.MinVersion); minimum != "" {
+			rule.MinVersion = minimum
+		}
+		if maximum := strings.TrimSpace(override.MaxVersionExclusive); maximum != "" {
+			rule.MaxVersionExclusive = maximum
+		}
+		for version, reason := range override.KnownBad {
+			if version = strings.TrimSpace(version); version != "" {
+				rule.KnownBad[version] = strings.TrimSpace(reason)
+			}
+		}
+		matrix[key] = rule
+	}
+}
+
+func loadCompatCache(path string, remoteURL string) (compatCache, bool) {
+	if path == "" {
+		return compatCache{}, false
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return compatCache{}, false
+	}
+	cache := compatCache{}
+	if err := json.Unmarshal(raw, &cache); err != nil || cache.URL != remoteURL {
+		return compatCache{}, false
+	}
+	return cache, true
+}
+
+func saveCompatCache(path string, cache compatCache) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(payload, '\n'), 0o644)
+}
+
+// fetchCompatMatrix downloads a compat.url document. Only tool version rules
+// are read from it, so it cannot change anything the config's compat.tools
+// could not.
+func fetchCompatMatrix(ctx context.Context, remoteURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, compatFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remoteURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := compatHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, compatMaxBodyBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > compatMaxBodyBytes {
+		return nil, fmt.Errorf("document is larger than %d bytes", compatMaxBodyBytes)
+	}
+	return raw, nil
+}
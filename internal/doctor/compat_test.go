@@ -0,0 +1,100 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func compatChecker(scdlVersion string) *Checker {
+	return &Checker{
+		LookPath: func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		ReadVersion: func(ctx context.Context, binary string) (string, error) {
+			switch binary {
+			case "scdl":
+				return "scdl " + scdlVersion, nil
+			case "yt-dlp":
+				return "yt-dlp 2026.2.4", nil
+			default:
+				return "0.0.0", nil
+			}
+		},
+		Getenv:        func(key string) string { return "set" },
+		CheckWritable: func(path string) error { return nil },
+		Matrix:        defaultDependencyMatrix(),
+	}
+}
+
+func TestDependencyMatrixAppliesRemoteThenConfigRules(t *testing.T) {
+	cfg := soundcloudConfig()
+	cfg.Defaults.StateDir = t.TempDir()
+	cfg.Compat = config.Compat{
+		URL: "https://example.com/compat.json",
+		Tools: map[string]config.CompatRule{
+			"scdl": {MaxVersionExclusive: "3.9.0"},
+		},
+	}
+	checker := compatChecker("3.1.2")
+	checker.FetchCompatMatrix = func(ctx context.Context, url string) ([]byte, error) {
+		return []byte(`{"tools": {"scdl": {"max_version_exclusive": "3.5.0", "known_bad": {"3.1.2": "breaks playlist pagination"}}}}`), nil
+	}
+
+	matrix, checks := checker.dependencyMatrix(context.Background(), cfg)
+	if len(checks) != 1 || !strings.Contains(checks[0].Message, "loaded from https://example.com/compat.json (1 tool rule(s))") {
+		t.Fatalf("unexpected matrix checks: %+v", checks)
+	}
+	if rule := matrix["scdl"]; rule.MinVersion != "3.0.0" || rule.MaxVersionExclusive != "3.9.0" || rule.KnownBad["3.1.2"] != "breaks playlist pagination" {
+		t.Fatalf("unexpected merged scdl rule: %+v", rule)
+	}
+
+	report := checker.Check(context.Background(), cfg)
+	if !hasErrorContaining(report, "scdl version 3.1.2 is blocked by compatibility matrix: breaks playlist pagination") {
+		t.Fatalf("expected remote known-bad version to fail, got %+v", report.Checks)
+	}
+}
+
+func TestDependencyMatrixFallsBackToCachedRemoteDocument(t *testing.T) {
+	cfg := soundcloudConfig()
+	cfg.Defaults.StateDir = t.TempDir()
+	cfg.Compat.URL = "https://example.com/compat.json"
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	checker := compatChecker("3.1.2")
+	checker.Now = func() time.Time { return now }
+	fetches := 0
+	checker.FetchCompatMatrix = func(ctx context.Context, url string) ([]byte, error) {
+		fetches++
+		return []byte(`{"tools": {"scdl": {"known_bad": {"3.1.2": ""}}}}`), nil
+	}
+	if _, checks := checker.dependencyMatrix(context.Background(), cfg); len(checks) != 1 || checks[0].Severity != SeverityInfo {
+		t.Fatalf("unexpected first load: %+v", checks)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Defaults.StateDir, "doctor", compatCacheFile)); err != nil {
+		t.Fatalf("expected cached compat document: %v", err)
+	}
+
+	if _, checks := checker.dependencyMatrix(context.Background(), cfg); fetches != 1 || !strings.Contains(checks[0].Message, "(cached ") {
+		t.Fatalf("expected a fresh cache to skip the fetch, got %d fetches and %+v", fetches, checks)
+	}
+
+	now = now.Add(2 * compatCacheTTL)
+	checker.FetchCompatMatrix = func(ctx context.Context, url string) ([]byte, error) {
+		return nil, errors.New("HTTP 503")
+	}
+	matrix, checks := checker.dependencyMatrix(context.Background(), cfg)
+	if _, blocked := matrix["scdl"].KnownBad["3.1.2"]; !blocked || checks[0].Severity != SeverityWarn || !strings.Contains(checks[0].Message, "HTTP 503; using copy from 2026-03-01T12:00:00Z") {
+		t.Fatalf("expected cached rules after a failed refresh, got %+v %+v", matrix["scdl"], checks)
+	}
+
+	checker.FetchCompatMatrix = nil
+	cfg.Compat.URL = "https://example.com/other.json"
+	matrix, checks = checker.dependencyMatrix(context.Background(), cfg)
+	if _, blocked := matrix["scdl"].KnownBad["3.1.2"]; blocked || !strings.Contains(checks[0].Message, "skipped offline") {
+		t.Fatalf("expected a cache for another URL to be ignored, got %+v %+v", matrix["scdl"], checks)
+	}
+}
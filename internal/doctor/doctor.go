@@ -3,6 +3,7 @@ package doctor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +11,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jaa/update-downloads/internal/auth"
 	"github.com/jaa/update-downloads/internal/config"
@@ -30,7 +32,8 @@ type Check struct {
 }
 
 type Report struct {
-	Checks []Check `json:"checks"`
+	Checks []Check     `json:"checks"`
+	Fixes  []FixResult `json:"fixes,omitempty"`
 }
 
 func (r Report) HasErrors() bool {
@@ -65,8 +68,31 @@ type Checker struct {
 	ResolveDeemixARL          func() (string, error)
 	ResolveDeemixWithSource   func() (string, auth.CredentialStorageSource, error)
 	ResolveSoundCloudClientID func() (string, auth.CredentialStorageSource, error)
+	ResolveSoundCloudOAuth    func() (auth.SoundCloudOAuthToken, auth.CredentialStorageSource, error)
+	ResolveSoundCloudOAuthApp func() (auth.SoundCloudAppCredentials, error)
+	ResolveTidalTokenFile     func() (string, auth.CredentialStorageSource, error)
+	ResolveAppleMusicCookies  func() (string, auth.CredentialStorageSource, error)
+	ReadAppleMusicUserToken   func(string) (string, error)
 	LoadCredentialMetadata    func(string) (auth.CredentialMetadataStore, error)
-	Matrix                    map[string]dependencyMatrixRule
+	// ProbeSpotifyAPI measures throttling for the configured Spotify app
+	// credentials. Nil skips the network probe.
+	ProbeSpotifyAPI func(context.Context, auth.SpotifyCredentials) (SpotifyProbeResult, error)
+	// ProbeSoundCloudOAuth asks SoundCloud whether the stored OAuth token
+	// is still accepted. Nil skips the network probe.
+	ProbeSoundCloudOAuth func(context.Context, string) error
+	// FetchCompatMatrix downloads the compat.url document. Nil skips the
+	// refresh and uses the cached copy, if any.
+	FetchCompatMatrix func(context.Context, string) ([]byte, error)
+	Now               func() time.Time
+	Matrix            map[string]dependencyMatrixRule
+	// Filesystem and process hooks used by Fix; nil uses the os package.
+	RunCommand func(ctx context.Context, name string, args ...string) ([]byte, error)
+	MkdirAll   func(string, os.FileMode) error
+	WriteFile  func(string, []byte, os.FileMode) error
+	Chmod      func(string, os.FileMode) error
+	Chown      func(string, int, int) error
+	Symlink    func(string, string) error
+	Geteuid    func() int
 }
 
 type dirAccessResult struct {
@@ -90,7 +116,16 @@ func NewChecker() *Checker {
 		ResolveDeemixARL:          auth.ResolveDeemixARL,
 		ResolveDeemixWithSource:   auth.ResolveDeemixARLWithSource,
 		ResolveSoundCloudClientID: auth.ResolveSoundCloudClientIDWithSource,
+		ResolveSoundCloudOAuth:    auth.ResolveSoundCloudOAuthTokenWithSource,
+		ResolveSoundCloudOAuthApp: auth.SoundCloudOAuthResolver{}.ResolveAppCredentials,
+		ResolveTidalTokenFile:     auth.ResolveTidalTokenFile,
+		ResolveAppleMusicCookies:  auth.ResolveAppleMusicCookiesFile,
+		ReadAppleMusicUserToken:   auth.ReadAppleMusicMediaUserToken,
 		LoadCredentialMetadata:    auth.LoadCredentialMetadata,
+		ProbeSpotifyAPI:           probeSpotifyAPI,
+		ProbeSoundCloudOAuth:      probeSoundCloudOAuth,
+		FetchCompatMatrix:         fetchCompatMatrix,
+		Now:                       time.Now,
 		Matrix:                    defaultDependencyMatrix(),
 	}
 }
@@ -114,7 +149,9 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 		return report
 	}
 
-	requiredBinaries := requiredBinaries(cfg, c.matrix())
+	matrix, matrixChecks := c.dependencyMatrix(ctx, cfg)
+	report.Checks = append(report.Checks, matrixChecks...)
+	requiredBinaries := requiredBinaries(cfg, matrix)
 	for _, dep := range requiredBinaries {
 		location, err := c.LookPath(dep.Binary)
 		if err != nil {
@@ -189,16 +226,14 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 				})
 				continue
 			}
+			supported := fmt.Sprintf(">=%s", dep.MinVersion)
+			if strings.TrimSpace(dep.Matrix.MaxVersionExclusive) != "" {
+				supported = fmt.Sprintf("%s and <%s", supported, dep.Matrix.MaxVersionExclusive)
+			}
 			report.Checks = append(report.Checks, Check{
 				Severity: SeverityInfo,
 				Name:     "dependency",
-				Message: fmt.Sprintf(
-					"%s version %s is compatible with supported matrix >=%s and <%s",
-					dep.Binary,
-					version,
-					dep.MinVersion,
-					dep.Matrix.MaxVersionExclusive,
-				),
+				Message:  fmt.Sprintf("%s version %s is compatible with supported matrix %s", dep.Binary, version, supported),
 			})
 			continue
 		}
@@ -214,8 +249,9 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 		if check, ok := c.sharedSpotDLCredentialsCheck(); ok {
 			report.Checks = append(report.Checks, check)
 		}
+		report.Checks = append(report.Checks, c.spotifyThrottleChecks(ctx, cfg)...)
 	}
-	if hasEnabledSpotifyDeemixSource(cfg.Sources) {
+	if hasEnabledDeemixSource(cfg.Sources) {
 		report.Checks = append(report.Checks, Check{
 			Severity: SeverityWarn,
 			Name:     "security",
@@ -223,6 +259,18 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 		})
 	}
 
+	if hasEnabledTidalSource(cfg.Sources) {
+		report.Checks = append(report.Checks, c.tidalTokenCheck())
+	}
+	if hasEnabledAppleMusicSource(cfg.Sources) {
+		report.Checks = append(report.Checks, c.appleMusicCookiesCheck())
+	}
+	if needsSoundCloudOAuthCheck(cfg.Sources) {
+		if check, ok := c.soundCloudOAuthCheck(ctx); ok {
+			report.Checks = append(report.Checks, check)
+		}
+	}
+
 	for _, source := range cfg.Sources {
 		if !source.Enabled {
 			continue
@@ -234,14 +282,16 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 				report.Checks = append(report.Checks, check)
 			}
 		}
+		if strings.TrimSpace(source.CookiesFile) != "" {
+			report.Checks = append(report.Checks, cookiesFileChecks(source)...)
+		}
 
-		targetDir, err := config.ExpandPath(source.TargetDir)
-		if err != nil {
+		if config.IsRcloneRemote(source.TargetDir) {
+			report.Checks = append(report.Checks, c.rcloneTargetCheck(source))
+		} else if targetDir, err := config.ExpandTargetDir(source.TargetDir); err != nil {
 			report.Checks = append(report.Checks, Check{Severity: SeverityError, Name: "filesystem", Message: fmt.Sprintf("source %s target_dir is invalid: %v", source.ID, err)})
 			continue
-		}
-		targetAccess := c.inspectDir(targetDir)
-		if targetAccess.Err != nil {
+		} else if targetAccess := c.inspectDir(targetDir); targetAccess.Err != nil {
 			report.Checks = append(report.Checks, Check{Severity: SeverityError, Name: "filesystem", Message: fmt.Sprintf("source %s target_dir is not writable: %v", source.ID, targetAccess.Err)})
 		} else if targetAccess.Creatable {
 			report.Checks = append(report.Checks, Check{Severity: SeverityInfo, Name: "filesystem", Message: fmt.Sprintf("source %s target directory will be created on first sync", source.ID)})
@@ -249,7 +299,7 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 			report.Checks = append(report.Checks, Check{Severity: SeverityInfo, Name: "filesystem", Message: fmt.Sprintf("source %s target_dir is writable", source.ID)})
 		}
 
-		if source.Type == config.SourceTypeSpotify {
+		if source.Type == config.SourceTypeSpotify || source.Type == config.SourceTypeDeezer || source.Type == config.SourceTypeAppleMusic {
 			stateFile, stateErr := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
 			if stateErr != nil {
 				report.Checks = append(report.Checks, Check{Severity: SeverityError, Name: "filesystem", Message: fmt.Sprintf("source %s state_file is invalid: %v", source.ID, stateErr)})
@@ -267,7 +317,9 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 
 			if source.Adapter.Kind == "deemix" {
 				report.Checks = append(report.Checks, c.deemixARLCheck()...)
-				report.Checks = append(report.Checks, c.spotifyCredentialsCheck()...)
+				if source.Type == config.SourceTypeSpotify {
+					report.Checks = append(report.Checks, c.spotifyCredentialsCheck()...)
+				}
 			}
 		}
 		if source.Type == config.SourceTypeSoundCloud {
@@ -287,9 +339,84 @@ func (c *Checker) Check(ctx context.Context, cfg config.Config) Report {
 			}
 		}
 	}
+	report.Checks = append(report.Checks, c.ownershipChecks(cfg)...)
 	return report
 }
 
+// ownershipChecks reports target and state directories owned by someone other
+// than the user running doctor, which is how files end up owned by root on a
+// shared NAS, and whether defaults.file_ownership can hand new files back.
+func (c *Checker) ownershipChecks(cfg config.Config) []Check {
+	checks := []Check{}
+	euid := c.geteuid()
+	policy := cfg.Defaults.FileOwnership
+	policyUID := -1
+	if policy.Owner != "" || policy.Group != "" {
+		uid, _, err := policy.IDs()
+		switch {
+		case err != nil:
+			checks = append(checks, Check{Severity: SeverityError, Name: "ownership", Message: fmt.Sprintf("defaults.file_ownership %v", err)})
+		case uid >= 0 && uid != euid && euid != 0:
+			checks = append(checks, Check{Severity: SeverityWarn, Name: "ownership", Message: fmt.Sprintf("defaults.file_ownership.owner is uid %d but udl runs as uid %d; only root can hand files to another user, so new files will keep uid %d", uid, euid, euid)})
+		default:
+			policyUID = uid
+			checks = append(checks, Check{Severity: SeverityInfo, Name: "ownership", Message: fmt.Sprintf("files created by sync will be changed to owner %s group %s", ownershipLabel(policy.Owner), ownershipLabel(policy.Group))})
+		}
+	}
+	if policy.Umask != "" {
+		checks = append(checks, Check{Severity: SeverityInfo, Name: "ownership", Message: fmt.Sprintf("sync and its adapters run with umask %s", policy.Umask)})
+	}
+
+	type dirTarget struct{ label, path string }
+	targets := []dirTarget{}
+	if stateDir, err := config.ExpandPath(cfg.Defaults.StateDir); err == nil {
+		targets = append(targets, dirTarget{"state_dir", stateDir})
+	}
+	for _, source := range cfg.Sources {
+		if !source.Enabled {
+			continue
+		}
+		if targetDir, err := config.ExpandTargetDir(source.TargetDir); err == nil {
+			targets = append(targets, dirTarget{fmt.Sprintf("source %s target_dir", source.ID), targetDir})
+		}
+	}
+	seen := map[string]struct{}{}
+	for _, target := range targets {
+		if _, ok := seen[target.path]; ok {
+			continue
+		}
+		seen[target.path] = struct{}{}
+		info, err := os.Stat(target.path)
+		if err != nil {
+			continue
+		}
+		owner, known := fileOwner(info)
+		if !known || owner == euid || (policyUID >= 0 && policyUID == owner) {
+			continue
+		}
+		runningAs := fmt.Sprintf("uid %d", euid)
+		if euid == 0 {
+			runningAs = "root"
+		}
+		checks = append(checks, Check{
+			Severity: SeverityWarn,
+			Name:     "ownership",
+			Message: fmt.Sprintf(
+				"%s %s is owned by uid %d but udl runs as %s; files a sync creates will be owned by %s and uid %d may not be able to modify them (run udl as uid %d or set defaults.file_ownership)",
+				target.label, target.path, owner, runningAs, runningAs, owner, owner,
+			),
+		})
+	}
+	return checks
+}
+
+func ownershipLabel(value string) string {
+	if value == "" {
+		return "unchanged"
+	}
+	return value
+}
+
 func (c *Checker) soundCloudClientIDCheck(stateDir string) Check {
 	resolve := c.ResolveSoundCloudClientID
 	if resolve == nil {
@@ -379,7 +506,7 @@ func (c *Checker) deemixARLCheck() []Check {
 		return []Check{{
 			Severity: SeverityError,
 			Name:     "auth",
-			Message:  "deemix Spotify sources require Deezer ARL; open `udl tui`, choose Credentials, and save it to Keychain or set UDL_DEEMIX_ARL",
+			Message:  "deemix sources require Deezer ARL; open `udl tui`, choose Credentials, and save it to Keychain or set UDL_DEEMIX_ARL",
 		}}
 	}
 	switch source {
@@ -387,11 +514,97 @@ func (c *Checker) deemixARLCheck() []Check {
 		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Deezer ARL is available via environment override"}}
 	case auth.CredentialStorageSourceKeychain:
 		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Deezer ARL is available in macOS Keychain"}}
+	case auth.CredentialStorageSourceStore:
+		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Deezer ARL is available in the encrypted credential store"}}
 	default:
 		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Deezer ARL is available"}}
 	}
 }
 
+func (c *Checker) tidalTokenCheck() Check {
+	resolve := c.ResolveTidalTokenFile
+	if resolve == nil {
+		resolve = auth.ResolveTidalTokenFile
+	}
+	path, source, err := resolve()
+	if err != nil {
+		location := "~/" + auth.TidalTokenFileName
+		if strings.TrimSpace(path) != "" {
+			location = path
+		}
+		return Check{
+			Severity: SeverityError,
+			Name:     "auth",
+			Message:  fmt.Sprintf("tidal-dl token file not found at %s; run `tidal-dl` once to log in or set UDL_TIDAL_TOKEN_FILE", location),
+		}
+	}
+	if source == auth.CredentialStorageSourceEnv {
+		return Check{Severity: SeverityInfo, Name: "auth", Message: fmt.Sprintf("tidal-dl token file is available via UDL_TIDAL_TOKEN_FILE (%s)", path)}
+	}
+	return Check{Severity: SeverityInfo, Name: "auth", Message: fmt.Sprintf("tidal-dl token file is available at %s", path)}
+}
+
+// cookiesFileChecks confirms a source's cookies_file exists, is private to
+// the user, and for SoundCloud carries the oauth_token scdl signs in with.
+func cookiesFileChecks(source config.Source) []Check {
+	path, err := config.ResolveCookiesFile(source)
+	if err != nil {
+		return []Check{{Severity: SeverityError, Name: "auth", Message: fmt.Sprintf("source %s cookies_file is invalid: %v", source.ID, err)}}
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() == 0 {
+		return []Check{{Severity: SeverityError, Name: "auth", Message: fmt.Sprintf("source %s cookies_file %s not found; export cookies (Netscape format) from a signed-in browser there", source.ID, path)}}
+	}
+	checks := []Check{}
+	if info.Mode().Perm()&0o077 != 0 {
+		checks = append(checks, Check{Severity: SeverityWarn, Name: "security", Message: fmt.Sprintf("source %s cookies_file %s is readable by other users; it holds a signed-in session, so chmod 600 it", source.ID, path)})
+	}
+	if source.Type == config.SourceTypeSoundCloud {
+		if _, err := auth.ReadSoundCloudOAuthToken(path); err != nil {
+			if errors.Is(err, auth.ErrSoundCloudCookiesOAuthNotFound) {
+				return append(checks, Check{Severity: SeverityWarn, Name: "auth", Message: fmt.Sprintf("source %s cookies_file %s has no soundcloud.com oauth_token; re-export it while signed in, or private and Go+ tracks stay unavailable", source.ID, path)})
+			}
+			return append(checks, Check{Severity: SeverityError, Name: "auth", Message: fmt.Sprintf("source %s cookies_file %s is unreadable: %v", source.ID, path, err)})
+		}
+	}
+	return append(checks, Check{Severity: SeverityInfo, Name: "auth", Message: fmt.Sprintf("source %s cookies_file is available at %s", source.ID, path)})
+}
+
+// appleMusicCookiesCheck confirms the cookies file exists and carries a
+// media-user-token; the token value itself is never reported.
+func (c *Checker) appleMusicCookiesCheck() Check {
+	resolve := c.ResolveAppleMusicCookies
+	if resolve == nil {
+		resolve = auth.ResolveAppleMusicCookiesFile
+	}
+	readToken := c.ReadAppleMusicUserToken
+	if readToken == nil {
+		readToken = auth.ReadAppleMusicMediaUserToken
+	}
+	path, source, err := resolve()
+	if err != nil {
+		location := "~/.config/udl/" + auth.AppleMusicCookiesFileName
+		if strings.TrimSpace(path) != "" {
+			location = path
+		}
+		return Check{
+			Severity: SeverityError,
+			Name:     "auth",
+			Message:  fmt.Sprintf("apple music cookies file not found at %s; export music.apple.com cookies (Netscape format) from a signed-in browser or set UDL_APPLE_MUSIC_COOKIES_FILE", location),
+		}
+	}
+	if _, err := readToken(path); err != nil {
+		if errors.Is(err, auth.ErrAppleMusicMediaUserTokenNotFound) {
+			return Check{Severity: SeverityError, Name: "auth", Message: fmt.Sprintf("apple music cookies file %s has no media-user-token; re-export it while signed in to music.apple.com", path)}
+		}
+		return Check{Severity: SeverityError, Name: "auth", Message: fmt.Sprintf("apple music cookies file %s is unreadable: %v", path, err)}
+	}
+	if source == auth.CredentialStorageSourceEnv {
+		return Check{Severity: SeverityInfo, Name: "auth", Message: fmt.Sprintf("apple music cookies file is available via UDL_APPLE_MUSIC_COOKIES_FILE (%s)", path)}
+	}
+	return Check{Severity: SeverityInfo, Name: "auth", Message: fmt.Sprintf("apple music cookies file is available at %s", path)}
+}
+
 func (c *Checker) spotifyCredentialsCheck() []Check {
 	resolveWithSource := c.ResolveSpotifyWithSource
 	if resolveWithSource == nil && c.ResolveSpotifyCredentials != nil {
@@ -417,6 +630,8 @@ func (c *Checker) spotifyCredentialsCheck() []Check {
 		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Spotify app credentials are available via environment override"}}
 	case auth.CredentialStorageSourceKeychain:
 		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Spotify app credentials are available in macOS Keychain"}}
+	case auth.CredentialStorageSourceStore:
+		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Spotify app credentials are available in the encrypted credential store"}}
 	case auth.CredentialStorageSourceSpotDL:
 		return []Check{{Severity: SeverityInfo, Name: "auth", Message: "Spotify app credentials are available via ~/.spotdl/config.json compatibility fallback"}}
 	default:
@@ -424,6 +639,16 @@ func (c *Checker) spotifyCredentialsCheck() []Check {
 	}
 }
 
+// rcloneTargetCheck covers a target_dir that is an rclone remote: udl only
+// needs the rclone binary locally; the remote itself is listed at sync time.
+func (c *Checker) rcloneTargetCheck(source config.Source) Check {
+	location, err := c.LookPath("rclone")
+	if err != nil {
+		return Check{Severity: SeverityError, Name: "rclone", Message: fmt.Sprintf("source %s target_dir %s is an rclone remote but rclone is not on PATH", source.ID, source.TargetDir)}
+	}
+	return Check{Severity: SeverityInfo, Name: "rclone", Message: fmt.Sprintf("source %s target_dir is rclone remote %s (rclone at %s)", source.ID, source.TargetDir, location)}
+}
+
 func (c *Checker) inspectDir(path string) dirAccessResult {
 	if c.CheckDirAccess != nil {
 		return c.CheckDirAccess(path)
@@ -487,9 +712,27 @@ func hasEnabledSpotifySource(sources []config.Source) bool {
 	return false
 }
 
-func hasEnabledSpotifyDeemixSource(sources []config.Source) bool {
+func hasEnabledDeemixSource(sources []config.Source) bool {
 	for _, source := range sources {
-		if source.Enabled && source.Type == config.SourceTypeSpotify && source.Adapter.Kind == "deemix" {
+		if source.Enabled && source.Adapter.Kind == "deemix" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasEnabledAppleMusicSource(sources []config.Source) bool {
+	for _, source := range sources {
+		if source.Enabled && source.Type == config.SourceTypeAppleMusic {
+			return true
+		}
+	}
+	return false
+}
+
+func hasEnabledTidalSource(sources []config.Source) bool {
+	for _, source := range sources {
+		if source.Enabled && source.Type == config.SourceTypeTidal {
 			return true
 		}
 	}
@@ -497,6 +740,23 @@ func hasEnabledSpotifyDeemixSource(sources []config.Source) bool {
 }
 
 func (c *Checker) sharedSpotDLCredentialsCheck() (Check, bool) {
+	cfg, configPath, ok := c.readSpotDLConfig()
+	if !ok {
+		return Check{}, false
+	}
+
+	if !usesSharedSpotDLCredentials(cfg.ClientID, cfg.ClientSecret) {
+		return Check{}, false
+	}
+
+	return Check{
+		Severity: SeverityWarn,
+		Name:     "auth",
+		Message:  fmt.Sprintf("spotdl config at %s is using shared default Spotify credentials; set your own app client_id/client_secret to avoid API throttling", configPath),
+	}, true
+}
+
+func (c *Checker) readSpotDLConfig() (spotDLConfig, string, bool) {
 	readFile := c.ReadFile
 	if readFile == nil {
 		readFile = os.ReadFile
@@ -507,28 +767,19 @@ func (c *Checker) sharedSpotDLCredentialsCheck() (Check, bool) {
 	}
 	home, err := homeDir()
 	if err != nil {
-		return Check{}, false
+		return spotDLConfig{}, "", false
 	}
 	configPath := filepath.Join(home, ".spotdl", "config.json")
 	raw, err := readFile(configPath)
 	if err != nil {
-		return Check{}, false
+		return spotDLConfig{}, "", false
 	}
 
 	var cfg spotDLConfig
 	if err := json.Unmarshal(raw, &cfg); err != nil {
-		return Check{}, false
+		return spotDLConfig{}, "", false
 	}
-
-	if !usesSharedSpotDLCredentials(cfg.ClientID, cfg.ClientSecret) {
-		return Check{}, false
-	}
-
-	return Check{
-		Severity: SeverityWarn,
-		Name:     "auth",
-		Message:  fmt.Sprintf("spotdl config at %s is using shared default Spotify credentials; set your own app client_id/client_secret to avoid API throttling", configPath),
-	}, true
+	return cfg, configPath, true
 }
 
 func usesSharedSpotDLCredentials(clientID string, clientSecret string) bool {
@@ -615,13 +866,15 @@ func requiredBinaries(cfg config.Config, matrix map[string]dependencyMatrixRule)
 			seen["spotdl"] = dependency{
 				Key:        "spotdl",
 				Binary:     resolveSpotDLBinaryForDoctor(),
-				MinVersion: minVersionOrDefault(source.Adapter.MinVersion, "4.0.0"),
+				MinVersion: withMatrixMinVersion(matrix, "spotdl", minVersionOrDefault(source.Adapter.MinVersion, "4.0.0")),
+				Matrix:     matrixRulePointer(matrix, "spotdl"),
 			}
 		case "deemix":
 			seen["deemix"] = dependency{
 				Key:        "deemix",
 				Binary:     resolveDeemixBinaryForDoctor(),
-				MinVersion: minVersionOrDefault(source.Adapter.MinVersion, "0.1.0"),
+				MinVersion: withMatrixMinVersion(matrix, "deemix", minVersionOrDefault(source.Adapter.MinVersion, "0.1.0")),
+				Matrix:     matrixRulePointer(matrix, "deemix"),
 			}
 		case "scdl":
 			scdlMin := "3.0.0"
@@ -646,6 +899,32 @@ func requiredBinaries(cfg config.Config, matrix map[string]dependencyMatrixRule)
 				MinVersion: ytdlpMin,
 				Matrix:     matrixRulePointer(matrix, "yt-dlp"),
 			}
+		case "ytdlp":
+			ytdlpMin := "2024.1.0"
+			if hasYTDLPRule && strings.TrimSpace(ytdlpRule.MinVersion) != "" {
+				ytdlpMin = ytdlpRule.MinVersion
+			}
+			ytdlpMin = maxVersion(ytdlpMin, minVersionOrDefault(source.Adapter.MinVersion, ytdlpMin))
+			seen["yt-dlp"] = dependency{
+				Key:        "yt-dlp",
+				Binary:     resolveYTDLPBinaryForDoctor(),
+				MinVersion: ytdlpMin,
+				Matrix:     matrixRulePointer(matrix, "yt-dlp"),
+			}
+		case "tidal-dl":
+			seen["tidal-dl"] = dependency{
+				Key:        "tidal-dl",
+				Binary:     resolveTidalDLBinaryForDoctor(),
+				MinVersion: withMatrixMinVersion(matrix, "tidal-dl", minVersionOrDefault(source.Adapter.MinVersion, "2022.10.31")),
+				Matrix:     matrixRulePointer(matrix, "tidal-dl"),
+			}
+		case "gamdl":
+			seen["gamdl"] = dependency{
+				Key:        "gamdl",
+				Binary:     resolveGamdlBinaryForDoctor(),
+				MinVersion: withMatrixMinVersion(matrix, "gamdl", minVersionOrDefault(source.Adapter.MinVersion, "2.0.0")),
+				Matrix:     matrixRulePointer(matrix, "gamdl"),
+			}
 		case "scdl-freedl":
 			ytdlpMin := "0.0.0"
 			if hasYTDLPRule && strings.TrimSpace(ytdlpRule.MinVersion) != "" {
@@ -658,6 +937,30 @@ func requiredBinaries(cfg config.Config, matrix map[string]dependencyMatrixRule)
 				Matrix:     matrixRulePointer(matrix, "yt-dlp"),
 			}
 		}
+		// adapter.fallback tools only need to be present; a primary adapter
+		// of the same kind keeps its stricter entry.
+		for _, kind := range source.Adapter.Fallback {
+			switch kind {
+			case "spotdl":
+				if _, ok := seen["spotdl"]; !ok {
+					seen["spotdl"] = dependency{
+						Key:        "spotdl",
+						Binary:     resolveSpotDLBinaryForDoctor(),
+						MinVersion: withMatrixMinVersion(matrix, "spotdl", "4.0.0"),
+						Matrix:     matrixRulePointer(matrix, "spotdl"),
+					}
+				}
+			case "ytdlp":
+				if _, ok := seen["yt-dlp"]; !ok {
+					seen["yt-dlp"] = dependency{
+						Key:        "yt-dlp",
+						Binary:     resolveYTDLPBinaryForDoctor(),
+						MinVersion: withMatrixMinVersion(matrix, "yt-dlp", "2024.1.0"),
+						Matrix:     matrixRulePointer(matrix, "yt-dlp"),
+					}
+				}
+			}
+		}
 	}
 
 	result := make([]dependency, 0, len(seen))
@@ -667,6 +970,15 @@ func requiredBinaries(cfg config.Config, matrix map[string]dependencyMatrixRule)
 	return result
 }
 
+// withMatrixMinVersion raises minimum to the matrix min_version for key, if
+// the matrix sets one.
+func withMatrixMinVersion(matrix map[string]dependencyMatrixRule, key string, minimum string) string {
+	if rule, ok := matrix[key]; ok && strings.TrimSpace(rule.MinVersion) != "" {
+		return maxVersion(rule.MinVersion, minimum)
+	}
+	return minimum
+}
+
 func matrixRulePointer(matrix map[string]dependencyMatrixRule, key string) *dependencyMatrixRule {
 	rule, ok := matrix[key]
 	if !ok {
@@ -713,6 +1025,27 @@ func resolveDeemixBinaryForDoctor() string {
 	return "deemix"
 }
 
+func resolveTidalDLBinaryForDoctor() string {
+	if override := strings.TrimSpace(os.Getenv("UDL_TIDAL_DL_BIN")); override != "" {
+		return override
+	}
+	return "tidal-dl"
+}
+
+func resolveGamdlBinaryForDoctor() string {
+	if override := strings.TrimSpace(os.Getenv("UDL_GAMDL_BIN")); override != "" {
+		return override
+	}
+	return "gamdl"
+}
+
+func resolveYTDLPBinaryForDoctor() string {
+	if override := strings.TrimSpace(os.Getenv("UDL_YTDLP_BIN")); override != "" {
+		return override
+	}
+	return "yt-dlp"
+}
+
 func minVersionOrDefault(candidate string, fallback string) string {
 	if strings.TrimSpace(candidate) == "" {
 		return fallback
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/jaa/update-downloads/internal/auth"
 	"github.com/jaa/update-downloads/internal/config"
@@ -575,3 +576,218 @@ func TestDoctorSoundCloudCredentialNeedsRefresh(t *testing.T) {
 		t.Fatalf("expected stale soundcloud credential error, got %+v", report.Checks)
 	}
 }
+
+func TestDoctorRequiresYTDLPForYouTube(t *testing.T) {
+	t.Setenv("UDL_YTDLP_BIN", "")
+	cfg := soundcloudConfig()
+	cfg.Sources = []config.Source{
+		{
+			ID:        "yt-mix",
+			Type:      config.SourceTypeYouTube,
+			Enabled:   true,
+			TargetDir: "/tmp/music",
+			URL:       "https://music.youtube.com/playlist?list=PL123",
+			Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+		},
+	}
+	checker := &Checker{
+		LookPath:      func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		ReadVersion:   func(ctx context.Context, binary string) (string, error) { return "2023.12.30", nil },
+		Getenv:        func(key string) string { return "" },
+		CheckWritable: func(path string) error { return nil },
+	}
+
+	report := checker.Check(context.Background(), cfg)
+	if !report.HasErrors() {
+		t.Fatalf("expected outdated yt-dlp to be reported for youtube source, got %+v", report.Checks)
+	}
+}
+
+func TestDoctorChecksTidalDLBinaryAndTokenFile(t *testing.T) {
+	t.Setenv("UDL_TIDAL_DL_BIN", "")
+	cfg := soundcloudConfig()
+	cfg.Sources = []config.Source{
+		{
+			ID:        "tidal-favs",
+			Type:      config.SourceTypeTidal,
+			Enabled:   true,
+			TargetDir: "/tmp/music",
+			URL:       "https://tidal.com/browse/playlist/1234-abcd",
+			Adapter:   config.AdapterSpec{Kind: "tidal-dl"},
+		},
+	}
+	checker := &Checker{
+		LookPath:      func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		ReadVersion:   func(ctx context.Context, binary string) (string, error) { return "2022.01.21.1", nil },
+		Getenv:        func(key string) string { return "" },
+		CheckWritable: func(path string) error { return nil },
+		ResolveTidalTokenFile: func() (string, auth.CredentialStorageSource, error) {
+			return "/home/listener/.tidal-dl.token.json", auth.CredentialStorageSourceNone, auth.ErrTidalTokenNotFound
+		},
+	}
+
+	report := checker.Check(context.Background(), cfg)
+	if !hasErrorContaining(report, "tidal-dl version 2022.01.21 is below minimum 2022.10.31") {
+		t.Fatalf("expected outdated tidal-dl to be reported, got %+v", report.Checks)
+	}
+	if !hasErrorContaining(report, "tidal-dl token file not found at /home/listener/.tidal-dl.token.json") {
+		t.Fatalf("expected missing token check, got %+v", report.Checks)
+	}
+}
+
+func TestDoctorDeezerSourceChecksARLWithoutSpotifyCredentials(t *testing.T) {
+	cfg := soundcloudConfig()
+	cfg.Sources = []config.Source{
+		{
+			ID:        "deezer-mix",
+			Type:      config.SourceTypeDeezer,
+			Enabled:   true,
+			TargetDir: "/tmp/music",
+			URL:       "https://www.deezer.com/playlist/908622995",
+			StateFile: "deezer-mix.sync.deezer",
+			Adapter:   config.AdapterSpec{Kind: "deemix"},
+		},
+	}
+	checker := &Checker{
+		LookPath:      func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		ReadVersion:   func(ctx context.Context, binary string) (string, error) { return "1.0.0", nil },
+		Getenv:        func(key string) string { return "" },
+		CheckWritable: func(path string) error { return nil },
+		ResolveDeemixWithSource: func() (string, auth.CredentialStorageSource, error) {
+			return "", auth.CredentialStorageSourceNone, auth.ErrDeemixARLNotFound
+		},
+		ResolveSpotifyWithSource: func() (auth.SpotifyCredentials, auth.CredentialStorageSource, error) {
+			t.Fatalf("deezer sources must not check spotify credentials")
+			return auth.SpotifyCredentials{}, auth.CredentialStorageSourceNone, nil
+		},
+	}
+
+	report := checker.Check(context.Background(), cfg)
+	if !hasErrorContaining(report, "deemix sources require Deezer ARL") {
+		t.Fatalf("expected missing ARL check, got %+v", report.Checks)
+	}
+}
+
+func TestDoctorChecksGamdlBinaryAndAppleMusicCookies(t *testing.T) {
+	t.Setenv("UDL_GAMDL_BIN", "")
+	cfg := soundcloudConfig()
+	cfg.Sources = []config.Source{
+		{
+			ID:        "am-chill",
+			Type:      config.SourceTypeAppleMusic,
+			Enabled:   true,
+			TargetDir: "/tmp/music",
+			URL:       "https://music.apple.com/us/playlist/chill/pl.u-abc123",
+			StateFile: "am-chill.sync.applemusic",
+			Adapter:   config.AdapterSpec{Kind: "gamdl"},
+		},
+	}
+	tokenReads := 0
+	checker := &Checker{
+		LookPath:      func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		ReadVersion:   func(ctx context.Context, binary string) (string, error) { return "gamdl, version 1.9.4", nil },
+		Getenv:        func(key string) string { return "" },
+		CheckWritable: func(path string) error { return nil },
+		ResolveAppleMusicCookies: func() (string, auth.CredentialStorageSource, error) {
+			return "/home/listener/.config/udl/apple-music-cookies.txt", auth.CredentialStorageSourceCookiesFile, nil
+		},
+		ReadAppleMusicUserToken: func(path string) (string, error) {
+			tokenReads++
+			return "", auth.ErrAppleMusicMediaUserTokenNotFound
+		},
+	}
+
+	report := checker.Check(context.Background(), cfg)
+	if !hasErrorContaining(report, "gamdl version 1.9.4 is below minimum 2.0.0") {
+		t.Fatalf("expected outdated gamdl to be reported, got %+v", report.Checks)
+	}
+	if !hasErrorContaining(report, "has no media-user-token") || tokenReads != 1 {
+		t.Fatalf("expected missing media-user-token check, got %+v", report.Checks)
+	}
+
+	checker.ResolveAppleMusicCookies = func() (string, auth.CredentialStorageSource, error) {
+		return "/home/listener/.config/udl/apple-music-cookies.txt", auth.CredentialStorageSourceNone, auth.ErrAppleMusicCookiesNotFound
+	}
+	report = checker.Check(context.Background(), cfg)
+	if !hasErrorContaining(report, "apple music cookies file not found at /home/listener/.config/udl/apple-music-cookies.txt") {
+		t.Fatalf("expected missing cookies check, got %+v", report.Checks)
+	}
+}
+
+func TestDoctorWarnsWhenLibraryBelongsToAnotherUser(t *testing.T) {
+	musicDir := t.TempDir()
+	cfg := soundcloudConfig()
+	cfg.Defaults.StateDir = t.TempDir()
+	cfg.Sources[0].TargetDir = musicDir
+	otherUID := os.Geteuid() + 4242
+	checker := &Checker{
+		LookPath:                  func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		ReadVersion:               func(ctx context.Context, binary string) (string, error) { return "scdl 3.0.0 yt-dlp 2026.2.4", nil },
+		ResolveSoundCloudClientID: func() (string, auth.CredentialStorageSource, error) { return "client-id", auth.CredentialStorageSourceKeychain, nil },
+		CheckDirAccess:            func(path string) dirAccessResult { return dirAccessResult{} },
+		Geteuid:                   func() int { return otherUID },
+	}
+
+	report := checker.Check(context.Background(), cfg)
+	want := fmt.Sprintf("source sc-a target_dir %s is owned by uid %d but udl runs as uid %d", musicDir, os.Geteuid(), otherUID)
+	if !hasWarnContaining(report, want) {
+		t.Fatalf("expected ownership warning %q, got %+v", want, report.Checks)
+	}
+
+	cfg.Defaults.FileOwnership = config.FileOwnership{Owner: fmt.Sprint(os.Geteuid() + 1)}
+	report = checker.Check(context.Background(), cfg)
+	if !hasWarnContaining(report, "only root can hand files to another user") {
+		t.Fatalf("expected file_ownership needs-root warning, got %+v", report.Checks)
+	}
+
+	checker.Geteuid = func() int { return 0 }
+	cfg.Defaults.FileOwnership = config.FileOwnership{Owner: fmt.Sprint(os.Geteuid()), Umask: "0002"}
+	report = checker.Check(context.Background(), cfg)
+	for _, check := range report.Checks {
+		if check.Name == "ownership" && check.Severity != SeverityInfo {
+			t.Fatalf("expected file_ownership for the library owner to resolve the warning, got %+v", report.Checks)
+		}
+	}
+	if !hasInfoContaining(report, "umask 0002") {
+		t.Fatalf("expected umask info, got %+v", report.Checks)
+	}
+}
+
+func TestDoctorSoundCloudOAuthTokenExpiryAndProbe(t *testing.T) {
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	token := auth.SoundCloudOAuthToken{AccessToken: "access", ExpiresAt: now.Add(5 * time.Minute).Unix()}
+	checker := &Checker{
+		Now: func() time.Time { return now },
+		ResolveSoundCloudOAuth: func() (auth.SoundCloudOAuthToken, auth.CredentialStorageSource, error) {
+			return token, auth.CredentialStorageSourceKeychain, nil
+		},
+		ResolveSoundCloudOAuthApp: func() (auth.SoundCloudAppCredentials, error) {
+			return auth.SoundCloudAppCredentials{ClientID: "app", ClientSecret: "secret"}, nil
+		},
+	}
+
+	check, ok := checker.soundCloudOAuthCheck(context.Background())
+	if !ok || check.Severity != SeverityWarn || !strings.Contains(check.Message, "cannot be refreshed (no refresh token stored)") {
+		t.Fatalf("expected near-expiry warning without refresh token, got %+v", check)
+	}
+
+	token.RefreshToken = "refresh"
+	check, _ = checker.soundCloudOAuthCheck(context.Background())
+	if check.Severity != SeverityInfo || !strings.Contains(check.Message, "udl refreshes it on the next sync") {
+		t.Fatalf("expected refreshable near-expiry info, got %+v", check)
+	}
+
+	token.ExpiresAt = now.Add(time.Hour).Unix()
+	checker.ProbeSoundCloudOAuth = func(ctx context.Context, accessToken string) error { return errSoundCloudOAuthRejected }
+	check, _ = checker.soundCloudOAuthCheck(context.Background())
+	if check.Severity != SeverityError || !strings.Contains(check.Message, "rejected") {
+		t.Fatalf("expected rejected token error, got %+v", check)
+	}
+
+	checker.ResolveSoundCloudOAuth = func() (auth.SoundCloudOAuthToken, auth.CredentialStorageSource, error) {
+		return auth.SoundCloudOAuthToken{}, auth.CredentialStorageSourceNone, auth.ErrSoundCloudOAuthTokenNotFound
+	}
+	if _, ok := checker.soundCloudOAuthCheck(context.Background()); ok {
+		t.Fatalf("expected no check without a stored token")
+	}
+}
@@ -0,0 +1,443 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/tools"
+)
+
+const (
+	FixStatusFixed   = "fixed"
+	FixStatusPlanned = "planned"
+	FixStatusSkipped = "skipped"
+	FixStatusFailed  = "failed"
+)
+
+// FixResult is one remediation attempted by Fix.
+type FixResult struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// FixOptions controls Fix. DryRun reports every remediation as planned
+// without touching the filesystem or running pip.
+type FixOptions struct {
+	DryRun bool
+}
+
+// pipTools maps dependency keys to the PyPI package udl installs for them and
+// the env var that points udl at a custom build instead. deemix is absent on
+// purpose: the PyPI release lacks the fixes udl relies on, so it is left to a
+// manual install.
+var pipTools = map[string]struct {
+	Package     string
+	OverrideEnv string
+}{
+	"spotdl":   {Package: "spotdl", OverrideEnv: "UDL_SPOTDL_BIN"},
+	"scdl":     {Package: "scdl"},
+	"yt-dlp":   {Package: "yt-dlp", OverrideEnv: "UDL_YTDLP_BIN"},
+	"tidal-dl": {Package: "tidal-dl", OverrideEnv: "UDL_TIDAL_DL_BIN"},
+	"gamdl":    {Package: "gamdl", OverrideEnv: "UDL_GAMDL_BIN"},
+}
+
+// Fix remediates what it safely can for the enabled sources: it creates
+// missing state and target directories, makes unwritable ones writable when
+// the current user owns them (or udl runs under sudo), installs missing or
+// outdated pip tools into per-tool venvs under ~/.venvs, and writes the
+// user's Spotify app credentials into ~/.spotdl/config.json for spotdl.
+// Anything it cannot fix is reported as skipped with the manual step.
+func (c *Checker) Fix(ctx context.Context, cfg config.Config, opts FixOptions) []FixResult {
+	results := []FixResult{}
+	results = append(results, c.fixDirectories(cfg, opts)...)
+	results = append(results, c.fixPipTools(ctx, cfg, opts)...)
+	if result, ok := c.fixSpotDLConfig(cfg, opts); ok {
+		results = append(results, result)
+	}
+	return results
+}
+
+func (c *Checker) fixDirectories(cfg config.Config, opts FixOptions) []FixResult {
+	type dirTarget struct {
+		path  string
+		label string
+	}
+	targets := []dirTarget{}
+	seen := map[string]struct{}{}
+	add := func(path string, label string) {
+		if strings.TrimSpace(path) == "" {
+			return
+		}
+		if _, ok := seen[path]; ok {
+			return
+		}
+		seen[path] = struct{}{}
+		targets = append(targets, dirTarget{path: path, label: label})
+	}
+	if stateDir, err := config.ExpandPath(cfg.Defaults.StateDir); err == nil {
+		add(stateDir, "state_dir")
+	}
+	for _, source := range cfg.Sources {
+		if !source.Enabled {
+			continue
+		}
+		if targetDir, err := config.ExpandTargetDir(source.TargetDir); err == nil {
+			add(targetDir, fmt.Sprintf("source %s target_dir", source.ID))
+		}
+		if strings.TrimSpace(source.StateFile) != "" {
+			if stateFile, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile); err == nil {
+				add(filepath.Dir(stateFile), fmt.Sprintf("source %s state directory", source.ID))
+			}
+		}
+	}
+
+	results := []FixResult{}
+	for _, target := range targets {
+		info, err := os.Stat(target.path)
+		if errors.Is(err, os.ErrNotExist) {
+			if opts.DryRun {
+				results = append(results, FixResult{Name: "filesystem", Status: FixStatusPlanned, Message: fmt.Sprintf("create %s %s", target.label, target.path)})
+				continue
+			}
+			if err := c.mkdirAll(target.path, 0o755); err != nil {
+				results = append(results, FixResult{Name: "filesystem", Status: FixStatusFailed, Message: fmt.Sprintf("create %s %s: %v", target.label, target.path, err)})
+				continue
+			}
+			results = append(results, FixResult{Name: "filesystem", Status: FixStatusFixed, Message: fmt.Sprintf("created %s %s", target.label, target.path)})
+			continue
+		}
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		if access := c.inspectDir(target.path); access.Err == nil {
+			continue
+		}
+		results = append(results, c.fixUnwritableDir(target.path, target.label, info, opts))
+	}
+	return results
+}
+
+// fixUnwritableDir grants the owner rwx when the current user owns path, and
+// hands path to the invoking user when udl runs as root under sudo. It never
+// changes permissions for other users or recurses into the directory.
+func (c *Checker) fixUnwritableDir(path string, label string, info os.FileInfo, opts FixOptions) FixResult {
+	euid := c.geteuid()
+	owner, known := fileOwner(info)
+	switch {
+	case known && owner == euid && euid != 0:
+		mode := info.Mode().Perm() | 0o700
+		if opts.DryRun {
+			return FixResult{Name: "filesystem", Status: FixStatusPlanned, Message: fmt.Sprintf("chmod %s %s to %04o", label, path, mode)}
+		}
+		if err := c.chmod(path, mode); err != nil {
+			return FixResult{Name: "filesystem", Status: FixStatusFailed, Message: fmt.Sprintf("chmod %s %s: %v", label, path, err)}
+		}
+		return FixResult{Name: "filesystem", Status: FixStatusFixed, Message: fmt.Sprintf("made %s %s writable (mode %04o)", label, path, mode)}
+	case euid == 0:
+		uid, uidErr := strconv.Atoi(strings.TrimSpace(c.getenv("SUDO_UID")))
+		gid, gidErr := strconv.Atoi(strings.TrimSpace(c.getenv("SUDO_GID")))
+		if uidErr != nil || gidErr != nil {
+			return FixResult{Name: "filesystem", Status: FixStatusSkipped, Message: fmt.Sprintf("%s %s is not writable; run `udl doctor --fix` with sudo as the user that syncs, or chown it yourself", label, path)}
+		}
+		if opts.DryRun {
+			return FixResult{Name: "filesystem", Status: FixStatusPlanned, Message: fmt.Sprintf("chown %s %s to %d:%d", label, path, uid, gid)}
+		}
+		if err := c.chown(path, uid, gid); err != nil {
+			return FixResult{Name: "filesystem", Status: FixStatusFailed, Message: fmt.Sprintf("chown %s %s: %v", label, path, err)}
+		}
+		return FixResult{Name: "filesystem", Status: FixStatusFixed, Message: fmt.Sprintf("changed owner of %s %s to %d:%d", label, path, uid, gid)}
+	default:
+		return FixResult{Name: "filesystem", Status: FixStatusSkipped, Message: fmt.Sprintf("%s %s is owned by another user; run `sudo udl doctor --fix` or `sudo chown $(id -u):$(id -g) %s`", label, path, path)}
+	}
+}
+
+func (c *Checker) fixPipTools(ctx context.Context, cfg config.Config, opts FixOptions) []FixResult {
+	matrix, _ := c.dependencyMatrix(ctx, cfg)
+	deps := requiredBinaries(cfg, matrix)
+	sort.Slice(deps, func(i, j int) bool { return deps[i].Key < deps[j].Key })
+
+	results := []FixResult{}
+	for _, dep := range deps {
+		tool, ok := pipTools[dep.Key]
+		if !ok {
+			continue
+		}
+		if tool.OverrideEnv != "" && strings.TrimSpace(c.getenv(tool.OverrideEnv)) != "" {
+			// A custom build is configured; leave it alone.
+			continue
+		}
+		pkg := tool.Package
+		location, err := c.LookPath(dep.Binary)
+		missing := err != nil
+		outdated := false
+		if !missing {
+			if output, versionErr := c.ReadVersion(ctx, dep.Binary); versionErr == nil {
+				if version, parseErr := extractVersion(output); parseErr == nil {
+					outdated = compareVersions(version, dep.MinVersion) < 0
+				}
+			}
+		}
+		if !missing && !outdated {
+			continue
+		}
+
+		venvDir, err := c.managedVenvDir(dep.Key)
+		if err != nil {
+			results = append(results, FixResult{Name: "dependency", Status: FixStatusFailed, Message: fmt.Sprintf("%s: %v", dep.Key, err)})
+			continue
+		}
+		venvBin := filepath.Join(venvDir, "bin", dep.Key)
+		// udl prefers the managed spotdl venv over PATH, so an outdated spotdl
+		// elsewhere is fixed by installing there. Other tools found outside
+		// udl's venvs belong to the user.
+		if outdated && dep.Key != "spotdl" && filepath.Clean(location) != venvBin && !c.isManagedLink(cfg, location, venvBin) {
+			results = append(results, FixResult{
+				Name:    "dependency",
+				Status:  FixStatusSkipped,
+				Message: fmt.Sprintf("%s at %s is below minimum %s and was not installed by udl; upgrade it with `pip install --upgrade '%s'`", dep.Key, location, dep.MinVersion, pipRequirement(pkg, dep)),
+			})
+			continue
+		}
+		results = append(results, c.installPipTool(ctx, cfg, dep, pkg, venvDir, missing, opts))
+	}
+	return results
+}
+
+// installPipTool installs pkg into its own venv. spotdl is picked up from
+// ~/.venvs/udl-spotdl directly; other tools are linked into
+// <state_dir>/tools/bin, which udl puts on PATH.
+func (c *Checker) installPipTool(ctx context.Context, cfg config.Config, dep dependency, pkg string, venvDir string, missing bool, opts FixOptions) FixResult {
+	verb := "upgrade"
+	if missing {
+		verb = "install"
+	}
+	requirement := pipRequirement(pkg, dep)
+	if opts.DryRun {
+		return FixResult{Name: "dependency", Status: FixStatusPlanned, Message: fmt.Sprintf("%s %s into %s", verb, requirement, venvDir)}
+	}
+
+	python := filepath.Join(venvDir, "bin", "python")
+	if _, err := os.Stat(python); err != nil {
+		system, lookErr := c.LookPath("python3")
+		if lookErr != nil {
+			return FixResult{Name: "dependency", Status: FixStatusSkipped, Message: fmt.Sprintf("%s %s needs python3 in PATH to create %s", verb, dep.Key, venvDir)}
+		}
+		if output, err := c.runFixCommand(ctx, system, "-m", "venv", venvDir); err != nil {
+			return FixResult{Name: "dependency", Status: FixStatusFailed, Message: fmt.Sprintf("create venv %s: %v: %s", venvDir, err, lastLine(output))}
+		}
+	}
+	if output, err := c.runFixCommand(ctx, python, "-m", "pip", "install", "--upgrade", requirement); err != nil {
+		return FixResult{Name: "dependency", Status: FixStatusFailed, Message: fmt.Sprintf("pip %s %s: %v: %s", verb, requirement, err, lastLine(output))}
+	}
+
+	installed := filepath.Join(venvDir, "bin", dep.Key)
+	if dep.Key == "spotdl" {
+		return FixResult{Name: "dependency", Status: FixStatusFixed, Message: fmt.Sprintf("%sed %s into %s", strings.TrimSuffix(verb, "e"), requirement, venvDir)}
+	}
+	binDir, err := tools.ManagedBinDir(cfg.Defaults.StateDir)
+	if err != nil {
+		return FixResult{Name: "dependency", Status: FixStatusFailed, Message: fmt.Sprintf("link %s: %v", dep.Key, err)}
+	}
+	if err := c.mkdirAll(binDir, 0o755); err != nil {
+		return FixResult{Name: "dependency", Status: FixStatusFailed, Message: fmt.Sprintf("link %s: %v", dep.Key, err)}
+	}
+	link := filepath.Join(binDir, dep.Key)
+	_ = os.Remove(link)
+	if err := c.symlink(installed, link); err != nil {
+		return FixResult{Name: "dependency", Status: FixStatusFailed, Message: fmt.Sprintf("link %s into %s: %v", dep.Key, binDir, err)}
+	}
+	return FixResult{Name: "dependency", Status: FixStatusFixed, Message: fmt.Sprintf("%sed %s into %s and linked it into %s", strings.TrimSuffix(verb, "e"), requirement, venvDir, binDir)}
+}
+
+// fixSpotDLConfig writes the user's own Spotify app credentials into spotdl's
+// config when it is missing or still uses the shared defaults that get
+// throttled. spotdl only reads credentials from that file, so they are stored
+// there in plaintext with owner-only permissions; other keys are preserved.
+func (c *Checker) fixSpotDLConfig(cfg config.Config, opts FixOptions) (FixResult, bool) {
+	usesSpotDL := false
+	for _, source := range cfg.Sources {
+		if source.Enabled && source.Adapter.Kind == "spotdl" {
+			usesSpotDL = true
+			break
+		}
+	}
+	if !usesSpotDL {
+		return FixResult{}, false
+	}
+	current, _, exists := c.readSpotDLConfig()
+	if exists && strings.TrimSpace(current.ClientID) != "" && strings.TrimSpace(current.ClientSecret) != "" &&
+		!usesSharedSpotDLCredentials(current.ClientID, current.ClientSecret) {
+		return FixResult{}, false
+	}
+
+	resolve := c.ResolveSpotifyWithSource
+	if resolve == nil {
+		resolve = auth.ResolveSpotifyCredentialsWithSource
+	}
+	creds, source, err := resolve()
+	if err != nil || source == auth.CredentialStorageSourceSpotDL ||
+		strings.TrimSpace(creds.ClientID) == "" || strings.TrimSpace(creds.ClientSecret) == "" ||
+		usesSharedSpotDLCredentials(creds.ClientID, creds.ClientSecret) {
+		return FixResult{
+			Name:    "auth",
+			Status:  FixStatusSkipped,
+			Message: "spotdl needs your own Spotify app credentials; store them with `udl auth set spotify_client_id` and `udl auth set spotify_client_secret`, then rerun `udl doctor --fix`",
+		}, true
+	}
+
+	homeDir := c.HomeDir
+	if homeDir == nil {
+		homeDir = os.UserHomeDir
+	}
+	home, err := homeDir()
+	if err != nil {
+		return FixResult{Name: "auth", Status: FixStatusFailed, Message: fmt.Sprintf("resolve home directory: %v", err)}, true
+	}
+	configPath := filepath.Join(home, ".spotdl", "config.json")
+	if opts.DryRun {
+		return FixResult{Name: "auth", Status: FixStatusPlanned, Message: fmt.Sprintf("write Spotify app credentials from %s to %s", source, configPath)}, true
+	}
+
+	payload := map[string]any{}
+	readFile := c.ReadFile
+	if readFile == nil {
+		readFile = os.ReadFile
+	}
+	if raw, err := readFile(configPath); err == nil {
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return FixResult{Name: "auth", Status: FixStatusFailed, Message: fmt.Sprintf("%s is not valid JSON; fix or remove it first: %v", configPath, err)}, true
+		}
+	}
+	payload["client_id"] = strings.TrimSpace(creds.ClientID)
+	payload["client_secret"] = strings.TrimSpace(creds.ClientSecret)
+	encoded, err := json.MarshalIndent(payload, "", "    ")
+	if err != nil {
+		return FixResult{Name: "auth", Status: FixStatusFailed, Message: err.Error()}, true
+	}
+	if err := c.mkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		return FixResult{Name: "auth", Status: FixStatusFailed, Message: fmt.Sprintf("create %s: %v", filepath.Dir(configPath), err)}, true
+	}
+	if err := c.writeFile(configPath, append(encoded, '\n'), 0o600); err != nil {
+		return FixResult{Name: "auth", Status: FixStatusFailed, Message: fmt.Sprintf("write %s: %v", configPath, err)}, true
+	}
+	return FixResult{
+		Name:    "auth",
+		Status:  FixStatusFixed,
+		Message: fmt.Sprintf("wrote Spotify app credentials from %s to %s (plaintext, mode 0600; spotdl reads only this file)", source, configPath),
+	}, true
+}
+
+func pipRequirement(pkg string, dep dependency) string {
+	requirement := pkg
+	if minimum := strings.TrimSpace(dep.MinVersion); minimum != "" && minimum != "0.0.0" {
+		requirement += ">=" + minimum
+	}
+	if dep.Matrix != nil && strings.TrimSpace(dep.Matrix.MaxVersionExclusive) != "" {
+		if requirement == pkg {
+			requirement += "<" + dep.Matrix.MaxVersionExclusive
+		} else {
+			requirement += ",<" + dep.Matrix.MaxVersionExclusive
+		}
+	}
+	return requirement
+}
+
+func (c *Checker) managedVenvDir(key string) (string, error) {
+	homeDir := c.HomeDir
+	if homeDir == nil {
+		homeDir = os.UserHomeDir
+	}
+	home, err := homeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve home directory: %w", err)
+	}
+	return filepath.Join(home, ".venvs", "udl-"+key), nil
+}
+
+// isManagedLink reports whether location is the <state_dir>/tools/bin link a
+// previous fix created for venvBin.
+func (c *Checker) isManagedLink(cfg config.Config, location string, venvBin string) bool {
+	binDir, err := tools.ManagedBinDir(cfg.Defaults.StateDir)
+	if err != nil || filepath.Dir(filepath.Clean(location)) != binDir {
+		return false
+	}
+	target, err := os.Readlink(location)
+	return err == nil && filepath.Clean(target) == venvBin
+}
+
+func (c *Checker) runFixCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if c.RunCommand != nil {
+		return c.RunCommand(ctx, name, args...)
+	}
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+func (c *Checker) mkdirAll(path string, perm os.FileMode) error {
+	if c.MkdirAll != nil {
+		return c.MkdirAll(path, perm)
+	}
+	return os.MkdirAll(path, perm)
+}
+
+func (c *Checker) writeFile(path string, data []byte, perm os.FileMode) error {
+	if c.WriteFile != nil {
+		return c.WriteFile(path, data, perm)
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file; credentials must end up
+	// owner-only either way.
+	return os.Chmod(path, perm)
+}
+
+func (c *Checker) chmod(path string, mode os.FileMode) error {
+	if c.Chmod != nil {
+		return c.Chmod(path, mode)
+	}
+	return os.Chmod(path, mode)
+}
+
+func (c *Checker) chown(path string, uid int, gid int) error {
+	if c.Chown != nil {
+		return c.Chown(path, uid, gid)
+	}
+	return os.Chown(path, uid, gid)
+}
+
+func (c *Checker) symlink(target string, link string) error {
+	if c.Symlink != nil {
+		return c.Symlink(target, link)
+	}
+	return os.Symlink(target, link)
+}
+
+func (c *Checker) geteuid() int {
+	if c.Geteuid != nil {
+		return c.Geteuid()
+	}
+	return os.Geteuid()
+}
+
+func (c *Checker) getenv(key string) string {
+	if c.Getenv != nil {
+		return c.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,169 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/auth"
+)
+
+func fixChecker(home string, versions map[string]string) *Checker {
+	return &Checker{
+		LookPath: func(name string) (string, error) {
+			if name == "python3" {
+				return "/usr/bin/python3", nil
+			}
+			if _, ok := versions[name]; ok {
+				return "/usr/local/bin/" + name, nil
+			}
+			return "", errors.New("not found")
+		},
+		ReadVersion: func(_ context.Context, name string) (string, error) {
+			return versions[name], nil
+		},
+		Getenv:  func(string) string { return "" },
+		HomeDir: func() (string, error) { return home, nil },
+	}
+}
+
+func TestFixCreatesMissingDirectories(t *testing.T) {
+	root := t.TempDir()
+	cfg := soundcloudConfig()
+	cfg.Defaults.StateDir = filepath.Join(root, "state")
+	cfg.Sources[0].TargetDir = filepath.Join(root, "music")
+	checker := fixChecker(root, map[string]string{"scdl": "3.1.0", "yt-dlp": "2025.01.15"})
+
+	planned := checker.Fix(context.Background(), cfg, FixOptions{DryRun: true})
+	if len(planned) != 2 || planned[0].Status != FixStatusPlanned || planned[1].Status != FixStatusPlanned {
+		t.Fatalf("expected two planned directory fixes, got %+v", planned)
+	}
+	if _, err := os.Stat(cfg.Defaults.StateDir); !os.IsNotExist(err) {
+		t.Fatalf("dry run must not create directories, got %v", err)
+	}
+
+	results := checker.Fix(context.Background(), cfg, FixOptions{})
+	if len(results) != 2 || results[0].Status != FixStatusFixed || results[1].Status != FixStatusFixed {
+		t.Fatalf("expected two fixed directories, got %+v", results)
+	}
+	for _, path := range []string{cfg.Defaults.StateDir, cfg.Sources[0].TargetDir} {
+		if info, err := os.Stat(path); err != nil || !info.IsDir() {
+			t.Fatalf("expected %s to be created, got %v", path, err)
+		}
+	}
+}
+
+func TestFixInstallsMissingPipToolIntoManagedVenv(t *testing.T) {
+	root := t.TempDir()
+	cfg := soundcloudConfig()
+	cfg.Defaults.StateDir = root
+	cfg.Sources[0].TargetDir = root
+	checker := fixChecker(root, map[string]string{"yt-dlp": "2025.01.15"})
+	commands := []string{}
+	checker.RunCommand = func(_ context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		return nil, nil
+	}
+	links := map[string]string{}
+	checker.Symlink = func(target string, link string) error {
+		links[link] = target
+		return nil
+	}
+
+	results := checker.Fix(context.Background(), cfg, FixOptions{})
+	if len(results) != 1 || results[0].Status != FixStatusFixed {
+		t.Fatalf("expected one fixed dependency, got %+v", results)
+	}
+	venv := filepath.Join(root, ".venvs", "udl-scdl")
+	want := []string{
+		"/usr/bin/python3 -m venv " + venv,
+		filepath.Join(venv, "bin", "python") + " -m pip install --upgrade scdl>=3.0.0,<4.0.0",
+	}
+	if strings.Join(commands, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected commands:\n%s", strings.Join(commands, "\n"))
+	}
+	if target := links[filepath.Join(root, "tools", "bin", "scdl")]; target != filepath.Join(venv, "bin", "scdl") {
+		t.Fatalf("expected scdl to be linked into tools/bin, got %v", links)
+	}
+}
+
+func TestFixSkipsOutdatedToolNotManagedByUDL(t *testing.T) {
+	root := t.TempDir()
+	cfg := soundcloudConfig()
+	cfg.Defaults.StateDir = root
+	cfg.Sources[0].TargetDir = root
+	checker := fixChecker(root, map[string]string{"scdl": "2.9.0", "yt-dlp": "2025.01.15"})
+	checker.RunCommand = func(context.Context, string, ...string) ([]byte, error) {
+		t.Fatalf("pip must not run for a user-installed tool")
+		return nil, nil
+	}
+
+	results := checker.Fix(context.Background(), cfg, FixOptions{})
+	if len(results) != 1 || results[0].Status != FixStatusSkipped || !strings.Contains(results[0].Message, "pip install --upgrade 'scdl>=3.0.0,<4.0.0'") {
+		t.Fatalf("expected skipped upgrade with a pip hint, got %+v", results)
+	}
+}
+
+func TestFixWritesSpotDLConfigWithUserCredentials(t *testing.T) {
+	root := t.TempDir()
+	cfg := spotifyConfig()
+	cfg.Defaults.StateDir = root
+	cfg.Sources[0].TargetDir = root
+	configPath := filepath.Join(root, ".spotdl", "config.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"output": "{title}.{output-ext}"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	checker := fixChecker(root, map[string]string{"spotdl": "4.2.0"})
+	checker.ResolveSpotifyWithSource = func() (auth.SpotifyCredentials, auth.CredentialStorageSource, error) {
+		return auth.SpotifyCredentials{ClientID: "my-id", ClientSecret: "my-secret"}, auth.CredentialStorageSourceStore, nil
+	}
+
+	results := checker.Fix(context.Background(), cfg, FixOptions{})
+	if len(results) != 1 || results[0].Status != FixStatusFixed || !strings.Contains(results[0].Message, "plaintext") {
+		t.Fatalf("expected spotdl config fix, got %+v", results)
+	}
+	raw, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := map[string]string{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["client_id"] != "my-id" || payload["client_secret"] != "my-secret" || payload["output"] != "{title}.{output-ext}" {
+		t.Fatalf("unexpected config: %v", payload)
+	}
+	if info, err := os.Stat(configPath); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("expected mode 0600, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	if again := checker.Fix(context.Background(), cfg, FixOptions{}); len(again) != 0 {
+		t.Fatalf("expected no fixes once spotdl has user credentials, got %+v", again)
+	}
+}
+
+func TestFixSkipsSpotDLConfigWithoutUserCredentials(t *testing.T) {
+	root := t.TempDir()
+	cfg := spotifyConfig()
+	cfg.Defaults.StateDir = root
+	cfg.Sources[0].TargetDir = root
+	checker := fixChecker(root, map[string]string{"spotdl": "4.2.0"})
+	checker.ResolveSpotifyWithSource = func() (auth.SpotifyCredentials, auth.CredentialStorageSource, error) {
+		return auth.SpotifyCredentials{}, auth.CredentialStorageSourceNone, errors.New("missing")
+	}
+
+	results := checker.Fix(context.Background(), cfg, FixOptions{})
+	if len(results) != 1 || results[0].Status != FixStatusSkipped || !strings.Contains(results[0].Message, "udl auth set spotify_client_id") {
+		t.Fatalf("expected skipped spotdl config fix, got %+v", results)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".spotdl", "config.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected no config to be written, got %v", err)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package doctor
+
+import "os"
+
+// fileOwner is not available on Windows, where Fix leaves permissions alone.
+func fileOwner(info os.FileInfo) (int, bool) {
+	return 0, false
+}
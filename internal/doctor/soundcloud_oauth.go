@@ -0,0 +1,143 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const soundCloudOAuthProbeTimeout = 10 * time.Second
+
+var (
+	soundCloudOAuthProbeHTTPClient = &http.Client{}
+	soundCloudOAuthProbeURL        = "https://api.soundcloud.com/me"
+
+	errSoundCloudOAuthRejected = errors.New("soundcloud rejected the oauth token")
+)
+
+// soundCloudOAuthCheck reports on the stored SoundCloud OAuth session used by
+// sources without a cookies_file: whether it is about to expire, whether udl
+// can refresh it, and, unless --offline, whether SoundCloud still accepts it.
+// No check is returned when no session is stored.
+func (c *Checker) soundCloudOAuthCheck(ctx context.Context) (Check, bool) {
+	resolve := c.ResolveSoundCloudOAuth
+	if resolve == nil {
+		resolve = auth.ResolveSoundCloudOAuthTokenWithSource
+	}
+	token, source, err := resolve()
+	if errors.Is(err, auth.ErrSoundCloudOAuthTokenNotFound) {
+		return Check{}, false
+	}
+	if err != nil {
+		return Check{Severity: SeverityWarn, Name: "auth", Message: fmt.Sprintf("unable to read SoundCloud oauth token: %v", err)}, true
+	}
+
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+	if token.Expired(now, auth.SoundCloudOAuthRefreshMargin) {
+		expiry := time.Unix(token.ExpiresAt, 0).UTC().Format(time.RFC3339)
+		verb := "expires"
+		if !now.Before(time.Unix(token.ExpiresAt, 0)) {
+			verb = "expired"
+		}
+		if reason := c.soundCloudOAuthRefreshBlocker(token); reason != "" {
+			return Check{
+				Severity: SeverityWarn,
+				Name:     "auth",
+				Message:  fmt.Sprintf("SoundCloud oauth token %s at %s and cannot be refreshed (%s); SoundCloud sources will run signed out until you sign in again", verb, expiry, reason),
+			}, true
+		}
+		return Check{
+			Severity: SeverityInfo,
+			Name:     "auth",
+			Message:  fmt.Sprintf("SoundCloud oauth token %s at %s; udl refreshes it on the next sync", verb, expiry),
+		}, true
+	}
+
+	if c.ProbeSoundCloudOAuth != nil {
+		if err := c.ProbeSoundCloudOAuth(ctx, token.AccessToken); err != nil {
+			if errors.Is(err, errSoundCloudOAuthRejected) {
+				return Check{
+					Severity: SeverityError,
+					Name:     "auth",
+					Message:  "SoundCloud oauth token was rejected by SoundCloud; sign in again and store the new token",
+				}, true
+			}
+			return Check{Severity: SeverityWarn, Name: "auth", Message: fmt.Sprintf("unable to verify SoundCloud oauth token: %v", err)}, true
+		}
+	}
+
+	if source == auth.CredentialStorageSourceEnv {
+		return Check{
+			Severity: SeverityInfo,
+			Name:     "auth",
+			Message:  "SoundCloud oauth token is available via UDL_SOUNDCLOUD_OAUTH_TOKEN; udl cannot see its expiry or refresh it",
+		}, true
+	}
+	message := "SoundCloud oauth token is available in macOS Keychain"
+	if token.ExpiresAt > 0 {
+		message += fmt.Sprintf(" (expires %s)", time.Unix(token.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	}
+	if reason := c.soundCloudOAuthRefreshBlocker(token); reason != "" && token.ExpiresAt > 0 {
+		return Check{Severity: SeverityWarn, Name: "auth", Message: message + "; it cannot be refreshed (" + reason + ")"}, true
+	}
+	return Check{Severity: SeverityInfo, Name: "auth", Message: message}, true
+}
+
+// soundCloudOAuthRefreshBlocker says why token cannot be refreshed, or ""
+// when it can.
+func (c *Checker) soundCloudOAuthRefreshBlocker(token auth.SoundCloudOAuthToken) string {
+	if !token.CanRefresh() {
+		return "no refresh token stored"
+	}
+	resolve := c.ResolveSoundCloudOAuthApp
+	if resolve == nil {
+		resolve = auth.SoundCloudOAuthResolver{}.ResolveAppCredentials
+	}
+	if _, err := resolve(); err != nil {
+		return "set UDL_SOUNDCLOUD_APP_CLIENT_ID and UDL_SOUNDCLOUD_APP_CLIENT_SECRET or store them in Keychain"
+	}
+	return ""
+}
+
+func probeSoundCloudOAuth(ctx context.Context, accessToken string) error {
+	ctx, cancel := context.WithTimeout(ctx, soundCloudOAuthProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, soundCloudOAuthProbeURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "OAuth "+accessToken)
+	req.Header.Set("Accept", "application/json; charset=utf-8")
+	resp, err := soundCloudOAuthProbeHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request: %w", err)
+	}
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errSoundCloudOAuthRejected
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("soundcloud returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// needsSoundCloudOAuthCheck reports whether an enabled SoundCloud source
+// would fall back to the stored OAuth session.
+func needsSoundCloudOAuthCheck(sources []config.Source) bool {
+	for _, source := range sources {
+		if source.Enabled && source.Type == config.SourceTypeSoundCloud && strings.TrimSpace(source.CookiesFile) == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,341 @@
+package doctor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	spotifyProbeRequests    = 3
+	spotifyProbeTimeout     = 15 * time.Second
+	spotifyProbeCacheTTL    = 6 * time.Hour
+	spotifyProbeCacheFile   = "spotify-throttle-probe.json"
+	spotifyThrottleLikely   = 60
+	spotifyThrottlePossible = 30
+)
+
+var (
+	spotifyProbeHTTPClient = &http.Client{}
+	spotifyTokenURL        = "https://accounts.spotify.com/api/token"
+	spotifyProbeURL        = "https://api.spotify.com/v1/search?q=udl&type=track&limit=1"
+)
+
+// SpotifyProbeResult summarizes a short burst of Spotify Web API requests.
+// RetryAfter is the longest Retry-After seen on a 429 response.
+type SpotifyProbeResult struct {
+	Requests    int           `json:"requests"`
+	RateLimited int           `json:"rate_limited"`
+	RetryAfter  time.Duration `json:"retry_after"`
+	MeanLatency time.Duration `json:"mean_latency"`
+}
+
+type spotifyProbeCache struct {
+	Entries map[string]spotifyProbeCacheEntry `json:"entries"`
+}
+
+type spotifyProbeCacheEntry struct {
+	CheckedAt time.Time          `json:"checked_at"`
+	Result    SpotifyProbeResult `json:"result"`
+}
+
+type spotifyProbeCandidate struct {
+	creds  auth.SpotifyCredentials
+	origin string
+}
+
+// spotifyThrottleChecks probes each distinct set of Spotify app credentials
+// udl would use and scores how likely throttling is. Results are cached
+// under state_dir per client ID (never the secret) for spotifyProbeCacheTTL.
+func (c *Checker) spotifyThrottleChecks(ctx context.Context, cfg config.Config) []Check {
+	if c.ProbeSpotifyAPI == nil {
+		return nil
+	}
+	now := c.Now
+	if now == nil {
+		now = time.Now
+	}
+	cachePath := ""
+	if stateDir, err := config.ExpandPath(cfg.Defaults.StateDir); err == nil && filepath.IsAbs(stateDir) {
+		cachePath = filepath.Join(stateDir, "doctor", spotifyProbeCacheFile)
+	}
+	cache := loadSpotifyProbeCache(cachePath)
+	cacheDirty := false
+
+	checks := []Check{}
+	for _, candidate := range c.spotifyProbeCandidates(cfg.Sources) {
+		key := spotifyProbeCacheKey(candidate.creds.ClientID)
+		entry, cached := cache.Entries[key]
+		if !cached || now().Sub(entry.CheckedAt) >= spotifyProbeCacheTTL {
+			result, err := c.ProbeSpotifyAPI(ctx, candidate.creds)
+			if err != nil {
+				checks = append(checks, Check{
+					Severity: SeverityWarn,
+					Name:     "auth",
+					Message:  fmt.Sprintf("Spotify API throttling probe for %s credentials failed: %v", candidate.origin, err),
+				})
+				continue
+			}
+			entry = spotifyProbeCacheEntry{CheckedAt: now().UTC(), Result: result}
+			cache.Entries[key] = entry
+			cacheDirty = true
+			cached = false
+		}
+		shared := usesSharedSpotDLCredentials(candidate.creds.ClientID, candidate.creds.ClientSecret)
+		checks = append(checks, spotifyThrottleCheck(candidate.origin, entry, shared, cached))
+	}
+	if cacheDirty && cachePath != "" {
+		_ = saveSpotifyProbeCache(cachePath, cache)
+	}
+	return checks
+}
+
+// spotifyThrottleScore weighs 429s (and long Retry-After windows, typical of
+// shared app credentials), slow responses, and known shared credentials into
+// a 0-100 score.
+func spotifyThrottleScore(result SpotifyProbeResult, shared bool) int {
+	score := 0
+	if shared {
+		score += 40
+	}
+	if result.RateLimited > 0 {
+		score += 60
+		if result.RetryAfter >= time.Hour {
+			score += 30
+		}
+	}
+	switch {
+	case result.MeanLatency >= 3*time.Second:
+		score += 30
+	case result.MeanLatency >= 1500*time.Millisecond:
+		score += 15
+	}
+	if score > 100 {
+		score = 100
+	}
+	return score
+}
+
+func spotifyThrottleCheck(origin string, entry spotifyProbeCacheEntry, shared bool, cached bool) Check {
+	result := entry.Result
+	score := spotifyThrottleScore(result, shared)
+	detail := fmt.Sprintf(
+		"score %d/100; %d/%d requests rate-limited",
+		score,
+		result.RateLimited,
+		result.Requests,
+	)
+	if result.RetryAfter > 0 {
+		detail += fmt.Sprintf(", retry-after %s", result.RetryAfter)
+	}
+	detail += fmt.Sprintf(", mean latency %s", result.MeanLatency.Round(time.Millisecond))
+	if cached {
+		detail += fmt.Sprintf(", cached %s", entry.CheckedAt.UTC().Format(time.RFC3339))
+	}
+
+	switch {
+	case score >= spotifyThrottleLikely:
+		return Check{
+			Severity: SeverityWarn,
+			Name:     "auth",
+			Message: fmt.Sprintf(
+				"Spotify API throttling is likely for %s credentials (%s); create your own Spotify app at https://developer.spotify.com/dashboard and save its client ID/secret via `udl tui` Credentials or UDL_SPOTIFY_CLIENT_ID/UDL_SPOTIFY_CLIENT_SECRET",
+				origin,
+				detail,
+			),
+		}
+	case score >= spotifyThrottlePossible:
+		return Check{
+			Severity: SeverityInfo,
+			Name:     "auth",
+			Message:  fmt.Sprintf("Spotify API throttling is possible for %s credentials (%s); per-user app credentials are recommended", origin, detail),
+		}
+	default:
+		return Check{
+			Severity: SeverityInfo,
+			Name:     "auth",
+			Message:  fmt.Sprintf("Spotify API responded normally for %s credentials (%s)", origin, detail),
+		}
+	}
+}
+
+// spotifyProbeCandidates returns the credentials each enabled Spotify adapter
+// would use: spotdl reads ~/.spotdl/config.json, deemix the resolved
+// env/Keychain credentials.
+func (c *Checker) spotifyProbeCandidates(sources []config.Source) []spotifyProbeCandidate {
+	usesSpotDL := false
+	usesDeemix := false
+	for _, source := range sources {
+		if !source.Enabled || source.Type != config.SourceTypeSpotify {
+			continue
+		}
+		switch source.Adapter.Kind {
+		case "spotdl":
+			usesSpotDL = true
+		case "deemix":
+			usesDeemix = true
+		}
+	}
+
+	candidates := []spotifyProbeCandidate{}
+	seen := map[string]struct{}{}
+	add := func(creds auth.SpotifyCredentials, origin string) {
+		creds.ClientID = strings.TrimSpace(creds.ClientID)
+		creds.ClientSecret = strings.TrimSpace(creds.ClientSecret)
+		if creds.ClientID == "" || creds.ClientSecret == "" {
+			return
+		}
+		if _, ok := seen[creds.ClientID]; ok {
+			return
+		}
+		seen[creds.ClientID] = struct{}{}
+		candidates = append(candidates, spotifyProbeCandidate{creds: creds, origin: origin})
+	}
+	if usesSpotDL {
+		if cfg, _, ok := c.readSpotDLConfig(); ok {
+			add(auth.SpotifyCredentials{ClientID: cfg.ClientID, ClientSecret: cfg.ClientSecret}, "spotdl config")
+		}
+	}
+	if usesDeemix {
+		resolve := c.ResolveSpotifyWithSource
+		if resolve == nil && c.ResolveSpotifyCredentials != nil {
+			legacy := c.ResolveSpotifyCredentials
+			resolve = func() (auth.SpotifyCredentials, auth.CredentialStorageSource, error) {
+				creds, err := legacy()
+				return creds, auth.CredentialStorageSourceKeychain, err
+			}
+		}
+		if resolve != nil {
+			if creds, source, err := resolve(); err == nil {
+				origin := string(source)
+				if origin == "" {
+					origin = "configured"
+				}
+				add(creds, origin)
+			}
+		}
+	}
+	return candidates
+}
+
+func spotifyProbeCacheKey(clientID string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(clientID)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func loadSpotifyProbeCache(path string) spotifyProbeCache {
+	cache := spotifyProbeCache{Entries: map[string]spotifyProbeCacheEntry{}}
+	if path == "" {
+		return cache
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(raw, &cache); err != nil || cache.Entries == nil {
+		return spotifyProbeCache{Entries: map[string]spotifyProbeCacheEntry{}}
+	}
+	return cache
+}
+
+func saveSpotifyProbeCache(path string, cache spotifyProbeCache) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(payload, '\n'), 0o600)
+}
+
+// probeSpotifyAPI requests a client-credentials token and times a few search
+// calls. The secret is only sent to the Spotify accounts endpoint.
+func probeSpotifyAPI(ctx context.Context, creds auth.SpotifyCredentials) (SpotifyProbeResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, spotifyProbeTimeout)
+	defer cancel()
+
+	result := SpotifyProbeResult{}
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, spotifyTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return result, err
+	}
+	req.SetBasicAuth(creds.ClientID, creds.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := spotifyProbeHTTPClient.Do(req)
+	if err != nil {
+		return result, fmt.Errorf("token request: %w", err)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&token)
+	_ = resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		result.Requests = 1
+		result.RateLimited = 1
+		result.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		return result, nil
+	case resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnauthorized:
+		return result, fmt.Errorf("credentials were rejected (HTTP %d)", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return result, fmt.Errorf("token request returned HTTP %d", resp.StatusCode)
+	case decodeErr != nil || strings.TrimSpace(token.AccessToken) == "":
+		return result, fmt.Errorf("token response did not include an access token")
+	}
+
+	var total time.Duration
+	for i := 0; i < spotifyProbeRequests; i++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, spotifyProbeURL, nil)
+		if err != nil {
+			return result, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+		started := time.Now()
+		resp, err := spotifyProbeHTTPClient.Do(req)
+		if err != nil {
+			if result.Requests > 0 {
+				break
+			}
+			return result, fmt.Errorf("api request: %w", err)
+		}
+		_ = resp.Body.Close()
+		total += time.Since(started)
+		result.Requests++
+		if resp.StatusCode == http.StatusTooManyRequests {
+			result.RateLimited++
+			if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > result.RetryAfter {
+				result.RetryAfter = retryAfter
+			}
+			if result.RetryAfter >= time.Minute {
+				break
+			}
+		}
+	}
+	if result.Requests > 0 {
+		result.MeanLatency = total / time.Duration(result.Requests)
+	}
+	return result, nil
+}
+
+func parseRetryAfter(raw string) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(raw))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
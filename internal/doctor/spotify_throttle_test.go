@@ -0,0 +1,125 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+)
+
+func TestDoctorSpotifyThrottleProbeWarnsAndCaches(t *testing.T) {
+	cfg := spotifyConfig()
+	cfg.Defaults.StateDir = t.TempDir()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	probes := 0
+	checker := &Checker{
+		LookPath:      func(name string) (string, error) { return "/usr/bin/" + name, nil },
+		ReadVersion:   func(ctx context.Context, binary string) (string, error) { return "spotdl 4.5.0", nil },
+		Getenv:        func(key string) string { return "" },
+		CheckWritable: func(path string) error { return nil },
+		HomeDir:       func() (string, error) { return "/home/tester", nil },
+		ReadFile: func(path string) ([]byte, error) {
+			return []byte(`{"client_id":"custom-client","client_secret":"custom-secret"}`), nil
+		},
+		ProbeSpotifyAPI: func(ctx context.Context, creds auth.SpotifyCredentials) (SpotifyProbeResult, error) {
+			probes++
+			if creds.ClientID != "custom-client" {
+				return SpotifyProbeResult{}, fmt.Errorf("unexpected client %q", creds.ClientID)
+			}
+			return SpotifyProbeResult{Requests: 3, RateLimited: 2, RetryAfter: 24 * time.Hour, MeanLatency: 200 * time.Millisecond}, nil
+		},
+		Now: func() time.Time { return now },
+	}
+
+	report := checker.Check(context.Background(), cfg)
+	if !hasWarnContaining(report, "throttling is likely for spotdl config credentials (score 90/100; 2/3 requests rate-limited, retry-after 24h0m0s") {
+		t.Fatalf("expected throttling warning, got %+v", report.Checks)
+	}
+
+	now = now.Add(time.Hour)
+	report = checker.Check(context.Background(), cfg)
+	if probes != 1 {
+		t.Fatalf("expected cached probe result, probed %d times", probes)
+	}
+	if !hasWarnContaining(report, "cached 2026-03-01T10:00:00Z") {
+		t.Fatalf("expected cached throttling warning, got %+v", report.Checks)
+	}
+
+	now = now.Add(spotifyProbeCacheTTL)
+	_ = checker.Check(context.Background(), cfg)
+	if probes != 2 {
+		t.Fatalf("expected probe after cache expiry, probed %d times", probes)
+	}
+}
+
+func TestSpotifyThrottleScore(t *testing.T) {
+	cases := []struct {
+		name   string
+		result SpotifyProbeResult
+		shared bool
+		want   int
+	}{
+		{name: "healthy", result: SpotifyProbeResult{Requests: 3, MeanLatency: 150 * time.Millisecond}, want: 0},
+		{name: "slow", result: SpotifyProbeResult{Requests: 3, MeanLatency: 2 * time.Second}, want: 15},
+		{name: "shared", result: SpotifyProbeResult{Requests: 3}, shared: true, want: 40},
+		{name: "rate limited", result: SpotifyProbeResult{Requests: 3, RateLimited: 1, RetryAfter: 5 * time.Second}, want: 60},
+		{name: "shared and throttled", result: SpotifyProbeResult{Requests: 1, RateLimited: 1, RetryAfter: 86400 * time.Second}, shared: true, want: 100},
+	}
+	for _, tc := range cases {
+		if got := spotifyThrottleScore(tc.result, tc.shared); got != tc.want {
+			t.Fatalf("%s: expected score %d, got %d", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestProbeSpotifyAPICountsRateLimitedResponses(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/token" {
+			if user, pass, ok := r.BasicAuth(); !ok || user != "id" || pass != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"token"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		calls++
+		if calls == 2 {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	origToken, origProbe, origClient := spotifyTokenURL, spotifyProbeURL, spotifyProbeHTTPClient
+	spotifyTokenURL = server.URL + "/api/token"
+	spotifyProbeURL = server.URL + "/v1/search"
+	spotifyProbeHTTPClient = server.Client()
+	defer func() {
+		spotifyTokenURL, spotifyProbeURL, spotifyProbeHTTPClient = origToken, origProbe, origClient
+	}()
+
+	result, err := probeSpotifyAPI(context.Background(), auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if result.Requests != 3 || result.RateLimited != 1 || result.RetryAfter != 30*time.Second {
+		t.Fatalf("unexpected probe result: %+v", result)
+	}
+
+	_, err = probeSpotifyAPI(context.Background(), auth.SpotifyCredentials{ClientID: "id", ClientSecret: "wrong"})
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("expected rejected credentials error, got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// activityMinRuns is how many finished runs of a source the schedule
+// suggestion needs before it trusts the weekday pattern.
+const activityMinRuns = 8
+
+// SourceActivityStats summarizes, from the history journal, on which local
+// weekdays a source's finished syncs found new tracks. A run is counted on
+// the day it started, so it stands for everything added since the run before.
+type SourceActivityStats struct {
+	SourceID          string              `json:"source_id"`
+	Schedule          string              `json:"schedule,omitempty"`
+	Runs              int                 `json:"runs"`
+	RunsWithNewTracks int                 `json:"runs_with_new_tracks"`
+	Tracks            int                 `json:"tracks"`
+	Weekdays          []WeekdayActivity   `json:"weekdays"`
+	Suggestion        *ScheduleSuggestion `json:"suggestion,omitempty"`
+
+	newTrackHours [24]int
+}
+
+// WeekdayActivity is one cell of the activity heat map, Sunday first.
+type WeekdayActivity struct {
+	Weekday           string `json:"weekday"`
+	Runs              int    `json:"runs"`
+	RunsWithNewTracks int    `json:"runs_with_new_tracks"`
+	Tracks            int    `json:"tracks"`
+}
+
+// ScheduleSuggestion is a sync.schedule value fitted to the weekdays a source
+// gets new tracks. Schedule is empty when the current one should be kept.
+// SkippableRuns counts past runs on weekdays the suggestion leaves out, all of
+// which found nothing.
+type ScheduleSuggestion struct {
+	Schedule      string `json:"schedule,omitempty"`
+	Reason        string `json:"reason"`
+	SkippableRuns int    `json:"skippable_runs"`
+}
+
+// InspectSourceActivity summarizes the history journal for the selected
+// sources (all when sourceIDs is empty), in config order, counting runs that
+// started at or after since (every run when since is zero). Only finished
+// source runs count; failed and skipped ones say nothing about the remote
+// playlist. Each source also gets a schedule suggestion.
+func InspectSourceActivity(cfg config.Config, sourceIDs []string, since time.Time) ([]SourceActivityStats, error) {
+	sources, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := LoadHistory(cfg.Defaults.StateDir, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	bySource := map[string]*SourceActivityStats{}
+	for _, source := range sources {
+		stats := &SourceActivityStats{
+			SourceID: source.ID,
+			Schedule: source.Sync.Schedule,
+			Weekdays: make([]WeekdayActivity, 7),
+		}
+		for day := range stats.Weekdays {
+			stats.Weekdays[day].Weekday = time.Weekday(day).String()[:3]
+		}
+		bySource[source.ID] = stats
+	}
+	for _, entry := range entries {
+		if entry.StartedAt.IsZero() || entry.StartedAt.Before(since) {
+			continue
+		}
+		started := entry.StartedAt.Local()
+		for _, source := range entry.Sources {
+			stats, ok := bySource[source.SourceID]
+			if !ok || source.Status != "finished" {
+				continue
+			}
+			day := &stats.Weekdays[started.Weekday()]
+			tracks := len(source.DownloadedTrackIDs)
+			stats.Runs++
+			day.Runs++
+			stats.Tracks += tracks
+			day.Tracks += tracks
+			if tracks > 0 {
+				stats.RunsWithNewTracks++
+				day.RunsWithNewTracks++
+				stats.newTrackHours[started.Hour()]++
+			}
+		}
+	}
+
+	out := make([]SourceActivityStats, 0, len(sources))
+	for _, source := range sources {
+		stats := bySource[source.ID]
+		suggestion := suggestSchedule(*stats)
+		stats.Suggestion = &suggestion
+		out = append(out, *stats)
+	}
+	return out, nil
+}
+
+func suggestSchedule(stats SourceActivityStats) ScheduleSuggestion {
+	if stats.Runs < activityMinRuns {
+		return ScheduleSuggestion{
+			Reason: fmt.Sprintf("not enough history: %d finished runs, need %d", stats.Runs, activityMinRuns),
+		}
+	}
+	if stats.RunsWithNewTracks == 0 {
+		return ScheduleSuggestion{
+			Schedule:      "@weekly",
+			Reason:        fmt.Sprintf("no new tracks in %d runs", stats.Runs),
+			SkippableRuns: stats.Runs - stats.Weekdays[time.Sunday].Runs,
+		}
+	}
+
+	activeDays := []string{}
+	activeNames := []string{}
+	skippable := 0
+	for day, cell := range stats.Weekdays {
+		if cell.RunsWithNewTracks > 0 {
+			activeDays = append(activeDays, strconv.Itoa(day))
+			activeNames = append(activeNames, cell.Weekday)
+			continue
+		}
+		skippable += cell.Runs
+	}
+	if skippable == 0 {
+		return ScheduleSuggestion{Reason: "every weekday that was synced found new tracks; keep the current schedule"}
+	}
+	if len(activeDays) >= 5 {
+		return ScheduleSuggestion{Reason: fmt.Sprintf("new tracks on %d of 7 weekdays; keep the current schedule", len(activeDays))}
+	}
+
+	hour := 0
+	for h, count := range stats.newTrackHours {
+		if count > stats.newTrackHours[hour] {
+			hour = h
+		}
+	}
+	return ScheduleSuggestion{
+		Schedule: fmt.Sprintf("0 %d * * %s", hour, strings.Join(activeDays, ",")),
+		Reason: fmt.Sprintf(
+			"new tracks only on %s; %d of %d runs fell on other days and found nothing",
+			strings.Join(activeNames, ", "),
+			skippable,
+			stats.Runs,
+		),
+		SkippableRuns: skippable,
+	}
+}
@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestInspectSourceActivitySuggestsScheduleFromWeekdays(t *testing.T) {
+	stateDir := t.TempDir()
+	// 2026-03-02 is a Monday; four weeks of daily 09:00 runs follow.
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.Local)
+	for day := 0; day < 28; day++ {
+		started := start.AddDate(0, 0, day)
+		fridays := HistorySource{SourceID: "fridays", Status: "finished"}
+		if started.Weekday() == time.Friday {
+			fridays.DownloadedTrackIDs = []string{"a", "b"}
+		}
+		entry := HistoryEntry{
+			StartedAt: started.UTC(),
+			Sources: []HistorySource{
+				fridays,
+				{SourceID: "quiet", Status: "finished"},
+				{SourceID: "removed", Status: "finished", DownloadedTrackIDs: []string{"x"}},
+				{SourceID: "fresh", Status: "failed"},
+			},
+		}
+		if err := appendHistoryEntry(stateDir, entry); err != nil {
+			t.Fatalf("append history: %v", err)
+		}
+	}
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir},
+		Sources: []config.Source{
+			{ID: "fridays", Sync: config.SyncPolicy{Schedule: "6h"}},
+			{ID: "quiet"},
+			{ID: "fresh"},
+		},
+	}
+
+	stats, err := InspectSourceActivity(cfg, nil, time.Time{})
+	if err != nil {
+		t.Fatalf("inspect activity: %v", err)
+	}
+	if len(stats) != 3 || stats[0].SourceID != "fridays" || stats[1].SourceID != "quiet" || stats[2].SourceID != "fresh" {
+		t.Fatalf("expected configured sources in config order, got %+v", stats)
+	}
+
+	fridays := stats[0]
+	if fridays.Runs != 28 || fridays.RunsWithNewTracks != 4 || fridays.Tracks != 8 || fridays.Schedule != "6h" {
+		t.Fatalf("unexpected totals: %+v", fridays)
+	}
+	if cell := fridays.Weekdays[time.Friday]; cell.Weekday != "Fri" || cell.Runs != 4 || cell.RunsWithNewTracks != 4 {
+		t.Fatalf("unexpected friday cell: %+v", cell)
+	}
+	if got := fridays.Suggestion; got == nil || got.Schedule != "0 9 * * 5" || got.SkippableRuns != 24 {
+		t.Fatalf("expected friday schedule suggestion, got %+v", got)
+	}
+	if got := stats[1].Suggestion; got == nil || got.Schedule != "@weekly" || got.SkippableRuns != 24 {
+		t.Fatalf("expected weekly suggestion for a quiet source, got %+v", got)
+	}
+	if got := stats[2].Suggestion; stats[2].Runs != 0 || got == nil || got.Schedule != "" || got.Reason != "not enough history: 0 finished runs, need 8" {
+		t.Fatalf("expected no suggestion without finished runs, got %+v", stats[2])
+	}
+
+	stats, err = InspectSourceActivity(cfg, []string{"fridays"}, start.AddDate(0, 0, 21))
+	if err != nil {
+		t.Fatalf("inspect activity since: %v", err)
+	}
+	if len(stats) != 1 || stats[0].Runs != 7 || stats[0].Suggestion.Schedule != "" {
+		t.Fatalf("expected one week of runs and no suggestion, got %+v", stats)
+	}
+}
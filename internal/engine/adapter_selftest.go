@@ -0,0 +1,326 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	SelfTestPass = "pass"
+	SelfTestFail = "fail"
+	SelfTestSkip = "skip"
+)
+
+// adapterSelfTestTargets are the source type and a stable public one-track URL
+// each adapter kind is probed with. tidal-dl and gamdl need an account-specific
+// URL, so they only run the download step with --url.
+var adapterSelfTestTargets = map[string]struct {
+	Type config.SourceType
+	URL  string
+}{
+	"deemix":      {Type: config.SourceTypeDeezer, URL: "https://www.deezer.com/track/3135556"},
+	"spotdl":      {Type: config.SourceTypeSpotify, URL: "https://open.spotify.com/track/11dFghVXANMlKmJXsNCbNl"},
+	"scdl":        {Type: config.SourceTypeSoundCloud, URL: "https://soundcloud.com/forss/flickermood"},
+	"scdl-freedl": {Type: config.SourceTypeSoundCloud},
+	"ytdlp":       {Type: config.SourceTypeYouTube, URL: "https://www.youtube.com/watch?v=jNQXAC9IVRw"},
+	"tidal-dl":    {Type: config.SourceTypeTidal},
+	"gamdl":       {Type: config.SourceTypeAppleMusic},
+}
+
+var (
+	resolveTidalTokenFileFn        = auth.ResolveTidalTokenFile
+	resolveAppleMusicCookiesFileFn = auth.ResolveAppleMusicCookiesFile
+)
+
+var selfTestVersionPattern = regexp.MustCompile(`(\d+)\.(\d+)\.(\d+)`)
+
+// AdapterSelfTestOptions controls RunAdapterSelfTest. URL overrides the
+// built-in public track; SkipDownload stops after the version and auth steps.
+type AdapterSelfTestOptions struct {
+	URL          string
+	Timeout      time.Duration
+	SkipDownload bool
+	KeepDir      bool
+}
+
+// AdapterSelfTestStep is the outcome of one probe step.
+type AdapterSelfTestStep struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Detail     string `json:"detail,omitempty"`
+}
+
+// AdapterSelfTestReport summarizes an adapter probe; Passed is false when any
+// step failed. Dir is only set when the download directory was kept.
+type AdapterSelfTestReport struct {
+	Kind       string                `json:"kind"`
+	Binary     string                `json:"binary"`
+	URL        string                `json:"url,omitempty"`
+	Passed     bool                  `json:"passed"`
+	DurationMS int64                 `json:"duration_ms"`
+	Steps      []AdapterSelfTestStep `json:"steps"`
+	Dir        string                `json:"dir,omitempty"`
+}
+
+// RunAdapterSelfTest probes adapter outside of any configured source: the
+// tool's version, the credentials it needs, and a one-track download into a
+// temporary directory. Later steps are skipped once a step fails, so the first
+// failure points at the layer that is broken.
+func RunAdapterSelfTest(ctx context.Context, adapter Adapter, runner ExecRunner, opts AdapterSelfTestOptions) AdapterSelfTestReport {
+	start := time.Now()
+	kind := adapter.Kind()
+	report := AdapterSelfTestReport{Kind: kind, Binary: adapter.Binary(), Steps: []AdapterSelfTestStep{}}
+	target := adapterSelfTestTargets[kind]
+	report.URL = strings.TrimSpace(opts.URL)
+	if report.URL == "" {
+		report.URL = target.URL
+	}
+
+	failed := false
+	record := func(name string, run func() (string, string)) {
+		if failed {
+			report.Steps = append(report.Steps, AdapterSelfTestStep{Name: name, Status: SelfTestSkip, Detail: "skipped after an earlier failure"})
+			return
+		}
+		stepStart := time.Now()
+		status, detail := run()
+		report.Steps = append(report.Steps, AdapterSelfTestStep{Name: name, Status: status, DurationMS: time.Since(stepStart).Milliseconds(), Detail: detail})
+		if status == SelfTestFail {
+			failed = true
+		}
+	}
+
+	record("version", func() (string, string) {
+		return selfTestVersion(ctx, adapter, runner, opts.Timeout)
+	})
+	var arl string
+	record("auth", func() (string, string) {
+		status, detail, value := selfTestAuth(kind)
+		arl = value
+		return status, detail
+	})
+	record("download", func() (string, string) {
+		switch {
+		case opts.SkipDownload:
+			return SelfTestSkip, "dry-run; no download attempted"
+		case kind == "scdl-freedl":
+			return SelfTestSkip, "scdl-freedl downloads are orchestrated by udl; its yt-dlp binary was checked above"
+		case report.URL == "":
+			return SelfTestSkip, "no public test track for " + kind + "; pass --url with a track your account can download"
+		}
+		status, detail, dir := selfTestDownload(ctx, adapter, runner, target.Type, report.URL, arl, opts)
+		report.Dir = dir
+		return status, detail
+	})
+
+	report.Passed = !failed
+	report.DurationMS = time.Since(start).Milliseconds()
+	return report
+}
+
+func selfTestVersion(ctx context.Context, adapter Adapter, runner ExecRunner, timeout time.Duration) (string, string) {
+	if timeout <= 0 || timeout > 30*time.Second {
+		timeout = 30 * time.Second
+	}
+	result := runner.Run(ctx, ExecSpec{Bin: adapter.Binary(), Args: []string{"--version"}, Timeout: timeout})
+	if result.ExitCode != 0 {
+		if result.ExitCode == 127 {
+			return SelfTestFail, fmt.Sprintf("%s not found in PATH", adapter.Binary())
+		}
+		return SelfTestFail, fmt.Sprintf("%s --version exited %d: %s", adapter.Binary(), result.ExitCode, lastNonEmptyLine(result.StderrTail, result.StdoutTail))
+	}
+	matches := selfTestVersionPattern.FindStringSubmatch(result.StdoutTail + "\n" + result.StderrTail)
+	if len(matches) != 4 {
+		return SelfTestFail, fmt.Sprintf("could not parse a version from %s --version", adapter.Binary())
+	}
+	version := matches[0]
+	if minimum := strings.TrimSpace(adapter.MinVersion()); minimum != "" && compareSelfTestVersions(version, minimum) < 0 {
+		return SelfTestFail, fmt.Sprintf("%s %s is older than the minimum %s", adapter.Binary(), version, minimum)
+	}
+	return SelfTestPass, fmt.Sprintf("%s %s", adapter.Binary(), version)
+}
+
+// selfTestAuth checks the credentials kind needs. The Deezer ARL is returned so
+// the download step can hand it to deemix.
+func selfTestAuth(kind string) (string, string, string) {
+	switch kind {
+	case "deemix":
+		arl, err := resolveDeemixARLFn()
+		if err != nil {
+			return SelfTestFail, fmt.Sprintf("Deezer ARL: %v", err), ""
+		}
+		return SelfTestPass, "Deezer ARL resolved", arl
+	case "spotdl":
+		if _, err := resolveSpotifyCredentialsFn(); err != nil {
+			return SelfTestSkip, "no Spotify app credentials; spotdl uses its shared defaults", ""
+		}
+		return SelfTestPass, "Spotify app credentials resolved", ""
+	case "scdl":
+		if _, source, err := resolveSoundCloudClientIDWithSourceFn(); err == nil {
+			return SelfTestPass, fmt.Sprintf("SoundCloud client ID resolved (%s)", source), ""
+		}
+		return SelfTestSkip, "no SoundCloud client ID; scdl generates its own", ""
+	case "tidal-dl":
+		path, _, err := resolveTidalTokenFileFn()
+		if err != nil {
+			return SelfTestFail, fmt.Sprintf("tidal-dl token file: %v", err), ""
+		}
+		return SelfTestPass, "tidal-dl token file " + path, ""
+	case "gamdl":
+		path, _, err := resolveAppleMusicCookiesFileFn()
+		if err != nil {
+			return SelfTestFail, fmt.Sprintf("Apple Music cookies: %v", err), ""
+		}
+		return SelfTestPass, "Apple Music cookies " + path, ""
+	default:
+		return SelfTestSkip, "no credentials needed", ""
+	}
+}
+
+func selfTestDownload(
+	ctx context.Context,
+	adapter Adapter,
+	runner ExecRunner,
+	sourceType config.SourceType,
+	sourceURL string,
+	arl string,
+	opts AdapterSelfTestOptions,
+) (string, string, string) {
+	root, err := os.MkdirTemp("", "udl-adapter-test-")
+	if err != nil {
+		return SelfTestFail, fmt.Sprintf("create temp dir: %v", err), ""
+	}
+	keep := opts.KeepDir
+	defer func() {
+		if !keep {
+			_ = os.RemoveAll(root)
+		}
+	}()
+
+	kind := adapter.Kind()
+	targetDir := filepath.Join(root, "music")
+	stateDir := filepath.Join(root, "state")
+	for _, dir := range []string{targetDir, stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return SelfTestFail, fmt.Sprintf("create temp dir: %v", err), ""
+		}
+	}
+	source := config.Source{
+		ID:        "adapter-test",
+		Type:      sourceType,
+		Enabled:   true,
+		TargetDir: targetDir,
+		URL:       sourceURL,
+		StateFile: "adapter-test.sync",
+		Adapter:   config.AdapterSpec{Kind: kind},
+		DeezerARL: arl,
+	}
+	defaults := config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt", Threads: 1}
+	if err := adapter.Validate(source); err != nil {
+		return SelfTestFail, fmt.Sprintf("validate: %v", err), ""
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	spec, err := adapter.BuildExecSpec(source, defaults, timeout)
+	if err != nil {
+		return SelfTestFail, fmt.Sprintf("build command: %v", err), ""
+	}
+	if kind == "deemix" {
+		defer func() { _ = cleanupRuntimeDir(spec.Dir) }()
+	}
+
+	result := runner.Run(ctx, spec)
+	if result.ExitCode != 0 {
+		reason := fmt.Sprintf("exited %d", result.ExitCode)
+		if result.TimedOut {
+			reason = "timed out after " + timeout.String()
+		}
+		return SelfTestFail, fmt.Sprintf("%s %s: %s", adapter.Binary(), reason, lastNonEmptyLine(result.StderrTail, result.StdoutTail)), keptDir(root, keep)
+	}
+	media, size, err := firstMediaFile(targetDir)
+	if err != nil {
+		return SelfTestFail, fmt.Sprintf("scan %s: %v", targetDir, err), keptDir(root, keep)
+	}
+	if media == "" {
+		return SelfTestFail, fmt.Sprintf("%s exited 0 but wrote no media file", adapter.Binary()), keptDir(root, keep)
+	}
+	return SelfTestPass, fmt.Sprintf("downloaded %s (%d bytes)", media, size), keptDir(root, keep)
+}
+
+func keptDir(root string, keep bool) string {
+	if keep {
+		return root
+	}
+	return ""
+}
+
+func firstMediaFile(root string) (string, int64, error) {
+	found := ""
+	var size int64
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if entry.IsDir() || !isMediaExt(strings.ToLower(filepath.Ext(path))) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		found, _ = filepath.Rel(root, path)
+		size = info.Size()
+		return fs.SkipAll
+	})
+	if err != nil && !errors.Is(err, fs.SkipAll) {
+		return "", 0, err
+	}
+	return found, size, nil
+}
+
+func lastNonEmptyLine(outputs ...string) string {
+	for _, output := range outputs {
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		for i := len(lines) - 1; i >= 0; i-- {
+			if line := strings.TrimSpace(lines[i]); line != "" {
+				return line
+			}
+		}
+	}
+	return "no output"
+}
+
+func compareSelfTestVersions(lhs string, rhs string) int {
+	left := strings.Split(lhs, ".")
+	right := strings.Split(rhs, ".")
+	for i := 0; i < 3; i++ {
+		var l, r int
+		if i < len(left) {
+			l, _ = strconv.Atoi(left[i])
+		}
+		if i < len(right) {
+			r, _ = strconv.Atoi(right[i])
+		}
+		if l != r {
+			if l > r {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
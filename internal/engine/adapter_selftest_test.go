@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type selfTestRunner struct {
+	version string
+	write   bool
+	exit    int
+	specs   []ExecSpec
+}
+
+func (r *selfTestRunner) Run(ctx context.Context, spec ExecSpec) ExecResult {
+	r.specs = append(r.specs, spec)
+	if len(spec.Args) == 1 && spec.Args[0] == "--version" {
+		return ExecResult{ExitCode: 0, StdoutTail: r.version}
+	}
+	if r.write {
+		_ = os.WriteFile(filepath.Join(spec.Dir, "track.mp3"), []byte("audio"), 0o644)
+	}
+	return ExecResult{ExitCode: r.exit, StderrTail: "ERROR: unable to download\n"}
+}
+
+func TestRunAdapterSelfTestPassesAndRemovesTempDir(t *testing.T) {
+	runner := &selfTestRunner{version: "fakebin 1.2.3\n", write: true}
+	report := RunAdapterSelfTest(context.Background(), fakeAdapter{}, runner, AdapterSelfTestOptions{URL: "https://example.com/track/1"})
+	if !report.Passed || len(report.Steps) != 3 {
+		t.Fatalf("expected passing report, got %+v", report)
+	}
+	if report.Steps[0].Detail != "fakebin 1.2.3" || report.Steps[1].Status != SelfTestSkip || report.Steps[2].Status != SelfTestPass {
+		t.Fatalf("unexpected steps: %+v", report.Steps)
+	}
+	if !strings.Contains(report.Steps[2].Detail, "track.mp3 (5 bytes)") {
+		t.Fatalf("unexpected download detail: %q", report.Steps[2].Detail)
+	}
+	if _, err := os.Stat(runner.specs[1].Dir); !os.IsNotExist(err) {
+		t.Fatalf("expected temp download dir to be removed, got %v", err)
+	}
+}
+
+func TestRunAdapterSelfTestStopsAtFirstFailure(t *testing.T) {
+	runner := &selfTestRunner{version: "fakebin 0.9.0"}
+	report := RunAdapterSelfTest(context.Background(), fakeAdapter{}, runner, AdapterSelfTestOptions{URL: "https://example.com/track/1"})
+	if report.Passed || report.Steps[0].Status != SelfTestFail || !strings.Contains(report.Steps[0].Detail, "older than the minimum 1.0.0") {
+		t.Fatalf("expected version failure, got %+v", report.Steps)
+	}
+	if report.Steps[2].Status != SelfTestSkip || len(runner.specs) != 1 {
+		t.Fatalf("download must be skipped after a failure: %+v (%d runs)", report.Steps, len(runner.specs))
+	}
+
+	runner = &selfTestRunner{version: "fakebin 1.0.0", exit: 1}
+	report = RunAdapterSelfTest(context.Background(), fakeAdapter{}, runner, AdapterSelfTestOptions{URL: "https://example.com/track/1", KeepDir: true})
+	t.Cleanup(func() { _ = os.RemoveAll(report.Dir) })
+	if report.Passed || report.Steps[2].Detail != "fakebin exited 1: ERROR: unable to download" {
+		t.Fatalf("expected download failure, got %+v", report.Steps)
+	}
+	if report.Dir == "" {
+		t.Fatalf("expected --keep to report the download dir")
+	}
+
+	runner = &selfTestRunner{version: "fakebin 1.0.0"}
+	report = RunAdapterSelfTest(context.Background(), fakeAdapter{}, runner, AdapterSelfTestOptions{})
+	if !report.Passed || report.Steps[2].Status != SelfTestSkip || !strings.Contains(report.Steps[2].Detail, "--url") {
+		t.Fatalf("expected download skip without a test URL, got %+v", report.Steps)
+	}
+}
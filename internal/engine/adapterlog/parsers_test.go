@@ -67,3 +67,27 @@ func assertEventKind(t *testing.T, event progress.TrackEvent, kind progress.Trac
 		t.Fatalf("expected kind %s, got %+v", kind, event)
 	}
 }
+
+func TestYTDLPParserEmitsProgressAndArchiveSkips(t *testing.T) {
+	parser := NewYTDLPParser()
+	parser.OnStdoutLine("[download] Downloading item 1 of 3")
+	parser.OnStdoutLine("[download] Destination: /music/Song One [abc123].webm")
+	parser.OnStdoutLine("[download]  42.0% of    5.20MiB at    1.20MiB/s ETA 00:03")
+	parser.OnStdoutLine("[download] 100% of    5.20MiB in 00:00:04 at 1.25MiB/s")
+	parser.OnStdoutLine("[download] Downloading item 2 of 3")
+	parser.OnStdoutLine("[download] Song Two has already been recorded in the archive")
+
+	events := parser.Flush()
+	if len(events) != 6 {
+		t.Fatalf("expected 6 events, got %+v", events)
+	}
+	assertEventKind(t, events[2], progress.TrackProgress)
+	if events[2].Percent != 42 {
+		t.Fatalf("expected 42%% progress, got %+v", events[2])
+	}
+	assertEventKind(t, events[3], progress.TrackDone)
+	assertEventKind(t, events[5], progress.TrackSkip)
+	if events[5].Reason != "already_in_archive" || events[5].TrackName != "Song Two" {
+		t.Fatalf("unexpected archive skip event: %+v", events[5])
+	}
+}
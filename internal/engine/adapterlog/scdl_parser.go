@@ -74,7 +74,16 @@ func (p *SCDLParser) onLine(line string) {
 				Reason:    "already_downloaded",
 			})
 			return
-		case compact.LineEventFragmentProgress:
+		case compact.LineEventAlreadyInArchive:
+			p.append(progress.TrackEvent{
+				Kind:      progress.TrackSkip,
+				TrackName: strings.TrimSpace(parsed.Text),
+				Index:     p.currentIndex,
+				Total:     p.currentTotal,
+				Reason:    "already_in_archive",
+			})
+			return
+		case compact.LineEventFragmentProgress, compact.LineEventDownloadProgress:
 			p.append(progress.TrackEvent{
 				Kind:      progress.TrackProgress,
 				TrackName: p.currentName,
@@ -202,3 +211,9 @@ func (p *SpotDLParser) Flush() []progress.TrackEvent {
 	p.events = p.events[:0]
 	return out
 }
+
+// NewYTDLPParser returns a parser for standalone yt-dlp runs. scdl drives
+// yt-dlp under the hood, so both adapters share the same line grammar.
+func NewYTDLPParser() Parser {
+	return &SCDLParser{}
+}
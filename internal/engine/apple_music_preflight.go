@@ -0,0 +1,365 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	appleMusicAPIPageSize          = 100
+	appleMusicAPIMaxPages          = 500
+	appleMusicDefaultStorefront    = "us"
+	appleMusicWebOrigin            = "https://music.apple.com"
+	appleMusicCatalogSongType      = "songs"
+	appleMusicLibrarySongType      = "library-songs"
+	appleMusicLibraryPlaylistIDTag = "p."
+)
+
+var (
+	appleMusicAPIBaseURL    = "https://amp-api.music.apple.com"
+	appleMusicAPIHTTPClient = &http.Client{Timeout: 20 * time.Second}
+
+	resolveAppleMusicAPIAuthFn = resolveAppleMusicAPIAuth
+
+	appleMusicStorefrontPattern = regexp.MustCompile(`^[a-z]{2}$`)
+)
+
+type appleMusicResourceKind string
+
+const (
+	appleMusicResourcePlaylist appleMusicResourceKind = "playlist"
+	appleMusicResourceAlbum    appleMusicResourceKind = "album"
+	appleMusicResourceSong     appleMusicResourceKind = "song"
+)
+
+var errAppleMusicURLUnsupported = errors.New("expected a music.apple.com playlist, album, or song link")
+
+// appleMusicResource is a parsed music.apple.com link. Library playlists
+// (music.apple.com/library/playlist/p.…) belong to the signed-in account and
+// are read through /v1/me instead of the storefront catalog.
+type appleMusicResource struct {
+	Kind       appleMusicResourceKind
+	ID         string
+	Storefront string
+	Library    bool
+}
+
+// appleMusicAPIAuth is the bearer developer token plus, for library links,
+// the account's media-user-token. Neither value may be logged.
+type appleMusicAPIAuth struct {
+	DeveloperToken string
+	MediaUserToken string
+}
+
+// appleMusicRemoteTrack is one song of an Apple Music playlist or album.
+// Unplayable songs are not licensed in the storefront (or are library-only
+// uploads); gamdl cannot download them.
+type appleMusicRemoteTrack struct {
+	ID       string
+	Title    string
+	Artist   string
+	Album    string
+	URL      string
+	Playable bool
+}
+
+type appleMusicAPISong struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Name       string `json:"name"`
+		ArtistName string `json:"artistName"`
+		AlbumName  string `json:"albumName"`
+		URL        string `json:"url"`
+		PlayParams *struct {
+			ID        string `json:"id"`
+			CatalogID string `json:"catalogId"`
+		} `json:"playParams"`
+	} `json:"attributes"`
+}
+
+type appleMusicAPIError struct {
+	Status string `json:"status"`
+	Code   string `json:"code"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+type appleMusicAPIPage struct {
+	Data   []appleMusicAPISong  `json:"data"`
+	Next   string               `json:"next"`
+	Errors []appleMusicAPIError `json:"errors"`
+}
+
+type appleMusicAPIStorefronts struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+// resolveAppleMusicAPIAuth fetches the web player's public developer token
+// and, for library links only, reads the media-user-token from the cookies
+// file gamdl uses.
+func resolveAppleMusicAPIAuth(ctx context.Context, library bool) (appleMusicAPIAuth, error) {
+	developerToken, err := auth.FetchAppleMusicDeveloperToken(ctx)
+	if err != nil {
+		return appleMusicAPIAuth{}, err
+	}
+	result := appleMusicAPIAuth{DeveloperToken: developerToken}
+	if !library {
+		return result, nil
+	}
+	cookiesPath, _, err := auth.ResolveAppleMusicCookiesFile()
+	if err != nil {
+		return result, fmt.Errorf("%w at %s", err, cookiesPath)
+	}
+	mediaUserToken, err := auth.ReadAppleMusicMediaUserToken(cookiesPath)
+	if err != nil {
+		return result, err
+	}
+	result.MediaUserToken = mediaUserToken
+	return result, nil
+}
+
+// enumerateAppleMusicTracks lists an Apple Music source through the same
+// amp-api endpoints the web player uses.
+func enumerateAppleMusicTracks(ctx context.Context, source config.Source) ([]appleMusicRemoteTrack, string, error) {
+	resource, err := parseAppleMusicResourceURL(source.URL)
+	if err != nil {
+		return nil, "", err
+	}
+	apiAuth, err := resolveAppleMusicAPIAuthFn(ctx, resource.Library)
+	if err != nil {
+		return nil, "", err
+	}
+	base := strings.TrimSuffix(appleMusicAPIBaseURL, "/")
+
+	storefront := resource.Storefront
+	if resource.Library {
+		var payload appleMusicAPIStorefronts
+		if err := getAppleMusicJSON(ctx, base+"/v1/me/storefront", apiAuth, &payload); err != nil {
+			return nil, "", fmt.Errorf("apple music storefront: %w", err)
+		}
+		if len(payload.Data) > 0 && strings.TrimSpace(payload.Data[0].ID) != "" {
+			storefront = strings.TrimSpace(payload.Data[0].ID)
+		}
+		if storefront == "" {
+			storefront = appleMusicDefaultStorefront
+		}
+	}
+
+	var next string
+	switch {
+	case resource.Library:
+		next = fmt.Sprintf("%s/v1/me/library/playlists/%s/tracks?limit=%d", base, url.PathEscape(resource.ID), appleMusicAPIPageSize)
+	case resource.Kind == appleMusicResourceSong:
+		next = fmt.Sprintf("%s/v1/catalog/%s/songs/%s", base, storefront, resource.ID)
+	default:
+		next = fmt.Sprintf("%s/v1/catalog/%s/%ss/%s/tracks?limit=%d", base, storefront, resource.Kind, url.PathEscape(resource.ID), appleMusicAPIPageSize)
+	}
+
+	tracks := []appleMusicRemoteTrack{}
+	seen := map[string]struct{}{}
+	for page := 0; next != "" && page < appleMusicAPIMaxPages; page++ {
+		var payload appleMusicAPIPage
+		if err := getAppleMusicJSON(ctx, next, apiAuth, &payload); err != nil {
+			return nil, "", fmt.Errorf("apple music %s %s: %w", resource.Kind, resource.ID, err)
+		}
+		if len(payload.Errors) > 0 {
+			return nil, "", fmt.Errorf("apple music %s %s: %s", resource.Kind, resource.ID, payload.Errors[0].describe())
+		}
+		for _, item := range payload.Data {
+			track, ok := item.remote(storefront)
+			if !ok {
+				continue
+			}
+			if _, dup := seen[track.ID]; dup {
+				continue
+			}
+			seen[track.ID] = struct{}{}
+			tracks = append(tracks, track)
+		}
+		next = ""
+		if ref := strings.TrimSpace(payload.Next); ref != "" {
+			next = base + ref
+			if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+				next = ref
+			}
+		}
+	}
+	return tracks, storefront, nil
+}
+
+// remote maps an API song onto a catalog id. Music videos and other
+// non-song items are dropped; library songs without a catalog match stay in
+// the list as unplayable so they are reported rather than silently ignored.
+func (s appleMusicAPISong) remote(storefront string) (appleMusicRemoteTrack, bool) {
+	track := appleMusicRemoteTrack{
+		Title:  strings.TrimSpace(s.Attributes.Name),
+		Artist: strings.TrimSpace(s.Attributes.ArtistName),
+		Album:  strings.TrimSpace(s.Attributes.AlbumName),
+		URL:    strings.TrimSpace(s.Attributes.URL),
+	}
+	switch s.Type {
+	case appleMusicCatalogSongType:
+		track.ID = strings.TrimSpace(s.ID)
+		track.Playable = s.Attributes.PlayParams != nil
+	case appleMusicLibrarySongType:
+		if s.Attributes.PlayParams != nil {
+			track.ID = strings.TrimSpace(s.Attributes.PlayParams.CatalogID)
+		}
+		track.Playable = track.ID != ""
+		if track.ID == "" {
+			track.ID = strings.TrimSpace(s.ID)
+		}
+	default:
+		return track, false
+	}
+	if track.ID == "" {
+		return track, false
+	}
+	if track.URL == "" && track.Playable {
+		track.URL = appleMusicSongURL(storefront, track.ID)
+	}
+	return track, true
+}
+
+func (t appleMusicRemoteTrack) spotifyShape() spotifyRemoteTrack {
+	return spotifyRemoteTrack{ID: t.ID, Title: t.Title, Artist: t.Artist, Album: t.Album, URL: t.URL}
+}
+
+func (t appleMusicRemoteTrack) displayName() string {
+	switch {
+	case t.Artist != "" && t.Title != "":
+		return t.Artist + " - " + t.Title
+	default:
+		return t.Title
+	}
+}
+
+func (e appleMusicAPIError) describe() string {
+	message := strings.TrimSpace(e.Detail)
+	if message == "" {
+		message = strings.TrimSpace(e.Title)
+	}
+	if code := strings.TrimSpace(e.Status); code != "" {
+		return fmt.Sprintf("%s (status %s)", message, code)
+	}
+	return message
+}
+
+func getAppleMusicJSON(ctx context.Context, rawURL string, apiAuth appleMusicAPIAuth, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Origin", appleMusicWebOrigin)
+	req.Header.Set("Authorization", "Bearer "+apiAuth.DeveloperToken)
+	if apiAuth.MediaUserToken != "" {
+		req.Header.Set("Media-User-Token", apiAuth.MediaUserToken)
+	}
+	resp, err := appleMusicAPIHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden:
+		if apiAuth.MediaUserToken != "" {
+			return fmt.Errorf("HTTP %d; re-export cookies from a signed-in music.apple.com session", resp.StatusCode)
+		}
+		return fmt.Errorf("HTTP %d; apple music developer token rejected", resp.StatusCode)
+	default:
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// buildAppleMusicPreflight diffs playable remote songs against the state
+// file the same way spotify+deemix does; unplayable songs are returned
+// separately so they are reported as skipped instead of failing the run.
+func buildAppleMusicPreflight(
+	remoteTracks []appleMusicRemoteTrack,
+	state spotifySyncState,
+	targetDir string,
+	mode SoundCloudMode,
+) (SoundCloudPreflight, []string, []string, []appleMusicRemoteTrack) {
+	playable := make([]spotifyRemoteTrack, 0, len(remoteTracks))
+	unavailable := []appleMusicRemoteTrack{}
+	for _, track := range remoteTracks {
+		if !track.Playable {
+			unavailable = append(unavailable, track)
+			continue
+		}
+		playable = append(playable, track.spotifyShape())
+	}
+	preflight, _, _, planned, existing := buildSpotifyPreflight(playable, state, targetDir, mode)
+	return preflight, planned, existing, unavailable
+}
+
+// parseAppleMusicResourceURL understands
+// music.apple.com/{storefront}/{playlist|album|song}/{slug}/{id}, album links
+// narrowed to one song with ?i=, and music.apple.com/library/playlist/{id}.
+func parseAppleMusicResourceURL(rawURL string) (appleMusicResource, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return appleMusicResource{}, err
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host != "music.apple.com" && host != "geo.music.apple.com" {
+		return appleMusicResource{}, errAppleMusicURLUnsupported
+	}
+	parts := strings.Split(strings.Trim(path.Clean(parsed.Path), "/"), "/")
+	if len(parts) < 3 {
+		return appleMusicResource{}, errAppleMusicURLUnsupported
+	}
+
+	if parts[0] == "library" {
+		id := parts[len(parts)-1]
+		if parts[1] != string(appleMusicResourcePlaylist) || !strings.HasPrefix(id, appleMusicLibraryPlaylistIDTag) {
+			return appleMusicResource{}, errAppleMusicURLUnsupported
+		}
+		return appleMusicResource{Kind: appleMusicResourcePlaylist, ID: id, Library: true}, nil
+	}
+
+	storefront := strings.ToLower(parts[0])
+	if !appleMusicStorefrontPattern.MatchString(storefront) {
+		return appleMusicResource{}, errAppleMusicURLUnsupported
+	}
+	id := parts[len(parts)-1]
+	resource := appleMusicResource{Kind: appleMusicResourceKind(parts[1]), ID: id, Storefront: storefront}
+	switch resource.Kind {
+	case appleMusicResourcePlaylist:
+		if !strings.HasPrefix(id, "pl.") {
+			return appleMusicResource{}, errAppleMusicURLUnsupported
+		}
+	case appleMusicResourceAlbum:
+		if !appleMusicIDPattern.MatchString(id) {
+			return appleMusicResource{}, errAppleMusicURLUnsupported
+		}
+		if songID := strings.TrimSpace(parsed.Query().Get("i")); appleMusicIDPattern.MatchString(songID) {
+			resource.Kind = appleMusicResourceSong
+			resource.ID = songID
+		}
+	case appleMusicResourceSong:
+		if !appleMusicIDPattern.MatchString(id) {
+			return appleMusicResource{}, errAppleMusicURLUnsupported
+		}
+	default:
+		return appleMusicResource{}, errAppleMusicURLUnsupported
+	}
+	return resource, nil
+}
@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestParseAppleMusicResourceURL(t *testing.T) {
+	cases := []struct {
+		raw      string
+		expected appleMusicResource
+	}{
+		{
+			raw:      "https://music.apple.com/us/playlist/chill/pl.u-abc123",
+			expected: appleMusicResource{Kind: appleMusicResourcePlaylist, ID: "pl.u-abc123", Storefront: "us"},
+		},
+		{
+			raw:      "https://music.apple.com/gb/album/blue/1440833098",
+			expected: appleMusicResource{Kind: appleMusicResourceAlbum, ID: "1440833098", Storefront: "gb"},
+		},
+		{
+			raw:      "https://music.apple.com/us/album/blue/1440833098?i=1440833541",
+			expected: appleMusicResource{Kind: appleMusicResourceSong, ID: "1440833541", Storefront: "us"},
+		},
+		{
+			raw:      "https://music.apple.com/de/song/river/1440833541",
+			expected: appleMusicResource{Kind: appleMusicResourceSong, ID: "1440833541", Storefront: "de"},
+		},
+		{
+			raw:      "https://music.apple.com/library/playlist/p.AbCdEf",
+			expected: appleMusicResource{Kind: appleMusicResourcePlaylist, ID: "p.AbCdEf", Library: true},
+		},
+	}
+	for _, tc := range cases {
+		got, err := parseAppleMusicResourceURL(tc.raw)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tc.raw, err)
+		}
+		if got != tc.expected {
+			t.Fatalf("parse %s: got %+v", tc.raw, got)
+		}
+	}
+	for _, raw := range []string{
+		"https://open.spotify.com/playlist/abc",
+		"https://music.apple.com/us/artist/joni/1234",
+		"https://music.apple.com/us/playlist/chill/1234",
+		"https://music.apple.com/library/albums/l.abc",
+	} {
+		if _, err := parseAppleMusicResourceURL(raw); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestAppleMusicSyncStateRoundTrip(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "am.sync.applemusic")
+	if err := os.WriteFile(statePath, []byte("apple_music 111\nhttps://music.apple.com/us/album/x/9?i=222\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if err := appendAppleMusicSyncStateEntry(statePath, "333", spotifyStateEntry{DisplayName: "Artist - Title", LocalPath: "Artist/Title.m4a"}); err != nil {
+		t.Fatalf("append state: %v", err)
+	}
+	if err := appendAppleMusicSyncStateEntry(statePath, "i.libraryOnly", spotifyStateEntry{}); err == nil {
+		t.Fatalf("expected library-only id to be rejected")
+	}
+
+	state, err := parseAppleMusicSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	for _, id := range []string{"111", "222", "333"} {
+		if _, ok := state.KnownIDs[id]; !ok {
+			t.Fatalf("expected %s in state, got %+v", id, state.KnownIDs)
+		}
+	}
+	if entry := state.Entries["333"]; entry.DisplayName != "Artist - Title" || entry.LocalPath != "Artist/Title.m4a" {
+		t.Fatalf("unexpected state entry %+v", entry)
+	}
+}
+
+func TestEnumerateAppleMusicTracksFollowsPagesAndMarksUnplayable(t *testing.T) {
+	var gotAuth, gotMediaUserToken []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = append(gotAuth, r.Header.Get("Authorization"))
+		gotMediaUserToken = append(gotMediaUserToken, r.Header.Get("Media-User-Token"))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/catalog/us/playlists/pl.u-abc/tracks":
+			if r.URL.Query().Get("offset") == "" {
+				fmt.Fprint(w, `{"data":[`+
+					`{"id":"1","type":"songs","attributes":{"name":"One","artistName":"A","url":"https://music.apple.com/us/album/x/9?i=1","playParams":{"id":"1"}}},`+
+					`{"id":"2","type":"songs","attributes":{"name":"Two","artistName":"B"}},`+
+					`{"id":"3","type":"music-videos","attributes":{"name":"Video","playParams":{"id":"3"}}}`+
+					`],"next":"/v1/catalog/us/playlists/pl.u-abc/tracks?offset=100"}`)
+				return
+			}
+			fmt.Fprint(w, `{"data":[{"id":"4","type":"songs","attributes":{"name":"Four","artistName":"C","playParams":{"id":"4"}}},`+
+				`{"id":"1","type":"songs","attributes":{"name":"One","playParams":{"id":"1"}}}]}`)
+		case "/v1/me/storefront":
+			fmt.Fprint(w, `{"data":[{"id":"gb"}]}`)
+		case "/v1/me/library/playlists/p.Lib/tracks":
+			fmt.Fprint(w, `{"data":[`+
+				`{"id":"i.aaa","type":"library-songs","attributes":{"name":"Catalog","artistName":"D","playParams":{"id":"i.aaa","catalogId":"55"}}},`+
+				`{"id":"i.bbb","type":"library-songs","attributes":{"name":"Upload","artistName":"E","playParams":{"id":"i.bbb"}}}`+
+				`]}`)
+		case "/v1/catalog/us/albums/9/tracks":
+			fmt.Fprint(w, `{"errors":[{"status":"404","title":"Resource Not Found","detail":"Resource with requested id was not found"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origBase := appleMusicAPIBaseURL
+	origAuth := resolveAppleMusicAPIAuthFn
+	t.Cleanup(func() {
+		appleMusicAPIBaseURL = origBase
+		resolveAppleMusicAPIAuthFn = origAuth
+	})
+	appleMusicAPIBaseURL = server.URL
+	resolveAppleMusicAPIAuthFn = func(ctx context.Context, library bool) (appleMusicAPIAuth, error) {
+		apiAuth := appleMusicAPIAuth{DeveloperToken: "dev-token"}
+		if library {
+			apiAuth.MediaUserToken = "user-token"
+		}
+		return apiAuth, nil
+	}
+
+	tracks, storefront, err := enumerateAppleMusicTracks(context.Background(), config.Source{URL: "https://music.apple.com/us/playlist/chill/pl.u-abc"})
+	if err != nil {
+		t.Fatalf("enumerate playlist: %v", err)
+	}
+	if storefront != "us" || len(tracks) != 3 {
+		t.Fatalf("expected 3 de-duplicated songs in us, got %s %+v", storefront, tracks)
+	}
+	if tracks[0].displayName() != "A - One" || tracks[0].URL != "https://music.apple.com/us/album/x/9?i=1" {
+		t.Fatalf("unexpected first track %+v", tracks[0])
+	}
+	if tracks[1].Playable || !tracks[2].Playable || tracks[2].URL != "https://music.apple.com/us/song/4" {
+		t.Fatalf("expected playable flags to follow playParams, got %+v", tracks)
+	}
+	if gotAuth[0] != "Bearer dev-token" || gotMediaUserToken[0] != "" {
+		t.Fatalf("expected catalog requests to send only the developer token, got %v %v", gotAuth, gotMediaUserToken)
+	}
+
+	library, storefront, err := enumerateAppleMusicTracks(context.Background(), config.Source{URL: "https://music.apple.com/library/playlist/p.Lib"})
+	if err != nil {
+		t.Fatalf("enumerate library playlist: %v", err)
+	}
+	if storefront != "gb" || len(library) != 2 || library[0].ID != "55" || !library[0].Playable || library[1].Playable {
+		t.Fatalf("expected library songs mapped to catalog ids, got %s %+v", storefront, library)
+	}
+	if library[0].URL != "https://music.apple.com/gb/song/55" || gotMediaUserToken[len(gotMediaUserToken)-1] != "user-token" {
+		t.Fatalf("expected library lookup with media-user-token, got %+v", library[0])
+	}
+
+	if _, _, err := enumerateAppleMusicTracks(context.Background(), config.Source{URL: "https://music.apple.com/us/album/blue/9"}); err == nil {
+		t.Fatalf("expected apple music api error payload to fail enumeration")
+	}
+}
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// appleMusicStateHeader opens Apple Music state files; v1 files are
+// migrated on the next append.
+const appleMusicStateHeader = "# udl apple music state v2"
+
+var appleMusicIDPattern = regexp.MustCompile(`^[0-9]{1,20}$`)
+
+// parseAppleMusicSyncState reads an Apple Music source's state file. Lines
+// use the spotify v3 layout (id, title=, path=, adapter=, added_at=) keyed
+// by catalog song ids; bare song links and "apple_music <id>" lines are
+// accepted as well.
+func parseAppleMusicSyncState(path string) (spotifySyncState, error) {
+	return parseTrackSyncState(path, parseAppleMusicStateLine)
+}
+
+func appendAppleMusicSyncStateEntry(path string, id string, entry spotifyStateEntry) error {
+	trackID := extractAppleMusicTrackID(id)
+	if trackID == "" {
+		return errors.New("apple music song id must not be empty")
+	}
+	return appendTrackSyncStateEntry(path, appleMusicStateHeader, parseAppleMusicStateLine, trackID, entry)
+}
+
+func parseAppleMusicStateLine(line string) (string, spotifyStateEntry) {
+	entry := spotifyStateEntry{}
+	raw := strings.TrimSpace(line)
+	if raw == "" {
+		return "", entry
+	}
+
+	parts := strings.Split(raw, "\t")
+	id := extractAppleMusicTrackID(strings.TrimSpace(parts[0]))
+	if id == "" {
+		fields := strings.Fields(raw)
+		if len(fields) >= 2 && fields[0] == "apple_music" {
+			id = extractAppleMusicTrackID(fields[1])
+		}
+		return id, entry
+	}
+
+	for _, field := range parts[1:] {
+		parseTrackStateField(&entry, field)
+	}
+	return id, entry
+}
+
+// extractAppleMusicTrackID accepts a numeric catalog id, a song link, or an
+// album link that selects one song with ?i=.
+func extractAppleMusicTrackID(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return ""
+	}
+	if strings.HasPrefix(trimmed, "https://") || strings.HasPrefix(trimmed, "http://") {
+		resource, err := parseAppleMusicResourceURL(trimmed)
+		if err != nil || resource.Kind != appleMusicResourceSong {
+			return ""
+		}
+		return resource.ID
+	}
+	if !appleMusicIDPattern.MatchString(trimmed) {
+		return ""
+	}
+	return trimmed
+}
+
+func appleMusicSongURL(storefront string, songID string) string {
+	return "https://music.apple.com/" + strings.TrimSpace(storefront) + "/song/" + strings.TrimSpace(songID)
+}
@@ -0,0 +1,300 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	ArchiveLinkSymlink   = "symlink"
+	ArchiveLinkTombstone = "tombstone"
+
+	archiveTombstonePrefix = "# udl: archive moved to "
+)
+
+// ArchiveMigration is the planned move of one legacy archive kept in a
+// source's target_dir into the managed <state_dir>/<id>.archive.txt file.
+type ArchiveMigration struct {
+	SourceID string
+	From     string
+	To       string
+	// Entries counts archive lines in From; Duplicates are already in To.
+	Entries    int
+	Duplicates int
+	// ClearConfig is set when the source's defaults.archive_file points at
+	// From and must be removed so the managed path applies.
+	ClearConfig bool
+	// Shared is set when several sources claim From; shared archives always
+	// get a tombstone since a symlink can only point at one of them.
+	Shared bool
+	// Link is how From was replaced, filled in by ApplyArchiveMigrations.
+	Link string
+}
+
+// ManagedArchivePath is the state_dir archive a source uses once migrated.
+// An absolute archive_file is treated as legacy and replaced by the default
+// per-source name.
+func ManagedArchivePath(source config.Source, defaults config.Defaults) (string, error) {
+	defaults = defaults.ForSource(source)
+	name := strings.TrimSpace(defaults.ArchiveFile)
+	expanded, err := config.ExpandPath(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "" || filepath.IsAbs(expanded) {
+		name = "archive.txt"
+	}
+	return config.ResolveArchiveFile(defaults.StateDir, name, source.ID)
+}
+
+// ResolveSpotDLArchivePath keeps spotdl on a legacy target_dir archive until
+// it is migrated; new and migrated sources use the managed state_dir archive.
+func ResolveSpotDLArchivePath(source config.Source, defaults config.Defaults) (string, error) {
+	defaults = defaults.ForSource(source)
+	expanded, err := config.ExpandPath(strings.TrimSpace(defaults.ArchiveFile))
+	if err != nil {
+		return "", err
+	}
+	if filepath.IsAbs(expanded) {
+		return filepath.Clean(expanded), nil
+	}
+	managed, err := ManagedArchivePath(source, defaults)
+	if err != nil {
+		return "", err
+	}
+	if fileExists(managed) {
+		return managed, nil
+	}
+	for _, legacy := range legacyArchiveCandidates(source, defaults) {
+		if isLegacyArchiveFile(legacy) {
+			return legacy, nil
+		}
+	}
+	return managed, nil
+}
+
+// PlanArchiveMigrations finds archives left in target_dir by sources set up
+// before archives moved into state_dir.
+func PlanArchiveMigrations(cfg config.Config) ([]ArchiveMigration, error) {
+	planned := []ArchiveMigration{}
+	claims := map[string]int{}
+	for _, source := range cfg.Sources {
+		switch source.Adapter.Kind {
+		case "spotdl", "scdl", "scdl-freedl", "ytdlp":
+		default:
+			continue
+		}
+		managed, err := ManagedArchivePath(source, cfg.Defaults)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", source.ID, err)
+		}
+		configured, err := config.ExpandPath(strings.TrimSpace(source.Defaults.ArchiveFile))
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", source.ID, err)
+		}
+		for _, legacy := range legacyArchiveCandidates(source, cfg.Defaults) {
+			if legacy == managed || !isLegacyArchiveFile(legacy) {
+				continue
+			}
+			migration := ArchiveMigration{
+				SourceID:    source.ID,
+				From:        legacy,
+				To:          managed,
+				ClearConfig: filepath.IsAbs(configured) && filepath.Clean(configured) == legacy,
+			}
+			if err := countArchiveMigration(&migration); err != nil {
+				return nil, fmt.Errorf("source %s: %w", source.ID, err)
+			}
+			claims[legacy]++
+			planned = append(planned, migration)
+		}
+	}
+	for i := range planned {
+		planned[i].Shared = claims[planned[i].From] > 1
+	}
+	return planned, nil
+}
+
+// ApplyArchiveMigrations merges each legacy archive into its managed file and
+// replaces the legacy file with link (ArchiveLinkSymlink or
+// ArchiveLinkTombstone). Symlinks fall back to tombstones where the platform
+// refuses them. It holds the lock of every migrated source under stateDir.
+func ApplyArchiveMigrations(ctx context.Context, stateDir string, migrations []ArchiveMigration, link string) error {
+	locked := make([]string, 0, len(migrations))
+	for _, migration := range migrations {
+		locked = append(locked, migration.SourceID)
+	}
+	unlock, err := lockSources(ctx, stateDir, locked)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for i := range migrations {
+		migration := &migrations[i]
+		existing, err := readSoundCloudArchiveLines(migration.To)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", migration.To, err)
+		}
+		legacy, err := readSoundCloudArchiveLines(migration.From)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", migration.From, err)
+		}
+		if err := writeSoundCloudLinesAtomically(migration.To, ".udl-archive-migrate-*.tmp", mergeArchiveLines(existing, legacy)); err != nil {
+			return fmt.Errorf("write %s: %w", migration.To, err)
+		}
+	}
+
+	replaced := map[string]string{}
+	for i := range migrations {
+		migration := &migrations[i]
+		if done, ok := replaced[migration.From]; ok {
+			migration.Link = done
+			continue
+		}
+		mode := link
+		if migration.Shared {
+			mode = ArchiveLinkTombstone
+		}
+		if mode == ArchiveLinkSymlink {
+			if err := replaceWithSymlink(migration.From, migration.To); err != nil {
+				mode = ArchiveLinkTombstone
+			}
+		}
+		if mode == ArchiveLinkTombstone {
+			if err := writeArchiveTombstone(migration.From, migration.To); err != nil {
+				return fmt.Errorf("replace %s: %w", migration.From, err)
+			}
+		}
+		migration.Link = mode
+		replaced[migration.From] = mode
+	}
+	return nil
+}
+
+// legacyArchiveCandidates lists where pre-state_dir configs kept a source's
+// archive: spotdl and scdl ran in target_dir, so a relative archive_file
+// landed there, optionally prefixed with the source id.
+func legacyArchiveCandidates(source config.Source, defaults config.Defaults) []string {
+	defaults = defaults.ForSource(source)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil || strings.TrimSpace(targetDir) == "" {
+		return nil
+	}
+	targetDir = filepath.Clean(targetDir)
+	name := strings.TrimSpace(defaults.ArchiveFile)
+	if name == "" {
+		name = "archive.txt"
+	}
+	expanded, err := config.ExpandPath(name)
+	if err != nil {
+		return nil
+	}
+	if filepath.IsAbs(expanded) {
+		if _, inside := RemapPathPrefix(expanded, targetDir, targetDir); inside {
+			return []string{filepath.Clean(expanded)}
+		}
+		return nil
+	}
+	candidates := []string{filepath.Join(targetDir, expanded)}
+	if !strings.ContainsRune(expanded, filepath.Separator) {
+		candidates = append(candidates, filepath.Join(targetDir, source.ID+"."+expanded))
+	}
+	return candidates
+}
+
+// isLegacyArchiveFile reports a regular archive file; symlinks and tombstones
+// left by an earlier migration do not count.
+func isLegacyArchiveFile(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return !strings.HasPrefix(string(raw), archiveTombstonePrefix)
+}
+
+func countArchiveMigration(migration *ArchiveMigration) error {
+	existing, err := readSoundCloudArchiveLines(migration.To)
+	if err != nil {
+		return err
+	}
+	legacy, err := readSoundCloudArchiveLines(migration.From)
+	if err != nil {
+		return err
+	}
+	seen := map[string]struct{}{}
+	for _, line := range existing {
+		seen[strings.TrimSpace(line.Raw)] = struct{}{}
+	}
+	for _, line := range legacy {
+		key := strings.TrimSpace(line.Raw)
+		if key == "" {
+			continue
+		}
+		migration.Entries++
+		if _, ok := seen[key]; ok {
+			migration.Duplicates++
+		}
+	}
+	return nil
+}
+
+// mergeArchiveLines appends legacy lines missing from existing. Archives are
+// sets, so order beyond "existing first" does not matter.
+func mergeArchiveLines(existing []soundCloudArchiveLine, legacy []soundCloudArchiveLine) []string {
+	lines := make([]string, 0, len(existing)+len(legacy))
+	seen := map[string]struct{}{}
+	for _, line := range existing {
+		lines = append(lines, line.Raw)
+		seen[strings.TrimSpace(line.Raw)] = struct{}{}
+	}
+	for _, line := range legacy {
+		key := strings.TrimSpace(line.Raw)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		lines = append(lines, line.Raw)
+	}
+	return lines
+}
+
+func replaceWithSymlink(path string, target string) error {
+	temp := path + ".udl-link"
+	_ = os.Remove(temp)
+	if err := os.Symlink(target, temp); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, path); err != nil {
+		_ = os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
+// writeArchiveTombstone leaves a read-only marker so tooling still pointed at
+// the old path fails instead of silently starting a second archive.
+func writeArchiveTombstone(path string, target string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	content := archiveTombstonePrefix + target + " (udl config migrate-archives)\n"
+	return os.WriteFile(path, []byte(content), 0o444)
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
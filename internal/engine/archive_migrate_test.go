@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestArchiveMigrationMergesLegacyArchiveAndLeavesSymlink(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	legacy := filepath.Join(targetDir, "archive.txt")
+	if err := os.WriteFile(legacy, []byte("soundcloud 1\nsoundcloud 2\n"), 0o644); err != nil {
+		t.Fatalf("write legacy archive: %v", err)
+	}
+	managed := filepath.Join(stateDir, "sc.archive.txt")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	if err := os.WriteFile(managed, []byte("soundcloud 2\nsoundcloud 3\n"), 0o644); err != nil {
+		t.Fatalf("write managed archive: %v", err)
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sc",
+			Type:      config.SourceTypeSoundCloud,
+			TargetDir: targetDir,
+			Adapter:   config.AdapterSpec{Kind: "scdl"},
+		}},
+	}
+	migrations, err := PlanArchiveMigrations(cfg)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(migrations) != 1 || migrations[0].From != legacy || migrations[0].To != managed {
+		t.Fatalf("unexpected migrations: %+v", migrations)
+	}
+	if migrations[0].Entries != 2 || migrations[0].Duplicates != 1 {
+		t.Fatalf("unexpected counts: %+v", migrations[0])
+	}
+
+	if err := ApplyArchiveMigrations(context.Background(), stateDir, migrations, ArchiveLinkSymlink); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	raw, err := os.ReadFile(managed)
+	if err != nil {
+		t.Fatalf("read managed archive: %v", err)
+	}
+	if got := string(raw); got != "soundcloud 2\nsoundcloud 3\nsoundcloud 1\n" {
+		t.Fatalf("unexpected merged archive: %q", got)
+	}
+	if migrations[0].Link == ArchiveLinkSymlink {
+		if target, err := os.Readlink(legacy); err != nil || target != managed {
+			t.Fatalf("expected symlink to managed archive, got %q err=%v", target, err)
+		}
+	}
+
+	again, err := PlanArchiveMigrations(cfg)
+	if err != nil {
+		t.Fatalf("plan after apply: %v", err)
+	}
+	if len(again) != 0 {
+		t.Fatalf("expected migrated archive to be skipped, got %+v", again)
+	}
+}
+
+func TestArchiveMigrationSharedLegacyArchiveGetsTombstone(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	legacy := filepath.Join(targetDir, "archive.txt")
+	if err := os.WriteFile(legacy, []byte("spotify:track:a\n"), 0o644); err != nil {
+		t.Fatalf("write legacy archive: %v", err)
+	}
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{
+			{ID: "sp-a", Type: config.SourceTypeSpotify, TargetDir: targetDir, Adapter: config.AdapterSpec{Kind: "spotdl"}},
+			{ID: "sp-b", Type: config.SourceTypeSpotify, TargetDir: targetDir, Adapter: config.AdapterSpec{Kind: "spotdl"}},
+		},
+	}
+
+	path, err := ResolveSpotDLArchivePath(cfg.Sources[0], cfg.Defaults)
+	if err != nil || path != legacy {
+		t.Fatalf("expected spotdl to keep the unmigrated legacy archive, got %q err=%v", path, err)
+	}
+
+	migrations, err := PlanArchiveMigrations(cfg)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(migrations) != 2 || !migrations[0].Shared || !migrations[1].Shared {
+		t.Fatalf("expected shared migrations, got %+v", migrations)
+	}
+	if err := ApplyArchiveMigrations(context.Background(), stateDir, migrations, ArchiveLinkSymlink); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	for _, id := range []string{"sp-a", "sp-b"} {
+		raw, err := os.ReadFile(filepath.Join(stateDir, id+".archive.txt"))
+		if err != nil || string(raw) != "spotify:track:a\n" {
+			t.Fatalf("expected %s archive copy, got %q err=%v", id, raw, err)
+		}
+	}
+	raw, err := os.ReadFile(legacy)
+	if err != nil || !strings.HasPrefix(string(raw), archiveTombstonePrefix) {
+		t.Fatalf("expected tombstone at legacy path, got %q err=%v", raw, err)
+	}
+
+	path, err = ResolveSpotDLArchivePath(cfg.Sources[1], cfg.Defaults)
+	if err != nil || path != filepath.Join(stateDir, "sp-b.archive.txt") {
+		t.Fatalf("expected spotdl to use managed archive after migration, got %q err=%v", path, err)
+	}
+}
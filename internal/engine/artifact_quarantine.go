@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const (
+	quarantineDirName      = "quarantine"
+	quarantineManifestName = "manifest.json"
+	quarantineFilesDirName = "files"
+)
+
+// ArtifactQuarantineManifest describes one quarantine folder: the partial
+// downloads a failed run left behind, keyed by where they used to live so
+// they can be inspected or moved back to resume by hand.
+type ArtifactQuarantineManifest struct {
+	SourceID      string                   `json:"source_id"`
+	TargetDir     string                   `json:"target_dir"`
+	QuarantinedAt time.Time                `json:"quarantined_at"`
+	Files         []ArtifactQuarantineFile `json:"files"`
+}
+
+// ArtifactQuarantineFile is one moved artifact. Path is relative to the
+// quarantine folder.
+type ArtifactQuarantineFile struct {
+	OriginalPath string    `json:"original_path"`
+	Path         string    `json:"path"`
+	Bytes        int64     `json:"bytes"`
+	ModTime      time.Time `json:"mod_time"`
+}
+
+// quarantineNewArtifacts moves the artifacts matching patterns that are not
+// in baseline from dir into a fresh <state_dir>/quarantine/<source>/<stamp>
+// folder, under files/ with their paths relative to dir, and writes
+// manifest.json next to them. It returns the folder, or "" when there was
+// nothing to move. Files already moved stay listed if a later one fails.
+func quarantineNewArtifacts(stateDir string, sourceID string, dir string, baseline map[string]struct{}, patterns []string, now time.Time) (string, []ArtifactQuarantineFile, error) {
+	current, err := snapshotArtifacts(dir, patterns)
+	if err != nil {
+		return "", nil, err
+	}
+	paths := make([]string, 0, len(current))
+	for path := range current {
+		if _, existed := baseline[path]; !existed {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return "", nil, nil
+	}
+	slices.Sort(paths)
+
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("resolve state_dir: %w", err)
+	}
+	quarantineDir, err := createQuarantineDir(filepath.Join(root, quarantineDirName, sourceID), now.UTC().Format("20060102-150405"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	manifest := ArtifactQuarantineManifest{SourceID: sourceID, TargetDir: dir, QuarantinedAt: now.UTC(), Files: []ArtifactQuarantineFile{}}
+	var moveErr error
+	for _, path := range paths {
+		info, statErr := os.Stat(path)
+		if statErr != nil {
+			if os.IsNotExist(statErr) {
+				continue
+			}
+			moveErr = statErr
+			break
+		}
+		rel := filepath.Join(quarantineFilesDirName, pruneTrashRelPath(dir, path))
+		if err := moveFile(path, filepath.Join(quarantineDir, rel)); err != nil {
+			moveErr = fmt.Errorf("move %s: %w", path, err)
+			break
+		}
+		manifest.Files = append(manifest.Files, ArtifactQuarantineFile{
+			OriginalPath: path,
+			Path:         filepath.ToSlash(rel),
+			Bytes:        info.Size(),
+			ModTime:      info.ModTime().UTC(),
+		})
+	}
+
+	payload, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return quarantineDir, manifest.Files, err
+	}
+	if err := os.WriteFile(filepath.Join(quarantineDir, quarantineManifestName), append(payload, '\n'), 0o644); err != nil && moveErr == nil {
+		moveErr = fmt.Errorf("write quarantine manifest: %w", err)
+	}
+	return quarantineDir, manifest.Files, moveErr
+}
+
+// createQuarantineDir creates parent/stamp, or parent/stamp-N when a run
+// retried within the same second already used it.
+func createQuarantineDir(parent string, stamp string) (string, error) {
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return "", err
+	}
+	for attempt := 1; ; attempt++ {
+		name := stamp
+		if attempt > 1 {
+			name = stamp + "-" + strconv.Itoa(attempt)
+		}
+		dir := filepath.Join(parent, name)
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			return dir, nil
+		}
+		if !os.IsExist(err) || attempt >= 100 {
+			return "", err
+		}
+	}
+}
+
+func (s *Syncer) quarantineArtifactsOnFailure(cfg config.Config, sourceID string, dir string, preArtifacts map[string]struct{}, patterns []string) {
+	quarantineDir, files, err := quarantineNewArtifacts(cfg.Defaults.StateDir, sourceID, dir, preArtifacts, patterns, s.Now())
+	if err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourceFailed,
+			SourceID:  sourceID,
+			Message:   fmt.Sprintf("[%s] artifact quarantine failed after %d file(s): %v", sourceID, len(files), err),
+		})
+		return
+	}
+	if len(files) == 0 {
+		return
+	}
+	moved := make([]string, 0, len(files))
+	for _, file := range files {
+		moved = append(moved, file.OriginalPath)
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourceFailed,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] quarantined %d partial artifact(s) in %s", sourceID, len(files), quarantineDir),
+		Details: map[string]any{
+			"quarantined_artifacts": moved,
+			"quarantine_dir":        quarantineDir,
+		},
+	})
+}
@@ -0,0 +1,381 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// Artwork scan reasons: the file has no embedded cover, or its largest
+// cover is narrower than ArtworkOptions.MinSize.
+const (
+	ArtworkReasonMissing = "missing"
+	ArtworkReasonLowRes  = "low-res"
+)
+
+// DefaultArtworkMinSize is the cover edge length, in pixels, below which a
+// cover counts as low resolution.
+const DefaultArtworkMinSize = 500
+
+// ArtworkOptions controls ScanArtwork and ApplyArtworkPlan.
+type ArtworkOptions struct {
+	MinSize      int
+	ProbeTimeout time.Duration
+}
+
+// ArtworkCandidate is a tracked file whose embedded cover is missing or
+// smaller than the minimum size. Width and Height describe the current
+// cover; ArtworkURL is where the source service serves the track's cover.
+// Embedded and Note are filled in by ApplyArtworkPlan.
+type ArtworkCandidate struct {
+	TrackID    string `json:"track_id"`
+	LocalPath  string `json:"local_path"`
+	Reason     string `json:"reason"`
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	ArtworkURL string `json:"artwork_url,omitempty"`
+	Embedded   bool   `json:"embedded,omitempty"`
+	Note       string `json:"note,omitempty"`
+}
+
+// SourceArtworkReport is the artwork plan of one source. Unsupported counts
+// files in containers ffmpeg cannot embed a cover into (such as Ogg/Opus);
+// Skipped explains why a source was not scanned at all.
+type SourceArtworkReport struct {
+	SourceID    string             `json:"source_id"`
+	Scanned     int                `json:"scanned"`
+	UpToDate    int                `json:"up_to_date"`
+	Unsupported int                `json:"unsupported,omitempty"`
+	Candidates  []ArtworkCandidate `json:"candidates"`
+	Skipped     string             `json:"skipped,omitempty"`
+	Errors      []string           `json:"errors,omitempty"`
+}
+
+// embeddedArtwork is the largest cover embedded in a file; zero when the
+// file has none.
+type embeddedArtwork struct {
+	Width  int
+	Height int
+}
+
+// trackArtworkLookup returns the cover URL a source service serves for one
+// track id, or "" when the track has none.
+type trackArtworkLookup func(ctx context.Context, trackID string) (string, error)
+
+var (
+	probeEmbeddedArtworkFn  = probeEmbeddedArtwork
+	newTrackArtworkLookupFn = newTrackArtworkLookup
+	downloadTrackArtworkFn  = downloadTrackArtwork
+	embedTrackArtworkFn     = embedTrackArtwork
+)
+
+// artworkEmbedExtensions lists the containers ffmpeg can write an attached
+// picture to.
+var artworkEmbedExtensions = map[string]struct{}{
+	".mp3":  {},
+	".flac": {},
+	".m4a":  {},
+	".mp4":  {},
+}
+
+// ScanArtwork probes the embedded cover of every tracked file of the
+// selected sources (all sources when sourceIDs is empty) and lists the files
+// whose cover is missing or below opts.MinSize on its shorter edge, together
+// with the track's cover URL on the service it was downloaded from. Only
+// SoundCloud, Spotify, and Deezer sources record track ids a cover can be
+// looked up by; other sources are reported as skipped.
+func ScanArtwork(ctx context.Context, cfg config.Config, sourceIDs []string, opts ArtworkOptions) ([]SourceArtworkReport, error) {
+	sources, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MinSize <= 0 {
+		opts.MinSize = DefaultArtworkMinSize
+	}
+	reports := make([]SourceArtworkReport, 0, len(sources))
+	for _, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		reports = append(reports, scanSourceArtwork(ctx, cfg.Defaults, source, opts))
+	}
+	return reports, nil
+}
+
+func scanSourceArtwork(ctx context.Context, defaults config.Defaults, source config.Source, opts ArtworkOptions) SourceArtworkReport {
+	report := SourceArtworkReport{SourceID: source.ID, Candidates: []ArtworkCandidate{}}
+	switch source.Type {
+	case config.SourceTypeSoundCloud, config.SourceTypeSpotify, config.SourceTypeDeezer:
+	default:
+		report.Skipped = fmt.Sprintf("type %s has no track artwork lookup", source.Type)
+		return report
+	}
+	statePath, err := stateFileForVerify(defaults, source)
+	if err != nil || statePath == "" {
+		report.Skipped = "no state file"
+		return report
+	}
+
+	var lookup trackArtworkLookup
+	lookupErr := error(nil)
+	tracked, _ := collectVerifyTrackedFiles(defaults, source)
+	for _, file := range tracked {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+		if !stateEntryHasLocalFile(file.path, "") {
+			continue
+		}
+		report.Scanned++
+		if _, ok := artworkEmbedExtensions[strings.ToLower(filepath.Ext(file.path))]; !ok {
+			report.Unsupported++
+			continue
+		}
+		probeCtx, cancel := withOptionalTimeout(ctx, opts.ProbeTimeout)
+		current, probeErr := probeEmbeddedArtworkFn(probeCtx, file.path)
+		cancel()
+		if probeErr != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", file.path, probeErr))
+			continue
+		}
+		candidate := ArtworkCandidate{TrackID: file.id, LocalPath: file.path, Width: current.Width, Height: current.Height}
+		switch {
+		case current.Width <= 0 || current.Height <= 0:
+			candidate.Reason = ArtworkReasonMissing
+		case min(current.Width, current.Height) < opts.MinSize:
+			candidate.Reason = ArtworkReasonLowRes
+		default:
+			report.UpToDate++
+			continue
+		}
+
+		if lookup == nil && lookupErr == nil {
+			lookup, lookupErr = newTrackArtworkLookupFn(ctx, source)
+			if lookupErr != nil {
+				report.Errors = append(report.Errors, "artwork lookup: "+lookupErr.Error())
+			}
+		}
+		if lookup != nil {
+			artworkURL, err := lookup(ctx, file.id)
+			switch {
+			case err != nil:
+				report.Errors = append(report.Errors, fmt.Sprintf("%s track %s: %v", source.Type, file.id, err))
+			case artworkURL == "":
+				candidate.Note = "no artwork on " + string(source.Type)
+			default:
+				candidate.ArtworkURL = artworkURL
+			}
+		}
+		report.Candidates = append(report.Candidates, candidate)
+	}
+	sort.SliceStable(report.Candidates, func(i, j int) bool {
+		return report.Candidates[i].LocalPath < report.Candidates[j].LocalPath
+	})
+	return report
+}
+
+// ApplyArtworkPlan downloads the cover of every candidate with an
+// ArtworkURL and embeds it in place of the file's current covers, keeping the
+// audio and tags as they are. A download no larger than the current cover is
+// left out. Per-file failures are recorded on the candidate and in the
+// report's errors rather than stopping the pass; it returns how many files
+// were rewritten.
+func ApplyArtworkPlan(ctx context.Context, reports []SourceArtworkReport, opts ArtworkOptions) int {
+	embedded := 0
+	for i := range reports {
+		report := &reports[i]
+		for j := range report.Candidates {
+			if err := ctx.Err(); err != nil {
+				return embedded
+			}
+			candidate := &report.Candidates[j]
+			if candidate.ArtworkURL == "" {
+				continue
+			}
+			if err := applyTrackArtwork(ctx, candidate, opts); err != nil {
+				candidate.Note = err.Error()
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", candidate.LocalPath, err))
+				continue
+			}
+			if candidate.Embedded {
+				embedded++
+			}
+		}
+	}
+	return embedded
+}
+
+func applyTrackArtwork(ctx context.Context, candidate *ArtworkCandidate, opts ArtworkOptions) error {
+	artworkPath, err := downloadTrackArtworkFn(ctx, candidate.ArtworkURL, filepath.Dir(candidate.LocalPath))
+	if err != nil {
+		return fmt.Errorf("artwork download failed: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(artworkPath)
+	}()
+	// WebP and other formats the image package cannot read have no known
+	// size; they are embedded anyway since ffmpeg converts them.
+	if width, height, ok := artworkImageSize(artworkPath); ok && min(width, height) <= min(candidate.Width, candidate.Height) {
+		candidate.Note = fmt.Sprintf("remote artwork is %dx%d, no larger than the embedded cover", width, height)
+		return nil
+	}
+	embedCtx, cancel := withOptionalTimeout(ctx, 4*opts.ProbeTimeout)
+	defer cancel()
+	if err := embedTrackArtworkFn(embedCtx, candidate.LocalPath, artworkPath); err != nil {
+		return fmt.Errorf("embed artwork: %w", err)
+	}
+	candidate.Embedded = true
+	return nil
+}
+
+func artworkImageSize(path string) (int, int, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer file.Close()
+	decoded, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, false
+	}
+	return decoded.Width, decoded.Height, true
+}
+
+// probeEmbeddedArtwork returns the size of the largest video stream ffprobe
+// finds, which for audio files is the attached cover.
+func probeEmbeddedArtwork(ctx context.Context, path string) (embeddedArtwork, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "v",
+		"-show_entries", "stream=width,height",
+		"-of", "json",
+		path,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return embeddedArtwork{}, fmt.Errorf("ffprobe timed out")
+		}
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			return embeddedArtwork{}, fmt.Errorf("%v: %s", err, trimmed)
+		}
+		return embeddedArtwork{}, err
+	}
+	var payload struct {
+		Streams []struct {
+			Width  int `json:"width"`
+			Height int `json:"height"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return embeddedArtwork{}, err
+	}
+	largest := embeddedArtwork{}
+	for _, stream := range payload.Streams {
+		if stream.Width*stream.Height > largest.Width*largest.Height {
+			largest = embeddedArtwork{Width: stream.Width, Height: stream.Height}
+		}
+	}
+	return largest, nil
+}
+
+// embedTrackArtwork remuxes path with artworkPath as its only cover,
+// dropping the covers it had and copying the audio and tags unchanged.
+func embedTrackArtwork(ctx context.Context, path string, artworkPath string) error {
+	return remuxWithTags(ctx, path, []string{
+		"-map", "-0:v",
+		"-map", "1:0",
+		"-c:v", "mjpeg",
+		"-disposition:v:0", "attached_pic",
+	}, artworkPath)
+}
+
+// downloadTrackArtwork saves the cover at rawURL in dir. SoundCloud covers
+// are tried at their original size first, falling back to 500x500.
+func downloadTrackArtwork(ctx context.Context, rawURL string, dir string) (string, error) {
+	if soundCloudArtworkVariantPattern.MatchString(rawURL) {
+		return downloadSoundCloudArtworkVariants(ctx, rawURL, []string{"original", "t500x500"}, dir)
+	}
+	return downloadSoundCloudArtworkURL(ctx, rawURL, dir)
+}
+
+// newTrackArtworkLookup returns the cover lookup for a source's service,
+// resolving its credentials once.
+func newTrackArtworkLookup(ctx context.Context, source config.Source) (trackArtworkLookup, error) {
+	switch source.Type {
+	case config.SourceTypeSoundCloud:
+		clientID, err := resolveSoundCloudAPIClientIDFn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		client := soundCloudAPIClient{BaseURL: soundCloudAPIBaseURL, HTTP: soundCloudAPIHTTPClient, ClientID: clientID}
+		return func(ctx context.Context, trackID string) (string, error) {
+			var payload soundCloudAPITrack
+			if err := client.getJSON(ctx, client.endpoint("/tracks/"+url.PathEscape(trackID), nil), &payload); err != nil {
+				return "", err
+			}
+			artworkURL := strings.TrimSpace(payload.ArtworkURL)
+			if artworkURL == "" {
+				// SoundCloud shows the uploader's avatar for tracks without
+				// their own artwork.
+				artworkURL = strings.TrimSpace(payload.User.AvatarURL)
+			}
+			return artworkURL, nil
+		}, nil
+	case config.SourceTypeSpotify:
+		creds, err := auth.ResolveSpotifyCredentials()
+		if err != nil {
+			return nil, err
+		}
+		token, err := fetchSpotifyAccessTokenFn(ctx, creds)
+		if err != nil {
+			return nil, err
+		}
+		base := strings.TrimSuffix(spotifyAPIBaseURL, "/")
+		return func(ctx context.Context, trackID string) (string, error) {
+			var payload struct {
+				Album struct {
+					Images []spotifyAPIImage `json:"images"`
+				} `json:"album"`
+			}
+			if err := getSpotifyJSON(ctx, base+"/v1/tracks/"+url.PathEscape(trackID), token, &payload); err != nil {
+				return "", err
+			}
+			return firstSpotifyImage(payload.Album.Images), nil
+		}, nil
+	case config.SourceTypeDeezer:
+		base := strings.TrimSuffix(deezerAPIBaseURL, "/")
+		return func(ctx context.Context, trackID string) (string, error) {
+			var payload struct {
+				Album struct {
+					CoverXL string `json:"cover_xl"`
+				} `json:"album"`
+				Error *deezerAPIError `json:"error"`
+			}
+			if err := getDeezerJSON(ctx, base+"/track/"+url.PathEscape(trackID), &payload); err != nil {
+				return "", err
+			}
+			if payload.Error != nil {
+				return "", fmt.Errorf("%s", payload.Error.describe())
+			}
+			return strings.TrimSpace(payload.Album.CoverXL), nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("type %s has no track artwork lookup", source.Type)
+	}
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestScanArtworkPlansMissingAndLowResCoversAndApplyEmbedsLargerOnes(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, targetDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"Good.mp3", "None.mp3", "Small.flac", "Tiny.m4a", "Voice.opus"} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "dz.sync.deezer")
+	for _, entry := range []struct{ id, path string }{
+		{"1", "Good.mp3"},
+		{"2", "None.mp3"},
+		{"3", "Small.flac"},
+		{"4", "Tiny.m4a"},
+		{"5", "Voice.opus"},
+	} {
+		if err := appendDeezerSyncStateEntry(statePath, entry.id, spotifyStateEntry{LocalPath: entry.path}); err != nil {
+			t.Fatalf("append state: %v", err)
+		}
+	}
+
+	origProbe := probeEmbeddedArtworkFn
+	origLookup := newTrackArtworkLookupFn
+	origDownload := downloadTrackArtworkFn
+	origEmbed := embedTrackArtworkFn
+	t.Cleanup(func() {
+		probeEmbeddedArtworkFn = origProbe
+		newTrackArtworkLookupFn = origLookup
+		downloadTrackArtworkFn = origDownload
+		embedTrackArtworkFn = origEmbed
+	})
+	probeEmbeddedArtworkFn = func(ctx context.Context, path string) (embeddedArtwork, error) {
+		switch filepath.Base(path) {
+		case "Good.mp3":
+			return embeddedArtwork{Width: 1000, Height: 1000}, nil
+		case "Small.flac":
+			return embeddedArtwork{Width: 300, Height: 300}, nil
+		case "Tiny.m4a":
+			return embeddedArtwork{Width: 100, Height: 100}, nil
+		default:
+			return embeddedArtwork{}, nil
+		}
+	}
+	newTrackArtworkLookupFn = func(ctx context.Context, source config.Source) (trackArtworkLookup, error) {
+		return func(ctx context.Context, trackID string) (string, error) {
+			if trackID == "4" {
+				return "", nil
+			}
+			return "https://cdn.example/" + trackID + ".png", nil
+		}, nil
+	}
+	downloadTrackArtworkFn = func(ctx context.Context, rawURL string, dir string) (string, error) {
+		// Track 3's remote cover is no larger than the 300px one it has.
+		size := 1000
+		if rawURL == "https://cdn.example/3.png" {
+			size = 250
+		}
+		path := filepath.Join(dir, ".udl-artwork-test.png")
+		file, err := os.Create(path)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		return path, png.Encode(file, image.NewGray(image.Rect(0, 0, size, size)))
+	}
+	embedded := []string{}
+	embedTrackArtworkFn = func(ctx context.Context, path string, artworkPath string) error {
+		embedded = append(embedded, filepath.Base(path))
+		return nil
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir},
+		Sources: []config.Source{
+			{ID: "dz", Type: config.SourceTypeDeezer, Enabled: true, TargetDir: targetDir, StateFile: "dz.sync.deezer", Adapter: config.AdapterSpec{Kind: "deemix"}},
+			{ID: "yt", Type: config.SourceType("youtube"), Enabled: true, TargetDir: targetDir, StateFile: "yt.txt", Adapter: config.AdapterSpec{Kind: "ytdlp"}},
+		},
+	}
+	reports, err := ScanArtwork(context.Background(), cfg, nil, ArtworkOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(reports) != 2 || reports[1].Skipped == "" {
+		t.Fatalf("expected the youtube source to be skipped, got %+v", reports)
+	}
+	report := reports[0]
+	if report.Scanned != 5 || report.UpToDate != 1 || report.Unsupported != 1 || len(report.Candidates) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	reasons := map[string]string{}
+	for _, candidate := range report.Candidates {
+		reasons[filepath.Base(candidate.LocalPath)] = candidate.Reason
+	}
+	if reasons["None.mp3"] != ArtworkReasonMissing || reasons["Small.flac"] != ArtworkReasonLowRes || reasons["Tiny.m4a"] != ArtworkReasonLowRes {
+		t.Fatalf("unexpected reasons %v", reasons)
+	}
+
+	if count := ApplyArtworkPlan(context.Background(), reports, ArtworkOptions{}); count != 1 {
+		t.Fatalf("expected one file to be rewritten, got %d (%+v)", count, reports[0].Candidates)
+	}
+	if len(embedded) != 1 || embedded[0] != "None.mp3" {
+		t.Fatalf("expected only None.mp3 to be embedded, got %v", embedded)
+	}
+	for _, candidate := range reports[0].Candidates {
+		if filepath.Base(candidate.LocalPath) == "Small.flac" && (candidate.Embedded || candidate.Note == "") {
+			t.Fatalf("expected the smaller remote cover to be left out with a note, got %+v", candidate)
+		}
+	}
+	if len(reports[0].Errors) != 0 {
+		t.Fatalf("unexpected errors %v", reports[0].Errors)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, ".udl-artwork-test.png")); !os.IsNotExist(err) {
+		t.Fatalf("expected the downloaded artwork to be removed, got %v", err)
+	}
+}
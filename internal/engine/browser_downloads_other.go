@@ -0,0 +1,9 @@
+//go:build !windows
+
+package engine
+
+// knownDownloadsFolder has no equivalent outside Windows; browsers there
+// default to ~/Downloads.
+func knownDownloadsFolder() (string, error) {
+	return "", nil
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package engine
+
+import "golang.org/x/sys/windows"
+
+// knownDownloadsFolder asks the shell for the Downloads known folder, which
+// users can move off their profile (Downloads > Properties > Location).
+func knownDownloadsFolder() (string, error) {
+	return windows.KnownFolderPath(windows.FOLDERID_Downloads, windows.KF_FLAG_DEFAULT)
+}
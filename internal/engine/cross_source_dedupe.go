@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const dedupeIndexFileName = "dedupe-index.json"
+
+// dedupeIndex is <state_dir>/dedupe-index.json: for each resolved target_dir,
+// the normalized artist/title keys of the tracks synced into it and the source
+// that downloaded them. Every non-dry-run sync adds its downloads; sources
+// with sync.dedupe_across_sources consult it while planning.
+type dedupeIndex struct {
+	Dirs map[string]map[string]dedupeEntry `json:"dirs"`
+}
+
+type dedupeEntry struct {
+	SourceID string `json:"source_id"`
+	TrackID  string `json:"track_id"`
+}
+
+// crossSourceTrack is a planned track's dedupe key and display label.
+type crossSourceTrack struct {
+	Key   string
+	Label string
+}
+
+// sourceTrackKeys are the dedupe keys a source planned, with the target_dir
+// they land in.
+type sourceTrackKeys struct {
+	targetDir string
+	tracks    map[string]crossSourceTrack
+}
+
+// crossSourceTrackKey normalizes artist and title into the key tracks are
+// matched on. SoundCloud titles usually embed the artist ("Artist - Title"),
+// so callers without a separate artist pass an empty one.
+func crossSourceTrackKey(artist string, title string) string {
+	return normalizeTrackKey(strings.TrimSpace(artist + " " + title))
+}
+
+func dedupeTargetDir(source config.Source) string {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil || strings.TrimSpace(targetDir) == "" {
+		return ""
+	}
+	return filepath.Clean(targetDir)
+}
+
+// skipCrossSourceDuplicates drops planned track ids that another source
+// sharing source's target_dir already downloaded, either in an earlier run or
+// earlier in this one. It only filters when sync.dedupe_across_sources is set
+// and emits a "[skip] ... (duplicate)" preflight line per dropped track.
+func (s *Syncer) skipCrossSourceDuplicates(
+	cfg config.Config,
+	source config.Source,
+	plannedIDs []string,
+	tracks map[string]crossSourceTrack,
+) ([]string, int) {
+	if source.Sync.DedupeAcrossSources == nil || !*source.Sync.DedupeAcrossSources {
+		return plannedIDs, 0
+	}
+	targetDir := dedupeTargetDir(source)
+	if targetDir == "" {
+		return plannedIDs, 0
+	}
+	known := map[string]dedupeEntry{}
+	if index, err := loadDedupeIndex(cfg.Defaults.StateDir); err == nil {
+		for key, entry := range index.Dirs[targetDir] {
+			known[key] = entry
+		}
+	}
+	if s.run != nil {
+		for key, entry := range s.run.downloadedTrackKeys()[targetDir] {
+			known[key] = entry
+		}
+	}
+	if len(known) == 0 {
+		return plannedIDs, 0
+	}
+
+	kept := make([]string, 0, len(plannedIDs))
+	skipped := 0
+	for _, id := range plannedIDs {
+		track := tracks[id]
+		entry, duplicate := known[track.Key]
+		if track.Key == "" || !duplicate || entry.SourceID == source.ID {
+			kept = append(kept, id)
+			continue
+		}
+		skipped++
+		label := track.Label
+		if label == "" {
+			label = id
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [skip] %s (%s) (duplicate) already downloaded by %s", source.ID, id, label, entry.SourceID),
+			Details: map[string]any{
+				"track_id":           id,
+				"reason":             "duplicate",
+				"duplicate_source":   entry.SourceID,
+				"duplicate_track_id": entry.TrackID,
+			},
+		})
+	}
+	return kept, skipped
+}
+
+// noteTrackKeys records the dedupe keys of the tracks source planned so the
+// ones it downloads can be added to the dedupe index.
+func (s *Syncer) noteTrackKeys(source config.Source, plannedIDs []string, tracks map[string]crossSourceTrack) {
+	if s.run == nil {
+		return
+	}
+	targetDir := dedupeTargetDir(source)
+	if targetDir == "" {
+		return
+	}
+	keys := make(map[string]crossSourceTrack, len(plannedIDs))
+	for _, id := range plannedIDs {
+		if track, ok := tracks[id]; ok && track.Key != "" {
+			keys[id] = track
+		}
+	}
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	s.run.trackKeys[source.ID] = sourceTrackKeys{targetDir: targetDir, tracks: keys}
+}
+
+// downloadedTrackKeys returns, per target_dir, the dedupe keys of tracks
+// sources reported done so far in this run.
+func (r *runReportRecorder) downloadedTrackKeys() map[string]map[string]dedupeEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := map[string]map[string]dedupeEntry{}
+	for sourceID, keys := range r.trackKeys {
+		activity := r.activity[sourceID]
+		if activity == nil {
+			continue
+		}
+		for _, trackID := range activity.downloaded {
+			track, ok := keys.tracks[trackID]
+			if !ok {
+				continue
+			}
+			if out[keys.targetDir] == nil {
+				out[keys.targetDir] = map[string]dedupeEntry{}
+			}
+			out[keys.targetDir][track.Key] = dedupeEntry{SourceID: sourceID, TrackID: trackID}
+		}
+	}
+	return out
+}
+
+func loadDedupeIndex(stateDir string) (dedupeIndex, error) {
+	index := dedupeIndex{Dirs: map[string]map[string]dedupeEntry{}}
+	path, err := dedupeIndexPath(stateDir)
+	if err != nil {
+		return index, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return index, nil
+		}
+		return index, err
+	}
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return dedupeIndex{Dirs: map[string]map[string]dedupeEntry{}}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if index.Dirs == nil {
+		index.Dirs = map[string]map[string]dedupeEntry{}
+	}
+	return index, nil
+}
+
+// updateDedupeIndex adds downloaded keys to the index. The first source to
+// download a key keeps it so duplicates always point at the original.
+func updateDedupeIndex(stateDir string, downloaded map[string]map[string]dedupeEntry) error {
+	if len(downloaded) == 0 {
+		return nil
+	}
+	path, err := dedupeIndexPath(stateDir)
+	if err != nil {
+		return err
+	}
+	return withStateDirLock(stateDir, func() error {
+		index, err := loadDedupeIndex(stateDir)
+		if err != nil {
+			return err
+		}
+		changed := false
+		for targetDir, keys := range downloaded {
+			if index.Dirs[targetDir] == nil {
+				index.Dirs[targetDir] = map[string]dedupeEntry{}
+			}
+			for key, entry := range keys {
+				if _, ok := index.Dirs[targetDir][key]; ok {
+					continue
+				}
+				index.Dirs[targetDir][key] = entry
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		payload, err := json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomically(path, ".udl-dedupe-*", append(payload, '\n'))
+	})
+}
+
+func dedupeIndexPath(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, dedupeIndexFileName), nil
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestCrossSourceTrackKeyMatchesEmbeddedArtistTitles(t *testing.T) {
+	if got, want := crossSourceTrackKey("", "Artist - Song (Original Mix)"), crossSourceTrackKey("Artist", "Song (Original Mix)"); got != want {
+		t.Fatalf("expected soundcloud-style title to match artist/title key, got %q vs %q", got, want)
+	}
+	if crossSourceTrackKey("", "  ") != "" {
+		t.Fatalf("expected empty key for blank tracks")
+	}
+}
+
+func TestSyncSkipsTracksDownloadedByAnotherSourceInSameTargetDir(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	runtimeDir := filepath.Join(tmp, "runtime")
+	for _, dir := range []string{targetDir, stateDir, runtimeDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	dedupe := true
+	spotifySource := func(id string, url string) config.Source {
+		return config.Source{
+			ID:               id,
+			Type:             config.SourceTypeSpotify,
+			Enabled:          true,
+			TargetDir:        targetDir,
+			URL:              url,
+			StateFile:        id + ".sync.spotify",
+			DeemixRuntimeDir: runtimeDir,
+			Adapter:          config.AdapterSpec{Kind: "deemix"},
+		}
+	}
+	second := spotifySource("mix", "https://open.spotify.com/playlist/mix0000001")
+	second.Sync.DedupeAcrossSources = &dedupe
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			spotifySource("artist", "https://open.spotify.com/artist/artist0000001"),
+			second,
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		if source.ID == "artist" {
+			return []spotifyRemoteTrack{{ID: "1abc234def", Title: "Song!", Artist: "Artist", Album: "LP"}}, nil
+		}
+		return []spotifyRemoteTrack{
+			{ID: "9xyz876wvu", Title: "song", Artist: "artist", Album: "Mix"},
+			{ID: "8xyz876wvu", Title: "Other", Artist: "Someone", Album: "Mix"},
+		}, nil
+	}
+
+	runner := &sequenceRunner{}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixPathAdapter{}},
+		runner,
+		output.NewHumanEmitter(&out, &out, false, true),
+	)
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 2 {
+		t.Fatalf("expected both sources to succeed, got %+v", result)
+	}
+	downloaded := []string{}
+	for _, spec := range runner.specs {
+		downloaded = append(downloaded, trackIDFromSpec(spec))
+	}
+	if got := strings.Join(downloaded, ","); got != "1abc234def,8xyz876wvu" {
+		t.Fatalf("expected duplicate to be skipped, got downloads %q", got)
+	}
+	if !strings.Contains(out.String(), "[mix] [skip] 9xyz876wvu") || !strings.Contains(out.String(), "(duplicate) already downloaded by artist") {
+		t.Fatalf("expected duplicate skip line, got:\n%s", out.String())
+	}
+
+	index, err := loadDedupeIndex(stateDir)
+	if err != nil {
+		t.Fatalf("load dedupe index: %v", err)
+	}
+	entry := index.Dirs[filepath.Clean(targetDir)][crossSourceTrackKey("artist", "song")]
+	if entry.SourceID != "artist" || entry.TrackID != "1abc234def" {
+		t.Fatalf("expected original download recorded in index, got %+v", index)
+	}
+}
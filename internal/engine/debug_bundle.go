@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// debugURLPattern finds URLs in free text so ScrubDebugText can redact them.
+var debugURLPattern = regexp.MustCompile(`https?://[^\s"'<>]+`)
+
+// DebugStateExcerpt is the anonymized tail of one source's state file.
+type DebugStateExcerpt struct {
+	SourceID   string   `json:"source_id"`
+	TotalLines int      `json:"total_lines"`
+	Lines      []string `json:"lines"`
+}
+
+// DebugAnonymizer hashes track IDs with a per-bundle key, so IDs can still be
+// matched up across the files of one bundle but not looked up elsewhere.
+type DebugAnonymizer struct {
+	Key  []byte
+	Home string
+}
+
+// HashID returns a short keyed hash of id.
+func (a DebugAnonymizer) HashID(id string) string {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.Key)
+	_, _ = mac.Write([]byte(id))
+	return "id-" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// AnonymizeStateLine rewrites a state line with its track ID hashed, titles
+// redacted and paths reduced to their extension; quality, provider, adapter,
+// and added_at fields are kept. Header and comment lines are only scrubbed.
+func (a DebugAnonymizer) AnonymizeStateLine(raw string) string {
+	line := strings.TrimSpace(raw)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return a.ScrubDebugText(line)
+	}
+	if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "soundcloud" && !strings.Contains(line, "\t") {
+		out := []string{"soundcloud", a.HashID(fields[1])}
+		if len(fields) > 2 {
+			out = append(out, redactedPath(strings.Join(fields[2:], " ")))
+		}
+		return strings.Join(out, " ")
+	}
+	parts := strings.Split(line, "\t")
+	out := []string{a.HashID(parts[0])}
+	for _, field := range parts[1:] {
+		trimmed := strings.TrimSpace(field)
+		key, value, ok := strings.Cut(trimmed, "=")
+		switch {
+		case ok && key == "path":
+			out = append(out, "path="+encodeSpotifyStateValue(redactedPath(decodeSpotifyStateValue(value))))
+		case ok && (key == "quality" || key == "provider" || key == "adapter" || key == "added_at"):
+			out = append(out, trimmed)
+		case ok:
+			out = append(out, key+"="+config.RedactedValue)
+		default:
+			out = append(out, config.RedactedValue)
+		}
+	}
+	return strings.Join(out, "\t")
+}
+
+// redactedPath keeps only a path's extension, which is what format and
+// quality bugs turn on.
+func redactedPath(path string) string {
+	return config.RedactedValue + strings.ToLower(filepath.Ext(strings.TrimSpace(path)))
+}
+
+// ScrubDebugText replaces the home directory with ~ and strips credentials
+// and share tokens from any URLs in text.
+func (a DebugAnonymizer) ScrubDebugText(text string) string {
+	if home := strings.TrimRight(strings.TrimSpace(a.Home), string(filepath.Separator)); home != "" {
+		text = strings.ReplaceAll(text, home, "~")
+	}
+	return debugURLPattern.ReplaceAllStringFunc(text, config.RedactURL)
+}
+
+// DebugStateExcerpts returns the last maxLines lines of every source's state
+// file, anonymized. Sources without a state file on disk are left out.
+func DebugStateExcerpts(cfg config.Config, anonymizer DebugAnonymizer, maxLines int) ([]DebugStateExcerpt, error) {
+	excerpts := []DebugStateExcerpt{}
+	for _, source := range cfg.Sources {
+		path, err := stateFileForVerify(cfg.Defaults, source)
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			continue
+		}
+		payload, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		lines := []string{}
+		for _, raw := range strings.Split(string(payload), "\n") {
+			if strings.TrimSpace(raw) != "" {
+				lines = append(lines, raw)
+			}
+		}
+		excerpt := DebugStateExcerpt{SourceID: source.ID, TotalLines: len(lines), Lines: []string{}}
+		if maxLines >= 0 && len(lines) > maxLines {
+			lines = lines[len(lines)-maxLines:]
+		}
+		for _, line := range lines {
+			excerpt.Lines = append(excerpt.Lines, anonymizer.AnonymizeStateLine(line))
+		}
+		excerpts = append(excerpts, excerpt)
+	}
+	return excerpts, nil
+}
+
+// DebugRunReports returns the newest limit run reports under
+// <state_dir>/runs, newest first, with their messages scrubbed. Unreadable
+// reports are skipped.
+func DebugRunReports(stateDir string, anonymizer DebugAnonymizer, limit int) ([]RunReport, error) {
+	root, err := RunReportsDir(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []RunReport{}, nil
+		}
+		return nil, err
+	}
+	runIDs := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			runIDs = append(runIDs, entry.Name())
+		}
+	}
+	// Run IDs are UTC timestamps, so they sort chronologically.
+	sort.Sort(sort.Reverse(sort.StringSlice(runIDs)))
+
+	reports := []RunReport{}
+	for _, runID := range runIDs {
+		if len(reports) >= limit {
+			break
+		}
+		payload, err := os.ReadFile(filepath.Join(root, runID, RunReportFileName))
+		if err != nil {
+			continue
+		}
+		report := RunReport{}
+		if err := json.Unmarshal(payload, &report); err != nil {
+			continue
+		}
+		report.Error = anonymizer.ScrubDebugText(report.Error)
+		for i := range report.Sources {
+			report.Sources[i].Message = anonymizer.ScrubDebugText(report.Sources[i].Message)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
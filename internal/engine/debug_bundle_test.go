@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestDebugStateExcerptsAnonymizeTail(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	spotifyState := strings.Join([]string{
+		spotifyStateHeader,
+		"1111111111111111111111\ttitle=Old+Song\tpath=Old.mp3",
+		"2222222222222222222222\ttitle=Secret+Artist+-+Secret+Song\tpath=Secret+Artist%2FSecret+Song.FLAC\tquality=flac\tprovider=deemix",
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(stateDir, "mix.sync.spotify"), []byte(spotifyState), 0o644); err != nil {
+		t.Fatalf("write spotify state: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "sc.sync.scdl"), []byte("soundcloud 12345 Private Demo.m4a\n"), 0o644); err != nil {
+		t.Fatalf("write soundcloud state: %v", err)
+	}
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir},
+		Sources: []config.Source{
+			{ID: "mix", Type: config.SourceTypeSpotify, StateFile: "mix.sync.spotify"},
+			{ID: "sc", Type: config.SourceTypeSoundCloud, StateFile: "sc.sync.scdl"},
+			{ID: "missing", Type: config.SourceTypeSpotify, StateFile: "missing.sync.spotify"},
+		},
+	}
+	anonymizer := DebugAnonymizer{Key: []byte("key")}
+
+	excerpts, err := DebugStateExcerpts(cfg, anonymizer, 2)
+	if err != nil {
+		t.Fatalf("excerpts: %v", err)
+	}
+	if len(excerpts) != 2 {
+		t.Fatalf("expected sources without a state file to be left out, got %+v", excerpts)
+	}
+	mix := excerpts[0]
+	if mix.SourceID != "mix" || mix.TotalLines != 3 || len(mix.Lines) != 2 {
+		t.Fatalf("expected the last 2 of 3 lines, got %+v", mix)
+	}
+	want := anonymizer.HashID("2222222222222222222222") + "\ttitle=<redacted>\tpath=%3Credacted%3E.flac\tquality=flac\tprovider=deemix"
+	if mix.Lines[1] != want {
+		t.Fatalf("unexpected anonymized spotify line:\n got %q\nwant %q", mix.Lines[1], want)
+	}
+	if got := excerpts[1].Lines[0]; got != "soundcloud "+anonymizer.HashID("12345")+" <redacted>.m4a" {
+		t.Fatalf("unexpected anonymized soundcloud line %q", got)
+	}
+	for _, excerpt := range excerpts {
+		joined := strings.Join(excerpt.Lines, "\n")
+		for _, leak := range []string{"Secret", "Private", "2222222222222222222222", "12345"} {
+			if strings.Contains(joined, leak) {
+				t.Fatalf("expected %q to be anonymized, got:\n%s", leak, joined)
+			}
+		}
+	}
+	if other := (DebugAnonymizer{Key: []byte("other")}).HashID("12345"); other == anonymizer.HashID("12345") {
+		t.Fatalf("expected hashes to depend on the bundle key")
+	}
+}
+
+func TestDebugRunReportsNewestFirstAndScrubbed(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	for _, runID := range []string{"20260101T000000Z", "20260102T000000Z", "20260103T000000Z"} {
+		dir := filepath.Join(stateDir, "runs", runID)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir run: %v", err)
+		}
+		report := `{"run_id":"` + runID + `","sources":[{"source_id":"mix","status":"failed","message":"fetch https://user:pw@example.com/a?token=x failed in ` + tmp + `/lib"}]}`
+		if err := os.WriteFile(filepath.Join(dir, RunReportFileName), []byte(report), 0o644); err != nil {
+			t.Fatalf("write report: %v", err)
+		}
+	}
+
+	reports, err := DebugRunReports(stateDir, DebugAnonymizer{Home: tmp}, 2)
+	if err != nil {
+		t.Fatalf("run reports: %v", err)
+	}
+	if len(reports) != 2 || reports[0].RunID != "20260103T000000Z" || reports[1].RunID != "20260102T000000Z" {
+		t.Fatalf("expected the 2 newest reports newest first, got %+v", reports)
+	}
+	if got := reports[0].Sources[0].Message; got != "fetch https://example.com/a failed in ~/lib" {
+		t.Fatalf("unexpected scrubbed message %q", got)
+	}
+}
@@ -0,0 +1,411 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// Why the files of a duplicate group were matched: byte-identical files,
+// the same audio stream under different tags, or a browser-style " (1)" or
+// " - Copy" twin of another file in the same folder.
+const (
+	DuplicateReasonIdentical  = "identical"
+	DuplicateReasonAudio      = "audio"
+	DuplicateReasonCopySuffix = "copy_suffix"
+)
+
+// DedupeOptions controls ScanDuplicates.
+type DedupeOptions struct {
+	ProbeTimeout time.Duration
+	// AudioHash hashes every file's audio stream with ffmpeg to find copies
+	// that differ only in tags or artwork. It reads the whole library.
+	AudioHash bool
+}
+
+// DuplicateFile is one copy in a duplicate group. Codec and BitrateKbps come
+// from ffprobe and are empty when it could not read the file. Tracked marks
+// files a state entry points at.
+type DuplicateFile struct {
+	Path        string    `json:"path"`
+	Bytes       int64     `json:"bytes"`
+	ModTime     time.Time `json:"mod_time"`
+	Codec       string    `json:"codec,omitempty"`
+	BitrateKbps int       `json:"bitrate_kbps,omitempty"`
+	Lossless    bool      `json:"lossless"`
+	CopySuffix  bool      `json:"copy_suffix,omitempty"`
+	Tracked     bool      `json:"tracked,omitempty"`
+}
+
+// DuplicateGroup proposes which copy to keep. Remove lists the copies
+// --apply moves to trash; Retained lists lesser copies kept anyway because a
+// state file points at them, so removing them would make the next sync
+// download them again.
+type DuplicateGroup struct {
+	Reason   string          `json:"reason"`
+	Keep     DuplicateFile   `json:"keep"`
+	Remove   []DuplicateFile `json:"remove"`
+	Retained []DuplicateFile `json:"retained,omitempty"`
+}
+
+// SourceDedupeReport is the dedupe plan of one source's target_dir.
+// Skipped explains why it was not scanned.
+type SourceDedupeReport struct {
+	SourceID  string           `json:"source_id"`
+	TargetDir string           `json:"target_dir,omitempty"`
+	Scanned   int              `json:"scanned"`
+	Groups    []DuplicateGroup `json:"groups"`
+	Skipped   string           `json:"skipped,omitempty"`
+	Errors    []string         `json:"errors,omitempty"`
+}
+
+// Removable counts the files --apply would move and the bytes it would free.
+func (r SourceDedupeReport) Removable() (int, int64) {
+	files, bytes := 0, int64(0)
+	for _, group := range r.Groups {
+		for _, file := range group.Remove {
+			files++
+			bytes += file.Bytes
+		}
+	}
+	return files, bytes
+}
+
+var (
+	hashDuplicateAudioFn = hashDuplicateAudio
+
+	// duplicateCopySuffixPattern matches the stem of "Track (1).mp3" and
+	// "Track - Copy.mp3" style copies.
+	duplicateCopySuffixPattern = regexp.MustCompile(`(?i)^(.*\S)(?: \(\d{1,3}\)| - copy| copy)$`)
+)
+
+// ScanDuplicates finds duplicate tracks in the target_dir of the selected
+// sources (all sources when sourceIDs is empty). Files are grouped when
+// their bytes match, when their audio streams match (opts.AudioHash), or
+// when one is a " (1)"-style copy of another in the same folder. The copy
+// kept is the best by codec and bitrate: lossless first, then the higher
+// bitrate, then the file without a copy suffix, then the oldest. Sources
+// sharing a target_dir are scanned once, under the first of them.
+func ScanDuplicates(ctx context.Context, cfg config.Config, sourceIDs []string, opts DedupeOptions) ([]SourceDedupeReport, error) {
+	sources, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	tracked := map[string]struct{}{}
+	for _, source := range cfg.Sources {
+		files, _ := collectVerifyTrackedFiles(cfg.Defaults.ForSource(source), source)
+		for _, file := range files {
+			tracked[file.path] = struct{}{}
+		}
+	}
+
+	reports := make([]SourceDedupeReport, 0, len(sources))
+	scannedBy := map[string]string{}
+	for _, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		report := SourceDedupeReport{SourceID: source.ID, Groups: []DuplicateGroup{}}
+		targetDir, err := config.ExpandTargetDir(source.TargetDir)
+		if err != nil {
+			report.Errors = append(report.Errors, "target_dir: "+err.Error())
+			reports = append(reports, report)
+			continue
+		}
+		report.TargetDir = targetDir
+		if owner, ok := scannedBy[targetDir]; ok {
+			report.Skipped = fmt.Sprintf("target_dir already scanned with source %s", owner)
+			reports = append(reports, report)
+			continue
+		}
+		scannedBy[targetDir] = source.ID
+		scanSourceDuplicates(ctx, &report, tracked, opts)
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+type duplicateCandidate struct {
+	file     DuplicateFile
+	rel      string
+	assigned bool
+}
+
+func scanSourceDuplicates(ctx context.Context, report *SourceDedupeReport, tracked map[string]struct{}, opts DedupeOptions) {
+	candidates := []*duplicateCandidate{}
+	err := filepath.WalkDir(report.TargetDir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() || !isMediaExt(strings.ToLower(filepath.Ext(d.Name()))) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(report.TargetDir, path)
+		stem := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+		_, isTracked := tracked[filepath.Clean(path)]
+		candidates = append(candidates, &duplicateCandidate{
+			rel: filepath.ToSlash(rel),
+			file: DuplicateFile{
+				Path:       path,
+				Bytes:      info.Size(),
+				ModTime:    info.ModTime(),
+				CopySuffix: duplicateCopySuffixPattern.MatchString(stem),
+				Tracked:    isTracked,
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			report.Skipped = "target_dir does not exist"
+			return
+		}
+		report.Errors = append(report.Errors, "scan target_dir: "+err.Error())
+		return
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].rel < candidates[j].rel })
+	report.Scanned = len(candidates)
+
+	clusters := [][]*duplicateCandidate{}
+	reasons := []string{}
+	addClusters := func(reason string, key func(*duplicateCandidate) string) {
+		buckets := map[string][]*duplicateCandidate{}
+		keys := []string{}
+		for _, candidate := range candidates {
+			if candidate.assigned {
+				continue
+			}
+			k := key(candidate)
+			if k == "" {
+				continue
+			}
+			if _, ok := buckets[k]; !ok {
+				keys = append(keys, k)
+			}
+			buckets[k] = append(buckets[k], candidate)
+		}
+		for _, k := range keys {
+			bucket := buckets[k]
+			if len(bucket) < 2 {
+				continue
+			}
+			if reason == DuplicateReasonCopySuffix && !hasCopySuffixTwin(bucket) {
+				continue
+			}
+			for _, candidate := range bucket {
+				candidate.assigned = true
+			}
+			clusters = append(clusters, bucket)
+			reasons = append(reasons, reason)
+		}
+	}
+
+	// Only files sharing a size can be byte-identical, so the rest are
+	// never read.
+	sizes := map[int64]int{}
+	for _, candidate := range candidates {
+		sizes[candidate.file.Bytes]++
+	}
+	addClusters(DuplicateReasonIdentical, func(candidate *duplicateCandidate) string {
+		if sizes[candidate.file.Bytes] < 2 || ctx.Err() != nil {
+			return ""
+		}
+		sum, err := hashDuplicateFile(candidate.file.Path)
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", candidate.file.Path, err))
+			return ""
+		}
+		return sum
+	})
+	if opts.AudioHash {
+		addClusters(DuplicateReasonAudio, func(candidate *duplicateCandidate) string {
+			if ctx.Err() != nil {
+				return ""
+			}
+			probeCtx, cancel := withOptionalTimeout(ctx, opts.ProbeTimeout)
+			sum, err := hashDuplicateAudioFn(probeCtx, candidate.file.Path)
+			cancel()
+			if err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("%s: audio hash: %v", candidate.file.Path, err))
+				return ""
+			}
+			return sum
+		})
+	}
+	addClusters(DuplicateReasonCopySuffix, func(candidate *duplicateCandidate) string {
+		stem := strings.TrimSuffix(candidate.rel, filepath.Ext(candidate.rel))
+		dir, name := filepath.Split(stem)
+		if match := duplicateCopySuffixPattern.FindStringSubmatch(name); match != nil {
+			name = match[1]
+		}
+		return strings.ToLower(dir + name)
+	})
+
+	for i, cluster := range clusters {
+		if ctx.Err() != nil {
+			break
+		}
+		files := make([]DuplicateFile, 0, len(cluster))
+		for _, candidate := range cluster {
+			file := candidate.file
+			probeCtx, cancel := withOptionalTimeout(ctx, opts.ProbeTimeout)
+			probe, err := probeUpgradeAudioFn(probeCtx, file.Path)
+			cancel()
+			if err == nil {
+				file.Codec = probe.Codec
+				file.BitrateKbps = probe.BitrateKbps
+				file.Lossless = upgradeLocalQuality(probe) == config.DeemixQualityFLAC
+			} else {
+				file.Lossless = isLosslessExt(filepath.Ext(file.Path))
+			}
+			files = append(files, file)
+		}
+		report.Groups = append(report.Groups, planDuplicateGroup(reasons[i], files))
+	}
+}
+
+// planDuplicateGroup keeps the best copy and proposes the rest for removal,
+// except tracked copies, which are retained.
+func planDuplicateGroup(reason string, files []DuplicateFile) DuplicateGroup {
+	sort.SliceStable(files, func(i, j int) bool { return betterDuplicate(files[i], files[j]) })
+	group := DuplicateGroup{Reason: reason, Keep: files[0], Remove: []DuplicateFile{}}
+	for _, file := range files[1:] {
+		if file.Tracked {
+			group.Retained = append(group.Retained, file)
+			continue
+		}
+		group.Remove = append(group.Remove, file)
+	}
+	return group
+}
+
+func betterDuplicate(a DuplicateFile, b DuplicateFile) bool {
+	if a.Lossless != b.Lossless {
+		return a.Lossless
+	}
+	if a.BitrateKbps != b.BitrateKbps {
+		return a.BitrateKbps > b.BitrateKbps
+	}
+	if a.CopySuffix != b.CopySuffix {
+		return !a.CopySuffix
+	}
+	if a.Tracked != b.Tracked {
+		return a.Tracked
+	}
+	if !a.ModTime.Equal(b.ModTime) {
+		return a.ModTime.Before(b.ModTime)
+	}
+	return a.Path < b.Path
+}
+
+// hasCopySuffixTwin reports whether bucket holds a copy-suffixed file and
+// the unsuffixed file it copies. "X (1)" and "X (2)" alone may be different
+// takes; copies with matching bytes or audio were grouped before this.
+func hasCopySuffixTwin(bucket []*duplicateCandidate) bool {
+	suffixed, plain := false, false
+	for _, candidate := range bucket {
+		if candidate.file.CopySuffix {
+			suffixed = true
+		} else {
+			plain = true
+		}
+	}
+	return suffixed && plain
+}
+
+func isLosslessExt(ext string) bool {
+	switch strings.ToLower(ext) {
+	case ".flac", ".wav", ".aif", ".aiff":
+		return true
+	default:
+		return false
+	}
+}
+
+// withOptionalTimeout bounds one probe; a zero timeout leaves ctx as is.
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func hashDuplicateFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashDuplicateAudio hashes the first audio stream's packets without
+// decoding them, so retagged copies of one download hash alike while
+// transcodes do not.
+func hashDuplicateAudio(ctx context.Context, path string) (string, error) {
+	output, err := exec.CommandContext(ctx, "ffmpeg", "-v", "error", "-i", path, "-map", "0:a:0", "-c", "copy", "-f", "md5", "-").Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("ffmpeg timed out")
+		}
+		return "", err
+	}
+	sum := strings.TrimSpace(string(output))
+	if !strings.HasPrefix(sum, "MD5=") {
+		return "", fmt.Errorf("unexpected ffmpeg output %q", sum)
+	}
+	return sum, nil
+}
+
+// ApplyDedupePlan moves every proposed copy to
+// <state_dir>/trash/<source>/<stamp>-dedupe/, keeping its path under
+// target_dir. It returns how many files moved.
+func ApplyDedupePlan(cfg config.Config, reports []SourceDedupeReport, stamp string) (int, error) {
+	byID := map[string]config.Source{}
+	for _, source := range cfg.Sources {
+		byID[source.ID] = source
+	}
+	moved := 0
+	for _, report := range reports {
+		source, ok := byID[report.SourceID]
+		if !ok {
+			continue
+		}
+		if files, _ := report.Removable(); files == 0 {
+			continue
+		}
+		trashDir, err := pruneTrashDir(cfg, source, stamp+"-dedupe")
+		if err != nil {
+			return moved, fmt.Errorf("[%s] %w", source.ID, err)
+		}
+		for _, group := range report.Groups {
+			for _, file := range group.Remove {
+				destination := filepath.Join(trashDir, pruneTrashRelPath(report.TargetDir, file.Path))
+				if err := moveFile(file.Path, destination); err != nil {
+					return moved, fmt.Errorf("[%s] move %s: %w", source.ID, file.Path, err)
+				}
+				moved++
+			}
+		}
+	}
+	return moved, nil
+}
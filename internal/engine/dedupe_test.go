@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestScanDuplicatesKeepsBestCopyRetainsTrackedAndApplyMovesTheRest(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, filepath.Join(targetDir, "Artist")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for name, body := range map[string]string{
+		"Artist/Song.mp3":       "same-bytes",
+		"Artist/Song copy.mp3":  "same-bytes",
+		"Artist/Mix.mp3":        "low",
+		"Artist/Mix (1).flac":   "lossless",
+		"Artist/Mix (2).mp3":    "tracked-low",
+		"Artist/Other.mp3":      "unique",
+		"Artist/Other Mix.mp3":  "unrelated",
+		"Artist/cover (1).jpg":  "not audio",
+		"Artist/Single (1).mp3": "orphan copy",
+		"Artist/Take (1).mp3":   "first take",
+		"Artist/Take (2).mp3":   "second take",
+	} {
+		if err := os.WriteFile(filepath.Join(targetDir, filepath.FromSlash(name)), []byte(body), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "dz.sync.deezer")
+	if err := appendDeezerSyncStateEntry(statePath, "1", spotifyStateEntry{DisplayName: "Artist - Mix", LocalPath: "Artist/Mix (2).mp3", Provider: "deemix"}); err != nil {
+		t.Fatalf("append state: %v", err)
+	}
+
+	origProbe := probeUpgradeAudioFn
+	t.Cleanup(func() { probeUpgradeAudioFn = origProbe })
+	probeUpgradeAudioFn = func(ctx context.Context, path string) (upgradeAudioProbe, error) {
+		if filepath.Ext(path) == ".flac" {
+			return upgradeAudioProbe{Codec: "flac", BitrateKbps: 900}, nil
+		}
+		return upgradeAudioProbe{Codec: "mp3", BitrateKbps: 192}, nil
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{
+			{ID: "dz", Type: config.SourceTypeDeezer, Enabled: true, TargetDir: targetDir, StateFile: "dz.sync.deezer", Adapter: config.AdapterSpec{Kind: "deemix"}},
+			{ID: "dz-2", Type: config.SourceTypeDeezer, Enabled: true, TargetDir: targetDir, StateFile: "dz2.sync.deezer", Adapter: config.AdapterSpec{Kind: "deemix"}},
+		},
+	}
+	reports, err := ScanDuplicates(context.Background(), cfg, nil, DedupeOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(reports) != 2 || reports[1].Skipped == "" {
+		t.Fatalf("expected the shared target_dir to be scanned once, got %+v", reports)
+	}
+	report := reports[0]
+	if report.Scanned != 10 || len(report.Groups) != 2 || len(report.Errors) != 0 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	identical, copies := report.Groups[0], report.Groups[1]
+	if identical.Reason != DuplicateReasonIdentical || filepath.Base(identical.Keep.Path) != "Song.mp3" || len(identical.Remove) != 1 {
+		t.Fatalf("expected the original of identical copies kept, got %+v", identical)
+	}
+	if copies.Reason != DuplicateReasonCopySuffix || filepath.Base(copies.Keep.Path) != "Mix (1).flac" {
+		t.Fatalf("expected the lossless copy kept, got %+v", copies)
+	}
+	if len(copies.Remove) != 1 || filepath.Base(copies.Remove[0].Path) != "Mix.mp3" || len(copies.Retained) != 1 || !copies.Retained[0].Tracked {
+		t.Fatalf("expected the tracked copy retained and the other removed, got %+v", copies)
+	}
+
+	moved, err := ApplyDedupePlan(cfg, reports, "20260501-100000")
+	if err != nil || moved != 2 {
+		t.Fatalf("expected two moved files, got %d (%v)", moved, err)
+	}
+	for _, name := range []string{"Song copy.mp3", "Mix.mp3"} {
+		if _, err := os.Stat(filepath.Join(stateDir, "trash", "dz", "20260501-100000-dedupe", "Artist", name)); err != nil {
+			t.Fatalf("expected %s in trash: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(targetDir, "Artist", name)); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to leave target_dir, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Artist", "Mix (2).mp3")); err != nil {
+		t.Fatalf("expected tracked copy to stay: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"sync"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine/progress"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// deemixTrackConcurrency returns how many deemix track subprocesses a source
+// runs at once: sync.concurrency, at least 1 and at most the planned count.
+func deemixTrackConcurrency(source config.Source, planned int) int {
+	concurrency := source.Sync.Concurrency
+	if concurrency > planned {
+		concurrency = planned
+	}
+	if concurrency < 1 {
+		return 1
+	}
+	return concurrency
+}
+
+// withSerializedEvents returns a copy of s whose emitter and progress sink
+// share a lock, so concurrent track runs can report through them safely.
+func (s *Syncer) withSerializedEvents() *Syncer {
+	lock := &sync.Mutex{}
+	serialized := *s
+	if s.Emitter != nil {
+		serialized.Emitter = serializedEmitter{mu: lock, next: s.Emitter}
+	}
+	if s.Progress != nil {
+		serialized.Progress = serializedProgressSink{mu: lock, next: s.Progress}
+	}
+	return &serialized
+}
+
+type serializedEmitter struct {
+	mu   *sync.Mutex
+	next output.EventEmitter
+}
+
+func (e serializedEmitter) Emit(event output.Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.next.Emit(event)
+}
+
+type serializedProgressSink struct {
+	mu   *sync.Mutex
+	next progress.Sink
+}
+
+func (p serializedProgressSink) RecordTrackEvent(event progress.TrackEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next.RecordTrackEvent(event)
+}
+
+// soleUpdatedMediaPath is detectUpdatedMediaPath for concurrent track runs:
+// other tracks may change files between the snapshots, so a path is only
+// returned when exactly one changed file is not claimed by another track.
+func soleUpdatedMediaPath(before, after map[string]mediaFileSnapshot, claimed map[string]struct{}) string {
+	found := ""
+	for path, current := range after {
+		if _, taken := claimed[path]; taken {
+			continue
+		}
+		previous, existed := before[path]
+		if existed && current.Size == previous.Size && !current.ModTime.After(previous.ModTime) {
+			continue
+		}
+		if found != "" {
+			return ""
+		}
+		found = path
+	}
+	return found
+}
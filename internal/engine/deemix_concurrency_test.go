@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// outOfOrderDeemixRunner holds the first track until the second one has run,
+// so with two workers the planned tracks finish out of order.
+type outOfOrderDeemixRunner struct {
+	mu        sync.Mutex
+	active    int
+	maxActive int
+	release   chan struct{}
+}
+
+func (r *outOfOrderDeemixRunner) Run(ctx context.Context, spec ExecSpec) ExecResult {
+	r.mu.Lock()
+	r.active++
+	if r.active > r.maxActive {
+		r.maxActive = r.active
+	}
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.active--
+		r.mu.Unlock()
+	}()
+
+	switch spec.Args[0] {
+	case spotifyTrackURL("1abc234def"):
+		select {
+		case <-r.release:
+		case <-time.After(5 * time.Second):
+			return ExecResult{ExitCode: 1, StderrTail: "second track never ran"}
+		}
+	case spotifyTrackURL("2abc234def"):
+		close(r.release)
+	}
+	return ExecResult{ExitCode: 0}
+}
+
+func TestSyncerSpotifyDeemixConcurrencyCommitsInPlannedOrder(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "spotify-deemix",
+				Type:      config.SourceTypeSpotify,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://open.spotify.com/playlist/a",
+				StateFile: "spotify-deemix.sync.spotify",
+				Sync:      config.SyncPolicy{Concurrency: 2},
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origSaveARL := saveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		saveDeemixARLFn = origSaveARL
+		enumerateSpotifyTracksFn = origEnumerate
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	saveDeemixARLFn = func(string) error { return nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		return []spotifyRemoteTrack{
+			{ID: "1abc234def", Title: "track-1", Artist: "artist-1"},
+			{ID: "2abc234def", Title: "track-2", Artist: "artist-2"},
+			{ID: "3abc234def", Title: "track-3", Artist: "artist-3"},
+		}, nil
+	}
+
+	runner := &outOfOrderDeemixRunner{release: make(chan struct{})}
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"deemix": fakeDeemixAdapter{}}, runner, emitter)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{ScanGaps: true})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful deemix source run, got %+v", result)
+	}
+	if runner.maxActive != 2 {
+		t.Fatalf("expected two concurrent deemix runs, got %d", runner.maxActive)
+	}
+
+	payload, err := os.ReadFile(filepath.Join(stateDir, "spotify-deemix.sync.spotify"))
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if got := strings.Join(spotifyStateIDsFromPayload(string(payload)), ","); got != "1abc234def,2abc234def,3abc234def" {
+		t.Fatalf("expected state entries in planned order, got %q", got)
+	}
+	done := []string{}
+	for _, event := range emitter.events {
+		if strings.Contains(event.Message, "[done]") {
+			done = append(done, event.Message)
+		}
+	}
+	if len(done) != 3 || !strings.Contains(done[0], "1abc234def") || !strings.Contains(done[1], "2abc234def") || !strings.Contains(done[2], "3abc234def") {
+		t.Fatalf("expected [done] events in planned order, got %q", done)
+	}
+}
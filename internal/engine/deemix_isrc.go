@@ -0,0 +1,165 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// deezerAPINoDataCode is the api.deezer.com error code for an unknown id.
+const deezerAPINoDataCode = 800
+
+var (
+	fetchSpotifyAccessTokenFn = fetchSpotifyAccessToken
+	fetchSpotifyTrackISRCFn   = fetchSpotifyTrackISRC
+	lookupDeezerTrackByISRCFn = lookupDeezerTrackByISRC
+)
+
+type spotifyAPITrack struct {
+	ID          string `json:"id"`
+	ExternalIDs struct {
+		ISRC string `json:"isrc"`
+	} `json:"external_ids"`
+}
+
+// deezerISRCMatcher maps Spotify tracks to the Deezer track with the same
+// ISRC, so deemix downloads that exact recording instead of whatever its
+// Spotify plugin matches the link to. The Spotify token is fetched once per
+// source run and shared by all workers.
+type deezerISRCMatcher struct {
+	creds    auth.SpotifyCredentials
+	mu       sync.Mutex
+	token    string
+	tokenErr error
+}
+
+func newDeezerISRCMatcher(creds auth.SpotifyCredentials) *deezerISRCMatcher {
+	return &deezerISRCMatcher{creds: creds}
+}
+
+func (m *deezerISRCMatcher) accessToken(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.token == "" && m.tokenErr == nil {
+		m.token, m.tokenErr = fetchSpotifyAccessTokenFn(ctx, m.creds)
+	}
+	return m.token, m.tokenErr
+}
+
+// match returns the readable Deezer track with the given ISRC, looking the
+// ISRC up on the Spotify track endpoint when the playlist listing did not
+// carry one. ok is false when no Deezer track has that ISRC or the match
+// cannot be streamed.
+func (m *deezerISRCMatcher) match(ctx context.Context, trackID string, isrc string) (deezerRemoteTrack, string, bool, error) {
+	isrc = strings.ToUpper(strings.TrimSpace(isrc))
+	if isrc == "" {
+		token, err := m.accessToken(ctx)
+		if err != nil {
+			return deezerRemoteTrack{}, "", false, err
+		}
+		isrc, err = fetchSpotifyTrackISRCFn(ctx, token, trackID)
+		if err != nil {
+			return deezerRemoteTrack{}, "", false, err
+		}
+		isrc = strings.ToUpper(strings.TrimSpace(isrc))
+		if isrc == "" {
+			return deezerRemoteTrack{}, "", false, nil
+		}
+	}
+	track, found, err := lookupDeezerTrackByISRCFn(ctx, isrc)
+	if err != nil {
+		return deezerRemoteTrack{}, isrc, false, err
+	}
+	if !found || !track.Readable || track.ID == "" {
+		return deezerRemoteTrack{}, isrc, false, nil
+	}
+	return track, isrc, true, nil
+}
+
+// deezerURLForSpotifyTrack resolves the Deezer link deemix should download
+// for a Spotify track, or "" to fall back to the Spotify link and deemix's
+// own Spotify plugin. Lookup failures are reported as warnings, never as
+// track failures.
+func (s *Syncer) deezerURLForSpotifyTrack(
+	ctx context.Context,
+	sourceID string,
+	matcher *deezerISRCMatcher,
+	trackID string,
+	metadata map[string]spotifyTrackMetadata,
+) string {
+	if matcher == nil || extractSpotifyTrackID(trackID) == "" {
+		return ""
+	}
+	track, isrc, ok, err := matcher.match(ctx, trackID, metadata[trackID].ISRC)
+	if err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  sourceID,
+			Message:   fmt.Sprintf("[%s] isrc lookup failed for %s: %v; using the spotify link", sourceID, trackID, err),
+		})
+		return ""
+	}
+	if !ok {
+		if isrc != "" {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  sourceID,
+				Message:   fmt.Sprintf("[%s] [isrc] %s has no readable deezer match for %s; using the spotify link", sourceID, trackID, isrc),
+			})
+		}
+		return ""
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [isrc] %s matched deezer track %s (%s)", sourceID, trackID, track.ID, isrc),
+		Details: map[string]any{
+			"track_id":        trackID,
+			"isrc":            isrc,
+			"deezer_track_id": track.ID,
+		},
+	})
+	return deezerTrackURL(track.ID)
+}
+
+func fetchSpotifyTrackISRC(ctx context.Context, token string, trackID string) (string, error) {
+	id := extractSpotifyTrackID(trackID)
+	if id == "" {
+		return "", fmt.Errorf("invalid spotify track id %q", trackID)
+	}
+	var payload spotifyAPITrack
+	rawURL := strings.TrimSuffix(spotifyAPIBaseURL, "/") + "/v1/tracks/" + url.PathEscape(id)
+	if err := getSpotifyJSON(ctx, rawURL, token, &payload); err != nil {
+		return "", fmt.Errorf("spotify track %s: %w", id, err)
+	}
+	return strings.TrimSpace(payload.ExternalIDs.ISRC), nil
+}
+
+// lookupDeezerTrackByISRC queries api.deezer.com/track/isrc:<ISRC>. found is
+// false when Deezer has no track with that ISRC.
+func lookupDeezerTrackByISRC(ctx context.Context, isrc string) (deezerRemoteTrack, bool, error) {
+	var payload deezerAPITrackResponse
+	rawURL := strings.TrimSuffix(deezerAPIBaseURL, "/") + "/track/isrc:" + url.PathEscape(isrc)
+	if err := getDeezerJSON(ctx, rawURL, &payload); err != nil {
+		return deezerRemoteTrack{}, false, fmt.Errorf("deezer isrc %s: %w", isrc, err)
+	}
+	if payload.Error != nil {
+		if payload.Error.Code == deezerAPINoDataCode {
+			return deezerRemoteTrack{}, false, nil
+		}
+		return deezerRemoteTrack{}, false, fmt.Errorf("deezer isrc %s: %s", isrc, payload.Error.describe())
+	}
+	track := payload.deezerAPITrack.remote()
+	return track, track.ID != "", nil
+}
@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestSyncerSpotifyDeemixPrefersDeezerISRCMatch(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "spotify-deemix",
+				Type:      config.SourceTypeSpotify,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://open.spotify.com/playlist/a",
+				StateFile: "spotify-deemix.sync.spotify",
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origSaveARL := saveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	origToken := fetchSpotifyAccessTokenFn
+	origISRC := fetchSpotifyTrackISRCFn
+	origLookup := lookupDeezerTrackByISRCFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		saveDeemixARLFn = origSaveARL
+		enumerateSpotifyTracksFn = origEnumerate
+		fetchSpotifyAccessTokenFn = origToken
+		fetchSpotifyTrackISRCFn = origISRC
+		lookupDeezerTrackByISRCFn = origLookup
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	saveDeemixARLFn = func(string) error { return nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		return []spotifyRemoteTrack{
+			{ID: "1abc234def", Title: "track-1", Artist: "artist-1", ISRC: "usrc11111111"},
+			{ID: "2abc234def", Title: "track-2", Artist: "artist-2"},
+			{ID: "3abc234def", Title: "track-3", Artist: "artist-3", ISRC: "USRC33333333"},
+		}, nil
+	}
+	tokenCalls := 0
+	fetchSpotifyAccessTokenFn = func(ctx context.Context, creds auth.SpotifyCredentials) (string, error) {
+		tokenCalls++
+		return "token", nil
+	}
+	fetchSpotifyTrackISRCFn = func(ctx context.Context, token string, trackID string) (string, error) {
+		if trackID != "2abc234def" || token != "token" {
+			t.Errorf("unexpected spotify isrc lookup for %s with token %q", trackID, token)
+			return "", nil
+		}
+		return "USRC22222222", nil
+	}
+	lookupDeezerTrackByISRCFn = func(ctx context.Context, isrc string) (deezerRemoteTrack, bool, error) {
+		switch isrc {
+		case "USRC11111111":
+			return deezerRemoteTrack{ID: "3135556", Readable: true}, true, nil
+		case "USRC22222222":
+			return deezerRemoteTrack{}, false, nil
+		default:
+			return deezerRemoteTrack{}, false, errors.New("HTTP 503")
+		}
+	}
+
+	runner := &sequenceRunner{}
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"deemix": fakeDeemixAdapter{}}, runner, emitter)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{ScanGaps: true})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful deemix source run, got %+v", result)
+	}
+	urls := []string{}
+	for _, spec := range runner.specs {
+		urls = append(urls, spec.Args[0])
+	}
+	want := []string{deezerTrackURL("3135556"), spotifyTrackURL("2abc234def"), spotifyTrackURL("3abc234def")}
+	if strings.Join(urls, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected deezer link only for the isrc match, got %v", urls)
+	}
+	if tokenCalls != 1 {
+		t.Fatalf("expected one spotify token fetch, got %d", tokenCalls)
+	}
+
+	messages := []string{}
+	for _, event := range emitter.events {
+		messages = append(messages, event.Message)
+	}
+	joined := strings.Join(messages, "\n")
+	for _, wantLine := range []string{
+		"[spotify-deemix] [isrc] 1abc234def matched deezer track 3135556 (USRC11111111)",
+		"[spotify-deemix] [isrc] 2abc234def has no readable deezer match for USRC22222222; using the spotify link",
+		"[spotify-deemix] isrc lookup failed for 3abc234def: HTTP 503; using the spotify link",
+	} {
+		if !strings.Contains(joined, wantLine) {
+			t.Fatalf("expected %q in events, got:\n%s", wantLine, joined)
+		}
+	}
+
+	payload, err := os.ReadFile(filepath.Join(stateDir, "spotify-deemix.sync.spotify"))
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if got := strings.Join(spotifyStateIDsFromPayload(string(payload)), ","); got != "1abc234def,2abc234def,3abc234def" {
+		t.Fatalf("expected spotify ids in state, got %q", got)
+	}
+}
+
+func TestLookupDeezerTrackByISRCTreatsNoDataAsMissing(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/track/isrc:USRC11111111":
+			_, _ = w.Write([]byte(`{"id":3135556,"title":"One More Time","readable":true,"artist":{"name":"Daft Punk"}}`))
+		default:
+			_, _ = w.Write([]byte(`{"error":{"type":"DataException","message":"no data","code":800}}`))
+		}
+	}))
+	defer server.Close()
+	origBase := deezerAPIBaseURL
+	t.Cleanup(func() { deezerAPIBaseURL = origBase })
+	deezerAPIBaseURL = server.URL
+
+	track, found, err := lookupDeezerTrackByISRC(context.Background(), "USRC11111111")
+	if err != nil || !found || track.ID != "3135556" || !track.Readable {
+		t.Fatalf("expected isrc match, got %+v found=%v err=%v", track, found, err)
+	}
+	_, found, err = lookupDeezerTrackByISRC(context.Background(), "USRC00000000")
+	if err != nil || found {
+		t.Fatalf("expected no match without error, got found=%v err=%v", found, err)
+	}
+}
@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// deemixQualityTiers returns the source's quality list, best first, or a
+// single empty tier when it sets none so deemix keeps its own bitrate.
+func deemixQualityTiers(source config.Source) []string {
+	if len(source.Quality) == 0 {
+		return []string{""}
+	}
+	return append([]string(nil), source.Quality...)
+}
+
+// deemixReportedWrongBitrate reports whether deemix could not find the track
+// at the requested bitrate. Runtime configs for sources with a quality list
+// turn deemix's own fallback off, so this is what triggers the next tier.
+func deemixReportedWrongBitrate(execResult ExecResult) bool {
+	combined := strings.ToLower(execResult.StdoutTail + "\n" + execResult.StderrTail)
+	return strings.Contains(combined, "track not found at desired bitrate")
+}
+
+// runDeemixTrack runs spec, built for tiers[0], and while deemix reports the
+// track missing at that bitrate rebuilds it for the next tier and runs it
+// again. It returns the last spec and result and the tier that run used.
+// downloadDir is where the track lands; the stall watchdog measures the
+// download's progress there and in the runtime dir.
+func (s *Syncer) runDeemixTrack(
+	ctx context.Context,
+	source config.Source,
+	trackID string,
+	tiers []string,
+	spec ExecSpec,
+	flow sourceFlowContext,
+	rebuild func(quality string) (ExecSpec, error),
+	downloadDir string,
+) (ExecSpec, ExecResult, string, error) {
+	for tier := 0; ; tier++ {
+		execResult := s.runWithStallWatchdog(ctx, source, spec, []string{downloadDir, spec.Dir})
+		s.flushFlowParser(flow, source)
+		if execResult.Interrupted || execResult.Stalled || tier+1 >= len(tiers) || !deemixReportedWrongBitrate(execResult) {
+			return spec, execResult, tiers[tier], nil
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [quality] %s not available as %s; retrying at %s", source.ID, trackID, tiers[tier], tiers[tier+1]),
+			Details: map[string]any{
+				"track_id": trackID,
+				"quality":  tiers[tier+1],
+			},
+		})
+		next, err := rebuild(tiers[tier+1])
+		if err != nil {
+			return spec, execResult, tiers[tier], err
+		}
+		spec = next
+	}
+}
@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+type bitrateDeemixAdapter struct {
+	fakeDeemixAdapter
+}
+
+func (a bitrateDeemixAdapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (ExecSpec, error) {
+	spec, err := a.fakeDeemixAdapter.BuildExecSpec(source, defaults, timeout)
+	spec.Args = append(spec.Args, "--bitrate", source.DeemixBitrate)
+	return spec, err
+}
+
+func TestSyncerDeemixQualityFallsBackThroughTiers(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "deezer-mix",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/en/playlist/908622995",
+				StateFile: "deezer-mix.sync.deezer",
+				Quality:   []string{"flac", "320", "128"},
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateDeezerTracksFn
+	t.Cleanup(func() {
+		resolveDeemixARLFn = origResolveARL
+		enumerateDeezerTracksFn = origEnumerate
+	})
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateDeezerTracksFn = func(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+		return []deezerRemoteTrack{
+			{ID: "3135556", Title: "Harder, Better, Faster, Stronger", Artist: "Daft Punk", Readable: true},
+			{ID: "3135558", Title: "One More Time", Artist: "Daft Punk", Readable: true},
+		}, nil
+	}
+
+	wrongBitrate := ExecResult{ExitCode: 0, StdoutTail: "Track not found at desired bitrate."}
+	runner := &sequenceRunner{results: []ExecResult{
+		wrongBitrate, {ExitCode: 0},
+		wrongBitrate, wrongBitrate, wrongBitrate,
+	}}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": bitrateDeemixAdapter{}},
+		runner,
+		output.NewHumanEmitter(&out, &out, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful deezer source run, got %+v\n%s", result, out.String())
+	}
+	bitrates := []string{}
+	for _, spec := range runner.specs {
+		bitrates = append(bitrates, spec.Args[len(spec.Args)-1])
+	}
+	if got := strings.Join(bitrates, ","); got != "flac,320,flac,320,128" {
+		t.Fatalf("expected each track to walk the quality tiers, got %s", got)
+	}
+	if !strings.Contains(out.String(), "[quality] 3135556 not available as flac; retrying at 320") {
+		t.Fatalf("expected quality retry line, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[skip] 3135558 (Daft Punk - One More Time) (unavailable-at-requested-quality)") {
+		t.Fatalf("expected skip after the last tier, got:\n%s", out.String())
+	}
+
+	state, err := parseDeezerSyncState(filepath.Join(stateDir, "deezer-mix.sync.deezer"))
+	if err != nil {
+		t.Fatalf("parse deezer state: %v", err)
+	}
+	if _, ok := state.KnownIDs["3135558"]; ok || len(state.KnownIDs) != 1 {
+		t.Fatalf("expected only the downloaded track in deezer state, got %+v", state.KnownIDs)
+	}
+	if got := state.Entries["3135556"].Quality; got != "320" {
+		t.Fatalf("expected obtained quality in deezer state, got %q", got)
+	}
+}
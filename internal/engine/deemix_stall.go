@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// stallPollInterval is how often the stall watchdog measures how far a
+// track's download has grown.
+var stallPollInterval = 5 * time.Second
+
+// trackStallPolicy is a source's sync.stall_min_kbps and sync.stall_seconds.
+type trackStallPolicy struct {
+	minBytesPerSecond float64
+	window            time.Duration
+}
+
+func trackStallPolicyFor(source config.Source) (trackStallPolicy, bool) {
+	if source.Sync.StallMinKbps <= 0 || source.Sync.StallSeconds <= 0 {
+		return trackStallPolicy{}, false
+	}
+	return trackStallPolicy{
+		minBytesPerSecond: float64(source.Sync.StallMinKbps) * 1000 / 8,
+		window:            time.Duration(source.Sync.StallSeconds) * time.Second,
+	}, true
+}
+
+type stallSample struct {
+	at    time.Time
+	bytes int64
+}
+
+// runWithStallWatchdog runs spec and cancels it once the files written under
+// dirs since it started grew slower than the source's stall rate over the
+// whole trailing window. A cancelled run comes back with Stalled set and
+// ExitCode 1 rather than as an interruption; sources without a stall policy
+// run spec as is.
+func (s *Syncer) runWithStallWatchdog(ctx context.Context, source config.Source, spec ExecSpec, dirs []string) ExecResult {
+	policy, ok := trackStallPolicyFor(source)
+	if !ok {
+		return s.Runner.Run(ctx, spec)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	started := time.Now()
+	interval := stallPollInterval
+	var stalled atomic.Bool
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		samples := []stallSample{{at: started}}
+		for {
+			select {
+			case <-done:
+				return
+			case <-runCtx.Done():
+				return
+			case now := <-ticker.C:
+				samples = append(samples, stallSample{at: now, bytes: downloadedBytesSince(dirs, started)})
+				oldest := -1
+				for i, sample := range samples {
+					if now.Sub(sample.at) >= policy.window {
+						oldest = i
+					}
+				}
+				if oldest < 0 {
+					continue
+				}
+				samples = samples[oldest:]
+				elapsed := now.Sub(samples[0].at).Seconds()
+				grown := samples[len(samples)-1].bytes - samples[0].bytes
+				if float64(grown)/elapsed < policy.minBytesPerSecond {
+					stalled.Store(true)
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	result := s.Runner.Run(runCtx, spec)
+	close(done)
+	<-exited
+	if stalled.Load() && ctx.Err() == nil {
+		result.Interrupted = false
+		result.Stalled = true
+		result.ExitCode = 1
+	}
+	return result
+}
+
+// downloadedBytesSince sums the sizes of the files under dirs modified at or
+// after since. Unreadable paths count as empty.
+func downloadedBytesSince(dirs []string, since time.Time) int64 {
+	// Coarse filesystem timestamps can put a file written right after since
+	// just before it.
+	since = since.Add(-time.Second)
+	total := int64(0)
+	seen := map[string]struct{}{}
+	for _, dir := range dirs {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		if expanded, err := config.ExpandPath(dir); err == nil {
+			dir = expanded
+		}
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				if entry != nil && entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			info, err := entry.Info()
+			if err != nil || info.ModTime().Before(since) {
+				return nil
+			}
+			total += info.Size()
+			return nil
+		})
+	}
+	return total
+}
+
+// emitTrackStalled reports a track the stall watchdog cancelled. requeued
+// tells whether it runs again at the end of this run; otherwise it stays out
+// of the state file so the next sync plans it again.
+func (s *Syncer) emitTrackStalled(source config.Source, trackID string, display string, requeued bool) {
+	next := "left for the next sync"
+	if requeued {
+		next = "re-queued at the end of the run"
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelWarn,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message: fmt.Sprintf(
+			"[%s] [stalled] %s (%s) below %d kbps for %ds; %s",
+			source.ID,
+			trackID,
+			display,
+			source.Sync.StallMinKbps,
+			source.Sync.StallSeconds,
+			next,
+		),
+		Details: map[string]any{
+			"track_id":       trackID,
+			"stall_min_kbps": source.Sync.StallMinKbps,
+			"stall_seconds":  source.Sync.StallSeconds,
+			"requeued":       requeued,
+		},
+	})
+}
+
+// removeStalledPartial deletes the media files under root that a cancelled
+// track run created (rel paths as snapshotMediaFiles keys them), so the
+// re-queued run or the next sync does not take a truncated download for a
+// finished one. Files that existed before the run are left alone.
+func removeStalledPartial(root string, before map[string]mediaFileSnapshot, rels ...string) {
+	for _, rel := range rels {
+		if _, existed := before[rel]; existed || rel == "" {
+			continue
+		}
+		_ = os.Remove(filepath.Join(root, filepath.FromSlash(rel)))
+	}
+}
+
+// newMediaPaths lists the files in after that are not in before.
+func newMediaPaths(before, after map[string]mediaFileSnapshot) []string {
+	paths := []string{}
+	for path := range after {
+		if _, existed := before[path]; !existed {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
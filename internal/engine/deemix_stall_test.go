@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// stallingRunner leaves a partial file for each track URL in stallsByURL
+// and then hangs until cancelled, as deemix does on a stuck CDN edge; other
+// runs write a finished file.
+type stallingRunner struct {
+	mu          sync.Mutex
+	targetDir   string
+	stallsByURL map[string]int
+	urls        []string
+}
+
+func (r *stallingRunner) Run(ctx context.Context, spec ExecSpec) ExecResult {
+	url := spec.Args[0]
+	name := url[strings.LastIndex(url, "/")+1:] + ".mp3"
+	r.mu.Lock()
+	r.urls = append(r.urls, url)
+	stall := r.stallsByURL[url] > 0
+	if stall {
+		r.stallsByURL[url]--
+	}
+	r.mu.Unlock()
+	if !stall {
+		_ = os.WriteFile(filepath.Join(r.targetDir, name), bytes.Repeat([]byte("a"), 4096), 0o644)
+		return ExecResult{ExitCode: 0}
+	}
+	_ = os.WriteFile(filepath.Join(r.targetDir, name), []byte("partial"), 0o644)
+	<-ctx.Done()
+	return ExecResult{ExitCode: 130, Interrupted: true}
+}
+
+func TestSyncerDeemixRequeuesStalledTracks(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "deezer-mix",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/en/playlist/908622995",
+				StateFile: "deezer-mix.sync.deezer",
+				Sync:      config.SyncPolicy{StallMinKbps: 64, StallSeconds: 1},
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateDeezerTracksFn
+	origPoll := stallPollInterval
+	t.Cleanup(func() {
+		resolveDeemixARLFn = origResolveARL
+		enumerateDeezerTracksFn = origEnumerate
+		stallPollInterval = origPoll
+	})
+	stallPollInterval = 50 * time.Millisecond
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateDeezerTracksFn = func(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+		return []deezerRemoteTrack{
+			{ID: "1", Title: "Stuck", Artist: "Artist", Readable: true},
+			{ID: "2", Title: "Slow Once", Artist: "Artist", Readable: true},
+			{ID: "3", Title: "Fine", Artist: "Artist", Readable: true},
+		}, nil
+	}
+
+	runner := &stallingRunner{
+		targetDir: targetDir,
+		stallsByURL: map[string]int{
+			deezerTrackURL("1"): 2,
+			deezerTrackURL("2"): 1,
+		},
+	}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixAdapter{}},
+		runner,
+		output.NewHumanEmitter(&out, &out, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 || result.Interrupted {
+		t.Fatalf("expected stalled tracks not to fail or interrupt the source, got %+v\n%s", result, out.String())
+	}
+	order := []string{}
+	for _, url := range runner.urls {
+		order = append(order, url[strings.LastIndex(url, "/")+1:])
+	}
+	if got := strings.Join(order, ","); got != "1,2,3,1,2" {
+		t.Fatalf("expected stalled tracks to run again after the rest of the plan, got %s", got)
+	}
+	for _, want := range []string{
+		"[stalled] 1 (Artist - Stuck) below 64 kbps for 1s; re-queued at the end of the run",
+		"[stalled] 1 (Artist - Stuck) below 64 kbps for 1s; left for the next sync",
+		"[stalled] 2 (Artist - Slow Once) below 64 kbps for 1s; re-queued at the end of the run",
+		"[done] 2 (Artist - Slow Once)",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out.String())
+		}
+	}
+
+	state, err := parseDeezerSyncState(filepath.Join(stateDir, "deezer-mix.sync.deezer"))
+	if err != nil {
+		t.Fatalf("parse deezer state: %v", err)
+	}
+	if _, ok := state.KnownIDs["1"]; ok || len(state.KnownIDs) != 2 {
+		t.Fatalf("expected the twice-stalled track to stay out of state for the next sync, got %+v", state.KnownIDs)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "1.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("expected the stalled partial download to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	deezerAPIPageSize = 100
+	deezerAPIMaxPages = 500
+)
+
+var (
+	deezerAPIBaseURL    = "https://api.deezer.com"
+	deezerAPIHTTPClient = &http.Client{Timeout: 20 * time.Second}
+)
+
+type deezerResourceKind string
+
+const (
+	deezerResourcePlaylist deezerResourceKind = "playlist"
+	deezerResourceAlbum    deezerResourceKind = "album"
+	deezerResourceTrack    deezerResourceKind = "track"
+)
+
+var errDeezerURLUnsupported = errors.New("expected a deezer.com playlist, album, or track link")
+
+// deezerRemoteTrack is one track of a Deezer playlist or album. Unreadable
+// tracks are region- or license-blocked; deemix cannot download them.
+type deezerRemoteTrack struct {
+	ID       string
+	Title    string
+	Artist   string
+	Album    string
+	URL      string
+	Readable bool
+	// DurationMS is the remote track length, 0 when the API has none.
+	DurationMS int64
+}
+
+type deezerAPITrack struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Link     string `json:"link"`
+	Readable *bool  `json:"readable"`
+	Duration int64  `json:"duration"`
+	Artist   struct {
+		Name string `json:"name"`
+	} `json:"artist"`
+	Album struct {
+		Title string `json:"title"`
+	} `json:"album"`
+}
+
+type deezerAPIError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+type deezerAPITrackPage struct {
+	Data  []deezerAPITrack `json:"data"`
+	Next  string           `json:"next"`
+	Error *deezerAPIError  `json:"error"`
+}
+
+type deezerAPITrackResponse struct {
+	deezerAPITrack
+	Error *deezerAPIError `json:"error"`
+}
+
+// enumerateDeezerTracks lists a Deezer source through the public
+// api.deezer.com endpoints, which need no ARL or app credentials.
+func enumerateDeezerTracks(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+	kind, id, err := parseDeezerResourceURL(source.URL)
+	if err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(deezerAPIBaseURL, "/")
+
+	if kind == deezerResourceTrack {
+		var payload deezerAPITrackResponse
+		if err := getDeezerJSON(ctx, base+"/track/"+id, &payload); err != nil {
+			return nil, fmt.Errorf("deezer track %s: %w", id, err)
+		}
+		if payload.Error != nil {
+			return nil, fmt.Errorf("deezer track %s: %s", id, payload.Error.describe())
+		}
+		return []deezerRemoteTrack{payload.deezerAPITrack.remote()}, nil
+	}
+
+	tracks := []deezerRemoteTrack{}
+	seen := map[string]struct{}{}
+	next := fmt.Sprintf("%s/%s/%s/tracks?index=0&limit=%d", base, kind, id, deezerAPIPageSize)
+	for page := 0; next != "" && page < deezerAPIMaxPages; page++ {
+		var payload deezerAPITrackPage
+		if err := getDeezerJSON(ctx, next, &payload); err != nil {
+			return nil, fmt.Errorf("deezer %s %s: %w", kind, id, err)
+		}
+		if payload.Error != nil {
+			return nil, fmt.Errorf("deezer %s %s: %s", kind, id, payload.Error.describe())
+		}
+		for _, item := range payload.Data {
+			track := item.remote()
+			if track.ID == "" {
+				continue
+			}
+			if _, ok := seen[track.ID]; ok {
+				continue
+			}
+			seen[track.ID] = struct{}{}
+			tracks = append(tracks, track)
+		}
+		next = strings.TrimSpace(payload.Next)
+	}
+	return tracks, nil
+}
+
+func (t deezerAPITrack) remote() deezerRemoteTrack {
+	id := ""
+	if t.ID > 0 {
+		id = strconv.FormatInt(t.ID, 10)
+	}
+	link := strings.TrimSpace(t.Link)
+	if link == "" && id != "" {
+		link = deezerTrackURL(id)
+	}
+	return deezerRemoteTrack{
+		ID:         id,
+		Title:      strings.TrimSpace(t.Title),
+		Artist:     strings.TrimSpace(t.Artist.Name),
+		Album:      strings.TrimSpace(t.Album.Title),
+		URL:        link,
+		Readable:   t.Readable == nil || *t.Readable,
+		DurationMS: t.Duration * 1000,
+	}
+}
+
+func (t deezerRemoteTrack) spotifyShape() spotifyRemoteTrack {
+	return spotifyRemoteTrack{ID: t.ID, Title: t.Title, Artist: t.Artist, Album: t.Album, URL: t.URL, DurationMS: t.DurationMS}
+}
+
+func (t deezerRemoteTrack) displayName() string {
+	switch {
+	case t.Artist != "" && t.Title != "":
+		return t.Artist + " - " + t.Title
+	default:
+		return t.Title
+	}
+}
+
+func (e *deezerAPIError) describe() string {
+	message := strings.TrimSpace(e.Message)
+	if message == "" {
+		message = strings.TrimSpace(e.Type)
+	}
+	if e.Code != 0 {
+		return fmt.Sprintf("%s (code %d)", message, e.Code)
+	}
+	return message
+}
+
+func getDeezerJSON(ctx context.Context, rawURL string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := deezerAPIHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// buildDeezerPreflight diffs readable remote tracks against the state file
+// the same way spotify+deemix does; unreadable tracks are returned separately
+// so they are reported as skipped instead of failing the run.
+func buildDeezerPreflight(
+	remoteTracks []deezerRemoteTrack,
+	state spotifySyncState,
+	targetDir string,
+	mode SoundCloudMode,
+) (SoundCloudPreflight, []string, []string, []deezerRemoteTrack) {
+	readable := make([]spotifyRemoteTrack, 0, len(remoteTracks))
+	unavailable := []deezerRemoteTrack{}
+	for _, track := range remoteTracks {
+		if !track.Readable {
+			unavailable = append(unavailable, track)
+			continue
+		}
+		readable = append(readable, track.spotifyShape())
+	}
+	preflight, _, _, planned, existing := buildSpotifyPreflight(readable, state, targetDir, mode)
+	return preflight, planned, existing, unavailable
+}
+
+func parseDeezerResourceURL(rawURL string) (deezerResourceKind, string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", "", err
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if host != "deezer.com" && !strings.HasSuffix(host, ".deezer.com") {
+		return "", "", errDeezerURLUnsupported
+	}
+	parts := strings.Split(strings.Trim(path.Clean(parsed.Path), "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		kind := deezerResourceKind(parts[i])
+		switch kind {
+		case deezerResourcePlaylist, deezerResourceAlbum, deezerResourceTrack:
+		default:
+			continue
+		}
+		if !deezerIDPattern.MatchString(parts[i+1]) {
+			break
+		}
+		return kind, parts[i+1], nil
+	}
+	return "", "", errDeezerURLUnsupported
+}
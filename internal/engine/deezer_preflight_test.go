@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestParseDeezerResourceURL(t *testing.T) {
+	cases := []struct {
+		raw  string
+		kind deezerResourceKind
+		id   string
+	}{
+		{raw: "https://www.deezer.com/playlist/908622995", kind: deezerResourcePlaylist, id: "908622995"},
+		{raw: "https://www.deezer.com/en/album/302127?utm=x", kind: deezerResourceAlbum, id: "302127"},
+		{raw: "https://deezer.com/fr/track/3135556", kind: deezerResourceTrack, id: "3135556"},
+	}
+	for _, tc := range cases {
+		kind, id, err := parseDeezerResourceURL(tc.raw)
+		if err != nil {
+			t.Fatalf("parse %s: %v", tc.raw, err)
+		}
+		if kind != tc.kind || id != tc.id {
+			t.Fatalf("parse %s: got %s/%s", tc.raw, kind, id)
+		}
+	}
+	for _, raw := range []string{"https://open.spotify.com/playlist/abc", "https://www.deezer.com/en/artist/27", "https://deezer.page.link/abc"} {
+		if _, _, err := parseDeezerResourceURL(raw); err == nil {
+			t.Fatalf("expected %s to be rejected", raw)
+		}
+	}
+}
+
+func TestDeezerSyncStateRoundTrip(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "deezer.sync.deezer")
+	if err := os.WriteFile(statePath, []byte("deezer 111\nhttps://www.deezer.com/track/222\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if err := appendDeezerSyncStateEntry(statePath, "333", spotifyStateEntry{DisplayName: "Artist - Title", LocalPath: "Artist/Title.mp3"}); err != nil {
+		t.Fatalf("append state: %v", err)
+	}
+	if err := appendDeezerSyncStateEntry(statePath, "not-an-id", spotifyStateEntry{}); err == nil {
+		t.Fatalf("expected non-numeric id to be rejected")
+	}
+
+	state, err := parseDeezerSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	for _, id := range []string{"111", "222", "333"} {
+		if _, ok := state.KnownIDs[id]; !ok {
+			t.Fatalf("expected %s in state, got %+v", id, state.KnownIDs)
+		}
+	}
+	entry := state.Entries["333"]
+	if entry.DisplayName != "Artist - Title" || entry.LocalPath != "Artist/Title.mp3" {
+		t.Fatalf("unexpected state entry %+v", entry)
+	}
+}
+
+func TestEnumerateDeezerTracksFollowsPagesAndMarksUnreadable(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/playlist/42/tracks":
+			if r.URL.Query().Get("index") == "0" {
+				fmt.Fprintf(w, `{"data":[{"id":1,"title":"One","readable":true,"artist":{"name":"A"},"album":{"title":"X"}},{"id":2,"title":"Two","readable":false,"artist":{"name":"B"}}],"next":"%s/playlist/42/tracks?index=2&limit=100"}`, server.URL)
+				return
+			}
+			fmt.Fprint(w, `{"data":[{"id":3,"title":"Three","artist":{"name":"C"}},{"id":1,"title":"One"}]}`)
+		case "/track/7":
+			fmt.Fprint(w, `{"id":7,"title":"Seven","readable":true,"artist":{"name":"D"},"link":"https://www.deezer.com/track/7"}`)
+		case "/album/9/tracks":
+			fmt.Fprint(w, `{"error":{"type":"DataException","message":"no data","code":800}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origBase := deezerAPIBaseURL
+	deezerAPIBaseURL = server.URL
+	t.Cleanup(func() { deezerAPIBaseURL = origBase })
+
+	tracks, err := enumerateDeezerTracks(context.Background(), config.Source{URL: "https://www.deezer.com/en/playlist/42"})
+	if err != nil {
+		t.Fatalf("enumerate playlist: %v", err)
+	}
+	if len(tracks) != 3 {
+		t.Fatalf("expected 3 de-duplicated tracks, got %+v", tracks)
+	}
+	if tracks[0].displayName() != "A - One" || tracks[0].URL != "https://www.deezer.com/track/1" {
+		t.Fatalf("unexpected first track %+v", tracks[0])
+	}
+	if tracks[1].Readable || !tracks[2].Readable {
+		t.Fatalf("expected readable flags to follow the API (missing means readable), got %+v", tracks)
+	}
+
+	single, err := enumerateDeezerTracks(context.Background(), config.Source{URL: "https://www.deezer.com/track/7"})
+	if err != nil || len(single) != 1 || single[0].ID != "7" {
+		t.Fatalf("expected single track lookup, got %+v err=%v", single, err)
+	}
+
+	if _, err := enumerateDeezerTracks(context.Background(), config.Source{URL: "https://www.deezer.com/album/9"}); err == nil {
+		t.Fatalf("expected deezer api error payload to fail enumeration")
+	}
+}
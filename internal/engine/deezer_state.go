@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// deezerStateHeader opens Deezer state files; v1 files are migrated on the
+// next append, as Spotify state files are.
+const deezerStateHeader = "# udl deezer state v2"
+
+var deezerIDPattern = regexp.MustCompile(`^[0-9]{1,20}$`)
+
+// parseDeezerSyncState reads a Deezer source's state file. Lines use the
+// spotify v3 layout (id, title=, path=, quality=, provider=, adapter=,
+// added_at=) keyed by numeric Deezer track ids; bare track links and
+// "deezer <id>" lines are accepted as well.
+func parseDeezerSyncState(path string) (spotifySyncState, error) {
+	return parseTrackSyncState(path, parseDeezerStateLine)
+}
+
+// parseTrackStateForSource picks the state parser for a spotify, deezer, or
+// apple_music source; all three share the spotify v3 line layout.
+func parseTrackStateForSource(sourceType config.SourceType, path string) (spotifySyncState, error) {
+	return parseTrackSyncState(path, trackStateLineParser(sourceType))
+}
+
+// trackStateLineParser returns the state line parser for a spotify, deezer,
+// or apple_music source.
+func trackStateLineParser(sourceType config.SourceType) func(string) (string, spotifyStateEntry) {
+	switch sourceType {
+	case config.SourceTypeDeezer:
+		return parseDeezerStateLine
+	case config.SourceTypeAppleMusic:
+		return parseAppleMusicStateLine
+	default:
+		return parseSpotifyStateLine
+	}
+}
+
+func trackStateHeader(sourceType config.SourceType) string {
+	switch sourceType {
+	case config.SourceTypeDeezer:
+		return deezerStateHeader
+	case config.SourceTypeAppleMusic:
+		return appleMusicStateHeader
+	default:
+		return spotifyStateHeader
+	}
+}
+
+func appendDeezerSyncStateEntry(path string, id string, entry spotifyStateEntry) error {
+	trackID := extractDeezerTrackID(id)
+	if trackID == "" {
+		return errors.New("deezer track id must not be empty")
+	}
+	return appendTrackSyncStateEntry(path, deezerStateHeader, parseDeezerStateLine, trackID, entry)
+}
+
+func parseDeezerStateLine(line string) (string, spotifyStateEntry) {
+	entry := spotifyStateEntry{}
+	raw := strings.TrimSpace(line)
+	if raw == "" {
+		return "", entry
+	}
+
+	parts := strings.Split(raw, "\t")
+	id := extractDeezerTrackID(strings.TrimSpace(parts[0]))
+	if id == "" {
+		fields := strings.Fields(raw)
+		if len(fields) >= 2 && fields[0] == "deezer" {
+			id = extractDeezerTrackID(fields[1])
+		}
+		return id, entry
+	}
+
+	for _, field := range parts[1:] {
+		parseTrackStateField(&entry, field)
+	}
+	return id, entry
+}
+
+// extractDeezerTrackID accepts a numeric id or a deezer.com track link,
+// with or without a locale segment (https://www.deezer.com/en/track/123).
+func extractDeezerTrackID(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return ""
+	}
+	if strings.HasPrefix(trimmed, "https://") || strings.HasPrefix(trimmed, "http://") {
+		kind, id, err := parseDeezerResourceURL(trimmed)
+		if err != nil || kind != deezerResourceTrack {
+			return ""
+		}
+		return id
+	}
+	if !deezerIDPattern.MatchString(trimmed) {
+		return ""
+	}
+	return trimmed
+}
+
+func deezerTrackURL(trackID string) string {
+	return "https://www.deezer.com/track/" + strings.TrimSpace(trackID)
+}
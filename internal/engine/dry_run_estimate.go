@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// nominalBitrateKbps is the audio bitrate a dry-run size estimate assumes
+// for an adapter when the source sets no quality list.
+var nominalBitrateKbps = map[string]int{
+	"deemix":      128,
+	"spotdl":      128,
+	"scdl":        128,
+	"scdl-freedl": 320,
+	"ytdlp":       160,
+	"tidal-dl":    1000,
+	"gamdl":       256,
+}
+
+// qualityBitrateKbps maps quality tiers to the bitrate they stand for;
+// flac is an average for 16-bit/44.1 kHz material.
+var qualityBitrateKbps = map[string]int{
+	"flac": 1000,
+	"320":  320,
+	"128":  128,
+}
+
+const defaultNominalBitrateKbps = 192
+
+// dryRunEstimate is the predicted cost of downloading a source's planned
+// tracks. Bytes and AudioDuration are zero when neither remote durations nor
+// local files give a basis; DownloadTime is zero without history.
+type dryRunEstimate struct {
+	PlannedTracks   int
+	AudioDuration   time.Duration
+	Bytes           int64
+	SizeBasis       string
+	DownloadTime    time.Duration
+	SecondsPerTrack float64
+	HistoryTracks   int
+}
+
+func (p *SoundCloudPreflight) notePlannedDuration(durationMS int64) {
+	if durationMS <= 0 {
+		return
+	}
+	p.PlannedDurationMS += durationMS
+	p.PlannedDurationKnown++
+}
+
+// estimateDryRun predicts size and time for the planned tracks. Size comes
+// from the remote track lengths at the source's nominal bitrate, with the
+// average length standing in for tracks the listing did not time; without
+// any lengths it falls back to the average size of media files already in
+// target_dir. Time is the planned count times the seconds per track finished
+// runs took in the history journal: this source's own runs, else those of
+// sources with the same adapter, else all runs.
+func estimateDryRun(cfg config.Config, source config.Source, preflight *SoundCloudPreflight) dryRunEstimate {
+	estimate := dryRunEstimate{PlannedTracks: preflight.PlannedDownloadCount}
+	if estimate.PlannedTracks <= 0 {
+		return estimate
+	}
+
+	if preflight.PlannedDurationKnown > 0 {
+		average := preflight.PlannedDurationMS / int64(preflight.PlannedDurationKnown)
+		estimate.AudioDuration = time.Duration(average*int64(estimate.PlannedTracks)) * time.Millisecond
+		estimate.Bytes = int64(estimate.AudioDuration.Seconds() * float64(sourceBitrateKbps(source)) * 1000 / 8)
+		estimate.SizeBasis = "remote-duration"
+	} else if average := averageLocalMediaSize(source.TargetDir); average > 0 {
+		estimate.Bytes = average * int64(estimate.PlannedTracks)
+		estimate.SizeBasis = "local-average"
+	}
+
+	estimate.SecondsPerTrack, estimate.HistoryTracks = historicalSecondsPerTrack(cfg, source)
+	if estimate.HistoryTracks > 0 {
+		estimate.DownloadTime = time.Duration(estimate.SecondsPerTrack * float64(estimate.PlannedTracks) * float64(time.Second))
+	}
+	return estimate
+}
+
+func sourceBitrateKbps(source config.Source) int {
+	if len(source.Quality) > 0 {
+		if kbps, ok := qualityBitrateKbps[source.Quality[0]]; ok {
+			return kbps
+		}
+	}
+	if kbps, ok := nominalBitrateKbps[source.Adapter.Kind]; ok {
+		return kbps
+	}
+	return defaultNominalBitrateKbps
+}
+
+func averageLocalMediaSize(targetDir string) int64 {
+	root, err := config.ExpandTargetDir(targetDir)
+	if err != nil {
+		return 0
+	}
+	files, err := snapshotMediaFiles(root)
+	if err != nil || len(files) == 0 {
+		return 0
+	}
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	return total / int64(len(files))
+}
+
+// historicalSecondsPerTrack returns the average wall-clock seconds per
+// downloaded track and how many tracks that average covers.
+func historicalSecondsPerTrack(cfg config.Config, source config.Source) (float64, int) {
+	entries, err := LoadHistory(cfg.Defaults.StateDir, "", 0)
+	if err != nil || len(entries) == 0 {
+		return 0, 0
+	}
+	kinds := make(map[string]string, len(cfg.Sources))
+	for _, candidate := range cfg.Sources {
+		kinds[candidate.ID] = candidate.Adapter.Kind
+	}
+	type sample struct {
+		ms     int64
+		tracks int
+	}
+	var own, sameKind, all sample
+	for _, entry := range entries {
+		for _, run := range entry.Sources {
+			if run.Status != "finished" || run.DurationMS <= 0 || len(run.DownloadedTrackIDs) == 0 {
+				continue
+			}
+			tracks := len(run.DownloadedTrackIDs)
+			all.ms += run.DurationMS
+			all.tracks += tracks
+			if kinds[run.SourceID] == source.Adapter.Kind {
+				sameKind.ms += run.DurationMS
+				sameKind.tracks += tracks
+			}
+			if run.SourceID == source.ID {
+				own.ms += run.DurationMS
+				own.tracks += tracks
+			}
+		}
+	}
+	for _, candidate := range []sample{own, sameKind, all} {
+		if candidate.tracks > 0 {
+			return float64(candidate.ms) / 1000 / float64(candidate.tracks), candidate.tracks
+		}
+	}
+	return 0, 0
+}
+
+// emitDryRunEstimate prints the predicted download size and time for a
+// source's planned tracks. Sources without a preflight plan are skipped,
+// since the planned count is unknown.
+func (s *Syncer) emitDryRunEstimate(cfg config.Config, source config.Source, preflight *SoundCloudPreflight) {
+	if preflight == nil || preflight.PlannedDownloadCount <= 0 {
+		return
+	}
+	estimate := estimateDryRun(cfg, source, preflight)
+	parts := []string{fmt.Sprintf("planned=%d", estimate.PlannedTracks)}
+	if estimate.AudioDuration > 0 {
+		parts = append(parts, "audio="+formatEstimateDuration(estimate.AudioDuration))
+	}
+	if estimate.Bytes > 0 {
+		parts = append(parts, "size=~"+formatEstimateBytes(estimate.Bytes))
+	} else {
+		parts = append(parts, "size=unknown")
+	}
+	if estimate.HistoryTracks > 0 {
+		parts = append(parts, fmt.Sprintf(
+			"time=~%s (%.0fs/track over %d past tracks)",
+			formatEstimateDuration(estimate.DownloadTime),
+			estimate.SecondsPerTrack,
+			estimate.HistoryTracks,
+		))
+	} else {
+		parts = append(parts, "time=unknown (no download history yet)")
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] estimate: %s", source.ID, strings.Join(parts, " ")),
+		Details: map[string]any{
+			"planned_download_count": estimate.PlannedTracks,
+			"estimated_audio_ms":     estimate.AudioDuration.Milliseconds(),
+			"estimated_bytes":        estimate.Bytes,
+			"size_basis":             estimate.SizeBasis,
+			"estimated_download_ms":  estimate.DownloadTime.Milliseconds(),
+			"seconds_per_track":      estimate.SecondsPerTrack,
+			"history_track_count":    estimate.HistoryTracks,
+		},
+	})
+}
+
+func formatEstimateBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+	value := float64(bytes)
+	suffixes := []string{"KB", "MB", "GB", "TB"}
+	suffix := ""
+	for _, candidate := range suffixes {
+		value /= unit
+		suffix = candidate
+		if value < unit {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
+
+func formatEstimateDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "<1m"
+	}
+	hours := int(d / time.Hour)
+	minutes := int((d % time.Hour) / time.Minute)
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh%02dm", hours, minutes)
+}
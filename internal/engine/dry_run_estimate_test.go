@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestEstimateDryRunUsesRemoteDurationsAndHistory(t *testing.T) {
+	stateDir := t.TempDir()
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir},
+		Sources: []config.Source{
+			{ID: "deezer-mix", Adapter: config.AdapterSpec{Kind: "deemix"}, Quality: []string{"320"}},
+			{ID: "spotify-mix", Adapter: config.AdapterSpec{Kind: "deemix"}},
+			{ID: "sc-likes", Adapter: config.AdapterSpec{Kind: "scdl"}},
+		},
+	}
+	started := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	for _, entry := range []HistoryEntry{
+		{StartedAt: started, Sources: []HistorySource{
+			{SourceID: "spotify-mix", Status: "finished", DurationMS: 40_000, DownloadedTrackIDs: []string{"a", "b"}},
+			{SourceID: "sc-likes", Status: "finished", DurationMS: 90_000, DownloadedTrackIDs: []string{"c"}},
+			{SourceID: "deezer-mix", Status: "failed", DurationMS: 5_000, DownloadedTrackIDs: []string{"d"}},
+		}},
+	} {
+		if err := appendHistoryEntry(stateDir, entry); err != nil {
+			t.Fatalf("append history: %v", err)
+		}
+	}
+
+	// Two of three planned tracks carry a length (3m + 5m), so the third
+	// counts as the 4m average.
+	preflight := &SoundCloudPreflight{PlannedDownloadCount: 3}
+	preflight.notePlannedDuration(180_000)
+	preflight.notePlannedDuration(300_000)
+	preflight.notePlannedDuration(0)
+
+	estimate := estimateDryRun(cfg, cfg.Sources[0], preflight)
+	if estimate.AudioDuration != 12*time.Minute {
+		t.Fatalf("expected 12m of audio, got %s", estimate.AudioDuration)
+	}
+	if want := int64(720 * 320 * 1000 / 8); estimate.Bytes != want || estimate.SizeBasis != "remote-duration" {
+		t.Fatalf("expected %d bytes from remote durations, got %d (%s)", want, estimate.Bytes, estimate.SizeBasis)
+	}
+	// deezer-mix has no finished runs, so the other deemix source's 20s per
+	// track is used rather than the all-source average.
+	if estimate.SecondsPerTrack != 20 || estimate.HistoryTracks != 2 || estimate.DownloadTime != time.Minute {
+		t.Fatalf("expected same-adapter history, got %+v", estimate)
+	}
+}
+
+func TestEmitDryRunEstimateFallsBackToLocalFileSizes(t *testing.T) {
+	targetDir := t.TempDir()
+	for name, size := range map[string]int{"a.mp3": 3 << 20, "b.mp3": 5 << 20, "cover.jpg": 1 << 20} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), make([]byte, size), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	cfg := config.Config{Defaults: config.Defaults{StateDir: t.TempDir()}}
+	source := config.Source{ID: "sc-likes", TargetDir: targetDir, Adapter: config.AdapterSpec{Kind: "scdl"}}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, &sequenceRunner{}, output.NewHumanEmitter(&out, &out, false, false))
+	syncer.emitDryRunEstimate(cfg, source, &SoundCloudPreflight{PlannedDownloadCount: 10})
+	if got := strings.TrimSpace(out.String()); got != "[sc-likes] estimate: planned=10 size=~40.0MB time=unknown (no download history yet)" {
+		t.Fatalf("unexpected estimate line %q", got)
+	}
+}
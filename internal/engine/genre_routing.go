@@ -0,0 +1,283 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+var (
+	fetchSpotifyTrackGenresFn = fetchSpotifyTrackGenres
+	fetchDeezerTrackGenresFn  = fetchDeezerTrackGenres
+)
+
+// genreRouteFolder returns the sync.genre_routing folder for a track with the
+// given genres, or "" when no rule matches. Rules are tried in order, and a
+// genre tag holding several values ("House; Techno") is split first.
+func genreRouteFolder(routes []config.GenreRoute, genres ...string) string {
+	values := make([]string, 0, len(genres))
+	for _, genre := range genres {
+		for _, value := range strings.FieldsFunc(genre, func(r rune) bool { return r == ';' || r == ',' }) {
+			if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	for _, route := range routes {
+		for _, pattern := range route.Genres {
+			for _, value := range values {
+				if matched, _ := path.Match(pattern, value); matched {
+					return route.Folder
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// routedTargetDir is the directory a track is downloaded into: its genre
+// folder under targetDir, or targetDir itself when no rule matches.
+func routedTargetDir(targetDir string, routes []config.GenreRoute, genres ...string) string {
+	folder := genreRouteFolder(routes, genres...)
+	if folder == "" {
+		return targetDir
+	}
+	return filepath.Join(targetDir, filepath.FromSlash(folder))
+}
+
+// fetchSpotifyTrackGenres collects the genres of a Spotify track's artists;
+// Spotify only assigns genres to artists, not to tracks or albums.
+func fetchSpotifyTrackGenres(ctx context.Context, token string, trackID string) ([]string, error) {
+	id := extractSpotifyTrackID(trackID)
+	if id == "" {
+		return nil, fmt.Errorf("invalid spotify track id %q", trackID)
+	}
+	base := strings.TrimSuffix(spotifyAPIBaseURL, "/")
+	var track struct {
+		Artists []spotifyAPIArtist `json:"artists"`
+	}
+	if err := getSpotifyJSON(ctx, base+"/v1/tracks/"+url.PathEscape(id), token, &track); err != nil {
+		return nil, fmt.Errorf("spotify track %s: %w", id, err)
+	}
+	ids := make([]string, 0, len(track.Artists))
+	for _, artist := range track.Artists {
+		if artistID := strings.TrimSpace(artist.ID); artistID != "" {
+			ids = append(ids, artistID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var artists struct {
+		Artists []struct {
+			Genres []string `json:"genres"`
+		} `json:"artists"`
+	}
+	if err := getSpotifyJSON(ctx, base+"/v1/artists?ids="+url.QueryEscape(strings.Join(ids, ",")), token, &artists); err != nil {
+		return nil, fmt.Errorf("spotify artists of %s: %w", id, err)
+	}
+	genres := []string{}
+	for _, artist := range artists.Artists {
+		genres = append(genres, artist.Genres...)
+	}
+	return genres, nil
+}
+
+// fetchDeezerTrackGenres returns the genres of a Deezer track's album, which
+// is where Deezer keeps them.
+func fetchDeezerTrackGenres(ctx context.Context, trackID string) ([]string, error) {
+	base := strings.TrimSuffix(deezerAPIBaseURL, "/")
+	var track struct {
+		Album struct {
+			ID int64 `json:"id"`
+		} `json:"album"`
+		Error *deezerAPIError `json:"error"`
+	}
+	if err := getDeezerJSON(ctx, base+"/track/"+url.PathEscape(trackID), &track); err != nil {
+		return nil, fmt.Errorf("deezer track %s: %w", trackID, err)
+	}
+	if track.Error != nil {
+		return nil, fmt.Errorf("deezer track %s: %s", trackID, track.Error.describe())
+	}
+	if track.Album.ID == 0 {
+		return nil, nil
+	}
+	var album struct {
+		Genres struct {
+			Data []struct {
+				Name string `json:"name"`
+			} `json:"data"`
+		} `json:"genres"`
+		Error *deezerAPIError `json:"error"`
+	}
+	if err := getDeezerJSON(ctx, fmt.Sprintf("%s/album/%d", base, track.Album.ID), &album); err != nil {
+		return nil, fmt.Errorf("deezer album %d: %w", track.Album.ID, err)
+	}
+	if album.Error != nil {
+		return nil, fmt.Errorf("deezer album %d: %s", track.Album.ID, album.Error.describe())
+	}
+	genres := make([]string, 0, len(album.Genres.Data))
+	for _, genre := range album.Genres.Data {
+		genres = append(genres, genre.Name)
+	}
+	return genres, nil
+}
+
+// routeDeemixTrack resolves the directory a deemix track is downloaded into
+// from the genres lookup returns. A failed lookup is a warning and leaves
+// the track in targetDir.
+func (s *Syncer) routeDeemixTrack(
+	ctx context.Context,
+	source config.Source,
+	targetDir string,
+	trackID string,
+	lookup func(ctx context.Context) ([]string, error),
+) string {
+	if len(source.Sync.GenreRouting) == 0 || strings.TrimSpace(trackID) == "" {
+		return targetDir
+	}
+	genres, err := lookup(ctx)
+	if err != nil {
+		s.emitGenreRoutingLine(source.ID, output.LevelWarn, fmt.Sprintf("genre lookup failed for %s: %v; keeping it in target_dir", trackID, err))
+		return targetDir
+	}
+	return routedTargetDir(targetDir, source.Sync.GenreRouting, genres...)
+}
+
+// applyGenreRouting files the media files scdl or yt-dlp added to target_dir
+// since before was taken under their sync.genre_routing folder. Those tools
+// only learn a track's genre while downloading it, so the files are moved
+// afterwards by their embedded genre tag, and SoundCloud state entries are
+// rewritten to the new paths. deemix sources and SoundCloud free downloads
+// are routed before the download and are left alone here.
+func (s *Syncer) applyGenreRouting(ctx context.Context, cfg config.Config, source config.Source, before map[string]mediaFileSnapshot) {
+	if before == nil || len(source.Sync.GenreRouting) == 0 || source.Adapter.Kind == "deemix" {
+		return
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return
+	}
+	after, err := snapshotMediaFiles(targetDir)
+	if err != nil {
+		s.emitGenreRoutingLine(source.ID, output.LevelWarn, fmt.Sprintf("skipped: unable to scan target_dir: %v", err))
+		return
+	}
+	changed := changedMediaFiles(before, after)
+	if len(changed) == 0 {
+		return
+	}
+
+	moved := map[string]string{}
+	for _, rel := range changed {
+		if ctx.Err() != nil {
+			break
+		}
+		if withinGenreFolder(source.Sync.GenreRouting, rel) {
+			continue
+		}
+		path := filepath.Join(targetDir, filepath.FromSlash(rel))
+		track, err := probeTaggedTrackFn(ctx, path)
+		if err != nil {
+			s.emitGenreRoutingLine(source.ID, output.LevelWarn, fmt.Sprintf("%s: read tags: %v", rel, err))
+			continue
+		}
+		folder := genreRouteFolder(source.Sync.GenreRouting, track.Tags["genre"])
+		if folder == "" {
+			continue
+		}
+		destDir := filepath.Join(targetDir, filepath.FromSlash(folder), filepath.Dir(filepath.FromSlash(rel)))
+		dest, err := moveDownloadedMediaToTargetFn(path, destDir)
+		if err != nil {
+			s.emitGenreRoutingLine(source.ID, output.LevelWarn, fmt.Sprintf("%s: move to %s: %v", rel, folder, err))
+			continue
+		}
+		moved[rel] = normalizeSoundCloudStatePath(targetDir, dest)
+	}
+	if len(moved) > 0 && source.Type == config.SourceTypeSoundCloud {
+		if err := rewriteSoundCloudStatePaths(cfg, source, targetDir, moved); err != nil {
+			s.emitGenreRoutingLine(source.ID, output.LevelWarn, fmt.Sprintf("unable to update state file paths: %v", err))
+		}
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] [route] moved %d/%d new file(s) into genre folders", source.ID, len(moved), len(changed)),
+		Details: map[string]any{
+			"moved_count":   len(moved),
+			"changed_count": len(changed),
+		},
+	})
+}
+
+// withinGenreFolder reports whether rel, relative to target_dir, already
+// sits in one of the routing folders.
+func withinGenreFolder(routes []config.GenreRoute, rel string) bool {
+	for _, route := range routes {
+		folder := strings.Trim(filepath.ToSlash(filepath.Clean(route.Folder)), "/")
+		if strings.HasPrefix(strings.ToLower(rel), strings.ToLower(folder)+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// rewriteSoundCloudStatePaths points the state entries of moved files, keyed
+// by their old target_dir-relative path, at where they were moved to.
+// Absolute entries stay absolute.
+func rewriteSoundCloudStatePaths(cfg config.Config, source config.Source, targetDir string, moved map[string]string) error {
+	statePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(statePath); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	state, err := parseSoundCloudSyncState(statePath)
+	if err != nil {
+		return err
+	}
+	lines := make([]string, 0, len(state.Entries))
+	rewritten := 0
+	for _, entry := range state.Entries {
+		newPath, ok := "", false
+		if entry.ID != "" && strings.TrimSpace(entry.FilePath) != "" {
+			newPath, ok = moved[normalizeSoundCloudStatePath(targetDir, entry.FilePath)]
+		}
+		if !ok {
+			lines = append(lines, entry.RawLine)
+			continue
+		}
+		if filepath.IsAbs(strings.TrimSpace(entry.FilePath)) && !filepath.IsAbs(newPath) {
+			newPath = filepath.Join(targetDir, filepath.FromSlash(newPath))
+		}
+		lines = append(lines, "soundcloud "+entry.ID+" "+newPath)
+		rewritten++
+	}
+	if rewritten == 0 {
+		return nil
+	}
+	return writeSoundCloudLinesAtomically(statePath, ".udl-route-*.tmp", lines)
+}
+
+func (s *Syncer) emitGenreRoutingLine(sourceID string, level output.Level, line string) {
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [route] %s", sourceID, line),
+	})
+}
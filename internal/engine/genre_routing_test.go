@@ -0,0 +1,240 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestGenreRouteFolderMatchesRulesInOrder(t *testing.T) {
+	routes := []config.GenreRoute{
+		{Genres: []string{"drum & bass", "dnb", "jungle*"}, Folder: "Drum & Bass"},
+		{Genres: []string{"*house*"}, Folder: "House"},
+	}
+	cases := map[string]string{
+		"Drum & Bass":          "Drum & Bass",
+		"Jungle Terror":        "Drum & Bass",
+		"Deep House":           "House",
+		"Techno; DnB":          "Drum & Bass",
+		"Ambient":              "",
+		"":                     "",
+		"tech house, uk house": "House",
+	}
+	for genre, want := range cases {
+		if got := genreRouteFolder(routes, genre); got != want {
+			t.Fatalf("genre %q: expected folder %q, got %q", genre, want, got)
+		}
+	}
+	if got := routedTargetDir("/music", routes, "ambient", "french house"); got != filepath.Join("/music", "House") {
+		t.Fatalf("expected any of several genres to match, got %q", got)
+	}
+}
+
+func TestFetchDeezerTrackGenresReadsTheAlbumGenres(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/track/3135556":
+			fmt.Fprint(w, `{"id":3135556,"album":{"id":302127}}`)
+		case "/album/302127":
+			fmt.Fprint(w, `{"id":302127,"genres":{"data":[{"id":113,"name":"Dance"},{"id":106,"name":"Electro"}]}}`)
+		default:
+			fmt.Fprint(w, `{"error":{"type":"DataException","message":"no data","code":800}}`)
+		}
+	}))
+	defer server.Close()
+	origBase := deezerAPIBaseURL
+	t.Cleanup(func() { deezerAPIBaseURL = origBase })
+	deezerAPIBaseURL = server.URL
+
+	genres, err := fetchDeezerTrackGenres(context.Background(), "3135556")
+	if err != nil || strings.Join(genres, ",") != "Dance,Electro" {
+		t.Fatalf("unexpected genres %v (%v)", genres, err)
+	}
+	if _, err := fetchDeezerTrackGenres(context.Background(), "1"); err == nil {
+		t.Fatalf("expected an unknown track to fail")
+	}
+}
+
+func TestApplyGenreRoutingMovesNewFilesAndRewritesSoundCloudState(t *testing.T) {
+	targetDir := t.TempDir()
+	stateDir := t.TempDir()
+	statePath := filepath.Join(stateDir, "sc.sync.scdl")
+	if err := os.WriteFile(filepath.Join(targetDir, "Old.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write old file: %v", err)
+	}
+
+	origProbe := probeTaggedTrackFn
+	t.Cleanup(func() { probeTaggedTrackFn = origProbe })
+	probeTaggedTrackFn = func(ctx context.Context, path string) (taggedTrack, error) {
+		genres := map[string]string{"Roller.mp3": "Drum & Bass", "Deep.flac": "Deep House", "Routed.mp3": "DnB"}
+		return taggedTrack{Tags: map[string]string{"genre": genres[filepath.Base(path)]}}, nil
+	}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+	cfg := config.Config{Defaults: config.Defaults{StateDir: stateDir}}
+	source := config.Source{
+		ID:        "sc",
+		Type:      config.SourceTypeSoundCloud,
+		TargetDir: targetDir,
+		StateFile: "sc.sync.scdl",
+		Sync: config.SyncPolicy{GenreRouting: []config.GenreRoute{
+			{Genres: []string{"drum & bass", "dnb"}, Folder: "Drum & Bass"},
+			{Genres: []string{"*house"}, Folder: "House"},
+		}},
+		Adapter: config.AdapterSpec{Kind: "scdl"},
+	}
+
+	before := syncer.postDownloadSnapshot(source, SyncOptions{})
+	if err := os.MkdirAll(filepath.Join(targetDir, "Drum & Bass"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"Roller.mp3", "Deep.flac", "Ambient.mp3", filepath.Join("Drum & Bass", "Routed.mp3")} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	state := strings.Join([]string{
+		"soundcloud 1 Old.mp3",
+		"soundcloud 2 Roller.mp3",
+		"soundcloud 3 " + filepath.Join(targetDir, "Deep.flac"),
+		"soundcloud 4 Ambient.mp3",
+	}, "\n") + "\n"
+	if err := os.WriteFile(statePath, []byte(state), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	syncer.applyGenreRouting(context.Background(), cfg, source, before)
+	for _, rel := range []string{"Old.mp3", "Ambient.mp3", filepath.Join("Drum & Bass", "Roller.mp3"), filepath.Join("Drum & Bass", "Routed.mp3"), filepath.Join("House", "Deep.flac")} {
+		if _, err := os.Stat(filepath.Join(targetDir, rel)); err != nil {
+			t.Fatalf("expected %s: %v\n%s", rel, err, out.String())
+		}
+	}
+	payload, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	wantState := strings.Join([]string{
+		"soundcloud 1 Old.mp3",
+		"soundcloud 2 Drum & Bass/Roller.mp3",
+		"soundcloud 3 " + filepath.Join(targetDir, "House", "Deep.flac"),
+		"soundcloud 4 Ambient.mp3",
+	}, "\n") + "\n"
+	if string(payload) != wantState {
+		t.Fatalf("unexpected state file:\n%s\nwant:\n%s", payload, wantState)
+	}
+	if !strings.Contains(out.String(), "[sc] [route] moved 2/4 new file(s) into genre folders") {
+		t.Fatalf("expected routing summary, got:\n%s", out.String())
+	}
+}
+
+func TestSyncerSpotifyArtistDeemixRoutesByArtistGenre(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	runtimeDir := filepath.Join(tmp, "runtime")
+	for _, dir := range []string{targetDir, stateDir, runtimeDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:               "artist",
+				Type:             config.SourceTypeSpotify,
+				Enabled:          true,
+				TargetDir:        targetDir,
+				URL:              "https://open.spotify.com/artist/artist0000001",
+				StateFile:        "artist.sync.spotify",
+				DeemixRuntimeDir: runtimeDir,
+				Sync: config.SyncPolicy{GenreRouting: []config.GenreRoute{
+					{Genres: []string{"*drum and bass*", "liquid funk"}, Folder: "Drum & Bass"},
+				}},
+				Adapter: config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	origToken := fetchSpotifyAccessTokenFn
+	origISRC := fetchSpotifyTrackISRCFn
+	origGenres := fetchSpotifyTrackGenresFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+		fetchSpotifyAccessTokenFn = origToken
+		fetchSpotifyTrackISRCFn = origISRC
+		fetchSpotifyTrackGenresFn = origGenres
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		return []spotifyRemoteTrack{
+			{ID: "1abc234def", Title: "track-1", Artist: "artist", Album: "LP"},
+			{ID: "2abc234def", Title: "track-2", Artist: "artist", Album: "Ambient EP"},
+			{ID: "3abc234def", Title: "track-3", Artist: "artist", Album: "Singles"},
+		}, nil
+	}
+	fetchSpotifyAccessTokenFn = func(ctx context.Context, creds auth.SpotifyCredentials) (string, error) { return "token", nil }
+	fetchSpotifyTrackISRCFn = func(ctx context.Context, token string, trackID string) (string, error) { return "", nil }
+	fetchSpotifyTrackGenresFn = func(ctx context.Context, token string, trackID string) ([]string, error) {
+		if token != "token" {
+			t.Errorf("expected the shared spotify token, got %q", token)
+		}
+		switch trackID {
+		case "1abc234def":
+			return []string{"uk drum and bass", "jungle"}, nil
+		case "3abc234def":
+			return nil, fmt.Errorf("status=502")
+		}
+		return []string{"ambient"}, nil
+	}
+
+	runner := &sequenceRunner{}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixPathAdapter{}},
+		runner,
+		output.NewHumanEmitter(&out, &out, false, true),
+	)
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(runner.specs) != 3 {
+		t.Fatalf("expected three track executions, got %d", len(runner.specs))
+	}
+	for i, want := range []string{filepath.Join(targetDir, "Drum & Bass", "LP"), filepath.Join(targetDir, "Ambient EP"), filepath.Join(targetDir, "Singles")} {
+		if got := runner.specs[i].Args[len(runner.specs[i].Args)-1]; got != want {
+			t.Fatalf("expected track %d to target %s, got %s", i+1, want, got)
+		}
+	}
+	if !strings.Contains(out.String(), "[artist] [route] genre lookup failed for 3abc234def: status=502; keeping it in target_dir") {
+		t.Fatalf("expected a lookup warning, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,181 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const historyFileName = "history.jsonl"
+
+// HistoryEntry is one line of <state_dir>/history.jsonl, appended after every
+// non-dry-run sync. RunID matches the run report directory when the report
+// could be written.
+type HistoryEntry struct {
+	RunID      string          `json:"run_id,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	DurationMS int64           `json:"duration_ms"`
+	Result     RunReportResult `json:"result"`
+	Sources    []HistorySource `json:"sources"`
+	Error      string          `json:"error,omitempty"`
+}
+
+type HistorySource struct {
+	SourceID           string   `json:"source_id"`
+	Status             string   `json:"status"`
+	Message            string   `json:"message,omitempty"`
+	Class              string   `json:"class,omitempty"`
+	DurationMS         int64    `json:"duration_ms"`
+	DownloadedTrackIDs []string `json:"downloaded_track_ids,omitempty"`
+}
+
+// sourceActivity tracks when a source's events started and stopped and which
+// track ids it reported as done, for the history journal.
+type sourceActivity struct {
+	first      time.Time
+	last       time.Time
+	downloaded []string
+	seen       map[string]struct{}
+}
+
+func (a *sourceActivity) observe(event output.Event) {
+	if !event.Timestamp.IsZero() {
+		if a.first.IsZero() || event.Timestamp.Before(a.first) {
+			a.first = event.Timestamp
+		}
+		if event.Timestamp.After(a.last) {
+			a.last = event.Timestamp
+		}
+	}
+	trackID := ""
+	switch event.Event {
+	case output.EventTrackDone:
+		trackID, _ = event.Details["track_id"].(string)
+	case output.EventSourcePreflight:
+		line := strings.TrimPrefix(event.Message, fmt.Sprintf("[%s] ", event.SourceID))
+		if rest, ok := strings.CutPrefix(line, "[done] "); ok {
+			if fields := strings.Fields(rest); len(fields) > 0 {
+				trackID = fields[0]
+			}
+		}
+	}
+	trackID = strings.TrimSpace(trackID)
+	if trackID == "" {
+		return
+	}
+	if a.seen == nil {
+		a.seen = map[string]struct{}{}
+	}
+	if _, ok := a.seen[trackID]; ok {
+		return
+	}
+	a.seen[trackID] = struct{}{}
+	a.downloaded = append(a.downloaded, trackID)
+}
+
+func (a *sourceActivity) duration() time.Duration {
+	if a == nil || a.first.IsZero() {
+		return 0
+	}
+	return a.last.Sub(a.first)
+}
+
+// LoadHistory returns journaled runs newest first. A non-empty sourceID keeps
+// only runs that touched that source, trimmed to its outcome; limit <= 0
+// returns every run. Lines that fail to parse are skipped.
+func LoadHistory(stateDir string, sourceID string, limit int) ([]HistoryEntry, error) {
+	path, err := historyPath(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []HistoryEntry{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	sourceID = strings.TrimSpace(sourceID)
+	entries := []HistoryEntry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var entry HistoryEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			continue
+		}
+		if sourceID != "" {
+			kept := []HistorySource{}
+			for _, source := range entry.Sources {
+				if source.SourceID == sourceID {
+					kept = append(kept, source)
+				}
+			}
+			if len(kept) == 0 {
+				continue
+			}
+			entry.Sources = kept
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func appendHistoryEntry(stateDir string, entry HistoryEntry) error {
+	path, err := historyPath(stateDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(payload, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func historyPath(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, historyFileName), nil
+}
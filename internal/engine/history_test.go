@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSyncAppendsHistoryJournal(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	runtimeDir := filepath.Join(tmp, "runtime")
+	for _, dir := range []string{targetDir, stateDir, runtimeDir, filepath.Join(tmp, "yt")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:               "artist",
+				Type:             config.SourceTypeSpotify,
+				Enabled:          true,
+				TargetDir:        targetDir,
+				URL:              "https://open.spotify.com/artist/artist0000001",
+				StateFile:        "artist.sync.spotify",
+				DeemixRuntimeDir: runtimeDir,
+				Adapter:          config.AdapterSpec{Kind: "deemix"},
+			},
+			{
+				ID:        "yt",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   true,
+				TargetDir: filepath.Join(tmp, "yt"),
+				URL:       "https://www.youtube.com/playlist?list=PL123",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+			},
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		return []spotifyRemoteTrack{
+			{ID: "1abc234def", Title: "track-1", Artist: "artist", Album: "LP"},
+			{ID: "2abc234def", Title: "track-2", Artist: "artist", Album: "LP"},
+		}, nil
+	}
+
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixPathAdapter{}, "ytdlp": fakeAdapter{}},
+		&sequenceRunner{},
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+	now := time.Date(2026, 4, 2, 10, 30, 0, 0, time.UTC)
+	syncer.Now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	for run := 0; run < 2; run++ {
+		if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil {
+			t.Fatalf("sync %d: %v", run+1, err)
+		}
+	}
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{DryRun: true}); err != nil {
+		t.Fatalf("dry-run sync: %v", err)
+	}
+
+	entries, err := LoadHistory(stateDir, "", 0)
+	if err != nil {
+		t.Fatalf("load history: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected two journaled runs, got %+v", entries)
+	}
+	first := entries[1]
+	if !entries[0].StartedAt.After(first.StartedAt) || first.RunID == "" || first.DurationMS <= 0 {
+		t.Fatalf("expected newest run first with run ids and durations, got %+v", entries)
+	}
+	if len(first.Sources) != 2 || first.Sources[0].SourceID != "artist" || first.Sources[0].Status != "finished" {
+		t.Fatalf("unexpected first run sources: %+v", first.Sources)
+	}
+	if got := strings.Join(first.Sources[0].DownloadedTrackIDs, ","); got != "1abc234def,2abc234def" {
+		t.Fatalf("expected downloaded track ids, got %q", got)
+	}
+	if first.Sources[0].DurationMS <= 0 {
+		t.Fatalf("expected per-source duration, got %+v", first.Sources[0])
+	}
+
+	filtered, err := LoadHistory(stateDir, "yt", 1)
+	if err != nil {
+		t.Fatalf("load filtered history: %v", err)
+	}
+	if len(filtered) != 1 || len(filtered[0].Sources) != 1 || filtered[0].Sources[0].SourceID != "yt" || filtered[0].RunID != entries[0].RunID {
+		t.Fatalf("unexpected filtered history: %+v", filtered)
+	}
+}
@@ -0,0 +1,24 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const httpCacheDirName = "http-cache"
+
+// HTTPCacheDir returns <state_dir>/http-cache, where sync keeps ETag /
+// Last-Modified validated copies of SoundCloud and Spotify enumeration
+// responses.
+func HTTPCacheDir(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, httpCacheDirName), nil
+}
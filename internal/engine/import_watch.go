@@ -0,0 +1,402 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// Import outcomes, as reported in import_watch event details.
+const (
+	ImportOutcomeImported  = "imported"
+	ImportOutcomePlanned   = "planned"
+	ImportOutcomeUnmatched = "unmatched"
+	ImportOutcomeAmbiguous = "ambiguous"
+	ImportOutcomeFailed    = "failed"
+	// ImportOutcomeLocked leaves a file in place while another udl process
+	// syncs its source; the watch retries it.
+	ImportOutcomeLocked = "locked"
+)
+
+// ImportCandidate is a track a SoundCloud source knows from its metadata
+// cache but has no state entry for yet, which a file in the downloads
+// directory can be imported as.
+type ImportCandidate struct {
+	SourceID    string
+	TrackID     string
+	TargetDir   string
+	StatePath   string
+	ArchivePath string
+	Metadata    soundCloudFreeDownloadMetadata
+}
+
+// ImportWatchOptions configures ImportWatcher.Run.
+type ImportWatchOptions struct {
+	// Dir is the directory watched; empty uses the browser downloads
+	// directory the free-DL flow watches.
+	Dir       string
+	SourceIDs []string
+	// Once imports the media files already in Dir and returns instead of
+	// watching for new ones.
+	Once   bool
+	DryRun bool
+}
+
+// ImportWatchSummary counts what one ImportWatcher.Run handled.
+type ImportWatchSummary struct {
+	Imported  int `json:"imported"`
+	Planned   int `json:"planned"`
+	Unmatched int `json:"unmatched"`
+	Ambiguous int `json:"ambiguous"`
+	Failed    int `json:"failed"`
+	Locked    int `json:"locked"`
+}
+
+// ImportWatcher moves media files that show up in the downloads directory
+// into the target_dir of the SoundCloud source they belong to, tagged and
+// recorded in state as if sync had downloaded them. It covers free-DL gates
+// completed by hand and tracks bought elsewhere alike.
+type ImportWatcher struct {
+	Config  config.Config
+	Emitter output.EventEmitter
+	Now     func() time.Time
+}
+
+// LoadImportCandidates lists the tracks of the selected SoundCloud sources
+// that the metadata cache knows and neither the state file nor the archive
+// records. Sources sync has not enumerated yet contribute none.
+func LoadImportCandidates(cfg config.Config, sourceIDs []string) ([]ImportCandidate, error) {
+	selected, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	candidates := []ImportCandidate{}
+	for _, source := range selected {
+		if !source.Enabled || source.Type != config.SourceTypeSoundCloud {
+			continue
+		}
+		cache, cacheErr := readSoundCloudMetadataCacheFile(cfg.Defaults.StateDir, source.ID)
+		if cacheErr != nil {
+			continue
+		}
+		targetDir, err := config.ExpandTargetDir(source.TargetDir)
+		if err != nil {
+			return nil, err
+		}
+		statePath, err := stateFileForVerify(cfg.Defaults, source)
+		if err != nil {
+			return nil, err
+		}
+		archivePath, err := resolveSoundCloudArchivePath(source, cfg.Defaults.ForSource(source))
+		if err != nil {
+			return nil, err
+		}
+		state := soundCloudSyncState{ByID: map[string]soundCloudSyncEntry{}}
+		if statePath != "" {
+			if state, err = parseSoundCloudSyncState(statePath); err != nil {
+				return nil, err
+			}
+		}
+		archived, err := parseSoundCloudArchive(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		for trackID, entry := range cache.Tracks {
+			if _, ok := state.ByID[trackID]; ok {
+				continue
+			}
+			if _, ok := archived[trackID]; ok {
+				continue
+			}
+			if strings.TrimSpace(entry.Title) == "" {
+				continue
+			}
+			candidates = append(candidates, ImportCandidate{
+				SourceID:    source.ID,
+				TrackID:     trackID,
+				TargetDir:   targetDir,
+				StatePath:   statePath,
+				ArchivePath: archivePath,
+				Metadata:    entry.metadata(soundCloudRemoteTrack{ID: trackID}),
+			})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].SourceID != candidates[j].SourceID {
+			return candidates[i].SourceID < candidates[j].SourceID
+		}
+		return candidates[i].TrackID < candidates[j].TrackID
+	})
+	return candidates, nil
+}
+
+// MatchImportCandidate picks the candidate a downloaded file's name belongs
+// to. The name must contain the track's title; the artist breaks ties. A file
+// matching several candidates equally is ambiguous and matches none.
+func MatchImportCandidate(rel string, candidates []ImportCandidate) (ImportCandidate, bool, bool) {
+	best, bestScore, ties := -1, 0, 0
+	for i, candidate := range candidates {
+		score := browserDownloadMatchScore(rel, candidate.Metadata)
+		if score < 2 {
+			continue
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore, ties = i, score, 1
+		case score == bestScore:
+			ties++
+		}
+	}
+	if best < 0 || ties > 1 {
+		return ImportCandidate{}, false, ties > 1
+	}
+	return candidates[best], true, false
+}
+
+// Run imports files from the downloads directory until ctx is done, or
+// once when opts.Once is set. Files already there when a watch starts are
+// left alone; a watch only imports files that appear or change after it,
+// once they stop changing for freedl.stable_samples polls.
+func (w *ImportWatcher) Run(ctx context.Context, opts ImportWatchOptions) (ImportWatchSummary, error) {
+	summary := ImportWatchSummary{}
+	if _, err := LoadImportCandidates(w.Config, opts.SourceIDs); err != nil {
+		return summary, err
+	}
+	dir := strings.TrimSpace(opts.Dir)
+	if dir == "" {
+		resolved, err := browserDownloadsDirFn()
+		if err != nil {
+			return summary, err
+		}
+		dir = resolved
+	}
+	wait := resolveBrowserDownloadWaitSettings(w.Config.FreeDL, 0)
+	pollInterval := wait.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = browserDownloadPollInterval
+	}
+	requiredStableSamples := wait.StableSamples
+	if requiredStableSamples <= 0 {
+		requiredStableSamples = browserDownloadStableSamples
+	}
+
+	handled := map[string]mediaFileSnapshot{}
+	if !opts.Once {
+		before, err := snapshotMediaFiles(dir)
+		if err != nil {
+			return summary, err
+		}
+		handled = before
+	}
+	verb := "watching"
+	if opts.Once {
+		verb = "scanning"
+	}
+	w.emit(output.LevelInfo, fmt.Sprintf("[import] %s %s for %d source(s)", verb, dir, w.countSources(opts.SourceIDs)), map[string]any{"dir": dir})
+
+	type sample struct {
+		Snapshot mediaFileSnapshot
+		Stable   int
+	}
+	samples := map[string]sample{}
+	for {
+		after, err := snapshotMediaFiles(dir)
+		if err != nil {
+			return summary, err
+		}
+		stable := []string{}
+		for rel, current := range after {
+			if previous, ok := handled[rel]; ok && current.Size == previous.Size && !current.ModTime.After(previous.ModTime) {
+				continue
+			}
+			last, seen := samples[rel]
+			if opts.Once || (seen && current.Size == last.Snapshot.Size && !current.ModTime.After(last.Snapshot.ModTime)) {
+				last.Stable++
+			} else {
+				last.Stable = 1
+			}
+			last.Snapshot = current
+			samples[rel] = last
+			if opts.Once || last.Stable >= requiredStableSamples {
+				stable = append(stable, rel)
+			}
+		}
+		sort.Strings(stable)
+		if len(stable) > 0 {
+			candidates, err := LoadImportCandidates(w.Config, opts.SourceIDs)
+			if err != nil {
+				return summary, err
+			}
+			for _, rel := range stable {
+				handled[rel] = after[rel]
+				delete(samples, rel)
+				outcome := w.importFile(ctx, filepath.Join(dir, filepath.FromSlash(rel)), rel, candidates, opts.DryRun)
+				switch outcome {
+				case ImportOutcomeImported:
+					summary.Imported++
+					// The track is recorded now; reload so a second copy of
+					// it does not match again.
+					if candidates, err = LoadImportCandidates(w.Config, opts.SourceIDs); err != nil {
+						return summary, err
+					}
+				case ImportOutcomePlanned:
+					summary.Planned++
+				case ImportOutcomeUnmatched:
+					summary.Unmatched++
+				case ImportOutcomeAmbiguous:
+					summary.Ambiguous++
+				case ImportOutcomeLocked:
+					summary.Locked++
+					delete(handled, rel)
+				default:
+					summary.Failed++
+				}
+			}
+		}
+		if opts.Once {
+			return summary, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return summary, nil
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// importFile moves one stable download into its source's target_dir, tags
+// it, and records it in the state file and archive.
+func (w *ImportWatcher) importFile(ctx context.Context, path string, rel string, candidates []ImportCandidate, dryRun bool) string {
+	candidate, ok, ambiguous := MatchImportCandidate(rel, candidates)
+	details := map[string]any{"path": path}
+	if !ok {
+		if ambiguous {
+			details["outcome"] = ImportOutcomeAmbiguous
+			w.emit(output.LevelWarn, fmt.Sprintf("[import] [skip] %s matches several tracks; leaving it in place", rel), details)
+			return ImportOutcomeAmbiguous
+		}
+		details["outcome"] = ImportOutcomeUnmatched
+		w.emit(output.LevelInfo, fmt.Sprintf("[import] [skip] %s matches no missing track", rel), details)
+		return ImportOutcomeUnmatched
+	}
+	label := soundCloudTrackDisplayName(candidate.Metadata)
+	details["source_id"] = candidate.SourceID
+	details["track_id"] = candidate.TrackID
+	details["target_dir"] = candidate.TargetDir
+	if dryRun {
+		details["outcome"] = ImportOutcomePlanned
+		w.emitSource(candidate.SourceID, output.LevelInfo, fmt.Sprintf("[%s] [plan] import %s as %s (%s) into %s", candidate.SourceID, rel, candidate.TrackID, label, candidate.TargetDir), details)
+		return ImportOutcomePlanned
+	}
+
+	fail := func(stage string, err error) string {
+		details["outcome"] = ImportOutcomeFailed
+		details["stage"] = stage
+		w.emitSource(candidate.SourceID, output.LevelError, fmt.Sprintf("[%s] [import] %s failed for %s: %v", candidate.SourceID, stage, rel, err), details)
+		return ImportOutcomeFailed
+	}
+	unlock, err := lockSource(ctx, w.Config.Defaults.StateDir, candidate.SourceID, false, nil)
+	if err != nil {
+		var held *SourceLockError
+		if !errors.As(err, &held) {
+			return fail("lock", err)
+		}
+		details["outcome"] = ImportOutcomeLocked
+		w.emitSource(candidate.SourceID, output.LevelWarn, fmt.Sprintf("[%s] [import] [skip] %s: %s is syncing this source; leaving it for later", candidate.SourceID, rel, held.holder()), details)
+		return ImportOutcomeLocked
+	}
+	defer unlock()
+	// A sync that held the lock may have downloaded the track meanwhile.
+	if archived, archiveErr := parseSoundCloudArchive(candidate.ArchivePath); archiveErr == nil {
+		if _, done := archived[candidate.TrackID]; done {
+			details["outcome"] = ImportOutcomeUnmatched
+			w.emitSource(candidate.SourceID, output.LevelInfo, fmt.Sprintf("[%s] [import] [skip] %s: %s is already recorded", candidate.SourceID, rel, candidate.TrackID), details)
+			return ImportOutcomeUnmatched
+		}
+	}
+	importedPath, err := moveDownloadedMediaToTargetFn(path, candidate.TargetDir)
+	if err != nil {
+		return fail("move", err)
+	}
+	details["path"] = importedPath
+	if tagErr := applySoundCloudTrackMetadataFn(ctx, importedPath, candidate.Metadata); tagErr != nil {
+		w.emitSource(candidate.SourceID, output.LevelWarn, fmt.Sprintf("[%s] metadata tagging warning for %s: %v", candidate.SourceID, candidate.TrackID, tagErr), nil)
+	}
+	if candidate.StatePath != "" {
+		statePath := normalizeSoundCloudStatePath(candidate.TargetDir, importedPath)
+		if err := appendSoundCloudSyncStateEntry(candidate.StatePath, candidate.TrackID, statePath); err != nil {
+			return fail("state-update", err)
+		}
+		if err := w.recordProvenance(candidate, statePath); err != nil {
+			w.emitSource(candidate.SourceID, output.LevelWarn, fmt.Sprintf("[%s] unable to record import provenance for %s: %v", candidate.SourceID, candidate.TrackID, err), nil)
+		}
+	}
+	if err := appendSoundCloudArchiveID(candidate.ArchivePath, candidate.TrackID); err != nil {
+		return fail("state-update", err)
+	}
+	details["outcome"] = ImportOutcomeImported
+	w.emitSource(candidate.SourceID, output.LevelInfo, fmt.Sprintf("[%s] [done] %s (%s) imported from %s", candidate.SourceID, candidate.TrackID, label, rel), details)
+	return ImportOutcomeImported
+}
+
+func (w *ImportWatcher) recordProvenance(candidate ImportCandidate, localPath string) error {
+	for _, source := range w.Config.Sources {
+		if source.ID != candidate.SourceID {
+			continue
+		}
+		return appendSoundCloudProvenance(w.Config.Defaults.StateDir, source, soundCloudProvenanceRecord{
+			TrackID:  candidate.TrackID,
+			AddedAt:  w.now().UTC(),
+			Provider: soundCloudProvenanceImport,
+			Path:     localPath,
+		})
+	}
+	return nil
+}
+
+func (w *ImportWatcher) countSources(sourceIDs []string) int {
+	selected, err := selectSources(w.Config.Sources, sourceIDs)
+	if err != nil {
+		return 0
+	}
+	count := 0
+	for _, source := range selected {
+		if source.Enabled && source.Type == config.SourceTypeSoundCloud {
+			count++
+		}
+	}
+	return count
+}
+
+func (w *ImportWatcher) emit(level output.Level, message string, details map[string]any) {
+	w.emitSource("", level, message, details)
+}
+
+func (w *ImportWatcher) now() time.Time {
+	if w.Now != nil {
+		return w.Now()
+	}
+	return time.Now()
+}
+
+func (w *ImportWatcher) emitSource(sourceID string, level output.Level, message string, details map[string]any) {
+	if w.Emitter == nil {
+		return
+	}
+	_ = w.Emitter.Emit(output.Event{
+		Timestamp: w.now(),
+		Level:     level,
+		Event:     output.EventImportWatch,
+		SourceID:  sourceID,
+		Message:   message,
+		Details:   details,
+	})
+}
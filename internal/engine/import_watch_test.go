@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func writeImportWatchFixture(t *testing.T) (config.Config, string, string) {
+	t.Helper()
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "target")
+	downloadsDir := filepath.Join(tmp, "downloads")
+	for _, dir := range []string{stateDir, targetDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version:  1,
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sc-likes",
+			Type:      config.SourceTypeSoundCloud,
+			Enabled:   true,
+			TargetDir: targetDir,
+			StateFile: "sc-likes.sync.scdl",
+		}},
+	}
+	fetchedAt := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	err := writeSoundCloudMetadataCacheFile(stateDir, "sc-likes", &soundCloudMetadataCacheFile{
+		Schema:   soundCloudMetadataCacheSchema,
+		SourceID: "sc-likes",
+		Tracks: map[string]soundCloudMetadataCacheEntry{
+			"111": {Title: "Night Drive", Artist: "Pichi", FetchedAt: fetchedAt},
+			"222": {Title: "Sunrise", Artist: "Pichi", FetchedAt: fetchedAt},
+			"333": {Title: "Already Here", Artist: "Pichi", FetchedAt: fetchedAt},
+		},
+	})
+	if err != nil {
+		t.Fatalf("write metadata cache: %v", err)
+	}
+	if err := appendSoundCloudSyncStateEntry(filepath.Join(stateDir, "sc-likes.sync.scdl"), "333", "Already Here.mp3"); err != nil {
+		t.Fatalf("seed state: %v", err)
+	}
+	return cfg, downloadsDir, targetDir
+}
+
+func TestLoadImportCandidatesSkipsTracksInState(t *testing.T) {
+	cfg, _, _ := writeImportWatchFixture(t)
+	candidates, err := LoadImportCandidates(cfg, nil)
+	if err != nil {
+		t.Fatalf("load candidates: %v", err)
+	}
+	if len(candidates) != 2 || candidates[0].TrackID != "111" || candidates[1].TrackID != "222" {
+		t.Fatalf("unexpected candidates: %+v", candidates)
+	}
+	if _, err := LoadImportCandidates(cfg, []string{"missing"}); err == nil {
+		t.Fatalf("expected unknown source selection to fail")
+	}
+
+	if match, ok, _ := MatchImportCandidate("Pichi - Night Drive (Original Mix).wav", candidates); !ok || match.TrackID != "111" {
+		t.Fatalf("expected title match for 111, got %+v ok=%v", match, ok)
+	}
+	if _, ok, ambiguous := MatchImportCandidate("Pichi - Live Set.mp3", candidates); ok || ambiguous {
+		t.Fatalf("expected artist-only name to match nothing, got ok=%v ambiguous=%v", ok, ambiguous)
+	}
+}
+
+func TestLoadImportCandidatesUsesThePerSourceArchiveFile(t *testing.T) {
+	cfg, _, _ := writeImportWatchFixture(t)
+	cfg.Sources[0].Defaults.ArchiveFile = "likes-archive.txt"
+	if err := os.WriteFile(filepath.Join(cfg.Defaults.StateDir, "sc-likes.likes-archive.txt"), []byte("soundcloud 111\n"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	candidates, err := LoadImportCandidates(cfg, nil)
+	if err != nil {
+		t.Fatalf("load candidates: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].TrackID != "222" {
+		t.Fatalf("expected the per-source archive to rule out 111, got %+v", candidates)
+	}
+}
+
+func TestImportWatcherOnceImportsMatchedFiles(t *testing.T) {
+	cfg, downloadsDir, targetDir := writeImportWatchFixture(t)
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	t.Cleanup(func() { applySoundCloudTrackMetadataFn = origApplyMetadata })
+	tagged := map[string]string{}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		tagged[filepath.Base(filePath)] = metadata.Title
+		return nil
+	}
+	for _, name := range []string{"Pichi - Night Drive.wav", "holiday photo mix.mp3"} {
+		if err := os.WriteFile(filepath.Join(downloadsDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	watcher := ImportWatcher{Config: cfg}
+	planned, err := watcher.Run(context.Background(), ImportWatchOptions{Dir: downloadsDir, Once: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("dry-run import: %v", err)
+	}
+	if planned.Planned != 1 || planned.Unmatched != 1 || planned.Imported != 0 {
+		t.Fatalf("unexpected dry-run summary: %+v", planned)
+	}
+	if _, err := os.Stat(filepath.Join(downloadsDir, "Pichi - Night Drive.wav")); err != nil {
+		t.Fatalf("expected dry run to leave the file in place: %v", err)
+	}
+
+	summary, err := watcher.Run(context.Background(), ImportWatchOptions{Dir: downloadsDir, Once: true})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Imported != 1 || summary.Unmatched != 1 || summary.Failed != 0 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Pichi - Night Drive.wav")); err != nil {
+		t.Fatalf("expected file moved into target_dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(downloadsDir, "holiday photo mix.mp3")); err != nil {
+		t.Fatalf("expected unmatched file left in place: %v", err)
+	}
+	if tagged["Pichi - Night Drive.wav"] != "Night Drive" {
+		t.Fatalf("expected imported file tagged, got %+v", tagged)
+	}
+
+	state, err := parseSoundCloudSyncState(filepath.Join(cfg.Defaults.StateDir, "sc-likes.sync.scdl"))
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if entry, ok := state.ByID["111"]; !ok || !strings.HasSuffix(entry.FilePath, "Pichi - Night Drive.wav") {
+		t.Fatalf("expected 111 recorded in state, got %+v", state.ByID)
+	}
+	archivePath, err := config.ResolveArchiveFile(cfg.Defaults.StateDir, "archive.txt", "sc-likes")
+	if err != nil {
+		t.Fatalf("resolve archive: %v", err)
+	}
+	archived, err := parseSoundCloudArchive(archivePath)
+	if err != nil {
+		t.Fatalf("parse archive: %v", err)
+	}
+	if _, ok := archived["111"]; !ok {
+		t.Fatalf("expected 111 in archive, got %+v", archived)
+	}
+	candidates, err := LoadImportCandidates(cfg, nil)
+	if err != nil || len(candidates) != 1 || candidates[0].TrackID != "222" {
+		t.Fatalf("expected only 222 left to import, got %+v (%v)", candidates, err)
+	}
+}
+
+func TestImportWatcherIgnoresFilesPresentAtStart(t *testing.T) {
+	cfg, downloadsDir, _ := writeImportWatchFixture(t)
+	cfg.FreeDL = config.FreeDL{PollIntervalMS: 5, StableSamples: 2}
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	t.Cleanup(func() { applySoundCloudTrackMetadataFn = origApplyMetadata })
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
+	if err := os.WriteFile(filepath.Join(downloadsDir, "Pichi - Night Drive.wav"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write existing download: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = os.WriteFile(filepath.Join(downloadsDir, "Pichi - Sunrise.mp3"), []byte("audio"), 0o644)
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	summary, err := (&ImportWatcher{Config: cfg}).Run(ctx, ImportWatchOptions{Dir: downloadsDir})
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if summary.Imported != 1 {
+		t.Fatalf("expected only the new download imported, got %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(downloadsDir, "Pichi - Night Drive.wav")); err != nil {
+		t.Fatalf("expected file present at start left in place: %v", err)
+	}
+}
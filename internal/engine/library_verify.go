@@ -0,0 +1,332 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	VerifyIssueMissing = "missing"
+	VerifyIssueOrphan  = "orphan"
+	VerifyIssueEmpty   = "empty"
+	VerifyIssueCorrupt = "corrupt"
+)
+
+// VerifyOptions controls how deeply VerifyLibrary inspects media files.
+type VerifyOptions struct {
+	Probe        bool
+	ProbeTimeout time.Duration
+}
+
+// VerifyIssue is one mismatch between a source's state and its target_dir.
+// Missing issues carry the state file and track id so they can be pruned.
+type VerifyIssue struct {
+	Kind      string `json:"kind"`
+	Path      string `json:"path"`
+	TrackID   string `json:"track_id,omitempty"`
+	StateFile string `json:"state_file,omitempty"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// SourceVerifyReport summarizes the verification of one source.
+// OrphansChecked is false for sources whose state does not record local file
+// paths, since every file would otherwise look untracked.
+type SourceVerifyReport struct {
+	SourceID       string        `json:"source_id"`
+	TargetDir      string        `json:"target_dir"`
+	Tracked        int           `json:"tracked"`
+	LocalFiles     int           `json:"local_files"`
+	OrphansChecked bool          `json:"orphans_checked"`
+	Issues         []VerifyIssue `json:"issues"`
+	Errors         []string      `json:"errors,omitempty"`
+}
+
+var probeMediaFileFn = probeMediaFile
+
+// VerifyLibrary cross-checks state files against the filesystem for the
+// selected sources (all sources when sourceIDs is empty). Files referenced by
+// any configured source's state count as tracked, so overlapping target_dirs
+// do not report each other's files as orphans.
+func VerifyLibrary(ctx context.Context, cfg config.Config, sourceIDs []string, opts VerifyOptions) ([]SourceVerifyReport, error) {
+	sources, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := map[string]struct{}{}
+	for _, source := range cfg.Sources {
+		tracked, _ := collectVerifyTrackedFiles(cfg.Defaults, source)
+		for _, file := range tracked {
+			referenced[file.path] = struct{}{}
+		}
+	}
+
+	reports := make([]SourceVerifyReport, 0, len(sources))
+	for _, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		reports = append(reports, verifySource(ctx, cfg.Defaults, source, referenced, opts))
+	}
+	return reports, nil
+}
+
+// PruneMissingStateEntries removes state entries reported as missing while
+// holding the lock of each source it prunes, and returns how many entries
+// were dropped.
+func PruneMissingStateEntries(ctx context.Context, stateDir string, reports []SourceVerifyReport) (int, error) {
+	byStateFile := map[string]map[string]struct{}{}
+	order := []string{}
+	locked := []string{}
+	for _, report := range reports {
+		for _, issue := range report.Issues {
+			if issue.Kind != VerifyIssueMissing || issue.StateFile == "" || issue.TrackID == "" {
+				continue
+			}
+			locked = append(locked, report.SourceID)
+			ids, ok := byStateFile[issue.StateFile]
+			if !ok {
+				ids = map[string]struct{}{}
+				byStateFile[issue.StateFile] = ids
+				order = append(order, issue.StateFile)
+			}
+			ids[issue.TrackID] = struct{}{}
+		}
+	}
+
+	unlock, err := lockSources(ctx, stateDir, locked)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	pruned := 0
+	for _, statePath := range order {
+		removeIDs := byStateFile[statePath]
+		lines, err := readSoundCloudArchiveLines(statePath)
+		if err != nil {
+			return pruned, err
+		}
+		kept := make([]string, 0, len(lines))
+		for _, line := range lines {
+			id := verifyStateLineID(line.Raw)
+			if _, remove := removeIDs[id]; id != "" && remove {
+				pruned++
+				continue
+			}
+			kept = append(kept, line.Raw)
+		}
+		if len(kept) == len(lines) {
+			continue
+		}
+		if err := writeSoundCloudLinesAtomically(statePath, ".udl-verify-*.tmp", kept); err != nil {
+			return pruned, err
+		}
+	}
+	return pruned, nil
+}
+
+type verifyTrackedFile struct {
+	id        string
+	path      string
+	stateFile string
+}
+
+func verifySource(
+	ctx context.Context,
+	defaults config.Defaults,
+	source config.Source,
+	referenced map[string]struct{},
+	opts VerifyOptions,
+) SourceVerifyReport {
+	report := SourceVerifyReport{SourceID: source.ID, Issues: []VerifyIssue{}}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		report.Errors = append(report.Errors, "target_dir: "+err.Error())
+		return report
+	}
+	report.TargetDir = targetDir
+
+	tracked, pathsComplete := collectVerifyTrackedFiles(defaults, source)
+	report.Tracked = len(tracked)
+	report.OrphansChecked = pathsComplete
+	for _, file := range tracked {
+		if info, err := os.Stat(file.path); err == nil && !info.IsDir() {
+			continue
+		}
+		report.Issues = append(report.Issues, VerifyIssue{
+			Kind:      VerifyIssueMissing,
+			Path:      file.path,
+			TrackID:   file.id,
+			StateFile: file.stateFile,
+		})
+	}
+	if source.Type == config.SourceTypeSoundCloud || source.Type == config.SourceTypeSpotify || source.Type == config.SourceTypeDeezer || source.Type == config.SourceTypeAppleMusic {
+		if _, err := stateFileForVerify(defaults, source); err != nil {
+			report.Errors = append(report.Errors, "state_file: "+err.Error())
+		}
+	}
+
+	_ = filepath.WalkDir(targetDir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			if path == targetDir && !os.IsNotExist(walkErr) {
+				report.Errors = append(report.Errors, "target_dir: "+walkErr.Error())
+			}
+			return nil
+		}
+		if d.IsDir() || !isMediaExt(strings.ToLower(filepath.Ext(d.Name()))) {
+			return nil
+		}
+		report.LocalFiles++
+		if report.OrphansChecked {
+			if _, ok := referenced[filepath.Clean(path)]; !ok {
+				report.Issues = append(report.Issues, VerifyIssue{Kind: VerifyIssueOrphan, Path: path})
+			}
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.Size() == 0 {
+			report.Issues = append(report.Issues, VerifyIssue{Kind: VerifyIssueEmpty, Path: path})
+			return nil
+		}
+		if !opts.Probe || ctx.Err() != nil {
+			return nil
+		}
+		probeCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.ProbeTimeout > 0 {
+			probeCtx, cancel = context.WithTimeout(ctx, opts.ProbeTimeout)
+		}
+		probeErr := probeMediaFileFn(probeCtx, path)
+		cancel()
+		if probeErr != nil && ctx.Err() == nil {
+			report.Issues = append(report.Issues, VerifyIssue{Kind: VerifyIssueCorrupt, Path: path, Detail: probeErr.Error()})
+		}
+		return nil
+	})
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].Kind != report.Issues[j].Kind {
+			return report.Issues[i].Kind < report.Issues[j].Kind
+		}
+		return report.Issues[i].Path < report.Issues[j].Path
+	})
+	return report
+}
+
+// collectVerifyTrackedFiles returns the absolute local paths recorded in a
+// source's state. The bool reports whether every known track has a path.
+func collectVerifyTrackedFiles(defaults config.Defaults, source config.Source) ([]verifyTrackedFile, bool) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return nil, false
+	}
+	statePath, err := stateFileForVerify(defaults, source)
+	if err != nil || statePath == "" {
+		return nil, false
+	}
+
+	tracked := []verifyTrackedFile{}
+	switch source.Type {
+	case config.SourceTypeSoundCloud:
+		state, err := parseSoundCloudSyncState(statePath)
+		if err != nil {
+			return nil, false
+		}
+		ids := make([]string, 0, len(state.ByID))
+		for id := range state.ByID {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			path := strings.TrimSpace(state.ByID[id].FilePath)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(targetDir, path)
+			}
+			tracked = append(tracked, verifyTrackedFile{id: id, path: filepath.Clean(path), stateFile: statePath})
+		}
+		return tracked, true
+	case config.SourceTypeSpotify, config.SourceTypeDeezer, config.SourceTypeAppleMusic:
+		state, err := parseTrackStateForSource(source.Type, statePath)
+		if err != nil {
+			return nil, false
+		}
+		ids := make([]string, 0, len(state.Entries))
+		for id, entry := range state.Entries {
+			if normalizeSpotifyStatePath(entry.LocalPath) != "" {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			localPath := normalizeSpotifyStatePath(state.Entries[id].LocalPath)
+			tracked = append(tracked, verifyTrackedFile{
+				id:        id,
+				path:      filepath.Join(targetDir, filepath.FromSlash(localPath)),
+				stateFile: statePath,
+			})
+		}
+		return tracked, len(ids) == len(state.KnownIDs)
+	default:
+		return nil, false
+	}
+}
+
+func stateFileForVerify(defaults config.Defaults, source config.Source) (string, error) {
+	if strings.TrimSpace(source.StateFile) == "" {
+		return "", nil
+	}
+	return config.ResolveStateFile(defaults.StateDir, source.StateFile)
+}
+
+// verifyStateLineID extracts the track id from a SoundCloud or Spotify state
+// line; comments and headers yield "".
+func verifyStateLineID(raw string) string {
+	line := strings.TrimSpace(raw)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ""
+	}
+	if id := parseSoundCloudArchiveLineID(line); id != "" {
+		return id
+	}
+	id, _ := parseSpotifyStateLine(line)
+	return id
+}
+
+// probeMediaFile asks ffprobe for the first audio stream; a decode error or a
+// file with no audio stream is treated as corrupt.
+func probeMediaFile(ctx context.Context, path string) error {
+	cmd := exec.CommandContext(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	output, err := cmd.CombinedOutput()
+	trimmed := strings.TrimSpace(string(output))
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("ffprobe timed out")
+		}
+		if trimmed == "" {
+			return err
+		}
+		return fmt.Errorf("%v: %s", err, trimmed)
+	}
+	if trimmed == "" {
+		return fmt.Errorf("no audio stream")
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestVerifyLibraryReportsMissingOrphanEmptyAndCorrupt(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, targetDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	files := map[string]string{"one.mp3": "audio", "stray.flac": "audio", "empty.m4a": "", "broken.mp3": "junk", "cover.jpg": "x"}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte(body), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "sc.sync.scdl")
+	state := "soundcloud 1 one.mp3\nsoundcloud 2 gone.mp3\nsoundcloud 3 empty.m4a\nsoundcloud 4 " + filepath.Join(targetDir, "broken.mp3") + "\n"
+	if err := os.WriteFile(statePath, []byte(state), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	originalProbe := probeMediaFileFn
+	t.Cleanup(func() { probeMediaFileFn = originalProbe })
+	probeMediaFileFn = func(ctx context.Context, path string) error {
+		if filepath.Base(path) == "broken.mp3" {
+			return errors.New("no audio stream")
+		}
+		return nil
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sc",
+			Type:      config.SourceTypeSoundCloud,
+			Enabled:   true,
+			TargetDir: targetDir,
+			StateFile: "sc.sync.scdl",
+			Adapter:   config.AdapterSpec{Kind: "scdl"},
+		}},
+	}
+	reports, err := VerifyLibrary(context.Background(), cfg, nil, VerifyOptions{Probe: true})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	report := reports[0]
+	if report.Tracked != 4 || report.LocalFiles != 4 || !report.OrphansChecked {
+		t.Fatalf("unexpected report counts: %+v", report)
+	}
+	got := []string{}
+	for _, issue := range report.Issues {
+		got = append(got, issue.Kind+":"+filepath.Base(issue.Path))
+	}
+	want := "corrupt:broken.mp3,empty:empty.m4a,missing:gone.mp3,orphan:stray.flac"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("expected issues %s, got %v", want, got)
+	}
+
+	unlock, err := lockSource(context.Background(), stateDir, "sc", false, nil)
+	if err != nil {
+		t.Fatalf("lock source: %v", err)
+	}
+	if _, err := PruneMissingStateEntries(context.Background(), stateDir, reports); !errors.Is(err, ErrSourceLocked) {
+		t.Fatalf("expected prune to refuse a locked source, got %v", err)
+	}
+	unlock()
+
+	pruned, err := PruneMissingStateEntries(context.Background(), stateDir, reports)
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if pruned != 1 {
+		t.Fatalf("expected one pruned entry, got %d", pruned)
+	}
+	updated, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if strings.Contains(string(updated), "gone.mp3") || !strings.Contains(string(updated), "soundcloud 1 one.mp3") {
+		t.Fatalf("unexpected pruned state: %q", string(updated))
+	}
+}
+
+func TestVerifyLibrarySpotifyOrphansRequireRecordedPaths(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, targetDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "a.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+	statePath := filepath.Join(stateDir, "sp.sync.spotify")
+	state := "# udl spotify state v2\n" +
+		"4uLU6hMCjMI75M1A2tKUQC\tpath=a.mp3\n" +
+		"0VjIjW4GlUZAMYd2vXMi3b\n"
+	if err := os.WriteFile(statePath, []byte(state), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sp",
+			Type:      config.SourceTypeSpotify,
+			Enabled:   true,
+			TargetDir: targetDir,
+			StateFile: "sp.sync.spotify",
+			Adapter:   config.AdapterSpec{Kind: "spotdl"},
+		}},
+	}
+	reports, err := VerifyLibrary(context.Background(), cfg, []string{"sp"}, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if reports[0].OrphansChecked || len(reports[0].Issues) != 0 || reports[0].Tracked != 1 {
+		t.Fatalf("expected path-less spotify state to skip orphan checks, got %+v", reports[0])
+	}
+}
@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+var (
+	lrclibBaseURL    = "https://lrclib.net"
+	lrclibHTTPClient = &http.Client{Timeout: 20 * time.Second}
+
+	probeTaggedTrackFn = probeTaggedTrack
+	fetchLyricsFn      = fetchLRCLIBLyrics
+	writeLyricsTagFn   = writeLyricsTag
+)
+
+// errLyricsNotFound marks tracks LRCLIB has no entry for.
+var errLyricsNotFound = errors.New("no lyrics found")
+
+// taggedTrack is what lyrics and MusicBrainz lookups match on, read from a
+// file's tags. Tags holds every container tag with a lower-cased name.
+type taggedTrack struct {
+	Artist          string
+	Title           string
+	Album           string
+	DurationSeconds int
+	// HasLyrics is set when the file already carries a lyrics tag.
+	HasLyrics bool
+	Tags      map[string]string
+}
+
+// trackLyrics is one lookup result. Synced is LRC text with [mm:ss.xx]
+// timestamps; either may be empty.
+type trackLyrics struct {
+	Synced       string
+	Plain        string
+	Instrumental bool
+}
+
+// applyLyrics looks up lyrics for the media files that appeared or changed
+// in target_dir since before was taken and embeds them (sync.lyrics: tags)
+// or writes them next to the file as <name>.lrc (sync.lyrics: lrc). Files
+// that already have lyrics are left alone; a failed lookup is a warning and
+// never fails the source.
+func (s *Syncer) applyLyrics(ctx context.Context, source config.Source, before map[string]mediaFileSnapshot) {
+	if before == nil || source.Sync.Lyrics == "" {
+		return
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return
+	}
+	after, err := snapshotMediaFiles(targetDir)
+	if err != nil {
+		s.emitLyricsLine(source.ID, output.LevelWarn, fmt.Sprintf("skipped: unable to scan target_dir: %v", err))
+		return
+	}
+	changed := changedMediaFiles(before, after)
+	if len(changed) == 0 {
+		return
+	}
+
+	written, notFound := 0, 0
+	for _, rel := range changed {
+		if ctx.Err() != nil {
+			return
+		}
+		err := s.writeTrackLyrics(ctx, source.Sync.Lyrics, filepath.Join(targetDir, filepath.FromSlash(rel)))
+		switch {
+		case errors.Is(err, errLyricsNotFound):
+			notFound++
+		case err != nil:
+			s.emitLyricsLine(source.ID, output.LevelWarn, fmt.Sprintf("%s: %v", rel, err))
+		default:
+			written++
+		}
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] [lyrics] wrote lyrics for %d/%d new file(s) (%d not found)", source.ID, written, len(changed), notFound),
+		Details: map[string]any{
+			"mode":            source.Sync.Lyrics,
+			"written_count":   written,
+			"not_found_count": notFound,
+			"changed_count":   len(changed),
+		},
+	})
+}
+
+// writeTrackLyrics handles one file. Files that already have lyrics, and
+// instrumentals, count as not found so they are neither written nor warned
+// about.
+func (s *Syncer) writeTrackLyrics(ctx context.Context, mode string, path string) error {
+	lrcPath := strings.TrimSuffix(path, filepath.Ext(path)) + ".lrc"
+	if mode == config.LyricsLRC {
+		if _, err := os.Stat(lrcPath); err == nil {
+			return errLyricsNotFound
+		}
+	}
+	track, err := probeTaggedTrackFn(ctx, path)
+	if err != nil {
+		return fmt.Errorf("read tags: %w", err)
+	}
+	if mode == config.LyricsTags && track.HasLyrics {
+		return errLyricsNotFound
+	}
+	if track.Title == "" {
+		return fmt.Errorf("no title tag to look lyrics up by")
+	}
+	lyrics, err := fetchLyricsFn(ctx, track)
+	if err != nil {
+		return err
+	}
+	if lyrics.Instrumental {
+		return errLyricsNotFound
+	}
+	switch mode {
+	case config.LyricsLRC:
+		if lyrics.Synced == "" {
+			return errLyricsNotFound
+		}
+		return writeFileAtomically(lrcPath, ".udl-lrc-*.tmp", []byte(strings.TrimRight(lyrics.Synced, "\n")+"\n"))
+	default:
+		text := lyrics.Synced
+		if text == "" {
+			text = lyrics.Plain
+		}
+		if text == "" {
+			return errLyricsNotFound
+		}
+		return writeLyricsTagFn(ctx, path, text)
+	}
+}
+
+// probeTaggedTrack reads the tags and duration of path. Files without a
+// title tag fall back to an "Artist - Title" file name.
+func probeTaggedTrack(ctx context.Context, path string) (taggedTrack, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_entries", "format=duration:format_tags", "-of", "json", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return taggedTrack{}, err
+	}
+	var payload struct {
+		Format struct {
+			Duration string            `json:"duration"`
+			Tags     map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return taggedTrack{}, err
+	}
+	tags := make(map[string]string, len(payload.Format.Tags))
+	for name, value := range payload.Format.Tags {
+		name = strings.ToLower(name)
+		tags[name] = strings.TrimSpace(value)
+	}
+	track := taggedTrack{Artist: tags["artist"], Title: tags["title"], Album: tags["album"], Tags: tags}
+	for name, value := range tags {
+		if value != "" && (strings.HasPrefix(name, "lyrics") || name == "unsyncedlyrics") {
+			track.HasLyrics = true
+		}
+	}
+	if track.Title == "" {
+		base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if artist, title, ok := strings.Cut(base, " - "); ok {
+			track.Title = strings.TrimSpace(title)
+			if track.Artist == "" {
+				track.Artist = strings.TrimSpace(artist)
+			}
+		}
+	}
+	if seconds, err := strconv.ParseFloat(strings.TrimSpace(payload.Format.Duration), 64); err == nil && seconds > 0 {
+		track.DurationSeconds = int(math.Round(seconds))
+	}
+	return track, nil
+}
+
+type lrclibTrack struct {
+	Instrumental bool   `json:"instrumental"`
+	PlainLyrics  string `json:"plainLyrics"`
+	SyncedLyrics string `json:"syncedLyrics"`
+}
+
+// fetchLRCLIBLyrics asks LRCLIB's /api/get for an exact artist, title,
+// album, and duration (within a couple of seconds) match. LRCLIB is public
+// and needs no key.
+func fetchLRCLIBLyrics(ctx context.Context, track taggedTrack) (trackLyrics, error) {
+	query := url.Values{"artist_name": {track.Artist}, "track_name": {track.Title}}
+	if track.Album != "" {
+		query.Set("album_name", track.Album)
+	}
+	if track.DurationSeconds > 0 {
+		query.Set("duration", strconv.Itoa(track.DurationSeconds))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(lrclibBaseURL, "/")+"/api/get?"+query.Encode(), nil)
+	if err != nil {
+		return trackLyrics{}, err
+	}
+	// LRCLIB asks clients to identify themselves.
+	req.Header.Set("User-Agent", "udl/lyrics")
+	resp, err := lrclibHTTPClient.Do(req)
+	if err != nil {
+		return trackLyrics{}, fmt.Errorf("lrclib: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return trackLyrics{}, errLyricsNotFound
+	case resp.StatusCode != http.StatusOK:
+		return trackLyrics{}, fmt.Errorf("lrclib: HTTP %d", resp.StatusCode)
+	}
+	var payload lrclibTrack
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&payload); err != nil {
+		return trackLyrics{}, fmt.Errorf("lrclib: %w", err)
+	}
+	return trackLyrics{
+		Synced:       strings.TrimSpace(payload.SyncedLyrics),
+		Plain:        strings.TrimSpace(payload.PlainLyrics),
+		Instrumental: payload.Instrumental,
+	}, nil
+}
+
+// writeLyricsTag remuxes path with a lyrics tag added, keeping the streams
+// and the other tags as they are.
+func writeLyricsTag(ctx context.Context, path string, lyrics string) error {
+	return remuxWithTags(ctx, path, []string{"-metadata", "lyrics=" + lyrics})
+}
+
+func (s *Syncer) emitLyricsLine(sourceID string, level output.Level, line string) {
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [lyrics] %s", sourceID, line),
+	})
+}
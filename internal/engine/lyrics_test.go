@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestFetchLRCLIBLyricsMatchesOnTagsAndDuration(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/api/get" || query.Get("artist_name") != "Daft Punk" || query.Get("duration") != "212" {
+			t.Fatalf("unexpected request %s", r.URL.String())
+		}
+		if query.Get("track_name") == "Missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"code":404,"name":"TrackNotFound"}`)
+			return
+		}
+		fmt.Fprint(w, `{"instrumental":false,"plainLyrics":"One more time","syncedLyrics":"[00:01.00] One more time"}`)
+	}))
+	defer server.Close()
+	origURL := lrclibBaseURL
+	t.Cleanup(func() { lrclibBaseURL = origURL })
+	lrclibBaseURL = server.URL
+
+	lyrics, err := fetchLRCLIBLyrics(context.Background(), taggedTrack{Artist: "Daft Punk", Title: "One More Time", DurationSeconds: 212})
+	if err != nil || lyrics.Synced != "[00:01.00] One more time" || lyrics.Plain != "One more time" {
+		t.Fatalf("unexpected lyrics %+v (%v)", lyrics, err)
+	}
+	if _, err := fetchLRCLIBLyrics(context.Background(), taggedTrack{Artist: "Daft Punk", Title: "Missing", DurationSeconds: 212}); err != errLyricsNotFound {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestApplyLyricsWritesTagsOrLRCForFilesAddedByTheRun(t *testing.T) {
+	targetDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(targetDir, "Old.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write old file: %v", err)
+	}
+
+	origProbe := probeTaggedTrackFn
+	origFetch := fetchLyricsFn
+	origWrite := writeLyricsTagFn
+	t.Cleanup(func() {
+		probeTaggedTrackFn = origProbe
+		fetchLyricsFn = origFetch
+		writeLyricsTagFn = origWrite
+	})
+	probeTaggedTrackFn = func(ctx context.Context, path string) (taggedTrack, error) {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		return taggedTrack{Artist: "Artist", Title: name, HasLyrics: name == "Tagged"}, nil
+	}
+	fetchLyricsFn = func(ctx context.Context, track taggedTrack) (trackLyrics, error) {
+		switch track.Title {
+		case "Unknown":
+			return trackLyrics{}, errLyricsNotFound
+		case "Plain":
+			return trackLyrics{Plain: "plain words"}, nil
+		default:
+			return trackLyrics{Synced: "[00:01.00] synced words", Plain: "synced words"}, nil
+		}
+	}
+	tagged := map[string]string{}
+	writeLyricsTagFn = func(ctx context.Context, path string, lyrics string) error {
+		tagged[filepath.Base(path)] = lyrics
+		return nil
+	}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+
+	source := config.Source{ID: "dz", Type: config.SourceTypeDeezer, TargetDir: targetDir, Sync: config.SyncPolicy{Lyrics: config.LyricsTags}}
+	if before := syncer.postDownloadSnapshot(source, SyncOptions{DryRun: true}); before != nil {
+		t.Fatalf("expected no snapshot in dry-run, got %v", before)
+	}
+	before := syncer.postDownloadSnapshot(source, SyncOptions{})
+	for _, name := range []string{"Synced.flac", "Plain.mp3", "Unknown.mp3", "Tagged.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	syncer.applyLyrics(context.Background(), source, before)
+	if len(tagged) != 2 || tagged["Synced.flac"] != "[00:01.00] synced words" || tagged["Plain.mp3"] != "plain words" {
+		t.Fatalf("expected synced lyrics preferred and plain as fallback, got %v", tagged)
+	}
+	if !strings.Contains(out.String(), "[dz] [lyrics] wrote lyrics for 2/4 new file(s) (2 not found)") {
+		t.Fatalf("expected lyrics summary, got:\n%s", out.String())
+	}
+
+	source.Sync.Lyrics = config.LyricsLRC
+	tagged = map[string]string{}
+	before = syncer.postDownloadSnapshot(source, SyncOptions{})
+	for _, name := range []string{"New.mp3", "NoSync.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	fetchLyricsFn = func(ctx context.Context, track taggedTrack) (trackLyrics, error) {
+		if track.Title == "NoSync" {
+			return trackLyrics{Plain: "plain words"}, nil
+		}
+		return trackLyrics{Synced: "[00:01.00] synced words"}, nil
+	}
+	syncer.applyLyrics(context.Background(), source, before)
+	payload, err := os.ReadFile(filepath.Join(targetDir, "New.lrc"))
+	if err != nil || string(payload) != "[00:01.00] synced words\n" {
+		t.Fatalf("expected New.lrc with synced lyrics, got %q (%v)", payload, err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "NoSync.lrc")); !os.IsNotExist(err) {
+		t.Fatalf("expected no .lrc without synced lyrics, got %v", err)
+	}
+	if len(tagged) != 0 {
+		t.Fatalf("expected lrc mode to leave tags alone, got %v", tagged)
+	}
+}
@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	metadataProviderCacheSchema    = 1
+	metadataProviderCacheTTL       = 30 * 24 * time.Hour
+	defaultMetadataProviderTimeout = 15 * time.Second
+)
+
+// MetadataQuery describes the track being enriched. It is sent as JSON on
+// stdin to exec-protocol providers.
+type MetadataQuery struct {
+	SourceID  string `json:"source_id"`
+	TrackID   string `json:"track_id"`
+	Title     string `json:"title,omitempty"`
+	Artist    string `json:"artist,omitempty"`
+	Genre     string `json:"genre,omitempty"`
+	SourceURL string `json:"source_url,omitempty"`
+	FilePath  string `json:"file_path,omitempty"`
+}
+
+// MetadataEnrichment holds the optional tag values a provider can supply.
+// Empty fields mean "no opinion" and fall through to lower-priority providers.
+type MetadataEnrichment struct {
+	Label         string `json:"label,omitempty"`
+	CatalogNumber string `json:"catalog_number,omitempty"`
+	Genre         string `json:"genre,omitempty"`
+}
+
+func (m MetadataEnrichment) complete() bool {
+	return strings.TrimSpace(m.Label) != "" &&
+		strings.TrimSpace(m.CatalogNumber) != "" &&
+		strings.TrimSpace(m.Genre) != ""
+}
+
+func (m MetadataEnrichment) empty() bool {
+	return strings.TrimSpace(m.Label) == "" &&
+		strings.TrimSpace(m.CatalogNumber) == "" &&
+		strings.TrimSpace(m.Genre) == ""
+}
+
+// MetadataProvider looks up additional tags for a downloaded track.
+type MetadataProvider interface {
+	Name() string
+	Lookup(ctx context.Context, query MetadataQuery) (MetadataEnrichment, error)
+}
+
+// execMetadataProvider implements the exec plugin protocol: the query is
+// written as JSON to stdin and a MetadataEnrichment JSON object is read from
+// stdout. Empty stdout means no match.
+type execMetadataProvider struct {
+	name    string
+	command []string
+	timeout time.Duration
+}
+
+func newExecMetadataProvider(spec config.MetadataProvider) *execMetadataProvider {
+	timeout := defaultMetadataProviderTimeout
+	if spec.TimeoutSeconds > 0 {
+		timeout = time.Duration(spec.TimeoutSeconds) * time.Second
+	}
+	return &execMetadataProvider{
+		name:    strings.TrimSpace(spec.Name),
+		command: append([]string{}, spec.Command...),
+		timeout: timeout,
+	}
+}
+
+func (p *execMetadataProvider) Name() string {
+	return p.name
+}
+
+func (p *execMetadataProvider) Lookup(ctx context.Context, query MetadataQuery) (MetadataEnrichment, error) {
+	if len(p.command) == 0 || strings.TrimSpace(p.command[0]) == "" {
+		return MetadataEnrichment{}, fmt.Errorf("metadata provider %s has no command", p.name)
+	}
+	binary, err := config.ExpandPath(p.command[0])
+	if err != nil {
+		return MetadataEnrichment{}, err
+	}
+	payload, err := json.Marshal(query)
+	if err != nil {
+		return MetadataEnrichment{}, err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, binary, p.command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if runCtx.Err() == context.DeadlineExceeded {
+			return MetadataEnrichment{}, fmt.Errorf("timed out after %s", p.timeout)
+		}
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return MetadataEnrichment{}, fmt.Errorf("%v: %s", err, detail)
+		}
+		return MetadataEnrichment{}, err
+	}
+
+	raw := bytes.TrimSpace(stdout.Bytes())
+	if len(raw) == 0 {
+		return MetadataEnrichment{}, nil
+	}
+	result := MetadataEnrichment{}
+	if err := json.Unmarshal(raw, &result); err != nil {
+		return MetadataEnrichment{}, fmt.Errorf("decode provider output: %w", err)
+	}
+	return result, nil
+}
+
+type metadataProviderEntry struct {
+	provider MetadataProvider
+	priority int
+}
+
+type metadataProviderCacheRecord struct {
+	Schema  int                                 `json:"schema"`
+	Entries map[string]metadataProviderCacheHit `json:"entries"`
+}
+
+type metadataProviderCacheHit struct {
+	FetchedAt  time.Time          `json:"fetched_at"`
+	Enrichment MetadataEnrichment `json:"enrichment"`
+}
+
+// metadataProviderChain consults providers in priority order and merges their
+// results field by field. Successful lookups (including "no match") are cached
+// under state_dir so repeat runs do not hit external services again.
+type metadataProviderChain struct {
+	entries   []metadataProviderEntry
+	cachePath string
+	now       func() time.Time
+
+	mu     sync.Mutex
+	loaded bool
+	cache  map[string]metadataProviderCacheHit
+}
+
+func newMetadataProviderChain(cfg config.Config, now func() time.Time) *metadataProviderChain {
+	entries := []metadataProviderEntry{}
+	for _, spec := range cfg.MetadataProviders {
+		if !spec.Enabled {
+			continue
+		}
+		entries = append(entries, metadataProviderEntry{
+			provider: newExecMetadataProvider(spec),
+			priority: spec.Priority,
+		})
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	cachePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, "metadata-providers.cache.json")
+	if err != nil {
+		cachePath = ""
+	}
+	return newMetadataProviderChainWithEntries(entries, cachePath, now)
+}
+
+func newMetadataProviderChainWithEntries(entries []metadataProviderEntry, cachePath string, now func() time.Time) *metadataProviderChain {
+	if now == nil {
+		now = time.Now
+	}
+	sorted := append([]metadataProviderEntry{}, entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].priority > sorted[j].priority
+	})
+	return &metadataProviderChain{
+		entries:   sorted,
+		cachePath: cachePath,
+		now:       now,
+	}
+}
+
+// Enrich returns the merged enrichment plus any per-provider errors. Provider
+// errors never abort the chain; they are reported so callers can warn.
+func (c *metadataProviderChain) Enrich(ctx context.Context, query MetadataQuery) (MetadataEnrichment, []error) {
+	if c == nil || len(c.entries) == 0 {
+		return MetadataEnrichment{}, nil
+	}
+	merged := MetadataEnrichment{}
+	errs := []error{}
+	for _, entry := range c.entries {
+		if merged.complete() {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		name := entry.provider.Name()
+		key := metadataProviderCacheKey(name, query)
+		result, hit := c.cached(key)
+		if !hit {
+			lookedUp, err := entry.provider.Lookup(ctx, query)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("metadata provider %s: %w", name, err))
+				continue
+			}
+			result = lookedUp
+			c.store(key, result)
+		}
+		merged = mergeMetadataEnrichment(merged, result)
+	}
+	return merged, errs
+}
+
+func mergeMetadataEnrichment(base MetadataEnrichment, next MetadataEnrichment) MetadataEnrichment {
+	if strings.TrimSpace(base.Label) == "" {
+		base.Label = strings.TrimSpace(next.Label)
+	}
+	if strings.TrimSpace(base.CatalogNumber) == "" {
+		base.CatalogNumber = strings.TrimSpace(next.CatalogNumber)
+	}
+	if strings.TrimSpace(base.Genre) == "" {
+		base.Genre = strings.TrimSpace(next.Genre)
+	}
+	return base
+}
+
+func metadataProviderCacheKey(provider string, query MetadataQuery) string {
+	trackKey := strings.TrimSpace(query.TrackID)
+	if trackKey == "" {
+		trackKey = strings.ToLower(strings.TrimSpace(query.Artist) + " - " + strings.TrimSpace(query.Title))
+	}
+	return provider + "|" + strings.TrimSpace(query.SourceID) + "|" + trackKey
+}
+
+func (c *metadataProviderChain) cached(key string) (MetadataEnrichment, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+	hit, ok := c.cache[key]
+	if !ok {
+		return MetadataEnrichment{}, false
+	}
+	if c.now().Sub(hit.FetchedAt) > metadataProviderCacheTTL {
+		delete(c.cache, key)
+		return MetadataEnrichment{}, false
+	}
+	return hit.Enrichment, true
+}
+
+func (c *metadataProviderChain) store(key string, enrichment MetadataEnrichment) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.loadLocked()
+	c.cache[key] = metadataProviderCacheHit{FetchedAt: c.now().UTC(), Enrichment: enrichment}
+	c.persistLocked()
+}
+
+func (c *metadataProviderChain) loadLocked() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.cache = map[string]metadataProviderCacheHit{}
+	if strings.TrimSpace(c.cachePath) == "" {
+		return
+	}
+	raw, err := os.ReadFile(c.cachePath)
+	if err != nil {
+		return
+	}
+	record := metadataProviderCacheRecord{}
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return
+	}
+	if record.Schema != metadataProviderCacheSchema {
+		return
+	}
+	for key, hit := range record.Entries {
+		c.cache[key] = hit
+	}
+}
+
+func (c *metadataProviderChain) persistLocked() {
+	if strings.TrimSpace(c.cachePath) == "" {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.cachePath), 0o755); err != nil {
+		return
+	}
+	encoded, err := json.MarshalIndent(metadataProviderCacheRecord{
+		Schema:  metadataProviderCacheSchema,
+		Entries: c.cache,
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(c.cachePath), ".udl-metadata-cache-*.tmp")
+	if err != nil {
+		return
+	}
+	tempPath := tempFile.Name()
+	if _, err := tempFile.Write(encoded); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+		return
+	}
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return
+	}
+	if err := os.Rename(tempPath, c.cachePath); err != nil {
+		_ = os.Remove(tempPath)
+	}
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+type fakeMetadataProvider struct {
+	name   string
+	result MetadataEnrichment
+	err    error
+	calls  int
+}
+
+func (p *fakeMetadataProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeMetadataProvider) Lookup(ctx context.Context, query MetadataQuery) (MetadataEnrichment, error) {
+	p.calls++
+	return p.result, p.err
+}
+
+func TestMetadataProviderChainMergesByPriority(t *testing.T) {
+	low := &fakeMetadataProvider{name: "low", result: MetadataEnrichment{Label: "Low Label", CatalogNumber: "LOW001", Genre: "House"}}
+	high := &fakeMetadataProvider{name: "high", result: MetadataEnrichment{Label: "High Label"}}
+	broken := &fakeMetadataProvider{name: "broken", err: errors.New("boom")}
+
+	chain := newMetadataProviderChainWithEntries([]metadataProviderEntry{
+		{provider: low, priority: 1},
+		{provider: broken, priority: 5},
+		{provider: high, priority: 10},
+	}, "", nil)
+
+	enrichment, errs := chain.Enrich(context.Background(), MetadataQuery{SourceID: "sc", TrackID: "1"})
+	if enrichment.Label != "High Label" {
+		t.Fatalf("expected higher-priority label, got %+v", enrichment)
+	}
+	if enrichment.CatalogNumber != "LOW001" || enrichment.Genre != "House" {
+		t.Fatalf("expected lower-priority fields to fill gaps, got %+v", enrichment)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "metadata provider broken") {
+		t.Fatalf("expected broken provider error, got %v", errs)
+	}
+}
+
+func TestMetadataProviderChainStopsWhenComplete(t *testing.T) {
+	first := &fakeMetadataProvider{name: "first", result: MetadataEnrichment{Label: "L", CatalogNumber: "C", Genre: "G"}}
+	second := &fakeMetadataProvider{name: "second", result: MetadataEnrichment{Label: "other"}}
+	chain := newMetadataProviderChainWithEntries([]metadataProviderEntry{
+		{provider: first, priority: 2},
+		{provider: second, priority: 1},
+	}, "", nil)
+
+	if _, errs := chain.Enrich(context.Background(), MetadataQuery{TrackID: "1"}); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if second.calls != 0 {
+		t.Fatalf("expected lower-priority provider to be skipped, got %d call(s)", second.calls)
+	}
+}
+
+func TestMetadataProviderChainCachesLookupsInStateDir(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "metadata-providers.cache.json")
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	provider := &fakeMetadataProvider{name: "beatport", result: MetadataEnrichment{Label: "Cached Label"}}
+
+	chain := newMetadataProviderChainWithEntries([]metadataProviderEntry{{provider: provider}}, cachePath, clock)
+	query := MetadataQuery{SourceID: "sc", TrackID: "42"}
+	if got, _ := chain.Enrich(context.Background(), query); got.Label != "Cached Label" {
+		t.Fatalf("unexpected enrichment: %+v", got)
+	}
+
+	reloaded := newMetadataProviderChainWithEntries([]metadataProviderEntry{{provider: provider}}, cachePath, clock)
+	if got, _ := reloaded.Enrich(context.Background(), query); got.Label != "Cached Label" {
+		t.Fatalf("unexpected cached enrichment: %+v", got)
+	}
+	if provider.calls != 1 {
+		t.Fatalf("expected cached lookup to skip provider, got %d call(s)", provider.calls)
+	}
+
+	now = now.Add(metadataProviderCacheTTL + time.Hour)
+	expired := newMetadataProviderChainWithEntries([]metadataProviderEntry{{provider: provider}}, cachePath, clock)
+	_, _ = expired.Enrich(context.Background(), query)
+	if provider.calls != 2 {
+		t.Fatalf("expected expired cache entry to trigger lookup, got %d call(s)", provider.calls)
+	}
+}
+
+func TestExecMetadataProviderProtocol(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script provider not supported on windows")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "provider.sh")
+	payload := "#!/bin/sh\n" +
+		"input=$(cat)\n" +
+		"case \"$input\" in\n" +
+		"  *'\"track_id\":\"42\"'*) echo '{\"label\":\"Exec Label\",\"catalog_number\":\"EX042\"}' ;;\n" +
+		"  *) exit 0 ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(script, []byte(payload), 0o755); err != nil {
+		t.Fatalf("write provider script: %v", err)
+	}
+
+	provider := newExecMetadataProvider(config.MetadataProvider{Name: "exec", Command: []string{script}})
+	result, err := provider.Lookup(context.Background(), MetadataQuery{SourceID: "sc", TrackID: "42"})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if result.Label != "Exec Label" || result.CatalogNumber != "EX042" {
+		t.Fatalf("unexpected exec provider result: %+v", result)
+	}
+
+	miss, err := provider.Lookup(context.Background(), MetadataQuery{SourceID: "sc", TrackID: "7"})
+	if err != nil {
+		t.Fatalf("lookup miss: %v", err)
+	}
+	if !miss.empty() {
+		t.Fatalf("expected empty result for provider miss, got %+v", miss)
+	}
+}
+
+func TestFreeDownloadMetadataEnrichmentKeepsScrapedGenre(t *testing.T) {
+	metadata := soundCloudFreeDownloadMetadata{Genre: "Techno"}
+	enriched := metadata.withMetadataEnrichment(MetadataEnrichment{Label: "Label", CatalogNumber: "CAT1", Genre: "House"})
+	if enriched.Genre != "Techno" {
+		t.Fatalf("expected scraped genre to win, got %q", enriched.Genre)
+	}
+	if enriched.Label != "Label" || enriched.CatalogNumber != "CAT1" {
+		t.Fatalf("expected provider label/catalog, got %+v", enriched)
+	}
+}
@@ -0,0 +1,457 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const (
+	// musicBrainzMinScore is the lowest search score (0-100) accepted as a
+	// match; acoustIDMinScore is the same for fingerprint lookups (0-1).
+	musicBrainzMinScore = 90
+	acoustIDMinScore    = 0.8
+	// musicBrainzDurationSlack is how far a recording's length may be from
+	// the file's before a search hit is ignored.
+	musicBrainzDurationSlack = 3
+)
+
+var (
+	musicBrainzBaseURL    = "https://musicbrainz.org"
+	acoustIDBaseURL       = "https://api.acoustid.org"
+	musicBrainzHTTPClient = &http.Client{Timeout: 20 * time.Second}
+	// musicBrainzMinInterval spaces requests to musicbrainz.org, which
+	// allows one per second per client.
+	musicBrainzMinInterval = time.Second
+
+	fingerprintAcoustIDFn  = fingerprintAcoustID
+	writeMusicBrainzTagsFn = writeMusicBrainzTags
+)
+
+// musicBrainzUserAgent identifies udl as the MusicBrainz API requires.
+const musicBrainzUserAgent = "udl/musicbrainz ( https://github.com/adamhalama/music-library-sync )"
+
+var errMusicBrainzNoMatch = errors.New("no musicbrainz match")
+
+// musicBrainzMatch is what is written to a matched file.
+type musicBrainzMatch struct {
+	RecordingID    string
+	ArtistID       string
+	ReleaseID      string
+	ReleaseGroupID string
+	Album          string
+	Year           string
+	Genre          string
+}
+
+type acoustIDFingerprint struct {
+	DurationSeconds int
+	Fingerprint     string
+}
+
+var musicBrainzThrottle struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// applyMusicBrainz matches the media files that appeared or changed in
+// target_dir since before was taken to MusicBrainz recordings and writes
+// their MBIDs, plus album, date, and genre where the file has none. Files
+// that already carry a recording MBID are left alone; a failed lookup is a
+// warning and never fails the source.
+func (s *Syncer) applyMusicBrainz(ctx context.Context, source config.Source, before map[string]mediaFileSnapshot) {
+	if before == nil || source.Sync.MusicBrainz == "" {
+		return
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return
+	}
+	after, err := snapshotMediaFiles(targetDir)
+	if err != nil {
+		s.emitMusicBrainzLine(source.ID, output.LevelWarn, fmt.Sprintf("skipped: unable to scan target_dir: %v", err))
+		return
+	}
+	changed := changedMediaFiles(before, after)
+	if len(changed) == 0 {
+		return
+	}
+
+	mode := source.Sync.MusicBrainz
+	apiKey := strings.TrimSpace(os.Getenv("UDL_ACOUSTID_API_KEY"))
+	if mode == config.MusicBrainzFingerprint && apiKey == "" {
+		s.emitMusicBrainzLine(source.ID, output.LevelWarn, "UDL_ACOUSTID_API_KEY is not set; matching by title and artist instead of fingerprint")
+		mode = config.MusicBrainzSearch
+	}
+	tagged, unmatched := 0, 0
+	for _, rel := range changed {
+		if ctx.Err() != nil {
+			return
+		}
+		err := s.tagMusicBrainzTrack(ctx, mode, apiKey, filepath.Join(targetDir, filepath.FromSlash(rel)))
+		switch {
+		case errors.Is(err, errMusicBrainzNoMatch):
+			unmatched++
+		case err != nil:
+			s.emitMusicBrainzLine(source.ID, output.LevelWarn, fmt.Sprintf("%s: %v", rel, err))
+		default:
+			tagged++
+		}
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] [musicbrainz] tagged %d/%d new file(s) (%d unmatched)", source.ID, tagged, len(changed), unmatched),
+		Details: map[string]any{
+			"mode":            mode,
+			"tagged_count":    tagged,
+			"unmatched_count": unmatched,
+			"changed_count":   len(changed),
+		},
+	})
+}
+
+// tagMusicBrainzTrack handles one file. Files that already have a recording
+// MBID count as unmatched so they are neither rewritten nor warned about.
+func (s *Syncer) tagMusicBrainzTrack(ctx context.Context, mode string, apiKey string, path string) error {
+	track, err := probeTaggedTrackFn(ctx, path)
+	if err != nil {
+		return fmt.Errorf("read tags: %w", err)
+	}
+	if track.Tags["musicbrainz_trackid"] != "" || track.Tags["musicbrainz track id"] != "" {
+		return errMusicBrainzNoMatch
+	}
+
+	recordingID := ""
+	if mode == config.MusicBrainzFingerprint {
+		fingerprint, err := fingerprintAcoustIDFn(ctx, path)
+		if err != nil {
+			return fmt.Errorf("fpcalc: %w", err)
+		}
+		recordingID, err = lookupAcoustIDRecording(ctx, apiKey, fingerprint)
+		if err != nil && !errors.Is(err, errMusicBrainzNoMatch) {
+			return err
+		}
+	}
+	if recordingID == "" {
+		recordingID, err = searchMusicBrainzRecording(ctx, track)
+		if err != nil {
+			return err
+		}
+	}
+	match, err := lookupMusicBrainzRecording(ctx, recordingID, track.Album)
+	if err != nil {
+		return err
+	}
+	return writeMusicBrainzTagsFn(ctx, path, match, track.Tags)
+}
+
+// searchMusicBrainzRecording finds the best recording for the file's title
+// and artist whose length is close to the file's. SoundCloud-style
+// "Artist - Title" titles are retried split when the tags do not match.
+func searchMusicBrainzRecording(ctx context.Context, track taggedTrack) (string, error) {
+	if track.Title == "" {
+		return "", errMusicBrainzNoMatch
+	}
+	attempts := []taggedTrack{track}
+	if artist, title, ok := strings.Cut(track.Title, " - "); ok {
+		split := track
+		split.Artist, split.Title = strings.TrimSpace(artist), strings.TrimSpace(title)
+		attempts = append(attempts, split)
+	}
+	for _, attempt := range attempts {
+		query := "recording:" + luceneQuote(attempt.Title)
+		if attempt.Artist != "" {
+			query += " AND artist:" + luceneQuote(attempt.Artist)
+		}
+		var payload struct {
+			Recordings []struct {
+				ID     string `json:"id"`
+				Score  int    `json:"score"`
+				Length int    `json:"length"`
+			} `json:"recordings"`
+		}
+		endpoint := musicBrainzEndpoint("/ws/2/recording", url.Values{"query": {query}, "limit": {"5"}})
+		if err := getMusicBrainzJSON(ctx, endpoint, &payload); err != nil {
+			return "", err
+		}
+		for _, recording := range payload.Recordings {
+			if recording.Score < musicBrainzMinScore {
+				break
+			}
+			if track.DurationSeconds > 0 && recording.Length > 0 {
+				if math.Abs(float64(recording.Length)/1000-float64(track.DurationSeconds)) > musicBrainzDurationSlack {
+					continue
+				}
+			}
+			return recording.ID, nil
+		}
+	}
+	return "", errMusicBrainzNoMatch
+}
+
+// lookupMusicBrainzRecording reads a recording's artist, releases, and
+// genres. The release whose title matches album is preferred, then the
+// earliest official one.
+func lookupMusicBrainzRecording(ctx context.Context, recordingID string, album string) (musicBrainzMatch, error) {
+	type musicBrainzRelease struct {
+		ID           string `json:"id"`
+		Title        string `json:"title"`
+		Date         string `json:"date"`
+		Status       string `json:"status"`
+		ReleaseGroup struct {
+			ID string `json:"id"`
+		} `json:"release-group"`
+	}
+	var payload struct {
+		ID           string `json:"id"`
+		ArtistCredit []struct {
+			Artist struct {
+				ID string `json:"id"`
+			} `json:"artist"`
+		} `json:"artist-credit"`
+		Releases []musicBrainzRelease `json:"releases"`
+		Genres   []struct {
+			Name  string `json:"name"`
+			Count int    `json:"count"`
+		} `json:"genres"`
+	}
+	endpoint := musicBrainzEndpoint("/ws/2/recording/"+url.PathEscape(recordingID), url.Values{"inc": {"artists releases release-groups genres"}})
+	if err := getMusicBrainzJSON(ctx, endpoint, &payload); err != nil {
+		return musicBrainzMatch{}, err
+	}
+	match := musicBrainzMatch{RecordingID: payload.ID}
+	if match.RecordingID == "" {
+		match.RecordingID = recordingID
+	}
+	if len(payload.ArtistCredit) > 0 {
+		match.ArtistID = payload.ArtistCredit[0].Artist.ID
+	}
+
+	releases := append([]musicBrainzRelease{}, payload.Releases...)
+	sort.SliceStable(releases, func(i, j int) bool {
+		iOfficial, jOfficial := releases[i].Status == "Official", releases[j].Status == "Official"
+		if iOfficial != jOfficial {
+			return iOfficial
+		}
+		if releases[i].Date == "" || releases[j].Date == "" {
+			return releases[i].Date != ""
+		}
+		return releases[i].Date < releases[j].Date
+	})
+	for i, release := range releases {
+		if strings.EqualFold(strings.TrimSpace(release.Title), strings.TrimSpace(album)) {
+			releases[0], releases[i] = releases[i], releases[0]
+			break
+		}
+	}
+	if len(releases) > 0 {
+		chosen := releases[0]
+		match.ReleaseID = chosen.ID
+		match.ReleaseGroupID = chosen.ReleaseGroup.ID
+		match.Album = strings.TrimSpace(chosen.Title)
+		if len(chosen.Date) >= 4 {
+			match.Year = chosen.Date[:4]
+		}
+	}
+	best := 0
+	for _, genre := range payload.Genres {
+		if genre.Count > best {
+			best = genre.Count
+			match.Genre = strings.TrimSpace(genre.Name)
+		}
+	}
+	return match, nil
+}
+
+// lookupAcoustIDRecording returns the recording MBID AcoustID matches the
+// fingerprint to with the highest score.
+func lookupAcoustIDRecording(ctx context.Context, apiKey string, fingerprint acoustIDFingerprint) (string, error) {
+	form := url.Values{
+		"client":      {apiKey},
+		"meta":        {"recordingids"},
+		"duration":    {strconv.Itoa(fingerprint.DurationSeconds)},
+		"fingerprint": {fingerprint.Fingerprint},
+		"format":      {"json"},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(acoustIDBaseURL, "/")+"/v2/lookup", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", musicBrainzUserAgent)
+	resp, err := musicBrainzHTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("acoustid: %w", err)
+	}
+	defer resp.Body.Close()
+	var payload struct {
+		Status string `json:"status"`
+		Error  struct {
+			Message string `json:"message"`
+		} `json:"error"`
+		Results []struct {
+			Score      float64 `json:"score"`
+			Recordings []struct {
+				ID string `json:"id"`
+			} `json:"recordings"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&payload); err != nil {
+		return "", fmt.Errorf("acoustid: HTTP %d: %w", resp.StatusCode, err)
+	}
+	if payload.Status != "ok" {
+		return "", fmt.Errorf("acoustid: %s", payload.Error.Message)
+	}
+	sort.SliceStable(payload.Results, func(i, j int) bool { return payload.Results[i].Score > payload.Results[j].Score })
+	for _, result := range payload.Results {
+		if result.Score < acoustIDMinScore {
+			break
+		}
+		if len(result.Recordings) > 0 {
+			return result.Recordings[0].ID, nil
+		}
+	}
+	return "", errMusicBrainzNoMatch
+}
+
+func fingerprintAcoustID(ctx context.Context, path string) (acoustIDFingerprint, error) {
+	output, err := exec.CommandContext(ctx, "fpcalc", "-json", path).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if trimmed := strings.TrimSpace(string(exitErr.Stderr)); trimmed != "" {
+				return acoustIDFingerprint{}, fmt.Errorf("%v: %s", err, trimmed)
+			}
+		}
+		return acoustIDFingerprint{}, err
+	}
+	var payload struct {
+		Duration    float64 `json:"duration"`
+		Fingerprint string  `json:"fingerprint"`
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return acoustIDFingerprint{}, fmt.Errorf("decode fpcalc output: %w", err)
+	}
+	return acoustIDFingerprint{DurationSeconds: int(math.Round(payload.Duration)), Fingerprint: payload.Fingerprint}, nil
+}
+
+func musicBrainzEndpoint(path string, query url.Values) string {
+	query.Set("fmt", "json")
+	return strings.TrimSuffix(musicBrainzBaseURL, "/") + path + "?" + query.Encode()
+}
+
+// getMusicBrainzJSON waits out musicBrainzMinInterval since the previous
+// request, then decodes rawURL's JSON response into target.
+func getMusicBrainzJSON(ctx context.Context, rawURL string, target any) error {
+	musicBrainzThrottle.mu.Lock()
+	wait := musicBrainzMinInterval - time.Since(musicBrainzThrottle.last)
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			musicBrainzThrottle.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	musicBrainzThrottle.last = time.Now()
+	musicBrainzThrottle.mu.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", musicBrainzUserAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := musicBrainzHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("musicbrainz: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errMusicBrainzNoMatch
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("musicbrainz: HTTP %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(target); err != nil {
+		return fmt.Errorf("musicbrainz: %w", err)
+	}
+	return nil
+}
+
+// luceneQuote quotes value as a Lucene phrase for the MusicBrainz search
+// syntax.
+func luceneQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+// musicBrainzTagNames returns the MBID tag names Picard writes for a
+// container: Vorbis comment names for FLAC and Ogg, and the TXXX
+// descriptions it uses for MP3 and MP4 (ffmpeg cannot write the UFID frame
+// Picard puts the recording id in, so MP3s get a TXXX frame instead).
+func musicBrainzTagNames(ext string) (recording string, artist string, release string, releaseGroup string) {
+	switch strings.ToLower(ext) {
+	case ".mp3", ".m4a", ".mp4":
+		return "MusicBrainz Track Id", "MusicBrainz Artist Id", "MusicBrainz Album Id", "MusicBrainz Release Group Id"
+	default:
+		return "MUSICBRAINZ_TRACKID", "MUSICBRAINZ_ARTISTID", "MUSICBRAINZ_ALBUMID", "MUSICBRAINZ_RELEASEGROUPID"
+	}
+}
+
+// writeMusicBrainzTags remuxes path with match's MBIDs, and its album, date,
+// and genre where existing has none, keeping the streams and the other tags
+// as they are.
+func writeMusicBrainzTags(ctx context.Context, path string, match musicBrainzMatch, existing map[string]string) error {
+	args := []string{}
+	recordingTag, artistTag, releaseTag, releaseGroupTag := musicBrainzTagNames(filepath.Ext(path))
+	for _, tag := range []struct{ name, value string }{
+		{recordingTag, match.RecordingID},
+		{artistTag, match.ArtistID},
+		{releaseTag, match.ReleaseID},
+		{releaseGroupTag, match.ReleaseGroupID},
+	} {
+		if tag.value != "" {
+			args = append(args, "-metadata", tag.name+"="+tag.value)
+		}
+	}
+	for _, tag := range []struct{ name, value string }{
+		{"album", match.Album},
+		{"date", match.Year},
+		{"genre", match.Genre},
+	} {
+		if tag.value != "" && existing[tag.name] == "" {
+			args = append(args, "-metadata", tag.name+"="+tag.value)
+		}
+	}
+	return remuxWithTags(ctx, path, args)
+}
+
+func (s *Syncer) emitMusicBrainzLine(sourceID string, level output.Level, line string) {
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [musicbrainz] %s", sourceID, line),
+	})
+}
@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestApplyMusicBrainzTagsNewFilesBySearchAndFingerprint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "udl/musicbrainz") {
+			t.Fatalf("expected a udl User-Agent, got %q", r.Header.Get("User-Agent"))
+		}
+		switch {
+		case r.URL.Path == "/ws/2/recording":
+			query := r.URL.Query().Get("query")
+			switch query {
+			case `recording:"One More Time" AND artist:"Daft Punk"`:
+				// The first hit is a live version ten seconds longer.
+				fmt.Fprint(w, `{"recordings":[{"id":"rec-live","score":100,"length":330000},{"id":"rec-1","score":98,"length":320000}]}`)
+			case `recording:"Daft Punk - Aerodynamic" AND artist:"uploader"`:
+				fmt.Fprint(w, `{"recordings":[]}`)
+			case `recording:"Aerodynamic" AND artist:"Daft Punk"`:
+				fmt.Fprint(w, `{"recordings":[{"id":"rec-2","score":95,"length":0}]}`)
+			default:
+				fmt.Fprint(w, `{"recordings":[{"id":"weak","score":40}]}`)
+			}
+		case strings.HasPrefix(r.URL.Path, "/ws/2/recording/"):
+			id := strings.TrimPrefix(r.URL.Path, "/ws/2/recording/")
+			if r.URL.Query().Get("inc") != "artists releases release-groups genres" {
+				t.Fatalf("unexpected inc %q", r.URL.Query().Get("inc"))
+			}
+			fmt.Fprintf(w, `{"id":%q,"artist-credit":[{"artist":{"id":"artist-1"}}],"releases":[
+				{"id":"rel-comp","title":"Hits","date":"2005-01-01","status":"Official","release-group":{"id":"rg-comp"}},
+				{"id":"rel-bootleg","title":"Live","date":"1999","status":"Bootleg","release-group":{"id":"rg-boot"}},
+				{"id":"rel-1","title":"Discovery","date":"2001-03-12","status":"Official","release-group":{"id":"rg-1"}}
+			],"genres":[{"name":"house","count":3},{"name":"french house","count":7}]}`, id)
+		case r.URL.Path == "/v2/lookup":
+			if err := r.ParseForm(); err != nil || r.PostForm.Get("client") != "key" || r.PostForm.Get("fingerprint") != "AQAA" {
+				t.Fatalf("unexpected acoustid form %v (%v)", r.PostForm, err)
+			}
+			fmt.Fprint(w, `{"status":"ok","results":[{"score":0.5,"recordings":[{"id":"rec-weak"}]},{"score":0.95,"recordings":[{"id":"rec-fp"}]}]}`)
+		default:
+			t.Fatalf("unexpected request %s", r.URL.String())
+		}
+	}))
+	defer server.Close()
+
+	origBase, origAcoustID, origInterval := musicBrainzBaseURL, acoustIDBaseURL, musicBrainzMinInterval
+	origProbe, origFingerprint, origWrite := probeTaggedTrackFn, fingerprintAcoustIDFn, writeMusicBrainzTagsFn
+	t.Cleanup(func() {
+		musicBrainzBaseURL, acoustIDBaseURL, musicBrainzMinInterval = origBase, origAcoustID, origInterval
+		probeTaggedTrackFn, fingerprintAcoustIDFn, writeMusicBrainzTagsFn = origProbe, origFingerprint, origWrite
+	})
+	musicBrainzBaseURL, acoustIDBaseURL, musicBrainzMinInterval = server.URL, server.URL, 0
+	probeTaggedTrackFn = func(ctx context.Context, path string) (taggedTrack, error) {
+		switch filepath.Base(path) {
+		case "One More Time.mp3":
+			return taggedTrack{Artist: "Daft Punk", Title: "One More Time", Album: "Hits", DurationSeconds: 321, Tags: map[string]string{"album": "Hits"}}, nil
+		case "Aerodynamic.flac":
+			return taggedTrack{Artist: "uploader", Title: "Daft Punk - Aerodynamic", Tags: map[string]string{}}, nil
+		case "Tagged.flac":
+			return taggedTrack{Artist: "Daft Punk", Title: "Tagged", Tags: map[string]string{"musicbrainz_trackid": "rec-0"}}, nil
+		default:
+			return taggedTrack{Artist: "Nobody", Title: "Unknown", Tags: map[string]string{}}, nil
+		}
+	}
+	fingerprintAcoustIDFn = func(ctx context.Context, path string) (acoustIDFingerprint, error) {
+		return acoustIDFingerprint{DurationSeconds: 200, Fingerprint: "AQAA"}, nil
+	}
+	written := map[string]musicBrainzMatch{}
+	writeMusicBrainzTagsFn = func(ctx context.Context, path string, match musicBrainzMatch, existing map[string]string) error {
+		written[filepath.Base(path)] = match
+		return nil
+	}
+
+	targetDir := t.TempDir()
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+	source := config.Source{ID: "sc", Type: config.SourceTypeSoundCloud, TargetDir: targetDir, Sync: config.SyncPolicy{MusicBrainz: config.MusicBrainzSearch}}
+
+	before := syncer.postDownloadSnapshot(source, SyncOptions{})
+	for _, name := range []string{"One More Time.mp3", "Aerodynamic.flac", "Tagged.flac", "Unknown.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	syncer.applyMusicBrainz(context.Background(), source, before)
+	if len(written) != 2 {
+		t.Fatalf("expected two files tagged, got %v\n%s", written, out.String())
+	}
+	first := written["One More Time.mp3"]
+	if first.RecordingID != "rec-1" || first.ArtistID != "artist-1" || first.ReleaseID != "rel-comp" || first.ReleaseGroupID != "rg-comp" || first.Year != "2005" || first.Genre != "french house" {
+		t.Fatalf("expected the studio recording on the release matching the album tag, got %+v", first)
+	}
+	if second := written["Aerodynamic.flac"]; second.RecordingID != "rec-2" || second.ReleaseID != "rel-1" {
+		t.Fatalf("expected the split title to match and the earliest official release, got %+v", second)
+	}
+	if !strings.Contains(out.String(), "[sc] [musicbrainz] tagged 2/4 new file(s) (2 unmatched)") {
+		t.Fatalf("expected musicbrainz summary, got:\n%s", out.String())
+	}
+
+	t.Setenv("UDL_ACOUSTID_API_KEY", "key")
+	source.Sync.MusicBrainz = config.MusicBrainzFingerprint
+	written = map[string]musicBrainzMatch{}
+	before = syncer.postDownloadSnapshot(source, SyncOptions{})
+	if err := os.WriteFile(filepath.Join(targetDir, "Fingerprinted.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	syncer.applyMusicBrainz(context.Background(), source, before)
+	if match := written["Fingerprinted.mp3"]; match.RecordingID != "rec-fp" {
+		t.Fatalf("expected the best fingerprint match, got %v", written)
+	}
+}
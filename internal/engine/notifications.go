@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	defaultNotificationTimeout = 10 * time.Second
+	notificationMessageMaxLen  = 1900
+)
+
+var notificationHTTPClient = &http.Client{}
+
+// runNotificationPayload is the body sent to generic webhooks.
+type runNotificationPayload struct {
+	Event   string    `json:"event"`
+	Summary string    `json:"summary"`
+	Run     RunReport `json:"run"`
+}
+
+func runNotificationEvent(report RunReport) string {
+	switch {
+	case report.Result.Interrupted:
+		return config.NotificationEventInterrupted
+	case report.Result.Failed > 0 || report.Error != "":
+		return config.NotificationEventFailed
+	default:
+		return config.NotificationEventFinished
+	}
+}
+
+// sendRunNotifications posts the run outcome to every webhook subscribed to
+// it, followed by a new_tracks notification when monitor sources found
+// tracks. A fresh context is used so interrupted runs still notify.
+func sendRunNotifications(notifications []config.Notification, report RunReport) []error {
+	events := []string{runNotificationEvent(report)}
+	if countRunNewTracks(report) > 0 {
+		events = append(events, config.NotificationEventNewTracks)
+	}
+	failures := []error{}
+	for _, event := range events {
+		for i, notification := range notifications {
+			if !notificationWantsEvent(notification, event) {
+				continue
+			}
+			label := notification.Name
+			if label == "" {
+				label = fmt.Sprintf("#%d", i+1)
+			}
+			if err := sendRunNotification(notification, event, report); err != nil {
+				failures = append(failures, fmt.Errorf("notification %s: %w", label, err))
+			}
+		}
+	}
+	return failures
+}
+
+func countRunNewTracks(report RunReport) int {
+	count := 0
+	for _, source := range report.Sources {
+		count += len(source.NewTracks)
+	}
+	return count
+}
+
+func notificationWantsEvent(notification config.Notification, event string) bool {
+	if len(notification.Events) == 0 {
+		return true
+	}
+	for _, candidate := range notification.Events {
+		if candidate == event {
+			return true
+		}
+	}
+	return false
+}
+
+func sendRunNotification(notification config.Notification, event string, report RunReport) error {
+	target := strings.TrimSpace(notification.URL)
+	if name := strings.TrimSpace(notification.URLEnv); name != "" {
+		target = strings.TrimSpace(os.Getenv(name))
+		if target == "" {
+			return fmt.Errorf("env var %s is not set", name)
+		}
+	}
+	body, err := buildRunNotificationBody(notification.Format, event, report)
+	if err != nil {
+		return err
+	}
+
+	timeout := defaultNotificationTimeout
+	if notification.TimeoutSeconds > 0 {
+		timeout = time.Duration(notification.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		// The error text would echo the URL, which usually embeds a token.
+		return fmt.Errorf("build webhook request failed")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "udl/notifications")
+	resp, err := notificationHTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		return fmt.Errorf("webhook request failed")
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func buildRunNotificationBody(format string, event string, report RunReport) ([]byte, error) {
+	summary := formatRunNotificationSummary(event, report)
+	switch format {
+	case config.NotificationFormatDiscord:
+		return json.Marshal(map[string]string{"content": summary})
+	case config.NotificationFormatSlack:
+		return json.Marshal(map[string]string{"text": summary})
+	default:
+		return json.Marshal(runNotificationPayload{Event: event, Summary: summary, Run: report})
+	}
+}
+
+func formatRunNotificationSummary(event string, report RunReport) string {
+	if event == config.NotificationEventNewTracks {
+		return formatNewTracksNotificationSummary(report)
+	}
+	var b strings.Builder
+	fmt.Fprintf(
+		&b,
+		"udl sync %s: attempted=%d succeeded=%d failed=%d skipped=%d",
+		event,
+		report.Result.Attempted,
+		report.Result.Succeeded,
+		report.Result.Failed,
+		report.Result.Skipped,
+	)
+	for _, source := range report.Sources {
+		if source.Status != "failed" {
+			continue
+		}
+		line := "\n- " + source.SourceID
+		if source.Message != "" {
+			line += ": " + source.Message
+		}
+		if b.Len()+len(line) > notificationMessageMaxLen {
+			b.WriteString("\n- ...")
+			break
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func formatNewTracksNotificationSummary(report RunReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "udl monitor: %d new track(s)", countRunNewTracks(report))
+	for _, source := range report.Sources {
+		for _, track := range source.NewTracks {
+			label := track.Title
+			if label == "" {
+				label = track.ID
+			}
+			line := fmt.Sprintf("\n- %s: %s", source.SourceID, label)
+			if track.URL != "" {
+				line += " " + track.URL
+			}
+			if b.Len()+len(line) > notificationMessageMaxLen {
+				b.WriteString("\n- ...")
+				return b.String()
+			}
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
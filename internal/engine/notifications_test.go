@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestSendRunNotificationsFiltersEventsAndFormatsPayloads(t *testing.T) {
+	bodies := map[string]map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		decoded := map[string]any{}
+		_ = json.Unmarshal(raw, &decoded)
+		bodies[r.URL.Path] = decoded
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+	t.Setenv("UDL_TEST_WEBHOOK", server.URL+"/discord")
+
+	report := RunReport{
+		RunID:   "20260402T103000Z",
+		Result:  RunReportResult{Attempted: 2, Succeeded: 1, Failed: 1},
+		Sources: []RunSourceOutcome{{SourceID: "sc", Status: "finished"}, {SourceID: "sp", Status: "failed", Message: "auth failed"}},
+	}
+	failures := sendRunNotifications([]config.Notification{
+		{Name: "generic", URL: server.URL + "/generic"},
+		{Name: "discord", URLEnv: "UDL_TEST_WEBHOOK", Format: config.NotificationFormatDiscord, Events: []string{config.NotificationEventFailed}},
+		{Name: "slack", URL: server.URL + "/slack", Format: config.NotificationFormatSlack, Events: []string{config.NotificationEventFinished}},
+		{Name: "broken", URL: server.URL + "/broken"},
+	}, report)
+
+	if len(failures) != 1 || !strings.Contains(failures[0].Error(), "notification broken: webhook returned status 502") {
+		t.Fatalf("unexpected failures: %v", failures)
+	}
+	if _, sent := bodies["/slack"]; sent {
+		t.Fatalf("expected slack webhook subscribed to finished to be skipped for a failed run")
+	}
+	if bodies["/generic"]["event"] != "failed" {
+		t.Fatalf("unexpected generic payload: %v", bodies["/generic"])
+	}
+	run, _ := bodies["/generic"]["run"].(map[string]any)
+	if run["run_id"] != "20260402T103000Z" {
+		t.Fatalf("expected run report in generic payload, got %v", bodies["/generic"])
+	}
+	content, _ := bodies["/discord"]["content"].(string)
+	if !strings.Contains(content, "udl sync failed: attempted=2 succeeded=1 failed=1") || !strings.Contains(content, "- sp: auth failed") {
+		t.Fatalf("unexpected discord content: %q", content)
+	}
+}
+
+func TestSendRunNotificationsSendsNewTracksForMonitorSources(t *testing.T) {
+	bodies := map[string][]map[string]any{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		decoded := map[string]any{}
+		_ = json.Unmarshal(raw, &decoded)
+		bodies[r.URL.Path] = append(bodies[r.URL.Path], decoded)
+	}))
+	defer server.Close()
+
+	report := RunReport{
+		RunID:  "20260402T103000Z",
+		Result: RunReportResult{Attempted: 1, Succeeded: 1},
+		Sources: []RunSourceOutcome{{
+			SourceID:  "sc-artist",
+			Status:    "finished",
+			NewTracks: []MonitoredTrack{{ID: "222", Title: "New One", URL: "https://soundcloud.com/artist/new-one"}},
+		}},
+	}
+	failures := sendRunNotifications([]config.Notification{
+		{Name: "discord", URL: server.URL + "/discord", Format: config.NotificationFormatDiscord, Events: []string{config.NotificationEventNewTracks}},
+		{Name: "finished", URL: server.URL + "/finished", Events: []string{config.NotificationEventFinished}},
+	}, report)
+	if len(failures) != 0 {
+		t.Fatalf("unexpected failures: %v", failures)
+	}
+
+	if len(bodies["/discord"]) != 1 {
+		t.Fatalf("expected one new_tracks notification, got %v", bodies["/discord"])
+	}
+	content, _ := bodies["/discord"][0]["content"].(string)
+	if !strings.Contains(content, "udl monitor: 1 new track(s)") || !strings.Contains(content, "- sc-artist: New One https://soundcloud.com/artist/new-one") {
+		t.Fatalf("unexpected discord content: %q", content)
+	}
+	if len(bodies["/finished"]) != 1 || bodies["/finished"][0]["event"] != "finished" {
+		t.Fatalf("expected finished webhook to get only the run event, got %v", bodies["/finished"])
+	}
+}
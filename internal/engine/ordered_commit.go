@@ -0,0 +1,79 @@
+package engine
+
+import "sort"
+
+// trackCommitOrderer gates per-track commits (state appends and [done]
+// events) on remote playlist position for --ordered runs. A commit runs once
+// every earlier planned position has committed or been skipped, so tracks
+// that finish out of order are written back in the order the playlist lists
+// them. Without --ordered commits run as soon as they arrive.
+type trackCommitOrderer struct {
+	ordered bool
+	next    int
+	pending map[int]func() error
+	skipped map[int]struct{}
+}
+
+func newTrackCommitOrderer(ordered bool) *trackCommitOrderer {
+	return &trackCommitOrderer{
+		ordered: ordered,
+		pending: map[int]func() error{},
+		skipped: map[int]struct{}{},
+	}
+}
+
+// Commit queues fn for the track at remote position pos (0-based among the
+// planned tracks) and runs every commit that is no longer blocked.
+func (o *trackCommitOrderer) Commit(pos int, fn func() error) error {
+	if !o.ordered {
+		return fn()
+	}
+	o.pending[pos] = fn
+	return o.drain()
+}
+
+// Skip settles pos without a commit, unblocking later positions.
+func (o *trackCommitOrderer) Skip(pos int) error {
+	if !o.ordered {
+		return nil
+	}
+	o.skipped[pos] = struct{}{}
+	return o.drain()
+}
+
+// Flush runs the remaining queued commits in position order, ignoring
+// positions that never settled.
+func (o *trackCommitOrderer) Flush() error {
+	positions := make([]int, 0, len(o.pending))
+	for pos := range o.pending {
+		positions = append(positions, pos)
+	}
+	sort.Ints(positions)
+	for _, pos := range positions {
+		fn := o.pending[pos]
+		delete(o.pending, pos)
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *trackCommitOrderer) drain() error {
+	for {
+		if _, ok := o.skipped[o.next]; ok {
+			delete(o.skipped, o.next)
+			o.next++
+			continue
+		}
+		fn, ok := o.pending[o.next]
+		if !ok {
+			return nil
+		}
+		delete(o.pending, o.next)
+		o.next++
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+}
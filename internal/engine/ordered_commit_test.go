@@ -0,0 +1,67 @@
+package engine
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestTrackCommitOrdererHoldsCommitsUntilEarlierPositionsSettle(t *testing.T) {
+	committed := []string{}
+	commit := func(id string) func() error {
+		return func() error {
+			committed = append(committed, id)
+			return nil
+		}
+	}
+
+	orderer := newTrackCommitOrderer(true)
+	if err := orderer.Commit(2, commit("c")); err != nil {
+		t.Fatalf("commit c: %v", err)
+	}
+	if err := orderer.Commit(0, commit("a")); err != nil {
+		t.Fatalf("commit a: %v", err)
+	}
+	if got := strings.Join(committed, ","); got != "a" {
+		t.Fatalf("expected only a committed while position 1 is open, got %q", got)
+	}
+	if err := orderer.Skip(1); err != nil {
+		t.Fatalf("skip b: %v", err)
+	}
+	if err := orderer.Commit(4, commit("e")); err != nil {
+		t.Fatalf("commit e: %v", err)
+	}
+	if got := strings.Join(committed, ","); got != "a,c" {
+		t.Fatalf("expected a,c after skipping position 1, got %q", got)
+	}
+	if err := orderer.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if got := strings.Join(committed, ","); got != "a,c,e" {
+		t.Fatalf("expected flush to commit e, got %q", got)
+	}
+}
+
+func TestTrackCommitOrdererUnorderedCommitsImmediately(t *testing.T) {
+	committed := []int{}
+	orderer := newTrackCommitOrderer(false)
+	for _, pos := range []int{3, 1, 2} {
+		pos := pos
+		if err := orderer.Commit(pos, func() error {
+			committed = append(committed, pos)
+			return nil
+		}); err != nil {
+			t.Fatalf("commit %d: %v", pos, err)
+		}
+	}
+	if len(committed) != 3 || committed[0] != 3 || committed[1] != 1 || committed[2] != 2 {
+		t.Fatalf("expected completion order, got %v", committed)
+	}
+
+	wantErr := errors.New("state append failed")
+	ordered := newTrackCommitOrderer(true)
+	_ = ordered.Commit(1, func() error { return nil })
+	if err := ordered.Commit(0, func() error { return wantErr }); !errors.Is(err, wantErr) {
+		t.Fatalf("expected commit error to surface, got %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+var (
+	geteuidFn = os.Geteuid
+	lchownFn  = os.Lchown
+)
+
+// fileOwnershipRun carries defaults.file_ownership through one sync: the
+// directories the run wrote to, and the owner to hand the files it created
+// to once every source is done.
+type fileOwnershipRun struct {
+	uid          int
+	gid          int
+	chown        bool
+	since        time.Time
+	restoreUmask func()
+	dirs         []string
+	seen         map[string]struct{}
+	warned       map[string]struct{}
+}
+
+// beginFileOwnership applies the configured umask for the rest of the sync
+// and resolves the owner and group to enforce. Dry runs only get the
+// ownership warnings.
+func (s *Syncer) beginFileOwnership(cfg config.Config, opts SyncOptions) *fileOwnershipRun {
+	run := &fileOwnershipRun{
+		uid:          -1,
+		gid:          -1,
+		since:        time.Now(),
+		restoreUmask: func() {},
+		seen:         map[string]struct{}{},
+		warned:       map[string]struct{}{},
+	}
+	policy := cfg.Defaults.FileOwnership
+	if opts.DryRun || !policy.Enabled() {
+		return run
+	}
+	if mask, ok, err := policy.UmaskValue(); err == nil && ok {
+		if previous, applied := setProcessUmask(mask); applied {
+			run.restoreUmask = func() { setProcessUmask(previous) }
+		}
+	}
+	uid, gid, err := policy.IDs()
+	if err != nil {
+		s.emitOwnershipLine("", output.LevelWarn, fmt.Sprintf("not changing file ownership: defaults.file_ownership %v", err))
+		return run
+	}
+	run.uid, run.gid = uid, gid
+	run.chown = uid >= 0 || gid >= 0
+	if euid := geteuidFn(); run.chown && uid >= 0 && uid != euid && euid != 0 {
+		s.emitOwnershipLine("", output.LevelWarn, fmt.Sprintf("defaults.file_ownership.owner is uid %d but udl runs as uid %d without root; changing the owner of new files will fail", uid, euid))
+	}
+	if stateDir, err := config.ExpandPath(cfg.Defaults.StateDir); err == nil {
+		run.addDir(stateDir)
+	}
+	return run
+}
+
+// observeSource records the directories source writes to, and warns when one
+// of them belongs to another user: files this run creates there will be owned
+// by the user running udl (root on many NAS setups), which the library owner
+// may then be unable to move, retag, or delete.
+func (s *Syncer) observeSourceOwnership(run *fileOwnershipRun, cfg config.Config, source config.Source) {
+	dirs := []struct{ label, path string }{}
+	if targetDir, err := config.ExpandTargetDir(source.TargetDir); err == nil {
+		dirs = append(dirs, struct{ label, path string }{"target_dir", targetDir})
+	}
+	if strings.TrimSpace(source.StateFile) != "" {
+		if statePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile); err == nil {
+			dirs = append(dirs, struct{ label, path string }{"state directory", filepath.Dir(statePath)})
+		}
+	}
+	euid := geteuidFn()
+	for _, dir := range dirs {
+		run.addDir(dir.path)
+		if _, ok := run.warned[dir.path]; ok {
+			continue
+		}
+		info, err := os.Stat(dir.path)
+		if err != nil {
+			continue
+		}
+		owner, _, _, ok := fileOwnership(info)
+		if !ok || owner == euid || (run.chown && run.uid == owner) {
+			continue
+		}
+		run.warned[dir.path] = struct{}{}
+		runningAs := fmt.Sprintf("uid %d", euid)
+		if euid == 0 {
+			runningAs = "root"
+		}
+		s.emitOwnershipLine(source.ID, output.LevelWarn, fmt.Sprintf(
+			"%s %s is owned by uid %d but udl is running as %s; new files will be owned by %s and uid %d may not be able to modify them. Set defaults.file_ownership or run udl as uid %d",
+			dir.label, dir.path, owner, runningAs, runningAs, owner, owner,
+		))
+	}
+}
+
+// finishFileOwnership restores the umask and hands every file and directory
+// the run created or rewrote to the configured owner and group. Files the run
+// did not touch keep their ownership.
+func (s *Syncer) finishFileOwnership(run *fileOwnershipRun) {
+	run.restoreUmask()
+	if !run.chown {
+		return
+	}
+	changed, failed := 0, 0
+	var firstErr error
+	for _, dir := range run.dirs {
+		_ = filepath.WalkDir(dir, func(path string, d os.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			uid, gid, changedAt, ok := fileOwnership(info)
+			if !ok || changedAt.Before(run.since) {
+				return nil
+			}
+			if (run.uid < 0 || uid == run.uid) && (run.gid < 0 || gid == run.gid) {
+				return nil
+			}
+			if err := lchownFn(path, run.uid, run.gid); err != nil {
+				failed++
+				if firstErr == nil {
+					firstErr = err
+				}
+				return nil
+			}
+			changed++
+			return nil
+		})
+	}
+	if changed > 0 {
+		s.emitOwnershipLine("", output.LevelInfo, fmt.Sprintf("changed ownership of %d new file(s) to %s", changed, formatOwnershipIDs(run.uid, run.gid)))
+	}
+	if failed > 0 {
+		s.emitOwnershipLine("", output.LevelWarn, fmt.Sprintf("failed to change ownership of %d file(s) to %s: %v", failed, formatOwnershipIDs(run.uid, run.gid), firstErr))
+	}
+}
+
+func (r *fileOwnershipRun) addDir(path string) {
+	path = filepath.Clean(strings.TrimSpace(path))
+	if path == "." || path == "" {
+		return
+	}
+	if _, ok := r.seen[path]; ok {
+		return
+	}
+	// Nested directories are covered by an ancestor's walk.
+	for _, existing := range r.dirs {
+		if rel, err := filepath.Rel(existing, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			r.seen[path] = struct{}{}
+			return
+		}
+	}
+	r.seen[path] = struct{}{}
+	kept := r.dirs[:0]
+	for _, existing := range r.dirs {
+		if rel, err := filepath.Rel(path, existing); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		kept = append(kept, existing)
+	}
+	r.dirs = append(kept, path)
+}
+
+func formatOwnershipIDs(uid int, gid int) string {
+	owner, group := "-", "-"
+	if uid >= 0 {
+		owner = fmt.Sprint(uid)
+	}
+	if gid >= 0 {
+		group = fmt.Sprint(gid)
+	}
+	return owner + ":" + group
+}
+
+func (s *Syncer) emitOwnershipLine(sourceID string, level output.Level, line string) {
+	message := "[ownership] " + line
+	if sourceID != "" {
+		message = fmt.Sprintf("[%s] %s", sourceID, message)
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   message,
+	})
+}
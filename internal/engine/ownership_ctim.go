@@ -0,0 +1,12 @@
+//go:build !windows && !darwin && !freebsd && !netbsd
+
+package engine
+
+import (
+	"syscall"
+	"time"
+)
+
+func statChangeTime(stat *syscall.Stat_t) time.Time {
+	return time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec))
+}
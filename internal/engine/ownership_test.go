@@ -0,0 +1,112 @@
+//go:build !windows
+
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestObserveSourceOwnershipWarnsWhenTargetDirBelongsToAnotherUser(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "music")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	origEUID := geteuidFn
+	t.Cleanup(func() { geteuidFn = origEUID })
+	geteuidFn = func() int { return os.Geteuid() + 4242 }
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+	cfg := config.Config{Defaults: config.Defaults{StateDir: filepath.Join(tmp, "state")}}
+	source := config.Source{ID: "nas", Type: config.SourceTypeYouTube, TargetDir: targetDir}
+
+	run := syncer.beginFileOwnership(cfg, SyncOptions{})
+	syncer.observeSourceOwnership(run, cfg, source)
+	syncer.observeSourceOwnership(run, cfg, source)
+	got := out.String()
+	want := "[nas] [ownership] target_dir " + targetDir + " is owned by uid " + strconv.Itoa(os.Geteuid())
+	if !strings.Contains(got, want) || strings.Count(got, "[ownership]") != 1 {
+		t.Fatalf("expected one ownership warning containing %q, got %q", want, got)
+	}
+
+	out.Reset()
+	cfg.Defaults.FileOwnership = config.FileOwnership{Owner: strconv.Itoa(os.Geteuid())}
+	run = syncer.beginFileOwnership(cfg, SyncOptions{DryRun: true})
+	run.chown, run.uid = true, os.Geteuid()
+	syncer.observeSourceOwnership(run, cfg, source)
+	if strings.Contains(out.String(), "is owned by uid") {
+		t.Fatalf("expected no warning when file_ownership hands files to the owner, got %q", out.String())
+	}
+}
+
+func TestFinishFileOwnershipChownsFilesCreatedDuringTheRun(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, filepath.Join(targetDir, "Artist")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	origChown := lchownFn
+	t.Cleanup(func() { lchownFn = origChown })
+	chowned := []string{}
+	lchownFn = func(path string, uid int, gid int) error {
+		if uid != 4242 || gid != -1 {
+			t.Fatalf("expected chown to 4242:-1, got %d:%d", uid, gid)
+		}
+		rel, _ := filepath.Rel(tmp, path)
+		chowned = append(chowned, filepath.ToSlash(rel))
+		return nil
+	}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+	cfg := config.Config{Defaults: config.Defaults{
+		StateDir:      stateDir,
+		FileOwnership: config.FileOwnership{Owner: "4242"},
+	}}
+	source := config.Source{ID: "nas", Type: config.SourceTypeYouTube, TargetDir: targetDir, StateFile: "nas.sync"}
+
+	run := syncer.beginFileOwnership(cfg, SyncOptions{})
+	// Entries changed before the run started are left alone.
+	run.since = time.Now().Add(-time.Minute)
+	syncer.observeSourceOwnership(run, cfg, source)
+	if err := os.WriteFile(filepath.Join(targetDir, "Artist", "Track.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "history.jsonl"), []byte("{}\n"), 0o644); err != nil {
+		t.Fatalf("write history: %v", err)
+	}
+	syncer.finishFileOwnership(run)
+
+	sort.Strings(chowned)
+	if got := strings.Join(chowned, ","); got != "music,music/Artist,music/Artist/Track.mp3,state,state/history.jsonl" {
+		t.Fatalf("unexpected chowned paths: %s", got)
+	}
+	if !strings.Contains(out.String(), "[ownership] changed ownership of 5 new file(s) to 4242:-") {
+		t.Fatalf("expected ownership summary, got %q", out.String())
+	}
+
+	chowned = chowned[:0]
+	run = syncer.beginFileOwnership(cfg, SyncOptions{})
+	run.since = time.Now().Add(time.Hour)
+	syncer.observeSourceOwnership(run, cfg, source)
+	syncer.finishFileOwnership(run)
+	if len(chowned) != 0 {
+		t.Fatalf("expected files older than the run to keep their owner, got %v", chowned)
+	}
+}
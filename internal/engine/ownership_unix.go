@@ -0,0 +1,24 @@
+//go:build !windows
+
+package engine
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func setProcessUmask(mask int) (previous int, ok bool) {
+	return syscall.Umask(mask), true
+}
+
+// fileOwnership returns the owner, group, and inode change time of info. The
+// change time moves on every write, rename, and chmod, so unlike the
+// modification time it cannot be back-dated by a downloader.
+func fileOwnership(info os.FileInfo) (uid int, gid int, changed time.Time, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, time.Time{}, false
+	}
+	return int(stat.Uid), int(stat.Gid), statChangeTime(stat), true
+}
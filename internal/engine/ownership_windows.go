@@ -0,0 +1,18 @@
+//go:build windows
+
+package engine
+
+import (
+	"os"
+	"time"
+)
+
+// setProcessUmask and fileOwnership are not available on Windows, where
+// defaults.file_ownership is ignored.
+func setProcessUmask(mask int) (previous int, ok bool) {
+	return 0, false
+}
+
+func fileOwnership(info os.FileInfo) (uid int, gid int, changed time.Time, ok bool) {
+	return 0, 0, time.Time{}, false
+}
@@ -47,6 +47,7 @@ func (p *SCDLPlanProvider) Build(
 	sourceForExec.StateFile = stateFilePath
 	breakOnExisting := mode == SoundCloudModeBreak
 	sourceForExec.Sync.BreakOnExisting = &breakOnExisting
+	sourceForExec.DisableSyncMode = config.IsSoundCloudStationURL(source.URL)
 
 	enumerateStage, err := enumerateSoundCloudStage(ctx, soundCloudEnumerateStageInput{
 		Source: source,
@@ -57,7 +58,7 @@ func (p *SCDLPlanProvider) Build(
 	}
 	tracks := enumerateStage.Tracks
 
-	targetDir, err := config.ExpandPath(source.TargetDir)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
 	if err != nil {
 		return nil, fmt.Errorf("resolve target_dir: %w", err)
 	}
@@ -76,7 +77,9 @@ func (p *SCDLPlanProvider) Build(
 	}
 
 	cacheEnabled := source.Sync.LocalIndexCache != nil && *source.Sync.LocalIndexCache
-	needsLocalIndex := needsSoundCloudLocalIndex(tracks, stateStage.State, archiveStage.KnownIDs, targetDir)
+	// With the cache on, state paths are checked against the cached file
+	// list instead of one stat per entry, which is slow on network target_dirs.
+	needsLocalIndex := cacheEnabled || needsSoundCloudLocalIndex(tracks, stateStage.State, archiveStage.KnownIDs, targetDir)
 	localIndexStage, err := loadSoundCloudLocalIndexStage(soundCloudLocalIndexStageInput{
 		SourceID:  source.ID,
 		TargetDir: targetDir,
@@ -93,6 +96,7 @@ func (p *SCDLPlanProvider) Build(
 		State:          stateStage.State,
 		ArchiveKnownID: archiveStage.KnownIDs,
 		LocalIndex:     localIndexStage.Index,
+		LocalFiles:     localIndexStage.Files,
 		TargetDir:      targetDir,
 		Mode:           mode,
 	})
@@ -171,6 +175,13 @@ func (p *scdlSourcePlan) ApplySelection(manifest ExecutionManifest, opts PlanApp
 
 	sourceForExec := p.sourceForExec
 	sourceForExec.SelectedPlaylistIDs = orderedSelectedPlaylistIDs
+	if config.IsSoundCloudStationURL(sourceForExec.URL) {
+		stationTrackIDs := make([]string, 0, len(manifest.Execution))
+		for _, entry := range manifest.Execution {
+			stationTrackIDs = append(stationTrackIDs, entry.RemoteID)
+		}
+		sourceForExec = selectSoundCloudStationTracks(sourceForExec, stationTrackIDs)
+	}
 	out := sourcePlanExecution{
 		SourceForExec:           sourceForExec,
 		SourcePreflight:         &preflight,
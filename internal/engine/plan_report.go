@@ -0,0 +1,293 @@
+package engine
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// Plan report statuses, one per source.
+const (
+	PlanStatusPlanned     = "planned"
+	PlanStatusUpToDate    = "up_to_date"
+	PlanStatusNoPreflight = "no_preflight"
+	PlanStatusSkipped     = "skipped"
+	PlanStatusFailed      = "failed"
+)
+
+// PlanSkipNotPlanned is the skip reason of remote tracks preflight left out
+// without a more specific reason: tracks already synced and, in break mode,
+// tracks past the first known one.
+const PlanSkipNotPlanned = "not-planned"
+
+// PlanReport is the result of Syncer.Plan: what a sync of the selected
+// sources would download, skip, and prune.
+type PlanReport struct {
+	Sources []PlanReportSource `json:"sources"`
+	Summary PlanReportSummary  `json:"summary"`
+}
+
+// PlanReportSummary totals a PlanReport.
+type PlanReportSummary struct {
+	Sources  int `json:"sources"`
+	Failed   int `json:"failed"`
+	Download int `json:"download"`
+	Skip     int `json:"skip"`
+	Prune    int `json:"prune"`
+}
+
+// PlanReportSource is one source's part of a PlanReport. Download is in the
+// order the sync would fetch tracks; Skip and Prune follow the remote and
+// label order.
+type PlanReportSource struct {
+	SourceID    string          `json:"source_id"`
+	Type        string          `json:"type"`
+	Adapter     string          `json:"adapter"`
+	Status      string          `json:"status"`
+	Message     string          `json:"message,omitempty"`
+	RemoteTotal int             `json:"remote_total"`
+	Mode        string          `json:"mode,omitempty"`
+	Download    []PlanTrackLine `json:"download"`
+	Skip        []PlanTrackLine `json:"skip"`
+	Prune       []PlanTrackLine `json:"prune"`
+}
+
+// PlanTrackLine is one track of a PlanReportSource.
+type PlanTrackLine struct {
+	ID     string `json:"id"`
+	Label  string `json:"label,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+// Plan runs preflight for the selected sources as a dry-run sync and
+// reports, per track, what a real sync would do. No adapter runs, no prompt
+// is shown, and nothing under the library or state changes.
+func (s *Syncer) Plan(ctx context.Context, cfg config.Config, opts SyncOptions) (PlanReport, error) {
+	selected, err := selectSources(cfg.Sources, opts.SourceIDs)
+	if err != nil {
+		return PlanReport{}, err
+	}
+	opts.DryRun = true
+	opts.Plan = false
+	opts.ApplyPrune = false
+	opts.AllowPrompt = false
+	opts.TrackStatus = TrackStatusNone
+
+	recorder := newPlanRecorder(s.Emitter)
+	originalEmitter := s.Emitter
+	s.Emitter = recorder
+	s.plan = recorder
+	defer func() {
+		s.Emitter = originalEmitter
+		s.plan = nil
+	}()
+
+	_, err = s.Sync(ctx, cfg, opts)
+	return recorder.report(selected), err
+}
+
+// planRecorder collects a Plan run: the planners note remote listings,
+// planned ids and prune candidates through the Syncer, and skip lines and
+// source outcomes are read off the events.
+type planRecorder struct {
+	next    output.EventEmitter
+	mu      sync.Mutex
+	sources map[string]*planRecorderSource
+}
+
+type planRecorderSource struct {
+	remote      []playlistTrack
+	remoteKnown bool
+	planned     []string
+	plannedSeen bool
+	skipReasons map[string]string
+	prune       []PruneCandidate
+	mode        string
+	status      string
+	message     string
+}
+
+func newPlanRecorder(next output.EventEmitter) *planRecorder {
+	return &planRecorder{next: next, sources: map[string]*planRecorderSource{}}
+}
+
+func (r *planRecorder) sourceLocked(sourceID string) *planRecorderSource {
+	entry, ok := r.sources[sourceID]
+	if !ok {
+		entry = &planRecorderSource{skipReasons: map[string]string{}}
+		r.sources[sourceID] = entry
+	}
+	return entry
+}
+
+func (r *planRecorder) Emit(event output.Event) error {
+	r.observe(event)
+	if r.next == nil {
+		return nil
+	}
+	return r.next.Emit(event)
+}
+
+func (r *planRecorder) observe(event output.Event) {
+	sourceID := strings.TrimSpace(event.SourceID)
+	if sourceID == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.sourceLocked(sourceID)
+	if mode, ok := event.Details["mode"].(string); ok && entry.mode == "" {
+		entry.mode = mode
+	}
+	switch event.Event {
+	case output.EventSourcePreflight:
+		line := strings.TrimPrefix(event.Message, "["+sourceID+"] ")
+		if !strings.HasPrefix(line, "[skip] ") {
+			return
+		}
+		id, _, _ := strings.Cut(strings.TrimPrefix(line, "[skip] "), " ")
+		if reason := lastPreflightSkipReason(line); id != "" && id != "..." && reason != "" {
+			entry.skipReasons[id] = reason
+		}
+	case output.EventSourceFinished:
+		if entry.status == PlanStatusFailed {
+			return
+		}
+		entry.status = ""
+		if skipped, _ := event.Details["skipped"].(bool); skipped {
+			entry.status = PlanStatusSkipped
+		}
+		if event.Level == output.LevelInfo || entry.status == PlanStatusSkipped {
+			entry.message = event.Message
+		}
+	case output.EventSourceFailed:
+		entry.status = PlanStatusFailed
+		entry.message = event.Message
+	}
+}
+
+func (r *planRecorder) noteRemote(sourceID string, tracks []playlistTrack) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.sourceLocked(sourceID)
+	entry.remote = append([]playlistTrack{}, tracks...)
+	entry.remoteKnown = true
+}
+
+func (r *planRecorder) notePlanned(sourceID string, ids []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.sourceLocked(sourceID)
+	entry.planned = append([]string{}, ids...)
+	entry.plannedSeen = true
+}
+
+func (r *planRecorder) notePrune(sourceID string, candidates []PruneCandidate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := r.sourceLocked(sourceID)
+	entry.prune = append([]PruneCandidate{}, candidates...)
+}
+
+// notePlanPrune records a Plan run's prune candidates for a source.
+func (s *Syncer) notePlanPrune(sourceID string, candidates []PruneCandidate) {
+	if s.plan == nil {
+		return
+	}
+	s.plan.notePrune(sourceID, candidates)
+}
+
+func (r *planRecorder) report(selected []config.Source) PlanReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := PlanReport{Sources: make([]PlanReportSource, 0, len(selected))}
+	for _, source := range selected {
+		plan := PlanReportSource{
+			SourceID: source.ID,
+			Type:     string(source.Type),
+			Adapter:  source.Adapter.Kind,
+			Download: []PlanTrackLine{},
+			Skip:     []PlanTrackLine{},
+			Prune:    []PlanTrackLine{},
+		}
+		entry, ok := r.sources[source.ID]
+		switch {
+		case !source.Enabled:
+			plan.Status = PlanStatusSkipped
+			plan.Message = "disabled"
+		case !ok:
+			plan.Status = PlanStatusSkipped
+		default:
+			plan.Status = entry.status
+			plan.Message = entry.message
+			plan.Mode = entry.mode
+			fillSourcePlanTracks(&plan, source, entry)
+		}
+		if plan.Status == "" {
+			switch {
+			case !entry.plannedSeen:
+				plan.Status = PlanStatusNoPreflight
+				plan.Message = "no per-track preflight for adapter " + source.Adapter.Kind + "; a sync runs it over the whole link"
+			case len(plan.Download) > 0:
+				plan.Status = PlanStatusPlanned
+			default:
+				plan.Status = PlanStatusUpToDate
+			}
+		}
+		report.Summary.Sources++
+		if plan.Status == PlanStatusFailed {
+			report.Summary.Failed++
+		}
+		report.Summary.Download += len(plan.Download)
+		report.Summary.Skip += len(plan.Skip)
+		report.Summary.Prune += len(plan.Prune)
+		report.Sources = append(report.Sources, plan)
+	}
+	return report
+}
+
+// fillSourcePlanTracks splits the source's remote listing into the tracks
+// the sync would download and those it would skip. A monitor source
+// downloads nothing, so its planned tracks are skips too.
+func fillSourcePlanTracks(plan *PlanReportSource, source config.Source, entry *planRecorderSource) {
+	labels := make(map[string]string, len(entry.remote))
+	for _, track := range entry.remote {
+		labels[track.ID] = track.Label
+	}
+	plan.RemoteTotal = len(entry.remote)
+	planned := make(map[string]struct{}, len(entry.planned))
+	for _, id := range entry.planned {
+		planned[id] = struct{}{}
+		if source.Monitor {
+			continue
+		}
+		plan.Download = append(plan.Download, PlanTrackLine{ID: id, Label: labels[id]})
+	}
+	if plan.Status != PlanStatusFailed && entry.remoteKnown {
+		for _, track := range entry.remote {
+			_, isPlanned := planned[track.ID]
+			reason := entry.skipReasons[track.ID]
+			switch {
+			case isPlanned && source.Monitor:
+				reason = "monitor"
+			case isPlanned:
+				continue
+			case reason == "":
+				reason = PlanSkipNotPlanned
+			}
+			plan.Skip = append(plan.Skip, PlanTrackLine{ID: track.ID, Label: track.Label, Reason: reason})
+		}
+	}
+	for _, candidate := range entry.prune {
+		plan.Prune = append(plan.Prune, PlanTrackLine{
+			ID:     candidate.TrackID,
+			Label:  candidate.Label,
+			Reason: "removed-from-remote",
+			Path:   candidate.LocalPath,
+		})
+	}
+}
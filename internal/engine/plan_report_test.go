@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSyncerPlanReportsDownloadsSkipsAndPrunesWithoutRunningAdapters(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	videoDir := filepath.Join(tmp, "videos")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{filepath.Join(targetDir, "Daft Punk"), videoDir, stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"Aerodynamic.mp3", "One More Time.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, "Daft Punk", name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "deezer-mix.sync.deezer")
+	stateBody := deezerStateHeader + "\n" +
+		"3135560\ttitle=Daft+Punk+-+Aerodynamic\tpath=Daft+Punk%2FAerodynamic.mp3\n" +
+		"3135558\ttitle=Daft+Punk+-+One+More+Time\tpath=Daft+Punk%2FOne+More+Time.mp3\n"
+	if err := os.WriteFile(statePath, []byte(stateBody), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "deezer-mix",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/en/playlist/908622995",
+				StateFile: "deezer-mix.sync.deezer",
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+				Sync:      config.SyncPolicy{Prune: boolPtrSyncer(true)},
+			},
+			{
+				ID:        "videos",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   true,
+				TargetDir: videoDir,
+				URL:       "https://www.youtube.com/playlist?list=PL1",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+			},
+			{
+				ID:        "off",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   false,
+				TargetDir: videoDir,
+				URL:       "https://www.youtube.com/playlist?list=PL2",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+			},
+		},
+	}
+
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateDeezerTracksFn
+	t.Cleanup(func() {
+		resolveDeemixARLFn = origResolveARL
+		enumerateDeezerTracksFn = origEnumerate
+	})
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateDeezerTracksFn = func(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+		return []deezerRemoteTrack{
+			{ID: "3135561", Title: "Digital Love", Artist: "Daft Punk", Readable: true},
+			{ID: "3135562", Title: "Veridis Quo", Artist: "Daft Punk", Readable: false},
+			{ID: "3135560", Title: "Aerodynamic", Artist: "Daft Punk", Readable: true},
+		}, nil
+	}
+
+	runner := &sequenceRunner{}
+	var logs bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixAdapter{}, "ytdlp": fakeAdapter{}},
+		runner,
+		output.NewHumanEmitter(&logs, &logs, false, true),
+	)
+	report, err := syncer.Plan(context.Background(), cfg, SyncOptions{ApplyPrune: true})
+	if err != nil {
+		t.Fatalf("plan: %v\n%s", err, logs.String())
+	}
+	if len(runner.specs) != 0 {
+		t.Fatalf("expected plan to run no adapter, got %d run(s)", len(runner.specs))
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Daft Punk", "One More Time.mp3")); err != nil {
+		t.Fatalf("expected plan to leave prune candidates in place: %v", err)
+	}
+	if len(report.Sources) != 3 {
+		t.Fatalf("expected three sources in the report, got %+v", report.Sources)
+	}
+
+	deezer := report.Sources[0]
+	if deezer.Status != PlanStatusPlanned || deezer.RemoteTotal != 3 {
+		t.Fatalf("unexpected deezer plan: %+v\n%s", deezer, logs.String())
+	}
+	if len(deezer.Download) != 1 || deezer.Download[0].ID != "3135561" || deezer.Download[0].Label != "Daft Punk - Digital Love" {
+		t.Fatalf("unexpected downloads: %+v", deezer.Download)
+	}
+	wantSkips := map[string]string{"3135562": "unavailable-on-deezer", "3135560": PlanSkipNotPlanned}
+	if len(deezer.Skip) != len(wantSkips) {
+		t.Fatalf("unexpected skips: %+v", deezer.Skip)
+	}
+	for _, skip := range deezer.Skip {
+		if wantSkips[skip.ID] != skip.Reason {
+			t.Fatalf("unexpected skip %+v", skip)
+		}
+	}
+	if len(deezer.Prune) != 1 || deezer.Prune[0].ID != "3135558" || deezer.Prune[0].Path != filepath.Join(targetDir, "Daft Punk", "One More Time.mp3") {
+		t.Fatalf("unexpected prunes: %+v", deezer.Prune)
+	}
+
+	if report.Sources[1].Status != PlanStatusNoPreflight {
+		t.Fatalf("expected ytdlp source without preflight, got %+v", report.Sources[1])
+	}
+	if report.Sources[2].Status != PlanStatusSkipped || report.Sources[2].Message != "disabled" {
+		t.Fatalf("expected disabled source to be skipped, got %+v", report.Sources[2])
+	}
+	if report.Summary != (PlanReportSummary{Sources: 3, Download: 1, Skip: 2, Prune: 1}) {
+		t.Fatalf("unexpected summary: %+v", report.Summary)
+	}
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// playlistTrack is one remote track in playlist order, as noted during
+// planning for sync.playlist_file.
+type playlistTrack struct {
+	ID         string
+	Label      string
+	URL        string
+	DurationMS int64
+}
+
+func spotifyPlaylistTracks(tracks []spotifyRemoteTrack) []playlistTrack {
+	out := make([]playlistTrack, 0, len(tracks))
+	for _, track := range tracks {
+		out = append(out, playlistTrack{ID: track.ID, Label: spotifyTrackLocalTitle(track), URL: track.URL, DurationMS: track.DurationMS})
+	}
+	return out
+}
+
+func soundCloudPlaylistTracks(tracks []soundCloudRemoteTrack) []playlistTrack {
+	out := make([]playlistTrack, 0, len(tracks))
+	for _, track := range tracks {
+		label := strings.TrimSpace(track.Title)
+		if label == "" {
+			label = track.ID
+		}
+		out = append(out, playlistTrack{ID: track.ID, Label: label, URL: track.URL, DurationMS: track.DurationMS})
+	}
+	return out
+}
+
+// noteRemoteTracks keeps a source's enumerated remote order for the
+// playlist written, the prune run, and the source.json track count after its
+// sync. Only sources with sync.playlist_file, sync.prune, or sync.source_info
+// are kept.
+func (s *Syncer) noteRemoteTracks(source config.Source, tracks []playlistTrack) {
+	if s.plan != nil {
+		s.plan.noteRemote(source.ID, tracks)
+	}
+	if s.run == nil || (source.Sync.PlaylistFile == "" && !pruneEnabled(source) && !sourceInfoEnabled(source)) {
+		return
+	}
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	s.run.remoteTracks[source.ID] = tracks
+}
+
+func (s *Syncer) remoteTracksFor(sourceID string) ([]playlistTrack, bool) {
+	if s.run == nil {
+		return nil, false
+	}
+	s.run.mu.Lock()
+	defer s.run.mu.Unlock()
+	tracks, ok := s.run.remoteTracks[sourceID]
+	return tracks, ok
+}
+
+// writeSourcePlaylist rewrites <target_dir>/<sync.playlist_file> with every
+// remote track that has a file on disk, in remote order, including tracks
+// downloaded by earlier runs. Paths come from the state file, else from a
+// local file whose name matches the track title. Entries are relative to the
+// playlist's folder. The file is left untouched when nothing changed, so DJ
+// software does not rescan it needlessly.
+func (s *Syncer) writeSourcePlaylist(cfg config.Config, source config.Source) {
+	if source.Sync.PlaylistFile == "" {
+		return
+	}
+	tracks, ok := s.remoteTracksFor(source.ID)
+	if !ok {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] playlist not updated: no remote listing this run (preflight disabled or skipped)", source.ID),
+		})
+		return
+	}
+	playlistPath, written, missing, err := writeSourcePlaylistFile(cfg, source, tracks)
+	if err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] unable to write playlist: %v", source.ID, err),
+		})
+		return
+	}
+	message := fmt.Sprintf("[%s] playlist: %s (%d tracks)", source.ID, playlistPath, written)
+	if missing > 0 {
+		message = fmt.Sprintf("[%s] playlist: %s (%d tracks, %d not on disk)", source.ID, playlistPath, written, missing)
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   message,
+		Details: map[string]any{
+			"playlist_file": playlistPath,
+			"track_count":   written,
+			"missing_count": missing,
+		},
+	})
+}
+
+func writeSourcePlaylistFile(cfg config.Config, source config.Source, tracks []playlistTrack) (string, int, int, error) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("resolve target_dir: %w", err)
+	}
+	playlistPath := filepath.Join(targetDir, source.Sync.PlaylistFile)
+	stateFilePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("resolve state_file: %w", err)
+	}
+	statePaths, err := stateLocalPaths(source, stateFilePath)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	var byTitle map[string]string
+
+	var body bytes.Buffer
+	body.WriteString("#EXTM3U\n")
+	written, missing := 0, 0
+	for _, track := range tracks {
+		localPath := ""
+		if candidate := statePaths[track.ID]; stateEntryHasLocalFile(candidate, targetDir) {
+			localPath = filepath.FromSlash(candidate)
+			if !filepath.IsAbs(localPath) {
+				localPath = filepath.Join(targetDir, localPath)
+			}
+		} else {
+			if byTitle == nil {
+				byTitle = localMediaPathsByTitle(targetDir)
+			}
+			localPath = byTitle[normalizeTrackKey(track.Label)]
+		}
+		if localPath == "" {
+			missing++
+			continue
+		}
+		entry, relErr := filepath.Rel(filepath.Dir(playlistPath), localPath)
+		if relErr != nil {
+			entry = localPath
+		}
+		seconds := int64(-1)
+		if track.DurationMS > 0 {
+			seconds = (track.DurationMS + 500) / 1000
+		}
+		label := strings.NewReplacer("\n", " ", "\r", " ").Replace(track.Label)
+		fmt.Fprintf(&body, "#EXTINF:%d,%s\n%s\n", seconds, label, filepath.ToSlash(entry))
+		written++
+	}
+
+	if existing, readErr := os.ReadFile(playlistPath); readErr == nil && bytes.Equal(existing, body.Bytes()) {
+		return playlistPath, written, missing, nil
+	}
+	if err := writeFileAtomically(playlistPath, ".udl-playlist-*", body.Bytes()); err != nil {
+		return "", 0, 0, err
+	}
+	return playlistPath, written, missing, nil
+}
+
+// stateLocalPaths maps track ids to the file paths the source's state file
+// recorded for them.
+func stateLocalPaths(source config.Source, stateFilePath string) (map[string]string, error) {
+	paths := map[string]string{}
+	if source.Type == config.SourceTypeSoundCloud {
+		state, err := parseSoundCloudSyncState(stateFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("parse soundcloud sync state file: %w", err)
+		}
+		for id, entry := range state.ByID {
+			paths[id] = strings.TrimSpace(entry.FilePath)
+		}
+		return paths, nil
+	}
+	state, err := parseTrackStateForSource(source.Type, stateFilePath)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s sync state file: %w", source.Type, err)
+	}
+	for id, entry := range state.Entries {
+		paths[id] = strings.TrimSpace(entry.LocalPath)
+	}
+	return paths, nil
+}
+
+// localMediaPathsByTitle maps normalized file stems in targetDir to the
+// first media file with that stem.
+func localMediaPathsByTitle(targetDir string) map[string]string {
+	byTitle := map[string]string{}
+	files, err := snapshotMediaFiles(targetDir)
+	if err != nil {
+		return byTitle
+	}
+	rels := make([]string, 0, len(files))
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	for _, rel := range remoteTargetFiles(targetDir) {
+		if isMediaExt(strings.ToLower(filepath.Ext(rel))) {
+			rels = append(rels, filepath.FromSlash(rel))
+		}
+	}
+	for _, rel := range rels {
+		name := filepath.Base(rel)
+		key := normalizeTrackKey(strings.TrimSuffix(name, filepath.Ext(name)))
+		if key == "" {
+			continue
+		}
+		if current, ok := byTitle[key]; !ok || filepath.Join(targetDir, rel) < current {
+			byTitle[key] = filepath.Join(targetDir, rel)
+		}
+	}
+	return byTitle
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSyncerWritesPlaylistFileInRemoteOrder(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{filepath.Join(targetDir, "Daft Punk"), stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"Daft Punk/Aerodynamic.mp3", "Daft Punk - One More Time.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, filepath.FromSlash(name)), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "deezer-mix.sync.deezer")
+	stateBody := deezerStateHeader + "\n3135560\ttitle=Daft+Punk+-+Aerodynamic\tpath=Daft+Punk%2FAerodynamic.mp3\n"
+	if err := os.WriteFile(statePath, []byte(stateBody), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "deezer-mix",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/en/playlist/908622995",
+				StateFile: "deezer-mix.sync.deezer",
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+				Sync:      config.SyncPolicy{PlaylistFile: "playlists/mix.m3u8"},
+			},
+		},
+	}
+
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateDeezerTracksFn
+	t.Cleanup(func() {
+		resolveDeemixARLFn = origResolveARL
+		enumerateDeezerTracksFn = origEnumerate
+	})
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateDeezerTracksFn = func(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+		return []deezerRemoteTrack{
+			{ID: "3135560", Title: "Aerodynamic", Artist: "Daft Punk", Readable: true, DurationMS: 212400},
+			{ID: "3135556", Title: "Harder, Better, Faster, Stronger", Artist: "Daft Punk", Readable: true},
+			{ID: "3135558", Title: "One More Time", Artist: "Daft Punk", Readable: true, DurationMS: 320000},
+		}, nil
+	}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixAdapter{}},
+		&sequenceRunner{results: []ExecResult{{ExitCode: 0}, {ExitCode: 0}}},
+		output.NewHumanEmitter(&out, &out, false, true),
+	)
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	playlistPath := filepath.Join(targetDir, "playlists", "mix.m3u8")
+	raw, err := os.ReadFile(playlistPath)
+	if err != nil {
+		t.Fatalf("read playlist: %v\n%s", err, out.String())
+	}
+	want := strings.Join([]string{
+		"#EXTM3U",
+		"#EXTINF:212,Daft Punk - Aerodynamic",
+		"../Daft Punk/Aerodynamic.mp3",
+		"#EXTINF:320,Daft Punk - One More Time",
+		"../Daft Punk - One More Time.mp3",
+		"",
+	}, "\n")
+	if string(raw) != want {
+		t.Fatalf("unexpected playlist\n got %q\nwant %q", string(raw), want)
+	}
+	if !strings.Contains(out.String(), "(2 tracks, 1 not on disk)") {
+		t.Fatalf("expected playlist summary in output, got:\n%s", out.String())
+	}
+
+	info, err := os.Stat(playlistPath)
+	if err != nil {
+		t.Fatalf("stat playlist: %v", err)
+	}
+	if _, _, _, err := writeSourcePlaylistFile(cfg, cfg.Sources[0], []playlistTrack{
+		{ID: "3135560", Label: "Daft Punk - Aerodynamic", DurationMS: 212400},
+		{ID: "3135558", Label: "Daft Punk - One More Time", DurationMS: 320000},
+	}); err != nil {
+		t.Fatalf("rewrite playlist: %v", err)
+	}
+	again, err := os.Stat(playlistPath)
+	if err != nil {
+		t.Fatalf("stat playlist: %v", err)
+	}
+	if !os.SameFile(info, again) {
+		t.Fatalf("expected unchanged playlist to be left in place")
+	}
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/fileops"
+)
+
+// postProcessThrottle bounds how many post-processing commands run at once
+// and the scheduling priority they run at.
+type postProcessThrottle struct {
+	slots    chan struct{}
+	priority string
+}
+
+var (
+	postProcessMu         sync.Mutex
+	postProcess           = newPostProcessThrottle(config.PostProcessing{})
+	postProcessLookPathFn = exec.LookPath
+)
+
+func newPostProcessThrottle(settings config.PostProcessing) *postProcessThrottle {
+	limit := settings.MaxConcurrent
+	if limit < 1 {
+		limit = 1
+	}
+	priority := strings.TrimSpace(settings.Priority)
+	if priority == "" {
+		priority = config.PostProcessingPriorityLow
+	}
+	return &postProcessThrottle{slots: make(chan struct{}, limit), priority: priority}
+}
+
+// ConfigurePostProcessing applies the post_processing config to every later
+// RunPostProcess call in this process. Commands already holding a slot keep
+// it; the new limit applies to commands started afterwards.
+func ConfigurePostProcessing(settings config.PostProcessing) {
+	postProcessMu.Lock()
+	defer postProcessMu.Unlock()
+	postProcess = newPostProcessThrottle(settings)
+}
+
+// RunPostProcess runs a post-processing tool such as ffmpeg once a slot is
+// free, at the configured priority, and returns its combined output. Waiting
+// for a slot is abandoned when ctx is canceled.
+func RunPostProcess(ctx context.Context, name string, args ...string) ([]byte, error) {
+	postProcessMu.Lock()
+	throttle := postProcess
+	postProcessMu.Unlock()
+
+	select {
+	case throttle.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-throttle.slots }()
+
+	bin, fullArgs := postProcessCommandLine(throttle.priority, runtime.GOOS, name, args)
+	return exec.CommandContext(ctx, bin, fullArgs...).CombinedOutput()
+}
+
+// remuxWithTags remuxes path through ffmpeg with extraArgs added to a copy of
+// its streams and tags, reading extraInputs as further inputs after path, and
+// replaces path with the result.
+func remuxWithTags(ctx context.Context, path string, extraArgs []string, extraInputs ...string) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".udl-remux-*"+filepath.Ext(path))
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	_ = tempFile.Close()
+
+	if out, err := RunPostProcess(ctx, "ffmpeg", remuxArgs(path, tempPath, extraArgs, extraInputs)...); err != nil {
+		_ = os.Remove(tempPath)
+		return postProcessError(err, out)
+	}
+	return fileops.ReplaceFileSafely(tempPath, path)
+}
+
+func remuxArgs(path string, tempPath string, extraArgs []string, extraInputs []string) []string {
+	args := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", path}
+	for _, input := range extraInputs {
+		args = append(args, "-i", input)
+	}
+	args = append(args, "-map", "0", "-codec", "copy", "-map_metadata", "0")
+	args = append(args, extraArgs...)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		// Keep the ID3 version deemix and scdl write; ffmpeg defaults to v2.4.
+		args = append(args, "-id3v2_version", "3")
+	case ".m4a", ".mp4":
+		// The mp4 muxer drops tags it has no atom for unless told to keep
+		// them as freeform metadata.
+		args = append(args, "-movflags", "use_metadata_tags")
+	}
+	return append(args, tempPath)
+}
+
+// postProcessCommandLine prefixes the command with the OS tools that lower its
+// CPU and I/O priority. A missing wrapper is skipped rather than failing the
+// job, so the command still runs at normal priority.
+func postProcessCommandLine(priority string, goos string, name string, args []string) (string, []string) {
+	wrappers := [][]string{}
+	switch priority {
+	case config.PostProcessingPriorityLow:
+		switch goos {
+		case "linux":
+			wrappers = [][]string{{"ionice", "-c2", "-n7"}, {"nice", "-n", "10"}}
+		case "darwin", "freebsd", "openbsd", "netbsd":
+			wrappers = [][]string{{"nice", "-n", "10"}}
+		}
+	case config.PostProcessingPriorityIdle:
+		switch goos {
+		case "linux":
+			wrappers = [][]string{{"ionice", "-c3"}, {"nice", "-n", "19"}}
+		case "darwin":
+			// taskpolicy -b applies the background policy, which throttles both
+			// CPU and disk I/O.
+			wrappers = [][]string{{"taskpolicy", "-b"}}
+		case "freebsd", "openbsd", "netbsd":
+			wrappers = [][]string{{"nice", "-n", "19"}}
+		}
+	}
+
+	line := []string{}
+	for _, wrapper := range wrappers {
+		if _, err := postProcessLookPathFn(wrapper[0]); err != nil {
+			continue
+		}
+		line = append(line, wrapper...)
+	}
+	if len(line) == 0 {
+		return name, args
+	}
+	line = append(line, name)
+	line = append(line, args...)
+	return line[0], line[1:]
+}
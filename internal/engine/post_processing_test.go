@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestPostProcessCommandLineWrapsByPriority(t *testing.T) {
+	original := postProcessLookPathFn
+	t.Cleanup(func() { postProcessLookPathFn = original })
+	postProcessLookPathFn = func(name string) (string, error) { return "/usr/bin/" + name, nil }
+
+	cases := []struct {
+		priority string
+		goos     string
+		want     string
+	}{
+		{config.PostProcessingPriorityNormal, "linux", "ffmpeg -i in.m4a"},
+		{config.PostProcessingPriorityLow, "linux", "ionice -c2 -n7 nice -n 10 ffmpeg -i in.m4a"},
+		{config.PostProcessingPriorityIdle, "linux", "ionice -c3 nice -n 19 ffmpeg -i in.m4a"},
+		{config.PostProcessingPriorityLow, "darwin", "nice -n 10 ffmpeg -i in.m4a"},
+		{config.PostProcessingPriorityIdle, "darwin", "taskpolicy -b ffmpeg -i in.m4a"},
+		{config.PostProcessingPriorityIdle, "windows", "ffmpeg -i in.m4a"},
+	}
+	for _, tc := range cases {
+		bin, args := postProcessCommandLine(tc.priority, tc.goos, "ffmpeg", []string{"-i", "in.m4a"})
+		if got := strings.Join(append([]string{bin}, args...), " "); got != tc.want {
+			t.Fatalf("%s/%s: got %q want %q", tc.priority, tc.goos, got, tc.want)
+		}
+	}
+
+	postProcessLookPathFn = func(name string) (string, error) {
+		if name == "ionice" {
+			return "", exec.ErrNotFound
+		}
+		return "/usr/bin/" + name, nil
+	}
+	bin, args := postProcessCommandLine(config.PostProcessingPriorityLow, "linux", "ffmpeg", nil)
+	if got := strings.Join(append([]string{bin}, args...), " "); got != "nice -n 10 ffmpeg" {
+		t.Fatalf("expected missing ionice to be skipped, got %q", got)
+	}
+}
+
+func TestRunPostProcessWaitsForASlot(t *testing.T) {
+	t.Cleanup(func() { ConfigurePostProcessing(config.PostProcessing{}) })
+	ConfigurePostProcessing(config.PostProcessing{MaxConcurrent: 1, Priority: config.PostProcessingPriorityNormal})
+
+	postProcessMu.Lock()
+	throttle := postProcess
+	postProcessMu.Unlock()
+	throttle.slots <- struct{}{}
+	defer func() { <-throttle.slots }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunPostProcess(ctx, "ffmpeg", "-version"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled wait while the only slot is taken, got %v", err)
+	}
+}
+
+func TestRemuxArgsAddContainerFlagsAfterTheCopy(t *testing.T) {
+	base := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", "/music/a.mp3", "-i", "/tmp/cover.jpg", "-map", "0", "-codec", "copy", "-map_metadata", "0", "-map", "1:0"}
+	if got, want := remuxArgs("/music/a.mp3", "/music/.tmp.mp3", []string{"-map", "1:0"}, []string{"/tmp/cover.jpg"}), append(base, "-id3v2_version", "3", "/music/.tmp.mp3"); !reflect.DeepEqual(got, want) {
+		t.Fatalf("mp3 args:\n%v\nwant:\n%v", got, want)
+	}
+	got := remuxArgs("/music/a.M4A", "/music/.tmp.M4A", []string{"-metadata", "lyrics=la"}, nil)
+	want := []string{"-hide_banner", "-loglevel", "error", "-y", "-i", "/music/a.M4A", "-map", "0", "-codec", "copy", "-map_metadata", "0", "-metadata", "lyrics=la", "-movflags", "use_metadata_tags", "/music/.tmp.M4A"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("m4a args:\n%v\nwant:\n%v", got, want)
+	}
+}
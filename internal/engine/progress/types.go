@@ -1,5 +1,7 @@
 package progress
 
+import "time"
+
 type TrackEventKind string
 
 const (
@@ -10,6 +12,16 @@ const (
 	TrackFail     TrackEventKind = "track_fail"
 )
 
+// Stages reported on TrackProgress events by flows that do not stream a
+// percentage, such as the free-download browser handoff.
+const (
+	StageGateOpened        = "gate_opened"
+	StageWaitingForBrowser = "waiting_for_browser"
+	StageDetectedFile      = "detected_file"
+	StageMoved             = "moved"
+	StageTagged            = "tagged"
+)
+
 type TrackEvent struct {
 	SourceID    string
 	AdapterKind string
@@ -20,4 +32,7 @@ type TrackEvent struct {
 	Percent     float64
 	Reason      string
 	Kind        TrackEventKind
+	Stage       string
+	Elapsed     time.Duration
+	Idle        time.Duration
 }
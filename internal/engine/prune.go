@@ -0,0 +1,277 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// PruneCandidate is a track in a source's state that is no longer in the
+// remote playlist. LocalPath is empty when the state has no file on disk
+// for it.
+type PruneCandidate struct {
+	TrackID   string `json:"track_id"`
+	Label     string `json:"label,omitempty"`
+	LocalPath string `json:"local_path,omitempty"`
+}
+
+func pruneEnabled(source config.Source) bool {
+	return source.Sync.Prune != nil && *source.Sync.Prune
+}
+
+// pruneRemovedTracks mirrors remote removals for a sync.prune source: state
+// entries whose track left the remote playlist have their local file moved
+// to <state_dir>/trash/<source>/<run time>/ and are dropped from the state
+// file (and, for SoundCloud, from the scdl archive, so a track added back is
+// downloaded again). Nothing changes in dry-run, or until the user confirms
+// or passes --apply. An empty remote listing never prunes, since it is more
+// likely an API failure than an emptied playlist.
+func (s *Syncer) pruneRemovedTracks(cfg config.Config, source config.Source, opts SyncOptions) {
+	if !pruneEnabled(source) {
+		return
+	}
+	tracks, ok := s.remoteTracksFor(source.ID)
+	if !ok {
+		s.emitPruneLine(source.ID, output.LevelWarn, "skipped: no remote listing this run (preflight disabled or skipped)")
+		return
+	}
+	if len(tracks) == 0 {
+		s.emitPruneLine(source.ID, output.LevelWarn, "skipped: the remote listing is empty")
+		return
+	}
+	stateFilePath, candidates, err := findPruneCandidates(cfg, source, tracks)
+	if err != nil {
+		s.emitPruneLine(source.ID, output.LevelWarn, fmt.Sprintf("skipped: %v", err))
+		return
+	}
+	s.notePlanPrune(source.ID, candidates)
+	if len(candidates) == 0 {
+		return
+	}
+	for _, candidate := range candidates {
+		where := candidate.LocalPath
+		if where == "" {
+			where = "no local file"
+		}
+		s.emitPruneLine(source.ID, output.LevelInfo, fmt.Sprintf("%s (%s) removed from remote: %s", candidate.TrackID, candidate.Label, where))
+	}
+	if opts.DryRun {
+		s.emitPruneLine(source.ID, output.LevelInfo, fmt.Sprintf("dry-run: would move %d removed track(s) to trash", len(candidates)))
+		return
+	}
+	if !opts.ApplyPrune {
+		confirmed := false
+		if opts.AllowPrompt && opts.PromptOnPrune != nil {
+			answer, promptErr := opts.PromptOnPrune(source.ID, candidates)
+			if promptErr != nil {
+				s.emitPruneLine(source.ID, output.LevelWarn, fmt.Sprintf("unable to read prune confirmation: %v", promptErr))
+			}
+			confirmed = answer && promptErr == nil
+		}
+		if !confirmed {
+			s.emitPruneLine(source.ID, output.LevelInfo, fmt.Sprintf("%d removed track(s) kept; rerun with --apply to move them to trash", len(candidates)))
+			return
+		}
+	}
+
+	trashDir, err := pruneTrashDir(cfg, source, s.Now().Format("20060102-150405"))
+	if err != nil {
+		s.emitPruneLine(source.ID, output.LevelWarn, fmt.Sprintf("skipped: %v", err))
+		return
+	}
+	targetDir, _ := config.ExpandTargetDir(source.TargetDir)
+	removed := map[string]struct{}{}
+	moved := 0
+	for _, candidate := range candidates {
+		if candidate.LocalPath != "" {
+			destination := filepath.Join(trashDir, pruneTrashRelPath(targetDir, candidate.LocalPath))
+			if err := moveFile(candidate.LocalPath, destination); err != nil {
+				s.emitPruneLine(source.ID, output.LevelWarn, fmt.Sprintf("%s kept in state: unable to move %s: %v", candidate.TrackID, candidate.LocalPath, err))
+				continue
+			}
+			moved++
+		}
+		removed[candidate.TrackID] = struct{}{}
+	}
+	if err := removeStateEntries(stateFilePath, source.Type, removed); err != nil {
+		s.emitPruneLine(source.ID, output.LevelWarn, fmt.Sprintf("unable to update state file: %v", err))
+		return
+	}
+	if source.Type == config.SourceTypeSoundCloud {
+		if archivePath, archiveErr := resolveSoundCloudArchivePath(source, cfg.Defaults); archiveErr == nil {
+			if err := removeStateEntries(archivePath, source.Type, removed); err != nil {
+				s.emitPruneLine(source.ID, output.LevelWarn, fmt.Sprintf("unable to update archive file: %v", err))
+			}
+		}
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] [prune] moved %d file(s) to %s; dropped %d state entries", source.ID, moved, trashDir, len(removed)),
+		Details: map[string]any{
+			"trash_dir":     trashDir,
+			"moved_count":   moved,
+			"removed_count": len(removed),
+		},
+	})
+}
+
+// findPruneCandidates lists the source's state entries missing from the
+// remote listing, sorted by label.
+func findPruneCandidates(cfg config.Config, source config.Source, tracks []playlistTrack) (string, []PruneCandidate, error) {
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("resolve target_dir: %w", err)
+	}
+	stateFilePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("resolve state_file: %w", err)
+	}
+	statePaths, err := stateLocalPaths(source, stateFilePath)
+	if err != nil {
+		return "", nil, err
+	}
+	labels := map[string]string{}
+	if source.Type != config.SourceTypeSoundCloud {
+		if state, stateErr := parseTrackStateForSource(source.Type, stateFilePath); stateErr == nil {
+			for id, entry := range state.Entries {
+				labels[id] = entry.DisplayName
+			}
+			for id := range state.KnownIDs {
+				if _, ok := statePaths[id]; !ok {
+					statePaths[id] = ""
+				}
+			}
+		}
+	}
+
+	remote := make(map[string]struct{}, len(tracks))
+	for _, track := range tracks {
+		remote[track.ID] = struct{}{}
+	}
+	candidates := []PruneCandidate{}
+	for id, rawPath := range statePaths {
+		if _, ok := remote[id]; ok || id == "" {
+			continue
+		}
+		candidate := PruneCandidate{TrackID: id, Label: strings.TrimSpace(labels[id])}
+		if stateEntryHasLocalFile(rawPath, targetDir) {
+			candidate.LocalPath = filepath.FromSlash(rawPath)
+			if !filepath.IsAbs(candidate.LocalPath) {
+				candidate.LocalPath = filepath.Join(targetDir, candidate.LocalPath)
+			}
+		}
+		if candidate.Label == "" && candidate.LocalPath != "" {
+			name := filepath.Base(candidate.LocalPath)
+			candidate.Label = strings.TrimSuffix(name, filepath.Ext(name))
+		}
+		if candidate.Label == "" {
+			candidate.Label = id
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Label != candidates[j].Label {
+			return candidates[i].Label < candidates[j].Label
+		}
+		return candidates[i].TrackID < candidates[j].TrackID
+	})
+	return stateFilePath, candidates, nil
+}
+
+func pruneTrashDir(cfg config.Config, source config.Source, stamp string) (string, error) {
+	stateDir, err := config.ExpandPath(cfg.Defaults.StateDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve state_dir: %w", err)
+	}
+	return filepath.Join(stateDir, "trash", source.ID, stamp), nil
+}
+
+// pruneTrashRelPath keeps a file's path under target_dir inside the trash
+// folder; files outside target_dir keep only their name.
+func pruneTrashRelPath(targetDir string, path string) string {
+	if rel, err := filepath.Rel(targetDir, path); err == nil && filepath.IsLocal(rel) {
+		return rel
+	}
+	return filepath.Base(path)
+}
+
+// removeStateEntries rewrites a state or archive file without the lines of
+// the given track ids.
+func removeStateEntries(path string, sourceType config.SourceType, ids map[string]struct{}) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	lines, err := readSoundCloudArchiveLines(path)
+	if err != nil {
+		return err
+	}
+	parseLine := trackStateLineParser(sourceType)
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		id := line.ID
+		if sourceType != config.SourceTypeSoundCloud {
+			id, _ = parseLine(line.Raw)
+		}
+		if _, remove := ids[id]; id != "" && remove {
+			continue
+		}
+		kept = append(kept, line.Raw)
+	}
+	if len(kept) == len(lines) {
+		return nil
+	}
+	return writeSoundCloudLinesAtomically(path, ".udl-prune-*.tmp", kept)
+}
+
+// moveFile renames src to dst, copying across filesystems when state_dir
+// and target_dir are on different volumes. An existing dst is never
+// overwritten.
+func moveFile(src string, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (s *Syncer) emitPruneLine(sourceID string, level output.Level, line string) {
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [prune] %s", sourceID, line),
+	})
+}
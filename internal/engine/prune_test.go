@@ -0,0 +1,131 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSyncerPruneMovesRemovedTracksToTrash(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{filepath.Join(targetDir, "Daft Punk"), stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"Daft Punk/Aerodynamic.mp3", "Daft Punk/One More Time.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, filepath.FromSlash(name)), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "deezer-mix.sync.deezer")
+	stateBody := deezerStateHeader + "\n" +
+		"3135560\ttitle=Daft+Punk+-+Aerodynamic\tpath=Daft+Punk%2FAerodynamic.mp3\n" +
+		"3135558\ttitle=Daft+Punk+-+One+More+Time\tpath=Daft+Punk%2FOne+More+Time.mp3\n" +
+		"3135556\ttitle=Daft+Punk+-+Harder%2C+Better%2C+Faster%2C+Stronger\n"
+	if err := os.WriteFile(statePath, []byte(stateBody), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "deezer-mix",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/en/playlist/908622995",
+				StateFile: "deezer-mix.sync.deezer",
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+				Sync:      config.SyncPolicy{Prune: boolPtrSyncer(true)},
+			},
+		},
+	}
+
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateDeezerTracksFn
+	t.Cleanup(func() {
+		resolveDeemixARLFn = origResolveARL
+		enumerateDeezerTracksFn = origEnumerate
+	})
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateDeezerTracksFn = func(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+		return []deezerRemoteTrack{
+			{ID: "3135560", Title: "Aerodynamic", Artist: "Daft Punk", Readable: true},
+		}, nil
+	}
+
+	run := func(opts SyncOptions) string {
+		var out bytes.Buffer
+		syncer := NewSyncer(
+			map[string]Adapter{"deemix": fakeDeemixAdapter{}},
+			&sequenceRunner{results: []ExecResult{{ExitCode: 0}}},
+			output.NewHumanEmitter(&out, &out, false, true),
+		)
+		syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+		if _, err := syncer.Sync(context.Background(), cfg, opts); err != nil {
+			t.Fatalf("sync: %v", err)
+		}
+		return out.String()
+	}
+
+	prompted := 0
+	logs := run(SyncOptions{
+		AllowPrompt: true,
+		PromptOnPrune: func(sourceID string, candidates []PruneCandidate) (bool, error) {
+			prompted = len(candidates)
+			return false, nil
+		},
+	})
+	if prompted != 2 {
+		t.Fatalf("expected two prune candidates in the prompt, got %d\n%s", prompted, logs)
+	}
+	if !strings.Contains(logs, "2 removed track(s) kept; rerun with --apply") {
+		t.Fatalf("expected declined prune to keep tracks, got:\n%s", logs)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Daft Punk", "One More Time.mp3")); err != nil {
+		t.Fatalf("expected declined prune to leave the file: %v", err)
+	}
+
+	logs = run(SyncOptions{ApplyPrune: true})
+	trashed := filepath.Join(stateDir, "trash", "deezer-mix", "20261014-093000", "Daft Punk", "One More Time.mp3")
+	if _, err := os.Stat(trashed); err != nil {
+		t.Fatalf("expected removed track in trash: %v\n%s", err, logs)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Daft Punk", "One More Time.mp3")); !os.IsNotExist(err) {
+		t.Fatalf("expected removed track to leave target_dir, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "Daft Punk", "Aerodynamic.mp3")); err != nil {
+		t.Fatalf("expected remote track to stay: %v", err)
+	}
+	if !strings.Contains(logs, "moved 1 file(s)") || !strings.Contains(logs, "dropped 2 state entries") {
+		t.Fatalf("expected prune summary, got:\n%s", logs)
+	}
+	state, err := parseDeezerSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if len(state.KnownIDs) != 1 {
+		t.Fatalf("expected only the remote track left in state, got %+v", state.KnownIDs)
+	}
+	if _, ok := state.KnownIDs["3135560"]; !ok {
+		t.Fatalf("expected remote track to stay in state, got %+v", state.KnownIDs)
+	}
+}
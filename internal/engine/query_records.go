@@ -0,0 +1,266 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/query"
+)
+
+// QuerySchema lists the collections `udl query` reads and their fields in
+// output order. runs has one row per source per journaled run.
+var QuerySchema = query.Schema{
+	"sources": {"id", "type", "adapter", "enabled", "url", "target_dir", "state_file", "archive_file", "schedule", "last_synced_at", "known_tracks", "local_files", "gaps"},
+	"tracks":  {"source", "source_type", "id", "title", "path", "present", "quality", "provider", "adapter", "added_at"},
+	"runs":    {"run_id", "started_at", "finished_at", "source", "status", "class", "message", "duration_ms", "downloaded"},
+}
+
+// LoadQueryRecords builds the rows of one QuerySchema collection from local
+// state files, archives, target_dir, and the history journal. Nothing remote
+// is contacted.
+func LoadQueryRecords(cfg config.Config, collection string) ([]query.Record, error) {
+	switch collection {
+	case "sources":
+		return querySourceRecords(cfg), nil
+	case "tracks":
+		return queryTrackRecords(cfg)
+	case "runs":
+		return queryRunRecords(cfg.Defaults.StateDir)
+	default:
+		return nil, fmt.Errorf("unknown query collection %q", collection)
+	}
+}
+
+func querySourceRecords(cfg config.Config) []query.Record {
+	records := make([]query.Record, 0, len(cfg.Sources))
+	for _, source := range cfg.Sources {
+		status := InspectSourceStatus(cfg.Defaults, source)
+		var lastSynced any
+		if status.LastSyncedAt != nil {
+			lastSynced = *status.LastSyncedAt
+		}
+		var gaps any
+		if status.GapsChecked {
+			gaps = int64(status.Gaps)
+		}
+		records = append(records, query.Record{
+			"id":             source.ID,
+			"type":           status.Type,
+			"adapter":        status.Adapter,
+			"enabled":        status.Enabled,
+			"url":            source.URL,
+			"target_dir":     status.TargetDir,
+			"state_file":     status.StateFile,
+			"archive_file":   status.ArchiveFile,
+			"schedule":       source.Sync.Schedule,
+			"last_synced_at": lastSynced,
+			"known_tracks":   int64(status.KnownTracks),
+			"local_files":    int64(status.LocalFiles),
+			"gaps":           gaps,
+		})
+	}
+	return records
+}
+
+// queryTrackRecords lists every track a source's state or archive knows.
+// SoundCloud titles come from the source's metadata cache, falling back to
+// the file name. added_at is the time recorded with the track (state v3
+// entries, or the SoundCloud provenance log), else the start of the first
+// journaled run that downloaded it, else the local file's modification time.
+func queryTrackRecords(cfg config.Config) ([]query.Record, error) {
+	history, err := LoadHistory(cfg.Defaults.StateDir, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	firstDownloaded := map[string]map[string]time.Time{}
+	for _, entry := range history {
+		for _, source := range entry.Sources {
+			for _, trackID := range source.DownloadedTrackIDs {
+				byTrack := firstDownloaded[source.SourceID]
+				if byTrack == nil {
+					byTrack = map[string]time.Time{}
+					firstDownloaded[source.SourceID] = byTrack
+				}
+				if at, ok := byTrack[trackID]; !ok || entry.StartedAt.Before(at) {
+					byTrack[trackID] = entry.StartedAt
+				}
+			}
+		}
+	}
+
+	records := []query.Record{}
+	for _, source := range cfg.Sources {
+		targetDir, err := config.ExpandTargetDir(source.TargetDir)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] target_dir: %w", source.ID, err)
+		}
+		tracks, err := queryLoadSourceTracks(cfg.Defaults, source)
+		if err != nil {
+			return nil, fmt.Errorf("[%s] %w", source.ID, err)
+		}
+		for _, track := range tracks {
+			record := query.Record{
+				"source":      source.ID,
+				"source_type": string(source.Type),
+				"id":          track.id,
+				"title":       track.title,
+				"path":        track.path,
+				"present":     false,
+				"quality":     track.quality,
+				"provider":    track.provider,
+				"adapter":     track.adapter,
+				"added_at":    nil,
+			}
+			if !track.addedAt.IsZero() {
+				record["added_at"] = track.addedAt
+			} else if at, ok := firstDownloaded[source.ID][track.id]; ok {
+				record["added_at"] = at
+			}
+			if fullPath := queryTrackFullPath(track.path, targetDir); fullPath != "" {
+				if info, statErr := os.Stat(fullPath); statErr == nil && !info.IsDir() {
+					record["present"] = true
+					if record["added_at"] == nil {
+						record["added_at"] = info.ModTime()
+					}
+				}
+			}
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+type queryTrack struct {
+	id       string
+	title    string
+	path     string
+	quality  string
+	provider string
+	adapter  string
+	addedAt  time.Time
+}
+
+func queryLoadSourceTracks(defaults config.Defaults, source config.Source) ([]queryTrack, error) {
+	tracks := []queryTrack{}
+	switch source.Type {
+	case config.SourceTypeSoundCloud, config.SourceTypeSpotify, config.SourceTypeDeezer, config.SourceTypeAppleMusic:
+		if strings.TrimSpace(source.StateFile) == "" {
+			return tracks, nil
+		}
+		statePath, err := config.ResolveStateFile(defaults.StateDir, source.StateFile)
+		if err != nil {
+			return nil, fmt.Errorf("state_file: %w", err)
+		}
+		if source.Type == config.SourceTypeSoundCloud {
+			state, err := parseSoundCloudSyncState(statePath)
+			if err != nil {
+				return nil, fmt.Errorf("parse soundcloud sync state file: %w", err)
+			}
+			cachedTitles := loadSoundCloudMetadataCacheTitles(defaults.StateDir, source.ID)
+			provenance, err := loadSoundCloudProvenance(defaults.StateDir, source.ID)
+			if err != nil {
+				return nil, fmt.Errorf("read provenance log: %w", err)
+			}
+			for _, entry := range state.Entries {
+				if entry.ID == "" {
+					continue
+				}
+				path := strings.TrimSpace(entry.FilePath)
+				title := cachedTitles[entry.ID]
+				if title == "" && path != "" {
+					title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+				}
+				record := provenance[entry.ID]
+				tracks = append(tracks, queryTrack{
+					id:       entry.ID,
+					title:    title,
+					path:     path,
+					provider: record.Provider,
+					adapter:  record.Adapter,
+					addedAt:  record.AddedAt,
+				})
+			}
+			return tracks, nil
+		}
+		state, err := parseTrackStateForSource(source.Type, statePath)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s sync state file: %w", source.Type, err)
+		}
+		ids := make([]string, 0, len(state.KnownIDs))
+		for id := range state.KnownIDs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			entry := state.Entries[id]
+			tracks = append(tracks, queryTrack{
+				id:       id,
+				title:    entry.DisplayName,
+				path:     strings.TrimSpace(entry.LocalPath),
+				quality:  entry.Quality,
+				provider: entry.Provider,
+				adapter:  entry.Adapter,
+				addedAt:  entry.AddedAt,
+			})
+		}
+		return tracks, nil
+	default:
+		archivePath, ok, err := sourceArchivePath(source, defaults)
+		if err != nil {
+			return nil, fmt.Errorf("archive_file: %w", err)
+		}
+		if !ok {
+			return tracks, nil
+		}
+		lines, err := readSoundCloudArchiveLines(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("archive_file: %w", err)
+		}
+		for _, line := range lines {
+			// yt-dlp archive lines are "<extractor> <id>".
+			fields := strings.Fields(line.Raw)
+			if len(fields) < 2 {
+				continue
+			}
+			tracks = append(tracks, queryTrack{id: fields[1]})
+		}
+		return tracks, nil
+	}
+}
+
+func queryTrackFullPath(rawPath string, targetDir string) string {
+	path := strings.TrimSpace(rawPath)
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(targetDir, path)
+}
+
+func queryRunRecords(stateDir string) ([]query.Record, error) {
+	history, err := LoadHistory(stateDir, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("load history: %w", err)
+	}
+	records := []query.Record{}
+	for _, entry := range history {
+		for _, source := range entry.Sources {
+			records = append(records, query.Record{
+				"run_id":      entry.RunID,
+				"started_at":  entry.StartedAt,
+				"finished_at": entry.FinishedAt,
+				"source":      source.SourceID,
+				"status":      source.Status,
+				"class":       source.Class,
+				"message":     source.Message,
+				"duration_ms": source.DurationMS,
+				"downloaded":  int64(len(source.DownloadedTrackIDs)),
+			})
+		}
+	}
+	return records, nil
+}
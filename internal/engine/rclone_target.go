@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+var (
+	rcloneListFn = rcloneList
+	rcloneMoveFn = rcloneMove
+)
+
+// defaultRcloneListingMaxAge is how long a cached remote listing is trusted
+// before the remote is listed again; UDL_RCLONE_LISTING_MAX_AGE overrides it
+// ("0" lists the remote on every run).
+const defaultRcloneListingMaxAge = 6 * time.Hour
+
+// rcloneListing is the cached file listing of a remote target_dir, kept at
+// <state_dir>/<source id>.rclone-listing.json. Files are slash-separated and
+// relative to the remote.
+type rcloneListing struct {
+	Remote    string    `json:"remote"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Files     []string  `json:"files"`
+}
+
+// remoteTarget is a source whose target_dir is an rclone remote. The run
+// downloads into StagingDir, and what lands there is moved to Remote once the
+// source finishes.
+type remoteTarget struct {
+	Remote      string
+	StagingDir  string
+	ListingPath string
+	Listing     rcloneListing
+}
+
+// remoteTargetListings holds the remote listing of each staging dir in use,
+// so preflight existence checks against the staging dir also see the files
+// that already live on the remote.
+var remoteTargetListings = struct {
+	sync.Mutex
+	byRoot map[string]map[string]struct{}
+}{byRoot: map[string]map[string]struct{}{}}
+
+func registerRemoteTargetListing(root string, files []string) {
+	set := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		set[file] = struct{}{}
+	}
+	remoteTargetListings.Lock()
+	defer remoteTargetListings.Unlock()
+	remoteTargetListings.byRoot[filepath.Clean(root)] = set
+}
+
+func unregisterRemoteTargetListing(root string) {
+	remoteTargetListings.Lock()
+	defer remoteTargetListings.Unlock()
+	delete(remoteTargetListings.byRoot, filepath.Clean(root))
+}
+
+// remoteTargetListed reports whether fullPath, under a staging dir, is a file
+// the remote already holds.
+func remoteTargetListed(fullPath string) bool {
+	remoteTargetListings.Lock()
+	defer remoteTargetListings.Unlock()
+	for root, files := range remoteTargetListings.byRoot {
+		rel, err := filepath.Rel(root, filepath.Clean(fullPath))
+		if err != nil || !filepath.IsLocal(rel) {
+			continue
+		}
+		if _, ok := files[filepath.ToSlash(rel)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteTargetFiles returns the remote files listed for a staging dir, or nil
+// when root is not one.
+func remoteTargetFiles(root string) []string {
+	remoteTargetListings.Lock()
+	defer remoteTargetListings.Unlock()
+	files := remoteTargetListings.byRoot[filepath.Clean(root)]
+	if len(files) == 0 {
+		return nil
+	}
+	list := make([]string, 0, len(files))
+	for file := range files {
+		list = append(list, file)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// stageRemoteTarget points source at a local staging dir when its target_dir
+// is an rclone remote, and loads the remote listing preflight checks use. It
+// returns nil when target_dir is local.
+func (s *Syncer) stageRemoteTarget(ctx context.Context, cfg config.Config, source config.Source) (config.Source, *remoteTarget, error) {
+	if !config.IsRcloneRemote(source.TargetDir) {
+		return source, nil, nil
+	}
+	stateDir, err := config.ExpandPath(cfg.Defaults.StateDir)
+	if err != nil {
+		return source, nil, fmt.Errorf("[%s] invalid state_dir: %w", source.ID, err)
+	}
+	target := &remoteTarget{
+		Remote:      strings.TrimSpace(source.TargetDir),
+		StagingDir:  filepath.Join(stateDir, "rclone-staging", source.ID),
+		ListingPath: filepath.Join(stateDir, source.ID+".rclone-listing.json"),
+	}
+	if err := os.MkdirAll(target.StagingDir, 0o755); err != nil {
+		return source, nil, fmt.Errorf("[%s] unable to create rclone staging dir: %w", source.ID, err)
+	}
+
+	listing, cached := loadRcloneListing(target.ListingPath, target.Remote, s.Now(), resolveRcloneListingMaxAge())
+	if !cached {
+		files, err := rcloneListFn(ctx, target.Remote)
+		if err != nil {
+			return source, nil, fmt.Errorf("[%s] unable to list rclone remote %s: %w", source.ID, target.Remote, err)
+		}
+		listing = rcloneListing{Remote: target.Remote, FetchedAt: s.Now().UTC(), Files: files}
+		if err := saveRcloneListing(target.ListingPath, listing); err != nil {
+			s.emitRcloneLine(source.ID, output.LevelWarn, fmt.Sprintf("unable to cache remote listing: %v", err))
+		}
+	}
+	target.Listing = listing
+	registerRemoteTargetListing(target.StagingDir, listing.Files)
+
+	how := "listed"
+	if cached {
+		how = "cached listing from " + listing.FetchedAt.Local().Format(time.RFC3339)
+	}
+	s.emitRcloneLine(source.ID, output.LevelInfo, fmt.Sprintf("staging downloads in %s for %s (%d remote file(s), %s)", target.StagingDir, target.Remote, len(listing.Files), how))
+
+	staged := source
+	staged.TargetDir = target.StagingDir
+	return staged, target, nil
+}
+
+// uploadRemoteTarget moves everything in the staging dir to the remote and
+// records the moved files in the cached listing. Files a failed move leaves
+// behind stay staged and go up with the next run.
+func (s *Syncer) uploadRemoteTarget(ctx context.Context, source config.Source, target *remoteTarget) error {
+	defer unregisterRemoteTargetListing(target.StagingDir)
+	staged, err := stagedFiles(target.StagingDir)
+	if err != nil {
+		return fmt.Errorf("scan rclone staging dir: %w", err)
+	}
+	if len(staged) == 0 {
+		return nil
+	}
+	if err := rcloneMoveFn(ctx, target.StagingDir, target.Remote); err != nil {
+		return fmt.Errorf("rclone move to %s: %w", target.Remote, err)
+	}
+
+	known := make(map[string]struct{}, len(target.Listing.Files)+len(staged))
+	for _, file := range target.Listing.Files {
+		known[file] = struct{}{}
+	}
+	for _, file := range staged {
+		if _, ok := known[file]; !ok {
+			known[file] = struct{}{}
+			target.Listing.Files = append(target.Listing.Files, file)
+		}
+	}
+	sort.Strings(target.Listing.Files)
+	if target.Listing.Remote == "" {
+		target.Listing.Remote = target.Remote
+	}
+	if err := saveRcloneListing(target.ListingPath, target.Listing); err != nil {
+		s.emitRcloneLine(source.ID, output.LevelWarn, fmt.Sprintf("unable to update cached remote listing: %v", err))
+	}
+	s.emitRcloneLine(source.ID, output.LevelInfo, fmt.Sprintf("moved %d file(s) to %s", len(staged), target.Remote))
+	return nil
+}
+
+func stagedFiles(root string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, relErr := filepath.Rel(root, path)
+		if relErr != nil {
+			return relErr
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return files, err
+}
+
+// loadRcloneListing returns the cached listing of remote when it is younger
+// than maxAge.
+func loadRcloneListing(path string, remote string, now time.Time, maxAge time.Duration) (rcloneListing, bool) {
+	if maxAge <= 0 {
+		return rcloneListing{}, false
+	}
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return rcloneListing{}, false
+	}
+	var listing rcloneListing
+	if err := json.Unmarshal(payload, &listing); err != nil || listing.Remote != remote {
+		return rcloneListing{}, false
+	}
+	if now.Sub(listing.FetchedAt) > maxAge {
+		return rcloneListing{}, false
+	}
+	return listing, true
+}
+
+func saveRcloneListing(path string, listing rcloneListing) error {
+	payload, err := json.MarshalIndent(listing, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path, ".udl-rclone-*", append(payload, '\n'))
+}
+
+func resolveRcloneListingMaxAge() time.Duration {
+	if override := strings.TrimSpace(os.Getenv("UDL_RCLONE_LISTING_MAX_AGE")); override != "" {
+		if override == "0" {
+			return 0
+		}
+		if parsed, err := time.ParseDuration(override); err == nil && parsed >= 0 {
+			return parsed
+		}
+	}
+	return defaultRcloneListingMaxAge
+}
+
+// rcloneList returns every file under remote, relative to it.
+func rcloneList(ctx context.Context, remote string) ([]string, error) {
+	payload, err := runRclone(ctx, "lsjson", "--recursive", "--files-only", "--no-modtime", "--no-mimetype", remote)
+	if err != nil {
+		return nil, err
+	}
+	var entries []struct {
+		Path string `json:"Path"`
+	}
+	if err := json.Unmarshal(payload, &entries); err != nil {
+		return nil, fmt.Errorf("parse rclone lsjson output: %w", err)
+	}
+	files := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Path != "" {
+			files = append(files, entry.Path)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// rcloneMove moves the contents of localDir into remote, leaving localDir
+// itself in place.
+func rcloneMove(ctx context.Context, localDir string, remote string) error {
+	_, err := runRclone(ctx, "move", localDir, remote, "--delete-empty-src-dirs")
+	return err
+}
+
+func runRclone(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "rclone", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	payload, err := cmd.Output()
+	if err != nil {
+		if detail := lastNonEmptyLine(stderr.String()); detail != "" {
+			return nil, fmt.Errorf("%w: %s", err, detail)
+		}
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (s *Syncer) emitRcloneLine(sourceID string, level output.Level, line string) {
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [rclone] %s", sourceID, line),
+	})
+}
+
+// applyRemoteUpload moves a remote source's staged downloads to the remote.
+// A failed move fails the source the same way unmet success criteria do.
+func (s *Syncer) applyRemoteUpload(ctx context.Context, cfg config.Config, source config.Source, target *remoteTarget, outcome sourceRunOutcome) sourceRunOutcome {
+	err := s.uploadRemoteTarget(ctx, source, target)
+	if err == nil {
+		return outcome
+	}
+	if outcome.Succeeded > 0 {
+		outcome.Succeeded--
+		outcome.Failed++
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		outcome.DependencyFailures++
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelError,
+		Event:     output.EventSourceFailed,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] [rclone] %v; downloads stay staged in %s for the next run", source.ID, err, target.StagingDir),
+		Details: map[string]any{
+			"remote":      target.Remote,
+			"staging_dir": target.StagingDir,
+		},
+	})
+	if !cfg.Defaults.ContinueOnError {
+		outcome.Stop = true
+	}
+	return outcome
+}
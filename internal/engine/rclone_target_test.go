@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestRemoteTargetStagesChecksListingAndMovesDownloads(t *testing.T) {
+	stateDir := t.TempDir()
+	origList, origMove := rcloneListFn, rcloneMoveFn
+	t.Cleanup(func() { rcloneListFn, rcloneMoveFn = origList, origMove })
+	lists := 0
+	rcloneListFn = func(ctx context.Context, remote string) ([]string, error) {
+		lists++
+		if remote != "gdrive:Music/Mix" {
+			t.Fatalf("unexpected remote %q", remote)
+		}
+		return []string{"Old Track.mp3", "Set/Live Set.flac"}, nil
+	}
+	var moved []string
+	rcloneMoveFn = func(ctx context.Context, localDir string, remote string) error {
+		files, err := stagedFiles(localDir)
+		if err != nil {
+			return err
+		}
+		moved = files
+		for _, file := range files {
+			if err := os.Remove(filepath.Join(localDir, filepath.FromSlash(file))); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	now := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	syncer.Now = func() time.Time { return now }
+	cfg := config.Config{Defaults: config.Defaults{StateDir: stateDir}}
+	source := config.Source{ID: "mix", Type: config.SourceTypeSoundCloud, TargetDir: "gdrive:Music/Mix"}
+
+	staged, target, err := syncer.stageRemoteTarget(context.Background(), cfg, source)
+	if err != nil {
+		t.Fatalf("stage: %v", err)
+	}
+	wantStaging := filepath.Join(stateDir, "rclone-staging", "mix")
+	if staged.TargetDir != wantStaging || target.Remote != "gdrive:Music/Mix" {
+		t.Fatalf("unexpected staging %q for %q", staged.TargetDir, target.Remote)
+	}
+	if !stateEntryHasLocalFile("Set/Live Set.flac", staged.TargetDir) || stateEntryHasLocalFile("Missing.mp3", staged.TargetDir) {
+		t.Fatalf("expected existence checks to consult the remote listing")
+	}
+	if index := scanLocalMediaTitleIndex(staged.TargetDir); index[normalizeTrackKey("Old Track")] != 1 {
+		t.Fatalf("expected remote titles in the local index, got %v", index)
+	}
+
+	if err := os.WriteFile(filepath.Join(staged.TargetDir, "New Track.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write staged file: %v", err)
+	}
+	if err := syncer.uploadRemoteTarget(context.Background(), staged, target); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if strings.Join(moved, ",") != "New Track.mp3" {
+		t.Fatalf("expected the new download moved, got %v", moved)
+	}
+	if stateEntryHasLocalFile("Old Track.mp3", staged.TargetDir) {
+		t.Fatalf("expected the listing to be released after the upload")
+	}
+
+	// The next run trusts the cached listing, which now includes the upload.
+	staged, _, err = syncer.stageRemoteTarget(context.Background(), cfg, source)
+	if err != nil {
+		t.Fatalf("stage again: %v", err)
+	}
+	defer unregisterRemoteTargetListing(staged.TargetDir)
+	if lists != 1 {
+		t.Fatalf("expected the cached listing to be reused, listed %d times", lists)
+	}
+	if !stateEntryHasLocalFile("New Track.mp3", staged.TargetDir) {
+		t.Fatalf("expected the uploaded file in the cached listing")
+	}
+	if !strings.Contains(out.String(), "[mix] [rclone] moved 1 file(s) to gdrive:Music/Mix") {
+		t.Fatalf("expected upload summary, got:\n%s", out.String())
+	}
+}
+
+func TestApplyRemoteUploadFailureFailsTheSource(t *testing.T) {
+	stagingDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(stagingDir, "Track.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write staged file: %v", err)
+	}
+	origMove := rcloneMoveFn
+	t.Cleanup(func() { rcloneMoveFn = origMove })
+	rcloneMoveFn = func(ctx context.Context, localDir string, remote string) error {
+		return errors.New("exit status 1: couldn't connect")
+	}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+	target := &remoteTarget{Remote: "gdrive:Music", StagingDir: stagingDir, ListingPath: filepath.Join(t.TempDir(), "mix.rclone-listing.json")}
+	outcome := syncer.applyRemoteUpload(context.Background(), config.Config{}, config.Source{ID: "mix"}, target, sourceRunOutcome{Attempted: 1, Succeeded: 1})
+	if outcome.Succeeded != 0 || outcome.Failed != 1 || !outcome.Stop {
+		t.Fatalf("expected a failed, stopping source, got %+v", outcome)
+	}
+	if _, err := os.Stat(filepath.Join(stagingDir, "Track.mp3")); err != nil {
+		t.Fatalf("expected the download to stay staged: %v", err)
+	}
+	if !strings.Contains(out.String(), "downloads stay staged in "+stagingDir) {
+		t.Fatalf("expected failure message, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,144 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const remoteCountsFileName = "remote-counts.json"
+
+// ErrRemoteDrift is wrapped by planning errors when a source's remote track
+// count shrank past sync.max_remote_shrink_percent.
+var ErrRemoteDrift = errors.New("remote track count drift alarm")
+
+// remoteCount is the remote track total a source last planned against, kept
+// in <state_dir>/remote-counts.json.
+type remoteCount struct {
+	Total int       `json:"total"`
+	At    time.Time `json:"at"`
+}
+
+type remoteCountsFile struct {
+	Sources map[string]remoteCount `json:"sources"`
+}
+
+// checkRemoteDrift compares a freshly enumerated remote total with the one
+// the source last planned against. When the playlist shrank by more than
+// sync.max_remote_shrink_percent, planning stops with a loud warning unless
+// the run passes --force; otherwise the new total is recorded. Dry runs
+// check but never record.
+func (s *Syncer) checkRemoteDrift(cfg config.Config, source config.Source, remoteTotal int, opts SyncOptions) error {
+	limit := source.Sync.MaxRemoteShrinkPercent
+	if limit <= 0 {
+		return nil
+	}
+	counts, err := loadRemoteCounts(cfg.Defaults.StateDir)
+	if err != nil {
+		counts = map[string]remoteCount{}
+	}
+	if previous, ok := counts[source.ID]; ok && previous.Total > 0 && remoteTotal < previous.Total {
+		shrink := (previous.Total - remoteTotal) * 100 / previous.Total
+		if shrink > limit {
+			message := fmt.Sprintf(
+				"[%s] remote track count dropped from %d to %d (-%d%%, sync.max_remote_shrink_percent=%d)",
+				source.ID, previous.Total, remoteTotal, shrink, limit,
+			)
+			details := map[string]any{
+				"previous_total": previous.Total,
+				"remote_total":   remoteTotal,
+				"shrink_percent": shrink,
+				"limit_percent":  limit,
+				"forced":         opts.Force,
+			}
+			if !opts.Force {
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelWarn,
+					Event:     output.EventSourcePreflight,
+					SourceID:  source.ID,
+					Message:   message + "; planning aborted to protect the local library, rerun with --force if the change is expected",
+					Details:   details,
+				})
+				return fmt.Errorf("%w: dropped from %d to %d tracks (rerun with --force to accept)", ErrRemoteDrift, previous.Total, remoteTotal)
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   message + "; continuing because of --force",
+				Details:   details,
+			})
+		}
+	}
+	if opts.DryRun {
+		return nil
+	}
+	_ = recordRemoteCount(cfg.Defaults.StateDir, source.ID, remoteCount{Total: remoteTotal, At: s.Now().UTC()})
+	return nil
+}
+
+// recordRemoteCount stores one source's remote total, re-reading the file
+// under the state_dir lock so totals other runs recorded meanwhile survive.
+func recordRemoteCount(stateDir string, sourceID string, count remoteCount) error {
+	return withStateDirLock(stateDir, func() error {
+		counts, err := loadRemoteCounts(stateDir)
+		if err != nil {
+			counts = map[string]remoteCount{}
+		}
+		counts[sourceID] = count
+		return writeRemoteCounts(stateDir, counts)
+	})
+}
+
+func loadRemoteCounts(stateDir string) (map[string]remoteCount, error) {
+	path, err := remoteCountsPath(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]remoteCount{}, nil
+		}
+		return nil, err
+	}
+	var payload remoteCountsFile
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if payload.Sources == nil {
+		payload.Sources = map[string]remoteCount{}
+	}
+	return payload.Sources, nil
+}
+
+func writeRemoteCounts(stateDir string, counts map[string]remoteCount) error {
+	path, err := remoteCountsPath(stateDir)
+	if err != nil {
+		return err
+	}
+	payload, err := json.MarshalIndent(remoteCountsFile{Sources: counts}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path, ".udl-remote-counts-*", append(payload, '\n'))
+}
+
+func remoteCountsPath(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, remoteCountsFileName), nil
+}
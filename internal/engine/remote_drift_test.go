@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSyncAbortsPlanningWhenRemoteTrackCountShrinksPastLimit(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	runtimeDir := filepath.Join(tmp, "runtime")
+	for _, dir := range []string{targetDir, stateDir, runtimeDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:               "mix",
+				Type:             config.SourceTypeSpotify,
+				Enabled:          true,
+				TargetDir:        targetDir,
+				URL:              "https://open.spotify.com/playlist/mix0000001",
+				StateFile:        "mix.sync.spotify",
+				DeemixRuntimeDir: runtimeDir,
+				Sync:             config.SyncPolicy{MaxRemoteShrinkPercent: 50},
+				Adapter:          config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	remote := []spotifyRemoteTrack{
+		{ID: "1abc234def", Title: "track-1", Artist: "artist"},
+		{ID: "2abc234def", Title: "track-2", Artist: "artist"},
+		{ID: "3abc234def", Title: "track-3", Artist: "artist"},
+		{ID: "4abc234def", Title: "track-4", Artist: "artist"},
+	}
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		return remote, nil
+	}
+
+	runner := &sequenceRunner{}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixPathAdapter{}},
+		runner,
+		output.NewHumanEmitter(&out, &out, false, true),
+	)
+	if result, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil || result.Succeeded != 1 {
+		t.Fatalf("first sync: result=%+v err=%v", result, err)
+	}
+
+	remote = remote[:1]
+	runner.specs = nil
+	out.Reset()
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("drifted sync: %v", err)
+	}
+	if result.Failed != 1 || len(runner.specs) != 0 {
+		t.Fatalf("expected drift alarm to fail the source without downloads, got result=%+v specs=%d", result, len(runner.specs))
+	}
+	if !strings.Contains(out.String(), "remote track count dropped from 4 to 1 (-75%, sync.max_remote_shrink_percent=50)") {
+		t.Fatalf("expected drift warning, got:\n%s", out.String())
+	}
+	lastErrors, err := LoadSourceLastErrors(stateDir)
+	if err != nil || lastErrors["mix"].Class != "drift" {
+		t.Fatalf("expected drift last error, got %+v (%v)", lastErrors, err)
+	}
+
+	result, err = syncer.Sync(context.Background(), cfg, SyncOptions{Force: true})
+	if err != nil || result.Succeeded != 1 {
+		t.Fatalf("forced sync: result=%+v err=%v", result, err)
+	}
+	counts, err := loadRemoteCounts(stateDir)
+	if err != nil || counts["mix"].Total != 1 {
+		t.Fatalf("expected forced run to record the new total, got %+v (%v)", counts, err)
+	}
+}
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// replayGainReferenceLUFS is the ReplayGain 2.0 reference loudness; a
+// track's gain is the distance from its integrated loudness to it.
+const replayGainReferenceLUFS = -18.0
+
+var tagReplayGainFn = tagReplayGain
+
+// applyReplayGain writes ReplayGain track tags to the media files that
+// appeared or changed in target_dir since before was taken. A file that
+// fails analysis is reported and left untouched; it does not fail the
+// source.
+func (s *Syncer) applyReplayGain(ctx context.Context, source config.Source, before map[string]mediaFileSnapshot) {
+	if before == nil || source.Sync.ReplayGain == "" {
+		return
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return
+	}
+	after, err := snapshotMediaFiles(targetDir)
+	if err != nil {
+		s.emitReplayGainLine(source.ID, output.LevelWarn, fmt.Sprintf("skipped: unable to scan target_dir: %v", err))
+		return
+	}
+	changed := changedMediaFiles(before, after)
+	if len(changed) == 0 {
+		return
+	}
+
+	tagged := 0
+	for _, rel := range changed {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := tagReplayGainFn(ctx, source.Sync.ReplayGain, filepath.Join(targetDir, filepath.FromSlash(rel))); err != nil {
+			s.emitReplayGainLine(source.ID, output.LevelWarn, fmt.Sprintf("%s: %v", rel, err))
+			continue
+		}
+		tagged++
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] [replaygain] tagged %d/%d new file(s) with %s", source.ID, tagged, len(changed), source.Sync.ReplayGain),
+		Details: map[string]any{
+			"tool":          source.Sync.ReplayGain,
+			"tagged_count":  tagged,
+			"changed_count": len(changed),
+		},
+	})
+}
+
+// postDownloadSnapshot records target_dir's media files before a source
+// with sync.genre_routing, sync.replaygain, sync.musicbrainz, or sync.lyrics
+// runs, so the files the run adds or rewrites can be told apart from the
+// rest of the library.
+// It returns nil when nothing should be post-processed.
+func (s *Syncer) postDownloadSnapshot(source config.Source, opts SyncOptions) map[string]mediaFileSnapshot {
+	if opts.DryRun || (len(source.Sync.GenreRouting) == 0 && source.Sync.ReplayGain == "" && source.Sync.MusicBrainz == "" && source.Sync.Lyrics == "") {
+		return nil
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return nil
+	}
+	before, err := snapshotMediaFiles(targetDir)
+	if err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] post-download tagging skipped: unable to scan target_dir: %v", source.ID, err),
+		})
+		return nil
+	}
+	return before
+}
+
+// changedMediaFiles lists, sorted, the files of after that are new or grew
+// or were modified since before was taken.
+func changedMediaFiles(before map[string]mediaFileSnapshot, after map[string]mediaFileSnapshot) []string {
+	changed := make([]string, 0)
+	for rel, current := range after {
+		previous, existed := before[rel]
+		if existed && current.Size == previous.Size && !current.ModTime.After(previous.ModTime) {
+			continue
+		}
+		changed = append(changed, rel)
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// tagReplayGain measures path with tool and writes its ReplayGain track gain
+// and peak tags in place.
+func tagReplayGain(ctx context.Context, tool string, path string) error {
+	switch tool {
+	case config.ReplayGainRsgain:
+		out, err := RunPostProcess(ctx, "rsgain", "custom", "--tagmode=i", "--quiet", path)
+		if err != nil {
+			return postProcessError(err, out)
+		}
+		return nil
+	case config.ReplayGainFFmpeg:
+		out, err := RunPostProcess(ctx, "ffmpeg", "-hide_banner", "-nostats", "-i", path, "-map", "0:a:0", "-af", "ebur128=peak=true", "-f", "null", "-")
+		if err != nil {
+			return postProcessError(err, out)
+		}
+		loudness, peak, err := parseEBUR128Summary(string(out))
+		if err != nil {
+			return err
+		}
+		return writeReplayGainTags(ctx, path, replayGainReferenceLUFS-loudness, peak)
+	default:
+		return fmt.Errorf("unsupported replaygain tool %q", tool)
+	}
+}
+
+var (
+	ebur128IntegratedPattern = regexp.MustCompile(`(?m)^\s*I:\s+(-?[0-9.]+|-inf)\s+LUFS`)
+	ebur128PeakPattern       = regexp.MustCompile(`(?m)^\s*Peak:\s+(-?[0-9.]+|-inf)\s+dBFS`)
+)
+
+// parseEBUR128Summary reads the integrated loudness (LUFS) and true peak
+// (linear, 1.0 = full scale) from the summary ffmpeg's ebur128 filter prints
+// at the end of its output.
+func parseEBUR128Summary(output string) (float64, float64, error) {
+	summary := output
+	if idx := strings.LastIndex(output, "Summary:"); idx >= 0 {
+		summary = output[idx:]
+	}
+	match := ebur128IntegratedPattern.FindStringSubmatch(summary)
+	if match == nil {
+		return 0, 0, errors.New("ffmpeg ebur128 printed no integrated loudness")
+	}
+	if match[1] == "-inf" {
+		return 0, 0, errors.New("track is silent")
+	}
+	loudness, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("parse integrated loudness %q: %w", match[1], err)
+	}
+	peak := 1.0
+	if peakMatch := ebur128PeakPattern.FindStringSubmatch(summary); peakMatch != nil && peakMatch[1] != "-inf" {
+		if dbfs, parseErr := strconv.ParseFloat(peakMatch[1], 64); parseErr == nil {
+			peak = math.Pow(10, dbfs/20)
+		}
+	}
+	return loudness, peak, nil
+}
+
+// writeReplayGainTags remuxes path with the gain and peak tags added, keeping
+// the streams and the other tags as they are.
+func writeReplayGainTags(ctx context.Context, path string, gainDB float64, peak float64) error {
+	return remuxWithTags(ctx, path, []string{
+		"-metadata", fmt.Sprintf("REPLAYGAIN_TRACK_GAIN=%.2f dB", gainDB),
+		"-metadata", fmt.Sprintf("REPLAYGAIN_TRACK_PEAK=%.6f", peak),
+	})
+}
+
+func postProcessError(err error, out []byte) error {
+	trimmed := strings.TrimSpace(string(out))
+	if trimmed == "" {
+		return err
+	}
+	// ffmpeg prints the failing reason last, after the stream banner.
+	lines := strings.Split(trimmed, "\n")
+	return fmt.Errorf("%v: %s", err, strings.TrimSpace(lines[len(lines)-1]))
+}
+
+func (s *Syncer) emitReplayGainLine(sourceID string, level output.Level, line string) {
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [replaygain] %s", sourceID, line),
+	})
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestParseEBUR128Summary(t *testing.T) {
+	ffmpegOutput := `[Parsed_ebur128_0 @ 0x600] t: 9.9     TARGET:-23 LUFS    M: -11.2 S: -11.9     I: -12.1 LUFS       LRA:   3.1 LU  FTPK:  -0.4 dBFS  TPK:  -0.3 dBFS
+[Parsed_ebur128_0 @ 0x600] Summary:
+
+  Integrated loudness:
+    I:         -12.4 LUFS
+    Threshold: -22.6 LUFS
+
+  Loudness range:
+    LRA:         3.2 LU
+    Threshold: -32.5 LUFS
+    LRA low:   -14.1 LUFS
+    LRA high:  -10.9 LUFS
+
+  True peak:
+    Peak:       -0.3 dBFS
+`
+	loudness, peak, err := parseEBUR128Summary(ffmpegOutput)
+	if err != nil {
+		t.Fatalf("parse summary: %v", err)
+	}
+	if loudness != -12.4 {
+		t.Fatalf("expected integrated loudness -12.4, got %v", loudness)
+	}
+	if math.Abs(peak-0.966051) > 1e-6 {
+		t.Fatalf("expected linear peak 0.966051, got %v", peak)
+	}
+
+	if _, _, err := parseEBUR128Summary("Summary:\n    I:         -inf LUFS\n"); err == nil || !strings.Contains(err.Error(), "silent") {
+		t.Fatalf("expected silent track error, got %v", err)
+	}
+}
+
+func TestApplyReplayGainTagsOnlyFilesAddedByTheRun(t *testing.T) {
+	targetDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(targetDir, "Old.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write old file: %v", err)
+	}
+	source := config.Source{
+		ID:        "deezer-mix",
+		Type:      config.SourceTypeDeezer,
+		TargetDir: targetDir,
+		Sync:      config.SyncPolicy{ReplayGain: config.ReplayGainFFmpeg},
+	}
+
+	origTag := tagReplayGainFn
+	t.Cleanup(func() { tagReplayGainFn = origTag })
+	tagged := []string{}
+	tagReplayGainFn = func(ctx context.Context, tool string, path string) error {
+		if tool != config.ReplayGainFFmpeg {
+			t.Fatalf("expected ffmpeg tool, got %q", tool)
+		}
+		tagged = append(tagged, filepath.Base(path))
+		return nil
+	}
+
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+
+	if before := syncer.postDownloadSnapshot(source, SyncOptions{DryRun: true}); before != nil {
+		t.Fatalf("expected no snapshot in dry-run, got %v", before)
+	}
+	before := syncer.postDownloadSnapshot(source, SyncOptions{})
+	if err := os.MkdirAll(filepath.Join(targetDir, "Daft Punk"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "Daft Punk", "Aerodynamic.flac"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write new file: %v", err)
+	}
+	syncer.applyReplayGain(context.Background(), source, before)
+
+	if !reflect.DeepEqual(tagged, []string{"Aerodynamic.flac"}) {
+		t.Fatalf("expected only the new file tagged, got %v", tagged)
+	}
+	if !strings.Contains(out.String(), "[deezer-mix] [replaygain] tagged 1/1 new file(s) with ffmpeg") {
+		t.Fatalf("expected replaygain summary, got:\n%s", out.String())
+	}
+}
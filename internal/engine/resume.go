@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const resumeCheckpointFileName = "resume.json"
+
+// ErrNoResumeCheckpoint is returned by LoadResumeCheckpoint when no
+// interrupted sync left a checkpoint behind.
+var ErrNoResumeCheckpoint = errors.New("no resume checkpoint found (no interrupted sync to resume)")
+
+// ResumeCheckpoint is written to <state_dir>/resume.json when a sync is
+// interrupted. It lists the sources that had not finished and, for sources
+// that got as far as planning, the planned tracks not yet downloaded.
+// `udl sync --resume` runs only those sources and tracks.
+type ResumeCheckpoint struct {
+	RunID         string         `json:"run_id,omitempty"`
+	InterruptedAt time.Time      `json:"interrupted_at"`
+	Sources       []ResumeSource `json:"sources"`
+}
+
+type ResumeSource struct {
+	SourceID string `json:"source_id"`
+	// Planned is false when the source was interrupted (or never reached)
+	// before its plan was built; it then resumes with a full plan.
+	Planned         bool     `json:"planned"`
+	PendingTrackIDs []string `json:"pending_track_ids,omitempty"`
+}
+
+func (c *ResumeCheckpoint) source(sourceID string) (ResumeSource, bool) {
+	if c == nil {
+		return ResumeSource{}, false
+	}
+	for _, source := range c.Sources {
+		if source.SourceID == sourceID {
+			return source, true
+		}
+	}
+	return ResumeSource{}, false
+}
+
+// filterSources keeps the selected sources the checkpoint still lists.
+func (c *ResumeCheckpoint) filterSources(selected []config.Source) []config.Source {
+	if c == nil {
+		return selected
+	}
+	kept := make([]config.Source, 0, len(selected))
+	for _, source := range selected {
+		if _, ok := c.source(source.ID); ok {
+			kept = append(kept, source)
+		}
+	}
+	return kept
+}
+
+// pendingTracks returns the tracks a resumed source is limited to, or false
+// when the source should be planned in full.
+func (c *ResumeCheckpoint) pendingTracks(sourceID string) (map[string]struct{}, bool) {
+	source, ok := c.source(sourceID)
+	if !ok || !source.Planned {
+		return nil, false
+	}
+	pending := make(map[string]struct{}, len(source.PendingTrackIDs))
+	for _, id := range source.PendingTrackIDs {
+		pending[id] = struct{}{}
+	}
+	return pending, true
+}
+
+// restrictTrackIDs drops planned track ids the checkpoint no longer lists as
+// pending for sourceID, keeping the plan's order.
+func (c *ResumeCheckpoint) restrictTrackIDs(sourceID string, ids []string) []string {
+	pending, ok := c.pendingTracks(sourceID)
+	if !ok {
+		return ids
+	}
+	kept := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, isPending := pending[id]; isPending {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// LoadResumeCheckpoint reads <state_dir>/resume.json.
+func LoadResumeCheckpoint(stateDir string) (*ResumeCheckpoint, error) {
+	path, err := resumeCheckpointPath(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoResumeCheckpoint
+		}
+		return nil, err
+	}
+	var checkpoint ResumeCheckpoint
+	if err := json.Unmarshal(raw, &checkpoint); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(checkpoint.Sources) == 0 {
+		return nil, ErrNoResumeCheckpoint
+	}
+	return &checkpoint, nil
+}
+
+// updateResumeCheckpoint replaces the checkpoint entries of the sources this
+// run selected: remaining lists the ones that still need work. Entries for
+// sources outside the run are kept, and the file is removed once empty.
+func updateResumeCheckpoint(stateDir string, runID string, at time.Time, selected []string, remaining []ResumeSource) error {
+	path, err := resumeCheckpointPath(stateDir)
+	if err != nil {
+		return err
+	}
+	return withStateDirLock(stateDir, func() error {
+		existing, err := LoadResumeCheckpoint(stateDir)
+		if err != nil {
+			existing = &ResumeCheckpoint{}
+		}
+		inRun := map[string]struct{}{}
+		for _, sourceID := range selected {
+			inRun[sourceID] = struct{}{}
+		}
+		sources := []ResumeSource{}
+		for _, source := range existing.Sources {
+			if _, ok := inRun[source.SourceID]; !ok {
+				sources = append(sources, source)
+			}
+		}
+		sources = append(sources, remaining...)
+
+		if len(sources) == 0 {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		checkpoint := ResumeCheckpoint{RunID: existing.RunID, InterruptedAt: existing.InterruptedAt, Sources: sources}
+		if len(remaining) > 0 {
+			checkpoint.RunID = runID
+			checkpoint.InterruptedAt = at
+		}
+		payload, err := json.MarshalIndent(checkpoint, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomically(path, ".udl-resume-*", append(payload, '\n'))
+	})
+}
+
+func resumeCheckpointPath(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, resumeCheckpointFileName), nil
+}
+
+// notePlannedTracks records the tracks a source planned to download so an
+// interrupted run can checkpoint the ones it did not get to.
+func (s *Syncer) notePlannedTracks(sourceID string, ids []string) {
+	if s.plan != nil {
+		s.plan.notePlanned(sourceID, ids)
+	}
+	if s.run == nil {
+		return
+	}
+	s.run.notePlanned(sourceID, ids)
+}
+
+func (r *runReportRecorder) notePlanned(sourceID string, ids []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	planned := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" {
+			planned = append(planned, id)
+		}
+	}
+	r.planned[strings.TrimSpace(sourceID)] = planned
+}
+
+// remainingSources lists the selected sources that did not finish in report,
+// with the planned tracks they had not yet downloaded.
+func (r *runReportRecorder) remainingSources(report RunReport) []ResumeSource {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	done := map[string]struct{}{}
+	for _, outcome := range report.Sources {
+		if outcome.Status == "finished" || outcome.Status == "skipped" {
+			done[outcome.SourceID] = struct{}{}
+		}
+	}
+	remaining := []ResumeSource{}
+	for _, sourceID := range r.selected {
+		if _, ok := done[sourceID]; ok {
+			continue
+		}
+		entry := ResumeSource{SourceID: sourceID}
+		if planned, ok := r.planned[sourceID]; ok {
+			entry.Planned = true
+			var downloaded map[string]struct{}
+			if activity := r.activity[sourceID]; activity != nil {
+				downloaded = activity.seen
+			}
+			for _, id := range planned {
+				if _, ok := downloaded[id]; !ok {
+					entry.PendingTrackIDs = append(entry.PendingTrackIDs, id)
+				}
+			}
+		}
+		remaining = append(remaining, entry)
+	}
+	return remaining
+}
+
+func (r *runReportRecorder) noteSelected(sourceID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selected = append(r.selected, sourceID)
+}
+
+func (r *runReportRecorder) selectedSourceIDs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.selected...)
+}
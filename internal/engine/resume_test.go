@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSyncInterruptWritesResumeCheckpointAndResumeRunsPendingTracks(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	runtimeDir := filepath.Join(tmp, "runtime")
+	for _, dir := range []string{targetDir, stateDir, runtimeDir, filepath.Join(tmp, "yt")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:               "artist",
+				Type:             config.SourceTypeSpotify,
+				Enabled:          true,
+				TargetDir:        targetDir,
+				URL:              "https://open.spotify.com/artist/artist0000001",
+				StateFile:        "artist.sync.spotify",
+				DeemixRuntimeDir: runtimeDir,
+				Adapter:          config.AdapterSpec{Kind: "deemix"},
+			},
+			{
+				ID:        "yt",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   true,
+				TargetDir: filepath.Join(tmp, "yt"),
+				URL:       "https://www.youtube.com/playlist?list=PL123",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+			},
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		return []spotifyRemoteTrack{
+			{ID: "1abc234def", Title: "track-1", Artist: "artist", Album: "LP"},
+			{ID: "2abc234def", Title: "track-2", Artist: "artist", Album: "LP"},
+			{ID: "3abc234def", Title: "track-3", Artist: "artist", Album: "LP"},
+		}, nil
+	}
+
+	runner := &sequenceRunner{results: []ExecResult{
+		{ExitCode: 0},
+		{ExitCode: 130, Interrupted: true},
+	}}
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixPathAdapter{}, "ytdlp": fakeAdapter{}},
+		runner,
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("expected interrupted sync, got %v", err)
+	}
+	if len(runner.specs) != 2 {
+		t.Fatalf("expected two track executions before the interrupt, got %d", len(runner.specs))
+	}
+	downloaded := trackIDFromSpec(runner.specs[0])
+
+	checkpoint, err := LoadResumeCheckpoint(stateDir)
+	if err != nil {
+		t.Fatalf("load checkpoint: %v", err)
+	}
+	if len(checkpoint.Sources) != 2 || checkpoint.RunID == "" {
+		t.Fatalf("expected both unfinished sources in checkpoint, got %+v", checkpoint)
+	}
+	artist, yt := checkpoint.Sources[0], checkpoint.Sources[1]
+	if artist.SourceID != "artist" || !artist.Planned || len(artist.PendingTrackIDs) != 2 {
+		t.Fatalf("unexpected artist checkpoint: %+v", artist)
+	}
+	for _, id := range artist.PendingTrackIDs {
+		if id == downloaded {
+			t.Fatalf("downloaded track %s left pending: %+v", downloaded, artist)
+		}
+	}
+	if yt.SourceID != "yt" || yt.Planned {
+		t.Fatalf("expected unreached source to resume with a full plan, got %+v", yt)
+	}
+
+	runner.specs = nil
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{Resume: checkpoint})
+	if err != nil {
+		t.Fatalf("resume sync: %v", err)
+	}
+	if result.Succeeded != 2 {
+		t.Fatalf("expected both sources to finish on resume, got %+v", result)
+	}
+	resumed := []string{}
+	for _, spec := range runner.specs {
+		if spec.Bin == "deemix" {
+			resumed = append(resumed, trackIDFromSpec(spec))
+		}
+	}
+	if strings.Join(resumed, ",") != strings.Join(artist.PendingTrackIDs, ",") {
+		t.Fatalf("expected resume to run only pending tracks %v, got %v", artist.PendingTrackIDs, resumed)
+	}
+	if _, err := LoadResumeCheckpoint(stateDir); !errors.Is(err, ErrNoResumeCheckpoint) {
+		t.Fatalf("expected checkpoint removed after resume, got %v", err)
+	}
+}
+
+func trackIDFromSpec(spec ExecSpec) string {
+	if len(spec.Args) == 0 {
+		return ""
+	}
+	url := spec.Args[0]
+	return url[strings.LastIndex(url, "/")+1:]
+}
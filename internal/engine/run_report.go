@@ -0,0 +1,369 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const (
+	runReportsDirName         = "runs"
+	RunReportFileName         = "report.json"
+	RunConfigSnapshotFileName = "config.yaml"
+)
+
+// RunReport is written to <state_dir>/runs/<run_id>/report.json after every
+// non-dry-run sync, next to a redacted snapshot of the resolved config.
+type RunReport struct {
+	RunID          string             `json:"run_id"`
+	StartedAt      time.Time          `json:"started_at"`
+	FinishedAt     time.Time          `json:"finished_at"`
+	SourceIDs      []string           `json:"source_ids,omitempty"`
+	ConfigSnapshot string             `json:"config_snapshot"`
+	Result         RunReportResult    `json:"result"`
+	Sources        []RunSourceOutcome `json:"sources"`
+	Error          string             `json:"error,omitempty"`
+}
+
+type RunReportResult struct {
+	Total              int  `json:"total"`
+	Attempted          int  `json:"attempted"`
+	Succeeded          int  `json:"succeeded"`
+	Failed             int  `json:"failed"`
+	Skipped            int  `json:"skipped"`
+	DependencyFailures int  `json:"dependency_failures"`
+	Interrupted        bool `json:"interrupted"`
+}
+
+type RunSourceOutcome struct {
+	SourceID string `json:"source_id"`
+	Status   string `json:"status"`
+	Message  string `json:"message,omitempty"`
+	Class    string `json:"class,omitempty"`
+	// NewTracks lists the tracks a monitor source saw for the first time.
+	NewTracks []MonitoredTrack `json:"new_tracks,omitempty"`
+}
+
+// RunReportsDir returns <state_dir>/runs.
+func RunReportsDir(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, runReportsDirName), nil
+}
+
+// runReportRecorder observes sync events to collect per-source outcomes for
+// the run report written once the sync returns.
+type runReportRecorder struct {
+	opts   SyncOptions
+	next   output.EventEmitter
+	mu     sync.Mutex
+	report RunReport
+
+	started   bool
+	index     map[string]int
+	failedAt  map[string]time.Time
+	counters  map[string]*sourceTrackCounters
+	activity  map[string]*sourceActivity
+	selected  []string
+	planned   map[string][]string
+	trackKeys map[string]sourceTrackKeys
+	// remoteTracks holds the remote order of sources with
+	// sync.playlist_file, sync.prune, or sync.source_info, noted during
+	// planning.
+	remoteTracks map[string][]playlistTrack
+	// skips holds the tracks skipped with a reason sync.skip_ttl_days
+	// remembers, by source and track id.
+	skips map[string]map[string]skipMemoryEntry
+}
+
+func newRunReportRecorder(opts SyncOptions, next output.EventEmitter) *runReportRecorder {
+	return &runReportRecorder{
+		opts:         opts,
+		next:         next,
+		index:        map[string]int{},
+		failedAt:     map[string]time.Time{},
+		counters:     map[string]*sourceTrackCounters{},
+		activity:     map[string]*sourceActivity{},
+		planned:      map[string][]string{},
+		trackKeys:    map[string]sourceTrackKeys{},
+		remoteTracks: map[string][]playlistTrack{},
+		skips:        map[string]map[string]skipMemoryEntry{},
+		report: RunReport{
+			SourceIDs: append([]string{}, opts.SourceIDs...),
+			Sources:   []RunSourceOutcome{},
+		},
+	}
+}
+
+func (r *runReportRecorder) Emit(event output.Event) error {
+	r.observe(event)
+	if r.next == nil {
+		return nil
+	}
+	return r.next.Emit(event)
+}
+
+func (r *runReportRecorder) observe(event output.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if sourceID := strings.TrimSpace(event.SourceID); sourceID != "" {
+		counters, ok := r.counters[sourceID]
+		if !ok {
+			counters = &sourceTrackCounters{}
+			r.counters[sourceID] = counters
+		}
+		counters.observe(event)
+		activity, ok := r.activity[sourceID]
+		if !ok {
+			activity = &sourceActivity{}
+			r.activity[sourceID] = activity
+		}
+		activity.observe(event)
+		if event.Event == output.EventSourcePreflight {
+			r.noteSkippedTrack(event)
+		}
+	}
+	switch event.Event {
+	case output.EventSyncStarted:
+		r.started = true
+		r.report.StartedAt = event.Timestamp.UTC()
+	case output.EventSourceFinished:
+		status := "finished"
+		if skipped, _ := event.Details["skipped"].(bool); skipped {
+			status = "skipped"
+		}
+		newTracks, _ := event.Details["new_tracks"].([]MonitoredTrack)
+		r.recordSourceLocked(event.SourceID, RunSourceOutcome{Status: status, NewTracks: newTracks}, event.Timestamp)
+	case output.EventSourceFailed:
+		r.recordSourceLocked(event.SourceID, RunSourceOutcome{
+			Status:  "failed",
+			Message: event.Message,
+			Class:   classifySourceFailure(event),
+		}, event.Timestamp)
+	}
+}
+
+// trackCounters returns the track outcomes observed so far for sourceID.
+func (r *runReportRecorder) trackCounters(sourceID string) sourceTrackCounters {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if counters, ok := r.counters[strings.TrimSpace(sourceID)]; ok {
+		return *counters
+	}
+	return sourceTrackCounters{}
+}
+
+func (r *runReportRecorder) recordSourceLocked(sourceID string, outcome RunSourceOutcome, at time.Time) {
+	sourceID = strings.TrimSpace(sourceID)
+	if sourceID == "" {
+		return
+	}
+	outcome.SourceID = sourceID
+	outcome.Message = strings.TrimSpace(outcome.Message)
+	if outcome.Status == "failed" {
+		if _, ok := r.failedAt[sourceID]; !ok {
+			r.failedAt[sourceID] = at.UTC()
+		}
+	}
+	if i, ok := r.index[sourceID]; ok {
+		// A source can finish after an earlier failure event (e.g. a retried
+		// auth step); the failure is the outcome worth keeping.
+		if r.report.Sources[i].Status == "failed" {
+			return
+		}
+		r.report.Sources[i] = outcome
+		return
+	}
+	r.index[sourceID] = len(r.report.Sources)
+	r.report.Sources = append(r.report.Sources, outcome)
+}
+
+// complete returns the final report, or false when the sync never started
+// (selection errors) or was a dry run.
+func (r *runReportRecorder) complete(finishedAt time.Time, result SyncResult, runErr error) (RunReport, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started || r.opts.DryRun {
+		return RunReport{}, false
+	}
+	report := r.report
+	report.Sources = append([]RunSourceOutcome{}, r.report.Sources...)
+	report.FinishedAt = finishedAt.UTC()
+	report.Result = RunReportResult{
+		Total:              result.Total,
+		Attempted:          result.Attempted,
+		Succeeded:          result.Succeeded,
+		Failed:             result.Failed,
+		Skipped:            result.Skipped,
+		DependencyFailures: result.DependencyFailures,
+		Interrupted:        result.Interrupted,
+	}
+	if runErr != nil {
+		report.Error = runErr.Error()
+	}
+	return report, true
+}
+
+// finishRun writes the run report, appends the history journal, records
+// downloads in the dedupe index and permanent skips in the skip memory,
+// updates the resume checkpoint, sends
+// configured notifications, and enforces state_dir retention. None of these
+// steps can change the sync result; notification and retention failures
+// surface as warnings.
+func (s *Syncer) finishRun(cfg config.Config, recorder *runReportRecorder, result SyncResult, runErr error) {
+	report, ok := recorder.complete(s.Now(), result, runErr)
+	if !ok {
+		return
+	}
+	reportPath, _ := writeRunReport(cfg, &report)
+	_ = appendHistoryEntry(cfg.Defaults.StateDir, recorder.historyEntry(report))
+	_ = updateDedupeIndex(cfg.Defaults.StateDir, recorder.downloadedTrackKeys())
+	_ = s.updateSkipMemory(cfg, recorder)
+	failureLog, _ := output.SyncFailureLogPath(cfg.Defaults.StateDir)
+	_ = updateSourceLastErrors(cfg.Defaults.StateDir, recorder.lastErrors(report, reportPath, failureLog), succeededSourceIDs(report))
+	if !recorder.opts.Plan {
+		if report.Result.Interrupted {
+			_ = updateResumeCheckpoint(cfg.Defaults.StateDir, report.RunID, report.FinishedAt, recorder.selectedSourceIDs(), recorder.remainingSources(report))
+		} else {
+			_ = updateResumeCheckpoint(cfg.Defaults.StateDir, report.RunID, report.FinishedAt, succeededSourceIDs(report), nil)
+		}
+	}
+	for _, failure := range sendRunNotifications(cfg.Defaults.Notifications, report) {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventNotificationFailed,
+			Message:   failure.Error(),
+		})
+	}
+	s.runStateGC(cfg)
+}
+
+// historyEntry condenses report into its history journal line, adding the
+// per-source durations and downloaded track ids observed during the run.
+func (r *runReportRecorder) historyEntry(report RunReport) HistoryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry := HistoryEntry{
+		RunID:      report.RunID,
+		StartedAt:  report.StartedAt,
+		FinishedAt: report.FinishedAt,
+		Result:     report.Result,
+		Sources:    make([]HistorySource, 0, len(report.Sources)),
+		Error:      report.Error,
+	}
+	if !report.StartedAt.IsZero() {
+		entry.DurationMS = report.FinishedAt.Sub(report.StartedAt).Milliseconds()
+	}
+	for _, outcome := range report.Sources {
+		activity := r.activity[outcome.SourceID]
+		source := HistorySource{
+			SourceID:   outcome.SourceID,
+			Status:     outcome.Status,
+			Message:    outcome.Message,
+			Class:      outcome.Class,
+			DurationMS: activity.duration().Milliseconds(),
+		}
+		if activity != nil {
+			source.DownloadedTrackIDs = append([]string(nil), activity.downloaded...)
+		}
+		entry.Sources = append(entry.Sources, source)
+	}
+	return entry
+}
+
+// lastErrors builds the source-errors.json entries for the sources that
+// failed in report, pointing at the report and failure logs.
+func (r *runReportRecorder) lastErrors(report RunReport, reportPath string, failureLog string) map[string]SourceLastError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	lastErrors := map[string]SourceLastError{}
+	for _, outcome := range report.Sources {
+		if outcome.Status != "failed" {
+			continue
+		}
+		at := r.failedAt[outcome.SourceID]
+		if at.IsZero() {
+			at = report.FinishedAt
+		}
+		lastErrors[outcome.SourceID] = SourceLastError{
+			Message:    outcome.Message,
+			Class:      outcome.Class,
+			At:         at,
+			RunID:      report.RunID,
+			RunReport:  reportPath,
+			FailureLog: failureLog,
+			LogFile:    strings.TrimSpace(r.opts.LogFile),
+		}
+	}
+	return lastErrors
+}
+
+func succeededSourceIDs(report RunReport) []string {
+	ids := []string{}
+	for _, outcome := range report.Sources {
+		if outcome.Status == "finished" {
+			ids = append(ids, outcome.SourceID)
+		}
+	}
+	return ids
+}
+
+func writeRunReport(cfg config.Config, report *RunReport) (string, error) {
+	root, err := RunReportsDir(cfg.Defaults.StateDir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return "", err
+	}
+	started := report.StartedAt
+	if started.IsZero() {
+		started = report.FinishedAt
+	}
+	base := started.UTC().Format("20060102T150405Z")
+	runID := base
+	runDir := filepath.Join(root, runID)
+	for attempt := 2; ; attempt++ {
+		err := os.Mkdir(runDir, 0o755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) || attempt > 100 {
+			return "", err
+		}
+		runID = fmt.Sprintf("%s-%d", base, attempt)
+		runDir = filepath.Join(root, runID)
+	}
+	report.RunID = runID
+	report.ConfigSnapshot = RunConfigSnapshotFileName
+
+	snapshot, err := config.MarshalCanonical(config.Redacted(cfg))
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(runDir, RunConfigSnapshotFileName), snapshot, 0o600); err != nil {
+		return "", err
+	}
+	payload, err := json.MarshalIndent(*report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	reportPath := filepath.Join(runDir, RunReportFileName)
+	if err := os.WriteFile(reportPath, append(payload, '\n'), 0o644); err != nil {
+		return "", err
+	}
+	return reportPath, nil
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestSyncWritesRunReportWithRedactedConfigSnapshot(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{stateDir, filepath.Join(tmp, "yt")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "yt",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   true,
+				TargetDir: filepath.Join(tmp, "yt"),
+				URL:       "https://www.youtube.com/playlist?list=PL123",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp", ExtraArgs: []string{"--cookies-from-browser", "firefox"}},
+			},
+			{
+				ID:        "tidal",
+				Type:      config.SourceTypeTidal,
+				Enabled:   true,
+				TargetDir: filepath.Join(tmp, "tidal"),
+				URL:       "https://tidal.com/browse/playlist/abc",
+				Adapter:   config.AdapterSpec{Kind: "tidal-dl"},
+			},
+		},
+	}
+
+	syncer := NewSyncer(map[string]Adapter{"ytdlp": fakeAdapter{}}, noOpRunner{}, &captureEventEmitter{})
+	now := time.Date(2026, 4, 2, 10, 30, 0, 0, time.UTC)
+	syncer.Now = func() time.Time { return now }
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{NoPreflight: true}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	runDir := filepath.Join(stateDir, "runs", "20260402T103000Z")
+	payload, err := os.ReadFile(filepath.Join(runDir, RunReportFileName))
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	report := RunReport{}
+	if err := json.Unmarshal(payload, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.RunID != "20260402T103000Z" || report.Result.Succeeded != 1 || report.Result.Failed != 1 {
+		t.Fatalf("unexpected report result: %+v", report)
+	}
+	if len(report.Sources) != 2 || report.Sources[0].Status != "finished" || report.Sources[1].Status != "failed" {
+		t.Fatalf("unexpected per-source outcomes: %+v", report.Sources)
+	}
+
+	snapshot, err := os.ReadFile(filepath.Join(runDir, report.ConfigSnapshot))
+	if err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	if strings.Contains(string(snapshot), "list=PL123") || !strings.Contains(string(snapshot), "--cookies-from-browser") {
+		t.Fatalf("unexpected config snapshot: %s", snapshot)
+	}
+	if !strings.Contains(string(snapshot), "<redacted>") {
+		t.Fatalf("expected cookie flag value to be redacted: %s", snapshot)
+	}
+
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{NoPreflight: true, DryRun: true}); err != nil {
+		t.Fatalf("dry-run sync: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(stateDir, "runs"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected dry runs to skip reports, got %d entries (%v)", len(entries), err)
+	}
+}
@@ -0,0 +1,294 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// skipMemoryFileName holds every source's remembered skips in state_dir. They
+// stay out of the sources' state files: scdl reads the SoundCloud state file
+// as its --sync list, and verify, prune, and the state tools treat each entry
+// there as a downloaded track.
+const skipMemoryFileName = "skipped-tracks.json"
+
+// rememberedSkipReasons are the skip reasons that mean a track will keep
+// failing the same way, so sources with sync.skip_ttl_days stop checking it.
+var rememberedSkipReasons = map[string]struct{}{
+	"unavailable-on-deezer": {},
+	"no-free-download-link": {},
+}
+
+// RememberedSkip is a track a source skipped as permanently unavailable,
+// kept in <state_dir>/skipped-tracks.json. The planner leaves it out until
+// sync.skip_ttl_days after At, or until `udl retry-skipped` clears it.
+type RememberedSkip struct {
+	SourceID string    `json:"source_id"`
+	TrackID  string    `json:"track_id"`
+	Reason   string    `json:"reason"`
+	Label    string    `json:"label,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+type skipMemoryEntry struct {
+	Reason string    `json:"reason"`
+	Label  string    `json:"label,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+type skipMemoryFile struct {
+	Sources map[string]map[string]skipMemoryEntry `json:"sources"`
+}
+
+func skipTTL(source config.Source) time.Duration {
+	return time.Duration(source.Sync.SkipTTLDays) * 24 * time.Hour
+}
+
+func loadSkipMemory(stateDir string) (map[string]map[string]skipMemoryEntry, error) {
+	path, err := skipMemoryPath(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]map[string]skipMemoryEntry{}, nil
+		}
+		return nil, err
+	}
+	var payload skipMemoryFile
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if payload.Sources == nil {
+		payload.Sources = map[string]map[string]skipMemoryEntry{}
+	}
+	return payload.Sources, nil
+}
+
+func saveSkipMemory(stateDir string, sources map[string]map[string]skipMemoryEntry) error {
+	path, err := skipMemoryPath(stateDir)
+	if err != nil {
+		return err
+	}
+	for sourceID, skips := range sources {
+		if len(skips) == 0 {
+			delete(sources, sourceID)
+		}
+	}
+	if len(sources) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	payload, err := json.MarshalIndent(skipMemoryFile{Sources: sources}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(path, ".udl-skips-*", append(payload, '\n'))
+}
+
+func skipMemoryPath(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, skipMemoryFileName), nil
+}
+
+// skipRememberedTracks drops planned track ids the source skipped as
+// permanently unavailable less than sync.skip_ttl_days ago. The "[skip]"
+// line it emits per dropped track keeps the original reason, so success
+// criteria and plan reports count it as before.
+func (s *Syncer) skipRememberedTracks(cfg config.Config, source config.Source, plannedIDs []string, tracks []playlistTrack) ([]string, int) {
+	ttl := skipTTL(source)
+	if ttl <= 0 || len(plannedIDs) == 0 {
+		return plannedIDs, 0
+	}
+	memory, err := loadSkipMemory(cfg.Defaults.StateDir)
+	if err != nil || len(memory[source.ID]) == 0 {
+		return plannedIDs, 0
+	}
+	labels := make(map[string]string, len(tracks))
+	for _, track := range tracks {
+		labels[track.ID] = track.Label
+	}
+
+	now := s.Now()
+	kept := make([]string, 0, len(plannedIDs))
+	skipped := 0
+	for _, id := range plannedIDs {
+		skip, ok := memory[source.ID][id]
+		if !ok || !now.Before(skip.At.Add(ttl)) {
+			kept = append(kept, id)
+			continue
+		}
+		skipped++
+		label := labels[id]
+		if label == "" {
+			label = id
+		}
+		recheck := skip.At.Add(ttl).Local().Format("2006-01-02")
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: now,
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [skip] %s (%s) remembered, rechecked after %s (%s)", source.ID, id, label, recheck, skip.Reason),
+			Details: map[string]any{
+				"track_id":   id,
+				"reason":     skip.Reason,
+				"remembered": true,
+				"skipped_at": skip.At,
+			},
+		})
+	}
+	return kept, skipped
+}
+
+// noteSkippedTrack collects a "[skip]" preflight line with a remembered
+// reason so finishRun can record it. Lines for tracks that were already
+// remembered are ignored, which keeps their original timestamp.
+func (r *runReportRecorder) noteSkippedTrack(event output.Event) {
+	if remembered, _ := event.Details["remembered"].(bool); remembered {
+		return
+	}
+	line := strings.TrimPrefix(event.Message, "["+event.SourceID+"] ")
+	if !strings.HasPrefix(line, "[skip] ") {
+		return
+	}
+	reason := lastPreflightSkipReason(line)
+	if _, ok := rememberedSkipReasons[reason]; !ok {
+		return
+	}
+	id, rest, _ := strings.Cut(strings.TrimPrefix(line, "[skip] "), " ")
+	if id == "" {
+		return
+	}
+	label := strings.TrimSuffix(strings.TrimSuffix(rest, " ("+reason+")"), ")")
+	label = strings.TrimPrefix(label, "(")
+	if r.skips[event.SourceID] == nil {
+		r.skips[event.SourceID] = map[string]skipMemoryEntry{}
+	}
+	r.skips[event.SourceID][id] = skipMemoryEntry{Reason: reason, Label: label, At: event.Timestamp.UTC()}
+}
+
+// updateSkipMemory records the run's remembered skips for sources with
+// sync.skip_ttl_days and drops their entries that outlived the TTL. A track
+// only leaves the memory by expiring or through `udl retry-skipped`.
+func (s *Syncer) updateSkipMemory(cfg config.Config, recorder *runReportRecorder) error {
+	recorder.mu.Lock()
+	noted := recorder.skips
+	recorder.mu.Unlock()
+
+	ttls := map[string]time.Duration{}
+	for _, source := range cfg.Sources {
+		if ttl := skipTTL(source); ttl > 0 {
+			ttls[source.ID] = ttl
+		}
+	}
+	if len(ttls) == 0 {
+		return nil
+	}
+	return withStateDirLock(cfg.Defaults.StateDir, func() error {
+		memory, err := loadSkipMemory(cfg.Defaults.StateDir)
+		if err != nil {
+			return err
+		}
+		changed := false
+		now := s.Now()
+		for sourceID, ttl := range ttls {
+			for id, skip := range memory[sourceID] {
+				if !now.Before(skip.At.Add(ttl)) {
+					delete(memory[sourceID], id)
+					changed = true
+				}
+			}
+			for id, skip := range noted[sourceID] {
+				if memory[sourceID] == nil {
+					memory[sourceID] = map[string]skipMemoryEntry{}
+				}
+				memory[sourceID][id] = skip
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		return saveSkipMemory(cfg.Defaults.StateDir, memory)
+	})
+}
+
+// ClearRememberedSkips forgets the remembered skips of the selected sources,
+// limited to trackIDs when given, so the next sync checks those tracks
+// again. It returns what was (or, with dryRun, would be) cleared.
+func ClearRememberedSkips(cfg config.Config, sourceIDs []string, trackIDs []string, dryRun bool) ([]RememberedSkip, error) {
+	if _, err := selectSources(cfg.Sources, sourceIDs); err != nil {
+		return nil, err
+	}
+	if dryRun {
+		memory, err := loadSkipMemory(cfg.Defaults.StateDir)
+		if err != nil {
+			return nil, err
+		}
+		return matchingRememberedSkips(memory, sourceIDs, trackIDs), nil
+	}
+	var cleared []RememberedSkip
+	err := withStateDirLock(cfg.Defaults.StateDir, func() error {
+		memory, err := loadSkipMemory(cfg.Defaults.StateDir)
+		if err != nil {
+			return err
+		}
+		cleared = matchingRememberedSkips(memory, sourceIDs, trackIDs)
+		if len(cleared) == 0 {
+			return nil
+		}
+		for _, skip := range cleared {
+			delete(memory[skip.SourceID], skip.TrackID)
+		}
+		return saveSkipMemory(cfg.Defaults.StateDir, memory)
+	})
+	return cleared, err
+}
+
+func matchingRememberedSkips(memory map[string]map[string]skipMemoryEntry, sourceIDs []string, trackIDs []string) []RememberedSkip {
+	sources := map[string]struct{}{}
+	for _, id := range sourceIDs {
+		sources[id] = struct{}{}
+	}
+	tracks := map[string]struct{}{}
+	for _, id := range trackIDs {
+		tracks[strings.TrimSpace(id)] = struct{}{}
+	}
+	out := []RememberedSkip{}
+	for sourceID, skips := range memory {
+		if _, ok := sources[sourceID]; len(sources) > 0 && !ok {
+			continue
+		}
+		for trackID, skip := range skips {
+			if _, ok := tracks[trackID]; len(tracks) > 0 && !ok {
+				continue
+			}
+			out = append(out, RememberedSkip{SourceID: sourceID, TrackID: trackID, Reason: skip.Reason, Label: skip.Label, At: skip.At})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].SourceID != out[j].SourceID {
+			return out[i].SourceID < out[j].SourceID
+		}
+		return out[i].TrackID < out[j].TrackID
+	})
+	return out
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestUpdateSkipMemoryRecordsPermanentSkipsAndExpiresOldOnes(t *testing.T) {
+	stateDir := t.TempDir()
+	now := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	if err := saveSkipMemory(stateDir, map[string]map[string]skipMemoryEntry{
+		"dz": {
+			"old":  {Reason: "unavailable-on-deezer", At: now.AddDate(0, 0, -8)},
+			"kept": {Reason: "unavailable-on-deezer", Label: "Kept", At: now.AddDate(0, 0, -2)},
+		},
+	}); err != nil {
+		t.Fatalf("seed skip memory: %v", err)
+	}
+
+	recorder := newRunReportRecorder(SyncOptions{}, nil)
+	for _, message := range []string{
+		"[dz] [skip] 3135556 (Artist - Title (Live)) (unavailable-on-deezer)",
+		"[dz] [skip] 42 (Artist - Slow) (unavailable-at-requested-quality)",
+		"[dz] [skip] kept (Kept) remembered, rechecked after 2026-10-19 (unavailable-on-deezer)",
+		"[sc] [skip] 777 (Gate) (no-free-download-link)",
+	} {
+		event := output.Event{Timestamp: now, Event: output.EventSourcePreflight, SourceID: strings.Trim(strings.Fields(message)[0], "[]"), Message: message}
+		if strings.Contains(message, "remembered") {
+			event.Details = map[string]any{"remembered": true}
+		}
+		_ = recorder.Emit(event)
+	}
+
+	syncer := NewSyncer(nil, nil, &captureEventEmitter{})
+	syncer.Now = func() time.Time { return now }
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir},
+		Sources: []config.Source{
+			{ID: "dz", Sync: config.SyncPolicy{SkipTTLDays: 7}},
+			{ID: "sc"},
+		},
+	}
+	if err := syncer.updateSkipMemory(cfg, recorder); err != nil {
+		t.Fatalf("update skip memory: %v", err)
+	}
+	memory, err := loadSkipMemory(stateDir)
+	if err != nil {
+		t.Fatalf("load skip memory: %v", err)
+	}
+	want := map[string]map[string]skipMemoryEntry{
+		"dz": {
+			"3135556": {Reason: "unavailable-on-deezer", Label: "Artist - Title (Live)", At: now},
+			"kept":    {Reason: "unavailable-on-deezer", Label: "Kept", At: now.AddDate(0, 0, -2)},
+		},
+	}
+	if !reflect.DeepEqual(memory, want) {
+		t.Fatalf("unexpected skip memory:\n%+v\nwant:\n%+v", memory, want)
+	}
+}
+
+func TestPrepareSoundCloudExecutionPlanLeavesOutRememberedSkips(t *testing.T) {
+	cfg, source := newSyncLimitTestSource(t, "")
+	source.Sync.SkipTTLDays = 7
+	now := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+	if err := saveSkipMemory(cfg.Defaults.StateDir, map[string]map[string]skipMemoryEntry{
+		source.ID: {
+			"333": {Reason: "no-free-download-link", At: now.AddDate(0, 0, -1)},
+			"444": {Reason: "no-free-download-link", At: now.AddDate(0, 0, -7)},
+		},
+	}); err != nil {
+		t.Fatalf("seed skip memory: %v", err)
+	}
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeAdapter{}}, noOpRunner{}, emitter)
+	syncer.Now = func() time.Time { return now }
+	plan, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{})
+	if err != nil {
+		t.Fatalf("prepare plan: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanupTempStateFiles(plan.StateSwap) })
+
+	if plan.Preflight == nil || plan.Preflight.PlannedDownloadCount != 4 || plan.Preflight.RememberedSkipped != 1 {
+		t.Fatalf("expected only the unexpired skip left out, got %+v", plan.Preflight)
+	}
+	if !reflect.DeepEqual(plan.Source.SelectedPlaylistIDs, []int{1, 2, 4, 5}) {
+		t.Fatalf("expected scdl held to the other tracks, got %v", plan.Source.SelectedPlaylistIDs)
+	}
+	found := false
+	for _, event := range emitter.events {
+		if strings.HasPrefix(event.Message, "[sc-likes] [skip] 333 (Gap) remembered") {
+			found = true
+			if reason := lastPreflightSkipReason(event.Message); reason != "no-free-download-link" {
+				t.Fatalf("expected the original skip reason, got %q", reason)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected a remembered skip line, got %+v", emitter.events)
+	}
+}
+
+func TestClearRememberedSkipsForgetsSelectedTracks(t *testing.T) {
+	stateDir := t.TempDir()
+	at := time.Date(2026, 10, 10, 8, 0, 0, 0, time.UTC)
+	if err := saveSkipMemory(stateDir, map[string]map[string]skipMemoryEntry{
+		"dz": {"1": {Reason: "unavailable-on-deezer", At: at}, "2": {Reason: "unavailable-on-deezer", At: at}},
+		"sc": {"3": {Reason: "no-free-download-link", At: at}},
+	}); err != nil {
+		t.Fatalf("seed skip memory: %v", err)
+	}
+	cfg := config.Config{Defaults: config.Defaults{StateDir: stateDir}, Sources: []config.Source{{ID: "dz"}, {ID: "sc"}}}
+
+	preview, err := ClearRememberedSkips(cfg, []string{"dz"}, nil, true)
+	if err != nil || len(preview) != 2 || preview[0].TrackID != "1" || preview[0].SourceID != "dz" {
+		t.Fatalf("unexpected preview %+v (%v)", preview, err)
+	}
+	if memory, _ := loadSkipMemory(stateDir); len(memory["dz"]) != 2 {
+		t.Fatalf("preview must not change the skip memory, got %+v", memory)
+	}
+
+	cleared, err := ClearRememberedSkips(cfg, nil, []string{"2", "3"}, false)
+	if err != nil || len(cleared) != 2 {
+		t.Fatalf("unexpected clear %+v (%v)", cleared, err)
+	}
+	memory, _ := loadSkipMemory(stateDir)
+	if !reflect.DeepEqual(memory, map[string]map[string]skipMemoryEntry{"dz": {"1": {Reason: "unavailable-on-deezer", At: at}}}) {
+		t.Fatalf("unexpected skip memory after clear: %+v", memory)
+	}
+
+	var selectionErr *SelectionError
+	if _, err := ClearRememberedSkips(cfg, []string{"missing"}, nil, false); !errors.As(err, &selectionErr) {
+		t.Fatalf("expected a selection error, got %v", err)
+	}
+}
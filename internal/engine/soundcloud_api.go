@@ -0,0 +1,403 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/httpcache"
+)
+
+const (
+	soundCloudAPIPageSize     = 200
+	soundCloudAPIHydrateBatch = 50
+	soundCloudAPIMaxPages     = 500
+)
+
+var (
+	soundCloudAPIBaseURL    = "https://api-v2.soundcloud.com"
+	soundCloudAPIHTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+	resolveSoundCloudAPIClientIDFn = resolveSoundCloudAPIClientID
+)
+
+// errSoundCloudAPIUnsupported marks list URLs the native client does not
+// handle (comments), so callers go straight to yt-dlp.
+var errSoundCloudAPIUnsupported = errors.New("soundcloud api enumeration does not support this url")
+
+// soundCloudAPIClient enumerates SoundCloud tracks through the public
+// api-v2 endpoints the web app uses, authenticated only by a client_id.
+type soundCloudAPIClient struct {
+	BaseURL  string
+	HTTP     *http.Client
+	ClientID string
+	// OAuthToken, from a source's cookies_file, lets api-v2 list the
+	// private tracks that session can see.
+	OAuthToken string
+	// Observe, when set, receives the hydrated tracks of each enumeration.
+	Observe func([]soundCloudAPITrack)
+}
+
+type soundCloudAPITrack struct {
+	ID           int64  `json:"id"`
+	Kind         string `json:"kind"`
+	Title        string `json:"title"`
+	PermalinkURL string `json:"permalink_url"`
+	Duration     int64  `json:"duration"`
+	Genre        string `json:"genre"`
+	ArtworkURL   string `json:"artwork_url"`
+	PurchaseURL  string `json:"purchase_url"`
+	User         struct {
+		Username  string `json:"username"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"user"`
+}
+
+type soundCloudAPIPlaylist struct {
+	ID     int64                `json:"id"`
+	Kind   string               `json:"kind"`
+	Tracks []soundCloudAPITrack `json:"tracks"`
+}
+
+type soundCloudAPIResource struct {
+	ID     int64                `json:"id"`
+	Kind   string               `json:"kind"`
+	Title  string               `json:"title"`
+	Tracks []soundCloudAPITrack `json:"tracks"`
+
+	PermalinkURL string `json:"permalink_url"`
+}
+
+type soundCloudAPICollectionItem struct {
+	Type     string                 `json:"type"`
+	Track    *soundCloudAPITrack    `json:"track"`
+	Playlist *soundCloudAPIPlaylist `json:"playlist"`
+	// /users/{id}/tracks and /users/{id}/playlists return bare resources.
+	ID     int64                `json:"id"`
+	Kind   string               `json:"kind"`
+	Title  string               `json:"title"`
+	Tracks []soundCloudAPITrack `json:"tracks"`
+
+	PermalinkURL string `json:"permalink_url"`
+	Duration     int64  `json:"duration"`
+	Genre        string `json:"genre"`
+	ArtworkURL   string `json:"artwork_url"`
+	PurchaseURL  string `json:"purchase_url"`
+	User         struct {
+		Username  string `json:"username"`
+		AvatarURL string `json:"avatar_url"`
+	} `json:"user"`
+}
+
+type soundCloudAPIPage struct {
+	Collection []soundCloudAPICollectionItem `json:"collection"`
+	NextHref   string                        `json:"next_href"`
+}
+
+// resolveSoundCloudAPIClientID prefers the stored client_id and otherwise
+// scrapes a current one from the web app; nothing is persisted here.
+func resolveSoundCloudAPIClientID(ctx context.Context) (string, error) {
+	if clientID, err := auth.ResolveSoundCloudClientID(); err == nil && strings.TrimSpace(clientID) != "" {
+		return strings.TrimSpace(clientID), nil
+	}
+	return auth.RefreshSoundCloudClientID(ctx)
+}
+
+// enumerateSoundCloudTracksViaAPI lists the tracks scdl would download for
+// source: likes, uploads, reposts, all (uploads+reposts), or playlists for a
+// profile URL, the tracks of a set or single track URL, and the tracks of a
+// station.
+func enumerateSoundCloudTracksViaAPI(ctx context.Context, source config.Source, limit int) ([]soundCloudRemoteTrack, error) {
+	clientID, err := resolveSoundCloudAPIClientIDFn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	oauthToken, err := resolveSoundCloudSourceOAuthToken(source)
+	if err != nil {
+		return nil, err
+	}
+	client := soundCloudAPIClient{BaseURL: soundCloudAPIBaseURL, HTTP: soundCloudAPIHTTPClient, ClientID: clientID, OAuthToken: oauthToken}
+	if cache := soundCloudMetadataCacheFrom(ctx); cache != nil {
+		client.Observe = func(tracks []soundCloudAPITrack) { cache.storeAPITracks(source.ID, tracks) }
+	}
+	listURL, mode := soundCloudListTarget(source)
+	return client.Enumerate(ctx, listURL, mode, limit)
+}
+
+// resolveSoundCloudSourceOAuthToken reads the OAuth token from the source's
+// cookies_file, falling back to the stored OAuth session. A file without one
+// enumerates anonymously.
+func resolveSoundCloudSourceOAuthToken(source config.Source) (string, error) {
+	cookiesPath, err := config.ResolveCookiesFile(source)
+	if err != nil {
+		return "", err
+	}
+	if cookiesPath == "" {
+		return strings.TrimSpace(source.SoundCloudOAuthToken), nil
+	}
+	token, err := auth.ReadSoundCloudOAuthToken(cookiesPath)
+	if errors.Is(err, auth.ErrSoundCloudCookiesOAuthNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cookies_file %s: %w", cookiesPath, err)
+	}
+	return token, nil
+}
+
+func (c soundCloudAPIClient) Enumerate(ctx context.Context, rawURL string, mode string, limit int) ([]soundCloudRemoteTrack, error) {
+	if config.IsSoundCloudStationURL(rawURL) {
+		return c.enumerateStation(ctx, rawURL, limit)
+	}
+	var resource soundCloudAPIResource
+	if err := c.getJSON(ctx, c.endpoint("/resolve", url.Values{"url": {rawURL}}), &resource); err != nil {
+		return nil, fmt.Errorf("resolve %s: %w", rawURL, err)
+	}
+
+	switch resource.Kind {
+	case "track":
+		return []soundCloudRemoteTrack{soundCloudAPITrack{
+			ID:           resource.ID,
+			Title:        resource.Title,
+			PermalinkURL: resource.PermalinkURL,
+		}.remote()}, nil
+	case "playlist":
+		return c.hydrate(ctx, resource.Tracks, limit)
+	case "user":
+	default:
+		return nil, fmt.Errorf("resolve %s: unexpected resource kind %q", rawURL, resource.Kind)
+	}
+
+	userID := strconv.FormatInt(resource.ID, 10)
+	var path string
+	switch mode {
+	case "-f":
+		path = "/users/" + userID + "/track_likes"
+	case "-t":
+		path = "/users/" + userID + "/tracks"
+	case "-r":
+		path = "/stream/users/" + userID + "/reposts"
+	case "-a":
+		path = "/stream/users/" + userID
+	case "-p":
+		path = "/users/" + userID + "/playlists"
+	default:
+		return nil, errSoundCloudAPIUnsupported
+	}
+
+	collected, err := c.listCollection(ctx, path, limit)
+	if err != nil {
+		return nil, err
+	}
+	return c.hydrate(ctx, collected, limit)
+}
+
+// enumerateStation lists the tracks of a track or artist station. Stations
+// are generated from a seed track or artist and never run out, so callers
+// pass a limit.
+func (c soundCloudAPIClient) enumerateStation(ctx context.Context, rawURL string, limit int) ([]soundCloudRemoteTrack, error) {
+	urn, err := c.resolveStationURN(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	collected, err := c.listCollection(ctx, "/stations/"+url.PathEscape(urn)+"/tracks", limit)
+	if err != nil {
+		return nil, err
+	}
+	return c.hydrate(ctx, collected, limit)
+}
+
+// resolveStationURN maps a station URL to its soundcloud:track-stations:<id>
+// or soundcloud:artist-stations:<id> URN, resolving the seed track or artist
+// when the URL names it by permalink.
+func (c soundCloudAPIClient) resolveStationURN(ctx context.Context, rawURL string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", fmt.Errorf("parse station url %s: %w", rawURL, err)
+	}
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if strings.EqualFold(segments[0], "discover") {
+		return "soundcloud:" + strings.ToLower(segments[2]), nil
+	}
+	kind := strings.ToLower(segments[1])
+	seedURL := "https://soundcloud.com/" + strings.Join(segments[2:], "/")
+	var seed soundCloudAPIResource
+	if err := c.getJSON(ctx, c.endpoint("/resolve", url.Values{"url": {seedURL}}), &seed); err != nil {
+		return "", fmt.Errorf("resolve station seed %s: %w", seedURL, err)
+	}
+	if (kind == "track" && seed.Kind != "track") || (kind == "artist" && seed.Kind != "user") || seed.ID == 0 {
+		return "", fmt.Errorf("resolve station seed %s: unexpected resource kind %q", seedURL, seed.Kind)
+	}
+	return fmt.Sprintf("soundcloud:%s-stations:%d", kind, seed.ID), nil
+}
+
+// listCollection pages through an api-v2 collection endpoint until limit
+// tracks are collected, or to its end when limit is 0.
+func (c soundCloudAPIClient) listCollection(ctx context.Context, path string, limit int) ([]soundCloudAPITrack, error) {
+	collected := []soundCloudAPITrack{}
+	next := c.endpoint(path, url.Values{"limit": {strconv.Itoa(soundCloudAPIPageSize)}, "linked_partitioning": {"1"}})
+	for page := 0; next != "" && page < soundCloudAPIMaxPages; page++ {
+		var payload soundCloudAPIPage
+		if err := c.getJSON(ctx, next, &payload); err != nil {
+			return nil, fmt.Errorf("list %s: %w", path, err)
+		}
+		for _, item := range payload.Collection {
+			collected = append(collected, item.tracks()...)
+		}
+		if limit > 0 && len(collected) >= limit {
+			break
+		}
+		next = c.withClientID(payload.NextHref)
+	}
+	return collected, nil
+}
+
+func (item soundCloudAPICollectionItem) tracks() []soundCloudAPITrack {
+	switch {
+	case item.Track != nil:
+		return []soundCloudAPITrack{*item.Track}
+	case item.Playlist != nil:
+		return item.Playlist.Tracks
+	case item.Kind == "track":
+		track := soundCloudAPITrack{
+			ID:           item.ID,
+			Kind:         item.Kind,
+			Title:        item.Title,
+			PermalinkURL: item.PermalinkURL,
+			Duration:     item.Duration,
+			Genre:        item.Genre,
+			ArtworkURL:   item.ArtworkURL,
+			PurchaseURL:  item.PurchaseURL,
+		}
+		track.User.Username = item.User.Username
+		track.User.AvatarURL = item.User.AvatarURL
+		return []soundCloudAPITrack{track}
+	case item.Kind == "playlist":
+		return item.Tracks
+	default:
+		return nil
+	}
+}
+
+// hydrate de-duplicates tracks, applies limit, and fills in the stub entries
+// (id only) that playlists return beyond their first few tracks.
+func (c soundCloudAPIClient) hydrate(ctx context.Context, tracks []soundCloudAPITrack, limit int) ([]soundCloudRemoteTrack, error) {
+	ordered := make([]soundCloudAPITrack, 0, len(tracks))
+	seen := map[int64]struct{}{}
+	for _, track := range tracks {
+		if track.ID == 0 {
+			continue
+		}
+		if _, ok := seen[track.ID]; ok {
+			continue
+		}
+		seen[track.ID] = struct{}{}
+		ordered = append(ordered, track)
+		if limit > 0 && len(ordered) >= limit {
+			break
+		}
+	}
+
+	stubs := []string{}
+	for _, track := range ordered {
+		if strings.TrimSpace(track.PermalinkURL) == "" {
+			stubs = append(stubs, strconv.FormatInt(track.ID, 10))
+		}
+	}
+	full := map[int64]soundCloudAPITrack{}
+	for start := 0; start < len(stubs); start += soundCloudAPIHydrateBatch {
+		end := start + soundCloudAPIHydrateBatch
+		if end > len(stubs) {
+			end = len(stubs)
+		}
+		var batch []soundCloudAPITrack
+		if err := c.getJSON(ctx, c.endpoint("/tracks", url.Values{"ids": {strings.Join(stubs[start:end], ",")}}), &batch); err != nil {
+			return nil, fmt.Errorf("hydrate tracks: %w", err)
+		}
+		for _, track := range batch {
+			full[track.ID] = track
+		}
+	}
+
+	remote := make([]soundCloudRemoteTrack, 0, len(ordered))
+	for i, track := range ordered {
+		if hydrated, ok := full[track.ID]; ok {
+			track = hydrated
+			ordered[i] = hydrated
+		}
+		remote = append(remote, track.remote())
+	}
+	if c.Observe != nil {
+		c.Observe(ordered)
+	}
+	return remote, nil
+}
+
+func (t soundCloudAPITrack) remote() soundCloudRemoteTrack {
+	return soundCloudRemoteTrack{
+		ID:         strconv.FormatInt(t.ID, 10),
+		Title:      strings.TrimSpace(t.Title),
+		URL:        strings.TrimSpace(t.PermalinkURL),
+		DurationMS: t.Duration,
+	}
+}
+
+func (c soundCloudAPIClient) endpoint(path string, query url.Values) string {
+	query.Set("client_id", c.ClientID)
+	return strings.TrimSuffix(c.BaseURL, "/") + path + "?" + query.Encode()
+}
+
+// withClientID re-adds client_id to next_href, which SoundCloud omits.
+func (c soundCloudAPIClient) withClientID(rawURL string) string {
+	if strings.TrimSpace(rawURL) == "" {
+		return ""
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	query := parsed.Query()
+	query.Set("client_id", c.ClientID)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func (c soundCloudAPIClient) getJSON(ctx context.Context, rawURL string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	client := httpcache.Client(ctx, c.HTTP)
+	if c.OAuthToken != "" {
+		// Responses for a signed-in session stay out of the shared cache.
+		client = c.HTTP
+		req.Header.Set("Authorization", "OAuth "+c.OAuthToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactSoundCloudClientID(err, c.ClientID)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// redactSoundCloudClientID keeps the client_id out of transport errors,
+// which embed the request URL.
+func redactSoundCloudClientID(err error, clientID string) error {
+	if strings.TrimSpace(clientID) == "" {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), clientID, "***"))
+}
@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/httpcache"
+)
+
+func TestSoundCloudAPIClientEnumeratesLikesAcrossPages(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("client_id") != "cid" {
+			http.Error(w, "missing client id", http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/resolve":
+			fmt.Fprint(w, `{"id": 42, "kind": "user"}`)
+		case r.URL.Path == "/users/42/track_likes" && r.URL.Query().Get("offset") == "":
+			fmt.Fprintf(w, `{"collection": [
+				{"track": {"id": 3, "kind": "track", "title": "Three", "permalink_url": "https://soundcloud.com/a/three"}},
+				{"track": {"id": 2, "kind": "track", "title": "Two", "permalink_url": "https://soundcloud.com/a/two"}}
+			], "next_href": "%s/users/42/track_likes?offset=2&limit=200"}`, server.URL)
+		case r.URL.Path == "/users/42/track_likes":
+			fmt.Fprint(w, `{"collection": [
+				{"track": {"id": 2, "kind": "track", "title": "Two", "permalink_url": "https://soundcloud.com/a/two"}},
+				{"track": {"id": 1, "kind": "track", "title": "One", "permalink_url": "https://soundcloud.com/a/one"}}
+			], "next_href": null}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := soundCloudAPIClient{BaseURL: server.URL, HTTP: server.Client(), ClientID: "cid"}
+	tracks, err := client.Enumerate(context.Background(), "https://soundcloud.com/a", "-f", 0)
+	if err != nil {
+		t.Fatalf("enumerate: %v", err)
+	}
+	want := []soundCloudRemoteTrack{
+		{ID: "3", Title: "Three", URL: "https://soundcloud.com/a/three"},
+		{ID: "2", Title: "Two", URL: "https://soundcloud.com/a/two"},
+		{ID: "1", Title: "One", URL: "https://soundcloud.com/a/one"},
+	}
+	if !reflect.DeepEqual(tracks, want) {
+		t.Fatalf("unexpected tracks: %+v", tracks)
+	}
+
+	limited, err := client.Enumerate(context.Background(), "https://soundcloud.com/a", "-f", 1)
+	if err != nil {
+		t.Fatalf("enumerate limited: %v", err)
+	}
+	if len(limited) != 1 || limited[0].ID != "3" {
+		t.Fatalf("expected first liked track only, got %+v", limited)
+	}
+}
+
+func TestSoundCloudAPIClientEnumeratesStations(t *testing.T) {
+	var resolved []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/resolve":
+			resolved = append(resolved, r.URL.Query().Get("url"))
+			switch r.URL.Query().Get("url") {
+			case "https://soundcloud.com/a/seed":
+				fmt.Fprint(w, `{"id": 7, "kind": "track"}`)
+			case "https://soundcloud.com/a":
+				fmt.Fprint(w, `{"id": 42, "kind": "user"}`)
+			default:
+				http.NotFound(w, r)
+			}
+		case "/stations/soundcloud:track-stations:7/tracks", "/stations/soundcloud:artist-stations:42/tracks", "/stations/soundcloud:track-stations:9/tracks":
+			fmt.Fprint(w, `{"collection": [
+				{"id": 11, "kind": "track", "title": "Eleven", "permalink_url": "https://soundcloud.com/b/eleven"},
+				{"id": 12, "kind": "track", "title": "Twelve", "permalink_url": "https://soundcloud.com/c/twelve"},
+				{"id": 13, "kind": "track", "title": "Thirteen", "permalink_url": "https://soundcloud.com/d/thirteen"}
+			], "next_href": null}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.String())
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := soundCloudAPIClient{BaseURL: server.URL, HTTP: server.Client(), ClientID: "cid"}
+	for _, rawURL := range []string{
+		"https://soundcloud.com/stations/track/a/seed",
+		"https://soundcloud.com/stations/artist/a",
+		"https://soundcloud.com/discover/sets/track-stations:9",
+	} {
+		tracks, err := client.Enumerate(context.Background(), rawURL, "-f", 2)
+		if err != nil {
+			t.Fatalf("enumerate %s: %v", rawURL, err)
+		}
+		if len(tracks) != 2 || tracks[0].ID != "11" || tracks[1].ID != "12" {
+			t.Fatalf("%s: expected the first two station tracks, got %+v", rawURL, tracks)
+		}
+	}
+	if strings.Join(resolved, ",") != "https://soundcloud.com/a/seed,https://soundcloud.com/a" {
+		t.Fatalf("unexpected seed lookups %v", resolved)
+	}
+}
+
+func TestSoundCloudAPIClientRevalidatesThroughHTTPCache(t *testing.T) {
+	fullResponses := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + r.URL.Path + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		w.Header().Set("ETag", etag)
+		switch r.URL.Path {
+		case "/resolve":
+			fmt.Fprint(w, `{"id": 42, "kind": "user"}`)
+		case "/users/42/track_likes":
+			fmt.Fprint(w, `{"collection": [{"track": {"id": 1, "kind": "track", "title": "One", "permalink_url": "https://soundcloud.com/a/one"}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	ctx := httpcache.NewContext(context.Background(), httpcache.New(t.TempDir()))
+	for run := 0; run < 2; run++ {
+		client := soundCloudAPIClient{BaseURL: server.URL, HTTP: server.Client(), ClientID: fmt.Sprintf("cid-%d", run)}
+		tracks, err := client.Enumerate(ctx, "https://soundcloud.com/a", "-f", 0)
+		if err != nil {
+			t.Fatalf("run %d: enumerate: %v", run, err)
+		}
+		if len(tracks) != 1 || tracks[0].Title != "One" {
+			t.Fatalf("run %d: unexpected tracks %+v", run, tracks)
+		}
+	}
+	if fullResponses != 2 {
+		t.Fatalf("expected the second run to be served by revalidation, got %d full responses", fullResponses)
+	}
+}
+
+func TestSoundCloudAPIClientSendsCookiesOAuthTokenAndBypassesCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if got := r.Header.Get("Authorization"); got != "OAuth secret-oauth" {
+			http.Error(w, "signed out", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("If-None-Match") != "" {
+			t.Errorf("expected no revalidation of signed-in responses")
+		}
+		w.Header().Set("ETag", `"private"`)
+		switch r.URL.Path {
+		case "/resolve":
+			fmt.Fprint(w, `{"id": 42, "kind": "user"}`)
+		case "/users/42/track_likes":
+			fmt.Fprint(w, `{"collection": [{"track": {"id": 9, "kind": "track", "title": "Private", "permalink_url": "https://soundcloud.com/a/private"}}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cookiesPath := filepath.Join(t.TempDir(), "cookies.txt")
+	payload := "#HttpOnly_.soundcloud.com\tTRUE\t/\tTRUE\t0\toauth_token\tsecret-oauth\n"
+	if err := os.WriteFile(cookiesPath, []byte(payload), 0o600); err != nil {
+		t.Fatalf("write cookies: %v", err)
+	}
+	token, err := resolveSoundCloudSourceOAuthToken(config.Source{CookiesFile: cookiesPath})
+	if err != nil || token != "secret-oauth" {
+		t.Fatalf("expected oauth token from cookies, got %q err=%v", token, err)
+	}
+
+	ctx := httpcache.NewContext(context.Background(), httpcache.New(t.TempDir()))
+	client := soundCloudAPIClient{BaseURL: server.URL, HTTP: server.Client(), ClientID: "cid", OAuthToken: token}
+	for run := 0; run < 2; run++ {
+		tracks, err := client.Enumerate(ctx, "https://soundcloud.com/a", "-f", 0)
+		if err != nil {
+			t.Fatalf("run %d: enumerate: %v", run, err)
+		}
+		if len(tracks) != 1 || tracks[0].Title != "Private" {
+			t.Fatalf("run %d: unexpected tracks %+v", run, tracks)
+		}
+	}
+	if requests != 4 {
+		t.Fatalf("expected every signed-in request to reach the server, got %d", requests)
+	}
+
+	if _, err := resolveSoundCloudSourceOAuthToken(config.Source{CookiesFile: filepath.Join(t.TempDir(), "missing.txt")}); err == nil {
+		t.Fatalf("expected an error for a missing cookies_file")
+	}
+}
+
+func TestSoundCloudAPIClientHydratesPlaylistStubs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/resolve":
+			fmt.Fprint(w, `{"id": 7, "kind": "playlist", "tracks": [
+				{"id": 10, "kind": "track", "title": "Ten", "permalink_url": "https://soundcloud.com/a/ten"},
+				{"id": 11, "kind": "track"},
+				{"id": 12, "kind": "track"}
+			]}`)
+		case "/tracks":
+			if got := r.URL.Query().Get("ids"); got != "11,12" {
+				t.Errorf("unexpected hydrate ids %q", got)
+			}
+			fmt.Fprint(w, `[
+				{"id": 12, "kind": "track", "title": "Twelve", "permalink_url": "https://soundcloud.com/a/twelve"},
+				{"id": 11, "kind": "track", "title": "Eleven", "permalink_url": "https://soundcloud.com/a/eleven"}
+			]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := soundCloudAPIClient{BaseURL: server.URL, HTTP: server.Client(), ClientID: "cid"}
+	tracks, err := client.Enumerate(context.Background(), "https://soundcloud.com/a/sets/mix", "-f", 0)
+	if err != nil {
+		t.Fatalf("enumerate: %v", err)
+	}
+	titles := []string{}
+	for _, track := range tracks {
+		titles = append(titles, track.Title)
+	}
+	if strings.Join(titles, ",") != "Ten,Eleven,Twelve" {
+		t.Fatalf("expected playlist order with hydrated stubs, got %v", titles)
+	}
+}
+
+func TestSoundCloudAPIClientRedactsClientIDFromErrors(t *testing.T) {
+	client := soundCloudAPIClient{BaseURL: "http://127.0.0.1:1", HTTP: &http.Client{}, ClientID: "secretclientid"}
+	_, err := client.Enumerate(context.Background(), "https://soundcloud.com/a", "-f", 0)
+	if err == nil {
+		t.Fatalf("expected connection error")
+	}
+	if strings.Contains(err.Error(), "secretclientid") {
+		t.Fatalf("expected client id to be redacted, got %v", err)
+	}
+}
+
+func TestEnumerateSoundCloudTracksPrefersNativeAPI(t *testing.T) {
+	orig := enumerateSoundCloudTracksViaAPIFn
+	t.Cleanup(func() { enumerateSoundCloudTracksViaAPIFn = orig })
+	enumerateSoundCloudTracksViaAPIFn = func(ctx context.Context, source config.Source, limit int) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{{ID: "1", Title: "One"}}, nil
+	}
+
+	tracks, err := enumerateSoundCloudTracksWithLimit(context.Background(), config.Source{URL: "https://soundcloud.com/a"}, 0)
+	if err != nil {
+		t.Fatalf("enumerate: %v", err)
+	}
+	if len(tracks) != 1 || tracks[0].ID != "1" {
+		t.Fatalf("expected native api tracks, got %+v", tracks)
+	}
+}
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// errGateBlocked marks a gate step automation cannot complete on its
+// own (a missing email or comment, or a SoundCloud sign-in), so the flow
+// hands the gate off to the desktop browser.
+var errGateBlocked = errors.New("gate needs a manual step")
+
+var (
+	automateGateFn = automateGate
+	// gateStepInterval is how often automation inspects the gate pages
+	// for the next step.
+	gateStepInterval = 1 * time.Second
+)
+
+// gateAutomation is what automation submits to a gate and where it runs.
+type gateAutomation struct {
+	Email       string
+	Comment     string
+	ProfileDir  string
+	BrowserPath string
+	Timeout     time.Duration
+}
+
+// resolveGateAutomation returns the automation settings for cfg, or
+// ok=false when freedl.automation does not ask for it. The Chrome binary can
+// be overridden with UDL_FREEDL_CHROME_PATH; chromedp looks it up otherwise.
+func resolveGateAutomation(cfg config.Config, timeout time.Duration) (gateAutomation, bool, error) {
+	if cfg.FreeDL.Automation != config.FreeDLAutomationHeadless {
+		return gateAutomation{}, false, nil
+	}
+	profileDir := cfg.FreeDL.BrowserProfileDir
+	if profileDir == "" {
+		profileDir = filepath.Join(cfg.Defaults.StateDir, "freedl-browser-profile")
+	}
+	expanded, err := config.ExpandPath(profileDir)
+	if err != nil {
+		return gateAutomation{}, false, fmt.Errorf("resolve freedl.browser_profile_dir: %w", err)
+	}
+	return gateAutomation{
+		Email:       cfg.FreeDL.Email,
+		Comment:     cfg.FreeDL.Comment,
+		ProfileDir:  expanded,
+		BrowserPath: strings.TrimSpace(os.Getenv("UDL_FREEDL_CHROME_PATH")),
+		Timeout:     timeout,
+	}, true, nil
+}
+
+// gateStepResult is what gateStepScript reports for one page.
+type gateStepResult struct {
+	Action  string `json:"action"`
+	Blocked string `json:"blocked"`
+}
+
+// gateStepScript performs at most one gate step on the current page:
+// it fills an empty email or comment field, confirms a SoundCloud connect
+// prompt, or clicks the next visible step or download button. Buttons are
+// not clicked again for five seconds so slow steps are not toggled twice.
+const gateStepScript = `(function(email, comment) {
+  const visible = (el) => !!(el.offsetWidth || el.offsetHeight || el.getClientRects().length);
+  const label = (el) => (el.innerText || el.value || el.getAttribute('aria-label') || '').trim();
+  const fill = (input, value) => {
+    input.focus();
+    input.value = value;
+    input.dispatchEvent(new Event('input', {bubbles: true}));
+    input.dispatchEvent(new Event('change', {bubbles: true}));
+  };
+  const clickable = (pattern) => [...document.querySelectorAll('button, a, input[type=submit], [role=button]')].find((el) =>
+    visible(el) && !el.disabled && Date.now() - Number(el.dataset.udlClicked || 0) > 5000 && pattern.test(label(el)));
+  const click = (el) => {
+    el.dataset.udlClicked = String(Date.now());
+    el.click();
+    return {action: 'click ' + label(el).slice(0, 40)};
+  };
+
+  if (/(^|\.)soundcloud\.com$/.test(location.hostname)) {
+    if (/\/signin|\/login/.test(location.pathname) || document.querySelector('input[type=password]')) {
+      return {blocked: 'soundcloud-sign-in'};
+    }
+    const connect = clickable(/^(connect|allow|authori[sz]e)/i);
+    return connect ? click(connect) : {};
+  }
+
+  const emailInput = [...document.querySelectorAll('input[type=email], input[name*=email i]')].find((el) => visible(el) && !el.value);
+  if (emailInput) {
+    if (!email) {
+      return {blocked: 'email'};
+    }
+    fill(emailInput, email);
+    return {action: 'email'};
+  }
+  const commentInput = [...document.querySelectorAll('textarea, input[name*=comment i]')].find((el) => visible(el) && !el.value);
+  if (commentInput) {
+    if (!comment) {
+      return {blocked: 'comment'};
+    }
+    fill(commentInput, comment);
+    return {action: 'comment'};
+  }
+  const step = clickable(/^(download|free download|get (the )?(track|file|download)|unlock|next|continue|submit|skip|done|follow|like|repost|connect|post comment|comment)/i);
+  return step ? click(step) : {};
+})`
+
+// automateGate completes the Hypeddit or ToneDen gate at purchaseURL in a
+// headless Chrome running in the automation profile and returns the path of
+// the downloaded file, in a temporary directory the caller removes. Chrome
+// popups (the SoundCloud connect window) are stepped through like the gate
+// page itself. It gives up with errGateBlocked when a step needs
+// something the config does not provide, and after settings.Timeout.
+func automateGate(ctx context.Context, purchaseURL string, settings gateAutomation) (string, error) {
+	if err := os.MkdirAll(settings.ProfileDir, 0o700); err != nil {
+		return "", fmt.Errorf("create browser profile dir: %w", err)
+	}
+	downloadDir, err := os.MkdirTemp("", "udl-freedl-gate-*")
+	if err != nil {
+		return "", err
+	}
+	keep := false
+	defer func() {
+		if !keep {
+			_ = os.RemoveAll(downloadDir)
+		}
+	}()
+
+	options := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.UserDataDir(settings.ProfileDir), chromedp.WindowSize(1280, 900))
+	if settings.BrowserPath != "" {
+		options = append(options, chromedp.ExecPath(settings.BrowserPath))
+	}
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, options...)
+	defer cancelAlloc()
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+	timeout := settings.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	runCtx, cancel := context.WithTimeout(browserCtx, timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	names := map[string]string{}
+	completed := make(chan string, 1)
+	onEvent := func(ev interface{}) {
+		switch e := ev.(type) {
+		case *browser.EventDownloadWillBegin:
+			mu.Lock()
+			names[e.GUID] = e.SuggestedFilename
+			mu.Unlock()
+		case *browser.EventDownloadProgress:
+			if e.State == browser.DownloadProgressStateCompleted {
+				select {
+				case completed <- e.GUID:
+				default:
+				}
+			}
+		}
+	}
+	chromedp.ListenBrowser(runCtx, onEvent)
+	chromedp.ListenTarget(runCtx, onEvent)
+
+	if err := chromedp.Run(runCtx,
+		browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName).WithDownloadPath(downloadDir).WithEventsEnabled(true),
+		chromedp.Navigate(purchaseURL),
+	); err != nil {
+		return "", fmt.Errorf("open gate in headless chrome: %w", err)
+	}
+
+	email, _ := json.Marshal(settings.Email)
+	comment, _ := json.Marshal(settings.Comment)
+	script := fmt.Sprintf("%s(%s, %s)", gateStepScript, email, comment)
+	popups := map[target.ID]context.Context{}
+	ticker := time.NewTicker(gateStepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case guid := <-completed:
+			mu.Lock()
+			name := filepath.Base(strings.TrimSpace(names[guid]))
+			mu.Unlock()
+			saved := filepath.Join(downloadDir, guid)
+			if name == "" || name == "." || name == string(filepath.Separator) {
+				name = guid
+			}
+			path := filepath.Join(downloadDir, name)
+			if err := os.Rename(saved, path); err != nil {
+				return "", fmt.Errorf("name downloaded file: %w", err)
+			}
+			keep = true
+			return path, nil
+		case <-runCtx.Done():
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			return "", fmt.Errorf("no download within %s", timeout)
+		case <-ticker.C:
+		}
+
+		contexts := []context.Context{runCtx}
+		if infos, err := chromedp.Targets(runCtx); err == nil {
+			for _, info := range infos {
+				if info.Type != "page" || info.TargetID == chromedp.FromContext(runCtx).Target.TargetID {
+					continue
+				}
+				popup, ok := popups[info.TargetID]
+				if !ok {
+					popup, _ = chromedp.NewContext(runCtx, chromedp.WithTargetID(info.TargetID))
+					popups[info.TargetID] = popup
+				}
+				contexts = append(contexts, popup)
+			}
+		}
+		for _, pageCtx := range contexts {
+			var step gateStepResult
+			if err := chromedp.Run(pageCtx, chromedp.Evaluate(script, &step)); err != nil {
+				// Pages navigate and popups close between steps.
+				continue
+			}
+			if step.Blocked != "" {
+				return "", fmt.Errorf("%w: %s", errGateBlocked, gateBlockedReason(step.Blocked))
+			}
+		}
+	}
+}
+
+func gateBlockedReason(blocked string) string {
+	switch blocked {
+	case "email":
+		return "gate asks for an email address and freedl.email is unset"
+	case "comment":
+		return "gate asks for a comment and freedl.comment is unset"
+	case "soundcloud-sign-in":
+		return "SoundCloud sign-in required; sign in once in the freedl.browser_profile_dir profile"
+	default:
+		return blocked
+	}
+}
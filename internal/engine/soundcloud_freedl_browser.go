@@ -5,10 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"sort"
 	"strings"
@@ -26,27 +26,52 @@ var (
 	openURLInBrowserFn            = openURLInBrowser
 	detectBrowserDownloadedFileFn = detectBrowserDownloadedFile
 	browserDownloadsDirFn         = defaultBrowserDownloadsDir
+	knownDownloadsFolderFn        = knownDownloadsFolder
 	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
 	runtimeGOOS                   = runtime.GOOS
 	runBrowserCommandFn           = runBrowserCommand
 	browserDownloadPollInterval   = 1 * time.Second
+	browserDownloadStableSamples  = 2
+	// browserDownloadWaitReportInterval throttles waiting-for-browser progress
+	// reports so the poll loop does not flood event consumers.
+	browserDownloadWaitReportInterval = 5 * time.Second
 )
 
-func isHypedditPurchaseURL(raw string) bool {
-	parsed, err := url.Parse(strings.TrimSpace(raw))
-	if err != nil {
-		return false
-	}
-	host := strings.ToLower(strings.TrimSpace(parsed.Hostname()))
-	if host == "hypeddit.com" {
-		return true
+// browserDownloadWaitSettings controls how detectBrowserDownloadedFile polls
+// the downloads directory. Zero fields fall back to the package defaults.
+type browserDownloadWaitSettings struct {
+	MaxWait       time.Duration
+	IdleTimeout   time.Duration
+	PollInterval  time.Duration
+	StableSamples int
+}
+
+func resolveBrowserDownloadWaitSettings(freeDL config.FreeDL, maxWait time.Duration) browserDownloadWaitSettings {
+	return browserDownloadWaitSettings{
+		MaxWait:       maxWait,
+		IdleTimeout:   time.Duration(freeDL.IdleTimeoutSeconds) * time.Second,
+		PollInterval:  time.Duration(freeDL.PollIntervalMS) * time.Millisecond,
+		StableSamples: freeDL.StableSamples,
 	}
-	return strings.HasSuffix(host, ".hypeddit.com")
+}
+
+// browserDownloadWaitStatus is reported while detectBrowserDownloadedFile is
+// polling the downloads directory.
+type browserDownloadWaitStatus struct {
+	Elapsed     time.Duration
+	Idle        time.Duration
+	IdleTimeout time.Duration
+	MaxWait     time.Duration
 }
 
 func defaultBrowserDownloadsDir() (string, error) {
 	if override := strings.TrimSpace(os.Getenv("UDL_FREEDL_BROWSER_DOWNLOAD_DIR")); override != "" {
-		return config.ExpandPath(override)
+		return normalizeBrowserDownloadsDir(override)
+	}
+	if runtimeGOOS == "windows" {
+		if dir, err := knownDownloadsFolderFn(); err == nil && strings.TrimSpace(dir) != "" {
+			return filepath.Clean(dir), nil
+		}
 	}
 	home, err := os.UserHomeDir()
 	if err != nil {
@@ -55,6 +80,30 @@ func defaultBrowserDownloadsDir() (string, error) {
 	return filepath.Join(home, "Downloads"), nil
 }
 
+// windowsEnvReference matches a cmd-style %NAME% variable reference.
+var windowsEnvReference = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
+
+// normalizeBrowserDownloadsDir cleans a UDL_FREEDL_BROWSER_DOWNLOAD_DIR
+// value. Besides what config.ExpandPath handles, it accepts the forms a
+// Windows user is likely to paste: surrounding quotes, %USERPROFILE%-style
+// references, and ~\ with a backslash. Unset %NAME% references are kept.
+func normalizeBrowserDownloadsDir(raw string) (string, error) {
+	dir := strings.TrimSpace(raw)
+	if len(dir) >= 2 && dir[0] == '"' && dir[len(dir)-1] == '"' {
+		dir = strings.TrimSpace(dir[1 : len(dir)-1])
+	}
+	dir = windowsEnvReference.ReplaceAllStringFunc(dir, func(reference string) string {
+		if value, ok := os.LookupEnv(strings.Trim(reference, "%")); ok {
+			return value
+		}
+		return reference
+	})
+	if strings.HasPrefix(dir, `~\`) {
+		dir = "~/" + dir[2:]
+	}
+	return config.ExpandPath(dir)
+}
+
 func openURLInBrowser(ctx context.Context, rawURL string) error {
 	bin, args, err := browserOpenCommand(rawURL)
 	if err != nil {
@@ -82,12 +131,34 @@ func browserOpenCommand(rawURL string) (string, []string, error) {
 	case "linux":
 		return "xdg-open", []string{trimmed}, nil
 	case "windows":
-		return "rundll32", []string{"url.dll,FileProtocolHandler", trimmed}, nil
+		// start takes its first quoted argument as the window title, so an
+		// empty one goes first. cmd re-parses the line: metacharacters in
+		// the URL (the & between query parameters in particular) are escaped
+		// with ^, which only works outside quotes, so spaces are encoded to
+		// keep the URL unquoted. A browser app, such as msedge or a full
+		// path, is quoted when it has spaces and needs no escaping.
+		args := []string{"/c", "start", ""}
+		if browserApp != "" {
+			args = append(args, browserApp)
+		}
+		return "cmd", append(args, escapeCmdMetacharacters(strings.ReplaceAll(trimmed, " ", "%20"))), nil
 	default:
 		return "", nil, fmt.Errorf("unsupported platform for browser handoff: %s", runtimeGOOS)
 	}
 }
 
+// escapeCmdMetacharacters makes value a literal argument on a cmd /c line.
+func escapeCmdMetacharacters(value string) string {
+	var escaped strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune("^&|<>()%!", r) {
+			escaped.WriteByte('^')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
+
 func runBrowserCommand(ctx context.Context, bin string, args ...string) error {
 	cmd := exec.CommandContext(ctx, bin, args...)
 	output, err := cmd.CombinedOutput()
@@ -105,20 +176,30 @@ func detectBrowserDownloadedFile(
 	ctx context.Context,
 	downloadsDir string,
 	before map[string]mediaFileSnapshot,
-	timeout time.Duration,
+	wait browserDownloadWaitSettings,
 	metadata soundCloudFreeDownloadMetadata,
+	observe func(browserDownloadWaitStatus),
 ) (string, error) {
 	dir := strings.TrimSpace(downloadsDir)
 	if dir == "" {
 		return "", fmt.Errorf("browser download directory is empty")
 	}
+	timeout := wait.MaxWait
 	if timeout <= 0 {
 		timeout = 10 * time.Minute
 	}
-	idleTimeout := resolveBrowserDownloadIdleTimeout(timeout)
+	idleTimeout := resolveBrowserDownloadIdleTimeout(timeout, wait.IdleTimeout)
 	if idleTimeout <= 0 {
 		idleTimeout = 1 * time.Minute
 	}
+	pollInterval := wait.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = browserDownloadPollInterval
+	}
+	requiredStableSamples := wait.StableSamples
+	if requiredStableSamples <= 0 {
+		requiredStableSamples = browserDownloadStableSamples
+	}
 	startedAt := time.Now()
 	absoluteDeadline := startedAt.Add(timeout)
 	lastProgressAt := startedAt
@@ -127,6 +208,7 @@ func detectBrowserDownloadedFile(
 	lastCandidateSnapshotSet := false
 	stableSamples := 0
 	inProgressBefore, _ := snapshotBrowserInProgressFiles(dir)
+	var lastReportAt time.Time
 
 	for {
 		if ctx.Err() != nil {
@@ -158,7 +240,7 @@ func detectBrowserDownloadedFile(
 					lastCandidateSnapshotSet = true
 				}
 				lastCandidateSnapshot = candidateSnapshot
-				if stableSamples >= 2 {
+				if stableSamples >= requiredStableSamples {
 					return abs, nil
 				}
 			}
@@ -170,17 +252,31 @@ func detectBrowserDownloadedFile(
 			}
 			inProgressBefore = inProgressAfter
 		}
+		if observe != nil && (lastReportAt.IsZero() || now.Sub(lastReportAt) >= browserDownloadWaitReportInterval) {
+			lastReportAt = now
+			observe(browserDownloadWaitStatus{
+				Elapsed:     now.Sub(startedAt),
+				Idle:        now.Sub(lastProgressAt),
+				IdleTimeout: idleTimeout,
+				MaxWait:     timeout,
+			})
+		}
 
 		select {
 		case <-ctx.Done():
 			return "", ctx.Err()
-		case <-time.After(browserDownloadPollInterval):
+		case <-time.After(pollInterval):
 		}
 	}
 }
 
-func resolveBrowserDownloadIdleTimeout(maxWait time.Duration) time.Duration {
+// resolveBrowserDownloadIdleTimeout prefers UDL_FREEDL_BROWSER_IDLE_TIMEOUT,
+// then the configured freedl.idle_timeout_seconds, then one minute.
+func resolveBrowserDownloadIdleTimeout(maxWait time.Duration, configured time.Duration) time.Duration {
 	idle := 1 * time.Minute
+	if configured > 0 {
+		idle = configured
+	}
 	if override := strings.TrimSpace(os.Getenv("UDL_FREEDL_BROWSER_IDLE_TIMEOUT")); override != "" {
 		if parsed, err := time.ParseDuration(override); err == nil && parsed > 0 {
 			idle = parsed
@@ -309,8 +405,6 @@ func selectBrowserDownloadCandidate(
 	}
 
 	candidates := make([]candidate, 0)
-	expectedTitle := normalizeTrackKey(metadata.Title)
-	expectedArtist := normalizeTrackKey(metadata.Artist)
 	for rel, current := range after {
 		previous, existed := before[rel]
 		if existed &&
@@ -318,19 +412,10 @@ func selectBrowserDownloadCandidate(
 			!current.ModTime.After(previous.ModTime) {
 			continue
 		}
-		stem := strings.TrimSpace(strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel)))
-		key := normalizeTrackKey(stem)
-		score := 0
-		if expectedTitle != "" && key != "" && strings.Contains(key, expectedTitle) {
-			score += 2
-		}
-		if expectedArtist != "" && key != "" && strings.Contains(key, expectedArtist) {
-			score++
-		}
 		candidates = append(candidates, candidate{
 			Rel:     rel,
 			ModTime: current.ModTime,
-			Score:   score,
+			Score:   browserDownloadMatchScore(rel, metadata),
 		})
 	}
 	if len(candidates) == 0 {
@@ -348,6 +433,24 @@ func selectBrowserDownloadCandidate(
 	return candidates[0].Rel
 }
 
+// browserDownloadMatchScore rates how well a downloaded file's name matches
+// the expected track: 2 for the title, 1 more for the artist.
+func browserDownloadMatchScore(rel string, metadata soundCloudFreeDownloadMetadata) int {
+	stem := strings.TrimSpace(strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel)))
+	key := normalizeTrackKey(stem)
+	if key == "" {
+		return 0
+	}
+	score := 0
+	if expectedTitle := normalizeTrackKey(metadata.Title); expectedTitle != "" && strings.Contains(key, expectedTitle) {
+		score += 2
+	}
+	if expectedArtist := normalizeTrackKey(metadata.Artist); expectedArtist != "" && strings.Contains(key, expectedArtist) {
+		score++
+	}
+	return score
+}
+
 func moveDownloadedMediaToTarget(sourcePath string, targetDir string) (string, error) {
 	src := strings.TrimSpace(sourcePath)
 	if src == "" {
@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// freeDLInteractiveBudget tracks freedl.interactive_budget_seconds across one
+// sync: the wall-clock time browser gates have taken so far, from opening the
+// gate until its download is detected or given up on, and the gated tracks
+// deferred once the budget ran out. With noBrowser set (--headless) no gate
+// is opened at all and every one is deferred.
+type freeDLInteractiveBudget struct {
+	limit     time.Duration
+	used      time.Duration
+	gates     int
+	noBrowser bool
+	deferred  []freeDLDeferredGate
+}
+
+// freeDLDeferredGate is a gated track the run did not open a browser for.
+type freeDLDeferredGate struct {
+	SourceID      string
+	TrackID       string
+	Title         string
+	Artist        string
+	SoundCloudURL string
+	PurchaseURL   string
+}
+
+func newFreeDLInteractiveBudget(freeDL config.FreeDL, noBrowser bool) *freeDLInteractiveBudget {
+	return &freeDLInteractiveBudget{
+		limit:     time.Duration(freeDL.InteractiveBudgetSeconds) * time.Second,
+		noBrowser: noBrowser,
+	}
+}
+
+// exhausted reports whether no new gate may be started. A gate already open
+// always runs to completion, so used can exceed the limit.
+func (b *freeDLInteractiveBudget) exhausted() bool {
+	return b != nil && (b.noBrowser || (b.limit > 0 && b.used >= b.limit))
+}
+
+// reason is the skip reason recorded for deferred gates.
+func (b *freeDLInteractiveBudget) reason() string {
+	if b != nil && b.noBrowser {
+		return "no-browser"
+	}
+	return "interactive-budget"
+}
+
+func (b *freeDLInteractiveBudget) spend(elapsed time.Duration) {
+	if b == nil || elapsed < 0 {
+		return
+	}
+	b.used += elapsed
+	b.gates++
+}
+
+// deferGate records track and reports whether it is the first deferral of
+// the run.
+func (b *freeDLInteractiveBudget) deferGate(gate freeDLDeferredGate) bool {
+	if b == nil {
+		return false
+	}
+	b.deferred = append(b.deferred, gate)
+	return len(b.deferred) == 1
+}
+
+// finishFreeDLInteractiveBudget reports the interactive time the run used and
+// lists every deferred gate with its purchase URL, so they can be opened by
+// hand or picked up by the next sync.
+func (s *Syncer) finishFreeDLInteractiveBudget(b *freeDLInteractiveBudget) {
+	if b == nil || (b.gates == 0 && len(b.deferred) == 0) {
+		return
+	}
+	details := map[string]any{
+		"interactive_used_ms":   b.used.Milliseconds(),
+		"interactive_gates":     b.gates,
+		"interactive_budget_ms": b.limit.Milliseconds(),
+		"deferred_gates":        len(b.deferred),
+		"no_browser":            b.noBrowser,
+	}
+	if len(b.deferred) == 0 {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			Message:   fmt.Sprintf("[free-dl] interactive time %s across %d gate(s)", formatFreeDLBudgetDuration(b.used), b.gates),
+			Details:   details,
+		})
+		return
+	}
+	message := fmt.Sprintf(
+		"[free-dl] interactive time %s across %d gate(s) reached the %s budget; deferred %d gated track(s):",
+		formatFreeDLBudgetDuration(b.used),
+		b.gates,
+		formatFreeDLBudgetDuration(b.limit),
+		len(b.deferred),
+	)
+	if b.noBrowser {
+		message = fmt.Sprintf("[free-dl] browser handoff disabled (--headless); deferred %d gated track(s):", len(b.deferred))
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelWarn,
+		Event:     output.EventSourcePreflight,
+		Message:   message,
+		Details:   details,
+	})
+	for _, gate := range b.deferred {
+		label := soundCloudTrackDisplayName(soundCloudFreeDownloadMetadata{ID: gate.TrackID, Title: gate.Title, Artist: gate.Artist})
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  gate.SourceID,
+			Message:   fmt.Sprintf("[%s] [deferred] %s (%s) %s", gate.SourceID, gate.TrackID, label, gate.PurchaseURL),
+			Details: map[string]any{
+				"track_id":       gate.TrackID,
+				"title":          gate.Title,
+				"artist":         gate.Artist,
+				"soundcloud_url": gate.SoundCloudURL,
+				"purchase_url":   gate.PurchaseURL,
+				"reason":         b.reason(),
+			},
+		})
+	}
+}
+
+func formatFreeDLBudgetDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
@@ -0,0 +1,232 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var detectBrowserDownloadsFn = detectBrowserDownloads
+
+// browserDownloadExpectation is one gate of a concurrent batch whose
+// download detectBrowserDownloads waits for, keyed by track ID.
+type browserDownloadExpectation struct {
+	Key      string
+	Metadata soundCloudFreeDownloadMetadata
+}
+
+// pendingFreeDLGate is a gate opened in the browser whose download has not
+// been attributed yet. The callbacks settle the track the way the
+// one-gate-at-a-time flow does.
+type pendingFreeDLGate struct {
+	expectation browserDownloadExpectation
+	observe     func(browserDownloadWaitStatus)
+	finish      func(detectedPath string, downloadsDir string, strategy string) bool
+	settle      func(downloadsDir string, detectErr error) bool
+}
+
+// freeDLGateBatch is the set of gates open at once under
+// freedl.concurrent_gates, sharing one snapshot of the downloads directory
+// taken before the first of them was opened.
+type freeDLGateBatch struct {
+	downloadsDir string
+	before       map[string]mediaFileSnapshot
+	openedAt     time.Time
+	gates        []pendingFreeDLGate
+}
+
+// snapshotBrowserDownloadsDir resolves the browser's downloads directory and
+// records the media files already in it.
+func snapshotBrowserDownloadsDir() (string, map[string]mediaFileSnapshot, error) {
+	dir, err := browserDownloadsDirFn()
+	if err != nil {
+		return "", nil, err
+	}
+	before, err := snapshotMediaFiles(dir)
+	if err != nil {
+		return "", nil, err
+	}
+	return dir, before, nil
+}
+
+// detectBrowserDownloads waits for the downloads of several open gates at
+// once. Each new media file that stops changing is attributed to the one
+// expected track its name matches best; a file matching none of them, or
+// several equally, is only attributed once a single track is left. It
+// returns the files found by key, and on idle or max timeout the partial
+// result with the timeout error.
+func detectBrowserDownloads(
+	ctx context.Context,
+	downloadsDir string,
+	before map[string]mediaFileSnapshot,
+	wait browserDownloadWaitSettings,
+	expected []browserDownloadExpectation,
+	observe func(key string, status browserDownloadWaitStatus),
+) (map[string]string, error) {
+	found := map[string]string{}
+	dir := strings.TrimSpace(downloadsDir)
+	if dir == "" {
+		return found, fmt.Errorf("browser download directory is empty")
+	}
+	timeout := wait.MaxWait
+	if timeout <= 0 {
+		timeout = 10 * time.Minute
+	}
+	idleTimeout := resolveBrowserDownloadIdleTimeout(timeout, wait.IdleTimeout)
+	if idleTimeout <= 0 {
+		idleTimeout = 1 * time.Minute
+	}
+	pollInterval := wait.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = browserDownloadPollInterval
+	}
+	requiredStableSamples := wait.StableSamples
+	if requiredStableSamples <= 0 {
+		requiredStableSamples = browserDownloadStableSamples
+	}
+
+	type sample struct {
+		Snapshot mediaFileSnapshot
+		Stable   int
+	}
+	startedAt := time.Now()
+	absoluteDeadline := startedAt.Add(timeout)
+	lastProgressAt := startedAt
+	samples := map[string]sample{}
+	claimed := map[string]struct{}{}
+	inProgressBefore, _ := snapshotBrowserInProgressFiles(dir)
+	var lastReportAt time.Time
+
+	for {
+		if ctx.Err() != nil {
+			return found, ctx.Err()
+		}
+		now := time.Now()
+		if now.After(absoluteDeadline) {
+			return found, fmt.Errorf("%w in %s (max_wait=%s)", errBrowserDownloadMaxTimeout, dir, timeout)
+		}
+		if now.Sub(lastProgressAt) >= idleTimeout {
+			return found, fmt.Errorf("%w in %s (idle_for=%s)", errBrowserDownloadIdleTimeout, dir, idleTimeout)
+		}
+
+		if after, err := snapshotMediaFiles(dir); err == nil {
+			stable := map[string]mediaFileSnapshot{}
+			for rel, current := range after {
+				if _, ok := claimed[rel]; ok {
+					continue
+				}
+				if previous, existed := before[rel]; existed &&
+					current.Size == previous.Size &&
+					!current.ModTime.After(previous.ModTime) {
+					continue
+				}
+				last, seen := samples[rel]
+				if seen && current.Size == last.Snapshot.Size && !current.ModTime.After(last.Snapshot.ModTime) {
+					last.Stable++
+				} else {
+					last.Stable = 1
+					lastProgressAt = now
+				}
+				last.Snapshot = current
+				samples[rel] = last
+				if last.Stable >= requiredStableSamples {
+					stable[rel] = current
+				}
+			}
+			for key, rel := range attributeBrowserDownloads(stable, unresolvedBrowserDownloads(expected, found)) {
+				found[key] = filepath.Join(dir, filepath.FromSlash(rel))
+				claimed[rel] = struct{}{}
+			}
+			if len(found) == len(expected) {
+				return found, nil
+			}
+		}
+		inProgressAfter, snapshotErr := snapshotBrowserInProgressFiles(dir)
+		if snapshotErr == nil {
+			if hasBrowserInProgressActivity(inProgressBefore, inProgressAfter) {
+				lastProgressAt = now
+			}
+			inProgressBefore = inProgressAfter
+		}
+		if observe != nil && (lastReportAt.IsZero() || now.Sub(lastReportAt) >= browserDownloadWaitReportInterval) {
+			lastReportAt = now
+			status := browserDownloadWaitStatus{
+				Elapsed:     now.Sub(startedAt),
+				Idle:        now.Sub(lastProgressAt),
+				IdleTimeout: idleTimeout,
+				MaxWait:     timeout,
+			}
+			for _, expectation := range unresolvedBrowserDownloads(expected, found) {
+				observe(expectation.Key, status)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return found, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func unresolvedBrowserDownloads(expected []browserDownloadExpectation, found map[string]string) []browserDownloadExpectation {
+	unresolved := make([]browserDownloadExpectation, 0, len(expected))
+	for _, expectation := range expected {
+		if _, ok := found[expectation.Key]; !ok {
+			unresolved = append(unresolved, expectation)
+		}
+	}
+	return unresolved
+}
+
+// attributeBrowserDownloads matches finished files to expected tracks,
+// oldest file first. A file goes to the single track with the best match
+// score; files that match no track or tie between several are held back
+// until only one track is left, which takes the oldest of them. Every
+// attribution can make another unambiguous, so passes repeat until one
+// changes nothing.
+func attributeBrowserDownloads(files map[string]mediaFileSnapshot, expected []browserDownloadExpectation) map[string]string {
+	rels := make([]string, 0, len(files))
+	for rel := range files {
+		rels = append(rels, rel)
+	}
+	sort.Slice(rels, func(i, j int) bool {
+		if !files[rels[i]].ModTime.Equal(files[rels[j]].ModTime) {
+			return files[rels[i]].ModTime.Before(files[rels[j]].ModTime)
+		}
+		return rels[i] < rels[j]
+	})
+
+	attributed := map[string]string{}
+	remaining := append([]browserDownloadExpectation(nil), expected...)
+	for changed := true; changed && len(rels) > 0 && len(remaining) > 0; {
+		changed = false
+		for i, rel := range rels {
+			best, bestScore, ties := -1, 0, 0
+			for j, expectation := range remaining {
+				score := browserDownloadMatchScore(rel, expectation.Metadata)
+				switch {
+				case score > bestScore:
+					best, bestScore, ties = j, score, 1
+				case score == bestScore && score > 0:
+					ties++
+				}
+			}
+			if bestScore == 0 || ties > 1 {
+				if len(remaining) != 1 {
+					continue
+				}
+				best = 0
+			}
+			attributed[remaining[best].Key] = rel
+			remaining = append(remaining[:best], remaining[best+1:]...)
+			rels = append(rels[:i], rels[i+1:]...)
+			changed = true
+			break
+		}
+	}
+	return attributed
+}
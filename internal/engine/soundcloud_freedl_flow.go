@@ -4,11 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine/progress"
 	"github.com/jaa/update-downloads/internal/output"
 )
 
@@ -29,6 +32,7 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 	if opts.TimeoutOverride > 0 {
 		timeout = opts.TimeoutOverride
 	}
+	waitSettings := resolveBrowserDownloadWaitSettings(cfg.FreeDL, timeout)
 
 	if opts.DryRun {
 		if err := cleanupTempStateFiles(stateSwap); err != nil {
@@ -41,6 +45,7 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 			})
 		}
 		outcome.Succeeded = true
+		s.emitDryRunEstimate(cfg, source, sourcePreflight)
 		_ = s.Emitter.Emit(output.Event{
 			Timestamp: s.Now(),
 			Level:     output.LevelInfo,
@@ -88,7 +93,7 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 		return outcome, nil
 	}
 
-	targetDir, err := config.ExpandPath(sourceForExec.TargetDir)
+	targetDir, err := config.ExpandTargetDir(sourceForExec.TargetDir)
 	if err != nil {
 		if cleanupErr := cleanupTempStateFiles(stateSwap); cleanupErr != nil {
 			_ = s.Emitter.Emit(output.Event{
@@ -132,6 +137,8 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 		return outcome, fmt.Errorf("[%s] parse archive file: %w", source.ID, err)
 	}
 
+	plannedTracks = s.adoptUntrackedFreeDownloads(cfg, source, sourceForExec, stateSwap, targetDir, archivePath, knownArchiveIDs, plannedTracks)
+
 	stuckLogPath, stuckPathErr := resolveSoundCloudFreeDLStuckLogPath(cfg.Defaults.StateDir, source.ID)
 	if stuckPathErr != nil {
 		_ = s.Emitter.Emit(output.Event{
@@ -144,10 +151,13 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 		stuckLogPath = ""
 	}
 
-	cleanupSuffixes := artifactSuffixesForAdapter("scdl")
+	metadataProviders := newMetadataProviderChain(cfg, s.Now)
+	flow := s.buildSourceFlowContext(source)
+
+	cleanupPatterns := artifactPatternsForAdapter(cfg.Defaults, source.Adapter.Kind)
 	preArtifacts := map[string]struct{}{}
-	if len(cleanupSuffixes) > 0 {
-		preArtifacts, err = snapshotArtifacts(targetDir, cleanupSuffixes)
+	if len(cleanupPatterns) > 0 {
+		preArtifacts, err = snapshotArtifacts(targetDir, cleanupPatterns)
 		if err != nil {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -186,10 +196,103 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 	skippedNoLink := 0
 	skippedUnsupportedHost := 0
 	skippedHypedditTimeout := 0
+	deferredBudget := 0
 	stuckLogCount := 0
 	var failureDetails map[string]any
 	failureMessage := ""
+	tagPipeline := startSoundCloudTagPipeline(ctx, soundCloudTagPipelineDepth)
+	defer tagPipeline.Close()
+	commits := newTrackCommitOrderer(opts.Ordered)
+	interrupt := func() (soundCloudFreeDownloadOutcome, error) {
+		// Tracks already in target_dir are recorded, in order, even when an
+		// earlier position never finished.
+		_ = finishSoundCloudTagResults(tagPipeline.Close())
+		_ = commits.Flush()
+		s.cleanupArtifactsOnFailure(cfg, source.ID, targetDir, preArtifacts, cleanupPatterns)
+		if cleanupErr := cleanupTempStateFiles(stateSwap); cleanupErr != nil {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourceFailed,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] unable to clean temporary state file: %v", source.ID, cleanupErr),
+			})
+		}
+		outcome.Interrupted = true
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelError,
+			Event:     output.EventSourceFailed,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] interrupted", source.ID),
+		})
+		return outcome, nil
+	}
+	automation, automationEnabled, automationErr := resolveGateAutomation(cfg, waitSettings.MaxWait)
+	if automationErr != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourceStarted,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] headless gate automation disabled: %v", source.ID, automationErr),
+		})
+	}
+	concurrentGates := cfg.FreeDL.ConcurrentGates
+	gateBatch := freeDLGateBatch{}
+	// flushGateBatch waits for the open batch of gates and settles each of
+	// its tracks. It reports whether the run was interrupted and whether the
+	// loop can go on.
+	flushGateBatch := func() (bool, bool) {
+		batch := gateBatch
+		gateBatch = freeDLGateBatch{}
+		expected := make([]browserDownloadExpectation, 0, len(batch.gates))
+		observers := map[string]func(browserDownloadWaitStatus){}
+		for _, gate := range batch.gates {
+			expected = append(expected, gate.expectation)
+			observers[gate.expectation.Key] = gate.observe
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [free-dl] waiting for %d completed browser download(s) in %s", source.ID, len(expected), batch.downloadsDir),
+		})
+		found, detectErr := detectBrowserDownloadsFn(ctx, batch.downloadsDir, batch.before, waitSettings, expected, func(key string, status browserDownloadWaitStatus) {
+			if observe := observers[key]; observe != nil {
+				observe(status)
+			}
+		})
+		s.freeDLBudget.spend(s.Now().Sub(batch.openedAt))
+		if errors.Is(detectErr, context.Canceled) || errors.Is(detectErr, context.DeadlineExceeded) {
+			return true, false
+		}
+		for _, gate := range batch.gates {
+			if path, ok := found[gate.expectation.Key]; ok {
+				if !gate.finish(path, batch.downloadsDir, freeDLStrategyHandoff) {
+					return false, false
+				}
+				continue
+			}
+			unresolvedErr := detectErr
+			if unresolvedErr == nil {
+				unresolvedErr = fmt.Errorf("browser download for %s was not detected", gate.expectation.Key)
+			}
+			if !gate.settle(batch.downloadsDir, unresolvedErr) {
+				return false, false
+			}
+		}
+		return false, true
+	}
 	for idx, track := range plannedTracks {
+		if finishSoundCloudTagResults(tagPipeline.Completed()) != nil {
+			break
+		}
+		remotePos := idx
+		if NormalizeDownloadOrder(downloadOrder) == DownloadOrderOldestFirst {
+			remotePos = len(plannedTracks) - 1 - idx
+		}
 		displayName := strings.TrimSpace(track.Title)
 		if displayName == "" {
 			displayName = track.ID
@@ -201,8 +304,24 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 			SourceID:  source.ID,
 			Message:   fmt.Sprintf("[%s] free-dl track %d/%d %s (%s)", source.ID, idx+1, len(plannedTracks), track.ID, displayName),
 		})
+		trackStartedAt := s.Now()
+		trackEvent := func(kind progress.TrackEventKind, stage string, percent float64, reason string) progress.TrackEvent {
+			return progress.TrackEvent{
+				Kind:      kind,
+				TrackID:   track.ID,
+				TrackName: displayName,
+				Index:     idx + 1,
+				Total:     len(plannedTracks),
+				Percent:   percent,
+				Reason:    reason,
+				Stage:     stage,
+				Elapsed:   s.Now().Sub(trackStartedAt),
+			}
+		}
+		s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackStarted, "", 0, ""))
 
-		metadata, metadataErr := fetchSoundCloudFreeDownloadMetadataFn(ctx, track)
+		metadata, metadataErr := lookupSoundCloudFreeDownloadMetadata(ctx, source.ID, track)
+		metadata.ArtworkVariants = cfg.FreeDL.ArtworkVariants
 		if errors.Is(metadataErr, errSoundCloudNoFreeDownloadLink) {
 			skippedNoLink++
 			_ = s.Emitter.Emit(output.Event{
@@ -212,14 +331,20 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 				SourceID:  source.ID,
 				Message:   fmt.Sprintf("[%s] [skip] %s (%s) (no-free-download-link)", source.ID, track.ID, displayName),
 			})
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackSkip, "", 0, "no-free-download-link"))
+			if commits.Skip(remotePos) != nil {
+				break
+			}
 			continue
 		}
 		if metadataErr != nil {
 			failureMessage = fmt.Sprintf("[%s] free-download metadata lookup failed for %s: %v", source.ID, track.ID, metadataErr)
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "metadata-lookup"))
 			break
 		}
 
-		if !isHypedditPurchaseURL(metadata.PurchaseURL) {
+		gateHost, supportedHost := resolveFreeDLGateHost(metadata.PurchaseURL)
+		if !supportedHost {
 			skippedUnsupportedHost++
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -231,30 +356,18 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 					"purchase_url": sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
 				},
 			})
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackSkip, "", 0, "unsupported-free-download-host"))
+			if commits.Skip(remotePos) != nil {
+				break
+			}
 			continue
 		}
 
-		downloadsDir, dirErr := browserDownloadsDirFn()
-		if dirErr != nil {
-			failureMessage = fmt.Sprintf("[%s] browser download setup failed for %s: %v", source.ID, track.ID, dirErr)
-			break
-		}
-		downloadsBefore, snapshotErr := snapshotMediaFiles(downloadsDir)
-		if snapshotErr != nil {
-			failureMessage = fmt.Sprintf("[%s] browser download setup failed for %s: %v", source.ID, track.ID, snapshotErr)
-			break
-		}
-		_ = s.Emitter.Emit(output.Event{
-			Timestamp: s.Now(),
-			Level:     output.LevelInfo,
-			Event:     output.EventSourcePreflight,
-			SourceID:  source.ID,
-			Message:   fmt.Sprintf("[%s] [free-dl] hypeddit gate detected for %s; opening browser", source.ID, track.ID),
-		})
-		if openErr := openURLInBrowserFn(ctx, metadata.PurchaseURL); openErr != nil {
-			if errors.Is(openErr, exec.ErrNotFound) {
-				outcome.DependencyFailure = true
-			}
+		// Direct links and headless automation get the file without the
+		// desktop browser; when they cannot, the gate falls back to the
+		// handoff below.
+		detectedPath, downloadsDir, strategy := "", "", freeDLStrategyHandoff
+		fallBackToHandoff := func(attempted string, attemptErr error) {
 			stuckRecord := soundCloudFreeDLStuckRecord{
 				Timestamp:     s.Now().UTC().Format(time.RFC3339Nano),
 				SourceID:      source.ID,
@@ -263,51 +376,304 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 				Artist:        strings.TrimSpace(metadata.Artist),
 				SoundCloudURL: strings.TrimSpace(metadata.SoundCloudURL),
 				PurchaseURL:   sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
-				DownloadDir:   downloadsDir,
-				Stage:         "browser-launch",
-				Error:         openErr.Error(),
-				Strategy:      "browser-handoff",
+				Stage:         attempted,
+				Error:         attemptErr.Error(),
+				Strategy:      attempted,
 			}
 			if appendErr := appendSoundCloudFreeDLStuckRecord(stuckLogPath, stuckRecord); appendErr == nil {
 				stuckLogCount++
 			}
-			failureMessage = fmt.Sprintf("[%s] browser launch failed for %s: %v", source.ID, track.ID, openErr)
-			failureDetails = map[string]any{
-				"purchase_url": sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
-				"strategy":     "browser-handoff",
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [free-dl] %s failed for %s: %v; falling back to browser handoff", source.ID, strings.ReplaceAll(attempted, "-", " "), track.ID, attemptErr),
+				Details: map[string]any{
+					"purchase_url": sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
+					"strategy":     attempted,
+					"gate_host":    gateHost.Name,
+				},
+			})
+		}
+		switch {
+		case gateHost.Direct:
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [free-dl] direct download link for %s; fetching it", source.ID, track.ID),
+			})
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackProgress, progress.StageGateOpened, 10, ""))
+			directCtx, cancelDirect := ctx, context.CancelFunc(func() {})
+			if waitSettings.MaxWait > 0 {
+				directCtx, cancelDirect = context.WithTimeout(ctx, waitSettings.MaxWait)
+			}
+			directPath, directErr := downloadDirectFreeDLFileFn(directCtx, metadata.PurchaseURL)
+			cancelDirect()
+			switch {
+			case directErr == nil:
+				detectedPath, downloadsDir, strategy = directPath, filepath.Dir(directPath), freeDLStrategyDirect
+			case ctx.Err() != nil:
+				return interrupt()
+			default:
+				fallBackToHandoff(freeDLStrategyDirect, directErr)
+			}
+		case gateHost.Headless && automationEnabled:
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [free-dl] %s gate detected for %s; completing it in headless chrome", source.ID, gateHost.Name, track.ID),
+			})
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackProgress, progress.StageGateOpened, 10, ""))
+			automatedPath, automateErr := automateGateFn(ctx, metadata.PurchaseURL, automation)
+			switch {
+			case automateErr == nil:
+				detectedPath, downloadsDir, strategy = automatedPath, filepath.Dir(automatedPath), freeDLStrategyHeadless
+			case ctx.Err() != nil:
+				return interrupt()
+			default:
+				fallBackToHandoff(freeDLStrategyHeadless, automateErr)
 			}
-			break
 		}
-		_ = s.Emitter.Emit(output.Event{
-			Timestamp: s.Now(),
-			Level:     output.LevelInfo,
-			Event:     output.EventSourcePreflight,
-			SourceID:  source.ID,
-			Message:   fmt.Sprintf("[%s] [free-dl] waiting for completed browser download for %s in %s", source.ID, track.ID, downloadsDir),
-		})
-		detectedPath, detectErr := detectBrowserDownloadedFileFn(ctx, downloadsDir, downloadsBefore, timeout, metadata)
-		if detectErr != nil {
-			if errors.Is(detectErr, context.Canceled) || errors.Is(detectErr, context.DeadlineExceeded) {
-				s.cleanupArtifactsOnFailure(source.ID, targetDir, preArtifacts, cleanupSuffixes)
-				if cleanupErr := cleanupTempStateFiles(stateSwap); cleanupErr != nil {
+		// finishDownload moves a completed download into target_dir and
+		// queues it for tagging; false means the source run has failed.
+		finishDownload := func(detectedPath string, downloadsDir string, strategy string) bool {
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackProgress, progress.StageDetectedFile, 70, ""))
+			// The hydrated SoundCloud genre picks the sync.genre_routing folder.
+			downloadedPath, moveErr := moveDownloadedMediaToTargetFn(detectedPath, routedTargetDir(targetDir, sourceForExec.Sync.GenreRouting, metadata.Genre))
+			if moveErr != nil {
+				stuckRecord := soundCloudFreeDLStuckRecord{
+					Timestamp:     s.Now().UTC().Format(time.RFC3339Nano),
+					SourceID:      source.ID,
+					TrackID:       track.ID,
+					Title:         strings.TrimSpace(metadata.Title),
+					Artist:        strings.TrimSpace(metadata.Artist),
+					SoundCloudURL: strings.TrimSpace(metadata.SoundCloudURL),
+					PurchaseURL:   sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
+					DownloadDir:   downloadsDir,
+					Stage:         "post-process-move",
+					Error:         moveErr.Error(),
+					Strategy:      strategy,
+				}
+				if appendErr := appendSoundCloudFreeDLStuckRecord(stuckLogPath, stuckRecord); appendErr == nil {
+					stuckLogCount++
+				}
+				failureMessage = fmt.Sprintf("[%s] browser download post-processing failed for %s: %v", source.ID, track.ID, moveErr)
+				failureDetails = map[string]any{
+					"purchase_url": sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
+					"strategy":     strategy,
+					"download_dir": downloadsDir,
+					"source_path":  detectedPath,
+				}
+				s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "post-process-move"))
+				return false
+			}
+			if strategy != freeDLStrategyHandoff {
+				_ = os.RemoveAll(downloadsDir)
+			}
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackProgress, progress.StageMoved, 85, ""))
+			if cfg.FreeDL.VerifyQuality && IsLosslessMediaPath(downloadedPath) {
+				metadata = s.verifySoundCloudFreeDLQuality(ctx, cfg, source, track.ID, targetDir, downloadedPath, metadata)
+			}
+
+			if metadataProviders != nil {
+				enrichment, providerErrs := metadataProviders.Enrich(ctx, MetadataQuery{
+					SourceID:  source.ID,
+					TrackID:   track.ID,
+					Title:     metadata.Title,
+					Artist:    metadata.Artist,
+					Genre:     metadata.Genre,
+					SourceURL: metadata.SoundCloudURL,
+					FilePath:  downloadedPath,
+				})
+				for _, providerErr := range providerErrs {
 					_ = s.Emitter.Emit(output.Event{
 						Timestamp: s.Now(),
 						Level:     output.LevelWarn,
-						Event:     output.EventSourceFailed,
+						Event:     output.EventSourcePreflight,
 						SourceID:  source.ID,
-						Message:   fmt.Sprintf("[%s] unable to clean temporary state file: %v", source.ID, cleanupErr),
+						Message:   fmt.Sprintf("[%s] metadata enrichment warning for %s: %v", source.ID, track.ID, providerErr),
 					})
 				}
-				outcome.Interrupted = true
+				metadata = metadata.withMetadataEnrichment(enrichment)
+			}
+
+			// Artwork and ffmpeg tagging run in the background so the next track's
+			// browser handoff is not held up. State appends and the done events
+			// follow each track's tags, in remote order when --ordered is set.
+			tagMetadata := metadata
+			tagPipeline.Submit(soundCloudTagJob{
+				Path:     downloadedPath,
+				Metadata: tagMetadata,
+				Finish: func(tagErr error) error {
+					return commits.Commit(remotePos, func() error {
+						if tagErr != nil {
+							_ = s.Emitter.Emit(output.Event{
+								Timestamp: s.Now(),
+								Level:     output.LevelWarn,
+								Event:     output.EventSourcePreflight,
+								SourceID:  source.ID,
+								Message:   fmt.Sprintf("[%s] metadata tagging warning for %s: %v", source.ID, track.ID, tagErr),
+							})
+						} else {
+							s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackProgress, progress.StageTagged, 95, ""))
+						}
+
+						statePath := normalizeSoundCloudStatePath(targetDir, downloadedPath)
+						if appendErr := appendSoundCloudSyncStateEntry(sourceForExec.StateFile, track.ID, statePath); appendErr != nil {
+							if failureMessage == "" {
+								failureMessage = fmt.Sprintf("[%s] failed to update soundcloud state file: %v", source.ID, appendErr)
+							}
+							s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "state-update"))
+							return appendErr
+						}
+						if provErr := s.recordSoundCloudProvenance(cfg, source, track.ID, soundCloudProvenanceFreeDL, statePath); provErr != nil {
+							_ = s.Emitter.Emit(output.Event{
+								Timestamp: s.Now(),
+								Level:     output.LevelWarn,
+								Event:     output.EventSourcePreflight,
+								SourceID:  source.ID,
+								Message:   fmt.Sprintf("[%s] unable to record download provenance for %s: %v", source.ID, track.ID, provErr),
+							})
+						}
+						if _, exists := knownArchiveIDs[track.ID]; !exists {
+							if appendErr := appendSoundCloudArchiveID(archivePath, track.ID); appendErr != nil {
+								if failureMessage == "" {
+									failureMessage = fmt.Sprintf("[%s] failed to update soundcloud archive file: %v", source.ID, appendErr)
+								}
+								s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "state-update"))
+								return appendErr
+							}
+							knownArchiveIDs[track.ID] = struct{}{}
+						}
+
+						doneLabel := soundCloudTrackDisplayName(tagMetadata)
+						if doneLabel == "" {
+							doneLabel = displayName
+						}
+						_ = s.Emitter.Emit(output.Event{
+							Timestamp: s.Now(),
+							Level:     output.LevelInfo,
+							Event:     output.EventSourcePreflight,
+							SourceID:  source.ID,
+							Message:   fmt.Sprintf("[%s] [done] %s (%s)", source.ID, track.ID, doneLabel),
+						})
+						doneEvent := trackEvent(progress.TrackDone, "", 100, "")
+						doneEvent.TrackName = doneLabel
+						s.emitSourceTrackEvent(flow, source, doneEvent)
+						return nil
+					})
+				},
+			})
+			return true
+		}
+		if detectedPath != "" {
+			if !finishDownload(detectedPath, downloadsDir, strategy) {
+				break
+			}
+			continue
+		}
+
+		if s.freeDLBudget.exhausted() {
+			deferredBudget++
+			purchaseURL := sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL)
+			if s.freeDLBudget.deferGate(freeDLDeferredGate{
+				SourceID:      source.ID,
+				TrackID:       track.ID,
+				Title:         strings.TrimSpace(metadata.Title),
+				Artist:        strings.TrimSpace(metadata.Artist),
+				SoundCloudURL: strings.TrimSpace(metadata.SoundCloudURL),
+				PurchaseURL:   purchaseURL,
+			}) {
+				message := fmt.Sprintf(
+					"[%s] [free-dl] interactive budget of %s used (%s); deferring remaining gated tracks",
+					source.ID,
+					formatFreeDLBudgetDuration(s.freeDLBudget.limit),
+					formatFreeDLBudgetDuration(s.freeDLBudget.used),
+				)
+				if s.freeDLBudget.noBrowser {
+					message = fmt.Sprintf("[%s] [free-dl] browser handoff disabled (--headless); deferring gated tracks", source.ID)
+				}
 				_ = s.Emitter.Emit(output.Event{
 					Timestamp: s.Now(),
-					Level:     output.LevelError,
-					Event:     output.EventSourceFailed,
+					Level:     output.LevelWarn,
+					Event:     output.EventSourcePreflight,
 					SourceID:  source.ID,
-					Message:   fmt.Sprintf("[%s] interrupted", source.ID),
+					Message:   message,
 				})
-				return outcome, nil
 			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [skip] %s (%s) (%s)", source.ID, track.ID, displayName, s.freeDLBudget.reason()),
+				Details: map[string]any{
+					"purchase_url": purchaseURL,
+				},
+			})
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackSkip, "", 0, s.freeDLBudget.reason()))
+			if commits.Skip(remotePos) != nil {
+				break
+			}
+			continue
+		}
+
+		// openGate hands the gate to the desktop browser; false means the
+		// launch failed and the source run has failed.
+		openGate := func(downloadsDir string) bool {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [free-dl] %s gate detected for %s; opening browser", source.ID, gateHost.Name, track.ID),
+			})
+			if openErr := openURLInBrowserFn(ctx, metadata.PurchaseURL); openErr != nil {
+				if errors.Is(openErr, exec.ErrNotFound) {
+					outcome.DependencyFailure = true
+				}
+				stuckRecord := soundCloudFreeDLStuckRecord{
+					Timestamp:     s.Now().UTC().Format(time.RFC3339Nano),
+					SourceID:      source.ID,
+					TrackID:       track.ID,
+					Title:         strings.TrimSpace(metadata.Title),
+					Artist:        strings.TrimSpace(metadata.Artist),
+					SoundCloudURL: strings.TrimSpace(metadata.SoundCloudURL),
+					PurchaseURL:   sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
+					DownloadDir:   downloadsDir,
+					Stage:         "browser-launch",
+					Error:         openErr.Error(),
+					Strategy:      freeDLStrategyHandoff,
+				}
+				if appendErr := appendSoundCloudFreeDLStuckRecord(stuckLogPath, stuckRecord); appendErr == nil {
+					stuckLogCount++
+				}
+				failureMessage = fmt.Sprintf("[%s] browser launch failed for %s: %v", source.ID, track.ID, openErr)
+				failureDetails = map[string]any{
+					"purchase_url": sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
+					"strategy":     freeDLStrategyHandoff,
+				}
+				s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "browser-launch"))
+				return false
+			}
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackProgress, progress.StageGateOpened, 10, ""))
+			return true
+		}
+		observeWait := func(status browserDownloadWaitStatus) {
+			event := trackEvent(progress.TrackProgress, progress.StageWaitingForBrowser, 25, "")
+			event.Elapsed = status.Elapsed
+			event.Idle = status.Idle
+			s.emitSourceTrackEvent(flow, source, event)
+		}
+		// settleDetectError handles a browser download that never arrived:
+		// timeouts skip the track and anything else fails the source run. It
+		// reports whether the loop can go on.
+		settleDetectError := func(downloadsDir string, detectErr error) bool {
 			if errors.Is(detectErr, errBrowserDownloadIdleTimeout) || errors.Is(detectErr, errBrowserDownloadMaxTimeout) {
 				skippedHypedditTimeout++
 				stuckRecord := soundCloudFreeDLStuckRecord{
@@ -321,7 +687,7 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 					DownloadDir:   downloadsDir,
 					Stage:         "browser-wait-timeout",
 					Error:         detectErr.Error(),
-					Strategy:      "browser-handoff",
+					Strategy:      freeDLStrategyHandoff,
 				}
 				if appendErr := appendSoundCloudFreeDLStuckRecord(stuckLogPath, stuckRecord); appendErr == nil {
 					stuckLogCount++
@@ -345,11 +711,12 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 						"soundcloud_url": metadata.SoundCloudURL,
 						"purchase_url":   sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
 						"download_dir":   downloadsDir,
-						"strategy":       "browser-handoff",
+						"strategy":       freeDLStrategyHandoff,
 						"error":          detectErr.Error(),
 					},
 				})
-				continue
+				s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackSkip, "", 0, "hypeddit-timeout"))
+				return commits.Skip(remotePos) == nil
 			}
 			stuckRecord := soundCloudFreeDLStuckRecord{
 				Timestamp:     s.Now().UTC().Format(time.RFC3339Nano),
@@ -362,7 +729,7 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 				DownloadDir:   downloadsDir,
 				Stage:         "browser-detect",
 				Error:         detectErr.Error(),
-				Strategy:      "browser-handoff",
+				Strategy:      freeDLStrategyHandoff,
 			}
 			if appendErr := appendSoundCloudFreeDLStuckRecord(stuckLogPath, stuckRecord); appendErr == nil {
 				stuckLogCount++
@@ -374,77 +741,91 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 				"artist":         metadata.Artist,
 				"soundcloud_url": metadata.SoundCloudURL,
 				"purchase_url":   sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
-				"strategy":       "browser-handoff",
+				"strategy":       freeDLStrategyHandoff,
 				"download_dir":   downloadsDir,
 			}
-			break
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "browser-detect"))
+			return false
 		}
-		downloadedPath, moveErr := moveDownloadedMediaToTargetFn(detectedPath, targetDir)
-		if moveErr != nil {
-			stuckRecord := soundCloudFreeDLStuckRecord{
-				Timestamp:     s.Now().UTC().Format(time.RFC3339Nano),
-				SourceID:      source.ID,
-				TrackID:       track.ID,
-				Title:         strings.TrimSpace(metadata.Title),
-				Artist:        strings.TrimSpace(metadata.Artist),
-				SoundCloudURL: strings.TrimSpace(metadata.SoundCloudURL),
-				PurchaseURL:   sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
-				DownloadDir:   downloadsDir,
-				Stage:         "post-process-move",
-				Error:         moveErr.Error(),
-				Strategy:      "browser-handoff",
-			}
-			if appendErr := appendSoundCloudFreeDLStuckRecord(stuckLogPath, stuckRecord); appendErr == nil {
-				stuckLogCount++
+
+		// With freedl.concurrent_gates above one, gates are opened in
+		// batches and their downloads are told apart by title and artist.
+		if concurrentGates > 1 {
+			if len(gateBatch.gates) == 0 {
+				batchDir, batchBefore, setupErr := snapshotBrowserDownloadsDir()
+				if setupErr != nil {
+					failureMessage = fmt.Sprintf("[%s] browser download setup failed for %s: %v", source.ID, track.ID, setupErr)
+					s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "browser-setup"))
+					break
+				}
+				gateBatch = freeDLGateBatch{downloadsDir: batchDir, before: batchBefore, openedAt: s.Now()}
 			}
-			failureMessage = fmt.Sprintf("[%s] browser download post-processing failed for %s: %v", source.ID, track.ID, moveErr)
-			failureDetails = map[string]any{
-				"purchase_url": sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
-				"strategy":     "browser-handoff",
-				"download_dir": downloadsDir,
-				"source_path":  detectedPath,
+			if !openGate(gateBatch.downloadsDir) {
+				break
 			}
-			break
-		}
-
-		if tagErr := applySoundCloudTrackMetadataFn(ctx, downloadedPath, metadata); tagErr != nil {
-			_ = s.Emitter.Emit(output.Event{
-				Timestamp: s.Now(),
-				Level:     output.LevelWarn,
-				Event:     output.EventSourcePreflight,
-				SourceID:  source.ID,
-				Message:   fmt.Sprintf("[%s] metadata tagging warning for %s: %v", source.ID, track.ID, tagErr),
+			gateBatch.gates = append(gateBatch.gates, pendingFreeDLGate{
+				expectation: browserDownloadExpectation{Key: track.ID, Metadata: metadata},
+				observe:     observeWait,
+				finish:      finishDownload,
+				settle:      settleDetectError,
 			})
-		}
-
-		statePath := normalizeSoundCloudStatePath(targetDir, downloadedPath)
-		if appendErr := appendSoundCloudSyncStateEntry(sourceForExec.StateFile, track.ID, statePath); appendErr != nil {
-			failureMessage = fmt.Sprintf("[%s] failed to update soundcloud state file: %v", source.ID, appendErr)
-			break
-		}
-		if _, exists := knownArchiveIDs[track.ID]; !exists {
-			if appendErr := appendSoundCloudArchiveID(archivePath, track.ID); appendErr != nil {
-				failureMessage = fmt.Sprintf("[%s] failed to update soundcloud archive file: %v", source.ID, appendErr)
+			if len(gateBatch.gates) < concurrentGates {
+				continue
+			}
+			interrupted, ok := flushGateBatch()
+			if interrupted {
+				return interrupt()
+			}
+			if !ok {
 				break
 			}
-			knownArchiveIDs[track.ID] = struct{}{}
+			continue
 		}
 
-		doneLabel := soundCloudTrackDisplayName(metadata)
-		if doneLabel == "" {
-			doneLabel = displayName
+		downloadsDir, downloadsBefore, setupErr := snapshotBrowserDownloadsDir()
+		if setupErr != nil {
+			failureMessage = fmt.Sprintf("[%s] browser download setup failed for %s: %v", source.ID, track.ID, setupErr)
+			s.emitSourceTrackEvent(flow, source, trackEvent(progress.TrackFail, "", 0, "browser-setup"))
+			break
+		}
+		gateOpenedAt := s.Now()
+		if !openGate(downloadsDir) {
+			break
 		}
 		_ = s.Emitter.Emit(output.Event{
 			Timestamp: s.Now(),
 			Level:     output.LevelInfo,
 			Event:     output.EventSourcePreflight,
 			SourceID:  source.ID,
-			Message:   fmt.Sprintf("[%s] [done] %s (%s)", source.ID, track.ID, doneLabel),
+			Message:   fmt.Sprintf("[%s] [free-dl] waiting for completed browser download for %s in %s", source.ID, track.ID, downloadsDir),
 		})
+		detectedPath, detectErr := detectBrowserDownloadedFileFn(ctx, downloadsDir, downloadsBefore, waitSettings, metadata, observeWait)
+		s.freeDLBudget.spend(s.Now().Sub(gateOpenedAt))
+		if detectErr != nil {
+			if errors.Is(detectErr, context.Canceled) || errors.Is(detectErr, context.DeadlineExceeded) {
+				return interrupt()
+			}
+			if !settleDetectError(downloadsDir, detectErr) {
+				break
+			}
+			continue
+		}
+		if !finishDownload(detectedPath, downloadsDir, freeDLStrategyHandoff) {
+			break
+		}
+	}
+	if failureMessage == "" && len(gateBatch.gates) > 0 && finishSoundCloudTagResults(tagPipeline.Completed()) == nil {
+		if interrupted, _ := flushGateBatch(); interrupted {
+			return interrupt()
+		}
 	}
+	// Flush after a failure too, so tracks that finished behind the failed
+	// position are still recorded.
+	_ = finishSoundCloudTagResults(tagPipeline.Close())
+	_ = commits.Flush()
 
 	if failureMessage != "" {
-		s.cleanupArtifactsOnFailure(source.ID, targetDir, preArtifacts, cleanupSuffixes)
+		s.cleanupArtifactsOnFailure(cfg, source.ID, targetDir, preArtifacts, cleanupPatterns)
 		if cleanupErr := cleanupTempStateFiles(stateSwap); cleanupErr != nil {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -482,6 +863,7 @@ func (s *Syncer) runSoundCloudFreeDownloadSource(
 		"skipped_no_free_dl":       skippedNoLink,
 		"skipped_unsupported_host": skippedUnsupportedHost,
 		"skipped_hypeddit_timeout": skippedHypedditTimeout,
+		"deferred_budget":          deferredBudget,
 		"stuck_log_count":          stuckLogCount,
 	}
 	if strings.TrimSpace(stuckLogPath) != "" {
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Free-DL gate strategies, as recorded in stuck records and event details.
+const (
+	freeDLStrategyHandoff  = "browser-handoff"
+	freeDLStrategyHeadless = "headless-automation"
+	freeDLStrategyDirect   = "direct-download"
+)
+
+// directDownloadMaxBytes caps a direct free-DL download; no single track
+// file comes close.
+const directDownloadMaxBytes = 2 << 30
+
+var (
+	downloadDirectFreeDLFileFn = downloadDirectFreeDLFile
+	directDownloadHTTPClient   = &http.Client{}
+)
+
+// freeDLGateHost is one entry of freeDLGateHosts: how a free-download host's
+// purchase links are recognised and how the flow gets their file.
+type freeDLGateHost struct {
+	// Name labels the host in log lines.
+	Name    string
+	Matches func(link *url.URL) bool
+	// Headless gates are completed in headless Chrome when
+	// freedl.automation is headless; the others go straight to the browser
+	// handoff.
+	Headless bool
+	// Direct links serve the file itself, so it is fetched over HTTPS with
+	// no gate to complete. A failed fetch falls back to the handoff.
+	Direct bool
+}
+
+// freeDLGateHosts lists the supported free-download hosts in match order.
+// Purchase links matching none of them are skipped as
+// unsupported-free-download-host.
+var freeDLGateHosts = []freeDLGateHost{
+	{Name: "hypeddit", Matches: matchGateDomain("hypeddit.com"), Headless: true},
+	{Name: "toneden", Matches: matchGateDomain("toneden.io"), Headless: true},
+	// FanLink unlocks and BandLab downloads ask for a sign-in on the host
+	// itself, which automation cannot do for the user.
+	{Name: "fanlink", Matches: matchGateDomain("fanlink.to")},
+	{Name: "bandlab", Matches: matchGateDomain("bandlab.com")},
+	{Name: "direct", Matches: isDirectMediaLink, Direct: true},
+}
+
+// resolveFreeDLGateHost returns the host entry for a purchase link.
+func resolveFreeDLGateHost(raw string) (freeDLGateHost, bool) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return freeDLGateHost{}, false
+	}
+	for _, host := range freeDLGateHosts {
+		if host.Matches(parsed) {
+			return host, true
+		}
+	}
+	return freeDLGateHost{}, false
+}
+
+// matchGateDomain matches links on domain or any of its subdomains.
+func matchGateDomain(domain string) func(*url.URL) bool {
+	return func(link *url.URL) bool {
+		host := strings.ToLower(strings.TrimSpace(link.Hostname()))
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+}
+
+// isDirectMediaLink matches HTTPS links whose path names a media file.
+// Plain-HTTP media links are not fetched.
+func isDirectMediaLink(link *url.URL) bool {
+	return link.Scheme == "https" && isMediaExt(strings.ToLower(path.Ext(link.Path)))
+}
+
+// downloadDirectFreeDLFile fetches a direct free-DL link into a temporary
+// directory the caller removes and returns the file's path. The name comes
+// from Content-Disposition or the link's path; responses that are not media
+// files (a landing page behind the link) are rejected.
+func downloadDirectFreeDLFile(ctx context.Context, rawURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "udl/scdl-freedl")
+	resp, err := directDownloadHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("direct download failed: status=%d", resp.StatusCode)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); strings.HasPrefix(mediaType, "text/") {
+		return "", fmt.Errorf("direct download returned %s, not a media file", mediaType)
+	}
+	name := path.Base(resp.Request.URL.Path)
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+	name = filepath.Base(strings.TrimSpace(name))
+	if !isMediaExt(strings.ToLower(filepath.Ext(name))) {
+		return "", fmt.Errorf("direct download %q is not a media file", name)
+	}
+
+	dir, err := os.MkdirTemp("", "udl-freedl-direct-*")
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, name)
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	written, copyErr := io.Copy(file, io.LimitReader(resp.Body, directDownloadMaxBytes+1))
+	closeErr := file.Close()
+	switch {
+	case copyErr != nil:
+		err = copyErr
+	case closeErr != nil:
+		err = closeErr
+	case written > directDownloadMaxBytes:
+		err = fmt.Errorf("direct download exceeds %d bytes", int64(directDownloadMaxBytes))
+	case written == 0:
+		err = fmt.Errorf("direct download is empty")
+	}
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	return target, nil
+}
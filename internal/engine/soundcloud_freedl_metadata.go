@@ -10,7 +10,6 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -18,6 +17,7 @@ import (
 	"time"
 
 	"github.com/jaa/update-downloads/internal/fileops"
+	"github.com/jaa/update-downloads/internal/httpcache"
 )
 
 var errSoundCloudNoFreeDownloadLink = errors.New("soundcloud track has no free-download link")
@@ -47,9 +47,21 @@ type soundCloudFreeDownloadMetadata struct {
 	Title         string
 	Artist        string
 	Genre         string
+	Label         string
+	CatalogNumber string
 	SoundCloudURL string
 	ArtworkURL    string
 	PurchaseURL   string
+	// ArtworkVariants lists SoundCloud artwork sizes to try in order (for
+	// example original, t500x500). Empty means t500x500 only.
+	ArtworkVariants []string
+	// ArtworkPath is artwork already fetched by the tag pipeline; when set
+	// (or ArtworkErr records a failed fetch) tagging skips its own download.
+	ArtworkPath string
+	ArtworkErr  error
+	// QualityNote is appended to the comment tag after the SoundCloud URL;
+	// freedl.verify_quality sets it on suspect downloads.
+	QualityNote string
 }
 
 func fetchSoundCloudFreeDownloadMetadata(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
@@ -69,7 +81,7 @@ func fetchSoundCloudFreeDownloadMetadata(ctx context.Context, track soundCloudRe
 	}
 	req.Header.Set("User-Agent", "udl/soundcloud-freedl")
 
-	client := &http.Client{Timeout: 20 * time.Second}
+	client := httpcache.Client(ctx, &http.Client{Timeout: 20 * time.Second})
 	resp, err := client.Do(req)
 	if err != nil {
 		return metadata, fmt.Errorf("soundcloud track page request failed: %w", err)
@@ -207,6 +219,21 @@ func resolveSoundCloudArtworkURL(raw string) string {
 	return strings.Replace(trimmed, "-large.", "-t500x500.", 1)
 }
 
+// withMetadataEnrichment layers provider results under scraped SoundCloud
+// metadata: an existing genre wins, label/catalog number are only provider-sourced.
+func (m soundCloudFreeDownloadMetadata) withMetadataEnrichment(enrichment MetadataEnrichment) soundCloudFreeDownloadMetadata {
+	if strings.TrimSpace(m.Genre) == "" {
+		m.Genre = strings.TrimSpace(enrichment.Genre)
+	}
+	if strings.TrimSpace(m.Label) == "" {
+		m.Label = strings.TrimSpace(enrichment.Label)
+	}
+	if strings.TrimSpace(m.CatalogNumber) == "" {
+		m.CatalogNumber = strings.TrimSpace(enrichment.CatalogNumber)
+	}
+	return m
+}
+
 func applySoundCloudTrackMetadata(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
 	trimmed := strings.TrimSpace(filePath)
 	if trimmed == "" {
@@ -228,10 +255,12 @@ func applySoundCloudTrackMetadata(ctx context.Context, filePath string, metadata
 	_ = tempFile.Close()
 	_ = os.Remove(tempPath)
 
-	artworkPath := ""
+	artworkPath := strings.TrimSpace(metadata.ArtworkPath)
 	artworkEmbedErr := error(nil)
-	if artworkURL := strings.TrimSpace(metadata.ArtworkURL); artworkURL != "" {
-		downloadedArtworkPath, artworkErr := downloadSoundCloudArtwork(ctx, artworkURL, filepath.Dir(trimmed))
+	if metadata.ArtworkErr != nil {
+		artworkEmbedErr = fmt.Errorf("artwork download failed: %w", metadata.ArtworkErr)
+	} else if artworkURL := strings.TrimSpace(metadata.ArtworkURL); artworkURL != "" && artworkPath == "" {
+		downloadedArtworkPath, artworkErr := downloadSoundCloudArtworkVariants(ctx, artworkURL, metadata.ArtworkVariants, filepath.Dir(trimmed))
 		if artworkErr == nil {
 			artworkPath = downloadedArtworkPath
 			defer func() {
@@ -296,13 +325,18 @@ func runSoundCloudMetadataFFmpeg(
 	if genre := strings.TrimSpace(metadata.Genre); genre != "" {
 		args = append(args, "-metadata", "genre="+genre)
 	}
-	if sourceURL := strings.TrimSpace(metadata.SoundCloudURL); sourceURL != "" {
-		args = append(args, "-metadata", "comment="+sourceURL)
+	if label := strings.TrimSpace(metadata.Label); label != "" {
+		args = append(args, "-metadata", "publisher="+label)
+	}
+	if catalogNumber := strings.TrimSpace(metadata.CatalogNumber); catalogNumber != "" {
+		args = append(args, "-metadata", "catalognumber="+catalogNumber)
+	}
+	if comment := strings.TrimSpace(strings.TrimSpace(metadata.SoundCloudURL) + " " + strings.TrimSpace(metadata.QualityNote)); comment != "" {
+		args = append(args, "-metadata", "comment="+comment)
 	}
 	args = append(args, outputPath)
 
-	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
-	output, runErr := cmd.CombinedOutput()
+	output, runErr := RunPostProcess(ctx, "ffmpeg", args...)
 	if runErr != nil {
 		_ = os.Remove(outputPath)
 		trimmedOutput := strings.TrimSpace(string(output))
@@ -314,8 +348,50 @@ func runSoundCloudMetadataFFmpeg(
 	return nil
 }
 
+// soundCloudArtworkVariantPattern matches the size suffix SoundCloud puts
+// before the extension of artwork and avatar URLs.
+var soundCloudArtworkVariantPattern = regexp.MustCompile(`-(original|large|crop|t[0-9]+x[0-9]+)(\.[A-Za-z0-9]+)$`)
+
+// soundCloudArtworkVariantURL swaps the size suffix of a SoundCloud artwork URL.
+// URLs without a recognizable suffix are returned unchanged.
+func soundCloudArtworkVariantURL(raw string, variant string) string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" || strings.TrimSpace(variant) == "" {
+		return trimmed
+	}
+	return soundCloudArtworkVariantPattern.ReplaceAllString(trimmed, "-"+strings.TrimSpace(variant)+"$2")
+}
+
+// downloadSoundCloudArtworkVariants tries each preferred variant in order and
+// returns the first successful download. Larger variants such as original are
+// not always available, so later entries act as fallbacks.
+func downloadSoundCloudArtworkVariants(ctx context.Context, rawURL string, variants []string, tempDir string) (string, error) {
+	if len(variants) == 0 {
+		return downloadSoundCloudArtwork(ctx, rawURL, tempDir)
+	}
+	var lastErr error
+	tried := map[string]struct{}{}
+	for _, variant := range variants {
+		candidate := soundCloudArtworkVariantURL(rawURL, variant)
+		if _, seen := tried[candidate]; seen {
+			continue
+		}
+		tried[candidate] = struct{}{}
+		path, err := downloadSoundCloudArtworkURL(ctx, candidate, tempDir)
+		if err == nil {
+			return path, nil
+		}
+		lastErr = err
+	}
+	return "", lastErr
+}
+
 func downloadSoundCloudArtwork(ctx context.Context, rawURL string, tempDir string) (string, error) {
-	resolvedURL := strings.TrimSpace(resolveSoundCloudArtworkURL(rawURL))
+	return downloadSoundCloudArtworkURL(ctx, resolveSoundCloudArtworkURL(rawURL), tempDir)
+}
+
+func downloadSoundCloudArtworkURL(ctx context.Context, rawURL string, tempDir string) (string, error) {
+	resolvedURL := strings.TrimSpace(rawURL)
 	if resolvedURL == "" {
 		return "", fmt.Errorf("empty artwork url")
 	}
@@ -0,0 +1,79 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+var probeSpectralCeilingFn = ProbeSpectralCeiling
+
+// verifySoundCloudFreeDLQuality runs the spectral check on a lossless
+// download. A suspect file is kept, but warned about, recorded in
+// <state_dir>/<source>.freedl-quality.jsonl, and given a comment-tag marker
+// that promote-freedl honors. A failed check only warns.
+func (s *Syncer) verifySoundCloudFreeDLQuality(
+	ctx context.Context,
+	cfg config.Config,
+	source config.Source,
+	trackID string,
+	targetDir string,
+	downloadedPath string,
+	metadata soundCloudFreeDownloadMetadata,
+) soundCloudFreeDownloadMetadata {
+	ceiling, err := probeSpectralCeilingFn(ctx, downloadedPath)
+	if err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [free-dl] quality check skipped for %s: %v", source.ID, trackID, err),
+		})
+		return metadata
+	}
+	if !ceiling.Suspect {
+		return metadata
+	}
+
+	metadata.QualityNote = fmt.Sprintf("%s ceiling=%s", SuspectQualityMarker, ceiling.Describe())
+	details := map[string]any{
+		"path":       downloadedPath,
+		"ceiling_hz": ceiling.CeilingHz,
+	}
+	logPath, err := resolveSoundCloudFreeDLQualityLogPath(cfg.Defaults.StateDir, source.ID)
+	if err == nil {
+		err = appendSoundCloudFreeDLQualityRecord(logPath, soundCloudFreeDLQualityRecord{
+			Timestamp: s.Now().UTC().Format(time.RFC3339Nano),
+			SourceID:  source.ID,
+			TrackID:   trackID,
+			Title:     strings.TrimSpace(metadata.Title),
+			Artist:    strings.TrimSpace(metadata.Artist),
+			Path:      normalizeSoundCloudStatePath(targetDir, downloadedPath),
+			CeilingHz: ceiling.CeilingHz,
+			Suspect:   true,
+		})
+		details["quality_log_path"] = logPath
+	}
+	if err != nil {
+		details["quality_log_error"] = err.Error()
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelWarn,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message: fmt.Sprintf(
+			"[%s] [free-dl] %s looks upsampled from lossy: spectrum ends at %s (suspect-quality)",
+			source.ID,
+			trackID,
+			ceiling.Describe(),
+		),
+		Details: details,
+	})
+	return metadata
+}
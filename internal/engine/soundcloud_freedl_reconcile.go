@@ -0,0 +1,200 @@
+package engine
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// soundCloudFreeDLAdoptWindow bounds how old an untracked file in target_dir
+// may be and still be adopted by a free-dl source. A crash between the move
+// and the state append is noticed on the next run, so older untracked files
+// are left alone.
+const soundCloudFreeDLAdoptWindow = 7 * 24 * time.Hour
+
+type soundCloudFreeDLAdoption struct {
+	Track   soundCloudRemoteTrack
+	RelPath string
+}
+
+// adoptUntrackedFreeDownloads recovers free-dl tracks whose browser download
+// reached target_dir but whose state append never happened, e.g. because the
+// process died in between. Recent media files that no source sharing
+// target_dir tracks are matched against the planned tracks by title; each
+// unambiguous match is appended to the state and archive files and dropped
+// from the plan instead of re-opening the gate. It returns the tracks still
+// to download.
+func (s *Syncer) adoptUntrackedFreeDownloads(
+	cfg config.Config,
+	source config.Source,
+	sourceForExec config.Source,
+	stateSwap soundCloudStateSwap,
+	targetDir string,
+	archivePath string,
+	knownArchiveIDs idSet,
+	plannedTracks []soundCloudRemoteTrack,
+) []soundCloudRemoteTrack {
+	if len(plannedTracks) == 0 {
+		return plannedTracks
+	}
+	files, err := snapshotMediaFiles(targetDir)
+	if err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] unable to scan target_dir for untracked free-dl downloads: %v", source.ID, err),
+		})
+		return plannedTracks
+	}
+	tracked := freeDLTrackedPaths(cfg, source, []string{sourceForExec.StateFile, stateSwap.OriginalSyncPath}, targetDir)
+	cutoff := s.Now().Add(-soundCloudFreeDLAdoptWindow)
+	untracked := map[string]mediaFileSnapshot{}
+	for rel, snapshot := range files {
+		if _, ok := tracked[rel]; ok || snapshot.ModTime.Before(cutoff) {
+			continue
+		}
+		untracked[rel] = snapshot
+	}
+	adoptions := matchUntrackedFreeDownloads(untracked, plannedTracks)
+	if len(adoptions) == 0 {
+		return plannedTracks
+	}
+
+	adopted := map[string]struct{}{}
+	for _, adoption := range adoptions {
+		track := adoption.Track
+		if err := appendSoundCloudSyncStateEntry(sourceForExec.StateFile, track.ID, adoption.RelPath); err != nil {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] unable to adopt %s into state: %v", source.ID, adoption.RelPath, err),
+			})
+			continue
+		}
+		if err := s.recordSoundCloudProvenance(cfg, source, track.ID, soundCloudProvenanceAdopt, adoption.RelPath); err != nil {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] unable to record provenance for adopted %s: %v", source.ID, track.ID, err),
+			})
+		}
+		if _, exists := knownArchiveIDs[track.ID]; !exists {
+			if err := appendSoundCloudArchiveID(archivePath, track.ID); err != nil {
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelWarn,
+					Event:     output.EventSourcePreflight,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] unable to add adopted %s to archive: %v", source.ID, track.ID, err),
+				})
+			} else {
+				knownArchiveIDs[track.ID] = struct{}{}
+			}
+		}
+		adopted[track.ID] = struct{}{}
+		displayName := strings.TrimSpace(track.Title)
+		if displayName == "" {
+			displayName = track.ID
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [adopt] %s (%s) found untracked in target_dir: %s", source.ID, track.ID, displayName, adoption.RelPath),
+			Details: map[string]any{
+				"track_id":   track.ID,
+				"local_path": adoption.RelPath,
+			},
+		})
+	}
+
+	remaining := make([]soundCloudRemoteTrack, 0, len(plannedTracks)-len(adopted))
+	for _, track := range plannedTracks {
+		if _, ok := adopted[track.ID]; !ok {
+			remaining = append(remaining, track)
+		}
+	}
+	return remaining
+}
+
+// freeDLTrackedPaths collects the target_dir-relative paths already claimed
+// by source's state files and by the state of every other source writing to
+// the same target_dir.
+func freeDLTrackedPaths(cfg config.Config, source config.Source, statePaths []string, targetDir string) map[string]struct{} {
+	tracked := map[string]struct{}{}
+	add := func(paths map[string]string) {
+		for _, raw := range paths {
+			if rel := normalizeSoundCloudStatePath(targetDir, filepath.FromSlash(raw)); rel != "" {
+				tracked[rel] = struct{}{}
+			}
+		}
+	}
+	for _, statePath := range statePaths {
+		if strings.TrimSpace(statePath) == "" {
+			continue
+		}
+		if paths, err := stateLocalPaths(source, statePath); err == nil {
+			add(paths)
+		}
+	}
+	sharedDir := dedupeTargetDir(source)
+	for _, other := range cfg.Sources {
+		if other.ID == source.ID || sharedDir == "" || dedupeTargetDir(other) != sharedDir {
+			continue
+		}
+		if strings.TrimSpace(other.StateFile) == "" {
+			continue
+		}
+		statePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, other.StateFile)
+		if err != nil {
+			continue
+		}
+		if paths, err := stateLocalPaths(other, statePath); err == nil {
+			add(paths)
+		}
+	}
+	return tracked
+}
+
+// matchUntrackedFreeDownloads pairs untracked files with planned tracks whose
+// normalized title appears in the file name. Files matching several tracks,
+// and tracks matched by several files, are skipped rather than guessed.
+func matchUntrackedFreeDownloads(untracked map[string]mediaFileSnapshot, plannedTracks []soundCloudRemoteTrack) []soundCloudFreeDLAdoption {
+	filesByTrack := map[string][]string{}
+	tracksByFile := map[string]int{}
+	for rel := range untracked {
+		stem := strings.TrimSpace(strings.TrimSuffix(filepath.Base(rel), filepath.Ext(rel)))
+		key := normalizeTrackKey(stem)
+		if key == "" {
+			continue
+		}
+		for _, track := range plannedTracks {
+			title := normalizeTrackKey(track.Title)
+			if title == "" || !strings.Contains(key, title) {
+				continue
+			}
+			filesByTrack[track.ID] = append(filesByTrack[track.ID], rel)
+			tracksByFile[rel]++
+		}
+	}
+	adoptions := []soundCloudFreeDLAdoption{}
+	for _, track := range plannedTracks {
+		files := filesByTrack[track.ID]
+		if len(files) != 1 || tracksByFile[files[0]] != 1 {
+			continue
+		}
+		adoptions = append(adoptions, soundCloudFreeDLAdoption{Track: track, RelPath: files[0]})
+	}
+	return adoptions
+}
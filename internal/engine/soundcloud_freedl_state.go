@@ -25,6 +25,19 @@ type soundCloudFreeDLStuckRecord struct {
 	Strategy      string `json:"strategy"`
 }
 
+// soundCloudFreeDLQualityRecord flags a free-DL download whose spectrum
+// looks upsampled from a lossy source (freedl.verify_quality).
+type soundCloudFreeDLQualityRecord struct {
+	Timestamp string  `json:"timestamp"`
+	SourceID  string  `json:"source_id"`
+	TrackID   string  `json:"track_id"`
+	Title     string  `json:"title,omitempty"`
+	Artist    string  `json:"artist,omitempty"`
+	Path      string  `json:"path"`
+	CeilingHz float64 `json:"ceiling_hz"`
+	Suspect   bool    `json:"suspect"`
+}
+
 func sanitizeSoundCloudFreeDownloadURL(raw string) string {
 	parsed, err := url.Parse(strings.TrimSpace(raw))
 	if err != nil {
@@ -94,6 +107,14 @@ func appendLine(path string, line string) error {
 }
 
 func resolveSoundCloudFreeDLStuckLogPath(defaultStateDir string, sourceID string) (string, error) {
+	return resolveSoundCloudFreeDLLogPath(defaultStateDir, sourceID, ".freedl-stuck.jsonl")
+}
+
+func resolveSoundCloudFreeDLQualityLogPath(defaultStateDir string, sourceID string) (string, error) {
+	return resolveSoundCloudFreeDLLogPath(defaultStateDir, sourceID, ".freedl-quality.jsonl")
+}
+
+func resolveSoundCloudFreeDLLogPath(defaultStateDir string, sourceID string, suffix string) (string, error) {
 	stateDir, err := config.ExpandPath(defaultStateDir)
 	if err != nil {
 		return "", err
@@ -105,10 +126,18 @@ func resolveSoundCloudFreeDLStuckLogPath(defaultStateDir string, sourceID string
 	if trimmedID == "" {
 		return "", fmt.Errorf("source id is required")
 	}
-	return filepath.Join(stateDir, trimmedID+".freedl-stuck.jsonl"), nil
+	return filepath.Join(stateDir, trimmedID+suffix), nil
 }
 
 func appendSoundCloudFreeDLStuckRecord(path string, record soundCloudFreeDLStuckRecord) error {
+	return appendSoundCloudFreeDLLogRecord(path, record)
+}
+
+func appendSoundCloudFreeDLQualityRecord(path string, record soundCloudFreeDLQualityRecord) error {
+	return appendSoundCloudFreeDLLogRecord(path, record)
+}
+
+func appendSoundCloudFreeDLLogRecord(path string, record any) error {
 	trimmed := strings.TrimSpace(path)
 	if trimmed == "" {
 		return nil
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// soundCloudTagPipelineDepth bounds how many downloaded tracks may wait for
+// artwork or ffmpeg at once, so a slow remux cannot pile up temp artwork.
+const soundCloudTagPipelineDepth = 2
+
+var prefetchSoundCloudArtworkFn = downloadSoundCloudArtworkVariants
+
+// soundCloudTagJob is one downloaded track waiting for artwork and tags.
+// Finish runs on the flow's goroutine once the job's result is collected, so
+// the track's remaining progress events keep their usual order per track.
+type soundCloudTagJob struct {
+	Path     string
+	Metadata soundCloudFreeDownloadMetadata
+	Finish   func(tagErr error) error
+}
+
+type soundCloudTagResult struct {
+	Job soundCloudTagJob
+	Err error
+}
+
+// soundCloudTagPipeline tags downloaded tracks in two overlapping stages,
+// an artwork fetcher and an ffmpeg remuxer joined by a bounded queue: the
+// next track's artwork downloads while ffmpeg rewrites the previous one.
+// Results are collected in submission order and handed back to the caller's
+// goroutine, which owns all event emission.
+type soundCloudTagPipeline struct {
+	jobs    chan soundCloudTagJob
+	fetched chan soundCloudTagJob
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	results []soundCloudTagResult
+	closed  bool
+}
+
+func startSoundCloudTagPipeline(ctx context.Context, depth int) *soundCloudTagPipeline {
+	if depth < 1 {
+		depth = 1
+	}
+	p := &soundCloudTagPipeline{
+		jobs:    make(chan soundCloudTagJob, depth),
+		fetched: make(chan soundCloudTagJob, depth),
+	}
+	p.wg.Add(2)
+	go p.fetchArtwork(ctx)
+	go p.remux(ctx)
+	return p
+}
+
+// Submit queues a job, blocking while the pipeline is full.
+func (p *soundCloudTagPipeline) Submit(job soundCloudTagJob) {
+	p.jobs <- job
+}
+
+// Completed returns the results finished since the last call without waiting.
+func (p *soundCloudTagPipeline) Completed() []soundCloudTagResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results := p.results
+	p.results = nil
+	return results
+}
+
+// Close waits for every queued job and returns the remaining results. It is
+// safe to call more than once; later calls return nothing.
+func (p *soundCloudTagPipeline) Close() []soundCloudTagResult {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+	return p.Completed()
+}
+
+func (p *soundCloudTagPipeline) fetchArtwork(ctx context.Context) {
+	defer p.wg.Done()
+	defer close(p.fetched)
+	for job := range p.jobs {
+		artworkURL := strings.TrimSpace(job.Metadata.ArtworkURL)
+		if artworkURL != "" && strings.TrimSpace(job.Metadata.ArtworkPath) == "" && ctx.Err() == nil {
+			artworkPath, err := prefetchSoundCloudArtworkFn(ctx, artworkURL, job.Metadata.ArtworkVariants, filepath.Dir(job.Path))
+			if err != nil {
+				job.Metadata.ArtworkErr = err
+			} else {
+				job.Metadata.ArtworkPath = artworkPath
+			}
+		}
+		p.fetched <- job
+	}
+}
+
+func (p *soundCloudTagPipeline) remux(ctx context.Context) {
+	defer p.wg.Done()
+	for job := range p.fetched {
+		err := ctx.Err()
+		if err == nil {
+			err = applySoundCloudTrackMetadataFn(ctx, job.Path, job.Metadata)
+		}
+		if artworkPath := strings.TrimSpace(job.Metadata.ArtworkPath); artworkPath != "" {
+			_ = os.Remove(artworkPath)
+		}
+		p.mu.Lock()
+		p.results = append(p.results, soundCloudTagResult{Job: job, Err: err})
+		p.mu.Unlock()
+	}
+}
+
+// finishSoundCloudTagResults runs each result's Finish in order and returns
+// the first error; later results are still finished so their events are not
+// lost.
+func finishSoundCloudTagResults(results []soundCloudTagResult) error {
+	var firstErr error
+	for _, result := range results {
+		if result.Job.Finish == nil {
+			continue
+		}
+		if err := result.Job.Finish(result.Err); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
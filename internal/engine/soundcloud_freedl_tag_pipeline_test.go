@@ -0,0 +1,85 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSoundCloudTagPipelineFetchesNextArtworkWhileTagging(t *testing.T) {
+	tmp := t.TempDir()
+	origPrefetch := prefetchSoundCloudArtworkFn
+	origApply := applySoundCloudTrackMetadataFn
+	t.Cleanup(func() {
+		prefetchSoundCloudArtworkFn = origPrefetch
+		applySoundCloudTrackMetadataFn = origApply
+	})
+
+	secondFetched := make(chan struct{})
+	prefetchSoundCloudArtworkFn = func(ctx context.Context, artworkURL string, variants []string, dir string) (string, error) {
+		if artworkURL == "https://img.example/broken.jpg" {
+			return "", errors.New("HTTP 404")
+		}
+		path := filepath.Join(dir, filepath.Base(artworkURL))
+		if err := os.WriteFile(path, []byte("jpg"), 0o644); err != nil {
+			return "", err
+		}
+		if artworkURL == "https://img.example/two.jpg" {
+			close(secondFetched)
+		}
+		return path, nil
+	}
+	applied := map[string]soundCloudFreeDownloadMetadata{}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		if metadata.ArtworkURL == "https://img.example/one.jpg" {
+			select {
+			case <-secondFetched:
+			case <-time.After(5 * time.Second):
+				t.Errorf("second artwork was not fetched while the first track was tagging")
+			}
+		}
+		if metadata.ArtworkPath != "" {
+			if _, err := os.Stat(metadata.ArtworkPath); err != nil {
+				t.Errorf("expected prefetched artwork on disk for %s: %v", filePath, err)
+			}
+		}
+		applied[filepath.Base(filePath)] = metadata
+		return nil
+	}
+
+	pipeline := startSoundCloudTagPipeline(context.Background(), soundCloudTagPipelineDepth)
+	finished := []string{}
+	for _, name := range []string{"one", "two", "broken"} {
+		name := name
+		pipeline.Submit(soundCloudTagJob{
+			Path:     filepath.Join(tmp, name+".mp3"),
+			Metadata: soundCloudFreeDownloadMetadata{ArtworkURL: "https://img.example/" + name + ".jpg"},
+			Finish: func(tagErr error) error {
+				if tagErr != nil {
+					t.Errorf("unexpected tag error for %s: %v", name, tagErr)
+				}
+				finished = append(finished, name)
+				return nil
+			},
+		})
+	}
+	finishSoundCloudTagResults(pipeline.Close())
+	if extra := pipeline.Close(); len(extra) != 0 {
+		t.Fatalf("expected second Close to return nothing, got %d result(s)", len(extra))
+	}
+
+	if len(finished) != 3 || finished[0] != "one" || finished[1] != "two" || finished[2] != "broken" {
+		t.Fatalf("expected results in submission order, got %v", finished)
+	}
+	if applied["broken.mp3"].ArtworkErr == nil || applied["broken.mp3"].ArtworkPath != "" {
+		t.Fatalf("expected failed artwork fetch to reach tagging as ArtworkErr, got %+v", applied["broken.mp3"])
+	}
+	for _, name := range []string{"one.jpg", "two.jpg"} {
+		if _, err := os.Stat(filepath.Join(tmp, name)); !os.IsNotExist(err) {
+			t.Fatalf("expected prefetched artwork %s to be removed after tagging, stat err=%v", name, err)
+		}
+	}
+}
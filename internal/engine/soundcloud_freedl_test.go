@@ -3,13 +3,18 @@ package engine
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
 )
 
 func TestParseSoundCloudHydratedSound(t *testing.T) {
@@ -41,15 +46,67 @@ func TestExtractSoundCloudBuyURLFallback(t *testing.T) {
 	}
 }
 
-func TestIsHypedditPurchaseURL(t *testing.T) {
-	if !isHypedditPurchaseURL("https://hypeddit.com/pichi/pichibofunk") {
-		t.Fatalf("expected hypeddit host to be detected")
+func TestResolveFreeDLGateHost(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://hypeddit.com/pichi/pichibofunk":       "hypeddit",
+		"https://www.hypeddit.com/track/abc":           "hypeddit",
+		"https://www.toneden.io/pichi/post/bofunk":     "toneden",
+		"https://fanlink.to/bofunk":                    "fanlink",
+		"https://www.bandlab.com/post/abc":             "bandlab",
+		"https://www.dropbox.com/s/abc/Bo%20Funk.wav":  "direct",
+		"https://example.com/files/bo-funk.mp3?dl=1":   "direct",
+		"http://example.com/files/bo-funk.mp3":         "",
+		"https://example.com/track/abc":                "",
+		"https://nothypeddit.com/pichi/pichibofunk":    "",
+		"mailto:pichi@example.com":                     "",
+		"https://hypeddit.com.example.com/pichi/track": "",
+	} {
+		host, ok := resolveFreeDLGateHost(raw)
+		if ok != (want != "") || host.Name != want {
+			t.Fatalf("resolve %s: expected %q, got %q (ok=%v)", raw, want, host.Name, ok)
+		}
+	}
+	if host, _ := resolveFreeDLGateHost("https://toneden.io/x"); !host.Headless || host.Direct {
+		t.Fatalf("expected toneden gates to be automated, got %+v", host)
+	}
+	if host, _ := resolveFreeDLGateHost("https://bandlab.com/x"); host.Headless || host.Direct {
+		t.Fatalf("expected bandlab gates to be handed off, got %+v", host)
 	}
-	if !isHypedditPurchaseURL("https://www.hypeddit.com/track/abc") {
-		t.Fatalf("expected hypeddit subdomain to be detected")
+}
+
+func TestDownloadDirectFreeDLFile(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bo-funk.mp3":
+			w.Header().Set("Content-Type", "audio/mpeg")
+			w.Header().Set("Content-Disposition", `attachment; filename="../PICHI - Bo Funk.mp3"`)
+			_, _ = w.Write([]byte("audio"))
+		case "/gate.mp3":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write([]byte("<html>sign in</html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	path, err := downloadDirectFreeDLFile(context.Background(), server.URL+"/bo-funk.mp3")
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	if filepath.Base(path) != "PICHI - Bo Funk.mp3" {
+		t.Fatalf("expected the Content-Disposition name without its directory, got %s", path)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "audio" {
+		t.Fatalf("unexpected downloaded content %q", data)
+	}
+
+	if _, err := downloadDirectFreeDLFile(context.Background(), server.URL+"/gate.mp3"); err == nil || !strings.Contains(err.Error(), "not a media file") {
+		t.Fatalf("expected an html landing page to be rejected, got %v", err)
 	}
-	if isHypedditPurchaseURL("https://example.com/track/abc") {
-		t.Fatalf("did not expect non-hypeddit host to be detected")
+	if _, err := downloadDirectFreeDLFile(context.Background(), server.URL+"/missing.mp3"); err == nil || !strings.Contains(err.Error(), "status=404") {
+		t.Fatalf("expected a missing file to fail, got %v", err)
 	}
 }
 
@@ -149,6 +206,97 @@ func TestBrowserOpenCommandDarwinWithAppOverride(t *testing.T) {
 	}
 }
 
+func TestBrowserOpenCommandWindowsUsesStartWithEscapedURL(t *testing.T) {
+	origGOOS := runtimeGOOS
+	runtimeGOOS = "windows"
+	t.Cleanup(func() {
+		runtimeGOOS = origGOOS
+	})
+
+	t.Setenv("UDL_FREEDL_BROWSER_APP", "")
+	bin, args, err := browserOpenCommand("https://hypeddit.com/pichi/pichibofunk?ref=sc&utm=a b")
+	if err != nil {
+		t.Fatalf("browserOpenCommand: %v", err)
+	}
+	if bin != "cmd" {
+		t.Fatalf("expected cmd binary, got %q", bin)
+	}
+	wantArgs := []string{"/c", "start", "", "https://hypeddit.com/pichi/pichibofunk?ref=sc^&utm=a^%20b"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("expected args %v, got %v", wantArgs, args)
+	}
+
+	t.Setenv("UDL_FREEDL_BROWSER_APP", `C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`)
+	_, args, err = browserOpenCommand("https://hypeddit.com/pichi/pichibofunk")
+	if err != nil {
+		t.Fatalf("browserOpenCommand: %v", err)
+	}
+	wantArgs = []string{"/c", "start", "", `C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`, "https://hypeddit.com/pichi/pichibofunk"}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("expected args %v, got %v", wantArgs, args)
+	}
+}
+
+func TestDefaultBrowserDownloadsDirWindowsUsesKnownFolder(t *testing.T) {
+	origGOOS := runtimeGOOS
+	origKnownFolder := knownDownloadsFolderFn
+	runtimeGOOS = "windows"
+	t.Cleanup(func() {
+		runtimeGOOS = origGOOS
+		knownDownloadsFolderFn = origKnownFolder
+	})
+	t.Setenv("UDL_FREEDL_BROWSER_DOWNLOAD_DIR", "")
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	moved := filepath.Join(t.TempDir(), "Moved Downloads")
+	knownDownloadsFolderFn = func() (string, error) { return moved + string(filepath.Separator), nil }
+	if dir, err := defaultBrowserDownloadsDir(); err != nil || dir != moved {
+		t.Fatalf("expected the known folder %q, got %q (%v)", moved, dir, err)
+	}
+
+	knownDownloadsFolderFn = func() (string, error) { return "", fmt.Errorf("element not found") }
+	if dir, err := defaultBrowserDownloadsDir(); err != nil || dir != filepath.Join(home, "Downloads") {
+		t.Fatalf("expected the profile Downloads fallback, got %q (%v)", dir, err)
+	}
+}
+
+func TestNormalizeBrowserDownloadsDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("UDL_TEST_DOWNLOADS", filepath.Join(home, "dl"))
+
+	cases := map[string]string{
+		`"` + filepath.Join(home, "Downloads") + `"`:        filepath.Join(home, "Downloads"),
+		"%USERPROFILE%" + string(filepath.Separator) + "dl": filepath.Join(home, "dl"),
+		"%UDL_TEST_DOWNLOADS%/":                             filepath.Join(home, "dl"),
+		`~\Downloads`:                                       filepath.Join(home, "Downloads"),
+		"~/Downloads/../dl":                                 filepath.Join(home, "dl"),
+		"/srv/%UDL_TEST_UNSET_VAR%/dl":                      "/srv/%UDL_TEST_UNSET_VAR%/dl",
+	}
+	for raw, want := range cases {
+		got, err := normalizeBrowserDownloadsDir(raw)
+		if err != nil || got != filepath.Clean(want) {
+			t.Fatalf("normalize %q: expected %q, got %q (%v)", raw, want, got, err)
+		}
+	}
+}
+
+func TestIsBrowserInProgressNameCoversWindowsBrowsers(t *testing.T) {
+	for _, name := range []string{"unconfirmed 123456.crdownload", "track.mp3.crdownload", "track.wav.part", "track.flac.tmp", "track.partial"} {
+		if !isBrowserInProgressName(name) {
+			t.Fatalf("expected %q to be in progress", name)
+		}
+	}
+	for _, name := range []string{"track.mp3", "desktop.ini", "track.wav"} {
+		if isBrowserInProgressName(name) {
+			t.Fatalf("expected %q not to be in progress", name)
+		}
+	}
+}
+
 func TestOpenURLInBrowserPropagatesLaunchFailure(t *testing.T) {
 	origRunBrowserCommand := runBrowserCommandFn
 	runBrowserCommandFn = func(ctx context.Context, bin string, args ...string) error {
@@ -213,7 +361,7 @@ func TestResolveBrowserDownloadIdleTimeoutOverride(t *testing.T) {
 		}
 	})
 
-	got := resolveBrowserDownloadIdleTimeout(5 * time.Minute)
+	got := resolveBrowserDownloadIdleTimeout(5*time.Minute, 2*time.Minute)
 	if got != 30*time.Second {
 		t.Fatalf("expected 30s idle timeout override, got %s", got)
 	}
@@ -227,6 +375,111 @@ func TestResolveSoundCloudArtworkURLUpgradesLargeVariant(t *testing.T) {
 	}
 }
 
+func TestResolveBrowserDownloadIdleTimeoutUsesConfiguredValue(t *testing.T) {
+	t.Setenv("UDL_FREEDL_BROWSER_IDLE_TIMEOUT", "")
+	if got := resolveBrowserDownloadIdleTimeout(5*time.Minute, 3*time.Minute); got != 3*time.Minute {
+		t.Fatalf("expected configured 3m idle timeout, got %s", got)
+	}
+	if got := resolveBrowserDownloadIdleTimeout(5*time.Minute, 0); got != time.Minute {
+		t.Fatalf("expected default 1m idle timeout, got %s", got)
+	}
+}
+
+func TestDetectBrowserDownloadedFileHonorsStableSamples(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "track.wav")
+	if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write download: %v", err)
+	}
+	polls := 0
+	wait := resolveBrowserDownloadWaitSettings(config.FreeDL{PollIntervalMS: 5, StableSamples: 4}, time.Minute)
+	startedAt := time.Now()
+	detected, err := detectBrowserDownloadedFile(context.Background(), dir, map[string]mediaFileSnapshot{}, wait, soundCloudFreeDownloadMetadata{}, func(browserDownloadWaitStatus) {
+		polls++
+	})
+	if err != nil {
+		t.Fatalf("detect: %v", err)
+	}
+	if detected != path {
+		t.Fatalf("expected %s, got %s", path, detected)
+	}
+	if elapsed := time.Since(startedAt); elapsed < 3*wait.PollInterval {
+		t.Fatalf("expected at least 3 poll intervals before 4 stable samples, got %s", elapsed)
+	}
+	if polls != 1 {
+		t.Fatalf("expected throttled single wait report, got %d", polls)
+	}
+}
+
+func TestAttributeBrowserDownloadsMatchesByTitleAndArtist(t *testing.T) {
+	now := time.Now()
+	expected := []browserDownloadExpectation{
+		{Key: "111", Metadata: soundCloudFreeDownloadMetadata{Title: "Night Drive", Artist: "Pichi"}},
+		{Key: "222", Metadata: soundCloudFreeDownloadMetadata{Title: "Night Drive", Artist: "Other"}},
+		{Key: "333", Metadata: soundCloudFreeDownloadMetadata{Title: "Sunrise", Artist: "Pichi"}},
+	}
+	files := map[string]mediaFileSnapshot{
+		"Other - Night Drive.wav": {Size: 5, ModTime: now},
+		"Pichi - Night Drive.wav": {Size: 5, ModTime: now.Add(time.Second)},
+	}
+	got := attributeBrowserDownloads(files, expected)
+	if got["111"] != "Pichi - Night Drive.wav" || got["222"] != "Other - Night Drive.wav" || len(got) != 2 {
+		t.Fatalf("unexpected attribution: %+v", got)
+	}
+
+	// A name matching no track waits until a single track is left.
+	files = map[string]mediaFileSnapshot{"download (1).mp3": {Size: 5, ModTime: now}}
+	if got := attributeBrowserDownloads(files, expected); len(got) != 0 {
+		t.Fatalf("expected unmatched file to stay unattributed, got %+v", got)
+	}
+	if got := attributeBrowserDownloads(files, expected[2:]); got["333"] != "download (1).mp3" {
+		t.Fatalf("expected last track to take unmatched file, got %+v", got)
+	}
+}
+
+func TestDetectBrowserDownloadsReturnsPartialResultOnIdleTimeout(t *testing.T) {
+	t.Setenv("UDL_FREEDL_BROWSER_IDLE_TIMEOUT", "")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "already-there.mp3"), []byte("old"), 0o644); err != nil {
+		t.Fatalf("write existing file: %v", err)
+	}
+	before, err := snapshotMediaFiles(dir)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "Pichi - Sunrise.wav"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write download: %v", err)
+	}
+	expected := []browserDownloadExpectation{
+		{Key: "111", Metadata: soundCloudFreeDownloadMetadata{Title: "Night Drive", Artist: "Pichi"}},
+		{Key: "333", Metadata: soundCloudFreeDownloadMetadata{Title: "Sunrise", Artist: "Pichi"}},
+	}
+	wait := browserDownloadWaitSettings{MaxWait: time.Minute, IdleTimeout: 50 * time.Millisecond, PollInterval: 5 * time.Millisecond, StableSamples: 2}
+	observed := map[string]int{}
+	found, err := detectBrowserDownloads(context.Background(), dir, before, wait, expected, func(key string, status browserDownloadWaitStatus) {
+		observed[key]++
+	})
+	if !errors.Is(err, errBrowserDownloadIdleTimeout) {
+		t.Fatalf("expected idle timeout for the missing download, got %v", err)
+	}
+	if found["333"] != filepath.Join(dir, "Pichi - Sunrise.wav") || len(found) != 1 {
+		t.Fatalf("unexpected downloads: %+v", found)
+	}
+	if observed["111"] == 0 {
+		t.Fatalf("expected wait reports for the unresolved track, got %+v", observed)
+	}
+}
+
+func TestSoundCloudArtworkVariantURL(t *testing.T) {
+	raw := "https://i1.sndcdn.com/artworks-abc-t500x500.jpg"
+	if got := soundCloudArtworkVariantURL(raw, "original"); got != "https://i1.sndcdn.com/artworks-abc-original.jpg" {
+		t.Fatalf("unexpected original variant url: %q", got)
+	}
+	if got := soundCloudArtworkVariantURL("https://example.com/cover.jpg", "original"); got != "https://example.com/cover.jpg" {
+		t.Fatalf("expected non-soundcloud url unchanged, got %q", got)
+	}
+}
+
 func TestInferArtworkFileExtensionFromContentType(t *testing.T) {
 	got := inferArtworkFileExtension("image/png", "")
 	if got != ".png" {
@@ -278,3 +531,24 @@ func TestAppendSoundCloudFreeDLStuckRecordWritesJSONL(t *testing.T) {
 		t.Fatalf("unexpected decoded record: %+v", decoded)
 	}
 }
+
+func TestMatchUntrackedFreeDownloadsSkipsAmbiguousFiles(t *testing.T) {
+	untracked := map[string]mediaFileSnapshot{
+		"Artist - Intro.mp3":          {},
+		"Artist - Intro (Remix).mp3":  {},
+		"Artist - Sunrise.wav":        {},
+		"Artist - Dusk and Dawn.aiff": {},
+	}
+	planned := []soundCloudRemoteTrack{
+		{ID: "1", Title: "Intro"},
+		{ID: "2", Title: "Sunrise"},
+		{ID: "3", Title: "Dusk"},
+		{ID: "4", Title: "Dawn"},
+	}
+
+	got := matchUntrackedFreeDownloads(untracked, planned)
+	want := []soundCloudFreeDLAdoption{{Track: planned[1], RelPath: "Artist - Sunrise.wav"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected adoptions\n got %+v\nwant %+v", got, want)
+	}
+}
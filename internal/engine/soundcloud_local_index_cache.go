@@ -12,7 +12,7 @@ import (
 	"github.com/jaa/update-downloads/internal/config"
 )
 
-const soundCloudLocalIndexCacheSchema = 1
+const soundCloudLocalIndexCacheSchema = 2
 
 type soundCloudLocalIndexCacheRecord struct {
 	Schema          int            `json:"schema"`
@@ -20,6 +20,7 @@ type soundCloudLocalIndexCacheRecord struct {
 	TargetDir       string         `json:"target_dir"`
 	TargetSignature string         `json:"target_signature"`
 	Index           map[string]int `json:"index"`
+	Files           []string       `json:"files"`
 	IntegrityHash   string         `json:"integrity_hash"`
 }
 
@@ -29,40 +30,41 @@ type soundCloudLocalIndexCachePayload struct {
 	TargetDir       string         `json:"target_dir"`
 	TargetSignature string         `json:"target_signature"`
 	Index           map[string]int `json:"index"`
+	Files           []string       `json:"files"`
 }
 
-func loadLocalIndexCache(stateDir string, sourceID string, targetDir string, signature string) (map[string]int, bool) {
+func loadLocalIndexCache(stateDir string, sourceID string, targetDir string, signature string) (map[string]int, localFileSet, bool) {
 	cachePath, err := localIndexCachePath(stateDir, sourceID)
 	if err != nil {
-		return nil, false
+		return nil, nil, false
 	}
 
 	raw, err := os.ReadFile(cachePath)
 	if err != nil {
-		return nil, false
+		return nil, nil, false
 	}
 
 	record := soundCloudLocalIndexCacheRecord{}
 	if err := json.Unmarshal(raw, &record); err != nil {
-		return nil, false
+		return nil, nil, false
 	}
 
 	if record.Schema != soundCloudLocalIndexCacheSchema {
-		return nil, false
+		return nil, nil, false
 	}
 
 	normalizedTargetDir := filepath.Clean(strings.TrimSpace(targetDir))
 	if filepath.Clean(strings.TrimSpace(record.TargetDir)) != normalizedTargetDir {
-		return nil, false
+		return nil, nil, false
 	}
 	if strings.TrimSpace(record.SourceID) != strings.TrimSpace(sourceID) {
-		return nil, false
+		return nil, nil, false
 	}
 	if strings.TrimSpace(record.TargetSignature) == "" || strings.TrimSpace(record.TargetSignature) != strings.TrimSpace(signature) {
-		return nil, false
+		return nil, nil, false
 	}
 	if len(record.Index) == 0 {
-		return nil, false
+		return nil, nil, false
 	}
 
 	expectedHash, err := localIndexCacheHash(soundCloudLocalIndexCachePayload{
@@ -71,22 +73,27 @@ func loadLocalIndexCache(stateDir string, sourceID string, targetDir string, sig
 		TargetDir:       record.TargetDir,
 		TargetSignature: record.TargetSignature,
 		Index:           record.Index,
+		Files:           record.Files,
 	})
 	if err != nil {
-		return nil, false
+		return nil, nil, false
 	}
 	if expectedHash != strings.TrimSpace(record.IntegrityHash) {
-		return nil, false
+		return nil, nil, false
 	}
 
 	out := make(map[string]int, len(record.Index))
 	for key, count := range record.Index {
 		out[key] = count
 	}
-	return out, true
+	files := make(localFileSet, len(record.Files))
+	for _, file := range record.Files {
+		files[file] = struct{}{}
+	}
+	return out, files, true
 }
 
-func storeLocalIndexCache(stateDir string, sourceID string, targetDir string, signature string, index map[string]int) {
+func storeLocalIndexCache(stateDir string, sourceID string, targetDir string, signature string, index map[string]int, files localFileSet) {
 	if len(index) == 0 {
 		return
 	}
@@ -110,6 +117,7 @@ func storeLocalIndexCache(stateDir string, sourceID string, targetDir string, si
 	for key, count := range index {
 		payload.Index[key] = count
 	}
+	payload.Files = files.sorted()
 
 	hash, err := localIndexCacheHash(payload)
 	if err != nil {
@@ -121,6 +129,7 @@ func storeLocalIndexCache(stateDir string, sourceID string, targetDir string, si
 		TargetDir:       payload.TargetDir,
 		TargetSignature: payload.TargetSignature,
 		Index:           payload.Index,
+		Files:           payload.Files,
 		IntegrityHash:   hash,
 	}
 	encoded, err := json.MarshalIndent(record, "", "  ")
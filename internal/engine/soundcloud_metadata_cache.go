@@ -0,0 +1,326 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	soundCloudMetadataCacheSchema = 1
+	// soundCloudMetadataCacheTTL bounds how long a cached lookup is trusted.
+	// Artists add and remove free-download links, so entries expire daily.
+	soundCloudMetadataCacheTTL = 24 * time.Hour
+)
+
+// soundCloudMetadataCacheEntry is what sync knows about one SoundCloud track
+// from API enumeration or its track page.
+type soundCloudMetadataCacheEntry struct {
+	Title        string `json:"title,omitempty"`
+	Artist       string `json:"artist,omitempty"`
+	Genre        string `json:"genre,omitempty"`
+	ArtworkURL   string `json:"artwork_url,omitempty"`
+	PurchaseURL  string `json:"purchase_url,omitempty"`
+	PermalinkURL string `json:"permalink_url,omitempty"`
+	// PageChecked marks entries read from the track page. Only those make an
+	// empty purchase_url authoritative: the page also has a Buy-link fallback
+	// the API does not expose.
+	PageChecked bool      `json:"page_checked,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
+
+type soundCloudMetadataCacheFile struct {
+	Schema   int                                     `json:"schema"`
+	SourceID string                                  `json:"source_id"`
+	Tracks   map[string]soundCloudMetadataCacheEntry `json:"tracks"`
+}
+
+// soundCloudMetadataCache holds <state_dir>/<source-id>.sc-metadata.json for
+// every source a sync touches, so free-download gate checks, free-DL tagging,
+// and the next run's preflight reuse what enumeration already fetched instead
+// of requesting each track page again. Files load on first use and are
+// written back by save.
+type soundCloudMetadataCache struct {
+	mu       sync.Mutex
+	stateDir string
+	now      func() time.Time
+	sources  map[string]*soundCloudMetadataCacheFile
+	dirty    map[string]bool
+}
+
+type soundCloudMetadataCacheKey struct{}
+
+func newSoundCloudMetadataCache(stateDir string, now func() time.Time) *soundCloudMetadataCache {
+	if now == nil {
+		now = time.Now
+	}
+	return &soundCloudMetadataCache{
+		stateDir: stateDir,
+		now:      now,
+		sources:  map[string]*soundCloudMetadataCacheFile{},
+		dirty:    map[string]bool{},
+	}
+}
+
+func withSoundCloudMetadataCache(ctx context.Context, cache *soundCloudMetadataCache) context.Context {
+	return context.WithValue(ctx, soundCloudMetadataCacheKey{}, cache)
+}
+
+// soundCloudMetadataCacheFrom returns the cache attached to ctx, or nil. A nil
+// cache misses on every lookup and ignores stores.
+func soundCloudMetadataCacheFrom(ctx context.Context) *soundCloudMetadataCache {
+	cache, _ := ctx.Value(soundCloudMetadataCacheKey{}).(*soundCloudMetadataCache)
+	return cache
+}
+
+// lookupSoundCloudFreeDownloadMetadata answers a free-download metadata lookup
+// from the source's cache when it holds a fresh answer, and otherwise fetches
+// the track page and caches the result. Lookup failures are not cached.
+func lookupSoundCloudFreeDownloadMetadata(ctx context.Context, sourceID string, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+	cache := soundCloudMetadataCacheFrom(ctx)
+	if entry, ok := cache.lookup(sourceID, track.ID); ok {
+		metadata := entry.metadata(track)
+		if strings.TrimSpace(metadata.PurchaseURL) == "" {
+			return metadata, errSoundCloudNoFreeDownloadLink
+		}
+		return metadata, nil
+	}
+	metadata, err := fetchSoundCloudFreeDownloadMetadataFn(ctx, track)
+	if err == nil || errors.Is(err, errSoundCloudNoFreeDownloadLink) {
+		cache.storePage(sourceID, track.ID, metadata)
+	}
+	return metadata, err
+}
+
+func (c *soundCloudMetadataCache) lookup(sourceID string, trackID string) (soundCloudMetadataCacheEntry, bool) {
+	if c == nil || strings.TrimSpace(trackID) == "" {
+		return soundCloudMetadataCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.load(sourceID).Tracks[strings.TrimSpace(trackID)]
+	if !ok || c.now().Sub(entry.FetchedAt) > soundCloudMetadataCacheTTL {
+		return soundCloudMetadataCacheEntry{}, false
+	}
+	if strings.TrimSpace(entry.PurchaseURL) == "" && !entry.PageChecked {
+		return soundCloudMetadataCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *soundCloudMetadataCache) storePage(sourceID string, trackID string, metadata soundCloudFreeDownloadMetadata) {
+	trackID = strings.TrimSpace(trackID)
+	if c == nil || trackID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file := c.load(sourceID)
+	file.Tracks[trackID] = soundCloudMetadataCacheEntry{
+		Title:        strings.TrimSpace(metadata.Title),
+		Artist:       strings.TrimSpace(metadata.Artist),
+		Genre:        strings.TrimSpace(metadata.Genre),
+		ArtworkURL:   strings.TrimSpace(metadata.ArtworkURL),
+		PurchaseURL:  strings.TrimSpace(metadata.PurchaseURL),
+		PermalinkURL: strings.TrimSpace(metadata.SoundCloudURL),
+		PageChecked:  true,
+		FetchedAt:    c.now(),
+	}
+	c.dirty[strings.TrimSpace(sourceID)] = true
+}
+
+// storeAPITracks records fully hydrated API tracks. A fresh track-page entry
+// is kept, picking up a purchase link the API now reports.
+func (c *soundCloudMetadataCache) storeAPITracks(sourceID string, tracks []soundCloudAPITrack) {
+	if c == nil || len(tracks) == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	file := c.load(sourceID)
+	now := c.now()
+	for _, track := range tracks {
+		permalinkURL := strings.TrimSpace(track.PermalinkURL)
+		if track.ID == 0 || permalinkURL == "" {
+			continue
+		}
+		trackID := strconv.FormatInt(track.ID, 10)
+		purchaseURL := resolveRelativeURL(permalinkURL, track.PurchaseURL)
+		if existing, ok := file.Tracks[trackID]; ok && existing.PageChecked && now.Sub(existing.FetchedAt) <= soundCloudMetadataCacheTTL {
+			if purchaseURL != "" && purchaseURL != existing.PurchaseURL {
+				existing.PurchaseURL = purchaseURL
+				file.Tracks[trackID] = existing
+				c.dirty[strings.TrimSpace(sourceID)] = true
+			}
+			continue
+		}
+		artworkURL := strings.TrimSpace(track.ArtworkURL)
+		if artworkURL == "" {
+			artworkURL = strings.TrimSpace(track.User.AvatarURL)
+		}
+		file.Tracks[trackID] = soundCloudMetadataCacheEntry{
+			Title:        strings.TrimSpace(track.Title),
+			Artist:       strings.TrimSpace(track.User.Username),
+			Genre:        strings.TrimSpace(track.Genre),
+			ArtworkURL:   resolveRelativeURL(permalinkURL, resolveSoundCloudArtworkURL(artworkURL)),
+			PurchaseURL:  purchaseURL,
+			PermalinkURL: permalinkURL,
+			FetchedAt:    now,
+		}
+		c.dirty[strings.TrimSpace(sourceID)] = true
+	}
+}
+
+// save writes back every source file that changed. Write failures only cost
+// a refetch next run.
+func (c *soundCloudMetadataCache) save() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for sourceID, dirty := range c.dirty {
+		if !dirty {
+			continue
+		}
+		file := c.sources[sourceID]
+		if file == nil {
+			continue
+		}
+		now := c.now()
+		for trackID, entry := range file.Tracks {
+			if now.Sub(entry.FetchedAt) > soundCloudMetadataCacheTTL {
+				delete(file.Tracks, trackID)
+			}
+		}
+		if err := writeSoundCloudMetadataCacheFile(c.stateDir, sourceID, file); err == nil {
+			c.dirty[sourceID] = false
+		}
+	}
+}
+
+// load returns the in-memory file for sourceID, reading it from disk on first
+// use. Callers hold c.mu.
+func (c *soundCloudMetadataCache) load(sourceID string) *soundCloudMetadataCacheFile {
+	sourceID = strings.TrimSpace(sourceID)
+	if file, ok := c.sources[sourceID]; ok {
+		return file
+	}
+	file, err := readSoundCloudMetadataCacheFile(c.stateDir, sourceID)
+	if err != nil {
+		file = &soundCloudMetadataCacheFile{}
+	}
+	file.Schema = soundCloudMetadataCacheSchema
+	file.SourceID = sourceID
+	if file.Tracks == nil {
+		file.Tracks = map[string]soundCloudMetadataCacheEntry{}
+	}
+	c.sources[sourceID] = file
+	return file
+}
+
+// metadata fills the free-download metadata for track from entry, the same
+// fields fetchSoundCloudFreeDownloadMetadata scrapes from the track page.
+func (e soundCloudMetadataCacheEntry) metadata(track soundCloudRemoteTrack) soundCloudFreeDownloadMetadata {
+	metadata := soundCloudFreeDownloadMetadata{
+		ID:            strings.TrimSpace(track.ID),
+		Title:         strings.TrimSpace(track.Title),
+		SoundCloudURL: strings.TrimSpace(track.URL),
+		Artist:        e.Artist,
+		Genre:         e.Genre,
+		ArtworkURL:    e.ArtworkURL,
+		PurchaseURL:   e.PurchaseURL,
+	}
+	if e.Title != "" {
+		metadata.Title = e.Title
+	}
+	if e.PermalinkURL != "" {
+		metadata.SoundCloudURL = e.PermalinkURL
+	}
+	return metadata
+}
+
+// loadSoundCloudMetadataCacheTitles returns the cached titles of a source's
+// tracks regardless of age, for offline readers such as `udl query`.
+func loadSoundCloudMetadataCacheTitles(stateDir string, sourceID string) map[string]string {
+	file, err := readSoundCloudMetadataCacheFile(stateDir, sourceID)
+	if err != nil {
+		return nil
+	}
+	titles := make(map[string]string, len(file.Tracks))
+	for trackID, entry := range file.Tracks {
+		if entry.Title != "" {
+			titles[trackID] = entry.Title
+		}
+	}
+	return titles
+}
+
+func readSoundCloudMetadataCacheFile(stateDir string, sourceID string) (*soundCloudMetadataCacheFile, error) {
+	path, err := soundCloudMetadataCachePath(stateDir, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &soundCloudMetadataCacheFile{}
+	if err := json.Unmarshal(raw, file); err != nil {
+		return nil, err
+	}
+	if file.Schema != soundCloudMetadataCacheSchema || strings.TrimSpace(file.SourceID) != strings.TrimSpace(sourceID) {
+		return nil, fmt.Errorf("soundcloud metadata cache %s does not belong to source %q", path, sourceID)
+	}
+	return file, nil
+}
+
+func writeSoundCloudMetadataCacheFile(stateDir string, sourceID string, file *soundCloudMetadataCacheFile) error {
+	path, err := soundCloudMetadataCachePath(stateDir, sourceID)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(path), ".udl-sc-metadata-*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	if _, err := tempFile.Write(encoded); err != nil {
+		_ = tempFile.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+func soundCloudMetadataCachePath(stateDir string, sourceID string) (string, error) {
+	cacheName := "soundcloud.sc-metadata.json"
+	if trimmedID := strings.TrimSpace(sourceID); trimmedID != "" {
+		cacheName = fmt.Sprintf("%s.sc-metadata.json", trimmedID)
+	}
+	return config.ResolveStateFile(stateDir, cacheName)
+}
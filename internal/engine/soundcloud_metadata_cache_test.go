@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLookupSoundCloudFreeDownloadMetadataReusesCachedPageLookups(t *testing.T) {
+	origFetch := fetchSoundCloudFreeDownloadMetadataFn
+	t.Cleanup(func() { fetchSoundCloudFreeDownloadMetadataFn = origFetch })
+
+	fetches := map[string]int{}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		fetches[track.ID]++
+		metadata := soundCloudFreeDownloadMetadata{ID: track.ID, Title: track.Title, Artist: "Artist", SoundCloudURL: track.URL}
+		if track.ID == "222" {
+			return metadata, errSoundCloudNoFreeDownloadLink
+		}
+		metadata.PurchaseURL = "https://hypeddit.com/a/" + track.ID
+		return metadata, nil
+	}
+
+	stateDir := t.TempDir()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	gated := soundCloudRemoteTrack{ID: "111", Title: "One", URL: "https://soundcloud.com/a/one"}
+	ungated := soundCloudRemoteTrack{ID: "222", Title: "Two", URL: "https://soundcloud.com/a/two"}
+
+	cache := newSoundCloudMetadataCache(stateDir, clock)
+	ctx := withSoundCloudMetadataCache(context.Background(), cache)
+	for i := 0; i < 2; i++ {
+		metadata, err := lookupSoundCloudFreeDownloadMetadata(ctx, "sc", gated)
+		if err != nil || metadata.PurchaseURL != "https://hypeddit.com/a/111" || metadata.Artist != "Artist" {
+			t.Fatalf("gated lookup %d = %+v, %v", i, metadata, err)
+		}
+		if _, err := lookupSoundCloudFreeDownloadMetadata(ctx, "sc", ungated); !errors.Is(err, errSoundCloudNoFreeDownloadLink) {
+			t.Fatalf("ungated lookup %d error = %v, want no-free-download-link", i, err)
+		}
+	}
+	if fetches["111"] != 1 || fetches["222"] != 1 {
+		t.Fatalf("expected one page fetch per track, got %v", fetches)
+	}
+	cache.save()
+
+	// The next run reads the saved file.
+	reloaded := withSoundCloudMetadataCache(context.Background(), newSoundCloudMetadataCache(stateDir, clock))
+	if _, err := lookupSoundCloudFreeDownloadMetadata(reloaded, "sc", gated); err != nil {
+		t.Fatalf("reloaded lookup: %v", err)
+	}
+	if fetches["111"] != 1 {
+		t.Fatalf("expected reloaded cache to answer the lookup, got %d fetches", fetches["111"])
+	}
+	if _, err := lookupSoundCloudFreeDownloadMetadata(reloaded, "other", gated); err != nil {
+		t.Fatalf("other source lookup: %v", err)
+	}
+	if fetches["111"] != 2 {
+		t.Fatalf("expected caches to be per source, got %d fetches", fetches["111"])
+	}
+
+	now = now.Add(soundCloudMetadataCacheTTL + time.Minute)
+	if _, err := lookupSoundCloudFreeDownloadMetadata(reloaded, "sc", gated); err != nil {
+		t.Fatalf("expired lookup: %v", err)
+	}
+	if fetches["111"] != 3 {
+		t.Fatalf("expected an expired entry to be fetched again, got %d fetches", fetches["111"])
+	}
+}
+
+func TestSoundCloudMetadataCacheAnswersFromAPIEnumerationWithPurchaseLink(t *testing.T) {
+	origFetch := fetchSoundCloudFreeDownloadMetadataFn
+	t.Cleanup(func() { fetchSoundCloudFreeDownloadMetadataFn = origFetch })
+
+	fetches := 0
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		fetches++
+		return soundCloudFreeDownloadMetadata{ID: track.ID}, errSoundCloudNoFreeDownloadLink
+	}
+
+	withLink := soundCloudAPITrack{ID: 111, Title: "One", PermalinkURL: "https://soundcloud.com/a/one", Genre: "House", PurchaseURL: "https://hypeddit.com/a/one"}
+	withLink.User.Username = "Artist"
+	withLink.User.AvatarURL = "https://i1.sndcdn.com/avatars-1-large.jpg"
+	withoutLink := soundCloudAPITrack{ID: 222, Title: "Two", PermalinkURL: "https://soundcloud.com/a/two"}
+
+	cache := newSoundCloudMetadataCache(t.TempDir(), nil)
+	cache.storeAPITracks("sc", []soundCloudAPITrack{withLink, withoutLink})
+	ctx := withSoundCloudMetadataCache(context.Background(), cache)
+
+	metadata, err := lookupSoundCloudFreeDownloadMetadata(ctx, "sc", soundCloudRemoteTrack{ID: "111", URL: "https://soundcloud.com/a/one"})
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	if metadata.Title != "One" || metadata.Artist != "Artist" || metadata.Genre != "House" || metadata.PurchaseURL != "https://hypeddit.com/a/one" {
+		t.Fatalf("unexpected metadata from api entry: %+v", metadata)
+	}
+	if metadata.ArtworkURL != "https://i1.sndcdn.com/avatars-1-t500x500.jpg" {
+		t.Fatalf("expected avatar artwork fallback, got %q", metadata.ArtworkURL)
+	}
+	if fetches != 0 {
+		t.Fatalf("expected no page fetch for an api entry with a purchase link, got %d", fetches)
+	}
+
+	// The API has no Buy-link fallback, so a missing purchase_url still
+	// needs the track page.
+	if _, err := lookupSoundCloudFreeDownloadMetadata(ctx, "sc", soundCloudRemoteTrack{ID: "222", URL: "https://soundcloud.com/a/two"}); !errors.Is(err, errSoundCloudNoFreeDownloadLink) {
+		t.Fatalf("lookup without api purchase link error = %v", err)
+	}
+	if fetches != 1 {
+		t.Fatalf("expected a page fetch for an api entry without a purchase link, got %d", fetches)
+	}
+}
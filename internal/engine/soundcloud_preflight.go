@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -20,6 +21,8 @@ type soundCloudRemoteTrack struct {
 	ID    string
 	Title string
 	URL   string
+	// DurationMS is the remote track length, 0 when the listing has none.
+	DurationMS int64
 }
 
 type soundCloudSyncEntry struct {
@@ -37,9 +40,13 @@ type idSet map[string]struct{}
 
 var enumerateSoundCloudTracksFn = enumerateSoundCloudTracks
 var enumerateSoundCloudTracksWithLimitFn = enumerateSoundCloudTracksWithLimit
+var enumerateSoundCloudTracksViaAPIFn = enumerateSoundCloudTracksViaAPI
 
 func effectiveSoundCloudListURL(source config.Source) string {
 	base := strings.TrimSpace(source.URL)
+	if _, reposts := config.SoundCloudRepostsProfile(base); reposts || config.IsSoundCloudStationURL(base) {
+		return base
+	}
 	mode := detectSoundCloudMode(source.Adapter.ExtraArgs)
 	switch mode {
 	case "-t":
@@ -57,6 +64,16 @@ func effectiveSoundCloudListURL(source config.Source) string {
 	}
 }
 
+// soundCloudListTarget returns the URL and scdl mode a source lists: a
+// reposts feed URL lists its profile in -r mode, anything else the source URL
+// in the mode its extra_args pick.
+func soundCloudListTarget(source config.Source) (string, string) {
+	if profile, ok := config.SoundCloudRepostsProfile(source.URL); ok {
+		return profile, "-r"
+	}
+	return strings.TrimSpace(source.URL), detectSoundCloudMode(source.Adapter.ExtraArgs)
+}
+
 func detectSoundCloudMode(args []string) string {
 	if hasFlagArg(args, "-a") {
 		return "-a"
@@ -104,7 +121,27 @@ func enumerateSoundCloudTracks(ctx context.Context, source config.Source) ([]sou
 	return enumerateSoundCloudTracksWithLimit(ctx, source, 0)
 }
 
+// enumerateSoundCloudTracksWithLimit uses the native SoundCloud API client
+// and falls back to yt-dlp, so planning does not depend on either alone.
 func enumerateSoundCloudTracksWithLimit(ctx context.Context, source config.Source, limit int) ([]soundCloudRemoteTrack, error) {
+	tracks, apiErr := enumerateSoundCloudTracksViaAPIFn(ctx, source, limit)
+	if apiErr == nil {
+		return tracks, nil
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	tracks, err := enumerateSoundCloudTracksViaYTDLP(ctx, source, limit)
+	if err != nil {
+		if errors.Is(apiErr, errSoundCloudAPIUnsupported) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w (soundcloud api: %v)", err, apiErr)
+	}
+	return tracks, nil
+}
+
+func enumerateSoundCloudTracksViaYTDLP(ctx context.Context, source config.Source, limit int) ([]soundCloudRemoteTrack, error) {
 	listURL := effectiveSoundCloudListURL(source)
 	args := []string{
 		"--flat-playlist",
@@ -114,6 +151,13 @@ func enumerateSoundCloudTracksWithLimit(ctx context.Context, source config.Sourc
 	if limit > 0 {
 		args = append(args, "--playlist-end", strconv.Itoa(limit))
 	}
+	cookiesPath, err := config.ResolveCookiesFile(source)
+	if err != nil {
+		return nil, err
+	}
+	if cookiesPath != "" {
+		args = append(args, "--cookies", cookiesPath)
+	}
 	args = append(args, listURL)
 	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
 	output, err := cmd.Output()
@@ -175,11 +219,16 @@ func parseSoundCloudSyncState(path string) (soundCloudSyncState, error) {
 	}
 
 	scanner := bufio.NewScanner(bytes.NewReader(payload))
+	lineNo := 0
 	for scanner.Scan() {
+		lineNo++
 		raw := strings.TrimSpace(scanner.Text())
 		if raw == "" {
 			continue
 		}
+		if strings.ContainsRune(raw, 0) {
+			return state, corruptStateFileError(path, lineNo, stateLineDropNUL)
+		}
 
 		entry := soundCloudSyncEntry{RawLine: raw}
 		parts := strings.SplitN(raw, " ", 3)
@@ -193,6 +242,9 @@ func parseSoundCloudSyncState(path string) (soundCloudSyncState, error) {
 		state.Entries = append(state.Entries, entry)
 	}
 	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return state, corruptStateFileError(path, lineNo+1, stateLineDropTooLong)
+		}
 		return state, err
 	}
 	return state, nil
@@ -299,6 +351,11 @@ func buildSoundCloudPreflight(
 		TargetDir:      targetDir,
 		Mode:           mode,
 	})
+	for _, track := range remoteTracks {
+		if _, ok := plan.PlannedID[track.ID]; ok {
+			plan.Preflight.notePlannedDuration(track.DurationMS)
+		}
+	}
 	return plan.Preflight, plan.ArchiveGapID, plan.KnownGapID, plan.PlannedID
 }
 
@@ -311,10 +368,10 @@ func stateEntryHasLocalFile(rawPath, targetDir string) bool {
 		fullPath = filepath.Join(targetDir, fullPath)
 	}
 	info, err := os.Stat(fullPath)
-	if err != nil || info.IsDir() {
-		return false
+	if err != nil {
+		return remoteTargetListed(fullPath)
 	}
-	return true
+	return !info.IsDir()
 }
 
 func writeFilteredSyncStateFile(
@@ -412,13 +469,35 @@ func writeFilteredArchiveFile(originalPath string, removeIDs map[string]struct{}
 }
 
 func scanLocalMediaTitleIndex(targetDir string) map[string]int {
+	index, _ := scanLocalMediaIndex(targetDir)
+	return index
+}
+
+// scanLocalMediaIndex walks targetDir once for both the title index and the
+// set of media files it holds.
+func scanLocalMediaIndex(targetDir string) (map[string]int, localFileSet) {
 	index := map[string]int{}
+	files := localFileSet{}
 	root := strings.TrimSpace(targetDir)
 	if root == "" {
-		return index
+		return index, files
 	}
 	if _, err := os.Stat(root); err != nil {
-		return index
+		return index, files
+	}
+	addName := func(name string) bool {
+		ext := strings.ToLower(filepath.Ext(name))
+		if !isMediaExt(ext) {
+			return false
+		}
+		stem := strings.TrimSpace(strings.TrimSuffix(name, ext))
+		if key := normalizeTrackKey(stem); key != "" {
+			index[key]++
+		}
+		return true
+	}
+	for _, file := range remoteTargetFiles(root) {
+		addName(filepath.Base(filepath.FromSlash(file)))
 	}
 	err := filepath.WalkDir(root, func(path string, d os.DirEntry, walkErr error) error {
 		if walkErr != nil {
@@ -427,23 +506,54 @@ func scanLocalMediaTitleIndex(targetDir string) map[string]int {
 		if d.IsDir() {
 			return nil
 		}
-		name := d.Name()
-		ext := strings.ToLower(filepath.Ext(name))
-		if !isMediaExt(ext) {
+		if !addName(d.Name()) {
 			return nil
 		}
-		stem := strings.TrimSpace(strings.TrimSuffix(name, ext))
-		key := normalizeTrackKey(stem)
-		if key == "" {
-			return nil
+		if rel, relErr := filepath.Rel(root, path); relErr == nil {
+			files[filepath.ToSlash(rel)] = struct{}{}
 		}
-		index[key]++
 		return nil
 	})
 	if err != nil {
-		return index
+		return index, files
 	}
-	return index
+	return index, files
+}
+
+// localFileSet is the media files under a target_dir, slash-separated and
+// relative to it, as recorded by the local index cache. A nil set checks
+// existence on disk instead.
+type localFileSet map[string]struct{}
+
+// has reports whether a state entry's file exists, answering from the set
+// when there is one so a slow network target_dir is not stat'ed per entry.
+// Paths outside targetDir are still checked on disk.
+func (set localFileSet) has(rawPath, targetDir string) bool {
+	fullPath := strings.TrimSpace(rawPath)
+	if set == nil || fullPath == "" {
+		return stateEntryHasLocalFile(rawPath, targetDir)
+	}
+	rel := fullPath
+	if filepath.IsAbs(fullPath) {
+		var err error
+		rel, err = filepath.Rel(targetDir, fullPath)
+		if err != nil || !filepath.IsLocal(rel) {
+			return stateEntryHasLocalFile(rawPath, targetDir)
+		}
+	}
+	if _, ok := set[filepath.ToSlash(filepath.Clean(rel))]; ok {
+		return true
+	}
+	return remoteTargetListed(filepath.Join(targetDir, rel))
+}
+
+func (set localFileSet) sorted() []string {
+	files := make([]string, 0, len(set))
+	for file := range set {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
 }
 
 func isMediaExt(ext string) bool {
@@ -20,8 +20,12 @@ func enumerateSoundCloudStage(ctx context.Context, input soundCloudEnumerateStag
 		tracks []soundCloudRemoteTrack
 		err    error
 	)
-	if input.Limit > 0 {
-		tracks, err = enumerateSoundCloudTracksWithLimitFn(ctx, input.Source, input.Limit)
+	limit := input.Limit
+	if depth := config.SoundCloudMaxDepth(input.Source); depth > 0 && (limit <= 0 || depth < limit) {
+		limit = depth
+	}
+	if limit > 0 {
+		tracks, err = enumerateSoundCloudTracksWithLimitFn(ctx, input.Source, limit)
 	} else {
 		tracks, err = enumerateSoundCloudTracksFn(ctx, input.Source)
 	}
@@ -81,7 +85,10 @@ type soundCloudLocalIndexStageInput struct {
 }
 
 type soundCloudLocalIndexStageResult struct {
-	Index    map[string]int
+	Index map[string]int
+	// Files is only set with UseCache, and then answers the plan stage's
+	// state-path existence checks.
+	Files    localFileSet
 	CacheHit bool
 	Scanned  bool
 }
@@ -98,17 +105,20 @@ func loadSoundCloudLocalIndexStage(input soundCloudLocalIndexStageInput) (soundC
 	}
 
 	if input.UseCache {
-		if cached, hit := loadLocalIndexCache(input.StateDir, input.SourceID, input.TargetDir, signature); hit {
+		if cached, files, hit := loadLocalIndexCache(input.StateDir, input.SourceID, input.TargetDir, signature); hit {
 			result.Index = cached
+			result.Files = files
 			result.CacheHit = true
 			return result, nil
 		}
 	}
 
-	result.Index = scanLocalMediaTitleIndex(input.TargetDir)
+	index, files := scanLocalMediaIndex(input.TargetDir)
+	result.Index = index
 	result.Scanned = true
 	if input.UseCache {
-		storeLocalIndexCache(input.StateDir, input.SourceID, input.TargetDir, signature, result.Index)
+		result.Files = files
+		storeLocalIndexCache(input.StateDir, input.SourceID, input.TargetDir, signature, result.Index, files)
 	}
 	return result, nil
 }
@@ -118,6 +128,7 @@ type soundCloudPlanStageInput struct {
 	State          soundCloudSyncState
 	ArchiveKnownID idSet
 	LocalIndex     map[string]int
+	LocalFiles     localFileSet
 	TargetDir      string
 	Mode           SoundCloudMode
 }
@@ -147,7 +158,7 @@ func planSoundCloudPreflightStage(input soundCloudPlanStageInput) soundCloudPlan
 		knownCount++
 		hasLocal := false
 		if knownFromState {
-			if stateEntryHasLocalFile(entry.FilePath, input.TargetDir) {
+			if input.LocalFiles.has(entry.FilePath, input.TargetDir) {
 				hasLocal = true
 			}
 			if !hasLocal && consumeLocalTitleMatch(availableLocalTitles, track.Title) {
@@ -174,6 +185,18 @@ func planSoundCloudPreflightStage(input soundCloudPlanStageInput) soundCloudPlan
 		for id := range knownGapIDs {
 			planned[id] = struct{}{}
 		}
+	case SoundCloudModeOnlyGaps:
+		for id := range knownGapIDs {
+			planned[id] = struct{}{}
+		}
+		// Unknown tracks above the first local one are new, not gaps.
+		if firstExisting > 0 {
+			for _, track := range input.RemoteTracks[firstExisting:] {
+				if _, gap := archiveGapIDs[track.ID]; gap {
+					planned[track.ID] = struct{}{}
+				}
+			}
+		}
 	default:
 		limit := len(input.RemoteTracks)
 		if firstExisting > 0 {
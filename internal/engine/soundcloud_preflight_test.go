@@ -1,8 +1,10 @@
 package engine
 
 import (
+	"context"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -412,6 +414,60 @@ func TestLoadSoundCloudLocalIndexStageRebuildsOnSignatureChange(t *testing.T) {
 	}
 }
 
+func TestSoundCloudPlanStageChecksStatePathsAgainstCachedFiles(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{filepath.Join(targetDir, "Sets"), stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"one.m4a", filepath.Join("Sets", "two.mp3")} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("a"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	input := soundCloudLocalIndexStageInput{SourceID: "nas", TargetDir: targetDir, StateDir: stateDir, NeedScan: true, UseCache: true}
+	if _, err := loadSoundCloudLocalIndexStage(input); err != nil {
+		t.Fatalf("initial stage run: %v", err)
+	}
+
+	// A file removed below the top level leaves target_dir's signature as
+	// it was, so the cached list keeps answering without touching the disk.
+	if err := os.Remove(filepath.Join(targetDir, "Sets", "two.mp3")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	cached, err := loadSoundCloudLocalIndexStage(input)
+	if err != nil {
+		t.Fatalf("cached stage run: %v", err)
+	}
+	if !cached.CacheHit || len(cached.Files) != 2 {
+		t.Fatalf("expected a cache hit with both files, got %+v", cached)
+	}
+
+	state := soundCloudSyncState{ByID: map[string]soundCloudSyncEntry{
+		"1": {ID: "1", FilePath: filepath.Join(targetDir, "one.m4a")},
+		"2": {ID: "2", FilePath: "Sets/two.mp3"},
+		"3": {ID: "3", FilePath: "gone.m4a"},
+	}}
+	plan := planSoundCloudPreflightStage(soundCloudPlanStageInput{
+		RemoteTracks:   []soundCloudRemoteTrack{{ID: "1", Title: "one"}, {ID: "2", Title: "two"}, {ID: "3", Title: "gone"}},
+		State:          state,
+		ArchiveKnownID: idSet{},
+		LocalIndex:     cached.Index,
+		LocalFiles:     cached.Files,
+		TargetDir:      targetDir,
+		Mode:           SoundCloudModeScanGaps,
+	})
+	if _, ok := plan.KnownGapID["2"]; ok {
+		t.Fatalf("expected the cached file list to answer for Sets/two.mp3, got gaps %v", plan.KnownGapID)
+	}
+	if _, ok := plan.KnownGapID["3"]; !ok || len(plan.KnownGapID) != 1 {
+		t.Fatalf("expected only the uncached file as a known gap, got %v", plan.KnownGapID)
+	}
+}
+
 func TestLoadSoundCloudLocalIndexStageSkipsScanWhenNotNeeded(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
@@ -443,3 +499,38 @@ func TestLoadSoundCloudLocalIndexStageSkipsScanWhenNotNeeded(t *testing.T) {
 		t.Fatalf("expected empty index when scan skipped, got %+v", result.Index)
 	}
 }
+
+func TestPrepareSoundCloudExecutionPlanSelectsStationTracksByID(t *testing.T) {
+	cfg, source := newSyncLimitTestSource(t, "soundcloud 222 Local.m4a\n")
+	source.URL = "https://soundcloud.com/stations/track/user/seed"
+	tracks, _ := enumerateSoundCloudTracksFn(context.Background(), source)
+	origLimited := enumerateSoundCloudTracksWithLimitFn
+	t.Cleanup(func() { enumerateSoundCloudTracksWithLimitFn = origLimited })
+	enumerateSoundCloudTracksWithLimitFn = func(ctx context.Context, source config.Source, limit int) ([]soundCloudRemoteTrack, error) {
+		if limit != config.DefaultSoundCloudStationDepth {
+			t.Errorf("expected the default station depth, got %d", limit)
+		}
+		return tracks, nil
+	}
+
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeAdapter{}}, noOpRunner{}, &captureEventEmitter{})
+	plan, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{})
+	if err != nil {
+		t.Fatalf("prepare plan: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanupTempStateFiles(plan.StateSwap) })
+
+	if plan.Preflight == nil || plan.Preflight.Mode != SoundCloudModeBreak || plan.Preflight.PlannedDownloadCount != 1 {
+		t.Fatalf("expected break-on-existing planning to stop at the local track, got %+v", plan.Preflight)
+	}
+	if !reflect.DeepEqual(plan.Source.SelectedTrackIDs, []string{"111"}) || plan.Source.SelectedPlaylistIDs != nil {
+		t.Fatalf("expected the station run held to track ids, got ids=%v positions=%v", plan.Source.SelectedTrackIDs, plan.Source.SelectedPlaylistIDs)
+	}
+	if !plan.Source.DisableSyncMode || plan.Source.Sync.BreakOnExisting == nil || *plan.Source.Sync.BreakOnExisting {
+		t.Fatalf("expected scdl sync mode and break-on-existing off for a station run, got %+v", plan.Source)
+	}
+
+	if _, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{NoPreflight: true}); err == nil {
+		t.Fatalf("expected a station source to require preflight")
+	}
+}
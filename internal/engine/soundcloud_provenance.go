@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// SoundCloud provenance tells apart how a track reached the state file.
+const (
+	soundCloudProvenanceSCDL   = "scdl"
+	soundCloudProvenanceFreeDL = "free-dl"
+	soundCloudProvenanceAdopt  = "adopted"
+	soundCloudProvenanceImport = "import"
+)
+
+// soundCloudProvenanceRecord is one line of
+// <state_dir>/<source>.provenance.jsonl. SoundCloud state files stay in the
+// "soundcloud <id> <path>" layout scdl --sync reads and rewrites, so the
+// download time, adapter, and provider of each track are kept beside them
+// instead. Tracks recorded before the log existed have no record.
+type soundCloudProvenanceRecord struct {
+	TrackID  string    `json:"track_id"`
+	AddedAt  time.Time `json:"added_at"`
+	Adapter  string    `json:"adapter"`
+	Provider string    `json:"provider,omitempty"`
+	Path     string    `json:"path,omitempty"`
+}
+
+func resolveSoundCloudProvenancePath(defaultStateDir string, sourceID string) (string, error) {
+	return resolveSoundCloudFreeDLLogPath(defaultStateDir, sourceID, ".provenance.jsonl")
+}
+
+// recordSoundCloudProvenance appends a provenance record for a track that
+// was just written to a SoundCloud source's state file.
+func (s *Syncer) recordSoundCloudProvenance(cfg config.Config, source config.Source, trackID string, provider string, localPath string) error {
+	return appendSoundCloudProvenance(cfg.Defaults.StateDir, source, soundCloudProvenanceRecord{
+		TrackID:  trackID,
+		AddedAt:  s.Now().UTC(),
+		Provider: provider,
+		Path:     localPath,
+	})
+}
+
+func appendSoundCloudProvenance(stateDir string, source config.Source, record soundCloudProvenanceRecord) error {
+	path, err := resolveSoundCloudProvenancePath(stateDir, source.ID)
+	if err != nil {
+		return err
+	}
+	record.TrackID = strings.TrimSpace(record.TrackID)
+	if record.Adapter == "" {
+		record.Adapter = source.Adapter.Kind
+	}
+	return appendSoundCloudFreeDLLogRecord(path, record)
+}
+
+// loadSoundCloudProvenance reads a source's provenance log keyed by track
+// ID; a later record for the same track replaces an earlier one. A missing
+// log is empty, and malformed lines are skipped.
+func loadSoundCloudProvenance(stateDir string, sourceID string) (map[string]soundCloudProvenanceRecord, error) {
+	records := map[string]soundCloudProvenanceRecord{}
+	path, err := resolveSoundCloudProvenancePath(stateDir, sourceID)
+	if err != nil {
+		return records, err
+	}
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return records, nil
+		}
+		return records, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(payload))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record soundCloudProvenanceRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil || record.TrackID == "" {
+			continue
+		}
+		records[record.TrackID] = record
+	}
+	return records, scanner.Err()
+}
+
+// recordSCDLProvenance records the tracks an scdl run added to the source's
+// state file, diffing it against the IDs known before the run.
+func (s *Syncer) recordSCDLProvenance(cfg config.Config, source config.Source, before map[string]soundCloudSyncEntry) error {
+	statePath, err := stateFileForVerify(cfg.Defaults, source)
+	if err != nil || statePath == "" {
+		return err
+	}
+	after, err := parseSoundCloudSyncState(statePath)
+	if err != nil {
+		return err
+	}
+	for _, entry := range after.Entries {
+		if entry.ID == "" {
+			continue
+		}
+		if _, known := before[entry.ID]; known {
+			continue
+		}
+		before[entry.ID] = entry
+		if err := s.recordSoundCloudProvenance(cfg, source, entry.ID, soundCloudProvenanceSCDL, entry.FilePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
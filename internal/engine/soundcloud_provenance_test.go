@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// stateAppendRunner stands in for scdl --sync: it records a downloaded
+// track by appending to the state file.
+type stateAppendRunner struct {
+	statePath string
+	line      string
+}
+
+func (r stateAppendRunner) Run(ctx context.Context, spec ExecSpec) ExecResult {
+	if err := appendLine(r.statePath, r.line); err != nil {
+		return ExecResult{ExitCode: 1, StderrTail: err.Error()}
+	}
+	return ExecResult{ExitCode: 0}
+}
+
+func TestSyncerSoundCloudSCDLRecordsProvenanceForNewStateEntries(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{targetDir, stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "sc-likes",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-likes.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl"},
+			},
+		},
+	}
+	statePath := filepath.Join(stateDir, "sc-likes.sync.scdl")
+	if err := os.WriteFile(statePath, []byte("soundcloud 111 Old.m4a\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	origEnumerate := enumerateSoundCloudTracksFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+	})
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{{ID: "222", Title: "New"}, {ID: "111", Title: "Old"}}, nil
+	}
+
+	now := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC)
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl": fakeAdapter{}},
+		stateAppendRunner{statePath: statePath, line: "soundcloud 222 New.m4a\n"},
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+	syncer.Now = func() time.Time { return now }
+
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	records, err := loadSoundCloudProvenance(stateDir, "sc-likes")
+	if err != nil {
+		t.Fatalf("load provenance: %v", err)
+	}
+	if _, ok := records["111"]; ok {
+		t.Fatalf("expected no record for the track known before the run, got %+v", records)
+	}
+	record, ok := records["222"]
+	if !ok {
+		t.Fatalf("expected a record for the new track, got %+v", records)
+	}
+	if record.Adapter != "scdl" || record.Provider != soundCloudProvenanceSCDL || record.Path != "New.m4a" || !record.AddedAt.Equal(now) {
+		t.Fatalf("unexpected provenance record %+v", record)
+	}
+
+	tracks, err := queryLoadSourceTracks(cfg.Defaults, cfg.Sources[0])
+	if err != nil {
+		t.Fatalf("load tracks: %v", err)
+	}
+	for _, track := range tracks {
+		if track.id == "222" && !track.addedAt.Equal(now) {
+			t.Fatalf("expected query added_at from provenance, got %+v", track)
+		}
+		if track.id == "111" && !track.addedAt.IsZero() {
+			t.Fatalf("expected no added_at for a pre-provenance track, got %+v", track)
+		}
+	}
+}
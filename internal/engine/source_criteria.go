@@ -0,0 +1,138 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/criteria"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+var preflightSkipReasonPattern = regexp.MustCompile(`\(([a-z0-9-]+)\)`)
+
+// sourceTrackCounters tallies one source's track outcomes from the events it
+// emits. Flows report tracks through structured track events, "[done]" and
+// "[skip]" preflight lines, or both, so each family is counted on its own and
+// the larger count wins.
+type sourceTrackCounters struct {
+	planned int
+
+	trackDone        int
+	trackSkipped     int
+	trackUnavailable int
+	trackFailed      int
+
+	lineDone        int
+	lineSkipped     int
+	lineUnavailable int
+
+	reportedUnavailable int
+}
+
+func (c *sourceTrackCounters) observe(event output.Event) {
+	if planned, ok := event.Details["planned_download_count"].(int); ok && planned > c.planned {
+		c.planned = planned
+	}
+	if unavailable, ok := event.Details["skipped_unavailable"].(int); ok && unavailable > c.reportedUnavailable {
+		c.reportedUnavailable = unavailable
+	}
+	switch event.Event {
+	case output.EventTrackDone:
+		c.trackDone++
+	case output.EventTrackSkip:
+		c.trackSkipped++
+		if reason, _ := event.Details["reason"].(string); isUnavailableSkipReason(reason) {
+			c.trackUnavailable++
+		}
+	case output.EventTrackFail:
+		c.trackFailed++
+	case output.EventSourcePreflight:
+		prefix := fmt.Sprintf("[%s] ", event.SourceID)
+		line := strings.TrimPrefix(event.Message, prefix)
+		switch {
+		case strings.HasPrefix(line, "[done] "):
+			c.lineDone++
+		case strings.HasPrefix(line, "[skip] "):
+			// Already-present lines describe the plan, not this run's work.
+			reason := lastPreflightSkipReason(line)
+			if reason == "" || strings.Contains(line, "already-present") {
+				return
+			}
+			c.lineSkipped++
+			if isUnavailableSkipReason(reason) {
+				c.lineUnavailable++
+			}
+		}
+	}
+}
+
+// vars returns the counters under the names success_when expressions use.
+func (c sourceTrackCounters) vars() map[string]int {
+	return map[string]int{
+		criteria.Planned:      c.planned,
+		criteria.Downloaded:   max(c.trackDone, c.lineDone),
+		criteria.Skipped:      max(c.trackSkipped, c.lineSkipped),
+		criteria.Unavailable:  max(c.trackUnavailable, c.lineUnavailable, c.reportedUnavailable),
+		criteria.FailedTracks: c.trackFailed,
+	}
+}
+
+func lastPreflightSkipReason(line string) string {
+	matches := preflightSkipReasonPattern.FindAllStringSubmatch(line, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return matches[len(matches)-1][1]
+}
+
+func isUnavailableSkipReason(reason string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(reason)), "unavailable")
+}
+
+// applySuccessCriteria turns a source that finished cleanly into a failure
+// when its sync.success_when expression does not hold for the run's track
+// counters. Criteria only ever tighten the outcome: a source the adapter or
+// engine already failed stays failed.
+func (s *Syncer) applySuccessCriteria(
+	cfg config.Config,
+	source config.Source,
+	counters sourceTrackCounters,
+	outcome sourceRunOutcome,
+	opts SyncOptions,
+) sourceRunOutcome {
+	raw := strings.TrimSpace(source.Sync.SuccessWhen)
+	if raw == "" || opts.DryRun || outcome.Succeeded == 0 || outcome.Interrupted {
+		return outcome
+	}
+	expr, err := criteria.Parse(raw)
+	if err != nil {
+		return outcome
+	}
+	vars := counters.vars()
+	if expr.Eval(vars) {
+		return outcome
+	}
+
+	outcome.Succeeded--
+	outcome.Failed++
+	details := map[string]any{"success_when": expr.String()}
+	parts := []string{}
+	for _, name := range criteria.Variables() {
+		details[name] = vars[name]
+		parts = append(parts, fmt.Sprintf("%s=%d", name, vars[name]))
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelError,
+		Event:     output.EventSourceFailed,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] success criteria not met: %s (%s)", source.ID, expr.String(), strings.Join(parts, " ")),
+		Details:   details,
+	})
+	if !cfg.Defaults.ContinueOnError {
+		outcome.Stop = true
+	}
+	return outcome
+}
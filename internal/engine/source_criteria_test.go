@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/criteria"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSourceTrackCountersPreferLargerEventFamily(t *testing.T) {
+	counters := sourceTrackCounters{}
+	for _, event := range []output.Event{
+		{Event: output.EventSourcePreflight, SourceID: "s", Message: "[s] [skip] 1 (Song (Remix)) (already-present)"},
+		{Event: output.EventSourcePreflight, SourceID: "s", Message: "[s] [skip] ... and 4 more already-present track(s)"},
+		{Event: output.EventSourcePreflight, SourceID: "s", Message: "[s] [done] 2 (Song)"},
+		{Event: output.EventSourcePreflight, SourceID: "s", Message: "[s] [skip] 3 (Other) (unavailable-on-deezer)"},
+		{Event: output.EventSourcePreflight, SourceID: "s", Message: "[s] [skip] 4 (Gate) (hypeddit-timeout) retry later"},
+		{Event: output.EventTrackDone, SourceID: "s"},
+		{Event: output.EventTrackFail, SourceID: "s", Details: map[string]any{"reason": "state-update"}},
+		{Event: output.EventSourceFinished, SourceID: "s", Details: map[string]any{"planned_download_count": 4, "skipped_unavailable": 1}},
+	} {
+		counters.observe(event)
+	}
+	vars := counters.vars()
+	want := map[string]int{
+		criteria.Planned:      4,
+		criteria.Downloaded:   1,
+		criteria.Skipped:      2,
+		criteria.Unavailable:  1,
+		criteria.FailedTracks: 1,
+	}
+	for name, value := range want {
+		if vars[name] != value {
+			t.Fatalf("expected %s=%d, got vars=%v", name, value, vars)
+		}
+	}
+}
+
+func TestSyncerSuccessWhenFailsSourceWithPartialFailures(t *testing.T) {
+	for _, tc := range []struct {
+		successWhen string
+		wantFailed  bool
+	}{
+		{successWhen: "", wantFailed: false},
+		{successWhen: "failed_tracks == 0 && unavailable <= 1", wantFailed: false},
+		{successWhen: "unavailable == 0", wantFailed: true},
+	} {
+		tmp := t.TempDir()
+		targetDir := filepath.Join(tmp, "target")
+		stateDir := filepath.Join(tmp, "state")
+		runtimeDir := filepath.Join(tmp, "runtime")
+		for _, dir := range []string{targetDir, stateDir, runtimeDir} {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatalf("mkdir %s: %v", dir, err)
+			}
+		}
+		cfg := config.Config{
+			Version: 1,
+			Defaults: config.Defaults{
+				StateDir:              stateDir,
+				ArchiveFile:           "archive.txt",
+				Threads:               1,
+				ContinueOnError:       true,
+				CommandTimeoutSeconds: 900,
+			},
+			Sources: []config.Source{
+				{
+					ID:               "artist",
+					Type:             config.SourceTypeSpotify,
+					Enabled:          true,
+					TargetDir:        targetDir,
+					URL:              "https://open.spotify.com/artist/artist0000001",
+					StateFile:        "artist.sync.spotify",
+					DeemixRuntimeDir: runtimeDir,
+					Sync:             config.SyncPolicy{SuccessWhen: tc.successWhen},
+					Adapter:          config.AdapterSpec{Kind: "deemix"},
+				},
+			},
+		}
+
+		origResolveCreds := resolveSpotifyCredentialsFn
+		origResolveARL := resolveDeemixARLFn
+		origEnumerate := enumerateSpotifyTracksFn
+		resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+			return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+		}
+		resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+		enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+			return []spotifyRemoteTrack{
+				{ID: "1abc234def", Title: "track-1", Artist: "artist", Album: "LP"},
+				{ID: "2abc234def", Title: "track-2", Artist: "artist", Album: "LP"},
+			}, nil
+		}
+
+		runner := &sequenceRunner{results: []ExecResult{
+			{ExitCode: 0},
+			{ExitCode: 0, StderrTail: "Track unavailable on Deezer"},
+		}}
+		var out bytes.Buffer
+		syncer := NewSyncer(
+			map[string]Adapter{"deemix": fakeDeemixPathAdapter{}},
+			runner,
+			output.NewHumanEmitter(&out, &out, false, true),
+		)
+		result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+		if err != nil {
+			t.Fatalf("%q: sync: %v", tc.successWhen, err)
+		}
+
+		if !tc.wantFailed {
+			if result.Succeeded != 1 || result.Failed != 0 {
+				t.Fatalf("%q: expected source to succeed, got %+v", tc.successWhen, result)
+			}
+			continue
+		}
+		if result.Succeeded != 0 || result.Failed != 1 {
+			t.Fatalf("%q: expected source to fail its criteria, got %+v", tc.successWhen, result)
+		}
+		if !strings.Contains(out.String(), "success criteria not met: unavailable == 0") || !strings.Contains(out.String(), "unavailable=1") {
+			t.Fatalf("expected criteria failure message, got:\n%s", out.String())
+		}
+		lastErrors, err := LoadSourceLastErrors(stateDir)
+		if err != nil {
+			t.Fatalf("load last errors: %v", err)
+		}
+		if lastErrors["artist"].Class != "criteria" {
+			t.Fatalf("expected criteria last error, got %+v", lastErrors)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const sourceLastErrorsFileName = "source-errors.json"
+
+// SourceLastError is the most recent failure of a source, kept in
+// <state_dir>/source-errors.json until the source next syncs successfully.
+// The log pointers lead to the run report, the sync failure log with the
+// adapter's stdout/stderr tails, and the --log-file the run wrote to, if any.
+type SourceLastError struct {
+	Message    string    `json:"message"`
+	Class      string    `json:"class"`
+	At         time.Time `json:"at"`
+	RunID      string    `json:"run_id,omitempty"`
+	RunReport  string    `json:"run_report,omitempty"`
+	FailureLog string    `json:"failure_log,omitempty"`
+	LogFile    string    `json:"log_file,omitempty"`
+}
+
+type sourceLastErrorsFile struct {
+	Sources map[string]SourceLastError `json:"sources"`
+}
+
+// LoadSourceLastErrors returns the persisted last failure per source id. A
+// missing file means no source has failed since it last succeeded.
+func LoadSourceLastErrors(stateDir string) (map[string]SourceLastError, error) {
+	path, err := sourceLastErrorsPath(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]SourceLastError{}, nil
+		}
+		return nil, err
+	}
+	var payload sourceLastErrorsFile
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if payload.Sources == nil {
+		payload.Sources = map[string]SourceLastError{}
+	}
+	return payload.Sources, nil
+}
+
+// updateSourceLastErrors records this run's failures and clears sources that
+// finished cleanly; sources the run did not touch keep their entry.
+func updateSourceLastErrors(stateDir string, failed map[string]SourceLastError, succeeded []string) error {
+	if len(failed) == 0 && len(succeeded) == 0 {
+		return nil
+	}
+	path, err := sourceLastErrorsPath(stateDir)
+	if err != nil {
+		return err
+	}
+	return withStateDirLock(stateDir, func() error {
+		existing, err := LoadSourceLastErrors(stateDir)
+		if err != nil {
+			existing = map[string]SourceLastError{}
+		}
+		changed := false
+		for _, sourceID := range succeeded {
+			if _, ok := existing[sourceID]; ok {
+				delete(existing, sourceID)
+				changed = true
+			}
+		}
+		for sourceID, lastErr := range failed {
+			existing[sourceID] = lastErr
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+
+		if len(existing) == 0 {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			return nil
+		}
+		payload, err := json.MarshalIndent(sourceLastErrorsFile{Sources: existing}, "", "  ")
+		if err != nil {
+			return err
+		}
+		return writeFileAtomically(path, ".udl-last-errors-*", append(payload, '\n'))
+	})
+}
+
+func sourceLastErrorsPath(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, sourceLastErrorsFileName), nil
+}
+
+// classifySourceFailure buckets a source_failed event so status output can
+// say what kind of fix is needed without reading the full message.
+func classifySourceFailure(event output.Event) string {
+	if interrupted, _ := event.Details["interrupted"].(bool); interrupted {
+		return "interrupted"
+	}
+	if timedOut, _ := event.Details["timed_out"].(bool); timedOut {
+		return "timeout"
+	}
+	message := strings.ToLower(event.Message)
+	switch {
+	case strings.HasSuffix(message, "interrupted"):
+		return "interrupted"
+	case strings.Contains(message, "success criteria not met"):
+		return "criteria"
+	case strings.Contains(message, "drift alarm"):
+		return "drift"
+	case strings.Contains(message, "rate limit"):
+		return "rate_limit"
+	case containsAny(message, "auth", "credential", "deemix arl", "deezer arl", "cookies", "client_id", "client id", "not logged in", "token"):
+		return "auth"
+	case containsAny(message, "not registered", "missing required env", "executable file not found"):
+		return "dependency"
+	case containsAny(message, "validation failed", "target_dir", "invalid state_file"):
+		return "config"
+	case strings.Contains(message, "state file"):
+		return "state"
+	case strings.Contains(message, "preflight failed"):
+		return "preflight"
+	}
+	if _, ok := event.Details["exit_code"]; ok {
+		return "command"
+	}
+	return "runtime"
+}
+
+func containsAny(value string, needles ...string) bool {
+	for _, needle := range needles {
+		if strings.Contains(value, needle) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestSyncPersistsAndClearsSourceLastError(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{stateDir, filepath.Join(tmp, "yt"), filepath.Join(tmp, "tidal")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "yt",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   true,
+				TargetDir: filepath.Join(tmp, "yt"),
+				URL:       "https://www.youtube.com/playlist?list=PL123",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+			},
+			{
+				ID:        "tidal",
+				Type:      config.SourceTypeTidal,
+				Enabled:   true,
+				TargetDir: filepath.Join(tmp, "tidal"),
+				URL:       "https://tidal.com/browse/playlist/abc",
+				Adapter:   config.AdapterSpec{Kind: "tidal-dl"},
+			},
+		},
+	}
+
+	syncer := NewSyncer(map[string]Adapter{"ytdlp": fakeAdapter{}}, noOpRunner{}, &captureEventEmitter{})
+	now := time.Date(2026, 4, 2, 10, 30, 0, 0, time.UTC)
+	syncer.Now = func() time.Time { return now }
+	logFile := filepath.Join(tmp, "udl.log")
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{NoPreflight: true, LogFile: logFile}); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	lastErrors, err := LoadSourceLastErrors(stateDir)
+	if err != nil {
+		t.Fatalf("load last errors: %v", err)
+	}
+	if _, ok := lastErrors["yt"]; ok {
+		t.Fatalf("expected no last error for succeeded source, got %+v", lastErrors["yt"])
+	}
+	tidal, ok := lastErrors["tidal"]
+	if !ok {
+		t.Fatalf("expected last error for tidal, got %+v", lastErrors)
+	}
+	if tidal.Class != "dependency" || !strings.Contains(tidal.Message, "not registered") {
+		t.Fatalf("unexpected tidal last error: %+v", tidal)
+	}
+	if tidal.RunID != "20260402T103000Z" || !tidal.At.Equal(now) {
+		t.Fatalf("unexpected tidal run pointer: %+v", tidal)
+	}
+	if tidal.RunReport != filepath.Join(stateDir, "runs", "20260402T103000Z", RunReportFileName) {
+		t.Fatalf("unexpected run report pointer: %q", tidal.RunReport)
+	}
+	if filepath.Base(tidal.FailureLog) != "sync-failures.jsonl" || tidal.LogFile != logFile {
+		t.Fatalf("unexpected log pointers: %+v", tidal)
+	}
+
+	retry := NewSyncer(map[string]Adapter{"ytdlp": fakeAdapter{}, "tidal-dl": fakeAdapter{}}, noOpRunner{}, &captureEventEmitter{})
+	retry.Now = func() time.Time { return now.Add(time.Hour) }
+	if _, err := retry.Sync(context.Background(), cfg, SyncOptions{NoPreflight: true, SourceIDs: []string{"tidal"}}); err != nil {
+		t.Fatalf("retry sync: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, sourceLastErrorsFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be removed once every source recovered, stat err=%v", sourceLastErrorsFileName, err)
+	}
+}
+
+func TestClassifySourceFailure(t *testing.T) {
+	cases := []struct {
+		event output.Event
+		want  string
+	}{
+		{output.Event{Message: "[yt] interrupted", Details: map[string]any{"interrupted": true}}, "interrupted"},
+		{output.Event{Message: "[yt] command timed out", Details: map[string]any{"timed_out": true}}, "timeout"},
+		{output.Event{Message: "[sc] soundcloud client_id rejected"}, "auth"},
+		{output.Event{Message: "[tidal] adapter \"tidal-dl\" not registered"}, "dependency"},
+		{output.Event{Message: "[sp] invalid state_file path"}, "config"},
+		{output.Event{Message: "[sc] preflight failed: HTTP 500"}, "preflight"},
+		{output.Event{Message: "[yt] command failed", Details: map[string]any{"exit_code": 1}}, "command"},
+		{output.Event{Message: "[yt] something else"}, "runtime"},
+	}
+	for _, tc := range cases {
+		if got := classifySourceFailure(tc.event); got != tc.want {
+			t.Fatalf("classifySourceFailure(%q) = %q, want %q", tc.event.Message, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const (
+	// sourceLockStaleAge is how long a source lock may go without a
+	// heartbeat before it is assumed to belong to a crashed process.
+	sourceLockStaleAge  = 2 * time.Minute
+	sourceLockHeartbeat = 30 * time.Second
+	sourceLockPoll      = 500 * time.Millisecond
+)
+
+// ErrSourceLocked reports that another udl process holds a source's lock.
+var ErrSourceLocked = errors.New("source is locked by another udl process")
+
+// SourceLockError names the source whose lock is held, and by which pid
+// when the lock file says.
+type SourceLockError struct {
+	SourceID string
+	Path     string
+	PID      int
+}
+
+func (e *SourceLockError) Error() string {
+	return fmt.Sprintf("source %s is being synced by %s (lock %s)", e.SourceID, e.holder(), e.Path)
+}
+
+func (e *SourceLockError) holder() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("udl pid %d", e.PID)
+	}
+	return "another udl process"
+}
+
+func (e *SourceLockError) Unwrap() error { return ErrSourceLocked }
+
+func sourceLockPath(stateDir string, sourceID string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, sourceID+".udl-lock"), nil
+}
+
+// lockSource takes <state_dir>/<source-id>.udl-lock, which every udl
+// command that rewrites a source's state file or archive holds while it
+// does: sync, import-watch, recover-state, verify --fix, upgrade-scan
+// --apply, remap --apply and config migrate-archives --apply. When the lock
+// is held, lockSource returns a *SourceLockError at once, or with wait polls
+// until it is free or ctx is done, calling onWait once before the first
+// poll.
+func lockSource(ctx context.Context, stateDir string, sourceID string, wait bool, onWait func(*SourceLockError)) (func(), error) {
+	path, err := sourceLockPath(stateDir, sourceID)
+	if err != nil {
+		return nil, err
+	}
+	waited := false
+	return acquireLockFile(ctx, path, func(pid int) error {
+		lockErr := &SourceLockError{SourceID: sourceID, Path: path, PID: pid}
+		if !wait {
+			return lockErr
+		}
+		if !waited && onWait != nil {
+			onWait(lockErr)
+		}
+		waited = true
+		return nil
+	})
+}
+
+// lockSources takes the locks of every listed source for a command that
+// rewrites several sources' files in one go. It does not wait: when one
+// lock is held it releases the ones it took and returns the
+// *SourceLockError.
+func lockSources(ctx context.Context, stateDir string, sourceIDs []string) (func(), error) {
+	ids := append([]string{}, sourceIDs...)
+	sort.Strings(ids)
+	unlocks := make([]func(), 0, len(ids))
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for i, sourceID := range ids {
+		if i > 0 && sourceID == ids[i-1] {
+			continue
+		}
+		unlock, err := lockSource(ctx, stateDir, sourceID, false, nil)
+		if err != nil {
+			unlockAll()
+			return nil, err
+		}
+		unlocks = append(unlocks, unlock)
+	}
+	return unlockAll, nil
+}
+
+// acquireLockFile creates path exclusively, writes the pid into it and
+// keeps its mtime fresh with a heartbeat until the returned unlock runs. A
+// lock file untouched for sourceLockStaleAge belongs to a crashed process
+// and is taken over. While the lock is held, held is called with the
+// holder's pid: a non-nil error gives up with it, nil polls again until ctx
+// is done.
+func acquireLockFile(ctx context.Context, path string, held func(pid int) error) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	for {
+		file, openErr := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if openErr == nil {
+			_, writeErr := file.WriteString(strconv.Itoa(os.Getpid()) + "\n")
+			closeErr := file.Close()
+			if writeErr != nil || closeErr != nil {
+				_ = os.Remove(path)
+				return nil, fmt.Errorf("write lock %s: %w", path, errors.Join(writeErr, closeErr))
+			}
+			return startLockHeartbeat(path), nil
+		}
+		if !os.IsExist(openErr) {
+			return nil, openErr
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) >= sourceLockStaleAge {
+			_ = os.Remove(path)
+			continue
+		}
+		if err := held(readSourceLockPID(path)); err != nil {
+			return nil, err
+		}
+		timer := time.NewTimer(sourceLockPoll)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("wait for lock %s: %w", path, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func readSourceLockPID(path string) int {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+func startLockHeartbeat(path string) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(sourceLockHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case tick := <-ticker.C:
+				_ = os.Chtimes(path, tick, tick)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		_ = os.Remove(path)
+	}
+}
+
+// lockSourceForSync takes a source's lock for one sync run. Dry runs write
+// neither state nor archive and take no lock.
+func (s *Syncer) lockSourceForSync(ctx context.Context, cfg config.Config, source config.Source, opts SyncOptions) (func(), error) {
+	if opts.DryRun {
+		return func() {}, nil
+	}
+	return lockSource(ctx, cfg.Defaults.StateDir, source.ID, opts.WaitForLock, func(held *SourceLockError) {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] waiting for %s to finish with this source (lock %s)", source.ID, held.holder(), held.Path),
+			Details: map[string]any{
+				"lock_path": held.Path,
+				"lock_pid":  held.PID,
+			},
+		})
+	})
+}
@@ -0,0 +1,182 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestLockSourceFailsFastWhileHeldAndTakesOverStaleLock(t *testing.T) {
+	stateDir := t.TempDir()
+	unlock, err := lockSource(context.Background(), stateDir, "sc-likes", false, nil)
+	if err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+
+	_, err = lockSource(context.Background(), stateDir, "sc-likes", false, nil)
+	var held *SourceLockError
+	if !errors.As(err, &held) || !errors.Is(err, ErrSourceLocked) {
+		t.Fatalf("expected SourceLockError, got %v", err)
+	}
+	if held.PID != os.Getpid() {
+		t.Fatalf("expected holder pid %d, got %d", os.Getpid(), held.PID)
+	}
+	if other, err := lockSource(context.Background(), stateDir, "sc-other", false, nil); err != nil {
+		t.Fatalf("expected other sources to lock independently: %v", err)
+	} else {
+		other()
+	}
+
+	unlock()
+	if _, err := os.Stat(filepath.Join(stateDir, "sc-likes.udl-lock")); !os.IsNotExist(err) {
+		t.Fatalf("expected unlock to remove the lock file, stat err=%v", err)
+	}
+
+	lockPath := filepath.Join(stateDir, "sc-likes.udl-lock")
+	if err := os.WriteFile(lockPath, []byte("999999\n"), 0o644); err != nil {
+		t.Fatalf("write stale lock: %v", err)
+	}
+	stale := time.Now().Add(-sourceLockStaleAge - time.Minute)
+	if err := os.Chtimes(lockPath, stale, stale); err != nil {
+		t.Fatalf("age lock: %v", err)
+	}
+	unlock, err = lockSource(context.Background(), stateDir, "sc-likes", false, nil)
+	if err != nil {
+		t.Fatalf("expected stale lock to be taken over: %v", err)
+	}
+	unlock()
+}
+
+func TestLockSourceWaitsForHolder(t *testing.T) {
+	stateDir := t.TempDir()
+	unlock, err := lockSource(context.Background(), stateDir, "sc-likes", false, nil)
+	if err != nil {
+		t.Fatalf("first lock: %v", err)
+	}
+	waits := 0
+	go func() {
+		time.Sleep(2 * sourceLockPoll)
+		unlock()
+	}()
+	second, err := lockSource(context.Background(), stateDir, "sc-likes", true, func(*SourceLockError) { waits++ })
+	if err != nil {
+		t.Fatalf("wait for lock: %v", err)
+	}
+	second()
+	if waits != 1 {
+		t.Fatalf("expected one wait notice, got %d", waits)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	held, err := lockSource(context.Background(), stateDir, "sc-likes", false, nil)
+	if err != nil {
+		t.Fatalf("relock: %v", err)
+	}
+	defer held()
+	cancel()
+	if _, err := lockSource(ctx, stateDir, "sc-likes", true, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled wait, got %v", err)
+	}
+}
+
+func TestLockSourcesReleasesTakenLocksWhenOneIsHeld(t *testing.T) {
+	stateDir := t.TempDir()
+	held, err := lockSource(context.Background(), stateDir, "sc-b", false, nil)
+	if err != nil {
+		t.Fatalf("hold lock: %v", err)
+	}
+
+	_, err = lockSources(context.Background(), stateDir, []string{"sc-c", "sc-a", "sc-b"})
+	var lockErr *SourceLockError
+	if !errors.As(err, &lockErr) || lockErr.SourceID != "sc-b" {
+		t.Fatalf("expected SourceLockError for sc-b, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "sc-a.udl-lock")); !os.IsNotExist(err) {
+		t.Fatalf("expected sc-a lock to be released, stat err=%v", err)
+	}
+	held()
+
+	unlock, err := lockSources(context.Background(), stateDir, []string{"sc-a", "sc-b", "sc-a"})
+	if err != nil {
+		t.Fatalf("lock sources: %v", err)
+	}
+	for _, id := range []string{"sc-a", "sc-b"} {
+		if _, err := os.Stat(filepath.Join(stateDir, id+".udl-lock")); err != nil {
+			t.Fatalf("expected %s lock: %v", id, err)
+		}
+	}
+	unlock()
+	for _, id := range []string{"sc-a", "sc-b"} {
+		if _, err := os.Stat(filepath.Join(stateDir, id+".udl-lock")); !os.IsNotExist(err) {
+			t.Fatalf("expected %s lock to be released, stat err=%v", id, err)
+		}
+	}
+}
+
+func TestSyncerFailsSourceLockedByAnotherProcess(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{targetDir, stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "yt-locked",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.youtube.com/playlist?list=PL123",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+			},
+		},
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "yt-locked.udl-lock"), []byte("4242\n"), 0o644); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+
+	runner := &sequenceRunner{}
+	var stderr bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"ytdlp": fakeAdapter{}},
+		runner,
+		output.NewHumanEmitter(&bytes.Buffer{}, &stderr, false, true),
+	)
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Failed != 1 || len(runner.specs) != 0 {
+		t.Fatalf("expected the locked source to fail without running, got %+v specs=%d", result, len(runner.specs))
+	}
+	if !strings.Contains(stderr.String(), "udl pid 4242 is syncing this source") {
+		t.Fatalf("expected lock holder in output, got %q", stderr.String())
+	}
+
+	result, err = syncer.Sync(context.Background(), cfg, SyncOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry-run sync: %v", err)
+	}
+	if result.Failed != 0 {
+		t.Fatalf("expected a dry run to ignore the lock, got %+v", result)
+	}
+}
@@ -0,0 +1,514 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const (
+	// SourceInfoFileName is written into target_dir by sync.source_info.
+	SourceInfoFileName = "source.json"
+
+	sourceCoverBaseName = "cover"
+	sourceCoverMaxBytes = 10 << 20
+	// sourceCoverSize is the edge length requested from services that size
+	// artwork on demand.
+	sourceCoverSize = 1000
+)
+
+var (
+	fetchSourceMetadataFn = fetchSourceMetadata
+	sourceCoverHTTPClient = &http.Client{Timeout: 30 * time.Second}
+)
+
+// errSourceMetadataUnsupported marks source URLs with no playlist-level
+// metadata, such as single tracks.
+var errSourceMetadataUnsupported = errors.New("source url has no playlist metadata")
+
+// SourceMetadata is the remote playlist description kept in
+// <target_dir>/source.json, so a folder explains itself outside udl.
+type SourceMetadata struct {
+	SourceID    string    `json:"source_id"`
+	Type        string    `json:"type"`
+	Kind        string    `json:"kind,omitempty"`
+	Name        string    `json:"name"`
+	Owner       string    `json:"owner,omitempty"`
+	Description string    `json:"description,omitempty"`
+	URL         string    `json:"url"`
+	TrackCount  int       `json:"track_count,omitempty"`
+	CoverURL    string    `json:"cover_url,omitempty"`
+	CoverFile   string    `json:"cover_file,omitempty"`
+	SyncedAt    time.Time `json:"synced_at"`
+}
+
+func sourceInfoEnabled(source config.Source) bool {
+	return source.Sync.SourceInfo != nil && *source.Sync.SourceInfo
+}
+
+// writeSourceInfo fetches the source's remote metadata and writes
+// source.json (and the cover image with sync.source_cover) into target_dir.
+// Failures are warnings: a missing description never fails a sync. The cover
+// is only downloaded again when its URL changed or the file is gone.
+func (s *Syncer) writeSourceInfo(ctx context.Context, source config.Source) {
+	if !sourceInfoEnabled(source) {
+		return
+	}
+	warn := func(message string) {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] source info not updated: %s", source.ID, message),
+		})
+	}
+	metadata, err := fetchSourceMetadataFn(ctx, source)
+	if errors.Is(err, errSourceMetadataUnsupported) {
+		return
+	}
+	if err != nil {
+		warn(err.Error())
+		return
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		warn(fmt.Sprintf("resolve target_dir: %v", err))
+		return
+	}
+	metadata.SourceID = source.ID
+	metadata.Type = string(source.Type)
+	if metadata.URL == "" {
+		metadata.URL = strings.TrimSpace(source.URL)
+	}
+	if tracks, ok := s.remoteTracksFor(source.ID); ok {
+		metadata.TrackCount = len(tracks)
+	}
+	metadata.SyncedAt = s.Now().UTC()
+
+	infoPath := filepath.Join(targetDir, SourceInfoFileName)
+	previous, _ := loadSourceMetadata(infoPath)
+	if source.Sync.SourceCover != nil && *source.Sync.SourceCover && metadata.CoverURL != "" {
+		coverFile := previous.CoverFile
+		_, statErr := os.Stat(filepath.Join(targetDir, coverFile))
+		if coverFile == "" || previous.CoverURL != metadata.CoverURL || statErr != nil {
+			coverFile, err = downloadSourceCover(ctx, metadata.CoverURL, targetDir)
+			if err != nil {
+				warn(fmt.Sprintf("cover download failed: %v", err))
+				coverFile = ""
+			}
+		}
+		metadata.CoverFile = coverFile
+	}
+
+	payload, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		warn(err.Error())
+		return
+	}
+	if err := writeFileAtomically(infoPath, ".udl-source-*.tmp", append(payload, '\n')); err != nil {
+		warn(err.Error())
+		return
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourcePreflight,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] source info: %s (%q)", source.ID, infoPath, metadata.Name),
+		Details: map[string]any{
+			"source_info_file": infoPath,
+			"cover_file":       metadata.CoverFile,
+		},
+	})
+}
+
+func loadSourceMetadata(path string) (SourceMetadata, error) {
+	var metadata SourceMetadata
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return metadata, err
+	}
+	err = json.Unmarshal(payload, &metadata)
+	return metadata, err
+}
+
+// downloadSourceCover saves the image at rawURL as cover.<ext> in targetDir
+// and returns the file name.
+func downloadSourceCover(ctx context.Context, rawURL string, targetDir string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := sourceCoverHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, sourceCoverMaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > sourceCoverMaxBytes {
+		return "", fmt.Errorf("cover is larger than %d bytes", sourceCoverMaxBytes)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(body)
+	}
+	ext := ""
+	switch contentType {
+	case "image/jpeg":
+		ext = ".jpg"
+	case "image/png":
+		ext = ".png"
+	case "image/webp":
+		ext = ".webp"
+	default:
+		return "", fmt.Errorf("unexpected cover content type %q", contentType)
+	}
+	name := sourceCoverBaseName + ext
+	if err := writeFileAtomically(filepath.Join(targetDir, name), ".udl-cover-*.tmp", body); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func writeFileAtomically(path string, pattern string, payload []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	if _, err := io.Copy(temp, bytes.NewReader(payload)); err != nil {
+		_ = temp.Close()
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, 0o644); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	if err := os.Rename(tempPath, path); err != nil {
+		_ = os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+// fetchSourceMetadata reads playlist-level metadata from the service's
+// public API with the same credentials enumeration uses.
+func fetchSourceMetadata(ctx context.Context, source config.Source) (SourceMetadata, error) {
+	switch source.Type {
+	case config.SourceTypeSpotify:
+		return fetchSpotifySourceMetadata(ctx, source)
+	case config.SourceTypeDeezer:
+		return fetchDeezerSourceMetadata(ctx, source)
+	case config.SourceTypeSoundCloud:
+		return fetchSoundCloudSourceMetadata(ctx, source)
+	case config.SourceTypeAppleMusic:
+		return fetchAppleMusicSourceMetadata(ctx, source)
+	default:
+		return SourceMetadata{}, errSourceMetadataUnsupported
+	}
+}
+
+type spotifyAPIImage struct {
+	URL string `json:"url"`
+}
+
+type spotifyAPIPlaylistInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Owner       struct {
+		DisplayName string `json:"display_name"`
+		ID          string `json:"id"`
+	} `json:"owner"`
+	Images       []spotifyAPIImage `json:"images"`
+	ExternalURLs map[string]string `json:"external_urls"`
+}
+
+type spotifyAPIArtistInfo struct {
+	Name         string            `json:"name"`
+	Images       []spotifyAPIImage `json:"images"`
+	ExternalURLs map[string]string `json:"external_urls"`
+}
+
+func fetchSpotifySourceMetadata(ctx context.Context, source config.Source) (SourceMetadata, error) {
+	base := strings.TrimSuffix(spotifyAPIBaseURL, "/")
+	artist := config.IsSpotifyArtistURL(source.URL)
+	var endpoint string
+	if artist {
+		artistID, err := resolveSpotifyArtistID(source.URL)
+		if err != nil {
+			return SourceMetadata{}, errSourceMetadataUnsupported
+		}
+		endpoint = base + "/v1/artists/" + url.PathEscape(artistID)
+	} else {
+		playlistID, err := resolveSpotifyPlaylistID(source.URL)
+		if err != nil {
+			return SourceMetadata{}, errSourceMetadataUnsupported
+		}
+		endpoint = base + "/v1/playlists/" + url.PathEscape(playlistID) + "?fields=name,description,owner(display_name,id),images,external_urls"
+	}
+	creds, err := auth.ResolveSpotifyCredentials()
+	if err != nil {
+		return SourceMetadata{}, err
+	}
+	token, err := fetchSpotifyAccessTokenFn(ctx, creds)
+	if err != nil {
+		return SourceMetadata{}, err
+	}
+
+	if artist {
+		var payload spotifyAPIArtistInfo
+		if err := getSpotifyJSON(ctx, endpoint, token, &payload); err != nil {
+			return SourceMetadata{}, fmt.Errorf("spotify artist: %w", err)
+		}
+		return SourceMetadata{
+			Kind:     "artist",
+			Name:     strings.TrimSpace(payload.Name),
+			URL:      strings.TrimSpace(payload.ExternalURLs["spotify"]),
+			CoverURL: firstSpotifyImage(payload.Images),
+		}, nil
+	}
+	var payload spotifyAPIPlaylistInfo
+	if err := getSpotifyJSON(ctx, endpoint, token, &payload); err != nil {
+		return SourceMetadata{}, fmt.Errorf("spotify playlist: %w", err)
+	}
+	owner := strings.TrimSpace(payload.Owner.DisplayName)
+	if owner == "" {
+		owner = strings.TrimSpace(payload.Owner.ID)
+	}
+	return SourceMetadata{
+		Kind:        "playlist",
+		Name:        strings.TrimSpace(payload.Name),
+		Owner:       owner,
+		Description: strings.TrimSpace(html.UnescapeString(payload.Description)),
+		URL:         strings.TrimSpace(payload.ExternalURLs["spotify"]),
+		CoverURL:    firstSpotifyImage(payload.Images),
+	}, nil
+}
+
+// firstSpotifyImage returns the largest image; the API lists them widest
+// first.
+func firstSpotifyImage(images []spotifyAPIImage) string {
+	for _, image := range images {
+		if trimmed := strings.TrimSpace(image.URL); trimmed != "" {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+type deezerAPICollectionInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Link        string `json:"link"`
+	PictureXL   string `json:"picture_xl"`
+	CoverXL     string `json:"cover_xl"`
+	Creator     struct {
+		Name string `json:"name"`
+	} `json:"creator"`
+	Artist struct {
+		Name string `json:"name"`
+	} `json:"artist"`
+	Error *deezerAPIError `json:"error"`
+}
+
+func fetchDeezerSourceMetadata(ctx context.Context, source config.Source) (SourceMetadata, error) {
+	kind, id, err := parseDeezerResourceURL(source.URL)
+	if err != nil || kind == deezerResourceTrack {
+		return SourceMetadata{}, errSourceMetadataUnsupported
+	}
+	var payload deezerAPICollectionInfo
+	rawURL := strings.TrimSuffix(deezerAPIBaseURL, "/") + "/" + string(kind) + "/" + id
+	if err := getDeezerJSON(ctx, rawURL, &payload); err != nil {
+		return SourceMetadata{}, fmt.Errorf("deezer %s %s: %w", kind, id, err)
+	}
+	if payload.Error != nil {
+		return SourceMetadata{}, fmt.Errorf("deezer %s %s: %s", kind, id, payload.Error.describe())
+	}
+	metadata := SourceMetadata{
+		Kind:        string(kind),
+		Name:        strings.TrimSpace(payload.Title),
+		Owner:       strings.TrimSpace(payload.Creator.Name),
+		Description: strings.TrimSpace(payload.Description),
+		URL:         strings.TrimSpace(payload.Link),
+		CoverURL:    strings.TrimSpace(payload.PictureXL),
+	}
+	if kind == deezerResourceAlbum {
+		metadata.Owner = strings.TrimSpace(payload.Artist.Name)
+		metadata.CoverURL = strings.TrimSpace(payload.CoverXL)
+	}
+	return metadata, nil
+}
+
+type soundCloudAPIResourceInfo struct {
+	Kind         string `json:"kind"`
+	Title        string `json:"title"`
+	Username     string `json:"username"`
+	Description  string `json:"description"`
+	PermalinkURL string `json:"permalink_url"`
+	ArtworkURL   string `json:"artwork_url"`
+	AvatarURL    string `json:"avatar_url"`
+	User         struct {
+		Username string `json:"username"`
+	} `json:"user"`
+}
+
+// soundCloudModeKinds names the collection a profile URL syncs, by scdl
+// mode flag.
+var soundCloudModeKinds = map[string]string{
+	"-f": "likes",
+	"-t": "uploads",
+	"-r": "reposts",
+	"-a": "uploads+reposts",
+	"-p": "playlists",
+}
+
+func fetchSoundCloudSourceMetadata(ctx context.Context, source config.Source) (SourceMetadata, error) {
+	if config.IsSoundCloudStationURL(source.URL) {
+		return SourceMetadata{}, errSourceMetadataUnsupported
+	}
+	clientID, err := resolveSoundCloudAPIClientIDFn(ctx)
+	if err != nil {
+		return SourceMetadata{}, err
+	}
+	client := soundCloudAPIClient{BaseURL: soundCloudAPIBaseURL, HTTP: soundCloudAPIHTTPClient, ClientID: clientID}
+	rawURL, mode := soundCloudListTarget(source)
+	var payload soundCloudAPIResourceInfo
+	if err := client.getJSON(ctx, client.endpoint("/resolve", url.Values{"url": {rawURL}}), &payload); err != nil {
+		return SourceMetadata{}, fmt.Errorf("resolve %s: %w", rawURL, err)
+	}
+	switch payload.Kind {
+	case "playlist":
+		return SourceMetadata{
+			Kind:        "playlist",
+			Name:        strings.TrimSpace(payload.Title),
+			Owner:       strings.TrimSpace(payload.User.Username),
+			Description: strings.TrimSpace(payload.Description),
+			URL:         strings.TrimSpace(payload.PermalinkURL),
+			CoverURL:    soundCloudLargeArtwork(payload.ArtworkURL),
+		}, nil
+	case "user":
+		kind := soundCloudModeKinds[mode]
+		name := strings.TrimSpace(payload.Username)
+		if kind != "" {
+			name = name + " - " + kind
+		}
+		return SourceMetadata{
+			Kind:        kind,
+			Name:        name,
+			Owner:       strings.TrimSpace(payload.Username),
+			Description: strings.TrimSpace(payload.Description),
+			URL:         strings.TrimSpace(payload.PermalinkURL),
+			CoverURL:    soundCloudLargeArtwork(payload.AvatarURL),
+		}, nil
+	default:
+		return SourceMetadata{}, errSourceMetadataUnsupported
+	}
+}
+
+// soundCloudLargeArtwork swaps the default 100x100 artwork variant for the
+// 500x500 one.
+func soundCloudLargeArtwork(rawURL string) string {
+	return strings.Replace(strings.TrimSpace(rawURL), "-large.", "-t500x500.", 1)
+}
+
+type appleMusicAPIResourceInfo struct {
+	Data []struct {
+		Attributes struct {
+			Name        string `json:"name"`
+			CuratorName string `json:"curatorName"`
+			ArtistName  string `json:"artistName"`
+			URL         string `json:"url"`
+			Description struct {
+				Standard string `json:"standard"`
+			} `json:"description"`
+			EditorialNotes struct {
+				Standard string `json:"standard"`
+			} `json:"editorialNotes"`
+			Artwork struct {
+				URL string `json:"url"`
+			} `json:"artwork"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Errors []appleMusicAPIError `json:"errors"`
+}
+
+func fetchAppleMusicSourceMetadata(ctx context.Context, source config.Source) (SourceMetadata, error) {
+	resource, err := parseAppleMusicResourceURL(source.URL)
+	if err != nil || resource.Kind == appleMusicResourceSong {
+		return SourceMetadata{}, errSourceMetadataUnsupported
+	}
+	apiAuth, err := resolveAppleMusicAPIAuthFn(ctx, resource.Library)
+	if err != nil {
+		return SourceMetadata{}, err
+	}
+	base := strings.TrimSuffix(appleMusicAPIBaseURL, "/")
+	storefront := resource.Storefront
+	if storefront == "" {
+		storefront = appleMusicDefaultStorefront
+	}
+	rawURL := fmt.Sprintf("%s/v1/catalog/%s/%ss/%s", base, storefront, resource.Kind, url.PathEscape(resource.ID))
+	if resource.Library {
+		rawURL = fmt.Sprintf("%s/v1/me/library/playlists/%s", base, url.PathEscape(resource.ID))
+	}
+	var payload appleMusicAPIResourceInfo
+	if err := getAppleMusicJSON(ctx, rawURL, apiAuth, &payload); err != nil {
+		return SourceMetadata{}, fmt.Errorf("apple music %s %s: %w", resource.Kind, resource.ID, err)
+	}
+	if len(payload.Errors) > 0 {
+		return SourceMetadata{}, fmt.Errorf("apple music %s %s: %s", resource.Kind, resource.ID, payload.Errors[0].describe())
+	}
+	if len(payload.Data) == 0 {
+		return SourceMetadata{}, fmt.Errorf("apple music %s %s: empty response", resource.Kind, resource.ID)
+	}
+	attributes := payload.Data[0].Attributes
+	owner := strings.TrimSpace(attributes.CuratorName)
+	if owner == "" {
+		owner = strings.TrimSpace(attributes.ArtistName)
+	}
+	description := strings.TrimSpace(attributes.Description.Standard)
+	if description == "" {
+		description = strings.TrimSpace(attributes.EditorialNotes.Standard)
+	}
+	size := strconv.Itoa(sourceCoverSize)
+	cover := strings.NewReplacer("{w}", size, "{h}", size).Replace(strings.TrimSpace(attributes.Artwork.URL))
+	return SourceMetadata{
+		Kind:        string(resource.Kind),
+		Name:        strings.TrimSpace(attributes.Name),
+		Owner:       owner,
+		Description: description,
+		URL:         strings.TrimSpace(attributes.URL),
+		CoverURL:    cover,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestWriteSourceInfoWritesDeezerPlaylistMetadataAndCover(t *testing.T) {
+	coverRequests := 0
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x10, 'J', 'F', 'I', 'F', 0x00}
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("/playlist/908622995", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"title":"Friday Warmup","description":"Deep and slow","link":"https://www.deezer.com/playlist/908622995","picture_xl":"%s/cover.jpg","creator":{"name":"dj-jaa"}}`, server.URL)
+	})
+	mux.HandleFunc("/cover.jpg", func(w http.ResponseWriter, r *http.Request) {
+		coverRequests++
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, _ = w.Write(jpeg)
+	})
+	origBase := deezerAPIBaseURL
+	t.Cleanup(func() { deezerAPIBaseURL = origBase })
+	deezerAPIBaseURL = server.URL
+
+	targetDir := t.TempDir()
+	source := config.Source{
+		ID:        "deezer-mix",
+		Type:      config.SourceTypeDeezer,
+		TargetDir: targetDir,
+		URL:       "https://www.deezer.com/en/playlist/908622995",
+		Sync:      config.SyncPolicy{SourceInfo: boolPtrSyncer(true), SourceCover: boolPtrSyncer(true)},
+	}
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC) }
+
+	syncer.writeSourceInfo(context.Background(), source)
+	syncer.writeSourceInfo(context.Background(), source)
+
+	metadata, err := loadSourceMetadata(filepath.Join(targetDir, SourceInfoFileName))
+	if err != nil {
+		t.Fatalf("load source.json: %v\n%s", err, out.String())
+	}
+	want := SourceMetadata{
+		SourceID:    "deezer-mix",
+		Type:        "deezer",
+		Kind:        "playlist",
+		Name:        "Friday Warmup",
+		Owner:       "dj-jaa",
+		Description: "Deep and slow",
+		URL:         "https://www.deezer.com/playlist/908622995",
+		CoverURL:    server.URL + "/cover.jpg",
+		CoverFile:   "cover.jpg",
+		SyncedAt:    time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC),
+	}
+	if metadata != want {
+		t.Fatalf("unexpected source.json\n got %+v\nwant %+v", metadata, want)
+	}
+	cover, err := os.ReadFile(filepath.Join(targetDir, "cover.jpg"))
+	if err != nil || !bytes.Equal(cover, jpeg) {
+		t.Fatalf("expected cover.jpg to be written, got %v", err)
+	}
+	if coverRequests != 1 {
+		t.Fatalf("expected an unchanged cover to be downloaded once, got %d requests", coverRequests)
+	}
+}
+
+func TestWriteSourceInfoSkipsSingleTrackSources(t *testing.T) {
+	targetDir := t.TempDir()
+	source := config.Source{
+		ID:        "deezer-track",
+		Type:      config.SourceTypeDeezer,
+		TargetDir: targetDir,
+		URL:       "https://www.deezer.com/track/3135556",
+		Sync:      config.SyncPolicy{SourceInfo: boolPtrSyncer(true)},
+	}
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = time.Now
+
+	syncer.writeSourceInfo(context.Background(), source)
+
+	if _, err := os.Stat(filepath.Join(targetDir, SourceInfoFileName)); !os.IsNotExist(err) {
+		t.Fatalf("expected no source.json for a single track, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no warning for a single track, got %q", out.String())
+	}
+}
@@ -0,0 +1,250 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+const sourceMonitorSchema = 1
+
+// MonitoredTrack is a remote track a monitor source has seen but not
+// downloaded.
+type MonitoredTrack struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// sourceMonitorFile is <state_dir>/<source-id>.monitor.json, every track a
+// monitor source has reported so far, keyed by track ID.
+type sourceMonitorFile struct {
+	Schema   int                       `json:"schema"`
+	SourceID string                    `json:"source_id"`
+	Tracks   map[string]MonitoredTrack `json:"tracks"`
+}
+
+func sourceMonitorPath(stateDir string, sourceID string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(root) {
+		return "", fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	return filepath.Join(root, sourceID+".monitor.json"), nil
+}
+
+// readSourceMonitorFile loads a source's monitor ledger; ok is false when
+// the source has never been monitored.
+func readSourceMonitorFile(stateDir string, sourceID string) (*sourceMonitorFile, bool, error) {
+	path, err := sourceMonitorPath(stateDir, sourceID)
+	if err != nil {
+		return nil, false, err
+	}
+	ledger := &sourceMonitorFile{Schema: sourceMonitorSchema, SourceID: sourceID, Tracks: map[string]MonitoredTrack{}}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ledger, false, nil
+		}
+		return nil, false, err
+	}
+	if err := json.Unmarshal(raw, ledger); err != nil {
+		return nil, false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if ledger.Tracks == nil {
+		ledger.Tracks = map[string]MonitoredTrack{}
+	}
+	return ledger, true, nil
+}
+
+func writeSourceMonitorFile(stateDir string, ledger *sourceMonitorFile) error {
+	path, err := sourceMonitorPath(stateDir, ledger.SourceID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	ledger.Schema = sourceMonitorSchema
+	payload, err := json.MarshalIndent(ledger, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(payload, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// unpulledMonitoredTracks lists the ledger's tracks that neither the state
+// file nor the archive records yet, oldest first.
+func unpulledMonitoredTracks(defaults config.Defaults, source config.Source, ledger *sourceMonitorFile) ([]MonitoredTrack, error) {
+	state := soundCloudSyncState{ByID: map[string]soundCloudSyncEntry{}}
+	statePath, err := stateFileForVerify(defaults, source)
+	if err != nil {
+		return nil, err
+	}
+	if statePath != "" {
+		if state, err = parseSoundCloudSyncState(statePath); err != nil {
+			return nil, err
+		}
+	}
+	archivePath, err := resolveSoundCloudArchivePath(source, defaults)
+	if err != nil {
+		return nil, err
+	}
+	archived, err := parseSoundCloudArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	unpulled := []MonitoredTrack{}
+	for id, track := range ledger.Tracks {
+		if _, ok := state.ByID[id]; ok {
+			continue
+		}
+		if _, ok := archived[id]; ok {
+			continue
+		}
+		unpulled = append(unpulled, track)
+	}
+	sortMonitoredTracks(unpulled)
+	return unpulled, nil
+}
+
+func sortMonitoredTracks(tracks []MonitoredTrack) {
+	sort.Slice(tracks, func(i, j int) bool {
+		if !tracks[i].FirstSeen.Equal(tracks[j].FirstSeen) {
+			return tracks[i].FirstSeen.Before(tracks[j].FirstSeen)
+		}
+		return tracks[i].ID < tracks[j].ID
+	})
+}
+
+// runSoundCloudMonitor finishes a monitor source after preflight: the
+// planned tracks it has not reported before are added to its ledger and
+// listed, and nothing is downloaded. The first run records the remote
+// listing as a baseline without reporting it as new.
+func (s *Syncer) runSoundCloudMonitor(
+	cfg config.Config,
+	source config.Source,
+	sourcePreflight *SoundCloudPreflight,
+	plannedTracks []soundCloudRemoteTrack,
+	stateSwap soundCloudStateSwap,
+	opts SyncOptions,
+) sourceRunOutcome {
+	outcome := sourceRunOutcome{Attempted: 1}
+	if err := cleanupTempStateFiles(stateSwap); err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourceFinished,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] unable to clean temporary state file: %v", source.ID, err),
+		})
+	}
+	fail := func(message string) sourceRunOutcome {
+		outcome.Failed++
+		outcome.Stop = !cfg.Defaults.ContinueOnError
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelError,
+			Event:     output.EventSourceFailed,
+			SourceID:  source.ID,
+			Message:   message,
+		})
+		return outcome
+	}
+	if sourcePreflight == nil {
+		return fail(fmt.Sprintf("[%s] monitor requires preflight planning; remove --no-preflight", source.ID))
+	}
+	ledger, existed, err := readSourceMonitorFile(cfg.Defaults.StateDir, source.ID)
+	if err != nil {
+		return fail(fmt.Sprintf("[%s] read monitor ledger: %v", source.ID, err))
+	}
+
+	now := s.Now().UTC()
+	newTracks := []MonitoredTrack{}
+	for _, track := range plannedTracks {
+		if _, seen := ledger.Tracks[track.ID]; seen {
+			continue
+		}
+		monitored := MonitoredTrack{
+			ID:        track.ID,
+			Title:     strings.TrimSpace(track.Title),
+			URL:       strings.TrimSpace(track.URL),
+			FirstSeen: now,
+		}
+		ledger.Tracks[track.ID] = monitored
+		newTracks = append(newTracks, monitored)
+	}
+	if !opts.DryRun && (len(newTracks) > 0 || !existed) {
+		if err := writeSourceMonitorFile(cfg.Defaults.StateDir, ledger); err != nil {
+			return fail(fmt.Sprintf("[%s] write monitor ledger: %v", source.ID, err))
+		}
+	}
+	unpulled, err := unpulledMonitoredTracks(cfg.Defaults, source, ledger)
+	if err != nil {
+		return fail(fmt.Sprintf("[%s] read state for monitor: %v", source.ID, err))
+	}
+
+	baseline := !existed
+	if !baseline {
+		for _, track := range newTracks {
+			label := track.Title
+			if label == "" {
+				label = track.ID
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [new] %s (%s) %s", source.ID, track.ID, label, track.URL),
+				Details: map[string]any{
+					"track_id": track.ID,
+					"url":      track.URL,
+				},
+			})
+		}
+	}
+
+	var message string
+	switch {
+	case opts.DryRun:
+		message = fmt.Sprintf("[%s] monitor dry-run: %d new track(s) would be recorded; nothing downloaded", source.ID, len(newTracks))
+	case baseline:
+		message = fmt.Sprintf("[%s] monitor: recorded %d remote track(s) as the baseline; nothing downloaded", source.ID, len(newTracks))
+	default:
+		message = fmt.Sprintf("[%s] monitor: %d new track(s), %d not pulled yet; nothing downloaded", source.ID, len(newTracks), len(unpulled))
+	}
+	details := map[string]any{
+		"monitor":  true,
+		"baseline": baseline,
+		"unpulled": len(unpulled),
+	}
+	if !baseline && !opts.DryRun && len(newTracks) > 0 {
+		details["new_tracks"] = newTracks
+	}
+	outcome.Succeeded++
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourceFinished,
+		SourceID:  source.ID,
+		Message:   message,
+		Details:   details,
+	})
+	return outcome
+}
@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// SourceStatus is a read-only snapshot of one source's local sync state. It is
+// built from the state file, archive, and target_dir without contacting any
+// remote service.
+type SourceStatus struct {
+	SourceID     string     `json:"source_id"`
+	Type         string     `json:"type"`
+	Adapter      string     `json:"adapter"`
+	Enabled      bool       `json:"enabled"`
+	TargetDir    string     `json:"target_dir"`
+	StateFile    string     `json:"state_file,omitempty"`
+	ArchiveFile  string     `json:"archive_file,omitempty"`
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+	KnownTracks  int        `json:"known_tracks"`
+	LocalFiles   int        `json:"local_files"`
+	Gaps         int        `json:"gaps"`
+	GapsChecked  bool       `json:"gaps_checked"`
+	Errors       []string   `json:"errors,omitempty"`
+
+	// Monitor sources report how many tracks they have recorded without
+	// downloading, and how many of those are still not pulled.
+	Monitor   bool `json:"monitor,omitempty"`
+	Monitored int  `json:"monitored,omitempty"`
+	Unpulled  int  `json:"unpulled,omitempty"`
+
+	LastError *SourceLastError `json:"last_error,omitempty"`
+}
+
+// InspectSourceStatuses returns a SourceStatus for the requested sources, or
+// for every configured source when sourceIDs is empty, each with the last
+// failure recorded since its most recent successful sync.
+func InspectSourceStatuses(cfg config.Config, sourceIDs []string) ([]SourceStatus, error) {
+	sources, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	lastErrors, lastErrorsErr := LoadSourceLastErrors(cfg.Defaults.StateDir)
+	statuses := make([]SourceStatus, 0, len(sources))
+	for _, source := range sources {
+		status := InspectSourceStatus(cfg.Defaults, source)
+		if lastErrorsErr != nil {
+			status.Errors = append(status.Errors, "last_error: "+lastErrorsErr.Error())
+		} else if lastErr, ok := lastErrors[source.ID]; ok {
+			status.LastError = &lastErr
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// InspectSourceStatus reports when a source was last synced (newest state or
+// archive write), how many tracks are known, how many media files exist in
+// target_dir, and how many known tracks are missing locally.
+func InspectSourceStatus(defaults config.Defaults, source config.Source) SourceStatus {
+	status := SourceStatus{
+		SourceID: source.ID,
+		Type:     string(source.Type),
+		Adapter:  source.Adapter.Kind,
+		Enabled:  source.Enabled,
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		status.Errors = append(status.Errors, "target_dir: "+err.Error())
+	}
+	status.TargetDir = targetDir
+	status.LocalFiles = countLocalMediaFiles(targetDir)
+
+	if strings.TrimSpace(source.StateFile) != "" {
+		statePath, err := config.ResolveStateFile(defaults.StateDir, source.StateFile)
+		if err != nil {
+			status.Errors = append(status.Errors, "state_file: "+err.Error())
+		} else {
+			status.StateFile = statePath
+			status.observeSyncTime(statePath)
+		}
+	}
+	archivePath, ok, err := sourceArchivePath(source, defaults)
+	if err != nil {
+		status.Errors = append(status.Errors, "archive_file: "+err.Error())
+	} else if ok {
+		status.ArchiveFile = archivePath
+		status.observeSyncTime(archivePath)
+	}
+
+	switch source.Type {
+	case config.SourceTypeSoundCloud:
+		if status.StateFile == "" {
+			break
+		}
+		state, err := parseSoundCloudSyncState(status.StateFile)
+		if err != nil {
+			status.Errors = append(status.Errors, "state_file: "+err.Error())
+			break
+		}
+		status.KnownTracks = len(state.ByID)
+		status.GapsChecked = true
+		for _, entry := range state.ByID {
+			if !stateEntryHasLocalFile(entry.FilePath, targetDir) {
+				status.Gaps++
+			}
+		}
+		if source.Monitor {
+			status.inspectMonitor(defaults, source)
+		}
+	case config.SourceTypeSpotify, config.SourceTypeDeezer, config.SourceTypeAppleMusic:
+		if status.StateFile == "" {
+			break
+		}
+		state, err := parseTrackStateForSource(source.Type, status.StateFile)
+		if err != nil {
+			status.Errors = append(status.Errors, "state_file: "+err.Error())
+			break
+		}
+		status.KnownTracks = len(state.KnownIDs)
+		status.GapsChecked = true
+		consumed := map[string]struct{}{}
+		for _, entry := range state.Entries {
+			if strings.TrimSpace(entry.LocalPath) == "" {
+				continue
+			}
+			if !spotifyStateTrackPresent(targetDir, entry, consumed) {
+				status.Gaps++
+			}
+		}
+	default:
+		if status.ArchiveFile == "" {
+			break
+		}
+		lines, err := readSoundCloudArchiveLines(status.ArchiveFile)
+		if err != nil {
+			status.Errors = append(status.Errors, "archive_file: "+err.Error())
+			break
+		}
+		for _, line := range lines {
+			if strings.TrimSpace(line.Raw) != "" {
+				status.KnownTracks++
+			}
+		}
+	}
+	return status
+}
+
+func (s *SourceStatus) inspectMonitor(defaults config.Defaults, source config.Source) {
+	s.Monitor = true
+	ledger, ok, err := readSourceMonitorFile(defaults.StateDir, source.ID)
+	if err != nil {
+		s.Errors = append(s.Errors, "monitor: "+err.Error())
+		return
+	}
+	if !ok {
+		return
+	}
+	if path, err := sourceMonitorPath(defaults.StateDir, source.ID); err == nil {
+		s.observeSyncTime(path)
+	}
+	unpulled, err := unpulledMonitoredTracks(defaults.ForSource(source), source, ledger)
+	if err != nil {
+		s.Errors = append(s.Errors, "monitor: "+err.Error())
+		return
+	}
+	s.Monitored = len(ledger.Tracks)
+	s.Unpulled = len(unpulled)
+}
+
+func (s *SourceStatus) observeSyncTime(path string) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return
+	}
+	modTime := info.ModTime().UTC()
+	if s.LastSyncedAt == nil || modTime.After(*s.LastSyncedAt) {
+		s.LastSyncedAt = &modTime
+	}
+}
+
+func countLocalMediaFiles(targetDir string) int {
+	root := strings.TrimSpace(targetDir)
+	if root == "" {
+		return 0
+	}
+	count := 0
+	_ = filepath.WalkDir(root, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return nil
+		}
+		if !d.IsDir() && isMediaExt(strings.ToLower(filepath.Ext(d.Name()))) {
+			count++
+		}
+		return nil
+	})
+	return count
+}
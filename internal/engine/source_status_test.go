@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestInspectSourceStatusCountsKnownLocalAndGaps(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, targetDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"one.mp3", "extra.flac", "cover.jpg"} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("x"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "sc.sync.scdl")
+	if err := os.WriteFile(statePath, []byte("soundcloud 1 one.mp3\nsoundcloud 2 two.mp3\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	syncedAt := time.Date(2026, 2, 1, 8, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(statePath, syncedAt, syncedAt); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sc",
+			Type:      config.SourceTypeSoundCloud,
+			Enabled:   true,
+			TargetDir: targetDir,
+			StateFile: "sc.sync.scdl",
+			Adapter:   config.AdapterSpec{Kind: "scdl"},
+		}},
+	}
+	statuses, err := InspectSourceStatuses(cfg, nil)
+	if err != nil {
+		t.Fatalf("inspect: %v", err)
+	}
+	status := statuses[0]
+	if status.KnownTracks != 2 || status.LocalFiles != 2 {
+		t.Fatalf("expected known=2 local=2, got %+v", status)
+	}
+	if !status.GapsChecked || status.Gaps != 1 {
+		t.Fatalf("expected one gap, got %+v", status)
+	}
+	if status.LastSyncedAt == nil || !status.LastSyncedAt.Equal(syncedAt) {
+		t.Fatalf("expected last synced from state mtime, got %v", status.LastSyncedAt)
+	}
+
+	if _, err := InspectSourceStatuses(cfg, []string{"missing"}); err == nil {
+		t.Fatalf("expected selection error for unknown source")
+	}
+}
+
+func TestInspectSourceStatusNeverSyncedSpotify(t *testing.T) {
+	tmp := t.TempDir()
+	status := InspectSourceStatus(config.Defaults{StateDir: filepath.Join(tmp, "state")}, config.Source{
+		ID:        "sp",
+		Type:      config.SourceTypeSpotify,
+		TargetDir: filepath.Join(tmp, "missing"),
+		StateFile: "sp.sync.spotify",
+		Adapter:   config.AdapterSpec{Kind: "deemix"},
+	})
+	if status.LastSyncedAt != nil || status.KnownTracks != 0 || status.LocalFiles != 0 || status.Gaps != 0 {
+		t.Fatalf("expected empty status for never-synced source, got %+v", status)
+	}
+	if len(status.Errors) != 0 {
+		t.Fatalf("expected no errors for missing state, got %v", status.Errors)
+	}
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// SelectSourceIDs resolves --source, --tag, and --exclude-tag into the
+// source IDs to run, in config order. A source is selected when its ID is
+// listed or it carries any listed tag; sources carrying an excluded tag are
+// then dropped. With neither IDs nor tags every source starts selected, and
+// with no exclusions either the IDs are returned as given, so nil still
+// means every source. Unknown IDs, tags no source carries, and exclusions
+// that leave nothing are reported as a *SelectionError.
+func SelectSourceIDs(sources []config.Source, ids []string, tags []string, excludeTags []string) ([]string, error) {
+	tags = normalizeSourceTags(tags)
+	excludeTags = normalizeSourceTags(excludeTags)
+	if len(tags) == 0 && len(excludeTags) == 0 {
+		return ids, nil
+	}
+
+	requested := map[string]struct{}{}
+	for _, id := range ids {
+		requested[id] = struct{}{}
+	}
+	selectAll := len(ids) == 0 && len(tags) == 0
+	foundIDs := map[string]struct{}{}
+	foundTags := map[string]struct{}{}
+	selected := []string{}
+	for _, source := range sources {
+		_, byID := requested[source.ID]
+		if byID {
+			foundIDs[source.ID] = struct{}{}
+		}
+		byTag := false
+		for _, tag := range tags {
+			if sourceHasTag(source, tag) {
+				foundTags[tag] = struct{}{}
+				byTag = true
+			}
+		}
+		if !selectAll && !byID && !byTag {
+			continue
+		}
+		if slices.ContainsFunc(excludeTags, func(tag string) bool { return sourceHasTag(source, tag) }) {
+			continue
+		}
+		selected = append(selected, source.ID)
+	}
+
+	selErr := &SelectionError{}
+	for _, id := range ids {
+		if _, ok := foundIDs[id]; !ok {
+			selErr.Missing = append(selErr.Missing, id)
+		}
+	}
+	for _, tag := range tags {
+		if _, ok := foundTags[tag]; !ok {
+			selErr.MissingTags = append(selErr.MissingTags, tag)
+		}
+	}
+	if len(selErr.Missing) > 0 || len(selErr.MissingTags) > 0 || len(selected) == 0 {
+		return nil, selErr
+	}
+	return selected, nil
+}
+
+func sourceHasTag(source config.Source, tag string) bool {
+	return slices.Contains(source.Tags, tag)
+}
+
+func normalizeSourceTags(tags []string) []string {
+	out := []string{}
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && !slices.Contains(out, tag) {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestSelectSourceIDsByTag(t *testing.T) {
+	sources := []config.Source{
+		{ID: "sc-weekly", Tags: []string{"weekly", "dj-sets"}},
+		{ID: "sp-weekly", Tags: []string{"weekly", "paused"}},
+		{ID: "sc-likes"},
+		{ID: "yt-sets", Tags: []string{"dj-sets"}},
+	}
+	cases := []struct {
+		name    string
+		ids     []string
+		tags    []string
+		exclude []string
+		want    []string
+	}{
+		{name: "no selection", want: nil},
+		{name: "ids only", ids: []string{"sc-likes"}, want: []string{"sc-likes"}},
+		{name: "tag", tags: []string{"Weekly"}, want: []string{"sc-weekly", "sp-weekly"}},
+		{name: "tag and id", ids: []string{"sc-likes"}, tags: []string{"dj-sets"}, want: []string{"sc-weekly", "sc-likes", "yt-sets"}},
+		{name: "tag minus excluded", tags: []string{"weekly"}, exclude: []string{"paused"}, want: []string{"sc-weekly"}},
+		{name: "exclude only", exclude: []string{"weekly"}, want: []string{"sc-likes", "yt-sets"}},
+	}
+	for _, tc := range cases {
+		got, err := SelectSourceIDs(sources, tc.ids, tc.tags, tc.exclude)
+		if err != nil {
+			t.Fatalf("%s: select: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: expected %v, got %v", tc.name, tc.want, got)
+		}
+	}
+
+	_, err := SelectSourceIDs(sources, []string{"nope"}, []string{"monthly"}, nil)
+	var selErr *SelectionError
+	if !errors.As(err, &selErr) || !reflect.DeepEqual(selErr.Missing, []string{"nope"}) || !reflect.DeepEqual(selErr.MissingTags, []string{"monthly"}) {
+		t.Fatalf("expected unknown id and tag, got %v", err)
+	}
+	_, err = SelectSourceIDs(sources, nil, []string{"paused"}, []string{"weekly"})
+	if !errors.As(err, &selErr) || !strings.Contains(err.Error(), "no sources selected") {
+		t.Fatalf("expected an empty selection error, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/cmplx"
+	"os/exec"
+	"strings"
+)
+
+const (
+	// spectralSampleRate is the rate files are decoded at for analysis;
+	// its 22.05 kHz Nyquist covers every lossy encoder's lowpass.
+	spectralSampleRate = 44100
+	spectralFrameSize  = 4096
+	// spectralAnalysisSeconds of audio are decoded, from the start.
+	spectralAnalysisSeconds = 60
+	// spectralFloorDB is how far below the loudest bin the averaged
+	// spectrum must fall to count as empty. Real high-frequency content
+	// sits well above it; what an encoder's lowpass leaves behind, dither
+	// and quantisation noise, sits below.
+	spectralFloorDB = 80.0
+	// spectralSmoothBins averages neighbouring bins so a single noisy bin
+	// does not set the ceiling.
+	spectralSmoothBins = 8
+	// SpectralSuspectCeilingHz is the ceiling below which a lossless file is
+	// taken for an upsampled lossy rip. 128-160 kbps MP3 and AAC stop at
+	// 16-17.5 kHz; AAC 256 and real masters reach 19 kHz and above.
+	SpectralSuspectCeilingHz = 18000.0
+)
+
+// SuspectQualityMarker is appended to the comment tag of free-DL downloads
+// whose spectrum looks upsampled, so promote-freedl can tell them apart.
+const SuspectQualityMarker = "udl:suspect-quality"
+
+var decodeSpectralSamplesFn = decodeSpectralSamples
+
+// SpectralCeiling is the result of ProbeSpectralCeiling.
+type SpectralCeiling struct {
+	CeilingHz float64 `json:"ceiling_hz"`
+	Suspect   bool    `json:"suspect"`
+}
+
+// Describe formats the ceiling for log lines and tags, e.g. "16.1kHz".
+func (c SpectralCeiling) Describe() string {
+	return fmt.Sprintf("%.1fkHz", c.CeilingHz/1000)
+}
+
+// ProbeSpectralCeiling decodes the opening minute of path with ffmpeg and
+// returns the highest frequency its averaged spectrum still has content at.
+// Lossless files that stop below SpectralSuspectCeilingHz are marked
+// suspect: a genuine master has content up to about 20 kHz, while a lossy
+// rip saved as WAV or FLAC keeps its encoder's lowpass.
+func ProbeSpectralCeiling(ctx context.Context, path string) (SpectralCeiling, error) {
+	samples, err := decodeSpectralSamplesFn(ctx, path)
+	if err != nil {
+		return SpectralCeiling{}, err
+	}
+	ceiling, err := spectralCeilingHz(samples, spectralSampleRate)
+	if err != nil {
+		return SpectralCeiling{}, err
+	}
+	return SpectralCeiling{CeilingHz: ceiling, Suspect: ceiling < SpectralSuspectCeilingHz}, nil
+}
+
+// IsLosslessMediaPath reports whether a path's extension is a lossless
+// container the spectral check applies to.
+func IsLosslessMediaPath(path string) bool {
+	switch strings.ToLower(path[strings.LastIndex(path, ".")+1:]) {
+	case "wav", "aif", "aiff", "flac":
+		return true
+	default:
+		return false
+	}
+}
+
+func decodeSpectralSamples(ctx context.Context, path string) ([]float64, error) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return nil, fmt.Errorf("ffmpeg is required for the spectral quality check: %w", err)
+	}
+	output, err := RunPostProcess(
+		ctx,
+		"ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-t", fmt.Sprint(spectralAnalysisSeconds),
+		"-i", path,
+		"-map", "0:a:0",
+		"-ac", "1",
+		"-ar", fmt.Sprint(spectralSampleRate),
+		"-f", "s16le",
+		"-",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("decode for spectral check: %w", err)
+	}
+	samples := make([]float64, len(output)/2)
+	for i := range samples {
+		samples[i] = float64(int16(binary.LittleEndian.Uint16(output[2*i:]))) / 32768
+	}
+	return samples, nil
+}
+
+// spectralCeilingHz averages the power spectrum of Hann-windowed frames and
+// returns the highest frequency whose smoothed power is within
+// spectralFloorDB of the loudest bin.
+func spectralCeilingHz(samples []float64, sampleRate int) (float64, error) {
+	frames := len(samples) / spectralFrameSize
+	if frames == 0 {
+		return 0, fmt.Errorf("too little audio for a spectral check")
+	}
+	bins := spectralFrameSize / 2
+	power := make([]float64, bins)
+	window := make([]float64, spectralFrameSize)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(spectralFrameSize-1))
+	}
+	frame := make([]complex128, spectralFrameSize)
+	for f := 0; f < frames; f++ {
+		offset := f * spectralFrameSize
+		for i := range frame {
+			frame[i] = complex(samples[offset+i]*window[i], 0)
+		}
+		fft(frame)
+		for i := range power {
+			magnitude := cmplx.Abs(frame[i])
+			power[i] += magnitude * magnitude
+		}
+	}
+
+	smoothed := make([]float64, bins)
+	peak := 0.0
+	for i := range smoothed {
+		lo, hi := i-spectralSmoothBins/2, i+spectralSmoothBins/2
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > bins {
+			hi = bins
+		}
+		sum := 0.0
+		for _, value := range power[lo:hi] {
+			sum += value
+		}
+		smoothed[i] = sum / float64(hi-lo)
+		if smoothed[i] > peak {
+			peak = smoothed[i]
+		}
+	}
+	if peak <= 0 {
+		return 0, fmt.Errorf("audio is silent; no spectral check possible")
+	}
+	floor := peak * math.Pow(10, -spectralFloorDB/10)
+	binHz := float64(sampleRate) / float64(spectralFrameSize)
+	for i := bins - 1; i > 0; i-- {
+		if smoothed[i] >= floor {
+			return float64(i) * binHz, nil
+		}
+	}
+	return 0, nil
+}
+
+// fft is an in-place iterative radix-2 FFT; len(values) must be a power of
+// two.
+func fft(values []complex128) {
+	n := len(values)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			values[i], values[j] = values[j], values[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even := values[start+k]
+				odd := values[start+k+size/2] * w
+				values[start+k] = even + odd
+				values[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
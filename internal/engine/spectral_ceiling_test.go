@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func synthesizeTones(seconds float64, frequencies ...float64) []float64 {
+	samples := make([]float64, int(seconds*spectralSampleRate))
+	for i := range samples {
+		at := float64(i) / spectralSampleRate
+		for _, frequency := range frequencies {
+			samples[i] += 0.2 * math.Sin(2*math.Pi*frequency*at)
+		}
+	}
+	return samples
+}
+
+func TestProbeSpectralCeilingFlagsLowpassedAudio(t *testing.T) {
+	restore := decodeSpectralSamplesFn
+	t.Cleanup(func() { decodeSpectralSamplesFn = restore })
+
+	cases := []struct {
+		name    string
+		tones   []float64
+		suspect bool
+	}{
+		{"full band", []float64{440, 5000, 12000, 19500}, false},
+		{"128k lowpass", []float64{440, 5000, 12000, 15500}, true},
+	}
+	for _, tc := range cases {
+		samples := synthesizeTones(2, tc.tones...)
+		decodeSpectralSamplesFn = func(ctx context.Context, path string) ([]float64, error) {
+			return samples, nil
+		}
+		ceiling, err := ProbeSpectralCeiling(context.Background(), "track.wav")
+		if err != nil {
+			t.Fatalf("%s: probe: %v", tc.name, err)
+		}
+		top := tc.tones[len(tc.tones)-1]
+		if ceiling.Suspect != tc.suspect || math.Abs(ceiling.CeilingHz-top) > 500 {
+			t.Fatalf("%s: unexpected ceiling %+v for top tone %.0f Hz", tc.name, ceiling, top)
+		}
+	}
+
+	decodeSpectralSamplesFn = func(ctx context.Context, path string) ([]float64, error) {
+		return make([]float64, spectralSampleRate), nil
+	}
+	if _, err := ProbeSpectralCeiling(context.Background(), "silence.wav"); err == nil {
+		t.Fatalf("expected silent audio to fail the check")
+	}
+}
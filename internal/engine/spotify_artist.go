@@ -0,0 +1,325 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/httpcache"
+)
+
+const (
+	spotifyArtistAlbumsPageSize = 50
+	spotifyAlbumsBatchSize      = 20
+	spotifyAPIMaxPages          = 200
+)
+
+var (
+	spotifyAPIBaseURL    = "https://api.spotify.com"
+	spotifyAPIHTTPClient = &http.Client{Timeout: 20 * time.Second}
+)
+
+type spotifyAPIArtist struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type spotifyAPIAlbumTrack struct {
+	ID           string             `json:"id"`
+	Name         string             `json:"name"`
+	Artists      []spotifyAPIArtist `json:"artists"`
+	ExternalURLs map[string]string  `json:"external_urls"`
+	DurationMS   int64              `json:"duration_ms"`
+	TrackNumber  int                `json:"track_number"`
+	DiscNumber   int                `json:"disc_number"`
+}
+
+type spotifyAPIAlbumTrackPage struct {
+	Items []spotifyAPIAlbumTrack `json:"items"`
+	Next  string                 `json:"next"`
+}
+
+type spotifyAPIArtistAlbum struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	AlbumGroup  string `json:"album_group"`
+	ReleaseDate string `json:"release_date"`
+	// artistID is the artist whose releases listed the album.
+	artistID string
+}
+
+type spotifyAPIArtistAlbumPage struct {
+	Items []spotifyAPIArtistAlbum `json:"items"`
+	Next  string                  `json:"next"`
+}
+
+type spotifyAPIAlbum struct {
+	ID          string                   `json:"id"`
+	Name        string                   `json:"name"`
+	AlbumType   string                   `json:"album_type"`
+	Artists     []spotifyAPIArtist       `json:"artists"`
+	TotalTracks int                      `json:"total_tracks"`
+	Tracks      spotifyAPIAlbumTrackPage `json:"tracks"`
+}
+
+type spotifyAPIAlbumsResponse struct {
+	Albums []*spotifyAPIAlbum `json:"albums"`
+}
+
+// enumerateSpotifyTracks lists a Spotify source: artist discographies and
+// the user's library go through the Web API, everything else through the
+// playlist enumeration.
+func enumerateSpotifyTracks(
+	ctx context.Context,
+	source config.Source,
+	creds auth.SpotifyCredentials,
+) ([]spotifyRemoteTrack, error) {
+	if config.IsSpotifyUserLibraryURL(source.URL) {
+		return enumerateSpotifyUserLibraryTracks(ctx, source, time.Now())
+	}
+	if config.IsSpotifyArtistURL(source.URL) {
+		return enumerateSpotifyArtistTracks(ctx, source, creds)
+	}
+	return enumerateSpotifyPlaylistTracks(ctx, source, creds)
+}
+
+// enumerateSpotifyArtistTracks lists every track on the artist's releases in
+// sync.include_groups, newest release first as the API returns them. Tracks
+// on appears_on and compilation releases are kept only when the artist is
+// credited on the track itself.
+func enumerateSpotifyArtistTracks(
+	ctx context.Context,
+	source config.Source,
+	creds auth.SpotifyCredentials,
+) ([]spotifyRemoteTrack, error) {
+	artistID, err := resolveSpotifyArtistID(source.URL)
+	if err != nil {
+		return nil, err
+	}
+	token, err := fetchSpotifyAccessToken(ctx, creds)
+	if err != nil {
+		return nil, err
+	}
+	return enumerateSpotifyArtistTracksWithToken(ctx, artistID, config.SpotifyArtistIncludeGroups(source), token)
+}
+
+func enumerateSpotifyArtistTracksWithToken(
+	ctx context.Context,
+	artistID string,
+	includeGroups []string,
+	token string,
+) ([]spotifyRemoteTrack, error) {
+	albums, err := listSpotifyArtistAlbums(ctx, artistID, includeGroups, token)
+	if err != nil {
+		return nil, err
+	}
+	return collectSpotifyReleaseTracks(ctx, albums, token)
+}
+
+// listSpotifyArtistAlbums returns the artist's releases in includeGroups in
+// the order the API lists them.
+func listSpotifyArtistAlbums(ctx context.Context, artistID string, includeGroups []string, token string) ([]spotifyAPIArtistAlbum, error) {
+	base := strings.TrimSuffix(spotifyAPIBaseURL, "/")
+	query := url.Values{
+		"include_groups": {strings.Join(includeGroups, ",")},
+		"limit":          {fmt.Sprintf("%d", spotifyArtistAlbumsPageSize)},
+	}
+	nextURL := base + "/v1/artists/" + artistID + "/albums?" + query.Encode()
+	albums := []spotifyAPIArtistAlbum{}
+	seenAlbums := map[string]struct{}{}
+	for page := 0; strings.TrimSpace(nextURL) != "" && page < spotifyAPIMaxPages; page++ {
+		var payload spotifyAPIArtistAlbumPage
+		if err := getSpotifyJSON(ctx, nextURL, token, &payload); err != nil {
+			return nil, fmt.Errorf("spotify artist albums request failed: %w", err)
+		}
+		for _, album := range payload.Items {
+			id := strings.TrimSpace(album.ID)
+			if id == "" {
+				continue
+			}
+			if _, exists := seenAlbums[id]; exists {
+				continue
+			}
+			seenAlbums[id] = struct{}{}
+			album.artistID = artistID
+			albums = append(albums, album)
+		}
+		nextURL = strings.TrimSpace(payload.Next)
+	}
+	return albums, nil
+}
+
+// collectSpotifyReleaseTracks fetches the albums in batches and lists their
+// tracks in album order. On appears_on and compilation releases only tracks
+// crediting the artist the album was listed for are kept.
+func collectSpotifyReleaseTracks(ctx context.Context, albums []spotifyAPIArtistAlbum, token string) ([]spotifyRemoteTrack, error) {
+	base := strings.TrimSuffix(spotifyAPIBaseURL, "/")
+	tracks := make([]spotifyRemoteTrack, 0, len(albums)*8)
+	seenTracks := map[string]struct{}{}
+	for start := 0; start < len(albums); start += spotifyAlbumsBatchSize {
+		end := start + spotifyAlbumsBatchSize
+		if end > len(albums) {
+			end = len(albums)
+		}
+		ids := make([]string, 0, end-start)
+		groups := map[string]string{}
+		artists := map[string]string{}
+		for _, album := range albums[start:end] {
+			ids = append(ids, album.ID)
+			groups[album.ID] = strings.ToLower(strings.TrimSpace(album.AlbumGroup))
+			artists[album.ID] = album.artistID
+		}
+
+		var payload spotifyAPIAlbumsResponse
+		if err := getSpotifyJSON(ctx, base+"/v1/albums?ids="+strings.Join(ids, ","), token, &payload); err != nil {
+			return nil, fmt.Errorf("spotify albums request failed: %w", err)
+		}
+		for _, album := range payload.Albums {
+			if album == nil {
+				continue
+			}
+			items, err := collectSpotifyAlbumTracks(ctx, album, token)
+			if err != nil {
+				return nil, err
+			}
+			group := groups[album.ID]
+			creditedOnly := group == config.SpotifyAlbumGroupAppearsOn || group == config.SpotifyAlbumGroupCompilation
+			compilation := group == config.SpotifyAlbumGroupCompilation || isSpotifyCompilation(album.AlbumType, album.Artists)
+			discTotal := 0
+			for _, item := range items {
+				if item.DiscNumber > discTotal {
+					discTotal = item.DiscNumber
+				}
+			}
+			for _, item := range items {
+				id := extractSpotifyTrackID(item.ID)
+				if id == "" {
+					continue
+				}
+				if _, exists := seenTracks[id]; exists {
+					continue
+				}
+				if creditedOnly && !spotifyTrackCreditsArtist(item, artists[album.ID]) {
+					continue
+				}
+				seenTracks[id] = struct{}{}
+				track := item.remote(id, album.Name)
+				track.Release = spotifyTrackRelease{
+					Compilation: compilation,
+					TrackNumber: item.TrackNumber,
+					TrackTotal:  album.TotalTracks,
+					DiscNumber:  item.DiscNumber,
+					DiscTotal:   discTotal,
+				}
+				tracks = append(tracks, track)
+			}
+		}
+	}
+	return tracks, nil
+}
+
+// collectSpotifyAlbumTracks returns the album's embedded first track page
+// plus any further pages; only albums with more than 50 tracks need them.
+func collectSpotifyAlbumTracks(ctx context.Context, album *spotifyAPIAlbum, token string) ([]spotifyAPIAlbumTrack, error) {
+	items := append([]spotifyAPIAlbumTrack(nil), album.Tracks.Items...)
+	nextURL := strings.TrimSpace(album.Tracks.Next)
+	for page := 0; nextURL != "" && page < spotifyAPIMaxPages; page++ {
+		var payload spotifyAPIAlbumTrackPage
+		if err := getSpotifyJSON(ctx, nextURL, token, &payload); err != nil {
+			return nil, fmt.Errorf("spotify album %s tracks request failed: %w", album.ID, err)
+		}
+		items = append(items, payload.Items...)
+		nextURL = strings.TrimSpace(payload.Next)
+	}
+	return items, nil
+}
+
+func spotifyTrackCreditsArtist(track spotifyAPIAlbumTrack, artistID string) bool {
+	for _, artist := range track.Artists {
+		if strings.TrimSpace(artist.ID) == artistID {
+			return true
+		}
+	}
+	return false
+}
+
+func (t spotifyAPIAlbumTrack) remote(id string, album string) spotifyRemoteTrack {
+	artist := ""
+	if len(t.Artists) > 0 {
+		artist = strings.TrimSpace(t.Artists[0].Name)
+	}
+	trackURL := spotifyTrackURL(id)
+	if t.ExternalURLs != nil && strings.TrimSpace(t.ExternalURLs["spotify"]) != "" {
+		trackURL = strings.TrimSpace(t.ExternalURLs["spotify"])
+	}
+	return spotifyRemoteTrack{
+		ID:         id,
+		Title:      strings.TrimSpace(t.Name),
+		Artist:     artist,
+		Album:      strings.TrimSpace(album),
+		URL:        trackURL,
+		DurationMS: t.DurationMS,
+	}
+}
+
+func getSpotifyJSON(ctx context.Context, rawURL string, token string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpcache.Client(ctx, spotifyAPIHTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+func resolveSpotifyArtistID(rawURL string) (string, error) {
+	trimmed := strings.TrimSpace(rawURL)
+	if strings.HasPrefix(strings.ToLower(trimmed), "spotify:artist:") {
+		id := strings.TrimSpace(trimmed[len("spotify:artist:"):])
+		if !spotifyIDPattern.MatchString(id) {
+			return "", fmt.Errorf("invalid spotify artist id in url %q", rawURL)
+		}
+		return id, nil
+	}
+
+	parsed, err := url.Parse(trimmed)
+	if err != nil {
+		return "", fmt.Errorf("parse spotify url %q: %w", rawURL, err)
+	}
+	parts := strings.Split(strings.Trim(path.Clean(parsed.Path), "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		if parts[i] != "artist" {
+			continue
+		}
+		if !spotifyIDPattern.MatchString(parts[i+1]) {
+			break
+		}
+		return parts[i+1], nil
+	}
+	return "", fmt.Errorf("spotify artist id not found in url %q", rawURL)
+}
+
+// spotifyAlbumDirName turns an album title into a single path component for
+// per-album output folders; it returns "" when nothing usable is left.
+func spotifyAlbumDirName(album string) string {
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+	name := strings.TrimSpace(replacer.Replace(album))
+	return strings.Trim(name, ". ")
+}
@@ -0,0 +1,287 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestResolveSpotifyArtistID(t *testing.T) {
+	for raw, want := range map[string]string{
+		"spotify:artist:4tZwfgrHOc3mvqYlEYSvVi":                          "4tZwfgrHOc3mvqYlEYSvVi",
+		"https://open.spotify.com/artist/4tZwfgrHOc3mvqYlEYSvVi?si=abc":  "4tZwfgrHOc3mvqYlEYSvVi",
+		"https://open.spotify.com/intl-de/artist/4tZwfgrHOc3mvqYlEYSvVi": "4tZwfgrHOc3mvqYlEYSvVi",
+	} {
+		got, err := resolveSpotifyArtistID(raw)
+		if err != nil || got != want {
+			t.Fatalf("resolve %s: got %q err=%v", raw, got, err)
+		}
+	}
+	if _, err := resolveSpotifyArtistID("https://open.spotify.com/playlist/37i9dQZF1DXcBWIGoYBM5M"); err == nil {
+		t.Fatalf("expected playlist link to be rejected")
+	}
+}
+
+func TestEnumerateSpotifyArtistTracksFiltersGroupsAndPages(t *testing.T) {
+	const artistID = "artist0000001"
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("unexpected authorization header %q", got)
+		}
+		switch {
+		case r.URL.Path == "/v1/artists/"+artistID+"/albums" && r.URL.Query().Get("offset") == "":
+			if got := r.URL.Query().Get("include_groups"); got != "album,appears_on" {
+				t.Errorf("unexpected include_groups %q", got)
+			}
+			fmt.Fprintf(w, `{"items":[{"id":"albumA000001","name":"Album A","album_group":"album"}],"next":"%s/v1/artists/%s/albums?offset=1"}`, server.URL, artistID)
+		case r.URL.Path == "/v1/artists/"+artistID+"/albums":
+			fmt.Fprint(w, `{"items":[{"id":"albumB000001","name":"Various: Hits","album_group":"appears_on"}],"next":null}`)
+		case r.URL.Path == "/v1/albums":
+			if got := r.URL.Query().Get("ids"); got != "albumA000001,albumB000001" {
+				t.Errorf("unexpected album ids %q", got)
+			}
+			fmt.Fprintf(w, `{"albums":[
+				{"id":"albumA000001","name":"Album A","tracks":{"items":[
+					{"id":"trackA000001","name":"Intro","artists":[{"id":"%[1]s","name":"Artist"}]}
+				],"next":"%[2]s/v1/albums/albumA000001/tracks?offset=1"}},
+				{"id":"albumB000001","name":"Various: Hits","album_type":"album","artists":[{"id":"va","name":"Various Artists"}],"total_tracks":2,"tracks":{"items":[
+					{"id":"trackB000001","name":"Someone Else","artists":[{"id":"other0000001","name":"Other"}],"track_number":1,"disc_number":1},
+					{"id":"trackB000002","name":"Feature","artists":[{"id":"other0000001","name":"Other"},{"id":"%[1]s","name":"Artist"}],"track_number":1,"disc_number":2}
+				],"next":null}}
+			]}`, artistID, server.URL)
+		case r.URL.Path == "/v1/albums/albumA000001/tracks":
+			fmt.Fprintf(w, `{"items":[{"id":"trackA000002","name":"Outro","artists":[{"id":"%s","name":"Artist"}]}],"next":null}`, artistID)
+		default:
+			t.Errorf("unexpected request %s", r.URL.String())
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origBase := spotifyAPIBaseURL
+	t.Cleanup(func() { spotifyAPIBaseURL = origBase })
+	spotifyAPIBaseURL = server.URL
+
+	tracks, err := enumerateSpotifyArtistTracksWithToken(context.Background(), artistID, []string{"album", "appears_on"}, "token")
+	if err != nil {
+		t.Fatalf("enumerate: %v", err)
+	}
+	got := []string{}
+	for _, track := range tracks {
+		got = append(got, track.ID+"@"+track.Album)
+	}
+	want := "trackA000001@Album A,trackA000002@Album A,trackB000002@Various: Hits"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("expected %s, got %s", want, strings.Join(got, ","))
+	}
+	if tracks[2].Artist != "Other" || tracks[2].URL != spotifyTrackURL("trackB000002") {
+		t.Fatalf("unexpected credited track %+v", tracks[2])
+	}
+	if tracks[0].Release.Compilation {
+		t.Fatalf("expected the artist's own album not to be a compilation, got %+v", tracks[0].Release)
+	}
+	want2 := spotifyTrackRelease{Compilation: true, TrackNumber: 1, TrackTotal: 2, DiscNumber: 2, DiscTotal: 2}
+	if tracks[2].Release != want2 {
+		t.Fatalf("expected the Various Artists release to be a compilation %+v, got %+v", want2, tracks[2].Release)
+	}
+}
+
+type fakeDeemixPathAdapter struct{ fakeDeemixAdapter }
+
+func (a fakeDeemixPathAdapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (ExecSpec, error) {
+	spec, err := a.fakeDeemixAdapter.BuildExecSpec(source, defaults, timeout)
+	spec.Args = append(spec.Args, "--path", source.TargetDir)
+	return spec, err
+}
+
+func TestSyncerSpotifyArtistDeemixWritesPerAlbumFolders(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	runtimeDir := filepath.Join(tmp, "runtime")
+	for _, dir := range []string{targetDir, stateDir, runtimeDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:               "artist",
+				Type:             config.SourceTypeSpotify,
+				Enabled:          true,
+				TargetDir:        targetDir,
+				URL:              "https://open.spotify.com/artist/artist0000001",
+				StateFile:        "artist.sync.spotify",
+				DeemixRuntimeDir: runtimeDir,
+				Adapter:          config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		if !config.IsSpotifyArtistURL(source.URL) {
+			t.Errorf("expected artist source, got %q", source.URL)
+		}
+		return []spotifyRemoteTrack{
+			{ID: "1abc234def", Title: "track-1", Artist: "artist", Album: "EP/1: Demos"},
+			{ID: "2abc234def", Title: "track-2", Artist: "artist", Album: "LP"},
+		}, nil
+	}
+
+	runner := &sequenceRunner{}
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixPathAdapter{}},
+		runner,
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || len(runner.specs) != 2 {
+		t.Fatalf("expected two track executions, got result=%+v specs=%d", result, len(runner.specs))
+	}
+	for i, want := range []string{filepath.Join(targetDir, "EP_1_ Demos"), filepath.Join(targetDir, "LP")} {
+		if got := runner.specs[i].Args[len(runner.specs[i].Args)-1]; got != want {
+			t.Fatalf("expected track %d to target %s, got %s", i+1, want, got)
+		}
+	}
+}
+
+// albumFileRunner writes a track file into the --path folder
+// fakeDeemixPathAdapter passes, as deemix does.
+type albumFileRunner struct{ specs []ExecSpec }
+
+func (r *albumFileRunner) Run(ctx context.Context, spec ExecSpec) ExecResult {
+	r.specs = append(r.specs, spec)
+	dir := spec.Args[len(spec.Args)-1]
+	url := spec.Args[0]
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return ExecResult{ExitCode: 1, StderrTail: err.Error()}
+	}
+	if err := os.WriteFile(filepath.Join(dir, url[strings.LastIndex(url, "/")+1:]+".mp3"), []byte("audio"), 0o644); err != nil {
+		return ExecResult{ExitCode: 1, StderrTail: err.Error()}
+	}
+	return ExecResult{ExitCode: 0}
+}
+
+func TestSyncerSpotifyDeemixFilesCompilationsSeparately(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	runtimeDir := filepath.Join(tmp, "runtime")
+	for _, dir := range []string{targetDir, stateDir, runtimeDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+
+	enabled := true
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:               "artist",
+				Type:             config.SourceTypeSpotify,
+				Enabled:          true,
+				TargetDir:        targetDir,
+				URL:              "https://open.spotify.com/artist/artist0000001",
+				StateFile:        "artist.sync.spotify",
+				DeemixRuntimeDir: runtimeDir,
+				Sync:             config.SyncPolicy{Compilations: &enabled},
+				Adapter:          config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateSpotifyTracksFn
+	origTag := writeCompilationTagsFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateSpotifyTracksFn = origEnumerate
+		writeCompilationTagsFn = origTag
+	})
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		return auth.SpotifyCredentials{ClientID: "id", ClientSecret: "secret"}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	compilation := spotifyTrackRelease{Compilation: true, TrackNumber: 7, TrackTotal: 20, DiscNumber: 2, DiscTotal: 2}
+	enumerateSpotifyTracksFn = func(ctx context.Context, source config.Source, creds auth.SpotifyCredentials) ([]spotifyRemoteTrack, error) {
+		return []spotifyRemoteTrack{
+			{ID: "1abc234def", Title: "track-1", Artist: "artist", Album: "LP", Release: spotifyTrackRelease{TrackNumber: 1}},
+			{ID: "2abc234def", Title: "track-2", Artist: "artist", Album: "Club Hits 2024", Release: compilation},
+		}, nil
+	}
+	tagged := map[string]spotifyTrackRelease{}
+	writeCompilationTagsFn = func(ctx context.Context, path string, release spotifyTrackRelease) error {
+		tagged[path] = release
+		return nil
+	}
+
+	runner := &albumFileRunner{}
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixPathAdapter{}},
+		runner,
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || len(runner.specs) != 2 {
+		t.Fatalf("expected two track executions, got result=%+v specs=%d", result, len(runner.specs))
+	}
+	compilationDir := filepath.Join(targetDir, "Compilations", "Club Hits 2024")
+	for i, want := range []string{filepath.Join(targetDir, "LP"), compilationDir} {
+		if got := runner.specs[i].Args[len(runner.specs[i].Args)-1]; got != want {
+			t.Fatalf("expected track %d to target %s, got %s", i+1, want, got)
+		}
+	}
+	if len(tagged) != 1 || tagged[filepath.Join(compilationDir, "2abc234def.mp3")] != compilation {
+		t.Fatalf("expected only the compilation track to be tagged with %+v, got %+v", compilation, tagged)
+	}
+}
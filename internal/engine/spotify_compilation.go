@@ -0,0 +1,89 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	// variousArtists is the album artist compilation tracks are tagged with,
+	// and the name Spotify credits various-artists releases to.
+	variousArtists = "Various Artists"
+	// compilationsDirName is the folder under target_dir compilation tracks
+	// are filed in, one subfolder per album.
+	compilationsDirName = "Compilations"
+)
+
+var writeCompilationTagsFn = writeCompilationTags
+
+// spotifyTrackRelease is where a track sits on its release. Totals are 0
+// when the listing does not say; playlist listings carry no disc total.
+type spotifyTrackRelease struct {
+	Compilation bool
+	TrackNumber int
+	TrackTotal  int
+	DiscNumber  int
+	DiscTotal   int
+}
+
+// isSpotifyCompilation reports whether a release is a compilation: Spotify
+// types it as one, or credits it to Various Artists.
+func isSpotifyCompilation(albumType string, albumArtists []spotifyAPIArtist) bool {
+	if strings.EqualFold(strings.TrimSpace(albumType), "compilation") {
+		return true
+	}
+	for _, artist := range albumArtists {
+		if strings.EqualFold(strings.TrimSpace(artist.Name), variousArtists) {
+			return true
+		}
+	}
+	return false
+}
+
+func compilationsEnabled(source config.Source) bool {
+	return source.Sync.Compilations != nil && *source.Sync.Compilations
+}
+
+// compilationTargetDir is <target_dir>/Compilations/<album> for a
+// compilation track, or "" when the album title leaves no usable folder name.
+func compilationTargetDir(targetDir string, album string) string {
+	albumDir := spotifyAlbumDirName(album)
+	if albumDir == "" {
+		return ""
+	}
+	return filepath.Join(targetDir, compilationsDirName, albumDir)
+}
+
+// writeCompilationTags remuxes path with the compilation flag, album artist
+// Various Artists, and the release's track and disc numbers, keeping the
+// streams and the other tags as they are.
+func writeCompilationTags(ctx context.Context, path string, release spotifyTrackRelease) error {
+	args := []string{
+		"-metadata", "compilation=1",
+		"-metadata", "album_artist=" + variousArtists,
+	}
+	if number := releasePosition(release.TrackNumber, release.TrackTotal); number != "" {
+		args = append(args, "-metadata", "track="+number)
+	}
+	if number := releasePosition(release.DiscNumber, release.DiscTotal); number != "" {
+		args = append(args, "-metadata", "disc="+number)
+	}
+	return remuxWithTags(ctx, path, args)
+}
+
+// releasePosition formats a track or disc number as "n/total", or "n" when
+// the total is unknown; it returns "" without a number.
+func releasePosition(number int, total int) string {
+	switch {
+	case number <= 0:
+		return ""
+	case total >= number:
+		return fmt.Sprintf("%d/%d", number, total)
+	default:
+		return fmt.Sprintf("%d", number)
+	}
+}
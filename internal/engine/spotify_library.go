@@ -0,0 +1,168 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	spotifySavedTracksPageSize     = 50
+	spotifyFollowedArtistsPageSize = 50
+	spotifyReleaseDateLayout       = "2006-01-02"
+)
+
+var resolveSpotifyUserAccessTokenFn = resolveSpotifyUserAccessToken
+
+// errSpotifyLoginRequired is returned for spotify:liked and
+// spotify:new-releases sources when there is no cached Spotify login.
+var errSpotifyLoginRequired = errors.New("no Spotify login cached; run `udl spotify-login` once")
+
+// resolveSpotifyUserAccessToken returns the access token of the login cached
+// in ~/.spotdl/.spotipy by `udl spotify-login` or spotdl --user-auth,
+// refreshing it first when it is about to expire.
+func resolveSpotifyUserAccessToken(ctx context.Context) (string, error) {
+	loggedIn, err := ensureSpotDLUserTokenFn(ctx)
+	if err != nil {
+		return "", fmt.Errorf("refresh spotify login: %w", err)
+	}
+	if !loggedIn {
+		return "", errSpotifyLoginRequired
+	}
+	path, err := auth.SpotDLUserTokenCachePath()
+	if err != nil {
+		return "", err
+	}
+	token, err := auth.LoadSpotifyUserToken(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(token.AccessToken), nil
+}
+
+// enumerateSpotifyUserLibraryTracks lists a spotify:liked or
+// spotify:new-releases source with the user's login.
+func enumerateSpotifyUserLibraryTracks(ctx context.Context, source config.Source, now time.Time) ([]spotifyRemoteTrack, error) {
+	token, err := resolveSpotifyUserAccessTokenFn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(strings.TrimSpace(source.URL), config.SpotifyNewReleasesURL) {
+		since := now.AddDate(0, 0, -config.SpotifyNewReleasesDays(source))
+		return enumerateSpotifyNewReleaseTracks(ctx, config.SpotifyArtistIncludeGroups(source), since, token)
+	}
+	return enumerateSpotifySavedTracks(ctx, token)
+}
+
+// enumerateSpotifySavedTracks lists the user's liked songs, most recently
+// saved first as the API returns them.
+func enumerateSpotifySavedTracks(ctx context.Context, token string) ([]spotifyRemoteTrack, error) {
+	base := strings.TrimSuffix(spotifyAPIBaseURL, "/")
+	nextURL := fmt.Sprintf("%s/v1/me/tracks?limit=%d", base, spotifySavedTracksPageSize)
+	tracks := []spotifyRemoteTrack{}
+	seen := map[string]struct{}{}
+	for page := 0; strings.TrimSpace(nextURL) != "" && page < spotifyAPIMaxPages; page++ {
+		var payload spotifyPlaylistTrackPage
+		if err := getSpotifyJSON(ctx, nextURL, token, &payload); err != nil {
+			return nil, fmt.Errorf("spotify saved tracks request failed: %w", err)
+		}
+		tracks = appendSpotifyPlaylistPageTracks(tracks, payload, seen)
+		nextURL = strings.TrimSpace(payload.Next)
+	}
+	return tracks, nil
+}
+
+// enumerateSpotifyNewReleaseTracks lists the tracks of releases in
+// includeGroups that artists the user follows put out on or after since,
+// newest release first.
+func enumerateSpotifyNewReleaseTracks(ctx context.Context, includeGroups []string, since time.Time, token string) ([]spotifyRemoteTrack, error) {
+	artistIDs, err := listSpotifyFollowedArtists(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := since.Format(spotifyReleaseDateLayout)
+	releases := []spotifyAPIArtistAlbum{}
+	seen := map[string]struct{}{}
+	for _, artistID := range artistIDs {
+		albums, err := listSpotifyArtistAlbums(ctx, artistID, includeGroups, token)
+		if err != nil {
+			return nil, err
+		}
+		for _, album := range albums {
+			if _, ok := seen[album.ID]; ok {
+				continue
+			}
+			if spotifyReleaseDay(album.ReleaseDate) < cutoff {
+				continue
+			}
+			seen[album.ID] = struct{}{}
+			releases = append(releases, album)
+		}
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		return spotifyReleaseDay(releases[i].ReleaseDate) > spotifyReleaseDay(releases[j].ReleaseDate)
+	})
+	return collectSpotifyReleaseTracks(ctx, releases, token)
+}
+
+// listSpotifyFollowedArtists returns the ids of the artists the user follows.
+func listSpotifyFollowedArtists(ctx context.Context, token string) ([]string, error) {
+	base := strings.TrimSuffix(spotifyAPIBaseURL, "/")
+	query := url.Values{
+		"type":  {"artist"},
+		"limit": {fmt.Sprintf("%d", spotifyFollowedArtistsPageSize)},
+	}
+	nextURL := base + "/v1/me/following?" + query.Encode()
+	ids := []string{}
+	for page := 0; strings.TrimSpace(nextURL) != "" && page < spotifyAPIMaxPages; page++ {
+		var payload struct {
+			Artists struct {
+				Items []spotifyAPIArtist `json:"items"`
+				Next  string             `json:"next"`
+			} `json:"artists"`
+		}
+		if err := getSpotifyJSON(ctx, nextURL, token, &payload); err != nil {
+			return nil, fmt.Errorf("spotify followed artists request failed: %w", err)
+		}
+		for _, artist := range payload.Artists.Items {
+			if id := strings.TrimSpace(artist.ID); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		nextURL = strings.TrimSpace(payload.Artists.Next)
+	}
+	return ids, nil
+}
+
+// spotifyReleaseDay pads a release_date of year or month precision ("2026",
+// "2026-10") to a day so dates compare as strings.
+func spotifyReleaseDay(raw string) string {
+	day := strings.TrimSpace(raw)
+	switch len(day) {
+	case 4:
+		return day + "-01-01"
+	case 7:
+		return day + "-01"
+	}
+	return day
+}
+
+// spotifySourceKind names what a Spotify source lists, for messages.
+func spotifySourceKind(raw string) string {
+	switch {
+	case strings.EqualFold(strings.TrimSpace(raw), config.SpotifyLikedURL):
+		return "liked songs"
+	case strings.EqualFold(strings.TrimSpace(raw), config.SpotifyNewReleasesURL):
+		return "new releases"
+	case config.IsSpotifyArtistURL(raw):
+		return "artist"
+	}
+	return "playlist"
+}
@@ -0,0 +1,80 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestEnumerateSpotifyUserLibraryTracks(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer user-token" {
+			t.Errorf("unexpected authorization header %q", got)
+		}
+		switch {
+		case r.URL.Path == "/v1/me/tracks" && r.URL.Query().Get("offset") == "":
+			fmt.Fprintf(w, `{"items":[{"track":{"id":"liked0000001","name":"First","artists":[{"name":"A"}]}}],"next":"%s/v1/me/tracks?offset=50"}`, server.URL)
+		case r.URL.Path == "/v1/me/tracks":
+			fmt.Fprint(w, `{"items":[{"track":{"id":"liked0000002","name":"Second","artists":[{"name":"B"}]}},{"track":{"id":"liked0000001","name":"First","artists":[{"name":"A"}]}}],"next":null}`)
+		case r.URL.Path == "/v1/me/following":
+			if got := r.URL.Query().Get("type"); got != "artist" {
+				t.Errorf("unexpected following type %q", got)
+			}
+			fmt.Fprint(w, `{"artists":{"items":[{"id":"artist0000001"},{"id":"artist0000002"}],"next":null}}`)
+		case r.URL.Path == "/v1/artists/artist0000001/albums":
+			fmt.Fprint(w, `{"items":[
+				{"id":"albumOld0001","name":"Old","album_group":"album","release_date":"2026-08-01"},
+				{"id":"albumNew0001","name":"New","album_group":"single","release_date":"2026-10-01"}
+			],"next":null}`)
+		case r.URL.Path == "/v1/artists/artist0000002/albums":
+			fmt.Fprint(w, `{"items":[{"id":"albumNew0002","name":"Newer","album_group":"album","release_date":"2026-10-10"}],"next":null}`)
+		case r.URL.Path == "/v1/albums":
+			if got := r.URL.Query().Get("ids"); got != "albumNew0002,albumNew0001" {
+				t.Errorf("expected the recent releases newest first, got %q", got)
+			}
+			fmt.Fprint(w, `{"albums":[
+				{"id":"albumNew0002","name":"Newer","tracks":{"items":[{"id":"track0000002","name":"Two","artists":[{"id":"artist0000002","name":"Other"}]}],"next":null}},
+				{"id":"albumNew0001","name":"New","tracks":{"items":[{"id":"track0000001","name":"One","artists":[{"id":"artist0000001","name":"Artist"}]}],"next":null}}
+			]}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL.String())
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	origBase, origToken := spotifyAPIBaseURL, resolveSpotifyUserAccessTokenFn
+	t.Cleanup(func() { spotifyAPIBaseURL, resolveSpotifyUserAccessTokenFn = origBase, origToken })
+	spotifyAPIBaseURL = server.URL
+	resolveSpotifyUserAccessTokenFn = func(ctx context.Context) (string, error) { return "user-token", nil }
+
+	ids := func(tracks []spotifyRemoteTrack) string {
+		got := []string{}
+		for _, track := range tracks {
+			got = append(got, track.ID)
+		}
+		return strings.Join(got, ",")
+	}
+	now := time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC)
+
+	liked, err := enumerateSpotifyUserLibraryTracks(context.Background(), config.Source{URL: config.SpotifyLikedURL}, now)
+	if err != nil || ids(liked) != "liked0000001,liked0000002" {
+		t.Fatalf("unexpected liked songs %s (%v)", ids(liked), err)
+	}
+	releases, err := enumerateSpotifyUserLibraryTracks(context.Background(), config.Source{URL: config.SpotifyNewReleasesURL}, now)
+	if err != nil || ids(releases) != "track0000002,track0000001" {
+		t.Fatalf("unexpected new releases %s (%v)", ids(releases), err)
+	}
+
+	resolveSpotifyUserAccessTokenFn = func(ctx context.Context) (string, error) { return "", errSpotifyLoginRequired }
+	if _, err := enumerateSpotifyUserLibraryTracks(context.Background(), config.Source{URL: config.SpotifyLikedURL}, now); err != errSpotifyLoginRequired {
+		t.Fatalf("expected the missing login to surface, got %v", err)
+	}
+}
@@ -22,10 +22,11 @@ var (
 )
 
 type spotifyTrackMetadata struct {
-	Title  string
-	Artist string
-	Album  string
-	ISRC   string
+	Title   string
+	Artist  string
+	Album   string
+	ISRC    string
+	Release spotifyTrackRelease
 }
 
 func buildSpotifyTrackMetadataIndex(tracks []spotifyRemoteTrack) map[string]spotifyTrackMetadata {
@@ -39,9 +40,11 @@ func buildSpotifyTrackMetadataIndex(tracks []spotifyRemoteTrack) map[string]spot
 			continue
 		}
 		lookup[id] = spotifyTrackMetadata{
-			Title:  strings.TrimSpace(track.Title),
-			Artist: strings.TrimSpace(track.Artist),
-			Album:  strings.TrimSpace(track.Album),
+			Title:   strings.TrimSpace(track.Title),
+			Artist:  strings.TrimSpace(track.Artist),
+			Album:   strings.TrimSpace(track.Album),
+			ISRC:    strings.TrimSpace(track.ISRC),
+			Release: track.Release,
 		}
 	}
 	return lookup
@@ -80,10 +83,11 @@ func normalizeSpotifyTrackMetadata(metadata spotifyTrackMetadata) spotifyTrackMe
 		album = title
 	}
 	return spotifyTrackMetadata{
-		Title:  title,
-		Artist: artist,
-		Album:  album,
-		ISRC:   isrc,
+		Title:   title,
+		Artist:  artist,
+		Album:   album,
+		ISRC:    isrc,
+		Release: metadata.Release,
 	}
 }
 
@@ -17,6 +17,7 @@ import (
 
 	"github.com/jaa/update-downloads/internal/auth"
 	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/httpcache"
 )
 
 var spotifyIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{10,32}$`)
@@ -27,6 +28,10 @@ type spotifyRemoteTrack struct {
 	Artist string
 	Album  string
 	URL    string
+	ISRC   string
+	// DurationMS is the remote track length, 0 when the listing has none.
+	DurationMS int64
+	Release    spotifyTrackRelease
 }
 
 type spotifyTokenResponse struct {
@@ -43,9 +48,18 @@ type spotifyPlaylistTrackPage struct {
 				Name string `json:"name"`
 			} `json:"artists"`
 			Album *struct {
-				Name string `json:"name"`
+				Name        string             `json:"name"`
+				AlbumType   string             `json:"album_type"`
+				Artists     []spotifyAPIArtist `json:"artists"`
+				TotalTracks int                `json:"total_tracks"`
 			} `json:"album"`
 			ExternalURLs map[string]string `json:"external_urls"`
+			ExternalIDs  struct {
+				ISRC string `json:"isrc"`
+			} `json:"external_ids"`
+			DurationMS  int64 `json:"duration_ms"`
+			TrackNumber int   `json:"track_number"`
+			DiscNumber  int   `json:"disc_number"`
 		} `json:"track"`
 	} `json:"items"`
 	Next string `json:"next"`
@@ -86,7 +100,7 @@ func enumerateSpotifyPlaylistTracksWithToken(
 	playlistID string,
 	token string,
 ) ([]spotifyRemoteTrack, error) {
-	client := &http.Client{Timeout: 20 * time.Second}
+	client := httpcache.Client(ctx, &http.Client{Timeout: 20 * time.Second})
 	nextURL := fmt.Sprintf("https://api.spotify.com/v1/playlists/%s/tracks?limit=100", playlistID)
 	tracks := make([]spotifyRemoteTrack, 0, 256)
 	seen := map[string]struct{}{}
@@ -116,47 +130,59 @@ func enumerateSpotifyPlaylistTracksWithToken(
 			return nil, fmt.Errorf("decode spotify playlist response: %w", err)
 		}
 
-		for _, item := range page.Items {
-			if item.Track == nil {
-				continue
-			}
-			id := extractSpotifyTrackID(item.Track.ID)
-			if id == "" {
-				continue
-			}
-			if _, exists := seen[id]; exists {
-				continue
-			}
-			seen[id] = struct{}{}
+		tracks = appendSpotifyPlaylistPageTracks(tracks, page, seen)
+		nextURL = strings.TrimSpace(page.Next)
+	}
 
-			artist := ""
-			if len(item.Track.Artists) > 0 {
-				artist = strings.TrimSpace(item.Track.Artists[0].Name)
-			}
-			title := strings.TrimSpace(item.Track.Name)
-			album := ""
-			if item.Track.Album != nil {
-				album = strings.TrimSpace(item.Track.Album.Name)
-			}
+	return tracks, nil
+}
 
-			trackURL := spotifyTrackURL(id)
-			if item.Track.ExternalURLs != nil && strings.TrimSpace(item.Track.ExternalURLs["spotify"]) != "" {
-				trackURL = strings.TrimSpace(item.Track.ExternalURLs["spotify"])
-			}
+// appendSpotifyPlaylistPageTracks adds the tracks of one playlist-shaped page
+// (playlist items, saved tracks) not already in seen.
+func appendSpotifyPlaylistPageTracks(tracks []spotifyRemoteTrack, page spotifyPlaylistTrackPage, seen map[string]struct{}) []spotifyRemoteTrack {
+	for _, item := range page.Items {
+		if item.Track == nil {
+			continue
+		}
+		id := extractSpotifyTrackID(item.Track.ID)
+		if id == "" {
+			continue
+		}
+		if _, exists := seen[id]; exists {
+			continue
+		}
+		seen[id] = struct{}{}
 
-			tracks = append(tracks, spotifyRemoteTrack{
-				ID:     id,
-				Title:  title,
-				Artist: artist,
-				Album:  album,
-				URL:    trackURL,
-			})
+		artist := ""
+		if len(item.Track.Artists) > 0 {
+			artist = strings.TrimSpace(item.Track.Artists[0].Name)
+		}
+		title := strings.TrimSpace(item.Track.Name)
+		album := ""
+		release := spotifyTrackRelease{TrackNumber: item.Track.TrackNumber, DiscNumber: item.Track.DiscNumber}
+		if item.Track.Album != nil {
+			album = strings.TrimSpace(item.Track.Album.Name)
+			release.Compilation = isSpotifyCompilation(item.Track.Album.AlbumType, item.Track.Album.Artists)
+			release.TrackTotal = item.Track.Album.TotalTracks
 		}
 
-		nextURL = strings.TrimSpace(page.Next)
-	}
+		trackURL := spotifyTrackURL(id)
+		if item.Track.ExternalURLs != nil && strings.TrimSpace(item.Track.ExternalURLs["spotify"]) != "" {
+			trackURL = strings.TrimSpace(item.Track.ExternalURLs["spotify"])
+		}
 
-	return tracks, nil
+		tracks = append(tracks, spotifyRemoteTrack{
+			ID:         id,
+			Title:      title,
+			Artist:     artist,
+			Album:      album,
+			URL:        trackURL,
+			ISRC:       strings.TrimSpace(item.Track.ExternalIDs.ISRC),
+			DurationMS: item.Track.DurationMS,
+			Release:    release,
+		})
+	}
+	return tracks
 }
 
 func enumerateSpotifyPlaylistTracksViaPage(
@@ -170,7 +196,7 @@ func enumerateSpotifyPlaylistTracksViaPage(
 	}
 	req.Header.Set("User-Agent", "udl/spotify-deemix")
 
-	client := &http.Client{Timeout: 20 * time.Second}
+	client := httpcache.Client(ctx, &http.Client{Timeout: 20 * time.Second})
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("spotify playlist page request failed: %w", err)
@@ -373,6 +399,17 @@ func buildSpotifyPreflight(
 				planned = append(planned, track.ID)
 			}
 		}
+	case SoundCloudModeOnlyGaps:
+		// Unknown tracks above the first local one are new, not gaps.
+		for i, track := range remoteTracks {
+			if _, ok := archiveGapIDs[track.ID]; ok && firstExisting > 0 && i >= firstExisting {
+				planned = append(planned, track.ID)
+				continue
+			}
+			if _, ok := knownGapIDs[track.ID]; ok {
+				planned = append(planned, track.ID)
+			}
+		}
 	default:
 		limit := len(remoteTracks)
 		if firstExisting > 0 {
@@ -399,6 +436,15 @@ func buildSpotifyPreflight(
 		PlannedDownloadCount: len(planned),
 		Mode:                 mode,
 	}
+	plannedSet := make(map[string]struct{}, len(planned))
+	for _, id := range planned {
+		plannedSet[id] = struct{}{}
+	}
+	for _, track := range remoteTracks {
+		if _, ok := plannedSet[track.ID]; ok {
+			preflight.notePlannedDuration(track.DurationMS)
+		}
+	}
 	return preflight, archiveGapIDs, knownGapIDs, planned, existingKnownIDs
 }
 
@@ -450,8 +496,11 @@ func spotifyStateTrackPresent(targetDir string, entry spotifyStateEntry, consume
 		return false
 	}
 	abs := filepath.Join(root, filepath.FromSlash(localPath))
-	info, err := os.Stat(abs)
-	if err != nil || info.IsDir() {
+	if info, err := os.Stat(abs); err != nil {
+		if !remoteTargetListed(abs) {
+			return false
+		}
+	} else if info.IsDir() {
 		return false
 	}
 	if !isMediaExt(strings.ToLower(filepath.Ext(abs))) {
@@ -8,11 +8,33 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
+// spotifyStateHeader opens Spotify state files. v3 lines add adapter= and
+// added_at= to the v2 layout; v2 files, header-less files of bare IDs or
+// links, and "spotify <id>" lines are read as-is and rewritten as v3 on the
+// next append.
+const spotifyStateHeader = "# udl spotify state v3"
+
 type spotifyStateEntry struct {
 	DisplayName string
 	LocalPath   string
+	// Quality is the deemix quality tier the track was downloaded at, when
+	// the source sets a quality list.
+	Quality string
+	// Provider is the adapter.fallback kind that downloaded the track when
+	// deemix could not; empty for deemix downloads.
+	Provider string
+	// Adapter is the source's adapter.kind when the track was recorded.
+	Adapter string
+	// AddedAt is when the track was recorded; zero for entries written
+	// before state v3 or rebuilt by recover-state.
+	AddedAt time.Time
+}
+
+func (e spotifyStateEntry) isZero() bool {
+	return e.DisplayName == "" && e.LocalPath == "" && e.Quality == "" && e.Provider == "" && e.Adapter == "" && e.AddedAt.IsZero()
 }
 
 type spotifySyncState struct {
@@ -21,6 +43,12 @@ type spotifySyncState struct {
 }
 
 func parseSpotifySyncState(path string) (spotifySyncState, error) {
+	return parseTrackSyncState(path, parseSpotifyStateLine)
+}
+
+// parseTrackSyncState reads the tab-separated state files shared by the
+// deemix flows; parseLine extracts the catalog-specific track id.
+func parseTrackSyncState(path string, parseLine func(string) (string, spotifyStateEntry)) (spotifySyncState, error) {
 	state := spotifySyncState{
 		KnownIDs: map[string]struct{}{},
 		Entries:  map[string]spotifyStateEntry{},
@@ -35,18 +63,23 @@ func parseSpotifySyncState(path string) (spotifySyncState, error) {
 	}
 
 	scanner := bufio.NewScanner(bytes.NewReader(payload))
+	lineNo := 0
 	for scanner.Scan() {
+		lineNo++
 		line := strings.TrimSpace(scanner.Text())
+		if strings.ContainsRune(line, 0) {
+			return state, corruptStateFileError(path, lineNo, stateLineDropNUL)
+		}
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
 
-		id, entry := parseSpotifyStateLine(line)
+		id, entry := parseLine(line)
 		if id == "" {
 			continue
 		}
 		state.KnownIDs[id] = struct{}{}
-		if entry.DisplayName != "" || entry.LocalPath != "" {
+		if !entry.isZero() {
 			existing := state.Entries[id]
 			if entry.DisplayName != "" {
 				existing.DisplayName = entry.DisplayName
@@ -54,25 +87,45 @@ func parseSpotifySyncState(path string) (spotifySyncState, error) {
 			if entry.LocalPath != "" {
 				existing.LocalPath = entry.LocalPath
 			}
+			if entry.Quality != "" {
+				existing.Quality = entry.Quality
+			}
+			if entry.Provider != "" {
+				existing.Provider = entry.Provider
+			}
+			if entry.Adapter != "" {
+				existing.Adapter = entry.Adapter
+			}
+			if !entry.AddedAt.IsZero() {
+				existing.AddedAt = entry.AddedAt
+			}
 			state.Entries[id] = existing
 		}
 	}
 	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return state, corruptStateFileError(path, lineNo+1, stateLineDropTooLong)
+		}
 		return state, err
 	}
 	return state, nil
 }
 
 func appendSpotifySyncStateID(path string, id string) error {
-	return appendSpotifySyncStateEntry(path, id, "", "")
+	return appendSpotifySyncStateEntry(path, id, spotifyStateEntry{})
 }
 
-func appendSpotifySyncStateEntry(path string, id string, displayName string, localPath string) error {
+func appendSpotifySyncStateEntry(path string, id string, entry spotifyStateEntry) error {
 	trackID := extractSpotifyTrackID(id)
 	if trackID == "" {
 		return errors.New("spotify track id must not be empty")
 	}
+	return appendTrackSyncStateEntry(path, spotifyStateHeader, parseSpotifyStateLine, trackID, entry)
+}
 
+// appendTrackSyncStateEntry appends one track line, writing the header to a
+// new file and migrating an older one to the current header first.
+func appendTrackSyncStateEntry(path string, header string, parseLine func(string) (string, spotifyStateEntry), trackID string, entry spotifyStateEntry) error {
 	stateDir := filepath.Dir(path)
 	if err := os.MkdirAll(stateDir, 0o755); err != nil {
 		return err
@@ -87,6 +140,8 @@ func appendSpotifySyncStateEntry(path string, id string, displayName string, loc
 		}
 	} else if info.Size() == 0 {
 		writeHeader = true
+	} else if err := migrateTrackSyncStateFile(path, header, parseLine); err != nil {
+		return err
 	}
 
 	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
@@ -96,22 +151,73 @@ func appendSpotifySyncStateEntry(path string, id string, displayName string, loc
 	defer file.Close()
 
 	if writeHeader {
-		if _, err := file.WriteString("# udl spotify state v2\n"); err != nil {
+		if _, err := file.WriteString(header + "\n"); err != nil {
 			return err
 		}
 	}
 
+	_, err = file.WriteString(formatTrackSyncStateLine(trackID, entry) + "\n")
+	return err
+}
+
+// migrateTrackSyncStateFile rewrites a state file whose first line is not
+// header: older "# udl" headers are replaced, and every line parseLine
+// recognizes is rewritten in the current layout. Other comments and lines
+// parseLine does not recognize are kept verbatim, so nothing is lost.
+func migrateTrackSyncStateFile(path string, header string, parseLine func(string) (string, spotifyStateEntry)) error {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rawLines := strings.Split(strings.TrimRight(string(payload), "\n"), "\n")
+	if strings.TrimSpace(rawLines[0]) == header {
+		return nil
+	}
+	lines := []string{header}
+	for idx, raw := range rawLines {
+		line := strings.TrimSpace(raw)
+		switch {
+		case strings.ContainsRune(line, 0):
+			return corruptStateFileError(path, idx+1, stateLineDropNUL)
+		case line == "", strings.HasPrefix(line, "# udl "):
+			continue
+		case strings.HasPrefix(line, "#"):
+			lines = append(lines, line)
+			continue
+		}
+		id, entry := parseLine(line)
+		if id == "" {
+			lines = append(lines, line)
+			continue
+		}
+		lines = append(lines, formatTrackSyncStateLine(id, entry))
+	}
+	return writeSoundCloudLinesAtomically(path, ".udl-state-migrate-*.tmp", lines)
+}
+
+func formatTrackSyncStateLine(trackID string, entry spotifyStateEntry) string {
 	fields := []string{trackID}
-	title := strings.TrimSpace(displayName)
+	title := strings.TrimSpace(entry.DisplayName)
 	if title != "" {
 		fields = append(fields, "title="+encodeSpotifyStateValue(title))
 	}
-	normalizedPath := normalizeSpotifyStatePath(localPath)
+	normalizedPath := normalizeSpotifyStatePath(entry.LocalPath)
 	if normalizedPath != "" {
 		fields = append(fields, "path="+encodeSpotifyStateValue(normalizedPath))
 	}
-	_, err = file.WriteString(strings.Join(fields, "\t") + "\n")
-	return err
+	if tier := strings.TrimSpace(entry.Quality); tier != "" {
+		fields = append(fields, "quality="+encodeSpotifyStateValue(tier))
+	}
+	if kind := strings.TrimSpace(entry.Provider); kind != "" {
+		fields = append(fields, "provider="+encodeSpotifyStateValue(kind))
+	}
+	if kind := strings.TrimSpace(entry.Adapter); kind != "" {
+		fields = append(fields, "adapter="+encodeSpotifyStateValue(kind))
+	}
+	if !entry.AddedAt.IsZero() {
+		fields = append(fields, "added_at="+entry.AddedAt.UTC().Format(time.RFC3339))
+	}
+	return strings.Join(fields, "\t")
 }
 
 func parseSpotifyStateLine(line string) (string, spotifyStateEntry) {
@@ -135,20 +241,36 @@ func parseSpotifyStateLine(line string) (string, spotifyStateEntry) {
 	}
 
 	for _, field := range parts[1:] {
-		trimmed := strings.TrimSpace(field)
-		switch {
-		case strings.HasPrefix(trimmed, "title="):
-			entry.DisplayName = decodeSpotifyStateValue(strings.TrimPrefix(trimmed, "title="))
-		case strings.HasPrefix(trimmed, "path="):
-			entry.LocalPath = normalizeSpotifyStatePath(decodeSpotifyStateValue(strings.TrimPrefix(trimmed, "path=")))
-		case entry.DisplayName == "":
-			entry.DisplayName = trimmed
-		}
+		parseTrackStateField(&entry, field)
 	}
 
 	return id, entry
 }
 
+// parseTrackStateField reads one tab-separated field of a track state line
+// into entry. An unkeyed field is taken as the title, as v1 lines wrote it.
+func parseTrackStateField(entry *spotifyStateEntry, field string) {
+	trimmed := strings.TrimSpace(field)
+	switch {
+	case strings.HasPrefix(trimmed, "title="):
+		entry.DisplayName = decodeSpotifyStateValue(strings.TrimPrefix(trimmed, "title="))
+	case strings.HasPrefix(trimmed, "path="):
+		entry.LocalPath = normalizeSpotifyStatePath(decodeSpotifyStateValue(strings.TrimPrefix(trimmed, "path=")))
+	case strings.HasPrefix(trimmed, "quality="):
+		entry.Quality = decodeSpotifyStateValue(strings.TrimPrefix(trimmed, "quality="))
+	case strings.HasPrefix(trimmed, "provider="):
+		entry.Provider = decodeSpotifyStateValue(strings.TrimPrefix(trimmed, "provider="))
+	case strings.HasPrefix(trimmed, "adapter="):
+		entry.Adapter = decodeSpotifyStateValue(strings.TrimPrefix(trimmed, "adapter="))
+	case strings.HasPrefix(trimmed, "added_at="):
+		if addedAt, err := time.Parse(time.RFC3339, strings.TrimPrefix(trimmed, "added_at=")); err == nil {
+			entry.AddedAt = addedAt
+		}
+	case entry.DisplayName == "":
+		entry.DisplayName = trimmed
+	}
+}
+
 func encodeSpotifyStateValue(raw string) string {
 	return url.QueryEscape(strings.TrimSpace(raw))
 }
@@ -3,7 +3,9 @@ package engine
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseSpotifySyncState(t *testing.T) {
@@ -75,7 +77,7 @@ func TestAppendSpotifySyncStateEntry(t *testing.T) {
 	tmp := t.TempDir()
 	statePath := filepath.Join(tmp, "spotify.sync")
 
-	if err := appendSpotifySyncStateEntry(statePath, "41gXFhitx4whS6PsoXREzy", "Regent - Permean", "spotify/Regent - Permean.mp3"); err != nil {
+	if err := appendSpotifySyncStateEntry(statePath, "41gXFhitx4whS6PsoXREzy", spotifyStateEntry{DisplayName: "Regent - Permean", LocalPath: "spotify/Regent - Permean.mp3", Quality: "320"}); err != nil {
 		t.Fatalf("append entry: %v", err)
 	}
 
@@ -96,4 +98,100 @@ func TestAppendSpotifySyncStateEntry(t *testing.T) {
 	if entry.LocalPath != "spotify/Regent - Permean.mp3" {
 		t.Fatalf("unexpected local path %q", entry.LocalPath)
 	}
+	if entry.Quality != "320" {
+		t.Fatalf("unexpected quality %q", entry.Quality)
+	}
+}
+
+func TestAppendSpotifySyncStateEntryRecordsAdapterAndAddedAt(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "spotify.sync")
+	addedAt := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	if err := appendSpotifySyncStateEntry(statePath, "41gXFhitx4whS6PsoXREzy", spotifyStateEntry{
+		DisplayName: "Regent - Permean",
+		LocalPath:   "Regent - Permean.mp3",
+		Provider:    "spotdl",
+		Adapter:     "deemix",
+		AddedAt:     addedAt,
+	}); err != nil {
+		t.Fatalf("append entry: %v", err)
+	}
+
+	raw, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	if !strings.Contains(string(raw), "\tadapter=deemix\tadded_at=2026-03-04T05:06:07Z\n") {
+		t.Fatalf("expected adapter and added_at fields, got %q", string(raw))
+	}
+	state, err := parseSpotifySyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	entry := state.Entries["41gXFhitx4whS6PsoXREzy"]
+	if entry.Adapter != "deemix" || entry.Provider != "spotdl" || !entry.AddedAt.Equal(addedAt) {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+}
+
+func TestAppendSpotifySyncStateEntryMigratesOlderFormats(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "spotify.sync")
+	legacy := "# udl spotify state v2\n" +
+		"1abc234def\n" +
+		"spotify 2abc234def\n" +
+		"https://open.spotify.com/track/3abc234def?si=redacted\n" +
+		"4abc234def\ttitle=Regent+-+Permean\tpath=Regent%2FPermean.mp3\tquality=flac\n" +
+		"# kept by hand\n" +
+		"bad-line\n"
+	if err := os.WriteFile(statePath, []byte(legacy), 0o644); err != nil {
+		t.Fatalf("write state file: %v", err)
+	}
+
+	if err := appendSpotifySyncStateEntry(statePath, "5abc234def", spotifyStateEntry{DisplayName: "New"}); err != nil {
+		t.Fatalf("append entry: %v", err)
+	}
+
+	raw, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	want := spotifyStateHeader + "\n" +
+		"1abc234def\n" +
+		"2abc234def\n" +
+		"3abc234def\n" +
+		"4abc234def\ttitle=Regent+-+Permean\tpath=Regent%2FPermean.mp3\tquality=flac\n" +
+		"# kept by hand\n" +
+		"bad-line\n" +
+		"5abc234def\ttitle=New\n"
+	if string(raw) != want {
+		t.Fatalf("unexpected migrated state:\n%s\nwant:\n%s", raw, want)
+	}
+
+	if err := appendSpotifySyncStateID(statePath, "6abc234def"); err != nil {
+		t.Fatalf("append id: %v", err)
+	}
+	raw, err = os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	if string(raw) != want+"6abc234def\n" {
+		t.Fatalf("expected a v3 file to be appended to as-is, got:\n%s", raw)
+	}
+}
+
+func TestAppendDeezerSyncStateEntryMigratesV1Header(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "deezer.sync")
+	if err := os.WriteFile(statePath, []byte("# udl deezer state v1\ndeezer 3135556\n"), 0o644); err != nil {
+		t.Fatalf("write state file: %v", err)
+	}
+	if err := appendDeezerSyncStateEntry(statePath, "3135560", spotifyStateEntry{}); err != nil {
+		t.Fatalf("append entry: %v", err)
+	}
+	raw, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	if want := deezerStateHeader + "\n3135556\n3135560\n"; string(raw) != want {
+		t.Fatalf("unexpected migrated state %q, want %q", raw, want)
+	}
 }
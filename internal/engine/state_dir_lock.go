@@ -0,0 +1,42 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// stateDirLockFileName guards the state_dir files every source shares:
+// skipped-tracks.json, resume.json, remote-counts.json, dedupe-index.json
+// and source-errors.json. Source locks end in .udl-lock, so no source id
+// can collide with it.
+const stateDirLockFileName = "udl-state.lock"
+
+// stateDirLockMu queues this process's writers so they do not poll the lock
+// file against each other.
+var stateDirLockMu sync.Mutex
+
+// withStateDirLock runs one load-modify-save of a shared state_dir file
+// under <state_dir>/udl-state.lock, so concurrent udl processes do not drop
+// each other's updates. It waits for the holder: holders keep the lock for
+// a single small write, and a crashed holder's lock goes stale.
+func withStateDirLock(stateDir string, update func() error) error {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(root) {
+		return fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	stateDirLockMu.Lock()
+	defer stateDirLockMu.Unlock()
+	unlock, err := acquireLockFile(context.Background(), filepath.Join(root, stateDirLockFileName), func(int) error { return nil })
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return update()
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithStateDirLockWaitsForAnotherProcess(t *testing.T) {
+	stateDir := t.TempDir()
+	lockPath := filepath.Join(stateDir, stateDirLockFileName)
+	if err := os.WriteFile(lockPath, []byte("4242\n"), 0o644); err != nil {
+		t.Fatalf("write lock: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- updateSourceLastErrors(stateDir, map[string]SourceLastError{"sc": {Class: "network"}}, nil)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("expected update to wait for the held lock, got %v", err)
+	case <-time.After(2 * sourceLockPoll):
+	}
+	if err := os.Remove(lockPath); err != nil {
+		t.Fatalf("release lock: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	lastErrors, err := LoadSourceLastErrors(stateDir)
+	if err != nil || lastErrors["sc"].Class != "network" {
+		t.Fatalf("expected recorded last error, got %+v (err=%v)", lastErrors, err)
+	}
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the update to release the lock, stat err=%v", err)
+	}
+}
+
+func TestStateDirUpdatesKeepConcurrentWriters(t *testing.T) {
+	stateDir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sourceID := fmt.Sprintf("src-%d", i)
+			_ = updateSourceLastErrors(stateDir, map[string]SourceLastError{sourceID: {Class: "network"}}, nil)
+			_ = recordRemoteCount(stateDir, sourceID, remoteCount{Total: i + 1})
+		}(i)
+	}
+	wg.Wait()
+
+	lastErrors, err := LoadSourceLastErrors(stateDir)
+	if err != nil {
+		t.Fatalf("load last errors: %v", err)
+	}
+	counts, err := loadRemoteCounts(stateDir)
+	if err != nil {
+		t.Fatalf("load remote counts: %v", err)
+	}
+	if len(lastErrors) != 8 || len(counts) != 8 {
+		t.Fatalf("expected every writer's entry to survive, got %d last errors and %d counts", len(lastErrors), len(counts))
+	}
+}
@@ -0,0 +1,406 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// State-dir artifact classes, as named under retention in the config.
+const (
+	StateGCReports = "reports"
+	StateGCLogs    = "logs"
+	StateGCCaches  = "caches"
+	StateGCBackups = "backups"
+)
+
+// stateGCCacheFileSuffixes match the per-source cache files kept at the top
+// of state_dir; each is rebuilt on the next sync that needs it.
+var stateGCCacheFileSuffixes = []string{".local-index.json", ".sc-metadata.json", "metadata-providers.cache.json"}
+
+// StateGCItem is one artifact retention removes: a run report directory, a
+// trash directory, a cache file, or the expired records of a log file.
+type StateGCItem struct {
+	Class   string    `json:"class"`
+	Path    string    `json:"path"`
+	Bytes   int64     `json:"bytes"`
+	ModTime time.Time `json:"mod_time"`
+	Reason  string    `json:"reason"`
+	Records int       `json:"records,omitempty"`
+}
+
+// StateGCClass summarizes one artifact class before and after retention.
+type StateGCClass struct {
+	Class        string               `json:"class"`
+	Rule         config.RetentionRule `json:"rule"`
+	Artifacts    int                  `json:"artifacts"`
+	Bytes        int64                `json:"bytes"`
+	Removed      int                  `json:"removed"`
+	RemovedBytes int64                `json:"removed_bytes"`
+}
+
+// StateGCPlan is what cfg.Retention removes from state_dir at a point in time.
+type StateGCPlan struct {
+	StateDir string         `json:"state_dir"`
+	Classes  []StateGCClass `json:"classes"`
+	Items    []StateGCItem  `json:"items"`
+
+	// logRecords holds, per log file, the lines of the expired records.
+	logRecords map[string]map[string]struct{}
+}
+
+// RemovedBytes totals the space the plan frees.
+func (p StateGCPlan) RemovedBytes() int64 {
+	total := int64(0)
+	for _, class := range p.Classes {
+		total += class.RemovedBytes
+	}
+	return total
+}
+
+// stateArtifact is one unit retention keeps or removes. Log records carry
+// their file and line.
+type stateArtifact struct {
+	path    string
+	bytes   int64
+	modTime time.Time
+	line    string
+}
+
+// PlanStateGC lists the state_dir artifacts cfg.Retention removes at now.
+// State files, archives, credentials, managed tools, and the free-DL browser
+// profile are never considered.
+func PlanStateGC(cfg config.Config, now time.Time) (StateGCPlan, error) {
+	root, err := config.ExpandPath(cfg.Defaults.StateDir)
+	if err != nil {
+		return StateGCPlan{}, err
+	}
+	if !filepath.IsAbs(root) {
+		return StateGCPlan{}, fmt.Errorf("state_dir must resolve to an absolute path")
+	}
+	plan := StateGCPlan{StateDir: root, Classes: []StateGCClass{}, Items: []StateGCItem{}, logRecords: map[string]map[string]struct{}{}}
+	for _, class := range []struct {
+		name    string
+		rule    config.RetentionRule
+		collect func(string) []stateArtifact
+	}{
+		{StateGCReports, cfg.Retention.Reports, collectRunReportArtifacts},
+		{StateGCLogs, cfg.Retention.Logs, collectLogRecordArtifacts},
+		{StateGCCaches, cfg.Retention.Caches, collectCacheArtifacts},
+		{StateGCBackups, cfg.Retention.Backups, collectTrashArtifacts},
+	} {
+		artifacts := class.collect(root)
+		summary := StateGCClass{Class: class.name, Rule: class.rule, Artifacts: len(artifacts)}
+		for _, artifact := range artifacts {
+			summary.Bytes += artifact.bytes
+		}
+		for _, expired := range expiredStateArtifacts(artifacts, class.rule, now) {
+			summary.Removed++
+			summary.RemovedBytes += expired.artifact.bytes
+			if class.name != StateGCLogs {
+				plan.Items = append(plan.Items, StateGCItem{
+					Class:   class.name,
+					Path:    expired.artifact.path,
+					Bytes:   expired.artifact.bytes,
+					ModTime: expired.artifact.modTime,
+					Reason:  expired.reason,
+				})
+				continue
+			}
+			lines := plan.logRecords[expired.artifact.path]
+			if lines == nil {
+				lines = map[string]struct{}{}
+				plan.logRecords[expired.artifact.path] = lines
+			}
+			lines[expired.artifact.line] = struct{}{}
+			plan.addLogRecord(expired.artifact, expired.reason)
+		}
+		plan.Classes = append(plan.Classes, summary)
+	}
+	return plan, nil
+}
+
+// FormatByteSize renders n the way dry-run estimates do.
+func FormatByteSize(n int64) string {
+	return formatEstimateBytes(n)
+}
+
+// addLogRecord folds an expired log record into its file's item.
+func (p *StateGCPlan) addLogRecord(record stateArtifact, reason string) {
+	for i := range p.Items {
+		item := &p.Items[i]
+		if item.Class == StateGCLogs && item.Path == record.path {
+			item.Records++
+			item.Bytes += record.bytes
+			if record.modTime.After(item.ModTime) {
+				item.ModTime = record.modTime
+			}
+			if item.Reason != reason {
+				item.Reason = "max_age,max_size"
+			}
+			return
+		}
+	}
+	p.Items = append(p.Items, StateGCItem{
+		Class:   StateGCLogs,
+		Path:    record.path,
+		Bytes:   record.bytes,
+		ModTime: record.modTime,
+		Reason:  reason,
+		Records: 1,
+	})
+}
+
+type expiredStateArtifact struct {
+	artifact stateArtifact
+	reason   string
+}
+
+// expiredStateArtifacts applies rule to artifacts: past max_age_days they
+// go, then the oldest of the rest until the class fits max_size_mb. The
+// newest artifact always stays.
+func expiredStateArtifacts(artifacts []stateArtifact, rule config.RetentionRule, now time.Time) []expiredStateArtifact {
+	if len(artifacts) < 2 || (rule.MaxAgeDays <= 0 && rule.MaxSizeMB <= 0) {
+		return nil
+	}
+	sorted := append([]stateArtifact{}, artifacts...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].modTime.After(sorted[j].modTime)
+	})
+	maxAge := time.Duration(rule.MaxAgeDays) * 24 * time.Hour
+	maxBytes := int64(rule.MaxSizeMB) << 20
+	kept := sorted[0].bytes
+	overSize := false
+	expired := []expiredStateArtifact{}
+	for _, artifact := range sorted[1:] {
+		switch {
+		case maxAge > 0 && now.Sub(artifact.modTime) > maxAge:
+			expired = append(expired, expiredStateArtifact{artifact: artifact, reason: "max_age"})
+		case maxBytes > 0 && (overSize || kept+artifact.bytes > maxBytes):
+			overSize = true
+			expired = append(expired, expiredStateArtifact{artifact: artifact, reason: "max_size"})
+		default:
+			kept += artifact.bytes
+		}
+	}
+	return expired
+}
+
+// ApplyStateGC removes what plan lists. Log files are rewritten without
+// their expired records; trash folders left empty are removed too. It stops
+// at the first failure and reports how many items it removed.
+func ApplyStateGC(plan StateGCPlan) (int, error) {
+	removed := 0
+	for _, item := range plan.Items {
+		if item.Class == StateGCLogs {
+			if err := dropLogRecords(item.Path, plan.logRecords[item.Path]); err != nil {
+				return removed, fmt.Errorf("trim %s: %w", item.Path, err)
+			}
+			removed++
+			continue
+		}
+		if err := os.RemoveAll(item.Path); err != nil {
+			return removed, fmt.Errorf("remove %s: %w", item.Path, err)
+		}
+		removed++
+		if item.Class == StateGCBackups {
+			// Drops trash/<source> or quarantine/<source> once its last stamp
+			// folder is gone; fails harmlessly while others remain.
+			_ = os.Remove(filepath.Dir(item.Path))
+		}
+	}
+	return removed, nil
+}
+
+// dropLogRecords rewrites path without lines. Records are matched by content
+// rather than position, so lines appended or pruned since the plan was made
+// are left alone.
+func dropLogRecords(path string, lines map[string]struct{}) error {
+	payload, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	kept := []string{}
+	for _, line := range strings.Split(strings.TrimRight(string(payload), "\n"), "\n") {
+		if _, drop := lines[strings.TrimSpace(line)]; drop {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return writeSoundCloudLinesAtomically(path, ".udl-gc-*", kept)
+}
+
+func collectRunReportArtifacts(root string) []stateArtifact {
+	return collectDirArtifacts(filepath.Join(root, runReportsDirName))
+}
+
+// collectTrashArtifacts lists the stamp folders of trash/<source> and
+// quarantine/<source>; both hold files a run moved aside rather than deleted.
+func collectTrashArtifacts(root string) []stateArtifact {
+	artifacts := []stateArtifact{}
+	for _, dirName := range []string{"trash", quarantineDirName} {
+		sources, err := os.ReadDir(filepath.Join(root, dirName))
+		if err != nil {
+			continue
+		}
+		for _, source := range sources {
+			if source.IsDir() {
+				artifacts = append(artifacts, collectDirArtifacts(filepath.Join(root, dirName, source.Name()))...)
+			}
+		}
+	}
+	return artifacts
+}
+
+// collectDirArtifacts lists the subdirectories of dir, each sized by its
+// files and dated by its own modification time.
+func collectDirArtifacts(dir string) []stateArtifact {
+	artifacts := []stateArtifact{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return artifacts
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		artifacts = append(artifacts, stateArtifact{path: path, bytes: treeSize(path), modTime: info.ModTime()})
+	}
+	return artifacts
+}
+
+func treeSize(root string) int64 {
+	total := int64(0)
+	_ = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
+
+// collectCacheArtifacts lists the files of the HTTP and doctor caches and the
+// per-source cache files at the top of state_dir.
+func collectCacheArtifacts(root string) []stateArtifact {
+	artifacts := []stateArtifact{}
+	for _, dir := range []string{filepath.Join(root, httpCacheDirName), filepath.Join(root, "doctor")} {
+		_ = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || !entry.Type().IsRegular() {
+				return nil
+			}
+			if info, err := entry.Info(); err == nil {
+				artifacts = append(artifacts, stateArtifact{path: path, bytes: info.Size(), modTime: info.ModTime()})
+			}
+			return nil
+		})
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return artifacts
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !hasAnySuffix(entry.Name(), stateGCCacheFileSuffixes) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			artifacts = append(artifacts, stateArtifact{path: filepath.Join(root, entry.Name()), bytes: info.Size(), modTime: info.ModTime()})
+		}
+	}
+	return artifacts
+}
+
+func hasAnySuffix(name string, suffixes []string) bool {
+	for _, suffix := range suffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectLogRecordArtifacts lists the records of the state_dir journals,
+// dated by their timestamp (failure log) or finished_at (history). Lines
+// without a readable time are never removed.
+func collectLogRecordArtifacts(root string) []stateArtifact {
+	artifacts := []stateArtifact{}
+	failureLog, _ := output.SyncFailureLogPath(root)
+	for _, path := range []string{failureLog, filepath.Join(root, historyFileName)} {
+		if path == "" {
+			continue
+		}
+		payload, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range bytes.Split(bytes.TrimRight(payload, "\n"), []byte("\n")) {
+			line = bytes.TrimSpace(line)
+			record := struct {
+				Timestamp  string `json:"timestamp"`
+				FinishedAt string `json:"finished_at"`
+			}{}
+			if err := json.Unmarshal(line, &record); err != nil {
+				continue
+			}
+			stamp := record.Timestamp
+			if stamp == "" {
+				stamp = record.FinishedAt
+			}
+			at, err := time.Parse(time.RFC3339Nano, stamp)
+			if err != nil {
+				continue
+			}
+			artifacts = append(artifacts, stateArtifact{path: path, bytes: int64(len(line)) + 1, modTime: at, line: string(line)})
+		}
+	}
+	return artifacts
+}
+
+// runStateGC enforces cfg.Retention after a sync. Nothing is reported unless
+// something was removed or removal failed.
+func (s *Syncer) runStateGC(cfg config.Config) {
+	plan, err := PlanStateGC(cfg, s.Now())
+	if err == nil && len(plan.Items) == 0 {
+		return
+	}
+	removed := 0
+	if err == nil {
+		removed, err = ApplyStateGC(plan)
+	}
+	if err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventStateGC,
+			Message:   fmt.Sprintf("[gc] retention stopped after %d item(s): %v", removed, err),
+		})
+		return
+	}
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventStateGC,
+		Message:   fmt.Sprintf("[gc] retention removed %d item(s), %s, from %s", removed, formatEstimateBytes(plan.RemovedBytes()), plan.StateDir),
+		Details: map[string]any{
+			"removed_count": removed,
+			"removed_bytes": plan.RemovedBytes(),
+		},
+	})
+}
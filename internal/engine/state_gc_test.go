@@ -0,0 +1,143 @@
+package engine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func TestStateGCAppliesRetentionPerClass(t *testing.T) {
+	stateDir := t.TempDir()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	writeAged := func(rel string, size int, age time.Duration) string {
+		t.Helper()
+		path := filepath.Join(stateDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, bytes.Repeat([]byte("x"), size), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+		stamp := now.Add(-age)
+		if err := os.Chtimes(path, stamp, stamp); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+		if err := os.Chtimes(filepath.Dir(path), stamp, stamp); err != nil {
+			t.Fatalf("chtimes dir: %v", err)
+		}
+		return path
+	}
+	day := 24 * time.Hour
+	oldReport := writeAged("runs/20260401T000000Z/report.json", 10, 60*day)
+	midReport := writeAged("runs/20260520T000000Z/report.json", 10, 12*day)
+	newReport := writeAged("runs/20260601T000000Z/report.json", 10, time.Hour)
+	bigCache := writeAged("http-cache/a.json", 3<<20, 2*day)
+	smallCache := writeAged("http-cache/b.json", 1<<20, day)
+	staleIndex := writeAged("gone.local-index.json", 100, 30*day)
+	oldTrash := writeAged("trash/mix/20260101-000000/song.mp3", 100, 150*day)
+	newTrash := writeAged("trash/mix/20260530-000000/song.mp3", 100, 2*day)
+	keptState := writeAged("mix.sync.spotify", 10, 365*day)
+	history := strings.Join([]string{
+		`{"run_id":"a","finished_at":"2026-03-01T00:00:00Z"}`,
+		`not json`,
+		`{"run_id":"b","finished_at":"2026-05-31T00:00:00Z"}`,
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(stateDir, historyFileName), []byte(history), 0o644); err != nil {
+		t.Fatalf("write history: %v", err)
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir},
+		Retention: config.Retention{
+			Reports: config.RetentionRule{MaxAgeDays: 30},
+			Logs:    config.RetentionRule{MaxAgeDays: 30},
+			Caches:  config.RetentionRule{MaxAgeDays: 14, MaxSizeMB: 2},
+			Backups: config.RetentionRule{MaxAgeDays: 90},
+		},
+	}
+	plan, err := PlanStateGC(cfg, now)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	reasons := map[string]string{}
+	for _, item := range plan.Items {
+		reasons[item.Path] = item.Reason
+	}
+	want := map[string]string{
+		filepath.Dir(oldReport):                  "max_age",
+		bigCache:                                 "max_size",
+		staleIndex:                               "max_age",
+		filepath.Dir(oldTrash):                   "max_age",
+		filepath.Join(stateDir, historyFileName): "max_age",
+	}
+	if len(reasons) != len(want) {
+		t.Fatalf("unexpected plan items %+v", plan.Items)
+	}
+	for path, reason := range want {
+		if reasons[path] != reason {
+			t.Fatalf("expected %s to expire by %s, got %+v", path, reason, plan.Items)
+		}
+	}
+	if _, err := ApplyStateGC(plan); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	for _, gone := range []string{filepath.Dir(oldReport), bigCache, staleIndex} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, got %v", gone, err)
+		}
+	}
+	for _, kept := range []string{midReport, newReport, smallCache, keptState, newTrash} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Fatalf("expected %s to be kept: %v", kept, err)
+		}
+	}
+	payload, err := os.ReadFile(filepath.Join(stateDir, historyFileName))
+	if err != nil {
+		t.Fatalf("read history: %v", err)
+	}
+	if got := string(payload); got != "not json\n"+`{"run_id":"b","finished_at":"2026-05-31T00:00:00Z"}`+"\n" {
+		t.Fatalf("expected only the expired history record to be dropped, got %q", got)
+	}
+}
+
+func TestSyncerStateGCKeepsNewestAndReports(t *testing.T) {
+	stateDir := t.TempDir()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, runID := range []string{"20260101T000000Z", "20260102T000000Z"} {
+		dir := filepath.Join(stateDir, "runs", runID)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		stamp := now.Add(-time.Duration(200-i) * 24 * time.Hour)
+		if err := os.Chtimes(dir, stamp, stamp); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	var out bytes.Buffer
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(&out, &out, false, true))
+	syncer.Now = func() time.Time { return now }
+	cfg := config.Config{
+		Defaults:  config.Defaults{StateDir: stateDir},
+		Retention: config.Retention{Reports: config.RetentionRule{MaxAgeDays: 7}},
+	}
+
+	syncer.runStateGC(cfg)
+	if !strings.Contains(out.String(), "[gc] retention removed 1 item(s), 0B, from "+stateDir) {
+		t.Fatalf("expected a retention summary, got %q", out.String())
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "runs", "20260102T000000Z")); err != nil {
+		t.Fatalf("expected the newest report to be kept past max_age_days: %v", err)
+	}
+
+	out.Reset()
+	syncer.runStateGC(cfg)
+	if out.Len() != 0 {
+		t.Fatalf("expected no output when nothing expires, got %q", out.String())
+	}
+}
@@ -0,0 +1,450 @@
+package engine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	stateLineDropNUL          = "nul-bytes"
+	stateLineDropInvalidUTF8  = "invalid-utf8"
+	stateLineDropTooLong      = "too-long"
+	stateLineDropUnrecognized = "unrecognized"
+
+	// droppedStateLinePreview bounds how much of a dropped line is echoed back.
+	droppedStateLinePreview = 80
+)
+
+// ErrCorruptStateFile is wrapped by state parse errors for content no sync
+// could have written (NUL bytes from an interrupted write, or a line past the
+// scanner limit).
+var ErrCorruptStateFile = errors.New("corrupt state file")
+
+func corruptStateFileError(path string, line int, reason string) error {
+	return fmt.Errorf(
+		"%w %s: line %d (%s); run `udl recover-state` to quarantine it and salvage the readable entries",
+		ErrCorruptStateFile, path, line, reason,
+	)
+}
+
+// StateRecoveryOptions controls RecoverStateFiles. Rebuild adds entries for
+// local media whose tags identify a track the state no longer knows, which
+// needs ProbeTags (ffprobe); SoundCloud archive ids still unmatched afterwards
+// are reported as unresolved.
+type StateRecoveryOptions struct {
+	Rebuild      bool
+	ProbeTags    bool
+	ProbeTimeout time.Duration
+	DryRun       bool
+}
+
+// DroppedStateLine is a state file line recovery could not keep. Content is a
+// quoted, truncated preview so binary garbage stays printable.
+type DroppedStateLine struct {
+	Line    int    `json:"line"`
+	Reason  string `json:"reason"`
+	Content string `json:"content"`
+}
+
+// RebuiltStateEntry is a state entry recovered from a local file's tags.
+type RebuiltStateEntry struct {
+	TrackID string `json:"track_id"`
+	Path    string `json:"path"`
+	From    string `json:"from"`
+}
+
+// StateRecoveryReport summarizes the recovery of one source's state file.
+// QuarantinePath is set when the original was preserved before rewriting.
+type StateRecoveryReport struct {
+	SourceID       string              `json:"source_id"`
+	StateFile      string              `json:"state_file"`
+	Lines          int                 `json:"lines"`
+	Kept           int                 `json:"kept"`
+	Dropped        []DroppedStateLine  `json:"dropped"`
+	Rebuilt        []RebuiltStateEntry `json:"rebuilt"`
+	Unresolved     int                 `json:"unresolved_archive_ids"`
+	QuarantinePath string              `json:"quarantine_path,omitempty"`
+	Rewritten      bool                `json:"rewritten"`
+	Errors         []string            `json:"errors,omitempty"`
+}
+
+var (
+	probeMediaTagsFn   = probeMediaTags
+	stateRecoveryNowFn = time.Now
+)
+
+// soundCloudTagIDPattern finds a numeric SoundCloud track id in tag values
+// such as api.soundcloud.com/tracks/123 or soundcloud:tracks:123.
+var soundCloudTagIDPattern = regexp.MustCompile(`(?:/tracks/|soundcloud:tracks:)([0-9]+)`)
+
+// RecoverStateFiles salvages the state files of the selected sources (all
+// state-file sources when sourceIDs is empty). Lines that cannot be parsed are
+// dropped and reported by line number; when any are dropped the original is
+// copied to <state_file>.corrupt-<UTC timestamp> before the salvaged lines
+// replace it. Dry runs only report.
+func RecoverStateFiles(ctx context.Context, cfg config.Config, sourceIDs []string, opts StateRecoveryOptions) ([]StateRecoveryReport, error) {
+	sources, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	reports := []StateRecoveryReport{}
+	for _, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		if !sourceHasTrackState(source) {
+			if len(sourceIDs) > 0 {
+				reports = append(reports, StateRecoveryReport{
+					SourceID: source.ID,
+					Dropped:  []DroppedStateLine{},
+					Rebuilt:  []RebuiltStateEntry{},
+					Errors:   []string{fmt.Sprintf("source type %s has no state file to recover", source.Type)},
+				})
+			}
+			continue
+		}
+		reports = append(reports, recoverSourceState(ctx, cfg.Defaults.ForSource(source), source, opts))
+	}
+	return reports, nil
+}
+
+func sourceHasTrackState(source config.Source) bool {
+	switch source.Type {
+	case config.SourceTypeSoundCloud, config.SourceTypeSpotify, config.SourceTypeDeezer, config.SourceTypeAppleMusic:
+		return strings.TrimSpace(source.StateFile) != ""
+	default:
+		return false
+	}
+}
+
+func recoverSourceState(ctx context.Context, defaults config.Defaults, source config.Source, opts StateRecoveryOptions) StateRecoveryReport {
+	report := StateRecoveryReport{SourceID: source.ID, Dropped: []DroppedStateLine{}, Rebuilt: []RebuiltStateEntry{}}
+	statePath, err := stateFileForVerify(defaults, source)
+	if err != nil {
+		report.Errors = append(report.Errors, "state_file: "+err.Error())
+		return report
+	}
+	report.StateFile = statePath
+	if !opts.DryRun {
+		unlock, lockErr := lockSource(ctx, defaults.StateDir, source.ID, false, nil)
+		if lockErr != nil {
+			report.Errors = append(report.Errors, "lock: "+lockErr.Error())
+			return report
+		}
+		defer unlock()
+	}
+
+	payload, err := os.ReadFile(statePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		report.Errors = append(report.Errors, "state_file: "+err.Error())
+		return report
+	}
+	fileExists := err == nil
+
+	kept, knownPaths := salvageStateLines(source.Type, payload, &report)
+	report.Kept = len(kept)
+
+	if opts.Rebuild {
+		rebuilt, err := rebuildStateEntries(ctx, defaults, source, knownPaths, opts)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		if len(rebuilt) > 0 && source.Type != config.SourceTypeSoundCloud && !hasStateComment(kept) {
+			kept = append([]string{trackStateHeader(source.Type)}, kept...)
+		}
+		for _, entry := range rebuilt {
+			kept = append(kept, entry.line)
+			report.Rebuilt = append(report.Rebuilt, entry.RebuiltStateEntry)
+		}
+		report.Unresolved = countUnresolvedArchiveIDs(defaults, source, knownPaths, rebuilt)
+	}
+
+	if opts.DryRun || (len(report.Dropped) == 0 && len(report.Rebuilt) == 0) {
+		return report
+	}
+	if fileExists && len(report.Dropped) > 0 {
+		quarantine := statePath + ".corrupt-" + stateRecoveryNowFn().UTC().Format("20060102T150405Z")
+		if err := os.WriteFile(quarantine, payload, 0o644); err != nil {
+			report.Errors = append(report.Errors, "quarantine: "+err.Error())
+			return report
+		}
+		report.QuarantinePath = quarantine
+	}
+	if err := writeSoundCloudLinesAtomically(statePath, ".udl-recover-*.tmp", kept); err != nil {
+		report.Errors = append(report.Errors, "rewrite state_file: "+err.Error())
+		return report
+	}
+	report.Rewritten = true
+	return report
+}
+
+// salvageStateLines returns the lines of payload a sync can parse, recording
+// every other non-blank line in report.Dropped, and the known track ids
+// mapped to their recorded local path ("" when the entry has none).
+func salvageStateLines(sourceType config.SourceType, payload []byte, report *StateRecoveryReport) ([]string, map[string]string) {
+	kept := []string{}
+	known := map[string]string{}
+	if len(payload) == 0 {
+		return kept, known
+	}
+	rawLines := bytes.Split(payload, []byte("\n"))
+	if len(rawLines[len(rawLines)-1]) == 0 {
+		rawLines = rawLines[:len(rawLines)-1]
+	}
+	parseLine := trackStateLineParser(sourceType)
+	for i, rawLine := range rawLines {
+		text := strings.TrimRight(string(rawLine), "\r")
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" {
+			continue
+		}
+		report.Lines++
+
+		reason := ""
+		switch {
+		case len(rawLine) > bufio.MaxScanTokenSize:
+			reason = stateLineDropTooLong
+		case strings.ContainsRune(trimmed, 0):
+			reason = stateLineDropNUL
+		case !utf8.ValidString(trimmed):
+			reason = stateLineDropInvalidUTF8
+		case strings.HasPrefix(trimmed, "#"):
+		case sourceType == config.SourceTypeSoundCloud:
+			parts := strings.SplitN(trimmed, " ", 3)
+			if len(parts) != 3 || parts[0] != "soundcloud" || strings.TrimSpace(parts[1]) == "" || strings.TrimSpace(parts[2]) == "" {
+				reason = stateLineDropUnrecognized
+				break
+			}
+			known[strings.TrimSpace(parts[1])] = strings.TrimSpace(parts[2])
+		default:
+			id, entry := parseLine(trimmed)
+			if id == "" {
+				reason = stateLineDropUnrecognized
+				break
+			}
+			if entry.LocalPath != "" || known[id] == "" {
+				known[id] = entry.LocalPath
+			}
+		}
+		if reason != "" {
+			report.Dropped = append(report.Dropped, DroppedStateLine{Line: i + 1, Reason: reason, Content: previewStateLine(trimmed)})
+			continue
+		}
+		kept = append(kept, text)
+	}
+	return kept, known
+}
+
+func previewStateLine(line string) string {
+	if len(line) > droppedStateLinePreview {
+		return strconv.QuoteToASCII(line[:droppedStateLinePreview]) + "..."
+	}
+	return strconv.QuoteToASCII(line)
+}
+
+func hasStateComment(lines []string) bool {
+	for _, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			return true
+		}
+	}
+	return false
+}
+
+type rebuiltStateLine struct {
+	RebuiltStateEntry
+	line string
+}
+
+// rebuildStateEntries walks target_dir for media files the salvaged state does
+// not reference and restores an entry for each one whose tags carry a track id
+// of the source's catalog that the state no longer knows.
+func rebuildStateEntries(
+	ctx context.Context,
+	defaults config.Defaults,
+	source config.Source,
+	known map[string]string,
+	opts StateRecoveryOptions,
+) ([]rebuiltStateLine, error) {
+	if !opts.ProbeTags {
+		return nil, nil
+	}
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return nil, fmt.Errorf("target_dir: %w", err)
+	}
+	referenced := map[string]struct{}{}
+	for _, path := range known {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(targetDir, filepath.FromSlash(path))
+		}
+		referenced[filepath.Clean(path)] = struct{}{}
+	}
+
+	files := []string{}
+	_ = filepath.WalkDir(targetDir, func(path string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil || d.IsDir() || !isMediaExt(strings.ToLower(filepath.Ext(d.Name()))) {
+			return nil
+		}
+		if _, ok := referenced[filepath.Clean(path)]; !ok {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+
+	rebuilt := []rebuiltStateLine{}
+	restored := map[string]struct{}{}
+	for _, path := range files {
+		if err := ctx.Err(); err != nil {
+			return rebuilt, err
+		}
+		probeCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.ProbeTimeout > 0 {
+			probeCtx, cancel = context.WithTimeout(ctx, opts.ProbeTimeout)
+		}
+		tags, err := probeMediaTagsFn(probeCtx, path)
+		cancel()
+		if err != nil {
+			continue
+		}
+		id := trackIDFromTags(source.Type, tags)
+		if id == "" {
+			continue
+		}
+		if _, ok := known[id]; ok {
+			continue
+		}
+		if _, ok := restored[id]; ok {
+			continue
+		}
+		restored[id] = struct{}{}
+
+		relPath, err := filepath.Rel(targetDir, path)
+		if err != nil {
+			relPath = path
+		}
+		entry := rebuiltStateLine{RebuiltStateEntry: RebuiltStateEntry{TrackID: id, Path: relPath, From: "tags"}}
+		if source.Type == config.SourceTypeSoundCloud {
+			entry.line = "soundcloud " + id + " " + relPath
+		} else {
+			displayName := strings.TrimSpace(tags["title"])
+			if artist := strings.TrimSpace(tags["artist"]); artist != "" && displayName != "" {
+				displayName = artist + " - " + displayName
+			}
+			entry.line = formatTrackSyncStateLine(id, spotifyStateEntry{DisplayName: displayName, LocalPath: filepath.ToSlash(relPath)})
+		}
+		rebuilt = append(rebuilt, entry)
+	}
+	return rebuilt, nil
+}
+
+// trackIDFromTags extracts a track id from tag values. Only links and URIs are
+// considered so numbers in free-form comments are not mistaken for ids.
+func trackIDFromTags(sourceType config.SourceType, tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := tags[name]
+		if sourceType == config.SourceTypeSoundCloud {
+			if match := soundCloudTagIDPattern.FindStringSubmatch(value); match != nil {
+				return match[1]
+			}
+			continue
+		}
+		for _, token := range strings.Fields(value) {
+			lowered := strings.ToLower(token)
+			if !strings.HasPrefix(lowered, "http://") && !strings.HasPrefix(lowered, "https://") && !strings.HasPrefix(lowered, "spotify:track:") {
+				continue
+			}
+			id := ""
+			switch sourceType {
+			case config.SourceTypeDeezer:
+				id = extractDeezerTrackID(token)
+			case config.SourceTypeAppleMusic:
+				id = extractAppleMusicTrackID(token)
+			default:
+				id = extractSpotifyTrackID(token)
+			}
+			if id != "" {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+// countUnresolvedArchiveIDs counts SoundCloud archive ids that neither the
+// salvaged state nor the rebuild could place. The archive still keeps them
+// from being downloaded again.
+func countUnresolvedArchiveIDs(defaults config.Defaults, source config.Source, known map[string]string, rebuilt []rebuiltStateLine) int {
+	if source.Type != config.SourceTypeSoundCloud {
+		return 0
+	}
+	archivePath, err := resolveSoundCloudArchivePath(source, defaults)
+	if err != nil {
+		return 0
+	}
+	archived, err := parseSoundCloudArchive(archivePath)
+	if err != nil {
+		return 0
+	}
+	restored := map[string]struct{}{}
+	for _, entry := range rebuilt {
+		restored[entry.TrackID] = struct{}{}
+	}
+	unresolved := 0
+	for id := range archived {
+		if _, ok := known[id]; ok {
+			continue
+		}
+		if _, ok := restored[id]; ok {
+			continue
+		}
+		unresolved++
+	}
+	return unresolved
+}
+
+// probeMediaTags returns a media file's container tags with lower-cased names.
+func probeMediaTags(ctx context.Context, path string) (map[string]string, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_entries", "format_tags", "-of", "json", path)
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var payload struct {
+		Format struct {
+			Tags map[string]string `json:"tags"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return nil, err
+	}
+	tags := make(map[string]string, len(payload.Format.Tags))
+	for name, value := range payload.Format.Tags {
+		tags[strings.ToLower(name)] = value
+	}
+	return tags, nil
+}
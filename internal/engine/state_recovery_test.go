@@ -0,0 +1,192 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestParseStateFilesRejectNULCorruption(t *testing.T) {
+	tmp := t.TempDir()
+	scPath := filepath.Join(tmp, "sc.sync.scdl")
+	if err := os.WriteFile(scPath, []byte("soundcloud 1 one.mp3\n\x00\x00\x00\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	_, err := parseSoundCloudSyncState(scPath)
+	if !errors.Is(err, ErrCorruptStateFile) || !strings.Contains(err.Error(), "line 2 (nul-bytes)") {
+		t.Fatalf("expected corrupt state error at line 2, got %v", err)
+	}
+
+	spotifyPath := filepath.Join(tmp, "sp.sync.spotify")
+	if err := os.WriteFile(spotifyPath, []byte("# udl spotify state v2\n"+strings.Repeat("x", 70*1024)+"\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	_, err = parseSpotifySyncState(spotifyPath)
+	if !errors.Is(err, ErrCorruptStateFile) || !strings.Contains(err.Error(), "udl recover-state") {
+		t.Fatalf("expected corrupt state error with recovery hint, got %v", err)
+	}
+}
+
+func TestRecoverStateFilesQuarantinesAndSalvagesSoundCloudState(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, targetDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"one.mp3", "three.mp3", "stray.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, name), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "sc.sync.scdl")
+	original := "soundcloud 1 one.mp3\n\x00\x00garbage\nsoundcloud 2 two.mp3\nnot a state line\n"
+	if err := os.WriteFile(statePath, []byte(original), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "sc.archive.txt"), []byte("soundcloud 1\nsoundcloud 2\nsoundcloud 3\nsoundcloud 4\n"), 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+
+	originalProbe := probeMediaTagsFn
+	originalNow := stateRecoveryNowFn
+	t.Cleanup(func() {
+		probeMediaTagsFn = originalProbe
+		stateRecoveryNowFn = originalNow
+	})
+	probed := []string{}
+	probeMediaTagsFn = func(ctx context.Context, path string) (map[string]string, error) {
+		probed = append(probed, filepath.Base(path))
+		if filepath.Base(path) == "three.mp3" {
+			return map[string]string{"title": "Three", "comment": "https://api.soundcloud.com/tracks/3"}, nil
+		}
+		return map[string]string{"title": "Stray"}, nil
+	}
+	stateRecoveryNowFn = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sc",
+			Type:      config.SourceTypeSoundCloud,
+			Enabled:   true,
+			TargetDir: targetDir,
+			StateFile: "sc.sync.scdl",
+			Adapter:   config.AdapterSpec{Kind: "scdl"},
+		}},
+	}
+
+	reports, err := RecoverStateFiles(context.Background(), cfg, nil, StateRecoveryOptions{Rebuild: true, ProbeTags: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("recover (dry run): %v", err)
+	}
+	if len(reports[0].Dropped) != 2 || reports[0].Rewritten {
+		t.Fatalf("unexpected dry-run report: %+v", reports[0])
+	}
+	if raw, _ := os.ReadFile(statePath); string(raw) != original {
+		t.Fatalf("dry run must not rewrite state, got %q", string(raw))
+	}
+	probed = probed[:0]
+
+	reports, err = RecoverStateFiles(context.Background(), cfg, nil, StateRecoveryOptions{Rebuild: true, ProbeTags: true})
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	report := reports[0]
+	if report.Lines != 4 || report.Kept != 2 || len(report.Errors) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Dropped[0].Line != 2 || report.Dropped[0].Reason != "nul-bytes" || report.Dropped[1].Line != 4 || report.Dropped[1].Reason != "unrecognized" {
+		t.Fatalf("unexpected dropped lines: %+v", report.Dropped)
+	}
+	if len(report.Rebuilt) != 1 || report.Rebuilt[0].TrackID != "3" || report.Rebuilt[0].Path != "three.mp3" {
+		t.Fatalf("unexpected rebuilt entries: %+v", report.Rebuilt)
+	}
+	if report.Unresolved != 1 {
+		t.Fatalf("expected archive id 4 to stay unresolved, got %d", report.Unresolved)
+	}
+	if strings.Join(probed, ",") != "stray.mp3,three.mp3" {
+		t.Fatalf("expected only untracked media to be probed, got %v", probed)
+	}
+
+	wantQuarantine := statePath + ".corrupt-20260301T120000Z"
+	if report.QuarantinePath != wantQuarantine {
+		t.Fatalf("unexpected quarantine path %q", report.QuarantinePath)
+	}
+	if raw, err := os.ReadFile(wantQuarantine); err != nil || string(raw) != original {
+		t.Fatalf("quarantine must keep the original bytes: %q (%v)", string(raw), err)
+	}
+	raw, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	if string(raw) != "soundcloud 1 one.mp3\nsoundcloud 2 two.mp3\nsoundcloud 3 three.mp3\n" {
+		t.Fatalf("unexpected salvaged state: %q", string(raw))
+	}
+	if _, err := parseSoundCloudSyncState(statePath); err != nil {
+		t.Fatalf("salvaged state must parse: %v", err)
+	}
+}
+
+func TestRecoverStateFilesRebuildsMissingSpotifyState(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music", "Artist")
+	for _, dir := range []string{stateDir, targetDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "Song.mp3"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write media: %v", err)
+	}
+
+	originalProbe := probeMediaTagsFn
+	t.Cleanup(func() { probeMediaTagsFn = originalProbe })
+	probeMediaTagsFn = func(ctx context.Context, path string) (map[string]string, error) {
+		return map[string]string{
+			"artist":  "Artist",
+			"title":   "Song",
+			"comment": "track 42 https://open.spotify.com/track/6rqhFgbbKwnb9MLmUQDhG6",
+		}, nil
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir},
+		Sources: []config.Source{{
+			ID:        "sp",
+			Type:      config.SourceTypeSpotify,
+			Enabled:   true,
+			TargetDir: filepath.Join(tmp, "music"),
+			StateFile: "sp.sync.spotify",
+			Adapter:   config.AdapterSpec{Kind: "deemix"},
+		}},
+	}
+	reports, err := RecoverStateFiles(context.Background(), cfg, []string{"sp"}, StateRecoveryOptions{Rebuild: true, ProbeTags: true})
+	if err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if len(reports[0].Rebuilt) != 1 || reports[0].QuarantinePath != "" {
+		t.Fatalf("unexpected report: %+v", reports[0])
+	}
+	state, err := parseSpotifySyncState(filepath.Join(stateDir, "sp.sync.spotify"))
+	if err != nil {
+		t.Fatalf("parse rebuilt state: %v", err)
+	}
+	entry := state.Entries["6rqhFgbbKwnb9MLmUQDhG6"]
+	if entry.DisplayName != "Artist - Song" || entry.LocalPath != "Artist/Song.mp3" {
+		t.Fatalf("unexpected rebuilt entry: %+v", entry)
+	}
+	raw, _ := os.ReadFile(filepath.Join(stateDir, "sp.sync.spotify"))
+	if !strings.HasPrefix(string(raw), spotifyStateHeader+"\n") {
+		t.Fatalf("expected rebuilt state to start with the header, got %q", string(raw))
+	}
+}
@@ -0,0 +1,270 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+const (
+	StateRemapKindState   = "state"
+	StateRemapKindArchive = "archive"
+)
+
+// StateRemapFile is the planned rewrite of one state or archive file after a
+// library move. Missing lists remapped entry paths that do not exist on disk.
+type StateRemapFile struct {
+	SourceID  string
+	Kind      string
+	Path      string
+	Rewritten int
+	Missing   []string
+
+	lines []string
+}
+
+// StaleStateRoot reports a source whose state entries point at absolute paths
+// that no longer exist, grouped under their deepest common directory.
+type StaleStateRoot struct {
+	SourceID string
+	Root     string
+	Entries  int
+}
+
+// RemapPathPrefix rewrites path when it equals from or lives below it.
+func RemapPathPrefix(path string, from string, to string) (string, bool) {
+	cleanPath := filepath.Clean(strings.TrimSpace(path))
+	cleanFrom := filepath.Clean(strings.TrimSpace(from))
+	if strings.TrimSpace(path) == "" || strings.TrimSpace(from) == "" {
+		return path, false
+	}
+	if cleanPath == cleanFrom {
+		return filepath.Clean(to), true
+	}
+	prefix := cleanFrom
+	if !strings.HasSuffix(prefix, string(os.PathSeparator)) {
+		prefix += string(os.PathSeparator)
+	}
+	if !strings.HasPrefix(cleanPath, prefix) {
+		return path, false
+	}
+	return filepath.Join(filepath.Clean(to), strings.TrimPrefix(cleanPath, prefix)), true
+}
+
+// PlanStateRemap prepares rewrites of SoundCloud state entries and per-source
+// archives for every source in cfg. cfg must already carry the remapped
+// target_dir/state_dir values so files are read from their new location.
+func PlanStateRemap(cfg config.Config, from string, to string) ([]StateRemapFile, error) {
+	planned := []StateRemapFile{}
+	seen := map[string]struct{}{}
+	for _, source := range cfg.Sources {
+		targetDir, err := config.ExpandTargetDir(source.TargetDir)
+		if err != nil {
+			return nil, err
+		}
+		if source.Type == config.SourceTypeSoundCloud {
+			statePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := seen[statePath]; !dup {
+				seen[statePath] = struct{}{}
+				file, err := planSoundCloudStateRemap(source.ID, statePath, targetDir, from, to)
+				if err != nil {
+					return nil, err
+				}
+				planned = append(planned, file)
+			}
+		}
+
+		archivePath, ok, err := sourceArchivePath(source, cfg.Defaults)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if _, dup := seen[archivePath]; dup {
+			continue
+		}
+		seen[archivePath] = struct{}{}
+		file, err := planArchiveRemap(source.ID, archivePath, from, to)
+		if err != nil {
+			return nil, err
+		}
+		planned = append(planned, file)
+	}
+	return planned, nil
+}
+
+// ApplyStateRemap writes each planned file that has rewritten entries while
+// holding the locks of their sources under stateDir.
+func ApplyStateRemap(ctx context.Context, stateDir string, files []StateRemapFile) error {
+	locked := []string{}
+	for _, file := range files {
+		if file.Rewritten > 0 {
+			locked = append(locked, file.SourceID)
+		}
+	}
+	unlock, err := lockSources(ctx, stateDir, locked)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for _, file := range files {
+		if file.Rewritten == 0 {
+			continue
+		}
+		if err := writeSoundCloudLinesAtomically(file.Path, ".udl-remap-*.tmp", file.lines); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DetectStaleStateRoots finds SoundCloud sources whose absolute state entry
+// paths are all missing, which usually means the library moved disks.
+func DetectStaleStateRoots(cfg config.Config) []StaleStateRoot {
+	roots := []StaleStateRoot{}
+	for _, source := range cfg.Sources {
+		if source.Type != config.SourceTypeSoundCloud {
+			continue
+		}
+		statePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+		if err != nil {
+			continue
+		}
+		state, err := parseSoundCloudSyncState(statePath)
+		if err != nil {
+			continue
+		}
+		missing := []string{}
+		present := 0
+		for _, entry := range state.Entries {
+			path := strings.TrimSpace(entry.FilePath)
+			if entry.ID == "" || !filepath.IsAbs(path) {
+				continue
+			}
+			if stateEntryHasLocalFile(path, "") {
+				present++
+				continue
+			}
+			missing = append(missing, path)
+		}
+		if len(missing) == 0 || present > 0 {
+			continue
+		}
+		roots = append(roots, StaleStateRoot{
+			SourceID: source.ID,
+			Root:     commonPathRoot(missing),
+			Entries:  len(missing),
+		})
+	}
+	return roots
+}
+
+func planSoundCloudStateRemap(sourceID string, statePath string, targetDir string, from string, to string) (StateRemapFile, error) {
+	file := StateRemapFile{SourceID: sourceID, Kind: StateRemapKindState, Path: statePath}
+	if _, err := os.Stat(statePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return file, nil
+		}
+		return file, err
+	}
+	state, err := parseSoundCloudSyncState(statePath)
+	if err != nil {
+		return file, err
+	}
+	file.lines = make([]string, 0, len(state.Entries))
+	for _, entry := range state.Entries {
+		if entry.ID == "" || strings.TrimSpace(entry.FilePath) == "" {
+			file.lines = append(file.lines, entry.RawLine)
+			continue
+		}
+		remapped, changed := entry.FilePath, false
+		if filepath.IsAbs(entry.FilePath) {
+			remapped, changed = RemapPathPrefix(entry.FilePath, from, to)
+		}
+		if changed {
+			file.Rewritten++
+			file.lines = append(file.lines, "soundcloud "+entry.ID+" "+remapped)
+		} else {
+			file.lines = append(file.lines, entry.RawLine)
+		}
+		if changed && !stateEntryHasLocalFile(remapped, targetDir) {
+			file.Missing = append(file.Missing, remapped)
+		}
+	}
+	return file, nil
+}
+
+// planArchiveRemap rewrites any archive field that is a path below from.
+// yt-dlp archives normally hold only "<extractor> <id>" pairs, so this is
+// usually a no-op kept for archives written by custom tooling.
+func planArchiveRemap(sourceID string, archivePath string, from string, to string) (StateRemapFile, error) {
+	file := StateRemapFile{SourceID: sourceID, Kind: StateRemapKindArchive, Path: archivePath}
+	lines, err := readSoundCloudArchiveLines(archivePath)
+	if err != nil {
+		return file, err
+	}
+	file.lines = make([]string, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line.Raw)
+		changed := false
+		for i, field := range fields {
+			if !filepath.IsAbs(field) {
+				continue
+			}
+			if remapped, ok := RemapPathPrefix(field, from, to); ok {
+				fields[i] = remapped
+				changed = true
+			}
+		}
+		if !changed {
+			file.lines = append(file.lines, line.Raw)
+			continue
+		}
+		file.Rewritten++
+		file.lines = append(file.lines, strings.Join(fields, " "))
+	}
+	return file, nil
+}
+
+func sourceArchivePath(source config.Source, defaults config.Defaults) (string, bool, error) {
+	defaults = defaults.ForSource(source)
+	switch source.Adapter.Kind {
+	case "scdl", "scdl-freedl":
+		path, err := resolveSoundCloudArchivePath(source, defaults)
+		return path, err == nil, err
+	case "ytdlp":
+		path, err := config.ResolveArchiveFile(defaults.StateDir, defaults.ArchiveFile, source.ID)
+		return path, err == nil, err
+	case "spotdl":
+		path, err := ResolveSpotDLArchivePath(source, defaults)
+		return path, err == nil, err
+	default:
+		return "", false, nil
+	}
+}
+
+func commonPathRoot(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	sorted := append([]string{}, paths...)
+	sort.Strings(sorted)
+	root := filepath.Dir(sorted[0])
+	last := sorted[len(sorted)-1]
+	for root != filepath.Dir(root) {
+		if _, ok := RemapPathPrefix(last, root, root); ok {
+			break
+		}
+		root = filepath.Dir(root)
+	}
+	return root
+}
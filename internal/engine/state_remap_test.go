@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestPlanStateRemapRewritesSoundCloudStateAndReportsMissing(t *testing.T) {
+	tmp := t.TempDir()
+	oldRoot := filepath.Join(tmp, "old")
+	newRoot := filepath.Join(tmp, "new")
+	stateDir := filepath.Join(newRoot, "state")
+	targetDir := filepath.Join(newRoot, "music")
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "one.mp3"), []byte("a"), 0o644); err != nil {
+		t.Fatalf("write track: %v", err)
+	}
+	statePath := filepath.Join(stateDir, "sc.sync.scdl")
+	payload := strings.Join([]string{
+		"soundcloud 1 " + filepath.Join(oldRoot, "music", "one.mp3"),
+		"soundcloud 2 " + filepath.Join(oldRoot, "music", "two.mp3"),
+		"soundcloud 3 relative.mp3",
+	}, "\n") + "\n"
+	if err := os.WriteFile(statePath, []byte(payload), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{{
+			ID:        "sc",
+			Type:      config.SourceTypeSoundCloud,
+			TargetDir: targetDir,
+			StateFile: "sc.sync.scdl",
+			Adapter:   config.AdapterSpec{Kind: "scdl"},
+		}},
+	}
+	if roots := DetectStaleStateRoots(cfg); len(roots) != 1 || roots[0].Root != filepath.Join(oldRoot, "music") || roots[0].Entries != 2 {
+		t.Fatalf("expected stale root under old music dir, got %+v", roots)
+	}
+
+	files, err := PlanStateRemap(cfg, oldRoot, newRoot)
+	if err != nil {
+		t.Fatalf("plan remap: %v", err)
+	}
+	var stateFile StateRemapFile
+	for _, file := range files {
+		if file.Kind == StateRemapKindState {
+			stateFile = file
+		}
+	}
+	if stateFile.Rewritten != 2 {
+		t.Fatalf("expected 2 rewritten entries, got %+v", stateFile)
+	}
+	if len(stateFile.Missing) != 1 || stateFile.Missing[0] != filepath.Join(targetDir, "two.mp3") {
+		t.Fatalf("expected missing two.mp3, got %v", stateFile.Missing)
+	}
+
+	if err := ApplyStateRemap(context.Background(), stateDir, files); err != nil {
+		t.Fatalf("apply remap: %v", err)
+	}
+	state, err := parseSoundCloudSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if got := state.ByID["1"].FilePath; got != filepath.Join(targetDir, "one.mp3") {
+		t.Fatalf("expected remapped path, got %q", got)
+	}
+	if got := state.ByID["3"].FilePath; got != "relative.mp3" {
+		t.Fatalf("expected relative path untouched, got %q", got)
+	}
+}
+
+func TestRemapPathPrefixMatchesWholeComponents(t *testing.T) {
+	if got, ok := RemapPathPrefix("/mnt/music/a.mp3", "/mnt/music", "/data/music"); !ok || got != "/data/music/a.mp3" {
+		t.Fatalf("expected prefix rewrite, got %q %v", got, ok)
+	}
+	if _, ok := RemapPathPrefix("/mnt/music-old/a.mp3", "/mnt/music", "/data/music"); ok {
+		t.Fatalf("expected sibling directory not to match")
+	}
+}
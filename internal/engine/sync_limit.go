@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"slices"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// supportsPlanningControls reports whether a source plans per track, which
+// --only-gaps and --limit need. Adapters synced without a remote diff
+// (spotdl, ytdlp, tidal-dl) cannot honour them.
+func supportsPlanningControls(source config.Source) bool {
+	switch source.Type {
+	case config.SourceTypeSoundCloud:
+		return true
+	case config.SourceTypeSpotify, config.SourceTypeDeezer:
+		return source.Adapter.Kind == "deemix"
+	case config.SourceTypeAppleMusic:
+		return source.Adapter.Kind == "gamdl"
+	}
+	return false
+}
+
+// limitPlannedTrackIDs keeps the limit planned IDs furthest down the remote
+// listing, in their planned order. Break mode stops at the first track it
+// finds locally, so downloading the oldest planned tracks first leaves the
+// rest above them for the next run to plan again.
+func limitPlannedTrackIDs(planned []string, remote []playlistTrack, limit int) []string {
+	if limit <= 0 || len(planned) <= limit {
+		return planned
+	}
+	position := make(map[string]int, len(remote))
+	for i, track := range remote {
+		position[track.ID] = i
+	}
+	ranked := append([]string(nil), planned...)
+	slices.SortStableFunc(ranked, func(a, b string) int {
+		return position[b] - position[a]
+	})
+	keep := make(map[string]struct{}, limit)
+	for _, id := range ranked[:limit] {
+		keep[id] = struct{}{}
+	}
+	kept := make([]string, 0, limit)
+	for _, id := range planned {
+		if _, ok := keep[id]; ok {
+			kept = append(kept, id)
+		}
+	}
+	return kept
+}
+
+// applySyncLimit caps a source's planned tracks at --limit and records how
+// many were deferred on its preflight. Monitor sources download nothing, so
+// they keep their full plan.
+func applySyncLimit(source config.Source, opts SyncOptions, preflight *SoundCloudPreflight, planned []string, remote []playlistTrack) []string {
+	if opts.Limit <= 0 || source.Monitor {
+		return planned
+	}
+	kept := limitPlannedTrackIDs(planned, remote, opts.Limit)
+	if deferred := len(planned) - len(kept); deferred > 0 && preflight != nil {
+		preflight.LimitDeferred = deferred
+		preflight.PlannedDownloadCount = len(kept)
+	}
+	return kept
+}
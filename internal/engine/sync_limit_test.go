@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+func newSyncLimitTestSource(t *testing.T, state string) (config.Config, config.Source) {
+	t.Helper()
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{targetDir, stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "Local.m4a"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write local track: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(stateDir, "sc-likes.sync.scdl"), []byte(state), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+	}
+	source := config.Source{
+		ID:        "sc-likes",
+		Type:      config.SourceTypeSoundCloud,
+		Enabled:   true,
+		TargetDir: targetDir,
+		URL:       "https://soundcloud.com/user",
+		StateFile: "sc-likes.sync.scdl",
+		Adapter:   config.AdapterSpec{Kind: "scdl"},
+	}
+
+	origEnumerate := enumerateSoundCloudTracksFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+	})
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "New"},
+			{ID: "222", Title: "Local"},
+			{ID: "333", Title: "Gap"},
+			{ID: "444", Title: "Missing"},
+			{ID: "555", Title: "Older Gap"},
+		}, nil
+	}
+	return cfg, source
+}
+
+func TestPrepareSoundCloudExecutionPlanOnlyGapsSkipsNewHeadTracks(t *testing.T) {
+	cfg, source := newSyncLimitTestSource(t, "soundcloud 222 Local.m4a\nsoundcloud 444 Missing.m4a\n")
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeAdapter{}}, noOpRunner{}, &captureEventEmitter{})
+	plan, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{OnlyGaps: true})
+	if err != nil {
+		t.Fatalf("prepare plan: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanupTempStateFiles(plan.StateSwap) })
+
+	if plan.Preflight == nil || plan.Preflight.Mode != SoundCloudModeOnlyGaps || plan.Preflight.PlannedDownloadCount != 3 {
+		t.Fatalf("expected three gaps planned in only-gaps mode, got %+v", plan.Preflight)
+	}
+	if !reflect.DeepEqual(plan.Source.SelectedPlaylistIDs, []int{3, 4, 5}) {
+		t.Fatalf("expected scdl held to the gap positions, got %v", plan.Source.SelectedPlaylistIDs)
+	}
+	if plan.StateSwap.TempSyncPath == "" {
+		t.Fatalf("expected the known gap to be filtered out of a temporary state file")
+	}
+}
+
+func TestPrepareSoundCloudExecutionPlanLimitKeepsOldestPlannedTracks(t *testing.T) {
+	cfg, source := newSyncLimitTestSource(t, "")
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeAdapter{}}, noOpRunner{}, emitter)
+	plan, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("prepare plan: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanupTempStateFiles(plan.StateSwap) })
+
+	if plan.Preflight == nil || plan.Preflight.PlannedDownloadCount != 2 || plan.Preflight.LimitDeferred != 3 {
+		t.Fatalf("expected two of five planned tracks kept, got %+v", plan.Preflight)
+	}
+	if !reflect.DeepEqual(plan.Source.SelectedPlaylistIDs, []int{4, 5}) {
+		t.Fatalf("expected the two oldest planned tracks selected, got %v", plan.Source.SelectedPlaylistIDs)
+	}
+	syncer.emitSourcePreflightSummary(source, plan.Preflight, plan.DownloadOrder)
+	if last := emitter.events[len(emitter.events)-1]; last.Details["limit_deferred_count"] != 3 {
+		t.Fatalf("expected limit_deferred_count in the preflight summary, got %+v", last.Details)
+	}
+
+	monitored := source
+	monitored.Monitor = true
+	if kept := applySyncLimit(monitored, SyncOptions{Limit: 1}, nil, []string{"a", "b"}, nil); len(kept) != 2 {
+		t.Fatalf("expected monitor sources to keep their full plan, got %v", kept)
+	}
+}
+
+func TestSyncerSkipsSourcesWithoutPreflightUnderPlanningControls(t *testing.T) {
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              t.TempDir(),
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "yt-mix",
+				Type:      config.SourceTypeYouTube,
+				Enabled:   true,
+				TargetDir: t.TempDir(),
+				URL:       "https://www.youtube.com/playlist?list=PL123",
+				Adapter:   config.AdapterSpec{Kind: "ytdlp"},
+			},
+		},
+	}
+	runner := &sequenceRunner{}
+	syncer := NewSyncer(map[string]Adapter{"ytdlp": fakeAdapter{}}, runner, output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, true, false))
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{Limit: 5})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Skipped != 1 || len(runner.specs) != 0 {
+		t.Fatalf("expected the ytdlp source skipped under --limit, got %+v specs=%d", result, len(runner.specs))
+	}
+}
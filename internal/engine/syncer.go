@@ -14,6 +14,7 @@ import (
 	"github.com/jaa/update-downloads/internal/config"
 	"github.com/jaa/update-downloads/internal/engine/adapterlog"
 	"github.com/jaa/update-downloads/internal/engine/progress"
+	"github.com/jaa/update-downloads/internal/httpcache"
 	"github.com/jaa/update-downloads/internal/output"
 )
 
@@ -21,10 +22,22 @@ var ErrInterrupted = errors.New("sync interrupted")
 
 type SelectionError struct {
 	Missing []string
+	// MissingTags lists --tag values no configured source carries.
+	MissingTags []string
 }
 
 func (e *SelectionError) Error() string {
-	return fmt.Sprintf("unknown source id(s): %s", strings.Join(e.Missing, ", "))
+	parts := []string{}
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("unknown source id(s): %s", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.MissingTags) > 0 {
+		parts = append(parts, fmt.Sprintf("no source tagged: %s", strings.Join(e.MissingTags, ", ")))
+	}
+	if len(parts) == 0 {
+		return "no sources selected: every matching source carries an excluded tag"
+	}
+	return strings.Join(parts, "; ")
 }
 
 type Syncer struct {
@@ -35,6 +48,13 @@ type Syncer struct {
 	Parsers      *adapterlog.Registry
 	PlanRegistry *PlanRegistry
 	Now          func() time.Time
+
+	// run is the in-flight sync's recorder, set for the duration of Sync.
+	run *runReportRecorder
+	// freeDLBudget is the in-flight sync's free-DL interactive budget.
+	freeDLBudget *freeDLInteractiveBudget
+	// plan collects per-track decisions while Plan runs.
+	plan *planRecorder
 }
 
 var (
@@ -42,10 +62,17 @@ var (
 	resolveDeemixARLFn                    = auth.ResolveDeemixARL
 	saveDeemixARLFn                       = auth.SaveDeemixARL
 	resolveSoundCloudClientIDWithSourceFn = auth.ResolveSoundCloudClientIDWithSource
+	refreshSoundCloudClientIDFn           = auth.RefreshSoundCloudClientID
+	saveSoundCloudClientIDFn              = auth.SaveSoundCloudClientID
+	soundCloudOAuthAccessTokenFn          = func(ctx context.Context, refresh bool) (string, bool, error) {
+		return auth.NewSoundCloudOAuthManager().AccessToken(ctx, refresh)
+	}
 	recordCredentialFailureFn             = auth.RecordCredentialFailure
 	clearCredentialFailureFn              = auth.ClearCredentialFailure
-	enumerateSpotifyTracksFn              = enumerateSpotifyPlaylistTracks
+	enumerateSpotifyTracksFn              = enumerateSpotifyTracks
 	enumerateSpotifyViaPageFn             = enumerateSpotifyPlaylistTracksViaPage
+	enumerateDeezerTracksFn               = enumerateDeezerTracks
+	enumerateAppleMusicTracksFn           = enumerateAppleMusicTracks
 	fetchSpotifyTrackMetadataFn           = fetchSpotifyTrackMetadataFromPage
 	fetchSoundCloudFreeDownloadMetadataFn = fetchSoundCloudFreeDownloadMetadata
 	applySoundCloudTrackMetadataFn        = applySoundCloudTrackMetadata
@@ -61,6 +88,7 @@ func NewSyncer(registry map[string]Adapter, runner ExecRunner, emitter output.Ev
 	parserRegistry.RegisterFactory("scdl", adapterlog.NewSCDLParser)
 	parserRegistry.RegisterFactory("deemix", adapterlog.NewDeemixParser)
 	parserRegistry.RegisterFactory("spotdl", adapterlog.NewSpotDLParser)
+	parserRegistry.RegisterFactory("ytdlp", adapterlog.NewYTDLPParser)
 	planRegistry := NewPlanRegistry()
 	planRegistry.Register("scdl", NewSCDLPlanProvider())
 	return &Syncer{
@@ -95,25 +123,44 @@ type sourceRunOutcome struct {
 	Stop               bool
 }
 
-func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions) (SyncResult, error) {
-	result := SyncResult{}
+func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions) (result SyncResult, err error) {
 	if s.Now == nil {
 		s.Now = time.Now
 	}
 	originalEmitter := s.Emitter
-	s.Emitter = output.NewFailureDiagnosticsEmitter(cfg.Defaults.StateDir, originalEmitter)
+	runReport := newRunReportRecorder(opts, output.NewFailureDiagnosticsEmitter(cfg.Defaults.StateDir, originalEmitter))
+	s.Emitter = runReport
+	s.run = runReport
+	ownership := s.beginFileOwnership(cfg, opts)
+	s.freeDLBudget = newFreeDLInteractiveBudget(cfg.FreeDL, opts.NoBrowser)
 	defer func() {
+		result.DeferredGates = len(s.freeDLBudget.deferred)
+		s.finishFreeDLInteractiveBudget(s.freeDLBudget)
+		s.freeDLBudget = nil
 		s.Emitter = originalEmitter
+		s.run = nil
+		s.finishRun(cfg, runReport, result, err)
+		s.finishFileOwnership(ownership)
 	}()
 
 	selected, err := selectSources(cfg.Sources, opts.SourceIDs)
 	if err != nil {
 		return result, err
 	}
+	selected = opts.Resume.filterSources(selected)
+	if !opts.NoHTTPCache {
+		if cacheDir, cacheErr := HTTPCacheDir(cfg.Defaults.StateDir); cacheErr == nil {
+			ctx = httpcache.NewContext(ctx, httpcache.New(cacheDir))
+		}
+		metadataCache := newSoundCloudMetadataCache(cfg.Defaults.StateDir, s.Now)
+		ctx = withSoundCloudMetadataCache(ctx, metadataCache)
+		defer metadataCache.save()
+	}
 
 	for _, source := range selected {
 		if source.Enabled {
 			result.Total++
+			runReport.noteSelected(source.ID)
 		}
 	}
 
@@ -128,11 +175,22 @@ func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions)
 		},
 	})
 
+	// Each source's lock is held from its preflight until the next source
+	// starts, so state and archive writes never interleave with another
+	// udl process syncing the same source.
+	unlockSource := func() {}
+	defer func() { unlockSource() }()
 	for _, source := range selected {
+		unlockSource()
+		unlockSource = func() {}
 		if !source.Enabled {
 			result.Skipped++
 			continue
 		}
+		// Per-source defaults (threads, timeout, archive file) apply to every
+		// step of this source's run.
+		cfg := cfg
+		cfg.Defaults = cfg.Defaults.ForSource(source)
 
 		if opts.Plan {
 			provider := s.planProviderForSource(source)
@@ -153,6 +211,21 @@ func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions)
 				continue
 			}
 		}
+		if (opts.OnlyGaps || opts.Limit > 0) && !supportsPlanningControls(source) {
+			result.Skipped++
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourceFinished,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] --only-gaps and --limit need per-track preflight, which adapter.kind=%s has not; skipping source", source.ID, source.Adapter.Kind),
+				Details: map[string]any{
+					"adapter_kind": source.Adapter.Kind,
+					"skipped":      true,
+				},
+			})
+			continue
+		}
 
 		adapter, ok := s.Registry[source.Adapter.Kind]
 		if !ok {
@@ -208,6 +281,29 @@ func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions)
 			continue
 		}
 
+		source, remote, stageErr := s.stageRemoteTarget(ctx, cfg, source)
+		if stageErr != nil {
+			result.Failed++
+			result.Attempted++
+			if errors.Is(stageErr, exec.ErrNotFound) {
+				result.DependencyFailures++
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelError,
+				Event:     output.EventSourceFailed,
+				SourceID:  source.ID,
+				Message:   stageErr.Error(),
+			})
+			if !cfg.Defaults.ContinueOnError {
+				break
+			}
+			continue
+		}
+		if remote != nil {
+			defer unregisterRemoteTargetListing(remote.StagingDir)
+		}
+
 		if err := ensureSourcePaths(cfg, source); err != nil {
 			result.Failed++
 			result.Attempted++
@@ -224,6 +320,40 @@ func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions)
 			continue
 		}
 
+		unlock, lockErr := s.lockSourceForSync(ctx, cfg, source, opts)
+		if lockErr != nil {
+			if ctx.Err() != nil {
+				result.Interrupted = true
+				break
+			}
+			result.Failed++
+			result.Attempted++
+			details := map[string]any{}
+			message := fmt.Sprintf("[%s] unable to lock source: %v", source.ID, lockErr)
+			var held *SourceLockError
+			if errors.As(lockErr, &held) {
+				message = fmt.Sprintf("[%s] %s is syncing this source; wait for it or rerun with --wait-lock (lock %s)", source.ID, held.holder(), held.Path)
+				details["lock_path"] = held.Path
+				details["lock_pid"] = held.PID
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelError,
+				Event:     output.EventSourceFailed,
+				SourceID:  source.ID,
+				Message:   message,
+				Details:   details,
+			})
+			if !cfg.Defaults.ContinueOnError {
+				break
+			}
+			continue
+		}
+		unlockSource = unlock
+
+		s.observeSourceOwnership(ownership, cfg, source)
+		source = s.attachSoundCloudOAuthToken(ctx, source, opts)
+
 		sourceForExec := source
 		stateSwap := soundCloudStateSwap{}
 		var sourcePreflight *SoundCloudPreflight
@@ -288,7 +418,18 @@ func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions)
 		if sourcePreflight != nil {
 			s.emitSourcePreflightSummary(source, sourcePreflight, downloadOrder)
 		}
+		// --plan picks tracks to pull by hand, which is how a monitor
+		// source is backfilled; otherwise it only records what it sees.
+		if source.Monitor && !opts.Plan {
+			flowOutcome := s.runSoundCloudMonitor(cfg, source, sourcePreflight, plannedSoundCloudTracks, stateSwap, opts)
+			applySourceOutcome(&result, flowOutcome)
+			if flowOutcome.Stop {
+				break
+			}
+			continue
+		}
 
+		postDownloadBefore := s.postDownloadSnapshot(source, opts)
 		flowOutcome := s.runSource(
 			ctx,
 			cfg,
@@ -301,6 +442,23 @@ func (s *Syncer) Sync(ctx context.Context, cfg config.Config, opts SyncOptions)
 			downloadOrder,
 			opts,
 		)
+		if !flowOutcome.Interrupted {
+			s.applyGenreRouting(ctx, cfg, source, postDownloadBefore)
+			s.applyReplayGain(ctx, source, postDownloadBefore)
+			s.applyMusicBrainz(ctx, source, postDownloadBefore)
+			s.applyLyrics(ctx, source, postDownloadBefore)
+		}
+		if !flowOutcome.Interrupted && flowOutcome.Failed == 0 {
+			s.pruneRemovedTracks(cfg, source, opts)
+		}
+		if !opts.DryRun && !flowOutcome.Interrupted {
+			s.writeSourcePlaylist(cfg, source)
+			s.writeSourceInfo(ctx, source)
+			if remote != nil {
+				flowOutcome = s.applyRemoteUpload(ctx, cfg, source, remote, flowOutcome)
+			}
+		}
+		flowOutcome = s.applySuccessCriteria(cfg, source, runReport.trackCounters(source.ID), flowOutcome, opts)
 		applySourceOutcome(&result, flowOutcome)
 		if flowOutcome.Stop {
 			break
@@ -413,20 +571,60 @@ func (s *Syncer) emitSourcePreflightSummary(source config.Source, preflight *Sou
 			preflight.PlannedDownloadCount,
 			preflight.Mode,
 			downloadOrder,
-		),
+		) + freeDownloadSkippedSuffix(preflight) + duplicateSkippedSuffix(preflight) + blocklistSkippedSuffix(preflight) + rememberedSkippedSuffix(preflight) + limitDeferredSuffix(preflight),
 		Details: map[string]any{
-			"remote_total":           preflight.RemoteTotal,
-			"known_count":            preflight.KnownCount,
-			"archive_gap_count":      preflight.ArchiveGapCount,
-			"known_gap_count":        preflight.KnownGapCount,
-			"first_existing_index":   preflight.FirstExistingIndex,
-			"planned_download_count": preflight.PlannedDownloadCount,
-			"mode":                   preflight.Mode,
-			"download_order":         string(downloadOrder),
+			"remote_total":             preflight.RemoteTotal,
+			"known_count":              preflight.KnownCount,
+			"archive_gap_count":        preflight.ArchiveGapCount,
+			"known_gap_count":          preflight.KnownGapCount,
+			"first_existing_index":     preflight.FirstExistingIndex,
+			"planned_download_count":   preflight.PlannedDownloadCount,
+			"free_dl_skipped_count":    preflight.FreeDownloadSkipped,
+			"duplicate_skipped_count":  preflight.DuplicateSkipped,
+			"blocklist_skipped_count":  preflight.BlocklistSkipped,
+			"remembered_skipped_count": preflight.RememberedSkipped,
+			"limit_deferred_count":     preflight.LimitDeferred,
+			"mode":                     preflight.Mode,
+			"download_order":           string(downloadOrder),
 		},
 	})
 }
 
+func freeDownloadSkippedSuffix(preflight *SoundCloudPreflight) string {
+	if preflight.FreeDownloadSkipped <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" free_dl_skipped=%d", preflight.FreeDownloadSkipped)
+}
+
+func duplicateSkippedSuffix(preflight *SoundCloudPreflight) string {
+	if preflight.DuplicateSkipped <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" duplicates_skipped=%d", preflight.DuplicateSkipped)
+}
+
+func blocklistSkippedSuffix(preflight *SoundCloudPreflight) string {
+	if preflight.BlocklistSkipped <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" blocklisted=%d", preflight.BlocklistSkipped)
+}
+
+func rememberedSkippedSuffix(preflight *SoundCloudPreflight) string {
+	if preflight.RememberedSkipped <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" remembered_skips=%d", preflight.RememberedSkipped)
+}
+
+func limitDeferredSuffix(preflight *SoundCloudPreflight) string {
+	if preflight.LimitDeferred <= 0 {
+		return ""
+	}
+	return fmt.Sprintf(" limit_deferred=%d", preflight.LimitDeferred)
+}
+
 func (s *Syncer) buildSourceFlowContext(source config.Source) sourceFlowContext {
 	sink := s.Progress
 	if sink == nil {
@@ -521,24 +719,49 @@ func (s *Syncer) emitTrackEvent(source config.Source, event progress.TrackEvent)
 		message = fmt.Sprintf("%s (%s)", message, event.Reason)
 	}
 
+	details := map[string]any{
+		"track_id":     strings.TrimSpace(event.TrackID),
+		"track_name":   strings.TrimSpace(event.TrackName),
+		"index":        event.Index,
+		"total":        event.Total,
+		"percent":      event.Percent,
+		"reason":       strings.TrimSpace(event.Reason),
+		"adapter_kind": strings.TrimSpace(event.AdapterKind),
+	}
+	if stage := strings.TrimSpace(event.Stage); stage != "" {
+		details["stage"] = stage
+		details["elapsed_ms"] = event.Elapsed.Milliseconds()
+		details["idle_ms"] = event.Idle.Milliseconds()
+		if event.Kind == progress.TrackProgress {
+			message = fmt.Sprintf("[%s] [%s] %s (%s)", source.ID, eventName, trackLabel, stage)
+		}
+	}
+
 	_ = s.Emitter.Emit(output.Event{
 		Timestamp: s.Now(),
 		Level:     level,
 		Event:     eventName,
 		SourceID:  source.ID,
 		Message:   message,
-		Details: map[string]any{
-			"track_id":     strings.TrimSpace(event.TrackID),
-			"track_name":   strings.TrimSpace(event.TrackName),
-			"index":        event.Index,
-			"total":        event.Total,
-			"percent":      event.Percent,
-			"reason":       strings.TrimSpace(event.Reason),
-			"adapter_kind": strings.TrimSpace(event.AdapterKind),
-		},
+		Details:   details,
 	})
 }
 
+// emitSourceTrackEvent reports a track event for flows that drive tracks
+// themselves instead of through an adapter log parser.
+func (s *Syncer) emitSourceTrackEvent(flow sourceFlowContext, source config.Source, event progress.TrackEvent) {
+	if strings.TrimSpace(event.SourceID) == "" {
+		event.SourceID = source.ID
+	}
+	if strings.TrimSpace(event.AdapterKind) == "" {
+		event.AdapterKind = source.Adapter.Kind
+	}
+	if flow.Progress != nil {
+		flow.Progress.RecordTrackEvent(event)
+	}
+	s.emitTrackEvent(source, event)
+}
+
 func (s *Syncer) runSource(
 	ctx context.Context,
 	cfg config.Config,
@@ -554,7 +777,13 @@ func (s *Syncer) runSource(
 	if source.Type == config.SourceTypeSpotify && source.Adapter.Kind == "deemix" {
 		return s.runSpotifyDeemix(ctx, cfg, source, adapter, sourceForExec, sourcePreflight, opts)
 	}
-	if source.Type == config.SourceTypeSoundCloud && source.Adapter.Kind == "scdl-freedl" {
+	if source.Type == config.SourceTypeDeezer && source.Adapter.Kind == "deemix" {
+		return s.runDeezerDeemix(ctx, cfg, source, adapter, opts)
+	}
+	if source.Type == config.SourceTypeAppleMusic && source.Adapter.Kind == "gamdl" {
+		return s.runAppleMusicGamdl(ctx, cfg, source, adapter, opts)
+	}
+	if isSoundCloudFreeDownloadFlow(source) {
 		return s.runSoundCloudFreeDL(
 			ctx,
 			cfg,
@@ -584,7 +813,26 @@ func (s *Syncer) runSoundCloudSCDL(
 	downloadOrder DownloadOrder,
 	opts SyncOptions,
 ) sourceRunOutcome {
-	return s.runGenericAdapter(ctx, cfg, source, adapter, sourceForExec, sourcePreflight, stateSwap, downloadOrder, opts)
+	if opts.DryRun {
+		return s.runGenericAdapter(ctx, cfg, source, adapter, sourceForExec, sourcePreflight, stateSwap, downloadOrder, opts)
+	}
+	before := map[string]soundCloudSyncEntry{}
+	if statePath, err := stateFileForVerify(cfg.Defaults, source); err == nil && statePath != "" {
+		if state, parseErr := parseSoundCloudSyncState(statePath); parseErr == nil {
+			before = state.ByID
+		}
+	}
+	outcome := s.runGenericAdapter(ctx, cfg, source, adapter, sourceForExec, sourcePreflight, stateSwap, downloadOrder, opts)
+	if err := s.recordSCDLProvenance(cfg, source, before); err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] unable to record download provenance: %v", source.ID, err),
+		})
+	}
+	return outcome
 }
 
 func (s *Syncer) runGenericAdapter(
@@ -685,10 +933,10 @@ func (s *Syncer) runGenericAdapter(
 		},
 	})
 
-	cleanupSuffixes := artifactSuffixesForAdapter(source.Adapter.Kind)
+	cleanupPatterns := artifactPatternsForAdapter(cfg.Defaults, source.Adapter.Kind)
 	preArtifacts := map[string]struct{}{}
-	if len(cleanupSuffixes) > 0 {
-		preArtifacts, err = snapshotArtifacts(spec.Dir, cleanupSuffixes)
+	if len(cleanupPatterns) > 0 {
+		preArtifacts, err = snapshotArtifacts(spec.Dir, cleanupPatterns)
 		if err != nil {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -711,6 +959,7 @@ func (s *Syncer) runGenericAdapter(
 				Message:   fmt.Sprintf("[%s] unable to clean temporary state file: %v", source.ID, err),
 			})
 		}
+		s.emitDryRunEstimate(cfg, source, sourcePreflight)
 		_ = s.Emitter.Emit(output.Event{
 			Timestamp: s.Now(),
 			Level:     output.LevelInfo,
@@ -728,7 +977,7 @@ func (s *Syncer) runGenericAdapter(
 	execResult := s.Runner.Run(ctx, spec)
 	s.flushFlowParser(flow, source)
 	if execResult.Interrupted {
-		s.cleanupArtifactsOnFailure(source.ID, spec.Dir, preArtifacts, cleanupSuffixes)
+		s.cleanupArtifactsOnFailure(cfg, source.ID, spec.Dir, preArtifacts, cleanupPatterns)
 		if err := cleanupTempStateFiles(stateSwap); err != nil {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -751,8 +1000,37 @@ func (s *Syncer) runGenericAdapter(
 		return outcome
 	}
 
-	if shouldRetrySpotifyWithUserAuth(sourceForExec, execResult, opts) {
-		retrySource, opensBrowser, promptErr := planSpotifyUserAuthRetry(sourceForExec, opts)
+	cachedLogin := false
+	if isSpotifyUserAuthRequired(sourceForExec, execResult) && !hasSpotDLUserAuthArg(sourceForExec.Adapter.ExtraArgs) {
+		ok, tokenErr := ensureSpotDLUserTokenFn(ctx)
+		if tokenErr != nil {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] unable to refresh cached Spotify login: %v", source.ID, tokenErr),
+			})
+		}
+		cachedLogin = ok
+	}
+	if shouldRetrySpotifyWithUserAuth(sourceForExec, execResult, opts, cachedLogin) {
+		releaseLogin := func() {}
+		if !cachedLogin {
+			unlock, loggedIn, lockErr := lockSpotDLUserTokenCacheFn(ctx)
+			if lockErr != nil {
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelWarn,
+					Event:     output.EventSourcePreflight,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] unable to lock the Spotify login cache: %v", source.ID, lockErr),
+				})
+			}
+			releaseLogin = unlock
+			cachedLogin = loggedIn
+		}
+		retrySource, opensBrowser, promptErr := planSpotifyUserAuthRetry(sourceForExec, opts, cachedLogin)
 		if promptErr != nil {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -773,11 +1051,15 @@ func (s *Syncer) runGenericAdapter(
 				SourceID:  source.ID,
 				Message:   fmt.Sprintf("[%s] spotify auth retry setup failed: %v", source.ID, retryErr),
 			})
+			releaseLogin()
 		} else {
 			retrySpec = s.applyFlowObservers(retrySpec, flow, source)
 			retryHint := "paste redirected URL in terminal when prompted"
 			retryMode := "manual"
-			if opensBrowser {
+			if cachedLogin {
+				retryHint = "using cached login"
+				retryMode = "cached"
+			} else if opensBrowser {
 				retryHint = "browser login enabled"
 				retryMode = "browser"
 			}
@@ -795,11 +1077,94 @@ func (s *Syncer) runGenericAdapter(
 				},
 			})
 			execResult = s.Runner.Run(ctx, retrySpec)
+			releaseLogin()
+			s.flushFlowParser(flow, source)
+			spec = retrySpec
+			sourceForExec = retrySource
+			if execResult.Interrupted {
+				s.cleanupArtifactsOnFailure(cfg, source.ID, spec.Dir, preArtifacts, cleanupPatterns)
+				if err := cleanupTempStateFiles(stateSwap); err != nil {
+					_ = s.Emitter.Emit(output.Event{
+						Timestamp: s.Now(),
+						Level:     output.LevelWarn,
+						Event:     output.EventSourceFailed,
+						SourceID:  source.ID,
+						Message:   fmt.Sprintf("[%s] unable to clean temporary state file: %v", source.ID, err),
+					})
+				}
+				outcome.Interrupted = true
+				outcome.Stop = true
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelError,
+					Event:     output.EventSourceFailed,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] interrupted", source.ID),
+					Details: map[string]any{
+						"exit_code":   execResult.ExitCode,
+						"duration_ms": execResult.Duration.Milliseconds(),
+					},
+				})
+				return outcome
+			}
+		}
+	}
+
+	if shouldRetrySoundCloudWithRefreshedClientID(sourceForExec, execResult) {
+		previousID, storageSource, _ := resolveSoundCloudClientIDWithSourceFn()
+		refreshedID, refreshErr := refreshSoundCloudClientIDFn(ctx)
+		switch {
+		case refreshErr != nil:
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] soundcloud client_id was rejected and refreshing it failed: %v", source.ID, refreshErr),
+			})
+		case refreshedID == strings.TrimSpace(previousID):
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] soundcloud client_id was rejected, but the web app still serves the same id; not retrying", source.ID),
+			})
+		default:
+			s.storeRefreshedSoundCloudClientID(source.ID, storageSource, refreshedID)
+			retrySource := sourceForExec
+			retrySource.SoundCloudClientID = refreshedID
+			retrySpec, retryErr := adapter.BuildExecSpec(retrySource, cfg.Defaults, timeout)
+			if retryErr != nil {
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelWarn,
+					Event:     output.EventSourceFailed,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] soundcloud client_id retry setup failed: %v", source.ID, retryErr),
+				})
+				break
+			}
+			retrySpec = s.applyFlowObservers(retrySpec, flow, source)
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] soundcloud client_id expired; retrying once with a refreshed client_id", source.ID),
+				Details: map[string]any{
+					"command": retrySpec.DisplayCommand,
+					"dir":     retrySpec.Dir,
+					"retry":   true,
+					"mode":    "client_id_refresh",
+				},
+			})
+			execResult = s.Runner.Run(ctx, retrySpec)
 			s.flushFlowParser(flow, source)
 			spec = retrySpec
 			sourceForExec = retrySource
 			if execResult.Interrupted {
-				s.cleanupArtifactsOnFailure(source.ID, spec.Dir, preArtifacts, cleanupSuffixes)
+				s.cleanupArtifactsOnFailure(cfg, source.ID, spec.Dir, preArtifacts, cleanupPatterns)
 				if err := cleanupTempStateFiles(stateSwap); err != nil {
 					_ = s.Emitter.Emit(output.Event{
 						Timestamp: s.Now(),
@@ -828,7 +1193,7 @@ func (s *Syncer) runGenericAdapter(
 	}
 
 	if execResult.ExitCode != 0 && isSpotifyUserAuthRequired(sourceForExec, execResult) {
-		guidance := "spotify login required; rerun in an interactive terminal once with --user-auth to refresh ~/.spotdl/.spotipy"
+		guidance := "spotify login required; run `udl spotify-login` (no browser needed on this machine) or rerun in an interactive terminal once with --user-auth to refresh ~/.spotdl/.spotipy"
 		if opts.AllowPrompt {
 			guidance = "spotify login required and retry did not complete; rerun sync and finish the OAuth prompt"
 		}
@@ -914,7 +1279,7 @@ func (s *Syncer) runGenericAdapter(
 			return outcome
 		}
 
-		s.cleanupArtifactsOnFailure(source.ID, spec.Dir, preArtifacts, cleanupSuffixes)
+		s.cleanupArtifactsOnFailure(cfg, source.ID, spec.Dir, preArtifacts, cleanupPatterns)
 		if err := cleanupTempStateFiles(stateSwap); err != nil {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
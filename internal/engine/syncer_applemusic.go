@@ -0,0 +1,444 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+var gamdlFinishedWithErrorsPattern = regexp.MustCompile(`finished with [1-9][0-9]* error`)
+
+type appleMusicGamdlExecutionPlan struct {
+	Source           config.Source
+	Preflight        *SoundCloudPreflight
+	PlannedTrackIDs  []string
+	ExistingTrackIDs []string
+	Unavailable      []appleMusicRemoteTrack
+	Tracks           map[string]appleMusicRemoteTrack
+	State            spotifySyncState
+	DownloadOrder    DownloadOrder
+}
+
+// runAppleMusicGamdl drives gamdl one song link at a time so each finished
+// song can be recorded in the source's apple music state file, mirroring
+// the deezer+deemix flow.
+func (s *Syncer) runAppleMusicGamdl(
+	ctx context.Context,
+	cfg config.Config,
+	source config.Source,
+	adapter Adapter,
+	opts SyncOptions,
+) sourceRunOutcome {
+	outcome := sourceRunOutcome{}
+	flow := s.buildSourceFlowContext(source)
+
+	plan, planErr := s.prepareAppleMusicGamdlExecutionPlan(ctx, cfg, source, opts)
+	if planErr != nil {
+		outcome.Failed++
+		outcome.Attempted++
+		if errors.Is(planErr, auth.ErrAppleMusicCookiesNotFound) || errors.Is(planErr, auth.ErrAppleMusicMediaUserTokenNotFound) {
+			outcome.DependencyFailures++
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelError,
+			Event:     output.EventSourceFailed,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] apple music gamdl preflight failed: %v", source.ID, planErr),
+		})
+		outcome.Stop = !cfg.Defaults.ContinueOnError
+		return outcome
+	}
+	sourceForExec := plan.Source
+	if plan.Preflight != nil {
+		s.emitSourcePreflightSummary(source, plan.Preflight, plan.DownloadOrder)
+	}
+	emitDeemixExistingTrackStatus(s, source.ID, plan.ExistingTrackIDs, plan.trackLabel, opts.TrackStatus)
+	for _, track := range plan.Unavailable {
+		display := track.displayName()
+		if display == "" {
+			display = track.ID
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [skip] %s (%s) (unavailable-on-apple-music)", source.ID, track.ID, display),
+		})
+	}
+
+	timeout := time.Duration(cfg.Defaults.CommandTimeoutSeconds) * time.Second
+	if opts.TimeoutOverride > 0 {
+		timeout = opts.TimeoutOverride
+	}
+
+	if opts.DryRun {
+		outcome.Attempted++
+		outcome.Succeeded++
+		s.emitDryRunEstimate(cfg, source, plan.Preflight)
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourceFinished,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] dry-run complete", source.ID),
+		})
+		return outcome
+	}
+
+	if plan.Preflight != nil && plan.Preflight.PlannedDownloadCount == 0 {
+		outcome.Attempted++
+		outcome.Succeeded++
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourceFinished,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] up-to-date (no downloads planned)", source.ID),
+			Details: map[string]any{
+				"planned_download_count": 0,
+				"skipped_unavailable":    len(plan.Unavailable),
+				"mode":                   plan.Preflight.Mode,
+			},
+		})
+		return outcome
+	}
+
+	plannedTrackIDs := append([]string(nil), plan.PlannedTrackIDs...)
+	if len(plannedTrackIDs) == 0 {
+		// Without preflight gamdl is handed the playlist/album link itself;
+		// a song link still yields an id for the state file.
+		plannedTrackIDs = []string{extractAppleMusicTrackID(sourceForExec.URL)}
+	}
+
+	outcome.Attempted++
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourceStarted,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] running gamdl for %d track(s) (download_order=%s)", source.ID, len(plannedTrackIDs), plan.DownloadOrder),
+		Details: map[string]any{
+			"planned_download_count": len(plannedTrackIDs),
+			"download_order":         string(plan.DownloadOrder),
+		},
+	})
+
+	sourceFailed := false
+	var sourceFailureMessage string
+	var sourceFailureDetails map[string]any
+	skippedUnavailable := len(plan.Unavailable)
+	targetDir, targetDirErr := config.ExpandTargetDir(sourceForExec.TargetDir)
+	if targetDirErr != nil {
+		sourceFailed = true
+		sourceFailureMessage = fmt.Sprintf("[%s] resolve target_dir: %v", source.ID, targetDirErr)
+	}
+	for idx, trackID := range plannedTrackIDs {
+		if sourceFailed {
+			break
+		}
+		trackSource := sourceForExec
+		if track, ok := plan.Tracks[trackID]; ok && track.URL != "" {
+			trackSource.URL = track.URL
+		}
+		trackLabel := plan.trackLabel(trackID)
+		spec, buildErr := adapter.BuildExecSpec(trackSource, cfg.Defaults, timeout)
+		if buildErr != nil {
+			sourceFailed = true
+			sourceFailureMessage = fmt.Sprintf("[%s] cannot build command: %v", source.ID, buildErr)
+			break
+		}
+		spec = s.applyFlowObservers(spec, flow, source)
+
+		if trackID != "" {
+			message := fmt.Sprintf("[%s] gamdl track %d/%d %s", source.ID, idx+1, len(plannedTrackIDs), trackID)
+			if trackLabel != "" {
+				message = fmt.Sprintf("[%s] gamdl track %d/%d %s (%s)", source.ID, idx+1, len(plannedTrackIDs), trackID, trackLabel)
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   message,
+			})
+		}
+
+		var mediaBefore map[string]mediaFileSnapshot
+		if trackID != "" {
+			before, snapshotErr := snapshotMediaFiles(targetDir)
+			if snapshotErr != nil {
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelWarn,
+					Event:     output.EventSourcePreflight,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] unable to snapshot target directory before track run: %v", source.ID, snapshotErr),
+				})
+			} else {
+				mediaBefore = before
+			}
+		}
+
+		execResult := s.Runner.Run(ctx, spec)
+		s.flushFlowParser(flow, source)
+		if execResult.Interrupted {
+			outcome.Interrupted = true
+			outcome.Stop = true
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelError,
+				Event:     output.EventSourceFailed,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] interrupted", source.ID),
+				Details:   buildExecFailureDetails(source, spec, execResult),
+			})
+			return outcome
+		}
+
+		if gamdlReportedTrackUnavailable(execResult) {
+			skippedUnavailable++
+			display := trackLabel
+			if display == "" {
+				display = trackID
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [skip] %s (%s) (unavailable-on-apple-music)", source.ID, trackID, display),
+			})
+			continue
+		}
+
+		if execResult.ExitCode != 0 {
+			sourceFailed = true
+			sourceFailureMessage = fmt.Sprintf("[%s] command failed with exit code %d", source.ID, execResult.ExitCode)
+			sourceFailureDetails = buildExecFailureDetails(source, spec, execResult)
+			break
+		}
+		if gamdlReportedFailure(execResult) {
+			sourceFailed = true
+			sourceFailureMessage = fmt.Sprintf(
+				"[%s] gamdl reported a download failure despite exit code 0; check that the Apple Music cookies file is from a signed-in session with an active subscription",
+				source.ID,
+			)
+			sourceFailureDetails = buildExecFailureDetails(source, spec, execResult)
+			break
+		}
+
+		if trackID != "" {
+			localPath := ""
+			if mediaBefore != nil {
+				after, snapshotErr := snapshotMediaFiles(targetDir)
+				if snapshotErr == nil {
+					localPath = detectUpdatedMediaPath(mediaBefore, after)
+				}
+			}
+			if appendErr := appendAppleMusicSyncStateEntry(sourceForExec.StateFile, trackID, spotifyStateEntry{
+				DisplayName: trackLabel,
+				LocalPath:   localPath,
+				Adapter:     source.Adapter.Kind,
+				AddedAt:     s.Now(),
+			}); appendErr != nil {
+				sourceFailed = true
+				sourceFailureMessage = fmt.Sprintf("[%s] failed to update apple music state file: %v", source.ID, appendErr)
+				break
+			}
+			plan.State.KnownIDs[trackID] = struct{}{}
+			doneMessage := fmt.Sprintf("[%s] [done] %s", source.ID, trackID)
+			if trackLabel != "" {
+				doneMessage = fmt.Sprintf("[%s] [done] %s (%s)", source.ID, trackID, trackLabel)
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   doneMessage,
+			})
+		}
+	}
+
+	if sourceFailed {
+		outcome.Failed++
+		if sourceFailureMessage == "" {
+			sourceFailureMessage = fmt.Sprintf("[%s] command failed", source.ID)
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelError,
+			Event:     output.EventSourceFailed,
+			SourceID:  source.ID,
+			Message:   sourceFailureMessage,
+			Details:   sourceFailureDetails,
+		})
+		outcome.Stop = !cfg.Defaults.ContinueOnError
+		return outcome
+	}
+
+	outcome.Succeeded++
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourceFinished,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] completed", source.ID),
+		Details: map[string]any{
+			"planned_download_count": len(plannedTrackIDs),
+			"skipped_unavailable":    skippedUnavailable,
+		},
+	})
+	return outcome
+}
+
+func (s *Syncer) prepareAppleMusicGamdlExecutionPlan(
+	ctx context.Context,
+	cfg config.Config,
+	source config.Source,
+	opts SyncOptions,
+) (appleMusicGamdlExecutionPlan, error) {
+	plan := appleMusicGamdlExecutionPlan{
+		Source: source,
+		Tracks: map[string]appleMusicRemoteTrack{},
+		State: spotifySyncState{
+			KnownIDs: map[string]struct{}{},
+			Entries:  map[string]spotifyStateEntry{},
+		},
+		DownloadOrder: DownloadOrderNewestFirst,
+	}
+
+	stateFilePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+	if err != nil {
+		return plan, fmt.Errorf("resolve state_file: %w", err)
+	}
+	plan.Source.StateFile = stateFilePath
+
+	mode := determineSoundCloudMode(source, opts)
+	askOnExisting := resolveAskOnExisting(source, opts)
+	if opts.NoPreflight {
+		if !source.Sync.Blocklist.Empty() {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] sync.blocklist ignored because preflight is disabled", source.ID),
+			})
+		}
+		if askOnExisting {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] ask-on-existing ignored because preflight is disabled", source.ID),
+			})
+		}
+		return plan, nil
+	}
+
+	tracks, _, err := enumerateAppleMusicTracksFn(ctx, source)
+	if err != nil {
+		return plan, err
+	}
+	if err := s.checkRemoteDrift(cfg, source, len(tracks), opts); err != nil {
+		return plan, err
+	}
+	shaped := make([]spotifyRemoteTrack, 0, len(tracks))
+	for _, track := range tracks {
+		plan.Tracks[track.ID] = track
+		shaped = append(shaped, track.spotifyShape())
+	}
+	s.noteRemoteTracks(source, spotifyPlaylistTracks(shaped))
+
+	state, err := parseAppleMusicSyncState(stateFilePath)
+	if err != nil {
+		return plan, fmt.Errorf("parse apple music sync state file: %w", err)
+	}
+	plan.State = state
+
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return plan, fmt.Errorf("resolve target_dir: %w", err)
+	}
+
+	preflight, plannedTrackIDs, existingTrackIDs, unavailable := buildAppleMusicPreflight(tracks, state, targetDir, mode)
+	if askOnExisting &&
+		mode == SoundCloudModeBreak &&
+		preflight.FirstExistingIndex > 0 &&
+		opts.AllowPrompt &&
+		opts.PromptOnExisting != nil {
+		shouldScanGaps, promptErr := opts.PromptOnExisting(source.ID, preflight)
+		if promptErr != nil {
+			return plan, promptErr
+		}
+		if shouldScanGaps {
+			mode = SoundCloudModeScanGaps
+			preflight, plannedTrackIDs, existingTrackIDs, unavailable = buildAppleMusicPreflight(tracks, state, targetDir, mode)
+		}
+	}
+
+	plan.Preflight = &preflight
+	plan.PlannedTrackIDs = orderForExecution(plannedTrackIDs, plan.DownloadOrder)
+	if _, resuming := opts.Resume.pendingTracks(source.ID); resuming {
+		plan.PlannedTrackIDs = opts.Resume.restrictTrackIDs(source.ID, plan.PlannedTrackIDs)
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+	}
+	dedupeTracks := make(map[string]crossSourceTrack, len(tracks))
+	for _, track := range tracks {
+		dedupeTracks[track.ID] = crossSourceTrack{Key: crossSourceTrackKey(track.Artist, track.Title), Label: track.displayName()}
+	}
+	var duplicates int
+	plan.PlannedTrackIDs, duplicates = s.skipCrossSourceDuplicates(cfg, source, plan.PlannedTrackIDs, dedupeTracks)
+	if duplicates > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.DuplicateSkipped = duplicates
+	}
+	var blocked int
+	plan.PlannedTrackIDs, blocked = s.skipBlockedTracks(source, plan.PlannedTrackIDs, spotifyPlaylistTracks(shaped))
+	if blocked > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.BlocklistSkipped = blocked
+	}
+	plan.PlannedTrackIDs = applySyncLimit(source, opts, &preflight, plan.PlannedTrackIDs, spotifyPlaylistTracks(shaped))
+	s.notePlannedTracks(source.ID, plan.PlannedTrackIDs)
+	s.noteTrackKeys(source, plan.PlannedTrackIDs, dedupeTracks)
+	plan.ExistingTrackIDs = existingTrackIDs
+	plan.Unavailable = unavailable
+	return plan, nil
+}
+
+func (p appleMusicGamdlExecutionPlan) trackLabel(trackID string) string {
+	if track, ok := p.Tracks[trackID]; ok {
+		if label := track.displayName(); label != "" {
+			return label
+		}
+	}
+	return strings.TrimSpace(p.State.Entries[trackID].DisplayName)
+}
+
+// gamdlReportedTrackUnavailable matches gamdl's per-song skip for songs not
+// licensed in the account's storefront.
+func gamdlReportedTrackUnavailable(execResult ExecResult) bool {
+	combined := strings.ToLower(execResult.StdoutTail + "\n" + execResult.StderrTail)
+	return strings.Contains(combined, "not streamable") ||
+		strings.Contains(combined, "not available in your country")
+}
+
+// gamdlReportedFailure catches gamdl runs that log a per-song error but still
+// exit 0.
+func gamdlReportedFailure(execResult ExecResult) bool {
+	combined := strings.ToLower(execResult.StdoutTail + "\n" + execResult.StderrTail)
+	return strings.Contains(combined, "failed to download") ||
+		gamdlFinishedWithErrorsPattern.MatchString(combined)
+}
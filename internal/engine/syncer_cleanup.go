@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -141,7 +142,7 @@ func commitTempStateFiles(stateSwap soundCloudStateSwap) error {
 }
 
 func ensureSourcePaths(cfg config.Config, source config.Source) error {
-	targetDir, err := config.ExpandPath(source.TargetDir)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
 	if err != nil {
 		return fmt.Errorf("[%s] invalid target_dir: %w", source.ID, err)
 	}
@@ -153,7 +154,7 @@ func ensureSourcePaths(cfg config.Config, source config.Source) error {
 		return fmt.Errorf("[%s] target_dir is not a directory: %s", source.ID, targetDir)
 	}
 
-	if source.Type == config.SourceTypeSpotify || source.Type == config.SourceTypeSoundCloud {
+	if source.Type == config.SourceTypeSpotify || source.Type == config.SourceTypeSoundCloud || source.Type == config.SourceTypeDeezer || source.Type == config.SourceTypeAppleMusic {
 		stateFile, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
 		if err != nil {
 			return fmt.Errorf("[%s] invalid state_file: %w", source.ID, err)
@@ -167,21 +168,65 @@ func ensureSourcePaths(cfg config.Config, source config.Source) error {
 			return fmt.Errorf("[%s] state directory is not a directory: %s", source.ID, stateDir)
 		}
 	}
+	if source.Type == config.SourceTypeYouTube {
+		archivePath, err := config.ResolveArchiveFile(cfg.Defaults.StateDir, cfg.Defaults.ArchiveFile, source.ID)
+		if err != nil {
+			return fmt.Errorf("[%s] invalid archive_file: %w", source.ID, err)
+		}
+		archiveDir := filepath.Dir(archivePath)
+		archiveInfo, archiveErr := os.Stat(archiveDir)
+		if archiveErr != nil {
+			return fmt.Errorf("[%s] state directory does not exist: %s", source.ID, archiveDir)
+		}
+		if !archiveInfo.IsDir() {
+			return fmt.Errorf("[%s] state directory is not a directory: %s", source.ID, archiveDir)
+		}
+	}
 
 	return nil
 }
 
-func artifactSuffixesForAdapter(adapterKind string) []string {
-	suffixes := []string{".part", ".ytdl"}
-	if adapterKind == "scdl" {
-		suffixes = append(suffixes, ".scdl.lock", ".jpg", ".jpeg", ".png", ".webp")
+// defaultArtifactPatterns are the leftovers each adapter kind is known to
+// leave behind when a run fails; kinds not listed get the yt-dlp partials.
+var defaultArtifactPatterns = map[string][]string{
+	"scdl":        {"*.part", "*.ytdl", "*.scdl.lock", "*.jpg", "*.jpeg", "*.png", "*.webp"},
+	"scdl-freedl": {"*.part", "*.ytdl", "*.scdl.lock", "*.jpg", "*.jpeg", "*.png", "*.webp"},
+	"ytdlp":       {"*.part", "*.ytdl", "*.jpg", "*.jpeg", "*.png", "*.webp"},
+}
+
+var fallbackArtifactPatterns = []string{"*.part", "*.ytdl"}
+
+// artifactPatternsForAdapter returns the cleanup globs for adapterKind:
+// defaults.artifact_cleanup.adapters (already merged with the source's
+// artifact_patterns by Defaults.ForSource), then the built-in list. It
+// returns nil when keep_artifacts is set.
+func artifactPatternsForAdapter(defaults config.Defaults, adapterKind string) []string {
+	if defaults.ArtifactCleanup.KeepArtifacts {
+		return nil
+	}
+	if patterns, ok := defaults.ArtifactCleanup.Adapters[adapterKind]; ok {
+		return patterns
+	}
+	if patterns, ok := defaultArtifactPatterns[adapterKind]; ok {
+		return patterns
+	}
+	return fallbackArtifactPatterns
+}
+
+// matchesArtifactPattern matches a file against one cleanup glob: by name,
+// or by its slash-separated path under dir when the pattern has a "/".
+func matchesArtifactPattern(pattern string, rel string, name string) bool {
+	subject := name
+	if strings.Contains(pattern, "/") {
+		subject = rel
 	}
-	return suffixes
+	matched, err := path.Match(pattern, subject)
+	return err == nil && matched
 }
 
-func snapshotArtifacts(dir string, suffixes []string) (map[string]struct{}, error) {
+func snapshotArtifacts(dir string, patterns []string) (map[string]struct{}, error) {
 	seen := map[string]struct{}{}
-	if len(suffixes) == 0 {
+	if len(patterns) == 0 {
 		return seen, nil
 	}
 
@@ -192,17 +237,21 @@ func snapshotArtifacts(dir string, suffixes []string) (map[string]struct{}, erro
 		return nil, err
 	}
 
-	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, walkErr error) error {
+	err := filepath.WalkDir(dir, func(filePath string, d os.DirEntry, walkErr error) error {
 		if walkErr != nil {
 			return walkErr
 		}
 		if d.IsDir() {
 			return nil
 		}
-		name := d.Name()
-		for _, suffix := range suffixes {
-			if strings.HasSuffix(name, suffix) {
-				seen[path] = struct{}{}
+		rel, relErr := filepath.Rel(dir, filePath)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+		for _, pattern := range patterns {
+			if matchesArtifactPattern(pattern, rel, d.Name()) {
+				seen[filePath] = struct{}{}
 				break
 			}
 		}
@@ -214,8 +263,8 @@ func snapshotArtifacts(dir string, suffixes []string) (map[string]struct{}, erro
 	return seen, nil
 }
 
-func cleanupNewArtifacts(dir string, baseline map[string]struct{}, suffixes []string) ([]string, error) {
-	current, err := snapshotArtifacts(dir, suffixes)
+func cleanupNewArtifacts(dir string, baseline map[string]struct{}, patterns []string) ([]string, error) {
+	current, err := snapshotArtifacts(dir, patterns)
 	if err != nil {
 		return nil, err
 	}
@@ -234,12 +283,19 @@ func cleanupNewArtifacts(dir string, baseline map[string]struct{}, suffixes []st
 	return removed, nil
 }
 
-func (s *Syncer) cleanupArtifactsOnFailure(sourceID string, dir string, preArtifacts map[string]struct{}, suffixes []string) {
-	if len(suffixes) == 0 {
+// cleanupArtifactsOnFailure removes the artifacts a failed run left in dir,
+// or moves them to the source's quarantine folder when
+// defaults.artifact_cleanup.quarantine is set.
+func (s *Syncer) cleanupArtifactsOnFailure(cfg config.Config, sourceID string, dir string, preArtifacts map[string]struct{}, patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	if cfg.Defaults.ArtifactCleanup.Quarantine {
+		s.quarantineArtifactsOnFailure(cfg, sourceID, dir, preArtifacts, patterns)
 		return
 	}
 
-	removed, err := cleanupNewArtifacts(dir, preArtifacts, suffixes)
+	removed, err := cleanupNewArtifacts(dir, preArtifacts, patterns)
 	if err != nil {
 		_ = s.Emitter.Emit(output.Event{
 			Timestamp: s.Now(),
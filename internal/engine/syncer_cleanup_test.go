@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestArtifactPatternsForAdapterHonorsConfigAndKeepArtifacts(t *testing.T) {
+	defaults := config.Defaults{}
+	if got := strings.Join(artifactPatternsForAdapter(defaults, "scdl"), ","); !strings.Contains(got, "*.scdl.lock") {
+		t.Fatalf("expected built-in scdl patterns, got %s", got)
+	}
+	if got := artifactPatternsForAdapter(defaults, "spotdl"); strings.Join(got, ",") != "*.part,*.ytdl" {
+		t.Fatalf("expected fallback patterns, got %v", got)
+	}
+	defaults.ArtifactCleanup.Adapters = map[string][]string{"spotdl": {}}
+	if got := artifactPatternsForAdapter(defaults, "spotdl"); len(got) != 0 || got == nil {
+		t.Fatalf("expected configured empty list to clean nothing, got %v", got)
+	}
+	defaults.ArtifactCleanup.KeepArtifacts = true
+	if got := artifactPatternsForAdapter(defaults, "scdl"); got != nil {
+		t.Fatalf("expected keep_artifacts to disable cleanup, got %v", got)
+	}
+}
+
+func TestCleanupNewArtifactsMatchesGlobsAndKeepsBaseline(t *testing.T) {
+	dir := t.TempDir()
+	write := func(rel string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	patterns := []string{"*.part", "tmp/*.json"}
+	write("old.part")
+	baseline, err := snapshotArtifacts(dir, patterns)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	write("new.mp3.part")
+	write("tmp/info.json")
+	write("album/info.json")
+	write("track.mp3")
+
+	removed, err := cleanupNewArtifacts(dir, baseline, patterns)
+	if err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	want := []string{filepath.Join(dir, "new.mp3.part"), filepath.Join(dir, "tmp", "info.json")}
+	if strings.Join(removed, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected removed artifacts %v", removed)
+	}
+	for _, rel := range []string{"old.part", "album/info.json", "track.mp3"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			t.Fatalf("expected %s to be kept: %v", rel, err)
+		}
+	}
+}
+
+func TestQuarantineNewArtifactsMovesFilesAndWritesManifest(t *testing.T) {
+	tmp := t.TempDir()
+	dir := filepath.Join(tmp, "music")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(filepath.Join(dir, "album"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "old.part"), []byte("old"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	patterns := []string{"*.part"}
+	baseline, err := snapshotArtifacts(dir, patterns)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "album", "track.mp3.part"), []byte("partial"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	now := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	quarantineDir, files, err := quarantineNewArtifacts(stateDir, "sc", dir, baseline, patterns, now)
+	if err != nil {
+		t.Fatalf("quarantine: %v", err)
+	}
+	if quarantineDir != filepath.Join(stateDir, "quarantine", "sc", "20260501-100000") {
+		t.Fatalf("unexpected quarantine dir %s", quarantineDir)
+	}
+	if len(files) != 1 || files[0].Path != "files/album/track.mp3.part" || files[0].Bytes != 7 {
+		t.Fatalf("unexpected quarantined files %+v", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "album", "track.mp3.part")); !os.IsNotExist(err) {
+		t.Fatalf("expected artifact to leave target_dir, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.part")); err != nil {
+		t.Fatalf("expected baseline artifact to stay: %v", err)
+	}
+	payload, err := os.ReadFile(filepath.Join(quarantineDir, "manifest.json"))
+	if err != nil {
+		t.Fatalf("read manifest: %v", err)
+	}
+	var manifest ArtifactQuarantineManifest
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if manifest.SourceID != "sc" || len(manifest.Files) != 1 || manifest.Files[0].OriginalPath != filepath.Join(dir, "album", "track.mp3.part") {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "again.part"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	second, _, err := quarantineNewArtifacts(stateDir, "sc", dir, baseline, patterns, now)
+	if err != nil || second != quarantineDir+"-2" {
+		t.Fatalf("expected a second folder for the same second, got %s (%v)", second, err)
+	}
+}
@@ -0,0 +1,545 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/auth"
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+type deezerDeemixExecutionPlan struct {
+	Source           config.Source
+	Preflight        *SoundCloudPreflight
+	PlannedTrackIDs  []string
+	ExistingTrackIDs []string
+	Unavailable      []deezerRemoteTrack
+	Tracks           map[string]deezerRemoteTrack
+	State            spotifySyncState
+	DownloadOrder    DownloadOrder
+	// FallbackOnly holds planned tracks Deezer lists as unreadable; they go
+	// straight to the source's adapter.fallback chain.
+	FallbackOnly map[string]struct{}
+}
+
+// runDeezerDeemix drives deemix one Deezer track link at a time. Unlike the
+// spotify bridge there is no Spotify lookup: ids come straight from the
+// Deezer API and land in the source's own deezer state file.
+func (s *Syncer) runDeezerDeemix(
+	ctx context.Context,
+	cfg config.Config,
+	source config.Source,
+	adapter Adapter,
+	opts SyncOptions,
+) sourceRunOutcome {
+	outcome := sourceRunOutcome{}
+	flow := s.buildSourceFlowContext(source)
+
+	plan, planErr := s.prepareDeezerDeemixExecutionPlan(ctx, cfg, source, opts)
+	if planErr != nil {
+		outcome.Failed++
+		outcome.Attempted++
+		if errors.Is(planErr, auth.ErrDeemixARLNotFound) {
+			outcome.DependencyFailures++
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelError,
+			Event:     output.EventSourceFailed,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] deezer deemix preflight failed: %v", source.ID, planErr),
+		})
+		outcome.Stop = !cfg.Defaults.ContinueOnError
+		return outcome
+	}
+	sourceForExec := plan.Source
+	if plan.Preflight != nil {
+		s.emitSourcePreflightSummary(source, plan.Preflight, plan.DownloadOrder)
+	}
+	emitDeemixExistingTrackStatus(s, source.ID, plan.ExistingTrackIDs, plan.trackLabel, opts.TrackStatus)
+	for _, track := range plan.Unavailable {
+		display := track.displayName()
+		if display == "" {
+			display = track.ID
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [skip] %s (%s) (unavailable-on-deezer)", source.ID, track.ID, display),
+		})
+	}
+
+	timeout := time.Duration(cfg.Defaults.CommandTimeoutSeconds) * time.Second
+	if opts.TimeoutOverride > 0 {
+		timeout = opts.TimeoutOverride
+	}
+
+	if opts.DryRun {
+		outcome.Attempted++
+		outcome.Succeeded++
+		s.emitDryRunEstimate(cfg, source, plan.Preflight)
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourceFinished,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] dry-run complete", source.ID),
+		})
+		return outcome
+	}
+
+	if plan.Preflight != nil && plan.Preflight.PlannedDownloadCount == 0 {
+		outcome.Attempted++
+		outcome.Succeeded++
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourceFinished,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] up-to-date (no downloads planned)", source.ID),
+			Details: map[string]any{
+				"planned_download_count": 0,
+				"skipped_unavailable":    len(plan.Unavailable),
+				"mode":                   plan.Preflight.Mode,
+			},
+		})
+		return outcome
+	}
+
+	plannedTrackIDs := append([]string(nil), plan.PlannedTrackIDs...)
+	if len(plannedTrackIDs) == 0 {
+		// Without preflight deemix is handed the playlist/album link itself;
+		// a single track link still yields an id for the state file.
+		plannedTrackIDs = []string{extractDeezerTrackID(sourceForExec.URL)}
+	}
+
+	outcome.Attempted++
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourceStarted,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] running deemix for %d track(s) (download_order=%s)", source.ID, len(plannedTrackIDs), plan.DownloadOrder),
+		Details: map[string]any{
+			"planned_download_count": len(plannedTrackIDs),
+			"download_order":         string(plan.DownloadOrder),
+		},
+	})
+
+	runtimeDir := strings.TrimSpace(sourceForExec.DeemixRuntimeDir)
+	sourceFailed := false
+	var sourceFailureMessage string
+	var sourceFailureDetails map[string]any
+	skippedUnavailable := len(plan.Unavailable)
+	targetDir, targetDirErr := config.ExpandTargetDir(sourceForExec.TargetDir)
+	if targetDirErr != nil {
+		sourceFailed = true
+		sourceFailureMessage = fmt.Sprintf("[%s] resolve target_dir: %v", source.ID, targetDirErr)
+	}
+	qualityTiers := deemixQualityTiers(sourceForExec)
+	// Tracks the stall watchdog cancels are appended once more and retried
+	// after the rest of the plan.
+	stalledOnce := map[string]struct{}{}
+	skippedStalled := 0
+	for idx := 0; idx < len(plannedTrackIDs); idx++ {
+		if sourceFailed {
+			break
+		}
+		trackID := plannedTrackIDs[idx]
+		trackSource := sourceForExec
+		if trackID != "" {
+			trackSource.URL = deezerTrackURL(trackID)
+			if targetDirErr == nil {
+				dir := s.routeDeemixTrack(ctx, sourceForExec, targetDir, trackID, func(ctx context.Context) ([]string, error) {
+					return fetchDeezerTrackGenresFn(ctx, trackID)
+				})
+				if dir != targetDir {
+					trackSource.TargetDir = dir
+				}
+			}
+		}
+		trackLabel := plan.trackLabel(trackID)
+		buildSpec := func(quality string) (ExecSpec, error) {
+			trackSource.DeemixRuntimeDir = runtimeDir
+			trackSource.DeemixBitrate = quality
+			spec, err := adapter.BuildExecSpec(trackSource, cfg.Defaults, timeout)
+			if err != nil {
+				return spec, err
+			}
+			spec = s.applyFlowObservers(spec, flow, source)
+			if runtimeDir == "" {
+				runtimeDir = spec.Dir
+				sourceForExec.DeemixRuntimeDir = spec.Dir
+			}
+			spec.Dir = runtimeDir
+			return spec, nil
+		}
+		// Tracks Deezer lists as unreadable only reach this loop when an
+		// adapter.fallback chain can try them; deemix is not run for those.
+		_, fallbackOnly := plan.FallbackOnly[trackID]
+		var spec ExecSpec
+		if !fallbackOnly {
+			built, buildErr := buildSpec(qualityTiers[0])
+			if buildErr != nil {
+				sourceFailed = true
+				sourceFailureMessage = fmt.Sprintf("[%s] cannot build command: %v", source.ID, buildErr)
+				break
+			}
+			spec = built
+		}
+
+		if trackID != "" {
+			message := fmt.Sprintf("[%s] deemix track %d/%d %s", source.ID, idx+1, len(plannedTrackIDs), trackID)
+			if trackLabel != "" {
+				message = fmt.Sprintf("[%s] deemix track %d/%d %s (%s)", source.ID, idx+1, len(plannedTrackIDs), trackID, trackLabel)
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   message,
+			})
+		}
+
+		var mediaBefore map[string]mediaFileSnapshot
+		if trackID != "" {
+			before, snapshotErr := snapshotMediaFiles(targetDir)
+			if snapshotErr != nil {
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelWarn,
+					Event:     output.EventSourcePreflight,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] unable to snapshot target directory before track run: %v", source.ID, snapshotErr),
+				})
+			} else {
+				mediaBefore = before
+			}
+		}
+
+		var execResult ExecResult
+		quality := ""
+		unavailable, reason := fallbackOnly, "unavailable-on-deezer"
+		if !fallbackOnly {
+			var buildErr error
+			spec, execResult, quality, buildErr = s.runDeemixTrack(ctx, source, trackID, qualityTiers, spec, flow, buildSpec, targetDir)
+			if buildErr != nil {
+				sourceFailed = true
+				sourceFailureMessage = fmt.Sprintf("[%s] cannot build command: %v", source.ID, buildErr)
+				break
+			}
+			if execResult.Stalled && trackID != "" {
+				if mediaBefore != nil {
+					if after, snapshotErr := snapshotMediaFiles(targetDir); snapshotErr == nil {
+						removeStalledPartial(targetDir, mediaBefore, newMediaPaths(mediaBefore, after)...)
+					}
+				}
+				display := trackLabel
+				if display == "" {
+					display = trackID
+				}
+				_, retried := stalledOnce[trackID]
+				if !retried {
+					stalledOnce[trackID] = struct{}{}
+					plannedTrackIDs = append(plannedTrackIDs, trackID)
+				} else {
+					skippedStalled++
+				}
+				s.emitTrackStalled(source, trackID, display, !retried)
+				continue
+			}
+			unavailable, reason = deemixReportedTrackUnavailable(execResult)
+		}
+		provider := ""
+		if unavailable && trackID != "" && len(trackSource.Adapter.Fallback) > 0 {
+			track := plan.Tracks[trackID]
+			fallbackTrack := FallbackTrack{ID: trackID, Artist: track.Artist, Title: track.Title}
+			fallbackProvider, fallbackSpec, fallbackResult := s.runTrackFallbacks(ctx, source, trackSource, cfg.Defaults, fallbackTrack, reason, timeout)
+			if fallbackResult.Interrupted {
+				spec, execResult = fallbackSpec, fallbackResult
+			} else if fallbackProvider != "" {
+				provider, spec, execResult, quality = fallbackProvider, fallbackSpec, fallbackResult, ""
+				unavailable = false
+			}
+		}
+		if execResult.Interrupted {
+			_ = cleanupRuntimeDir(runtimeDir)
+			outcome.Interrupted = true
+			outcome.Stop = true
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelError,
+				Event:     output.EventSourceFailed,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] interrupted", source.ID),
+				Details:   buildExecFailureDetails(source, spec, execResult),
+			})
+			return outcome
+		}
+
+		if unavailable {
+			skippedUnavailable++
+			display := trackLabel
+			if display == "" {
+				display = deemixTrackDisplayName(execResult)
+			}
+			if display == "" {
+				display = trackID
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] [skip] %s (%s) (%s)", source.ID, trackID, display, reason),
+			})
+			continue
+		}
+
+		if execResult.ExitCode != 0 {
+			sourceFailed = true
+			sourceFailureMessage = fmt.Sprintf("[%s] command failed with exit code %d", source.ID, execResult.ExitCode)
+			sourceFailureDetails = buildExecFailureDetails(source, spec, execResult)
+			break
+		}
+		if failed, reason := deemixReportedFailure(execResult); failed && provider == "" {
+			sourceFailed = true
+			sourceFailureMessage = fmt.Sprintf(
+				"[%s] deemix reported runtime failure despite exit code 0 (%s); check the Deezer ARL in `udl tui` Credentials or UDL_DEEMIX_ARL",
+				source.ID,
+				reason,
+			)
+			sourceFailureDetails = buildExecFailureDetails(source, spec, execResult)
+			break
+		}
+
+		if trackID != "" {
+			entryLabel := trackLabel
+			if entryLabel == "" {
+				entryLabel = deemixTrackDisplayName(execResult)
+			}
+			localPath := ""
+			if mediaBefore != nil {
+				after, snapshotErr := snapshotMediaFiles(targetDir)
+				if snapshotErr == nil {
+					localPath = detectUpdatedMediaPath(mediaBefore, after)
+				}
+			}
+			if appendErr := appendDeezerSyncStateEntry(sourceForExec.StateFile, trackID, spotifyStateEntry{
+				DisplayName: entryLabel,
+				LocalPath:   localPath,
+				Quality:     quality,
+				Provider:    provider,
+				Adapter:     source.Adapter.Kind,
+				AddedAt:     s.Now(),
+			}); appendErr != nil {
+				sourceFailed = true
+				sourceFailureMessage = fmt.Sprintf("[%s] failed to update deezer state file: %v", source.ID, appendErr)
+				break
+			}
+			plan.State.KnownIDs[trackID] = struct{}{}
+			doneMessage := fmt.Sprintf("[%s] [done] %s", source.ID, trackID)
+			if entryLabel != "" {
+				doneMessage = fmt.Sprintf("[%s] [done] %s (%s)", source.ID, trackID, entryLabel)
+			}
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelInfo,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   doneMessage,
+			})
+		}
+	}
+
+	_ = cleanupRuntimeDir(runtimeDir)
+
+	if sourceFailed {
+		outcome.Failed++
+		if sourceFailureMessage == "" {
+			sourceFailureMessage = fmt.Sprintf("[%s] command failed", source.ID)
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelError,
+			Event:     output.EventSourceFailed,
+			SourceID:  source.ID,
+			Message:   sourceFailureMessage,
+			Details:   sourceFailureDetails,
+		})
+		outcome.Stop = !cfg.Defaults.ContinueOnError
+		return outcome
+	}
+
+	outcome.Succeeded++
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     output.LevelInfo,
+		Event:     output.EventSourceFinished,
+		SourceID:  source.ID,
+		Message:   fmt.Sprintf("[%s] completed", source.ID),
+		Details: map[string]any{
+			"planned_download_count": len(plannedTrackIDs) - len(stalledOnce),
+			"skipped_unavailable":    skippedUnavailable,
+			"skipped_stalled":        skippedStalled,
+		},
+	})
+	return outcome
+}
+
+func (s *Syncer) prepareDeezerDeemixExecutionPlan(
+	ctx context.Context,
+	cfg config.Config,
+	source config.Source,
+	opts SyncOptions,
+) (deezerDeemixExecutionPlan, error) {
+	plan := deezerDeemixExecutionPlan{
+		Source: source,
+		Tracks: map[string]deezerRemoteTrack{},
+		State: spotifySyncState{
+			KnownIDs: map[string]struct{}{},
+			Entries:  map[string]spotifyStateEntry{},
+		},
+		DownloadOrder: DownloadOrderNewestFirst,
+	}
+
+	stateFilePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+	if err != nil {
+		return plan, fmt.Errorf("resolve state_file: %w", err)
+	}
+	plan.Source.StateFile = stateFilePath
+
+	arl, err := s.resolveDeemixARLForSource(source, opts)
+	if err != nil {
+		return plan, err
+	}
+	plan.Source.DeezerARL = arl
+
+	mode := determineSoundCloudMode(source, opts)
+	askOnExisting := resolveAskOnExisting(source, opts)
+	if opts.NoPreflight {
+		if !source.Sync.Blocklist.Empty() {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] sync.blocklist ignored because preflight is disabled", source.ID),
+			})
+		}
+		if askOnExisting {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] ask-on-existing ignored because preflight is disabled", source.ID),
+			})
+		}
+		return plan, nil
+	}
+
+	tracks, err := enumerateDeezerTracksFn(ctx, source)
+	if err != nil {
+		return plan, err
+	}
+	if err := s.checkRemoteDrift(cfg, source, len(tracks), opts); err != nil {
+		return plan, err
+	}
+	shaped := make([]spotifyRemoteTrack, 0, len(tracks))
+	for _, track := range tracks {
+		plan.Tracks[track.ID] = track
+		shaped = append(shaped, track.spotifyShape())
+	}
+	s.noteRemoteTracks(source, spotifyPlaylistTracks(shaped))
+
+	state, err := parseDeezerSyncState(stateFilePath)
+	if err != nil {
+		return plan, fmt.Errorf("parse deezer sync state file: %w", err)
+	}
+	plan.State = state
+
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
+	if err != nil {
+		return plan, fmt.Errorf("resolve target_dir: %w", err)
+	}
+
+	preflight, plannedTrackIDs, existingTrackIDs, unavailable := buildDeezerPreflight(tracks, state, targetDir, mode)
+	if askOnExisting &&
+		mode == SoundCloudModeBreak &&
+		preflight.FirstExistingIndex > 0 &&
+		opts.AllowPrompt &&
+		opts.PromptOnExisting != nil {
+		shouldScanGaps, promptErr := opts.PromptOnExisting(source.ID, preflight)
+		if promptErr != nil {
+			return plan, promptErr
+		}
+		if shouldScanGaps {
+			mode = SoundCloudModeScanGaps
+			preflight, plannedTrackIDs, existingTrackIDs, unavailable = buildDeezerPreflight(tracks, state, targetDir, mode)
+		}
+	}
+
+	plan.Preflight = &preflight
+	plan.PlannedTrackIDs = orderForExecution(plannedTrackIDs, plan.DownloadOrder)
+	if len(source.Adapter.Fallback) > 0 && len(unavailable) > 0 {
+		plan.FallbackOnly = map[string]struct{}{}
+		for _, track := range unavailable {
+			if _, known := state.KnownIDs[track.ID]; known {
+				continue
+			}
+			plan.FallbackOnly[track.ID] = struct{}{}
+			plan.PlannedTrackIDs = append(plan.PlannedTrackIDs, track.ID)
+		}
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		unavailable = nil
+	}
+	if _, resuming := opts.Resume.pendingTracks(source.ID); resuming {
+		plan.PlannedTrackIDs = opts.Resume.restrictTrackIDs(source.ID, plan.PlannedTrackIDs)
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+	}
+	dedupeTracks := make(map[string]crossSourceTrack, len(tracks))
+	for _, track := range tracks {
+		dedupeTracks[track.ID] = crossSourceTrack{Key: crossSourceTrackKey(track.Artist, track.Title), Label: track.displayName()}
+	}
+	var duplicates int
+	plan.PlannedTrackIDs, duplicates = s.skipCrossSourceDuplicates(cfg, source, plan.PlannedTrackIDs, dedupeTracks)
+	if duplicates > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.DuplicateSkipped = duplicates
+	}
+	var blocked int
+	plan.PlannedTrackIDs, blocked = s.skipBlockedTracks(source, plan.PlannedTrackIDs, spotifyPlaylistTracks(shaped))
+	if blocked > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.BlocklistSkipped = blocked
+	}
+	var remembered int
+	plan.PlannedTrackIDs, remembered = s.skipRememberedTracks(cfg, source, plan.PlannedTrackIDs, spotifyPlaylistTracks(shaped))
+	if remembered > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.RememberedSkipped = remembered
+	}
+	plan.PlannedTrackIDs = applySyncLimit(source, opts, &preflight, plan.PlannedTrackIDs, spotifyPlaylistTracks(shaped))
+	s.notePlannedTracks(source.ID, plan.PlannedTrackIDs)
+	s.noteTrackKeys(source, plan.PlannedTrackIDs, dedupeTracks)
+	plan.ExistingTrackIDs = existingTrackIDs
+	plan.Unavailable = unavailable
+	return plan, nil
+}
+
+func (p deezerDeemixExecutionPlan) trackLabel(trackID string) string {
+	if track, ok := p.Tracks[trackID]; ok {
+		if label := track.displayName(); label != "" {
+			return label
+		}
+	}
+	return strings.TrimSpace(p.State.Entries[trackID].DisplayName)
+}
@@ -2,9 +2,11 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 
+	"github.com/jaa/update-downloads/internal/auth"
 	"github.com/jaa/update-downloads/internal/config"
 	"github.com/jaa/update-downloads/internal/output"
 )
@@ -83,6 +85,14 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 	opts SyncOptions,
 ) (soundCloudExecutionPlan, error) {
 	plan := soundCloudExecutionPlan{Source: source}
+	if config.IsSoundCloudStationURL(source.URL) {
+		if opts.NoPreflight {
+			return plan, fmt.Errorf("soundcloud station sources require preflight planning; remove --no-preflight")
+		}
+		// scdl --sync deletes files whose tracks left the listing, and a
+		// station reshuffles every time it is listed.
+		plan.Source.DisableSyncMode = true
+	}
 
 	stateFilePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
 	if err != nil {
@@ -100,6 +110,27 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 		if source.Adapter.Kind == "scdl-freedl" {
 			return plan, fmt.Errorf("soundcloud adapter.kind=scdl-freedl requires preflight planning; remove --no-preflight")
 		}
+		if isSoundCloudFreeDownloadFlow(source) {
+			return plan, fmt.Errorf("soundcloud sync.free_downloads=only requires preflight planning; remove --no-preflight")
+		}
+		if config.FreeDownloadsPolicy(source) == config.FreeDownloadsSkip {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] free_downloads=skip ignored because preflight is disabled", source.ID),
+			})
+		}
+		if !source.Sync.Blocklist.Empty() {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] sync.blocklist ignored because preflight is disabled", source.ID),
+			})
+		}
 		if askOnExisting {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -126,8 +157,12 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 		return plan, err
 	}
 	tracks := enumerateStage.Tracks
+	if err := s.checkRemoteDrift(cfg, source, len(tracks), opts); err != nil {
+		return plan, err
+	}
+	s.noteRemoteTracks(source, soundCloudPlaylistTracks(tracks))
 
-	targetDir, err := config.ExpandPath(source.TargetDir)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
 	if err != nil {
 		return plan, fmt.Errorf("resolve target_dir: %w", err)
 	}
@@ -149,7 +184,9 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 	archiveKnownIDs := archiveStage.KnownIDs
 
 	cacheEnabled := source.Sync.LocalIndexCache != nil && *source.Sync.LocalIndexCache
-	needsLocalIndex := needsSoundCloudLocalIndex(tracks, state, archiveKnownIDs, targetDir)
+	// With the cache on, state paths are checked against the cached file
+	// list instead of one stat per entry, which is slow on network target_dirs.
+	needsLocalIndex := cacheEnabled || needsSoundCloudLocalIndex(tracks, state, archiveKnownIDs, targetDir)
 	localIndexStage, err := loadSoundCloudLocalIndexStage(soundCloudLocalIndexStageInput{
 		SourceID:  source.ID,
 		TargetDir: targetDir,
@@ -166,6 +203,7 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 		State:          state,
 		ArchiveKnownID: archiveKnownIDs,
 		LocalIndex:     localIndexStage.Index,
+		LocalFiles:     localIndexStage.Files,
 		TargetDir:      targetDir,
 		Mode:           mode,
 	})
@@ -190,6 +228,7 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 				State:          state,
 				ArchiveKnownID: archiveKnownIDs,
 				LocalIndex:     localIndexStage.Index,
+				LocalFiles:     localIndexStage.Files,
 				TargetDir:      targetDir,
 				Mode:           mode,
 			})
@@ -200,9 +239,104 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 		}
 	}
 
+	if config.FreeDownloadsPolicy(source) == config.FreeDownloadsSkip && len(plannedIDs) > 0 {
+		gatedIDs, gateErr := s.findSoundCloudFreeDownloadTracks(ctx, source, tracks, plannedIDs)
+		if gateErr != nil {
+			return plan, gateErr
+		}
+		if len(gatedIDs) > 0 {
+			remaining := map[string]struct{}{}
+			for id := range plannedIDs {
+				if _, gated := gatedIDs[id]; !gated {
+					remaining[id] = struct{}{}
+				}
+			}
+			plannedIDs = remaining
+			preflight.PlannedDownloadCount = len(remaining)
+			preflight.FreeDownloadSkipped = len(gatedIDs)
+			plan.PlannedTracks = orderForExecution(orderPlannedSoundCloudTracks(tracks, remaining), plan.DownloadOrder)
+			plan.Source.SelectedPlaylistIDs = soundCloudPlaylistIndices(tracks, remaining)
+		}
+	}
+
+	if pending, resuming := opts.Resume.pendingTracks(source.ID); resuming {
+		remaining := map[string]struct{}{}
+		for id := range plannedIDs {
+			if _, isPending := pending[id]; isPending {
+				remaining[id] = struct{}{}
+			}
+		}
+		plannedIDs = remaining
+		preflight.PlannedDownloadCount = len(remaining)
+		plan.PlannedTracks = orderForExecution(orderPlannedSoundCloudTracks(tracks, remaining), plan.DownloadOrder)
+		plan.Source.SelectedPlaylistIDs = soundCloudPlaylistIndices(tracks, remaining)
+	}
+	dedupeTracks := make(map[string]crossSourceTrack, len(tracks))
+	for _, track := range tracks {
+		dedupeTracks[track.ID] = crossSourceTrack{Key: crossSourceTrackKey("", track.Title), Label: track.Title}
+	}
+	plannedTrackIDs := make([]string, 0, len(plan.PlannedTracks))
+	for _, track := range plan.PlannedTracks {
+		plannedTrackIDs = append(plannedTrackIDs, track.ID)
+	}
+	if kept, duplicates := s.skipCrossSourceDuplicates(cfg, source, plannedTrackIDs, dedupeTracks); duplicates > 0 {
+		remaining := make(map[string]struct{}, len(kept))
+		for _, id := range kept {
+			remaining[id] = struct{}{}
+		}
+		plannedIDs = remaining
+		plannedTrackIDs = kept
+		preflight.PlannedDownloadCount = len(remaining)
+		preflight.DuplicateSkipped = duplicates
+		plan.PlannedTracks = orderForExecution(orderPlannedSoundCloudTracks(tracks, remaining), plan.DownloadOrder)
+		plan.Source.SelectedPlaylistIDs = soundCloudPlaylistIndices(tracks, remaining)
+	}
+	if kept, blocked := s.skipBlockedTracks(source, plannedTrackIDs, soundCloudPlaylistTracks(tracks)); blocked > 0 {
+		remaining := make(map[string]struct{}, len(kept))
+		for _, id := range kept {
+			remaining[id] = struct{}{}
+		}
+		plannedIDs = remaining
+		plannedTrackIDs = kept
+		preflight.PlannedDownloadCount = len(remaining)
+		preflight.BlocklistSkipped = blocked
+		plan.PlannedTracks = orderForExecution(orderPlannedSoundCloudTracks(tracks, remaining), plan.DownloadOrder)
+		plan.Source.SelectedPlaylistIDs = soundCloudPlaylistIndices(tracks, remaining)
+	}
+	if kept, remembered := s.skipRememberedTracks(cfg, source, plannedTrackIDs, soundCloudPlaylistTracks(tracks)); remembered > 0 {
+		remaining := make(map[string]struct{}, len(kept))
+		for _, id := range kept {
+			remaining[id] = struct{}{}
+		}
+		plannedIDs = remaining
+		plannedTrackIDs = kept
+		preflight.PlannedDownloadCount = len(remaining)
+		preflight.RememberedSkipped = remembered
+		plan.PlannedTracks = orderForExecution(orderPlannedSoundCloudTracks(tracks, remaining), plan.DownloadOrder)
+		plan.Source.SelectedPlaylistIDs = soundCloudPlaylistIndices(tracks, remaining)
+	}
+	if limited := applySyncLimit(source, opts, &preflight, plannedTrackIDs, soundCloudPlaylistTracks(tracks)); len(limited) < len(plannedTrackIDs) {
+		remaining := make(map[string]struct{}, len(limited))
+		for _, id := range limited {
+			remaining[id] = struct{}{}
+		}
+		plannedIDs = remaining
+		plannedTrackIDs = limited
+		plan.PlannedTracks = orderForExecution(orderPlannedSoundCloudTracks(tracks, remaining), plan.DownloadOrder)
+		plan.Source.SelectedPlaylistIDs = soundCloudPlaylistIndices(tracks, remaining)
+	}
+	if mode == SoundCloudModeOnlyGaps {
+		// Without break_on_existing scdl walks the whole listing, new head
+		// tracks included, so it is held to the planned gaps.
+		plan.Source.SelectedPlaylistIDs = soundCloudPlaylistIndices(tracks, plannedIDs)
+	}
+	s.notePlannedTracks(source.ID, plannedTrackIDs)
+	s.noteTrackKeys(source, plannedTrackIDs, dedupeTracks)
+
 	plan.Preflight = &preflight
 	breakOnExisting = mode == SoundCloudModeBreak
 	plan.Source.Sync.BreakOnExisting = &breakOnExisting
+	plan.Source = selectSoundCloudStationTracks(plan.Source, plannedTrackIDs)
 
 	if opts.DryRun {
 		return plan, nil
@@ -237,6 +371,94 @@ func (s *Syncer) prepareSoundCloudExecutionPlan(
 	return plan, nil
 }
 
+// isSoundCloudFreeDownloadFlow reports whether a source runs the browser-gate
+// free-download flow instead of the scdl stream-rip flow.
+func isSoundCloudFreeDownloadFlow(source config.Source) bool {
+	return source.Type == config.SourceTypeSoundCloud && config.FreeDownloadsPolicy(source) == config.FreeDownloadsOnly
+}
+
+// findSoundCloudFreeDownloadTracks looks up the free-download link of each
+// planned track and reports the gated ones as skipped. Lookup failures keep
+// the track in the stream-rip plan.
+func (s *Syncer) findSoundCloudFreeDownloadTracks(
+	ctx context.Context,
+	source config.Source,
+	tracks []soundCloudRemoteTrack,
+	plannedIDs map[string]struct{},
+) (map[string]struct{}, error) {
+	gated := map[string]struct{}{}
+	for _, track := range tracks {
+		if _, planned := plannedIDs[track.ID]; !planned {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		metadata, err := lookupSoundCloudFreeDownloadMetadata(ctx, source.ID, track)
+		if errors.Is(err, errSoundCloudNoFreeDownloadLink) {
+			continue
+		}
+		if err != nil {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] unable to check free-download link for %s: %v", source.ID, track.ID, err),
+			})
+			continue
+		}
+		if strings.TrimSpace(metadata.PurchaseURL) == "" {
+			continue
+		}
+		gated[track.ID] = struct{}{}
+		displayName := strings.TrimSpace(track.Title)
+		if displayName == "" {
+			displayName = track.ID
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [skip] %s (%s) (free-downloads-skipped)", source.ID, track.ID, displayName),
+			Details: map[string]any{
+				"track_id":     track.ID,
+				"reason":       "free-downloads-skipped",
+				"purchase_url": sanitizeSoundCloudFreeDownloadURL(metadata.PurchaseURL),
+			},
+		})
+	}
+	return gated, nil
+}
+
+// soundCloudPlaylistIndices returns the 1-based playlist positions of the
+// given IDs so scdl can be restricted to them via --playlist-items.
+func soundCloudPlaylistIndices(tracks []soundCloudRemoteTrack, ids map[string]struct{}) []int {
+	indices := make([]int, 0, len(ids))
+	for i, track := range tracks {
+		if _, ok := ids[track.ID]; ok {
+			indices = append(indices, i+1)
+		}
+	}
+	return indices
+}
+
+// selectSoundCloudStationTracks points the scdl run of a station source at
+// the planned tracks by id. SoundCloud generates a station anew each time it
+// is listed, so the positions planning saw do not carry over to scdl's own
+// listing, and the run must not break at the first downloaded track.
+func selectSoundCloudStationTracks(source config.Source, plannedTrackIDs []string) config.Source {
+	if !config.IsSoundCloudStationURL(source.URL) {
+		return source
+	}
+	noBreak := false
+	source.SelectedPlaylistIDs = nil
+	source.SelectedTrackIDs = append([]string{}, plannedTrackIDs...)
+	source.Sync.BreakOnExisting = &noBreak
+	return source
+}
+
 func orderPlannedSoundCloudTracks(tracks []soundCloudRemoteTrack, plannedIDs map[string]struct{}) []soundCloudRemoteTrack {
 	if len(tracks) == 0 || len(plannedIDs) == 0 {
 		return []soundCloudRemoteTrack{}
@@ -252,6 +474,9 @@ func orderPlannedSoundCloudTracks(tracks []soundCloudRemoteTrack, plannedIDs map
 }
 
 func determineSoundCloudMode(source config.Source, opts SyncOptions) SoundCloudMode {
+	if opts.OnlyGaps {
+		return SoundCloudModeOnlyGaps
+	}
 	if opts.ScanGaps {
 		return SoundCloudModeScanGaps
 	}
@@ -282,7 +507,7 @@ func isGracefulBreakOnExistingStop(
 	preflight *SoundCloudPreflight,
 	execResult ExecResult,
 ) bool {
-	if source.Type != config.SourceTypeSoundCloud {
+	if source.Type != config.SourceTypeSoundCloud && source.Type != config.SourceTypeYouTube {
 		return false
 	}
 	breakOnExisting := true
@@ -312,3 +537,97 @@ func isGracefulBreakOnExistingStop(
 
 	return false
 }
+
+// shouldRetrySoundCloudWithRefreshedClientID matches scdl runs that failed
+// because SoundCloud rejected the client_id (HTTP 401 or scdl's own
+// client_id errors). A source that already carries a refreshed id is not
+// retried again.
+func shouldRetrySoundCloudWithRefreshedClientID(source config.Source, execResult ExecResult) bool {
+	if strings.TrimSpace(source.SoundCloudClientID) != "" {
+		return false
+	}
+	if execResult.TimedOut {
+		return false
+	}
+	return isSoundCloudClientIDRejected(source, execResult)
+}
+
+func isSoundCloudClientIDRejected(source config.Source, execResult ExecResult) bool {
+	if _, _, ok := scdlClientIDFailureDetails(source, execResult); ok {
+		return true
+	}
+	if source.Type != config.SourceTypeSoundCloud || source.Adapter.Kind != "scdl" {
+		return false
+	}
+	if execResult.ExitCode == 0 || execResult.Interrupted {
+		return false
+	}
+	combined := strings.ToLower(execResult.StdoutTail + "\n" + execResult.StderrTail)
+	if !strings.Contains(combined, "soundcloud") && !strings.Contains(combined, "client_id") {
+		return false
+	}
+	return strings.Contains(combined, "http error 401") ||
+		strings.Contains(combined, "401 client error") ||
+		strings.Contains(combined, "401: unauthorized") ||
+		strings.Contains(combined, "status code 401")
+}
+
+// storeRefreshedSoundCloudClientID saves a refreshed client_id to Keychain so
+// later runs start with it. An SCDL_CLIENT_ID override is left untouched
+// since udl cannot rewrite the caller's environment.
+func (s *Syncer) storeRefreshedSoundCloudClientID(sourceID string, storageSource auth.CredentialStorageSource, clientID string) {
+	if storageSource == auth.CredentialStorageSourceEnv {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  sourceID,
+			Message:   fmt.Sprintf("[%s] SCDL_CLIENT_ID is stale; the refreshed client_id is used for this run only. Unset SCDL_CLIENT_ID to let udl keep it in Keychain", sourceID),
+		})
+		return
+	}
+	if err := saveSoundCloudClientIDFn(clientID); err != nil {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelWarn,
+			Event:     output.EventSourcePreflight,
+			SourceID:  sourceID,
+			Message:   fmt.Sprintf("[%s] unable to save refreshed SoundCloud client_id to keychain: %v", sourceID, err),
+		})
+	}
+}
+
+// attachSoundCloudOAuthToken signs a SoundCloud source in with the stored
+// OAuth session, refreshing it first when it is about to expire. A
+// cookies_file carries its own session and wins. Dry runs never refresh, so
+// they leave Keychain alone. Without a usable token the source runs signed
+// out, as it did before.
+func (s *Syncer) attachSoundCloudOAuthToken(ctx context.Context, source config.Source, opts SyncOptions) config.Source {
+	if source.Type != config.SourceTypeSoundCloud || strings.TrimSpace(source.CookiesFile) != "" {
+		return source
+	}
+	token, refreshed, err := soundCloudOAuthAccessTokenFn(ctx, !opts.DryRun)
+	if err != nil {
+		if !errors.Is(err, auth.ErrSoundCloudOAuthTokenNotFound) {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] soundcloud oauth token unusable, continuing signed out: %v", source.ID, err),
+			})
+		}
+		return source
+	}
+	if refreshed {
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] refreshed soundcloud oauth token", source.ID),
+		})
+	}
+	source.SoundCloudOAuthToken = token
+	return source
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jaa/update-downloads/internal/auth"
@@ -34,7 +35,6 @@ func (s *Syncer) runSpotifyDeemix(
 	opts SyncOptions,
 ) sourceRunOutcome {
 	outcome := sourceRunOutcome{}
-	flow := s.buildSourceFlowContext(source)
 
 	plan, planErr := s.prepareSpotifyDeemixExecutionPlan(ctx, cfg, source, opts)
 	if planErr != nil {
@@ -59,7 +59,9 @@ func (s *Syncer) runSpotifyDeemix(
 	if plan.Preflight != nil {
 		s.emitSourcePreflightSummary(source, plan.Preflight, plan.DownloadOrder)
 	}
-	emitSpotifyDeemixExistingTrackStatus(s, source.ID, plan, opts.TrackStatus)
+	emitDeemixExistingTrackStatus(s, source.ID, plan.ExistingTrackIDs, func(id string) string {
+		return spotifyTrackDisplayNameFromState(id, plan.TrackMetadata, plan.State)
+	}, opts.TrackStatus)
 
 	timeout := time.Duration(cfg.Defaults.CommandTimeoutSeconds) * time.Second
 	if opts.TimeoutOverride > 0 {
@@ -69,6 +71,7 @@ func (s *Syncer) runSpotifyDeemix(
 	if opts.DryRun {
 		outcome.Attempted++
 		outcome.Succeeded++
+		s.emitDryRunEstimate(cfg, source, plan.Preflight)
 		_ = s.Emitter.Emit(output.Event{
 			Timestamp: s.Now(),
 			Level:     output.LevelInfo,
@@ -100,6 +103,31 @@ func (s *Syncer) runSpotifyDeemix(
 	if len(plannedTrackIDs) == 0 {
 		if trackID := extractSpotifyTrackID(sourceForExec.URL); trackID != "" {
 			plannedTrackIDs = []string{trackID}
+		} else if config.IsSpotifyArtistURL(sourceForExec.URL) || config.IsSpotifyUserLibraryURL(sourceForExec.URL) {
+			// deemix cannot expand artist links or the user's library itself,
+			// so the tracks are listed even when the remote diff is skipped.
+			artistTracks, artistErr := enumerateSpotifyTracksFn(ctx, sourceForExec, auth.SpotifyCredentials{
+				ClientID:     sourceForExec.SpotifyClientID,
+				ClientSecret: sourceForExec.SpotifyClientSecret,
+			})
+			if artistErr != nil {
+				outcome.Attempted++
+				outcome.Failed++
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelError,
+					Event:     output.EventSourceFailed,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] spotify %s enumeration failed: %v", source.ID, spotifySourceKind(sourceForExec.URL), artistErr),
+				})
+				outcome.Stop = !cfg.Defaults.ContinueOnError
+				return outcome
+			}
+			plannedTrackIDs = make([]string, 0, len(artistTracks))
+			for _, track := range artistTracks {
+				plannedTrackIDs = append(plannedTrackIDs, track.ID)
+			}
+			plan.TrackMetadata = buildSpotifyTrackMetadataIndex(artistTracks)
 		} else if playlistID, playlistErr := resolveSpotifyPlaylistID(sourceForExec.URL); playlistErr == nil {
 			pageTracks, pageErr := enumerateSpotifyViaPageFn(ctx, playlistID)
 			if pageErr != nil || len(pageTracks) == 0 {
@@ -145,186 +173,422 @@ func (s *Syncer) runSpotifyDeemix(
 		},
 	})
 
-	runtimeDir := strings.TrimSpace(sourceForExec.DeemixRuntimeDir)
 	sourceFailed := false
 	var sourceFailureMessage string
 	var sourceFailureDetails map[string]any
 	skippedUnavailable := 0
-	spotifyTargetDir, targetDirErr := config.ExpandPath(sourceForExec.TargetDir)
+	spotifyTargetDir, targetDirErr := config.ExpandTargetDir(sourceForExec.TargetDir)
 	if targetDirErr != nil {
 		sourceFailed = true
 		sourceFailureMessage = fmt.Sprintf("[%s] resolve target_dir: %v", source.ID, targetDirErr)
 	}
-	for idx, trackID := range plannedTrackIDs {
-		if sourceFailed {
-			break
-		}
-		trackSource := sourceForExec
-		if trackID != "" {
-			trackSource.URL = spotifyTrackURL(trackID)
-		}
-		trackLabel := spotifyTrackDisplayNameFromState(trackID, plan.TrackMetadata, plan.State)
-		spec, buildErr := adapter.BuildExecSpec(trackSource, cfg.Defaults, timeout)
-		if buildErr != nil {
+	// Artist discographies are organized one folder per album under target_dir.
+	artistSource := config.IsSpotifyArtistURL(sourceForExec.URL)
+	// With sync.compilations, compilation tracks go to Compilations/<album>
+	// instead and get their release tags rewritten after the download.
+	compilations := compilationsEnabled(sourceForExec)
+
+	// With sync.concurrency > 1 several workers run deemix at once, each in
+	// its own runtime dir. Tracks are claimed in planned order, and their
+	// state appends and [done]/[skip] events commit in that order too.
+	concurrency := deemixTrackConcurrency(sourceForExec, len(plannedTrackIDs))
+	qualityTiers := deemixQualityTiers(sourceForExec)
+	isrcMatcher := newDeezerISRCMatcher(auth.SpotifyCredentials{
+		ClientID:     sourceForExec.SpotifyClientID,
+		ClientSecret: sourceForExec.SpotifyClientSecret,
+	})
+	if concurrency > 1 {
+		s = s.withSerializedEvents()
+	}
+	var mu sync.Mutex
+	commits := newTrackCommitOrderer(true)
+	nextIdx := 0
+	// Tracks the stall watchdog cancels are appended once more and retried
+	// after the rest of the plan.
+	stalledOnce := map[string]struct{}{}
+	skippedStalled := 0
+	interrupted := false
+	var interruptedDetails map[string]any
+	runtimeDirs := []string{}
+	claimedPaths := map[string]struct{}{}
+	fail := func(message string, details map[string]any) {
+		if !sourceFailed {
 			sourceFailed = true
-			sourceFailureMessage = fmt.Sprintf("[%s] cannot build command: %v", source.ID, buildErr)
-			break
+			sourceFailureMessage = message
+			sourceFailureDetails = details
 		}
-		spec = s.applyFlowObservers(spec, flow, source)
-		if runtimeDir == "" {
-			runtimeDir = spec.Dir
-			sourceForExec.DeemixRuntimeDir = spec.Dir
-		}
-		trackSource.DeemixRuntimeDir = runtimeDir
-		spec.Dir = runtimeDir
+	}
 
-		if trackID != "" && strings.TrimSpace(runtimeDir) != "" {
-			metadata, metadataErr := resolveSpotifyTrackMetadataForExecution(ctx, trackID, plan.TrackMetadata)
-			if metadataErr != nil {
-				_ = s.Emitter.Emit(output.Event{
-					Timestamp: s.Now(),
-					Level:     output.LevelWarn,
-					Event:     output.EventSourcePreflight,
-					SourceID:  source.ID,
-					Message:   fmt.Sprintf("[%s] spotify metadata lookup failed for %s: %v", source.ID, trackID, metadataErr),
-				})
-			} else if cacheErr := writeSpotifyTrackMetadataCache(runtimeDir, trackID, metadata); cacheErr != nil {
+	runTracks := func(runtimeDir string) {
+		flow := s.buildSourceFlowContext(source)
+		for {
+			mu.Lock()
+			if sourceFailed || interrupted || nextIdx >= len(plannedTrackIDs) {
+				mu.Unlock()
+				return
+			}
+			idx := nextIdx
+			nextIdx++
+			trackID := plannedTrackIDs[idx]
+			trackLabel := spotifyTrackDisplayNameFromState(trackID, plan.TrackMetadata, plan.State)
+			if trackID != "" {
+				message := fmt.Sprintf("[%s] deemix track %d/%d %s", source.ID, idx+1, len(plannedTrackIDs), trackID)
+				if trackLabel != "" {
+					message = fmt.Sprintf("[%s] deemix track %d/%d %s (%s)", source.ID, idx+1, len(plannedTrackIDs), trackID, trackLabel)
+				}
 				_ = s.Emitter.Emit(output.Event{
 					Timestamp: s.Now(),
-					Level:     output.LevelWarn,
+					Level:     output.LevelInfo,
 					Event:     output.EventSourcePreflight,
 					SourceID:  source.ID,
-					Message:   fmt.Sprintf("[%s] unable to prime deemix spotify cache for %s: %v", source.ID, trackID, cacheErr),
+					Message:   message,
 				})
 			}
-		}
+			mu.Unlock()
+
+			trackSource := sourceForExec
+			deezerURL := ""
+			if trackID != "" {
+				trackSource.URL = spotifyTrackURL(trackID)
+				// An exact ISRC match on Deezer avoids deemix's Spotify plugin
+				// picking another version of the track.
+				if deezerURL = s.deezerURLForSpotifyTrack(ctx, source.ID, isrcMatcher, trackID, plan.TrackMetadata); deezerURL != "" {
+					trackSource.URL = deezerURL
+				}
+				release := plan.TrackMetadata[trackID].Release
+				if compilations && release.Compilation {
+					if dir := compilationTargetDir(spotifyTargetDir, plan.TrackMetadata[trackID].Album); dir != "" && targetDirErr == nil {
+						trackSource.TargetDir = dir
+					}
+				} else if targetDirErr == nil {
+					// sync.genre_routing picks the genre folder; artist
+					// sources still get one folder per album inside it.
+					dir := s.routeDeemixTrack(ctx, sourceForExec, spotifyTargetDir, trackID, func(ctx context.Context) ([]string, error) {
+						token, err := isrcMatcher.accessToken(ctx)
+						if err != nil {
+							return nil, err
+						}
+						return fetchSpotifyTrackGenresFn(ctx, token, trackID)
+					})
+					if albumDir := spotifyAlbumDirName(plan.TrackMetadata[trackID].Album); artistSource && albumDir != "" {
+						dir = filepath.Join(dir, albumDir)
+					}
+					if dir != spotifyTargetDir {
+						trackSource.TargetDir = dir
+					}
+				}
+			}
+			buildSpec := func(quality string) (ExecSpec, error) {
+				trackSource.DeemixRuntimeDir = runtimeDir
+				trackSource.DeemixBitrate = quality
+				spec, err := adapter.BuildExecSpec(trackSource, cfg.Defaults, timeout)
+				if err != nil {
+					return spec, err
+				}
+				spec = s.applyFlowObservers(spec, flow, source)
+				if runtimeDir == "" {
+					runtimeDir = spec.Dir
+					mu.Lock()
+					runtimeDirs = append(runtimeDirs, runtimeDir)
+					mu.Unlock()
+				}
+				spec.Dir = runtimeDir
+				return spec, nil
+			}
+			spec, buildErr := buildSpec(qualityTiers[0])
+			if buildErr != nil {
+				mu.Lock()
+				fail(fmt.Sprintf("[%s] cannot build command: %v", source.ID, buildErr), nil)
+				mu.Unlock()
+				return
+			}
 
-		if trackID != "" {
-			message := fmt.Sprintf("[%s] deemix track %d/%d %s", source.ID, idx+1, len(plannedTrackIDs), trackID)
-			if trackLabel != "" {
-				message = fmt.Sprintf("[%s] deemix track %d/%d %s (%s)", source.ID, idx+1, len(plannedTrackIDs), trackID, trackLabel)
+			if trackID != "" && deezerURL == "" && strings.TrimSpace(runtimeDir) != "" {
+				metadata, metadataErr := resolveSpotifyTrackMetadataForExecution(ctx, trackID, plan.TrackMetadata)
+				if metadataErr != nil {
+					_ = s.Emitter.Emit(output.Event{
+						Timestamp: s.Now(),
+						Level:     output.LevelWarn,
+						Event:     output.EventSourcePreflight,
+						SourceID:  source.ID,
+						Message:   fmt.Sprintf("[%s] spotify metadata lookup failed for %s: %v", source.ID, trackID, metadataErr),
+					})
+				} else if cacheErr := writeSpotifyTrackMetadataCache(runtimeDir, trackID, metadata); cacheErr != nil {
+					_ = s.Emitter.Emit(output.Event{
+						Timestamp: s.Now(),
+						Level:     output.LevelWarn,
+						Event:     output.EventSourcePreflight,
+						SourceID:  source.ID,
+						Message:   fmt.Sprintf("[%s] unable to prime deemix spotify cache for %s: %v", source.ID, trackID, cacheErr),
+					})
+				}
 			}
-			_ = s.Emitter.Emit(output.Event{
-				Timestamp: s.Now(),
-				Level:     output.LevelInfo,
-				Event:     output.EventSourcePreflight,
-				SourceID:  source.ID,
-				Message:   message,
-			})
-		}
 
-		var mediaBefore map[string]mediaFileSnapshot
-		if trackID != "" {
-			before, snapshotErr := snapshotMediaFiles(spotifyTargetDir)
-			if snapshotErr != nil {
-				_ = s.Emitter.Emit(output.Event{
-					Timestamp: s.Now(),
-					Level:     output.LevelWarn,
-					Event:     output.EventSourcePreflight,
-					SourceID:  source.ID,
-					Message:   fmt.Sprintf("[%s] unable to snapshot target directory before track run: %v", source.ID, snapshotErr),
-				})
-			} else {
-				mediaBefore = before
+			var mediaBefore map[string]mediaFileSnapshot
+			if trackID != "" {
+				before, snapshotErr := snapshotMediaFiles(spotifyTargetDir)
+				if snapshotErr != nil {
+					_ = s.Emitter.Emit(output.Event{
+						Timestamp: s.Now(),
+						Level:     output.LevelWarn,
+						Event:     output.EventSourcePreflight,
+						SourceID:  source.ID,
+						Message:   fmt.Sprintf("[%s] unable to snapshot target directory before track run: %v", source.ID, snapshotErr),
+					})
+				} else {
+					mediaBefore = before
+				}
 			}
-		}
 
-		execResult := s.Runner.Run(ctx, spec)
-		s.flushFlowParser(flow, source)
-		if execResult.Interrupted {
-			_ = cleanupRuntimeDir(runtimeDir)
-			outcome.Interrupted = true
-			outcome.Stop = true
-			_ = s.Emitter.Emit(output.Event{
-				Timestamp: s.Now(),
-				Level:     output.LevelError,
-				Event:     output.EventSourceFailed,
-				SourceID:  source.ID,
-				Message:   fmt.Sprintf("[%s] interrupted", source.ID),
-				Details:   buildExecFailureDetails(source, spec, execResult),
-			})
-			return outcome
-		}
+			spec, execResult, quality, buildErr := s.runDeemixTrack(ctx, source, trackID, qualityTiers, spec, flow, buildSpec, trackSource.TargetDir)
+			if buildErr != nil {
+				mu.Lock()
+				fail(fmt.Sprintf("[%s] cannot build command: %v", source.ID, buildErr), nil)
+				mu.Unlock()
+				return
+			}
+			if execResult.Stalled && trackID != "" {
+				var mediaAfter map[string]mediaFileSnapshot
+				if mediaBefore != nil {
+					if after, snapshotErr := snapshotMediaFiles(spotifyTargetDir); snapshotErr == nil {
+						mediaAfter = after
+					}
+				}
+				display := trackLabel
+				if display == "" {
+					display = trackID
+				}
+				mu.Lock()
+				if mediaAfter != nil {
+					// Other workers' downloads are new files too; only the
+					// one file nobody else can own is removed.
+					if concurrency > 1 {
+						removeStalledPartial(spotifyTargetDir, mediaBefore, soleUpdatedMediaPath(mediaBefore, mediaAfter, claimedPaths))
+					} else {
+						removeStalledPartial(spotifyTargetDir, mediaBefore, newMediaPaths(mediaBefore, mediaAfter)...)
+					}
+				}
+				_, retried := stalledOnce[trackID]
+				if !retried {
+					stalledOnce[trackID] = struct{}{}
+					plannedTrackIDs = append(plannedTrackIDs, trackID)
+				}
+				commitErr := commits.Commit(idx, func() error {
+					if retried {
+						skippedStalled++
+					}
+					s.emitTrackStalled(source, trackID, display, !retried)
+					return nil
+				})
+				if commitErr != nil {
+					fail(fmt.Sprintf("[%s] failed to update spotify state file: %v", source.ID, commitErr), nil)
+				}
+				mu.Unlock()
+				continue
+			}
+			if execResult.Interrupted {
+				mu.Lock()
+				if !interrupted {
+					interrupted = true
+					interruptedDetails = buildExecFailureDetails(source, spec, execResult)
+				}
+				mu.Unlock()
+				return
+			}
 
-		if unavailable, reason := deemixReportedTrackUnavailable(execResult); unavailable {
-			skippedUnavailable++
-			display := trackLabel
-			if display == "" {
-				display = deemixTrackDisplayName(execResult)
+			provider := ""
+			if unavailable, reason := deemixReportedTrackUnavailable(execResult); unavailable && trackID != "" && len(trackSource.Adapter.Fallback) > 0 {
+				metadata := plan.TrackMetadata[trackID]
+				fallbackTrack := FallbackTrack{ID: trackID, URL: spotifyTrackURL(trackID), Artist: metadata.Artist, Title: metadata.Title}
+				fallbackProvider, fallbackSpec, fallbackResult := s.runTrackFallbacks(ctx, source, trackSource, cfg.Defaults, fallbackTrack, reason, timeout)
+				if fallbackResult.Interrupted {
+					mu.Lock()
+					if !interrupted {
+						interrupted = true
+						interruptedDetails = buildExecFailureDetails(source, fallbackSpec, fallbackResult)
+					}
+					mu.Unlock()
+					return
+				}
+				if fallbackProvider != "" {
+					provider, spec, execResult, quality = fallbackProvider, fallbackSpec, fallbackResult, ""
+				}
 			}
-			if display == "" {
-				display = trackID
+			if unavailable, reason := deemixReportedTrackUnavailable(execResult); unavailable && provider == "" {
+				display := trackLabel
+				if display == "" {
+					display = deemixTrackDisplayName(execResult)
+				}
+				if display == "" {
+					display = trackID
+				}
+				mu.Lock()
+				commitErr := commits.Commit(idx, func() error {
+					skippedUnavailable++
+					_ = s.Emitter.Emit(output.Event{
+						Timestamp: s.Now(),
+						Level:     output.LevelInfo,
+						Event:     output.EventSourcePreflight,
+						SourceID:  source.ID,
+						Message:   fmt.Sprintf("[%s] [skip] %s (%s) (%s)", source.ID, trackID, display, reason),
+					})
+					return nil
+				})
+				if commitErr != nil {
+					fail(fmt.Sprintf("[%s] failed to update spotify state file: %v", source.ID, commitErr), nil)
+				}
+				mu.Unlock()
+				continue
 			}
-			_ = s.Emitter.Emit(output.Event{
-				Timestamp: s.Now(),
-				Level:     output.LevelInfo,
-				Event:     output.EventSourcePreflight,
-				SourceID:  source.ID,
-				Message:   fmt.Sprintf("[%s] [skip] %s (%s) (%s)", source.ID, trackID, display, reason),
-			})
-			continue
-		}
 
-		if execResult.ExitCode != 0 {
-			sourceFailed = true
-			sourceFailureMessage = fmt.Sprintf("[%s] command failed with exit code %d", source.ID, execResult.ExitCode)
-			sourceFailureDetails = buildExecFailureDetails(source, spec, execResult)
-			break
-		}
-		if failed, reason := deemixReportedFailure(execResult); failed {
-			sourceFailed = true
-			sourceFailureMessage = fmt.Sprintf(
-				"[%s] deemix reported runtime failure despite exit code 0 (%s); check Spotify app credentials/quota and consider fallback to spotdl for this source",
-				source.ID,
-				reason,
-			)
-			sourceFailureDetails = buildExecFailureDetails(source, spec, execResult)
-			break
-		}
+			if execResult.ExitCode != 0 {
+				mu.Lock()
+				fail(fmt.Sprintf("[%s] command failed with exit code %d", source.ID, execResult.ExitCode), buildExecFailureDetails(source, spec, execResult))
+				mu.Unlock()
+				return
+			}
+			if failed, reason := deemixReportedFailure(execResult); failed && provider == "" {
+				mu.Lock()
+				fail(fmt.Sprintf(
+					"[%s] deemix reported runtime failure despite exit code 0 (%s); check Spotify app credentials/quota and consider fallback to spotdl for this source",
+					source.ID,
+					reason,
+				), buildExecFailureDetails(source, spec, execResult))
+				mu.Unlock()
+				return
+			}
 
-		if trackID != "" {
+			var mediaAfter map[string]mediaFileSnapshot
+			if mediaBefore != nil {
+				if after, snapshotErr := snapshotMediaFiles(spotifyTargetDir); snapshotErr == nil {
+					mediaAfter = after
+				}
+			}
+			mu.Lock()
+			if trackID == "" {
+				commitErr := commits.Skip(idx)
+				if commitErr != nil {
+					fail(fmt.Sprintf("[%s] failed to update spotify state file: %v", source.ID, commitErr), nil)
+				}
+				mu.Unlock()
+				continue
+			}
 			entryLabel := trackLabel
 			if entryLabel == "" {
 				entryLabel = deemixTrackDisplayName(execResult)
 			}
 			localPath := ""
-			if mediaBefore != nil {
-				after, snapshotErr := snapshotMediaFiles(spotifyTargetDir)
-				if snapshotErr == nil {
-					localPath = detectUpdatedMediaPath(mediaBefore, after)
+			if mediaAfter != nil {
+				if concurrency > 1 {
+					localPath = soleUpdatedMediaPath(mediaBefore, mediaAfter, claimedPaths)
+				} else {
+					localPath = detectUpdatedMediaPath(mediaBefore, mediaAfter)
+				}
+				if localPath != "" {
+					claimedPaths[localPath] = struct{}{}
 				}
 			}
-			doneMessage := fmt.Sprintf("[%s] [done] %s", source.ID, trackID)
-			if entryLabel != "" {
-				doneMessage = fmt.Sprintf("[%s] [done] %s (%s)", source.ID, trackID, entryLabel)
-			}
-			if appendErr := appendSpotifySyncStateEntry(sourceForExec.StateFile, trackID, entryLabel, localPath); appendErr != nil {
-				sourceFailed = true
-				sourceFailureMessage = fmt.Sprintf("[%s] failed to update spotify state file: %v", source.ID, appendErr)
-				break
-			}
-			plan.State.KnownIDs[trackID] = struct{}{}
-			entry := plan.State.Entries[trackID]
-			if entryLabel != "" {
-				entry.DisplayName = entryLabel
+			mu.Unlock()
+			if release := plan.TrackMetadata[trackID].Release; compilations && release.Compilation && localPath != "" {
+				if tagErr := writeCompilationTagsFn(ctx, filepath.Join(spotifyTargetDir, filepath.FromSlash(localPath)), release); tagErr != nil {
+					_ = s.Emitter.Emit(output.Event{
+						Timestamp: s.Now(),
+						Level:     output.LevelWarn,
+						Event:     output.EventSourcePreflight,
+						SourceID:  source.ID,
+						Message:   fmt.Sprintf("[%s] [compilation] unable to tag %s: %v", source.ID, localPath, tagErr),
+					})
+				}
 			}
-			if localPath != "" {
-				entry.LocalPath = localPath
+			mu.Lock()
+			commitErr := commits.Commit(idx, func() error {
+				if appendErr := appendSpotifySyncStateEntry(sourceForExec.StateFile, trackID, spotifyStateEntry{
+					DisplayName: entryLabel,
+					LocalPath:   localPath,
+					Quality:     quality,
+					Provider:    provider,
+					Adapter:     source.Adapter.Kind,
+					AddedAt:     s.Now(),
+				}); appendErr != nil {
+					return appendErr
+				}
+				plan.State.KnownIDs[trackID] = struct{}{}
+				entry := plan.State.Entries[trackID]
+				if entryLabel != "" {
+					entry.DisplayName = entryLabel
+				}
+				if localPath != "" {
+					entry.LocalPath = localPath
+				}
+				if quality != "" {
+					entry.Quality = quality
+				}
+				if provider != "" {
+					entry.Provider = provider
+				}
+				if entry.DisplayName != "" || entry.LocalPath != "" || entry.Quality != "" || entry.Provider != "" {
+					plan.State.Entries[trackID] = entry
+				}
+				doneMessage := fmt.Sprintf("[%s] [done] %s", source.ID, trackID)
+				if entryLabel != "" {
+					doneMessage = fmt.Sprintf("[%s] [done] %s (%s)", source.ID, trackID, entryLabel)
+				}
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelInfo,
+					Event:     output.EventSourcePreflight,
+					SourceID:  source.ID,
+					Message:   doneMessage,
+				})
+				return nil
+			})
+			if commitErr != nil {
+				fail(fmt.Sprintf("[%s] failed to update spotify state file: %v", source.ID, commitErr), nil)
 			}
-			if entry.DisplayName != "" || entry.LocalPath != "" {
-				plan.State.Entries[trackID] = entry
+			mu.Unlock()
+		}
+	}
+
+	if !sourceFailed {
+		if initial := strings.TrimSpace(sourceForExec.DeemixRuntimeDir); initial != "" {
+			runtimeDirs = append(runtimeDirs, initial)
+		}
+		var workers sync.WaitGroup
+		for worker := 0; worker < concurrency; worker++ {
+			runtimeDir := ""
+			if worker == 0 {
+				runtimeDir = strings.TrimSpace(sourceForExec.DeemixRuntimeDir)
 			}
-			_ = s.Emitter.Emit(output.Event{
-				Timestamp: s.Now(),
-				Level:     output.LevelInfo,
-				Event:     output.EventSourcePreflight,
-				SourceID:  source.ID,
-				Message:   doneMessage,
-			})
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				runTracks(runtimeDir)
+			}()
+		}
+		workers.Wait()
+		// Tracks that finished after an earlier one failed are still recorded.
+		if flushErr := commits.Flush(); flushErr != nil {
+			fail(fmt.Sprintf("[%s] failed to update spotify state file: %v", source.ID, flushErr), nil)
 		}
 	}
 
-	_ = cleanupRuntimeDir(runtimeDir)
+	for _, runtimeDir := range runtimeDirs {
+		_ = cleanupRuntimeDir(runtimeDir)
+	}
+
+	if interrupted {
+		outcome.Interrupted = true
+		outcome.Stop = true
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelError,
+			Event:     output.EventSourceFailed,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] interrupted", source.ID),
+			Details:   interruptedDetails,
+		})
+		return outcome
+	}
 
 	if sourceFailed {
 		outcome.Failed++
@@ -351,8 +615,9 @@ func (s *Syncer) runSpotifyDeemix(
 		SourceID:  source.ID,
 		Message:   fmt.Sprintf("[%s] completed", source.ID),
 		Details: map[string]any{
-			"planned_download_count": len(plannedTrackIDs),
+			"planned_download_count": len(plannedTrackIDs) - len(stalledOnce),
 			"skipped_unavailable":    skippedUnavailable,
+			"skipped_stalled":        skippedStalled,
 		},
 	})
 
@@ -386,32 +651,10 @@ func (s *Syncer) prepareSpotifyDeemixExecutionPlan(
 	plan.Source.SpotifyClientID = spotifyCreds.ClientID
 	plan.Source.SpotifyClientSecret = spotifyCreds.ClientSecret
 
-	arl, err := resolveDeemixARLFn()
-	if err != nil && !errors.Is(err, auth.ErrDeemixARLNotFound) {
+	arl, err := s.resolveDeemixARLForSource(source, opts)
+	if err != nil {
 		return plan, err
 	}
-	if strings.TrimSpace(arl) == "" && opts.AllowPrompt && opts.PromptOnDeemixARL != nil {
-		prompted, promptErr := opts.PromptOnDeemixARL(source.ID)
-		if promptErr != nil {
-			return plan, promptErr
-		}
-		arl = strings.TrimSpace(prompted)
-		if arl != "" {
-			if saveErr := saveDeemixARLFn(arl); saveErr != nil {
-				_ = s.Emitter.Emit(output.Event{
-					Timestamp: s.Now(),
-					Level:     output.LevelWarn,
-					Event:     output.EventSourcePreflight,
-					SourceID:  source.ID,
-					Message:   fmt.Sprintf("[%s] unable to save Deezer ARL to keychain: %v", source.ID, saveErr),
-				})
-			}
-		}
-	}
-	arl = strings.TrimSpace(arl)
-	if arl == "" {
-		return plan, auth.ErrDeemixARLNotFound
-	}
 	plan.Source.DeezerARL = arl
 
 	mode := determineSoundCloudMode(source, opts)
@@ -420,6 +663,15 @@ func (s *Syncer) prepareSpotifyDeemixExecutionPlan(
 	plan.Source.Sync.BreakOnExisting = &breakOnExisting
 
 	if opts.NoPreflight {
+		if !source.Sync.Blocklist.Empty() {
+			_ = s.Emitter.Emit(output.Event{
+				Timestamp: s.Now(),
+				Level:     output.LevelWarn,
+				Event:     output.EventSourcePreflight,
+				SourceID:  source.ID,
+				Message:   fmt.Sprintf("[%s] sync.blocklist ignored because preflight is disabled", source.ID),
+			})
+		}
 		if askOnExisting {
 			_ = s.Emitter.Emit(output.Event{
 				Timestamp: s.Now(),
@@ -453,8 +705,12 @@ func (s *Syncer) prepareSpotifyDeemixExecutionPlan(
 		if err != nil {
 			return plan, err
 		}
+		if err := s.checkRemoteDrift(cfg, source, len(tracks), opts); err != nil {
+			return plan, err
+		}
 	}
 	plan.TrackMetadata = buildSpotifyTrackMetadataIndex(tracks)
+	s.noteRemoteTracks(source, spotifyPlaylistTracks(tracks))
 
 	state, err := parseSpotifySyncState(stateFilePath)
 	if err != nil {
@@ -462,7 +718,7 @@ func (s *Syncer) prepareSpotifyDeemixExecutionPlan(
 	}
 	plan.State = state
 
-	targetDir, err := config.ExpandPath(source.TargetDir)
+	targetDir, err := config.ExpandTargetDir(source.TargetDir)
 	if err != nil {
 		return plan, fmt.Errorf("resolve target_dir: %w", err)
 	}
@@ -486,14 +742,82 @@ func (s *Syncer) prepareSpotifyDeemixExecutionPlan(
 	plan.Preflight = &preflight
 	plan.DownloadOrder = DownloadOrderNewestFirst
 	plan.PlannedTrackIDs = orderForExecution(plannedTrackIDs, plan.DownloadOrder)
+	if _, resuming := opts.Resume.pendingTracks(source.ID); resuming {
+		plan.PlannedTrackIDs = opts.Resume.restrictTrackIDs(source.ID, plan.PlannedTrackIDs)
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+	}
+	dedupeTracks := make(map[string]crossSourceTrack, len(tracks))
+	for _, track := range tracks {
+		dedupeTracks[track.ID] = crossSourceTrack{
+			Key:   crossSourceTrackKey(track.Artist, track.Title),
+			Label: spotifyTrackDisplayName(track.ID, plan.TrackMetadata),
+		}
+	}
+	var duplicates int
+	plan.PlannedTrackIDs, duplicates = s.skipCrossSourceDuplicates(cfg, source, plan.PlannedTrackIDs, dedupeTracks)
+	if duplicates > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.DuplicateSkipped = duplicates
+	}
+	var blocked int
+	plan.PlannedTrackIDs, blocked = s.skipBlockedTracks(source, plan.PlannedTrackIDs, spotifyPlaylistTracks(tracks))
+	if blocked > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.BlocklistSkipped = blocked
+	}
+	var remembered int
+	plan.PlannedTrackIDs, remembered = s.skipRememberedTracks(cfg, source, plan.PlannedTrackIDs, spotifyPlaylistTracks(tracks))
+	if remembered > 0 {
+		preflight.PlannedDownloadCount = len(plan.PlannedTrackIDs)
+		preflight.RememberedSkipped = remembered
+	}
+	plan.PlannedTrackIDs = applySyncLimit(source, opts, &preflight, plan.PlannedTrackIDs, spotifyPlaylistTracks(tracks))
+	s.notePlannedTracks(source.ID, plan.PlannedTrackIDs)
+	s.noteTrackKeys(source, plan.PlannedTrackIDs, dedupeTracks)
 	plan.ExistingTrackIDs = existingTrackIDs
 	breakOnExisting = mode == SoundCloudModeBreak
 	plan.Source.Sync.BreakOnExisting = &breakOnExisting
 	return plan, nil
 }
 
-func shouldRetrySpotifyWithUserAuth(source config.Source, execResult ExecResult, opts SyncOptions) bool {
-	if !opts.AllowPrompt {
+// resolveDeemixARLForSource returns the stored Deezer ARL, prompting for (and
+// saving) one when the run allows prompts.
+func (s *Syncer) resolveDeemixARLForSource(source config.Source, opts SyncOptions) (string, error) {
+	arl, err := resolveDeemixARLFn()
+	if err != nil && !errors.Is(err, auth.ErrDeemixARLNotFound) {
+		return "", err
+	}
+	if strings.TrimSpace(arl) == "" && opts.AllowPrompt && opts.PromptOnDeemixARL != nil {
+		prompted, promptErr := opts.PromptOnDeemixARL(source.ID)
+		if promptErr != nil {
+			return "", promptErr
+		}
+		arl = strings.TrimSpace(prompted)
+		if arl != "" {
+			if saveErr := saveDeemixARLFn(arl); saveErr != nil {
+				_ = s.Emitter.Emit(output.Event{
+					Timestamp: s.Now(),
+					Level:     output.LevelWarn,
+					Event:     output.EventSourcePreflight,
+					SourceID:  source.ID,
+					Message:   fmt.Sprintf("[%s] unable to save Deezer ARL to keychain: %v", source.ID, saveErr),
+				})
+			}
+		}
+	}
+	arl = strings.TrimSpace(arl)
+	if arl == "" {
+		return "", auth.ErrDeemixARLNotFound
+	}
+	return arl, nil
+}
+
+// shouldRetrySpotifyWithUserAuth reports whether a spotdl run that needed a
+// user login gets one --user-auth retry. Without a prompt the retry only runs
+// when a cached login can be reused, since spotdl would otherwise wait for a
+// browser.
+func shouldRetrySpotifyWithUserAuth(source config.Source, execResult ExecResult, opts SyncOptions, cachedLogin bool) bool {
+	if !opts.AllowPrompt && !cachedLogin {
 		return false
 	}
 	if source.Type != config.SourceTypeSpotify {
@@ -511,8 +835,11 @@ func shouldRetrySpotifyWithUserAuth(source config.Source, execResult ExecResult,
 	return isSpotifyUserAuthRequired(source, execResult)
 }
 
-func planSpotifyUserAuthRetry(source config.Source, opts SyncOptions) (config.Source, bool, error) {
+func planSpotifyUserAuthRetry(source config.Source, opts SyncOptions, cachedLogin bool) (config.Source, bool, error) {
 	retrySource := withSpotDLUserAuth(source)
+	if cachedLogin {
+		return withSpotDLHeadless(retrySource), false, nil
+	}
 	if !hasSpotDLHeadlessArg(retrySource.Adapter.ExtraArgs) {
 		return retrySource, true, nil
 	}
@@ -529,6 +856,94 @@ func planSpotifyUserAuthRetry(source config.Source, opts SyncOptions) (config.So
 	return retrySource, openBrowser, nil
 }
 
+var (
+	ensureSpotDLUserTokenFn    = ensureSpotDLUserToken
+	lockSpotDLUserTokenCacheFn = lockSpotDLUserTokenCache
+)
+
+// spotDLUserTokenRefreshMargin refreshes a cached access token this long
+// before it expires, so spotdl rarely has to refresh it mid-run itself;
+// those refreshes happen outside udl's cache lock.
+const spotDLUserTokenRefreshMargin = 10 * time.Minute
+
+// ensureSpotDLUserToken checks spotdl's OAuth cache for a login that can be
+// reused headlessly, refreshing an expired access token with the cached
+// refresh token. It reports false when there is no cached login. Refreshes
+// are single-flight: the cache lock is held while refreshing, and a caller
+// that waited for it re-reads the token another sync refreshed instead of
+// spending the refresh token again.
+func ensureSpotDLUserToken(ctx context.Context) (bool, error) {
+	path, err := auth.SpotDLUserTokenCachePath()
+	if err != nil {
+		return false, nil
+	}
+	token, err := auth.LoadSpotifyUserToken(path)
+	if err != nil {
+		if errors.Is(err, auth.ErrSpotifyUserTokenNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if strings.TrimSpace(token.RefreshToken) == "" {
+		return false, nil
+	}
+	if !token.Expired(time.Now(), spotDLUserTokenRefreshMargin) {
+		return true, nil
+	}
+
+	unlock, waited, err := auth.LockSpotifyUserTokenCache(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+	if waited {
+		token, err = auth.LoadSpotifyUserToken(path)
+		if err != nil {
+			return false, err
+		}
+		if !token.Expired(time.Now(), spotDLUserTokenRefreshMargin) {
+			return true, nil
+		}
+	}
+	creds, err := auth.ResolveSpotifyCredentials()
+	if err != nil {
+		return false, err
+	}
+	refreshed, err := auth.SpotifyUserAuth{Credentials: creds}.Refresh(ctx, token)
+	if err != nil {
+		return false, err
+	}
+	if err := auth.SaveSpotifyUserToken(path, refreshed); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// lockSpotDLUserTokenCache holds spotdl's OAuth cache lock for an
+// interactive --user-auth run, so a second sync waits for that login instead
+// of prompting for its own. loggedIn reports that the lock was held by
+// someone else and the cache now has a usable login; the lock is then
+// already released and the caller can retry headlessly.
+func lockSpotDLUserTokenCache(ctx context.Context) (unlock func(), loggedIn bool, err error) {
+	path, err := auth.SpotDLUserTokenCachePath()
+	if err != nil {
+		return func() {}, false, nil
+	}
+	unlock, waited, err := auth.LockSpotifyUserTokenCache(ctx, path)
+	if err != nil {
+		return func() {}, false, err
+	}
+	if !waited {
+		return unlock, false, nil
+	}
+	token, loadErr := auth.LoadSpotifyUserToken(path)
+	if loadErr == nil && strings.TrimSpace(token.RefreshToken) != "" && !token.Expired(time.Now(), time.Minute) {
+		unlock()
+		return func() {}, true, nil
+	}
+	return unlock, false, nil
+}
+
 func isSpotifyUserAuthRequired(source config.Source, execResult ExecResult) bool {
 	if source.Type != config.SourceTypeSpotify {
 		return false
@@ -588,6 +1003,16 @@ func withSpotDLUserAuth(source config.Source) config.Source {
 	return source
 }
 
+func withSpotDLHeadless(source config.Source) config.Source {
+	if hasSpotDLHeadlessArg(source.Adapter.ExtraArgs) {
+		return source
+	}
+	cloned := append([]string{}, source.Adapter.ExtraArgs...)
+	cloned = append(cloned, "--headless")
+	source.Adapter.ExtraArgs = cloned
+	return source
+}
+
 func withoutSpotDLHeadless(source config.Source) config.Source {
 	if !hasSpotDLHeadlessArg(source.Adapter.ExtraArgs) {
 		return source
@@ -632,6 +1057,9 @@ func deemixReportedTrackUnavailable(execResult ExecResult) (bool, string) {
 	if strings.Contains(combined, "track unavailable on deezer") {
 		return true, "unavailable-on-deezer"
 	}
+	if deemixReportedWrongBitrate(execResult) {
+		return true, "unavailable-at-requested-quality"
+	}
 	return false, ""
 }
 
@@ -710,9 +1138,9 @@ func normalizeTrackStatusMode(mode TrackStatusMode) TrackStatusMode {
 	}
 }
 
-func emitSpotifyDeemixExistingTrackStatus(s *Syncer, sourceID string, plan spotifyDeemixExecutionPlan, mode TrackStatusMode) {
+func emitDeemixExistingTrackStatus(s *Syncer, sourceID string, existingTrackIDs []string, trackLabel func(string) string, mode TrackStatusMode) {
 	statusMode := normalizeTrackStatusMode(mode)
-	if statusMode == TrackStatusNone || len(plan.ExistingTrackIDs) == 0 {
+	if statusMode == TrackStatusNone || len(existingTrackIDs) == 0 {
 		return
 	}
 
@@ -722,19 +1150,19 @@ func emitSpotifyDeemixExistingTrackStatus(s *Syncer, sourceID string, plan spoti
 			Level:     output.LevelInfo,
 			Event:     output.EventSourcePreflight,
 			SourceID:  sourceID,
-			Message:   fmt.Sprintf("[%s] already-present locally: %d track(s)", sourceID, len(plan.ExistingTrackIDs)),
+			Message:   fmt.Sprintf("[%s] already-present locally: %d track(s)", sourceID, len(existingTrackIDs)),
 		})
 		return
 	}
 
 	const maxDetailed = 20
-	limit := len(plan.ExistingTrackIDs)
+	limit := len(existingTrackIDs)
 	if limit > maxDetailed {
 		limit = maxDetailed
 	}
 	for i := 0; i < limit; i++ {
-		id := plan.ExistingTrackIDs[i]
-		label := spotifyTrackDisplayNameFromState(id, plan.TrackMetadata, plan.State)
+		id := existingTrackIDs[i]
+		label := trackLabel(id)
 		if strings.TrimSpace(label) == "" {
 			label = id
 		}
@@ -746,13 +1174,13 @@ func emitSpotifyDeemixExistingTrackStatus(s *Syncer, sourceID string, plan spoti
 			Message:   fmt.Sprintf("[%s] [skip] %s (%s) (already-present)", sourceID, id, label),
 		})
 	}
-	if len(plan.ExistingTrackIDs) > limit {
+	if len(existingTrackIDs) > limit {
 		_ = s.Emitter.Emit(output.Event{
 			Timestamp: s.Now(),
 			Level:     output.LevelInfo,
 			Event:     output.EventSourcePreflight,
 			SourceID:  sourceID,
-			Message:   fmt.Sprintf("[%s] [skip] ... and %d more already-present track(s)", sourceID, len(plan.ExistingTrackIDs)-limit),
+			Message:   fmt.Sprintf("[%s] [skip] ... and %d more already-present track(s)", sourceID, len(existingTrackIDs)-limit),
 		})
 	}
 }
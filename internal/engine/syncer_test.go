@@ -3,7 +3,9 @@ package engine
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -13,6 +15,7 @@ import (
 
 	"github.com/jaa/update-downloads/internal/auth"
 	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/engine/progress"
 	"github.com/jaa/update-downloads/internal/output"
 )
 
@@ -437,8 +440,26 @@ func TestIsGracefulBreakOnExistingStopFalseWhenDisabled(t *testing.T) {
 	}
 }
 
+func TestIsGracefulBreakOnExistingStopRecognizesYouTubeSource(t *testing.T) {
+	source := config.Source{
+		Type: config.SourceTypeYouTube,
+		Sync: config.SyncPolicy{
+			BreakOnExisting: boolPtrSyncer(true),
+		},
+	}
+	execResult := ExecResult{
+		ExitCode:   101,
+		StdoutTail: "[download] Song has already been recorded in the archive",
+	}
+
+	if !isGracefulBreakOnExistingStop(source, nil, execResult) {
+		t.Fatalf("expected graceful break-on-existing detection for youtube")
+	}
+}
+
 func TestSyncerRetriesSpotifyWithUserAuthWhenRequired(t *testing.T) {
 	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
@@ -543,6 +564,9 @@ func TestSyncerDoesNotRetrySpotifyAuthWithoutPrompt(t *testing.T) {
 		},
 	}
 	syncer := NewSyncer(map[string]Adapter{"spotdl": fakeSpotifyAdapter{}}, runner, output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true))
+	originalEnsure := ensureSpotDLUserTokenFn
+	t.Cleanup(func() { ensureSpotDLUserTokenFn = originalEnsure })
+	ensureSpotDLUserTokenFn = func(ctx context.Context) (bool, error) { return false, nil }
 
 	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{AllowPrompt: false})
 	if err != nil {
@@ -556,8 +580,65 @@ func TestSyncerDoesNotRetrySpotifyAuthWithoutPrompt(t *testing.T) {
 	}
 }
 
+func TestSyncerRetriesSpotifyWithCachedLoginWithoutPrompt(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	for _, dir := range []string{targetDir, stateDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "spotify-source",
+				Type:      config.SourceTypeSpotify,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://open.spotify.com/playlist/a",
+				StateFile: "spotify-source.sync.spotdl",
+				Adapter:   config.AdapterSpec{Kind: "spotdl"},
+			},
+		},
+	}
+
+	runner := &sequenceRunner{
+		results: []ExecResult{
+			{ExitCode: 1, StderrTail: "HTTP Error ... returned 401 due to Valid user authentication required"},
+			{ExitCode: 0},
+		},
+	}
+	syncer := NewSyncer(map[string]Adapter{"spotdl": fakeSpotifyAdapter{}}, runner, output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true))
+	originalEnsure := ensureSpotDLUserTokenFn
+	t.Cleanup(func() { ensureSpotDLUserTokenFn = originalEnsure })
+	ensureSpotDLUserTokenFn = func(ctx context.Context) (bool, error) { return true, nil }
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{AllowPrompt: false})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || len(runner.specs) != 2 {
+		t.Fatalf("expected a successful headless retry, got %+v after %d runs", result, len(runner.specs))
+	}
+	retryArgs := strings.Join(runner.specs[1].Args, " ")
+	if !strings.Contains(retryArgs, "--user-auth") || !strings.Contains(retryArgs, "--headless") {
+		t.Fatalf("expected retry with --user-auth --headless, got %v", runner.specs[1].Args)
+	}
+}
+
 func TestSyncerSpotifyRetryDropsHeadlessWhenBrowserChosen(t *testing.T) {
 	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
@@ -634,6 +715,7 @@ func TestSyncerSpotifyRetryDropsHeadlessWhenBrowserChosen(t *testing.T) {
 
 func TestSyncerSpotifyRetryKeepsHeadlessWhenBrowserDeclined(t *testing.T) {
 	tmp := t.TempDir()
+	t.Setenv("HOME", tmp)
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
@@ -703,6 +785,52 @@ func TestSyncerSpotifyRetryKeepsHeadlessWhenBrowserDeclined(t *testing.T) {
 	}
 }
 
+func TestEnsureSpotDLUserTokenReusesTokenRefreshedWhileWaiting(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path, err := auth.SpotDLUserTokenCachePath()
+	if err != nil {
+		t.Fatalf("token path: %v", err)
+	}
+	expired := auth.SpotifyUserToken{AccessToken: "old", RefreshToken: "refresh", ExpiresAt: time.Now().Add(-time.Hour).Unix()}
+	if err := auth.SaveSpotifyUserToken(path, expired); err != nil {
+		t.Fatalf("save token: %v", err)
+	}
+
+	unlock, _, err := auth.LockSpotifyUserTokenCache(context.Background(), path)
+	if err != nil {
+		t.Fatalf("lock: %v", err)
+	}
+	type ensureResult struct {
+		ok  bool
+		err error
+	}
+	done := make(chan ensureResult, 1)
+	go func() {
+		ok, err := ensureSpotDLUserToken(context.Background())
+		done <- ensureResult{ok: ok, err: err}
+	}()
+
+	// Another sync finishes its refresh while this one waits for the lock.
+	fresh := auth.SpotifyUserToken{AccessToken: "new", RefreshToken: "refresh", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	if err := auth.SaveSpotifyUserToken(path, fresh); err != nil {
+		t.Fatalf("save refreshed token: %v", err)
+	}
+	unlock()
+
+	result := <-done
+	if result.err != nil || !result.ok {
+		t.Fatalf("expected the refreshed token to be reused without a second refresh, got ok=%v err=%v", result.ok, result.err)
+	}
+	cached, err := auth.LoadSpotifyUserToken(path)
+	if err != nil {
+		t.Fatalf("load token: %v", err)
+	}
+	if cached.AccessToken != "new" {
+		t.Fatalf("expected refreshed token to stay cached, got %+v", cached)
+	}
+}
+
 func TestIsSpotifyRateLimited(t *testing.T) {
 	source := config.Source{
 		Type:    config.SourceTypeSpotify,
@@ -1793,7 +1921,7 @@ func TestSyncerSoundCloudFreeDLRunsSeparateFlowAndUpdatesStateArchive(t *testing
 		openedURLs = append(openedURLs, rawURL)
 		return nil
 	}
-	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, timeout time.Duration, metadata soundCloudFreeDownloadMetadata) (string, error) {
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
 		path := filepath.Join(dir, "track-"+metadata.ID+".wav")
 		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
 			return "", err
@@ -1849,21 +1977,37 @@ func TestSyncerSoundCloudFreeDLRunsSeparateFlowAndUpdatesStateArchive(t *testing
 	if _, ok := archiveKnown["222"]; !ok {
 		t.Fatalf("expected id 222 in archive, got %+v", archiveKnown)
 	}
+	provenance, err := loadSoundCloudProvenance(stateDir, "sc-free")
+	if err != nil {
+		t.Fatalf("load provenance: %v", err)
+	}
+	if record := provenance["111"]; record.Adapter != "scdl-freedl" || record.Provider != soundCloudProvenanceFreeDL || record.AddedAt.IsZero() {
+		t.Fatalf("expected free-dl provenance for 111, got %+v", record)
+	}
 }
 
-func TestSyncerSoundCloudFreeDLOldestFirstReversesBrowserHandoffOrder(t *testing.T) {
+func TestSyncerSoundCloudFreeDLAdoptsUntrackedDownloadInTargetDir(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
 	downloadsDir := filepath.Join(tmp, "downloads")
-	if err := os.MkdirAll(targetDir, 0o755); err != nil {
-		t.Fatalf("mkdir target: %v", err)
+	for _, dir := range []string{targetDir, stateDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
 	}
-	if err := os.MkdirAll(stateDir, 0o755); err != nil {
-		t.Fatalf("mkdir state: %v", err)
+	// A previous run moved "Track One" into target_dir and died before the
+	// state append; "Track Two" is an unrelated file from long ago.
+	if err := os.WriteFile(filepath.Join(targetDir, "Artist 111 - Track One.wav"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write leftover: %v", err)
 	}
-	if err := os.MkdirAll(downloadsDir, 0o755); err != nil {
-		t.Fatalf("mkdir downloads: %v", err)
+	oldPath := filepath.Join(targetDir, "Track Two.mp3")
+	if err := os.WriteFile(oldPath, []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write old file: %v", err)
+	}
+	old := time.Now().Add(-30 * 24 * time.Hour)
+	if err := os.Chtimes(oldPath, old, old); err != nil {
+		t.Fatalf("age old file: %v", err)
 	}
 
 	cfg := config.Config{
@@ -1905,19 +2049,16 @@ func TestSyncerSoundCloudFreeDLOldestFirstReversesBrowserHandoffOrder(t *testing
 		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
 	})
 
-	tracks := []soundCloudRemoteTrack{
-		{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
-		{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
-		{ID: "333", Title: "Track Three", URL: "https://soundcloud.com/a/three"},
-	}
 	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
-		return tracks, nil
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+			{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
+		}, nil
 	}
 	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
 		return soundCloudFreeDownloadMetadata{
 			ID:            track.ID,
 			Title:         track.Title,
-			Artist:        "Artist " + track.ID,
 			SoundCloudURL: track.URL,
 			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
 		}, nil
@@ -1928,12 +2069,12 @@ func TestSyncerSoundCloudFreeDLOldestFirstReversesBrowserHandoffOrder(t *testing
 	browserDownloadsDirFn = func() (string, error) {
 		return downloadsDir, nil
 	}
-	openedURLs := make([]string, 0, len(tracks))
+	openedURLs := []string{}
 	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
 		openedURLs = append(openedURLs, rawURL)
 		return nil
 	}
-	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, timeout time.Duration, metadata soundCloudFreeDownloadMetadata) (string, error) {
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
 		path := filepath.Join(dir, "track-"+metadata.ID+".wav")
 		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
 			return "", err
@@ -1942,40 +2083,63 @@ func TestSyncerSoundCloudFreeDLOldestFirstReversesBrowserHandoffOrder(t *testing
 	}
 	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
 
-	runner := &freeDownloadRunner{}
+	var out bytes.Buffer
 	syncer := NewSyncer(
 		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
-		runner,
-		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+		&freeDownloadRunner{},
+		output.NewHumanEmitter(&out, &out, false, true),
 	)
-
 	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
 	if err != nil {
 		t.Fatalf("sync: %v", err)
 	}
 	if result.Succeeded != 1 || result.Failed != 0 {
-		t.Fatalf("expected successful source run, got %+v", result)
+		t.Fatalf("expected successful source run, got %+v\n%s", result, out.String())
 	}
-	wantOpened := []string{
-		"https://hypeddit.com/pichi/111",
-		"https://hypeddit.com/pichi/222",
-		"https://hypeddit.com/pichi/333",
+	if !reflect.DeepEqual(openedURLs, []string{"https://hypeddit.com/pichi/222"}) {
+		t.Fatalf("expected only the unrecovered track to open the gate, got %v", openedURLs)
 	}
-	if !reflect.DeepEqual(openedURLs, wantOpened) {
-		t.Fatalf("expected newest_first browser handoff order %v, got %v", wantOpened, openedURLs)
+	if !strings.Contains(out.String(), "[sc-free] [adopt] 111 (Track One) found untracked in target_dir: Artist 111 - Track One.wav") {
+		t.Fatalf("expected adopt line, got:\n%s", out.String())
+	}
+
+	state, err := parseSoundCloudSyncState(filepath.Join(stateDir, "sc-free.sync.scdl"))
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if got := state.ByID["111"].FilePath; got != "Artist 111 - Track One.wav" {
+		t.Fatalf("expected adopted file in state, got %q", got)
+	}
+	if got := state.ByID["222"].FilePath; got != "track-222.wav" {
+		t.Fatalf("expected downloaded file in state, got %q", got)
+	}
+	archivePath, err := config.ResolveArchiveFile(stateDir, "archive.txt", "sc-free")
+	if err != nil {
+		t.Fatalf("resolve archive path: %v", err)
+	}
+	archiveKnown, err := parseSoundCloudArchive(archivePath)
+	if err != nil {
+		t.Fatalf("parse archive: %v", err)
+	}
+	if _, ok := archiveKnown["111"]; !ok {
+		t.Fatalf("expected adopted id 111 in archive, got %+v", archiveKnown)
 	}
 }
 
-func TestSyncerSoundCloudFreeDLSkipsUnsupportedFreeDownloadHost(t *testing.T) {
+func TestSyncerSoundCloudFreeDLOldestFirstReversesBrowserHandoffOrder(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
+	downloadsDir := filepath.Join(tmp, "downloads")
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
 		t.Fatalf("mkdir target: %v", err)
 	}
 	if err := os.MkdirAll(stateDir, 0o755); err != nil {
 		t.Fatalf("mkdir state: %v", err)
 	}
+	if err := os.MkdirAll(downloadsDir, 0o755); err != nil {
+		t.Fatalf("mkdir downloads: %v", err)
+	}
 
 	cfg := config.Config{
 		Version: 1,
@@ -2003,17 +2167,26 @@ func TestSyncerSoundCloudFreeDLSkipsUnsupportedFreeDownloadHost(t *testing.T) {
 	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
 	origApplyMetadata := applySoundCloudTrackMetadataFn
 	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
 	t.Cleanup(func() {
 		enumerateSoundCloudTracksFn = origEnumerate
 		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
 		applySoundCloudTrackMetadataFn = origApplyMetadata
 		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
+		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
 	})
 
+	tracks := []soundCloudRemoteTrack{
+		{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+		{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
+		{ID: "333", Title: "Track Three", URL: "https://soundcloud.com/a/three"},
+	}
 	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
-		return []soundCloudRemoteTrack{
-			{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
-		}, nil
+		return tracks, nil
 	}
 	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
 		return soundCloudFreeDownloadMetadata{
@@ -2021,17 +2194,28 @@ func TestSyncerSoundCloudFreeDLSkipsUnsupportedFreeDownloadHost(t *testing.T) {
 			Title:         track.Title,
 			Artist:        "Artist " + track.ID,
 			SoundCloudURL: track.URL,
-			PurchaseURL:   "https://example.com/freedl/" + track.ID,
+			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
 		}, nil
 	}
 	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
 		return nil
 	}
-	browserOpened := false
+	browserDownloadsDirFn = func() (string, error) {
+		return downloadsDir, nil
+	}
+	openedURLs := make([]string, 0, len(tracks))
 	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
-		browserOpened = true
+		openedURLs = append(openedURLs, rawURL)
 		return nil
 	}
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		path := filepath.Join(dir, "track-"+metadata.ID+".wav")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
 
 	runner := &freeDownloadRunner{}
 	syncer := NewSyncer(
@@ -2045,26 +2229,247 @@ func TestSyncerSoundCloudFreeDLSkipsUnsupportedFreeDownloadHost(t *testing.T) {
 		t.Fatalf("sync: %v", err)
 	}
 	if result.Succeeded != 1 || result.Failed != 0 {
-		t.Fatalf("expected successful source run with unsupported-host skip, got %+v", result)
+		t.Fatalf("expected successful source run, got %+v", result)
 	}
-	if browserOpened {
-		t.Fatalf("did not expect browser handoff for unsupported host")
+	wantOpened := []string{
+		"https://hypeddit.com/pichi/111",
+		"https://hypeddit.com/pichi/222",
+		"https://hypeddit.com/pichi/333",
 	}
-	if len(runner.specs) != 0 {
-		t.Fatalf("expected no yt-dlp subprocess runs for unsupported host skip, got %d", len(runner.specs))
+	if !reflect.DeepEqual(openedURLs, wantOpened) {
+		t.Fatalf("expected newest_first browser handoff order %v, got %v", wantOpened, openedURLs)
 	}
+}
 
+func TestSoundCloudFreeDLOrderedOldestFirstInterruptRecordsFinishedTracks(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	downloadsDir := filepath.Join(tmp, "downloads")
+	for _, dir := range []string{targetDir, stateDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
 	statePath := filepath.Join(stateDir, "sc-free.sync.scdl")
-	state, err := parseSoundCloudSyncState(statePath)
+	archivePath := filepath.Join(stateDir, "sc-free.archive.txt")
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+	}
+	source := config.Source{
+		ID:                  "sc-free",
+		Type:                config.SourceTypeSoundCloud,
+		Enabled:             true,
+		TargetDir:           targetDir,
+		URL:                 "https://soundcloud.com/user",
+		StateFile:           statePath,
+		DownloadArchivePath: archivePath,
+		Adapter:             config.AdapterSpec{Kind: "scdl-freedl"},
+	}
+
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
+	t.Cleanup(func() {
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
+		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
+		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
+	})
+
+	// Execution order is oldest first, so the last track handed off holds
+	// remote position 0 and every earlier track waits on it under --ordered.
+	tracks := []soundCloudRemoteTrack{
+		{ID: "333", Title: "Track Three", URL: "https://soundcloud.com/a/three"},
+		{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
+		{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist " + track.ID,
+			SoundCloudURL: track.URL,
+			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
+		}, nil
+	}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
+	browserDownloadsDirFn = func() (string, error) {
+		return downloadsDir, nil
+	}
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		if metadata.ID == "111" {
+			cancel()
+			return "", context.Canceled
+		}
+		path := filepath.Join(dir, "track-"+metadata.ID+".wav")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
+
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
+		&freeDownloadRunner{},
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+	outcome, err := syncer.runSoundCloudFreeDownloadSource(
+		ctx,
+		cfg,
+		source,
+		source,
+		nil,
+		tracks,
+		soundCloudStateSwap{},
+		DownloadOrderOldestFirst,
+		SyncOptions{Ordered: true},
+	)
 	if err != nil {
-		t.Fatalf("parse state: %v", err)
+		t.Fatalf("run free-dl flow: %v", err)
 	}
-	if len(state.ByID) != 0 {
-		t.Fatalf("did not expect state entries for unsupported-host skips, got %+v", state.ByID)
+	if !outcome.Interrupted {
+		t.Fatalf("expected interrupted outcome, got %+v", outcome)
+	}
+
+	stateBytes, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatalf("read state file: %v", err)
+	}
+	state := string(stateBytes)
+	for _, id := range []string{"333", "222"} {
+		if !strings.Contains(state, id) {
+			t.Fatalf("expected finished track %s in state file, got %q", id, state)
+		}
+	}
+	if strings.Contains(state, "111") {
+		t.Fatalf("expected interrupted track to stay out of state, got %q", state)
+	}
+	if strings.Index(state, "222") > strings.Index(state, "333") {
+		t.Fatalf("expected state entries in remote order, got %q", state)
 	}
 }
 
-func TestSyncerSoundCloudFreeDLSkipsTracksWithoutFreeLink(t *testing.T) {
+func TestSyncerSoundCloudFreeDLSkipsUnsupportedFreeDownloadHost(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "sc-free",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-free.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl-freedl"},
+			},
+		},
+	}
+
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
+		openURLInBrowserFn = origOpenBrowser
+	})
+
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+		}, nil
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist " + track.ID,
+			SoundCloudURL: track.URL,
+			PurchaseURL:   "https://example.com/freedl/" + track.ID,
+		}, nil
+	}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
+	browserOpened := false
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		browserOpened = true
+		return nil
+	}
+
+	runner := &freeDownloadRunner{}
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
+		runner,
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful source run with unsupported-host skip, got %+v", result)
+	}
+	if browserOpened {
+		t.Fatalf("did not expect browser handoff for unsupported host")
+	}
+	if len(runner.specs) != 0 {
+		t.Fatalf("expected no yt-dlp subprocess runs for unsupported host skip, got %d", len(runner.specs))
+	}
+
+	statePath := filepath.Join(stateDir, "sc-free.sync.scdl")
+	state, err := parseSoundCloudSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if len(state.ByID) != 0 {
+		t.Fatalf("did not expect state entries for unsupported-host skips, got %+v", state.ByID)
+	}
+}
+
+func TestSyncerSoundCloudFreeDLSkipsTracksWithoutFreeLink(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
@@ -2226,6 +2631,117 @@ func TestSyncerSoundCloudSCDLDoesNotDeleteKnownGapIfAdapterDoesNotRewriteState(t
 	}
 }
 
+func TestSyncerMonitorSourceRecordsNewTracksWithoutDownloading(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "sc-artist",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/artist/tracks",
+				StateFile: "sc-artist.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl"},
+				Monitor:   true,
+			},
+		},
+	}
+
+	remote := []soundCloudRemoteTrack{
+		{ID: "111", Title: "Old One", URL: "https://soundcloud.com/artist/old-one"},
+	}
+	origEnumerate := enumerateSoundCloudTracksFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+	})
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return remote, nil
+	}
+
+	runner := &freeDownloadRunner{}
+	var logs bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl": fakeAdapter{}},
+		runner,
+		output.NewHumanEmitter(&logs, &logs, false, true),
+	)
+	if result, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil || result.Succeeded != 1 {
+		t.Fatalf("baseline sync: result=%+v err=%v\n%s", result, err, logs.String())
+	}
+	if !strings.Contains(logs.String(), "[sc-artist] monitor: recorded 1 remote track(s) as the baseline") {
+		t.Fatalf("expected baseline message, got:\n%s", logs.String())
+	}
+
+	remote = append([]soundCloudRemoteTrack{{ID: "222", Title: "New One", URL: "https://soundcloud.com/artist/new-one"}}, remote...)
+	logs.Reset()
+	if result, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil || result.Succeeded != 1 {
+		t.Fatalf("monitor sync: result=%+v err=%v", result, err)
+	}
+	if len(runner.specs) != 0 {
+		t.Fatalf("expected monitor source to download nothing, got %d run(s)", len(runner.specs))
+	}
+	for _, want := range []string{
+		"[sc-artist] [new] 222 (New One) https://soundcloud.com/artist/new-one",
+		"[sc-artist] monitor: 1 new track(s), 2 not pulled yet; nothing downloaded",
+	} {
+		if !strings.Contains(logs.String(), want) {
+			t.Fatalf("expected %q, got:\n%s", want, logs.String())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "sc-artist.sync.scdl")); !os.IsNotExist(err) {
+		t.Fatalf("expected monitor source to leave the state file alone, got %v", err)
+	}
+	ledger, ok, err := readSourceMonitorFile(stateDir, "sc-artist")
+	if err != nil || !ok || len(ledger.Tracks) != 2 {
+		t.Fatalf("unexpected monitor ledger: %+v ok=%v err=%v", ledger, ok, err)
+	}
+
+	reports, err := filepath.Glob(filepath.Join(stateDir, "runs", "*", RunReportFileName))
+	if err != nil || len(reports) != 2 {
+		t.Fatalf("expected two run reports, got %v err=%v", reports, err)
+	}
+	found := false
+	for _, path := range reports {
+		payload, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read report: %v", err)
+		}
+		var report RunReport
+		if err := json.Unmarshal(payload, &report); err != nil {
+			t.Fatalf("decode report: %v", err)
+		}
+		if len(report.Sources) == 1 && len(report.Sources[0].NewTracks) == 1 && report.Sources[0].NewTracks[0].ID == "222" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected a run report listing the new track")
+	}
+
+	status := InspectSourceStatus(cfg.Defaults, cfg.Sources[0])
+	if !status.Monitor || status.Monitored != 2 || status.Unpulled != 2 {
+		t.Fatalf("unexpected monitor status: %+v", status)
+	}
+}
+
 func TestSyncerSoundCloudFreeDLUsesBrowserFallbackForHypeddit(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
@@ -2306,7 +2822,7 @@ func TestSyncerSoundCloudFreeDLUsesBrowserFallbackForHypeddit(t *testing.T) {
 		return nil
 	}
 	downloadedPath := filepath.Join(downloadsDir, "MASTER BOFUNK.wav")
-	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, timeout time.Duration, metadata soundCloudFreeDownloadMetadata) (string, error) {
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
 		if err := os.WriteFile(downloadedPath, []byte("audio"), 0o644); err != nil {
 			return "", err
 		}
@@ -2345,19 +2861,15 @@ func TestSyncerSoundCloudFreeDLUsesBrowserFallbackForHypeddit(t *testing.T) {
 	}
 }
 
-func TestSyncerSoundCloudFreeDLSkipsHypedditTimeoutAndContinues(t *testing.T) {
+func TestSyncerSoundCloudFreeDLFlagsSuspectQualityDownloads(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
 	downloadsDir := filepath.Join(tmp, "downloads")
-	if err := os.MkdirAll(targetDir, 0o755); err != nil {
-		t.Fatalf("mkdir target: %v", err)
-	}
-	if err := os.MkdirAll(stateDir, 0o755); err != nil {
-		t.Fatalf("mkdir state: %v", err)
-	}
-	if err := os.MkdirAll(downloadsDir, 0o755); err != nil {
-		t.Fatalf("mkdir downloads: %v", err)
+	for _, dir := range []string{targetDir, stateDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
 	}
 
 	cfg := config.Config{
@@ -2369,6 +2881,7 @@ func TestSyncerSoundCloudFreeDLSkipsHypedditTimeoutAndContinues(t *testing.T) {
 			ContinueOnError:       true,
 			CommandTimeoutSeconds: 900,
 		},
+		FreeDL: config.FreeDL{VerifyQuality: true},
 		Sources: []config.Source{
 			{
 				ID:        "sc-free",
@@ -2389,6 +2902,7 @@ func TestSyncerSoundCloudFreeDLSkipsHypedditTimeoutAndContinues(t *testing.T) {
 	origDetectBrowserDownload := detectBrowserDownloadedFileFn
 	origBrowserDownloadsDir := browserDownloadsDirFn
 	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
+	origProbeSpectral := probeSpectralCeilingFn
 	t.Cleanup(func() {
 		enumerateSoundCloudTracksFn = origEnumerate
 		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
@@ -2397,24 +2911,26 @@ func TestSyncerSoundCloudFreeDLSkipsHypedditTimeoutAndContinues(t *testing.T) {
 		detectBrowserDownloadedFileFn = origDetectBrowserDownload
 		browserDownloadsDirFn = origBrowserDownloadsDir
 		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
+		probeSpectralCeilingFn = origProbeSpectral
 	})
 
 	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
 		return []soundCloudRemoteTrack{
-			{ID: "111", Title: "Stuck Track", URL: "https://soundcloud.com/a/stuck"},
-			{ID: "222", Title: "Good Track", URL: "https://soundcloud.com/a/good"},
+			{ID: "111", Title: "PICHI - BO FUNK [FREE DL]", URL: "https://soundcloud.com/a/one"},
 		}, nil
 	}
 	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
 		return soundCloudFreeDownloadMetadata{
 			ID:            track.ID,
 			Title:         track.Title,
-			Artist:        "Artist " + track.ID,
+			Artist:        "PICHI",
 			SoundCloudURL: track.URL,
-			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
+			PurchaseURL:   "https://hypeddit.com/pichi/pichibofunk",
 		}, nil
 	}
+	var tagged soundCloudFreeDownloadMetadata
 	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		tagged = metadata
 		return nil
 	}
 	browserDownloadsDirFn = func() (string, error) {
@@ -2423,71 +2939,67 @@ func TestSyncerSoundCloudFreeDLSkipsHypedditTimeoutAndContinues(t *testing.T) {
 	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
 		return nil
 	}
-	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, timeout time.Duration, metadata soundCloudFreeDownloadMetadata) (string, error) {
-		if metadata.ID == "111" {
-			return "", errBrowserDownloadIdleTimeout
-		}
-		path := filepath.Join(dir, "good-track.aif")
-		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+	downloadedPath := filepath.Join(downloadsDir, "MASTER BOFUNK.wav")
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		if err := os.WriteFile(downloadedPath, []byte("audio"), 0o644); err != nil {
 			return "", err
 		}
-		return path, nil
+		return downloadedPath, nil
 	}
 	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
+	probeSpectralCeilingFn = func(ctx context.Context, path string) (SpectralCeiling, error) {
+		return SpectralCeiling{CeilingHz: 16100, Suspect: true}, nil
+	}
 
-	runner := &freeDownloadRunner{}
+	var logs bytes.Buffer
 	syncer := NewSyncer(
 		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
-		runner,
-		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+		&freeDownloadRunner{},
+		output.NewHumanEmitter(&logs, &logs, false, true),
 	)
-
 	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
 	if err != nil {
 		t.Fatalf("sync: %v", err)
 	}
 	if result.Succeeded != 1 || result.Failed != 0 {
-		t.Fatalf("expected source run to continue after hypeddit timeout skip, got %+v", result)
-	}
-	if len(runner.specs) != 0 {
-		t.Fatalf("expected no yt-dlp subprocess run for browser flow, got %d", len(runner.specs))
+		t.Fatalf("expected successful source run, got %+v", result)
 	}
-
-	statePath := filepath.Join(stateDir, "sc-free.sync.scdl")
-	state, err := parseSoundCloudSyncState(statePath)
-	if err != nil {
-		t.Fatalf("parse state: %v", err)
+	if !strings.Contains(logs.String(), "111 looks upsampled from lossy: spectrum ends at 16.1kHz (suspect-quality)") {
+		t.Fatalf("expected suspect-quality warning, got:\n%s", logs.String())
 	}
-	if _, ok := state.ByID["111"]; ok {
-		t.Fatalf("did not expect timed-out id in state, got %+v", state.ByID)
+	if tagged.QualityNote != SuspectQualityMarker+" ceiling=16.1kHz" {
+		t.Fatalf("expected quality note in tags, got %q", tagged.QualityNote)
 	}
-	if _, ok := state.ByID["222"]; !ok {
-		t.Fatalf("expected successful id in state, got %+v", state.ByID)
+	if _, err := os.Stat(filepath.Join(targetDir, "MASTER BOFUNK.wav")); err != nil {
+		t.Fatalf("expected suspect download to be kept: %v", err)
 	}
-
-	stuckLogPath := filepath.Join(stateDir, "sc-free.freedl-stuck.jsonl")
-	stuckPayload, err := os.ReadFile(stuckLogPath)
+	payload, err := os.ReadFile(filepath.Join(stateDir, "sc-free.freedl-quality.jsonl"))
 	if err != nil {
-		t.Fatalf("read stuck log: %v", err)
+		t.Fatalf("read quality log: %v", err)
 	}
-	if !strings.Contains(string(stuckPayload), `"track_id":"111"`) {
-		t.Fatalf("expected stuck log to include timed-out track id, got: %s", string(stuckPayload))
+	var record soundCloudFreeDLQualityRecord
+	if err := json.Unmarshal(bytes.TrimSpace(payload), &record); err != nil {
+		t.Fatalf("decode quality record: %v", err)
 	}
-	if !strings.Contains(string(stuckPayload), `"stage":"browser-wait-timeout"`) {
-		t.Fatalf("expected stuck log timeout stage, got: %s", string(stuckPayload))
+	if record.TrackID != "111" || record.Path != "MASTER BOFUNK.wav" || record.CeilingHz != 16100 || !record.Suspect {
+		t.Fatalf("unexpected quality record: %+v", record)
 	}
 }
 
-func TestSyncerSoundCloudFreeDLWritesStuckLogOnBrowserLaunchFailure(t *testing.T) {
+func TestSyncerSoundCloudFreeDLSkipsHypedditTimeoutAndContinues(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
+	downloadsDir := filepath.Join(tmp, "downloads")
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
 		t.Fatalf("mkdir target: %v", err)
 	}
 	if err := os.MkdirAll(stateDir, 0o755); err != nil {
 		t.Fatalf("mkdir state: %v", err)
 	}
+	if err := os.MkdirAll(downloadsDir, 0o755); err != nil {
+		t.Fatalf("mkdir downloads: %v", err)
+	}
 
 	cfg := config.Config{
 		Version: 1,
@@ -2513,18 +3025,25 @@ func TestSyncerSoundCloudFreeDLWritesStuckLogOnBrowserLaunchFailure(t *testing.T
 
 	origEnumerate := enumerateSoundCloudTracksFn
 	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
 	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
 	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
 	t.Cleanup(func() {
 		enumerateSoundCloudTracksFn = origEnumerate
 		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
 		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
 		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
 	})
 
 	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
 		return []soundCloudRemoteTrack{
-			{ID: "111", Title: "Launch Fail", URL: "https://soundcloud.com/a/launch-fail"},
+			{ID: "111", Title: "Stuck Track", URL: "https://soundcloud.com/a/stuck"},
+			{ID: "222", Title: "Good Track", URL: "https://soundcloud.com/a/good"},
 		}, nil
 	}
 	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
@@ -2536,12 +3055,26 @@ func TestSyncerSoundCloudFreeDLWritesStuckLogOnBrowserLaunchFailure(t *testing.T
 			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
 		}, nil
 	}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
 	browserDownloadsDirFn = func() (string, error) {
-		return filepath.Join(tmp, "downloads"), nil
+		return downloadsDir, nil
 	}
 	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
-		return errors.New("launch failed")
+		return nil
+	}
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		if metadata.ID == "111" {
+			return "", errBrowserDownloadIdleTimeout
+		}
+		path := filepath.Join(dir, "good-track.aif")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
 	}
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
 
 	runner := &freeDownloadRunner{}
 	syncer := NewSyncer(
@@ -2554,32 +3087,47 @@ func TestSyncerSoundCloudFreeDLWritesStuckLogOnBrowserLaunchFailure(t *testing.T
 	if err != nil {
 		t.Fatalf("sync: %v", err)
 	}
-	if result.Failed != 1 {
-		t.Fatalf("expected one failed source, got %+v", result)
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected source run to continue after hypeddit timeout skip, got %+v", result)
+	}
+	if len(runner.specs) != 0 {
+		t.Fatalf("expected no yt-dlp subprocess run for browser flow, got %d", len(runner.specs))
 	}
 
-	stuckLogPath := filepath.Join(stateDir, "sc-free.freedl-stuck.jsonl")
-	stuckPayload, err := os.ReadFile(stuckLogPath)
-	if err != nil {
+	statePath := filepath.Join(stateDir, "sc-free.sync.scdl")
+	state, err := parseSoundCloudSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if _, ok := state.ByID["111"]; ok {
+		t.Fatalf("did not expect timed-out id in state, got %+v", state.ByID)
+	}
+	if _, ok := state.ByID["222"]; !ok {
+		t.Fatalf("expected successful id in state, got %+v", state.ByID)
+	}
+
+	stuckLogPath := filepath.Join(stateDir, "sc-free.freedl-stuck.jsonl")
+	stuckPayload, err := os.ReadFile(stuckLogPath)
+	if err != nil {
 		t.Fatalf("read stuck log: %v", err)
 	}
 	if !strings.Contains(string(stuckPayload), `"track_id":"111"`) {
-		t.Fatalf("expected stuck log to include launch-failed track id, got: %s", string(stuckPayload))
+		t.Fatalf("expected stuck log to include timed-out track id, got: %s", string(stuckPayload))
 	}
-	if !strings.Contains(string(stuckPayload), `"stage":"browser-launch"`) {
-		t.Fatalf("expected stuck log launch stage, got: %s", string(stuckPayload))
+	if !strings.Contains(string(stuckPayload), `"stage":"browser-wait-timeout"`) {
+		t.Fatalf("expected stuck log timeout stage, got: %s", string(stuckPayload))
 	}
 }
 
-func TestSyncerSoundCloudFreeDLRequiresPreflight(t *testing.T) {
+func TestSyncerSoundCloudFreeDLOpensConcurrentGatesInBatches(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
-	if err := os.MkdirAll(targetDir, 0o755); err != nil {
-		t.Fatalf("mkdir target: %v", err)
-	}
-	if err := os.MkdirAll(stateDir, 0o755); err != nil {
-		t.Fatalf("mkdir state: %v", err)
+	downloadsDir := filepath.Join(tmp, "downloads")
+	for _, dir := range []string{targetDir, stateDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
 	}
 
 	cfg := config.Config{
@@ -2591,6 +3139,7 @@ func TestSyncerSoundCloudFreeDLRequiresPreflight(t *testing.T) {
 			ContinueOnError:       true,
 			CommandTimeoutSeconds: 900,
 		},
+		FreeDL: config.FreeDL{ConcurrentGates: 2},
 		Sources: []config.Source{
 			{
 				ID:        "sc-free",
@@ -2604,52 +3153,138 @@ func TestSyncerSoundCloudFreeDLRequiresPreflight(t *testing.T) {
 		},
 	}
 
-	runner := &freeDownloadRunner{}
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
+	origDetectBrowserDownloads := detectBrowserDownloadsFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
+		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
+		detectBrowserDownloadsFn = origDetectBrowserDownloads
+		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
+	})
+
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+			{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
+			{ID: "333", Title: "Track Three", URL: "https://soundcloud.com/a/three"},
+		}, nil
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist " + track.ID,
+			SoundCloudURL: track.URL,
+			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
+		}, nil
+	}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
+	browserDownloadsDirFn = func() (string, error) {
+		return downloadsDir, nil
+	}
+	opened := 0
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		opened++
+		return nil
+	}
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		t.Fatalf("expected concurrent detection, got single-gate wait for %s", metadata.ID)
+		return "", nil
+	}
+	batches := [][]string{}
+	openedAtDetect := []int{}
+	detectBrowserDownloadsFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, expected []browserDownloadExpectation, observe func(string, browserDownloadWaitStatus)) (map[string]string, error) {
+		keys := []string{}
+		for _, expectation := range expected {
+			keys = append(keys, expectation.Key)
+		}
+		batches = append(batches, keys)
+		openedAtDetect = append(openedAtDetect, opened)
+		found := map[string]string{}
+		for _, expectation := range expected {
+			if expectation.Key == "333" {
+				return found, errBrowserDownloadIdleTimeout
+			}
+			path := filepath.Join(dir, expectation.Metadata.Title+".wav")
+			if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+				return nil, err
+			}
+			found[expectation.Key] = path
+		}
+		return found, nil
+	}
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
+
 	syncer := NewSyncer(
 		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
-		runner,
+		&freeDownloadRunner{},
 		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
 	)
-
-	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{NoPreflight: true})
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
 	if err != nil {
 		t.Fatalf("sync: %v", err)
 	}
-	if result.Failed != 1 || result.Succeeded != 0 {
-		t.Fatalf("expected preflight requirement failure, got %+v", result)
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful source run, got %+v", result)
 	}
-	if len(runner.specs) != 0 {
-		t.Fatalf("expected no free-download subprocess execution when preflight is disabled")
+	if !reflect.DeepEqual(batches, [][]string{{"111", "222"}, {"333"}}) {
+		t.Fatalf("unexpected gate batches: %+v", batches)
+	}
+	if !reflect.DeepEqual(openedAtDetect, []int{2, 3}) {
+		t.Fatalf("expected both gates of a batch open before waiting, got %+v", openedAtDetect)
 	}
-}
 
-func spotifyStateIDsFromPayload(payload string) []string {
-	lines := strings.Split(payload, "\n")
-	ids := make([]string, 0, len(lines))
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			continue
+	state, err := parseSoundCloudSyncState(filepath.Join(stateDir, "sc-free.sync.scdl"))
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	for id, name := range map[string]string{"111": "Track One.wav", "222": "Track Two.wav"} {
+		entry, ok := state.ByID[id]
+		if !ok {
+			t.Fatalf("expected id %s in state, got %+v", id, state.ByID)
 		}
-		id, _ := parseSpotifyStateLine(trimmed)
-		if id == "" {
-			continue
+		if filepath.Base(entry.FilePath) != name {
+			t.Fatalf("expected %s attributed to %s, got %s", name, id, entry.FilePath)
 		}
-		ids = append(ids, id)
 	}
-	return ids
+	if _, ok := state.ByID["333"]; ok {
+		t.Fatalf("did not expect timed-out id in state, got %+v", state.ByID)
+	}
+	stuckPayload, err := os.ReadFile(filepath.Join(stateDir, "sc-free.freedl-stuck.jsonl"))
+	if err != nil {
+		t.Fatalf("read stuck log: %v", err)
+	}
+	if !strings.Contains(string(stuckPayload), `"track_id":"333"`) || !strings.Contains(string(stuckPayload), `"stage":"browser-wait-timeout"`) {
+		t.Fatalf("expected timeout stuck record for 333, got: %s", string(stuckPayload))
+	}
 }
 
-func TestSyncerCommandFailureEmitsNormalizedFailureDiagnostics(t *testing.T) {
+func TestSyncerSoundCloudFreeDLEmitsStructuredTrackStages(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
+	downloadsDir := filepath.Join(tmp, "downloads")
 	if err := os.MkdirAll(targetDir, 0o755); err != nil {
 		t.Fatalf("mkdir target: %v", err)
 	}
 	if err := os.MkdirAll(stateDir, 0o755); err != nil {
 		t.Fatalf("mkdir state: %v", err)
 	}
+	if err := os.MkdirAll(downloadsDir, 0o755); err != nil {
+		t.Fatalf("mkdir downloads: %v", err)
+	}
 
 	cfg := config.Config{
 		Version: 1,
@@ -2662,75 +3297,121 @@ func TestSyncerCommandFailureEmitsNormalizedFailureDiagnostics(t *testing.T) {
 		},
 		Sources: []config.Source{
 			{
-				ID:        "spotify-source",
-				Type:      config.SourceTypeSpotify,
+				ID:        "sc-free",
+				Type:      config.SourceTypeSoundCloud,
 				Enabled:   true,
 				TargetDir: targetDir,
-				URL:       "https://open.spotify.com/playlist/test",
-				StateFile: "spotify-source.sync.spotdl",
-				Adapter:   config.AdapterSpec{Kind: "fake"},
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-free.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl-freedl"},
 			},
 		},
 	}
 
-	runner := &execResultRunner{result: ExecResult{
-		ExitCode:   23,
-		StdoutTail: strings.Repeat("o", 64),
-		StderrTail: strings.Repeat("e", 64),
-	}}
-	emitter := &captureEventEmitter{}
-	syncer := NewSyncer(map[string]Adapter{"fake": fakeAdapter{}}, runner, emitter)
-	syncer.Now = func() time.Time { return time.Date(2026, 3, 19, 12, 0, 0, 0, time.UTC) }
-
-	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
-	if err != nil {
-		t.Fatalf("sync: %v", err)
-	}
-	if result.Failed != 1 {
-		t.Fatalf("expected one failed source, got %+v", result)
-	}
-
-	var failureEvent *output.Event
-	for i := range emitter.events {
-		if emitter.events[i].Event == output.EventSourceFailed && emitter.events[i].Level == output.LevelError {
-			failureEvent = &emitter.events[i]
-		}
-	}
-	if failureEvent == nil {
-		t.Fatalf("expected normalized source_failed event, got %+v", emitter.events)
-	}
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
+		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
+		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
+	})
 
-	if got := failureEvent.Details["adapter_kind"]; got != "fake" {
-		t.Fatalf("expected adapter_kind=fake, got %#v", got)
-	}
-	if got := failureEvent.Details["command"]; got != "fakebin run spotify-source" {
-		t.Fatalf("unexpected command detail %#v", got)
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Stuck Track", URL: "https://soundcloud.com/a/stuck"},
+			{ID: "222", Title: "Good Track", URL: "https://soundcloud.com/a/good"},
+		}, nil
 	}
-	if got := failureEvent.Details["exit_code"]; got != 23 {
-		t.Fatalf("unexpected exit code detail %#v", got)
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist " + track.ID,
+			SoundCloudURL: track.URL,
+			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
+		}, nil
 	}
-	if got := failureEvent.Details["failure_message"]; got != failureEvent.Message {
-		t.Fatalf("expected failure_message mirror, got %#v", got)
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
 	}
-	if got := failureEvent.Details["source_id"]; got != "spotify-source" {
-		t.Fatalf("expected source_id detail, got %#v", got)
+	browserDownloadsDirFn = func() (string, error) {
+		return downloadsDir, nil
 	}
-	if _, ok := failureEvent.Details["stdout_tail"]; !ok {
-		t.Fatalf("expected stdout_tail detail, got %+v", failureEvent.Details)
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		return nil
 	}
-	if _, ok := failureEvent.Details["stderr_tail"]; !ok {
-		t.Fatalf("expected stderr_tail detail, got %+v", failureEvent.Details)
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		observe(browserDownloadWaitStatus{Elapsed: 65 * time.Second, Idle: 20 * time.Second})
+		if metadata.ID == "111" {
+			return "", errBrowserDownloadIdleTimeout
+		}
+		path := filepath.Join(dir, "good-track.aif")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
 	}
-	logPath, ok := failureEvent.Details["failure_log_path"].(string)
-	if !ok || logPath == "" {
-		t.Fatalf("expected failure_log_path detail, got %+v", failureEvent.Details)
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
+
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"scdl-freedl": fakeAdapter{}}, &freeDownloadRunner{}, emitter)
+
+	if _, err := syncer.Sync(context.Background(), cfg, SyncOptions{}); err != nil {
+		t.Fatalf("sync: %v", err)
 	}
-	if _, err := os.Stat(logPath); err != nil {
-		t.Fatalf("expected persisted failure log at %s: %v", logPath, err)
+
+	sequence := []string{}
+	var waiting output.Event
+	for _, event := range emitter.events {
+		if !output.IsTrackEventName(event.Event) {
+			continue
+		}
+		entry := fmt.Sprintf("%v:%s", event.Details["track_id"], event.Event)
+		if stage, ok := event.Details["stage"].(string); ok {
+			entry += ":" + stage
+			if stage == progress.StageWaitingForBrowser && waiting.Event == "" {
+				waiting = event
+			}
+		}
+		if reason, _ := event.Details["reason"].(string); reason != "" {
+			entry += ":" + reason
+		}
+		sequence = append(sequence, entry)
+	}
+	expected := []string{
+		"111:track_started",
+		"111:track_progress:gate_opened",
+		"111:track_progress:waiting_for_browser",
+		"111:track_skip:hypeddit-timeout",
+		"222:track_started",
+		"222:track_progress:gate_opened",
+		"222:track_progress:waiting_for_browser",
+		"222:track_progress:detected_file",
+		"222:track_progress:moved",
+		"222:track_progress:tagged",
+		"222:track_done",
+	}
+	if strings.Join(sequence, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected track event sequence:\n%s", strings.Join(sequence, "\n"))
+	}
+	if waiting.Details["elapsed_ms"] != int64(65000) || waiting.Details["idle_ms"] != int64(20000) {
+		t.Fatalf("expected waiting timers in details, got %+v", waiting.Details)
+	}
+	if waiting.Details["adapter_kind"] != "scdl-freedl" || waiting.Details["index"] != 1 || waiting.Details["total"] != 2 {
+		t.Fatalf("unexpected waiting event details %+v", waiting.Details)
 	}
 }
 
-func TestRunGenericAdapterBreakOnExistingEmitsStructuredStopDetails(t *testing.T) {
+func TestSyncerSoundCloudFreeDLWritesStuckLogOnBrowserLaunchFailure(t *testing.T) {
 	tmp := t.TempDir()
 	targetDir := filepath.Join(tmp, "target")
 	stateDir := filepath.Join(tmp, "state")
@@ -2750,50 +3431,1212 @@ func TestRunGenericAdapterBreakOnExistingEmitsStructuredStopDetails(t *testing.T
 			ContinueOnError:       true,
 			CommandTimeoutSeconds: 900,
 		},
+		Sources: []config.Source{
+			{
+				ID:        "sc-free",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-free.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl-freedl"},
+			},
+		},
 	}
-	source := config.Source{
-		ID:        "soundcloud-likes",
-		Type:      config.SourceTypeSoundCloud,
-		Enabled:   true,
-		TargetDir: targetDir,
-		URL:       "https://soundcloud.com/user/likes",
-		StateFile: "soundcloud-likes.sync.scdl",
-		Adapter:   config.AdapterSpec{Kind: "fake"},
-	}
-
-	runner := &execResultRunner{result: ExecResult{
-		ExitCode:   1,
-		Duration:   2 * time.Second,
-		StderrTail: "yt_dlp.utils.ExistingVideoReached: Encountered a video that is already in the archive, stopping due to --break-on-existing",
-	}}
-	emitter := &captureEventEmitter{}
-	syncer := NewSyncer(map[string]Adapter{"fake": fakeAdapter{}}, runner, emitter)
-	syncer.Now = func() time.Time { return time.Date(2026, 3, 19, 12, 0, 0, 0, time.UTC) }
 
-	outcome := syncer.runGenericAdapter(context.Background(), cfg, source, fakeAdapter{}, source, nil, soundCloudStateSwap{}, DownloadOrderNewestFirst, SyncOptions{})
-	if outcome.Failed != 0 || outcome.Succeeded != 1 {
-		t.Fatalf("expected graceful break-on-existing success, got %+v", outcome)
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		openURLInBrowserFn = origOpenBrowser
+		browserDownloadsDirFn = origBrowserDownloadsDir
+	})
+
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Launch Fail", URL: "https://soundcloud.com/a/launch-fail"},
+		}, nil
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist " + track.ID,
+			SoundCloudURL: track.URL,
+			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
+		}, nil
+	}
+	browserDownloadsDirFn = func() (string, error) {
+		return filepath.Join(tmp, "downloads"), nil
+	}
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		return errors.New("launch failed")
 	}
 
-	var finishedEvent *output.Event
-	for i := range emitter.events {
-		if emitter.events[i].Event == output.EventSourceFinished {
-			finishedEvent = &emitter.events[i]
+	runner := &freeDownloadRunner{}
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
+		runner,
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Failed != 1 {
+		t.Fatalf("expected one failed source, got %+v", result)
+	}
+
+	stuckLogPath := filepath.Join(stateDir, "sc-free.freedl-stuck.jsonl")
+	stuckPayload, err := os.ReadFile(stuckLogPath)
+	if err != nil {
+		t.Fatalf("read stuck log: %v", err)
+	}
+	if !strings.Contains(string(stuckPayload), `"track_id":"111"`) {
+		t.Fatalf("expected stuck log to include launch-failed track id, got: %s", string(stuckPayload))
+	}
+	if !strings.Contains(string(stuckPayload), `"stage":"browser-launch"`) {
+		t.Fatalf("expected stuck log launch stage, got: %s", string(stuckPayload))
+	}
+}
+
+func TestSyncerSoundCloudFreeDLRequiresPreflight(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "sc-free",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-free.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl-freedl"},
+			},
+		},
+	}
+
+	runner := &freeDownloadRunner{}
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
+		runner,
+		output.NewHumanEmitter(&bytes.Buffer{}, &bytes.Buffer{}, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{NoPreflight: true})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Failed != 1 || result.Succeeded != 0 {
+		t.Fatalf("expected preflight requirement failure, got %+v", result)
+	}
+	if len(runner.specs) != 0 {
+		t.Fatalf("expected no free-download subprocess execution when preflight is disabled")
+	}
+}
+
+func spotifyStateIDsFromPayload(payload string) []string {
+	lines := strings.Split(payload, "\n")
+	ids := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		id, _ := parseSpotifyStateLine(trimmed)
+		if id == "" {
+			continue
 		}
+		ids = append(ids, id)
 	}
-	if finishedEvent == nil {
-		t.Fatalf("expected source_finished stop event, got %+v", emitter.events)
+	return ids
+}
+
+func TestSyncerCommandFailureEmitsNormalizedFailureDiagnostics(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
 	}
-	if got := finishedEvent.Details["stop_reason"]; got != "break_on_existing" {
-		t.Fatalf("expected stop_reason=break_on_existing, got %#v", got)
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
 	}
-	if got := finishedEvent.Details["stopped_on_existing"]; got != true {
-		t.Fatalf("expected stopped_on_existing=true, got %#v", got)
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "spotify-source",
+				Type:      config.SourceTypeSpotify,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://open.spotify.com/playlist/test",
+				StateFile: "spotify-source.sync.spotdl",
+				Adapter:   config.AdapterSpec{Kind: "fake"},
+			},
+		},
 	}
-	if got := finishedEvent.Details["adapter_kind"]; got != "fake" {
+
+	runner := &execResultRunner{result: ExecResult{
+		ExitCode:   23,
+		StdoutTail: strings.Repeat("o", 64),
+		StderrTail: strings.Repeat("e", 64),
+	}}
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"fake": fakeAdapter{}}, runner, emitter)
+	syncer.Now = func() time.Time { return time.Date(2026, 3, 19, 12, 0, 0, 0, time.UTC) }
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Failed != 1 {
+		t.Fatalf("expected one failed source, got %+v", result)
+	}
+
+	var failureEvent *output.Event
+	for i := range emitter.events {
+		if emitter.events[i].Event == output.EventSourceFailed && emitter.events[i].Level == output.LevelError {
+			failureEvent = &emitter.events[i]
+		}
+	}
+	if failureEvent == nil {
+		t.Fatalf("expected normalized source_failed event, got %+v", emitter.events)
+	}
+
+	if got := failureEvent.Details["adapter_kind"]; got != "fake" {
 		t.Fatalf("expected adapter_kind=fake, got %#v", got)
 	}
-	if !strings.Contains(finishedEvent.Message, "stopped at first existing track (break_on_existing)") {
-		t.Fatalf("unexpected stop message %q", finishedEvent.Message)
+	if got := failureEvent.Details["command"]; got != "fakebin run spotify-source" {
+		t.Fatalf("unexpected command detail %#v", got)
+	}
+	if got := failureEvent.Details["exit_code"]; got != 23 {
+		t.Fatalf("unexpected exit code detail %#v", got)
+	}
+	if got := failureEvent.Details["failure_message"]; got != failureEvent.Message {
+		t.Fatalf("expected failure_message mirror, got %#v", got)
+	}
+	if got := failureEvent.Details["source_id"]; got != "spotify-source" {
+		t.Fatalf("expected source_id detail, got %#v", got)
+	}
+	if _, ok := failureEvent.Details["stdout_tail"]; !ok {
+		t.Fatalf("expected stdout_tail detail, got %+v", failureEvent.Details)
+	}
+	if _, ok := failureEvent.Details["stderr_tail"]; !ok {
+		t.Fatalf("expected stderr_tail detail, got %+v", failureEvent.Details)
+	}
+	logPath, ok := failureEvent.Details["failure_log_path"].(string)
+	if !ok || logPath == "" {
+		t.Fatalf("expected failure_log_path detail, got %+v", failureEvent.Details)
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		t.Fatalf("expected persisted failure log at %s: %v", logPath, err)
+	}
+}
+
+func TestRunGenericAdapterBreakOnExistingEmitsStructuredStopDetails(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+	}
+	source := config.Source{
+		ID:        "soundcloud-likes",
+		Type:      config.SourceTypeSoundCloud,
+		Enabled:   true,
+		TargetDir: targetDir,
+		URL:       "https://soundcloud.com/user/likes",
+		StateFile: "soundcloud-likes.sync.scdl",
+		Adapter:   config.AdapterSpec{Kind: "fake"},
+	}
+
+	runner := &execResultRunner{result: ExecResult{
+		ExitCode:   1,
+		Duration:   2 * time.Second,
+		StderrTail: "yt_dlp.utils.ExistingVideoReached: Encountered a video that is already in the archive, stopping due to --break-on-existing",
+	}}
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"fake": fakeAdapter{}}, runner, emitter)
+	syncer.Now = func() time.Time { return time.Date(2026, 3, 19, 12, 0, 0, 0, time.UTC) }
+
+	outcome := syncer.runGenericAdapter(context.Background(), cfg, source, fakeAdapter{}, source, nil, soundCloudStateSwap{}, DownloadOrderNewestFirst, SyncOptions{})
+	if outcome.Failed != 0 || outcome.Succeeded != 1 {
+		t.Fatalf("expected graceful break-on-existing success, got %+v", outcome)
+	}
+
+	var finishedEvent *output.Event
+	for i := range emitter.events {
+		if emitter.events[i].Event == output.EventSourceFinished {
+			finishedEvent = &emitter.events[i]
+		}
+	}
+	if finishedEvent == nil {
+		t.Fatalf("expected source_finished stop event, got %+v", emitter.events)
+	}
+	if got := finishedEvent.Details["stop_reason"]; got != "break_on_existing" {
+		t.Fatalf("expected stop_reason=break_on_existing, got %#v", got)
+	}
+	if got := finishedEvent.Details["stopped_on_existing"]; got != true {
+		t.Fatalf("expected stopped_on_existing=true, got %#v", got)
+	}
+	if got := finishedEvent.Details["adapter_kind"]; got != "fake" {
+		t.Fatalf("expected adapter_kind=fake, got %#v", got)
+	}
+	if !strings.Contains(finishedEvent.Message, "stopped at first existing track (break_on_existing)") {
+		t.Fatalf("unexpected stop message %q", finishedEvent.Message)
+	}
+}
+
+func TestPrepareSoundCloudExecutionPlanSkipsFreeDownloadTracks(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+	}
+	source := config.Source{
+		ID:        "sc-stream",
+		Type:      config.SourceTypeSoundCloud,
+		Enabled:   true,
+		TargetDir: targetDir,
+		URL:       "https://soundcloud.com/user",
+		StateFile: "sc-stream.sync.scdl",
+		Sync:      config.SyncPolicy{FreeDownloads: config.FreeDownloadsSkip},
+		Adapter:   config.AdapterSpec{Kind: "scdl"},
+	}
+
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+	})
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Stream One", URL: "https://soundcloud.com/a/one"},
+			{ID: "222", Title: "Gated Two", URL: "https://soundcloud.com/a/two"},
+			{ID: "333", Title: "Stream Three", URL: "https://soundcloud.com/a/three"},
+		}, nil
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		if track.ID != "222" {
+			return soundCloudFreeDownloadMetadata{}, errSoundCloudNoFreeDownloadLink
+		}
+		return soundCloudFreeDownloadMetadata{ID: track.ID, PurchaseURL: "https://hypeddit.com/track/222"}, nil
+	}
+
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeAdapter{}}, &freeDownloadRunner{}, emitter)
+	plan, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{})
+	if err != nil {
+		t.Fatalf("prepare plan: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanupTempStateFiles(plan.StateSwap) })
+
+	if plan.Preflight == nil || plan.Preflight.PlannedDownloadCount != 2 || plan.Preflight.FreeDownloadSkipped != 1 {
+		t.Fatalf("expected gated track excluded from plan, got %+v", plan.Preflight)
+	}
+	if !reflect.DeepEqual(plan.Source.SelectedPlaylistIDs, []int{1, 3}) {
+		t.Fatalf("expected playlist items restricted to stream tracks, got %v", plan.Source.SelectedPlaylistIDs)
+	}
+	for _, track := range plan.PlannedTracks {
+		if track.ID == "222" {
+			t.Fatalf("did not expect gated track in planned tracks: %+v", plan.PlannedTracks)
+		}
+	}
+	foundSkip := false
+	for _, event := range emitter.events {
+		if strings.Contains(event.Message, "[skip] 222 (Gated Two) (free-downloads-skipped)") {
+			foundSkip = true
+		}
+	}
+	if !foundSkip {
+		t.Fatalf("expected free-download skip report, got %+v", emitter.events)
+	}
+}
+
+func TestIsSoundCloudFreeDownloadFlowHonorsOnlyPolicy(t *testing.T) {
+	source := config.Source{Type: config.SourceTypeSoundCloud, Adapter: config.AdapterSpec{Kind: "scdl"}}
+	if isSoundCloudFreeDownloadFlow(source) {
+		t.Fatalf("expected default scdl source to use stream-rip flow")
+	}
+	source.Sync.FreeDownloads = config.FreeDownloadsOnly
+	if !isSoundCloudFreeDownloadFlow(source) {
+		t.Fatalf("expected free_downloads=only to run the free-download flow")
+	}
+	if !isSoundCloudFreeDownloadFlow(config.Source{Type: config.SourceTypeSoundCloud, Adapter: config.AdapterSpec{Kind: "scdl-freedl"}}) {
+		t.Fatalf("expected scdl-freedl source to run the free-download flow")
+	}
+}
+
+type fakeSCDLClientIDAdapter struct{}
+
+func (a fakeSCDLClientIDAdapter) Kind() string                              { return "scdl" }
+func (a fakeSCDLClientIDAdapter) Binary() string                            { return "scdl" }
+func (a fakeSCDLClientIDAdapter) MinVersion() string                        { return "3.0.0" }
+func (a fakeSCDLClientIDAdapter) RequiredEnv(source config.Source) []string { return nil }
+func (a fakeSCDLClientIDAdapter) Validate(source config.Source) error       { return nil }
+func (a fakeSCDLClientIDAdapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (ExecSpec, error) {
+	args := []string{"-l", source.URL}
+	if source.SoundCloudClientID != "" {
+		args = append(args, "--client-id", source.SoundCloudClientID)
+	}
+	return ExecSpec{
+		Bin:            "scdl",
+		Args:           args,
+		Dir:            source.TargetDir,
+		Timeout:        timeout,
+		DisplayCommand: "scdl -l " + source.URL,
+	}, nil
+}
+
+func TestSyncerRetriesSCDLOnceWithRefreshedClientID(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "sc-likes",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user/likes",
+				StateFile: "sc-likes.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl"},
+			},
+		},
+	}
+
+	origResolve := resolveSoundCloudClientIDWithSourceFn
+	origRefresh := refreshSoundCloudClientIDFn
+	origSave := saveSoundCloudClientIDFn
+	origRecord := recordCredentialFailureFn
+	origClear := clearCredentialFailureFn
+	t.Cleanup(func() {
+		resolveSoundCloudClientIDWithSourceFn = origResolve
+		refreshSoundCloudClientIDFn = origRefresh
+		saveSoundCloudClientIDFn = origSave
+		recordCredentialFailureFn = origRecord
+		clearCredentialFailureFn = origClear
+	})
+	resolveSoundCloudClientIDWithSourceFn = func() (string, auth.CredentialStorageSource, error) {
+		return "stale-id", auth.CredentialStorageSourceKeychain, nil
+	}
+	refreshCalls := 0
+	refreshSoundCloudClientIDFn = func(ctx context.Context) (string, error) {
+		refreshCalls++
+		return "fresh-id", nil
+	}
+	saved := ""
+	saveSoundCloudClientIDFn = func(clientID string) error {
+		saved = clientID
+		return nil
+	}
+	recordCredentialFailureFn = func(string, auth.CredentialKind, auth.CredentialStorageSource, string, string) error { return nil }
+	clearCredentialFailureFn = func(string, auth.CredentialKind) error { return nil }
+
+	runner := &sequenceRunner{
+		results: []ExecResult{
+			{ExitCode: 1, StderrTail: "ERROR: [soundcloud] likes: Unable to download JSON metadata: HTTP Error 401: Unauthorized"},
+			{ExitCode: 0},
+		},
+	}
+	errOut := &bytes.Buffer{}
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeSCDLClientIDAdapter{}}, runner, output.NewHumanEmitter(errOut, errOut, false, true))
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{NoPreflight: true})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful retry result, got %+v\n%s", result, errOut.String())
+	}
+	if len(runner.specs) != 2 || refreshCalls != 1 {
+		t.Fatalf("expected one refresh and two runs, got refresh=%d runs=%d", refreshCalls, len(runner.specs))
+	}
+	if !strings.Contains(strings.Join(runner.specs[1].Args, " "), "--client-id fresh-id") {
+		t.Fatalf("expected retry to use refreshed client id, got %v", runner.specs[1].Args)
+	}
+	if saved != "fresh-id" {
+		t.Fatalf("expected refreshed client id to be saved, got %q", saved)
+	}
+}
+
+func TestShouldRetrySoundCloudWithRefreshedClientIDOnlyOnce(t *testing.T) {
+	source := config.Source{ID: "sc", Type: config.SourceTypeSoundCloud, Adapter: config.AdapterSpec{Kind: "scdl"}}
+	failure := ExecResult{ExitCode: 1, StderrTail: "soundcloud: HTTP Error 401: Unauthorized"}
+	if !shouldRetrySoundCloudWithRefreshedClientID(source, failure) {
+		t.Fatalf("expected 401 to trigger a client_id refresh retry")
+	}
+	source.SoundCloudClientID = "fresh-id"
+	if shouldRetrySoundCloudWithRefreshedClientID(source, failure) {
+		t.Fatalf("did not expect a second retry once a refreshed id was used")
+	}
+	source.SoundCloudClientID = ""
+	if shouldRetrySoundCloudWithRefreshedClientID(source, ExecResult{ExitCode: 1, StderrTail: "HTTP Error 404: Not Found"}) {
+		t.Fatalf("did not expect unrelated failures to retry")
+	}
+}
+
+func TestSyncerDeezerDeemixSkipsUnavailableAndRecordsState(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "deezer-mix",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/en/playlist/908622995",
+				StateFile: "deezer-mix.sync.deezer",
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+			},
+		},
+	}
+	statePath := filepath.Join(stateDir, "deezer-mix.sync.deezer")
+
+	origResolveCreds := resolveSpotifyCredentialsFn
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateDeezerTracksFn
+	t.Cleanup(func() {
+		resolveSpotifyCredentialsFn = origResolveCreds
+		resolveDeemixARLFn = origResolveARL
+		enumerateDeezerTracksFn = origEnumerate
+	})
+
+	resolveSpotifyCredentialsFn = func() (auth.SpotifyCredentials, error) {
+		t.Fatalf("deezer sources must not resolve spotify credentials")
+		return auth.SpotifyCredentials{}, nil
+	}
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateDeezerTracksFn = func(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+		return []deezerRemoteTrack{
+			{ID: "3135556", Title: "Harder, Better, Faster, Stronger", Artist: "Daft Punk", Readable: true},
+			{ID: "3135557", Title: "Blocked", Artist: "Daft Punk", Readable: false},
+			{ID: "3135558", Title: "One More Time", Artist: "Daft Punk", Readable: true},
+		}, nil
+	}
+
+	runner := &sequenceRunner{results: []ExecResult{
+		{ExitCode: 0},
+		{ExitCode: 0, StdoutTail: "Track unavailable on Deezer"},
+	}}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"deemix": fakeDeemixAdapter{}},
+		runner,
+		output.NewHumanEmitter(&out, &bytes.Buffer{}, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful deezer source run, got %+v\n%s", result, out.String())
+	}
+	if len(runner.specs) != 2 {
+		t.Fatalf("expected unreadable track to be skipped before deemix runs, got %d executions", len(runner.specs))
+	}
+	if got := runner.specs[0].Args[0]; got != "https://www.deezer.com/track/3135556" {
+		t.Fatalf("expected deezer track URL, got %q", got)
+	}
+	if !strings.Contains(out.String(), "[skip] 3135557 (Daft Punk - Blocked) (unavailable-on-deezer)") {
+		t.Fatalf("expected preflight unavailable skip, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[skip] 3135558 (Daft Punk - One More Time) (unavailable-on-deezer)") {
+		t.Fatalf("expected runtime unavailable skip, got:\n%s", out.String())
+	}
+
+	state, err := parseDeezerSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse deezer state: %v", err)
+	}
+	if _, ok := state.KnownIDs["3135556"]; !ok || len(state.KnownIDs) != 1 {
+		t.Fatalf("expected only the downloaded track in deezer state, got %+v", state.KnownIDs)
+	}
+	if got := state.Entries["3135556"].DisplayName; got != "Daft Punk - Harder, Better, Faster, Stronger" {
+		t.Fatalf("expected display name in deezer state, got %q", got)
+	}
+}
+
+func TestSyncerAppleMusicGamdlSkipsUnavailableAndRecordsState(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "am-chill",
+				Type:      config.SourceTypeAppleMusic,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://music.apple.com/us/playlist/chill/pl.u-abc123",
+				StateFile: "am-chill.sync.applemusic",
+				Adapter:   config.AdapterSpec{Kind: "gamdl"},
+			},
+		},
+	}
+	statePath := filepath.Join(stateDir, "am-chill.sync.applemusic")
+	if err := os.WriteFile(statePath, []byte(appleMusicStateHeader+"\n1440833541\ttitle=Known\tpath=Known.m4a\n"), 0o644); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "Known.m4a"), []byte("audio"), 0o644); err != nil {
+		t.Fatalf("write known track: %v", err)
+	}
+
+	origEnumerate := enumerateAppleMusicTracksFn
+	t.Cleanup(func() { enumerateAppleMusicTracksFn = origEnumerate })
+	enumerateAppleMusicTracksFn = func(ctx context.Context, source config.Source) ([]appleMusicRemoteTrack, string, error) {
+		return []appleMusicRemoteTrack{
+			{ID: "1440833100", Title: "New", Artist: "Artist", URL: "https://music.apple.com/us/song/1440833100", Playable: true},
+			{ID: "1440833200", Title: "Region Locked", Artist: "Artist", Playable: false},
+			{ID: "1440833300", Title: "Gone", Artist: "Artist", URL: "https://music.apple.com/us/song/1440833300", Playable: true},
+			{ID: "1440833541", Title: "Known", Artist: "Artist", URL: "https://music.apple.com/us/song/1440833541", Playable: true},
+		}, "us", nil
+	}
+
+	runner := &sequenceRunner{results: []ExecResult{
+		{ExitCode: 0},
+		{ExitCode: 0, StderrTail: "[WARNING] Song is not streamable or downloadable"},
+	}}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{"gamdl": fakeDeemixAdapter{}},
+		runner,
+		output.NewHumanEmitter(&out, &bytes.Buffer{}, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful apple music source run, got %+v\n%s", result, out.String())
+	}
+	if len(runner.specs) != 2 {
+		t.Fatalf("expected gamdl to run for the two new playable songs only, got %d executions", len(runner.specs))
+	}
+	if got := runner.specs[0].Args[0]; got != "https://music.apple.com/us/song/1440833100" {
+		t.Fatalf("expected apple music song URL, got %q", got)
+	}
+	if !strings.Contains(out.String(), "[skip] 1440833200 (Artist - Region Locked) (unavailable-on-apple-music)") {
+		t.Fatalf("expected preflight unavailable skip, got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[skip] 1440833300 (Artist - Gone) (unavailable-on-apple-music)") {
+		t.Fatalf("expected runtime unavailable skip, got:\n%s", out.String())
+	}
+
+	state, err := parseAppleMusicSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse apple music state: %v", err)
+	}
+	if _, ok := state.KnownIDs["1440833100"]; !ok || len(state.KnownIDs) != 2 {
+		t.Fatalf("expected the downloaded song added to apple music state, got %+v", state.KnownIDs)
+	}
+	if got := state.Entries["1440833100"].DisplayName; got != "Artist - New" {
+		t.Fatalf("expected display name in apple music state, got %q", got)
+	}
+}
+
+func TestSyncerSoundCloudFreeDLDefersGatesAfterInteractiveBudget(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	downloadsDir := filepath.Join(tmp, "downloads")
+	for _, dir := range []string{targetDir, stateDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		FreeDL: config.FreeDL{InteractiveBudgetSeconds: 15 * 60},
+		Sources: []config.Source{
+			{
+				ID:        "sc-free",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-free.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl-freedl"},
+			},
+		},
+	}
+
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
+		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
+		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
+	})
+
+	tracks := []soundCloudRemoteTrack{
+		{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+		{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
+		{ID: "333", Title: "Track Three", URL: "https://soundcloud.com/a/three"},
+	}
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return tracks, nil
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist",
+			SoundCloudURL: track.URL,
+			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID + "?ref=sc",
+		}, nil
+	}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
+	browserDownloadsDirFn = func() (string, error) {
+		return downloadsDir, nil
+	}
+	openedURLs := []string{}
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		openedURLs = append(openedURLs, rawURL)
+		return nil
+	}
+	// Each gate keeps the user in the browser for ten minutes.
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		now = now.Add(10 * time.Minute)
+		path := filepath.Join(dir, "track-"+metadata.ID+".wav")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
+		&freeDownloadRunner{},
+		output.NewHumanEmitter(stdout, stderr, false, true),
+	)
+	syncer.Now = func() time.Time { return now }
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful source run, got %+v", result)
+	}
+	// 0m < 15m opens the first gate, 10m < 15m the second, 20m defers the third.
+	if len(openedURLs) != 2 {
+		t.Fatalf("expected two gates before the budget ran out, got %v", openedURLs)
+	}
+
+	state, err := parseSoundCloudSyncState(filepath.Join(stateDir, "sc-free.sync.scdl"))
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if _, ok := state.ByID["333"]; ok {
+		t.Fatalf("expected deferred track to stay out of state so the next run retries it")
+	}
+
+	logs := stdout.String() + stderr.String()
+	for _, want := range []string{
+		"[skip] 333 (Track Three) (interactive-budget)",
+		"interactive time 20m0s across 2 gate(s) reached the 15m0s budget; deferred 1 gated track(s)",
+		"[sc-free] [deferred] 333 (Artist - Track Three) https://hypeddit.com/pichi/333",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, logs)
+		}
+	}
+	if strings.Contains(logs, "ref=sc") {
+		t.Fatalf("expected deferred purchase urls without query strings, got:\n%s", logs)
+	}
+	if result.DeferredGates != 1 {
+		t.Fatalf("expected one deferred gate in the result, got %+v", result)
+	}
+
+	// A --headless run opens no browser and defers the gate again (scan-gaps
+	// reaches it past the tracks now in state).
+	openedURLs = openedURLs[:0]
+	stdout.Reset()
+	stderr.Reset()
+	result, err = syncer.Sync(context.Background(), cfg, SyncOptions{NoBrowser: true, ScanGaps: true})
+	if err != nil {
+		t.Fatalf("headless sync: %v", err)
+	}
+	if len(openedURLs) != 0 || result.DeferredGates != 1 {
+		t.Fatalf("expected the headless run to defer without a browser, opened=%v result=%+v", openedURLs, result)
+	}
+	logs = stdout.String() + stderr.String()
+	for _, want := range []string{
+		"[skip] 333 (Track Three) (no-browser)",
+		"browser handoff disabled (--headless); deferred 1 gated track(s)",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("expected %q in headless output, got:\n%s", want, logs)
+		}
+	}
+}
+
+func TestSyncerSoundCloudFreeDLAutomatesHypedditGatesBeforeHandoff(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	downloadsDir := filepath.Join(tmp, "downloads")
+	for _, dir := range []string{targetDir, stateDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		FreeDL: config.FreeDL{Automation: config.FreeDLAutomationHeadless, Email: "me@example.com"},
+		Sources: []config.Source{
+			{
+				ID:        "sc-free",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-free.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl-freedl"},
+			},
+		},
+	}
+
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
+	origAutomate := automateGateFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
+		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
+		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
+		automateGateFn = origAutomate
+	})
+
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+			{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
+		}, nil
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist",
+			SoundCloudURL: track.URL,
+			PurchaseURL:   "https://hypeddit.com/pichi/" + track.ID,
+		}, nil
+	}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
+	browserDownloadsDirFn = func() (string, error) {
+		return downloadsDir, nil
+	}
+	automationDirs := []string{}
+	automateGateFn = func(ctx context.Context, purchaseURL string, settings gateAutomation) (string, error) {
+		if settings.Email != "me@example.com" || settings.ProfileDir != filepath.Join(stateDir, "freedl-browser-profile") {
+			t.Fatalf("unexpected automation settings: %+v", settings)
+		}
+		if strings.HasSuffix(purchaseURL, "/222") {
+			return "", fmt.Errorf("%w: %s", errGateBlocked, gateBlockedReason("comment"))
+		}
+		dir := t.TempDir()
+		automationDirs = append(automationDirs, dir)
+		path := filepath.Join(dir, "Artist - Track One.wav")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	openedURLs := []string{}
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		openedURLs = append(openedURLs, rawURL)
+		return nil
+	}
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		path := filepath.Join(dir, "track-"+metadata.ID+".wav")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
+		&freeDownloadRunner{},
+		output.NewHumanEmitter(stdout, stderr, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful source run, got %+v\n%s%s", result, stdout.String(), stderr.String())
+	}
+	if len(openedURLs) != 1 || openedURLs[0] != "https://hypeddit.com/pichi/222" {
+		t.Fatalf("expected only the blocked gate in the desktop browser, got %v", openedURLs)
+	}
+	for _, name := range []string{"Artist - Track One.wav", "track-222.wav"} {
+		if _, err := os.Stat(filepath.Join(targetDir, name)); err != nil {
+			t.Fatalf("expected %s in target dir: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(automationDirs[0]); !os.IsNotExist(err) {
+		t.Fatalf("expected the automation download dir to be removed, got %v", err)
+	}
+
+	state, err := parseSoundCloudSyncState(filepath.Join(stateDir, "sc-free.sync.scdl"))
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if _, ok := state.ByID["111"]; !ok {
+		t.Fatalf("expected the automated track in state")
+	}
+	logs := stdout.String() + stderr.String()
+	for _, want := range []string{
+		"[free-dl] hypeddit gate detected for 111; completing it in headless chrome",
+		"[free-dl] headless automation failed for 222: gate needs a manual step: gate asks for a comment and freedl.comment is unset; falling back to browser handoff",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, logs)
+		}
+	}
+}
+
+func TestSyncerSoundCloudFreeDLRoutesGatesByHost(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	downloadsDir := filepath.Join(tmp, "downloads")
+	for _, dir := range []string{targetDir, stateDir, downloadsDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		FreeDL: config.FreeDL{Automation: config.FreeDLAutomationHeadless},
+		Sources: []config.Source{
+			{
+				ID:        "sc-free",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://soundcloud.com/user",
+				StateFile: "sc-free.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl-freedl"},
+			},
+		},
+	}
+
+	origEnumerate := enumerateSoundCloudTracksFn
+	origFetchFree := fetchSoundCloudFreeDownloadMetadataFn
+	origApplyMetadata := applySoundCloudTrackMetadataFn
+	origOpenBrowser := openURLInBrowserFn
+	origDetectBrowserDownload := detectBrowserDownloadedFileFn
+	origBrowserDownloadsDir := browserDownloadsDirFn
+	origMoveBrowserDownload := moveDownloadedMediaToTargetFn
+	origAutomate := automateGateFn
+	origDirect := downloadDirectFreeDLFileFn
+	t.Cleanup(func() {
+		enumerateSoundCloudTracksFn = origEnumerate
+		fetchSoundCloudFreeDownloadMetadataFn = origFetchFree
+		applySoundCloudTrackMetadataFn = origApplyMetadata
+		openURLInBrowserFn = origOpenBrowser
+		detectBrowserDownloadedFileFn = origDetectBrowserDownload
+		browserDownloadsDirFn = origBrowserDownloadsDir
+		moveDownloadedMediaToTargetFn = origMoveBrowserDownload
+		automateGateFn = origAutomate
+		downloadDirectFreeDLFileFn = origDirect
+	})
+
+	purchaseURLs := map[string]string{
+		"111": "https://www.dropbox.com/s/abc/Track%20One.wav?dl=1",
+		"222": "https://www.bandlab.com/post/222",
+		"333": "https://example.com/gate/333",
+	}
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "Track One", URL: "https://soundcloud.com/a/one"},
+			{ID: "222", Title: "Track Two", URL: "https://soundcloud.com/a/two"},
+			{ID: "333", Title: "Track Three", URL: "https://soundcloud.com/a/three"},
+		}, nil
+	}
+	fetchSoundCloudFreeDownloadMetadataFn = func(ctx context.Context, track soundCloudRemoteTrack) (soundCloudFreeDownloadMetadata, error) {
+		return soundCloudFreeDownloadMetadata{
+			ID:            track.ID,
+			Title:         track.Title,
+			Artist:        "Artist",
+			SoundCloudURL: track.URL,
+			PurchaseURL:   purchaseURLs[track.ID],
+		}, nil
+	}
+	applySoundCloudTrackMetadataFn = func(ctx context.Context, filePath string, metadata soundCloudFreeDownloadMetadata) error {
+		return nil
+	}
+	browserDownloadsDirFn = func() (string, error) {
+		return downloadsDir, nil
+	}
+	automateGateFn = func(ctx context.Context, purchaseURL string, settings gateAutomation) (string, error) {
+		t.Fatalf("did not expect automation for %s", purchaseURL)
+		return "", nil
+	}
+	downloadDirectFreeDLFileFn = func(ctx context.Context, rawURL string) (string, error) {
+		if rawURL != purchaseURLs["111"] {
+			t.Fatalf("unexpected direct download %s", rawURL)
+		}
+		path := filepath.Join(t.TempDir(), "Track One.wav")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	openedURLs := []string{}
+	openURLInBrowserFn = func(ctx context.Context, rawURL string) error {
+		openedURLs = append(openedURLs, rawURL)
+		return nil
+	}
+	detectBrowserDownloadedFileFn = func(ctx context.Context, dir string, before map[string]mediaFileSnapshot, wait browserDownloadWaitSettings, metadata soundCloudFreeDownloadMetadata, observe func(browserDownloadWaitStatus)) (string, error) {
+		path := filepath.Join(dir, "track-"+metadata.ID+".wav")
+		if err := os.WriteFile(path, []byte("audio"), 0o644); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	moveDownloadedMediaToTargetFn = moveDownloadedMediaToTarget
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	syncer := NewSyncer(
+		map[string]Adapter{"scdl-freedl": fakeAdapter{}},
+		&freeDownloadRunner{},
+		output.NewHumanEmitter(stdout, stderr, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful source run, got %+v\n%s%s", result, stdout.String(), stderr.String())
+	}
+	if !reflect.DeepEqual(openedURLs, []string{purchaseURLs["222"]}) {
+		t.Fatalf("expected only the bandlab gate in the desktop browser, got %v", openedURLs)
+	}
+	for _, name := range []string{"Track One.wav", "track-222.wav"} {
+		if _, err := os.Stat(filepath.Join(targetDir, name)); err != nil {
+			t.Fatalf("expected %s in target dir: %v", name, err)
+		}
+	}
+	logs := stdout.String() + stderr.String()
+	for _, want := range []string{
+		"[free-dl] direct download link for 111; fetching it",
+		"[free-dl] bandlab gate detected for 222; opening browser",
+		"[skip] 333 (Track Three) (unsupported-free-download-host)",
+	} {
+		if !strings.Contains(logs, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, logs)
+		}
+	}
+}
+
+func TestAttachSoundCloudOAuthTokenSkipsRefreshOnDryRunAndDefersToCookiesFile(t *testing.T) {
+	orig := soundCloudOAuthAccessTokenFn
+	t.Cleanup(func() { soundCloudOAuthAccessTokenFn = orig })
+	refreshRequests := []bool{}
+	soundCloudOAuthAccessTokenFn = func(ctx context.Context, refresh bool) (string, bool, error) {
+		refreshRequests = append(refreshRequests, refresh)
+		return "oauth-token", refresh, nil
+	}
+	errOut := &bytes.Buffer{}
+	syncer := NewSyncer(nil, nil, output.NewHumanEmitter(errOut, errOut, false, true))
+	source := config.Source{ID: "sc", Type: config.SourceTypeSoundCloud}
+
+	if got := syncer.attachSoundCloudOAuthToken(context.Background(), source, SyncOptions{DryRun: true}); got.SoundCloudOAuthToken != "oauth-token" {
+		t.Fatalf("expected token attached on dry run, got %+v", got)
+	}
+	if got := syncer.attachSoundCloudOAuthToken(context.Background(), source, SyncOptions{}); got.SoundCloudOAuthToken != "oauth-token" {
+		t.Fatalf("expected token attached, got %+v", got)
+	}
+	if len(refreshRequests) != 2 || refreshRequests[0] || !refreshRequests[1] {
+		t.Fatalf("expected refresh only outside dry run, got %v", refreshRequests)
+	}
+	if !strings.Contains(errOut.String(), "refreshed soundcloud oauth token") {
+		t.Fatalf("expected refresh notice, got %s", errOut.String())
+	}
+
+	source.CookiesFile = "/tmp/cookies.txt"
+	if got := syncer.attachSoundCloudOAuthToken(context.Background(), source, SyncOptions{}); got.SoundCloudOAuthToken != "" || len(refreshRequests) != 2 {
+		t.Fatalf("expected cookies_file to win without resolving the stored token, got %+v", got)
+	}
+
+	soundCloudOAuthAccessTokenFn = func(ctx context.Context, refresh bool) (string, bool, error) {
+		return "", false, auth.ErrSoundCloudRefreshTokenRevoked
+	}
+	source.CookiesFile = ""
+	if got := syncer.attachSoundCloudOAuthToken(context.Background(), source, SyncOptions{}); got.SoundCloudOAuthToken != "" {
+		t.Fatalf("expected signed-out source on refresh failure, got %+v", got)
+	}
+	if !strings.Contains(errOut.String(), "continuing signed out") {
+		t.Fatalf("expected signed-out warning, got %s", errOut.String())
 	}
 }
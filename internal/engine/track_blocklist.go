@@ -0,0 +1,114 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// trackBlocklist is a compiled sync.blocklist.
+type trackBlocklist struct {
+	ids    map[string]struct{}
+	urls   []*regexp.Regexp
+	titles []*regexp.Regexp
+	// rules keeps each compiled pattern's config text for skip messages.
+	rules map[*regexp.Regexp]string
+}
+
+func compileTrackBlocklist(blocklist config.Blocklist) trackBlocklist {
+	compiled := trackBlocklist{ids: map[string]struct{}{}, rules: map[*regexp.Regexp]string{}}
+	for _, id := range blocklist.IDs {
+		compiled.ids[id] = struct{}{}
+	}
+	for _, pattern := range blocklist.URLs {
+		if re, err := regexp.Compile(blocklistURLPattern(pattern)); err == nil {
+			compiled.urls = append(compiled.urls, re)
+			compiled.rules[re] = pattern
+		}
+	}
+	// Validate rejects invalid title regexes, so a failed compile here only
+	// drops the rule.
+	for _, expr := range blocklist.Titles {
+		if re, err := regexp.Compile(expr); err == nil {
+			compiled.titles = append(compiled.titles, re)
+			compiled.rules[re] = expr
+		}
+	}
+	return compiled
+}
+
+// blocklistURLPattern turns a sync.blocklist.urls glob into an anchored,
+// case-insensitive regular expression.
+func blocklistURLPattern(pattern string) string {
+	quoted := regexp.QuoteMeta(strings.TrimSpace(pattern))
+	return "(?i)^" + strings.ReplaceAll(quoted, `\*`, ".*") + "$"
+}
+
+// blockedBy returns the rule that blocks track, or "" when none does.
+func (b trackBlocklist) blockedBy(track playlistTrack) string {
+	if _, ok := b.ids[track.ID]; ok {
+		return "id " + track.ID
+	}
+	if url := strings.TrimSpace(track.URL); url != "" {
+		for _, re := range b.urls {
+			if re.MatchString(url) {
+				return "url " + b.rules[re]
+			}
+		}
+	}
+	for _, re := range b.titles {
+		if re.MatchString(track.Label) {
+			return "title " + b.rules[re]
+		}
+	}
+	return ""
+}
+
+// skipBlockedTracks drops planned track ids that source's sync.blocklist
+// names and emits a "[skip] ... (blocklisted)" preflight line per dropped
+// track. Blocked tracks are never planned, so they are not retried either.
+func (s *Syncer) skipBlockedTracks(source config.Source, plannedIDs []string, tracks []playlistTrack) ([]string, int) {
+	if source.Sync.Blocklist.Empty() || len(plannedIDs) == 0 {
+		return plannedIDs, 0
+	}
+	blocklist := compileTrackBlocklist(source.Sync.Blocklist)
+	byID := make(map[string]playlistTrack, len(tracks))
+	for _, track := range tracks {
+		byID[track.ID] = track
+	}
+
+	kept := make([]string, 0, len(plannedIDs))
+	skipped := 0
+	for _, id := range plannedIDs {
+		track, ok := byID[id]
+		if !ok {
+			track = playlistTrack{ID: id}
+		}
+		rule := blocklist.blockedBy(track)
+		if rule == "" {
+			kept = append(kept, id)
+			continue
+		}
+		skipped++
+		label := track.Label
+		if label == "" {
+			label = id
+		}
+		_ = s.Emitter.Emit(output.Event{
+			Timestamp: s.Now(),
+			Level:     output.LevelInfo,
+			Event:     output.EventSourcePreflight,
+			SourceID:  source.ID,
+			Message:   fmt.Sprintf("[%s] [skip] %s (%s) matched %s (blocklisted)", source.ID, id, label, rule),
+			Details: map[string]any{
+				"track_id": id,
+				"reason":   "blocklisted",
+				"rule":     rule,
+			},
+		})
+	}
+	return kept, skipped
+}
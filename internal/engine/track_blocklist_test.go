@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestTrackBlocklistMatchesIDsURLsAndTitles(t *testing.T) {
+	blocklist := compileTrackBlocklist(config.Blocklist{
+		IDs:    []string{"111"},
+		URLs:   []string{"https://soundcloud.com/label/*-intro"},
+		Titles: []string{`(?i)\bskit\b`},
+	})
+	cases := map[string]playlistTrack{
+		"id 111": {ID: "111", Label: "Anything"},
+		"url https://soundcloud.com/label/*-intro": {ID: "2", URL: "https://SoundCloud.com/label/album-intro"},
+		`title (?i)\bskit\b`:                       {ID: "3", Label: "Artist - Skit (Interlude)"},
+		"":                                         {ID: "4", Label: "Artist - Sketch", URL: "https://soundcloud.com/label/album-outro"},
+	}
+	for want, track := range cases {
+		if got := blocklist.blockedBy(track); got != want {
+			t.Fatalf("track %+v: expected rule %q, got %q", track, want, got)
+		}
+	}
+}
+
+func TestSkipBlockedTracksKeepsTheReasonLastInTheSkipLine(t *testing.T) {
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(nil, nil, emitter)
+	source := config.Source{ID: "sc", Sync: config.SyncPolicy{Blocklist: config.Blocklist{Titles: []string{`(?i)(interlude)`}}}}
+	kept, skipped := syncer.skipBlockedTracks(source, []string{"1", "2"}, []playlistTrack{{ID: "1", Label: "Interlude"}, {ID: "2", Label: "Song"}})
+	if skipped != 1 || len(kept) != 1 || kept[0] != "2" {
+		t.Fatalf("expected the interlude skipped, kept %v", kept)
+	}
+	if reason := lastPreflightSkipReason(emitter.events[0].Message); reason != "blocklisted" {
+		t.Fatalf("expected a rule with parentheses to leave the reason intact, got %q from %q", reason, emitter.events[0].Message)
+	}
+}
+
+func TestPrepareSoundCloudExecutionPlanSkipsBlocklistedTracks(t *testing.T) {
+	cfg, source := newSyncLimitTestSource(t, "")
+	enumerateSoundCloudTracksFn = func(ctx context.Context, source config.Source) ([]soundCloudRemoteTrack, error) {
+		return []soundCloudRemoteTrack{
+			{ID: "111", Title: "New", URL: "https://soundcloud.com/user/new"},
+			{ID: "222", Title: "Local"},
+			{ID: "333", Title: "Gap (Intro)", URL: "https://soundcloud.com/user/gap-intro"},
+			{ID: "444", Title: "Missing", URL: "https://soundcloud.com/geo/missing"},
+			{ID: "555", Title: "Older Gap"},
+		}, nil
+	}
+	source.Sync.Blocklist = config.Blocklist{
+		IDs:    []string{"555"},
+		URLs:   []string{"https://soundcloud.com/geo/*"},
+		Titles: []string{`\(Intro\)$`},
+	}
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeAdapter{}}, noOpRunner{}, emitter)
+	plan, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{})
+	if err != nil {
+		t.Fatalf("prepare plan: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanupTempStateFiles(plan.StateSwap) })
+
+	if plan.Preflight == nil || plan.Preflight.PlannedDownloadCount != 2 || plan.Preflight.BlocklistSkipped != 3 {
+		t.Fatalf("expected three of five planned tracks blocklisted, got %+v", plan.Preflight)
+	}
+	if !reflect.DeepEqual(plan.Source.SelectedPlaylistIDs, []int{1, 2}) {
+		t.Fatalf("expected scdl held to the unblocked tracks, got %v", plan.Source.SelectedPlaylistIDs)
+	}
+	skips := 0
+	for _, event := range emitter.events {
+		if strings.Contains(event.Message, "[skip]") {
+			if reason := lastPreflightSkipReason(event.Message); reason != "blocklisted" {
+				t.Fatalf("expected the blocklisted skip reason, got %q from %q", reason, event.Message)
+			}
+			skips++
+		}
+	}
+	if skips != 3 {
+		t.Fatalf("expected a skip line per blocklisted track, got %d", skips)
+	}
+	syncer.emitSourcePreflightSummary(source, plan.Preflight, plan.DownloadOrder)
+	if last := emitter.events[len(emitter.events)-1]; last.Details["blocklist_skipped_count"] != 3 || !strings.Contains(last.Message, "blocklisted=3") {
+		t.Fatalf("expected the blocklist count in the preflight summary, got %+v", last)
+	}
+}
+
+func TestPrepareSoundCloudExecutionPlanWarnsBlocklistIgnoredWithoutPreflight(t *testing.T) {
+	cfg, source := newSyncLimitTestSource(t, "")
+	source.Sync.Blocklist = config.Blocklist{IDs: []string{"555"}}
+	emitter := &captureEventEmitter{}
+	syncer := NewSyncer(map[string]Adapter{"scdl": fakeAdapter{}}, noOpRunner{}, emitter)
+	plan, err := syncer.prepareSoundCloudExecutionPlan(context.Background(), cfg, source, SyncOptions{NoPreflight: true})
+	if err != nil {
+		t.Fatalf("prepare plan: %v", err)
+	}
+	t.Cleanup(func() { _ = cleanupTempStateFiles(plan.StateSwap) })
+
+	for _, event := range emitter.events {
+		if event.Message == "[sc-likes] sync.blocklist ignored because preflight is disabled" {
+			return
+		}
+	}
+	t.Fatalf("expected a blocklist warning, got %+v", emitter.events)
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+// runTrackFallbacks tries the source's adapter.fallback kinds in order for a
+// track deemix could not download, in trackSource's target_dir. It returns
+// the kind that downloaded the track ("" when none did) together with the
+// last spec and result, so callers can tell an interrupt from a miss.
+func (s *Syncer) runTrackFallbacks(
+	ctx context.Context,
+	source config.Source,
+	trackSource config.Source,
+	defaults config.Defaults,
+	track FallbackTrack,
+	reason string,
+	timeout time.Duration,
+) (string, ExecSpec, ExecResult) {
+	var spec ExecSpec
+	var execResult ExecResult
+	for _, kind := range trackSource.Adapter.Fallback {
+		adapter, ok := s.Registry[kind].(TrackFallbackAdapter)
+		if !ok {
+			s.emitTrackFallbackLine(source.ID, output.LevelWarn, fmt.Sprintf("%s adapter cannot download single tracks; skipping it", kind))
+			continue
+		}
+		s.emitTrackFallbackLine(source.ID, output.LevelInfo, fmt.Sprintf("%s %s; trying %s", track.ID, reason, kind))
+		built, err := adapter.BuildTrackFallbackSpec(track, trackSource, defaults, timeout)
+		if err != nil {
+			s.emitTrackFallbackLine(source.ID, output.LevelWarn, fmt.Sprintf("%s cannot build %s command: %v", track.ID, kind, err))
+			continue
+		}
+		spec = built
+		execResult = s.Runner.Run(ctx, spec)
+		if execResult.Interrupted {
+			return "", spec, execResult
+		}
+		if execResult.ExitCode == 0 {
+			s.emitTrackFallbackLine(source.ID, output.LevelInfo, fmt.Sprintf("%s downloaded via %s", track.ID, kind))
+			return kind, spec, execResult
+		}
+		reason = fmt.Sprintf("failed via %s (exit code %d)", kind, execResult.ExitCode)
+	}
+	if execResult.ExitCode != 0 {
+		s.emitTrackFallbackLine(source.ID, output.LevelWarn, fmt.Sprintf("%s %s", track.ID, reason))
+	}
+	return "", spec, execResult
+}
+
+func (s *Syncer) emitTrackFallbackLine(sourceID string, level output.Level, line string) {
+	_ = s.Emitter.Emit(output.Event{
+		Timestamp: s.Now(),
+		Level:     level,
+		Event:     output.EventSourcePreflight,
+		SourceID:  sourceID,
+		Message:   fmt.Sprintf("[%s] [fallback] %s", sourceID, strings.TrimSpace(line)),
+	})
+}
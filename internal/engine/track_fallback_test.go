@@ -0,0 +1,137 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/output"
+)
+
+type fakeFallbackAdapter struct {
+	kind string
+}
+
+func (a fakeFallbackAdapter) Kind() string                              { return a.kind }
+func (a fakeFallbackAdapter) Binary() string                            { return a.kind }
+func (a fakeFallbackAdapter) MinVersion() string                        { return "0.0.0" }
+func (a fakeFallbackAdapter) RequiredEnv(source config.Source) []string { return nil }
+func (a fakeFallbackAdapter) Validate(source config.Source) error       { return nil }
+func (a fakeFallbackAdapter) BuildExecSpec(source config.Source, defaults config.Defaults, timeout time.Duration) (ExecSpec, error) {
+	return ExecSpec{Bin: a.kind, Args: []string{source.URL}, Dir: source.TargetDir, Timeout: timeout}, nil
+}
+func (a fakeFallbackAdapter) BuildTrackFallbackSpec(track FallbackTrack, source config.Source, defaults config.Defaults, timeout time.Duration) (ExecSpec, error) {
+	return ExecSpec{Bin: a.kind, Args: []string{track.ID}, Dir: source.TargetDir, Timeout: timeout}, nil
+}
+
+func TestSyncerDeezerFallbackChainRecordsProvider(t *testing.T) {
+	tmp := t.TempDir()
+	targetDir := filepath.Join(tmp, "target")
+	stateDir := filepath.Join(tmp, "state")
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		t.Fatalf("mkdir state: %v", err)
+	}
+	cfg := config.Config{
+		Version: 1,
+		Defaults: config.Defaults{
+			StateDir:              stateDir,
+			ArchiveFile:           "archive.txt",
+			Threads:               1,
+			ContinueOnError:       true,
+			CommandTimeoutSeconds: 900,
+		},
+		Sources: []config.Source{
+			{
+				ID:        "deezer-mix",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/en/playlist/908622995",
+				StateFile: "deezer-mix.sync.deezer",
+				Adapter:   config.AdapterSpec{Kind: "deemix", Fallback: []string{"spotdl", "ytdlp"}},
+			},
+		},
+	}
+
+	origResolveARL := resolveDeemixARLFn
+	origEnumerate := enumerateDeezerTracksFn
+	t.Cleanup(func() {
+		resolveDeemixARLFn = origResolveARL
+		enumerateDeezerTracksFn = origEnumerate
+	})
+	resolveDeemixARLFn = func() (string, error) { return "arl", nil }
+	enumerateDeezerTracksFn = func(ctx context.Context, source config.Source) ([]deezerRemoteTrack, error) {
+		return []deezerRemoteTrack{
+			{ID: "3135556", Title: "Harder, Better, Faster, Stronger", Artist: "Daft Punk", Readable: true},
+			{ID: "3135558", Title: "One More Time", Artist: "Daft Punk", Readable: false},
+			{ID: "3135560", Title: "Aerodynamic", Artist: "Daft Punk", Readable: false},
+		}, nil
+	}
+
+	runner := &sequenceRunner{results: []ExecResult{
+		{ExitCode: 0, StdoutTail: "Track unavailable on Deezer"},
+		{ExitCode: 1},
+		{ExitCode: 0},
+		{ExitCode: 0},
+		{ExitCode: 1},
+		{ExitCode: 1},
+	}}
+	var out bytes.Buffer
+	syncer := NewSyncer(
+		map[string]Adapter{
+			"deemix": fakeDeemixAdapter{},
+			"spotdl": fakeFallbackAdapter{kind: "spotdl"},
+			"ytdlp":  fakeFallbackAdapter{kind: "ytdlp"},
+		},
+		runner,
+		output.NewHumanEmitter(&out, &out, false, true),
+	)
+
+	result, err := syncer.Sync(context.Background(), cfg, SyncOptions{})
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Succeeded != 1 || result.Failed != 0 {
+		t.Fatalf("expected successful deezer source run, got %+v\n%s", result, out.String())
+	}
+	runs := []string{}
+	for _, spec := range runner.specs {
+		runs = append(runs, spec.Bin+":"+spec.Args[len(spec.Args)-1])
+	}
+	want := "deemix:https://www.deezer.com/track/3135556,spotdl:3135556,ytdlp:3135556,spotdl:3135558,spotdl:3135560,ytdlp:3135560"
+	if got := strings.Join(runs, ","); got != want {
+		t.Fatalf("unexpected run order\n got %s\nwant %s", got, want)
+	}
+	for _, line := range []string{
+		"[fallback] 3135556 unavailable-on-deezer; trying spotdl",
+		"[fallback] 3135556 downloaded via ytdlp",
+		"[fallback] 3135560 failed via ytdlp (exit code 1)",
+		"[skip] 3135560 (Daft Punk - Aerodynamic) (unavailable-on-deezer)",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("expected %q in output, got:\n%s", line, out.String())
+		}
+	}
+
+	state, err := parseDeezerSyncState(filepath.Join(stateDir, "deezer-mix.sync.deezer"))
+	if err != nil {
+		t.Fatalf("parse deezer state: %v", err)
+	}
+	if _, ok := state.KnownIDs["3135560"]; ok || len(state.KnownIDs) != 2 {
+		t.Fatalf("expected only fallback downloads in deezer state, got %+v", state.KnownIDs)
+	}
+	if got := state.Entries["3135556"].Provider; got != "ytdlp" {
+		t.Fatalf("expected ytdlp provenance, got %q", got)
+	}
+	if got := state.Entries["3135558"].Provider; got != "spotdl" {
+		t.Fatalf("expected spotdl provenance, got %q", got)
+	}
+}
@@ -12,6 +12,7 @@ type ExecSpec struct {
 	Dir             string
 	Timeout         time.Duration
 	DisplayCommand  string
+	Env             []string
 	StdoutObservers []func(line string)
 	StderrObservers []func(line string)
 }
@@ -24,6 +25,9 @@ type ExecResult struct {
 	StdoutTail  string
 	StderrTail  string
 	Err         error
+	// Stalled is set when the stall watchdog cancelled the run because its
+	// download stopped making progress (sync.stall_min_kbps).
+	Stalled bool
 }
 
 type Adapter interface {
@@ -35,6 +39,23 @@ type Adapter interface {
 	RequiredEnv(source config.Source) []string
 }
 
+// TrackFallbackAdapter is implemented by adapters that can fetch a single
+// track on their own, so they can be listed in adapter.fallback for tracks
+// the source's deemix adapter reports unavailable.
+type TrackFallbackAdapter interface {
+	Adapter
+	BuildTrackFallbackSpec(track FallbackTrack, source config.Source, defaults config.Defaults, timeout time.Duration) (ExecSpec, error)
+}
+
+// FallbackTrack identifies the track a fallback adapter should fetch. URL is
+// the Spotify track link for spotify sources and empty otherwise.
+type FallbackTrack struct {
+	ID     string
+	URL    string
+	Artist string
+	Title  string
+}
+
 type SyncOptions struct {
 	SourceIDs           []string
 	DryRun              bool
@@ -50,7 +71,35 @@ type SyncOptions struct {
 	PromptOnExisting    func(sourceID string, preflight SoundCloudPreflight) (bool, error)
 	PromptOnSpotifyAuth func(sourceID string) (bool, error)
 	PromptOnDeemixARL   func(sourceID string) (string, error)
+	PromptOnPrune       func(sourceID string, candidates []PruneCandidate) (bool, error)
 	TrackStatus         TrackStatusMode
+	// Ordered commits state appends and per-track done events in remote
+	// playlist order even when tracks finish out of order.
+	Ordered bool
+	// Resume limits the run to the sources and planned tracks an interrupted
+	// sync left unfinished.
+	Resume *ResumeCheckpoint
+	// Force proceeds past remote track count drift alarms.
+	Force bool
+	// NoHTTPCache bypasses the on-disk HTTP cache for remote enumeration and
+	// the per-source SoundCloud metadata cache.
+	NoHTTPCache bool
+	// LogFile is the --log-file path, recorded with source failures.
+	LogFile string
+	// ApplyPrune moves sync.prune candidates to trash without asking.
+	ApplyPrune bool
+	// NoBrowser defers every free-DL browser gate instead of opening a
+	// browser (--headless).
+	NoBrowser bool
+	// WaitForLock queues behind another udl process syncing the same
+	// source instead of failing the source (--wait-lock).
+	WaitForLock bool
+	// OnlyGaps plans only gaps, skipping new tracks above the first one
+	// found locally (--only-gaps).
+	OnlyGaps bool
+	// Limit caps the tracks downloaded per source this run; the rest stay
+	// planned for the next one (--limit). Zero means no cap.
+	Limit int
 }
 
 type PlanSelectionResult struct {
@@ -70,6 +119,9 @@ type SyncResult struct {
 	Skipped            int
 	DependencyFailures int
 	Interrupted        bool
+	// DeferredGates counts free-DL browser gates left for a later run by
+	// freedl.interactive_budget_seconds or NoBrowser.
+	DeferredGates int
 }
 
 type SoundCloudMode string
@@ -77,6 +129,9 @@ type SoundCloudMode string
 const (
 	SoundCloudModeBreak    SoundCloudMode = "break"
 	SoundCloudModeScanGaps SoundCloudMode = "scan_gaps"
+	// SoundCloudModeOnlyGaps plans known gaps and the tracks missing below
+	// the first local track, never new tracks at the head of the listing.
+	SoundCloudModeOnlyGaps SoundCloudMode = "only_gaps"
 )
 
 type SoundCloudPreflight struct {
@@ -87,6 +142,24 @@ type SoundCloudPreflight struct {
 	FirstExistingIndex   int
 	PlannedDownloadCount int
 	Mode                 SoundCloudMode
+	// FreeDownloadSkipped counts planned tracks dropped because they expose a
+	// free-download link and the source sets sync.free_downloads=skip.
+	FreeDownloadSkipped int
+	// DuplicateSkipped counts planned tracks dropped because another source
+	// sharing the target_dir already downloaded them.
+	DuplicateSkipped int
+	// BlocklistSkipped counts planned tracks dropped by sync.blocklist.
+	BlocklistSkipped int
+	// RememberedSkipped counts planned tracks dropped because an earlier run
+	// skipped them as permanently unavailable within sync.skip_ttl_days.
+	RememberedSkipped int
+	// LimitDeferred counts planned tracks left for a later run by --limit.
+	LimitDeferred int
+	// PlannedDurationMS sums the remote lengths of the PlannedDurationKnown
+	// planned tracks whose listing carried one; dry-run estimates scale it to
+	// the final planned count.
+	PlannedDurationMS    int64
+	PlannedDurationKnown int
 }
 
 type TrackStatusMode string
@@ -0,0 +1,334 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+// Upgrade scan remote states: the track is still downloadable, no longer
+// is, or was not checked (non-Deezer ids, --no-remote, or a failed lookup).
+const (
+	UpgradeRemoteAvailable   = "available"
+	UpgradeRemoteUnavailable = "unavailable"
+	UpgradeRemoteUnchecked   = "unchecked"
+)
+
+// UpgradeScanOptions controls ScanUpgrades.
+type UpgradeScanOptions struct {
+	ProbeTimeout time.Duration
+	// NoRemote skips the api.deezer.com availability lookups.
+	NoRemote bool
+}
+
+// UpgradeCandidate is a tracked file below the best quality its source is
+// configured to download. Codec and BitrateKbps come from ffprobe;
+// StateQuality is the deemix tier recorded when the file was downloaded.
+type UpgradeCandidate struct {
+	TrackID       string `json:"track_id"`
+	Label         string `json:"label,omitempty"`
+	LocalPath     string `json:"local_path"`
+	Codec         string `json:"codec,omitempty"`
+	BitrateKbps   int    `json:"bitrate_kbps,omitempty"`
+	StateQuality  string `json:"state_quality,omitempty"`
+	LocalQuality  string `json:"local_quality"`
+	TargetQuality string `json:"target_quality"`
+	Remote        string `json:"remote"`
+}
+
+// SourceUpgradeReport is the upgrade plan of one source. Skipped explains
+// why a source was not scanned at all.
+type SourceUpgradeReport struct {
+	SourceID      string             `json:"source_id"`
+	TargetQuality string             `json:"target_quality,omitempty"`
+	Scanned       int                `json:"scanned"`
+	UpToDate      int                `json:"up_to_date"`
+	Candidates    []UpgradeCandidate `json:"candidates"`
+	Skipped       string             `json:"skipped,omitempty"`
+	Errors        []string           `json:"errors,omitempty"`
+}
+
+// Planned lists the candidates a re-download can improve: every candidate
+// except those the remote no longer offers.
+func (r SourceUpgradeReport) Planned() []UpgradeCandidate {
+	planned := []UpgradeCandidate{}
+	for _, candidate := range r.Candidates {
+		if candidate.Remote != UpgradeRemoteUnavailable {
+			planned = append(planned, candidate)
+		}
+	}
+	return planned
+}
+
+type upgradeAudioProbe struct {
+	Codec       string
+	BitrateKbps int
+}
+
+var (
+	probeUpgradeAudioFn = probeUpgradeAudio
+	lookupDeezerTrackFn = lookupDeezerTrack
+)
+
+// upgradeQualityRank orders deemix quality tiers; 0 is unknown.
+func upgradeQualityRank(quality string) int {
+	switch quality {
+	case config.DeemixQualityFLAC:
+		return 3
+	case config.DeemixQuality320:
+		return 2
+	case config.DeemixQuality128:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// upgradeLocalQuality maps a probed file onto the deemix tier it
+// corresponds to: lossless codecs are flac, lossy files of 256 kbps or more
+// are 320, and anything lower is 128.
+func upgradeLocalQuality(probe upgradeAudioProbe) string {
+	switch codec := strings.ToLower(probe.Codec); {
+	case codec == "flac", codec == "alac", codec == "wavpack", codec == "ape", strings.HasPrefix(codec, "pcm_"):
+		return config.DeemixQualityFLAC
+	case probe.BitrateKbps >= 256:
+		return config.DeemixQuality320
+	case probe.BitrateKbps > 0:
+		return config.DeemixQuality128
+	default:
+		return ""
+	}
+}
+
+// ScanUpgrades probes the tracked files of the selected sources (all
+// sources when sourceIDs is empty) and lists those below the first tier of
+// their source's quality list. Only deemix sources with a quality list can
+// request a better tier; other sources are reported as skipped. For Deezer
+// sources each candidate is looked up on api.deezer.com, which tells whether
+// the track is still downloadable but not which tiers it has, so deemix falls
+// back through the quality list on the re-download as it does on a sync.
+func ScanUpgrades(ctx context.Context, cfg config.Config, sourceIDs []string, opts UpgradeScanOptions) ([]SourceUpgradeReport, error) {
+	sources, err := selectSources(cfg.Sources, sourceIDs)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]SourceUpgradeReport, 0, len(sources))
+	for _, source := range sources {
+		if err := ctx.Err(); err != nil {
+			return reports, err
+		}
+		reports = append(reports, scanSourceUpgrades(ctx, cfg.Defaults, source, opts))
+	}
+	return reports, nil
+}
+
+func scanSourceUpgrades(ctx context.Context, defaults config.Defaults, source config.Source, opts UpgradeScanOptions) SourceUpgradeReport {
+	report := SourceUpgradeReport{SourceID: source.ID, Candidates: []UpgradeCandidate{}}
+	if source.Adapter.Kind != "deemix" {
+		report.Skipped = fmt.Sprintf("adapter %s has no quality tiers to upgrade to", source.Adapter.Kind)
+		return report
+	}
+	if len(source.Quality) == 0 {
+		report.Skipped = "no quality list; set quality (for example [flac, 320]) to scan"
+		return report
+	}
+	report.TargetQuality = source.Quality[0]
+	targetRank := upgradeQualityRank(report.TargetQuality)
+
+	statePath, err := stateFileForVerify(defaults, source)
+	if err != nil || statePath == "" {
+		report.Skipped = "no state file"
+		return report
+	}
+	state, err := parseTrackStateForSource(source.Type, statePath)
+	if err != nil {
+		report.Errors = append(report.Errors, "state_file: "+err.Error())
+		return report
+	}
+	tracked, _ := collectVerifyTrackedFiles(defaults, source)
+	for _, file := range tracked {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+		if !stateEntryHasLocalFile(file.path, "") {
+			continue
+		}
+		report.Scanned++
+		entry := state.Entries[file.id]
+		candidate := UpgradeCandidate{
+			TrackID:       file.id,
+			Label:         strings.TrimSpace(entry.DisplayName),
+			LocalPath:     file.path,
+			StateQuality:  strings.TrimSpace(entry.Quality),
+			TargetQuality: report.TargetQuality,
+			Remote:        UpgradeRemoteUnchecked,
+		}
+		if candidate.Label == "" {
+			candidate.Label = strings.TrimSuffix(filepath.Base(file.path), filepath.Ext(file.path))
+		}
+		probeCtx, cancel := ctx, context.CancelFunc(func() {})
+		if opts.ProbeTimeout > 0 {
+			probeCtx, cancel = context.WithTimeout(ctx, opts.ProbeTimeout)
+		}
+		probe, probeErr := probeUpgradeAudioFn(probeCtx, file.path)
+		cancel()
+		if probeErr == nil {
+			candidate.Codec = probe.Codec
+			candidate.BitrateKbps = probe.BitrateKbps
+			candidate.LocalQuality = upgradeLocalQuality(probe)
+		}
+		if candidate.LocalQuality == "" {
+			// Unreadable files fall back to the tier recorded in state.
+			candidate.LocalQuality = candidate.StateQuality
+		}
+		if candidate.LocalQuality == "" {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: quality unknown (ffprobe: %v)", file.path, probeErr))
+			continue
+		}
+		if upgradeQualityRank(candidate.LocalQuality) >= targetRank {
+			report.UpToDate++
+			continue
+		}
+		if source.Type == config.SourceTypeDeezer && !opts.NoRemote {
+			remote, lookupErr := lookupDeezerTrackFn(ctx, file.id)
+			switch {
+			case lookupErr != nil:
+				report.Errors = append(report.Errors, fmt.Sprintf("deezer track %s: %v", file.id, lookupErr))
+			case remote.Readable:
+				candidate.Remote = UpgradeRemoteAvailable
+			default:
+				candidate.Remote = UpgradeRemoteUnavailable
+			}
+		}
+		report.Candidates = append(report.Candidates, candidate)
+	}
+	sort.SliceStable(report.Candidates, func(i, j int) bool {
+		return report.Candidates[i].Label < report.Candidates[j].Label
+	})
+	return report
+}
+
+// ApplyUpgradePlan queues the planned candidates for a re-download: their
+// files move to <state_dir>/trash/<source>/<stamp>-upgrade/ and their state
+// entries are dropped, so the next sync downloads them at the best tier the
+// remote offers. It holds the lock of every source it touches and returns
+// how many files moved.
+func ApplyUpgradePlan(ctx context.Context, cfg config.Config, reports []SourceUpgradeReport, stamp string) (int, error) {
+	byID := map[string]config.Source{}
+	for _, source := range cfg.Sources {
+		byID[source.ID] = source
+	}
+	locked := []string{}
+	for _, report := range reports {
+		if _, ok := byID[report.SourceID]; ok && len(report.Planned()) > 0 {
+			locked = append(locked, report.SourceID)
+		}
+	}
+	unlock, err := lockSources(ctx, cfg.Defaults.StateDir, locked)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	moved := 0
+	for _, report := range reports {
+		planned := report.Planned()
+		source, ok := byID[report.SourceID]
+		if !ok || len(planned) == 0 {
+			continue
+		}
+		statePath, err := config.ResolveStateFile(cfg.Defaults.StateDir, source.StateFile)
+		if err != nil {
+			return moved, fmt.Errorf("[%s] resolve state_file: %w", source.ID, err)
+		}
+		trashDir, err := pruneTrashDir(cfg, source, stamp+"-upgrade")
+		if err != nil {
+			return moved, fmt.Errorf("[%s] %w", source.ID, err)
+		}
+		targetDir, _ := config.ExpandTargetDir(source.TargetDir)
+		removed := map[string]struct{}{}
+		for _, candidate := range planned {
+			destination := filepath.Join(trashDir, pruneTrashRelPath(targetDir, candidate.LocalPath))
+			if err := moveFile(candidate.LocalPath, destination); err != nil {
+				return moved, fmt.Errorf("[%s] move %s: %w", source.ID, candidate.LocalPath, err)
+			}
+			moved++
+			removed[candidate.TrackID] = struct{}{}
+		}
+		if err := removeStateEntries(statePath, source.Type, removed); err != nil {
+			return moved, fmt.Errorf("[%s] update state file: %w", source.ID, err)
+		}
+	}
+	return moved, nil
+}
+
+// lookupDeezerTrack fetches one track from the public api.deezer.com, which
+// needs no ARL.
+func lookupDeezerTrack(ctx context.Context, trackID string) (deezerRemoteTrack, error) {
+	var payload deezerAPITrackResponse
+	if err := getDeezerJSON(ctx, strings.TrimSuffix(deezerAPIBaseURL, "/")+"/track/"+trackID, &payload); err != nil {
+		return deezerRemoteTrack{}, err
+	}
+	if payload.Error != nil {
+		if payload.Error.Code == 800 {
+			// "no data": the track was removed from Deezer.
+			return deezerRemoteTrack{ID: trackID}, nil
+		}
+		return deezerRemoteTrack{}, fmt.Errorf("%s", payload.Error.describe())
+	}
+	return payload.deezerAPITrack.remote(), nil
+}
+
+// probeUpgradeAudio reads the first audio stream's codec and bitrate,
+// falling back to the container bitrate.
+func probeUpgradeAudio(ctx context.Context, path string) (upgradeAudioProbe, error) {
+	cmd := exec.CommandContext(
+		ctx,
+		"ffprobe",
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,bit_rate:format=bit_rate",
+		"-of", "json",
+		path,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return upgradeAudioProbe{}, fmt.Errorf("ffprobe timed out")
+		}
+		if trimmed := strings.TrimSpace(string(output)); trimmed != "" {
+			return upgradeAudioProbe{}, fmt.Errorf("%v: %s", err, trimmed)
+		}
+		return upgradeAudioProbe{}, err
+	}
+	var payload struct {
+		Streams []struct {
+			CodecName string `json:"codec_name"`
+			BitRate   string `json:"bit_rate"`
+		} `json:"streams"`
+		Format struct {
+			BitRate string `json:"bit_rate"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return upgradeAudioProbe{}, err
+	}
+	if len(payload.Streams) == 0 {
+		return upgradeAudioProbe{}, fmt.Errorf("no audio stream")
+	}
+	probe := upgradeAudioProbe{Codec: strings.TrimSpace(payload.Streams[0].CodecName)}
+	for _, raw := range []string{payload.Streams[0].BitRate, payload.Format.BitRate} {
+		if bitrate, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && bitrate > 0 {
+			probe.BitrateKbps = bitrate / 1000
+			break
+		}
+	}
+	return probe, nil
+}
@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jaa/update-downloads/internal/config"
+)
+
+func TestScanUpgradesPlansTracksBelowTheBestTierAndApplyQueuesThem(t *testing.T) {
+	tmp := t.TempDir()
+	stateDir := filepath.Join(tmp, "state")
+	targetDir := filepath.Join(tmp, "music")
+	for _, dir := range []string{stateDir, filepath.Join(targetDir, "Artist")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", dir, err)
+		}
+	}
+	for _, name := range []string{"Artist/Lossless.flac", "Artist/Low.mp3", "Artist/Gone.mp3"} {
+		if err := os.WriteFile(filepath.Join(targetDir, filepath.FromSlash(name)), []byte("audio"), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	statePath := filepath.Join(stateDir, "dz.sync.deezer")
+	for _, entry := range []struct{ id, label, path, quality string }{
+		{"111", "Artist - Lossless", "Artist/Lossless.flac", "flac"},
+		{"222", "Artist - Low", "Artist/Low.mp3", "320"},
+		{"333", "Artist - Gone", "Artist/Gone.mp3", "128"},
+		{"444", "Artist - Missing", "Artist/Missing.mp3", "128"},
+	} {
+		if err := appendDeezerSyncStateEntry(statePath, entry.id, spotifyStateEntry{DisplayName: entry.label, LocalPath: entry.path, Quality: entry.quality, Provider: "deemix"}); err != nil {
+			t.Fatalf("append state: %v", err)
+		}
+	}
+
+	origProbe := probeUpgradeAudioFn
+	origLookup := lookupDeezerTrackFn
+	t.Cleanup(func() {
+		probeUpgradeAudioFn = origProbe
+		lookupDeezerTrackFn = origLookup
+	})
+	probeUpgradeAudioFn = func(ctx context.Context, path string) (upgradeAudioProbe, error) {
+		switch filepath.Base(path) {
+		case "Lossless.flac":
+			return upgradeAudioProbe{Codec: "flac", BitrateKbps: 900}, nil
+		case "Low.mp3":
+			// A 320 state entry whose file is really 128 kbps.
+			return upgradeAudioProbe{Codec: "mp3", BitrateKbps: 128}, nil
+		default:
+			return upgradeAudioProbe{Codec: "mp3", BitrateKbps: 320}, nil
+		}
+	}
+	lookups := []string{}
+	lookupDeezerTrackFn = func(ctx context.Context, trackID string) (deezerRemoteTrack, error) {
+		lookups = append(lookups, trackID)
+		return deezerRemoteTrack{ID: trackID, Readable: trackID != "333"}, nil
+	}
+
+	cfg := config.Config{
+		Defaults: config.Defaults{StateDir: stateDir, ArchiveFile: "archive.txt"},
+		Sources: []config.Source{
+			{
+				ID:        "dz",
+				Type:      config.SourceTypeDeezer,
+				Enabled:   true,
+				TargetDir: targetDir,
+				URL:       "https://www.deezer.com/playlist/1",
+				StateFile: "dz.sync.deezer",
+				Quality:   []string{"flac", "320"},
+				Adapter:   config.AdapterSpec{Kind: "deemix"},
+			},
+			{
+				ID:        "sc",
+				Type:      config.SourceTypeSoundCloud,
+				Enabled:   true,
+				TargetDir: targetDir,
+				StateFile: "sc.sync.scdl",
+				Adapter:   config.AdapterSpec{Kind: "scdl"},
+			},
+		},
+	}
+	reports, err := ScanUpgrades(context.Background(), cfg, nil, UpgradeScanOptions{})
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(reports) != 2 || reports[1].Skipped == "" {
+		t.Fatalf("expected the scdl source to be skipped, got %+v", reports)
+	}
+	report := reports[0]
+	if report.TargetQuality != "flac" || report.Scanned != 3 || report.UpToDate != 1 || len(report.Candidates) != 2 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if len(lookups) != 2 {
+		t.Fatalf("expected remote lookups only for candidates, got %v", lookups)
+	}
+	planned := report.Planned()
+	if len(planned) != 1 || planned[0].TrackID != "222" || planned[0].LocalQuality != "128" || planned[0].Remote != UpgradeRemoteAvailable {
+		t.Fatalf("expected only the readable low-bitrate track planned, got %+v", planned)
+	}
+
+	moved, err := ApplyUpgradePlan(context.Background(), cfg, reports, "20260301-120000")
+	if err != nil || moved != 1 {
+		t.Fatalf("apply: moved=%d err=%v", moved, err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "trash", "dz", "20260301-120000-upgrade", "Artist", "Low.mp3")); err != nil {
+		t.Fatalf("expected the planned file in trash: %v", err)
+	}
+	state, err := parseDeezerSyncState(statePath)
+	if err != nil {
+		t.Fatalf("parse state: %v", err)
+	}
+	if _, ok := state.Entries["222"]; ok {
+		t.Fatalf("expected the planned track's state entry to be dropped")
+	}
+	if _, ok := state.Entries["333"]; !ok {
+		t.Fatalf("expected the unavailable track to stay in state")
+	}
+}
@@ -0,0 +1,239 @@
+// Package httpcache is an on-disk HTTP cache for remote enumeration. It keeps
+// GET responses that carry an ETag or Last-Modified validator and revalidates
+// them with If-None-Match / If-Modified-Since, so unchanged playlist pages and
+// API responses are not downloaded again on every run.
+package httpcache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MaxBodyBytes bounds the responses the cache stores; larger bodies pass
+// through uncached.
+const MaxBodyBytes = 8 << 20
+
+// StatusHeader is set on responses served from the cache after a 304.
+const StatusHeader = "X-Udl-Cache"
+
+// credentialQueryParams are dropped from cache keys so rotating a client_id
+// or token does not invalidate entries. Request URLs are never written to
+// disk; entries are named by a hash of the key.
+var credentialQueryParams = []string{"client_id", "access_token", "oauth_token"}
+
+// storedHeaders are the response headers kept with an entry.
+var storedHeaders = []string{"Content-Type", "ETag", "Last-Modified"}
+
+type entry struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+	StoredAt   time.Time   `json:"stored_at"`
+}
+
+// Transport is an http.RoundTripper backed by entries in Dir.
+type Transport struct {
+	Dir  string
+	Base http.RoundTripper
+	Now  func() time.Time
+}
+
+// New returns a Transport storing entries in dir over http.DefaultTransport.
+func New(dir string) *Transport {
+	return &Transport{Dir: dir}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return base.RoundTrip(req)
+	}
+	path := t.entryPath(req.URL)
+	cached, hasEntry := readEntry(path)
+
+	out := req
+	if hasEntry {
+		out = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			out.Header.Set("If-None-Match", etag)
+		}
+		if modified := cached.Header.Get("Last-Modified"); modified != "" {
+			out.Header.Set("If-Modified-Since", modified)
+		}
+	}
+	resp, err := base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasEntry && resp.StatusCode == http.StatusNotModified {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		return cached.response(req), nil
+	}
+	if resp.StatusCode != http.StatusOK || !storable(resp) {
+		return resp, nil
+	}
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, MaxBodyBytes+1))
+	if readErr != nil {
+		_ = resp.Body.Close()
+		return nil, readErr
+	}
+	if len(body) > MaxBodyBytes {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored := entry{StatusCode: resp.StatusCode, Header: http.Header{}, Body: body, StoredAt: t.now().UTC()}
+	for _, name := range storedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			stored.Header.Set(name, value)
+		}
+	}
+	_ = writeEntry(t.Dir, path, stored)
+	return resp, nil
+}
+
+func (t *Transport) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+	return time.Now()
+}
+
+func (t *Transport) entryPath(u *url.URL) string {
+	sum := sha256.Sum256([]byte(Key(u)))
+	return filepath.Join(t.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Key is the cache key for u: the URL without its fragment and without
+// credential query parameters.
+func Key(u *url.URL) string {
+	keyed := *u
+	keyed.Fragment = ""
+	query := keyed.Query()
+	for _, name := range credentialQueryParams {
+		query.Del(name)
+	}
+	keyed.RawQuery = query.Encode()
+	return keyed.String()
+}
+
+func storable(resp *http.Response) bool {
+	if resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return false
+	}
+	cacheControl := strings.ToLower(resp.Header.Get("Cache-Control"))
+	return !strings.Contains(cacheControl, "no-store")
+}
+
+func (e entry) response(req *http.Request) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(StatusHeader, "revalidated")
+	return &http.Response{
+		Status:        http.StatusText(e.StatusCode),
+		StatusCode:    e.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.Body)),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}
+}
+
+func readEntry(path string) (entry, bool) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return entry{}, false
+	}
+	var cached entry
+	if err := json.Unmarshal(raw, &cached); err != nil || cached.StatusCode != http.StatusOK {
+		return entry{}, false
+	}
+	return cached, true
+}
+
+func writeEntry(dir string, path string, stored entry) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(payload); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying t, so enumeration code several calls deep
+// can route its requests through the cache.
+func NewContext(ctx context.Context, t *Transport) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the Transport carried by ctx, if any.
+func FromContext(ctx context.Context) *Transport {
+	t, _ := ctx.Value(contextKey{}).(*Transport)
+	return t
+}
+
+// Client returns client with its transport wrapped by the cache in ctx, or
+// client unchanged when ctx carries none.
+func Client(ctx context.Context, client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	cache := FromContext(ctx)
+	if cache == nil {
+		return client
+	}
+	if _, wrapped := client.Transport.(*Transport); wrapped {
+		return client
+	}
+	wrapped := *client
+	wrapped.Transport = &Transport{Dir: cache.Dir, Base: client.Transport, Now: cache.Now}
+	return &wrapped
+}
@@ -0,0 +1,112 @@
+package httpcache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTransportRevalidatesWithETag(t *testing.T) {
+	fullResponses := 0
+	notModified := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"tracks":[1,2,3]}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	ctx := NewContext(context.Background(), New(dir))
+	client := Client(ctx, server.Client())
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL + "/playlist?client_id=secret-id&page=1")
+		if err != nil {
+			t.Fatalf("get %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != `{"tracks":[1,2,3]}` {
+			t.Fatalf("get %d: unexpected response %d %q", i, resp.StatusCode, body)
+		}
+		if wantCached := i > 0; (resp.Header.Get(StatusHeader) == "revalidated") != wantCached {
+			t.Fatalf("get %d: unexpected cache header %q", i, resp.Header.Get(StatusHeader))
+		}
+	}
+	if fullResponses != 1 || notModified != 2 {
+		t.Fatalf("expected one full response and two revalidations, got full=%d not_modified=%d", fullResponses, notModified)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one cache entry, got %v (%v)", entries, err)
+	}
+	raw, err := os.ReadFile(dir + "/" + entries[0].Name())
+	if err != nil {
+		t.Fatalf("read entry: %v", err)
+	}
+	if strings.Contains(string(raw), "secret-id") {
+		t.Fatalf("cache entry must not contain request credentials: %s", raw)
+	}
+}
+
+func TestTransportSkipsResponsesWithoutValidators(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+			t.Errorf("unexpected conditional request")
+		}
+		if r.URL.Path == "/nostore" {
+			w.Header().Set("ETag", `"x"`)
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		_, _ = io.WriteString(w, "page")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	client := Client(NewContext(context.Background(), New(dir)), server.Client())
+	for _, path := range []string{"/plain", "/plain", "/nostore", "/nostore"} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		_ = resp.Body.Close()
+	}
+	if hits != 4 {
+		t.Fatalf("expected every request to reach the server, got %d", hits)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("expected no cache entries, got %d", len(entries))
+	}
+}
+
+func TestKeyDropsCredentialsAndFragment(t *testing.T) {
+	a, _ := url.Parse("https://api-v2.soundcloud.com/users/1/likes?limit=200&client_id=aaa#frag")
+	b, _ := url.Parse("https://api-v2.soundcloud.com/users/1/likes?client_id=bbb&limit=200")
+	if Key(a) != Key(b) {
+		t.Fatalf("expected keys to ignore client_id and fragment: %q vs %q", Key(a), Key(b))
+	}
+	if strings.Contains(Key(a), "client_id") {
+		t.Fatalf("expected client_id removed from key, got %q", Key(a))
+	}
+}
+
+func TestClientWithoutCacheIsUnchanged(t *testing.T) {
+	client := &http.Client{}
+	if Client(context.Background(), client) != client {
+		t.Fatalf("expected client unchanged without a cache in context")
+	}
+}
@@ -11,6 +11,8 @@ var fragmentProgressPattern = regexp.MustCompile(`^\[download\]\s+([0-9]+(?:\.[0
 var downloadItemPattern = regexp.MustCompile(`^\[download\] Downloading item ([0-9]+) of ([0-9]+)$`)
 var downloadDestinationPattern = regexp.MustCompile(`^\[download\] Destination: (.+)$`)
 var alreadyDownloadedPattern = regexp.MustCompile(`^\[download\] (.+) has already been downloaded$`)
+var alreadyInArchivePattern = regexp.MustCompile(`^\[download\] (.+) has already been recorded in the archive$`)
+var downloadProgressPattern = regexp.MustCompile(`^\[download\]\s+([0-9]+(?:\.[0-9]+)?)% of\s+~?\s*\S+ at\s+.*$`)
 var spotDLFoundSongsPattern = regexp.MustCompile(`^Found ([0-9]+) songs in .+$`)
 var spotDLDownloadedPattern = regexp.MustCompile(`^Downloaded "(.+)":\s+https?://.+$`)
 var spotDLLookupErrorPattern = regexp.MustCompile(`^LookupError: No results found for song: (.+)$`)
@@ -22,6 +24,8 @@ const (
 	LineEventDownloadItem          LineEventKind = "download_item"
 	LineEventDownloadDestination   LineEventKind = "download_destination"
 	LineEventAlreadyDownloaded     LineEventKind = "already_downloaded"
+	LineEventAlreadyInArchive      LineEventKind = "already_in_archive"
+	LineEventDownloadProgress      LineEventKind = "download_progress"
 	LineEventFragmentProgress      LineEventKind = "fragment_progress"
 	LineEventNoisyDownloadProgress LineEventKind = "noisy_progress"
 	LineEventSpotDLFoundSongs      LineEventKind = "spotdl_found_songs"
@@ -50,6 +54,9 @@ func ParseLine(line string) (LineEvent, bool) {
 	if match := alreadyDownloadedPattern.FindStringSubmatch(line); len(match) == 2 {
 		return LineEvent{Kind: LineEventAlreadyDownloaded, Text: strings.TrimSpace(match[1])}, true
 	}
+	if match := alreadyInArchivePattern.FindStringSubmatch(line); len(match) == 2 {
+		return LineEvent{Kind: LineEventAlreadyInArchive, Text: strings.TrimSpace(match[1])}, true
+	}
 	if match := fragmentProgressPattern.FindStringSubmatch(line); len(match) == 2 {
 		percent, _ := strconv.ParseFloat(match[1], 64)
 		return LineEvent{Kind: LineEventFragmentProgress, Percent: percent}, true
@@ -57,6 +64,10 @@ func ParseLine(line string) (LineEvent, bool) {
 	if noisyDownloadProgressPattern.MatchString(line) {
 		return LineEvent{Kind: LineEventNoisyDownloadProgress}, true
 	}
+	if match := downloadProgressPattern.FindStringSubmatch(line); len(match) == 2 {
+		percent, _ := strconv.ParseFloat(match[1], 64)
+		return LineEvent{Kind: LineEventDownloadProgress, Percent: percent}, true
+	}
 	if match := spotDLFoundSongsPattern.FindStringSubmatch(line); len(match) == 2 {
 		total, _ := strconv.Atoi(match[1])
 		return LineEvent{Kind: LineEventSpotDLFoundSongs, Total: total}, true
@@ -2,9 +2,14 @@ package compact
 
 import "sync"
 
+// StateMachine tracks progress for the active source. Counters of sources
+// that were active earlier are kept by source id, so late events from a
+// previous source (continue-on-error runs, background tagging) update that
+// source instead of the one currently running.
 type StateMachine struct {
-	mu    sync.Mutex
-	state ProgressModel
+	mu      sync.Mutex
+	state   ProgressModel
+	sources map[string]SourceProgress
 }
 
 func NewStateMachine() *StateMachine {
@@ -20,11 +25,43 @@ func (m *StateMachine) Reset() {
 		Source: SourceProgress{Lifecycle: SourceLifecycleIdle},
 		Track:  TrackProgress{Lifecycle: TrackLifecycleIdle},
 	}
+	m.sources = map[string]SourceProgress{}
+}
+
+// Select makes sourceID the active source, restoring its saved counters (or
+// starting idle) and saving the previous source's. An empty id keeps the
+// current source.
+func (m *StateMachine) Select(sourceID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.selectLocked(sourceID)
+}
+
+func (m *StateMachine) selectLocked(sourceID string) {
+	if sourceID == "" || sourceID == m.state.Source.ID {
+		return
+	}
+	if m.sources == nil {
+		m.sources = map[string]SourceProgress{}
+	}
+	if m.state.Source.ID != "" {
+		m.sources[m.state.Source.ID] = m.state.Source
+	}
+	next, ok := m.sources[sourceID]
+	if !ok {
+		next = SourceProgress{ID: sourceID, Lifecycle: SourceLifecycleIdle}
+	}
+	m.state.Source = next
+	m.state.Global = GlobalProgress{
+		Total:     m.effectiveTotalLocked(),
+		Completed: m.state.Source.Completed,
+	}
 }
 
 func (m *StateMachine) BeginSource(sourceID string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.selectLocked(sourceID)
 	m.state.Source.ID = sourceID
 	m.state.Source.Lifecycle = SourceLifecycleRunning
 }
@@ -32,6 +69,7 @@ func (m *StateMachine) BeginSource(sourceID string) {
 func (m *StateMachine) SetPlanningSource(sourceID string, plannedTotal int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.selectLocked(sourceID)
 	m.state.Source.ID = sourceID
 	m.state.Source.Lifecycle = SourceLifecyclePlanning
 	m.state.Source.PlannedTotal = clampCount(plannedTotal)
@@ -47,6 +85,7 @@ func (m *StateMachine) SetPlanningSource(sourceID string, plannedTotal int) {
 func (m *StateMachine) FinishSource(sourceID string, failed bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.selectLocked(sourceID)
 	m.state.Source.ID = sourceID
 	if failed {
 		m.state.Source.Lifecycle = SourceLifecycleFailed
@@ -16,6 +16,9 @@ var preflightSummaryPattern = regexp.MustCompile(`^\[[^\]]+\]\s+preflight:\s+.*\
 var deemixTrackProgressPattern = regexp.MustCompile(`^\[[^\]]+\]\s+deemix track ([0-9]+)\/([0-9]+)\s+([A-Za-z0-9]{10,32})(?:\s+\((.+)\))?$`)
 var deemixTrackDonePattern = regexp.MustCompile(`^\[[^\]]+\]\s+\[done\]\s+([A-Za-z0-9]{10,32})(?:\s+\(([^)]+)\))?$`)
 var deemixTrackSkipPattern = regexp.MustCompile(`^\[[^\]]+\]\s+\[skip\]\s+([A-Za-z0-9]{10,32})(?:\s+\(([^)]+)\))?(?:\s+\(([^)]+)\))?$`)
+var freeDLTrackProgressPattern = regexp.MustCompile(`^\[[^\]]+\]\s+free-dl track ([0-9]+)\/([0-9]+)\s+\S+(?:\s+\((.+)\))?$`)
+var freeDLTrackOutcomePattern = regexp.MustCompile(`^\[[^\]]+\]\s+\[(?:done|skip)\]\s+[0-9]+\s+\(`)
+var freeDLHandoffPattern = regexp.MustCompile(`^\[[^\]]+\]\s+\[free-dl\]\s+`)
 var deemixTrackFailurePattern = regexp.MustCompile(`^(?:ERROR:\s*)?\[[^\]]+\]\s+command failed with exit code ([0-9]+)$`)
 var deemixDownloadProgressPattern = regexp.MustCompile(`^\[([^\]]+)\]\s+Downloading:\s+([0-9]+(?:\.[0-9]+)?)%$`)
 var deemixDownloadCompletePattern = regexp.MustCompile(`^\[([^\]]+)\]\s+Download complete$`)
@@ -24,11 +27,18 @@ type CompactLogOptions struct {
 	Interactive      bool
 	PreflightSummary string
 	TrackStatus      string
+	// Color tints the leading [source-id] of persistent lines with a
+	// per-source ANSI color.
+	Color bool
 }
 
+// compactSourceColors are assigned to sources in the order they first emit.
+var compactSourceColors = []string{"36", "33", "35", "32", "34", "31"}
+
 type CompactLogWriter struct {
 	dst         io.Writer
 	interactive bool
+	color       bool
 
 	mu          sync.Mutex
 	buf         []byte
@@ -44,6 +54,12 @@ type CompactLogWriter struct {
 	structuredTrackEvents bool
 	preflightSummaryMode  string
 	trackStatusMode       string
+
+	// multiSource is set once the run covers more than one source; track
+	// outcome lines then carry a [source-id] prefix.
+	multiSource  bool
+	sourceOrder  []string
+	sourceColors map[string]string
 }
 
 const (
@@ -97,11 +113,13 @@ func NewCompactLogWriterWithOptions(dst io.Writer, opts CompactLogOptions) *Comp
 	return &CompactLogWriter{
 		dst:                  dst,
 		interactive:          opts.Interactive,
+		color:                opts.Color,
 		buf:                  make([]byte, 0, 256),
 		progress:             progress,
 		structured:           NewStructuredProgressTracker(progress),
 		preflightSummaryMode: preflightSummary,
 		trackStatusMode:      trackStatus,
+		sourceColors:         map[string]string{},
 	}
 }
 
@@ -157,6 +175,8 @@ func (w *CompactLogWriter) ObserveEvent(event Event) {
 	snapshot := w.structured.Snapshot()
 	w.structuredTrackEvents = snapshot.StructuredTrackEvents
 
+	w.noteSourceLocked(event)
+
 	switch event.Event {
 	case EventSyncStarted:
 		w.track = trackState{}
@@ -168,10 +188,10 @@ func (w *CompactLogWriter) ObserveEvent(event Event) {
 		_ = w.renderIdleStatusLocked()
 	case EventTrackStarted:
 		w.applyStructuredTrackLocked(snapshot.Track)
-		_ = w.renderStatusLocked(stageForStructuredLifecycle(snapshot.Track.Lifecycle))
+		_ = w.renderStatusLocked(stageForStructuredTrack(snapshot.Track))
 	case EventTrackProgress:
 		w.applyStructuredTrackLocked(snapshot.Track)
-		_ = w.renderStatusLocked(stageForStructuredLifecycle(snapshot.Track.Lifecycle))
+		_ = w.renderStatusLocked(stageForStructuredTrack(snapshot.Track))
 	case EventTrackDone:
 		w.track = trackState{}
 		w.printStructuredOutcomesLocked()
@@ -190,6 +210,50 @@ func (w *CompactLogWriter) ObserveEvent(event Event) {
 	}
 }
 
+// noteSourceLocked records the sources a run touches, resetting on each
+// sync start, so persistent lines can be attributed when sources interleave.
+func (w *CompactLogWriter) noteSourceLocked(event Event) {
+	if event.Event == EventSyncStarted {
+		total, _ := eventDetailInt(event.Details, "total")
+		w.multiSource = total > 1
+		w.sourceOrder = nil
+		w.sourceColors = map[string]string{}
+		return
+	}
+	sourceID := strings.TrimSpace(event.SourceID)
+	if sourceID == "" {
+		return
+	}
+	if w.sourceColors == nil {
+		w.sourceColors = map[string]string{}
+	}
+	if _, ok := w.sourceColors[sourceID]; ok {
+		return
+	}
+	w.sourceColors[sourceID] = compactSourceColors[len(w.sourceOrder)%len(compactSourceColors)]
+	w.sourceOrder = append(w.sourceOrder, sourceID)
+	if len(w.sourceOrder) > 1 {
+		w.multiSource = true
+	}
+}
+
+// colorizeSourcePrefixLocked tints a leading [source-id] that belongs to a
+// source seen in this run.
+func (w *CompactLogWriter) colorizeSourcePrefixLocked(line string) string {
+	if !w.color || !strings.HasPrefix(line, "[") {
+		return line
+	}
+	end := strings.IndexByte(line, ']')
+	if end <= 1 {
+		return line
+	}
+	color, ok := w.sourceColors[line[1:end]]
+	if !ok {
+		return line
+	}
+	return fmt.Sprintf("\033[%sm%s\033[0m%s", color, line[:end+1], line[end+1:])
+}
+
 func (w *CompactLogWriter) flushLineLocked() error {
 	if len(w.buf) == 0 {
 		return nil
@@ -297,7 +361,7 @@ func (w *CompactLogWriter) printPersistentLocked(line string) error {
 	if err := w.clearLiveLinesLocked(); err != nil {
 		return err
 	}
-	_, err := fmt.Fprintln(w.dst, line)
+	_, err := fmt.Fprintln(w.dst, w.colorizeSourcePrefixLocked(line))
 	return err
 }
 
@@ -336,6 +400,9 @@ func shouldSuppressSCDLNoise(line string) bool {
 	return strings.HasPrefix(line, "[scdl] ") ||
 		strings.HasPrefix(line, "[soundcloud] ") ||
 		strings.HasPrefix(line, "[soundcloud:user] ") ||
+		strings.HasPrefix(line, "[youtube] ") ||
+		strings.HasPrefix(line, "[youtube:tab] ") ||
+		strings.HasPrefix(line, "[ExtractAudio] ") ||
 		strings.HasPrefix(line, "[info] ") ||
 		strings.HasPrefix(line, "[hlsnative] ") ||
 		strings.HasPrefix(line, "[download] Destination: ") ||
@@ -367,7 +434,9 @@ func shouldSuppressAdapterChatter(line string, structuredTrackEvents bool) bool
 		case compactstate.LineEventDownloadItem,
 			compactstate.LineEventDownloadDestination,
 			compactstate.LineEventAlreadyDownloaded,
+			compactstate.LineEventAlreadyInArchive,
 			compactstate.LineEventFragmentProgress,
+			compactstate.LineEventDownloadProgress,
 			compactstate.LineEventNoisyDownloadProgress,
 			compactstate.LineEventSpotDLFoundSongs,
 			compactstate.LineEventSpotDLDownloaded,
@@ -387,6 +456,12 @@ func shouldSuppressAdapterChatter(line string, structuredTrackEvents bool) bool
 		(deemixTrackDonePattern.MatchString(trimmed) || deemixTrackSkipPattern.MatchString(trimmed)) {
 		return true
 	}
+	if structuredTrackEvents &&
+		(freeDLTrackProgressPattern.MatchString(trimmed) ||
+			freeDLTrackOutcomePattern.MatchString(trimmed) ||
+			freeDLHandoffPattern.MatchString(trimmed)) {
+		return true
+	}
 
 	return false
 }
@@ -611,8 +686,19 @@ func (w *CompactLogWriter) printStructuredOutcomesLocked() {
 		if w.trackStatusMode == CompactTrackStatusNone {
 			continue
 		}
-		_ = w.printPersistentLocked(FormatCompactTrackOutcome(outcome, w.trackStatusMode))
+		line := FormatCompactTrackOutcome(outcome, w.trackStatusMode)
+		if w.multiSource && outcome.SourceID != "" {
+			line = fmt.Sprintf("[%s] %s", outcome.SourceID, line)
+		}
+		_ = w.printPersistentLocked(line)
+	}
+}
+
+func stageForStructuredTrack(track StructuredTrackState) string {
+	if stage := FormatStructuredTrackStage(track); stage != "" {
+		return stage
 	}
+	return stageForStructuredLifecycle(track.Lifecycle)
 }
 
 func stageForStructuredLifecycle(lifecycle compactstate.TrackLifecycle) string {
@@ -305,3 +305,96 @@ func TestCompactLogWriterKeepsNonStructuredSkipLineWhileSuppressingDeemixStackNo
 		t.Fatalf("expected raw deemix stack noise to be suppressed, got: %s", out)
 	}
 }
+
+func TestCompactLogWriterRendersFreeDLStageAndSuppressesTextualLines(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := NewCompactLogWriterWithOptions(buf, CompactLogOptions{Interactive: true})
+
+	writer.ObserveEvent(Event{
+		Event:    EventTrackStarted,
+		SourceID: "sc-free",
+		Details: map[string]any{
+			"track_id":   "111",
+			"track_name": "Gate Song",
+			"index":      1,
+			"total":      1,
+		},
+	})
+	_, _ = writer.Write([]byte("[sc-free] free-dl track 1/1 111 (Gate Song)\n"))
+	_, _ = writer.Write([]byte("[sc-free] [free-dl] waiting for completed browser download for 111 in /tmp/Downloads\n"))
+	writer.ObserveEvent(Event{
+		Event:    EventTrackProgress,
+		SourceID: "sc-free",
+		Details: map[string]any{
+			"track_id":   "111",
+			"track_name": "Gate Song",
+			"index":      1,
+			"total":      1,
+			"percent":    25.0,
+			"stage":      "waiting_for_browser",
+			"elapsed_ms": int64(65000),
+			"idle_ms":    int64(20000),
+		},
+	})
+	_, _ = writer.Write([]byte("[sc-free] [done] 111 (Artist - Gate Song)\n"))
+
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "waiting-for-browser 1m5s, idle 20s") {
+		t.Fatalf("expected stage label with timers, got: %s", out)
+	}
+	if strings.Contains(out, "free-dl track 1/1") || strings.Contains(out, "[free-dl] waiting") || strings.Contains(out, "[done] 111") {
+		t.Fatalf("expected textual free-dl lines to be suppressed, got: %s", out)
+	}
+}
+
+func TestCompactLogWriterPrefixesInterleavedSourcesAndKeepsPerSourceCounts(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := NewCompactLogWriterWithOptions(buf, CompactLogOptions{Interactive: false, TrackStatus: CompactTrackStatusCount})
+
+	writer.ObserveEvent(Event{Event: EventSyncStarted, Details: map[string]any{"total": 2}})
+	writer.ObserveEvent(Event{Event: EventSourcePreflight, SourceID: "source-a", Details: map[string]any{"planned_download_count": 2}})
+	writer.ObserveEvent(Event{Event: EventTrackStarted, SourceID: "source-a", Details: map[string]any{"track_name": "A Song"}})
+	writer.ObserveEvent(Event{Event: EventSourcePreflight, SourceID: "source-b", Details: map[string]any{"planned_download_count": 3}})
+	writer.ObserveEvent(Event{Event: EventTrackDone, SourceID: "source-b", Details: map[string]any{"track_name": "B Song"}})
+	writer.ObserveEvent(Event{Event: EventTrackDone, SourceID: "source-a"})
+	writer.ObserveEvent(Event{Event: EventTrackDone, SourceID: "source-a", Details: map[string]any{"track_name": "A Second"}})
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+
+	want := strings.Join([]string{
+		"[source-b] [done] track 1/3",
+		"[source-a] [done] track 1/2",
+		"[source-a] [done] track 2/2",
+	}, "\n") + "\n"
+	if got := buf.String(); got != want {
+		t.Fatalf("expected per-source outcome lines:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestCompactLogWriterColorsKnownSourcePrefixes(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writer := NewCompactLogWriterWithOptions(buf, CompactLogOptions{Interactive: false, Color: true})
+
+	writer.ObserveEvent(Event{Event: EventSyncStarted, Details: map[string]any{"total": 2}})
+	writer.ObserveEvent(Event{Event: EventSourceStarted, SourceID: "source-a"})
+	writer.ObserveEvent(Event{Event: EventSourceStarted, SourceID: "source-b"})
+	if _, err := writer.Write([]byte("[source-a] finished\n[source-b] finished\n[download] other\nWarning: [source-a] later\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"\033[36m[source-a]\033[0m finished\n",
+		"\033[33m[source-b]\033[0m finished\n",
+		"Warning: [source-a] later\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output, got %q", want, out)
+		}
+	}
+}
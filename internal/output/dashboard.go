@@ -0,0 +1,383 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	dashboardBarWidth     = 20
+	dashboardRecentEvents = 8
+	dashboardDefaultWidth = 100
+)
+
+// dashboardRenderer draws a sync as a live multi-source dashboard: one row
+// per source with a progress bar and its current track, the latest events
+// underneath, and a summary once the sync finishes. It runs a Bubble Tea
+// program without input so prompts and Ctrl-C stay with the sync.
+type dashboardRenderer struct {
+	program   *tea.Program
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newDashboardRenderer(opts RendererOptions) Renderer {
+	model := dashboardModel{state: newDashboardState(opts)}
+	r := &dashboardRenderer{
+		program: tea.NewProgram(model,
+			tea.WithInput(nil),
+			tea.WithOutput(opts.Stdout),
+			tea.WithoutSignalHandler(),
+		),
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		_, _ = r.program.Run()
+	}()
+	return r
+}
+
+type dashboardEventMsg struct {
+	event Event
+}
+
+func (r *dashboardRenderer) Emit(event Event) error {
+	r.program.Send(dashboardEventMsg{event: event})
+	return nil
+}
+
+func (r *dashboardRenderer) SubprocessOutput() (io.Writer, io.Writer) {
+	return io.Discard, io.Discard
+}
+
+// Close stops the program after its final frame, which holds the summary
+// when the sync got as far as finishing.
+func (r *dashboardRenderer) Close() error {
+	r.closeOnce.Do(func() {
+		r.program.Quit()
+		<-r.done
+	})
+	return nil
+}
+
+type dashboardModel struct {
+	state *dashboardState
+}
+
+func (m dashboardModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case dashboardEventMsg:
+		m.state.observe(msg.event)
+		if m.state.finished {
+			return m, tea.Quit
+		}
+	case tea.WindowSizeMsg:
+		m.state.width = msg.Width
+	}
+	return m, nil
+}
+
+func (m dashboardModel) View() string {
+	return m.state.view()
+}
+
+type dashboardSourceStatus string
+
+const (
+	dashboardSourcePlanned dashboardSourceStatus = "planned"
+	dashboardSourceRunning dashboardSourceStatus = "running"
+	dashboardSourceDone    dashboardSourceStatus = "done"
+	dashboardSourceSkipped dashboardSourceStatus = "skipped"
+	dashboardSourceFailed  dashboardSourceStatus = "failed"
+)
+
+type dashboardSource struct {
+	id           string
+	status       dashboardSourceStatus
+	planned      int
+	plannedKnown bool
+	done         int
+	skipped      int
+	failed       int
+	track        StructuredTrackState
+}
+
+func (s *dashboardSource) completed() int {
+	return s.done + s.skipped + s.failed
+}
+
+// dashboardState is the dashboard's view of the run, built from events
+// alone; the Bubble Tea model only forwards to it.
+type dashboardState struct {
+	trackStatus string
+	styles      dashboardStyles
+	width       int
+	total       int
+	started     time.Time
+	last        time.Time
+	order       []string
+	sources     map[string]*dashboardSource
+	recent      []string
+	finished    bool
+	summary     string
+}
+
+func newDashboardState(opts RendererOptions) *dashboardState {
+	return &dashboardState{
+		trackStatus: opts.TrackStatus,
+		styles:      newDashboardStyles(opts.Color),
+		width:       dashboardDefaultWidth,
+		sources:     map[string]*dashboardSource{},
+	}
+}
+
+func (d *dashboardState) source(id string) *dashboardSource {
+	source, ok := d.sources[id]
+	if !ok {
+		source = &dashboardSource{id: id, status: dashboardSourcePlanned}
+		d.sources[id] = source
+		d.order = append(d.order, id)
+	}
+	return source
+}
+
+func (d *dashboardState) observe(event Event) {
+	if event.Timestamp.After(d.last) {
+		d.last = event.Timestamp
+	}
+	if event.Event == EventSyncStarted {
+		d.started = event.Timestamp
+		if total, ok := eventDetailInt(event.Details, "total"); ok {
+			d.total = total
+		}
+		return
+	}
+	if event.Event == EventSyncFinished {
+		d.finished = true
+		d.summary = event.Message
+		return
+	}
+	if strings.TrimSpace(event.SourceID) == "" {
+		if event.Level != LevelInfo {
+			d.note(event.Message)
+		}
+		return
+	}
+
+	source := d.source(event.SourceID)
+	switch event.Event {
+	case EventSourcePreflight:
+		if planned, ok := eventDetailInt(event.Details, "planned_download_count"); ok {
+			source.planned = planned
+			source.plannedKnown = true
+		}
+	case EventSourceStarted:
+		source.status = dashboardSourceRunning
+	case EventTrackStarted, EventTrackProgress:
+		source.status = dashboardSourceRunning
+		if name := dashboardTrackName(event); name != "" {
+			source.track.Name = name
+		}
+		if percent, ok := eventDetailFloat(event.Details, "percent"); ok {
+			source.track.ProgressKnown = true
+			source.track.ProgressPercent = percent
+		}
+		source.track.Stage = strings.TrimSpace(eventDetailString(event.Details, "stage"))
+		source.track.StageElapsed = eventDetailMillis(event.Details, "elapsed_ms")
+		source.track.StageIdle = eventDetailMillis(event.Details, "idle_ms")
+	case EventTrackDone, EventTrackSkip, EventTrackFail:
+		outcome := StructuredTrackOutcome{SourceID: source.id, Kind: StructuredTrackOutcomeDone, Name: dashboardTrackName(event)}
+		switch event.Event {
+		case EventTrackDone:
+			source.done++
+		case EventTrackSkip:
+			source.skipped++
+			outcome.Kind = StructuredTrackOutcomeSkip
+		default:
+			source.failed++
+			outcome.Kind = StructuredTrackOutcomeFail
+		}
+		outcome.Reason = strings.TrimSpace(eventDetailString(event.Details, "reason"))
+		outcome.Completed = source.completed()
+		outcome.Total = source.planned
+		source.track = StructuredTrackState{}
+		if d.trackStatus != CompactTrackStatusNone {
+			d.note(fmt.Sprintf("[%s] %s", source.id, FormatCompactTrackOutcome(outcome, d.trackStatus)))
+		}
+		return
+	case EventSourceFinished:
+		source.status = dashboardSourceDone
+		if skipped, _ := event.Details["skipped"].(bool); skipped {
+			source.status = dashboardSourceSkipped
+		}
+		source.track = StructuredTrackState{}
+	case EventSourceFailed:
+		source.status = dashboardSourceFailed
+		source.track = StructuredTrackState{}
+		d.note(event.Message)
+		return
+	}
+	if event.Level != LevelInfo {
+		d.note(event.Message)
+	}
+}
+
+func dashboardTrackName(event Event) string {
+	if name := strings.TrimSpace(eventDetailString(event.Details, "track_name")); name != "" {
+		return name
+	}
+	return strings.TrimSpace(eventDetailString(event.Details, "track_id"))
+}
+
+func (d *dashboardState) note(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	d.recent = append(d.recent, line)
+	if len(d.recent) > dashboardRecentEvents {
+		d.recent = d.recent[len(d.recent)-dashboardRecentEvents:]
+	}
+}
+
+func (d *dashboardState) view() string {
+	width := d.width
+	if width <= 0 {
+		width = dashboardDefaultWidth
+	}
+	finishedSources, done, skipped, failed := 0, 0, 0, 0
+	idWidth := 0
+	for _, id := range d.order {
+		source := d.sources[id]
+		switch source.status {
+		case dashboardSourceDone, dashboardSourceSkipped, dashboardSourceFailed:
+			finishedSources++
+		}
+		done += source.done
+		skipped += source.skipped
+		failed += source.failed
+		idWidth = max(idWidth, len(id))
+	}
+	total := max(d.total, len(d.order))
+
+	lines := []string{}
+	header := fmt.Sprintf("udl sync  %d/%d source(s)  downloaded=%d skipped=%d failed=%d", finishedSources, total, done, skipped, failed)
+	if !d.started.IsZero() && d.last.After(d.started) {
+		header += "  " + d.last.Sub(d.started).Truncate(time.Second).String()
+	}
+	lines = append(lines, d.styles.header.Render(truncateDashboardLine(header, width)))
+
+	for _, id := range d.order {
+		lines = append(lines, d.sourceLine(d.sources[id], idWidth, width))
+	}
+
+	if len(d.recent) > 0 {
+		lines = append(lines, "", d.styles.label.Render("recent"))
+		for _, line := range d.recent {
+			lines = append(lines, d.styles.recent.Render("  "+truncateDashboardLine(line, width-2)))
+		}
+	}
+	if d.finished && d.summary != "" {
+		lines = append(lines, "", d.styles.header.Render(truncateDashboardLine(d.summary, width)))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (d *dashboardState) sourceLine(source *dashboardSource, idWidth int, width int) string {
+	counts := fmt.Sprintf("%d", source.completed())
+	if source.plannedKnown {
+		counts = fmt.Sprintf("%d/%d", source.completed(), source.planned)
+	}
+	prefix := fmt.Sprintf("%-*s %s %-9s %-8s", idWidth, source.id, dashboardBar(source), counts, source.status)
+
+	detail := ""
+	if source.track.Name != "" {
+		detail = source.track.Name
+		if stage := FormatStructuredTrackStage(source.track); stage != "" {
+			detail += " (" + stage + ")"
+		} else if source.track.ProgressKnown {
+			detail += fmt.Sprintf(" %.0f%%", source.track.ProgressPercent)
+		}
+	}
+	room := width - len(prefix) - 1
+	if detail == "" || room <= 0 {
+		return d.styles.forStatus(source.status).Render(strings.TrimRight(truncateDashboardLine(prefix, width), " "))
+	}
+	return d.styles.forStatus(source.status).Render(prefix) + " " + truncateDashboardLine(detail, room)
+}
+
+func dashboardBar(source *dashboardSource) string {
+	fraction := 0.0
+	switch {
+	case source.plannedKnown && source.planned > 0:
+		partial := 0.0
+		if source.track.ProgressKnown {
+			partial = source.track.ProgressPercent / 100
+		}
+		fraction = (float64(source.completed()) + partial) / float64(source.planned)
+	case source.status == dashboardSourceDone || source.status == dashboardSourceSkipped:
+		fraction = 1
+	}
+	filled := int(min(max(fraction, 0), 1) * dashboardBarWidth)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", dashboardBarWidth-filled) + "]"
+}
+
+func truncateDashboardLine(line string, width int) string {
+	runes := []rune(line)
+	if width <= 0 || len(runes) <= width {
+		return line
+	}
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+type dashboardStyles struct {
+	header  lipgloss.Style
+	label   lipgloss.Style
+	recent  lipgloss.Style
+	running lipgloss.Style
+	done    lipgloss.Style
+	failed  lipgloss.Style
+	idle    lipgloss.Style
+}
+
+func newDashboardStyles(color bool) dashboardStyles {
+	if !color {
+		plain := lipgloss.NewStyle()
+		return dashboardStyles{header: plain, label: plain, recent: plain, running: plain, done: plain, failed: plain, idle: plain}
+	}
+	return dashboardStyles{
+		header:  lipgloss.NewStyle().Bold(true),
+		label:   lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Bold(true),
+		recent:  lipgloss.NewStyle().Foreground(lipgloss.Color("245")),
+		running: lipgloss.NewStyle().Foreground(lipgloss.Color("39")),
+		done:    lipgloss.NewStyle().Foreground(lipgloss.Color("42")),
+		failed:  lipgloss.NewStyle().Foreground(lipgloss.Color("203")),
+		idle:    lipgloss.NewStyle().Foreground(lipgloss.Color("245")),
+	}
+}
+
+func (s dashboardStyles) forStatus(status dashboardSourceStatus) lipgloss.Style {
+	switch status {
+	case dashboardSourceRunning:
+		return s.running
+	case dashboardSourceDone:
+		return s.done
+	case dashboardSourceFailed:
+		return s.failed
+	}
+	return s.idle
+}
@@ -0,0 +1,77 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dashboardTestEvents() []Event {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	return []Event{
+		{Timestamp: start, Event: EventSyncStarted, Details: map[string]any{"total": 3}},
+		{Timestamp: start, Event: EventSourcePreflight, SourceID: "sc-likes", Details: map[string]any{"planned_download_count": 4}},
+		{Timestamp: start, Event: EventSourceStarted, SourceID: "sc-likes"},
+		{Timestamp: start, Level: LevelInfo, Event: EventTrackDone, SourceID: "sc-likes", Details: map[string]any{"track_name": "First"}},
+		{Timestamp: start, Level: LevelWarn, Event: EventTrackSkip, SourceID: "sc-likes", Details: map[string]any{"track_name": "Second", "reason": "unavailable"}},
+		{Timestamp: start.Add(42 * time.Second), Event: EventTrackProgress, SourceID: "sc-likes", Details: map[string]any{"track_name": "Third", "percent": 50.0}},
+		{Timestamp: start, Level: LevelWarn, Event: EventSourceFinished, SourceID: "yt-mix", Message: "[yt-mix] skipping source", Details: map[string]any{"skipped": true}},
+		{Timestamp: start, Level: LevelError, Event: EventSourceFailed, SourceID: "sp-weekly", Message: "[sp-weekly] spotify deemix preflight failed: boom"},
+	}
+}
+
+func TestDashboardStateRendersSourcesTracksAndRecentEvents(t *testing.T) {
+	state := newDashboardState(RendererOptions{TrackStatus: CompactTrackStatusNames})
+	for _, event := range dashboardTestEvents() {
+		state.observe(event)
+	}
+	view := state.view()
+	for _, want := range []string{
+		"udl sync  2/3 source(s)  downloaded=1 skipped=1 failed=0  42s",
+		"sc-likes  [############--------] 2/4       running  Third 50%",
+		"yt-mix    [####################] 0         skipped\n",
+		"sp-weekly [--------------------] 0         failed\n",
+		"  [sc-likes] [done] First",
+		"  [sc-likes] [skip] Second (unavailable)",
+		"  [yt-mix] skipping source",
+		"  [sp-weekly] spotify deemix preflight failed: boom",
+	} {
+		if !strings.Contains(view, want) {
+			t.Fatalf("expected %q in dashboard view:\n%s", want, view)
+		}
+	}
+	if state.finished {
+		t.Fatal("did not expect the dashboard to finish before sync_finished")
+	}
+
+	state.width = 40
+	if line := strings.Split(state.view(), "\n")[1]; len([]rune(line)) > 40 {
+		t.Fatalf("expected source rows cut to the terminal width, got %q", line)
+	}
+}
+
+func TestDashboardRendererPrintsSummaryOnFinish(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	renderer, err := NewRenderer(RendererDashboard, RendererOptions{Stdout: stdout, TrackStatus: CompactTrackStatusNames})
+	if err != nil {
+		t.Fatalf("new renderer: %v", err)
+	}
+	for _, event := range dashboardTestEvents() {
+		_ = renderer.Emit(event)
+	}
+	_ = renderer.Emit(Event{Event: EventSyncFinished, Message: "sync finished: attempted=2 succeeded=1 failed=1 skipped=1"})
+	closer, ok := renderer.(interface{ Close() error })
+	if !ok {
+		t.Fatal("expected the dashboard renderer to be closable")
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "sync finished: attempted=2 succeeded=1 failed=1 skipped=1") {
+		t.Fatalf("expected the final frame to hold the summary, got %q", stdout.String())
+	}
+	if err := renderer.Emit(Event{Event: EventSourceStarted, SourceID: "late"}); err != nil {
+		t.Fatalf("expected events after close to be dropped, got %v", err)
+	}
+}
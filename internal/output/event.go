@@ -24,6 +24,15 @@ const (
 	EventTrackDone       EventName = "track_done"
 	EventTrackSkip       EventName = "track_skip"
 	EventTrackFail       EventName = "track_fail"
+
+	EventNotificationFailed EventName = "notification_failed"
+	EventStateGC            EventName = "state_gc"
+	EventImportWatch        EventName = "import_watch"
+
+	EventWatchStarted       EventName = "watch_started"
+	EventWatchCycleStarted  EventName = "watch_cycle_started"
+	EventWatchCycleFinished EventName = "watch_cycle_finished"
+	EventWatchStopped       EventName = "watch_stopped"
 )
 
 func IsTrackEventName(name EventName) bool {
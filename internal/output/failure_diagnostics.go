@@ -79,6 +79,12 @@ func normalizeFailureDiagnosticsEvent(stateDir string, event Event) Event {
 	return normalized
 }
 
+// SyncFailureLogPath returns <state_dir>/sync-failures.jsonl, where source
+// failures are captured with their adapter output tails.
+func SyncFailureLogPath(stateDir string) (string, error) {
+	return resolveSyncFailureLogPath(stateDir)
+}
+
 func resolveSyncFailureLogPath(defaultStateDir string) (string, error) {
 	stateDir, err := config.ExpandPath(defaultStateDir)
 	if err != nil {
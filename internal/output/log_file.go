@@ -0,0 +1,117 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	DefaultLogFileMaxBytes   int64 = 10 << 20
+	DefaultLogFileMaxBackups       = 3
+)
+
+type RotatingFileOptions struct {
+	MaxBytes   int64
+	MaxBackups int
+}
+
+// RotatingFile is an append-only writer that rotates path to path.1, path.2,
+// ... once a write would push it past MaxBytes. Each Write is kept whole, so
+// newline-delimited JSON records are never split across files.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func OpenRotatingFile(path string, opts RotatingFileOptions) (*RotatingFile, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultLogFileMaxBytes
+	}
+	if opts.MaxBackups < 0 {
+		opts.MaxBackups = 0
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	w := &RotatingFile{path: path, maxBytes: opts.MaxBytes, maxBackups: opts.MaxBackups}
+	if err := w.openLocked(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *RotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, fmt.Errorf("log file %s is closed", w.path)
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotateLocked(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *RotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *RotatingFile) openLocked() error {
+	file, err := os.OpenFile(w.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *RotatingFile) rotateLocked() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+	if w.maxBackups == 0 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return w.openLocked()
+	}
+	_ = os.Remove(w.backupPath(w.maxBackups))
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(w.backupPath(i), w.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(w.path, w.backupPath(1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return w.openLocked()
+}
+
+func (w *RotatingFile) backupPath(index int) string {
+	return fmt.Sprintf("%s.%d", w.path, index)
+}
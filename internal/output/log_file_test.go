@@ -0,0 +1,52 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFileKeepsWholeJSONRecordsAndBoundedBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "udl.jsonl")
+	file, err := OpenRotatingFile(path, RotatingFileOptions{MaxBytes: 200, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	emitter := NewJSONEmitter(file)
+	for i := 0; i < 20; i++ {
+		if err := emitter.Emit(Event{Level: LevelInfo, Event: EventTrackDone, SourceID: "sc", Message: "track done"}); err != nil {
+			t.Fatalf("emit %d: %v", i, err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	for _, candidate := range []string{path, path + ".1", path + ".2"} {
+		handle, err := os.Open(candidate)
+		if err != nil {
+			t.Fatalf("expected %s: %v", candidate, err)
+		}
+		scanner := bufio.NewScanner(handle)
+		lines := 0
+		for scanner.Scan() {
+			lines++
+			decoded := Event{}
+			if err := json.Unmarshal(scanner.Bytes(), &decoded); err != nil {
+				t.Fatalf("%s line %d is not a whole JSON record: %v", candidate, lines, err)
+			}
+		}
+		_ = handle.Close()
+		if lines == 0 {
+			t.Fatalf("expected records in %s", candidate)
+		}
+		if info, _ := os.Stat(candidate); info.Size() > 200 {
+			t.Fatalf("expected %s to stay under max size, got %d", candidate, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected at most two backups, got err=%v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsCollector counts what long-running syncs did, per source, from
+// their events and serves the totals in the Prometheus text exposition
+// format. It is an EventObserver, so it rides along an emitter through
+// NewObservingEmitter.
+type MetricsCollector struct {
+	mu      sync.Mutex
+	sources map[string]*sourceMetrics
+
+	cycles             int
+	cycleFailures      int
+	dependencyFailures int
+}
+
+type sourceMetrics struct {
+	downloads     int
+	skips         int
+	trackFailures int
+	succeeded     int
+	failed        int
+	skipped       int
+
+	started         time.Time
+	durationSum     time.Duration
+	durationCount   int
+	lastDuration    time.Duration
+	lastSuccessUnix int64
+}
+
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{sources: map[string]*sourceMetrics{}}
+}
+
+func (m *MetricsCollector) source(id string) *sourceMetrics {
+	source, ok := m.sources[id]
+	if !ok {
+		source = &sourceMetrics{}
+		m.sources[id] = source
+	}
+	return source
+}
+
+func (m *MetricsCollector) ObserveEvent(event Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if event.Event == EventWatchCycleFinished {
+		m.cycles++
+		if event.Level != LevelInfo {
+			m.cycleFailures++
+		}
+		if count, ok := eventDetailInt(event.Details, "dependency_failures"); ok {
+			m.dependencyFailures += count
+		}
+		return
+	}
+	sourceID := strings.TrimSpace(event.SourceID)
+	if sourceID == "" {
+		return
+	}
+	switch event.Event {
+	case EventTrackDone:
+		m.source(sourceID).downloads++
+	case EventTrackSkip:
+		m.source(sourceID).skips++
+	case EventTrackFail:
+		m.source(sourceID).trackFailures++
+	case EventSourceStarted:
+		m.source(sourceID).started = event.Timestamp
+	case EventSourceFinished:
+		source := m.source(sourceID)
+		if skipped, _ := event.Details["skipped"].(bool); skipped {
+			source.skipped++
+			source.started = time.Time{}
+			return
+		}
+		// Warnings such as a failed temp-state cleanup are emitted as
+		// source_finished too; only the info-level event ends the run.
+		if event.Level != LevelInfo {
+			return
+		}
+		source.succeeded++
+		source.lastSuccessUnix = event.Timestamp.Unix()
+		source.finishRun(event.Timestamp)
+	case EventSourceFailed:
+		source := m.source(sourceID)
+		source.failed++
+		source.finishRun(event.Timestamp)
+	}
+}
+
+// finishRun records the run's duration when the source was seen starting;
+// sources that fail in preflight never start and add no duration.
+func (s *sourceMetrics) finishRun(at time.Time) {
+	if s.started.IsZero() {
+		return
+	}
+	if at.After(s.started) {
+		s.lastDuration = at.Sub(s.started)
+	} else {
+		s.lastDuration = 0
+	}
+	s.durationSum += s.lastDuration
+	s.durationCount++
+	s.started = time.Time{}
+}
+
+// ServeHTTP answers scrapes of the metrics endpoint.
+func (m *MetricsCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.Write(w)
+}
+
+// Write writes every metric in the Prometheus text exposition format.
+// Per-source series are ordered by source ID so scrapes diff cleanly.
+func (m *MetricsCollector) Write(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := make([]string, 0, len(m.sources))
+	for id := range m.sources {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	writeMetricHeader(&b, "udl_watch_cycles_total", "counter", "Watch cycles finished.")
+	fmt.Fprintf(&b, "udl_watch_cycles_total %d\n", m.cycles)
+	writeMetricHeader(&b, "udl_watch_cycle_failures_total", "counter", "Watch cycles that finished with a failed source or an error.")
+	fmt.Fprintf(&b, "udl_watch_cycle_failures_total %d\n", m.cycleFailures)
+	writeMetricHeader(&b, "udl_dependency_failures_total", "counter", "Sources that failed on a missing tool, env var, or credential.")
+	fmt.Fprintf(&b, "udl_dependency_failures_total %d\n", m.dependencyFailures)
+
+	perSource := []struct {
+		name  string
+		kind  string
+		help  string
+		value func(*sourceMetrics) string
+	}{
+		{"udl_source_downloads_total", "counter", "Tracks downloaded.", func(s *sourceMetrics) string { return fmt.Sprint(s.downloads) }},
+		{"udl_source_skips_total", "counter", "Tracks skipped.", func(s *sourceMetrics) string { return fmt.Sprint(s.skips) }},
+		{"udl_source_track_failures_total", "counter", "Tracks that failed to download.", func(s *sourceMetrics) string { return fmt.Sprint(s.trackFailures) }},
+		{"udl_source_last_duration_seconds", "gauge", "Duration of the latest finished source run.", func(s *sourceMetrics) string { return formatMetricSeconds(s.lastDuration) }},
+		{"udl_source_last_success_timestamp_seconds", "gauge", "Unix time of the latest successful source run.", func(s *sourceMetrics) string { return fmt.Sprint(s.lastSuccessUnix) }},
+	}
+	for _, metric := range perSource {
+		writeMetricHeader(&b, metric.name, metric.kind, metric.help)
+		for _, id := range ids {
+			fmt.Fprintf(&b, "%s{source=\"%s\"} %s\n", metric.name, escapeMetricLabel(id), metric.value(m.sources[id]))
+		}
+	}
+
+	writeMetricHeader(&b, "udl_source_runs_total", "counter", "Source runs by result.")
+	for _, id := range ids {
+		source := m.sources[id]
+		label := escapeMetricLabel(id)
+		fmt.Fprintf(&b, "udl_source_runs_total{source=\"%s\",result=\"succeeded\"} %d\n", label, source.succeeded)
+		fmt.Fprintf(&b, "udl_source_runs_total{source=\"%s\",result=\"failed\"} %d\n", label, source.failed)
+		fmt.Fprintf(&b, "udl_source_runs_total{source=\"%s\",result=\"skipped\"} %d\n", label, source.skipped)
+	}
+
+	writeMetricHeader(&b, "udl_source_duration_seconds", "summary", "Duration of finished source runs.")
+	for _, id := range ids {
+		source := m.sources[id]
+		label := escapeMetricLabel(id)
+		fmt.Fprintf(&b, "udl_source_duration_seconds_sum{source=\"%s\"} %s\n", label, formatMetricSeconds(source.durationSum))
+		fmt.Fprintf(&b, "udl_source_duration_seconds_count{source=\"%s\"} %d\n", label, source.durationCount)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMetricHeader(b *strings.Builder, name string, kind string, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func formatMetricSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func escapeMetricLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
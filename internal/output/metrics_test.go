@@ -0,0 +1,74 @@
+package output
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsCollectorCountsPerSourceOutcomesAndDurations(t *testing.T) {
+	metrics := NewMetricsCollector()
+	next := &captureEmitter{}
+	emitter := NewObservingEmitter(metrics, next)
+	start := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	events := []Event{
+		{Timestamp: start, Event: EventSourceStarted, SourceID: "sc-likes"},
+		{Timestamp: start, Event: EventTrackDone, SourceID: "sc-likes"},
+		{Timestamp: start, Event: EventTrackDone, SourceID: "sc-likes"},
+		{Timestamp: start, Event: EventTrackSkip, SourceID: "sc-likes"},
+		{Timestamp: start.Add(30 * time.Second), Level: LevelWarn, Event: EventSourceFinished, SourceID: "sc-likes", Message: "[sc-likes] unable to clean temporary state file"},
+		{Timestamp: start.Add(90 * time.Second), Level: LevelInfo, Event: EventSourceFinished, SourceID: "sc-likes"},
+		{Timestamp: start, Event: EventSourceStarted, SourceID: "sp-mix"},
+		{Timestamp: start, Event: EventTrackFail, SourceID: "sp-mix"},
+		{Timestamp: start.Add(3 * time.Second), Level: LevelError, Event: EventSourceFailed, SourceID: "sp-mix"},
+		{Timestamp: start, Event: EventSourceFinished, SourceID: "yt-off", Details: map[string]any{"skipped": true}},
+		{Timestamp: start, Level: LevelWarn, Event: EventWatchCycleFinished, Details: map[string]any{"dependency_failures": 1}},
+	}
+	for _, event := range events {
+		if err := emitter.Emit(event); err != nil {
+			t.Fatalf("emit: %v", err)
+		}
+	}
+	if len(next.events) != len(events) {
+		t.Fatalf("expected events forwarded, got %d", len(next.events))
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK || !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected response %d %q", recorder.Code, recorder.Header().Get("Content-Type"))
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"# TYPE udl_source_downloads_total counter\n",
+		`udl_source_downloads_total{source="sc-likes"} 2`,
+		`udl_source_skips_total{source="sc-likes"} 1`,
+		`udl_source_track_failures_total{source="sp-mix"} 1`,
+		`udl_source_runs_total{source="sc-likes",result="succeeded"} 1`,
+		`udl_source_runs_total{source="sp-mix",result="failed"} 1`,
+		`udl_source_runs_total{source="yt-off",result="skipped"} 1`,
+		`udl_source_duration_seconds_sum{source="sc-likes"} 90.000`,
+		`udl_source_duration_seconds_count{source="sp-mix"} 1`,
+		`udl_source_duration_seconds_count{source="yt-off"} 0`,
+		`udl_source_last_success_timestamp_seconds{source="sc-likes"} 1777629690`,
+		"udl_watch_cycles_total 1\n",
+		"udl_watch_cycle_failures_total 1\n",
+		"udl_dependency_failures_total 1\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in metrics:\n%s", want, body)
+		}
+	}
+	if strings.Index(body, `{source="sc-likes"}`) > strings.Index(body, `{source="sp-mix"}`) {
+		t.Fatalf("expected series ordered by source id:\n%s", body)
+	}
+
+	recorder = httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST to be rejected, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,210 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Renderer draws a run for the user. Every structured event reaches Emit;
+// adapter subprocess output that is not an event goes to the writers
+// returned by SubprocessOutput (io.Discard to drop it). A renderer sees
+// track_* events with their details, so it does not need to parse message
+// text the way the compact writer does.
+type Renderer interface {
+	EventEmitter
+	SubprocessOutput() (stdout io.Writer, stderr io.Writer)
+}
+
+// RendererOptions are the terminal settings a renderer is built with.
+type RendererOptions struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	// Interactive allows in-place line updates (progress bars).
+	Interactive bool
+	Color       bool
+	Quiet       bool
+	Verbose     bool
+	// PreflightSummary and TrackStatus carry the --preflight-summary and
+	// --track-status modes for renderers that support them.
+	PreflightSummary string
+	TrackStatus      string
+}
+
+// RendererFactory builds a renderer for one run.
+type RendererFactory func(opts RendererOptions) Renderer
+
+const (
+	RendererCompact = "compact"
+	RendererPlain   = "plain"
+	RendererMinimal = "minimal"
+	RendererJSON    = "json"
+	// RendererDashboard is the live multi-source view behind sync --ui tui.
+	RendererDashboard = "dashboard"
+)
+
+var (
+	renderersMu sync.RWMutex
+	renderers   = map[string]RendererFactory{
+		RendererCompact:   newCompactRenderer,
+		RendererPlain:     newPlainRenderer,
+		RendererMinimal:   newMinimalRenderer,
+		RendererJSON:      newJSONRenderer,
+		RendererDashboard: newDashboardRenderer,
+	}
+)
+
+// RegisterRenderer makes a renderer selectable by name with
+// --output-renderer. Names are case-insensitive and cannot be registered
+// twice.
+func RegisterRenderer(name string, factory RendererFactory) error {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		return fmt.Errorf("renderer name is required")
+	}
+	if factory == nil {
+		return fmt.Errorf("renderer %q has no factory", key)
+	}
+	renderersMu.Lock()
+	defer renderersMu.Unlock()
+	if _, exists := renderers[key]; exists {
+		return fmt.Errorf("renderer %q is already registered", key)
+	}
+	renderers[key] = factory
+	return nil
+}
+
+// RendererNames lists the registered renderer names, sorted.
+func RendererNames() []string {
+	renderersMu.RLock()
+	defer renderersMu.RUnlock()
+	names := make([]string, 0, len(renderers))
+	for name := range renderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewRenderer builds the renderer registered under name.
+func NewRenderer(name string, opts RendererOptions) (Renderer, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	renderersMu.RLock()
+	factory, ok := renderers[key]
+	renderersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output renderer %q (available: %s)", name, strings.Join(RendererNames(), ", "))
+	}
+	return factory(opts), nil
+}
+
+// compactRenderer is the default terminal view: the compact writer folds
+// subprocess output and events into one progress line per track.
+type compactRenderer struct {
+	writer  *CompactLogWriter
+	emitter EventEmitter
+}
+
+func newCompactRenderer(opts RendererOptions) Renderer {
+	writer := NewCompactLogWriterWithOptions(opts.Stdout, CompactLogOptions{
+		Interactive:      opts.Interactive,
+		PreflightSummary: opts.PreflightSummary,
+		TrackStatus:      opts.TrackStatus,
+		Color:            opts.Color,
+	})
+	return &compactRenderer{
+		writer:  writer,
+		emitter: NewObservingEmitter(writer, NewHumanEmitter(writer, opts.Stderr, opts.Quiet, opts.Verbose)),
+	}
+}
+
+func (r *compactRenderer) Emit(event Event) error {
+	return r.emitter.Emit(event)
+}
+
+func (r *compactRenderer) SubprocessOutput() (io.Writer, io.Writer) {
+	return r.writer, r.writer
+}
+
+// plainRenderer prints event messages as they come and passes subprocess
+// output through unchanged (dropped with --quiet).
+type plainRenderer struct {
+	*HumanEmitter
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func newPlainRenderer(opts RendererOptions) Renderer {
+	r := &plainRenderer{
+		HumanEmitter: NewHumanEmitter(opts.Stdout, opts.Stderr, opts.Quiet, opts.Verbose),
+		stdout:       opts.Stdout,
+		stderr:       opts.Stderr,
+	}
+	if opts.Quiet {
+		r.stdout, r.stderr = io.Discard, io.Discard
+	}
+	return r
+}
+
+func (r *plainRenderer) SubprocessOutput() (io.Writer, io.Writer) {
+	return r.stdout, r.stderr
+}
+
+// minimalRenderer is meant for CI logs: one line per source outcome plus
+// warnings and errors, no progress, no subprocess output.
+type minimalRenderer struct {
+	stdout io.Writer
+	stderr io.Writer
+	quiet  bool
+	mu     sync.Mutex
+}
+
+func newMinimalRenderer(opts RendererOptions) Renderer {
+	return &minimalRenderer{stdout: opts.Stdout, stderr: opts.Stderr, quiet: opts.Quiet}
+}
+
+func (r *minimalRenderer) Emit(event Event) error {
+	line := event.Message
+	if line == "" {
+		line = string(event.Event)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case event.Level == LevelError:
+		_, err := fmt.Fprintln(r.stderr, "ERROR:", line)
+		return err
+	case r.quiet && event.Event != EventSyncFinished:
+		return nil
+	case event.Level == LevelWarn:
+		_, err := fmt.Fprintln(r.stderr, "WARN:", line)
+		return err
+	}
+	switch event.Event {
+	case EventSourceFinished, EventSyncFinished:
+		_, err := fmt.Fprintln(r.stdout, line)
+		return err
+	}
+	return nil
+}
+
+func (r *minimalRenderer) SubprocessOutput() (io.Writer, io.Writer) {
+	return io.Discard, io.Discard
+}
+
+// jsonRenderer writes one JSON event per line to stdout and keeps
+// subprocess output on stderr, the same as --json.
+type jsonRenderer struct {
+	*JSONEmitter
+	stderr io.Writer
+}
+
+func newJSONRenderer(opts RendererOptions) Renderer {
+	return &jsonRenderer{JSONEmitter: NewJSONEmitter(opts.Stdout), stderr: opts.Stderr}
+}
+
+func (r *jsonRenderer) SubprocessOutput() (io.Writer, io.Writer) {
+	return r.stderr, r.stderr
+}
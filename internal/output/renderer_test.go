@@ -0,0 +1,81 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+type recordingRenderer struct {
+	events []Event
+}
+
+func (r *recordingRenderer) Emit(event Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingRenderer) SubprocessOutput() (io.Writer, io.Writer) {
+	return io.Discard, io.Discard
+}
+
+func TestRegisterRendererMakesItSelectable(t *testing.T) {
+	recorder := &recordingRenderer{}
+	if err := RegisterRenderer("Test-Recorder", func(opts RendererOptions) Renderer { return recorder }); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	t.Cleanup(func() {
+		renderersMu.Lock()
+		delete(renderers, "test-recorder")
+		renderersMu.Unlock()
+	})
+	if err := RegisterRenderer("test-recorder", func(opts RendererOptions) Renderer { return recorder }); err == nil {
+		t.Fatal("expected duplicate name to be rejected")
+	}
+	if err := RegisterRenderer(RendererCompact, func(opts RendererOptions) Renderer { return recorder }); err == nil {
+		t.Fatal("expected built-in name to be rejected")
+	}
+
+	renderer, err := NewRenderer("test-recorder", RendererOptions{})
+	if err != nil {
+		t.Fatalf("new renderer: %v", err)
+	}
+	_ = renderer.Emit(Event{Event: EventTrackDone, SourceID: "sc", Details: map[string]any{"track_id": "123"}})
+	if len(recorder.events) != 1 || recorder.events[0].Details["track_id"] != "123" {
+		t.Fatalf("expected the structured event to reach the renderer, got %+v", recorder.events)
+	}
+	if _, err := NewRenderer("missing", RendererOptions{}); err == nil || !strings.Contains(err.Error(), "available: compact, dashboard, json, minimal, plain, test-recorder") {
+		t.Fatalf("expected available renderer list, got %v", err)
+	}
+}
+
+func TestMinimalRendererPrintsOnlyOutcomes(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	renderer, err := NewRenderer(RendererMinimal, RendererOptions{Stdout: stdout, Stderr: stderr})
+	if err != nil {
+		t.Fatalf("new renderer: %v", err)
+	}
+	for _, event := range []Event{
+		{Level: LevelInfo, Event: EventSourcePreflight, Message: "[sc] preflight: planned=2"},
+		{Level: LevelInfo, Event: EventTrackDone, Message: "[sc] [done] 123"},
+		{Level: LevelInfo, Event: EventSourceFinished, Message: "[sc] done"},
+		{Level: LevelWarn, Event: EventSourcePreflight, Message: "[sc] slow"},
+		{Level: LevelError, Event: EventSourceFailed, Message: "[yt] failed"},
+		{Level: LevelInfo, Event: EventSyncFinished, Message: "sync finished"},
+	} {
+		if err := renderer.Emit(event); err != nil {
+			t.Fatalf("emit: %v", err)
+		}
+	}
+	if got := stdout.String(); got != "[sc] done\nsync finished\n" {
+		t.Fatalf("unexpected stdout %q", got)
+	}
+	if got := stderr.String(); got != "WARN: [sc] slow\nERROR: [yt] failed\n" {
+		t.Fatalf("unexpected stderr %q", got)
+	}
+	if out, errOut := renderer.SubprocessOutput(); out != io.Discard || errOut != io.Discard {
+		t.Fatal("expected subprocess output to be dropped")
+	}
+}
@@ -3,6 +3,7 @@ package output
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	compactstate "github.com/jaa/update-downloads/internal/output/compact"
 )
@@ -12,6 +13,11 @@ type StructuredTrackState struct {
 	ProgressKnown   bool
 	ProgressPercent float64
 	Lifecycle       compactstate.TrackLifecycle
+	// Stage is set by flows that report named steps (for example the
+	// free-download browser handoff) instead of a download percentage.
+	Stage        string
+	StageElapsed time.Duration
+	StageIdle    time.Duration
 }
 
 type StructuredTrackOutcomeKind string
@@ -23,6 +29,7 @@ const (
 )
 
 type StructuredTrackOutcome struct {
+	SourceID  string
 	Kind      StructuredTrackOutcomeKind
 	Name      string
 	Reason    string
@@ -36,9 +43,13 @@ type StructuredProgressSnapshot struct {
 	StructuredTrackEvents bool
 }
 
+// StructuredProgressTracker follows the active track of each source by
+// source id; Snapshot reports the source that emitted the latest event.
 type StructuredProgressTracker struct {
 	progress              *compactstate.StateMachine
 	track                 StructuredTrackState
+	activeSource          string
+	sourceTracks          map[string]StructuredTrackState
 	structuredTrackEvents bool
 	pendingOutcomes       []StructuredTrackOutcome
 }
@@ -61,10 +72,34 @@ func (t *StructuredProgressTracker) Reset() {
 	}
 	t.progress.Reset()
 	t.track = StructuredTrackState{Lifecycle: compactstate.TrackLifecycleIdle}
+	t.activeSource = ""
+	t.sourceTracks = map[string]StructuredTrackState{}
 	t.structuredTrackEvents = false
 	t.pendingOutcomes = nil
 }
 
+// selectSource swaps in the track state of sourceID, saving the previously
+// active source's track so interleaved events do not clobber each other.
+func (t *StructuredProgressTracker) selectSource(sourceID string) {
+	sourceID = strings.TrimSpace(sourceID)
+	if sourceID == "" || sourceID == t.activeSource {
+		return
+	}
+	if t.sourceTracks == nil {
+		t.sourceTracks = map[string]StructuredTrackState{}
+	}
+	if t.activeSource != "" {
+		t.sourceTracks[t.activeSource] = t.track
+	}
+	track, ok := t.sourceTracks[sourceID]
+	if !ok {
+		track = StructuredTrackState{Lifecycle: compactstate.TrackLifecycleIdle}
+	}
+	t.track = track
+	t.activeSource = sourceID
+	t.progress.Select(sourceID)
+}
+
 func (t *StructuredProgressTracker) ObserveEvent(event Event) {
 	if t == nil {
 		return
@@ -73,6 +108,10 @@ func (t *StructuredProgressTracker) ObserveEvent(event Event) {
 		t.progress = compactstate.NewStateMachine()
 	}
 
+	if event.Event != EventSyncStarted {
+		t.selectSource(event.SourceID)
+	}
+
 	switch event.Event {
 	case EventSyncStarted:
 		t.Reset()
@@ -117,6 +156,11 @@ func (t *StructuredProgressTracker) ObserveEvent(event Event) {
 			t.track.ProgressKnown = true
 			t.track.ProgressPercent = compactstate.ClampPercent(percent)
 		}
+		if stage := strings.TrimSpace(eventDetailString(event.Details, "stage")); stage != "" {
+			t.track.Stage = stage
+			t.track.StageElapsed = eventDetailMillis(event.Details, "elapsed_ms")
+			t.track.StageIdle = eventDetailMillis(event.Details, "idle_ms")
+		}
 		if t.track.Lifecycle == compactstate.TrackLifecycleIdle {
 			t.track.Lifecycle = compactstate.TrackLifecycleDownloading
 		}
@@ -126,6 +170,7 @@ func (t *StructuredProgressTracker) ObserveEvent(event Event) {
 		name := t.resolveTrackName(event, true)
 		t.progress.CompleteTrack()
 		t.pendingOutcomes = append(t.pendingOutcomes, StructuredTrackOutcome{
+			SourceID:  t.activeSource,
 			Kind:      StructuredTrackOutcomeDone,
 			Name:      name,
 			Completed: t.progress.Completed(),
@@ -138,6 +183,7 @@ func (t *StructuredProgressTracker) ObserveEvent(event Event) {
 		name := t.resolveTrackName(event, true)
 		t.progress.CompleteTrack()
 		t.pendingOutcomes = append(t.pendingOutcomes, StructuredTrackOutcome{
+			SourceID:  t.activeSource,
 			Kind:      StructuredTrackOutcomeSkip,
 			Name:      name,
 			Reason:    strings.TrimSpace(eventDetailString(event.Details, "reason")),
@@ -151,6 +197,7 @@ func (t *StructuredProgressTracker) ObserveEvent(event Event) {
 		name := t.resolveTrackName(event, true)
 		t.progress.CompleteTrack()
 		t.pendingOutcomes = append(t.pendingOutcomes, StructuredTrackOutcome{
+			SourceID:  t.activeSource,
 			Kind:      StructuredTrackOutcomeFail,
 			Name:      name,
 			Reason:    strings.TrimSpace(eventDetailString(event.Details, "reason")),
@@ -241,6 +288,31 @@ func (t *StructuredProgressTracker) updateItemPosition(event Event) (int, int) {
 	return t.progress.CurrentIndex(), t.progress.EffectiveTotal()
 }
 
+// FormatStructuredTrackStage renders a named track stage with its timers, e.g.
+// "waiting-for-browser 1m5s, idle 20s". It returns "" when no stage is set.
+func FormatStructuredTrackStage(track StructuredTrackState) string {
+	stage := strings.TrimSpace(track.Stage)
+	if stage == "" {
+		return ""
+	}
+	label := strings.ReplaceAll(stage, "_", "-")
+	if track.StageElapsed >= time.Second {
+		label = fmt.Sprintf("%s %s", label, track.StageElapsed.Truncate(time.Second))
+	}
+	if track.StageIdle >= time.Second {
+		label = fmt.Sprintf("%s, idle %s", label, track.StageIdle.Truncate(time.Second))
+	}
+	return label
+}
+
+func eventDetailMillis(details map[string]any, key string) time.Duration {
+	millis, ok := eventDetailInt(details, key)
+	if !ok || millis <= 0 {
+		return 0
+	}
+	return time.Duration(millis) * time.Millisecond
+}
+
 func FormatCompactTrackOutcome(outcome StructuredTrackOutcome, mode string) string {
 	mode = strings.TrimSpace(strings.ToLower(mode))
 	switch mode {
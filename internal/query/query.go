@@ -0,0 +1,692 @@
+// Package query parses and runs the `udl query` language: a collection name
+// followed by optional where, select, order by, and limit clauses, e.g.
+//
+//	tracks where source == "label-x" and added_after("2025-01-01") select id, title order by added_at desc limit 20
+//
+// Records are flat maps of field name to a string, int64, float64, bool,
+// time.Time, or nil value. Comparisons between a time field and a string
+// parse the string as a date.
+package query
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one row of a collection.
+type Record map[string]any
+
+// Schema maps each collection name to its fields in output order.
+type Schema map[string][]string
+
+// Query is a parsed query, bound to the fields of its collection.
+type Query struct {
+	Collection string
+	Columns    []string
+	OrderBy    string
+	Descending bool
+	// Limit caps the number of rows returned; 0 returns all of them.
+	Limit int
+
+	where node
+	raw   string
+}
+
+// Result is the filtered, ordered, and projected rows of a query.
+type Result struct {
+	Columns []string `json:"columns"`
+	Rows    []Record `json:"rows"`
+}
+
+func (q Query) String() string {
+	return q.raw
+}
+
+// Run filters, orders, limits, and projects records, which must belong to
+// q.Collection.
+func (q Query) Run(records []Record) Result {
+	rows := make([]Record, 0, len(records))
+	for _, record := range records {
+		if q.where == nil || q.where.eval(record) {
+			rows = append(rows, record)
+		}
+	}
+	if q.OrderBy != "" {
+		sort.SliceStable(rows, func(i, j int) bool {
+			left, right := rows[i][q.OrderBy], rows[j][q.OrderBy]
+			// Rows missing the field sort last in both directions.
+			if left == nil || right == nil {
+				return left != nil && right == nil
+			}
+			cmp, ok := compareValues(left, right)
+			if !ok {
+				return false
+			}
+			if q.Descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		})
+	}
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+	projected := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		out := make(Record, len(q.Columns))
+		for _, column := range q.Columns {
+			out[column] = row[column]
+		}
+		projected = append(projected, out)
+	}
+	return Result{Columns: append([]string(nil), q.Columns...), Rows: projected}
+}
+
+// Parse parses raw against schema. Unknown collections, fields, and
+// functions are reported here rather than when the query runs.
+func Parse(raw string, schema Schema) (Query, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return Query{}, fmt.Errorf("query is empty")
+	}
+	tokens, err := tokenize(value)
+	if err != nil {
+		return Query{}, err
+	}
+	p := &parser{tokens: tokens}
+	collectionTok := p.next()
+	if collectionTok.kind != tokenIdent {
+		return Query{}, fmt.Errorf("expected collection name at offset %d, got %q", collectionTok.pos, collectionTok.text)
+	}
+	collection := strings.ToLower(collectionTok.text)
+	fields, ok := schema[collection]
+	if !ok {
+		return Query{}, fmt.Errorf("unknown collection %q (expected one of %s)", collectionTok.text, strings.Join(sortedKeys(schema), ", "))
+	}
+	p.fields = map[string]struct{}{}
+	for _, field := range fields {
+		p.fields[field] = struct{}{}
+	}
+	p.fieldList = fields
+
+	q := Query{Collection: collection, Columns: append([]string(nil), fields...), raw: value}
+	seen := map[string]bool{}
+	for p.peek().kind != tokenEOF {
+		tok := p.next()
+		clause := strings.ToLower(tok.text)
+		if tok.kind != tokenIdent || (clause != "where" && clause != "select" && clause != "order" && clause != "limit") {
+			return Query{}, fmt.Errorf("expected where, select, order by, or limit at offset %d, got %q", tok.pos, tok.text)
+		}
+		if seen[clause] {
+			return Query{}, fmt.Errorf("duplicate %s clause at offset %d", clause, tok.pos)
+		}
+		seen[clause] = true
+		switch clause {
+		case "where":
+			if q.where, err = p.parseOr(); err != nil {
+				return Query{}, err
+			}
+		case "select":
+			if q.Columns, err = p.parseFieldList(); err != nil {
+				return Query{}, err
+			}
+		case "order":
+			if by := p.next(); !isKeyword(by, "by") {
+				return Query{}, fmt.Errorf("expected by after order at offset %d, got %q", by.pos, by.text)
+			}
+			if q.OrderBy, err = p.parseField(); err != nil {
+				return Query{}, err
+			}
+			if next := p.peek(); isKeyword(next, "desc") || isKeyword(next, "asc") {
+				p.next()
+				q.Descending = isKeyword(next, "desc")
+			}
+		case "limit":
+			tok := p.next()
+			limit, convErr := strconv.Atoi(tok.text)
+			if tok.kind != tokenNumber || convErr != nil || limit < 0 {
+				return Query{}, fmt.Errorf("expected a non-negative integer after limit at offset %d, got %q", tok.pos, tok.text)
+			}
+			q.Limit = limit
+		}
+	}
+	return q, nil
+}
+
+type node interface {
+	eval(record Record) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(record Record) bool { return n.left.eval(record) && n.right.eval(record) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(record Record) bool { return n.left.eval(record) || n.right.eval(record) }
+
+type notNode struct{ inner node }
+
+func (n notNode) eval(record Record) bool { return !n.inner.eval(record) }
+
+// operand is a field reference or a literal.
+type operand struct {
+	field   string
+	literal any
+}
+
+func (o operand) resolve(record Record) any {
+	if o.field != "" {
+		return record[o.field]
+	}
+	return o.literal
+}
+
+type compareNode struct {
+	op          string
+	left, right operand
+}
+
+func (n compareNode) eval(record Record) bool {
+	left, right := n.left.resolve(record), n.right.resolve(record)
+	if left == nil || right == nil {
+		switch n.op {
+		case "==":
+			return left == nil && right == nil
+		case "!=":
+			return (left == nil) != (right == nil)
+		default:
+			return false
+		}
+	}
+	cmp, ok := compareValues(left, right)
+	if !ok {
+		return n.op == "!="
+	}
+	switch n.op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// truthyNode is a bare field used as a condition: true for true, non-zero
+// numbers, non-empty strings, and set times.
+type truthyNode struct{ field string }
+
+func (n truthyNode) eval(record Record) bool {
+	switch value := record[n.field].(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case string:
+		return value != ""
+	case time.Time:
+		return !value.IsZero()
+	default:
+		number, ok := toFloat(value)
+		return ok && number != 0
+	}
+}
+
+type stringFuncNode struct {
+	field  string
+	needle string
+	match  func(haystack, needle string) bool
+}
+
+func (n stringFuncNode) eval(record Record) bool {
+	value, ok := record[n.field].(string)
+	return ok && n.match(strings.ToLower(value), n.needle)
+}
+
+type timeFuncNode struct {
+	field  string
+	at     time.Time
+	before bool
+}
+
+func (n timeFuncNode) eval(record Record) bool {
+	value, ok := record[n.field].(time.Time)
+	if !ok || value.IsZero() {
+		return false
+	}
+	if n.before {
+		return value.Before(n.at)
+	}
+	return value.After(n.at)
+}
+
+// compareValues orders two non-nil values of compatible types. ok is false
+// when they cannot be compared, e.g. a string with a number.
+func compareValues(left any, right any) (int, bool) {
+	if leftNumber, ok := toFloat(left); ok {
+		rightNumber, ok := toFloat(right)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(leftNumber, rightNumber), true
+	}
+	switch l := left.(type) {
+	case string:
+		switch r := right.(type) {
+		case string:
+			return strings.Compare(l, r), true
+		case time.Time:
+			parsed, err := parseTime(l)
+			if err != nil {
+				return 0, false
+			}
+			return parsed.Compare(r), true
+		}
+	case time.Time:
+		switch r := right.(type) {
+		case time.Time:
+			return l.Compare(r), true
+		case string:
+			parsed, err := parseTime(r)
+			if err != nil {
+				return 0, false
+			}
+			return l.Compare(parsed), true
+		}
+	case bool:
+		if r, ok := right.(bool); ok {
+			switch {
+			case l == r:
+				return 0, true
+			case !l:
+				return -1, true
+			default:
+				return 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func compareOrdered(left float64, right float64) int {
+	switch {
+	case left < right:
+		return -1
+	case left > right:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, !math.IsNaN(v)
+	default:
+		return 0, false
+	}
+}
+
+// parseTime accepts RFC 3339 timestamps and dates; dates and timestamps
+// without a zone are local time.
+func parseTime(raw string) (time.Time, error) {
+	value := strings.TrimSpace(raw)
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (expected YYYY-MM-DD or RFC 3339)", raw)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenCompare
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(value string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(value); {
+		c := value[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == ',':
+			kind := tokenLParen
+			switch c {
+			case ')':
+				kind = tokenRParen
+			case ',':
+				kind = tokenComma
+			}
+			tokens = append(tokens, token{kind: kind, text: string(c), pos: i})
+			i++
+		case strings.HasPrefix(value[i:], "&&"):
+			tokens = append(tokens, token{kind: tokenAnd, text: "&&", pos: i})
+			i += 2
+		case strings.HasPrefix(value[i:], "||"):
+			tokens = append(tokens, token{kind: tokenOr, text: "||", pos: i})
+			i += 2
+		case c == '=' || c == '!' || c == '<' || c == '>':
+			if i+1 < len(value) && value[i+1] == '=' {
+				tokens = append(tokens, token{kind: tokenCompare, text: value[i : i+2], pos: i})
+				i += 2
+				continue
+			}
+			switch c {
+			case '!':
+				tokens = append(tokens, token{kind: tokenNot, text: "!", pos: i})
+			case '<', '>':
+				tokens = append(tokens, token{kind: tokenCompare, text: string(c), pos: i})
+			default:
+				return nil, fmt.Errorf("unexpected %q at offset %d (use == for equality)", string(c), i)
+			}
+			i++
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			closed := false
+			for i < len(value) {
+				if value[i] == '\\' && i+1 < len(value) {
+					b.WriteByte(value[i+1])
+					i += 2
+					continue
+				}
+				if value[i] == c {
+					closed = true
+					i++
+					break
+				}
+				b.WriteByte(value[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string at offset %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+		case (c >= '0' && c <= '9') || (c == '-' && i+1 < len(value) && value[i+1] >= '0' && value[i+1] <= '9'):
+			start := i
+			i++
+			for i < len(value) && ((value[i] >= '0' && value[i] <= '9') || value[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: value[start:i], pos: start})
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			start := i
+			for i < len(value) && (value[i] == '_' || (value[i] >= 'a' && value[i] <= 'z') || (value[i] >= 'A' && value[i] <= 'Z') || (value[i] >= '0' && value[i] <= '9')) {
+				i++
+			}
+			text := value[start:i]
+			kind := tokenIdent
+			switch strings.ToLower(text) {
+			case "and":
+				kind = tokenAnd
+			case "or":
+				kind = tokenOr
+			case "not":
+				kind = tokenNot
+			}
+			tokens = append(tokens, token{kind: kind, text: text, pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at offset %d", string(c), i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, text: "end of query", pos: len(value)}), nil
+}
+
+func isKeyword(tok token, keyword string) bool {
+	return tok.kind == tokenIdent && strings.EqualFold(tok.text, keyword)
+}
+
+type parser struct {
+	tokens    []token
+	pos       int
+	fields    map[string]struct{}
+	fieldList []string
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokenAnd {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	switch p.peek().kind {
+	case tokenNot:
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner: inner}, nil
+	case tokenLParen:
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ) at offset %d, got %q", tok.pos, tok.text)
+		}
+		return inner, nil
+	}
+	if p.peek().kind == tokenIdent && p.tokens[p.pos+1].kind == tokenLParen {
+		return p.parseCall()
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenCompare {
+		if left.field == "" {
+			return nil, fmt.Errorf("expected comparison operator at offset %d, got %q", p.peek().pos, p.peek().text)
+		}
+		return truthyNode{field: left.field}, nil
+	}
+	op := p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return compareNode{op: op.text, left: left, right: right}, nil
+}
+
+// parseCall parses contains(field, "text"), starts_with(field, "text"), and
+// <name>_after("date") / <name>_before("date"), which test the <name>_at
+// field.
+func (p *parser) parseCall() (node, error) {
+	nameTok := p.next()
+	p.next() // (
+	name := strings.ToLower(nameTok.text)
+	var result node
+	switch name {
+	case "contains", "starts_with":
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != tokenComma {
+			return nil, fmt.Errorf("expected , at offset %d, got %q", tok.pos, tok.text)
+		}
+		needle := p.next()
+		if needle.kind != tokenString {
+			return nil, fmt.Errorf("%s expects a string at offset %d, got %q", name, needle.pos, needle.text)
+		}
+		match := strings.Contains
+		if name == "starts_with" {
+			match = strings.HasPrefix
+		}
+		result = stringFuncNode{field: field, needle: strings.ToLower(needle.text), match: match}
+	default:
+		prefix, before := strings.CutSuffix(name, "_before")
+		if !before {
+			var after bool
+			if prefix, after = strings.CutSuffix(name, "_after"); !after {
+				return nil, fmt.Errorf("unknown function %q at offset %d (expected contains, starts_with, <field>_after, or <field>_before)", nameTok.text, nameTok.pos)
+			}
+		}
+		field := prefix + "_at"
+		if _, ok := p.fields[field]; !ok {
+			return nil, fmt.Errorf("unknown function %q at offset %d (no %s field)", nameTok.text, nameTok.pos, field)
+		}
+		dateTok := p.next()
+		if dateTok.kind != tokenString {
+			return nil, fmt.Errorf("%s expects a date string at offset %d, got %q", name, dateTok.pos, dateTok.text)
+		}
+		at, err := parseTime(dateTok.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s at offset %d: %w", name, dateTok.pos, err)
+		}
+		result = timeFuncNode{field: field, at: at, before: before}
+	}
+	if tok := p.next(); tok.kind != tokenRParen {
+		return nil, fmt.Errorf("expected ) at offset %d, got %q", tok.pos, tok.text)
+	}
+	return result, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenString:
+		return operand{literal: tok.text}, nil
+	case tokenNumber:
+		if value, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return operand{literal: value}, nil
+		}
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("invalid number %q at offset %d", tok.text, tok.pos)
+		}
+		return operand{literal: value}, nil
+	case tokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return operand{literal: true}, nil
+		case "false":
+			return operand{literal: false}, nil
+		case "null":
+			return operand{}, nil
+		}
+		p.pos--
+		field, err := p.parseField()
+		if err != nil {
+			return operand{}, err
+		}
+		return operand{field: field}, nil
+	default:
+		return operand{}, fmt.Errorf("expected field or value at offset %d, got %q", tok.pos, tok.text)
+	}
+}
+
+func (p *parser) parseField() (string, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return "", fmt.Errorf("expected field at offset %d, got %q", tok.pos, tok.text)
+	}
+	name := strings.ToLower(tok.text)
+	if _, ok := p.fields[name]; !ok {
+		return "", fmt.Errorf("unknown field %q at offset %d (expected one of %s)", tok.text, tok.pos, strings.Join(p.fieldList, ", "))
+	}
+	return name, nil
+}
+
+func (p *parser) parseFieldList() ([]string, error) {
+	fields := []string{}
+	for {
+		field, err := p.parseField()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		if p.peek().kind != tokenComma {
+			return fields, nil
+		}
+		p.next()
+	}
+}
+
+func sortedKeys(schema Schema) []string {
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,94 @@
+package query
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSchema = Schema{
+	"tracks": {"source", "id", "title", "present", "added_at", "plays"},
+}
+
+func testRecords() []Record {
+	return []Record{
+		{"source": "label-x", "id": "1", "title": "Night Drive", "present": true, "added_at": time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC), "plays": int64(4)},
+		{"source": "label-x", "id": "2", "title": "Morning Mix", "present": false, "added_at": time.Date(2024, 11, 5, 12, 0, 0, 0, time.UTC), "plays": int64(9)},
+		{"source": "other", "id": "3", "title": "Night Bus", "present": true, "added_at": nil, "plays": int64(1)},
+	}
+}
+
+func TestRunFiltersRecords(t *testing.T) {
+	cases := map[string][]string{
+		`tracks where source == "label-x" and added_after("2025-01-01")`:  {"1"},
+		`tracks where source == "label-x" && added_before("2025-01-01")`:  {"2"},
+		`tracks where contains(title, "night") or plays >= 9`:             {"1", "2", "3"},
+		`tracks where not present`:                                        {"2"},
+		`tracks where added_at == null`:                                   {"3"},
+		`tracks where added_at > "2024-12-31" || !(plays > 1)`:            {"1", "3"},
+		`tracks where starts_with(title, "Morning") and present == false`: {"2"},
+		`TRACKS WHERE source != "other"`:                                  {"1", "2"},
+	}
+	for raw, want := range cases {
+		q, err := Parse(raw, testSchema)
+		if err != nil {
+			t.Fatalf("parse %q: %v", raw, err)
+		}
+		got := []string{}
+		for _, row := range q.Run(testRecords()).Rows {
+			got = append(got, row["id"].(string))
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("%q: expected ids %v, got %v", raw, want, got)
+		}
+	}
+}
+
+func TestRunSelectsOrdersAndLimits(t *testing.T) {
+	q, err := Parse(`tracks select id, title order by added_at desc limit 2`, testSchema)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	result := q.Run(testRecords())
+	if strings.Join(result.Columns, ",") != "id,title" {
+		t.Fatalf("unexpected columns: %v", result.Columns)
+	}
+	if len(result.Rows) != 2 || result.Rows[0]["id"] != "1" || result.Rows[1]["id"] != "2" {
+		t.Fatalf("unexpected rows: %v", result.Rows)
+	}
+	if _, ok := result.Rows[0]["source"]; ok {
+		t.Fatalf("expected unselected fields to be dropped, got %v", result.Rows[0])
+	}
+
+	q, err = Parse(`tracks order by added_at`, testSchema)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	rows := q.Run(testRecords()).Rows
+	if rows[0]["id"] != "2" || rows[2]["id"] != "3" {
+		t.Fatalf("expected ascending order with missing values last, got %v", rows)
+	}
+}
+
+func TestParseRejectsInvalidQueries(t *testing.T) {
+	for raw, want := range map[string]string{
+		"":                                    "empty",
+		"albums":                              "unknown collection",
+		"tracks where artist == 1":            "unknown field",
+		`tracks where title = "x"`:            "use ==",
+		`tracks where released_after("2025")`: "no released_at field",
+		`tracks where added_after("soon")`:    "invalid date",
+		`tracks where (plays > 1`:             "expected )",
+		`tracks where "x"`:                    "comparison operator",
+		"tracks limit -1":                     "non-negative integer",
+		"tracks limit 1 limit 2":              "duplicate limit",
+		"tracks order added_at":               "expected by",
+		`tracks where title == "unterminated`: "unterminated string",
+		"tracks group by source":              "expected where, select",
+	} {
+		_, err := Parse(raw, testSchema)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error containing %q, got %v", raw, want, err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+// Package rpc serves newline-delimited JSON-RPC 2.0 over a byte stream so
+// scripts can drive udl without parsing its human output.
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const Version = "2.0"
+
+// Standard JSON-RPC 2.0 error codes.
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error is a JSON-RPC error object. Handlers return it to choose the code;
+// any other error is reported as CodeInternalError.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+// InvalidParams reports a params problem to the client.
+func InvalidParams(format string, args ...any) *Error {
+	return &Error{Code: CodeInvalidParams, Message: fmt.Sprintf(format, args...)}
+}
+
+// Notifier sends a notification (a message without an id) to the client.
+type Notifier func(method string, params any) error
+
+// Handler serves one method. params is nil when the request has none.
+type Handler func(ctx context.Context, params json.RawMessage, notify Notifier) (any, error)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
+// Server dispatches one request per input line, in order. A request runs to
+// completion before the next one is handled; notifications it sends are
+// written before its response.
+type Server struct {
+	handlers map[string]Handler
+}
+
+func NewServer() *Server {
+	return &Server{handlers: map[string]Handler{}}
+}
+
+func (s *Server) Register(method string, handler Handler) {
+	s.handlers[method] = handler
+}
+
+// Serve reads requests from in until EOF or ctx is done and writes responses
+// and notifications to out, one JSON object per line.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	writer := &lineWriter{enc: json.NewEncoder(out)}
+	writer.enc.SetEscapeHTML(false)
+	notify := func(method string, params any) error {
+		return writer.write(notification{JSONRPC: Version, Method: method, Params: params})
+	}
+
+	// Read on a separate goroutine so a canceled ctx ends Serve even while
+	// the client keeps stdin open.
+	lines := make(chan []byte)
+	readErrs := make(chan error, 1)
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				readErrs <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-readErrs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case line := <-lines:
+			if resp, ok := s.handle(ctx, bytes.TrimSpace(line), notify); ok {
+				if err := writer.write(resp); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, line []byte, notify Notifier) (response, bool) {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		return errorResponse(nil, &Error{Code: CodeParseError, Message: err.Error()}), true
+	}
+	isNotification := len(req.ID) == 0
+	if req.JSONRPC != Version || req.Method == "" {
+		return errorResponse(req.ID, &Error{Code: CodeInvalidRequest, Message: `expected "jsonrpc": "2.0" and a method`}), true
+	}
+	handler, ok := s.handlers[req.Method]
+	if !ok {
+		if isNotification {
+			return response{}, false
+		}
+		return errorResponse(req.ID, &Error{Code: CodeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}), true
+	}
+
+	result, err := handler(ctx, req.Params, notify)
+	if isNotification {
+		return response{}, false
+	}
+	if err != nil {
+		var rpcErr *Error
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr), true
+	}
+	if result == nil {
+		result = struct{}{}
+	}
+	return response{JSONRPC: Version, ID: req.ID, Result: result}, true
+}
+
+func errorResponse(id json.RawMessage, err *Error) response {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return response{JSONRPC: Version, ID: id, Error: err}
+}
+
+// lineWriter serializes writes so notifications from concurrent emitters do
+// not interleave with each other or with responses.
+type lineWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func (w *lineWriter) write(value any) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.enc.Encode(value)
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestServeDispatchesRequestsAndNotifications(t *testing.T) {
+	server := NewServer()
+	server.Register("echo", func(ctx context.Context, params json.RawMessage, notify Notifier) (any, error) {
+		if err := notify("progress", map[string]int{"step": 1}); err != nil {
+			return nil, err
+		}
+		return map[string]json.RawMessage{"params": params}, nil
+	})
+	server.Register("fail", func(ctx context.Context, params json.RawMessage, notify Notifier) (any, error) {
+		if len(params) == 0 {
+			return nil, InvalidParams("name is required")
+		}
+		return nil, errors.New("boom")
+	})
+
+	in := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"echo","params":{"a":1}}`,
+		`{"jsonrpc":"2.0","id":"two","method":"fail"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"fail","params":{}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"missing"}`,
+		`{"jsonrpc":"2.0","method":"echo"}`,
+		`not json`,
+		``,
+		`{"id":5,"method":"echo"}`,
+	}, "\n")
+	out := &bytes.Buffer{}
+	if err := server.Serve(context.Background(), strings.NewReader(in), out); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+
+	want := []string{
+		`{"jsonrpc":"2.0","method":"progress","params":{"step":1}}`,
+		`{"jsonrpc":"2.0","id":1,"result":{"params":{"a":1}}}`,
+		`{"jsonrpc":"2.0","id":"two","error":{"code":-32602,"message":"name is required"}}`,
+		`{"jsonrpc":"2.0","id":3,"error":{"code":-32603,"message":"boom"}}`,
+		`{"jsonrpc":"2.0","id":4,"error":{"code":-32601,"message":"method \"missing\" not found"}}`,
+		`{"jsonrpc":"2.0","method":"progress","params":{"step":1}}`,
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"invalid character 'o' in literal null (expecting 'u')"}}`,
+		`{"jsonrpc":"2.0","id":5,"error":{"code":-32600,"message":"expected \"jsonrpc\": \"2.0\" and a method"}}`,
+	}
+	got := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestServeStopsWhenContextIsCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	if err := NewServer().Serve(ctx, reader, &bytes.Buffer{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled serve, got %v", err)
+	}
+}
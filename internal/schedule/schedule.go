@@ -0,0 +1,212 @@
+// Package schedule parses the sync.schedule values used by `udl watch`:
+// Go durations ("30m", "6h"), "@every <duration>", the @hourly/@daily/@weekly
+// shorthands, and standard five-field cron expressions in local time.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MinInterval keeps schedules from hammering remote services.
+const MinInterval = time.Minute
+
+type Schedule interface {
+	// Next returns the first run time strictly after after.
+	Next(after time.Time) time.Time
+	String() string
+}
+
+type interval struct {
+	every time.Duration
+	raw   string
+}
+
+func (s interval) Next(after time.Time) time.Time {
+	return after.Add(s.every)
+}
+
+func (s interval) String() string {
+	return s.raw
+}
+
+// Every returns an interval schedule.
+func Every(d time.Duration) Schedule {
+	return interval{every: d, raw: "@every " + d.String()}
+}
+
+func Parse(raw string) (Schedule, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" {
+		return nil, fmt.Errorf("schedule is empty")
+	}
+	switch strings.ToLower(value) {
+	case "@hourly":
+		return Parse("0 * * * *")
+	case "@daily", "@midnight":
+		return Parse("0 0 * * *")
+	case "@weekly":
+		return Parse("0 0 * * 0")
+	}
+	if rest, ok := strings.CutPrefix(strings.ToLower(value), "@every "); ok {
+		return parseInterval(strings.TrimSpace(rest), value)
+	}
+	if strings.HasPrefix(value, "@") {
+		return nil, fmt.Errorf("unknown schedule shorthand %q", value)
+	}
+	if fields := strings.Fields(value); len(fields) == 5 {
+		return parseCron(fields, value)
+	}
+	return parseInterval(value, value)
+}
+
+func parseInterval(rawDuration string, raw string) (Schedule, error) {
+	d, err := time.ParseDuration(rawDuration)
+	if err != nil {
+		return nil, fmt.Errorf("schedule %q must be a duration, @every <duration>, or a 5-field cron expression", raw)
+	}
+	if d < MinInterval {
+		return nil, fmt.Errorf("schedule %q must be at least %s", raw, MinInterval)
+	}
+	return interval{every: d, raw: raw}, nil
+}
+
+// cron matches minute, hour, day-of-month, month, and day-of-week sets. As in
+// classic cron, when both day fields are restricted a day matches either one.
+type cron struct {
+	minute, hour, dom, month, dow fieldSet
+	domAny, dowAny                bool
+	raw                           string
+}
+
+type fieldSet map[int]struct{}
+
+func (s fieldSet) has(v int) bool {
+	_, ok := s[v]
+	return ok
+}
+
+func parseCron(fields []string, raw string) (Schedule, error) {
+	specs := []struct {
+		name     string
+		min, max int
+	}{
+		{"minute", 0, 59},
+		{"hour", 0, 23},
+		{"day-of-month", 1, 31},
+		{"month", 1, 12},
+		{"day-of-week", 0, 7},
+	}
+	sets := make([]fieldSet, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, specs[i].min, specs[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q %s: %w", raw, specs[i].name, err)
+		}
+		sets[i] = set
+	}
+	if sets[4].has(7) {
+		sets[4][0] = struct{}{}
+	}
+	return cron{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+		raw:    raw,
+	}, nil
+}
+
+func parseCronField(field string, min int, max int) (fieldSet, error) {
+	set := fieldSet{}
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			parsed, err := strconv.Atoi(stepPart)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = parsed
+		}
+		lo, hi := min, max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			from, to, _ := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("invalid value %q", from)
+			}
+			if hi, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid value %q", to)
+			}
+		default:
+			value, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", rangePart)
+			}
+			lo = value
+			if !hasStep {
+				hi = value
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("value %q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = struct{}{}
+		}
+	}
+	return set, nil
+}
+
+func (s cron) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	// Five years covers every satisfiable expression (including Feb 29).
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !s.month.has(int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hour.has(t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minute.has(t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s cron) dayMatches(t time.Time) bool {
+	domMatch := s.dom.has(t.Day())
+	dowMatch := s.dow.has(int(t.Weekday()))
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowMatch
+	case s.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+func (s cron) String() string {
+	return s.raw
+}
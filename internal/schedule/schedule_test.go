@@ -0,0 +1,56 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseIntervalSchedules(t *testing.T) {
+	base := time.Date(2026, 3, 1, 10, 17, 0, 0, time.UTC)
+	for raw, want := range map[string]time.Duration{
+		"30m":        30 * time.Minute,
+		"@every 6h":  6 * time.Hour,
+		"@EVERY 90m": 90 * time.Minute,
+	} {
+		parsed, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("parse %q: %v", raw, err)
+		}
+		if got := parsed.Next(base); !got.Equal(base.Add(want)) {
+			t.Fatalf("%q: expected %s, got %s", raw, base.Add(want), got)
+		}
+	}
+	for _, raw := range []string{"", "10s", "@every soon", "@yearly", "* * *"} {
+		if _, err := Parse(raw); err == nil {
+			t.Fatalf("expected %q to be rejected", raw)
+		}
+	}
+}
+
+func TestParseCronSchedules(t *testing.T) {
+	base := time.Date(2026, 3, 1, 10, 17, 30, 0, time.UTC) // a Sunday
+	cases := map[string]time.Time{
+		"*/15 * * * *":  time.Date(2026, 3, 1, 10, 30, 0, 0, time.UTC),
+		"0 3 * * *":     time.Date(2026, 3, 2, 3, 0, 0, 0, time.UTC),
+		"@hourly":       time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC),
+		"30 9 * * 1-5":  time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC),
+		"0 0 29 2 *":    time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		"0 12 15 * 7":   time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		"5,45 10 * * *": time.Date(2026, 3, 1, 10, 45, 0, 0, time.UTC),
+	}
+	for raw, want := range cases {
+		parsed, err := Parse(raw)
+		if err != nil {
+			t.Fatalf("parse %q: %v", raw, err)
+		}
+		if got := parsed.Next(base); !got.Equal(want) {
+			t.Fatalf("%q: expected %s, got %s", raw, want, got)
+		}
+	}
+
+	_, err := Parse("61 * * * *")
+	if err == nil || !strings.Contains(err.Error(), "minute") {
+		t.Fatalf("expected minute range error, got %v", err)
+	}
+}
@@ -0,0 +1,401 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jaa/update-downloads/internal/config"
+	"github.com/jaa/update-downloads/internal/fileops"
+)
+
+const (
+	managedToolsDirName  = "tools"
+	managedBinDirName    = "bin"
+	ffmpegManifestName   = "ffmpeg.json"
+	maxFFmpegArchiveSize = 512 << 20
+)
+
+// FFmpegBinaries are the executables udl extracts from an ffmpeg archive.
+var FFmpegBinaries = []string{"ffmpeg", "ffprobe"}
+
+var (
+	httpDownloadClient = &http.Client{Timeout: 10 * time.Minute}
+	nowFn              = time.Now
+)
+
+// InstallFFmpegOptions describes where to fetch an ffmpeg archive from. Exactly
+// one of URL or ArchivePath is set; SHA256 is always required because the
+// extracted binaries are executed by later commands.
+type InstallFFmpegOptions struct {
+	StateDir    string
+	URL         string
+	ArchivePath string
+	SHA256      string
+	Force       bool
+}
+
+// InstallFFmpegResult reports what an install placed under the managed dir.
+type InstallFFmpegResult struct {
+	BinDir    string   `json:"bin_dir"`
+	Installed []string `json:"installed"`
+	Skipped   []string `json:"skipped,omitempty"`
+	Source    string   `json:"source"`
+	SHA256    string   `json:"sha256"`
+}
+
+type ffmpegManifest struct {
+	Source      string    `json:"source"`
+	SHA256      string    `json:"sha256"`
+	Binaries    []string  `json:"binaries"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// ManagedBinDir returns <state_dir>/tools/bin, where udl-managed helper
+// binaries are installed.
+func ManagedBinDir(stateDir string) (string, error) {
+	root, err := config.ExpandPath(stateDir)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(root) == "" {
+		return "", fmt.Errorf("state_dir is empty")
+	}
+	return filepath.Join(root, managedToolsDirName, managedBinDirName), nil
+}
+
+// ActivateManagedBinDir appends the managed bin dir to PATH when it exists so
+// udl and the adapters it launches can find ffmpeg/ffprobe. It is appended,
+// not prepended, so a system install always wins.
+func ActivateManagedBinDir(stateDir string) {
+	binDir, err := ManagedBinDir(stateDir)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(binDir)
+	if err != nil || !info.IsDir() {
+		return
+	}
+	current := os.Getenv("PATH")
+	for _, entry := range filepath.SplitList(current) {
+		if filepath.Clean(entry) == binDir {
+			return
+		}
+	}
+	if current == "" {
+		_ = os.Setenv("PATH", binDir)
+		return
+	}
+	_ = os.Setenv("PATH", current+string(os.PathListSeparator)+binDir)
+}
+
+// InstallFFmpeg fetches an ffmpeg archive, verifies its SHA-256, and extracts
+// ffmpeg/ffprobe into the managed bin dir. Existing binaries are kept unless
+// Force is set.
+func InstallFFmpeg(ctx context.Context, opts InstallFFmpegOptions) (InstallFFmpegResult, error) {
+	result := InstallFFmpegResult{}
+	expected, err := normalizeSHA256(opts.SHA256)
+	if err != nil {
+		return result, err
+	}
+	binDir, err := ManagedBinDir(opts.StateDir)
+	if err != nil {
+		return result, err
+	}
+	result.BinDir = binDir
+	result.SHA256 = expected
+
+	source, name, err := resolveFFmpegSource(opts)
+	if err != nil {
+		return result, err
+	}
+	result.Source = source
+
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		return result, err
+	}
+	archive, err := os.CreateTemp(filepath.Dir(binDir), ".udl-ffmpeg-*.download")
+	if err != nil {
+		return result, err
+	}
+	archivePath := archive.Name()
+	defer func() {
+		_ = archive.Close()
+		_ = os.Remove(archivePath)
+	}()
+
+	hasher := sha256.New()
+	if err := copyFFmpegArchive(ctx, opts, io.MultiWriter(archive, hasher)); err != nil {
+		return result, err
+	}
+	if actual := hex.EncodeToString(hasher.Sum(nil)); actual != expected {
+		return result, fmt.Errorf("checksum mismatch for %s: expected sha256 %s, got %s", name, expected, actual)
+	}
+
+	wanted := map[string]string{}
+	for _, binary := range FFmpegBinaries {
+		wanted[executableName(binary)] = binary
+	}
+	extracted, err := extractFFmpegBinaries(archivePath, name, wanted, binDir, opts.Force)
+	if err != nil {
+		return result, err
+	}
+	for _, binary := range FFmpegBinaries {
+		switch extracted[binary] {
+		case extractInstalled:
+			result.Installed = append(result.Installed, binary)
+		case extractSkipped:
+			result.Skipped = append(result.Skipped, binary)
+		}
+	}
+	if len(result.Installed) == 0 && len(result.Skipped) == 0 {
+		return result, fmt.Errorf("%s contains neither ffmpeg nor ffprobe", name)
+	}
+	if len(result.Installed) > 0 {
+		if err := writeFFmpegManifest(binDir, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func resolveFFmpegSource(opts InstallFFmpegOptions) (string, string, error) {
+	rawURL := strings.TrimSpace(opts.URL)
+	archivePath := strings.TrimSpace(opts.ArchivePath)
+	switch {
+	case rawURL != "" && archivePath != "":
+		return "", "", fmt.Errorf("set only one of url or archive path")
+	case archivePath != "":
+		expanded, err := config.ExpandPath(archivePath)
+		if err != nil {
+			return "", "", err
+		}
+		return expanded, filepath.Base(expanded), nil
+	case rawURL != "":
+		parsed, err := url.Parse(rawURL)
+		if err != nil {
+			return "", "", fmt.Errorf("parse url: %w", err)
+		}
+		if parsed.Scheme != "https" {
+			return "", "", fmt.Errorf("ffmpeg archive url must use https")
+		}
+		return rawURL, path.Base(parsed.Path), nil
+	default:
+		return "", "", fmt.Errorf("an archive url or local archive path is required")
+	}
+}
+
+func copyFFmpegArchive(ctx context.Context, opts InstallFFmpegOptions, dst io.Writer) error {
+	if archivePath := strings.TrimSpace(opts.ArchivePath); archivePath != "" {
+		expanded, err := config.ExpandPath(archivePath)
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(expanded)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(dst, io.LimitReader(file, maxFFmpegArchiveSize))
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSpace(opts.URL), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "udl/ffmpeg-installer")
+	resp, err := httpDownloadClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("download ffmpeg archive: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("download ffmpeg archive: unexpected status %d", resp.StatusCode)
+	}
+	written, err := io.Copy(dst, io.LimitReader(resp.Body, maxFFmpegArchiveSize+1))
+	if err != nil {
+		return fmt.Errorf("download ffmpeg archive: %w", err)
+	}
+	if written > maxFFmpegArchiveSize {
+		return fmt.Errorf("download ffmpeg archive: exceeds %d MiB limit", maxFFmpegArchiveSize>>20)
+	}
+	return nil
+}
+
+type extractOutcome int
+
+const (
+	extractNone extractOutcome = iota
+	extractInstalled
+	extractSkipped
+)
+
+// extractFFmpegBinaries copies matching archive members into binDir. Members
+// are matched on base name only, so nested release layouts such as
+// ffmpeg-7.0-amd64-static/ffprobe are supported without path traversal risk.
+func extractFFmpegBinaries(
+	archivePath string,
+	name string,
+	wanted map[string]string,
+	binDir string,
+	force bool,
+) (map[string]extractOutcome, error) {
+	outcomes := map[string]extractOutcome{}
+	install := func(member string, reader io.Reader) error {
+		binary, ok := wanted[path.Base(filepath.ToSlash(member))]
+		if !ok || outcomes[binary] != extractNone {
+			return nil
+		}
+		target := filepath.Join(binDir, executableName(binary))
+		if _, err := os.Stat(target); err == nil && !force {
+			outcomes[binary] = extractSkipped
+			return nil
+		}
+		if err := writeExecutableAtomically(target, reader); err != nil {
+			return fmt.Errorf("install %s: %w", binary, err)
+		}
+		outcomes[binary] = extractInstalled
+		return nil
+	}
+
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		archive, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("open zip archive: %w", err)
+		}
+		defer archive.Close()
+		for _, member := range archive.File {
+			if member.FileInfo().IsDir() {
+				continue
+			}
+			reader, err := member.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = install(member.Name, reader)
+			_ = reader.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"), strings.HasSuffix(lower, ".tar"):
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		var stream io.Reader = file
+		if !strings.HasSuffix(lower, ".tar") {
+			gz, err := gzip.NewReader(file)
+			if err != nil {
+				return nil, fmt.Errorf("open gzip archive: %w", err)
+			}
+			defer gz.Close()
+			stream = gz
+		}
+		tr := tar.NewReader(stream)
+		for {
+			header, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("read tar archive: %w", err)
+			}
+			if header.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := install(header.Name, tr); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported archive format %q (use .zip, .tar.gz, or .tar)", name)
+	}
+	return outcomes, nil
+}
+
+func writeExecutableAtomically(target string, reader io.Reader) error {
+	temp, err := os.CreateTemp(filepath.Dir(target), ".udl-tool-*.tmp")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+	success := false
+	defer func() {
+		_ = temp.Close()
+		if !success {
+			_ = os.Remove(tempPath)
+		}
+	}()
+	if _, err := io.Copy(temp, io.LimitReader(reader, maxFFmpegArchiveSize)); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tempPath, 0o755); err != nil {
+		return err
+	}
+	if err := fileops.ReplaceFileSafely(tempPath, target); err != nil {
+		return err
+	}
+	success = true
+	return nil
+}
+
+func writeFFmpegManifest(binDir string, result InstallFFmpegResult) error {
+	manifestPath := filepath.Join(filepath.Dir(binDir), ffmpegManifestName)
+	binaries := append([]string{}, result.Installed...)
+	binaries = append(binaries, result.Skipped...)
+	sort.Strings(binaries)
+	payload, err := json.MarshalIndent(ffmpegManifest{
+		Source:      result.Source,
+		SHA256:      result.SHA256,
+		Binaries:    binaries,
+		InstalledAt: nowFn().UTC(),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(manifestPath, append(payload, '\n'), 0o644)
+}
+
+func normalizeSHA256(raw string) (string, error) {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	value = strings.TrimPrefix(value, "sha256:")
+	if value == "" {
+		return "", fmt.Errorf("sha256 checksum is required")
+	}
+	decoded, err := hex.DecodeString(value)
+	if err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("sha256 checksum must be 64 hex characters")
+	}
+	return value, nil
+}
+
+func executableName(binary string) string {
+	if runtime.GOOS == "windows" {
+		return binary + ".exe"
+	}
+	return binary
+}
@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("tar write: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar close: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("gzip close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInstallFFmpegFromArchiveVerifiesChecksumAndExtractsNestedBinaries(t *testing.T) {
+	tmp := t.TempDir()
+	payload := buildTarGz(t, map[string]string{
+		"ffmpeg-7.0-static/" + executableName("ffmpeg"):  "ffmpeg-bin",
+		"ffmpeg-7.0-static/" + executableName("ffprobe"): "ffprobe-bin",
+		"ffmpeg-7.0-static/readme.txt":                   "docs",
+	})
+	archivePath := filepath.Join(tmp, "ffmpeg-release.tar.gz")
+	if err := os.WriteFile(archivePath, payload, 0o644); err != nil {
+		t.Fatalf("write archive: %v", err)
+	}
+	stateDir := filepath.Join(tmp, "state")
+
+	_, err := InstallFFmpeg(context.Background(), InstallFFmpegOptions{
+		StateDir:    stateDir,
+		ArchivePath: archivePath,
+		SHA256:      strings.Repeat("0", 64),
+	})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	binDir := filepath.Join(stateDir, "tools", "bin")
+	if _, statErr := os.Stat(filepath.Join(binDir, executableName("ffmpeg"))); statErr == nil {
+		t.Fatalf("expected no binary installed after checksum mismatch")
+	}
+
+	result, err := InstallFFmpeg(context.Background(), InstallFFmpegOptions{
+		StateDir:    stateDir,
+		ArchivePath: archivePath,
+		SHA256:      "sha256:" + strings.ToUpper(sha256Hex(payload)),
+	})
+	if err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if strings.Join(result.Installed, ",") != "ffmpeg,ffprobe" || result.BinDir != binDir {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	got, err := os.ReadFile(filepath.Join(binDir, executableName("ffprobe")))
+	if err != nil || string(got) != "ffprobe-bin" {
+		t.Fatalf("unexpected ffprobe content %q (%v)", string(got), err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, "tools", ffmpegManifestName)); err != nil {
+		t.Fatalf("expected manifest: %v", err)
+	}
+
+	again, err := InstallFFmpeg(context.Background(), InstallFFmpegOptions{
+		StateDir:    stateDir,
+		ArchivePath: archivePath,
+		SHA256:      sha256Hex(payload),
+	})
+	if err != nil {
+		t.Fatalf("reinstall: %v", err)
+	}
+	if len(again.Installed) != 0 || strings.Join(again.Skipped, ",") != "ffmpeg,ffprobe" {
+		t.Fatalf("expected existing binaries to be kept without --force, got %+v", again)
+	}
+}
+
+func TestInstallFFmpegDownloadsZipOverHTTPS(t *testing.T) {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	writer, err := zw.Create(executableName("ffprobe"))
+	if err != nil {
+		t.Fatalf("zip create: %v", err)
+	}
+	_, _ = writer.Write([]byte("ffprobe-bin"))
+	if err := zw.Close(); err != nil {
+		t.Fatalf("zip close: %v", err)
+	}
+	payload := buf.Bytes()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(payload)
+	}))
+	defer server.Close()
+	originalClient := httpDownloadClient
+	t.Cleanup(func() { httpDownloadClient = originalClient })
+	httpDownloadClient = server.Client()
+
+	stateDir := filepath.Join(t.TempDir(), "state")
+	result, err := InstallFFmpeg(context.Background(), InstallFFmpegOptions{
+		StateDir: stateDir,
+		URL:      server.URL + "/ffprobe-7.0.zip",
+		SHA256:   sha256Hex(payload),
+	})
+	if err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if strings.Join(result.Installed, ",") != "ffprobe" {
+		t.Fatalf("expected ffprobe only, got %+v", result)
+	}
+
+	_, err = InstallFFmpeg(context.Background(), InstallFFmpegOptions{
+		StateDir: stateDir,
+		URL:      "http://example.com/ffmpeg.zip",
+		SHA256:   sha256Hex(payload),
+	})
+	if err == nil || !strings.Contains(err.Error(), "https") {
+		t.Fatalf("expected https requirement, got %v", err)
+	}
+}
+
+func TestActivateManagedBinDirAppendsOnce(t *testing.T) {
+	stateDir := t.TempDir()
+	binDir := filepath.Join(stateDir, "tools", "bin")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Setenv("PATH", "/usr/bin")
+	ActivateManagedBinDir(stateDir)
+	ActivateManagedBinDir(stateDir)
+	want := "/usr/bin" + string(os.PathListSeparator) + binDir
+	if got := os.Getenv("PATH"); got != want {
+		t.Fatalf("expected PATH %q, got %q", want, got)
+	}
+}